@@ -0,0 +1,109 @@
+package addr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Length is the size of an address in bytes.
+const Length = 20
+
+var ErrInvalidLength = errors.New("address must be 20 bytes")
+
+// Address is a 20-byte account or contract address.
+//
+// It is the single address type used across the registries and the console,
+// so hex parsing and formatting live in one place.
+type Address [Length]byte
+
+// Parse decodes a hex address with or without the "0x" prefix.
+// Checksum casing is accepted but not enforced.
+func Parse(s string) (Address, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		s = s[2:]
+	}
+	if len(s) != Length*2 {
+		return Address{}, fmt.Errorf("%w: got %d hex characters", ErrInvalidLength, len(s))
+	}
+
+	var a Address
+	if _, err := hex.Decode(a[:], []byte(s)); err != nil {
+		return Address{}, fmt.Errorf("invalid address hex: %w", err)
+	}
+	return a, nil
+}
+
+// FromBytes copies a 20-byte slice into an Address.
+func FromBytes(b []byte) (Address, error) {
+	if len(b) != Length {
+		return Address{}, fmt.Errorf("%w: got %d bytes", ErrInvalidLength, len(b))
+	}
+	return Address(b), nil
+}
+
+// FromCommon converts a go-ethereum address.
+func FromCommon(a common.Address) Address {
+	return Address(a)
+}
+
+// Common converts the address to its go-ethereum equivalent.
+func (a Address) Common() common.Address {
+	return common.Address(a)
+}
+
+// Bytes returns the raw address bytes.
+func (a Address) Bytes() []byte {
+	return a[:]
+}
+
+// Hex returns the lowercase hex representation with a "0x" prefix.
+func (a Address) Hex() string {
+	return "0x" + hex.EncodeToString(a[:])
+}
+
+// Checksum returns the EIP-55 mixed-case representation with a "0x" prefix.
+func (a Address) Checksum() string {
+	return common.Address(a).Hex()
+}
+
+// String implements fmt.Stringer using the checksummed form.
+func (a Address) String() string {
+	return a.Checksum()
+}
+
+// Equal reports whether a and b are the same address.
+func (a Address) Equal(b Address) bool {
+	return a == b
+}
+
+// Less reports whether a is numerically less than b, the order in which Uniswap pools
+// sort their tokens.
+func (a Address) Less(b Address) bool {
+	return bytes.Compare(a[:], b[:]) < 0
+}
+
+// IsZero reports whether the address is all zeros.
+func (a Address) IsZero() bool {
+	return a == Address{}
+}
+
+// MarshalText serializes the address as lowercase hex, matching go-ethereum's JSON form.
+func (a Address) MarshalText() ([]byte, error) {
+	return []byte(a.Hex()), nil
+}
+
+// UnmarshalText parses a hex address with or without the "0x" prefix.
+func (a *Address) UnmarshalText(text []byte) error {
+	parsed, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
@@ -0,0 +1,127 @@
+package addr
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	const checksummed = "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"
+	const lower = "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2"
+	expected := Address(common.HexToAddress(checksummed))
+
+	t.Run("WithPrefix", func(t *testing.T) {
+		a, err := Parse(checksummed)
+		require.NoError(t, err)
+		assert.Equal(t, expected, a)
+	})
+
+	t.Run("WithoutPrefix", func(t *testing.T) {
+		a, err := Parse(lower[2:])
+		require.NoError(t, err)
+		assert.Equal(t, expected, a)
+	})
+
+	t.Run("UppercasePrefixAndWhitespace", func(t *testing.T) {
+		a, err := Parse("  0X" + lower[2:] + "\n")
+		require.NoError(t, err)
+		assert.Equal(t, expected, a)
+	})
+
+	t.Run("WrongLength", func(t *testing.T) {
+		for _, input := range []string{"", "0x", "0x1234", lower + "00", lower[:len(lower)-1]} {
+			_, err := Parse(input)
+			assert.ErrorIs(t, err, ErrInvalidLength, "input %q", input)
+		}
+	})
+
+	t.Run("InvalidHex", func(t *testing.T) {
+		_, err := Parse("0xzz2aaa39b223fe8d0a0e5c4f27ead9083c756cc2")
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, ErrInvalidLength)
+	})
+}
+
+func TestFormatting(t *testing.T) {
+	// EIP-55 test vectors.
+	vectors := []string{
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+		"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb",
+	}
+
+	for _, v := range vectors {
+		a, err := Parse(v)
+		require.NoError(t, err)
+		assert.Equal(t, v, a.Checksum())
+		assert.Equal(t, v, a.String())
+
+		lowered, err := Parse(a.Hex())
+		require.NoError(t, err)
+		assert.True(t, a.Equal(lowered), "Hex output should round-trip")
+		assert.Equal(t, "0x"+common.Bytes2Hex(a.Bytes()), a.Hex())
+	}
+}
+
+func TestEqual(t *testing.T) {
+	a := Address{1}
+	b := Address{1}
+	c := Address{2}
+
+	assert.True(t, a.Equal(b))
+	assert.False(t, a.Equal(c))
+	assert.True(t, Address{}.IsZero())
+	assert.False(t, a.IsZero())
+}
+
+func TestLess(t *testing.T) {
+	low := Address(common.HexToAddress("0x00ff000000000000000000000000000000000000"))
+	high := Address(common.HexToAddress("0x0100000000000000000000000000000000000000"))
+
+	assert.True(t, low.Less(high))
+	assert.False(t, high.Less(low))
+	assert.False(t, low.Less(low))
+}
+
+func TestConversions(t *testing.T) {
+	c := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	a := FromCommon(c)
+	assert.Equal(t, c, a.Common())
+
+	fromBytes, err := FromBytes(c.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, a, fromBytes)
+
+	_, err = FromBytes(make([]byte, 19))
+	assert.ErrorIs(t, err, ErrInvalidLength)
+}
+
+func TestJSONCompatibility(t *testing.T) {
+	type wrapper struct {
+		Address Address `json:"address"`
+	}
+	type commonWrapper struct {
+		Address common.Address `json:"address"`
+	}
+
+	c := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+
+	// The wire format must match go-ethereum's so existing payloads keep decoding.
+	expected, err := json.Marshal(commonWrapper{Address: c})
+	require.NoError(t, err)
+	got, err := json.Marshal(wrapper{Address: FromCommon(c)})
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(got))
+
+	var decoded wrapper
+	require.NoError(t, json.Unmarshal(expected, &decoded))
+	assert.Equal(t, FromCommon(c), decoded.Address)
+
+	err = json.Unmarshal([]byte(`{"address":"0x1234"}`), &decoded)
+	assert.Error(t, err)
+}
@@ -0,0 +1,208 @@
+// Package analytics computes the total value locked (TVL) of every pool and protocol of a
+// state, valued at the prices of the pricing package, and how it changes from block to
+// block. A Tracker follows a stream and can export the figures as Prometheus gauges, so
+// dashboards can be driven directly from the state stream.
+package analytics
+
+import (
+	"math/big"
+
+	"github.com/defistate/defistate-client-go/engine"
+	"github.com/defistate/defistate-client-go/pricing"
+	"github.com/defistate/defistate-client-go/protocols/solidly"
+	"github.com/defistate/defistate-client-go/protocols/tokenregistry"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	uniswapv3calculator "github.com/defistate/defistate-client-go/protocols/uniswapv3/calculator"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv4"
+	uniswapv4calculator "github.com/defistate/defistate-client-go/protocols/uniswapv4/calculator"
+)
+
+// Prices looks up token prices. *pricing.Prices implements it.
+type Prices interface {
+	Price(tokenID uint64) (pricing.Price, bool)
+}
+
+// PoolTVL is the value locked in one pool.
+type PoolTVL struct {
+	PoolID   uint64
+	Protocol engine.ProtocolID
+	Token0   uint64
+	Token1   uint64
+	// Reserve0 and Reserve1 are the raw token amounts held by the pool. For concentrated
+	// liquidity pools they are the virtual reserves of the active liquidity at the current
+	// price (see uniswapv3calculator.VirtualReserves), so liquidity in other ticks is not
+	// counted.
+	Reserve0 *big.Int
+	Reserve1 *big.Int
+	// Value is the worth of both reserves in the numeraire of the prices.
+	Value float64
+	// Unpriced is true if a token of the pool has no price or decimals; its reserve
+	// counts as worthless.
+	Unpriced bool
+}
+
+// ProtocolTVL is the value locked in one protocol.
+type ProtocolTVL struct {
+	Protocol      engine.ProtocolID
+	Schema        engine.ProtocolSchema
+	Value         float64
+	Pools         int
+	UnpricedPools int
+}
+
+// Snapshot is the TVL of a state.
+type Snapshot struct {
+	Block     uint64
+	Total     float64
+	Protocols map[engine.ProtocolID]ProtocolTVL
+	Pools     map[uint64]PoolTVL
+}
+
+// Compute values the pools of state at prices. Protocols whose data holds no pools, such
+// as the registries, are left out. Token decimals are taken from the token registry of
+// state.
+func Compute(state *engine.State, prices Prices) *Snapshot {
+	snapshot := &Snapshot{
+		Protocols: make(map[engine.ProtocolID]ProtocolTVL),
+		Pools:     make(map[uint64]PoolTVL),
+	}
+	if state.Block.Number != nil {
+		snapshot.Block = state.Block.Number.Uint64()
+	}
+
+	decimals := make(map[uint64]uint8)
+	for _, protocol := range state.Protocols {
+		if tokens, ok := protocol.Data.([]tokenregistry.Token); ok {
+			for _, token := range tokens {
+				decimals[token.ID] = token.Decimals
+			}
+		}
+	}
+	valuer := &valuer{prices: prices, decimals: decimals}
+
+	for id, protocol := range state.Protocols {
+		pools, ok := poolReserves(protocol.Data)
+		if !ok {
+			continue
+		}
+		protocolTVL := ProtocolTVL{Protocol: id, Schema: protocol.Schema, Pools: len(pools)}
+		for _, pool := range pools {
+			pool.Protocol = id
+			valuer.value(&pool)
+			if pool.Unpriced {
+				protocolTVL.UnpricedPools++
+			}
+			protocolTVL.Value += pool.Value
+			snapshot.Pools[pool.PoolID] = pool
+		}
+		snapshot.Protocols[id] = protocolTVL
+		snapshot.Total += protocolTVL.Value
+	}
+	return snapshot
+}
+
+// valuer values reserves in the numeraire.
+type valuer struct {
+	prices   Prices
+	decimals map[uint64]uint8
+}
+
+func (v *valuer) value(pool *PoolTVL) {
+	for _, side := range []struct {
+		token   uint64
+		reserve *big.Int
+	}{{pool.Token0, pool.Reserve0}, {pool.Token1, pool.Reserve1}} {
+		price, priced := v.prices.Price(side.token)
+		decimals, known := v.decimals[side.token]
+		if !priced || !known || price.Value == nil {
+			pool.Unpriced = true
+			continue
+		}
+		if side.reserve == nil || side.reserve.Sign() == 0 {
+			continue
+		}
+		amount := new(big.Float).Quo(new(big.Float).SetInt(side.reserve), new(big.Float).SetInt(pow10(decimals)))
+		value, _ := amount.Mul(amount, price.Value).Float64()
+		pool.Value += value
+	}
+}
+
+// poolReserves returns the pools of a protocol's data with their reserves, or false if
+// the data holds no pools.
+func poolReserves(data any) ([]PoolTVL, bool) {
+	switch pools := data.(type) {
+	case []uniswapv2.Pool:
+		tvls := make([]PoolTVL, len(pools))
+		for i, p := range pools {
+			tvls[i] = PoolTVL{PoolID: p.ID, Token0: p.Token0, Token1: p.Token1, Reserve0: p.Reserve0, Reserve1: p.Reserve1}
+		}
+		return tvls, true
+	case []solidly.Pool:
+		tvls := make([]PoolTVL, len(pools))
+		for i, p := range pools {
+			tvls[i] = PoolTVL{PoolID: p.ID, Token0: p.Token0, Token1: p.Token1, Reserve0: p.Reserve0, Reserve1: p.Reserve1}
+		}
+		return tvls, true
+	case []uniswapv3.Pool:
+		tvls := make([]PoolTVL, len(pools))
+		for i, p := range pools {
+			reserve0, reserve1 := uniswapv3calculator.VirtualReserves(p)
+			tvls[i] = PoolTVL{PoolID: p.ID, Token0: p.Token0, Token1: p.Token1, Reserve0: reserve0, Reserve1: reserve1}
+		}
+		return tvls, true
+	case []uniswapv4.Pool:
+		tvls := make([]PoolTVL, len(pools))
+		for i, p := range pools {
+			reserve0, reserve1, _ := uniswapv4calculator.GetVirtualReserves(p.Token0, p.Token1, p)
+			tvls[i] = PoolTVL{PoolID: p.ID, Token0: p.Token0, Token1: p.Token1, Reserve0: reserve0, Reserve1: reserve1}
+		}
+		return tvls, true
+	}
+	return nil, false
+}
+
+func pow10(decimals uint8) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+}
+
+// Delta is how the TVL changed between two snapshots. Pools and protocols that appear or
+// disappear count with their whole value.
+type Delta struct {
+	FromBlock uint64
+	ToBlock   uint64
+	Total     float64
+	Protocols map[engine.ProtocolID]float64
+	// Pools holds only the pools whose value changed.
+	Pools map[uint64]float64
+}
+
+// Diff returns the change from prev to next.
+func Diff(prev, next *Snapshot) *Delta {
+	delta := &Delta{
+		FromBlock: prev.Block,
+		ToBlock:   next.Block,
+		Total:     next.Total - prev.Total,
+		Protocols: make(map[engine.ProtocolID]float64),
+		Pools:     make(map[uint64]float64),
+	}
+	for id, protocol := range next.Protocols {
+		delta.Protocols[id] = protocol.Value - prev.Protocols[id].Value
+	}
+	for id, protocol := range prev.Protocols {
+		if _, ok := next.Protocols[id]; !ok {
+			delta.Protocols[id] = -protocol.Value
+		}
+	}
+	for id, pool := range next.Pools {
+		if change := pool.Value - prev.Pools[id].Value; change != 0 {
+			delta.Pools[id] = change
+		}
+	}
+	for id, pool := range prev.Pools {
+		if _, ok := next.Pools[id]; !ok && pool.Value != 0 {
+			delta.Pools[id] = -pool.Value
+		}
+	}
+	return delta
+}
@@ -0,0 +1,127 @@
+package analytics
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/defistate/defistate-client-go/engine"
+	"github.com/defistate/defistate-client-go/pricing"
+	"github.com/defistate/defistate-client-go/protocols/tokenregistry"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	jsonrpcclient "github.com/defistate/defistate-client-go/streams/jsonrpc/client"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fixedPrices map[uint64]float64
+
+func (p fixedPrices) Price(tokenID uint64) (pricing.Price, bool) {
+	value, ok := p[tokenID]
+	if !ok {
+		return pricing.Price{}, false
+	}
+	return pricing.Price{Value: big.NewFloat(value)}, true
+}
+
+var prices = fixedPrices{1: 1, 2: 3000}
+
+func bigInt(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("invalid big int " + s)
+	}
+	return n
+}
+
+// testState has a USDC/WETH pool worth 2,000 USD, a WETH/UNKNOWN pool worth 3,000 USD of
+// WETH, and an empty Uniswap V3 pool.
+func testState(block int64, usdcReserve string) *engine.State {
+	return &engine.State{
+		Block: engine.BlockSummary{Number: big.NewInt(block)},
+		Protocols: map[engine.ProtocolID]engine.ProtocolState{
+			"tokens": {Schema: tokenregistry.Schema, Data: []tokenregistry.Token{
+				{ID: 1, Symbol: "USDC", Decimals: 6},
+				{ID: 2, Symbol: "WETH", Decimals: 18},
+				{ID: 3, Symbol: "UNKNOWN", Decimals: 18},
+			}},
+			"uniswap_v2": {Schema: uniswapv2.Schema, Data: []uniswapv2.Pool{
+				{ID: 10, Token0: 1, Token1: 2, Reserve0: bigInt(usdcReserve), Reserve1: bigInt("333333333333333333")},
+				{ID: 11, Token0: 2, Token1: 3, Reserve0: bigInt("1000000000000000000"), Reserve1: bigInt("5")},
+			}},
+			"uniswap_v3": {Schema: uniswapv3.Schema, Data: []uniswapv3.Pool{
+				{PoolViewMinimal: uniswapv3.PoolViewMinimal{ID: 20, Token0: 1, Token1: 2}},
+			}},
+		},
+	}
+}
+
+func TestCompute(t *testing.T) {
+	snapshot := Compute(testState(100, "1000000000"), prices)
+
+	assert.Equal(t, uint64(100), snapshot.Block)
+	assert.InDelta(t, 2000, snapshot.Pools[10].Value, 0.01)
+	assert.False(t, snapshot.Pools[10].Unpriced)
+	assert.InDelta(t, 3000, snapshot.Pools[11].Value, 0.01, "the unpriced side counts as worthless")
+	assert.True(t, snapshot.Pools[11].Unpriced)
+	assert.Zero(t, snapshot.Pools[20].Value)
+
+	t.Run("concentrated liquidity pools count their virtual reserves", func(t *testing.T) {
+		// At a price of 1 both virtual reserves equal the active liquidity.
+		state := testState(100, "1000000000")
+		state.Protocols["uniswap_v3"] = engine.ProtocolState{Schema: uniswapv3.Schema, Data: []uniswapv3.Pool{
+			{PoolViewMinimal: uniswapv3.PoolViewMinimal{ID: 20, Token0: 1, Token1: 2, Liquidity: big.NewInt(5_000_000), SqrtPriceX96: new(big.Int).Lsh(big.NewInt(1), 96)}},
+		}}
+		pool := Compute(state, prices).Pools[20]
+		assert.Equal(t, big.NewInt(5_000_000), pool.Reserve0)
+		assert.Equal(t, big.NewInt(5_000_000), pool.Reserve1)
+		assert.InDelta(t, 5, pool.Value, 0.01, "5 USDC and a negligible amount of WETH")
+	})
+
+	v2 := snapshot.Protocols["uniswap_v2"]
+	assert.Equal(t, uniswapv2.Schema, v2.Schema)
+	assert.InDelta(t, 5000, v2.Value, 0.01)
+	assert.Equal(t, 2, v2.Pools)
+	assert.Equal(t, 1, v2.UnpricedPools)
+	assert.NotContains(t, snapshot.Protocols, engine.ProtocolID("tokens"))
+	assert.InDelta(t, 5000, snapshot.Total, 0.01)
+}
+
+func TestTracker(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	tracker := NewTracker(registry)
+
+	_, delta := tracker.Update(testState(100, "1000000000"), prices)
+	assert.Nil(t, delta)
+
+	// The USDC reserve doubles.
+	snapshot, delta := tracker.Update(testState(101, "2000000000"), prices)
+	require.NotNil(t, delta)
+	assert.Equal(t, uint64(100), delta.FromBlock)
+	assert.Equal(t, uint64(101), delta.ToBlock)
+	assert.InDelta(t, 1000, delta.Total, 0.01)
+	assert.InDelta(t, 1000, delta.Protocols["uniswap_v2"], 0.01)
+	assert.Equal(t, []uint64{10}, keys(delta.Pools))
+	assert.Same(t, snapshot, tracker.Last())
+
+	assert.InDelta(t, 6000, testutil.ToFloat64(tracker.metrics.totalTVL), 0.01)
+	assert.InDelta(t, 6000, testutil.ToFloat64(tracker.metrics.protocolTVL.WithLabelValues("uniswap_v2")), 0.01)
+	assert.Equal(t, 1.0, testutil.ToFloat64(tracker.metrics.protocolUnpriced.WithLabelValues("uniswap_v2")))
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	require.NotEmpty(t, families)
+	for _, family := range families {
+		assert.Contains(t, family.GetName(), jsonrpcclient.MetricsNamespace+"_analytics_")
+	}
+}
+
+func keys(m map[uint64]float64) []uint64 {
+	var ids []uint64
+	for id := range m {
+		ids = append(ids, id)
+	}
+	return ids
+}
@@ -0,0 +1,107 @@
+package analytics
+
+import (
+	"sync"
+
+	"github.com/defistate/defistate-client-go/engine"
+	jsonrpcclient "github.com/defistate/defistate-client-go/streams/jsonrpc/client"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Tracker computes the TVL of each state of a stream and its change since the previous
+// one. It is safe for concurrent use.
+type Tracker struct {
+	mu      sync.Mutex
+	last    *Snapshot
+	metrics *metrics
+}
+
+// NewTracker returns a Tracker. If registry is not nil, the TVL is exported as gauges
+// labeled by protocol, named like the stream health metrics (defistate_client_analytics_*).
+func NewTracker(registry prometheus.Registerer) *Tracker {
+	t := &Tracker{}
+	if registry != nil {
+		t.metrics = newMetrics(registry)
+	}
+	return t
+}
+
+// Update values state at prices. The delta is nil for the first state.
+func (t *Tracker) Update(state *engine.State, prices Prices) (*Snapshot, *Delta) {
+	snapshot := Compute(state, prices)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var delta *Delta
+	if t.last != nil {
+		delta = Diff(t.last, snapshot)
+	}
+	if t.metrics != nil {
+		t.metrics.observe(t.last, snapshot)
+	}
+	t.last = snapshot
+	return snapshot, delta
+}
+
+// Last returns the most recent snapshot, or nil before the first Update.
+func (t *Tracker) Last() *Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.last
+}
+
+// metrics holds the TVL gauges.
+type metrics struct {
+	totalTVL         prometheus.Gauge
+	protocolTVL      *prometheus.GaugeVec
+	protocolPools    *prometheus.GaugeVec
+	protocolUnpriced *prometheus.GaugeVec
+}
+
+func newMetrics(reg prometheus.Registerer) *metrics {
+	m := &metrics{
+		totalTVL: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: jsonrpcclient.MetricsNamespace,
+			Name:      "analytics_tvl_total",
+			Help:      "Total value locked across all protocols, in the numeraire of the prices.",
+		}),
+		protocolTVL: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: jsonrpcclient.MetricsNamespace,
+			Name:      "analytics_protocol_tvl",
+			Help:      "Value locked in a protocol, in the numeraire of the prices.",
+		}, []string{"protocol"}),
+		protocolPools: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: jsonrpcclient.MetricsNamespace,
+			Name:      "analytics_protocol_pools",
+			Help:      "Number of pools of a protocol.",
+		}, []string{"protocol"}),
+		protocolUnpriced: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: jsonrpcclient.MetricsNamespace,
+			Name:      "analytics_protocol_unpriced_pools",
+			Help:      "Number of pools of a protocol with a token that could not be priced.",
+		}, []string{"protocol"}),
+	}
+	reg.MustRegister(m.totalTVL, m.protocolTVL, m.protocolPools, m.protocolUnpriced)
+	return m
+}
+
+// observe sets the gauges to snapshot, removing the series of protocols that are gone
+// since prev.
+func (m *metrics) observe(prev, snapshot *Snapshot) {
+	m.totalTVL.Set(snapshot.Total)
+	for id, protocol := range snapshot.Protocols {
+		m.protocolTVL.WithLabelValues(string(id)).Set(protocol.Value)
+		m.protocolPools.WithLabelValues(string(id)).Set(float64(protocol.Pools))
+		m.protocolUnpriced.WithLabelValues(string(id)).Set(float64(protocol.UnpricedPools))
+	}
+	if prev == nil {
+		return
+	}
+	for id := range prev.Protocols {
+		if _, ok := snapshot.Protocols[id]; !ok {
+			m.protocolTVL.DeleteLabelValues(string(id))
+			m.protocolPools.DeleteLabelValues(string(id))
+			m.protocolUnpriced.DeleteLabelValues(string(id))
+		}
+	}
+}
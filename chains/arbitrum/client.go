@@ -2,8 +2,10 @@ package arbitrum
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/defistate/defistate-client-go/chains"
@@ -15,6 +17,8 @@ import (
 
 	"github.com/defistate/defistate-client-go/protocols/poolregistry"
 	poolregistryindexer "github.com/defistate/defistate-client-go/protocols/poolregistry/indexer"
+	"github.com/defistate/defistate-client-go/protocols/solidly"
+	solidlyindexer "github.com/defistate/defistate-client-go/protocols/solidly/indexer"
 	"github.com/defistate/defistate-client-go/protocols/tokenpoolregistry"
 	tokenregistry "github.com/defistate/defistate-client-go/protocols/tokenregistry"
 	tokenregistryindexer "github.com/defistate/defistate-client-go/protocols/tokenregistry/indexer"
@@ -38,6 +42,21 @@ type Client struct {
 	poolRegistryIndexer chains.PoolRegistryIndexer
 	uniswapV2Indexer    chains.UniswapV2Indexer
 	uniswapV3Indexer    chains.UniswapV3Indexer
+	solidlyIndexer      chains.SolidlyIndexer
+
+	// stateStore, if set, persists every state that is processed successfully.
+	stateStore chains.StateStore
+
+	// diffs, if set, holds the diffs the stream patched its states with, so a state
+	// patched from the last processed one updates its graph instead of rebuilding it.
+	diffs *chains.DiffTracker
+	// lastRaw is the last state processed successfully, and last its processed form.
+	lastRaw *engine.State
+	last    *State
+
+	// droppedStates counts processed states discarded because the consumer fell behind.
+	droppedStates        atomic.Uint64
+	droppedStatesCounter prometheus.Counter
 
 	ctx context.Context
 	wg  sync.WaitGroup
@@ -77,13 +96,15 @@ func Dial(
 		return nil, fmt.Errorf("failed to create state ops: %w", err)
 	}
 
+	diffs := chains.NewDiffTracker()
 	clientCfg := jsonrpcclient.Config{
 		URL:              url,
 		Logger:           logger,
 		BufferSize:       100,
-		StatePatcher:     stateOps.Patch,
+		StatePatcher:     diffs.Patcher(stateOps.Patch),
 		StateDecoder:     stateOps.DecodeStateJSON,
 		StateDiffDecoder: stateOps.DecodeStateDiffJSON,
+		Registry:         prometheusRegistry,
 	}
 
 	client, err := jsonrpcclient.NewClient(ctx, clientCfg)
@@ -106,7 +127,10 @@ func Dial(
 		tokenPoolGrapher:    tokenPoolGrapher,
 		uniswapV2Indexer:    uniswapv2indexer.New(),
 		uniswapV3Indexer:    uniswapv3indexer.New(),
+		solidlyIndexer:      solidlyindexer.New(),
+		diffs:               diffs,
 	}
+	p.droppedStatesCounter = newDroppedStatesCounter(prometheusRegistry)
 
 	for _, opt := range opts {
 		opt.apply(p)
@@ -143,7 +167,9 @@ func FromStream(
 		tokenPoolGrapher:    tokenPoolGrapher,
 		uniswapV2Indexer:    uniswapv2indexer.New(),
 		uniswapV3Indexer:    uniswapv3indexer.New(),
+		solidlyIndexer:      solidlyindexer.New(),
 	}
+	p.droppedStatesCounter = newDroppedStatesCounter(prometheusRegistry)
 	for _, opt := range opts {
 		opt.apply(p)
 	}
@@ -158,6 +184,7 @@ func FromStream(
 }
 
 // State channel is best-effort; if consumer is slow, updates may be dropped
+// (each drop is logged at warn level and counted; see DroppedStates)
 func (p *Client) State() <-chan *State {
 	return p.stateCh
 }
@@ -199,23 +226,72 @@ func (p *Client) loop() {
 				continue
 			}
 
+			p.storeState(rawState)
+
 			select {
 			case p.stateCh <- processed:
 			case <-p.ctx.Done():
 				return
 			default:
-				p.logger.Warn("State buffer full, discarding processed state...", "block", rawState.Block.Number)
+				p.dropState(processed)
 			}
 		}
 	}
 }
 
+// storeState writes rawState through the configured StateStore, if any. A failed write
+// is logged and does not hold back the state.
+func (p *Client) storeState(rawState *engine.State) {
+	if p.stateStore == nil {
+		return
+	}
+	block := rawState.Block.Number.Uint64()
+	if err := p.stateStore.Put(block, rawState); err != nil {
+		p.logger.Error("Failed to store state", "block", block, "err", err)
+	}
+}
+
+// DroppedStates returns how many processed states were discarded because the
+// State channel was full.
+func (p *Client) DroppedStates() uint64 {
+	return p.droppedStates.Load()
+}
+
+// dropState records a processed state that was discarded because the consumer is not
+// keeping up. Frequent drops point at a slow consumer of State().
+func (p *Client) dropState(state *State) {
+	dropped := p.droppedStates.Add(1)
+	if p.droppedStatesCounter != nil {
+		p.droppedStatesCounter.Inc()
+	}
+	p.logger.Warn("State buffer full, discarding processed state", "block", state.Block.Number, "dropped_total", dropped)
+}
+
+// newDroppedStatesCounter creates and registers the dropped states counter. Clients
+// sharing a registry share the counter, so building a second client does not panic.
+func newDroppedStatesCounter(reg prometheus.Registerer) prometheus.Counter {
+	counter := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: jsonrpcclient.MetricsNamespace,
+		Name:      "dropped_states_total",
+		Help:      "Total number of processed states discarded because the state buffer was full.",
+	})
+	if err := reg.Register(counter); err != nil {
+		var registered prometheus.AlreadyRegisteredError
+		if errors.As(err, &registered) {
+			return registered.ExistingCollector.(prometheus.Counter)
+		}
+		panic(err)
+	}
+	return counter
+}
+
 type State struct {
 	Graph               chains.TokenPoolGraph
 	IndexedTokenSystem  tokenregistryindexer.IndexedTokenSystem
 	IndexedPoolRegistry poolregistryindexer.IndexedPoolRegistry
 	IndexedUniswapV2    uniswapv2indexer.IndexedUniswapV2
 	IndexedUniswapV3    uniswapv3indexer.IndexedUniswapV3
+	IndexedSolidly      solidlyindexer.IndexedSolidly
 	ProtocolResolver    *chains.ProtocolResolver
 	Block               engine.BlockSummary
 	ProcessedAtUnixNs   uint64
@@ -227,7 +303,7 @@ func (p *Client) processState(rawState *engine.State) (*State, error) {
 	p.logger.Info("New state received, starting processing", "block", rawState.Block.Number)
 
 	var wg sync.WaitGroup
-	wg.Add(4)
+	wg.Add(5)
 
 	var (
 		rawGraph         *tokenpoolregistry.TokenPoolRegistryView
@@ -236,11 +312,13 @@ func (p *Client) processState(rawState *engine.State) (*State, error) {
 
 		allUniswapV2Data []uniswapv2.Pool
 		allUniswapV3Data []uniswapv3.Pool
+		allSolidlyData   []solidly.Pool
 
 		indexedTokenSystem  tokenregistryindexer.IndexedTokenSystem
 		indexedPoolRegistry poolregistryindexer.IndexedPoolRegistry
 		indexedUniswapV2    uniswapv2indexer.IndexedUniswapV2
 		indexedUniswapV3    uniswapv3indexer.IndexedUniswapV3
+		indexedSolidly      solidlyindexer.IndexedSolidly
 	)
 
 	// first, get all data with switch on Protocol.Schema
@@ -267,6 +345,8 @@ func (p *Client) processState(rawState *engine.State) (*State, error) {
 			allUniswapV2Data = append(allUniswapV2Data, protocol.Data.([]uniswapv2.Pool)...)
 		case uniswapv3.Schema:
 			allUniswapV3Data = append(allUniswapV3Data, protocol.Data.([]uniswapv3.Pool)...)
+		case solidly.Schema:
+			allSolidlyData = append(allSolidlyData, protocol.Data.([]solidly.Pool)...)
 		}
 	}
 
@@ -274,7 +354,9 @@ func (p *Client) processState(rawState *engine.State) (*State, error) {
 		return nil, fmt.Errorf("No token pool graph data found in raw state. Block %d", rawState.Block.Number)
 	}
 	if tokenData == nil {
-		return nil, fmt.Errorf("No token system data found in raw state. Block %d", rawState.Block.Number)
+		// Token metadata is optional: the graph can still route on token IDs alone,
+		// so we degrade instead of dropping the state.
+		p.logger.Warn("No token system data found in raw state, continuing without token metadata", "block", rawState.Block.Number)
 	}
 
 	if poolRegistryData == nil {
@@ -283,7 +365,9 @@ func (p *Client) processState(rawState *engine.State) (*State, error) {
 
 	go func() {
 		defer wg.Done()
-		indexedTokenSystem = p.tokenIndexer.Index(tokenData)
+		if tokenData != nil {
+			indexedTokenSystem = p.tokenIndexer.Index(tokenData)
+		}
 	}()
 
 	go func() {
@@ -299,6 +383,10 @@ func (p *Client) processState(rawState *engine.State) (*State, error) {
 		defer wg.Done()
 		indexedUniswapV3 = p.uniswapV3Indexer.Index(allUniswapV3Data)
 	}()
+	go func() {
+		defer wg.Done()
+		indexedSolidly = p.solidlyIndexer.Index(allSolidlyData)
+	}()
 
 	wg.Wait()
 
@@ -317,14 +405,15 @@ func (p *Client) processState(rawState *engine.State) (*State, error) {
 		indexedPoolRegistry,
 	)
 
-	graph, err := p.tokenPoolGrapher.Graph(
-		rawGraph,
-		indexedTokenSystem,
-		indexedPoolRegistry,
-		indexedUniswapV2,
-		indexedUniswapV3,
-		protocolResolver,
-	)
+	graph, err := p.graph(rawState, chains.GraphDiff{
+		TokenPool:            rawGraph,
+		IndexedTokenRegistry: indexedTokenSystem,
+		IndexedPoolRegistry:  indexedPoolRegistry,
+		IndexedUniswapV2:     indexedUniswapV2,
+		IndexedUniswapV3:     indexedUniswapV3,
+		IndexedSolidly:       indexedSolidly,
+		ProtocolResolver:     protocolResolver,
+	})
 
 	if err != nil {
 		return nil, fmt.Errorf("Grapher error %v", err)
@@ -339,15 +428,41 @@ func (p *Client) processState(rawState *engine.State) (*State, error) {
 		IndexedPoolRegistry: indexedPoolRegistry,
 		IndexedUniswapV2:    indexedUniswapV2,
 		IndexedUniswapV3:    indexedUniswapV3,
+		IndexedSolidly:      indexedSolidly,
 		ProtocolResolver:    protocolResolver,
 		Block:               rawState.Block,
 		ProcessedAtUnixNs:   uint64(time.Now().UnixNano()),
 	}
+	p.lastRaw, p.last = rawState, state
 
 	return state, nil
 
 }
 
+// graph builds the graph of rawState from its views. A state the stream patched from the
+// last processed state instead updates that state's graph with ApplyDiff, which rewires
+// only the pools and tokens the diff changed; the graph is built from scratch for full
+// states and for diffs whose changes are unknown.
+func (p *Client) graph(rawState *engine.State, views chains.GraphDiff) (chains.TokenPoolGraph, error) {
+	if p.diffs != nil {
+		if diff, ok := p.diffs.Take(p.lastRaw, rawState); ok {
+			if views.ChangedPools, ok = chains.ChangedPools(diff); ok {
+				views.ChangedTokens = chains.ChangedTokens(diff)
+				return p.last.Graph.ApplyDiff(views)
+			}
+		}
+	}
+	return p.tokenPoolGrapher.Graph(
+		views.TokenPool,
+		views.IndexedTokenRegistry,
+		views.IndexedPoolRegistry,
+		views.IndexedUniswapV2,
+		views.IndexedUniswapV3,
+		views.IndexedSolidly,
+		views.ProtocolResolver,
+	)
+}
+
 // Options Constructors for the Client
 
 func WithTokenIndexer(indexer chains.TokenIndexer) Option {
@@ -374,8 +489,31 @@ func WithUniswapV3Indexer(indexer chains.UniswapV3Indexer) Option {
 	})
 }
 
+func WithSolidlyIndexer(indexer chains.SolidlyIndexer) Option {
+	return newOption(func(p *Client) {
+		p.solidlyIndexer = indexer
+	})
+}
+
 func WithTokenPoolGrapher(grapher chains.TokenPoolGrapher) Option {
 	return newOption(func(p *Client) {
 		p.tokenPoolGrapher = grapher
 	})
 }
+
+// WithDiffTracker updates the graph of each state the stream patched from the previous
+// one instead of rebuilding it, using the diffs tracker recorded. Dial sets it up itself;
+// FromStream callers wrap their stream's state patcher with tracker.Patcher.
+func WithDiffTracker(tracker *chains.DiffTracker) Option {
+	return newOption(func(p *Client) {
+		p.diffs = tracker
+	})
+}
+
+// WithStateStore persists every state the client processes successfully through store,
+// keyed by block number. See statestore.FileStore for a file-based store.
+func WithStateStore(store chains.StateStore) Option {
+	return newOption(func(p *Client) {
+		p.stateStore = store
+	})
+}
@@ -0,0 +1,127 @@
+package chains
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// ArbScannerConfig configures an ArbScanner.
+type ArbScannerConfig struct {
+	// NumeraireTokenID is the token every cycle starts and ends in; profits are measured in it.
+	NumeraireTokenID uint64
+	// AmountIn is the amount of the numeraire token fed into each cycle.
+	AmountIn *big.Int
+	// MinProfit drops cycles whose profit, in raw units of the numeraire, is below it.
+	// Nil keeps every cycle with a positive profit.
+	MinProfit *big.Int
+	// MaxLength caps the number of hops in a cycle. Zero means DefaultMaxCycleDepth.
+	MaxLength int
+	// Limit caps the number of cycles returned. Zero means no limit.
+	Limit int
+}
+
+// ArbCycle is a profitable cycle found by an ArbScanner.
+type ArbCycle struct {
+	Path      []TokenPoolPath
+	AmountIn  *big.Int
+	AmountOut *big.Int
+	// Profit is AmountOut minus AmountIn, in raw units of the numeraire.
+	Profit *big.Int
+}
+
+// ArbScanner finds profitable arbitrage cycles in a token pool graph. Its results are
+// deterministic: cycles are ordered by profit, highest first, then by length, shortest
+// first, and finally by their pool and token ids. A scanner is safe for concurrent use.
+type ArbScanner struct {
+	cfg ArbScannerConfig
+}
+
+// NewArbScanner returns a scanner for cfg.
+func NewArbScanner(cfg ArbScannerConfig) (*ArbScanner, error) {
+	if cfg.AmountIn == nil || cfg.AmountIn.Sign() <= 0 {
+		return nil, errors.New("arb scanner: amount in must be positive")
+	}
+	if cfg.MinProfit != nil && cfg.MinProfit.Sign() < 0 {
+		return nil, errors.New("arb scanner: min profit must not be negative")
+	}
+	if cfg.MaxLength < 0 {
+		return nil, fmt.Errorf("arb scanner: invalid max length %d", cfg.MaxLength)
+	}
+	if cfg.Limit < 0 {
+		return nil, fmt.Errorf("arb scanner: invalid limit %d", cfg.Limit)
+	}
+	if cfg.MaxLength == 0 {
+		cfg.MaxLength = DefaultMaxCycleDepth
+	}
+	cfg.AmountIn = new(big.Int).Set(cfg.AmountIn)
+	if cfg.MinProfit != nil {
+		cfg.MinProfit = new(big.Int).Set(cfg.MinProfit)
+	}
+	return &ArbScanner{cfg: cfg}, nil
+}
+
+// Scan returns the profitable cycles in graph, typically the Graph of a chain client's State.
+func (s *ArbScanner) Scan(graph TokenPoolGraph) ([]ArbCycle, error) {
+	if graph == nil {
+		return nil, errors.New("arb scanner: nil graph")
+	}
+	cycles, amountsOut, err := graph.FindAllArbitrageCycles(CycleFindingParams{
+		AmountIn: new(big.Int).Set(s.cfg.AmountIn),
+		TokenID:  s.cfg.NumeraireTokenID,
+		Runs:     s.cfg.MaxLength,
+		MaxDepth: s.cfg.MaxLength,
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]ArbCycle, 0, len(cycles))
+	for i, path := range cycles {
+		if len(path) > s.cfg.MaxLength || amountsOut[i] == nil {
+			continue
+		}
+		profit := new(big.Int).Sub(amountsOut[i], s.cfg.AmountIn)
+		if profit.Sign() <= 0 || (s.cfg.MinProfit != nil && profit.Cmp(s.cfg.MinProfit) < 0) {
+			continue
+		}
+		result = append(result, ArbCycle{
+			Path:      append([]TokenPoolPath(nil), path...),
+			AmountIn:  new(big.Int).Set(s.cfg.AmountIn),
+			AmountOut: new(big.Int).Set(amountsOut[i]),
+			Profit:    profit,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return arbCycleLess(result[i], result[j])
+	})
+	if s.cfg.Limit > 0 && len(result) > s.cfg.Limit {
+		result = result[:s.cfg.Limit]
+	}
+	return result, nil
+}
+
+// arbCycleLess orders cycles by profit descending, then by length, then by hop ids.
+func arbCycleLess(a, b ArbCycle) bool {
+	if c := a.Profit.Cmp(b.Profit); c != 0 {
+		return c > 0
+	}
+	if len(a.Path) != len(b.Path) {
+		return len(a.Path) < len(b.Path)
+	}
+	for k := range a.Path {
+		ha, hb := a.Path[k], b.Path[k]
+		if ha.PoolID != hb.PoolID {
+			return ha.PoolID < hb.PoolID
+		}
+		if ha.TokenInID != hb.TokenInID {
+			return ha.TokenInID < hb.TokenInID
+		}
+		if ha.TokenOutID != hb.TokenOutID {
+			return ha.TokenOutID < hb.TokenOutID
+		}
+	}
+	return false
+}
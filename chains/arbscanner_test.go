@@ -0,0 +1,73 @@
+package chains
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cycleGraph is a TokenPoolGraph stub that returns a fixed set of cycles.
+type cycleGraph struct {
+	TokenPoolGraph
+	cycles     [][]TokenPoolPath
+	amountsOut []*big.Int
+	params     CycleFindingParams
+}
+
+func (g *cycleGraph) FindAllArbitrageCycles(params CycleFindingParams, limit int) ([][]TokenPoolPath, []*big.Int, error) {
+	g.params = params
+	return g.cycles, g.amountsOut, nil
+}
+
+func TestArbScanner(t *testing.T) {
+	twoHop := func(a, b uint64) []TokenPoolPath {
+		return []TokenPoolPath{{TokenInID: 1, TokenOutID: 2, PoolID: a}, {TokenInID: 2, TokenOutID: 1, PoolID: b}}
+	}
+	threeHop := []TokenPoolPath{
+		{TokenInID: 1, TokenOutID: 2, PoolID: 10},
+		{TokenInID: 2, TokenOutID: 3, PoolID: 11},
+		{TokenInID: 3, TokenOutID: 1, PoolID: 12},
+	}
+	graph := &cycleGraph{
+		cycles:     [][]TokenPoolPath{threeHop, twoHop(7, 8), twoHop(5, 6), twoHop(3, 4), twoHop(1, 2)},
+		amountsOut: []*big.Int{big.NewInt(1100), big.NewInt(1100), big.NewInt(1200), big.NewInt(1000), big.NewInt(900)},
+	}
+
+	t.Run("filters unprofitable cycles and orders deterministically", func(t *testing.T) {
+		scanner, err := NewArbScanner(ArbScannerConfig{NumeraireTokenID: 1, AmountIn: big.NewInt(1000), MaxLength: 3})
+		require.NoError(t, err)
+
+		cycles, err := scanner.Scan(graph)
+		require.NoError(t, err)
+		require.Len(t, cycles, 3)
+		assert.Equal(t, twoHop(5, 6), cycles[0].Path)
+		assert.Equal(t, big.NewInt(200), cycles[0].Profit)
+		assert.Equal(t, twoHop(7, 8), cycles[1].Path, "shorter cycle wins a profit tie")
+		assert.Equal(t, threeHop, cycles[2].Path)
+
+		assert.Equal(t, uint64(1), graph.params.TokenID)
+		assert.Equal(t, 3, graph.params.MaxDepth)
+		assert.Equal(t, 3, graph.params.Runs)
+	})
+
+	t.Run("applies min profit, max length and limit", func(t *testing.T) {
+		scanner, err := NewArbScanner(ArbScannerConfig{NumeraireTokenID: 1, AmountIn: big.NewInt(1000), MinProfit: big.NewInt(100), MaxLength: 2, Limit: 1})
+		require.NoError(t, err)
+
+		cycles, err := scanner.Scan(graph)
+		require.NoError(t, err)
+		require.Len(t, cycles, 1)
+		assert.Equal(t, twoHop(5, 6), cycles[0].Path)
+	})
+
+	t.Run("rejects invalid config", func(t *testing.T) {
+		_, err := NewArbScanner(ArbScannerConfig{})
+		assert.Error(t, err)
+		_, err = NewArbScanner(ArbScannerConfig{AmountIn: big.NewInt(1), MinProfit: big.NewInt(-1)})
+		assert.Error(t, err)
+		_, err = NewArbScanner(ArbScannerConfig{AmountIn: big.NewInt(1), MaxLength: -1})
+		assert.Error(t, err)
+	})
+}
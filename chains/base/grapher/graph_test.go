@@ -3,6 +3,8 @@ package grapher
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
+	"math"
 	"math/big"
 	"math/rand"
 	"testing"
@@ -12,15 +14,25 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/defistate/defistate-client-go/addr"
 	"github.com/defistate/defistate-client-go/chains"
 	"github.com/defistate/defistate-client-go/engine"
 	poolregistry "github.com/defistate/defistate-client-go/protocols/poolregistry"
 	tokenpoolregistry "github.com/defistate/defistate-client-go/protocols/tokenpoolregistry"
+	tokenregistry "github.com/defistate/defistate-client-go/protocols/tokenregistry"
 
+	"github.com/defistate/defistate-client-go/protocols/solidly"
+	solidlycalculator "github.com/defistate/defistate-client-go/protocols/solidly/calculator"
+	solidlyindexer "github.com/defistate/defistate-client-go/protocols/solidly/indexer"
 	uniswapv2 "github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	uniswapv2calculator "github.com/defistate/defistate-client-go/protocols/uniswapv2/calculator"
 	uniswapv3 "github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	uniswapv3calculator "github.com/defistate/defistate-client-go/protocols/uniswapv3/calculator"
+	uniswapv4 "github.com/defistate/defistate-client-go/protocols/uniswapv4"
+	uniswapv4calculator "github.com/defistate/defistate-client-go/protocols/uniswapv4/calculator"
 
 	poolregistryindexer "github.com/defistate/defistate-client-go/protocols/poolregistry/indexer"
+	tokenregistryindexer "github.com/defistate/defistate-client-go/protocols/tokenregistry/indexer"
 	uniswapv2indexer "github.com/defistate/defistate-client-go/protocols/uniswapv2/indexer"
 	uniswapv3indexer "github.com/defistate/defistate-client-go/protocols/uniswapv3/indexer"
 )
@@ -6996,20 +7008,20 @@ func setupUniswapV3ETHUSDCPool(token0ID uint64, token1ID uint64, poolID uint64)
 
 type mockIndexedPoolRegistry struct {
 	poolsByID      map[uint64]poolregistry.Pool
-	poolsByAddress map[common.Address]poolregistry.Pool
+	poolsByAddress map[addr.Address]poolregistry.Pool
 }
 
 func newMockIndexedPoolRegistry() *mockIndexedPoolRegistry {
 	return &mockIndexedPoolRegistry{
 		poolsByID:      make(map[uint64]poolregistry.Pool),
-		poolsByAddress: make(map[common.Address]poolregistry.Pool),
+		poolsByAddress: make(map[addr.Address]poolregistry.Pool),
 	}
 }
 func (m *mockIndexedPoolRegistry) GetByID(id uint64) (poolregistry.Pool, bool) {
 	p, ok := m.poolsByID[id]
 	return p, ok
 }
-func (m *mockIndexedPoolRegistry) GetByAddress(address common.Address) (poolregistry.Pool, bool) {
+func (m *mockIndexedPoolRegistry) GetByAddress(address addr.Address) (poolregistry.Pool, bool) {
 	p, ok := m.poolsByAddress[address]
 	return p, ok
 }
@@ -7096,9 +7108,9 @@ func NewMockGraphRequirements(
 		if !ok {
 			t.Errorf("Address for V2 pool ID %d not found in pools map", pool.ID)
 		}
-		genericPool := poolregistry.Pool{ID: pool.ID, Key: poolregistry.AddressToPoolKey(poolAddress), Protocol: uniswapV2PoolRegistryID}
+		genericPool := poolregistry.Pool{ID: pool.ID, Key: poolregistry.AddressToPoolKey(addr.FromCommon(poolAddress)), Protocol: uniswapV2PoolRegistryID}
 		mockPoolRegistry.poolsByID[pool.ID] = genericPool
-		mockPoolRegistry.poolsByAddress[poolAddress] = genericPool
+		mockPoolRegistry.poolsByAddress[addr.FromCommon(poolAddress)] = genericPool
 		mockV2View.poolsByID[pool.ID] = pool
 		tokenPoolSystem.AddPool([]uint64{pool.Token0, pool.Token1}, pool.ID)
 	}
@@ -7109,9 +7121,9 @@ func NewMockGraphRequirements(
 		if !ok {
 			t.Errorf("Address for V3 pool ID %d not found in pools map", pool.ID)
 		}
-		genericPool := poolregistry.Pool{ID: pool.ID, Key: poolregistry.AddressToPoolKey(poolAddress), Protocol: uniswapV3PoolRegistryID}
+		genericPool := poolregistry.Pool{ID: pool.ID, Key: poolregistry.AddressToPoolKey(addr.FromCommon(poolAddress)), Protocol: uniswapV3PoolRegistryID}
 		mockPoolRegistry.poolsByID[pool.ID] = genericPool
-		mockPoolRegistry.poolsByAddress[poolAddress] = genericPool
+		mockPoolRegistry.poolsByAddress[addr.FromCommon(poolAddress)] = genericPool
 		mockV3View.poolsByID[pool.ID] = pool
 		tokenPoolSystem.AddPool([]uint64{pool.PoolViewMinimal.Token0, pool.PoolViewMinimal.Token1}, pool.ID)
 	}
@@ -7158,6 +7170,7 @@ func setupSimpleTestGraph(t *testing.T, activePools map[uint64]struct{}) (*Graph
 
 	graph, err := NewGraph(
 		rawGraph,
+		nil,
 		poolRegistry,
 		v2View,
 		v3View,
@@ -7229,6 +7242,7 @@ func TestNewGraph(t *testing.T) {
 
 		graph, err := NewGraph(
 			tokenPoolView,
+			nil,
 			poolRegistry,
 			v2View,
 			v3View,
@@ -7305,443 +7319,1073 @@ func TestGetTokensForPool(t *testing.T) {
 	})
 }
 
-func setupGetExchangeRatesGraph(t *testing.T, activePools map[uint64]struct{}) (*Graph, poolregistryindexer.IndexedPoolRegistry, uniswapv2indexer.IndexedUniswapV2, uniswapv3indexer.IndexedUniswapV3) {
-	tokens := map[uint64]common.Address{
-		1: common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"), // WETH
-		2: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), // USDC
-		3: common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F"), // DAI
-		4: common.HexToAddress("0x2260FAC5E5542a773Aa44fBCfeDf7C193bc2C599"), // WBTC
-		5: common.HexToAddress("0x4Fabb145d64652a948d72533023f6E7A623C7C53"), // BUSD (Isolated)
-		6: common.HexToAddress("0x95aD61b0a150d79219dCF64E1E6Cc01f0B64C4cE"), // SHIB (Isolated)
-	}
-	pools := map[uint64]common.Address{
-		101: common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc"), // V2 WETH/USDC
-		102: common.HexToAddress("0x88e6a0c2ddd26feeb64f039a2c41296fcb3f5640"), // V3 WETH/USDC
-		104: common.HexToAddress("0xBb2b8038a1640196FbE3e38816F3e67Cba72D940"), // V2 WETH/WBTC
-	}
+func TestGetPoolState(t *testing.T) {
+	graph, _, _, v2View, v3View := setupSimpleTestGraph(t, map[uint64]struct{}{})
+
+	t.Run("V2 pool", func(t *testing.T) {
+		schema, state, ok := graph.GetPoolState(101)
+		require.True(t, ok)
+		assert.Equal(t, uniswapv2.Schema, schema)
+		pool, isV2 := state.(uniswapv2.Pool)
+		require.True(t, isV2)
+		expected, _ := v2View.GetByID(101)
+		assert.Equal(t, expected, pool)
+	})
 
-	uniswapV2Pools := []uniswapv2.Pool{
-		{ID: 101, Token0: 1, Token1: 2, Reserve0: bigIntFromString("4000000000000000000000"), Reserve1: bigIntFromString("15600000000000"), FeeBps: 30},
-		{ID: 104, Token0: 1, Token1: 4, Reserve0: bigIntFromString("1000000000000000000000"), Reserve1: bigIntFromString("3154340000"), FeeBps: 30},
+	t.Run("V3 pool", func(t *testing.T) {
+		schema, state, ok := graph.GetPoolState(102)
+		require.True(t, ok)
+		assert.Equal(t, uniswapv3.Schema, schema)
+		pool, isV3 := state.(uniswapv3.Pool)
+		require.True(t, isV3)
+		expected, _ := v3View.GetByID(102)
+		assert.Equal(t, expected, pool)
+	})
+
+	t.Run("Unknown pool", func(t *testing.T) {
+		_, state, ok := graph.GetPoolState(999)
+		assert.False(t, ok)
+		assert.Nil(t, state)
+	})
+}
+
+func TestUniswapV4Pools(t *testing.T) {
+	liquidity := new(big.Int).Exp(big.NewInt(10), big.NewInt(21), nil)
+	lpFee := uint64(500)
+	v4Pools := []uniswapv4.Pool{
+		{
+			ID: 201, Token0: 1, Token1: 2, Fee: uniswapv4.DynamicFeeFlag, LPFee: &lpFee, TickSpacing: 60,
+			Liquidity: liquidity, SqrtPriceX96: new(big.Int).Set(uniswapv3calculator.Q96),
+			Ticks: []uniswapv4.TickInfo{
+				{Index: -600, LiquidityGross: liquidity, LiquidityNet: liquidity},
+				{Index: 600, LiquidityGross: liquidity, LiquidityNet: new(big.Int).Neg(liquidity)},
+			},
+		},
+		// A dynamic fee the stream does not report cannot be quoted.
+		{ID: 202, Token0: 1, Token1: 2, Fee: uniswapv4.DynamicFeeFlag, Liquidity: liquidity, SqrtPriceX96: new(big.Int).Set(uniswapv3calculator.Q96)},
 	}
-	uniswapV3Pools := []uniswapv3.Pool{
-		setupUniswapV3ETHUSDCPool(2, 1, 102), // 2 == USDC, 1 == WETH, 102 == poolID
+	v3Form, unknownFee := uniswapv4calculator.V3Pools(v4Pools)
+	require.Equal(t, []uint64{202}, unknownFee)
+
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"),
+		2: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"),
 	}
+	pools := map[uint64]common.Address{201: common.HexToAddress("0x01")}
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, nil, v3Form)
+	resolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV3ProtocolID: uniswapv4.Schema,
+	}, poolRegistry)
 
-	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, uniswapV3Pools)
+	graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{201: {}}, resolver)
+	require.NoError(t, err)
 
-	protocolIDToProtocolSchema := map[engine.ProtocolID]engine.ProtocolSchema{
-		uniswapV2ProtocolID: uniswapv2.Schema,
-		uniswapV3ProtocolID: uniswapv3.Schema,
+	schema, state, ok := graph.GetPoolState(201)
+	require.True(t, ok)
+	assert.Equal(t, uniswapv4.Schema, schema)
+	assert.IsType(t, uniswapv3.Pool{}, state)
+
+	amount := big.NewInt(1_000_000_000_000_000)
+	quote, err := graph.QuoteBothDirections(1, 2, amount)
+	require.NoError(t, err)
+	assert.Equal(t, []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 2, PoolID: 201}}, quote.Forward)
+	expected, err := uniswapv4calculator.GetAmountOut(amount, nil, 1, v4Pools[0])
+	require.NoError(t, err)
+	assert.Equal(t, expected, quote.ForwardOut)
+}
+
+func TestSolidlyPools(t *testing.T) {
+	e18 := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	reserve := new(big.Int).Mul(big.NewInt(1_000_000), e18)
+	solidlyPools := []solidly.Pool{
+		{ID: 301, Token0: 1, Token1: 2, Reserve0: reserve, Reserve1: reserve, Stable: true, FeeBps: 5, Decimals0: 18, Decimals1: 18},
+		{ID: 302, Token0: 1, Token1: 2, Reserve0: reserve, Reserve1: reserve, FeeBps: 30, Decimals0: 18, Decimals1: 18},
 	}
 
-	protocolResolver := chains.NewProtocolResolver(
-		protocolIDToProtocolSchema,
-		poolRegistry,
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F"),
+		2: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"),
+	}
+	pools := map[uint64]common.Address{301: common.HexToAddress("0x01"), 302: common.HexToAddress("0x02")}
+	// Register the pools under a protocol resolved to the Solidly schema; the V2 view is
+	// only used to populate the registries.
+	asV2 := []uniswapv2.Pool{{ID: 301, Token0: 1, Token1: 2}, {ID: 302, Token0: 1, Token1: 2}}
+	rawGraph, poolRegistry, _, v3View := NewMockGraphRequirements(t, tokens, pools, asV2, nil)
+	resolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: solidly.Schema,
+	}, poolRegistry)
+
+	graph, err := newGraph(
+		rawGraph, nil, poolRegistry, newMockIndexedUniswapV2(), v3View,
+		solidlyindexer.NewIndexableSolidlySystem(solidlyPools),
+		map[uint64]struct{}{301: {}, 302: {}}, resolver, nil, nil, SkipDuplicatePools, nil,
 	)
+	require.NoError(t, err)
+	assert.Equal(t, 2, graph.BuildReport().RoutablePools)
 
-	graph, err := NewGraph(
-		rawGraph,
-		poolRegistry,
-		v2View,
-		v3View,
-		activePools,
-		protocolResolver,
-	)
+	schema, state, ok := graph.GetPoolState(301)
+	require.True(t, ok)
+	assert.Equal(t, solidly.Schema, schema)
+	assert.IsType(t, solidly.Pool{}, state)
+
+	// The stable curve is flatter around the peg, so it beats the volatile pool despite
+	// sharing its reserves.
+	amount := new(big.Int).Mul(big.NewInt(10_000), e18)
+	quote, err := graph.QuoteBothDirections(1, 2, amount)
 	require.NoError(t, err)
-	return graph, poolRegistry, v2View, v3View
+	assert.Equal(t, []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 2, PoolID: 301}}, quote.Forward)
+	expected, err := solidlycalculator.GetAmountOut(amount, 1, 2, solidlyPools[0])
+	require.NoError(t, err)
+	assert.Equal(t, expected, quote.ForwardOut)
 }
 
-func TestGetExchangeRates(t *testing.T) {
-	activePools := map[uint64]struct{}{101: {}, 102: {}, 104: {}}
-	graph, _, _, _ := setupGetExchangeRatesGraph(t, activePools)
-
-	t.Run("Success from WETH", func(t *testing.T) {
-		// Start with 1 WETH (10^18)
-		oneWETH := new(big.Int).SetUint64(1e18)
-		equivalents, err := graph.GetExchangeRates(oneWETH, 1, 3, nil) // No source token restriction
+func TestQuoteBothDirections(t *testing.T) {
+	t.Run("Single pool", func(t *testing.T) {
+		// Only pool 101 (token 1/token 2) is active.
+		graph, _, _, _, _ := setupSimpleTestGraph(t, map[uint64]struct{}{101: {}})
+		amount := big.NewInt(1_000_000)
 
+		quote, err := graph.QuoteBothDirections(1, 2, amount)
 		require.NoError(t, err)
-		require.NotNil(t, equivalents)
-		require.Len(t, equivalents, 3, "Should find equivalents for WETH, USDC, and WBTC")
+		assert.Equal(t, []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 2, PoolID: 101}}, quote.Forward)
+		assert.Equal(t, []chains.TokenPoolPath{{TokenInID: 2, TokenOutID: 1, PoolID: 101}}, quote.Reverse)
+		assert.Positive(t, quote.ForwardOut.Sign())
+		assert.Positive(t, quote.ReverseOut.Sign())
+
+		// Fees and price impact are paid on both legs, so the round trip loses value.
+		assert.Positive(t, quote.Spread().Sign())
+		assert.Equal(t, amount, quote.AmountIn)
+	})
 
-		// The base token's equivalent should be exactly the input amount.
-		assert.Equal(t, 0, oneWETH.Cmp(equivalents[1]), "Base token WETH equivalent should match input amount")
+	t.Run("Unconnected pair", func(t *testing.T) {
+		graph, _, _, _, _ := setupSimpleTestGraph(t, map[uint64]struct{}{101: {}})
+		_, err := graph.QuoteBothDirections(1, 999, big.NewInt(1_000))
+		assert.Error(t, err)
+	})
 
-		usdcAmount := equivalents[2] // USDC
-		wbtcAmount := equivalents[4] // WBTC
-		wethAmount := equivalents[1]
+	t.Run("Invalid amount", func(t *testing.T) {
+		graph, _, _, _, _ := setupSimpleTestGraph(t, nil)
+		_, err := graph.QuoteBothDirections(1, 2, big.NewInt(0))
+		assert.Error(t, err)
+	})
+}
 
-		assert.NotNil(t, usdcAmount)
-		assert.True(t, usdcAmount.Cmp(big.NewInt(3924259038)) == 0, "USDC equivalent should be exactly 3924259038")
-		assert.NotNil(t, wbtcAmount)
-		assert.True(t, wbtcAmount.Cmp(big.NewInt(3141744)) == 0, "WBTC equivalent should be exactly 3141744")
-		assert.NotNil(t, wethAmount)
-		assert.True(t, wethAmount.Cmp(oneWETH) == 0, "WETH equivalent should be equal to oneWETH")
+func TestWeightedPrice(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0x1"), // WETH
+		2: common.HexToAddress("0x2"), // USDC
+		3: common.HexToAddress("0x3"), // unconnected
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"),
+		102: common.HexToAddress("0x102"),
+	}
+	uniswapV2Pools := []uniswapv2.Pool{
+		// A deep, honest pool: 1,000 WETH against 3,000,000 USDC (3,000 USDC per WETH).
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(1_000), d18), Reserve1: big.NewInt(3_000_000e6), FeeBps: 30},
+		// A thin pool pushed to ten times the price: 0.1 WETH against 3,000 USDC.
+		{ID: 102, Token0: 1, Token1: 2, Reserve0: new(big.Int).Div(d18, big.NewInt(10)), Reserve1: big.NewInt(3_000e6), FeeBps: 30},
+	}
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+	protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}, poolRegistry)
+	tokenRegistry := tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+		{ID: 1, Symbol: "WETH", Decimals: 18},
+		{ID: 2, Symbol: "USDC", Decimals: 6},
+		{ID: 3, Symbol: "XYZ", Decimals: 18},
 	})
+	graph, err := NewGraph(rawGraph, tokenRegistry, poolRegistry, v2View, v3View, map[uint64]struct{}{}, protocolResolver)
+	require.NoError(t, err)
 
-	t.Run("Success with allowedSourceTokens", func(t *testing.T) {
-		oneWETH := new(big.Int).SetUint64(1e18)
-		// Only allow WETH to be a source of price propagation.
-		allowedSourceTokens := map[uint64]struct{}{1: {}}
-		equivalents, err := graph.GetExchangeRates(oneWETH, 1, 3, allowedSourceTokens)
+	t.Run("Thin manipulated pool barely moves the price", func(t *testing.T) {
+		price, err := graph.WeightedPrice(1, 2)
+		require.NoError(t, err)
+		f, _ := price.Float64()
+		// An unweighted mean of the two spot prices would be 16,500.
+		assert.InDelta(t, 3000, f, 10)
+		assert.Greater(t, f, 3000.0, "the manipulated pool still contributes a little")
+	})
 
+	t.Run("Inverse direction", func(t *testing.T) {
+		price, err := graph.WeightedPrice(2, 1)
 		require.NoError(t, err)
-		require.NotNil(t, equivalents)
-		// Should still find all connected tokens, as the search starts from WETH.
-		require.Len(t, equivalents, 3)
-		assert.Contains(t, equivalents, uint64(1))
-		assert.Contains(t, equivalents, uint64(2))
-		assert.Contains(t, equivalents, uint64(4))
+		f, _ := price.Float64()
+		assert.InDelta(t, 1.0/3000, f, 1e-6)
 	})
 
-	t.Run("Non-existent base token", func(t *testing.T) {
-		oneWETH := new(big.Int).SetUint64(1e18)
-		_, err := graph.GetExchangeRates(oneWETH, 999, 3, nil) // Non-existent token ID
+	t.Run("Same token", func(t *testing.T) {
+		price, err := graph.WeightedPrice(1, 1)
+		require.NoError(t, err)
+		assert.Equal(t, 0, price.Cmp(big.NewFloat(1)))
+	})
 
-		require.Error(t, err)
-		assert.Contains(t, err.Error(), "token 999 not found in the graph")
+	t.Run("No shared pool", func(t *testing.T) {
+		_, err := graph.WeightedPrice(1, 3)
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
 	})
 
-	t.Run("No path to isolated token", func(t *testing.T) {
-		oneWETH := new(big.Int).SetUint64(1e18)
-		equivalents, err := graph.GetExchangeRates(oneWETH, 1, 3, nil)
+	t.Run("V3 pool is weighted with its virtual reserves", func(t *testing.T) {
+		// WETH/USDC is held by V2 pool 101 and V3 pool 102; without token metadata
+		// the price is in raw units.
+		graph, _, v2View, v3View := setupGetExchangeRatesGraph(t, map[uint64]struct{}{})
+		v2Pool, _ := v2View.GetByID(101)
+		v3Pool, _ := v3View.GetByID(102)
+		v2Price := new(big.Float).Quo(new(big.Float).SetInt(v2Pool.Reserve1), new(big.Float).SetInt(v2Pool.Reserve0))
+		wethReserve, usdcReserve, err := uniswapv3calculator.GetVirtualReserves(1, 2, v3Pool)
+		require.NoError(t, err)
+		v3Price := new(big.Float).Quo(new(big.Float).SetInt(usdcReserve), new(big.Float).SetInt(wethReserve))
 
+		price, err := graph.WeightedPrice(1, 2)
 		require.NoError(t, err)
-		require.NotNil(t, equivalents)
-		_, exists := equivalents[5] // BUSD
-		assert.False(t, exists, "Isolated token BUSD should not have an equivalent")
+		low, high := v2Price, v3Price
+		if low.Cmp(high) > 0 {
+			low, high = high, low
+		}
+		assert.True(t, price.Cmp(low) >= 0 && price.Cmp(high) <= 0, "price %s should lie between %s and %s", price, low, high)
 	})
 }
 
-// setupUniswapV2BenchmarkGraph creates a large, realistic graph using only V2 pools for performance testing.
-// It uses a "hub-and-spoke" model to simulate real-world liquidity patterns.
-func setupUniswapV2BenchmarkGraph(t require.TestingT, numTokens, numPools int) *Graph {
-	tokens := make(map[uint64]common.Address, numTokens)
-	for i := 0; i < numTokens; i++ {
-		tokens[uint64(i)] = common.HexToAddress(fmt.Sprintf("0x%040x", i))
+func TestMostMispricedPool(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0x1"), // WETH
+		2: common.HexToAddress("0x2"), // USDC
+		3: common.HexToAddress("0x3"), // unconnected
 	}
-
-	pools := make(map[uint64]common.Address, numPools)
-	uniswapV2Pools := make([]uniswapv2.Pool, 0, numPools)
-
-	// Define our "hub" tokens (e.g., WETH, USDC)
-	hubTokens := []uint64{0, 1, 2}
-	if numTokens < len(hubTokens) {
-		hubTokens = hubTokens[:numTokens]
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"),
+		102: common.HexToAddress("0x102"),
+		103: common.HexToAddress("0x103"),
+	}
+	wethReserve := new(big.Int).Mul(big.NewInt(100), d18)
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: wethReserve, Reserve1: big.NewInt(300_000e6), FeeBps: 30}, // 3,000 USDC per WETH
+		{ID: 102, Token0: 1, Token1: 2, Reserve0: wethReserve, Reserve1: big.NewInt(306_000e6), FeeBps: 30}, // 3,060
+		{ID: 103, Token0: 2, Token1: 1, Reserve0: big.NewInt(240_000e6), Reserve1: wethReserve, FeeBps: 30}, // 2,400, tokens reversed
 	}
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+	protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}, poolRegistry)
+	tokenRegistry := tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+		{ID: 1, Symbol: "WETH", Decimals: 18},
+		{ID: 2, Symbol: "USDC", Decimals: 6},
+		{ID: 3, Symbol: "XYZ", Decimals: 18},
+	})
+	graph, err := NewGraph(rawGraph, tokenRegistry, poolRegistry, v2View, v3View, map[uint64]struct{}{}, protocolResolver)
+	require.NoError(t, err)
 
-	// Setup for generating large random numbers safely
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	baseReserve := new(big.Int).SetUint64(1e18)
-	maxRandReserve := new(big.Int)
-	maxRandReserve.Exp(big.NewInt(10), big.NewInt(20), nil) // 10^20
-	poolIDCounter := uint64(0)
+	t.Run("Cheap pool is bought from", func(t *testing.T) {
+		mispricing, err := graph.MostMispricedPool(1, 2, big.NewFloat(3000))
+		require.NoError(t, err)
+		assert.Equal(t, uint64(103), mispricing.PoolID)
+		assert.Equal(t, chains.TokenPoolPath{TokenInID: 2, TokenOutID: 1, PoolID: 103}, mispricing.Swap)
 
-	// --- Step 1: Guarantee Connectivity ---
-	// Create a "backbone" by linking every non-hub token to a hub.
-	for i := len(hubTokens); i < numTokens; i++ {
-		if poolIDCounter >= uint64(numPools) {
-			break // Stop if we've already created the max number of pools
-		}
-		poolID := poolIDCounter
-		pools[poolID] = common.HexToAddress(fmt.Sprintf("0x1%039x", poolID))
+		spot, _ := mispricing.SpotPrice.Float64()
+		assert.InDelta(t, 2400, spot, 1e-6)
+		deviation, _ := mispricing.Deviation.Float64()
+		assert.InDelta(t, -0.2, deviation, 1e-9)
+	})
 
-		tokenA := hubTokens[r.Intn(len(hubTokens))] // Pick a random hub
-		tokenB := uint64(i)
+	t.Run("Expensive pool is sold into", func(t *testing.T) {
+		// Against an external price of 2,400, pool 102 is the furthest above it.
+		mispricing, err := graph.MostMispricedPool(1, 2, big.NewFloat(2400))
+		require.NoError(t, err)
+		assert.Equal(t, uint64(102), mispricing.PoolID)
+		assert.Equal(t, chains.TokenPoolPath{TokenInID: 1, TokenOutID: 2, PoolID: 102}, mispricing.Swap)
+		deviation, _ := mispricing.Deviation.Float64()
+		assert.InDelta(t, 0.275, deviation, 1e-9)
+	})
 
-		randReserve0 := new(big.Int).Rand(r, maxRandReserve)
-		randReserve1 := new(big.Int).Rand(r, maxRandReserve)
-		reserve0 := new(big.Int).Add(baseReserve, randReserve0)
-		reserve1 := new(big.Int).Add(baseReserve, randReserve1)
+	t.Run("Pair given in reverse", func(t *testing.T) {
+		// 1/3,000 WETH per USDC: pool 103 makes USDC expensive, so USDC is sold there.
+		mispricing, err := graph.MostMispricedPool(2, 1, new(big.Float).Quo(big.NewFloat(1), big.NewFloat(3000)))
+		require.NoError(t, err)
+		assert.Equal(t, uint64(103), mispricing.PoolID)
+		assert.Equal(t, chains.TokenPoolPath{TokenInID: 2, TokenOutID: 1, PoolID: 103}, mispricing.Swap)
+		assert.Positive(t, mispricing.Deviation.Sign())
+	})
 
-		uniswapV2Pools = append(uniswapV2Pools, uniswapv2.Pool{
-			ID:       poolID,
-			Token0:   tokenA,
-			Token1:   tokenB,
-			Reserve0: reserve0,
-			Reserve1: reserve1,
-			Type:     0,
-		})
-		poolIDCounter++
-	}
+	t.Run("Invalid input", func(t *testing.T) {
+		_, err := graph.MostMispricedPool(1, 2, big.NewFloat(0))
+		assert.Error(t, err)
+		_, err = graph.MostMispricedPool(1, 2, nil)
+		assert.Error(t, err)
+		_, err = graph.MostMispricedPool(1, 1, big.NewFloat(1))
+		assert.Error(t, err)
+	})
 
-	// --- Step 2: Add remaining pools randomly for complexity ---
-	for i := poolIDCounter; i < uint64(numPools); i++ {
-		poolID := i
-		pools[poolID] = common.HexToAddress(fmt.Sprintf("0x1%039x", poolID))
+	t.Run("No shared pool", func(t *testing.T) {
+		_, err := graph.MostMispricedPool(1, 3, big.NewFloat(1))
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+}
 
-		var tokenA, tokenB uint64
+func TestDepthCurves(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	weth := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), d18) }
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0x1"), // WETH
+		2: common.HexToAddress("0x2"), // USDC
+		3: common.HexToAddress("0x3"), // XYZ
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"),
+		102: common.HexToAddress("0x102"),
+		103: common.HexToAddress("0x103"),
+	}
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: weth(10), Reserve1: big.NewInt(30_000e6), FeeBps: 30},      // shallow
+		{ID: 102, Token0: 2, Token1: 1, Reserve0: big.NewInt(3_000_000e6), Reserve1: weth(1000), FeeBps: 30}, // deep, tokens reversed
+		{ID: 103, Token0: 1, Token1: 3, Reserve0: weth(1000), Reserve1: weth(1000), FeeBps: 30},              // another pair
+	}
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+	protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}, poolRegistry)
+	tokenRegistry := tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+		{ID: 1, Symbol: "WETH", Decimals: 18},
+		{ID: 2, Symbol: "USDC", Decimals: 6},
+		{ID: 3, Symbol: "XYZ", Decimals: 18},
+	})
+	graph, err := NewGraph(rawGraph, tokenRegistry, poolRegistry, v2View, v3View, map[uint64]struct{}{}, protocolResolver)
+	require.NoError(t, err)
 
-		// Create a mix of hub-spoke and spoke-spoke pools
-		if i%5 == 0 && numTokens > len(hubTokens) { // 20% of pools are cross-connects
-			// Spoke-to-spoke pool
-			tokenA = uint64(len(hubTokens) + r.Intn(numTokens-len(hubTokens)))
-			tokenB = uint64(len(hubTokens) + r.Intn(numTokens-len(hubTokens)))
-		} else {
-			// Hub-to-spoke pool
-			tokenA = hubTokens[r.Intn(len(hubTokens))]
-			tokenB = uint64(r.Intn(numTokens))
-		}
+	params := chains.DepthCurveParams{
+		TokenInID:   1,
+		TokenOutID:  2,
+		MinAmountIn: new(big.Int).Div(d18, big.NewInt(1000)),
+		MaxAmountIn: weth(10),
+		Points:      5,
+	}
 
-		// Ensure we don't create a pool with the same token
-		if tokenA == tokenB {
-			tokenB = (tokenB + 1) % uint64(numTokens)
+	t.Run("Pools of the pair, deepest first", func(t *testing.T) {
+		curves, err := graph.DepthCurves(params)
+		require.NoError(t, err)
+		require.Len(t, curves, 2)
+		assert.Equal(t, uint64(102), curves[0].PoolID)
+		assert.Equal(t, uint64(101), curves[1].PoolID)
+
+		for _, curve := range curves {
+			assert.Equal(t, uniswapv2.Schema, curve.Schema)
+			require.Len(t, curve.Points, params.Points)
+			assert.Equal(t, params.MinAmountIn, curve.Points[0].AmountIn)
+			assert.Equal(t, params.MaxAmountIn, curve.Points[params.Points-1].AmountIn)
+
+			pool, _ := graph.indexedUniswapV2.GetByID(curve.PoolID)
+			for i, point := range curve.Points {
+				expected, err := uniswapv2calculator.GetAmountOut(point.AmountIn, 1, 2, pool)
+				require.NoError(t, err)
+				assert.Equal(t, expected, point.AmountOut)
+				if i > 0 {
+					// Sizes grow by a constant factor, 10 here, and prices fall.
+					ratio, _ := new(big.Float).Quo(new(big.Float).SetInt(point.AmountIn), new(big.Float).SetInt(curve.Points[i-1].AmountIn)).Float64()
+					assert.InDelta(t, 10, ratio, 1e-9)
+					assert.Equal(t, -1, point.MarginalPrice.Cmp(curve.Points[i-1].MarginalPrice))
+				}
+			}
 		}
 
-		randReserve0 := new(big.Int).Rand(r, maxRandReserve)
-		randReserve1 := new(big.Int).Rand(r, maxRandReserve)
-		reserve0 := new(big.Int).Add(baseReserve, randReserve0)
-		reserve1 := new(big.Int).Add(baseReserve, randReserve1)
+		// The smallest size barely moves the price from 3,000 USDC per WETH.
+		price, _ := curves[0].Points[0].MarginalPrice.Float64()
+		assert.InDelta(t, 3000, price, 0.01)
+		// Selling 10 WETH into the shallow pool doubles its WETH reserve and halves its USDC.
+		price, _ = curves[1].Points[params.Points-1].MarginalPrice.Float64()
+		assert.InDelta(t, 751.13, price, 0.01)
+	})
 
-		uniswapV2Pools = append(uniswapV2Pools, uniswapv2.Pool{
-			ID:       poolID,
-			Token0:   tokenA,
-			Token1:   tokenB,
-			Reserve0: reserve0,
-			Reserve1: reserve1,
-			FeeBps:   30,
-		})
-	}
+	t.Run("Invalid params", func(t *testing.T) {
+		invalid := params
+		invalid.Points = 1
+		_, err := graph.DepthCurves(invalid)
+		assert.Error(t, err)
 
-	activePools := make(map[uint64]struct{}, numPools)
-	for i := 0; i < numPools; i++ {
-		activePools[uint64(i)] = struct{}{}
-	}
+		invalid = params
+		invalid.MinAmountIn, invalid.MaxAmountIn = params.MaxAmountIn, params.MinAmountIn
+		_, err = graph.DepthCurves(invalid)
+		assert.Error(t, err)
+	})
 
-	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+	t.Run("No pool of the pair", func(t *testing.T) {
+		noPair := params
+		noPair.TokenInID, noPair.TokenOutID = 2, 3
+		_, err := graph.DepthCurves(noPair)
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+}
 
-	protocolIDToProtocolSchema := map[engine.ProtocolID]engine.ProtocolSchema{
-		uniswapV2ProtocolID: uniswapv2.Schema,
-		uniswapV3ProtocolID: uniswapv3.Schema,
+func TestTriangleDeviation(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	units := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), d18) }
+	setup := func(t *testing.T, reserveCA1 *big.Int) *Graph {
+		tokens := map[uint64]common.Address{
+			1: common.HexToAddress("0xA"),
+			2: common.HexToAddress("0xB"),
+			3: common.HexToAddress("0xC"),
+			4: common.HexToAddress("0xD"), // connected to A only
+		}
+		pools := map[uint64]common.Address{
+			101: common.HexToAddress("0x101"),
+			102: common.HexToAddress("0x102"),
+			103: common.HexToAddress("0x103"),
+			104: common.HexToAddress("0x104"),
+		}
+		uniswapV2Pools := []uniswapv2.Pool{
+			{ID: 101, Token0: 1, Token1: 2, Reserve0: units(100), Reserve1: units(200), FeeBps: 30}, // 1 A = 2 B
+			{ID: 102, Token0: 2, Token1: 3, Reserve0: units(100), Reserve1: units(300), FeeBps: 30}, // 1 B = 3 C
+			{ID: 103, Token0: 3, Token1: 1, Reserve0: units(600), Reserve1: reserveCA1, FeeBps: 30}, // C/A
+			{ID: 104, Token0: 1, Token1: 4, Reserve0: units(100), Reserve1: units(100), FeeBps: 30},
+		}
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+		protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+			uniswapV3ProtocolID: uniswapv3.Schema,
+		}, poolRegistry)
+		graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{}, protocolResolver)
+		require.NoError(t, err)
+		return graph
 	}
 
-	protocolResolver := chains.NewProtocolResolver(
-		protocolIDToProtocolSchema,
-		poolRegistry,
-	)
+	t.Run("Consistent triangle", func(t *testing.T) {
+		// 600 C against 100 A: 1 C = 1/6 A, which closes the cycle exactly.
+		graph := setup(t, units(100))
+		for _, order := range [][3]uint64{{1, 2, 3}, {2, 3, 1}, {1, 3, 2}} {
+			deviation, err := graph.TriangleDeviation(order[0], order[1], order[2])
+			require.NoError(t, err)
+			f, _ := deviation.Float64()
+			assert.InDelta(t, 0, f, 1e-12, "order %v", order)
+		}
+	})
 
-	graph, err := NewGraph(
-		rawGraph,
-		poolRegistry,
-		v2View,
-		v3View,
-		activePools,
-		protocolResolver,
-	)
-	require.NoError(t, err)
-	return graph
+	t.Run("Triangle with an arbitrage", func(t *testing.T) {
+		// 600 C against 120 A: 1 C = 0.2 A, so A -> B -> C -> A returns 1.2 A per A.
+		graph := setup(t, units(120))
+
+		deviation, err := graph.TriangleDeviation(1, 2, 3)
+		require.NoError(t, err)
+		f, _ := deviation.Float64()
+		assert.InDelta(t, 0.2, f, 1e-12)
+
+		// The reverse cycle loses at the same prices.
+		deviation, err = graph.TriangleDeviation(1, 3, 2)
+		require.NoError(t, err)
+		f, _ = deviation.Float64()
+		assert.InDelta(t, 1/1.2-1, f, 1e-12)
+	})
+
+	t.Run("Missing leg", func(t *testing.T) {
+		graph := setup(t, units(100))
+		_, err := graph.TriangleDeviation(1, 2, 4)
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+
+	t.Run("Repeated token", func(t *testing.T) {
+		graph := setup(t, units(100))
+		_, err := graph.TriangleDeviation(1, 2, 1)
+		assert.Error(t, err)
+	})
 }
 
-func BenchmarkGetExchangeRates(b *testing.B) {
-	// Define different scales to benchmark against
-	benchmarkCases := []struct {
-		name      string
-		numTokens int
-		numPools  int
-	}{
-		{"100Tokens_300Pools", 100, 300},
-		{"500Tokens_1500Pools", 500, 1500},
-		{"1000Tokens_3000Pools", 1000, 3000},
-		{"2000Tokens_6000Pools", 2000, 6000},
+func TestShortestPath(t *testing.T) {
+	setup := func(t *testing.T) *Graph {
+		tokens := make(map[uint64]common.Address)
+		for id := uint64(1); id <= 7; id++ {
+			tokens[id] = common.HexToAddress(fmt.Sprintf("0x%x", id))
+		}
+		pools := make(map[uint64]common.Address)
+		for id := uint64(201); id <= 207; id++ {
+			pools[id] = common.HexToAddress(fmt.Sprintf("0x%x", id))
+		}
+		pool := func(id, token0, token1 uint64) uniswapv2.Pool {
+			return uniswapv2.Pool{ID: id, Token0: token0, Token1: token1, Reserve0: big.NewInt(1e18), Reserve1: big.NewInt(1e18), FeeBps: 30}
+		}
+		uniswapV2Pools := []uniswapv2.Pool{
+			// A long path 1 -> 2 -> 3 -> 4 ...
+			pool(201, 1, 2), pool(202, 2, 3), pool(203, 3, 4),
+			// ... and a shorter one 1 -> 5 -> 4, with two pools on the 1/5 edge.
+			pool(207, 1, 5), pool(204, 1, 5), pool(205, 5, 4),
+			// A separate component.
+			pool(206, 6, 7),
+		}
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+		protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+			uniswapV3ProtocolID: uniswapv3.Schema,
+		}, poolRegistry)
+		graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{}, protocolResolver)
+		require.NoError(t, err)
+		return graph
+	}
+	disable := func(graph *Graph, poolID uint64) {
+		graph.allGetAmountOutFuncs[graph.poolToIndex[poolID]] = nil
 	}
 
-	for _, bc := range benchmarkCases {
-		b.Run(bc.name, func(b *testing.B) {
-			// Setup is done once, outside the timed loop
-			graph := setupUniswapV2BenchmarkGraph(b, bc.numTokens, bc.numPools)
-			baseAmount := new(big.Int).SetUint64(1e18)
-			baseTokenID := uint64(0) // Start from one of our hub tokens
+	t.Run("Fewest hops wins over a longer path", func(t *testing.T) {
+		graph := setup(t)
+		expected := []chains.TokenPoolPath{
+			{TokenInID: 1, TokenOutID: 5, PoolID: 204}, // lowest pool ID on the edge
+			{TokenInID: 5, TokenOutID: 4, PoolID: 205},
+		}
+		assert.Equal(t, expected, graph.ShortestPath(1, 4))
+		assert.Len(t, graph.ShortestPath(4, 1), 2)
+		assert.Len(t, graph.ShortestPath(1, 2), 1)
+		assert.Len(t, graph.ShortestPath(2, 5), 2)
+	})
 
-			// Reset the timer to exclude setup time
-			b.ResetTimer()
+	t.Run("Unroutable pools are skipped", func(t *testing.T) {
+		graph := setup(t)
+		disable(graph, 204)
+		assert.Equal(t, uint64(207), graph.ShortestPath(1, 5)[0].PoolID)
 
-			// The loop runs b.N times
+		disable(graph, 205)
+		expected := []chains.TokenPoolPath{
+			{TokenInID: 1, TokenOutID: 2, PoolID: 201},
+			{TokenInID: 2, TokenOutID: 3, PoolID: 202},
+			{TokenInID: 3, TokenOutID: 4, PoolID: 203},
+		}
+		assert.Equal(t, expected, graph.ShortestPath(1, 4))
+	})
+
+	t.Run("Same token", func(t *testing.T) {
+		path := setup(t).ShortestPath(3, 3)
+		assert.NotNil(t, path)
+		assert.Empty(t, path)
+	})
+
+	t.Run("Unreachable or unknown token", func(t *testing.T) {
+		graph := setup(t)
+		assert.Nil(t, graph.ShortestPath(1, 6))
+		assert.Nil(t, graph.ShortestPath(1, 999))
+		assert.Nil(t, graph.ShortestPath(999, 1))
+	})
+}
+
+func TestAllSpotPrices(t *testing.T) {
+	t.Run("Prices V2 and V3 pools", func(t *testing.T) {
+		graph, _, v2View, v3View := setupGetExchangeRatesGraph(t, map[uint64]struct{}{})
+		prices, err := graph.AllSpotPrices(chains.SpotPriceParams{})
+		require.NoError(t, err)
+		require.Len(t, prices, 3)
+
+		// Without token metadata prices are raw: token1 units per token0 unit.
+		v2Pool, _ := v2View.GetByID(101)
+		expected := new(big.Float).Quo(new(big.Float).SetInt(v2Pool.Reserve1), new(big.Float).SetInt(v2Pool.Reserve0))
+		assert.Equal(t, 0, expected.Cmp(prices[101]))
+
+		v3Pool, _ := v3View.GetByID(102)
+		reserve0, reserve1 := uniswapv3calculator.VirtualReserves(v3Pool)
+		expected = new(big.Float).Quo(new(big.Float).SetInt(reserve1), new(big.Float).SetInt(reserve0))
+		assert.Equal(t, 0, expected.Cmp(prices[102]))
+	})
+
+	t.Run("Parallel results match the serial computation", func(t *testing.T) {
+		graph := setupUniswapV2BenchmarkGraph(t, 200, 600)
+		serial, err := graph.AllSpotPrices(chains.SpotPriceParams{Concurrency: 1})
+		require.NoError(t, err)
+		require.Len(t, serial, 600)
+
+		for _, concurrency := range []int{2, 4, 16} {
+			for _, batchSize := range []int{0, 1, 7, 1000} {
+				params := chains.SpotPriceParams{Concurrency: concurrency, BatchSize: batchSize}
+				for run := 0; run < 3; run++ {
+					parallel, err := graph.AllSpotPrices(params)
+					require.NoError(t, err)
+					require.Len(t, parallel, len(serial), "%+v", params)
+					for poolID, price := range serial {
+						require.Contains(t, parallel, poolID, "%+v", params)
+						require.Equal(t, 0, price.Cmp(parallel[poolID]), "pool %d, %+v", poolID, params)
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("Invalid parameters", func(t *testing.T) {
+		graph, _, _, _ := setupGetExchangeRatesGraph(t, map[uint64]struct{}{})
+		_, err := graph.AllSpotPrices(chains.SpotPriceParams{Concurrency: -1})
+		assert.Error(t, err)
+		_, err = graph.AllSpotPrices(chains.SpotPriceParams{BatchSize: -1})
+		assert.Error(t, err)
+	})
+}
+
+func BenchmarkAllSpotPrices(b *testing.B) {
+	graph := setupUniswapV2BenchmarkGraph(b, 2000, 6000)
+	for _, concurrency := range []int{1, 4, 8} {
+		b.Run(fmt.Sprintf("6000Pools_Concurrency%d", concurrency), func(b *testing.B) {
+			params := chains.SpotPriceParams{Concurrency: concurrency}
+			b.ResetTimer()
 			for i := 0; i < b.N; i++ {
-				// The function being benchmarked
-				_, _ = graph.GetExchangeRates(baseAmount, baseTokenID, 5, nil) // 5 runs for propagation
+				_, _ = graph.AllSpotPrices(params)
 			}
 		})
 	}
 }
 
-// setupArbitrageTestGraph creates a graph specifically for testing arbitrage cycles.
-// It features a 3-token loop (WETH-USDC-DAI) with initially balanced pools.
-func setupArbitrageTestGraph(t *testing.T, activePools map[uint64]struct{}) *Graph {
+func TestRateForSize(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
 	tokens := map[uint64]common.Address{
-		1: common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"), // WETH
-		2: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), // USDC
-		3: common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F"), // DAI
+		1: common.HexToAddress("0x1"), // WETH
+		2: common.HexToAddress("0x2"), // USDC
 	}
-	pools := map[uint64]common.Address{
-		101: common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc"), // V2 WETH/USDC
-		102: common.HexToAddress("0xAE461cA67B15dc82787E5236E28020173Bf30ac2"), // V2 USDC/DAI
-		103: common.HexToAddress("0xa478c2975ab1ea89e8196811f51a7b7ade33eb11"), // V2 DAI/WETH
-	}
-
-	// Reserves are set to create a balanced 1:4000:4000 price ratio
+	pools := map[uint64]common.Address{101: common.HexToAddress("0x101")}
+	// A shallow pool: 10 WETH against 30,000 USDC.
 	uniswapV2Pools := []uniswapv2.Pool{
-		{ID: 101, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18)), Reserve1: new(big.Int).Mul(big.NewInt(4000000), big.NewInt(1e6)), FeeBps: 30},
-		{ID: 102, Token0: 2, Token1: 3, Reserve0: new(big.Int).Mul(big.NewInt(4000000), big.NewInt(1e6)), Reserve1: new(big.Int).Mul(big.NewInt(4000000), big.NewInt(1e18)), FeeBps: 30},
-		{ID: 103, Token0: 3, Token1: 1, Reserve0: new(big.Int).Mul(big.NewInt(4000000), big.NewInt(1e18)), Reserve1: new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18)), FeeBps: 30},
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(10), d18), Reserve1: big.NewInt(30_000e6), FeeBps: 30},
 	}
-
 	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
-
-	protocolIDToProtocolSchema := map[engine.ProtocolID]engine.ProtocolSchema{
+	protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
 		uniswapV2ProtocolID: uniswapv2.Schema,
 		uniswapV3ProtocolID: uniswapv3.Schema,
+	}, poolRegistry)
+	tokenRegistry := tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+		{ID: 1, Symbol: "WETH", Decimals: 18},
+		{ID: 2, Symbol: "USDC", Decimals: 6},
+	})
+	graph, err := NewGraph(rawGraph, tokenRegistry, poolRegistry, v2View, v3View, map[uint64]struct{}{101: {}}, protocolResolver)
+	require.NoError(t, err)
+
+	rateFor := func(numerator, denominator int64) float64 {
+		amountIn := new(big.Int).Div(new(big.Int).Mul(d18, big.NewInt(numerator)), big.NewInt(denominator))
+		rate, err := graph.RateForSize(1, 2, amountIn)
+		require.NoError(t, err)
+		f, _ := rate.Float64()
+		return f
 	}
 
-	protocolResolver := chains.NewProtocolResolver(
-		protocolIDToProtocolSchema,
-		poolRegistry,
-	)
-	graph, err := NewGraph(
-		rawGraph,
-		poolRegistry,
-		v2View,
-		v3View,
-		activePools,
-		protocolResolver,
-	)
-	require.NoError(t, err)
-	return graph
+	small := rateFor(1, 100) // 0.01 WETH
+	medium := rateFor(1, 1)  // 1 WETH
+	large := rateFor(5, 1)   // 5 WETH
+
+	assert.InDelta(t, 2990, small, 5, "a small trade gets close to the spot rate less fees")
+	assert.Greater(t, small, medium)
+	assert.Greater(t, medium, large)
+	assert.InDelta(t, 1994, large, 5, "half the pool's WETH depth costs about a third of the rate")
+
+	_, err = graph.RateForSize(1, 2, big.NewInt(0))
+	assert.Error(t, err)
 }
-func TestFindArbitrageCycles(t *testing.T) {
-	startAmount := new(big.Int).SetUint64(1e18) // 1 WETH
 
-	// Define the default active set where all pools are available.
-	allPoolsActive := map[uint64]struct{}{
-		101: {}, 102: {}, 103: {},
+func TestPortfolioValue(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0x1"), // WETH
+		2: common.HexToAddress("0x2"), // USDC
+		3: common.HexToAddress("0x3"), // WBTC
+		4: common.HexToAddress("0x4"), // DAI, only paired with LINK
+		5: common.HexToAddress("0x5"), // LINK
 	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"),
+		102: common.HexToAddress("0x102"),
+		103: common.HexToAddress("0x103"),
+	}
+	// Deep pools at 3,000 USDC per WETH and 60,000 USDC per WBTC.
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(10_000), d18), Reserve1: big.NewInt(30_000_000e6), FeeBps: 30},
+		{ID: 102, Token0: 3, Token1: 2, Reserve0: big.NewInt(1_000e8), Reserve1: big.NewInt(60_000_000e6), FeeBps: 30},
+		{ID: 103, Token0: 4, Token1: 5, Reserve0: new(big.Int).Mul(big.NewInt(1_000), d18), Reserve1: new(big.Int).Mul(big.NewInt(100), d18), FeeBps: 30},
+	}
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+	protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}, poolRegistry)
+	tokenRegistry := tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+		{ID: 1, Symbol: "WETH", Decimals: 18},
+		{ID: 2, Symbol: "USDC", Decimals: 6},
+		{ID: 3, Symbol: "WBTC", Decimals: 8},
+		{ID: 4, Symbol: "DAI", Decimals: 18},
+		{ID: 5, Symbol: "LINK", Decimals: 18},
+	})
+	graph, err := NewGraph(rawGraph, tokenRegistry, poolRegistry, v2View, v3View, map[uint64]struct{}{101: {}, 102: {}, 103: {}}, protocolResolver)
+	require.NoError(t, err)
 
-	// FindArbitrageCycles will always find cycles (so far there are more than one pool for a token)
-	// because the implementation now finds the best effort at a cycle
-	t.Run("Finds arbitrage with V2 override", func(t *testing.T) {
-		graph := setupArbitrageTestGraph(t, allPoolsActive)
-		originalPool, _ := graph.indexedUniswapV2.GetByID(101)
-		overriddenPool := originalPool
-		overriddenPool.Reserve1 = new(big.Int).Mul(big.NewInt(3800000), big.NewInt(1e6))
-		overriddenPool.Reserve0 = new(big.Int).Set(originalPool.Reserve0)
+	t.Run("Values a three-token portfolio", func(t *testing.T) {
+		portfolio, err := graph.PortfolioValue(map[uint64]*big.Int{
+			1: d18,               // 1 WETH
+			2: big.NewInt(500e6), // 500 USDC
+			3: big.NewInt(0.1e8), // 0.1 WBTC
+			4: big.NewInt(0),     // ignored
+		}, 2)
+		require.NoError(t, err)
+		require.Len(t, portfolio.Values, 3)
+		assert.Empty(t, portfolio.Unpriced)
 
-		params := chains.CycleFindingParams{
-			TokenID:  1,
-			AmountIn: startAmount,
-			Runs:     4,
-			UniswapV2Overrides: map[uint64]uniswapv2.Pool{
-				101: overriddenPool,
-			},
+		value := func(tokenID uint64) float64 {
+			f, _ := portfolio.Values[tokenID].Float64()
+			return f
 		}
+		// Each balance is worth its spot value less the 0.3% fee; the pools are deep enough
+		// for price impact to stay below a dollar.
+		assert.InDelta(t, 2991, value(1), 1)
+		assert.Equal(t, float64(500), value(2))
+		assert.InDelta(t, 5982, value(3), 1)
+
+		total, _ := portfolio.Total.Float64()
+		assert.InDelta(t, value(1)+value(2)+value(3), total, 1e-9)
+	})
 
-		cycles, costs, err := graph.FindArbitrageCycles(params)
+	t.Run("Reports tokens with no route", func(t *testing.T) {
+		portfolio, err := graph.PortfolioValue(map[uint64]*big.Int{
+			1:   d18,
+			5:   d18, // LINK only trades against DAI
+			999: d18, // not in the graph
+		}, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{5, 999}, portfolio.Unpriced)
+		require.Len(t, portfolio.Values, 1)
+		assert.Equal(t, 0, portfolio.Total.Cmp(portfolio.Values[1]))
+	})
+
+	t.Run("Invalid inputs", func(t *testing.T) {
+		_, err := graph.PortfolioValue(map[uint64]*big.Int{1: d18}, 999)
+		assert.Error(t, err, "unknown base token")
+
+		_, err = graph.PortfolioValue(map[uint64]*big.Int{1: big.NewInt(-1)}, 2)
+		assert.Error(t, err, "negative balance")
+	})
+}
+
+func TestQuoteRoute(t *testing.T) {
+	graph := setupSwapPathTestGraph(t, map[uint64]struct{}{101: {}, 102: {}, 103: {}, 104: {}, 105: {}})
+	amountIn := new(big.Int).SetUint64(1e18)
 
+	t.Run("Matches the best swap path hop by hop", func(t *testing.T) {
+		path, amountOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{TokenInID: 1, TokenOutID: 4, AmountIn: amountIn, Runs: 3})
 		require.NoError(t, err)
-		require.Len(t, cycles, 1, "Should find exactly one profitable cycle")
-		require.Len(t, costs, 1, "Should find exactly one profitable cost")
 
-		expectedPath := []chains.TokenPoolPath{
-			{TokenInID: 1, TokenOutID: 3, PoolID: 103},
-			{TokenInID: 3, TokenOutID: 2, PoolID: 102},
-			{TokenInID: 2, TokenOutID: 1, PoolID: 101},
+		quote, err := graph.QuoteRoute(path, amountIn)
+		require.NoError(t, err)
+		assert.Equal(t, amountIn, quote.AmountIn)
+		assert.Equal(t, amountOut, quote.AmountOut)
+		require.Len(t, quote.Hops, len(path))
+
+		for i, hop := range quote.Hops {
+			assert.Equal(t, path[i], hop.TokenPoolPath)
+			if i > 0 {
+				assert.Equal(t, quote.Hops[i-1].AmountOut, hop.AmountIn, "hop %d should sell the previous hop's output", i)
+			}
+			// Selling into the pool lowers its price of the input token.
+			assert.Equal(t, -1, hop.SpotPriceAfter.Cmp(hop.SpotPriceBefore))
+			assert.Equal(t, 1, hop.PriceImpact.Sign())
 		}
-		assert.Equal(t, expectedPath, cycles[0], "The arbitrage path is incorrect")
-		profit := new(big.Int).Sub(costs[0], startAmount)
-		assert.True(t, profit.Sign() > 0, "The cycle should be profitable")
+		last := quote.Hops[len(quote.Hops)-1]
+		assert.Equal(t, amountOut, last.AmountOut)
+		assert.Equal(t, last.CumulativePriceImpact, quote.PriceImpact)
+		assert.Equal(t, 1, quote.PriceImpact.Cmp(quote.Hops[0].PriceImpact), "impact accumulates along the route")
 	})
 
-	t.Run("Non-existent start token", func(t *testing.T) {
-		graph := setupArbitrageTestGraph(t, allPoolsActive)
-		params := chains.CycleFindingParams{
-			TokenID:  999,
-			AmountIn: startAmount,
-			Runs:     3,
-		}
-		_, _, err := graph.FindArbitrageCycles(params)
-		require.Error(t, err)
-		assert.Contains(t, err.Error(), "token 999 not found in the graph")
+	t.Run("V2 prices from reserves", func(t *testing.T) {
+		quote, err := graph.QuoteRoute([]chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 2, PoolID: 101}}, amountIn)
+		require.NoError(t, err)
+		hop := quote.Hops[0]
+
+		pool, _ := graph.indexedUniswapV2.GetByID(101)
+		spot, _ := new(big.Float).Quo(new(big.Float).SetInt(pool.Reserve1), new(big.Float).SetInt(pool.Reserve0)).Float64()
+		before, _ := hop.SpotPriceBefore.Float64()
+		assert.InDelta(t, spot, before, 1e-12)
+
+		reserve0 := new(big.Int).Add(pool.Reserve0, amountIn)
+		reserve1 := new(big.Int).Sub(pool.Reserve1, hop.AmountOut)
+		spotAfter, _ := new(big.Float).Quo(new(big.Float).SetInt(reserve1), new(big.Float).SetInt(reserve0)).Float64()
+		after, _ := hop.SpotPriceAfter.Float64()
+		assert.InDelta(t, spotAfter, after, 1e-12)
+
+		// A small trade in a deep pool pays little more than the 0.3% fee.
+		impact, _ := hop.PriceImpact.Float64()
+		assert.InDelta(t, 0.003, impact, 0.001)
+	})
+
+	t.Run("Invalid route", func(t *testing.T) {
+		_, err := graph.QuoteRoute(nil, amountIn)
+		assert.Error(t, err)
+		_, err = graph.QuoteRoute([]chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 2, PoolID: 101}}, big.NewInt(0))
+		assert.Error(t, err)
+		_, err = graph.QuoteRoute([]chains.TokenPoolPath{
+			{TokenInID: 1, TokenOutID: 2, PoolID: 101},
+			{TokenInID: 3, TokenOutID: 4, PoolID: 105},
+		}, amountIn)
+		assert.Error(t, err)
 	})
 }
 
-// setupArbitrageBenchmarkGraph creates a large, complex, and interconnected graph with diverse arbitrage opportunities.
-func setupArbitrageBenchmarkGraph(t testing.TB, numTokens, numPools int) *Graph {
-	tokens := make(map[uint64]common.Address, numTokens)
-	for i := 0; i < numTokens; i++ {
-		tokens[uint64(i)] = common.HexToAddress(fmt.Sprintf("0x%040x", i))
+func TestFindSplitRoute(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0x1"), // WETH
+		2: common.HexToAddress("0x2"), // USDC
+		3: common.HexToAddress("0x3"), // DAI
 	}
-
-	pools := make(map[uint64]common.Address, numPools)
-	uniswapV2Pools := make([]uniswapv2.Pool, 0, numPools)
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	poolIDCounter := uint64(0)
-
-	// Define hub tokens
-	hubTokens := []uint64{0, 1, 2} // WETH, USDC, DAI equivalents
-	if numTokens < len(hubTokens) {
-		hubTokens = hubTokens[:numTokens]
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"),
+		102: common.HexToAddress("0x102"),
+		103: common.HexToAddress("0x103"),
+		104: common.HexToAddress("0x104"),
 	}
+	// Two WETH/USDC pools of different depth, and a WETH -> DAI -> USDC detour.
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(1_000), d18), Reserve1: big.NewInt(3_000_000e6), FeeBps: 30},
+		{ID: 102, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(500), d18), Reserve1: big.NewInt(1_500_000e6), FeeBps: 5},
+		{ID: 103, Token0: 1, Token1: 3, Reserve0: new(big.Int).Mul(big.NewInt(200), d18), Reserve1: new(big.Int).Mul(big.NewInt(600_000), d18), FeeBps: 30},
+		{ID: 104, Token0: 3, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(10_000_000), d18), Reserve1: big.NewInt(10_000_000e6), FeeBps: 1},
+	}
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+	protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}, poolRegistry)
+	graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{101: {}, 102: {}, 103: {}, 104: {}}, protocolResolver)
+	require.NoError(t, err)
 
-	// Big number setup
-	decimals := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	amountIn := new(big.Int).Mul(big.NewInt(100), d18)
 
-	// Helper to create a pool
-	createPool := func(tA, tB uint64, rA, rB *big.Int) {
-		if poolIDCounter >= uint64(numPools) {
-			return
+	t.Run("Splits a large trade across disjoint routes", func(t *testing.T) {
+		result, err := graph.FindSplitRoute(chains.SplitRouteParams{AmountIn: amountIn, TokenInID: 1, TokenOutID: 2, Runs: 3})
+		require.NoError(t, err)
+		require.Len(t, result.Legs, 3)
+		assert.Equal(t, 1, result.AmountOut.Cmp(result.SinglePathOut), "splitting should beat the single best route")
+
+		_, singleOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{AmountIn: amountIn, TokenInID: 1, TokenOutID: 2, Runs: 3})
+		require.NoError(t, err)
+		assert.Equal(t, singleOut, result.SinglePathOut)
+
+		totalIn, totalOut := new(big.Int), new(big.Int)
+		usedPools := map[uint64]struct{}{}
+		for _, leg := range result.Legs {
+			out, err := graph.quoteRoute(leg.Path, leg.AmountIn)
+			require.NoError(t, err)
+			assert.Equal(t, out, leg.AmountOut)
+			totalIn.Add(totalIn, leg.AmountIn)
+			totalOut.Add(totalOut, leg.AmountOut)
+			for _, hop := range leg.Path {
+				_, used := usedPools[hop.PoolID]
+				assert.False(t, used, "pool %d is shared between legs", hop.PoolID)
+				usedPools[hop.PoolID] = struct{}{}
+			}
 		}
-		poolID := poolIDCounter
-		pools[poolID] = common.HexToAddress(fmt.Sprintf("0x1%039x", poolID))
-		uniswapV2Pools = append(uniswapV2Pools, uniswapv2.Pool{ID: poolID, Token0: tA, Token1: tB, Reserve0: rA, Reserve1: rB, FeeBps: 30})
-		poolIDCounter++
-	}
+		assert.Equal(t, amountIn, totalIn)
+		assert.Equal(t, result.AmountOut, totalOut)
 
-	// --- Step 1: Guaranteed Connectivity (Backbone) ---
-	// Connect every non-hub token to a random hub
-	for i := len(hubTokens); i < numTokens; i++ {
-		hub := hubTokens[r.Intn(len(hubTokens))]
-		reserveHub := new(big.Int).Mul(big.NewInt(1000), decimals)
-		reserveSpoke := new(big.Int).Mul(big.NewInt(int64(1000+r.Intn(5000))), decimals)
-		createPool(hub, uint64(i), reserveHub, reserveSpoke)
-	}
+		// The legs plug straight into SplitSlippage.
+		slippage, err := graph.SplitSlippage(result.Legs)
+		require.NoError(t, err)
+		assert.Equal(t, result.AmountOut, slippage.AmountOut)
+	})
 
-	// --- Step 2: Hub Interconnectivity & Arbitrage Injection ---
-	// Create a richly connected core with guaranteed arbitrage loops
-	for i := 0; i < len(hubTokens); i++ {
-		for j := i + 1; j < len(hubTokens); j++ {
-			tA, tB := hubTokens[i], hubTokens[j]
+	t.Run("Deeper routes take more of the input", func(t *testing.T) {
+		result, err := graph.FindSplitRoute(chains.SplitRouteParams{AmountIn: amountIn, TokenInID: 1, TokenOutID: 2, Runs: 3, MaxRoutes: 2})
+		require.NoError(t, err)
+		require.Len(t, result.Legs, 2)
+		allocation := map[uint64]*big.Int{}
+		for _, leg := range result.Legs {
+			allocation[leg.Path[0].PoolID] = leg.AmountIn
+		}
+		assert.Equal(t, 1, allocation[101].Cmp(allocation[102]))
+	})
 
-			// Create one "fair" pool
-			rA_fair := new(big.Int).Mul(big.NewInt(5000), decimals)
-			rB_fair := new(big.Int).Mul(big.NewInt(5000), decimals)
-			createPool(tA, tB, rA_fair, rB_fair)
+	t.Run("A single route holds the whole trade", func(t *testing.T) {
+		result, err := graph.FindSplitRoute(chains.SplitRouteParams{AmountIn: amountIn, TokenInID: 1, TokenOutID: 2, Runs: 3, MaxRoutes: 1})
+		require.NoError(t, err)
+		require.Len(t, result.Legs, 1)
+		assert.Equal(t, amountIn, result.Legs[0].AmountIn)
+		assert.Equal(t, result.SinglePathOut, result.AmountOut)
+	})
 
-			// Create a second, imbalanced pool to guarantee an arbitrage opportunity
-			rA_imbalanced := new(big.Int).Mul(big.NewInt(5000), decimals)
-			imbalanceFactor := 1.0 + 0.02 + (r.Float64() * 0.05) // 2-7% imbalance
-			rB_imbalanced_float := new(big.Float).Mul(new(big.Float).SetInt(rB_fair), big.NewFloat(imbalanceFactor))
-			rB_imbalanced, _ := rB_imbalanced_float.Int(nil)
-			createPool(tA, tB, rA_imbalanced, rB_imbalanced)
+	t.Run("Tiny trades are not split below one unit", func(t *testing.T) {
+		result, err := graph.FindSplitRoute(chains.SplitRouteParams{AmountIn: big.NewInt(3), TokenInID: 2, TokenOutID: 3, Runs: 3})
+		require.NoError(t, err)
+		totalIn := new(big.Int)
+		for _, leg := range result.Legs {
+			totalIn.Add(totalIn, leg.AmountIn)
 		}
+		assert.Equal(t, big.NewInt(3), totalIn)
+	})
+
+	t.Run("Unknown token", func(t *testing.T) {
+		_, err := graph.FindSplitRoute(chains.SplitRouteParams{AmountIn: amountIn, TokenInID: 1, TokenOutID: 4, Runs: 3})
+		assert.Error(t, err)
+	})
+
+	t.Run("Invalid params", func(t *testing.T) {
+		_, err := graph.FindSplitRoute(chains.SplitRouteParams{AmountIn: big.NewInt(0), TokenInID: 1, TokenOutID: 2, Runs: 3})
+		assert.Error(t, err)
+		_, err = graph.FindSplitRoute(chains.SplitRouteParams{AmountIn: amountIn, TokenInID: 1, TokenOutID: 2})
+		assert.Error(t, err)
+		_, err = graph.FindSplitRoute(chains.SplitRouteParams{AmountIn: amountIn, TokenInID: 1, TokenOutID: 2, Runs: 3, Parts: -1})
+		assert.Error(t, err)
+	})
+}
+
+func TestSplitSlippage(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0x1"), // WETH
+		2: common.HexToAddress("0x2"), // USDC
+		3: common.HexToAddress("0x3"), // DAI
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"),
+		102: common.HexToAddress("0x102"),
+		103: common.HexToAddress("0x103"),
+	}
+	// Two WETH/USDC pools at slightly different prices, and a DAI/USDC pool.
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(1_000), d18), Reserve1: big.NewInt(3_000_000e6), FeeBps: 30},
+		{ID: 102, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(500), d18), Reserve1: big.NewInt(1_510_000e6), FeeBps: 5},
+		{ID: 103, Token0: 3, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(1_000_000), d18), Reserve1: big.NewInt(1_000_000e6), FeeBps: 1},
 	}
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+	protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}, poolRegistry)
+	tokenRegistry := tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+		{ID: 1, Symbol: "WETH", Decimals: 18},
+		{ID: 2, Symbol: "USDC", Decimals: 6},
+		{ID: 3, Symbol: "DAI", Decimals: 18},
+	})
+	graph, err := NewGraph(rawGraph, tokenRegistry, poolRegistry, v2View, v3View, map[uint64]struct{}{101: {}, 102: {}, 103: {}}, protocolResolver)
+	require.NoError(t, err)
 
-	// --- Step 3: Add Random Cross-Connections for Chaos ---
-	for poolIDCounter < uint64(numPools) {
-		tokenA := uint64(r.Intn(numTokens))
-		tokenB := uint64(r.Intn(numTokens))
-		if tokenA == tokenB {
-			continue
+	leg := func(poolID uint64, wholeIn int64) chains.SplitLeg {
+		path := []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 2, PoolID: poolID}}
+		amountIn := new(big.Int).Mul(big.NewInt(wholeIn), d18)
+		amountOut, err := graph.quoteRoute(path, amountIn)
+		require.NoError(t, err)
+		return chains.SplitLeg{Path: path, AmountIn: amountIn, AmountOut: amountOut}
+	}
+
+	t.Run("Two-leg split matches a manual computation", func(t *testing.T) {
+		legA, legB := leg(101, 60), leg(102, 40)
+		result, err := graph.SplitSlippage([]chains.SplitLeg{legA, legB})
+		require.NoError(t, err)
+
+		usdc := func(amount *big.Int) float64 { f, _ := new(big.Float).SetInt(amount).Float64(); return f / 1e6 }
+		totalOut := usdc(legA.AmountOut) + usdc(legB.AmountOut)
+		effective := totalOut / 100
+		spot := 3020.0 // pool 102 is the better pre-trade price
+		slippage := 1 - effective/spot
+
+		assert.Equal(t, new(big.Int).Mul(big.NewInt(100), d18), result.AmountIn)
+		assert.Equal(t, new(big.Int).Add(legA.AmountOut, legB.AmountOut), result.AmountOut)
+		gotSpot, _ := result.SpotPrice.Float64()
+		gotEffective, _ := result.EffectivePrice.Float64()
+		gotSlippage, _ := result.Slippage.Float64()
+		assert.InDelta(t, spot, gotSpot, 1e-9)
+		assert.InDelta(t, effective, gotEffective, 1e-9)
+		assert.InDelta(t, slippage, gotSlippage, 1e-12)
+
+		// Per-leg slippage against each leg's own pool does not add up to the net figure.
+		legSlippage := func(l chains.SplitLeg, legSpot float64) float64 {
+			f, _ := new(big.Float).SetInt(l.AmountIn).Float64()
+			return 1 - usdc(l.AmountOut)/(f/1e18)/legSpot
 		}
-		rA := new(big.Int).Mul(big.NewInt(int64(100+r.Intn(1000))), decimals)
-		rB := new(big.Int).Mul(big.NewInt(int64(100+r.Intn(1000))), decimals)
-		createPool(tokenA, tokenB, rA, rB)
+		sum := legSlippage(legA, 3000) + legSlippage(legB, 3020)
+		assert.Greater(t, math.Abs(sum-gotSlippage), 1e-3)
+	})
+
+	t.Run("Multi-hop legs are priced through every hop", func(t *testing.T) {
+		path := []chains.TokenPoolPath{{TokenInID: 3, TokenOutID: 2, PoolID: 103}, {TokenInID: 2, TokenOutID: 1, PoolID: 101}}
+		amountIn := new(big.Int).Mul(big.NewInt(3_000), d18)
+		amountOut, err := graph.quoteRoute(path, amountIn)
+		require.NoError(t, err)
+
+		result, err := graph.SplitSlippage([]chains.SplitLeg{{Path: path, AmountIn: amountIn, AmountOut: amountOut}})
+		require.NoError(t, err)
+		spot, _ := result.SpotPrice.Float64()
+		assert.InDelta(t, 1.0/3000, spot, 1e-12)
+		assert.Equal(t, 1, result.Slippage.Sign())
+	})
+
+	t.Run("Invalid splits", func(t *testing.T) {
+		_, err := graph.SplitSlippage(nil)
+		assert.Error(t, err)
+
+		reverse := chains.SplitLeg{
+			Path:      []chains.TokenPoolPath{{TokenInID: 2, TokenOutID: 1, PoolID: 101}},
+			AmountIn:  big.NewInt(1e6),
+			AmountOut: big.NewInt(1),
+		}
+		_, err = graph.SplitSlippage([]chains.SplitLeg{leg(101, 1), reverse})
+		assert.Error(t, err, "legs must share their endpoints")
+
+		noAmount := leg(101, 1)
+		noAmount.AmountIn = nil
+		_, err = graph.SplitSlippage([]chains.SplitLeg{noAmount})
+		assert.Error(t, err)
+	})
+}
+
+func TestPoolTokenDecimals(t *testing.T) {
+	graph, _, _, _, _ := setupSimpleTestGraph(t, map[uint64]struct{}{})
+	// Token 4 (WBTC) is intentionally missing from the registry.
+	graph.indexedTokenRegistry = tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+		{ID: 1, Symbol: "WETH", Decimals: 18},
+		{ID: 2, Symbol: "USDC", Decimals: 6},
+		{ID: 3, Symbol: "DAI", Decimals: 18},
+	})
+
+	t.Run("V2 Pool with mismatched decimals", func(t *testing.T) {
+		decimals0, decimals1, ok := graph.PoolTokenDecimals(101)
+		require.True(t, ok)
+		assert.Equal(t, uint8(18), decimals0)
+		assert.Equal(t, uint8(6), decimals1)
+	})
+
+	t.Run("V3 Pool", func(t *testing.T) {
+		decimals0, decimals1, ok := graph.PoolTokenDecimals(102)
+		require.True(t, ok)
+		assert.Equal(t, uint8(18), decimals0)
+		assert.Equal(t, uint8(18), decimals1)
+	})
+
+	t.Run("Token missing from registry", func(t *testing.T) {
+		_, _, ok := graph.PoolTokenDecimals(104)
+		assert.False(t, ok)
+	})
+
+	t.Run("Non-existent pool", func(t *testing.T) {
+		_, _, ok := graph.PoolTokenDecimals(999)
+		assert.False(t, ok)
+	})
+
+	t.Run("No token registry", func(t *testing.T) {
+		graphWithoutRegistry, _, _, _, _ := setupSimpleTestGraph(t, map[uint64]struct{}{})
+		_, _, ok := graphWithoutRegistry.PoolTokenDecimals(101)
+		assert.False(t, ok)
+	})
+}
+
+func setupGetExchangeRatesGraph(t *testing.T, activePools map[uint64]struct{}) (*Graph, poolregistryindexer.IndexedPoolRegistry, uniswapv2indexer.IndexedUniswapV2, uniswapv3indexer.IndexedUniswapV3) {
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"), // WETH
+		2: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), // USDC
+		3: common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F"), // DAI
+		4: common.HexToAddress("0x2260FAC5E5542a773Aa44fBCfeDf7C193bc2C599"), // WBTC
+		5: common.HexToAddress("0x4Fabb145d64652a948d72533023f6E7A623C7C53"), // BUSD (Isolated)
+		6: common.HexToAddress("0x95aD61b0a150d79219dCF64E1E6Cc01f0B64C4cE"), // SHIB (Isolated)
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc"), // V2 WETH/USDC
+		102: common.HexToAddress("0x88e6a0c2ddd26feeb64f039a2c41296fcb3f5640"), // V3 WETH/USDC
+		104: common.HexToAddress("0xBb2b8038a1640196FbE3e38816F3e67Cba72D940"), // V2 WETH/WBTC
 	}
 
-	activePools := make(map[uint64]struct{}, numPools)
-	for i := 0; i < int(poolIDCounter); i++ {
-		activePools[uint64(i)] = struct{}{}
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: bigIntFromString("4000000000000000000000"), Reserve1: bigIntFromString("15600000000000"), FeeBps: 30},
+		{ID: 104, Token0: 1, Token1: 4, Reserve0: bigIntFromString("1000000000000000000000"), Reserve1: bigIntFromString("3154340000"), FeeBps: 30},
+	}
+	uniswapV3Pools := []uniswapv3.Pool{
+		setupUniswapV3ETHUSDCPool(2, 1, 102), // 2 == USDC, 1 == WETH, 102 == poolID
 	}
 
-	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, uniswapV3Pools)
 
 	protocolIDToProtocolSchema := map[engine.ProtocolID]engine.ProtocolSchema{
 		uniswapV2ProtocolID: uniswapv2.Schema,
@@ -7755,6 +8399,7 @@ func setupArbitrageBenchmarkGraph(t testing.TB, numTokens, numPools int) *Graph
 
 	graph, err := NewGraph(
 		rawGraph,
+		nil,
 		poolRegistry,
 		v2View,
 		v3View,
@@ -7762,251 +8407,3472 @@ func setupArbitrageBenchmarkGraph(t testing.TB, numTokens, numPools int) *Graph
 		protocolResolver,
 	)
 	require.NoError(t, err)
-	return graph
+	return graph, poolRegistry, v2View, v3View
 }
-func BenchmarkFindArbitrageCycles(b *testing.B) {
-	benchmarkCases := []struct {
-		name      string
-		numTokens int
-		numPools  int
-		runs      int // Path length to search
-	}{
-		{"100T_300P_3Hops", 100, 300, 3},
-		{"200T_600P_3Hops", 200, 600, 3},
-		{"500T_1500P_3Hops", 500, 1500, 3},
-		{"1000T_3000P_3Hops", 1000, 3000, 3},
-		{"100T_300P_4Hops", 100, 300, 4},
-		{"200T_600P_4Hops", 200, 600, 4},
-		{"500T_1500P_4Hops", 500, 1500, 4},
-		{"1000T_3000P_4Hops", 1000, 3000, 4},
-		{"5000T_10000P_3Hops", 5000, 10000, 3},
-		{"5000T_10000P_4Hops", 5000, 10000, 4},
+
+func TestGetExchangeRates(t *testing.T) {
+	activePools := map[uint64]struct{}{101: {}, 102: {}, 104: {}}
+	graph, _, _, _ := setupGetExchangeRatesGraph(t, activePools)
+
+	t.Run("Success from WETH", func(t *testing.T) {
+		// Start with 1 WETH (10^18)
+		oneWETH := new(big.Int).SetUint64(1e18)
+		equivalents, err := graph.GetExchangeRates(oneWETH, 1, 3, nil) // No source token restriction
+
+		require.NoError(t, err)
+		require.NotNil(t, equivalents)
+		require.Len(t, equivalents, 3, "Should find equivalents for WETH, USDC, and WBTC")
+
+		// The base token's equivalent should be exactly the input amount.
+		assert.Equal(t, 0, oneWETH.Cmp(equivalents[1]), "Base token WETH equivalent should match input amount")
+
+		usdcAmount := equivalents[2] // USDC
+		wbtcAmount := equivalents[4] // WBTC
+		wethAmount := equivalents[1]
+
+		assert.NotNil(t, usdcAmount)
+		assert.True(t, usdcAmount.Cmp(big.NewInt(3924259038)) == 0, "USDC equivalent should be exactly 3924259038")
+		assert.NotNil(t, wbtcAmount)
+		assert.True(t, wbtcAmount.Cmp(big.NewInt(3141744)) == 0, "WBTC equivalent should be exactly 3141744")
+		assert.NotNil(t, wethAmount)
+		assert.True(t, wethAmount.Cmp(oneWETH) == 0, "WETH equivalent should be equal to oneWETH")
+	})
+
+	t.Run("Success with allowedSourceTokens", func(t *testing.T) {
+		oneWETH := new(big.Int).SetUint64(1e18)
+		// Only allow WETH to be a source of price propagation.
+		allowedSourceTokens := map[uint64]struct{}{1: {}}
+		equivalents, err := graph.GetExchangeRates(oneWETH, 1, 3, allowedSourceTokens)
+
+		require.NoError(t, err)
+		require.NotNil(t, equivalents)
+		// Should still find all connected tokens, as the search starts from WETH.
+		require.Len(t, equivalents, 3)
+		assert.Contains(t, equivalents, uint64(1))
+		assert.Contains(t, equivalents, uint64(2))
+		assert.Contains(t, equivalents, uint64(4))
+	})
+
+	t.Run("Non-existent base token", func(t *testing.T) {
+		oneWETH := new(big.Int).SetUint64(1e18)
+		_, err := graph.GetExchangeRates(oneWETH, 999, 3, nil) // Non-existent token ID
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "token 999 not found in the graph")
+	})
+
+	t.Run("No path to isolated token", func(t *testing.T) {
+		oneWETH := new(big.Int).SetUint64(1e18)
+		equivalents, err := graph.GetExchangeRates(oneWETH, 1, 3, nil)
+
+		require.NoError(t, err)
+		require.NotNil(t, equivalents)
+		_, exists := equivalents[5] // BUSD
+		assert.False(t, exists, "Isolated token BUSD should not have an equivalent")
+	})
+}
+
+// setupUniswapV2BenchmarkGraph creates a large, realistic graph using only V2 pools for performance testing.
+// It uses a "hub-and-spoke" model to simulate real-world liquidity patterns.
+func setupUniswapV2BenchmarkGraph(t require.TestingT, numTokens, numPools int) *Graph {
+	tokens := make(map[uint64]common.Address, numTokens)
+	for i := 0; i < numTokens; i++ {
+		tokens[uint64(i)] = common.HexToAddress(fmt.Sprintf("0x%040x", i))
 	}
 
-	for _, bc := range benchmarkCases {
-		b.Run(bc.name, func(b *testing.B) {
-			graph := setupArbitrageBenchmarkGraph(b, bc.numTokens, bc.numPools)
-			params := chains.CycleFindingParams{
-				TokenID:  0, // Start from our "WETH" hub token
-				AmountIn: new(big.Int).SetUint64(1e18),
-				Runs:     bc.runs,
-			}
+	pools := make(map[uint64]common.Address, numPools)
+	uniswapV2Pools := make([]uniswapv2.Pool, 0, numPools)
 
-			b.ResetTimer()
+	// Define our "hub" tokens (e.g., WETH, USDC)
+	hubTokens := []uint64{0, 1, 2}
+	if numTokens < len(hubTokens) {
+		hubTokens = hubTokens[:numTokens]
+	}
+
+	// Setup for generating large random numbers safely
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	baseReserve := new(big.Int).SetUint64(1e18)
+	maxRandReserve := new(big.Int)
+	maxRandReserve.Exp(big.NewInt(10), big.NewInt(20), nil) // 10^20
+	poolIDCounter := uint64(0)
+
+	// --- Step 1: Guarantee Connectivity ---
+	// Create a "backbone" by linking every non-hub token to a hub.
+	for i := len(hubTokens); i < numTokens; i++ {
+		if poolIDCounter >= uint64(numPools) {
+			break // Stop if we've already created the max number of pools
+		}
+		poolID := poolIDCounter
+		pools[poolID] = common.HexToAddress(fmt.Sprintf("0x1%039x", poolID))
+
+		tokenA := hubTokens[r.Intn(len(hubTokens))] // Pick a random hub
+		tokenB := uint64(i)
+
+		randReserve0 := new(big.Int).Rand(r, maxRandReserve)
+		randReserve1 := new(big.Int).Rand(r, maxRandReserve)
+		reserve0 := new(big.Int).Add(baseReserve, randReserve0)
+		reserve1 := new(big.Int).Add(baseReserve, randReserve1)
+
+		uniswapV2Pools = append(uniswapV2Pools, uniswapv2.Pool{
+			ID:       poolID,
+			Token0:   tokenA,
+			Token1:   tokenB,
+			Reserve0: reserve0,
+			Reserve1: reserve1,
+			Type:     0,
+		})
+		poolIDCounter++
+	}
+
+	// --- Step 2: Add remaining pools randomly for complexity ---
+	for i := poolIDCounter; i < uint64(numPools); i++ {
+		poolID := i
+		pools[poolID] = common.HexToAddress(fmt.Sprintf("0x1%039x", poolID))
+
+		var tokenA, tokenB uint64
+
+		// Create a mix of hub-spoke and spoke-spoke pools
+		if i%5 == 0 && numTokens > len(hubTokens) { // 20% of pools are cross-connects
+			// Spoke-to-spoke pool
+			tokenA = uint64(len(hubTokens) + r.Intn(numTokens-len(hubTokens)))
+			tokenB = uint64(len(hubTokens) + r.Intn(numTokens-len(hubTokens)))
+		} else {
+			// Hub-to-spoke pool
+			tokenA = hubTokens[r.Intn(len(hubTokens))]
+			tokenB = uint64(r.Intn(numTokens))
+		}
+
+		// Ensure we don't create a pool with the same token
+		if tokenA == tokenB {
+			tokenB = (tokenB + 1) % uint64(numTokens)
+		}
+
+		randReserve0 := new(big.Int).Rand(r, maxRandReserve)
+		randReserve1 := new(big.Int).Rand(r, maxRandReserve)
+		reserve0 := new(big.Int).Add(baseReserve, randReserve0)
+		reserve1 := new(big.Int).Add(baseReserve, randReserve1)
+
+		uniswapV2Pools = append(uniswapV2Pools, uniswapv2.Pool{
+			ID:       poolID,
+			Token0:   tokenA,
+			Token1:   tokenB,
+			Reserve0: reserve0,
+			Reserve1: reserve1,
+			FeeBps:   30,
+		})
+	}
+
+	activePools := make(map[uint64]struct{}, numPools)
+	for i := 0; i < numPools; i++ {
+		activePools[uint64(i)] = struct{}{}
+	}
+
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+
+	protocolIDToProtocolSchema := map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}
+
+	protocolResolver := chains.NewProtocolResolver(
+		protocolIDToProtocolSchema,
+		poolRegistry,
+	)
+
+	graph, err := NewGraph(
+		rawGraph,
+		nil,
+		poolRegistry,
+		v2View,
+		v3View,
+		activePools,
+		protocolResolver,
+	)
+	require.NoError(t, err)
+	return graph
+}
+
+func TestGetExchangeRatesLongPathIsExact(t *testing.T) {
+	// A linear 4-hop chain: 1 -> 2 -> 3 -> 4 -> 5, with very different decimals and prices.
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xA"),
+		2: common.HexToAddress("0xB"),
+		3: common.HexToAddress("0xC"),
+		4: common.HexToAddress("0xD"),
+		5: common.HexToAddress("0xE"),
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"),
+		102: common.HexToAddress("0x102"),
+		103: common.HexToAddress("0x103"),
+		104: common.HexToAddress("0x104"),
+	}
+	d18 := new(big.Int).SetUint64(1e18)
+	d6 := new(big.Int).SetUint64(1e6)
+	d8 := new(big.Int).SetUint64(1e8)
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(10000), d18), Reserve1: new(big.Int).Mul(big.NewInt(40000000), d6), FeeBps: 30},
+		{ID: 102, Token0: 2, Token1: 3, Reserve0: new(big.Int).Mul(big.NewInt(33333333), d6), Reserve1: new(big.Int).Mul(big.NewInt(777), d8), FeeBps: 30},
+		{ID: 103, Token0: 3, Token1: 4, Reserve0: new(big.Int).Mul(big.NewInt(999), d8), Reserve1: new(big.Int).Mul(big.NewInt(123456789), d18), FeeBps: 25},
+		{ID: 104, Token0: 4, Token1: 5, Reserve0: new(big.Int).Mul(big.NewInt(987654321), d18), Reserve1: new(big.Int).Mul(big.NewInt(31337), d6), FeeBps: 100},
+	}
+
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+	protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}, poolRegistry)
+	graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{}, protocolResolver)
+	require.NoError(t, err)
+
+	baseAmountIn := new(big.Int).Mul(big.NewInt(3), d18)
+	rates, err := graph.GetExchangeRates(baseAmountIn, 1, 5, nil)
+	require.NoError(t, err)
+
+	// The rate for every token must equal the exact composition of per-hop quotes.
+	expected := new(big.Int).Set(baseAmountIn)
+	for i, pool := range uniswapV2Pools {
+		tokenIn, tokenOut := uint64(i+1), uint64(i+2)
+		expected, err = uniswapv2calculator.GetAmountOut(expected, tokenIn, tokenOut, pool)
+		require.NoError(t, err)
+		require.Contains(t, rates, tokenOut)
+		assert.Equal(t, expected.String(), rates[tokenOut].String(), "rate for token %d after %d hops", tokenOut, i+1)
+	}
+}
+
+func BenchmarkGetExchangeRates(b *testing.B) {
+	// Define different scales to benchmark against
+	benchmarkCases := []struct {
+		name      string
+		numTokens int
+		numPools  int
+	}{
+		{"100Tokens_300Pools", 100, 300},
+		{"500Tokens_1500Pools", 500, 1500},
+		{"1000Tokens_3000Pools", 1000, 3000},
+		{"2000Tokens_6000Pools", 2000, 6000},
+	}
+
+	for _, bc := range benchmarkCases {
+		b.Run(bc.name, func(b *testing.B) {
+			// Setup is done once, outside the timed loop
+			graph := setupUniswapV2BenchmarkGraph(b, bc.numTokens, bc.numPools)
+			baseAmount := new(big.Int).SetUint64(1e18)
+			baseTokenID := uint64(0) // Start from one of our hub tokens
+
+			// Reset the timer to exclude setup time
+			b.ResetTimer()
+
+			// The loop runs b.N times
+			for i := 0; i < b.N; i++ {
+				// The function being benchmarked
+				_, _ = graph.GetExchangeRates(baseAmount, baseTokenID, 5, nil) // 5 runs for propagation
+			}
+		})
+	}
+}
+
+// setupArbitrageTestGraph creates a graph specifically for testing arbitrage cycles.
+// It features a 3-token loop (WETH-USDC-DAI) with initially balanced pools.
+func setupArbitrageTestGraph(t *testing.T, activePools map[uint64]struct{}) *Graph {
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"), // WETH
+		2: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), // USDC
+		3: common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F"), // DAI
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc"), // V2 WETH/USDC
+		102: common.HexToAddress("0xAE461cA67B15dc82787E5236E28020173Bf30ac2"), // V2 USDC/DAI
+		103: common.HexToAddress("0xa478c2975ab1ea89e8196811f51a7b7ade33eb11"), // V2 DAI/WETH
+	}
+
+	// Reserves are set to create a balanced 1:4000:4000 price ratio
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18)), Reserve1: new(big.Int).Mul(big.NewInt(4000000), big.NewInt(1e6)), FeeBps: 30},
+		{ID: 102, Token0: 2, Token1: 3, Reserve0: new(big.Int).Mul(big.NewInt(4000000), big.NewInt(1e6)), Reserve1: new(big.Int).Mul(big.NewInt(4000000), big.NewInt(1e18)), FeeBps: 30},
+		{ID: 103, Token0: 3, Token1: 1, Reserve0: new(big.Int).Mul(big.NewInt(4000000), big.NewInt(1e18)), Reserve1: new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18)), FeeBps: 30},
+	}
+
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+
+	protocolIDToProtocolSchema := map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}
+
+	protocolResolver := chains.NewProtocolResolver(
+		protocolIDToProtocolSchema,
+		poolRegistry,
+	)
+	graph, err := NewGraph(
+		rawGraph,
+		nil,
+		poolRegistry,
+		v2View,
+		v3View,
+		activePools,
+		protocolResolver,
+	)
+	require.NoError(t, err)
+	return graph
+}
+func TestFindArbitrageCycles(t *testing.T) {
+	startAmount := new(big.Int).SetUint64(1e18) // 1 WETH
+
+	// Define the default active set where all pools are available.
+	allPoolsActive := map[uint64]struct{}{
+		101: {}, 102: {}, 103: {},
+	}
+
+	// FindArbitrageCycles will always find cycles (so far there are more than one pool for a token)
+	// because the implementation now finds the best effort at a cycle
+	t.Run("Finds arbitrage with V2 override", func(t *testing.T) {
+		graph := setupArbitrageTestGraph(t, allPoolsActive)
+		originalPool, _ := graph.indexedUniswapV2.GetByID(101)
+		overriddenPool := originalPool
+		overriddenPool.Reserve1 = new(big.Int).Mul(big.NewInt(3800000), big.NewInt(1e6))
+		overriddenPool.Reserve0 = new(big.Int).Set(originalPool.Reserve0)
+
+		params := chains.CycleFindingParams{
+			TokenID:  1,
+			AmountIn: startAmount,
+			Runs:     4,
+			UniswapV2Overrides: map[uint64]uniswapv2.Pool{
+				101: overriddenPool,
+			},
+		}
+
+		cycles, costs, err := graph.FindArbitrageCycles(params)
+
+		require.NoError(t, err)
+		require.Len(t, cycles, 1, "Should find exactly one profitable cycle")
+		require.Len(t, costs, 1, "Should find exactly one profitable cost")
+
+		expectedPath := []chains.TokenPoolPath{
+			{TokenInID: 1, TokenOutID: 3, PoolID: 103},
+			{TokenInID: 3, TokenOutID: 2, PoolID: 102},
+			{TokenInID: 2, TokenOutID: 1, PoolID: 101},
+		}
+		assert.Equal(t, expectedPath, cycles[0], "The arbitrage path is incorrect")
+		profit := new(big.Int).Sub(costs[0], startAmount)
+		assert.True(t, profit.Sign() > 0, "The cycle should be profitable")
+	})
+
+	t.Run("Non-existent start token", func(t *testing.T) {
+		graph := setupArbitrageTestGraph(t, allPoolsActive)
+		params := chains.CycleFindingParams{
+			TokenID:  999,
+			AmountIn: startAmount,
+			Runs:     3,
+		}
+		_, _, err := graph.FindArbitrageCycles(params)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "token 999 not found in the graph")
+	})
+}
+
+func TestFindArbitrageCyclesMinProfit(t *testing.T) {
+	startAmount := new(big.Int).SetUint64(1e18) // 1 WETH
+	setup := func(t *testing.T) *Graph {
+		graph := setupProfitableCycleTestGraph(t)
+		graph.indexedTokenRegistry = tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+			{ID: 1, Symbol: "WETH", Decimals: 18},
+			{ID: 2, Symbol: "USDC", Decimals: 6},
+			{ID: 3, Symbol: "DAI", Decimals: 18},
+		})
+		return graph
+	}
+	params := func(threshold *chains.ProfitThreshold) chains.CycleFindingParams {
+		return chains.CycleFindingParams{TokenID: 1, AmountIn: startAmount, Runs: 4, MinProfit: threshold}
+	}
+	stables := []uint64{2, 3}
+
+	t.Run("Cycle profitable in raw terms is filtered below the USD threshold", func(t *testing.T) {
+		graph := setup(t)
+
+		rawThreshold := &chains.ProfitThreshold{Unit: chains.ProfitUnitRaw, Amount: big.NewFloat(1)}
+		cycles, amounts, err := graph.FindArbitrageCycles(params(rawThreshold))
+		require.NoError(t, err)
+		require.Len(t, cycles, 1, "the cycle clears a one-wei raw threshold")
+		require.Positive(t, new(big.Int).Sub(amounts[0], startAmount).Sign())
+
+		usdThreshold := &chains.ProfitThreshold{Unit: chains.ProfitUnitUSD, Amount: big.NewFloat(1000), StableTokenIDs: stables}
+		cycles, amounts, err = graph.FindArbitrageCycles(params(usdThreshold))
+		require.NoError(t, err)
+		assert.Empty(t, cycles)
+		assert.Empty(t, amounts)
+
+		all, _, err := graph.FindAllArbitrageCycles(params(usdThreshold), 0)
+		require.NoError(t, err)
+		assert.Empty(t, all)
+	})
+
+	t.Run("Cycle above the USD threshold is kept", func(t *testing.T) {
+		graph := setup(t)
+		threshold := &chains.ProfitThreshold{Unit: chains.ProfitUnitUSD, Amount: big.NewFloat(10), StableTokenIDs: stables}
+		cycles, _, err := graph.FindArbitrageCycles(params(threshold))
+		require.NoError(t, err)
+		assert.Len(t, cycles, 1)
+
+		all, _, err := graph.FindAllArbitrageCycles(params(threshold), 0)
+		require.NoError(t, err)
+		assert.NotEmpty(t, all)
+	})
+
+	t.Run("Threshold in bps of the input", func(t *testing.T) {
+		graph := setup(t)
+		cycles, _, err := graph.FindArbitrageCycles(params(&chains.ProfitThreshold{Unit: chains.ProfitUnitBps, Amount: big.NewFloat(10)}))
+		require.NoError(t, err)
+		assert.Len(t, cycles, 1)
+
+		cycles, _, err = graph.FindArbitrageCycles(params(&chains.ProfitThreshold{Unit: chains.ProfitUnitBps, Amount: big.NewFloat(5_000)}))
+		require.NoError(t, err)
+		assert.Empty(t, cycles)
+	})
+
+	t.Run("USD threshold requires anchor stables", func(t *testing.T) {
+		graph := setup(t)
+		_, _, err := graph.FindArbitrageCycles(params(&chains.ProfitThreshold{Unit: chains.ProfitUnitUSD, Amount: big.NewFloat(10)}))
+		assert.Error(t, err)
+	})
+}
+
+// setupArbitrageBenchmarkGraph creates a large, complex, and interconnected graph with diverse arbitrage opportunities.
+// setupProfitableCycleTestGraph mirrors setupArbitrageTestGraph but prices WETH lower in the
+// WETH/USDC pool, so the cycle WETH -> DAI -> USDC -> WETH is profitable before gas.
+func setupProfitableCycleTestGraph(t *testing.T) *Graph {
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"), // WETH
+		2: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), // USDC
+		3: common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F"), // DAI
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc"), // V2 WETH/USDC
+		102: common.HexToAddress("0xAE461cA67B15dc82787E5236E28020173Bf30ac2"), // V2 USDC/DAI
+		103: common.HexToAddress("0xa478c2975ab1ea89e8196811f51a7b7ade33eb11"), // V2 DAI/WETH
+	}
+
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18)), Reserve1: new(big.Int).Mul(big.NewInt(3800000), big.NewInt(1e6)), FeeBps: 30},
+		{ID: 102, Token0: 2, Token1: 3, Reserve0: new(big.Int).Mul(big.NewInt(4000000), big.NewInt(1e6)), Reserve1: new(big.Int).Mul(big.NewInt(4000000), big.NewInt(1e18)), FeeBps: 30},
+		{ID: 103, Token0: 3, Token1: 1, Reserve0: new(big.Int).Mul(big.NewInt(4000000), big.NewInt(1e18)), Reserve1: new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18)), FeeBps: 30},
+	}
+
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+
+	protocolResolver := chains.NewProtocolResolver(
+		map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+			uniswapV3ProtocolID: uniswapv3.Schema,
+		},
+		poolRegistry,
+	)
+	graph, err := NewGraph(
+		rawGraph,
+		nil,
+		poolRegistry,
+		v2View,
+		v3View,
+		map[uint64]struct{}{101: {}, 102: {}, 103: {}},
+		protocolResolver,
+	)
+	require.NoError(t, err)
+	return graph
+}
+
+// setupMultiCycleTestGraph extends setupProfitableCycleTestGraph with a second, cheaper
+// WETH/USDC pool so that several distinct cycles exist from WETH.
+func setupMultiCycleTestGraph(t *testing.T) *Graph {
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"), // WETH
+		2: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), // USDC
+		3: common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F"), // DAI
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc"), // V2 WETH/USDC
+		102: common.HexToAddress("0xAE461cA67B15dc82787E5236E28020173Bf30ac2"), // V2 USDC/DAI
+		103: common.HexToAddress("0xa478c2975ab1ea89e8196811f51a7b7ade33eb11"), // V2 DAI/WETH
+		104: common.HexToAddress("0x397FF1542f962076d0BFE58eA045FfA2d347ACa0"), // V2 WETH/USDC (Cheaper WETH)
+	}
+
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18)), Reserve1: new(big.Int).Mul(big.NewInt(3800000), big.NewInt(1e6)), FeeBps: 30},
+		{ID: 102, Token0: 2, Token1: 3, Reserve0: new(big.Int).Mul(big.NewInt(4000000), big.NewInt(1e6)), Reserve1: new(big.Int).Mul(big.NewInt(4000000), big.NewInt(1e18)), FeeBps: 30},
+		{ID: 103, Token0: 3, Token1: 1, Reserve0: new(big.Int).Mul(big.NewInt(4000000), big.NewInt(1e18)), Reserve1: new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18)), FeeBps: 30},
+		{ID: 104, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18)), Reserve1: new(big.Int).Mul(big.NewInt(4200000), big.NewInt(1e6)), FeeBps: 30},
+	}
+
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+
+	protocolResolver := chains.NewProtocolResolver(
+		map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+			uniswapV3ProtocolID: uniswapv3.Schema,
+		},
+		poolRegistry,
+	)
+	graph, err := NewGraph(
+		rawGraph,
+		nil,
+		poolRegistry,
+		v2View,
+		v3View,
+		map[uint64]struct{}{101: {}, 102: {}, 103: {}, 104: {}},
+		protocolResolver,
+	)
+	require.NoError(t, err)
+	return graph
+}
+
+func TestArbitrageSearchRestrictedPools(t *testing.T) {
+	graph := setupMultiCycleTestGraph(t)
+	params := chains.CycleFindingParams{
+		TokenID:  1,
+		AmountIn: new(big.Int).SetUint64(1e18), // 1 WETH
+		Runs:     3,
+	}
+
+	usesOnly := func(t *testing.T, cycles [][]chains.TokenPoolPath, allowed map[uint64]struct{}) {
+		t.Helper()
+		require.NotEmpty(t, cycles)
+		for _, cycle := range cycles {
+			for _, hop := range cycle {
+				assert.Contains(t, allowed, hop.PoolID, "cycle %v leaves the restricted set", cycle)
+			}
+		}
+	}
+
+	t.Run("ActivePools", func(t *testing.T) {
+		restricted := params
+		restricted.ActivePools = map[uint64]struct{}{101: {}, 102: {}, 103: {}}
+
+		cycles, _, err := graph.FindAllArbitrageCycles(restricted, 0)
+		require.NoError(t, err)
+		usesOnly(t, cycles, restricted.ActivePools)
+
+		best, _, err := graph.FindArbitrageCycles(restricted)
+		require.NoError(t, err)
+		usesOnly(t, best, restricted.ActivePools)
+	})
+
+	t.Run("MaxPools keeps the deepest pools", func(t *testing.T) {
+		// By sqrt(reserve0*reserve1) the pools rank 103, 102, 104, 101.
+		restricted := params
+		restricted.MaxPools = 3
+		deepest := map[uint64]struct{}{102: {}, 103: {}, 104: {}}
+
+		all, _, err := graph.FindAllArbitrageCycles(params, 0)
+		require.NoError(t, err)
+		cycles, _, err := graph.FindAllArbitrageCycles(restricted, 0)
+		require.NoError(t, err)
+		usesOnly(t, cycles, deepest)
+		assert.Less(t, len(cycles), len(all))
+
+		best, _, err := graph.FindArbitrageCycles(restricted)
+		require.NoError(t, err)
+		usesOnly(t, best, deepest)
+	})
+
+	t.Run("MaxPools applies within ActivePools", func(t *testing.T) {
+		restricted := params
+		restricted.ActivePools = map[uint64]struct{}{101: {}, 102: {}, 103: {}}
+		restricted.MaxPools = 2
+
+		// Only 102 and 103 remain, which do not connect WETH to USDC.
+		cycles, amounts, err := graph.FindAllArbitrageCycles(restricted, 0)
+		require.NoError(t, err)
+		assert.Empty(t, cycles)
+		assert.Empty(t, amounts)
+	})
+
+	t.Run("MaxPools measures overridden pools by their overrides", func(t *testing.T) {
+		// Pool 101, the shallowest, is ranked by its override, deeper than every other pool.
+		pool101, ok := graph.indexedUniswapV2.GetByID(101)
+		require.True(t, ok)
+		pool101.Reserve0 = new(big.Int).Mul(pool101.Reserve0, big.NewInt(1e6))
+		pool101.Reserve1 = new(big.Int).Mul(pool101.Reserve1, big.NewInt(1e6))
+		restricted := params
+		restricted.MaxPools = 3
+		restricted.UniswapV2Overrides = map[uint64]uniswapv2.Pool{101: pool101}
+		deepest := map[uint64]struct{}{101: {}, 102: {}, 103: {}}
+
+		cycles, _, err := graph.FindAllArbitrageCycles(restricted, 0)
+		require.NoError(t, err)
+		usesOnly(t, cycles, deepest)
+	})
+
+	t.Run("Negative MaxPools", func(t *testing.T) {
+		restricted := params
+		restricted.MaxPools = -1
+		_, _, err := graph.FindAllArbitrageCycles(restricted, 0)
+		assert.Error(t, err)
+		_, _, err = graph.FindArbitrageCycles(restricted)
+		assert.Error(t, err)
+	})
+}
+
+func TestFindAllArbitrageCycles(t *testing.T) {
+	params := chains.CycleFindingParams{
+		TokenID:  1,
+		AmountIn: new(big.Int).SetUint64(1e18), // 1 WETH
+		Runs:     3,
+	}
+
+	t.Run("Zero limit returns all cycles ordered by profit", func(t *testing.T) {
+		graph := setupMultiCycleTestGraph(t)
+		cycles, amounts, err := graph.FindAllArbitrageCycles(params, 0)
+		require.NoError(t, err)
+		require.Len(t, amounts, len(cycles))
+		require.Greater(t, len(cycles), 2, "the test graph should contain more cycles than the limits used below")
+
+		for i, cycle := range cycles {
+			assert.Equal(t, uint64(1), cycle[0].TokenInID, "cycle must start at the start token")
+			assert.Equal(t, uint64(1), cycle[len(cycle)-1].TokenOutID, "cycle must end at the start token")
+			if i > 0 {
+				assert.True(t, amounts[i-1].Cmp(amounts[i]) >= 0, "cycles must be ordered from most to least profitable")
+			}
+		}
+
+		// The best cycle buys cheap WETH in pool 104 and sells it in pool 101.
+		assert.Equal(t, []chains.TokenPoolPath{
+			{TokenInID: 1, TokenOutID: 2, PoolID: 104},
+			{TokenInID: 2, TokenOutID: 1, PoolID: 101},
+		}, cycles[0])
+	})
+
+	t.Run("Limit returns only the most profitable cycles", func(t *testing.T) {
+		graph := setupMultiCycleTestGraph(t)
+		allCycles, allAmounts, err := graph.FindAllArbitrageCycles(params, 0)
+		require.NoError(t, err)
+
+		for _, limit := range []int{1, 2} {
+			cycles, amounts, err := graph.FindAllArbitrageCycles(params, limit)
+			require.NoError(t, err)
+			require.Len(t, cycles, limit)
+			require.Len(t, amounts, limit)
+			assert.Equal(t, allCycles[:limit], cycles)
+			assert.Equal(t, allAmounts[:limit], amounts)
+		}
+	})
+
+	t.Run("Limit larger than the number of cycles", func(t *testing.T) {
+		graph := setupMultiCycleTestGraph(t)
+		allCycles, _, err := graph.FindAllArbitrageCycles(params, 0)
+		require.NoError(t, err)
+
+		cycles, _, err := graph.FindAllArbitrageCycles(params, len(allCycles)+10)
+		require.NoError(t, err)
+		assert.Equal(t, allCycles, cycles)
+	})
+
+	t.Run("Runs bounds the cycle length", func(t *testing.T) {
+		graph := setupMultiCycleTestGraph(t)
+		shortParams := params
+		shortParams.Runs = 2
+		cycles, _, err := graph.FindAllArbitrageCycles(shortParams, 0)
+		require.NoError(t, err)
+		require.NotEmpty(t, cycles)
+		for _, cycle := range cycles {
+			assert.LessOrEqual(t, len(cycle), 2)
+		}
+	})
+
+	t.Run("Negative limit", func(t *testing.T) {
+		graph := setupMultiCycleTestGraph(t)
+		_, _, err := graph.FindAllArbitrageCycles(params, -1)
+		require.Error(t, err)
+	})
+
+	t.Run("Non-existent start token", func(t *testing.T) {
+		graph := setupMultiCycleTestGraph(t)
+		badParams := params
+		badParams.TokenID = 999
+		_, _, err := graph.FindAllArbitrageCycles(badParams, 1)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "token 999 not found in the graph")
+	})
+}
+
+func TestFindAllArbitrageCyclesMaxDepth(t *testing.T) {
+	// A complete graph: every pair of tokens shares a pool, so the number of simple
+	// cycles grows factorially with their length.
+	const numTokens = 7
+	d18 := new(big.Int).SetUint64(1e18)
+	tokens := make(map[uint64]common.Address, numTokens)
+	for i := uint64(1); i <= numTokens; i++ {
+		tokens[i] = common.HexToAddress(fmt.Sprintf("0x%x", i))
+	}
+	pools := make(map[uint64]common.Address)
+	var uniswapV2Pools []uniswapv2.Pool
+	for a := uint64(1); a <= numTokens; a++ {
+		for b := a + 1; b <= numTokens; b++ {
+			poolID := 100*a + b
+			pools[poolID] = common.HexToAddress(fmt.Sprintf("0x%x", poolID))
+			uniswapV2Pools = append(uniswapV2Pools, uniswapv2.Pool{
+				ID: poolID, Token0: a, Token1: b, FeeBps: 30,
+				Reserve0: new(big.Int).Mul(big.NewInt(int64(1000+a*b)), d18),
+				Reserve1: new(big.Int).Mul(big.NewInt(int64(1000+a+b)), d18),
+			})
+		}
+	}
+	activePools := make(map[uint64]struct{}, len(pools))
+	for poolID := range pools {
+		activePools[poolID] = struct{}{}
+	}
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+	protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}, poolRegistry)
+	graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, activePools, protocolResolver)
+	require.NoError(t, err)
+
+	params := chains.CycleFindingParams{TokenID: 1, AmountIn: d18, Runs: numTokens}
+	assertValidCycles := func(t *testing.T, cycles [][]chains.TokenPoolPath, maxLen int) {
+		for _, cycle := range cycles {
+			require.GreaterOrEqual(t, len(cycle), 2)
+			require.LessOrEqual(t, len(cycle), maxLen)
+			assert.Equal(t, uint64(1), cycle[0].TokenInID)
+			assert.Equal(t, uint64(1), cycle[len(cycle)-1].TokenOutID)
+			seenTokens := map[uint64]bool{}
+			seenPools := map[uint64]bool{}
+			for i, hop := range cycle {
+				if i > 0 {
+					assert.Equal(t, cycle[i-1].TokenOutID, hop.TokenInID, "hops must chain")
+				}
+				assert.False(t, seenTokens[hop.TokenInID], "cycle must be simple")
+				assert.False(t, seenPools[hop.PoolID], "pool reused in cycle")
+				seenTokens[hop.TokenInID], seenPools[hop.PoolID] = true, true
+			}
+		}
+	}
+
+	t.Run("The depth limit cuts the enumeration short", func(t *testing.T) {
+		limited := params
+		limited.MaxDepth = 3
+		cycles, amounts, err := graph.FindAllArbitrageCycles(limited, 0)
+		require.ErrorIs(t, err, chains.ErrCycleDepthLimit)
+		require.NotEmpty(t, cycles)
+		require.Len(t, amounts, len(cycles))
+		assertValidCycles(t, cycles, 3)
+
+		// The cycles found are exactly those Runs = MaxDepth would enumerate.
+		bounded := params
+		bounded.Runs = 3
+		expected, _, err := graph.FindAllArbitrageCycles(bounded, 0)
+		require.NoError(t, err)
+		assert.Equal(t, expected, cycles)
+	})
+
+	t.Run("No error when Runs is within the limit", func(t *testing.T) {
+		cycles, _, err := graph.FindAllArbitrageCycles(params, 0)
+		require.NoError(t, err)
+		assertValidCycles(t, cycles, numTokens)
+		longest := 0
+		for _, cycle := range cycles {
+			longest = max(longest, len(cycle))
+		}
+		assert.Equal(t, numTokens, longest, "cycles through every token are enumerated")
+	})
+
+	t.Run("Zero uses the default depth", func(t *testing.T) {
+		deep := params
+		deep.Runs = chains.DefaultMaxCycleDepth + 1
+		_, _, err := graph.FindAllArbitrageCycles(deep, 1)
+		assert.NoError(t, err, "%d tokens cannot form a cycle of more than %d hops", numTokens, numTokens)
+
+		deep.MaxDepth = numTokens - 1
+		_, _, err = graph.FindAllArbitrageCycles(deep, 1)
+		assert.ErrorIs(t, err, chains.ErrCycleDepthLimit)
+	})
+
+	t.Run("Negative depth", func(t *testing.T) {
+		negative := params
+		negative.MaxDepth = -1
+		_, _, err := graph.FindAllArbitrageCycles(negative, 0)
+		assert.Error(t, err)
+	})
+}
+
+func TestCycleNetProfit(t *testing.T) {
+	startAmount := new(big.Int).SetUint64(1e18) // 1 WETH
+	gwei := big.NewInt(1e9)
+	gasEstimates := chains.GasEstimates{
+		uniswapv2.Schema: 100000,
+		uniswapv3.Schema: 150000,
+	}
+
+	wethCycle := []chains.TokenPoolPath{
+		{TokenInID: 1, TokenOutID: 3, PoolID: 103},
+		{TokenInID: 3, TokenOutID: 2, PoolID: 102},
+		{TokenInID: 2, TokenOutID: 1, PoolID: 101},
+	}
+
+	t.Run("Profitable at low gas", func(t *testing.T) {
+		graph := setupProfitableCycleTestGraph(t)
+		result, err := graph.CycleNetProfit(chains.CycleProfitParams{
+			Cycle:         wethCycle,
+			AmountIn:      startAmount,
+			GasPrice:      new(big.Int).Mul(big.NewInt(1), gwei),
+			GasEstimates:  gasEstimates,
+			NativeTokenID: 1,
+			Runs:          3,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, uint64(300000), result.GasUsed)
+		assert.Equal(t, new(big.Int).Mul(big.NewInt(300000), gwei), result.GasCost, "gas cost is not converted when the cycle token is native")
+		assert.True(t, result.GrossProfit.Sign() > 0, "cycle should be profitable before gas")
+		assert.True(t, result.NetProfit.Sign() > 0, "cycle should be profitable after gas")
+		assert.True(t, result.Profitable)
+		assert.Equal(t, new(big.Int).Sub(result.GrossProfit, result.GasCost), result.NetProfit)
+	})
+
+	t.Run("Gross profitable cycle is net negative at high gas", func(t *testing.T) {
+		graph := setupProfitableCycleTestGraph(t)
+		result, err := graph.CycleNetProfit(chains.CycleProfitParams{
+			Cycle:         wethCycle,
+			AmountIn:      startAmount,
+			GasPrice:      new(big.Int).Mul(big.NewInt(1000), gwei),
+			GasEstimates:  gasEstimates,
+			NativeTokenID: 1,
+			Runs:          3,
+		})
+		require.NoError(t, err)
+
+		assert.True(t, result.GrossProfit.Sign() > 0, "cycle should be profitable before gas")
+		assert.True(t, result.NetProfit.Sign() < 0, "cycle should be unprofitable after gas")
+		assert.False(t, result.Profitable)
+	})
+
+	t.Run("Gas is converted into a non-native cycle token", func(t *testing.T) {
+		graph := setupProfitableCycleTestGraph(t)
+		daiCycle := []chains.TokenPoolPath{
+			{TokenInID: 3, TokenOutID: 2, PoolID: 102},
+			{TokenInID: 2, TokenOutID: 1, PoolID: 101},
+			{TokenInID: 1, TokenOutID: 3, PoolID: 103},
+		}
+		result, err := graph.CycleNetProfit(chains.CycleProfitParams{
+			Cycle:         daiCycle,
+			AmountIn:      new(big.Int).Mul(big.NewInt(4000), big.NewInt(1e18)), // ~1 WETH worth of DAI
+			GasPrice:      new(big.Int).Mul(big.NewInt(1000), gwei),
+			GasEstimates:  gasEstimates,
+			NativeTokenID: 1,
+			Runs:          3,
+		})
+		require.NoError(t, err)
+
+		// 0.3 WETH of gas is roughly 1200 DAI.
+		assert.Equal(t, new(big.Int).Mul(big.NewInt(300000), new(big.Int).Mul(big.NewInt(1000), gwei)), result.GasCostNative)
+		lower := new(big.Int).Mul(big.NewInt(1100), big.NewInt(1e18))
+		upper := new(big.Int).Mul(big.NewInt(1300), big.NewInt(1e18))
+		assert.True(t, result.GasCost.Cmp(lower) > 0 && result.GasCost.Cmp(upper) < 0, "unexpected gas cost in DAI: %s", result.GasCost)
+		assert.True(t, result.GrossProfit.Sign() > 0)
+		assert.False(t, result.Profitable)
+	})
+
+	t.Run("Missing gas estimate for schema", func(t *testing.T) {
+		graph := setupProfitableCycleTestGraph(t)
+		_, err := graph.CycleNetProfit(chains.CycleProfitParams{
+			Cycle:         wethCycle,
+			AmountIn:      startAmount,
+			GasPrice:      gwei,
+			GasEstimates:  chains.GasEstimates{uniswapv3.Schema: 150000},
+			NativeTokenID: 1,
+			Runs:          3,
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no gas estimate for schema")
+	})
+
+	t.Run("Path is not a cycle", func(t *testing.T) {
+		graph := setupProfitableCycleTestGraph(t)
+		_, err := graph.CycleNetProfit(chains.CycleProfitParams{
+			Cycle:         wethCycle[:2],
+			AmountIn:      startAmount,
+			GasPrice:      gwei,
+			GasEstimates:  gasEstimates,
+			NativeTokenID: 1,
+			Runs:          3,
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "does not form a cycle")
+	})
+}
+
+func setupArbitrageBenchmarkGraph(t testing.TB, numTokens, numPools int) *Graph {
+	tokens := make(map[uint64]common.Address, numTokens)
+	for i := 0; i < numTokens; i++ {
+		tokens[uint64(i)] = common.HexToAddress(fmt.Sprintf("0x%040x", i))
+	}
+
+	pools := make(map[uint64]common.Address, numPools)
+	uniswapV2Pools := make([]uniswapv2.Pool, 0, numPools)
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	poolIDCounter := uint64(0)
+
+	// Define hub tokens
+	hubTokens := []uint64{0, 1, 2} // WETH, USDC, DAI equivalents
+	if numTokens < len(hubTokens) {
+		hubTokens = hubTokens[:numTokens]
+	}
+
+	// Big number setup
+	decimals := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+
+	// Helper to create a pool
+	createPool := func(tA, tB uint64, rA, rB *big.Int) {
+		if poolIDCounter >= uint64(numPools) {
+			return
+		}
+		poolID := poolIDCounter
+		pools[poolID] = common.HexToAddress(fmt.Sprintf("0x1%039x", poolID))
+		uniswapV2Pools = append(uniswapV2Pools, uniswapv2.Pool{ID: poolID, Token0: tA, Token1: tB, Reserve0: rA, Reserve1: rB, FeeBps: 30})
+		poolIDCounter++
+	}
+
+	// --- Step 1: Guaranteed Connectivity (Backbone) ---
+	// Connect every non-hub token to a random hub
+	for i := len(hubTokens); i < numTokens; i++ {
+		hub := hubTokens[r.Intn(len(hubTokens))]
+		reserveHub := new(big.Int).Mul(big.NewInt(1000), decimals)
+		reserveSpoke := new(big.Int).Mul(big.NewInt(int64(1000+r.Intn(5000))), decimals)
+		createPool(hub, uint64(i), reserveHub, reserveSpoke)
+	}
+
+	// --- Step 2: Hub Interconnectivity & Arbitrage Injection ---
+	// Create a richly connected core with guaranteed arbitrage loops
+	for i := 0; i < len(hubTokens); i++ {
+		for j := i + 1; j < len(hubTokens); j++ {
+			tA, tB := hubTokens[i], hubTokens[j]
+
+			// Create one "fair" pool
+			rA_fair := new(big.Int).Mul(big.NewInt(5000), decimals)
+			rB_fair := new(big.Int).Mul(big.NewInt(5000), decimals)
+			createPool(tA, tB, rA_fair, rB_fair)
+
+			// Create a second, imbalanced pool to guarantee an arbitrage opportunity
+			rA_imbalanced := new(big.Int).Mul(big.NewInt(5000), decimals)
+			imbalanceFactor := 1.0 + 0.02 + (r.Float64() * 0.05) // 2-7% imbalance
+			rB_imbalanced_float := new(big.Float).Mul(new(big.Float).SetInt(rB_fair), big.NewFloat(imbalanceFactor))
+			rB_imbalanced, _ := rB_imbalanced_float.Int(nil)
+			createPool(tA, tB, rA_imbalanced, rB_imbalanced)
+		}
+	}
+
+	// --- Step 3: Add Random Cross-Connections for Chaos ---
+	for poolIDCounter < uint64(numPools) {
+		tokenA := uint64(r.Intn(numTokens))
+		tokenB := uint64(r.Intn(numTokens))
+		if tokenA == tokenB {
+			continue
+		}
+		rA := new(big.Int).Mul(big.NewInt(int64(100+r.Intn(1000))), decimals)
+		rB := new(big.Int).Mul(big.NewInt(int64(100+r.Intn(1000))), decimals)
+		createPool(tokenA, tokenB, rA, rB)
+	}
+
+	activePools := make(map[uint64]struct{}, numPools)
+	for i := 0; i < int(poolIDCounter); i++ {
+		activePools[uint64(i)] = struct{}{}
+	}
+
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+
+	protocolIDToProtocolSchema := map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}
+
+	protocolResolver := chains.NewProtocolResolver(
+		protocolIDToProtocolSchema,
+		poolRegistry,
+	)
+
+	graph, err := NewGraph(
+		rawGraph,
+		nil,
+		poolRegistry,
+		v2View,
+		v3View,
+		activePools,
+		protocolResolver,
+	)
+	require.NoError(t, err)
+	return graph
+}
+func BenchmarkFindArbitrageCycles(b *testing.B) {
+	benchmarkCases := []struct {
+		name      string
+		numTokens int
+		numPools  int
+		runs      int // Path length to search
+	}{
+		{"100T_300P_3Hops", 100, 300, 3},
+		{"200T_600P_3Hops", 200, 600, 3},
+		{"500T_1500P_3Hops", 500, 1500, 3},
+		{"1000T_3000P_3Hops", 1000, 3000, 3},
+		{"100T_300P_4Hops", 100, 300, 4},
+		{"200T_600P_4Hops", 200, 600, 4},
+		{"500T_1500P_4Hops", 500, 1500, 4},
+		{"1000T_3000P_4Hops", 1000, 3000, 4},
+		{"5000T_10000P_3Hops", 5000, 10000, 3},
+		{"5000T_10000P_4Hops", 5000, 10000, 4},
+	}
+
+	for _, bc := range benchmarkCases {
+		b.Run(bc.name, func(b *testing.B) {
+			graph := setupArbitrageBenchmarkGraph(b, bc.numTokens, bc.numPools)
+			params := chains.CycleFindingParams{
+				TokenID:  0, // Start from our "WETH" hub token
+				AmountIn: new(big.Int).SetUint64(1e18),
+				Runs:     bc.runs,
+			}
+
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				_, _, _ = graph.FindArbitrageCycles(params)
+
+			}
+		})
+	}
+}
+
+// setupSwapPathTestGraph creates a graph specifically for testing swap paths.
+// It includes a clear optimal path (A->B->D) and several suboptimal paths.
+func setupSwapPathTestGraph(t *testing.T, activePools map[uint64]struct{}) *Graph {
+	// A, B, C, D are connected. E is isolated.
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xA"), // Token A (e.g., WETH)
+		2: common.HexToAddress("0xB"), // Token B (e.g., USDC)
+		3: common.HexToAddress("0xC"), // Token C (e.g., DAI)
+		4: common.HexToAddress("0xD"), // Token D (e.g., WBTC)
+		5: common.HexToAddress("0xE"), // Token E (Isolated)
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"), // A -> B (High Liquidity)
+		102: common.HexToAddress("0x102"), // B -> D (High Liquidity)
+		103: common.HexToAddress("0x103"), // A -> D (Direct, but Low Liquidity)
+		104: common.HexToAddress("0x104"), // A -> C (Low Liquidity)
+		105: common.HexToAddress("0x105"), // C -> D (Low Liquidity)
+	}
+
+	// Decimals for convenience
+	d18 := new(big.Int).SetUint64(1e18)
+	d6 := new(big.Int).SetUint64(1e6)
+	d8 := new(big.Int).SetUint64(1e8)
+
+	uniswapV2Pools := []uniswapv2.Pool{
+		// Optimal Path (High Liquidity)
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(10000), d18), Reserve1: new(big.Int).Mul(big.NewInt(40000000), d6), FeeBps: 30}, // A/B
+		{ID: 102, Token0: 2, Token1: 4, Reserve0: new(big.Int).Mul(big.NewInt(40000000), d6), Reserve1: new(big.Int).Mul(big.NewInt(1000), d8), FeeBps: 30},   // B/D
+
+		// Suboptimal Direct Path (Low Liquidity)
+		{ID: 103, Token0: 1, Token1: 4, Reserve0: new(big.Int).Mul(big.NewInt(10), d18), Reserve1: new(big.Int).Mul(big.NewInt(1), d8), FeeBps: 30}, // A/D
+
+		// Suboptimal Indirect Path (Low Liquidity)
+		{ID: 104, Token0: 1, Token1: 3, Reserve0: new(big.Int).Mul(big.NewInt(10), d18), Reserve1: new(big.Int).Mul(big.NewInt(40000), d18), FeeBps: 30}, // A/C
+		{ID: 105, Token0: 3, Token1: 4, Reserve0: new(big.Int).Mul(big.NewInt(40000), d18), Reserve1: new(big.Int).Mul(big.NewInt(1), d8), FeeBps: 30},   // C/D
+	}
+
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+
+	protocolIDToProtocolSchema := map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}
+
+	protocolResolver := chains.NewProtocolResolver(
+		protocolIDToProtocolSchema,
+		poolRegistry,
+	)
+
+	graph, err := NewGraph(
+		rawGraph,
+		nil,
+		poolRegistry,
+		v2View,
+		v3View,
+		activePools,
+		protocolResolver,
+	)
+	require.NoError(t, err)
+	return graph
+}
+
+func TestFindBestSwapPathEquivalences(t *testing.T) {
+	allActive := map[uint64]struct{}{101: {}, 102: {}, 103: {}, 104: {}, 105: {}}
+	d18 := new(big.Int).SetUint64(1e18)
+	// C is only reachable through thin pools; a conversion at 4,000 C per A beats them.
+	aToC := map[chains.TokenPair]*big.Rat{{From: 1, To: 3}: big.NewRat(4000, 1)}
+
+	t.Run("Converts directly at the configured ratio", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allActive)
+		path, amountOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+			TokenInID:    1,
+			TokenOutID:   3,
+			AmountIn:     d18,
+			Runs:         3,
+			Equivalences: aToC,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 3, PoolID: chains.EquivalencePoolID}}, path)
+		assert.Equal(t, new(big.Int).Mul(d18, big.NewInt(4000)), amountOut)
+	})
+
+	t.Run("Bridges a pool route into an equivalent token", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allActive)
+		amountIn := new(big.Int).SetUint64(1e7) // 0.1 D
+		poolPath, poolOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{TokenInID: 4, TokenOutID: 1, AmountIn: amountIn, Runs: 3})
+		require.NoError(t, err)
+
+		path, amountOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+			TokenInID:    4,
+			TokenOutID:   3,
+			AmountIn:     amountIn,
+			Runs:         3,
+			Equivalences: aToC,
+		})
+		require.NoError(t, err)
+		expectedPath := append(append([]chains.TokenPoolPath{}, poolPath...), chains.TokenPoolPath{TokenInID: 1, TokenOutID: 3, PoolID: chains.EquivalencePoolID})
+		assert.Equal(t, expectedPath, path)
+		assert.Equal(t, new(big.Int).Mul(poolOut, big.NewInt(4000)), amountOut)
+	})
+
+	t.Run("Reverse direction uses the inverse ratio", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allActive)
+		tenA := new(big.Int).Mul(d18, big.NewInt(10))
+		_, poolOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{TokenInID: 1, TokenOutID: 4, AmountIn: tenA, Runs: 3})
+		require.NoError(t, err)
+
+		// 40,000 C plus a remainder that rounds down to exactly 10 A.
+		amountIn := new(big.Int).Mul(tenA, big.NewInt(4000))
+		amountIn.Add(amountIn, big.NewInt(3999))
+		path, amountOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+			TokenInID:    3,
+			TokenOutID:   4,
+			AmountIn:     amountIn,
+			Runs:         3,
+			Equivalences: aToC,
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, path)
+		assert.Equal(t, chains.TokenPoolPath{TokenInID: 3, TokenOutID: 1, PoolID: chains.EquivalencePoolID}, path[0])
+		assert.Equal(t, poolOut, amountOut)
+	})
+
+	t.Run("A configured reverse ratio is used as is", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allActive)
+		path, amountOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+			TokenInID:  3,
+			TokenOutID: 1,
+			AmountIn:   new(big.Int).Mul(d18, big.NewInt(4000)),
+			Runs:       3,
+			Equivalences: map[chains.TokenPair]*big.Rat{
+				{From: 1, To: 3}: big.NewRat(4000, 1),
+				{From: 3, To: 1}: big.NewRat(1, 5000),
+			},
+		})
+		require.NoError(t, err)
+		// The inverse of A->C would pay exactly 1 A; the configured 0.8 A loses to pool 104.
+		assert.Equal(t, []chains.TokenPoolPath{{TokenInID: 3, TokenOutID: 1, PoolID: 104}}, path)
+		assert.Equal(t, -1, amountOut.Cmp(d18))
+	})
+
+	t.Run("Invalid equivalences", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allActive)
+		base := chains.SwapFindingParams{TokenInID: 1, TokenOutID: 4, AmountIn: d18, Runs: 3}
+		for name, equivalences := range map[string]map[chains.TokenPair]*big.Rat{
+			"nil ratio":      {{From: 1, To: 3}: nil},
+			"zero ratio":     {{From: 1, To: 3}: new(big.Rat)},
+			"negative ratio": {{From: 1, To: 3}: big.NewRat(-1, 1)},
+			"same token":     {{From: 1, To: 1}: big.NewRat(1, 1)},
+			"unknown token":  {{From: 1, To: 999}: big.NewRat(1, 1)},
+		} {
+			params := base
+			params.Equivalences = equivalences
+			_, _, err := graph.FindBestSwapPath(params)
+			assert.Error(t, err, name)
+		}
+
+		params := base
+		params.Equivalences = aToC
+		params.FloatSearch = true
+		_, _, err := graph.FindBestSwapPath(params)
+		assert.Error(t, err)
+	})
+}
+
+func TestFindBestSwapPathFloatSearch(t *testing.T) {
+	t.Run("Matches the exact search on a small graph", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, map[uint64]struct{}{
+			101: {}, 102: {}, 103: {}, 104: {}, 105: {},
+		})
+		for _, amount := range []uint64{1e15, 1e18, 1e19} {
+			params := chains.SwapFindingParams{
+				TokenInID:  1,
+				TokenOutID: 4,
+				AmountIn:   new(big.Int).SetUint64(amount),
+				Runs:       3,
+			}
+			exactPath, exactOut, err := graph.FindBestSwapPath(params)
+			require.NoError(t, err)
+
+			params.FloatSearch = true
+			floatPath, floatOut, err := graph.FindBestSwapPath(params)
+			require.NoError(t, err)
+
+			assert.Equal(t, exactPath, floatPath, "amount %d", amount)
+			assert.Equal(t, 0, exactOut.Cmp(floatOut), "amount %d: exact %s, float %s", amount, exactOut, floatOut)
+		}
+	})
+
+	t.Run("Matches the exact search on a larger V2 graph", func(t *testing.T) {
+		graph := setupUniswapV2BenchmarkGraph(t, 100, 400)
+		amountIn := new(big.Int).SetUint64(1e18)
+		for tokenOut := uint64(3); tokenOut < 100; tokenOut += 7 {
+			params := chains.SwapFindingParams{
+				TokenInID:  0,
+				TokenOutID: tokenOut,
+				AmountIn:   amountIn,
+				Runs:       3,
+			}
+			exactPath, exactOut, exactErr := graph.FindBestSwapPath(params)
+
+			params.FloatSearch = true
+			floatPath, floatOut, floatErr := graph.FindBestSwapPath(params)
+
+			if exactErr != nil {
+				assert.ErrorIs(t, floatErr, exactErr, "token %d", tokenOut)
+				continue
+			}
+			require.NoError(t, floatErr, "token %d", tokenOut)
+			assert.Equal(t, exactPath, floatPath, "token %d", tokenOut)
+			assert.Equal(t, 0, exactOut.Cmp(floatOut), "token %d: exact %s, float %s", tokenOut, exactOut, floatOut)
+		}
+	})
+
+	t.Run("Matches the exact search with a V3 pool for small swaps", func(t *testing.T) {
+		// Small enough that the V3 swap stays within the current tick, so the float quote
+		// from virtual reserves ranks the V2 and V3 pools the same way the exact math does.
+		graph, _, _, _ := setupGetExchangeRatesGraph(t, map[uint64]struct{}{101: {}, 102: {}, 104: {}})
+		cases := []struct {
+			tokenIn, tokenOut uint64
+			amountIn          *big.Int
+		}{
+			{1, 2, new(big.Int).SetUint64(1e18)},
+			{2, 1, big.NewInt(1000e6)},
+			{2, 4, big.NewInt(1000e6)},
+		}
+		for _, tc := range cases {
+			params := chains.SwapFindingParams{
+				TokenInID:  tc.tokenIn,
+				TokenOutID: tc.tokenOut,
+				AmountIn:   tc.amountIn,
+				Runs:       3,
+			}
+			exactPath, exactOut, err := graph.FindBestSwapPath(params)
+			require.NoError(t, err)
+
+			params.FloatSearch = true
+			floatPath, floatOut, err := graph.FindBestSwapPath(params)
+			require.NoError(t, err)
+
+			assert.Equal(t, exactPath, floatPath, "%d -> %d", tc.tokenIn, tc.tokenOut)
+			assert.Equal(t, 0, exactOut.Cmp(floatOut), "%d -> %d: exact %s, float %s", tc.tokenIn, tc.tokenOut, exactOut, floatOut)
+		}
+	})
+
+	t.Run("Rejects a PoolSelector", func(t *testing.T) {
+		graph, _, _, _, _ := setupSimpleTestGraph(t, map[uint64]struct{}{101: {}})
+		_, _, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+			TokenInID:    1,
+			TokenOutID:   2,
+			AmountIn:     big.NewInt(1e6),
+			Runs:         3,
+			FloatSearch:  true,
+			PoolSelector: chains.LowestFeeSelector{},
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestFindBestSwapPathMaxPoolStaleBlocks(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	units := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), d18) }
+	block := func(n uint64) *uint64 { return &n }
+	setup := func(t *testing.T, deepPoolUpdated *uint64) *Graph {
+		tokens := map[uint64]common.Address{1: common.HexToAddress("0xA"), 2: common.HexToAddress("0xB")}
+		pools := map[uint64]common.Address{101: common.HexToAddress("0x101"), 102: common.HexToAddress("0x102")}
+		uniswapV2Pools := []uniswapv2.Pool{
+			// The deeper pool gives the better quote, but may not have been updated recently.
+			{ID: 101, Token0: 1, Token1: 2, Reserve0: units(1_000), Reserve1: units(1_000), FeeBps: 30, LastUpdatedBlock: deepPoolUpdated},
+			{ID: 102, Token0: 1, Token1: 2, Reserve0: units(100), Reserve1: units(100), FeeBps: 30, LastUpdatedBlock: block(100)},
+		}
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+		protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+			uniswapV3ProtocolID: uniswapv3.Schema,
+		}, poolRegistry)
+		graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{101: {}, 102: {}}, protocolResolver)
+		require.NoError(t, err)
+		return graph
+	}
+	params := chains.SwapFindingParams{
+		TokenInID:     1,
+		TokenOutID:    2,
+		AmountIn:      units(10),
+		Runs:          3,
+		SnapshotBlock: 100,
+	}
+	poolUsed := func(t *testing.T, graph *Graph, params chains.SwapFindingParams) uint64 {
+		path, _, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		require.Len(t, path, 1)
+		return path[0].PoolID
+	}
+
+	t.Run("Stale pool is excluded", func(t *testing.T) {
+		graph := setup(t, block(90))
+		assert.Equal(t, uint64(101), poolUsed(t, graph, params), "without a limit the deeper pool wins")
+
+		params := params
+		params.MaxPoolStaleBlocks = 5
+		assert.Equal(t, uint64(102), poolUsed(t, graph, params))
+
+		params.MaxPoolStaleBlocks = 10 // exactly ten blocks behind is still fresh enough
+		assert.Equal(t, uint64(101), poolUsed(t, graph, params))
+	})
+
+	t.Run("Pool without a last-update block is kept", func(t *testing.T) {
+		graph := setup(t, nil)
+		params := params
+		params.MaxPoolStaleBlocks = 1
+		assert.Equal(t, uint64(101), poolUsed(t, graph, params))
+	})
+
+	t.Run("Override carries its own last-update block", func(t *testing.T) {
+		graph := setup(t, block(90))
+		refreshed, _ := graph.indexedUniswapV2.GetByID(101)
+		refreshed.LastUpdatedBlock = block(100)
+		params := params
+		params.MaxPoolStaleBlocks = 5
+		params.UniswapV2Overrides = map[uint64]uniswapv2.Pool{101: refreshed}
+		assert.Equal(t, uint64(101), poolUsed(t, graph, params))
+	})
+
+	t.Run("Every pool stale", func(t *testing.T) {
+		graph := setup(t, block(90))
+		params := params
+		params.SnapshotBlock = 200
+		params.MaxPoolStaleBlocks = 5
+		_, _, err := graph.FindBestSwapPath(params)
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+
+	t.Run("Snapshot block is required", func(t *testing.T) {
+		graph := setup(t, block(90))
+		params := params
+		params.SnapshotBlock = 0
+		params.MaxPoolStaleBlocks = 5
+		_, _, err := graph.FindBestSwapPath(params)
+		assert.Error(t, err)
+	})
+}
+
+func TestFindBestSwapPath(t *testing.T) {
+	startAmount := new(big.Int).SetUint64(1e18) // Represents 1 token A
+
+	// Define the set of all pools to be used as the default "active" set.
+	allPoolsActive := map[uint64]struct{}{
+		101: {}, 102: {}, 103: {}, 104: {}, 105: {},
+	}
+
+	t.Run("Finds best multi-hop path over direct path", func(t *testing.T) {
+		// This test ensures the algorithm correctly prioritizes the higher liquidity
+		// multi-hop path (A->B->D) over the direct but low-liquidity path (A->D).
+		graph := setupSwapPathTestGraph(t, allPoolsActive)
+		params := chains.SwapFindingParams{
+			TokenInID:  1,
+			TokenOutID: 4,
+			AmountIn:   startAmount,
+			Runs:       3,
+		}
+
+		path, amountOut, err := graph.FindBestSwapPath(params)
+
+		require.NoError(t, err)
+		require.NotNil(t, path)
+		require.NotNil(t, amountOut)
+		require.Len(t, path, 2)
+
+		expectedPath := []chains.TokenPoolPath{
+			{TokenInID: 1, TokenOutID: 2, PoolID: 101},
+			{TokenInID: 2, TokenOutID: 4, PoolID: 102},
+		}
+
+		assert.Equal(t, expectedPath, path)
+		assert.True(t, amountOut.Sign() > 0, "Amount out should be positive")
+	})
+
+	t.Run("Finds path with V2 override", func(t *testing.T) {
+		// We override the best path (A->B pool 101) to have terrible liquidity.
+		// The algorithm should now choose the direct A->D path (pool 103) as the best option.
+		graph := setupSwapPathTestGraph(t, allPoolsActive)
+		originalPool, _ := graph.indexedUniswapV2.GetByID(101)
+		overriddenPool := originalPool // copy
+		overriddenPool.Reserve0 = big.NewInt(1)
+		overriddenPool.Reserve1 = big.NewInt(1)
+
+		params := chains.SwapFindingParams{
+			TokenInID:  1,
+			TokenOutID: 4,
+			AmountIn:   startAmount,
+			Runs:       3,
+			UniswapV2Overrides: map[uint64]uniswapv2.Pool{
+				101: overriddenPool,
+			},
+		}
+
+		path, amountOut, err := graph.FindBestSwapPath(params)
+
+		require.NoError(t, err)
+		require.NotNil(t, path)
+		require.NotNil(t, amountOut)
+		require.Len(t, path, 1)
+
+		expectedPath := []chains.TokenPoolPath{
+			{TokenInID: 1, TokenOutID: 4, PoolID: 103},
+		}
+		assert.Equal(t, expectedPath, path)
+	})
+
+	t.Run("Finds best path using only active pools", func(t *testing.T) {
+		// Define an active set that EXCLUDES pool 101, which is part of the best multi-hop path.
+		activePools := map[uint64]struct{}{
+			102: {}, // B -> D (High Liquidity)
+			103: {}, // A -> D (Direct, Low Liquidity)
+			104: {}, // A -> C (Low Liquidity)
+			105: {}, // C -> D (Low Liquidity)
+		}
+		// Create a new graph with this specific active pool set.
+		graphWithInactivePool := setupSwapPathTestGraph(t, activePools)
+
+		params := chains.SwapFindingParams{
+			TokenInID:  1,
+			TokenOutID: 4,
+			AmountIn:   startAmount,
+			Runs:       3,
+		}
+
+		path, amountOut, err := graphWithInactivePool.FindBestSwapPath(params)
+
+		require.NoError(t, err)
+		require.NotNil(t, path)
+		require.NotNil(t, amountOut)
+
+		// The algorithm should now choose the direct, suboptimal path (A->D)
+		// because the better A->B->D path is broken by the inactive pool 101.
+		require.Len(t, path, 1)
+
+		expectedPath := []chains.TokenPoolPath{
+			{TokenInID: 1, TokenOutID: 4, PoolID: 103},
+		}
+		assert.Equal(t, expectedPath, path)
+	})
+
+	t.Run("Routes through inactive pools when requested", func(t *testing.T) {
+		// Pool 101 (A -> B) is outside the active set, as in the previous case.
+		graph := setupSwapPathTestGraph(t, map[uint64]struct{}{102: {}, 103: {}, 104: {}, 105: {}})
+		params := chains.SwapFindingParams{
+			TokenInID:  1,
+			TokenOutID: 4,
+			AmountIn:   startAmount,
+			Runs:       3,
+		}
+
+		activePath, activeOut, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		assert.Equal(t, []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 4, PoolID: 103}}, activePath)
+
+		params.IncludeInactivePools = true
+		allPath, allOut, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		expectedPath := []chains.TokenPoolPath{
+			{TokenInID: 1, TokenOutID: 2, PoolID: 101},
+			{TokenInID: 2, TokenOutID: 4, PoolID: 102},
+		}
+		assert.Equal(t, expectedPath, allPath)
+		assert.Positive(t, allOut.Cmp(activeOut), "the wider search should find the better route")
+
+		params.FloatSearch = true
+		floatPath, floatOut, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		assert.Equal(t, expectedPath, floatPath)
+		assert.Equal(t, 0, allOut.Cmp(floatOut))
+	})
+
+	t.Run("No path exists to isolated token", func(t *testing.T) {
+		// Token 5 exists in the graph but has no connecting pools.
+		graph := setupSwapPathTestGraph(t, allPoolsActive)
+		params := chains.SwapFindingParams{
+			TokenInID:  1,
+			TokenOutID: 5,
+			AmountIn:   startAmount,
+			Runs:       3,
+		}
+
+		path, amountOut, err := graph.FindBestSwapPath(params)
+
+		require.Error(t, err)
+		assert.Nil(t, path, "Path should be nil when no route exists")
+		assert.Nil(t, amountOut, "Amount out should be nil when no route exists")
+	})
+
+	t.Run("Start token does not exist", func(t *testing.T) {
+		// Token 999 is not in the graph's token registry.
+		graph := setupSwapPathTestGraph(t, allPoolsActive)
+		params := chains.SwapFindingParams{
+			TokenInID:  999,
+			TokenOutID: 4,
+			AmountIn:   startAmount,
+			Runs:       3,
+		}
+		_, _, err := graph.FindBestSwapPath(params)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "start token 999 not found in the graph")
+	})
+
+	t.Run("End token does not exist", func(t *testing.T) {
+		// Token 999 is not in the graph's token registry.
+		graph := setupSwapPathTestGraph(t, allPoolsActive)
+		params := chains.SwapFindingParams{
+			TokenInID:  1,
+			TokenOutID: 999,
+			AmountIn:   startAmount,
+			Runs:       3,
+		}
+		_, _, err := graph.FindBestSwapPath(params)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "end token 999 not found in the graph")
+	})
+}
+
+func TestFindBestSwapPathExactOut(t *testing.T) {
+	allPoolsActive := map[uint64]struct{}{
+		101: {}, 102: {}, 103: {}, 104: {}, 105: {},
+	}
+	amountOut := big.NewInt(10_000_000) // 0.1 token D
+
+	t.Run("Finds the cheapest multi-hop path", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allPoolsActive)
+
+		path, amountIn, err := graph.FindBestSwapPathExactOut(1, 4, amountOut, 3)
+		require.NoError(t, err)
+		assert.Equal(t, []chains.TokenPoolPath{
+			{TokenInID: 1, TokenOutID: 2, PoolID: 101},
+			{TokenInID: 2, TokenOutID: 4, PoolID: 102},
+		}, path)
+
+		// The input is the exact-output quote of every hop, from the last one back.
+		pool102, _ := graph.indexedUniswapV2.GetByID(102)
+		pool101, _ := graph.indexedUniswapV2.GetByID(101)
+		midAmount, err := uniswapv2calculator.GetAmountIn(amountOut, 2, 4, pool102)
+		require.NoError(t, err)
+		expected, err := uniswapv2calculator.GetAmountIn(midAmount, 1, 2, pool101)
+		require.NoError(t, err)
+		assert.Equal(t, expected, amountIn)
+
+		// Spending that input along the route delivers at least amountOut.
+		_, bought, err := graph.FindBestSwapPath(chains.SwapFindingParams{TokenInID: 1, TokenOutID: 4, AmountIn: amountIn, Runs: 3})
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, bought.Cmp(amountOut), 0)
+	})
+
+	t.Run("Respects maxHops", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allPoolsActive)
+
+		path, _, err := graph.FindBestSwapPathExactOut(1, 4, amountOut, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 4, PoolID: 103}}, path)
+	})
+
+	t.Run("Only uses active pools", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, map[uint64]struct{}{103: {}})
+
+		path, _, err := graph.FindBestSwapPathExactOut(1, 4, amountOut, 3)
+		require.NoError(t, err)
+		assert.Equal(t, []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 4, PoolID: 103}}, path)
+	})
+
+	t.Run("Output beyond the reserves", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, map[uint64]struct{}{103: {}})
+
+		_, _, err := graph.FindBestSwapPathExactOut(1, 4, big.NewInt(1e8), 3)
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+
+	t.Run("Uniswap V3 pool", func(t *testing.T) {
+		liquidity := new(big.Int).Exp(big.NewInt(10), big.NewInt(21), nil)
+		v3Pools := []uniswapv3.Pool{{
+			PoolViewMinimal: uniswapv3.PoolViewMinimal{
+				ID: 201, Token0: 1, Token1: 2, Fee: 500, TickSpacing: 60,
+				Liquidity: liquidity, SqrtPriceX96: new(big.Int).Set(uniswapv3calculator.Q96),
+			},
+			Ticks: []uniswapv3.TickInfo{
+				{Index: -600, LiquidityGross: liquidity, LiquidityNet: liquidity},
+				{Index: 600, LiquidityGross: liquidity, LiquidityNet: new(big.Int).Neg(liquidity)},
+			},
+		}}
+		tokens := map[uint64]common.Address{1: common.HexToAddress("0x1"), 2: common.HexToAddress("0x2")}
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, map[uint64]common.Address{201: common.HexToAddress("0x201")}, nil, v3Pools)
+		resolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{uniswapV3ProtocolID: uniswapv3.Schema}, poolRegistry)
+		graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{201: {}}, resolver)
+		require.NoError(t, err)
+
+		want := big.NewInt(1_000_000_000_000_000)
+		path, amountIn, err := graph.FindBestSwapPathExactOut(1, 2, want, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 2, PoolID: 201}}, path)
+		expected, err := uniswapv3calculator.GetAmountIn(new(big.Int).Neg(want), nil, 1, v3Pools[0])
+		require.NoError(t, err)
+		assert.Equal(t, expected, amountIn)
+
+		// More than the initialized ticks hold cannot be filled.
+		_, _, err = graph.FindBestSwapPathExactOut(1, 2, new(big.Int).Mul(liquidity, big.NewInt(1000)), 2)
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+
+	t.Run("Invalid input", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allPoolsActive)
+
+		_, _, err := graph.FindBestSwapPathExactOut(1, 4, big.NewInt(0), 3)
+		assert.Error(t, err)
+		_, _, err = graph.FindBestSwapPathExactOut(1, 4, amountOut, 0)
+		assert.Error(t, err)
+		_, _, err = graph.FindBestSwapPathExactOut(1, 999, amountOut, 3)
+		assert.Error(t, err)
+		_, _, err = graph.FindBestSwapPathExactOut(1, 1, amountOut, 3)
+		assert.Error(t, err)
+	})
+}
+
+func TestGraphWithoutTokenRegistry(t *testing.T) {
+	// Same topology as setupSwapPathTestGraph, but built through the Grapher with no token registry.
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xA"),
+		2: common.HexToAddress("0xB"),
+		4: common.HexToAddress("0xD"),
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"), // A -> B (High Liquidity)
+		102: common.HexToAddress("0x102"), // B -> D (High Liquidity)
+		103: common.HexToAddress("0x103"), // A -> D (Direct, but Low Liquidity)
+	}
+
+	d18 := new(big.Int).SetUint64(1e18)
+	d6 := new(big.Int).SetUint64(1e6)
+	d8 := new(big.Int).SetUint64(1e8)
+
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(10000), d18), Reserve1: new(big.Int).Mul(big.NewInt(40000000), d6), FeeBps: 30},
+		{ID: 102, Token0: 2, Token1: 4, Reserve0: new(big.Int).Mul(big.NewInt(40000000), d6), Reserve1: new(big.Int).Mul(big.NewInt(1000), d8), FeeBps: 30},
+		{ID: 103, Token0: 1, Token1: 4, Reserve0: new(big.Int).Mul(big.NewInt(10), d18), Reserve1: new(big.Int).Mul(big.NewInt(1), d8), FeeBps: 30},
+	}
+
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+	protocolResolver := chains.NewProtocolResolver(
+		map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+			uniswapV3ProtocolID: uniswapv3.Schema,
+		},
+		poolRegistry,
+	)
+
+	grapher, err := NewGrapher()
+	require.NoError(t, err)
+
+	graph, err := grapher.Graph(rawGraph, nil, poolRegistry, v2View, v3View, nil, protocolResolver)
+	require.NoError(t, err)
+
+	t.Run("Metadata is reported as unavailable", func(t *testing.T) {
+		assert.False(t, graph.HasTokenMetadata())
+		assert.Equal(t, "ID:1", graph.TokenLabel(1))
+		_, _, ok := graph.(*Graph).PoolTokenDecimals(101)
+		assert.False(t, ok)
+	})
+
+	t.Run("Routing by ID still works", func(t *testing.T) {
+		path, amountOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+			TokenInID:  1,
+			TokenOutID: 4,
+			AmountIn:   new(big.Int).SetUint64(1e18),
+			Runs:       3,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, amountOut)
+		assert.Equal(t, []chains.TokenPoolPath{
+			{TokenInID: 1, TokenOutID: 2, PoolID: 101},
+			{TokenInID: 2, TokenOutID: 4, PoolID: 102},
+		}, path)
+	})
+
+	t.Run("Labels use symbols when metadata is present", func(t *testing.T) {
+		withMetadata, err := grapher.Graph(
+			rawGraph,
+			tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{{ID: 1, Symbol: "WETH", Decimals: 18}}),
+			poolRegistry,
+			v2View,
+			v3View,
+			nil,
+			protocolResolver,
+		)
+		require.NoError(t, err)
+		assert.True(t, withMetadata.HasTokenMetadata())
+		assert.Equal(t, "WETH", withMetadata.TokenLabel(1))
+		assert.Equal(t, "ID:2", withMetadata.TokenLabel(2), "tokens missing from the registry fall back to their ID")
+	})
+}
+
+func TestFindBestSwapPathZeroOutput(t *testing.T) {
+	allPoolsActive := map[uint64]struct{}{
+		101: {}, 102: {}, 103: {}, 104: {}, 105: {},
+	}
+	// 1 wei of token A (18 decimals) is worth far less than 1 unit of token D (8 decimals),
+	// so every route from A to D rounds down to zero.
+	tinyAmount := big.NewInt(1)
+
+	t.Run("Zero-output route is no route by default", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allPoolsActive)
+		path, amountOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+			TokenInID:  1,
+			TokenOutID: 4,
+			AmountIn:   tinyAmount,
+			Runs:       3,
+		})
+		require.ErrorIs(t, err, chains.ErrNoRoute)
+		assert.Nil(t, path)
+		assert.Nil(t, amountOut)
+	})
+
+	t.Run("Zero-output route is returned when allowed", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allPoolsActive)
+		path, amountOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+			TokenInID:       1,
+			TokenOutID:      4,
+			AmountIn:        tinyAmount,
+			Runs:            3,
+			AllowZeroOutput: true,
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, path)
+		assert.Equal(t, uint64(1), path[0].TokenInID)
+		assert.Equal(t, uint64(4), path[len(path)-1].TokenOutID)
+		require.NotNil(t, amountOut)
+		assert.Equal(t, 0, amountOut.Sign())
+	})
+
+	t.Run("Zero-output route is returned when allowed with a pool selector", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allPoolsActive)
+		path, amountOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+			TokenInID:       1,
+			TokenOutID:      4,
+			AmountIn:        tinyAmount,
+			Runs:            3,
+			AllowZeroOutput: true,
+			PoolSelector:    chains.LowestFeeSelector{},
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, path)
+		assert.Equal(t, 0, amountOut.Sign())
+	})
+
+	t.Run("Positive routes are unaffected by the option", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allPoolsActive)
+		params := chains.SwapFindingParams{
+			TokenInID:  1,
+			TokenOutID: 4,
+			AmountIn:   new(big.Int).SetUint64(1e18),
+			Runs:       3,
+		}
+		expectedPath, expectedAmountOut, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+
+		params.AllowZeroOutput = true
+		path, amountOut, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		assert.Equal(t, expectedPath, path)
+		assert.Equal(t, expectedAmountOut, amountOut)
+	})
+}
+
+// setupPoolSelectorTestGraph creates a graph where a single edge (A <-> B) is served by
+// two pools with different trade-offs: a deep pool with a high fee and a shallow pool
+// with a low fee.
+func setupPoolSelectorTestGraph(t *testing.T) *Graph {
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xA"), // Token A (e.g., WETH)
+		2: common.HexToAddress("0xB"), // Token B (e.g., USDC)
+	}
+	pools := map[uint64]common.Address{
+		201: common.HexToAddress("0x201"), // A/B (Deep, 0.3% fee)
+		202: common.HexToAddress("0x202"), // A/B (Shallow, 0.05% fee)
+	}
+
+	d18 := new(big.Int).SetUint64(1e18)
+	d6 := new(big.Int).SetUint64(1e6)
+
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 201, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(1000), d18), Reserve1: new(big.Int).Mul(big.NewInt(2000000), d6), FeeBps: 30},
+		{ID: 202, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(10), d18), Reserve1: new(big.Int).Mul(big.NewInt(21000), d6), FeeBps: 5},
+	}
+
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+
+	protocolResolver := chains.NewProtocolResolver(
+		map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+			uniswapV3ProtocolID: uniswapv3.Schema,
+		},
+		poolRegistry,
+	)
+
+	graph, err := NewGraph(
+		rawGraph,
+		nil,
+		poolRegistry,
+		v2View,
+		v3View,
+		map[uint64]struct{}{201: {}, 202: {}},
+		protocolResolver,
+	)
+	require.NoError(t, err)
+	return graph
+}
+
+func TestFindBestSwapPathPoolSelector(t *testing.T) {
+	startAmount := new(big.Int).SetUint64(1e18) // Represents 1 token A
+
+	findPath := func(t *testing.T, selector chains.PoolSelector) ([]chains.TokenPoolPath, *big.Int) {
+		graph := setupPoolSelectorTestGraph(t)
+		path, amountOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+			TokenInID:    1,
+			TokenOutID:   2,
+			AmountIn:     startAmount,
+			Runs:         2,
+			PoolSelector: selector,
+		})
+		require.NoError(t, err)
+		require.Len(t, path, 1)
+		require.NotNil(t, amountOut)
+		return path, amountOut
+	}
+
+	t.Run("Default selector picks max amount out", func(t *testing.T) {
+		path, amountOut := findPath(t, nil)
+		assert.Equal(t, uint64(201), path[0].PoolID)
+
+		explicitPath, explicitAmountOut := findPath(t, chains.MaxAmountOutSelector{})
+		assert.Equal(t, path, explicitPath)
+		assert.Equal(t, amountOut, explicitAmountOut)
+	})
+
+	t.Run("Different selectors choose different pools on the same graph", func(t *testing.T) {
+		deepPath, deepAmountOut := findPath(t, chains.DeepestPoolSelector{})
+		cheapPath, cheapAmountOut := findPath(t, chains.LowestFeeSelector{})
+
+		assert.Equal(t, uint64(201), deepPath[0].PoolID, "deepest pool should be chosen")
+		assert.Equal(t, uint64(202), cheapPath[0].PoolID, "lowest fee pool should be chosen")
+
+		// The reported amount out reflects the pool that was actually chosen.
+		assert.Equal(t, 1, deepAmountOut.Cmp(cheapAmountOut))
+	})
+
+	t.Run("Custom selector can skip an edge", func(t *testing.T) {
+		graph := setupPoolSelectorTestGraph(t)
+		skipAll := chains.PoolSelectorFunc(func(_, _ uint64, _ []chains.PoolCandidate) int {
+			return -1
+		})
+		path, amountOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+			TokenInID:    1,
+			TokenOutID:   2,
+			AmountIn:     startAmount,
+			Runs:         2,
+			PoolSelector: skipAll,
+		})
+		require.ErrorIs(t, err, chains.ErrNoRoute)
+		assert.Nil(t, path)
+		assert.Nil(t, amountOut)
+	})
+}
+
+func TestFindBestSwapPathEdgeWeight(t *testing.T) {
+	allActive := map[uint64]struct{}{101: {}, 102: {}, 103: {}, 104: {}, 105: {}}
+	d18 := new(big.Int).SetUint64(1e18)
+	weights := func(w map[uint64]float64) func(uint64) float64 {
+		return func(poolID uint64) float64 {
+			if weight, ok := w[poolID]; ok {
+				return weight
+			}
+			return 1
+		}
+	}
+
+	t.Run("Unit weights match the unweighted search", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allActive)
+		params := chains.SwapFindingParams{TokenInID: 1, TokenOutID: 4, AmountIn: d18, Runs: 3}
+		path, amountOut, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+
+		params.EdgeWeight = weights(nil)
+		weightedPath, weightedAmountOut, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		assert.Equal(t, path, weightedPath)
+		assert.Equal(t, amountOut, weightedAmountOut)
+	})
+
+	t.Run("Weighting a pool diverts the route to it", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allActive)
+		params := chains.SwapFindingParams{TokenInID: 1, TokenOutID: 4, AmountIn: d18, Runs: 3}
+		path, bestOut, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		require.Equal(t, []uint64{101, 102}, []uint64{path[0].PoolID, path[1].PoolID})
+
+		params.EdgeWeight = weights(map[uint64]float64{103: 2})
+		path, amountOut, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		assert.Equal(t, []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 4, PoolID: 103}}, path)
+
+		// The returned amount is the real, unweighted quote of the preferred pool.
+		directOut, err := graph.quoteRoute(path, d18)
+		require.NoError(t, err)
+		assert.Equal(t, directOut, amountOut)
+		assert.Equal(t, -1, amountOut.Cmp(bestOut))
+	})
+
+	t.Run("Weighting a pool wins its edge", func(t *testing.T) {
+		graph := setupPoolSelectorTestGraph(t)
+		params := chains.SwapFindingParams{TokenInID: 1, TokenOutID: 2, AmountIn: d18, Runs: 2}
+		path, _, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		require.Equal(t, uint64(201), path[0].PoolID)
+
+		params.EdgeWeight = weights(map[uint64]float64{202: 1.5})
+		path, _, err = graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(202), path[0].PoolID)
+	})
+
+	t.Run("A non-positive weight excludes the pool", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allActive)
+		for _, weight := range []float64{0, -1, math.NaN(), math.Inf(1)} {
+			path, _, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+				TokenInID:  1,
+				TokenOutID: 4,
+				AmountIn:   d18,
+				Runs:       3,
+				EdgeWeight: weights(map[uint64]float64{101: weight}),
+			})
+			require.NoError(t, err)
+			for _, hop := range path {
+				assert.NotEqual(t, uint64(101), hop.PoolID, "weight %v", weight)
+			}
+		}
+	})
+
+	t.Run("Not supported with FloatSearch", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allActive)
+		_, _, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+			TokenInID:   1,
+			TokenOutID:  4,
+			AmountIn:    d18,
+			Runs:        3,
+			FloatSearch: true,
+			EdgeWeight:  weights(nil),
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestGrapherTokenFilters(t *testing.T) {
+	weth := common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+	usdc := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	dai := common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F")
+	tokens := map[uint64]common.Address{1: weth, 2: usdc, 3: dai}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"), // WETH/USDC
+		102: common.HexToAddress("0x102"), // USDC/DAI
+		103: common.HexToAddress("0x103"), // WETH/DAI
+	}
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: big.NewInt(1_000_000), Reserve1: big.NewInt(2_000_000), FeeBps: 30},
+		{ID: 102, Token0: 2, Token1: 3, Reserve0: big.NewInt(1_000_000), Reserve1: big.NewInt(1_000_000), FeeBps: 30},
+		{ID: 103, Token0: 1, Token1: 3, Reserve0: big.NewInt(1_000_000), Reserve1: big.NewInt(2_000_000), FeeBps: 30},
+	}
+	tokenRegistry := tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+		{ID: 1, Address: addr.FromCommon(weth), Symbol: "WETH", Decimals: 18},
+		{ID: 2, Address: addr.FromCommon(usdc), Symbol: "USDC", Decimals: 6},
+		{ID: 3, Address: addr.FromCommon(dai), Symbol: "DAI", Decimals: 18},
+	})
+	schemas := map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}
+
+	buildGraph := func(t *testing.T, registry tokenregistryindexer.IndexedTokenSystem, opts ...Option) (chains.TokenPoolGraph, error) {
+		t.Helper()
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+		grapher, err := NewGrapher(opts...)
+		require.NoError(t, err)
+		return grapher.Graph(rawGraph, registry, poolRegistry, v2View, v3View, nil, chains.NewProtocolResolver(schemas, poolRegistry))
+	}
+	poolsUsed := func(t *testing.T, graph chains.TokenPoolGraph, tokenIn, tokenOut uint64) []uint64 {
+		t.Helper()
+		path, _, err := graph.FindBestSwapPath(chains.SwapFindingParams{TokenInID: tokenIn, TokenOutID: tokenOut, AmountIn: big.NewInt(1_000), Runs: 3})
+		require.NoError(t, err)
+		var used []uint64
+		for _, hop := range path {
+			used = append(used, hop.PoolID)
+		}
+		return used
+	}
+
+	t.Run("Denylisted tokens are pruned", func(t *testing.T) {
+		graph, err := buildGraph(t, tokenRegistry, WithTokenDenylist([]addr.Address{addr.FromCommon(dai)}))
+		require.NoError(t, err)
+
+		report := graph.BuildReport()
+		assert.Equal(t, 1, report.RoutablePools)
+		assert.ElementsMatch(t, []chains.SkippedPool{
+			{PoolID: 102, Reason: chains.SkipReasonTokenFiltered},
+			{PoolID: 103, Reason: chains.SkipReasonTokenFiltered},
+		}, report.Skipped)
+
+		assert.Equal(t, []uint64{101}, poolsUsed(t, graph, 1, 2))
+		_, _, err = graph.FindBestSwapPath(chains.SwapFindingParams{TokenInID: 1, TokenOutID: 3, AmountIn: big.NewInt(1_000), Runs: 3})
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+
+	t.Run("Only allowlisted tokens are routed", func(t *testing.T) {
+		graph, err := buildGraph(t, tokenRegistry, WithTokenAllowlist([]addr.Address{addr.FromCommon(weth), addr.FromCommon(dai)}))
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, graph.BuildReport().RoutablePools)
+		assert.Equal(t, []uint64{103}, poolsUsed(t, graph, 1, 3))
+	})
+
+	t.Run("Denylist wins over allowlist", func(t *testing.T) {
+		graph, err := buildGraph(t, tokenRegistry,
+			WithTokenAllowlist([]addr.Address{addr.FromCommon(weth), addr.FromCommon(usdc)}),
+			WithTokenDenylist([]addr.Address{addr.FromCommon(usdc)}),
+		)
+		require.NoError(t, err)
+		assert.Equal(t, 0, graph.BuildReport().RoutablePools)
+	})
+
+	t.Run("Filters require token metadata", func(t *testing.T) {
+		_, err := buildGraph(t, nil, WithTokenDenylist([]addr.Address{addr.FromCommon(dai)}))
+		assert.Error(t, err)
+
+		graph, err := buildGraph(t, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 3, graph.BuildReport().RoutablePools)
+	})
+
+	t.Run("ApplyDiff keeps the filter", func(t *testing.T) {
+		graph, err := buildGraph(t, tokenRegistry, WithTokenDenylist([]addr.Address{addr.FromCommon(dai)}))
+		require.NoError(t, err)
+
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+		next, err := graph.ApplyDiff(chains.GraphDiff{
+			TokenPool:            rawGraph,
+			IndexedTokenRegistry: tokenRegistry,
+			IndexedPoolRegistry:  poolRegistry,
+			IndexedUniswapV2:     v2View,
+			IndexedUniswapV3:     v3View,
+			ProtocolResolver:     chains.NewProtocolResolver(schemas, poolRegistry),
+			ChangedPools:         []uint64{102, 103},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, next.BuildReport().RoutablePools)
+	})
+}
+
+func TestGrapherGraphOptions(t *testing.T) {
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"), // WETH
+		2: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), // USDC
+		3: common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F"), // DAI
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"), // WETH/USDC, ~600k USD
+		102: common.HexToAddress("0x102"), // USDC/DAI, ~20 USD
+		103: common.HexToAddress("0x103"), // WETH/DAI, ~6k USD
+		104: common.HexToAddress("0x104"), // USDC/WETH (V3)
+	}
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: bigIntFromString("100000000000000000000"), Reserve1: big.NewInt(300_000_000_000), FeeBps: 30},
+		{ID: 102, Token0: 2, Token1: 3, Reserve0: big.NewInt(10_000_000), Reserve1: bigIntFromString("10000000000000000000"), FeeBps: 30},
+		{ID: 103, Token0: 1, Token1: 3, Reserve0: bigIntFromString("1000000000000000000"), Reserve1: bigIntFromString("3000000000000000000000"), FeeBps: 30},
+	}
+	v3Pool := setupUniswapV3ETHUSDCPool(2, 1, 104)
+	tokenRegistry := tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+		{ID: 1, Address: addr.FromCommon(tokens[1]), Symbol: "WETH", Decimals: 18},
+		{ID: 2, Address: addr.FromCommon(tokens[2]), Symbol: "USDC", Decimals: 6},
+		{ID: 3, Address: addr.FromCommon(tokens[3]), Symbol: "DAI", Decimals: 18},
+	})
+	schemas := map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}
+
+	buildGraph := func(t *testing.T, opts GraphOptions) (chains.TokenPoolGraph, error) {
+		t.Helper()
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{v3Pool})
+		grapher, err := NewGrapher(WithGraphOptions(opts))
+		require.NoError(t, err)
+		return grapher.Graph(rawGraph, tokenRegistry, poolRegistry, v2View, v3View, nil, chains.NewProtocolResolver(schemas, poolRegistry))
+	}
+
+	t.Run("Pools below MinReserveUSD are pruned", func(t *testing.T) {
+		graph, err := buildGraph(t, GraphOptions{MinReserveUSD: 1_000, NumeraireTokenID: 2})
+		require.NoError(t, err)
+
+		report := graph.BuildReport()
+		assert.Equal(t, 3, report.RoutablePools)
+		assert.Equal(t, []chains.SkippedPool{{PoolID: 102, Reason: chains.SkipReasonBelowLiquidity}}, report.Skipped)
+
+		path, _, err := graph.FindBestSwapPath(chains.SwapFindingParams{TokenInID: 2, TokenOutID: 3, AmountIn: big.NewInt(1_000_000), Runs: 3})
+		require.NoError(t, err)
+		for _, hop := range path {
+			assert.NotEqual(t, uint64(102), hop.PoolID)
+		}
+	})
+
+	t.Run("Pools below MinLiquidity are pruned", func(t *testing.T) {
+		graph, err := buildGraph(t, GraphOptions{MinLiquidity: v3Pool.Liquidity})
+		require.NoError(t, err)
+		assert.Equal(t, 4, graph.BuildReport().RoutablePools)
+
+		graph, err = buildGraph(t, GraphOptions{MinLiquidity: new(big.Int).Add(v3Pool.Liquidity, big.NewInt(1))})
+		require.NoError(t, err)
+		assert.Equal(t, []chains.SkippedPool{{PoolID: 104, Reason: chains.SkipReasonBelowLiquidity}}, graph.BuildReport().Skipped)
+	})
+
+	t.Run("The numeraire must be a known token", func(t *testing.T) {
+		_, err := buildGraph(t, GraphOptions{MinReserveUSD: 1_000, NumeraireTokenID: 99})
+		assert.Error(t, err)
+	})
+
+	t.Run("ApplyDiff prunes again", func(t *testing.T) {
+		graph, err := buildGraph(t, GraphOptions{MinReserveUSD: 1_000, NumeraireTokenID: 2})
+		require.NoError(t, err)
+
+		// The USDC/DAI pool deepens past the threshold.
+		deeper := append([]uniswapv2.Pool(nil), uniswapV2Pools...)
+		deeper[1].Reserve0 = big.NewInt(10_000_000_000)
+		deeper[1].Reserve1 = bigIntFromString("10000000000000000000000")
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, deeper, []uniswapv3.Pool{v3Pool})
+		next, err := graph.ApplyDiff(chains.GraphDiff{
+			TokenPool:            rawGraph,
+			IndexedTokenRegistry: tokenRegistry,
+			IndexedPoolRegistry:  poolRegistry,
+			IndexedUniswapV2:     v2View,
+			IndexedUniswapV3:     v3View,
+			ProtocolResolver:     chains.NewProtocolResolver(schemas, poolRegistry),
+			ChangedPools:         []uint64{102},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 4, next.BuildReport().RoutablePools)
+		assert.Empty(t, next.BuildReport().Skipped)
+	})
+
+	t.Run("Illiquid pools do not price tokens", func(t *testing.T) {
+		scamTokens := maps.Clone(tokens)
+		scamTokens[4] = common.HexToAddress("0x4")
+		scamPools := maps.Clone(pools)
+		scamPools[105] = common.HexToAddress("0x105") // USDC/SCAM, ~20 USD, prices SCAM at 10 USD
+		scamPools[106] = common.HexToAddress("0x106") // DAI/SCAM, 1,000 SCAM against dust
+		scamV2Pools := append(append([]uniswapv2.Pool(nil), uniswapV2Pools...),
+			uniswapv2.Pool{ID: 105, Token0: 2, Token1: 4, Reserve0: big.NewInt(10_000_000), Reserve1: bigIntFromString("1000000000000000000"), FeeBps: 30},
+			uniswapv2.Pool{ID: 106, Token0: 3, Token1: 4, Reserve0: big.NewInt(1_000), Reserve1: bigIntFromString("1000000000000000000000"), FeeBps: 30},
+		)
+		registry := tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+			{ID: 1, Address: addr.FromCommon(tokens[1]), Symbol: "WETH", Decimals: 18},
+			{ID: 2, Address: addr.FromCommon(tokens[2]), Symbol: "USDC", Decimals: 6},
+			{ID: 3, Address: addr.FromCommon(tokens[3]), Symbol: "DAI", Decimals: 18},
+			{ID: 4, Address: addr.FromCommon(scamTokens[4]), Symbol: "SCAM", Decimals: 18},
+		})
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, scamTokens, scamPools, scamV2Pools, []uniswapv3.Pool{v3Pool})
+		grapher, err := NewGrapher(WithGraphOptions(GraphOptions{MinReserveUSD: 1_000, NumeraireTokenID: 2}))
+		require.NoError(t, err)
+		graph, err := grapher.Graph(rawGraph, registry, poolRegistry, v2View, v3View, nil, chains.NewProtocolResolver(schemas, poolRegistry))
+		require.NoError(t, err)
+
+		// Valued at the 10 USD the shallow pool quotes, pool 106 would hold 10,000 USD.
+		assert.NotContains(t, graph.(*Graph).liquidityPrices, uint64(4))
+		assert.Equal(t, []chains.SkippedPool{
+			{PoolID: 102, Reason: chains.SkipReasonBelowLiquidity},
+			{PoolID: 105, Reason: chains.SkipReasonBelowLiquidity},
+			{PoolID: 106, Reason: chains.SkipReasonBelowLiquidity},
+		}, graph.BuildReport().Skipped)
+	})
+
+	t.Run("Fixed prices value reserves", func(t *testing.T) {
+		// Without a WETH price, pool 101 is worth its 300,000 USDC and pool 103 its 3,000 DAI.
+		graph, err := buildGraph(t, GraphOptions{MinReserveUSD: 1_000, PricesUSD: map[uint64]float64{2: 1, 3: 1}})
+		require.NoError(t, err)
+		assert.Equal(t, []chains.SkippedPool{{PoolID: 102, Reason: chains.SkipReasonBelowLiquidity}}, graph.BuildReport().Skipped)
+
+		graph, err = buildGraph(t, GraphOptions{MinReserveUSD: 5_000, PricesUSD: map[uint64]float64{2: 1, 3: 1}})
+		require.NoError(t, err)
+		assert.Contains(t, graph.BuildReport().Skipped, chains.SkippedPool{PoolID: 103, Reason: chains.SkipReasonBelowLiquidity})
+	})
+
+	t.Run("ApplyDiff keeps the prices", func(t *testing.T) {
+		graph, err := buildGraph(t, GraphOptions{MinReserveUSD: 1_000, NumeraireTokenID: 2})
+		require.NoError(t, err)
+		prices := graph.(*Graph).liquidityPrices
+		require.Contains(t, prices, uint64(1))
+		require.Contains(t, prices, uint64(3))
+		assert.InDelta(t, 3_000, prices[1]/prices[3], 1, "DAI is priced through WETH at pool 103's spot price")
+
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{v3Pool})
+		next, err := graph.ApplyDiff(chains.GraphDiff{
+			TokenPool:            rawGraph,
+			IndexedTokenRegistry: tokenRegistry,
+			IndexedPoolRegistry:  poolRegistry,
+			IndexedUniswapV2:     v2View,
+			IndexedUniswapV3:     v3View,
+			ProtocolResolver:     chains.NewProtocolResolver(schemas, poolRegistry),
+			ChangedPools:         []uint64{101, 102, 103},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, prices, next.(*Graph).liquidityPrices)
+		assert.Equal(t, graph.BuildReport().Skipped, next.BuildReport().Skipped)
+	})
+}
+
+func TestGrapherUnknownTokens(t *testing.T) {
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0x1"),
+		2: common.HexToAddress("0x2"),
+		3: common.HexToAddress("0x3"), // not in the token registry
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"), // token 1/token 2
+		102: common.HexToAddress("0x102"), // token 1/token 3
+	}
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: big.NewInt(1_000_000), Reserve1: big.NewInt(2_000_000), FeeBps: 30},
+		{ID: 102, Token0: 1, Token1: 3, Reserve0: big.NewInt(1_000_000), Reserve1: big.NewInt(3_000_000), FeeBps: 30},
+	}
+	tokenRegistry := tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+		{ID: 1, Symbol: "WETH", Decimals: 18},
+		{ID: 2, Symbol: "USDC", Decimals: 6},
+	})
+	schemas := map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}
+	buildGraph := func(t *testing.T, opts ...Option) chains.TokenPoolGraph {
+		t.Helper()
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+		grapher, err := NewGrapher(opts...)
+		require.NoError(t, err)
+		graph, err := grapher.Graph(rawGraph, tokenRegistry, poolRegistry, v2View, v3View, nil, chains.NewProtocolResolver(schemas, poolRegistry))
+		require.NoError(t, err)
+		return graph
+	}
+	params := chains.SwapFindingParams{TokenInID: 1, TokenOutID: 3, AmountIn: big.NewInt(1_000), Runs: 2}
+
+	t.Run("Skipped by default", func(t *testing.T) {
+		graph := buildGraph(t)
+
+		report := graph.BuildReport()
+		assert.Equal(t, 1, report.RoutablePools)
+		assert.Equal(t, []chains.SkippedPool{{PoolID: 102, Reason: chains.SkipReasonUnknownToken}}, report.Skipped)
+		assert.Equal(t, []uint64{102}, report.UnknownTokenPools)
+
+		_, _, err := graph.FindBestSwapPath(params)
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+
+	t.Run("Routed by ID with default decimals", func(t *testing.T) {
+		graph := buildGraph(t, WithUnknownTokenPolicy(RouteUnknownTokensWithDefaultDecimals, 18))
+
+		report := graph.BuildReport()
+		assert.Equal(t, 2, report.RoutablePools)
+		assert.Empty(t, report.Skipped)
+		assert.Equal(t, []uint64{102}, report.UnknownTokenPools)
+
+		path, _, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		assert.Equal(t, []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 3, PoolID: 102}}, path)
+
+		// Decimals-aware features fall back to the default decimals.
+		grapherGraph := graph.(*Graph)
+		decimals0, decimals1, ok := grapherGraph.PoolTokenDecimals(102)
+		require.True(t, ok)
+		assert.Equal(t, uint8(18), decimals0)
+		assert.Equal(t, uint8(18), decimals1)
+
+		rate, err := graph.RateForSize(1, 3, big.NewInt(1_000))
+		require.NoError(t, err)
+		rateF, _ := rate.Float64()
+		assert.InDelta(t, 2.98, rateF, 0.01)
+	})
+}
+
+func TestGraphBuildReport(t *testing.T) {
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"), // WETH
+		2: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), // USDC
+		3: common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F"), // DAI
+		4: common.HexToAddress("0x2260FAC5E5542a773Aa44fBCfeDf7C193bc2C599"), // WBTC
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc"), // V2 WETH/USDC
+		102: common.HexToAddress("0xC2e9F25Be6257c210d7Adf0D4Cd6E3E881ba25f8"), // V3 WETH/DAI
+		104: common.HexToAddress("0xBb2b8038a1640196FbE3e38816F3e67Cba72D940"), // V2 WETH/WBTC
+	}
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: big.NewInt(1000), Reserve1: big.NewInt(2000000), FeeBps: 30},
+		{ID: 104, Token0: 1, Token1: 4, Reserve0: big.NewInt(500), Reserve1: big.NewInt(10), FeeBps: 30},
+	}
+	uniswapV3Pools := []uniswapv3.Pool{
+		{PoolViewMinimal: uniswapv3.PoolViewMinimal{ID: 102, Token0: 1, Token1: 3, Fee: 3000}},
+	}
+
+	buildGraph := func(
+		t *testing.T,
+		schemas map[engine.ProtocolID]engine.ProtocolSchema,
+		mutate func(poolRegistry *mockIndexedPoolRegistry, v2View *mockIndexedUniswapV2, v3View *mockIndexedUniswapV3),
+	) *Graph {
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, uniswapV3Pools)
+		if mutate != nil {
+			mutate(poolRegistry.(*mockIndexedPoolRegistry), v2View.(*mockIndexedUniswapV2), v3View.(*mockIndexedUniswapV3))
+		}
+		graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{}, chains.NewProtocolResolver(schemas, poolRegistry))
+		require.NoError(t, err)
+		return graph
+	}
+
+	defaultSchemas := map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}
+
+	t.Run("Healthy graph reports no skipped pools", func(t *testing.T) {
+		report := buildGraph(t, defaultSchemas, nil).BuildReport()
+		assert.Equal(t, 3, report.TotalPools)
+		assert.Equal(t, 3, report.RoutablePools)
+		assert.Empty(t, report.Skipped)
+	})
+
+	t.Run("Missing registry entries and pool data are reported", func(t *testing.T) {
+		graph := buildGraph(t, defaultSchemas, func(poolRegistry *mockIndexedPoolRegistry, v2View *mockIndexedUniswapV2, _ *mockIndexedUniswapV3) {
+			delete(poolRegistry.poolsByID, 102)
+			delete(v2View.poolsByID, 104)
+		})
+
+		report := graph.BuildReport()
+		assert.Equal(t, 3, report.TotalPools)
+		assert.Equal(t, 1, report.RoutablePools)
+		assert.ElementsMatch(t, []chains.SkippedPool{
+			{PoolID: 102, Reason: chains.SkipReasonNotInPoolRegistry},
+			{PoolID: 104, Reason: chains.SkipReasonMissingPoolData},
+		}, report.Skipped)
+
+		// Skipped pools must not be used for routing.
+		_, _, err := graph.FindBestSwapPath(chains.SwapFindingParams{TokenInID: 1, TokenOutID: 4, AmountIn: big.NewInt(100), Runs: 2})
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+
+	t.Run("Grapher skips and reports pools missing from the protocol indexer", func(t *testing.T) {
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, uniswapV3Pools)
+		// Pool 102 stays in the pool registry but is missing from the V3 indexer.
+		delete(v3View.(*mockIndexedUniswapV3).poolsByID, 102)
+
+		grapher, err := NewGrapher()
+		require.NoError(t, err)
+		graph, err := grapher.Graph(rawGraph, nil, poolRegistry, v2View, v3View, nil, chains.NewProtocolResolver(defaultSchemas, poolRegistry))
+		require.NoError(t, err)
+
+		report := graph.BuildReport()
+		assert.Equal(t, 2, report.RoutablePools)
+		assert.Equal(t, []chains.SkippedPool{{PoolID: 102, Reason: chains.SkipReasonMissingPoolData}}, report.Skipped)
+
+		// Routing over the remaining pools still works.
+		_, _, err = graph.FindBestSwapPath(chains.SwapFindingParams{TokenInID: 1, TokenOutID: 2, AmountIn: big.NewInt(100), Runs: 2})
+		require.NoError(t, err)
+		_, _, err = graph.FindBestSwapPath(chains.SwapFindingParams{TokenInID: 1, TokenOutID: 3, AmountIn: big.NewInt(100), Runs: 2})
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+
+	t.Run("Unresolvable and unsupported schemas are reported", func(t *testing.T) {
+		unresolved := buildGraph(t, map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+		}, nil)
+		assert.Equal(t, []chains.SkippedPool{{PoolID: 102, Reason: chains.SkipReasonUnknownSchema}}, unresolved.BuildReport().Skipped)
+
+		unsupported := buildGraph(t, map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+			uniswapV3ProtocolID: "defistate/unsupported@v1",
+		}, nil)
+		assert.Equal(t, []chains.SkippedPool{{PoolID: 102, Reason: chains.SkipReasonUnsupportedSchema}}, unsupported.BuildReport().Skipped)
+	})
+
+	t.Run("Report is a copy", func(t *testing.T) {
+		graph := buildGraph(t, defaultSchemas, func(_ *mockIndexedPoolRegistry, v2View *mockIndexedUniswapV2, _ *mockIndexedUniswapV3) {
+			delete(v2View.poolsByID, 104)
+		})
+		report := graph.BuildReport()
+		require.Len(t, report.Skipped, 1)
+		report.Skipped[0].PoolID = 999
+		assert.Equal(t, uint64(104), graph.BuildReport().Skipped[0].PoolID)
+	})
+}
+
+func TestGraphDuplicatePoolIDs(t *testing.T) {
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"), // WETH
+		2: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), // USDC
+		3: common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F"), // DAI
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc"), // V2 WETH/USDC
+		102: common.HexToAddress("0xC2e9F25Be6257c210d7Adf0D4Cd6E3E881ba25f8"), // V3 WETH/DAI
+	}
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: big.NewInt(1000), Reserve1: big.NewInt(2000000), FeeBps: 30},
+	}
+	uniswapV3Pools := []uniswapv3.Pool{
+		{PoolViewMinimal: uniswapv3.PoolViewMinimal{ID: 102, Token0: 1, Token1: 3, Fee: 3000}},
+	}
+	schemas := map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}
+	// requirements returns the graph views with pool 102 also claimed by the V2 protocol.
+	requirements := func(t *testing.T) (*tokenpoolregistry.TokenPoolRegistryView, poolregistryindexer.IndexedPoolRegistry, uniswapv2indexer.IndexedUniswapV2, uniswapv3indexer.IndexedUniswapV3) {
+		t.Helper()
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, uniswapV3Pools)
+		v2View.(*mockIndexedUniswapV2).poolsByID[102] = uniswapv2.Pool{
+			ID: 102, Token0: 1, Token1: 3, Reserve0: big.NewInt(1000), Reserve1: big.NewInt(3000), FeeBps: 30,
+		}
+		return rawGraph, poolRegistry, v2View, v3View
+	}
+
+	t.Run("NewGraph skips and reports the conflict", func(t *testing.T) {
+		rawGraph, poolRegistry, v2View, v3View := requirements(t)
+		graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{}, chains.NewProtocolResolver(schemas, poolRegistry))
+		require.NoError(t, err)
+
+		report := graph.BuildReport()
+		assert.Equal(t, []uint64{102}, report.DuplicatePoolIDs)
+		assert.Equal(t, 1, report.RoutablePools)
+		assert.Equal(t, []chains.SkippedPool{{PoolID: 102, Reason: chains.SkipReasonDuplicatePoolID}}, report.Skipped)
+
+		_, _, err = graph.FindBestSwapPath(chains.SwapFindingParams{TokenInID: 1, TokenOutID: 3, AmountIn: big.NewInt(100), Runs: 2})
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+
+	t.Run("Grapher rejects the conflict when configured", func(t *testing.T) {
+		rawGraph, poolRegistry, v2View, v3View := requirements(t)
+		resolver := chains.NewProtocolResolver(schemas, poolRegistry)
+
+		grapher, err := NewGrapher()
+		require.NoError(t, err)
+		graph, err := grapher.Graph(rawGraph, nil, poolRegistry, v2View, v3View, nil, resolver)
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{102}, graph.BuildReport().DuplicatePoolIDs)
+
+		grapher, err = NewGrapher(WithDuplicatePoolPolicy(RejectDuplicatePools))
+		require.NoError(t, err)
+		_, err = grapher.Graph(rawGraph, nil, poolRegistry, v2View, v3View, nil, resolver)
+		assert.ErrorIs(t, err, chains.ErrDuplicatePoolID)
+	})
+
+	t.Run("ApplyDiff detects a new conflict on an unchanged pool", func(t *testing.T) {
+		buildGraph := func(t *testing.T, opts ...Option) chains.TokenPoolGraph {
+			t.Helper()
+			rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, uniswapV3Pools)
+			grapher, err := NewGrapher(opts...)
+			require.NoError(t, err)
+			graph, err := grapher.Graph(rawGraph, nil, poolRegistry, v2View, v3View, nil, chains.NewProtocolResolver(schemas, poolRegistry))
+			require.NoError(t, err)
+			require.Empty(t, graph.BuildReport().DuplicatePoolIDs)
+			return graph
+		}
+		rawGraph, poolRegistry, v2View, v3View := requirements(t)
+		// Pool 102 is not listed as changed, so it would otherwise keep its V3 functions.
+		diff := chains.GraphDiff{
+			TokenPool:           rawGraph,
+			IndexedPoolRegistry: poolRegistry,
+			IndexedUniswapV2:    v2View,
+			IndexedUniswapV3:    v3View,
+			ProtocolResolver:    chains.NewProtocolResolver(schemas, poolRegistry),
+		}
+
+		next, err := buildGraph(t).ApplyDiff(diff)
+		require.NoError(t, err)
+		report := next.BuildReport()
+		assert.Equal(t, []uint64{102}, report.DuplicatePoolIDs)
+		assert.Equal(t, []chains.SkippedPool{{PoolID: 102, Reason: chains.SkipReasonDuplicatePoolID}}, report.Skipped)
+
+		_, err = buildGraph(t, WithDuplicatePoolPolicy(RejectDuplicatePools)).ApplyDiff(diff)
+		assert.ErrorIs(t, err, chains.ErrDuplicatePoolID)
+	})
+}
+
+// TestGraphV3FeeOverride builds a graph over a V3 pool that charges a fee other than its
+// fee tier, as on forks with dynamic fees, and expects the fee it charges to be quoted
+// and reported.
+func TestGraphV3FeeOverride(t *testing.T) {
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"), // WETH
+		2: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), // USDC
+	}
+	pools := map[uint64]common.Address{
+		301: common.HexToAddress("0x301"), // 0.05% tier, charging 1%
+		302: common.HexToAddress("0x302"), // 0.3% tier
+	}
+	liquidity := new(big.Int).Mul(big.NewInt(1_000_000), big.NewInt(1e18))
+	pool := func(id, fee uint64, feeOverride *uint64) uniswapv3.Pool {
+		return uniswapv3.Pool{
+			PoolViewMinimal: uniswapv3.PoolViewMinimal{ID: id, Token0: 1, Token1: 2, Fee: fee, TickSpacing: 60, Liquidity: liquidity, SqrtPriceX96: new(big.Int).Lsh(big.NewInt(1), 96)},
+			Ticks: []uniswapv3.TickInfo{
+				{Index: -887220, LiquidityGross: liquidity, LiquidityNet: liquidity},
+				{Index: 887220, LiquidityGross: liquidity, LiquidityNet: new(big.Int).Neg(liquidity)},
+			},
+			FeeOverride: feeOverride,
+		}
+	}
+	feeOverride := uint64(10_000)
+	uniswapV3Pools := []uniswapv3.Pool{pool(301, 500, &feeOverride), pool(302, 3000, nil)}
+
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, []uniswapv2.Pool{}, uniswapV3Pools)
+	resolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}, poolRegistry)
+	graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{301: {}, 302: {}}, resolver)
+	require.NoError(t, err)
+	params := chains.SwapFindingParams{TokenInID: 1, TokenOutID: 2, AmountIn: big.NewInt(1e18), Runs: 2}
+
+	t.Run("Candidates report the fee swaps pay", func(t *testing.T) {
+		fees := make(map[uint64]uint64)
+		params := params
+		params.PoolSelector = chains.PoolSelectorFunc(func(_, _ uint64, candidates []chains.PoolCandidate) int {
+			for _, candidate := range candidates {
+				fees[candidate.PoolID] = candidate.FeePips
+			}
+			return 0
+		})
+		_, _, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		assert.Equal(t, map[uint64]uint64{301: 10_000, 302: 3000}, fees)
+
+		params.PoolSelector = chains.LowestFeeSelector{}
+		path, _, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(302), path[0].PoolID, "the pool with the lower tier charges more")
+	})
+
+	t.Run("The float quote charges the override", func(t *testing.T) {
+		quote := graph.cachedGetAmountOutFuncs[graph.poolToIndex[301]]
+		require.NotNil(t, quote)
+		amountOut, err := quote(1e18, 1, 2)
+		require.NoError(t, err)
+
+		atOverride, err := uniswapV3FloatQuote(pool(301, feeOverride, nil))(1e18, 1, 2)
+		require.NoError(t, err)
+		atTier, err := uniswapV3FloatQuote(pool(301, 500, nil))(1e18, 1, 2)
+		require.NoError(t, err)
+		assert.Equal(t, atOverride, amountOut)
+		assert.Less(t, amountOut, atTier)
+	})
+}
+
+func TestBreakEvenFee(t *testing.T) {
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"), // WETH
+		2: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), // USDC
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"), // WETH/USDC 0.30%
+		102: common.HexToAddress("0x102"), // WETH/USDC 0.05%
+	}
+	reserveWETH := new(big.Int).Mul(big.NewInt(1_000), big.NewInt(1e18))
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: reserveWETH, Reserve1: big.NewInt(3_000_000e6), FeeBps: 30},
+		{ID: 102, Token0: 1, Token1: 2, Reserve0: reserveWETH, Reserve1: big.NewInt(3_000_000e6), FeeBps: 5},
+	}
+	schemas := map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+	graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{}, chains.NewProtocolResolver(schemas, poolRegistry))
+	require.NoError(t, err)
+
+	expensive := []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 2, PoolID: 101}}
+	cheap := []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 2, PoolID: 102}}
+	amountIn := big.NewInt(1e18)
+
+	// bruteForce raises the extra fee one pip at a time until the better pool falls behind.
+	bruteForce := func(t *testing.T, better uniswapv2.Pool, worseOut *big.Int) uint64 {
+		t.Helper()
+		for fee := int64(0); fee <= 1_000_000; fee++ {
+			in := new(big.Int).Mul(amountIn, big.NewInt(1_000_000-fee))
+			in.Quo(in, big.NewInt(1_000_000))
+			out, err := uniswapv2calculator.GetAmountOut(in, 1, 2, better)
+			require.NoError(t, err)
+			if out.Cmp(worseOut) < 0 {
+				return uint64(fee - 1)
+			}
+		}
+		return 1_000_000
+	}
+
+	t.Run("Cheaper fee tier can bear the fee difference", func(t *testing.T) {
+		result, err := graph.BreakEvenFee(chains.BreakEvenFeeParams{RouteA: expensive, RouteB: cheap, AmountIn: amountIn})
+		require.NoError(t, err)
+
+		assert.False(t, result.BetterIsA)
+		assert.Equal(t, 1, result.AmountOutB.Cmp(result.AmountOutA))
+		assert.Equal(t, bruteForce(t, uniswapV2Pools[1], result.AmountOutA), result.FeePips)
+		// Identical reserves: the break-even is about the 25 bip fee difference.
+		assert.InDelta(t, 2500, float64(result.FeePips), 10)
+	})
+
+	t.Run("Order of the routes does not matter", func(t *testing.T) {
+		result, err := graph.BreakEvenFee(chains.BreakEvenFeeParams{RouteA: cheap, RouteB: expensive, AmountIn: amountIn})
+		require.NoError(t, err)
+
+		assert.True(t, result.BetterIsA)
+		assert.Equal(t, bruteForce(t, uniswapV2Pools[1], result.AmountOutB), result.FeePips)
+	})
+
+	t.Run("Identical routes break even at zero", func(t *testing.T) {
+		result, err := graph.BreakEvenFee(chains.BreakEvenFeeParams{RouteA: cheap, RouteB: cheap, AmountIn: amountIn})
+		require.NoError(t, err)
+		assert.True(t, result.BetterIsA)
+		assert.Zero(t, result.FeePips)
+	})
+
+	t.Run("Invalid input", func(t *testing.T) {
+		_, err := graph.BreakEvenFee(chains.BreakEvenFeeParams{RouteA: cheap, RouteB: expensive})
+		assert.Error(t, err)
+
+		reversed := []chains.TokenPoolPath{{TokenInID: 2, TokenOutID: 1, PoolID: 101}}
+		_, err = graph.BreakEvenFee(chains.BreakEvenFeeParams{RouteA: cheap, RouteB: reversed, AmountIn: amountIn})
+		assert.Error(t, err)
+
+		unknown := []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 2, PoolID: 999}}
+		_, err = graph.BreakEvenFee(chains.BreakEvenFeeParams{RouteA: cheap, RouteB: unknown, AmountIn: amountIn})
+		assert.Error(t, err)
+	})
+}
+
+func TestFindCheapestAcceptableRoute(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	setup := func(t *testing.T) *Graph {
+		tokens := map[uint64]common.Address{
+			1: common.HexToAddress("0xA"),
+			2: common.HexToAddress("0xB"),
+			3: common.HexToAddress("0xC"),
+			4: common.HexToAddress("0xD"),
+		}
+		pools := map[uint64]common.Address{
+			101: common.HexToAddress("0x101"), // A -> D (Direct, Low Liquidity)
+			102: common.HexToAddress("0x102"), // A -> B (High Liquidity)
+			103: common.HexToAddress("0x103"), // B -> C (High Liquidity)
+			104: common.HexToAddress("0x104"), // C -> D (High Liquidity)
+		}
+		deep := new(big.Int).Mul(big.NewInt(10000), d18)
+		shallow := new(big.Int).Mul(big.NewInt(10), d18)
+		uniswapV2Pools := []uniswapv2.Pool{
+			{ID: 101, Token0: 1, Token1: 4, Reserve0: shallow, Reserve1: shallow, FeeBps: 30},
+			{ID: 102, Token0: 1, Token1: 2, Reserve0: deep, Reserve1: deep, FeeBps: 30},
+			{ID: 103, Token0: 2, Token1: 3, Reserve0: deep, Reserve1: deep, FeeBps: 30},
+			{ID: 104, Token0: 3, Token1: 4, Reserve0: deep, Reserve1: deep, FeeBps: 30},
+		}
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+		protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+			uniswapV3ProtocolID: uniswapv3.Schema,
+		}, poolRegistry)
+		graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{101: {}, 102: {}, 103: {}, 104: {}}, protocolResolver)
+		require.NoError(t, err)
+		return graph
+	}
+
+	gasEstimates := chains.GasEstimates{uniswapv2.Schema: 100_000}
+	amountIn := new(big.Int).Set(d18)
+
+	t.Run("Cheaper 1-hop route wins when both clear the minimum", func(t *testing.T) {
+		graph := setup(t)
+
+		bestPath, bestOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{TokenInID: 1, TokenOutID: 4, AmountIn: amountIn, Runs: 3})
+		require.NoError(t, err)
+		require.Len(t, bestPath, 3, "the output-maximizing route is the 3-hop route")
+
+		route, err := graph.FindCheapestAcceptableRoute(chains.CheapestRouteParams{
+			TokenInID:        1,
+			TokenOutID:       4,
+			AmountIn:         amountIn,
+			MinAcceptableOut: new(big.Int).Div(new(big.Int).Mul(d18, big.NewInt(8)), big.NewInt(10)), // 0.8
+			GasEstimates:     gasEstimates,
+			Runs:             3,
+		})
+		require.NoError(t, err)
+		require.Len(t, route.Path, 1)
+		assert.Equal(t, uint64(101), route.Path[0].PoolID)
+		assert.Equal(t, uint64(100_000), route.GasUsed)
+		assert.Equal(t, -1, route.AmountOut.Cmp(bestOut), "the cheap route accepts less output")
+	})
+
+	t.Run("Falls back to the costlier route when the cheap one misses the minimum", func(t *testing.T) {
+		graph := setup(t)
+		route, err := graph.FindCheapestAcceptableRoute(chains.CheapestRouteParams{
+			TokenInID:        1,
+			TokenOutID:       4,
+			AmountIn:         amountIn,
+			MinAcceptableOut: new(big.Int).Div(new(big.Int).Mul(d18, big.NewInt(95)), big.NewInt(100)), // 0.95
+			GasEstimates:     gasEstimates,
+			Runs:             3,
+		})
+		require.NoError(t, err)
+		require.Len(t, route.Path, 3)
+		assert.Equal(t, uint64(300_000), route.GasUsed)
+	})
+
+	t.Run("Returns ErrNoRoute when nothing clears the minimum", func(t *testing.T) {
+		graph := setup(t)
+		_, err := graph.FindCheapestAcceptableRoute(chains.CheapestRouteParams{
+			TokenInID:        1,
+			TokenOutID:       4,
+			AmountIn:         amountIn,
+			MinAcceptableOut: amountIn,
+			GasEstimates:     gasEstimates,
+			Runs:             3,
+		})
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+
+	t.Run("Pools without a gas estimate are not used", func(t *testing.T) {
+		graph := setup(t)
+		_, err := graph.FindCheapestAcceptableRoute(chains.CheapestRouteParams{
+			TokenInID:        1,
+			TokenOutID:       4,
+			AmountIn:         amountIn,
+			MinAcceptableOut: big.NewInt(1),
+			GasEstimates:     chains.GasEstimates{uniswapv3.Schema: 150_000},
+			Runs:             3,
+		})
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+
+	t.Run("Invalid params", func(t *testing.T) {
+		graph := setup(t)
+		_, err := graph.FindCheapestAcceptableRoute(chains.CheapestRouteParams{TokenInID: 1, TokenOutID: 4, MinAcceptableOut: big.NewInt(1), Runs: 3})
+		assert.Error(t, err)
+		_, err = graph.FindCheapestAcceptableRoute(chains.CheapestRouteParams{TokenInID: 1, TokenOutID: 4, AmountIn: amountIn, MinAcceptableOut: big.NewInt(1)})
+		assert.Error(t, err)
+		_, err = graph.FindCheapestAcceptableRoute(chains.CheapestRouteParams{TokenInID: 1, TokenOutID: 99, AmountIn: amountIn, MinAcceptableOut: big.NewInt(1), Runs: 3})
+		assert.Error(t, err)
+	})
+}
+
+func TestGasModelRouting(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	gasModel := func(gwei int64) *chains.BlockGasModel {
+		return chains.NewBlockGasModel(engine.BlockSummary{BaseFee: big.NewInt(gwei * 1e9)}, chains.GasEstimates{uniswapv2.Schema: 100_000}, nil)
+	}
+
+	t.Run("Swap routes are ranked net of gas", func(t *testing.T) {
+		tokens := map[uint64]common.Address{
+			1: common.HexToAddress("0xA"),
+			2: common.HexToAddress("0xB"),
+			3: common.HexToAddress("0xC"),
+			4: common.HexToAddress("0xD"),
+		}
+		pools := map[uint64]common.Address{
+			101: common.HexToAddress("0x101"), // A -> D (Direct, Low Liquidity)
+			102: common.HexToAddress("0x102"), // A -> B (High Liquidity)
+			103: common.HexToAddress("0x103"), // B -> C (High Liquidity)
+			104: common.HexToAddress("0x104"), // C -> D (High Liquidity)
+		}
+		deep := new(big.Int).Mul(big.NewInt(10000), d18)
+		shallow := new(big.Int).Mul(big.NewInt(10), d18)
+		uniswapV2Pools := []uniswapv2.Pool{
+			{ID: 101, Token0: 1, Token1: 4, Reserve0: shallow, Reserve1: shallow, FeeBps: 30},
+			{ID: 102, Token0: 1, Token1: 2, Reserve0: deep, Reserve1: deep, FeeBps: 30},
+			{ID: 103, Token0: 2, Token1: 3, Reserve0: deep, Reserve1: deep, FeeBps: 30},
+			{ID: 104, Token0: 3, Token1: 4, Reserve0: deep, Reserve1: deep, FeeBps: 30},
+		}
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+		protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+			uniswapV3ProtocolID: uniswapv3.Schema,
+		}, poolRegistry)
+		graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{101: {}, 102: {}, 103: {}, 104: {}}, protocolResolver)
+		require.NoError(t, err)
+
+		params := chains.SwapFindingParams{TokenInID: 1, TokenOutID: 4, AmountIn: d18, Runs: 3, NativeTokenID: 1}
+
+		// At 1 gwei the two extra hops cost 0.0002 A, less than the 3-hop route's extra output.
+		params.GasModel = gasModel(1)
+		path, _, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		assert.Len(t, path, 3)
+
+		// At 1,000 gwei they cost 0.2 A, so the thin direct pool wins.
+		params.GasModel = gasModel(1000)
+		path, amountOut, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		require.Len(t, path, 1)
+		assert.Equal(t, uint64(101), path[0].PoolID)
+		direct, err := graph.quoteRoute(path, d18)
+		require.NoError(t, err)
+		assert.Equal(t, direct, amountOut, "the returned amount is the gross output")
+
+		// Pools without a gas estimate are not used.
+		params.GasModel = chains.NewBlockGasModel(engine.BlockSummary{}, chains.GasEstimates{uniswapv3.Schema: 100_000}, nil)
+		_, _, err = graph.FindBestSwapPath(params)
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+
+		params.GasModel = gasModel(1)
+		params.FloatSearch = true
+		_, _, err = graph.FindBestSwapPath(params)
+		assert.Error(t, err, "GasModel is not supported with FloatSearch")
+	})
+
+	t.Run("Cycles are ranked and filtered net of gas", func(t *testing.T) {
+		graph := setupMultiCycleTestGraph(t)
+		params := chains.CycleFindingParams{
+			TokenID:       1,
+			AmountIn:      new(big.Int).SetUint64(1e18),
+			Runs:          3,
+			GasModel:      gasModel(1),
+			NativeTokenID: 1,
+		}
+		cycles, amounts, err := graph.FindAllArbitrageCycles(params, 0)
+		require.NoError(t, err)
+		require.Greater(t, len(cycles), 1)
+
+		// Gas is paid in the start token, so a hop costs exactly 100,000 gwei of it.
+		hopCost := big.NewInt(100_000 * 1e9)
+		net := func(i int) *big.Int {
+			cost := new(big.Int).Mul(hopCost, big.NewInt(int64(len(cycles[i]))))
+			return cost.Sub(amounts[i], cost)
+		}
+		for i := 1; i < len(cycles); i++ {
+			assert.True(t, net(i-1).Cmp(net(i)) >= 0, "cycles must be ordered by net profit")
+		}
+
+		// At 100,000 gwei a single hop costs 0.01 WETH, more than any cycle makes.
+		params.GasModel = gasModel(100_000)
+		params.MinProfit = &chains.ProfitThreshold{Unit: chains.ProfitUnitRaw, Amount: big.NewFloat(0)}
+		cycles, _, err = graph.FindAllArbitrageCycles(params, 0)
+		require.NoError(t, err)
+		assert.Empty(t, cycles)
+
+		cycles, _, err = graph.FindArbitrageCycles(params)
+		require.NoError(t, err)
+		assert.Empty(t, cycles)
+	})
+}
+
+// recordingLogger captures warnings so tests can assert on logged discrepancies.
+type recordingLogger struct {
+	warnings []string
+}
+
+func (l *recordingLogger) Debug(msg string, args ...any) {}
+func (l *recordingLogger) Info(msg string, args ...any)  {}
+func (l *recordingLogger) Warn(msg string, args ...any)  { l.warnings = append(l.warnings, msg) }
+func (l *recordingLogger) Error(msg string, args ...any) {}
+
+func TestFindBestSwapPathValidationFallback(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	setup := func(t *testing.T) *Graph {
+		tokens := map[uint64]common.Address{
+			1: common.HexToAddress("0xA"),
+			2: common.HexToAddress("0xB"),
+			3: common.HexToAddress("0xC"),
+			4: common.HexToAddress("0xD"),
+		}
+		pools := map[uint64]common.Address{
+			101: common.HexToAddress("0x101"), // A -> D (Direct, Low Liquidity)
+			102: common.HexToAddress("0x102"), // A -> B (High Liquidity)
+			103: common.HexToAddress("0x103"), // B -> C (High Liquidity)
+			104: common.HexToAddress("0x104"), // C -> D (High Liquidity)
+		}
+		deep := new(big.Int).Mul(big.NewInt(10000), d18)
+		shallow := new(big.Int).Mul(big.NewInt(10), d18)
+		uniswapV2Pools := []uniswapv2.Pool{
+			{ID: 101, Token0: 1, Token1: 4, Reserve0: shallow, Reserve1: shallow, FeeBps: 30},
+			{ID: 102, Token0: 1, Token1: 2, Reserve0: deep, Reserve1: deep, FeeBps: 30},
+			{ID: 103, Token0: 2, Token1: 3, Reserve0: deep, Reserve1: deep, FeeBps: 30},
+			{ID: 104, Token0: 3, Token1: 4, Reserve0: deep, Reserve1: deep, FeeBps: 30},
+		}
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+		protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+			uniswapV3ProtocolID: uniswapv3.Schema,
+		}, poolRegistry)
+		graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{101: {}, 102: {}, 103: {}, 104: {}}, protocolResolver)
+		require.NoError(t, err)
+
+		// Simulate a calculator discrepancy: pool 103's routing function overstates its output.
+		poolIndex := graph.poolToIndex[103]
+		honest := graph.activeGetAmountOutFuncs[poolIndex]
+		graph.activeGetAmountOutFuncs[poolIndex] = func(amountIn *big.Int, tokenInID, tokenOutID uint64) (*big.Int, error) {
+			out, err := honest(amountIn, tokenInID, tokenOutID)
+			if err != nil {
+				return nil, err
+			}
+			return out.Mul(out, big.NewInt(2)), nil
+		}
+		return graph
+	}
+	amountIn := new(big.Int).Set(d18)
+
+	t.Run("Without fallback the faulty route is returned and fails validation", func(t *testing.T) {
+		graph := setup(t)
+		path, amountOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{TokenInID: 1, TokenOutID: 4, AmountIn: amountIn, Runs: 3})
+		require.NoError(t, err)
+		require.Len(t, path, 3)
+		assert.Equal(t, uint64(103), path[1].PoolID)
+
+		err = graph.ValidateRoute(chains.RouteValidationParams{Path: path, AmountIn: amountIn, ExpectedOut: amountOut})
+		assert.ErrorIs(t, err, chains.ErrRouteValidation)
+	})
+
+	t.Run("Fallback returns the next-best valid route and logs the discrepancy", func(t *testing.T) {
+		graph := setup(t)
+		logger := &recordingLogger{}
+		path, amountOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+			TokenInID:                   1,
+			TokenOutID:                  4,
+			AmountIn:                    amountIn,
+			Runs:                        3,
+			FallbackOnValidationFailure: true,
+			Logger:                      logger,
+		})
+		require.NoError(t, err)
+		require.Len(t, path, 1, "the direct pool is the best route without pool 103")
+		assert.Equal(t, uint64(101), path[0].PoolID)
+		assert.Len(t, logger.warnings, 1)
+
+		require.NoError(t, graph.ValidateRoute(chains.RouteValidationParams{Path: path, AmountIn: amountIn, ExpectedOut: amountOut}))
+	})
+
+	t.Run("Validation rejects broken paths", func(t *testing.T) {
+		graph := setup(t)
+		brokenPath := []chains.TokenPoolPath{
+			{TokenInID: 1, TokenOutID: 2, PoolID: 102},
+			{TokenInID: 3, TokenOutID: 4, PoolID: 104},
+		}
+		err := graph.ValidateRoute(chains.RouteValidationParams{Path: brokenPath, AmountIn: amountIn})
+		assert.ErrorIs(t, err, chains.ErrRouteValidation)
+
+		wrongPool := []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 4, PoolID: 102}}
+		err = graph.ValidateRoute(chains.RouteValidationParams{Path: wrongPool, AmountIn: amountIn})
+		assert.ErrorIs(t, err, chains.ErrRouteValidation)
+	})
+}
+
+func TestUSDPrice(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	weth := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), d18) }
+	usd6 := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e6)) }
+
+	setup := func(t *testing.T) *Graph {
+		tokens := map[uint64]common.Address{
+			1: common.HexToAddress("0x1"), // WETH
+			2: common.HexToAddress("0x2"), // USDC
+			3: common.HexToAddress("0x3"), // USDT
+			4: common.HexToAddress("0x4"), // DAI (depegged)
+			5: common.HexToAddress("0x5"), // FRAX (no pools)
+		}
+		pools := map[uint64]common.Address{
+			101: common.HexToAddress("0x101"),
+			102: common.HexToAddress("0x102"),
+			103: common.HexToAddress("0x103"),
+		}
+		uniswapV2Pools := []uniswapv2.Pool{
+			{ID: 101, Token0: 1, Token1: 2, Reserve0: weth(1000), Reserve1: usd6(3_000_000), FeeBps: 0},
+			{ID: 102, Token0: 1, Token1: 3, Reserve0: weth(1000), Reserve1: usd6(3_010_000), FeeBps: 0},
+			{ID: 103, Token0: 1, Token1: 4, Reserve0: weth(1000), Reserve1: weth(1_500_000), FeeBps: 0},
+		}
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+		protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+			uniswapV3ProtocolID: uniswapv3.Schema,
+		}, poolRegistry)
+		tokenRegistry := tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+			{ID: 1, Symbol: "WETH", Decimals: 18},
+			{ID: 2, Symbol: "USDC", Decimals: 6},
+			{ID: 3, Symbol: "USDT", Decimals: 6},
+			{ID: 4, Symbol: "DAI", Decimals: 18},
+			{ID: 5, Symbol: "FRAX", Decimals: 18},
+		})
+		graph, err := NewGraph(rawGraph, tokenRegistry, poolRegistry, v2View, v3View, map[uint64]struct{}{101: {}, 102: {}, 103: {}}, protocolResolver)
+		require.NoError(t, err)
+		return graph
+	}
+	// A small amount keeps price impact negligible.
+	amountIn := new(big.Int).Div(d18, big.NewInt(1000))
+
+	assertPrice := func(t *testing.T, expected float64, price *big.Float) {
+		t.Helper()
+		got, _ := price.Float64()
+		assert.InDelta(t, expected, got, expected*0.001)
+	}
+
+	t.Run("Median excludes the outlier stable", func(t *testing.T) {
+		graph := setup(t)
+		price, err := graph.USDPrice(chains.USDPriceParams{
+			TokenID:        1,
+			AmountIn:       amountIn,
+			StableTokenIDs: []uint64{4, 2, 3},
+			Mode:           chains.USDPriceMedian,
+			Runs:           2,
+		})
+		require.NoError(t, err)
+		assertPrice(t, 3000, price)
+	})
+
+	t.Run("Median of an even set averages the middle quotes", func(t *testing.T) {
+		graph := setup(t)
+		price, err := graph.USDPrice(chains.USDPriceParams{
+			TokenID:        1,
+			AmountIn:       amountIn,
+			StableTokenIDs: []uint64{2, 3, 5}, // FRAX is unreachable and ignored
+			Mode:           chains.USDPriceMedian,
+			Runs:           2,
+		})
+		require.NoError(t, err)
+		assertPrice(t, 3005, price)
+	})
 
-			for i := 0; i < b.N; i++ {
-				_, _, _ = graph.FindArbitrageCycles(params)
+	t.Run("Single anchor uses the first reachable stable", func(t *testing.T) {
+		graph := setup(t)
+		price, err := graph.USDPrice(chains.USDPriceParams{
+			TokenID:        1,
+			AmountIn:       amountIn,
+			StableTokenIDs: []uint64{5, 4, 2},
+			Mode:           chains.USDPriceSingleAnchor,
+			Runs:           2,
+		})
+		require.NoError(t, err)
+		assertPrice(t, 1500, price) // the depegged stable skews a single-anchor price
+	})
 
-			}
+	t.Run("Returns ErrNoRoute when no stable is reachable", func(t *testing.T) {
+		graph := setup(t)
+		_, err := graph.USDPrice(chains.USDPriceParams{
+			TokenID:        1,
+			AmountIn:       amountIn,
+			StableTokenIDs: []uint64{5},
+			Mode:           chains.USDPriceMedian,
+			Runs:           2,
 		})
-	}
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
 }
 
-// setupSwapPathTestGraph creates a graph specifically for testing swap paths.
-// It includes a clear optimal path (A->B->D) and several suboptimal paths.
-func setupSwapPathTestGraph(t *testing.T, activePools map[uint64]struct{}) *Graph {
-	// A, B, C, D are connected. E is isolated.
+func TestTopPoolsBySchema(t *testing.T) {
 	tokens := map[uint64]common.Address{
-		1: common.HexToAddress("0xA"), // Token A (e.g., WETH)
-		2: common.HexToAddress("0xB"), // Token B (e.g., USDC)
-		3: common.HexToAddress("0xC"), // Token C (e.g., DAI)
-		4: common.HexToAddress("0xD"), // Token D (e.g., WBTC)
-		5: common.HexToAddress("0xE"), // Token E (Isolated)
+		1: common.HexToAddress("0x1"),
+		2: common.HexToAddress("0x2"),
+		3: common.HexToAddress("0x3"),
 	}
 	pools := map[uint64]common.Address{
-		101: common.HexToAddress("0x101"), // A -> B (High Liquidity)
-		102: common.HexToAddress("0x102"), // B -> D (High Liquidity)
-		103: common.HexToAddress("0x103"), // A -> D (Direct, but Low Liquidity)
-		104: common.HexToAddress("0x104"), // A -> C (Low Liquidity)
-		105: common.HexToAddress("0x105"), // C -> D (Low Liquidity)
+		101: common.HexToAddress("0x101"),
+		102: common.HexToAddress("0x102"),
+		103: common.HexToAddress("0x103"),
+		104: common.HexToAddress("0x104"),
+	}
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: big.NewInt(100), Reserve1: big.NewInt(400), FeeBps: 30},    // depth 200
+		{ID: 102, Token0: 1, Token1: 3, Reserve0: big.NewInt(10_000), Reserve1: big.NewInt(100), FeeBps: 30}, // depth 1000
+		{ID: 103, Token0: 2, Token1: 3, Reserve0: big.NewInt(50), Reserve1: big.NewInt(50), FeeBps: 30},      // depth 50
 	}
+	uniswapV3Pools := []uniswapv3.Pool{setupUniswapV3ETHUSDCPool(1, 2, 104)}
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, uniswapV3Pools)
+	protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}, poolRegistry)
+	graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{}, protocolResolver)
+	require.NoError(t, err)
 
-	// Decimals for convenience
-	d18 := new(big.Int).SetUint64(1e18)
-	d6 := new(big.Int).SetUint64(1e6)
-	d8 := new(big.Int).SetUint64(1e8)
+	t.Run("Orders by depth and limits to N", func(t *testing.T) {
+		top, err := graph.TopPoolsBySchema(uniswapv2.Schema, 2)
+		require.NoError(t, err)
+		require.Len(t, top, 2)
+		assert.Equal(t, uint64(102), top[0].PoolID)
+		assert.Equal(t, big.NewInt(1000), top[0].Depth)
+		assert.Equal(t, uint64(101), top[1].PoolID)
+		assert.Equal(t, big.NewInt(200), top[1].Depth)
+	})
 
-	uniswapV2Pools := []uniswapv2.Pool{
-		// Optimal Path (High Liquidity)
-		{ID: 101, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(10000), d18), Reserve1: new(big.Int).Mul(big.NewInt(40000000), d6), FeeBps: 30}, // A/B
-		{ID: 102, Token0: 2, Token1: 4, Reserve0: new(big.Int).Mul(big.NewInt(40000000), d6), Reserve1: new(big.Int).Mul(big.NewInt(1000), d8), FeeBps: 30},   // B/D
+	t.Run("N larger than the pool count returns every pool", func(t *testing.T) {
+		top, err := graph.TopPoolsBySchema(uniswapv2.Schema, 10)
+		require.NoError(t, err)
+		require.Len(t, top, 3)
+		assert.Equal(t, uint64(103), top[2].PoolID)
+	})
 
-		// Suboptimal Direct Path (Low Liquidity)
-		{ID: 103, Token0: 1, Token1: 4, Reserve0: new(big.Int).Mul(big.NewInt(10), d18), Reserve1: new(big.Int).Mul(big.NewInt(1), d8), FeeBps: 30}, // A/D
+	t.Run("Only pools of the requested schema are returned", func(t *testing.T) {
+		top, err := graph.TopPoolsBySchema(uniswapv3.Schema, 10)
+		require.NoError(t, err)
+		require.Len(t, top, 1)
+		assert.Equal(t, uint64(104), top[0].PoolID)
+		assert.Positive(t, top[0].Depth.Sign())
+	})
 
-		// Suboptimal Indirect Path (Low Liquidity)
-		{ID: 104, Token0: 1, Token1: 3, Reserve0: new(big.Int).Mul(big.NewInt(10), d18), Reserve1: new(big.Int).Mul(big.NewInt(40000), d18), FeeBps: 30}, // A/C
-		{ID: 105, Token0: 3, Token1: 4, Reserve0: new(big.Int).Mul(big.NewInt(40000), d18), Reserve1: new(big.Int).Mul(big.NewInt(1), d8), FeeBps: 30},   // C/D
-	}
+	t.Run("N must be positive", func(t *testing.T) {
+		_, err := graph.TopPoolsBySchema(uniswapv2.Schema, 0)
+		assert.Error(t, err)
+	})
 
-	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+	t.Run("Reserves are scaled to 18 decimals", func(t *testing.T) {
+		// In raw units the 3 DAI pool looks a thousand times deeper than the 3,000 USDC
+		// pool, because USDC has 6 decimals.
+		uniswapV2Pools := []uniswapv2.Pool{
+			{ID: 101, Token0: 1, Token1: 2, Reserve0: big.NewInt(1e18), Reserve1: big.NewInt(3000e6), FeeBps: 30},
+			{ID: 102, Token0: 1, Token1: 3, Reserve0: big.NewInt(1e15), Reserve1: big.NewInt(3e18), FeeBps: 30},
+		}
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, nil)
+		tokenRegistry := tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+			{ID: 1, Symbol: "WETH", Decimals: 18},
+			{ID: 2, Symbol: "USDC", Decimals: 6},
+			{ID: 3, Symbol: "DAI", Decimals: 18},
+		})
+		graph, err := NewGraph(rawGraph, tokenRegistry, poolRegistry, v2View, v3View, map[uint64]struct{}{}, chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+		}, poolRegistry))
+		require.NoError(t, err)
 
-	protocolIDToProtocolSchema := map[engine.ProtocolID]engine.ProtocolSchema{
+		top, err := graph.TopPoolsBySchema(uniswapv2.Schema, 2)
+		require.NoError(t, err)
+		depth101, _ := new(big.Int).SetString("54772255750516611345", 10) // sqrt(1e18 * 3000e18)
+		assert.Equal(t, []chains.PoolDepth{
+			{PoolID: 101, Depth: depth101},
+			{PoolID: 102, Depth: big.NewInt(54772255750516611)}, // sqrt(1e15 * 3e18)
+		}, top)
+	})
+}
+
+func TestMinRouteDepth(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0x1"), // A
+		2: common.HexToAddress("0x2"), // B, 3,000 per A
+		3: common.HexToAddress("0x3"), // C, 3,000 per A
+		4: common.HexToAddress("0x4"), // D, 1 per A
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"),
+		102: common.HexToAddress("0x102"),
+		103: common.HexToAddress("0x103"),
+		104: common.HexToAddress("0x104"),
+	}
+	whole := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), d18) }
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: whole(1_000), Reserve1: whole(3_000_000), FeeBps: 30},     // deep
+		{ID: 102, Token0: 2, Token1: 3, Reserve0: whole(30_000), Reserve1: whole(30_000), FeeBps: 30},       // shallow: 10 A of B
+		{ID: 103, Token0: 3, Token1: 4, Reserve0: whole(3_000_000), Reserve1: whole(1_000), FeeBps: 30},     // deep
+		{ID: 104, Token0: 2, Token1: 3, Reserve0: whole(1_500_000), Reserve1: whole(1_500_000), FeeBps: 30}, // deep alternative to 102
+	}
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+	protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
 		uniswapV2ProtocolID: uniswapv2.Schema,
 		uniswapV3ProtocolID: uniswapv3.Schema,
+	}, poolRegistry)
+	graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{101: {}, 102: {}, 103: {}, 104: {}}, protocolResolver)
+	require.NoError(t, err)
+
+	route := func(middlePool uint64) []chains.TokenPoolPath {
+		return []chains.TokenPoolPath{
+			{TokenInID: 1, TokenOutID: 2, PoolID: 101},
+			{TokenInID: 2, TokenOutID: 3, PoolID: middlePool},
+			{TokenInID: 3, TokenOutID: 4, PoolID: 103},
+		}
+	}
+	assertDepth := func(t *testing.T, expectedWholeA float64, depth *big.Int) {
+		f, _ := new(big.Float).SetInt(depth).Float64()
+		assert.InEpsilon(t, expectedWholeA, f/1e18, 1e-9)
 	}
 
-	protocolResolver := chains.NewProtocolResolver(
-		protocolIDToProtocolSchema,
-		poolRegistry,
-	)
+	t.Run("Identifies the shallow hop", func(t *testing.T) {
+		depth, err := graph.MinRouteDepth(route(102))
+		require.NoError(t, err)
+		assert.Equal(t, 1, depth.HopIndex)
+		assert.Equal(t, uint64(102), depth.PoolID)
+		assertDepth(t, 10, depth.Depth)
+
+		// The other hops are 100 times deeper, in units of A.
+		require.Len(t, depth.HopDepths, 3)
+		assertDepth(t, 1_000, depth.HopDepths[0])
+		assertDepth(t, 10, depth.HopDepths[1])
+		assertDepth(t, 1_000, depth.HopDepths[2])
+	})
 
-	graph, err := NewGraph(
-		rawGraph,
-		poolRegistry,
-		v2View,
-		v3View,
-		activePools,
-		protocolResolver,
-	)
-	require.NoError(t, err)
-	return graph
+	t.Run("A deeper middle pool lifts the minimum", func(t *testing.T) {
+		depth, err := graph.MinRouteDepth(route(104))
+		require.NoError(t, err)
+		assert.Equal(t, 1, depth.HopIndex)
+		assert.Equal(t, uint64(104), depth.PoolID)
+		assertDepth(t, 500, depth.Depth)
+	})
+
+	t.Run("Invalid routes", func(t *testing.T) {
+		_, err := graph.MinRouteDepth(nil)
+		assert.Error(t, err)
+
+		broken := route(102)
+		broken[1].TokenInID = 4
+		_, err = graph.MinRouteDepth(broken)
+		assert.Error(t, err)
+
+		_, err = graph.MinRouteDepth([]chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 3, PoolID: 101}})
+		assert.Error(t, err, "pool does not hold the hop's tokens")
+	})
 }
 
-func TestFindBestSwapPath(t *testing.T) {
-	startAmount := new(big.Int).SetUint64(1e18) // Represents 1 token A
+func TestConsistencyCheck(t *testing.T) {
+	decimals := func(d uint8) *uint8 { return &d }
 
-	// Define the set of all pools to be used as the default "active" set.
-	allPoolsActive := map[uint64]struct{}{
-		101: {}, 102: {}, 103: {}, 104: {}, 105: {},
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0x1"), // WETH
+		2: common.HexToAddress("0x2"), // USDC
+		3: common.HexToAddress("0x3"), // DAI
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"),
+		102: common.HexToAddress("0x102"),
+		103: common.HexToAddress("0x103"),
 	}
+	uniswapV2Pools := []uniswapv2.Pool{
+		// Consistent with the registry.
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: big.NewInt(1), Reserve1: big.NewInt(1), FeeBps: 30, Decimals0: decimals(18), Decimals1: decimals(6)},
+		// No assumed decimals: not checked.
+		{ID: 103, Token0: 2, Token1: 3, Reserve0: big.NewInt(1), Reserve1: big.NewInt(1), FeeBps: 30},
+	}
+	mismatched := setupUniswapV3ETHUSDCPool(1, 2, 102)
+	// USDC was deliberately indexed as an 18-decimal token.
+	mismatched.Decimals0, mismatched.Decimals1 = decimals(18), decimals(18)
+	uniswapV3Pools := []uniswapv3.Pool{mismatched}
 
-	t.Run("Finds best multi-hop path over direct path", func(t *testing.T) {
-		// This test ensures the algorithm correctly prioritizes the higher liquidity
-		// multi-hop path (A->B->D) over the direct but low-liquidity path (A->D).
-		graph := setupSwapPathTestGraph(t, allPoolsActive)
-		params := chains.SwapFindingParams{
-			TokenInID:  1,
-			TokenOutID: 4,
-			AmountIn:   startAmount,
-			Runs:       3,
-		}
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, uniswapV3Pools)
+	protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}, poolRegistry)
+	tokenRegistry := tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+		{ID: 1, Symbol: "WETH", Decimals: 18},
+		{ID: 2, Symbol: "USDC", Decimals: 6},
+		{ID: 3, Symbol: "DAI", Decimals: 18},
+	})
 
-		path, amountOut, err := graph.FindBestSwapPath(params)
+	t.Run("Flags the mismatched pool and token", func(t *testing.T) {
+		graph, err := NewGraph(rawGraph, tokenRegistry, poolRegistry, v2View, v3View, map[uint64]struct{}{}, protocolResolver)
+		require.NoError(t, err)
+		assert.Equal(t, []chains.DecimalsMismatch{
+			{PoolID: 102, TokenID: 2, PoolDecimals: 18, RegistryDecimals: 6},
+		}, graph.ConsistencyCheck())
+	})
 
+	t.Run("Nothing to check without token metadata", func(t *testing.T) {
+		graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{}, protocolResolver)
 		require.NoError(t, err)
-		require.NotNil(t, path)
-		require.NotNil(t, amountOut)
-		require.Len(t, path, 2)
+		assert.Empty(t, graph.ConsistencyCheck())
+	})
+}
 
-		expectedPath := []chains.TokenPoolPath{
-			{TokenInID: 1, TokenOutID: 2, PoolID: 101},
-			{TokenInID: 2, TokenOutID: 4, PoolID: 102},
-		}
+func TestFindBestSwapPathTimeout(t *testing.T) {
+	graph := setupUniswapV2BenchmarkGraph(t, 2000, 6000)
+	params := chains.SwapFindingParams{
+		TokenInID:  0,
+		TokenOutID: 1999,
+		AmountIn:   new(big.Int).SetUint64(1e18),
+		Runs:       50,
+	}
 
-		assert.Equal(t, expectedPath, path)
-		assert.True(t, amountOut.Sign() > 0, "Amount out should be positive")
-	})
+	t.Run("Tiny timeout returns promptly", func(t *testing.T) {
+		params := params
+		params.Timeout = time.Millisecond
 
-	t.Run("Finds path with V2 override", func(t *testing.T) {
-		// We override the best path (A->B pool 101) to have terrible liquidity.
-		// The algorithm should now choose the direct A->D path (pool 103) as the best option.
-		graph := setupSwapPathTestGraph(t, allPoolsActive)
-		originalPool, _ := graph.indexedUniswapV2.GetByID(101)
-		overriddenPool := originalPool // copy
-		overriddenPool.Reserve0 = big.NewInt(1)
-		overriddenPool.Reserve1 = big.NewInt(1)
+		start := time.Now()
+		path, amountOut, err := graph.FindBestSwapPath(params)
+		elapsed := time.Since(start)
 
-		params := chains.SwapFindingParams{
-			TokenInID:  1,
-			TokenOutID: 4,
-			AmountIn:   startAmount,
-			Runs:       3,
-			UniswapV2Overrides: map[uint64]uniswapv2.Pool{
-				101: overriddenPool,
-			},
+		require.ErrorIs(t, err, chains.ErrRouteTimeout)
+		assert.Less(t, elapsed, 500*time.Millisecond, "the search should stop soon after the deadline")
+		if path != nil {
+			assert.Positive(t, amountOut.Sign(), "a partial route carries its output")
 		}
+	})
 
-		path, amountOut, err := graph.FindBestSwapPath(params)
+	t.Run("Generous timeout completes the search", func(t *testing.T) {
+		params := params
+		params.Runs = 3
+		params.Timeout = time.Minute
 
+		path, _, err := graph.FindBestSwapPath(params)
 		require.NoError(t, err)
-		require.NotNil(t, path)
-		require.NotNil(t, amountOut)
-		require.Len(t, path, 1)
+		assert.NotEmpty(t, path)
+	})
+}
 
-		expectedPath := []chains.TokenPoolPath{
-			{TokenInID: 1, TokenOutID: 4, PoolID: 103},
-		}
-		assert.Equal(t, expectedPath, path)
+func TestApplyDiff(t *testing.T) {
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0x1"),
+		2: common.HexToAddress("0x2"),
+		3: common.HexToAddress("0x3"),
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"),
+		102: common.HexToAddress("0x102"),
+	}
+	pool101 := uniswapv2.Pool{ID: 101, Token0: 1, Token1: 2, Reserve0: big.NewInt(1_000_000), Reserve1: big.NewInt(2_000_000), FeeBps: 30}
+	pool102 := uniswapv2.Pool{ID: 102, Token0: 2, Token1: 3, Reserve0: big.NewInt(1_000_000), Reserve1: big.NewInt(3_000_000), FeeBps: 30}
+	schemas := map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}
+
+	// The old snapshot only knows pool 101, so token 3 is not in the graph.
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, []uniswapv2.Pool{pool101}, []uniswapv3.Pool{})
+	graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{101: {}}, chains.NewProtocolResolver(schemas, poolRegistry))
+	require.NoError(t, err)
+
+	params := chains.SwapFindingParams{AmountIn: big.NewInt(10_000), TokenInID: 1, TokenOutID: 3, Runs: 3}
+	_, _, err = graph.FindBestSwapPath(params)
+	require.Error(t, err)
+
+	// The patched snapshot adds pool 102, which connects token 2 to token 3.
+	newRawGraph, newPoolRegistry, newV2View, newV3View := NewMockGraphRequirements(t, tokens, pools, []uniswapv2.Pool{pool101, pool102}, []uniswapv3.Pool{})
+	next, err := graph.ApplyDiff(chains.GraphDiff{
+		TokenPool:           newRawGraph,
+		IndexedPoolRegistry: newPoolRegistry,
+		IndexedUniswapV2:    newV2View,
+		IndexedUniswapV3:    newV3View,
+		ProtocolResolver:    chains.NewProtocolResolver(schemas, newPoolRegistry),
+		ChangedPools:        []uint64{102},
 	})
+	require.NoError(t, err)
 
-	t.Run("Finds best path using only active pools", func(t *testing.T) {
-		// Define an active set that EXCLUDES pool 101, which is part of the best multi-hop path.
-		activePools := map[uint64]struct{}{
-			102: {}, // B -> D (High Liquidity)
-			103: {}, // A -> D (Direct, Low Liquidity)
-			104: {}, // A -> C (Low Liquidity)
-			105: {}, // C -> D (Low Liquidity)
-		}
-		// Create a new graph with this specific active pool set.
-		graphWithInactivePool := setupSwapPathTestGraph(t, activePools)
+	path, amountOut, err := next.FindBestSwapPath(params)
+	require.NoError(t, err)
+	require.Len(t, path, 2)
+	assert.Equal(t, uint64(101), path[0].PoolID)
+	assert.Equal(t, uint64(102), path[1].PoolID)
+	assert.Positive(t, amountOut.Sign())
 
-		params := chains.SwapFindingParams{
-			TokenInID:  1,
-			TokenOutID: 4,
-			AmountIn:   startAmount,
-			Runs:       3,
-		}
+	report := next.BuildReport()
+	assert.Equal(t, 2, report.TotalPools)
+	assert.Equal(t, 2, report.RoutablePools)
 
-		path, amountOut, err := graphWithInactivePool.FindBestSwapPath(params)
+	// The new pool is wired for the active (cached) search as well.
+	rates, err := next.GetExchangeRates(big.NewInt(10_000), 1, 3, nil)
+	require.NoError(t, err)
+	assert.Contains(t, rates, uint64(3))
 
-		require.NoError(t, err)
-		require.NotNil(t, path)
-		require.NotNil(t, amountOut)
+	// The receiver still serves the old snapshot.
+	_, _, err = graph.FindBestSwapPath(params)
+	assert.Error(t, err)
 
-		// The algorithm should now choose the direct, suboptimal path (A->D)
-		// because the better A->B->D path is broken by the inactive pool 101.
-		require.Len(t, path, 1)
+	t.Run("same tokens and pools rewire only the changed pools", func(t *testing.T) {
+		fullRawGraph, fullPoolRegistry, fullV2View, fullV3View := NewMockGraphRequirements(t, tokens, pools, []uniswapv2.Pool{pool101, pool102}, []uniswapv3.Pool{})
+		full, err := NewGraph(fullRawGraph, nil, fullPoolRegistry, fullV2View, fullV3View, nil, chains.NewProtocolResolver(schemas, fullPoolRegistry))
+		require.NoError(t, err)
 
-		expectedPath := []chains.TokenPoolPath{
-			{TokenInID: 1, TokenOutID: 4, PoolID: 103},
-		}
-		assert.Equal(t, expectedPath, path)
-	})
+		// Both pools move in the patched snapshot, but only pool 102 is listed as changed.
+		moved101, moved102 := pool101, pool102
+		moved101.Reserve1 = big.NewInt(4_000_000)
+		moved102.Reserve1 = big.NewInt(6_000_000)
+		patchedRawGraph, patchedPoolRegistry, patchedV2View, patchedV3View := NewMockGraphRequirements(t, tokens, pools, []uniswapv2.Pool{moved101, moved102}, []uniswapv3.Pool{})
+		next, err := full.ApplyDiff(chains.GraphDiff{
+			TokenPool:           patchedRawGraph,
+			IndexedPoolRegistry: patchedPoolRegistry,
+			IndexedUniswapV2:    patchedV2View,
+			IndexedUniswapV3:    patchedV3View,
+			ProtocolResolver:    chains.NewProtocolResolver(schemas, patchedPoolRegistry),
+			ChangedPools:        []uint64{102},
+		})
+		require.NoError(t, err)
 
-	t.Run("No path exists to isolated token", func(t *testing.T) {
-		// Token 5 exists in the graph but has no connecting pools.
-		graph := setupSwapPathTestGraph(t, allPoolsActive)
-		params := chains.SwapFindingParams{
-			TokenInID:  1,
-			TokenOutID: 5,
-			AmountIn:   startAmount,
-			Runs:       3,
+		quote := func(graph chains.TokenPoolGraph, hop chains.TokenPoolPath) *big.Int {
+			t.Helper()
+			q, err := graph.QuoteRoute([]chains.TokenPoolPath{hop}, big.NewInt(10_000))
+			require.NoError(t, err)
+			return q.AmountOut
 		}
+		hop101 := chains.TokenPoolPath{TokenInID: 1, TokenOutID: 2, PoolID: 101}
+		hop102 := chains.TokenPoolPath{TokenInID: 2, TokenOutID: 3, PoolID: 102}
+		assert.Equal(t, quote(full, hop101), quote(next, hop101), "an unlisted pool keeps its functions")
+		assert.Equal(t, 1, quote(next, hop102).Cmp(quote(full, hop102)), "a listed pool is quoted on its new reserves")
+		assert.Equal(t, 2, next.BuildReport().RoutablePools)
 
-		path, amountOut, err := graph.FindBestSwapPath(params)
-
-		require.Error(t, err)
-		assert.Nil(t, path, "Path should be nil when no route exists")
-		assert.Nil(t, amountOut, "Amount out should be nil when no route exists")
+		top, err := next.TopPoolsBySchema(uniswapv2.Schema, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []chains.PoolDepth{
+			{PoolID: 102, Depth: big.NewInt(2_449_489)}, // sqrt(1e6 * 6e6), measured again
+			{PoolID: 101, Depth: big.NewInt(1_414_213)}, // sqrt(1e6 * 2e6), carried over
+		}, top)
 	})
 
-	t.Run("Start token does not exist", func(t *testing.T) {
-		// Token 999 is not in the graph's token registry.
-		graph := setupSwapPathTestGraph(t, allPoolsActive)
-		params := chains.SwapFindingParams{
-			TokenInID:  999,
-			TokenOutID: 4,
-			AmountIn:   startAmount,
-			Runs:       3,
+	t.Run("changed tokens rewire their pools", func(t *testing.T) {
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, []uniswapv2.Pool{pool101, pool102}, []uniswapv3.Pool{})
+		resolver := chains.NewProtocolResolver(schemas, poolRegistry)
+		grapher, err := NewGrapher()
+		require.NoError(t, err)
+		// Token 3 is missing from the registry, so pool 102 is skipped.
+		known := []tokenregistry.Token{{ID: 1, Symbol: "A", Decimals: 18}, {ID: 2, Symbol: "B", Decimals: 18}}
+		graph, err := grapher.Graph(rawGraph, tokenregistryindexer.NewIndexableTokenSystem(known), poolRegistry, v2View, v3View, nil, resolver)
+		require.NoError(t, err)
+		require.Equal(t, []chains.SkippedPool{{PoolID: 102, Reason: chains.SkipReasonUnknownToken}}, graph.BuildReport().Skipped)
+
+		diff := chains.GraphDiff{
+			TokenPool:            rawGraph,
+			IndexedTokenRegistry: tokenregistryindexer.NewIndexableTokenSystem(append(known, tokenregistry.Token{ID: 3, Symbol: "C", Decimals: 18})),
+			IndexedPoolRegistry:  poolRegistry,
+			IndexedUniswapV2:     v2View,
+			IndexedUniswapV3:     v3View,
+			ProtocolResolver:     resolver,
 		}
-		_, _, err := graph.FindBestSwapPath(params)
-		require.Error(t, err)
-		assert.Contains(t, err.Error(), "start token 999 not found in the graph")
+		next, err := graph.ApplyDiff(diff)
+		require.NoError(t, err)
+		assert.Equal(t, 1, next.BuildReport().RoutablePools, "an unlisted token leaves its pools alone")
+
+		diff.ChangedTokens = []uint64{3}
+		next, err = graph.ApplyDiff(diff)
+		require.NoError(t, err)
+		report := next.BuildReport()
+		assert.Equal(t, 2, report.RoutablePools)
+		assert.Empty(t, report.Skipped)
+		assert.Empty(t, report.UnknownTokenPools)
+
+		// Deleting the token again skips its pool.
+		diff.IndexedTokenRegistry = tokenregistryindexer.NewIndexableTokenSystem(known)
+		again, err := next.ApplyDiff(diff)
+		require.NoError(t, err)
+		assert.Equal(t, []chains.SkippedPool{{PoolID: 102, Reason: chains.SkipReasonUnknownToken}}, again.BuildReport().Skipped)
+		assert.Equal(t, []uint64{102}, again.BuildReport().UnknownTokenPools)
 	})
 
-	t.Run("End token does not exist", func(t *testing.T) {
-		// Token 999 is not in the graph's token registry.
-		graph := setupSwapPathTestGraph(t, allPoolsActive)
-		params := chains.SwapFindingParams{
-			TokenInID:  1,
-			TokenOutID: 999,
-			AmountIn:   startAmount,
-			Runs:       3,
-		}
-		_, _, err := graph.FindBestSwapPath(params)
-		require.Error(t, err)
-		assert.Contains(t, err.Error(), "end token 999 not found in the graph")
+	t.Run("missing views", func(t *testing.T) {
+		_, err := graph.ApplyDiff(chains.GraphDiff{})
+		assert.Error(t, err)
 	})
 }
 
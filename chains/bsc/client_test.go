@@ -0,0 +1,766 @@
+package bsc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/defistate/defistate-client-go/chains"
+	"github.com/defistate/defistate-client-go/differ"
+	"github.com/defistate/defistate-client-go/engine"
+	poolregistry "github.com/defistate/defistate-client-go/protocols/poolregistry"
+	poolregistryindexer "github.com/defistate/defistate-client-go/protocols/poolregistry/indexer"
+	"github.com/defistate/defistate-client-go/protocols/solidly"
+	solidlyindexer "github.com/defistate/defistate-client-go/protocols/solidly/indexer"
+	tokenpoolregistry "github.com/defistate/defistate-client-go/protocols/tokenpoolregistry"
+	tokenregistry "github.com/defistate/defistate-client-go/protocols/tokenregistry"
+	tokenregistryindexer "github.com/defistate/defistate-client-go/protocols/tokenregistry/indexer"
+	uniswapv2 "github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	uniswapv2indexer "github.com/defistate/defistate-client-go/protocols/uniswapv2/indexer"
+	uniswapv3 "github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	uniswapv3indexer "github.com/defistate/defistate-client-go/protocols/uniswapv3/indexer"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// --- Mocks ---
+
+// mockTransport simulates the low-level chains.Client
+type mockTransport struct {
+	stateCh chan *engine.State
+	errCh   chan error
+}
+
+func newMockTransport() *mockTransport {
+	return &mockTransport{
+		stateCh: make(chan *engine.State, 10),
+		errCh:   make(chan error, 10),
+	}
+}
+func (m *mockTransport) State() <-chan *engine.State { return m.stateCh }
+func (m *mockTransport) Err() <-chan error           { return m.errCh }
+
+// --- Indexer Mocks ---
+
+type mockTokenIndexer struct{ called bool }
+
+func (m *mockTokenIndexer) Index(tokens []tokenregistry.Token) tokenregistryindexer.IndexedTokenSystem {
+	m.called = true
+	return &mockIndexedTokenSystem{}
+}
+
+type mockPoolRegistryIndexer struct{ called bool }
+
+func (m *mockPoolRegistryIndexer) Index(pr poolregistry.PoolRegistry) poolregistryindexer.IndexedPoolRegistry {
+	m.called = true
+	return &mockIndexedPoolRegistry{}
+}
+
+type mockUniswapV2Indexer struct{ called bool }
+
+func (m *mockUniswapV2Indexer) Index(pools []uniswapv2.Pool) uniswapv2indexer.IndexedUniswapV2 {
+	m.called = true
+	return &mockIndexedUniswapV2{}
+}
+
+type mockUniswapV3Indexer struct{ called bool }
+
+func (m *mockUniswapV3Indexer) Index(pools []uniswapv3.Pool) uniswapv3indexer.IndexedUniswapV3 {
+	m.called = true
+	return &mockIndexedUniswapV3{}
+}
+
+type mockSolidlyIndexer struct{ called bool }
+
+func (m *mockSolidlyIndexer) Index(pools []solidly.Pool) solidlyindexer.IndexedSolidly {
+	m.called = true
+	return solidlyindexer.NewIndexableSolidlySystem(pools)
+}
+
+// --- Grapher Mock ---
+
+type mockGrapher struct{ called bool }
+
+func (m *mockGrapher) Graph(
+	tp *tokenpoolregistry.TokenPoolRegistryView,
+	tr tokenregistryindexer.IndexedTokenSystem,
+	pr poolregistryindexer.IndexedPoolRegistry,
+	v2 uniswapv2indexer.IndexedUniswapV2,
+	v3 uniswapv3indexer.IndexedUniswapV3,
+	sol solidlyindexer.IndexedSolidly,
+	resolver *chains.ProtocolResolver,
+) (chains.TokenPoolGraph, error) {
+	m.called = true
+	return &mockTokenPoolGraph{}, nil
+}
+
+type mockIndexedTokenSystem struct {
+	tokenregistryindexer.IndexedTokenSystem
+}
+type mockIndexedPoolRegistry struct {
+	poolregistryindexer.IndexedPoolRegistry
+}
+
+// Helper to satisfy the ProtocolResolver requirement
+func (m *mockIndexedPoolRegistry) GetByID(id uint64) (poolregistry.Pool, bool) {
+	return poolregistry.Pool{}, false
+}
+func (m *mockIndexedPoolRegistry) GetProtocols() map[uint16]engine.ProtocolID {
+	return map[uint16]engine.ProtocolID{}
+}
+
+type mockIndexedUniswapV2 struct {
+	uniswapv2indexer.IndexedUniswapV2
+}
+type mockIndexedUniswapV3 struct {
+	uniswapv3indexer.IndexedUniswapV3
+}
+type mockTokenPoolGraph struct{ chains.TokenPoolGraph }
+
+// --- Test Suite ---
+
+func TestClient_Lifecycle(t *testing.T) {
+	// Setup Mocks
+	transport := newMockTransport()
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	// Create Client manually to bypass Dial's real network connection
+	c := &Client{
+		stream:              transport,
+		logger:              logger,
+		stateCh:             make(chan *State, 10),
+		errCh:               make(chan error, 10),
+		tokenIndexer:        &mockTokenIndexer{},
+		poolRegistryIndexer: &mockPoolRegistryIndexer{},
+		uniswapV2Indexer:    &mockUniswapV2Indexer{},
+		uniswapV3Indexer:    &mockUniswapV3Indexer{},
+		solidlyIndexer:      &mockSolidlyIndexer{},
+		tokenPoolGrapher:    &mockGrapher{},
+	}
+
+	// Manual Context Setup (Simulating Dial)
+	ctx, cancel := context.WithCancel(context.Background())
+	c.ctx = ctx
+	c.wg.Add(1)
+
+	// Start Loop
+	go c.loop()
+
+	// 1. Send Data
+	rawState := &engine.State{
+		Block: engine.BlockSummary{Number: big.NewInt(100)},
+		Protocols: map[engine.ProtocolID]engine.ProtocolState{
+			"tokens":   {Schema: tokenregistry.Schema, Data: []tokenregistry.Token{}},
+			"registry": {Schema: poolregistry.Schema, Data: poolregistry.PoolRegistry{}},
+			"graph":    {Schema: tokenpoolregistry.Schema, Data: &tokenpoolregistry.TokenPoolRegistryView{}},
+			"univ2":    {Schema: uniswapv2.Schema, Data: []uniswapv2.Pool{}},
+			"univ3":    {Schema: uniswapv3.Schema, Data: []uniswapv3.Pool{}},
+		},
+	}
+
+	transport.stateCh <- rawState
+
+	// 2. Expect Result
+	select {
+	case processed := <-c.State():
+		assert.Equal(t, int64(100), processed.Block.Number.Int64())
+		assert.NotNil(t, processed.Graph)
+		assert.NotNil(t, processed.ProtocolResolver)
+
+		// Verify Mocks were called
+		assert.True(t, c.tokenIndexer.(*mockTokenIndexer).called)
+		assert.True(t, c.poolRegistryIndexer.(*mockPoolRegistryIndexer).called)
+		assert.True(t, c.tokenPoolGrapher.(*mockGrapher).called)
+
+	case <-time.After(1 * time.Second):
+		t.Fatal("timed out waiting for processed state")
+	}
+
+	// 3. Test Shutdown (Cancel Context)
+	cancel()
+
+	// Channels should be closed by the loop
+	select {
+	case _, ok := <-c.State():
+		assert.False(t, ok, "State channel should be closed")
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("channels did not close")
+	}
+}
+
+func TestClient_ErrorHandling(t *testing.T) {
+	transport := newMockTransport()
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	c := &Client{
+		stream:  transport,
+		logger:  logger,
+		stateCh: make(chan *State, 1),
+		errCh:   make(chan error, 1),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.ctx = ctx
+	c.wg.Add(1)
+	go c.loop()
+	defer cancel()
+
+	// 1. Send Fatal Error
+	expectedErr := fmt.Errorf("websocket disconnect")
+	transport.errCh <- expectedErr
+
+	select {
+	case err := <-c.Err():
+		assert.Equal(t, expectedErr, err)
+	case <-time.After(1 * time.Second):
+		t.Fatal("timed out waiting for error")
+	}
+}
+
+func TestClient_MissingDataValidation(t *testing.T) {
+	transport := newMockTransport()
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	c := &Client{
+		stream:  transport,
+		logger:  logger,
+		stateCh: make(chan *State, 1),
+		errCh:   make(chan error, 1),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.ctx = ctx
+	c.wg.Add(1)
+	go c.loop()
+	defer cancel()
+
+	// 1. Send Incomplete Data (Missing Graph)
+	incompleteState := &engine.State{
+		Block: engine.BlockSummary{Number: big.NewInt(101)},
+		Protocols: map[engine.ProtocolID]engine.ProtocolState{
+			"tokens": {Schema: tokenregistry.Schema, Data: []tokenregistry.Token{}},
+			// Missing other required protocols
+		},
+	}
+
+	transport.stateCh <- incompleteState
+
+	// 2. Ensure it didn't crash and didn't produce state
+	select {
+	case <-c.State():
+		t.Fatal("Should not produce state for incomplete data")
+	case <-time.After(100 * time.Millisecond):
+		// This is expected behavior; the loop logs an error and continues
+	}
+}
+
+func TestClient_MissingTokenRegistryDegrades(t *testing.T) {
+	transport := newMockTransport()
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	grapher := &mockGrapher{}
+	c := &Client{
+		stream:              transport,
+		logger:              logger,
+		stateCh:             make(chan *State, 1),
+		errCh:               make(chan error, 1),
+		tokenIndexer:        &mockTokenIndexer{},
+		poolRegistryIndexer: &mockPoolRegistryIndexer{},
+		uniswapV2Indexer:    &mockUniswapV2Indexer{},
+		uniswapV3Indexer:    &mockUniswapV3Indexer{},
+		solidlyIndexer:      &mockSolidlyIndexer{},
+		tokenPoolGrapher:    grapher,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.ctx = ctx
+	c.wg.Add(1)
+	go c.loop()
+	defer cancel()
+
+	// Send a state without the token system.
+	transport.stateCh <- &engine.State{
+		Block: engine.BlockSummary{Number: big.NewInt(102)},
+		Protocols: map[engine.ProtocolID]engine.ProtocolState{
+			"registry": {Schema: poolregistry.Schema, Data: poolregistry.PoolRegistry{}},
+			"graph":    {Schema: tokenpoolregistry.Schema, Data: &tokenpoolregistry.TokenPoolRegistryView{}},
+		},
+	}
+
+	select {
+	case processed := <-c.State():
+		assert.Equal(t, int64(102), processed.Block.Number.Int64())
+		assert.NotNil(t, processed.Graph)
+		assert.Nil(t, processed.IndexedTokenSystem)
+		assert.False(t, c.tokenIndexer.(*mockTokenIndexer).called, "token indexer should not run without token data")
+		assert.True(t, grapher.called)
+	case <-time.After(1 * time.Second):
+		t.Fatal("state without token metadata should still be processed")
+	}
+}
+
+func TestClient_Backpressure(t *testing.T) {
+	// Test the "Warn-Then-Drop" behavior
+	transport := newMockTransport()
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	// Buffer size 0 to force immediate backpressure logic if no consumer
+	c := &Client{
+		stream:              transport,
+		logger:              logger,
+		stateCh:             make(chan *State), // Unbuffered!
+		errCh:               make(chan error, 1),
+		tokenIndexer:        &mockTokenIndexer{},
+		poolRegistryIndexer: &mockPoolRegistryIndexer{},
+		uniswapV2Indexer:    &mockUniswapV2Indexer{},
+		uniswapV3Indexer:    &mockUniswapV3Indexer{},
+		solidlyIndexer:      &mockSolidlyIndexer{},
+		tokenPoolGrapher:    &mockGrapher{},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.ctx = ctx
+	c.wg.Add(1)
+	go c.loop()
+	defer cancel()
+
+	rawState := &engine.State{
+		Block: engine.BlockSummary{Number: big.NewInt(200)},
+		Protocols: map[engine.ProtocolID]engine.ProtocolState{
+			"tokens":   {Schema: tokenregistry.Schema, Data: []tokenregistry.Token{}},
+			"registry": {Schema: poolregistry.Schema, Data: poolregistry.PoolRegistry{}},
+			"graph":    {Schema: tokenpoolregistry.Schema, Data: &tokenpoolregistry.TokenPoolRegistryView{}},
+		},
+	}
+
+	// 1. Send data while NO ONE is listening on c.State()
+	transport.stateCh <- rawState
+
+	// 2. Wait a moment. The loop should have tried to send, hit 'default', logged warning, and continued.
+	time.Sleep(50 * time.Millisecond)
+
+	// 3. Now start listening. We expect the PREVIOUS item might have been dropped
+	// (depending on your loop implementation: default case = drop).
+	// If you want to verify it was dropped:
+	select {
+	case <-c.State():
+		t.Fatal("Expected state to be dropped due to backpressure")
+	default:
+		// Success: nothing in channel
+	}
+}
+
+// warnRecorder is a chains.Logger that keeps the arguments of every Warn call.
+type warnRecorder struct {
+	mu    sync.Mutex
+	warns [][]any
+}
+
+func (l *warnRecorder) Debug(msg string, args ...any) {}
+func (l *warnRecorder) Info(msg string, args ...any)  {}
+func (l *warnRecorder) Error(msg string, args ...any) {}
+func (l *warnRecorder) Warn(msg string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warns = append(l.warns, append([]any{msg}, args...))
+}
+
+func (l *warnRecorder) snapshot() [][]any {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([][]any(nil), l.warns...)
+}
+
+func TestClient_DroppedStatesAreLoggedAndCounted(t *testing.T) {
+	transport := newMockTransport()
+	logger := &warnRecorder{}
+	reg := prometheus.NewRegistry()
+
+	// A slow consumer: only one state fits and nobody reads it.
+	client := &Client{
+		stream:               transport,
+		logger:               logger,
+		stateCh:              make(chan *State, 1),
+		errCh:                make(chan error, 1),
+		tokenIndexer:         &mockTokenIndexer{},
+		poolRegistryIndexer:  &mockPoolRegistryIndexer{},
+		uniswapV2Indexer:     &mockUniswapV2Indexer{},
+		uniswapV3Indexer:     &mockUniswapV3Indexer{},
+		solidlyIndexer:       &mockSolidlyIndexer{},
+		tokenPoolGrapher:     &mockGrapher{},
+		droppedStatesCounter: newDroppedStatesCounter(reg),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client.ctx = ctx
+	client.wg.Add(1)
+	go client.loop()
+	defer cancel()
+
+	rawState := func(block int64) *engine.State {
+		return &engine.State{
+			Block: engine.BlockSummary{Number: big.NewInt(block)},
+			Protocols: map[engine.ProtocolID]engine.ProtocolState{
+				"tokens":   {Schema: tokenregistry.Schema, Data: []tokenregistry.Token{}},
+				"registry": {Schema: poolregistry.Schema, Data: poolregistry.PoolRegistry{}},
+				"graph":    {Schema: tokenpoolregistry.Schema, Data: &tokenpoolregistry.TokenPoolRegistryView{}},
+			},
+		}
+	}
+	for block := int64(300); block < 303; block++ {
+		transport.stateCh <- rawState(block)
+	}
+
+	require.Eventually(t, func() bool { return client.DroppedStates() == 2 }, time.Second, 5*time.Millisecond)
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	require.Len(t, families, 1)
+	assert.Equal(t, "defistate_client_dropped_states_total", families[0].GetName())
+	assert.Equal(t, float64(2), families[0].GetMetric()[0].GetCounter().GetValue())
+
+	var droppedBlocks []int64
+	for _, warn := range logger.snapshot() {
+		if warn[0] != "State buffer full, discarding processed state" {
+			continue
+		}
+		for i := 1; i+1 < len(warn); i += 2 {
+			if warn[i] == "block" {
+				droppedBlocks = append(droppedBlocks, warn[i+1].(*big.Int).Int64())
+			}
+		}
+	}
+	assert.Equal(t, []int64{301, 302}, droppedBlocks)
+
+	// The first state is still waiting for the consumer.
+	processed := <-client.State()
+	assert.Equal(t, int64(300), processed.Block.Number.Int64())
+}
+
+// memStateStore is an in-memory chains.StateStore.
+type memStateStore struct {
+	mu     sync.Mutex
+	states map[uint64]*engine.State
+	err    error // returned by every Put, if set
+}
+
+func newMemStateStore() *memStateStore {
+	return &memStateStore{states: make(map[uint64]*engine.State)}
+}
+
+func (s *memStateStore) Put(block uint64, state *engine.State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.states[block] = state
+	return nil
+}
+
+func (s *memStateStore) Get(block uint64) (*engine.State, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.states[block]
+	return state, ok
+}
+
+func TestClient_StateStore(t *testing.T) {
+	newClient := func(store chains.StateStore) (*Client, *mockTransport, context.CancelFunc) {
+		transport := newMockTransport()
+		c := &Client{
+			stream:              transport,
+			logger:              slog.New(slog.NewJSONHandler(io.Discard, nil)),
+			stateCh:             make(chan *State, 10),
+			errCh:               make(chan error, 10),
+			tokenIndexer:        &mockTokenIndexer{},
+			poolRegistryIndexer: &mockPoolRegistryIndexer{},
+			uniswapV2Indexer:    &mockUniswapV2Indexer{},
+			uniswapV3Indexer:    &mockUniswapV3Indexer{},
+			solidlyIndexer:      &mockSolidlyIndexer{},
+			tokenPoolGrapher:    &mockGrapher{},
+		}
+		WithStateStore(store).apply(c)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		c.ctx = ctx
+		c.wg.Add(1)
+		go c.loop()
+		return c, transport, cancel
+	}
+	rawStateAt := func(block int64) *engine.State {
+		return &engine.State{
+			Block: engine.BlockSummary{Number: big.NewInt(block)},
+			Protocols: map[engine.ProtocolID]engine.ProtocolState{
+				"tokens":   {Schema: tokenregistry.Schema, Data: []tokenregistry.Token{}},
+				"registry": {Schema: poolregistry.Schema, Data: poolregistry.PoolRegistry{}},
+				"graph":    {Schema: tokenpoolregistry.Schema, Data: &tokenpoolregistry.TokenPoolRegistryView{}},
+			},
+		}
+	}
+	receive := func(t *testing.T, c *Client) *State {
+		select {
+		case processed := <-c.State():
+			return processed
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for processed state")
+			return nil
+		}
+	}
+
+	t.Run("Every processed block is stored", func(t *testing.T) {
+		store := newMemStateStore()
+		c, transport, cancel := newClient(store)
+		defer cancel()
+
+		sent := make(map[uint64]*engine.State)
+		for block := int64(100); block < 103; block++ {
+			rawState := rawStateAt(block)
+			sent[uint64(block)] = rawState
+			transport.stateCh <- rawState
+			receive(t, c)
+		}
+
+		for block, rawState := range sent {
+			stored, ok := store.Get(block)
+			require.True(t, ok, "block %d should be stored", block)
+			assert.Same(t, rawState, stored)
+		}
+		_, ok := store.Get(99)
+		assert.False(t, ok)
+	})
+
+	t.Run("States failing to process are not stored", func(t *testing.T) {
+		store := newMemStateStore()
+		c, transport, cancel := newClient(store)
+		defer cancel()
+
+		// Missing the token-pool graph, so processing fails.
+		transport.stateCh <- &engine.State{Block: engine.BlockSummary{Number: big.NewInt(300)}}
+		transport.stateCh <- rawStateAt(301)
+		assert.Equal(t, int64(301), receive(t, c).Block.Number.Int64())
+
+		_, ok := store.Get(300)
+		assert.False(t, ok)
+		_, ok = store.Get(301)
+		assert.True(t, ok)
+	})
+
+	t.Run("A failing store does not hold back states", func(t *testing.T) {
+		store := newMemStateStore()
+		store.err = fmt.Errorf("disk full")
+		c, transport, cancel := newClient(store)
+		defer cancel()
+
+		transport.stateCh <- rawStateAt(400)
+		assert.Equal(t, int64(400), receive(t, c).Block.Number.Int64())
+	})
+}
+
+func TestOptions(t *testing.T) {
+	// 1. Create specific mocks to verify assignment
+	mockTokenIdx := &mockTokenIndexer{}
+	mockPoolRegistryIdx := &mockPoolRegistryIndexer{}
+	mockUniswapV2Idx := &mockUniswapV2Indexer{}
+	mockUniswapV3Idx := &mockUniswapV3Indexer{}
+	mockSolidlyIdx := &mockSolidlyIndexer{}
+	mockGrapher := &mockGrapher{}
+	mockStore := newMemStateStore()
+
+	// 2. Initialize an empty client
+	c := &Client{}
+
+	// 3. Define the options to test
+	opts := []Option{
+		WithTokenIndexer(mockTokenIdx),
+		WithPoolRegistryIndexer(mockPoolRegistryIdx),
+		WithUniswapV2Indexer(mockUniswapV2Idx),
+		WithUniswapV3Indexer(mockUniswapV3Idx),
+		WithSolidlyIndexer(mockSolidlyIdx),
+		WithTokenPoolGrapher(mockGrapher),
+		WithStateStore(mockStore),
+	}
+
+	// 4. Apply them manually (allowed since we are in package ethereum)
+	for _, opt := range opts {
+		opt.apply(c)
+	}
+
+	// 5. Assertions: Verify the internal fields point to our mocks
+	// We compare pointers to ensure the exact object was assigned.
+	assert.Same(t, mockTokenIdx, c.tokenIndexer, "WithTokenIndexer should set tokenIndexer")
+	assert.Same(t, mockPoolRegistryIdx, c.poolRegistryIndexer, "WithPoolRegistryIndexer should set poolRegistryIndexer")
+	assert.Same(t, mockUniswapV2Idx, c.uniswapV2Indexer, "WithUniswapV2Indexer should set uniswapV2Indexer")
+	assert.Same(t, mockUniswapV3Idx, c.uniswapV3Indexer, "WithUniswapV3Indexer should set uniswapV3Indexer")
+	assert.Same(t, mockSolidlyIdx, c.solidlyIndexer, "WithSolidlyIndexer should set solidlyIndexer")
+	assert.Same(t, mockGrapher, c.tokenPoolGrapher, "WithTokenPoolGrapher should set tokenPoolGrapher")
+	assert.Same(t, mockStore, c.stateStore, "WithStateStore should set stateStore")
+}
+func TestClient_FromStream(t *testing.T) {
+	// Setup Mocks
+	transport := newMockTransport()
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	reg := prometheus.NewRegistry()
+
+	// 1. Initialize via FromStream
+	// We use context.WithCancel so we can stop the client's internal loop later
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client, err := FromStream(ctx, transport, logger, reg)
+	assert.NoError(t, err)
+	assert.NotNil(t, client)
+
+	// 2. Prepare mock data
+	rawState := &engine.State{
+		Block: engine.BlockSummary{Number: big.NewInt(500)},
+		Protocols: map[engine.ProtocolID]engine.ProtocolState{
+			"tokens":   {Schema: tokenregistry.Schema, Data: []tokenregistry.Token{}},
+			"registry": {Schema: poolregistry.Schema, Data: poolregistry.PoolRegistry{}},
+			"graph":    {Schema: tokenpoolregistry.Schema, Data: &tokenpoolregistry.TokenPoolRegistryView{}},
+		},
+	}
+
+	// 3. Push data into the transport
+	transport.stateCh <- rawState
+
+	// 4. Verify the client (which is now running its loop) processes it
+	select {
+	case processed := <-client.State():
+		assert.Equal(t, int64(500), processed.Block.Number.Int64())
+	case <-time.After(1 * time.Second):
+		t.Fatal("FromStream client failed to process state within timeout")
+	}
+
+	// 5. Verify manual shutdown via context
+	cancel()
+
+	// Wait for the loop to terminate and close channels
+	select {
+	case _, ok := <-client.State():
+		assert.False(t, ok, "State channel should close when context is cancelled")
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("FromStream client loop did not exit on context cancellation")
+	}
+}
+
+func TestNewDroppedStatesCounter_SharedRegistry(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	first := newDroppedStatesCounter(reg)
+
+	// A second client on the same registry reuses the counter instead of panicking.
+	var second prometheus.Counter
+	require.NotPanics(t, func() { second = newDroppedStatesCounter(reg) })
+	first.Inc()
+	second.Inc()
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	require.Len(t, families, 1)
+	assert.Equal(t, float64(2), families[0].GetMetric()[0].GetCounter().GetValue())
+}
+
+// diffRecordingGraph records the diffs applied to it.
+type diffRecordingGraph struct {
+	chains.TokenPoolGraph
+	applied []chains.GraphDiff
+}
+
+func (g *diffRecordingGraph) ApplyDiff(diff chains.GraphDiff) (chains.TokenPoolGraph, error) {
+	g.applied = append(g.applied, diff)
+	return g, nil
+}
+
+// countingGrapher counts the graphs it builds, all of which are graph.
+type countingGrapher struct {
+	graphs int
+	graph  *diffRecordingGraph
+}
+
+func (c *countingGrapher) Graph(
+	tp *tokenpoolregistry.TokenPoolRegistryView,
+	tr tokenregistryindexer.IndexedTokenSystem,
+	pr poolregistryindexer.IndexedPoolRegistry,
+	v2 uniswapv2indexer.IndexedUniswapV2,
+	v3 uniswapv3indexer.IndexedUniswapV3,
+	sol solidlyindexer.IndexedSolidly,
+	resolver *chains.ProtocolResolver,
+) (chains.TokenPoolGraph, error) {
+	c.graphs++
+	return c.graph, nil
+}
+
+func TestClient_DiffTracker(t *testing.T) {
+	transport := newMockTransport()
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	grapher := &countingGrapher{graph: &diffRecordingGraph{}}
+	tracker := chains.NewDiffTracker()
+	client, err := FromStream(ctx, transport, logger, prometheus.NewRegistry(), WithTokenPoolGrapher(grapher), WithDiffTracker(tracker))
+	require.NoError(t, err)
+
+	newState := func(block int64) *engine.State {
+		return &engine.State{
+			Block: engine.BlockSummary{Number: big.NewInt(block)},
+			Protocols: map[engine.ProtocolID]engine.ProtocolState{
+				"tokens":   {Schema: tokenregistry.Schema, Data: []tokenregistry.Token{}},
+				"registry": {Schema: poolregistry.Schema, Data: poolregistry.PoolRegistry{}},
+				"graph":    {Schema: tokenpoolregistry.Schema, Data: &tokenpoolregistry.TokenPoolRegistryView{}},
+			},
+		}
+	}
+	// patch records next as patched from prev with diff, as the stream's patcher would.
+	patch := func(prev, next *engine.State, diff *differ.StateDiff) *engine.State {
+		patched, err := tracker.Patcher(func(*engine.State, *differ.StateDiff) (*engine.State, error) {
+			return next, nil
+		})(prev, diff)
+		require.NoError(t, err)
+		return patched
+	}
+	process := func(state *engine.State) {
+		t.Helper()
+		transport.stateCh <- state
+		select {
+		case processed := <-client.State():
+			require.Equal(t, state.Block.Number, processed.Block.Number)
+		case <-time.After(time.Second):
+			t.Fatal("state was not processed")
+		}
+	}
+	poolDiff := &differ.StateDiff{Protocols: map[engine.ProtocolID]differ.ProtocolDiff{
+		"uniswap-v2": {Data: uniswapv2.UniswapV2SystemDiff{Updates: []uniswapv2.Pool{{ID: 7}}}},
+		"tokens":     {Data: tokenregistry.TokenSystemDiff{Deletions: []uint64{3}}},
+	}}
+
+	full := newState(500)
+	process(full)
+	assert.Equal(t, 1, grapher.graphs)
+
+	// A state patched from the last processed one updates its graph.
+	patched := patch(full, newState(501), poolDiff)
+	process(patched)
+	assert.Equal(t, 1, grapher.graphs)
+	require.Len(t, grapher.graph.applied, 1)
+	assert.Equal(t, []uint64{7}, grapher.graph.applied[0].ChangedPools)
+	assert.Equal(t, []uint64{3}, grapher.graph.applied[0].ChangedTokens)
+
+	// A state patched from one the client never processed is graphed from scratch.
+	process(patch(newState(600), newState(601), poolDiff))
+	assert.Equal(t, 2, grapher.graphs)
+
+	// So is one whose diff has changes of an unknown kind.
+	last := newState(700)
+	process(last)
+	process(patch(last, newState(701), &differ.StateDiff{Protocols: map[engine.ProtocolID]differ.ProtocolDiff{
+		"curve": {Data: struct{}{}},
+	}}))
+	assert.Equal(t, 4, grapher.graphs)
+	assert.Len(t, grapher.graph.applied, 1)
+}
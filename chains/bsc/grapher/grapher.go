@@ -0,0 +1,302 @@
+package grapher
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/defistate/defistate-client-go/addr"
+	"github.com/defistate/defistate-client-go/chains"
+	"github.com/defistate/defistate-client-go/protocols/pancakeswap"
+	poolregistryindexer "github.com/defistate/defistate-client-go/protocols/poolregistry/indexer"
+	"github.com/defistate/defistate-client-go/protocols/solidly"
+	solidlyindexer "github.com/defistate/defistate-client-go/protocols/solidly/indexer"
+	tokenpoolregistry "github.com/defistate/defistate-client-go/protocols/tokenpoolregistry"
+	"github.com/defistate/defistate-client-go/protocols/tokenregistry"
+	tokenregistryindexer "github.com/defistate/defistate-client-go/protocols/tokenregistry/indexer"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	uniswapv2indexer "github.com/defistate/defistate-client-go/protocols/uniswapv2/indexer"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	uniswapv3indexer "github.com/defistate/defistate-client-go/protocols/uniswapv3/indexer"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv4"
+)
+
+var _ chains.TokenPoolGrapher = &Grapher{}
+
+type Grapher struct {
+	tokenAllowlist []addr.Address
+	tokenDenylist  []addr.Address
+	unknownTokens  unknownTokenHandling
+	duplicatePools DuplicatePoolPolicy
+	graphOptions   *GraphOptions
+}
+
+// UnknownTokenPolicy decides what the grapher does with pools that reference token IDs
+// missing from the token registry. It only applies when token metadata is available.
+type UnknownTokenPolicy int
+
+const (
+	// SkipUnknownTokenPools leaves such pools out of routing. This is the default.
+	SkipUnknownTokenPools UnknownTokenPolicy = iota
+	// RouteUnknownTokensWithDefaultDecimals routes such pools by token ID, and
+	// decimals-aware features (USDPrice, RateForSize, ...) assume the default decimals
+	// for the unknown tokens.
+	RouteUnknownTokensWithDefaultDecimals
+)
+
+// unknownTokenHandling is an UnknownTokenPolicy together with its default decimals.
+type unknownTokenHandling struct {
+	policy          UnknownTokenPolicy
+	defaultDecimals uint8
+}
+
+// DuplicatePoolPolicy decides what the grapher does with pool ids claimed by more than one
+// protocol, which would otherwise be quoted with whichever protocol the registry resolves.
+type DuplicatePoolPolicy int
+
+const (
+	// SkipDuplicatePools leaves such pools out of routing. This is the default.
+	SkipDuplicatePools DuplicatePoolPolicy = iota
+	// RejectDuplicatePools fails the graph build with chains.ErrDuplicatePoolID.
+	RejectDuplicatePools
+)
+
+// Option configures the Grapher.
+type Option interface {
+	apply(*Grapher)
+}
+
+type funcOption func(*Grapher)
+
+func (f funcOption) apply(g *Grapher) {
+	f(g)
+}
+
+func newOption(f func(*Grapher)) Option {
+	return funcOption(f)
+}
+
+// WithTokenAllowlist restricts routing to pools whose tokens are all in the allowlist.
+// An empty allowlist allows every token. Filtering requires token metadata.
+func WithTokenAllowlist(tokens []addr.Address) Option {
+	return newOption(func(g *Grapher) {
+		g.tokenAllowlist = tokens
+	})
+}
+
+// WithTokenDenylist leaves pools touching any denylisted token out of routing.
+// The denylist wins over the allowlist. Filtering requires token metadata.
+func WithTokenDenylist(tokens []addr.Address) Option {
+	return newOption(func(g *Grapher) {
+		g.tokenDenylist = tokens
+	})
+}
+
+// WithUnknownTokenPolicy sets how pools referencing tokens missing from the token registry
+// are handled. defaultDecimals is only used by RouteUnknownTokensWithDefaultDecimals.
+// Either way such pools are listed in the graph's build report.
+func WithUnknownTokenPolicy(policy UnknownTokenPolicy, defaultDecimals uint8) Option {
+	return newOption(func(g *Grapher) {
+		g.unknownTokens = unknownTokenHandling{policy: policy, defaultDecimals: defaultDecimals}
+	})
+}
+
+// WithDuplicatePoolPolicy sets how pool ids claimed by more than one protocol are handled.
+// Either way such ids are listed in the graph's build report.
+func WithDuplicatePoolPolicy(policy DuplicatePoolPolicy) Option {
+	return newOption(func(g *Grapher) {
+		g.duplicatePools = policy
+	})
+}
+
+// GraphOptions sets the liquidity below which pools are pruned from routing. Pruned pools
+// are listed in the graph's build report. The zero value prunes nothing.
+type GraphOptions struct {
+	// MinReserveUSD prunes pools whose reserves are worth less than it, concentrated-
+	// liquidity pools by their virtual reserves. Reserves are valued at PricesUSD if set.
+	// Otherwise one whole unit of NumeraireTokenID counts as one USD and the other tokens
+	// are priced at the spot prices of the deepest pools connecting them to it, using only
+	// pools worth at least MinReserveUSD. Prices are derived when the grapher builds a
+	// graph and kept by ApplyDiff. Tokens that cannot be priced count as worthless.
+	MinReserveUSD float64
+	// NumeraireTokenID is the token reserves are valued in, typically a USD stablecoin.
+	// It must be in the token registry unless PricesUSD is set.
+	NumeraireTokenID uint64
+	// PricesUSD, if set, is the USD price of one whole token by token ID, e.g. from an
+	// external price feed, to value reserves at instead of deriving prices from the pools.
+	PricesUSD map[uint64]float64
+	// MinLiquidity prunes concentrated-liquidity pools whose active liquidity is below
+	// it. Nil disables it.
+	MinLiquidity *big.Int
+}
+
+// WithGraphOptions sets the liquidity thresholds of the graphs the grapher builds.
+func WithGraphOptions(opts GraphOptions) Option {
+	return newOption(func(g *Grapher) {
+		g.graphOptions = &opts
+	})
+}
+
+func NewGrapher(opts ...Option) (*Grapher, error) {
+	grapher := &Grapher{}
+	for _, opt := range opts {
+		opt.apply(grapher)
+	}
+	return grapher, nil
+}
+
+func (g *Grapher) Graph(
+	rawGraph *tokenpoolregistry.TokenPoolRegistryView,
+	tokenregistry tokenregistryindexer.IndexedTokenSystem,
+	indexedPoolRegistry poolregistryindexer.IndexedPoolRegistry,
+	indexedUniswapV2 uniswapv2indexer.IndexedUniswapV2,
+	indexedUniswapV3 uniswapv3indexer.IndexedUniswapV3,
+	indexedSolidly solidlyindexer.IndexedSolidly,
+	protocolResolver *chains.ProtocolResolver,
+) (chains.TokenPoolGraph, error) {
+	filter := newTokenFilter(g.tokenAllowlist, g.tokenDenylist)
+	unknownTokens := g.unknownTokens
+	if filter != nil && tokenregistry == nil {
+		return nil, errTokenFilterNeedsMetadata
+	}
+
+	activePools := make(map[uint64]struct{})
+	for _, pool := range indexedPoolRegistry.All() {
+		allowUnknownTokens := g.unknownTokens.policy == RouteUnknownTokensWithDefaultDecimals
+		if isActivePool(pool.ID, tokenregistry, indexedUniswapV2, indexedUniswapV3, indexedSolidly, protocolResolver, allowUnknownTokens) {
+			activePools[pool.ID] = struct{}{}
+		}
+	}
+
+	return newGraph(
+		rawGraph,
+		tokenregistry,
+		indexedPoolRegistry,
+		indexedUniswapV2,
+		indexedUniswapV3,
+		indexedSolidly,
+		activePools,
+		protocolResolver,
+		filter,
+		&unknownTokens,
+		g.duplicatePools,
+		g.graphOptions,
+	)
+}
+
+// errTokenFilterNeedsMetadata is returned when token filters are configured but the state
+// carries no token metadata to resolve token addresses with.
+var errTokenFilterNeedsMetadata = errors.New("token allowlist/denylist requires token metadata")
+
+// tokenFilter decides, by token address, which tokens pools may be routed through.
+type tokenFilter struct {
+	allow map[addr.Address]struct{} // empty allows every token
+	deny  map[addr.Address]struct{}
+}
+
+// newTokenFilter returns nil if both lists are empty.
+func newTokenFilter(allowlist, denylist []addr.Address) *tokenFilter {
+	if len(allowlist) == 0 && len(denylist) == 0 {
+		return nil
+	}
+	f := &tokenFilter{
+		allow: make(map[addr.Address]struct{}, len(allowlist)),
+		deny:  make(map[addr.Address]struct{}, len(denylist)),
+	}
+	for _, a := range allowlist {
+		f.allow[a] = struct{}{}
+	}
+	for _, a := range denylist {
+		f.deny[a] = struct{}{}
+	}
+	return f
+}
+
+// allows reports whether the token passes the filter. A token missing from the registry
+// cannot be matched by address, so it only passes when there is no allowlist.
+func (f *tokenFilter) allows(registry tokenregistryindexer.IndexedTokenSystem, tokenID uint64) bool {
+	var token tokenregistry.Token
+	found := false
+	if registry != nil {
+		token, found = registry.GetByID(tokenID)
+	}
+	if !found {
+		return len(f.allow) == 0
+	}
+	if _, denied := f.deny[token.Address]; denied {
+		return false
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	_, allowed := f.allow[token.Address]
+	return allowed
+}
+
+// isActivePool reports whether a pool may be used by the active (cached) routing functions.
+// We set pools without fee on transfer tokens as active; other checks can be implemented.
+// When token metadata is unavailable we cannot detect fee on transfer tokens,
+// so every known pool is considered active and routing works on token IDs alone.
+// allowUnknownTokens treats tokens missing from the registry the same way.
+func isActivePool(
+	poolID uint64,
+	tokenregistry tokenregistryindexer.IndexedTokenSystem,
+	indexedUniswapV2 uniswapv2indexer.IndexedUniswapV2,
+	indexedUniswapV3 uniswapv3indexer.IndexedUniswapV3,
+	indexedSolidly solidlyindexer.IndexedSolidly,
+	protocolResolver *chains.ProtocolResolver,
+	allowUnknownTokens bool,
+) bool {
+	schema, ok := protocolResolver.ResolveSchemaFromPoolID(poolID)
+	if !ok {
+		return false
+	}
+
+	var token0ID, token1ID uint64
+	switch schema {
+	case uniswapv2.Schema, pancakeswap.V2Schema:
+		uniswapV2Pool, ok := indexedUniswapV2.GetByID(poolID)
+		if !ok {
+			// missing from the protocol indexer: NewGraph skips it and records it in the build report
+			return false
+		}
+		token0ID, token1ID = uniswapV2Pool.Token0, uniswapV2Pool.Token1
+	case uniswapv3.Schema, uniswapv4.Schema, pancakeswap.V3Schema:
+		uniswapV3Pool, ok := indexedUniswapV3.GetByID(poolID)
+		if !ok {
+			// missing from the protocol indexer: NewGraph skips it and records it in the build report
+			return false
+		}
+		token0ID, token1ID = uniswapV3Pool.Token0, uniswapV3Pool.Token1
+	case solidly.Schema:
+		if indexedSolidly == nil {
+			return false
+		}
+		solidlyPool, ok := indexedSolidly.GetByID(poolID)
+		if !ok {
+			// missing from the protocol indexer: NewGraph skips it and records it in the build report
+			return false
+		}
+		token0ID, token1ID = solidlyPool.Token0, solidlyPool.Token1
+	default:
+		return false
+	}
+
+	if tokenregistry == nil {
+		return true
+	}
+
+	for _, tokenID := range []uint64{token0ID, token1ID} {
+		token, ok := tokenregistry.GetByID(tokenID)
+		if !ok {
+			if allowUnknownTokens {
+				continue
+			}
+			return false
+		}
+		// filter out tokens with fee
+		if token.FeeOnTransferPercent > 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package chains
+
+import (
+	"sync"
+
+	"github.com/defistate/defistate-client-go/differ"
+	"github.com/defistate/defistate-client-go/engine"
+)
+
+// maxTrackedDiffs bounds the patched states a DiffTracker remembers before the chain
+// client has processed them; older ones are forgotten and their graphs rebuilt.
+const maxTrackedDiffs = 256
+
+// DiffTracker remembers the state diff each patched state was built from, so a chain
+// client can carry its previous graph over to the next state with
+// TokenPoolGraph.ApplyDiff instead of rebuilding it. It is safe for concurrent use.
+type DiffTracker struct {
+	mu      sync.Mutex
+	patched []patchedState
+}
+
+type patchedState struct {
+	prev, next *engine.State
+	diff       *differ.StateDiff
+}
+
+// NewDiffTracker returns an empty DiffTracker.
+func NewDiffTracker() *DiffTracker {
+	return &DiffTracker{}
+}
+
+// Patcher wraps patch so that every state it returns is tracked together with the
+// state and diff it was patched from. Pass the result as the stream's state patcher.
+func (t *DiffTracker) Patcher(
+	patch func(prevState *engine.State, diff *differ.StateDiff) (*engine.State, error),
+) func(prevState *engine.State, diff *differ.StateDiff) (*engine.State, error) {
+	return func(prevState *engine.State, diff *differ.StateDiff) (*engine.State, error) {
+		next, err := patch(prevState, diff)
+		if err != nil {
+			return nil, err
+		}
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if len(t.patched) == maxTrackedDiffs {
+			t.patched = t.patched[1:]
+		}
+		t.patched = append(t.patched, patchedState{prev: prevState, next: next, diff: diff})
+		return next, nil
+	}
+}
+
+// Take returns the diff state was patched with, if state was patched from prev. States
+// arrive in the order they were patched, so the states tracked before state, which were
+// never taken, are forgotten with it. ok is false for a full state, a state patched from
+// another state than prev, or a state the tracker has already forgotten.
+func (t *DiffTracker) Take(prev, state *engine.State) (diff *differ.StateDiff, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, patched := range t.patched {
+		if patched.next != state {
+			continue
+		}
+		t.patched = t.patched[i+1:]
+		if prev == nil || patched.prev != prev {
+			return nil, false
+		}
+		return patched.diff, true
+	}
+	return nil, false
+}
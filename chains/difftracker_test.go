@@ -0,0 +1,71 @@
+package chains
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/defistate/defistate-client-go/differ"
+	"github.com/defistate/defistate-client-go/engine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffTracker(t *testing.T) {
+	tracker := NewDiffTracker()
+	var next *engine.State
+	patch := tracker.Patcher(func(prev *engine.State, diff *differ.StateDiff) (*engine.State, error) {
+		if diff == nil {
+			return nil, errors.New("no diff")
+		}
+		return next, nil
+	})
+	patchTo := func(prev *engine.State, diff *differ.StateDiff) *engine.State {
+		next = &engine.State{}
+		state, err := patch(prev, diff)
+		require.NoError(t, err)
+		return state
+	}
+
+	s0 := &engine.State{}
+	d1, d2, d3 := &differ.StateDiff{FromBlock: 0}, &differ.StateDiff{FromBlock: 1}, &differ.StateDiff{FromBlock: 2}
+	s1 := patchTo(s0, d1)
+	s2 := patchTo(s1, d2)
+	s3 := patchTo(s2, d3)
+
+	t.Run("returns the diff of a state patched from prev", func(t *testing.T) {
+		diff, ok := tracker.Take(s0, s1)
+		assert.True(t, ok)
+		assert.Same(t, d1, diff)
+	})
+
+	t.Run("rejects a state patched from another state", func(t *testing.T) {
+		_, ok := tracker.Take(s0, s2)
+		assert.False(t, ok)
+	})
+
+	t.Run("forgets the states before a taken one", func(t *testing.T) {
+		diff, ok := tracker.Take(s2, s3)
+		assert.True(t, ok)
+		assert.Same(t, d3, diff)
+		_, ok = tracker.Take(s1, s2)
+		assert.False(t, ok)
+	})
+
+	t.Run("does not track failed patches or full states", func(t *testing.T) {
+		_, err := patch(s3, nil)
+		assert.Error(t, err)
+		_, ok := tracker.Take(s3, &engine.State{})
+		assert.False(t, ok)
+		_, ok = tracker.Take(nil, s1)
+		assert.False(t, ok)
+	})
+
+	t.Run("is bounded", func(t *testing.T) {
+		first := patchTo(s3, d1)
+		for i := 0; i < maxTrackedDiffs; i++ {
+			patchTo(first, d2)
+		}
+		_, ok := tracker.Take(s3, first)
+		assert.False(t, ok)
+	})
+}
@@ -1,21 +1,32 @@
 package grapher
 
 import (
+	"container/heap"
+	"context"
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
+	"slices"
+	"sort"
 	"sync"
 
 	"github.com/defistate/defistate-client-go/bitset"
 	"github.com/defistate/defistate-client-go/chains"
+	"github.com/defistate/defistate-client-go/engine"
 
+	solidly "github.com/defistate/defistate-client-go/protocols/solidly"
+	solidlycalculator "github.com/defistate/defistate-client-go/protocols/solidly/calculator"
+	solidlyindexer "github.com/defistate/defistate-client-go/protocols/solidly/indexer"
 	tokenpoolregistry "github.com/defistate/defistate-client-go/protocols/tokenpoolregistry"
 	uniswapv2 "github.com/defistate/defistate-client-go/protocols/uniswapv2"
 	uniswapv2calculator "github.com/defistate/defistate-client-go/protocols/uniswapv2/calculator"
 	uniswapv3 "github.com/defistate/defistate-client-go/protocols/uniswapv3"
 	uniswapv3calculator "github.com/defistate/defistate-client-go/protocols/uniswapv3/calculator"
+	uniswapv4 "github.com/defistate/defistate-client-go/protocols/uniswapv4"
 
 	poolregistryindexer "github.com/defistate/defistate-client-go/protocols/poolregistry/indexer"
+	tokenregistryindexer "github.com/defistate/defistate-client-go/protocols/tokenregistry/indexer"
 	uniswapv2indexer "github.com/defistate/defistate-client-go/protocols/uniswapv2/indexer"
 	uniswapv3indexer "github.com/defistate/defistate-client-go/protocols/uniswapv3/indexer"
 )
@@ -54,10 +65,12 @@ type GetReservesFunc func(tokenInID, tokenOutID uint64) (reserveIn, reserveOut *
 // Graph is a reusable, stateless algorithmic engine for a single state snapshot.
 type Graph struct {
 	// Raw data views required for lookups.
-	rawGraph            *tokenpoolregistry.TokenPoolRegistryView
-	indexedPoolRegistry poolregistryindexer.IndexedPoolRegistry
-	indexedUniswapV2    uniswapv2indexer.IndexedUniswapV2
-	indexedUniswapV3    uniswapv3indexer.IndexedUniswapV3
+	rawGraph             *tokenpoolregistry.TokenPoolRegistryView
+	indexedTokenRegistry tokenregistryindexer.IndexedTokenSystem
+	indexedPoolRegistry  poolregistryindexer.IndexedPoolRegistry
+	indexedUniswapV2     uniswapv2indexer.IndexedUniswapV2
+	indexedUniswapV3     uniswapv3indexer.IndexedUniswapV3
+	indexedSolidly       solidlyindexer.IndexedSolidly // nil when the chain has no Solidly pools
 
 	// Internal lookup maps for fast access.
 	tokenToIndex     map[uint64]int
@@ -67,18 +80,69 @@ type Graph struct {
 	allGetAmountOutFuncs    []GetAmountOutFunc
 	getReservesFuncs        []GetReservesFunc
 	activeGetAmountOutFuncs []GetAmountOutFunc
+	// cachedGetAmountOutFuncs are float64 approximations of allGetAmountOutFuncs, used
+	// by the FloatSearch routing mode alongside whichever exact functions it searches.
+	cachedGetAmountOutFuncs []GetAmountOutFromCacheFunc
+	// poolFees holds each pool's swap fee in hundredths of a basis point, by pool index.
+	poolFees []uint64
+	// depths holds each routable pool's depth (see TopPoolsBySchema), by pool index,
+	// measured when the pool is wired.
+	depths []*big.Int
+	// buildReport records the pools that could not be included in routing.
+	buildReport chains.GraphBuildReport
+	// tokenFilter, if set, leaves pools touching filtered tokens out of routing.
+	tokenFilter *tokenFilter
+	// unknownTokens, if set, decides how pools with tokens missing from the token registry
+	// are handled. Graphs built with NewGraph leave it nil and route such pools by ID.
+	unknownTokens *unknownTokenHandling
+	// duplicatePools holds the pool ids claimed by more than one protocol, which are never
+	// routed; duplicatePolicy decides whether building a graph with any fails instead.
+	duplicatePools  map[uint64]struct{}
+	duplicatePolicy DuplicatePoolPolicy
+	// graphOptions, if set, holds the liquidity below which pools are pruned.
+	graphOptions *GraphOptions
+	// liquidityPrices holds the USD value of one raw unit of each token reserves can be
+	// valued in, when graphOptions sets MinReserveUSD. It is derived once per build and
+	// carried over by ApplyDiff.
+	liquidityPrices map[uint64]float64
 }
 
 // NewGraph creates a new Graph instance. It pre-processes the raw view data
 // by building lookup maps and the two distinct slices of computation functions.
+// Pool ids claimed by more than one protocol are skipped and listed in the build report.
+// Uniswap V4 pools are quoted from indexedUniswapV3, which holds them in V3 form (see
+// the uniswapv4 calculator's V3Pools). Graphs with Solidly pools are built by the Grapher.
 func NewGraph(
 	rawGraph *tokenpoolregistry.TokenPoolRegistryView,
+	indexedTokenRegistry tokenregistryindexer.IndexedTokenSystem,
 	indexedPoolRegistry poolregistryindexer.IndexedPoolRegistry,
 	indexedUniswapV2 uniswapv2indexer.IndexedUniswapV2,
 	indexedUniswapV3 uniswapv3indexer.IndexedUniswapV3,
 	activePools map[uint64]struct{},
 	protocolResolver *chains.ProtocolResolver,
 ) (*Graph, error) {
+	return newGraph(rawGraph, indexedTokenRegistry, indexedPoolRegistry, indexedUniswapV2, indexedUniswapV3, nil, activePools, protocolResolver, nil, nil, SkipDuplicatePools, nil)
+}
+
+// newGraph is NewGraph with the optional Grapher settings applied while the pools are wired.
+func newGraph(
+	rawGraph *tokenpoolregistry.TokenPoolRegistryView,
+	indexedTokenRegistry tokenregistryindexer.IndexedTokenSystem,
+	indexedPoolRegistry poolregistryindexer.IndexedPoolRegistry,
+	indexedUniswapV2 uniswapv2indexer.IndexedUniswapV2,
+	indexedUniswapV3 uniswapv3indexer.IndexedUniswapV3,
+	indexedSolidly solidlyindexer.IndexedSolidly,
+	activePools map[uint64]struct{},
+	protocolResolver *chains.ProtocolResolver,
+	filter *tokenFilter,
+	unknownTokens *unknownTokenHandling,
+	duplicatePolicy DuplicatePoolPolicy,
+	graphOptions *GraphOptions,
+) (*Graph, error) {
+	duplicatePools, duplicateIDs := findDuplicatePools(rawGraph, indexedUniswapV2, indexedUniswapV3, indexedSolidly)
+	if len(duplicateIDs) > 0 && duplicatePolicy == RejectDuplicatePools {
+		return nil, fmt.Errorf("%w: %v", chains.ErrDuplicatePoolID, duplicateIDs)
+	}
 
 	tokenToIndex := make(map[uint64]int, len(rawGraph.Tokens))
 	for i, id := range rawGraph.Tokens {
@@ -94,80 +158,605 @@ func NewGraph(
 	allGetAmountOutFuncs := make([]GetAmountOutFunc, len(rawGraph.Pools))
 	getReservesFuncs := make([]GetReservesFunc, len(rawGraph.Pools))
 	activeGetAmountOutFuncs := make([]GetAmountOutFunc, len(rawGraph.Pools))
+	cachedGetAmountOutFuncs := make([]GetAmountOutFromCacheFunc, len(rawGraph.Pools))
+	poolFees := make([]uint64, len(rawGraph.Pools))
+	depths := make([]*big.Int, len(rawGraph.Pools))
+
+	g := &Graph{
+		rawGraph:                rawGraph,
+		indexedTokenRegistry:    indexedTokenRegistry,
+		indexedPoolRegistry:     indexedPoolRegistry,
+		indexedUniswapV2:        indexedUniswapV2,
+		indexedUniswapV3:        indexedUniswapV3,
+		indexedSolidly:          indexedSolidly,
+		tokenToIndex:            tokenToIndex,
+		poolToIndex:             poolToIndex,
+		protocolResolver:        protocolResolver,
+		allGetAmountOutFuncs:    allGetAmountOutFuncs,
+		activeGetAmountOutFuncs: activeGetAmountOutFuncs,
+		cachedGetAmountOutFuncs: cachedGetAmountOutFuncs,
+		getReservesFuncs:        getReservesFuncs,
+		poolFees:                poolFees,
+		depths:                  depths,
+		buildReport:             chains.GraphBuildReport{TotalPools: len(rawGraph.Pools), DuplicatePoolIDs: duplicateIDs},
+		tokenFilter:             filter,
+		unknownTokens:           unknownTokens,
+		duplicatePools:          duplicatePools,
+		duplicatePolicy:         duplicatePolicy,
+		graphOptions:            graphOptions,
+	}
 
+	if err := g.priceLiquidity(); err != nil {
+		return nil, err
+	}
 	for i, poolID := range rawGraph.Pools {
-		poolInfo, ok := indexedPoolRegistry.GetByID(poolID)
-		if !ok {
-			continue
+		_, active := activePools[poolID]
+		g.wirePool(i, poolID, active)
+	}
+
+	return g, nil
+
+}
+
+// wirePool builds the quoting functions of the pool at index i and records it in the
+// build report. Pools that cannot be routed or fall below the liquidity thresholds of the
+// graph options are recorded as skipped and left unwired.
+func (g *Graph) wirePool(i int, poolID uint64, active bool) {
+	if g.hasUnknownToken(poolID) {
+		g.buildReport.UnknownTokenPools = append(g.buildReport.UnknownTokenPools, poolID)
+	}
+	if ok, reason := g.canRoute(poolID); !ok {
+		g.buildReport.Skipped = append(g.buildReport.Skipped, chains.SkippedPool{PoolID: poolID, Reason: reason})
+		return
+	}
+	if !g.meetsGraphOptions(poolID) {
+		g.buildReport.Skipped = append(g.buildReport.Skipped, chains.SkippedPool{PoolID: poolID, Reason: chains.SkipReasonBelowLiquidity})
+		return
+	}
+	g.buildReport.RoutablePools++
+
+	schema, _ := g.protocolResolver.ResolveSchemaFromPoolID(poolID)
+	switch schema {
+	case uniswapv2.Schema:
+		pool, _ := g.indexedUniswapV2.GetByID(poolID)
+		// V2 fees are expressed in basis points; normalize to hundredths of a bip.
+		g.poolFees[i] = uint64(pool.SwapFeeBps()) * 100
+
+		// Build the precise function using the live calculator.
+		g.allGetAmountOutFuncs[i] = func(amountIn *big.Int, tokenInID, tokenOutID uint64) (*big.Int, error) {
+			return uniswapv2calculator.GetAmountOut(amountIn, tokenInID, tokenOutID, pool)
+		}
+		// Build the reserves function.
+		g.getReservesFuncs[i] = func(tokenInID, tokenOutID uint64) (*big.Int, *big.Int, error) {
+			return uniswapv2calculator.GetReserves(tokenInID, tokenOutID, pool)
+		}
+		g.cachedGetAmountOutFuncs[i] = uniswapV2FloatQuote(pool)
+		// Build the active function if this pool is in the active set.
+		if active {
+			g.activeGetAmountOutFuncs[i] = func(amountIn *big.Int, tokenInID, tokenOutID uint64) (*big.Int, error) {
+				return uniswapv2calculator.GetAmountOut(amountIn, tokenInID, tokenOutID, pool)
+			}
 		}
 
-		schema, ok := protocolResolver.ResolveSchemaFromPoolID(poolInfo.ID)
-		if !ok {
-			continue
+	case uniswapv3.Schema, uniswapv4.Schema:
+		pool, _ := g.indexedUniswapV3.GetByID(poolID)
+		g.poolFees[i] = pool.SwapFee()
+		g.allGetAmountOutFuncs[i] = func(amountIn *big.Int, tokenInID, tokenOutID uint64) (*big.Int, error) {
+			return uniswapv3calculator.GetAmountOut(amountIn, nil, tokenInID, pool)
 		}
-		switch schema {
-		case uniswapv2.Schema:
-			pool, found := indexedUniswapV2.GetByID(poolID)
-			if !found {
-				continue // maybe panic?
+		g.getReservesFuncs[i] = func(tokenInID, tokenOutID uint64) (*big.Int, *big.Int, error) {
+			reserveTokenOut, err := uniswapv3calculator.GetAmountOut(uniswapv3calculator.MaxUint256, nil, tokenInID, pool)
+			if err != nil {
+				return nil, nil, err
 			}
 
-			// Build the precise function using the live calculator.
-			allGetAmountOutFuncs[i] = func(amountIn *big.Int, tokenInID, tokenOutID uint64) (*big.Int, error) {
-				return uniswapv2calculator.GetAmountOut(amountIn, tokenInID, tokenOutID, pool)
+			reserveTokenIn, err := uniswapv3calculator.GetAmountOut(uniswapv3calculator.MaxUint256, nil, tokenOutID, pool)
+			if err != nil {
+				return nil, nil, err
 			}
-			// Build the reserves function.
-			getReservesFuncs[i] = func(tokenInID, tokenOutID uint64) (*big.Int, *big.Int, error) {
-				return uniswapv2calculator.GetReserves(tokenInID, tokenOutID, pool)
+			return reserveTokenIn, reserveTokenOut, nil
+		}
+		g.cachedGetAmountOutFuncs[i] = uniswapV3FloatQuote(pool)
+		if active {
+			g.activeGetAmountOutFuncs[i] = func(amountIn *big.Int, tokenInID, tokenOutID uint64) (*big.Int, error) {
+				return uniswapv3calculator.GetAmountOut(amountIn, nil, tokenInID, pool)
 			}
-			// Build the cached function if this pool is in the active set.
-			if _, ok := activePools[poolID]; ok {
-				activeGetAmountOutFuncs[i] = func(amountIn *big.Int, tokenInID, tokenOutID uint64) (*big.Int, error) {
-					return uniswapv2calculator.GetAmountOut(amountIn, tokenInID, tokenOutID, pool)
-				}
+		}
+
+	case solidly.Schema:
+		pool, _ := g.indexedSolidly.GetByID(poolID)
+		// Solidly fees are expressed in basis points; normalize to hundredths of a bip.
+		g.poolFees[i] = uint64(pool.FeeBps) * 100
+		g.allGetAmountOutFuncs[i] = func(amountIn *big.Int, tokenInID, tokenOutID uint64) (*big.Int, error) {
+			return solidlycalculator.GetAmountOut(amountIn, tokenInID, tokenOutID, pool)
+		}
+		g.getReservesFuncs[i] = func(tokenInID, tokenOutID uint64) (*big.Int, *big.Int, error) {
+			return solidlycalculator.GetReserves(tokenInID, tokenOutID, pool)
+		}
+		g.cachedGetAmountOutFuncs[i] = solidlycalculator.FloatQuoter(pool)
+		if active {
+			g.activeGetAmountOutFuncs[i] = func(amountIn *big.Int, tokenInID, tokenOutID uint64) (*big.Int, error) {
+				return solidlycalculator.GetAmountOut(amountIn, tokenInID, tokenOutID, pool)
 			}
+		}
+	}
+	g.depths[i] = g.measureDepth(poolID)
+}
 
-		case uniswapv3.Schema:
-			pool, found := indexedUniswapV3.GetByID(poolID)
-			if !found {
-				continue // maybe panic?
+// findDuplicatePools returns the pool ids of the token-pool graph that are listed more than
+// once or held by more than one protocol indexer, as a set and in order of first appearance.
+func findDuplicatePools(
+	rawGraph *tokenpoolregistry.TokenPoolRegistryView,
+	indexedUniswapV2 uniswapv2indexer.IndexedUniswapV2,
+	indexedUniswapV3 uniswapv3indexer.IndexedUniswapV3,
+	indexedSolidly solidlyindexer.IndexedSolidly,
+) (map[uint64]struct{}, []uint64) {
+	inBothProtocols := func(poolID uint64) bool {
+		held := 0
+		if indexedUniswapV2 != nil {
+			if _, found := indexedUniswapV2.GetByID(poolID); found {
+				held++
 			}
-			allGetAmountOutFuncs[i] = func(amountIn *big.Int, tokenInID, tokenOutID uint64) (*big.Int, error) {
-				return uniswapv3calculator.GetAmountOut(amountIn, nil, tokenInID, pool)
+		}
+		if indexedUniswapV3 != nil {
+			if _, found := indexedUniswapV3.GetByID(poolID); found {
+				held++
 			}
-			getReservesFuncs[i] = func(tokenInID, tokenOutID uint64) (*big.Int, *big.Int, error) {
-				reserveTokenOut, err := uniswapv3calculator.GetAmountOut(uniswapv3calculator.MaxUint256, nil, tokenInID, pool)
-				if err != nil {
-					return nil, nil, err
-				}
+		}
+		if indexedSolidly != nil {
+			if _, found := indexedSolidly.GetByID(poolID); found {
+				held++
+			}
+		}
+		return held > 1
+	}
 
-				reserveTokenIn, err := uniswapv3calculator.GetAmountOut(uniswapv3calculator.MaxUint256, nil, tokenOutID, pool)
-				if err != nil {
-					return nil, nil, err
+	duplicates := make(map[uint64]struct{})
+	var ids []uint64
+	seen := make(map[uint64]struct{}, len(rawGraph.Pools))
+	for _, poolID := range rawGraph.Pools {
+		_, listed := seen[poolID]
+		seen[poolID] = struct{}{}
+		if _, found := duplicates[poolID]; found || (!listed && !inBothProtocols(poolID)) {
+			continue
+		}
+		duplicates[poolID] = struct{}{}
+		ids = append(ids, poolID)
+	}
+	return duplicates, ids
+}
+
+// ApplyDiff returns a new Graph for the patched views in diff, typically the result of
+// patching the state this graph was built from. Pools listed in diff.ChangedPools, which
+// includes brand-new pools, and the pools trading a token in diff.ChangedTokens are
+// rewired against the new views; pools removed from the token-pool view are dropped;
+// every other pool reuses its existing functions. While the token-pool view lists the
+// same tokens and pools, the lookup maps are shared and only those pools are visited.
+// The receiver is not modified, so it keeps serving the previous snapshot. Its token
+// filter, if any, and its duplicate pool policy carry over to the new Graph.
+func (g *Graph) ApplyDiff(diff chains.GraphDiff) (chains.TokenPoolGraph, error) {
+	if diff.TokenPool == nil || diff.IndexedPoolRegistry == nil || diff.ProtocolResolver == nil {
+		return nil, errors.New("graph diff is missing its token-pool view, pool registry or protocol resolver")
+	}
+	if g.tokenFilter != nil && diff.IndexedTokenRegistry == nil {
+		return nil, errTokenFilterNeedsMetadata
+	}
+	rawGraph := diff.TokenPool
+	duplicatePools, duplicateIDs := findDuplicatePools(rawGraph, diff.IndexedUniswapV2, diff.IndexedUniswapV3, diff.IndexedSolidly)
+	if len(duplicateIDs) > 0 && g.duplicatePolicy == RejectDuplicatePools {
+		return nil, fmt.Errorf("%w: %v", chains.ErrDuplicatePoolID, duplicateIDs)
+	}
+
+	next := &Graph{
+		rawGraph:             rawGraph,
+		indexedTokenRegistry: diff.IndexedTokenRegistry,
+		indexedPoolRegistry:  diff.IndexedPoolRegistry,
+		indexedUniswapV2:     diff.IndexedUniswapV2,
+		indexedUniswapV3:     diff.IndexedUniswapV3,
+		indexedSolidly:       diff.IndexedSolidly,
+		protocolResolver:     diff.ProtocolResolver,
+		buildReport:          chains.GraphBuildReport{TotalPools: len(rawGraph.Pools), DuplicatePoolIDs: duplicateIDs},
+		tokenFilter:          g.tokenFilter,
+		unknownTokens:        g.unknownTokens,
+		duplicatePools:       duplicatePools,
+		duplicatePolicy:      g.duplicatePolicy,
+		graphOptions:         g.graphOptions,
+		liquidityPrices:      g.liquidityPrices,
+	}
+	allowUnknownTokens := g.unknownTokens != nil && g.unknownTokens.policy == RouteUnknownTokensWithDefaultDecimals
+	wire := func(i int, poolID uint64) {
+		next.wirePool(i, poolID, isActivePool(poolID, diff.IndexedTokenRegistry, diff.IndexedUniswapV2, diff.IndexedUniswapV3, diff.IndexedSolidly, diff.ProtocolResolver, allowUnknownTokens))
+	}
+
+	if slices.Equal(g.rawGraph.Tokens, rawGraph.Tokens) && slices.Equal(g.rawGraph.Pools, rawGraph.Pools) {
+		g.rewireChangedPools(next, diff, wire)
+	} else {
+		g.remapPools(next, diff, wire)
+	}
+	return next, nil
+}
+
+// rewireChangedPools fills next from g when both list the same tokens and pools, so every
+// pool keeps its index: the lookup maps are shared, the functions are copied over and only
+// the pools that may route differently are wired again. Those are the changed pools, the
+// pools of changed tokens and the pools gaining or losing a duplicate id.
+func (g *Graph) rewireChangedPools(next *Graph, diff chains.GraphDiff, wire func(i int, poolID uint64)) {
+	next.tokenToIndex = g.tokenToIndex
+	next.poolToIndex = g.poolToIndex
+	next.allGetAmountOutFuncs = slices.Clone(g.allGetAmountOutFuncs)
+	next.getReservesFuncs = slices.Clone(g.getReservesFuncs)
+	next.activeGetAmountOutFuncs = slices.Clone(g.activeGetAmountOutFuncs)
+	next.cachedGetAmountOutFuncs = slices.Clone(g.cachedGetAmountOutFuncs)
+	next.poolFees = slices.Clone(g.poolFees)
+	next.depths = slices.Clone(g.depths)
+	next.buildReport.RoutablePools = g.buildReport.RoutablePools
+
+	rewire := make(map[int]struct{})
+	add := func(poolID uint64) {
+		if i, ok := g.poolToIndex[poolID]; ok {
+			rewire[i] = struct{}{}
+		}
+	}
+	addDuplicate := func(poolID uint64) {
+		add(poolID)
+		// A pool listed twice has an index per listing, and poolToIndex holds the last.
+		if i := slices.Index(g.rawGraph.Pools, poolID); i >= 0 {
+			rewire[i] = struct{}{}
+		}
+	}
+	for _, poolID := range diff.ChangedPools {
+		add(poolID)
+	}
+	for _, tokenID := range diff.ChangedTokens {
+		poolIDs, _ := g.GetPoolsForToken(tokenID)
+		for _, poolID := range poolIDs {
+			add(poolID)
+		}
+	}
+	for poolID := range g.duplicatePools {
+		addDuplicate(poolID)
+	}
+	for poolID := range next.duplicatePools {
+		addDuplicate(poolID)
+	}
+
+	indices := make([]int, 0, len(rewire))
+	for i := range rewire {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+
+	// The report entries of the pools left alone carry over; the rewired pools record
+	// theirs again, and both lists are kept in pool order as a full build has them.
+	report := &next.buildReport
+	for _, poolID := range g.buildReport.UnknownTokenPools {
+		if _, rewired := rewire[g.poolToIndex[poolID]]; !rewired {
+			report.UnknownTokenPools = append(report.UnknownTokenPools, poolID)
+		}
+	}
+	for _, skipped := range g.buildReport.Skipped {
+		if _, rewired := rewire[g.poolToIndex[skipped.PoolID]]; !rewired {
+			report.Skipped = append(report.Skipped, skipped)
+		}
+	}
+	for _, i := range indices {
+		if next.allGetAmountOutFuncs[i] != nil {
+			report.RoutablePools--
+			next.unwirePool(i)
+		}
+		wire(i, next.rawGraph.Pools[i])
+	}
+	sort.SliceStable(report.UnknownTokenPools, func(a, b int) bool {
+		return next.poolToIndex[report.UnknownTokenPools[a]] < next.poolToIndex[report.UnknownTokenPools[b]]
+	})
+	sort.SliceStable(report.Skipped, func(a, b int) bool {
+		return next.poolToIndex[report.Skipped[a].PoolID] < next.poolToIndex[report.Skipped[b].PoolID]
+	})
+}
+
+// remapPools fills next from g when tokens or pools were added or removed, which moves
+// pools to other indices: the lookup maps are rebuilt and every pool of the new view is
+// either carried over from its old index or wired again.
+func (g *Graph) remapPools(next *Graph, diff chains.GraphDiff, wire func(i int, poolID uint64)) {
+	rawGraph := next.rawGraph
+	next.tokenToIndex = make(map[uint64]int, len(rawGraph.Tokens))
+	for i, id := range rawGraph.Tokens {
+		next.tokenToIndex[id] = i
+	}
+	next.poolToIndex = make(map[uint64]int, len(rawGraph.Pools))
+	for i, id := range rawGraph.Pools {
+		next.poolToIndex[id] = i
+	}
+	next.allGetAmountOutFuncs = make([]GetAmountOutFunc, len(rawGraph.Pools))
+	next.getReservesFuncs = make([]GetReservesFunc, len(rawGraph.Pools))
+	next.activeGetAmountOutFuncs = make([]GetAmountOutFunc, len(rawGraph.Pools))
+	next.cachedGetAmountOutFuncs = make([]GetAmountOutFromCacheFunc, len(rawGraph.Pools))
+	next.poolFees = make([]uint64, len(rawGraph.Pools))
+	next.depths = make([]*big.Int, len(rawGraph.Pools))
+
+	changed := make(map[uint64]struct{}, len(diff.ChangedPools))
+	for _, poolID := range diff.ChangedPools {
+		changed[poolID] = struct{}{}
+	}
+	for _, tokenID := range diff.ChangedTokens {
+		poolIDs, _ := next.GetPoolsForToken(tokenID)
+		for _, poolID := range poolIDs {
+			changed[poolID] = struct{}{}
+		}
+	}
+
+	for i, poolID := range rawGraph.Pools {
+		// Unchanged pools that were already routable keep their functions. Everything
+		// else, including pools skipped last time, is wired against the new views.
+		if prev, existed := g.poolToIndex[poolID]; existed && g.allGetAmountOutFuncs[prev] != nil {
+			_, isChanged := changed[poolID]
+			if _, isDuplicate := next.duplicatePools[poolID]; !isChanged && !isDuplicate {
+				if next.hasUnknownToken(poolID) {
+					next.buildReport.UnknownTokenPools = append(next.buildReport.UnknownTokenPools, poolID)
 				}
-				return reserveTokenIn, reserveTokenOut, nil
+				next.buildReport.RoutablePools++
+				next.allGetAmountOutFuncs[i] = g.allGetAmountOutFuncs[prev]
+				next.getReservesFuncs[i] = g.getReservesFuncs[prev]
+				next.activeGetAmountOutFuncs[i] = g.activeGetAmountOutFuncs[prev]
+				next.cachedGetAmountOutFuncs[i] = g.cachedGetAmountOutFuncs[prev]
+				next.poolFees[i] = g.poolFees[prev]
+				next.depths[i] = g.depths[prev]
+				continue
 			}
-			if _, ok := activePools[poolID]; ok {
-				activeGetAmountOutFuncs[i] = func(amountIn *big.Int, tokenInID, tokenOutID uint64) (*big.Int, error) {
-					return uniswapv3calculator.GetAmountOut(amountIn, nil, tokenInID, pool)
-				}
+		}
+		wire(i, poolID)
+	}
+}
+
+// unwirePool clears the functions and fee of the pool at index i.
+func (g *Graph) unwirePool(i int) {
+	g.allGetAmountOutFuncs[i] = nil
+	g.getReservesFuncs[i] = nil
+	g.activeGetAmountOutFuncs[i] = nil
+	g.cachedGetAmountOutFuncs[i] = nil
+	g.poolFees[i] = 0
+	g.depths[i] = nil
+}
+
+// priceLiquidity sets the prices reserves are valued at for MinReserveUSD: the fixed
+// PricesUSD of the graph options if set, and otherwise prices derived from the pools'
+// spot prices, walking out from the numeraire through the deepest pools first. Only
+// routable pools worth at least MinReserveUSD themselves price a token, so the pools
+// about to be pruned cannot prop each other up.
+func (g *Graph) priceLiquidity() error {
+	opts := g.graphOptions
+	if opts == nil || opts.MinReserveUSD <= 0 {
+		return nil
+	}
+
+	prices := make(map[uint64]float64)
+	if opts.PricesUSD != nil {
+		for tokenID, priceUSD := range opts.PricesUSD {
+			if decimals, ok := g.tokenDecimals(tokenID); ok {
+				prices[tokenID] = priceUSD / math.Pow10(int(decimals))
 			}
 		}
+		g.liquidityPrices = prices
+		return nil
 	}
 
-	return &Graph{
-		rawGraph:                rawGraph,
-		indexedPoolRegistry:     indexedPoolRegistry,
-		indexedUniswapV2:        indexedUniswapV2,
-		indexedUniswapV3:        indexedUniswapV3,
-		tokenToIndex:            tokenToIndex,
-		poolToIndex:             poolToIndex,
-		protocolResolver:        protocolResolver,
-		allGetAmountOutFuncs:    allGetAmountOutFuncs,
-		activeGetAmountOutFuncs: activeGetAmountOutFuncs,
-		getReservesFuncs:        getReservesFuncs,
-	}, nil
+	decimals, ok := g.tokenDecimals(opts.NumeraireTokenID)
+	if !ok {
+		return fmt.Errorf("numeraire token %d not found in the token registry", opts.NumeraireTokenID)
+	}
+	prices[opts.NumeraireTokenID] = 1 / math.Pow10(int(decimals))
+
+	candidates := &priceCandidates{}
+	// priceThrough offers the price each pool of the priced token tokenID implies for its
+	// other token, if the pool is deep enough to be trusted with it.
+	priceThrough := func(tokenID uint64) {
+		poolIDs, _ := g.GetPoolsForToken(tokenID)
+		for _, poolID := range poolIDs {
+			if ok, _ := g.canRoute(poolID); !ok || !g.meetsMinLiquidity(poolID) {
+				continue
+			}
+			tokens, err := g.GetTokensForPool(poolID)
+			if err != nil || len(tokens) != 2 {
+				continue
+			}
+			other := tokens[0]
+			if other == tokenID {
+				other = tokens[1]
+			}
+			if _, priced := prices[other]; priced {
+				continue
+			}
+			reserve, otherReserve, ok := g.reservesAtSpot(poolID, tokenID, other)
+			if !ok || reserve.Sign() <= 0 || otherReserve.Sign() <= 0 {
+				continue
+			}
+			// At the spot price both sides of the pool are worth the same.
+			held, _ := new(big.Float).SetInt(reserve).Float64()
+			otherHeld, _ := new(big.Float).SetInt(otherReserve).Float64()
+			valueUSD := 2 * held * prices[tokenID]
+			if valueUSD < opts.MinReserveUSD {
+				continue
+			}
+			heap.Push(candidates, priceCandidate{tokenID: other, price: held * prices[tokenID] / otherHeld, valueUSD: valueUSD})
+		}
+	}
+
+	priceThrough(opts.NumeraireTokenID)
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(priceCandidate)
+		if _, priced := prices[c.tokenID]; priced {
+			continue
+		}
+		prices[c.tokenID] = c.price
+		priceThrough(c.tokenID)
+	}
+	g.liquidityPrices = prices
+	return nil
+}
+
+// priceCandidate is a price for a token implied by a pool worth valueUSD.
+type priceCandidate struct {
+	tokenID  uint64
+	price    float64
+	valueUSD float64
+}
+
+// priceCandidates is a max-heap of price candidates ordered by the value of their pool.
+type priceCandidates []priceCandidate
+
+func (h priceCandidates) Len() int           { return len(h) }
+func (h priceCandidates) Less(i, j int) bool { return h[i].valueUSD > h[j].valueUSD }
+func (h priceCandidates) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *priceCandidates) Push(x any)        { *h = append(*h, x.(priceCandidate)) }
+func (h *priceCandidates) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// meetsGraphOptions reports whether a routable pool is at or above the liquidity
+// thresholds of the graph options. Reserves are valued at the graph's liquidity prices,
+// concentrated-liquidity pools by their virtual reserves; a token without a price adds
+// nothing to the value.
+func (g *Graph) meetsGraphOptions(poolID uint64) bool {
+	opts := g.graphOptions
+	if opts == nil {
+		return true
+	}
+	if !g.meetsMinLiquidity(poolID) {
+		return false
+	}
+	if opts.MinReserveUSD <= 0 {
+		return true
+	}
+
+	tokens, err := g.GetTokensForPool(poolID)
+	if err != nil || len(tokens) != 2 {
+		return false
+	}
+	reserve0, reserve1, ok := g.reservesAtSpot(poolID, tokens[0], tokens[1])
+	if !ok {
+		return false
+	}
+	valueUSD := 0.0
+	for j, reserve := range []*big.Int{reserve0, reserve1} {
+		price, ok := g.liquidityPrices[tokens[j]]
+		if !ok || reserve == nil {
+			continue // cannot be priced
+		}
+		held, _ := new(big.Float).SetInt(reserve).Float64()
+		valueUSD += held * price
+	}
+	return valueUSD >= opts.MinReserveUSD
+}
+
+// meetsMinLiquidity reports whether a concentrated-liquidity pool's active liquidity is at
+// or above MinLiquidity. Other pools always meet it.
+func (g *Graph) meetsMinLiquidity(poolID uint64) bool {
+	opts := g.graphOptions
+	if opts == nil || opts.MinLiquidity == nil || g.indexedUniswapV3 == nil {
+		return true
+	}
+	pool, ok := g.indexedUniswapV3.GetByID(poolID)
+	return !ok || (pool.Liquidity != nil && pool.Liquidity.Cmp(opts.MinLiquidity) >= 0)
+}
+
+// canRoute reports whether a pool has all the data needed to be quoted.
+// If it does not, the returned reason explains what is missing.
+func (g *Graph) canRoute(poolID uint64) (bool, chains.PoolSkipReason) {
+	if _, duplicate := g.duplicatePools[poolID]; duplicate {
+		return false, chains.SkipReasonDuplicatePoolID
+	}
+
+	if _, ok := g.indexedPoolRegistry.GetByID(poolID); !ok {
+		return false, chains.SkipReasonNotInPoolRegistry
+	}
+
+	schema, ok := g.protocolResolver.ResolveSchemaFromPoolID(poolID)
+	if !ok {
+		return false, chains.SkipReasonUnknownSchema
+	}
 
+	switch schema {
+	case uniswapv2.Schema:
+		if _, found := g.indexedUniswapV2.GetByID(poolID); !found {
+			return false, chains.SkipReasonMissingPoolData
+		}
+	case uniswapv3.Schema, uniswapv4.Schema:
+		if _, found := g.indexedUniswapV3.GetByID(poolID); !found {
+			return false, chains.SkipReasonMissingPoolData
+		}
+	case solidly.Schema:
+		if g.indexedSolidly == nil {
+			return false, chains.SkipReasonMissingPoolData
+		}
+		if _, found := g.indexedSolidly.GetByID(poolID); !found {
+			return false, chains.SkipReasonMissingPoolData
+		}
+	default:
+		return false, chains.SkipReasonUnsupportedSchema
+	}
+
+	if g.tokenFilter != nil {
+		tokens, err := g.GetTokensForPool(poolID)
+		if err != nil {
+			return false, chains.SkipReasonTokenFiltered
+		}
+		for _, tokenID := range tokens {
+			if !g.tokenFilter.allows(g.indexedTokenRegistry, tokenID) {
+				return false, chains.SkipReasonTokenFiltered
+			}
+		}
+	}
+
+	if g.unknownTokens != nil && g.unknownTokens.policy == SkipUnknownTokenPools && g.hasUnknownToken(poolID) {
+		return false, chains.SkipReasonUnknownToken
+	}
+	return true, ""
+}
+
+// hasUnknownToken reports whether any token of the pool is missing from the token registry.
+// It is always false without token metadata.
+func (g *Graph) hasUnknownToken(poolID uint64) bool {
+	if g.indexedTokenRegistry == nil {
+		return false
+	}
+	tokens, err := g.GetTokensForPool(poolID)
+	if err != nil {
+		return false
+	}
+	for _, tokenID := range tokens {
+		if _, ok := g.indexedTokenRegistry.GetByID(tokenID); !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenDecimals returns a token's decimals from the token registry. Tokens missing from
+// the registry get the default decimals when the graph routes them; otherwise ok is false.
+func (g *Graph) tokenDecimals(tokenID uint64) (uint8, bool) {
+	if g.indexedTokenRegistry == nil {
+		return 0, false
+	}
+	if token, ok := g.indexedTokenRegistry.GetByID(tokenID); ok {
+		return token.Decimals, true
+	}
+	if g.unknownTokens != nil && g.unknownTokens.policy == RouteUnknownTokensWithDefaultDecimals {
+		return g.unknownTokens.defaultDecimals, true
+	}
+	return 0, false
+}
+
+// BuildReport lists the pools of the token-pool graph that were left out of routing
+// when the graph was built, together with the reason each one was skipped.
+func (g *Graph) BuildReport() chains.GraphBuildReport {
+	report := g.buildReport
+	report.Skipped = append([]chains.SkippedPool(nil), g.buildReport.Skipped...)
+	report.UnknownTokenPools = append([]uint64(nil), g.buildReport.UnknownTokenPools...)
+	return report
 }
 
 func (g *Graph) Raw() *tokenpoolregistry.TokenPoolRegistryView {
@@ -217,221 +806,1440 @@ func (g *Graph) GetTokensForPool(poolID uint64) ([]uint64, error) {
 		if found {
 			return []uint64{pool.Token0, pool.Token1}, nil
 		}
-	case uniswapv3.Schema:
+	case uniswapv3.Schema, uniswapv4.Schema:
 		pool, found := g.indexedUniswapV3.GetByID(poolID)
 		if found {
 			return []uint64{pool.Token0, pool.Token1}, nil
 		}
+	case solidly.Schema:
+		if pool, found := g.solidlyPool(poolID); found {
+			return []uint64{pool.Token0, pool.Token1}, nil
+		}
 	}
 
 	return nil, nil
 }
 
-// findConversionPathState encapsulates the state required for the Bellman-Ford-like
-// pathfinding algorithm used in GetExchangeRates.
-type findConversionPathState struct {
-	start                    int                      // starting vertex index
-	current                  int                      // current vertex index being processed
-	paths                    [][]chains.TokenPoolPath // vertex index -> path to this token
-	costs                    []*big.Int               // vertex index -> cost
-	reserves                 []*big.Int               // vertex index -> reserve
-	known                    []bitset.BitSet          // vertex index -> vertex index
-	bestConnection           []int                    // edge index -> pool index
-	bestConnectionComputed   bitset.BitSet            // edge index -> whether the best connection has been computed
-	reserveForBestConnection []*big.Int               // edge index -> reserve for the best connection
-	temp                     *big.Int
+// ShortestPath returns a path from tokenIn to tokenOut with the fewest hops, found by a
+// breadth-first search over the routable pools, regardless of price. Where several pools
+// share an edge, the one with the lowest pool ID is used. It returns an empty path if
+// tokenIn and tokenOut are the same token, and nil if tokenOut cannot be reached.
+// Use FindBestSwapPath for the best-priced route.
+func (g *Graph) ShortestPath(tokenIn, tokenOut uint64) []chains.TokenPoolPath {
+	startIndex, ok := g.tokenToIndex[tokenIn]
+	if !ok {
+		return nil
+	}
+	endIndex, ok := g.tokenToIndex[tokenOut]
+	if !ok {
+		return nil
+	}
+	if startIndex == endIndex {
+		return []chains.TokenPoolPath{}
+	}
+
+	// prevHop[v] is the hop that first reached token index v.
+	prevHop := make([]chains.TokenPoolPath, len(g.rawGraph.Tokens))
+	visited := bitset.NewBitSet(uint64(len(g.rawGraph.Tokens)))
+	visited.Set(uint64(startIndex))
+	queue := []int{startIndex}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, edgeIndex := range g.rawGraph.Adjacency[current] {
+			target := g.rawGraph.EdgeTargets[edgeIndex]
+			if visited.IsSet(uint64(target)) {
+				continue
+			}
+			poolID, ok := g.lowestRoutablePool(edgeIndex)
+			if !ok {
+				continue
+			}
+			visited.Set(uint64(target))
+			prevHop[target] = chains.TokenPoolPath{
+				TokenInID:  g.rawGraph.Tokens[current],
+				TokenOutID: g.rawGraph.Tokens[target],
+				PoolID:     poolID,
+			}
+			if target == endIndex {
+				return g.unwindHops(prevHop, startIndex, endIndex)
+			}
+			queue = append(queue, target)
+		}
+	}
+	return nil
 }
 
-// GetExchangeRates calculates the equivalent value of a given amount of a base token
-// across all other tokens in the graph using a Bellman-Ford-like algorithm.
-// It can be constrained to only propagate prices from a specific set of allowed source tokens.
-func (g *Graph) GetExchangeRates(
-	baseAmountIn *big.Int,
-	baseTokenID uint64,
-	runs int,
-	allowedSourceTokens map[uint64]struct{}, // New parameter
-) (map[uint64]*big.Int, error) {
-
-	// Step 1: Find the internal index for the starting token.
-	baseIndex, exists := g.tokenToIndex[baseTokenID]
-	if !exists {
-		return nil, fmt.Errorf("token %d not found in the graph", baseTokenID)
+// lowestRoutablePool returns the lowest ID of the routable pools on an edge.
+func (g *Graph) lowestRoutablePool(edgeIndex int) (uint64, bool) {
+	var best uint64
+	found := false
+	for _, poolIndex := range g.rawGraph.EdgePools[edgeIndex] {
+		if g.allGetAmountOutFuncs[poolIndex] == nil {
+			continue
+		}
+		if poolID := g.rawGraph.Pools[poolIndex]; !found || poolID < best {
+			best, found = poolID, true
+		}
 	}
+	return best, found
+}
 
-	// Step 2: Initialize the state for the pathfinding search.
-	numTokens := len(g.rawGraph.Tokens)
-	numEdges := len(g.rawGraph.EdgePools)
+// unwindHops follows prevHop back from endIndex to startIndex and returns the hops in order.
+func (g *Graph) unwindHops(prevHop []chains.TokenPoolPath, startIndex, endIndex int) []chains.TokenPoolPath {
+	var path []chains.TokenPoolPath
+	for current := endIndex; current != startIndex; current = g.tokenToIndex[prevHop[current].TokenInID] {
+		path = append(path, prevHop[current])
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
 
-	state := &findConversionPathState{
-		start:                    baseIndex,
-		paths:                    make([][]chains.TokenPoolPath, numTokens),
-		costs:                    make([]*big.Int, numTokens),
-		known:                    make([]bitset.BitSet, numTokens),
-		bestConnection:           make([]int, numEdges),
-		bestConnectionComputed:   bitset.NewBitSet(uint64(numEdges)),
-		reserveForBestConnection: make([]*big.Int, numEdges),
-		reserves:                 make([]*big.Int, numTokens),
-		temp:                     bigIntPool.Get().(*big.Int).SetUint64(0), // Get from pool
+// GetPoolState returns the protocol state of a pool together with its schema. The value
+// is a uniswapv2.Pool, a uniswapv3.Pool or a solidly.Pool depending on the schema, V4
+// pools being held in V3 form; it is the indexed
+// value itself, so callers must not mutate its big.Int fields. It returns false if the
+// pool's schema cannot be resolved or the pool is missing from the protocol state.
+func (g *Graph) GetPoolState(poolID uint64) (engine.ProtocolSchema, any, bool) {
+	schema, ok := g.protocolResolver.ResolveSchemaFromPoolID(poolID)
+	if !ok {
+		return "", nil, false
 	}
 
-	// This defer block ensures all temporary, pooled objects are returned.
-	defer func() {
-		bigIntPool.Put(state.temp.SetUint64(0))
-		for _, r := range state.reserves {
-			if r != nil {
-				bigIntPool.Put(r.SetUint64(0))
-			}
+	switch schema {
+	case uniswapv2.Schema:
+		if pool, found := g.indexedUniswapV2.GetByID(poolID); found {
+			return schema, pool, true
 		}
-		for _, r := range state.reserveForBestConnection {
-			if r != nil {
-				bigIntPool.Put(r.SetUint64(0))
-			}
+	case uniswapv3.Schema, uniswapv4.Schema:
+		if pool, found := g.indexedUniswapV3.GetByID(poolID); found {
+			return schema, pool, true
 		}
-	}()
+	case solidly.Schema:
+		if pool, found := g.solidlyPool(poolID); found {
+			return schema, pool, true
+		}
+	}
+	return "", nil, false
+}
 
-	for i := range numTokens {
-		state.known[i] = bitset.NewBitSet(uint64(numTokens))
-		// Rent from pool for temporary state
-		state.reserves[i] = bigIntPool.Get().(*big.Int).SetUint64(0) // ensure zero value
-		// Allocate new for returned data
-		state.costs[i] = new(big.Int)
+// solidlyPool looks a pool up in the Solidly index, which is absent on graphs built
+// without one.
+func (g *Graph) solidlyPool(poolID uint64) (solidly.Pool, bool) {
+	if g.indexedSolidly == nil {
+		return solidly.Pool{}, false
 	}
-	state.costs[baseIndex].Set(baseAmountIn)
-	for i := range numEdges {
-		state.bestConnection[i] = -1 // -1 indicates no best connection yet
-		// Rent from pool for temporary state
-		state.reserveForBestConnection[i] = bigIntPool.Get().(*big.Int).SetUint64(0) // ensure zero value
+	return g.indexedSolidly.GetByID(poolID)
+}
+
+// HasTokenMetadata reports whether the graph was built with a token registry.
+// Without one, routing still works on token IDs but symbols and decimals are unknown.
+func (g *Graph) HasTokenMetadata() bool {
+	return g.indexedTokenRegistry != nil
+}
+
+// TokenLabel returns a display label for a token: its symbol when token metadata is
+// available, or "ID:<id>" otherwise.
+func (g *Graph) TokenLabel(tokenID uint64) string {
+	if g.indexedTokenRegistry != nil {
+		if token, ok := g.indexedTokenRegistry.GetByID(tokenID); ok && token.Symbol != "" {
+			return token.Symbol
+		}
 	}
+	return fmt.Sprintf("ID:%d", tokenID)
+}
 
-	// Step 3: Iteratively "relax" the edges for a set number of runs.
-	for i := 0; i < runs; i++ {
-		for j := 0; j < numTokens; j++ {
-			if state.costs[j].Sign() == 0 {
-				continue // Skip tokens that haven't been reached yet.
-			}
+// PoolTokenDecimals resolves the decimals of a pool's token0 and token1 via the token registry.
+// It returns false if the pool or either of its tokens cannot be resolved.
+func (g *Graph) PoolTokenDecimals(poolID uint64) (uint8, uint8, bool) {
+	if g.indexedTokenRegistry == nil {
+		return 0, 0, false
+	}
 
-			// Convert the internal index to the external token ID.
-			currentTokenID := g.rawGraph.Tokens[j]
-			// If a set of allowed source tokens is provided, check if the current
-			// token is in that set before allowing it to propagate its price.
-			if allowedSourceTokens != nil {
-				if _, isAllowed := allowedSourceTokens[currentTokenID]; !isAllowed {
-					continue // This token is not allowed to be a source.
-				}
-			}
+	tokens, err := g.GetTokensForPool(poolID)
+	if err != nil || len(tokens) != 2 {
+		return 0, 0, false
+	}
 
-			state.current = j
-			if err := g.getExchangeRatesUsingMaxReservePath(state); err != nil {
-				return nil, err
-			}
-		}
+	decimals0, ok := g.tokenDecimals(tokens[0])
+	if !ok {
+		return 0, 0, false
 	}
+	decimals1, ok := g.tokenDecimals(tokens[1])
+	if !ok {
+		return 0, 0, false
+	}
+	return decimals0, decimals1, true
+}
 
-	// Step 4: Convert the final costs slice back to a map for the user.
-	finalExchangeRates := make(map[uint64]*big.Int, len(state.costs))
-	for i, cost := range state.costs {
-		if cost.Sign() != 0 {
-			tokenID := g.rawGraph.Tokens[i]
-			finalExchangeRates[tokenID] = cost
+// USDPrice returns the USD price of one whole params.TokenID by routing params.AmountIn to
+// the anchor stables, each valued at exactly one USD. In USDPriceMedian mode every stable
+// that can be reached contributes a quote and the median is returned.
+func (g *Graph) USDPrice(params chains.USDPriceParams) (*big.Float, error) {
+	if g.indexedTokenRegistry == nil {
+		return nil, errors.New("USD pricing requires token metadata")
+	}
+	if len(params.StableTokenIDs) == 0 {
+		return nil, errors.New("USDPriceParams: at least one stable token is required")
+	}
+	if params.AmountIn == nil || params.AmountIn.Sign() <= 0 {
+		return nil, errors.New("USDPriceParams: amount in must be positive")
+	}
+	decimals, ok := g.tokenDecimals(params.TokenID)
+	if !ok {
+		return nil, fmt.Errorf("token %d not found in the token registry", params.TokenID)
+	}
+	amountIn := toWholeUnits(params.AmountIn, decimals)
+
+	var prices []*big.Float
+	for _, stableID := range params.StableTokenIDs {
+		price, ok := g.anchorPrice(params, stableID, amountIn)
+		if !ok {
+			continue // this stable cannot be reached; try the others
+		}
+		prices = append(prices, price)
+		if params.Mode == chains.USDPriceSingleAnchor {
+			break
 		}
 	}
 
-	// ensure baseToken equivalent equal to baseAmountIn
-	finalExchangeRates[baseTokenID] = new(big.Int).Set(baseAmountIn)
-	return finalExchangeRates, nil
+	if len(prices) == 0 {
+		return nil, fmt.Errorf("%w: token %d to any anchor stable", chains.ErrNoRoute, params.TokenID)
+	}
+	return median(prices), nil
 }
 
-// getExchangeRatesUsingMaxReservePath is the core of the algorithm. It uses the
-// pre-computed swap functions for maximum performance.
-// it sets connections based on maxReserve
-func (g *Graph) getExchangeRatesUsingMaxReservePath(
-	state *findConversionPathState,
-) error {
-	currentIndex := state.current
-	currentCost := state.costs[currentIndex]
-	currentKnown := state.known[currentIndex]
-	currentPath := state.paths[currentIndex]
-	currentTokenID := g.rawGraph.Tokens[currentIndex]
+// anchorPrice prices params.TokenID in a single stable, given the input in whole tokens.
+func (g *Graph) anchorPrice(params chains.USDPriceParams, stableID uint64, amountIn *big.Float) (*big.Float, bool) {
+	if stableID == params.TokenID {
+		return big.NewFloat(1), true
+	}
+	stableDecimals, ok := g.tokenDecimals(stableID)
+	if !ok {
+		return nil, false
+	}
+	_, amountOut, err := g.FindBestSwapPath(chains.SwapFindingParams{
+		AmountIn:   params.AmountIn,
+		TokenInID:  params.TokenID,
+		TokenOutID: stableID,
+		Runs:       params.Runs,
+	})
+	if err != nil {
+		return nil, false
+	}
+	return new(big.Float).Quo(toWholeUnits(amountOut, stableDecimals), amountIn), true
+}
 
-	if currentKnown.IsSet(uint64(currentIndex)) {
-		// we should never get here!
-		return errors.New("cycle detected in path history")
+// defaultQuoteRuns is the maximum number of hops RateForSize and QuoteBothDirections
+// route through.
+const defaultQuoteRuns = 3
+
+// RateForSize routes the exact amountIn from tokenInID to tokenOutID and returns the
+// effective rate amountOut/amountIn, so price impact of the trade size is included.
+// Unlike the probe-based rates of GetExchangeRates, the rate degrades as the size grows.
+// With token metadata the rate is in whole tokens (e.g. USDC per WETH); without it,
+// it is in raw units.
+func (g *Graph) RateForSize(tokenInID, tokenOutID uint64, amountIn *big.Int) (*big.Float, error) {
+	if amountIn == nil || amountIn.Sign() <= 0 {
+		return nil, errors.New("amount in must be positive")
 	}
 
-	// Iterate through all outgoing edges from the current token.
-	for _, edgeIndex := range g.rawGraph.Adjacency[currentIndex] {
-		targetIndex := g.rawGraph.EdgeTargets[edgeIndex]
-		targetTokenID := g.rawGraph.Tokens[targetIndex]
+	_, amountOut, err := g.FindBestSwapPath(chains.SwapFindingParams{
+		AmountIn:   amountIn,
+		TokenInID:  tokenInID,
+		TokenOutID: tokenOutID,
+		Runs:       defaultQuoteRuns,
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		// Crucial cycle prevention: do not traverse to a token that is already in the current path.
-		if currentKnown.IsSet(uint64(targetIndex)) {
-			continue
+	if g.indexedTokenRegistry == nil {
+		return new(big.Float).Quo(new(big.Float).SetInt(amountOut), new(big.Float).SetInt(amountIn)), nil
+	}
+	decimalsIn, ok := g.tokenDecimals(tokenInID)
+	if !ok {
+		return nil, fmt.Errorf("token %d not found in the token registry", tokenInID)
+	}
+	decimalsOut, ok := g.tokenDecimals(tokenOutID)
+	if !ok {
+		return nil, fmt.Errorf("token %d not found in the token registry", tokenOutID)
+	}
+	return new(big.Float).Quo(toWholeUnits(amountOut, decimalsOut), toWholeUnits(amountIn, decimalsIn)), nil
+}
+
+// QuoteBothDirections quotes amount of tokenAID into tokenBID and then sells that output
+// back into tokenAID, each leg along its best route. Showing both legs together makes the
+// bid/ask spread of the pair visible; see TwoWayQuote.Spread.
+func (g *Graph) QuoteBothDirections(tokenAID, tokenBID uint64, amount *big.Int) (*chains.TwoWayQuote, error) {
+	if amount == nil || amount.Sign() <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+
+	forward, forwardOut, err := g.FindBestSwapPath(chains.SwapFindingParams{
+		AmountIn:   amount,
+		TokenInID:  tokenAID,
+		TokenOutID: tokenBID,
+		Runs:       defaultQuoteRuns,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("quoting %d -> %d: %w", tokenAID, tokenBID, err)
+	}
+
+	reverse, reverseOut, err := g.FindBestSwapPath(chains.SwapFindingParams{
+		AmountIn:   forwardOut,
+		TokenInID:  tokenBID,
+		TokenOutID: tokenAID,
+		Runs:       defaultQuoteRuns,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("quoting %d -> %d: %w", tokenBID, tokenAID, err)
+	}
+
+	return &chains.TwoWayQuote{
+		AmountIn:   new(big.Int).Set(amount),
+		Forward:    forward,
+		ForwardOut: forwardOut,
+		Reverse:    reverse,
+		ReverseOut: reverseOut,
+	}, nil
+}
+
+// SplitSlippage measures a trade split across several routes as a whole. Slippage is not
+// additive across legs: each leg moves its own pools, and the legs share one reference,
+// the best pre-trade spot price among their routes, which is what an infinitesimal trade
+// would get. The blended price is the summed output over the summed input. Prices are in
+// whole tokens with token metadata and in raw units without it. Every leg must go from
+// the same input token to the same output token through pools.
+func (g *Graph) SplitSlippage(legs []chains.SplitLeg) (*chains.SplitSlippage, error) {
+	if len(legs) == 0 {
+		return nil, errors.New("split route has no legs")
+	}
+	first := legs[0].Path
+	if len(first) == 0 {
+		return nil, errors.New("leg 0: route is empty")
+	}
+	tokenInID, tokenOutID := first[0].TokenInID, first[len(first)-1].TokenOutID
+
+	result := &chains.SplitSlippage{AmountIn: new(big.Int), AmountOut: new(big.Int)}
+	var rawSpot *big.Float
+	for i, leg := range legs {
+		if len(leg.Path) == 0 {
+			return nil, fmt.Errorf("leg %d: route is empty", i)
 		}
+		if leg.Path[0].TokenInID != tokenInID || leg.Path[len(leg.Path)-1].TokenOutID != tokenOutID {
+			return nil, fmt.Errorf("leg %d: route does not go from token %d to token %d", i, tokenInID, tokenOutID)
+		}
+		if leg.AmountIn == nil || leg.AmountIn.Sign() <= 0 {
+			return nil, fmt.Errorf("leg %d: amount in must be positive", i)
+		}
+		if leg.AmountOut == nil || leg.AmountOut.Sign() < 0 {
+			return nil, fmt.Errorf("leg %d: amount out must not be negative", i)
+		}
+		spot, err := g.routeSpotPrice(leg.Path)
+		if err != nil {
+			return nil, fmt.Errorf("leg %d: %w", i, err)
+		}
+		if rawSpot == nil || spot.Cmp(rawSpot) > 0 {
+			rawSpot = spot
+		}
+		result.AmountIn.Add(result.AmountIn, leg.AmountIn)
+		result.AmountOut.Add(result.AmountOut, leg.AmountOut)
+	}
 
-		bestReserve := state.temp
+	scale, err := g.priceScale(tokenInID, tokenOutID)
+	if err != nil {
+		return nil, err
+	}
+	result.SpotPrice = rawSpot.Mul(rawSpot, scale)
+	result.EffectivePrice = new(big.Float).Quo(new(big.Float).SetInt(result.AmountOut), new(big.Float).SetInt(result.AmountIn))
+	result.EffectivePrice.Mul(result.EffectivePrice, scale)
+	result.Slippage = new(big.Float).Quo(result.EffectivePrice, result.SpotPrice)
+	result.Slippage.Sub(big.NewFloat(1), result.Slippage)
+	return result, nil
+}
 
-		if !state.bestConnectionComputed.IsSet(uint64(edgeIndex)) {
-			// Iterate through all pools associated with this edge.
-			bestConnection := -1
-			bestReserve.SetUint64(0)
-			for _, poolIndex := range g.rawGraph.EdgePools[edgeIndex] {
-				getReserveFunc := g.getReservesFuncs[poolIndex]
-				// can be nil - @todo fix this
-				if getReserveFunc == nil {
-					continue
-				}
-				_, reserveOut, err := getReserveFunc(currentTokenID, targetTokenID)
-				if err != nil {
-					continue
-				}
+// Defaults of SplitRouteParams.
+const (
+	defaultSplitRoutes = 4
+	defaultSplitParts  = 20
+)
 
-				// we need the reserveOut
-				if reserveOut.Cmp(bestReserve) == 1 {
-					bestReserve.Set(reserveOut)
-					bestConnection = poolIndex
-				}
-			}
+// FindSplitRoute splits a trade across parallel routes of active pools. The candidates are
+// the best route for the whole amount, then the best route for one part of it among the
+// pools no earlier candidate uses, until params.MaxRoutes are found. Keeping the routes
+// pool-disjoint makes every leg's quote independent of the others. The input is then
+// handed out part by part, each part going to the route whose output grows the most.
+// Since AMM output is concave in the input, this greedy allocation is the best one at
+// the granularity of params.Parts. The split is never worse than the single best route.
+func (g *Graph) FindSplitRoute(params chains.SplitRouteParams) (*chains.SplitRoute, error) {
+	if params.AmountIn == nil || params.AmountIn.Sign() <= 0 {
+		return nil, errors.New("SplitRouteParams: amountIn must be greater than 0")
+	}
+	if params.Runs <= 0 {
+		return nil, errors.New("SplitRouteParams: runs must be greater than 0")
+	}
+	if params.MaxRoutes < 0 || params.Parts < 0 {
+		return nil, errors.New("SplitRouteParams: maxRoutes and parts must not be negative")
+	}
+	maxRoutes, parts := params.MaxRoutes, params.Parts
+	if maxRoutes == 0 {
+		maxRoutes = defaultSplitRoutes
+	}
+	if parts == 0 {
+		parts = defaultSplitParts
+	}
+	if params.AmountIn.IsInt64() && params.AmountIn.Int64() < int64(parts) {
+		parts = int(params.AmountIn.Int64())
+	}
+	part := new(big.Int).Div(params.AmountIn, big.NewInt(int64(parts)))
 
-			if bestConnection != -1 {
-				// we have found a best connection for this edge (the pool with the highest reserve for targetID)
-				state.bestConnection[edgeIndex] = bestConnection
-				state.bestConnectionComputed.Set(uint64(edgeIndex))
-				state.reserveForBestConnection[edgeIndex].Set(bestReserve)
+	// Candidate pools are removed from funcs as they are taken; it is a private copy.
+	funcs := g.applyOverrides(g.activeGetAmountOutFuncs, nil, nil)
+	search := chains.SwapFindingParams{
+		AmountIn:   params.AmountIn,
+		TokenInID:  params.TokenInID,
+		TokenOutID: params.TokenOutID,
+		Runs:       params.Runs,
+	}
+	best, singlePathOut, err := g.findBestSwapPath(context.Background(), search, funcs)
+	if err != nil {
+		return nil, err
+	}
+	routes := [][]chains.TokenPoolPath{best}
+	search.AmountIn = part
+	for len(routes) < maxRoutes {
+		for _, hop := range routes[len(routes)-1] {
+			funcs[g.poolToIndex[hop.PoolID]] = nil
+		}
+		route, _, err := g.findBestSwapPath(context.Background(), search, funcs)
+		if err != nil {
+			break
+		}
+		routes = append(routes, route)
+	}
+
+	// The remainder of the division rides with the first part.
+	allocated := make([]*big.Int, len(routes))
+	outputs := make([]*big.Int, len(routes))
+	for i := range routes {
+		allocated[i], outputs[i] = new(big.Int), new(big.Int)
+	}
+	chunk := new(big.Int).Sub(params.AmountIn, new(big.Int).Mul(part, big.NewInt(int64(parts-1))))
+	gain := new(big.Int)
+	for p := 0; p < parts; p++ {
+		bestRoute := -1
+		var bestGain, bestOut *big.Int
+		for i, route := range routes {
+			out, err := g.quoteRoute(route, new(big.Int).Add(allocated[i], chunk))
+			if err != nil {
+				continue
 			}
+			gain.Sub(out, outputs[i])
+			if bestRoute == -1 || gain.Cmp(bestGain) > 0 {
+				bestRoute, bestGain, bestOut = i, new(big.Int).Set(gain), out
+			}
+		}
+		if bestRoute == -1 {
+			return nil, fmt.Errorf("%w: token %d to token %d cannot absorb %s", chains.ErrNoRoute, params.TokenInID, params.TokenOutID, params.AmountIn)
 		}
+		allocated[bestRoute].Add(allocated[bestRoute], chunk)
+		outputs[bestRoute] = bestOut
+		chunk = part
+	}
 
-		if state.bestConnection[edgeIndex] != -1 {
-			poolIndex := state.bestConnection[edgeIndex]
-			reserve := state.reserveForBestConnection[edgeIndex]
+	result := &chains.SplitRoute{AmountOut: new(big.Int), SinglePathOut: singlePathOut}
+	for i, route := range routes {
+		if allocated[i].Sign() == 0 {
+			continue
+		}
+		result.Legs = append(result.Legs, chains.SplitLeg{Path: route, AmountIn: allocated[i], AmountOut: outputs[i]})
+		result.AmountOut.Add(result.AmountOut, outputs[i])
+	}
+	if result.AmountOut.Cmp(singlePathOut) < 0 {
+		// Rounding can make a coarse split lose to the single route by a few units.
+		result.Legs = []chains.SplitLeg{{Path: best, AmountIn: new(big.Int).Set(params.AmountIn), AmountOut: new(big.Int).Set(singlePathOut)}}
+		result.AmountOut = new(big.Int).Set(singlePathOut)
+	}
+	return result, nil
+}
 
-			if state.reserves[targetIndex].Cmp(reserve) == -1 {
-				amountOut, err := g.allGetAmountOutFuncs[poolIndex](currentCost, currentTokenID, targetTokenID)
-				if err != nil || amountOut == nil || amountOut.Sign() <= 0 {
-					continue
-				}
+// routeSpotPrice is the raw spot price of a route's input token in its output token: the
+// product of the pool prices of its hops, fees excluded.
+func (g *Graph) routeSpotPrice(path []chains.TokenPoolPath) (*big.Float, error) {
+	price := big.NewFloat(1)
+	for i, hop := range path {
+		if i > 0 && hop.TokenInID != path[i-1].TokenOutID {
+			return nil, fmt.Errorf("hop %d does not start where hop %d ends", i, i-1)
+		}
+		reserveIn, reserveOut, ok := g.spotReserves(hop.PoolID, hop.TokenInID, hop.TokenOutID)
+		if !ok || reserveIn.Sign() == 0 || reserveOut.Sign() == 0 {
+			return nil, fmt.Errorf("hop %d: pool %d cannot be priced from token %d to token %d", i, hop.PoolID, hop.TokenInID, hop.TokenOutID)
+		}
+		price.Mul(price, new(big.Float).SetInt(reserveOut))
+		price.Quo(price, new(big.Float).SetInt(reserveIn))
+	}
+	return price, nil
+}
 
-				state.costs[targetIndex].Set(amountOut)
-				poolID := g.rawGraph.Pools[poolIndex]
-				newPath := make([]chains.TokenPoolPath, len(currentPath)+1)
-				copy(newPath, currentPath)
-				newPath[len(currentPath)] = chains.TokenPoolPath{
-					TokenInID:  currentTokenID,
-					TokenOutID: targetTokenID,
-					PoolID:     poolID,
-				}
-				state.paths[targetIndex] = newPath
-				state.known[targetIndex].SetFrom(currentKnown)
-				state.known[targetIndex].Set(uint64(currentIndex))
-				state.reserves[targetIndex].Set(reserve)
-			}
+// QuoteRoute quotes amountIn hop by hop along path through routable pools, reporting
+// each pool's spot price before and after its hop. The amounts match the quote
+// FindBestSwapPath returns for the same route without overrides. Spot prices come from the
+// pool reserves, the virtual ones for Uniswap V3, like SplitSlippage's; a Solidly stable
+// pool's reserve ratio is not its curve's marginal price.
+func (g *Graph) QuoteRoute(path []chains.TokenPoolPath, amountIn *big.Int) (*chains.RouteQuote, error) {
+	if len(path) == 0 {
+		return nil, errors.New("route is empty")
+	}
+	if amountIn == nil || amountIn.Sign() <= 0 {
+		return nil, errors.New("amountIn must be greater than 0")
+	}
+
+	quote := &chains.RouteQuote{AmountIn: new(big.Int).Set(amountIn), Hops: make([]chains.HopQuote, len(path))}
+	amount := quote.AmountIn
+	routeSpot := big.NewFloat(1) // raw
+	for i, hop := range path {
+		if i > 0 && hop.TokenInID != path[i-1].TokenOutID {
+			return nil, fmt.Errorf("hop %d starts at token %d but hop %d ends at token %d", i, hop.TokenInID, i-1, path[i-1].TokenOutID)
+		}
+		amountOut, err := g.quoteRoute(path[i:i+1], amount)
+		if err != nil {
+			return nil, fmt.Errorf("hop %d: %w", i, err)
+		}
+		reserveIn, reserveOut, ok := g.spotReserves(hop.PoolID, hop.TokenInID, hop.TokenOutID)
+		if !ok || reserveIn.Sign() == 0 || reserveOut.Sign() == 0 {
+			return nil, fmt.Errorf("hop %d: pool %d cannot be priced from token %d to token %d", i, hop.PoolID, hop.TokenInID, hop.TokenOutID)
+		}
+		spotBefore := new(big.Float).Quo(new(big.Float).SetInt(reserveOut), new(big.Float).SetInt(reserveIn))
+		spotAfter, err := g.spotPriceAfter(hop, amount, amountOut)
+		if err != nil {
+			return nil, fmt.Errorf("hop %d: %w", i, err)
+		}
+		routeSpot.Mul(routeSpot, spotBefore)
 
+		scale, err := g.priceScale(hop.TokenInID, hop.TokenOutID)
+		if err != nil {
+			return nil, err
 		}
+		quote.Hops[i] = chains.HopQuote{
+			TokenPoolPath:         hop,
+			AmountIn:              amount,
+			AmountOut:             amountOut,
+			SpotPriceBefore:       new(big.Float).Mul(spotBefore, scale),
+			SpotPriceAfter:        spotAfter.Mul(spotAfter, scale),
+			PriceImpact:           priceImpact(amount, amountOut, spotBefore),
+			CumulativePriceImpact: priceImpact(quote.AmountIn, amountOut, routeSpot),
+		}
+		amount = amountOut
 	}
-	return nil
+	quote.AmountOut = amount
+	quote.PriceImpact = quote.Hops[len(path)-1].CumulativePriceImpact
+	return quote, nil
 }
 
-// getExchangeRatesUsingMaxAmountOutPath is the core of the algorithm. It uses the
-// pre-computed swap functions for maximum performance.
+// priceImpact is 1 - (amountOut/amountIn)/spot, with spot a raw price.
+func priceImpact(amountIn, amountOut *big.Int, spot *big.Float) *big.Float {
+	impact := new(big.Float).Quo(new(big.Float).SetInt(amountOut), new(big.Float).SetInt(amountIn))
+	impact.Quo(impact, spot)
+	return impact.Sub(big.NewFloat(1), impact)
+}
+
+// spotPriceAfter returns the raw spot price of a hop's pool once amountIn has been swapped
+// through it for amountOut, fees excluded.
+func (g *Graph) spotPriceAfter(hop chains.TokenPoolPath, amountIn, amountOut *big.Int) (*big.Float, error) {
+	schema, _ := g.protocolResolver.ResolveSchemaFromPoolID(hop.PoolID)
+	var reserveIn, reserveOut *big.Int
+	switch schema {
+	case uniswapv2.Schema:
+		pool, _ := g.indexedUniswapV2.GetByID(hop.PoolID)
+		_, after, err := uniswapv2calculator.SimulateSwap(amountIn, hop.TokenInID, hop.TokenOutID, pool)
+		if err != nil {
+			return nil, err
+		}
+		if reserveIn, reserveOut, err = uniswapv2calculator.GetReserves(hop.TokenInID, hop.TokenOutID, after); err != nil {
+			return nil, err
+		}
+	case uniswapv3.Schema, uniswapv4.Schema:
+		pool, _ := g.indexedUniswapV3.GetByID(hop.PoolID)
+		return uniswapv3calculator.MarginalPriceAfter(amountIn, hop.TokenInID, pool)
+	case solidly.Schema:
+		pool, _ := g.solidlyPool(hop.PoolID)
+		before, beforeOut, err := solidlycalculator.GetReserves(hop.TokenInID, hop.TokenOutID, pool)
+		if err != nil {
+			return nil, err
+		}
+		// The fee leaves the pool, so only the rest of the input joins the reserves.
+		fee := new(big.Int).Mul(amountIn, big.NewInt(int64(pool.FeeBps)))
+		fee.Div(fee, big.NewInt(10_000))
+		reserveIn = new(big.Int).Add(before, amountIn)
+		reserveIn.Sub(reserveIn, fee)
+		reserveOut = new(big.Int).Sub(beforeOut, amountOut)
+	default:
+		return nil, fmt.Errorf("pool %d has unsupported schema %s", hop.PoolID, schema)
+	}
+	if reserveIn.Sign() == 0 || reserveOut.Sign() <= 0 {
+		return nil, fmt.Errorf("pool %d is drained by the hop", hop.PoolID)
+	}
+	return new(big.Float).Quo(new(big.Float).SetInt(reserveOut), new(big.Float).SetInt(reserveIn)), nil
+}
+
+// PortfolioValue values each balance, keyed by token ID, in baseTokenID with RateForSize,
+// so the price impact of selling the whole balance is included, and sums the values.
+// Tokens with no route to the base token, including tokens absent from the graph, are
+// listed in Unpriced instead of failing the call. Zero balances are ignored.
+func (g *Graph) PortfolioValue(balances map[uint64]*big.Int, baseTokenID uint64) (*chains.PortfolioValue, error) {
+	if _, ok := g.tokenToIndex[baseTokenID]; !ok {
+		return nil, fmt.Errorf("base token %d not found in the graph", baseTokenID)
+	}
+	tokenIDs := make([]uint64, 0, len(balances))
+	for tokenID, balance := range balances {
+		if balance == nil || balance.Sign() == 0 {
+			continue
+		}
+		if balance.Sign() < 0 {
+			return nil, fmt.Errorf("balance of token %d is negative", tokenID)
+		}
+		tokenIDs = append(tokenIDs, tokenID)
+	}
+	sort.Slice(tokenIDs, func(i, j int) bool { return tokenIDs[i] < tokenIDs[j] })
+
+	portfolio := &chains.PortfolioValue{
+		Total:  new(big.Float),
+		Values: make(map[uint64]*big.Float, len(tokenIDs)),
+	}
+	for _, tokenID := range tokenIDs {
+		rate := big.NewFloat(1)
+		if tokenID != baseTokenID {
+			if _, ok := g.tokenToIndex[tokenID]; !ok {
+				portfolio.Unpriced = append(portfolio.Unpriced, tokenID)
+				continue
+			}
+			var err error
+			rate, err = g.RateForSize(tokenID, baseTokenID, balances[tokenID])
+			if errors.Is(err, chains.ErrNoRoute) {
+				portfolio.Unpriced = append(portfolio.Unpriced, tokenID)
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("token %d: %w", tokenID, err)
+			}
+		}
+		value, err := g.displayAmount(tokenID, balances[tokenID])
+		if err != nil {
+			return nil, err
+		}
+		value.Mul(value, rate)
+		portfolio.Values[tokenID] = value
+		portfolio.Total.Add(portfolio.Total, value)
+	}
+	return portfolio, nil
+}
+
+// displayAmount converts a raw amount of tokenID to whole tokens with token metadata and
+// leaves it in raw units without it, the units RateForSize quotes in.
+func (g *Graph) displayAmount(tokenID uint64, amount *big.Int) (*big.Float, error) {
+	if g.indexedTokenRegistry == nil {
+		return new(big.Float).SetInt(amount), nil
+	}
+	decimals, ok := g.tokenDecimals(tokenID)
+	if !ok {
+		return nil, fmt.Errorf("token %d not found in the token registry", tokenID)
+	}
+	return toWholeUnits(amount, decimals), nil
+}
+
+// WeightedPrice returns the price of one tokenID in baseTokenID as the liquidity-weighted
+// average of the spot prices of every routable pool that holds both tokens. A pool's
+// weight is its depth, sqrt(reserve0 * reserve1), which a swap through the pool leaves
+// unchanged, so a thin pool pushed to an extreme price barely moves the result. Uniswap V3
+// pools are priced from their virtual reserves. Like RateForSize, the price is in whole
+// tokens with token metadata and in raw units without it; fees are not included.
+func (g *Graph) WeightedPrice(tokenID, baseTokenID uint64) (*big.Float, error) {
+	if tokenID == baseTokenID {
+		return big.NewFloat(1), nil
+	}
+	price, err := g.rawWeightedPrice(tokenID, baseTokenID)
+	if err != nil {
+		return nil, err
+	}
+	scale, err := g.priceScale(tokenID, baseTokenID)
+	if err != nil {
+		return nil, err
+	}
+	return price.Mul(price, scale), nil
+}
+
+// rawWeightedPrice is WeightedPrice in raw units, regardless of token metadata.
+func (g *Graph) rawWeightedPrice(tokenID, baseTokenID uint64) (*big.Float, error) {
+	poolIDs, err := g.GetPoolsForToken(tokenID)
+	if err != nil {
+		return nil, err
+	}
+
+	weightedSum := new(big.Float)
+	totalWeight := new(big.Float)
+	for _, poolID := range poolIDs {
+		reserveToken, reserveBase, ok := g.spotReserves(poolID, tokenID, baseTokenID)
+		if !ok || reserveToken.Sign() == 0 || reserveBase.Sign() == 0 {
+			continue
+		}
+		price := new(big.Float).Quo(new(big.Float).SetInt(reserveBase), new(big.Float).SetInt(reserveToken))
+		depth := new(big.Int).Mul(reserveToken, reserveBase)
+		weight := new(big.Float).SetInt(depth.Sqrt(depth))
+
+		weightedSum.Add(weightedSum, price.Mul(price, weight))
+		totalWeight.Add(totalWeight, weight)
+	}
+	if totalWeight.Sign() == 0 {
+		return nil, fmt.Errorf("%w: no pool with liquidity holds token %d and token %d", chains.ErrNoRoute, tokenID, baseTokenID)
+	}
+	return weightedSum.Quo(weightedSum, totalWeight), nil
+}
+
+// TriangleDeviation returns price(a→b) · price(b→c) · price(c→a) - 1, where each price is
+// the WeightedPrice of the leg. A consistent triangle is at zero; a positive value means
+// cycling a→b→c→a returns more a than it started with at spot prices, a negative value
+// that the reverse cycle does. Fees and price impact are not included, so only deviations
+// beyond the cycle's combined fees can be exploited; it is meant as a cheap pre-filter
+// before FindArbitrageCycles. Decimals cancel around the cycle, so no token metadata is
+// needed.
+func (g *Graph) TriangleDeviation(a, b, c uint64) (*big.Float, error) {
+	if a == b || b == c || a == c {
+		return nil, errors.New("triangle tokens must be distinct")
+	}
+	product := big.NewFloat(1)
+	for _, leg := range [3][2]uint64{{a, b}, {b, c}, {c, a}} {
+		price, err := g.rawWeightedPrice(leg[0], leg[1])
+		if err != nil {
+			return nil, err
+		}
+		product.Mul(product, price)
+	}
+	return product.Sub(product, big.NewFloat(1)), nil
+}
+
+// MostMispricedPool compares the spot price of every routable pool holding tokenA and
+// tokenB with externalPrice, the price of one tokenA in tokenB (e.g. from a CEX), and
+// returns the pool with the largest relative deviation together with the trade that
+// captures it. Like WeightedPrice, prices are in whole tokens with token metadata and
+// in raw units without it; Uniswap V3 pools are priced from their virtual reserves.
+// Ties are broken by the lower pool ID.
+func (g *Graph) MostMispricedPool(tokenA, tokenB uint64, externalPrice *big.Float) (*chains.PoolMispricing, error) {
+	if externalPrice == nil || externalPrice.Sign() <= 0 {
+		return nil, errors.New("external price must be positive")
+	}
+	if tokenA == tokenB {
+		return nil, errors.New("tokenA and tokenB must differ")
+	}
+	poolIDs, err := g.GetPoolsForToken(tokenA)
+	if err != nil {
+		return nil, err
+	}
+	scale, err := g.priceScale(tokenA, tokenB)
+	if err != nil {
+		return nil, err
+	}
+
+	var best *chains.PoolMispricing
+	var bestAbs *big.Float
+	for _, poolID := range poolIDs {
+		reserveA, reserveB, ok := g.spotReserves(poolID, tokenA, tokenB)
+		if !ok || reserveA.Sign() == 0 || reserveB.Sign() == 0 {
+			continue
+		}
+		spot := new(big.Float).Quo(new(big.Float).SetInt(reserveB), new(big.Float).SetInt(reserveA))
+		spot.Mul(spot, scale)
+		deviation := new(big.Float).Sub(spot, externalPrice)
+		deviation.Quo(deviation, externalPrice)
+
+		abs := new(big.Float).Abs(deviation)
+		if best != nil {
+			if c := abs.Cmp(bestAbs); c < 0 || (c == 0 && poolID > best.PoolID) {
+				continue
+			}
+		}
+
+		swap := chains.TokenPoolPath{TokenInID: tokenB, TokenOutID: tokenA, PoolID: poolID} // tokenA is cheap: buy it
+		if deviation.Sign() > 0 {
+			swap = chains.TokenPoolPath{TokenInID: tokenA, TokenOutID: tokenB, PoolID: poolID} // tokenA is expensive: sell it
+		}
+		best = &chains.PoolMispricing{PoolID: poolID, SpotPrice: spot, Deviation: deviation, Swap: swap}
+		bestAbs = abs
+	}
+	if best == nil {
+		return nil, fmt.Errorf("%w: no pool with liquidity holds token %d and token %d", chains.ErrNoRoute, tokenA, tokenB)
+	}
+	return best, nil
+}
+
+// DepthCurves quotes params.Points input sizes, spaced evenly in log scale between
+// MinAmountIn and MaxAmountIn, on every routable pool that holds both tokens, with the
+// depth curve of the pool's calculator. Curves are ordered by their output at
+// MaxAmountIn, deepest first, ties broken by pool ID. Pools whose curve cannot be
+// computed, such as empty ones, are left out. It returns ErrNoRoute if no pool is left.
+func (g *Graph) DepthCurves(params chains.DepthCurveParams) ([]chains.DepthCurve, error) {
+	if params.TokenInID == params.TokenOutID {
+		return nil, errors.New("DepthCurveParams: tokens must differ")
+	}
+	if params.MinAmountIn == nil || params.MaxAmountIn == nil || params.MinAmountIn.Sign() <= 0 || params.MaxAmountIn.Cmp(params.MinAmountIn) < 0 {
+		return nil, errors.New("DepthCurveParams: amounts must satisfy 0 < MinAmountIn <= MaxAmountIn")
+	}
+	if params.Points < 2 {
+		return nil, fmt.Errorf("DepthCurveParams: points must be at least 2, got %d", params.Points)
+	}
+	poolIDs, err := g.GetPoolsForToken(params.TokenInID)
+	if err != nil {
+		return nil, err
+	}
+	scale, err := g.priceScale(params.TokenInID, params.TokenOutID)
+	if err != nil {
+		return nil, err
+	}
+
+	in, out := params.TokenInID, params.TokenOutID
+	var curves []chains.DepthCurve
+	for _, poolID := range poolIDs {
+		if _, _, ok := g.spotReserves(poolID, in, out); !ok {
+			continue
+		}
+		schema, _ := g.protocolResolver.ResolveSchemaFromPoolID(poolID)
+		var points []chains.DepthPoint
+		switch schema {
+		case uniswapv2.Schema:
+			pool, _ := g.indexedUniswapV2.GetByID(poolID)
+			curve, err := uniswapv2calculator.DepthCurve(params.MinAmountIn, params.MaxAmountIn, params.Points, in, out, pool)
+			if err != nil {
+				continue
+			}
+			for _, point := range curve {
+				points = append(points, chains.DepthPoint(point))
+			}
+		case uniswapv3.Schema, uniswapv4.Schema:
+			pool, _ := g.indexedUniswapV3.GetByID(poolID)
+			curve, err := uniswapv3calculator.DepthCurve(params.MinAmountIn, params.MaxAmountIn, params.Points, in, pool)
+			if err != nil {
+				continue
+			}
+			for _, point := range curve {
+				points = append(points, chains.DepthPoint(point))
+			}
+		case solidly.Schema:
+			pool, _ := g.solidlyPool(poolID)
+			curve, err := solidlycalculator.DepthCurve(params.MinAmountIn, params.MaxAmountIn, params.Points, in, out, pool)
+			if err != nil {
+				continue
+			}
+			for _, point := range curve {
+				points = append(points, chains.DepthPoint(point))
+			}
+		default:
+			continue
+		}
+		for _, point := range points {
+			point.MarginalPrice.Mul(point.MarginalPrice, scale)
+		}
+		curves = append(curves, chains.DepthCurve{PoolID: poolID, Schema: schema, Points: points})
+	}
+	if len(curves) == 0 {
+		return nil, fmt.Errorf("%w: no pool with liquidity holds token %d and token %d", chains.ErrNoRoute, in, out)
+	}
+
+	sort.Slice(curves, func(i, j int) bool {
+		last := params.Points - 1
+		if c := curves[i].Points[last].AmountOut.Cmp(curves[j].Points[last].AmountOut); c != 0 {
+			return c > 0
+		}
+		return curves[i].PoolID < curves[j].PoolID
+	})
+	return curves, nil
+}
+
+// defaultSpotPriceBatchSize is the number of pools per task when
+// SpotPriceParams.BatchSize is zero.
+const defaultSpotPriceBatchSize = 256
+
+// AllSpotPrices returns the spot price of one token0 in token1 for every routable pool,
+// keyed by pool ID, with the units and Uniswap V3 handling of WeightedPrice. Pools without
+// liquidity, or whose token decimals are unknown when token metadata is present, are left
+// out. The pools are priced in batches by params.Concurrency workers; each worker writes
+// only its own pools' slots, so the result does not depend on scheduling.
+func (g *Graph) AllSpotPrices(params chains.SpotPriceParams) (map[uint64]*big.Float, error) {
+	if params.Concurrency < 0 {
+		return nil, fmt.Errorf("SpotPriceParams: concurrency must not be negative, got %d", params.Concurrency)
+	}
+	if params.BatchSize < 0 {
+		return nil, fmt.Errorf("SpotPriceParams: batch size must not be negative, got %d", params.BatchSize)
+	}
+	batchSize := params.BatchSize
+	if batchSize == 0 {
+		batchSize = defaultSpotPriceBatchSize
+	}
+
+	numPools := len(g.rawGraph.Pools)
+	prices := make([]*big.Float, numPools)
+	priceBatch := func(start int) {
+		end := min(start+batchSize, numPools)
+		for i := start; i < end; i++ {
+			prices[i] = g.poolSpotPrice(g.rawGraph.Pools[i])
+		}
+	}
+
+	if params.Concurrency <= 1 {
+		for start := 0; start < numPools; start += batchSize {
+			priceBatch(start)
+		}
+	} else {
+		batches := make(chan int)
+		var wg sync.WaitGroup
+		for w := 0; w < params.Concurrency; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for start := range batches {
+					priceBatch(start)
+				}
+			}()
+		}
+		for start := 0; start < numPools; start += batchSize {
+			batches <- start
+		}
+		close(batches)
+		wg.Wait()
+	}
+
+	result := make(map[uint64]*big.Float, numPools)
+	for i, price := range prices {
+		if price != nil {
+			result[g.rawGraph.Pools[i]] = price
+		}
+	}
+	return result, nil
+}
+
+// poolSpotPrice returns the spot price of one token0 in token1 of a pool, or nil if the
+// pool cannot be priced.
+func (g *Graph) poolSpotPrice(poolID uint64) *big.Float {
+	tokens, err := g.GetTokensForPool(poolID)
+	if err != nil || len(tokens) != 2 {
+		return nil
+	}
+	reserve0, reserve1, ok := g.spotReserves(poolID, tokens[0], tokens[1])
+	if !ok || reserve0.Sign() == 0 || reserve1.Sign() == 0 {
+		return nil
+	}
+	scale, err := g.priceScale(tokens[0], tokens[1])
+	if err != nil {
+		return nil
+	}
+	price := new(big.Float).Quo(new(big.Float).SetInt(reserve1), new(big.Float).SetInt(reserve0))
+	return price.Mul(price, scale)
+}
+
+// priceScale returns the factor converting a raw price of tokenID in baseTokenID into
+// whole tokens, 10^(decimals - baseDecimals), or 1 without token metadata.
+func (g *Graph) priceScale(tokenID, baseTokenID uint64) (*big.Float, error) {
+	if g.indexedTokenRegistry == nil {
+		return big.NewFloat(1), nil
+	}
+	decimals, ok := g.tokenDecimals(tokenID)
+	if !ok {
+		return nil, fmt.Errorf("token %d not found in the token registry", tokenID)
+	}
+	baseDecimals, ok := g.tokenDecimals(baseTokenID)
+	if !ok {
+		return nil, fmt.Errorf("token %d not found in the token registry", baseTokenID)
+	}
+	scale := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil))
+	baseScale := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(baseDecimals)), nil))
+	return scale.Quo(scale, baseScale), nil
+}
+
+// spotReserves returns the reserves of tokenID and baseTokenID in a routable pool that
+// holds both, using the virtual reserves for Uniswap V3 pools.
+func (g *Graph) spotReserves(poolID, tokenID, baseTokenID uint64) (reserveToken, reserveBase *big.Int, ok bool) {
+	i, ok := g.poolToIndex[poolID]
+	if !ok || g.allGetAmountOutFuncs[i] == nil {
+		return nil, nil, false
+	}
+	return g.reservesAtSpot(poolID, tokenID, baseTokenID)
+}
+
+// reservesAtSpot is spotReserves for any pool with data, wired or not.
+func (g *Graph) reservesAtSpot(poolID, tokenID, baseTokenID uint64) (reserveToken, reserveBase *big.Int, ok bool) {
+	schema, _ := g.protocolResolver.ResolveSchemaFromPoolID(poolID)
+	var err error
+	switch schema {
+	case uniswapv2.Schema:
+		pool, _ := g.indexedUniswapV2.GetByID(poolID)
+		reserveToken, reserveBase, err = uniswapv2calculator.GetReserves(tokenID, baseTokenID, pool)
+	case uniswapv3.Schema, uniswapv4.Schema:
+		pool, _ := g.indexedUniswapV3.GetByID(poolID)
+		reserveToken, reserveBase, err = uniswapv3calculator.GetVirtualReserves(tokenID, baseTokenID, pool)
+	case solidly.Schema:
+		pool, _ := g.solidlyPool(poolID)
+		reserveToken, reserveBase, err = solidlycalculator.GetReserves(tokenID, baseTokenID, pool)
+	default:
+		return nil, nil, false
+	}
+	if err != nil {
+		return nil, nil, false // the pool does not hold baseTokenID
+	}
+	return reserveToken, reserveBase, true
+}
+
+// toWholeUnits converts a raw token amount into whole tokens.
+func toWholeUnits(amount *big.Int, decimals uint8) *big.Float {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	return new(big.Float).Quo(new(big.Float).SetInt(amount), new(big.Float).SetInt(scale))
+}
+
+// median returns the median of values, averaging the middle two for an even count.
+// values is sorted in place.
+func median(values []*big.Float) *big.Float {
+	sort.Slice(values, func(i, j int) bool { return values[i].Cmp(values[j]) < 0 })
+	mid := len(values) / 2
+	if len(values)%2 == 1 {
+		return new(big.Float).Set(values[mid])
+	}
+	sum := new(big.Float).Add(values[mid-1], values[mid])
+	return sum.Quo(sum, big.NewFloat(2))
+}
+
+// TopPoolsBySchema returns the n deepest pools of the given schema, ordered by depth
+// (deepest first, ties broken by pool ID). Depth is sqrt(reserve0 * reserve1) at the spot
+// price, Uniswap V3 pools counting their virtual reserves, with each reserve scaled to 18
+// decimals so pools of tokens with different decimals compare; reserves of tokens whose
+// decimals are unknown count as 18-decimal amounts. Pools whose reserves cannot be read
+// are left out.
+func (g *Graph) TopPoolsBySchema(schema engine.ProtocolSchema, n int) ([]chains.PoolDepth, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("n must be positive, got %d", n)
+	}
+
+	depths := g.poolDepths(func(_ int, poolID uint64) bool {
+		poolSchema, ok := g.protocolResolver.ResolveSchemaFromPoolID(poolID)
+		return ok && poolSchema == schema
+	}, nil, nil)
+	if len(depths) > n {
+		depths = depths[:n]
+	}
+	return depths, nil
+}
+
+// MinRouteDepth finds the least liquid hop of path, so a route that is deep on average but
+// shallow at one hop can be flagged. Each hop's depth is its pool's reserve of the hop's
+// input token, the side a trade along the route fills, expressed in raw units of the
+// route's input token by converting at the spot prices of the hops before it. Uniswap V3
+// pools are measured by their virtual reserves, the active liquidity at the current
+// price. Ties go to the earliest hop.
+func (g *Graph) MinRouteDepth(path []chains.TokenPoolPath) (*chains.RouteDepth, error) {
+	if len(path) == 0 {
+		return nil, errors.New("route is empty")
+	}
+	result := &chains.RouteDepth{HopDepths: make([]*big.Int, len(path))}
+	// price is the spot price of the route's input token in the current hop's input token.
+	price := big.NewFloat(1)
+	for i, hop := range path {
+		if i > 0 && hop.TokenInID != path[i-1].TokenOutID {
+			return nil, fmt.Errorf("hop %d does not start where hop %d ends", i, i-1)
+		}
+		reserveIn, reserveOut, ok := g.spotReserves(hop.PoolID, hop.TokenInID, hop.TokenOutID)
+		if !ok || reserveIn.Sign() == 0 || reserveOut.Sign() == 0 {
+			return nil, fmt.Errorf("hop %d: pool %d has no liquidity from token %d to token %d", i, hop.PoolID, hop.TokenInID, hop.TokenOutID)
+		}
+		depth, _ := new(big.Float).Quo(new(big.Float).SetInt(reserveIn), price).Int(nil)
+		result.HopDepths[i] = depth
+		if result.Depth == nil || depth.Cmp(result.Depth) < 0 {
+			result.HopIndex, result.PoolID, result.Depth = i, hop.PoolID, depth
+		}
+		price.Mul(price, new(big.Float).SetInt(reserveOut))
+		price.Quo(price, new(big.Float).SetInt(reserveIn))
+	}
+	return result, nil
+}
+
+// poolDepths returns the depth of every pool accepted by include, deepest first (ties
+// broken by pool ID). Overridden pools are measured from their override; the others use
+// the depth measured when they were wired. Pools whose reserves cannot be read are left
+// out.
+func (g *Graph) poolDepths(
+	include func(poolIndex int, poolID uint64) bool,
+	uniswapV2Overrides map[uint64]uniswapv2.Pool,
+	uniswapV3Overrides map[uint64]uniswapv3.Pool,
+) []chains.PoolDepth {
+	var depths []chains.PoolDepth
+	for i, poolID := range g.rawGraph.Pools {
+		depth := g.depths[i]
+		if depth == nil || !include(i, poolID) {
+			continue
+		}
+		if pool, ok := uniswapV2Overrides[poolID]; ok {
+			reserve0, reserve1, err := uniswapv2calculator.GetReserves(pool.Token0, pool.Token1, pool)
+			if depth = g.depthOf(pool.Token0, pool.Token1, reserve0, reserve1, err); depth == nil {
+				continue
+			}
+		} else if pool, ok := uniswapV3Overrides[poolID]; ok {
+			reserve0, reserve1, err := uniswapv3calculator.GetVirtualReserves(pool.Token0, pool.Token1, pool)
+			if depth = g.depthOf(pool.Token0, pool.Token1, reserve0, reserve1, err); depth == nil {
+				continue
+			}
+		}
+		depths = append(depths, chains.PoolDepth{PoolID: poolID, Depth: depth})
+	}
+
+	sort.Slice(depths, func(i, j int) bool {
+		if c := depths[i].Depth.Cmp(depths[j].Depth); c != 0 {
+			return c > 0
+		}
+		return depths[i].PoolID < depths[j].PoolID
+	})
+	return depths
+}
+
+// measureDepth returns the depth of a pool from its reserves at the spot price, or nil if
+// they cannot be read.
+func (g *Graph) measureDepth(poolID uint64) *big.Int {
+	tokens, err := g.GetTokensForPool(poolID)
+	if err != nil || len(tokens) != 2 {
+		return nil
+	}
+	reserve0, reserve1, ok := g.reservesAtSpot(poolID, tokens[0], tokens[1])
+	if !ok {
+		return nil
+	}
+	return g.depthOf(tokens[0], tokens[1], reserve0, reserve1, nil)
+}
+
+// depthOf returns sqrt(reserve0 * reserve1) with each reserve scaled to 18 decimals, or
+// nil if the reserves could not be read.
+func (g *Graph) depthOf(token0, token1 uint64, reserve0, reserve1 *big.Int, err error) *big.Int {
+	if err != nil || reserve0 == nil || reserve1 == nil {
+		return nil
+	}
+	depth := new(big.Int).Mul(g.scaleTo18Decimals(token0, reserve0), g.scaleTo18Decimals(token1, reserve1))
+	return depth.Sqrt(depth)
+}
+
+// scaleTo18Decimals converts a raw amount of tokenID into an 18-decimal amount. Amounts of
+// tokens whose decimals are unknown are returned as they are.
+func (g *Graph) scaleTo18Decimals(tokenID uint64, amount *big.Int) *big.Int {
+	decimals, ok := g.tokenDecimals(tokenID)
+	if !ok || decimals == 18 {
+		return amount
+	}
+	if decimals < 18 {
+		return new(big.Int).Mul(amount, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(18-decimals)), nil))
+	}
+	return new(big.Int).Quo(amount, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals-18)), nil))
+}
+
+// restrictPools removes from funcs, in place, every pool outside params.ActivePools and,
+// if params.MaxPools is positive, every pool but the MaxPools deepest of those left,
+// measuring overridden pools by their overrides.
+func (g *Graph) restrictPools(funcs []GetAmountOutFunc, params chains.CycleFindingParams) {
+	if params.ActivePools != nil {
+		for i, poolID := range g.rawGraph.Pools {
+			if _, ok := params.ActivePools[poolID]; !ok {
+				funcs[i] = nil
+			}
+		}
+	}
+	if params.MaxPools <= 0 {
+		return
+	}
+
+	depths := g.poolDepths(
+		func(poolIndex int, _ uint64) bool { return funcs[poolIndex] != nil },
+		params.UniswapV2Overrides,
+		params.UniswapV3Overrides,
+	)
+	if len(depths) > params.MaxPools {
+		depths = depths[:params.MaxPools]
+	}
+	keep := make(map[uint64]struct{}, len(depths))
+	for _, d := range depths {
+		keep[d.PoolID] = struct{}{}
+	}
+	for i, poolID := range g.rawGraph.Pools {
+		if _, ok := keep[poolID]; !ok {
+			funcs[i] = nil
+		}
+	}
+}
+
+// ConsistencyCheck compares the token decimals each pool was indexed with against the
+// token registry and returns every disagreement, in graph order. Pools that do not carry
+// assumed decimals are not checked, and nothing is checked without token metadata.
+func (g *Graph) ConsistencyCheck() []chains.DecimalsMismatch {
+	if g.indexedTokenRegistry == nil {
+		return nil
+	}
+
+	var mismatches []chains.DecimalsMismatch
+	for _, poolID := range g.rawGraph.Pools {
+		var (
+			token0, token1       uint64
+			decimals0, decimals1 uint8
+			ok                   bool
+		)
+		schema, _ := g.protocolResolver.ResolveSchemaFromPoolID(poolID)
+		switch schema {
+		case uniswapv2.Schema:
+			pool, found := g.indexedUniswapV2.GetByID(poolID)
+			if !found {
+				continue
+			}
+			token0, token1 = pool.Token0, pool.Token1
+			decimals0, decimals1, ok = pool.AssumedDecimals()
+		case uniswapv3.Schema, uniswapv4.Schema:
+			pool, found := g.indexedUniswapV3.GetByID(poolID)
+			if !found {
+				continue
+			}
+			token0, token1 = pool.Token0, pool.Token1
+			decimals0, decimals1, ok = pool.AssumedDecimals()
+		case solidly.Schema:
+			pool, found := g.solidlyPool(poolID)
+			if !found {
+				continue
+			}
+			token0, token1 = pool.Token0, pool.Token1
+			decimals0, decimals1, ok = pool.AssumedDecimals()
+		}
+		if !ok {
+			continue
+		}
+
+		for _, assumed := range []struct {
+			tokenID  uint64
+			decimals uint8
+		}{{token0, decimals0}, {token1, decimals1}} {
+			token, found := g.indexedTokenRegistry.GetByID(assumed.tokenID)
+			if !found || token.Decimals == assumed.decimals {
+				continue
+			}
+			mismatches = append(mismatches, chains.DecimalsMismatch{
+				PoolID:           poolID,
+				TokenID:          assumed.tokenID,
+				PoolDecimals:     assumed.decimals,
+				RegistryDecimals: token.Decimals,
+			})
+		}
+	}
+	return mismatches
+}
+
+// findConversionPathState encapsulates the state required for the Bellman-Ford-like
+// pathfinding algorithm used in GetExchangeRates.
+type findConversionPathState struct {
+	start                    int                      // starting vertex index
+	current                  int                      // current vertex index being processed
+	paths                    [][]chains.TokenPoolPath // vertex index -> path to this token
+	costs                    []*big.Int               // vertex index -> cost
+	reserves                 []*big.Int               // vertex index -> reserve
+	known                    []bitset.BitSet          // vertex index -> vertex index
+	bestConnection           []int                    // edge index -> pool index
+	bestConnectionComputed   bitset.BitSet            // edge index -> whether the best connection has been computed
+	reserveForBestConnection []*big.Int               // edge index -> reserve for the best connection
+	temp                     *big.Int
+}
+
+// GetExchangeRates calculates the equivalent value of a given amount of a base token
+// across all other tokens in the graph using a Bellman-Ford-like algorithm.
+// It can be constrained to only propagate prices from a specific set of allowed source tokens.
+//
+// Rates are chained with exact big.Int quotes from the protocol calculators, so the only
+// rounding on a multi-hop path is the per-hop truncation the pools themselves perform;
+// no floating-point error accumulates with path length.
+func (g *Graph) GetExchangeRates(
+	baseAmountIn *big.Int,
+	baseTokenID uint64,
+	runs int,
+	allowedSourceTokens map[uint64]struct{}, // New parameter
+) (map[uint64]*big.Int, error) {
+
+	// Step 1: Find the internal index for the starting token.
+	baseIndex, exists := g.tokenToIndex[baseTokenID]
+	if !exists {
+		return nil, fmt.Errorf("token %d not found in the graph", baseTokenID)
+	}
+
+	// Step 2: Initialize the state for the pathfinding search.
+	numTokens := len(g.rawGraph.Tokens)
+	numEdges := len(g.rawGraph.EdgePools)
+
+	state := &findConversionPathState{
+		start:                    baseIndex,
+		paths:                    make([][]chains.TokenPoolPath, numTokens),
+		costs:                    make([]*big.Int, numTokens),
+		known:                    make([]bitset.BitSet, numTokens),
+		bestConnection:           make([]int, numEdges),
+		bestConnectionComputed:   bitset.NewBitSet(uint64(numEdges)),
+		reserveForBestConnection: make([]*big.Int, numEdges),
+		reserves:                 make([]*big.Int, numTokens),
+		temp:                     bigIntPool.Get().(*big.Int).SetUint64(0), // Get from pool
+	}
+
+	// This defer block ensures all temporary, pooled objects are returned.
+	defer func() {
+		bigIntPool.Put(state.temp.SetUint64(0))
+		for _, r := range state.reserves {
+			if r != nil {
+				bigIntPool.Put(r.SetUint64(0))
+			}
+		}
+		for _, r := range state.reserveForBestConnection {
+			if r != nil {
+				bigIntPool.Put(r.SetUint64(0))
+			}
+		}
+	}()
+
+	for i := range numTokens {
+		state.known[i] = bitset.NewBitSet(uint64(numTokens))
+		// Rent from pool for temporary state
+		state.reserves[i] = bigIntPool.Get().(*big.Int).SetUint64(0) // ensure zero value
+		// Allocate new for returned data
+		state.costs[i] = new(big.Int)
+	}
+	state.costs[baseIndex].Set(baseAmountIn)
+	for i := range numEdges {
+		state.bestConnection[i] = -1 // -1 indicates no best connection yet
+		// Rent from pool for temporary state
+		state.reserveForBestConnection[i] = bigIntPool.Get().(*big.Int).SetUint64(0) // ensure zero value
+	}
+
+	// Step 3: Iteratively "relax" the edges for a set number of runs.
+	for i := 0; i < runs; i++ {
+		for j := 0; j < numTokens; j++ {
+			if state.costs[j].Sign() == 0 {
+				continue // Skip tokens that haven't been reached yet.
+			}
+
+			// Convert the internal index to the external token ID.
+			currentTokenID := g.rawGraph.Tokens[j]
+			// If a set of allowed source tokens is provided, check if the current
+			// token is in that set before allowing it to propagate its price.
+			if allowedSourceTokens != nil {
+				if _, isAllowed := allowedSourceTokens[currentTokenID]; !isAllowed {
+					continue // This token is not allowed to be a source.
+				}
+			}
+
+			state.current = j
+			if err := g.getExchangeRatesUsingMaxReservePath(state); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Step 4: Convert the final costs slice back to a map for the user.
+	finalExchangeRates := make(map[uint64]*big.Int, len(state.costs))
+	for i, cost := range state.costs {
+		if cost.Sign() != 0 {
+			tokenID := g.rawGraph.Tokens[i]
+			finalExchangeRates[tokenID] = cost
+		}
+	}
+
+	// ensure baseToken equivalent equal to baseAmountIn
+	finalExchangeRates[baseTokenID] = new(big.Int).Set(baseAmountIn)
+	return finalExchangeRates, nil
+}
+
+// getExchangeRatesUsingMaxReservePath is the core of the algorithm. It uses the
+// pre-computed swap functions for maximum performance.
+// it sets connections based on maxReserve
+func (g *Graph) getExchangeRatesUsingMaxReservePath(
+	state *findConversionPathState,
+) error {
+	currentIndex := state.current
+	currentCost := state.costs[currentIndex]
+	currentKnown := state.known[currentIndex]
+	currentPath := state.paths[currentIndex]
+	currentTokenID := g.rawGraph.Tokens[currentIndex]
+
+	if currentKnown.IsSet(uint64(currentIndex)) {
+		// we should never get here!
+		return errors.New("cycle detected in path history")
+	}
+
+	// Iterate through all outgoing edges from the current token.
+	for _, edgeIndex := range g.rawGraph.Adjacency[currentIndex] {
+		targetIndex := g.rawGraph.EdgeTargets[edgeIndex]
+		targetTokenID := g.rawGraph.Tokens[targetIndex]
+
+		// Crucial cycle prevention: do not traverse to a token that is already in the current path.
+		if currentKnown.IsSet(uint64(targetIndex)) {
+			continue
+		}
+
+		bestReserve := state.temp
+
+		if !state.bestConnectionComputed.IsSet(uint64(edgeIndex)) {
+			// Iterate through all pools associated with this edge.
+			bestConnection := -1
+			bestReserve.SetUint64(0)
+			for _, poolIndex := range g.rawGraph.EdgePools[edgeIndex] {
+				getReserveFunc := g.getReservesFuncs[poolIndex]
+				// can be nil - @todo fix this
+				if getReserveFunc == nil {
+					continue
+				}
+				_, reserveOut, err := getReserveFunc(currentTokenID, targetTokenID)
+				if err != nil {
+					continue
+				}
+
+				// we need the reserveOut
+				if reserveOut.Cmp(bestReserve) == 1 {
+					bestReserve.Set(reserveOut)
+					bestConnection = poolIndex
+				}
+			}
+
+			if bestConnection != -1 {
+				// we have found a best connection for this edge (the pool with the highest reserve for targetID)
+				state.bestConnection[edgeIndex] = bestConnection
+				state.bestConnectionComputed.Set(uint64(edgeIndex))
+				state.reserveForBestConnection[edgeIndex].Set(bestReserve)
+			}
+		}
+
+		if state.bestConnection[edgeIndex] != -1 {
+			poolIndex := state.bestConnection[edgeIndex]
+			reserve := state.reserveForBestConnection[edgeIndex]
+
+			if state.reserves[targetIndex].Cmp(reserve) == -1 {
+				amountOut, err := g.allGetAmountOutFuncs[poolIndex](currentCost, currentTokenID, targetTokenID)
+				if err != nil || amountOut == nil || amountOut.Sign() <= 0 {
+					continue
+				}
+
+				state.costs[targetIndex].Set(amountOut)
+				poolID := g.rawGraph.Pools[poolIndex]
+				newPath := make([]chains.TokenPoolPath, len(currentPath)+1)
+				copy(newPath, currentPath)
+				newPath[len(currentPath)] = chains.TokenPoolPath{
+					TokenInID:  currentTokenID,
+					TokenOutID: targetTokenID,
+					PoolID:     poolID,
+				}
+				state.paths[targetIndex] = newPath
+				state.known[targetIndex].SetFrom(currentKnown)
+				state.known[targetIndex].Set(uint64(currentIndex))
+				state.reserves[targetIndex].Set(reserve)
+			}
+
+		}
+	}
+	return nil
+}
+
+// getExchangeRatesUsingMaxAmountOutPath is the core of the algorithm. It uses the
+// pre-computed swap functions for maximum performance.
 // it sets connections based on maxAmountOut
 func (g *Graph) getExchangeRatesUsingMaxAmountOutPath(
 	state *findConversionPathState,
@@ -442,329 +2250,1470 @@ func (g *Graph) getExchangeRatesUsingMaxAmountOutPath(
 	currentPath := state.paths[currentIndex]
 	currentTokenID := g.rawGraph.Tokens[currentIndex]
 
-	if currentKnown.IsSet(uint64(currentIndex)) {
-		// we should never get here!
-		return errors.New("cycle detected in path history")
+	if currentKnown.IsSet(uint64(currentIndex)) {
+		// we should never get here!
+		return errors.New("cycle detected in path history")
+	}
+
+	// Iterate through all outgoing edges from the current token.
+	for _, edgeIndex := range g.rawGraph.Adjacency[currentIndex] {
+		targetIndex := g.rawGraph.EdgeTargets[edgeIndex]
+		targetTokenID := g.rawGraph.Tokens[targetIndex]
+
+		// Crucial cycle prevention: do not traverse to a token that is already in the current path.
+		if currentKnown.IsSet(uint64(targetIndex)) {
+			continue
+		}
+
+		bestAmountOut := state.temp
+		if !state.bestConnectionComputed.IsSet(uint64(edgeIndex)) {
+			// Iterate through all pools associated with this edge.
+			bestConnection := -1
+			bestAmountOut.SetUint64(0)
+			for _, poolIndex := range g.rawGraph.EdgePools[edgeIndex] {
+				getAmountOutFunc := g.allGetAmountOutFuncs[poolIndex]
+				// can be nil - @todo fix this
+				if getAmountOutFunc == nil {
+					continue
+				}
+				amountOut, err := getAmountOutFunc(currentCost, currentTokenID, targetTokenID)
+				if err != nil || amountOut == nil || amountOut.Sign() <= 0 {
+					continue
+				}
+
+				// we need the reserveOut
+				if amountOut.Cmp(bestAmountOut) == 1 {
+					bestAmountOut.Set(amountOut)
+					bestConnection = poolIndex
+				}
+			}
+
+			if bestConnection != -1 {
+				// we have found a best connection for this edge (the pool with the highest reserve for targetID)
+				state.bestConnection[edgeIndex] = bestConnection
+				state.bestConnectionComputed.Set(uint64(edgeIndex))
+			}
+		}
+
+		if state.bestConnection[edgeIndex] != -1 {
+			poolIndex := state.bestConnection[edgeIndex]
+			amountOut, err := g.allGetAmountOutFuncs[poolIndex](currentCost, currentTokenID, targetTokenID)
+			if err != nil || amountOut == nil || amountOut.Sign() <= 0 {
+				continue
+			}
+
+			if state.costs[targetIndex].Sign() == 0 || amountOut.Cmp(state.costs[targetIndex]) == 1 {
+				state.costs[targetIndex].Set(amountOut)
+				poolID := g.rawGraph.Pools[poolIndex]
+				newPath := make([]chains.TokenPoolPath, len(currentPath)+1)
+				copy(newPath, currentPath)
+				newPath[len(currentPath)] = chains.TokenPoolPath{
+					TokenInID:  currentTokenID,
+					TokenOutID: targetTokenID,
+					PoolID:     poolID,
+				}
+				state.paths[targetIndex] = newPath
+				state.known[targetIndex].SetFrom(currentKnown)
+				state.known[targetIndex].Set(uint64(currentIndex))
+			}
+		}
+	}
+	return nil
+}
+
+// findArbitrageCyclesState encapsulates the state required for the Bellman-Ford-like
+// arbitrage cycle finding algorithm.
+type findArbitrageCyclesState struct {
+	start         int
+	current       int
+	initialCost   *big.Int
+	paths         [][]chains.TokenPoolPath // vertex index -> path
+	costs         []*big.Int               // vertex index -> cost
+	known         []bitset.BitSet          // vertex index -> vertex index
+	bestCycleCost *big.Int
+	temp          *big.Int
+}
+
+// FindArbitrageCycles searches the graph for a best effort at a profitable cycle
+// It begins by initializing all the required fields of the findArbitrageCyclesState and
+// updating our amountOut funcs with the pool overrides (if any)
+func (g *Graph) FindArbitrageCycles(params chains.CycleFindingParams) ([][]chains.TokenPoolPath, []*big.Int, error) {
+	runs := params.Runs
+	if runs <= 0 {
+		return nil, nil, errors.New("CycleFindingParams: runs must be greater than 09")
+	}
+	if params.MaxPools < 0 {
+		return nil, nil, errors.New("CycleFindingParams: max pools must not be negative")
+	}
+
+	// --- Step 1: Create a temporary, patched slice of swap functions ---
+	getAmountOutFuncs := make([]GetAmountOutFunc, len(g.activeGetAmountOutFuncs))
+	copy(getAmountOutFuncs, g.activeGetAmountOutFuncs)
+
+	// Patch the local function slice with V2 overrides.
+	for poolID, overriddenPool := range params.UniswapV2Overrides {
+
+		poolIndex, exists := g.poolToIndex[poolID]
+		if !exists {
+			continue
+		}
+		if getAmountOutFuncs[poolIndex] == nil {
+			// pool is inactive skip!
+			continue
+		}
+		getAmountOutFuncs[poolIndex] = func(amountIn *big.Int, tokenInID, tokenOutID uint64) (*big.Int, error) {
+			return uniswapv2calculator.GetAmountOut(amountIn, tokenInID, tokenOutID, overriddenPool)
+		}
+	}
+
+	// Patch with UniswapV3 overrides.
+	for poolID, overriddenPool := range params.UniswapV3Overrides {
+
+		poolIndex, exists := g.poolToIndex[poolID]
+		if !exists {
+			continue
+		}
+		if getAmountOutFuncs[poolIndex] == nil {
+			// pool is inactive skip!
+			continue
+		}
+		getAmountOutFuncs[poolIndex] = func(amountIn *big.Int, tokenInID, tokenOutID uint64) (*big.Int, error) {
+			return uniswapv3calculator.GetAmountOut(amountIn, nil, tokenInID, overriddenPool)
+		}
+	}
+
+	g.restrictPools(getAmountOutFuncs, params)
+
+	baseIndex, exists := g.tokenToIndex[params.TokenID]
+	if !exists {
+		return nil, nil, fmt.Errorf("token %d not found in the graph", params.TokenID)
+	}
+
+	numTokens := len(g.rawGraph.Tokens)
+	state := &findArbitrageCyclesState{
+		start:         baseIndex,
+		initialCost:   params.AmountIn,
+		paths:         make([][]chains.TokenPoolPath, numTokens),
+		costs:         make([]*big.Int, numTokens),
+		known:         make([]bitset.BitSet, numTokens),
+		bestCycleCost: new(big.Int),
+		temp:          bigIntPool.Get().(*big.Int).SetUint64(0),
+	}
+
+	// This defer block is CRITICAL. It ensures all rented objects are returned.
+	defer func() {
+		// Return the scratchpad int
+		bigIntPool.Put(state.temp.SetUint64(0))
+		// Return all integers used in the costs slice
+		for _, cost := range state.costs {
+			if cost != nil {
+				bigIntPool.Put(cost.SetUint64(0))
+			}
+		}
+	}()
+
+	// Rent *big.Int objects from the pool instead of allocating new ones
+	for i := range numTokens {
+		state.known[i] = bitset.NewBitSet(uint64(numTokens))
+		state.costs[i] = bigIntPool.Get().(*big.Int).SetUint64(0)
+	}
+
+	state.costs[baseIndex].Set(params.AmountIn)
+
+	for range runs {
+		for j := range numTokens {
+			if state.costs[j].Sign() == 0 {
+				continue
+			}
+			state.current = j
+			if err := g.findArbitragePath(state, getAmountOutFuncs); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	if len(state.paths[baseIndex]) == 0 {
+		return nil, nil, nil
+	}
+
+	// we set new big.Int because costs big.Ints are returned to pool
+	cycle := state.paths[baseIndex]
+	var netAmounts []*big.Int
+	if params.GasModel != nil {
+		pricer, err := g.cycleGasPricer(params, baseIndex)
+		if err != nil {
+			return nil, nil, err
+		}
+		gas, ok := pricer.pathGas(g, cycle)
+		if !ok {
+			return nil, nil, fmt.Errorf("cannot estimate the gas of the cycle from token %d", params.TokenID)
+		}
+		netAmounts = []*big.Int{pricer.net(baseIndex, state.bestCycleCost, gas)}
+	}
+	return g.filterByMinProfit(params, [][]chains.TokenPoolPath{cycle}, []*big.Int{state.bestCycleCost}, netAmounts)
+}
+
+// findArbitragePath is the core Bellman-Ford-like relaxation step for finding arbitrage.
+func (g *Graph) findArbitragePath(
+	state *findArbitrageCyclesState,
+	getAmountOutFuncs []GetAmountOutFunc,
+) error {
+
+	currentIndex := state.current
+	currentCost := state.costs[currentIndex]
+	currentKnown := state.known[currentIndex]
+	currentPath := state.paths[currentIndex]
+	currentTokenID := g.rawGraph.Tokens[currentIndex]
+
+	if currentKnown.IsSet(uint64(currentIndex)) {
+		return nil
+	}
+
+	maxAmountOut := state.temp
+	for _, edgeIndex := range g.rawGraph.Adjacency[currentIndex] {
+		targetIndex := g.rawGraph.EdgeTargets[edgeIndex]
+		targetTokenID := g.rawGraph.Tokens[targetIndex]
+		if currentKnown.IsSet(uint64(targetIndex)) && targetIndex != state.start {
+			continue
+		}
+
+		bestPoolIndex := -1
+		maxAmountOut.SetUint64(0)
+
+		for _, poolIndex := range g.rawGraph.EdgePools[edgeIndex] {
+			// ignore pools currently in path
+			if poolInPath(currentPath, g.rawGraph.Pools[poolIndex]) {
+				continue
+			}
+			getAmountOut := getAmountOutFuncs[poolIndex]
+			// can be nil if pool is not part of active set
+			if getAmountOut == nil {
+				continue
+			}
+			amountOut, err := getAmountOut(currentCost, currentTokenID, targetTokenID)
+			if err == nil && amountOut.Cmp(maxAmountOut) == 1 {
+				maxAmountOut.Set(amountOut)
+				bestPoolIndex = poolIndex
+			}
+		}
+
+		if bestPoolIndex == -1 {
+			continue
+		}
+
+		// handle target == start separately
+		if targetIndex == state.start {
+			// this allows us to still collect unprofitable cycles (to be optimized up stream)
+			if maxAmountOut.Cmp(state.bestCycleCost) == 1 {
+				poolID := g.rawGraph.Pools[bestPoolIndex]
+				newPath := make([]chains.TokenPoolPath, len(currentPath)+1)
+				copy(newPath, currentPath)
+				newPath[len(currentPath)] = chains.TokenPoolPath{
+					TokenInID:  currentTokenID,
+					TokenOutID: targetTokenID,
+					PoolID:     poolID,
+				}
+				state.paths[targetIndex] = newPath
+				state.known[targetIndex].SetFrom(currentKnown)
+				state.known[targetIndex].Set(uint64(currentIndex))
+				// set best cycle cost
+				state.bestCycleCost.Set(maxAmountOut)
+			}
+		} else if maxAmountOut.Cmp(state.costs[targetIndex]) == 1 {
+			poolID := g.rawGraph.Pools[bestPoolIndex]
+			newPath := make([]chains.TokenPoolPath, len(currentPath)+1)
+			copy(newPath, currentPath)
+			newPath[len(currentPath)] = chains.TokenPoolPath{
+				TokenInID:  currentTokenID,
+				TokenOutID: targetTokenID,
+				PoolID:     poolID,
+			}
+			state.paths[targetIndex] = newPath
+			state.known[targetIndex].SetFrom(currentKnown)
+			state.known[targetIndex].Set(uint64(currentIndex))
+			state.costs[targetIndex].Set(maxAmountOut)
+		}
+	}
+
+	return nil
+}
+
+// rankedCycle is a complete cycle together with the amount of the start token it returns.
+// netOut is the amount the cycle is ranked by: amountOut, less the cost of gas when a gas
+// model is set.
+type rankedCycle struct {
+	path      []chains.TokenPoolPath
+	amountOut *big.Int
+	netOut    *big.Int
+}
+
+// cycleHeap is a min-heap of cycles ordered by netOut. It keeps the top-N most
+// profitable cycles: once full, the least profitable one sits at the root and is evicted first.
+type cycleHeap []rankedCycle
+
+func (h cycleHeap) Len() int           { return len(h) }
+func (h cycleHeap) Less(i, j int) bool { return h[i].netOut.Cmp(h[j].netOut) == -1 }
+func (h cycleHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *cycleHeap) Push(x any)        { *h = append(*h, x.(rankedCycle)) }
+func (h *cycleHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// findAllArbitrageCyclesState encapsulates the state required for the depth-first
+// enumeration of all cycles from a start token.
+type findAllArbitrageCyclesState struct {
+	start    int
+	maxHops  int
+	limit    int
+	path     []chains.TokenPoolPath
+	visited  bitset.BitSet // vertex index -> on current path
+	cycles   cycleHeap
+	unsorted []rankedCycle // used when limit is 0 (unlimited)
+
+	// depthLimited is set when maxHops is the depth cap rather than the requested runs;
+	// truncated records that a path was then cut at the cap.
+	depthLimited bool
+	truncated    bool
+
+	// gas prices hops when CycleFindingParams.GasModel is set.
+	gas *gasPricer
+}
+
+// FindAllArbitrageCycles enumerates every cycle of up to params.Runs hops that starts and
+// ends at params.TokenID, using the best pool for each hop. Unlike FindArbitrageCycles it
+// does not stop at the best cycle; results are ordered from most to least profitable,
+// net of gas when params.GasModel is set.
+// The depth-first walk never goes deeper than params.MaxDepth hops; if that cuts it
+// short, the cycles found are returned with an error wrapping ErrCycleDepthLimit.
+//
+// limit caps the number of cycles returned to the top-limit by profit. The cycles are
+// ranked in a bounded heap as they are found, so at most limit cycles are held in memory.
+// A limit of 0 means unlimited.
+func (g *Graph) FindAllArbitrageCycles(params chains.CycleFindingParams, limit int) ([][]chains.TokenPoolPath, []*big.Int, error) {
+	if params.Runs <= 0 {
+		return nil, nil, errors.New("CycleFindingParams: runs must be greater than 0")
+	}
+	if limit < 0 {
+		return nil, nil, errors.New("limit must not be negative")
+	}
+	if params.MaxPools < 0 {
+		return nil, nil, errors.New("CycleFindingParams: max pools must not be negative")
+	}
+	if params.MaxDepth < 0 {
+		return nil, nil, errors.New("CycleFindingParams: max depth must not be negative")
+	}
+	maxDepth := params.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = chains.DefaultMaxCycleDepth
+	}
+
+	getAmountOutFuncs := g.applyOverrides(g.activeGetAmountOutFuncs, params.UniswapV2Overrides, params.UniswapV3Overrides)
+	g.restrictPools(getAmountOutFuncs, params)
+
+	baseIndex, exists := g.tokenToIndex[params.TokenID]
+	if !exists {
+		return nil, nil, fmt.Errorf("token %d not found in the graph", params.TokenID)
+	}
+
+	state := &findAllArbitrageCyclesState{
+		start:        baseIndex,
+		maxHops:      min(params.Runs, maxDepth),
+		depthLimited: params.Runs > maxDepth,
+		limit:        limit,
+		path:         make([]chains.TokenPoolPath, 0, min(params.Runs, maxDepth)),
+		visited:      bitset.NewBitSet(uint64(len(g.rawGraph.Tokens))),
+	}
+	if limit > 0 {
+		state.cycles = make(cycleHeap, 0, limit)
+	}
+	if params.GasModel != nil {
+		var err error
+		if state.gas, err = g.cycleGasPricer(params, baseIndex); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	state.visited.Set(uint64(baseIndex))
+	g.enumerateCycles(state, baseIndex, params.AmountIn, getAmountOutFuncs)
+
+	// Order the results from most to least profitable.
+	ranked := state.unsorted
+	if limit > 0 {
+		ranked = make([]rankedCycle, state.cycles.Len())
+		for i := len(ranked) - 1; i >= 0; i-- {
+			ranked[i] = heap.Pop(&state.cycles).(rankedCycle)
+		}
+	} else {
+		sort.SliceStable(ranked, func(i, j int) bool {
+			return ranked[i].netOut.Cmp(ranked[j].netOut) == 1
+		})
+	}
+
+	var truncated error
+	if state.truncated {
+		truncated = fmt.Errorf("%w: cycles from token %d were cut at %d hops", chains.ErrCycleDepthLimit, params.TokenID, state.maxHops)
+	}
+	if len(ranked) == 0 {
+		return nil, nil, truncated
+	}
+
+	cycles := make([][]chains.TokenPoolPath, len(ranked))
+	amounts := make([]*big.Int, len(ranked))
+	netAmounts := make([]*big.Int, len(ranked))
+	for i, c := range ranked {
+		cycles[i] = c.path
+		amounts[i] = c.amountOut
+		netAmounts[i] = c.netOut
+	}
+	cycles, amounts, err := g.filterByMinProfit(params, cycles, amounts, netAmounts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cycles, amounts, truncated
+}
+
+// cycleGasPricer returns the gas pricer for params.GasModel, checking that gas can be
+// priced in the cycles' start token.
+func (g *Graph) cycleGasPricer(params chains.CycleFindingParams, baseIndex int) (*gasPricer, error) {
+	pricer, err := g.newGasPricer(params.GasModel, params.NativeTokenID, params.Runs)
+	if err != nil {
+		return nil, err
+	}
+	if pricer.rates[baseIndex] == nil {
+		return nil, fmt.Errorf("cannot price gas in token %d: no conversion from native token %d", params.TokenID, params.NativeTokenID)
+	}
+	return pricer, nil
+}
+
+// filterByMinProfit drops the cycles whose profit is below params.MinProfit, keeping
+// the order of the rest. Profit is measured on netAmounts, the amounts out net of gas,
+// which may be nil to use amounts. Without a threshold the input is returned unchanged.
+func (g *Graph) filterByMinProfit(
+	params chains.CycleFindingParams,
+	cycles [][]chains.TokenPoolPath,
+	amounts []*big.Int,
+	netAmounts []*big.Int,
+) ([][]chains.TokenPoolPath, []*big.Int, error) {
+	threshold := params.MinProfit
+	if threshold == nil {
+		return cycles, amounts, nil
+	}
+	if threshold.Amount == nil {
+		return nil, nil, errors.New("ProfitThreshold: amount must be set")
+	}
+
+	// toUnit converts a raw profit into the threshold's unit.
+	var toUnit func(profit *big.Int) *big.Float
+	switch threshold.Unit {
+	case chains.ProfitUnitRaw:
+		toUnit = func(profit *big.Int) *big.Float {
+			return new(big.Float).SetInt(profit)
+		}
+	case chains.ProfitUnitBps:
+		amountIn := new(big.Float).SetInt(params.AmountIn)
+		toUnit = func(profit *big.Int) *big.Float {
+			bps := new(big.Float).SetInt(new(big.Int).Mul(profit, big.NewInt(10_000)))
+			return bps.Quo(bps, amountIn)
+		}
+	case chains.ProfitUnitUSD:
+		price, err := g.USDPrice(chains.USDPriceParams{
+			TokenID:        params.TokenID,
+			AmountIn:       params.AmountIn,
+			StableTokenIDs: threshold.StableTokenIDs,
+			Mode:           chains.USDPriceMedian,
+			Runs:           params.Runs,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("pricing profit in USD: %w", err)
+		}
+		decimals, _ := g.tokenDecimals(params.TokenID)
+		toUnit = func(profit *big.Int) *big.Float {
+			usd := toWholeUnits(profit, decimals)
+			return usd.Mul(usd, price)
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown profit unit %d", threshold.Unit)
+	}
+
+	var keptCycles [][]chains.TokenPoolPath
+	var keptAmounts []*big.Int
+	if netAmounts == nil {
+		netAmounts = amounts
+	}
+	for i, amountOut := range amounts {
+		profit := new(big.Int).Sub(netAmounts[i], params.AmountIn)
+		if toUnit(profit).Cmp(threshold.Amount) < 0 {
+			continue
+		}
+		keptCycles = append(keptCycles, cycles[i])
+		keptAmounts = append(keptAmounts, amountOut)
+	}
+	return keptCycles, keptAmounts, nil
+}
+
+// enumerateCycles walks all simple paths from currentIndex, recording every path that
+// returns to the start token.
+func (g *Graph) enumerateCycles(
+	state *findAllArbitrageCyclesState,
+	currentIndex int,
+	currentAmount *big.Int,
+	getAmountOutFuncs []GetAmountOutFunc,
+) {
+	if len(state.path) >= state.maxHops {
+		state.truncated = state.truncated || state.depthLimited
+		return
+	}
+
+	currentTokenID := g.rawGraph.Tokens[currentIndex]
+	for _, edgeIndex := range g.rawGraph.Adjacency[currentIndex] {
+		targetIndex := g.rawGraph.EdgeTargets[edgeIndex]
+		if targetIndex != state.start && state.visited.IsSet(uint64(targetIndex)) {
+			continue
+		}
+		targetTokenID := g.rawGraph.Tokens[targetIndex]
+
+		// Pick the best pool for this hop, ignoring pools already in the path. With a gas
+		// model, pools are compared net of their hop's gas.
+		bestPoolIndex := -1
+		var bestAmountOut, bestNetOut *big.Int
+		for _, poolIndex := range g.rawGraph.EdgePools[edgeIndex] {
+			if poolInPath(state.path, g.rawGraph.Pools[poolIndex]) {
+				continue
+			}
+			getAmountOut := getAmountOutFuncs[poolIndex]
+			if getAmountOut == nil {
+				continue
+			}
+			var hopGas uint64
+			if state.gas != nil {
+				var ok bool
+				if hopGas, ok = state.gas.hopGas(g, poolIndex); !ok {
+					continue
+				}
+			}
+			amountOut, err := getAmountOut(currentAmount, currentTokenID, targetTokenID)
+			if err != nil || amountOut == nil || amountOut.Sign() <= 0 {
+				continue
+			}
+			netOut := amountOut
+			if state.gas != nil {
+				netOut = state.gas.net(targetIndex, amountOut, hopGas)
+			}
+			if bestNetOut == nil || netOut.Cmp(bestNetOut) == 1 {
+				bestAmountOut, bestNetOut = amountOut, netOut
+				bestPoolIndex = poolIndex
+			}
+		}
+		if bestPoolIndex == -1 {
+			continue
+		}
+
+		state.path = append(state.path, chains.TokenPoolPath{
+			TokenInID:  currentTokenID,
+			TokenOutID: targetTokenID,
+			PoolID:     g.rawGraph.Pools[bestPoolIndex],
+		})
+
+		if targetIndex == state.start {
+			g.recordCycle(state, bestAmountOut)
+		} else {
+			state.visited.Set(uint64(targetIndex))
+			g.enumerateCycles(state, targetIndex, bestAmountOut, getAmountOutFuncs)
+			state.visited.Unset(uint64(targetIndex))
+		}
+
+		state.path = state.path[:len(state.path)-1]
+	}
+}
+
+// recordCycle stores a copy of the current path, evicting the least profitable
+// cycle when the bounded heap is full.
+func (g *Graph) recordCycle(state *findAllArbitrageCyclesState, amountOut *big.Int) {
+	netOut := new(big.Int).Set(amountOut)
+	if state.gas != nil {
+		// Every hop was estimated when its pool was picked.
+		gas, _ := state.gas.pathGas(g, state.path)
+		netOut = state.gas.net(state.start, amountOut, gas)
+	}
+	if state.limit == 0 {
+		state.unsorted = append(state.unsorted, rankedCycle{
+			path:      append([]chains.TokenPoolPath(nil), state.path...),
+			amountOut: new(big.Int).Set(amountOut),
+			netOut:    netOut,
+		})
+		return
+	}
+
+	if state.cycles.Len() == state.limit {
+		if netOut.Cmp(state.cycles[0].netOut) <= 0 {
+			return // Not better than the worst cycle we are keeping.
+		}
+		heap.Pop(&state.cycles)
+	}
+	heap.Push(&state.cycles, rankedCycle{
+		path:      append([]chains.TokenPoolPath(nil), state.path...),
+		amountOut: new(big.Int).Set(amountOut),
+		netOut:    netOut,
+	})
+}
+
+// applyOverrides returns a copy of funcs with the given pool overrides patched in.
+// Pools without a function in funcs (i.e. inactive pools) are left untouched.
+func (g *Graph) applyOverrides(
+	funcs []GetAmountOutFunc,
+	uniswapV2Overrides map[uint64]uniswapv2.Pool,
+	uniswapV3Overrides map[uint64]uniswapv3.Pool,
+) []GetAmountOutFunc {
+	getAmountOutFuncs := make([]GetAmountOutFunc, len(funcs))
+	copy(getAmountOutFuncs, funcs)
+
+	for poolID, overriddenPool := range uniswapV2Overrides {
+		poolIndex, exists := g.poolToIndex[poolID]
+		if !exists || getAmountOutFuncs[poolIndex] == nil {
+			continue
+		}
+		getAmountOutFuncs[poolIndex] = func(amountIn *big.Int, tokenInID, tokenOutID uint64) (*big.Int, error) {
+			return uniswapv2calculator.GetAmountOut(amountIn, tokenInID, tokenOutID, overriddenPool)
+		}
+	}
+
+	for poolID, overriddenPool := range uniswapV3Overrides {
+		poolIndex, exists := g.poolToIndex[poolID]
+		if !exists || getAmountOutFuncs[poolIndex] == nil {
+			continue
+		}
+		getAmountOutFuncs[poolIndex] = func(amountIn *big.Int, tokenInID, tokenOutID uint64) (*big.Int, error) {
+			return uniswapv3calculator.GetAmountOut(amountIn, nil, tokenInID, overriddenPool)
+		}
+	}
+
+	return getAmountOutFuncs
+}
+
+// applyCachedOverrides is applyOverrides for the float64 quote functions.
+func (g *Graph) applyCachedOverrides(
+	uniswapV2Overrides map[uint64]uniswapv2.Pool,
+	uniswapV3Overrides map[uint64]uniswapv3.Pool,
+) []GetAmountOutFromCacheFunc {
+	cachedFuncs := make([]GetAmountOutFromCacheFunc, len(g.cachedGetAmountOutFuncs))
+	copy(cachedFuncs, g.cachedGetAmountOutFuncs)
+
+	for poolID, overriddenPool := range uniswapV2Overrides {
+		poolIndex, exists := g.poolToIndex[poolID]
+		if !exists || cachedFuncs[poolIndex] == nil {
+			continue
+		}
+		cachedFuncs[poolIndex] = uniswapV2FloatQuote(overriddenPool)
+	}
+
+	for poolID, overriddenPool := range uniswapV3Overrides {
+		poolIndex, exists := g.poolToIndex[poolID]
+		if !exists || cachedFuncs[poolIndex] == nil {
+			continue
+		}
+		cachedFuncs[poolIndex] = uniswapV3FloatQuote(overriddenPool)
+	}
+
+	return cachedFuncs
+}
+
+// uniswapV2FloatQuote returns the constant-product quote of a Uniswap V2 pool in float64.
+// The reserves are converted once, when the function is built.
+func uniswapV2FloatQuote(pool uniswapv2.Pool) GetAmountOutFromCacheFunc {
+	reserve0, reserve1 := bigToFloat64(pool.Reserve0), bigToFloat64(pool.Reserve1)
+	feeMultiplier := float64(10_000-int64(pool.SwapFeeBps())) / 10_000
+	return constantProductFloatQuote(pool.Token0, pool.Token1, reserve0, reserve1, feeMultiplier)
+}
+
+// uniswapV3FloatQuote approximates a Uniswap V3 pool in float64 by a constant-product pool
+// over its virtual reserves, which only holds while the swap stays within the current tick.
+func uniswapV3FloatQuote(pool uniswapv3.Pool) GetAmountOutFromCacheFunc {
+	virtual0, virtual1 := uniswapv3calculator.VirtualReserves(pool)
+	feeMultiplier := float64(1_000_000-int64(pool.SwapFee())) / 1_000_000
+	return constantProductFloatQuote(pool.Token0, pool.Token1, bigToFloat64(virtual0), bigToFloat64(virtual1), feeMultiplier)
+}
+
+func constantProductFloatQuote(token0, token1 uint64, reserve0, reserve1, feeMultiplier float64) GetAmountOutFromCacheFunc {
+	return func(amountIn float64, tokenInID, tokenOutID uint64) (float64, error) {
+		reserveIn, reserveOut := reserve0, reserve1
+		switch {
+		case tokenInID == token0 && tokenOutID == token1:
+		case tokenInID == token1 && tokenOutID == token0:
+			reserveIn, reserveOut = reserve1, reserve0
+		default:
+			return 0, fmt.Errorf("pool does not trade token %d for token %d", tokenInID, tokenOutID)
+		}
+		if reserveIn <= 0 || reserveOut <= 0 {
+			return 0, nil
+		}
+		amountInWithFee := amountIn * feeMultiplier
+		return amountInWithFee * reserveOut / (reserveIn + amountInWithFee), nil
+	}
+}
+
+// bigToFloat64 converts x to the nearest float64; nil converts to zero.
+func bigToFloat64(x *big.Int) float64 {
+	if x == nil {
+		return 0
+	}
+	f, _ := new(big.Float).SetInt(x).Float64()
+	return f
+}
+
+// CycleNetProfit evaluates a cycle (typically one returned by FindArbitrageCycles) at the given
+// amount and subtracts the estimated gas cost of executing it. Gas is estimated per hop from
+// params.GasEstimates, priced at params.GasPrice and converted into the cycle's start token
+// using GetExchangeRates from the native token.
+func (g *Graph) CycleNetProfit(params chains.CycleProfitParams) (*chains.CycleProfit, error) {
+	if len(params.Cycle) == 0 {
+		return nil, errors.New("CycleProfitParams: cycle must not be empty")
+	}
+	if params.AmountIn == nil || params.AmountIn.Sign() <= 0 {
+		return nil, errors.New("CycleProfitParams: amountIn must be greater than 0")
+	}
+	if params.GasPrice == nil || params.GasPrice.Sign() < 0 {
+		return nil, errors.New("CycleProfitParams: gasPrice must not be negative")
+	}
+
+	startTokenID := params.Cycle[0].TokenInID
+	if params.Cycle[len(params.Cycle)-1].TokenOutID != startTokenID {
+		return nil, fmt.Errorf("path does not form a cycle: starts at token %d, ends at token %d", startTokenID, params.Cycle[len(params.Cycle)-1].TokenOutID)
+	}
+
+	// Step 1: Simulate the cycle hop by hop and accumulate the gas used.
+	amountOut := new(big.Int).Set(params.AmountIn)
+	var gasUsed uint64
+	for _, hop := range params.Cycle {
+		poolIndex, exists := g.poolToIndex[hop.PoolID]
+		if !exists {
+			return nil, fmt.Errorf("pool %d not found in the graph", hop.PoolID)
+		}
+		getAmountOut := g.allGetAmountOutFuncs[poolIndex]
+		if getAmountOut == nil {
+			return nil, fmt.Errorf("pool %d cannot be quoted", hop.PoolID)
+		}
+		schema, ok := g.protocolResolver.ResolveSchemaFromPoolID(hop.PoolID)
+		if !ok {
+			return nil, fmt.Errorf("protocol schema not found for pool ID %d", hop.PoolID)
+		}
+		gas, ok := params.GasEstimates[schema]
+		if !ok {
+			return nil, fmt.Errorf("no gas estimate for schema %s (pool %d)", schema, hop.PoolID)
+		}
+		gasUsed += gas
+
+		out, err := getAmountOut(amountOut, hop.TokenInID, hop.TokenOutID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to quote pool %d: %w", hop.PoolID, err)
+		}
+		amountOut.Set(out)
+	}
+
+	// Step 2: Price the gas in the native token, then convert it into the cycle's token.
+	gasCostNative := new(big.Int).Mul(new(big.Int).SetUint64(gasUsed), params.GasPrice)
+	gasCost := new(big.Int)
+	switch {
+	case gasCostNative.Sign() == 0:
+	case startTokenID == params.NativeTokenID:
+		gasCost.Set(gasCostNative)
+	default:
+		rates, err := g.GetExchangeRates(gasCostNative, params.NativeTokenID, params.Runs, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to price gas: %w", err)
+		}
+		rate, ok := rates[startTokenID]
+		if !ok {
+			return nil, fmt.Errorf("no exchange rate from native token %d to token %d", params.NativeTokenID, startTokenID)
+		}
+		gasCost.Set(rate)
+	}
+
+	grossProfit := new(big.Int).Sub(amountOut, params.AmountIn)
+	netProfit := new(big.Int).Sub(grossProfit, gasCost)
+	return &chains.CycleProfit{
+		AmountOut:     amountOut,
+		GrossProfit:   grossProfit,
+		GasUsed:       gasUsed,
+		GasCostNative: gasCostNative,
+		GasCost:       gasCost,
+		NetProfit:     netProfit,
+		Profitable:    netProfit.Sign() > 0,
+	}, nil
+}
+
+// feePipsDenominator is 100% in hundredths of a basis point, the unit of pool fees.
+const feePipsDenominator = 1_000_000
+
+// BreakEvenFee quotes both routes at params.AmountIn and finds, by binary search, the
+// largest extra fee on the amount in that the better route can bear while still producing
+// at least as much as the other one. The extra fee stands in for a more expensive fee tier
+// on the better route, so it tells how much cheaper the worse route would have to be to win.
+func (g *Graph) BreakEvenFee(params chains.BreakEvenFeeParams) (*chains.BreakEvenFee, error) {
+	if len(params.RouteA) == 0 || len(params.RouteB) == 0 {
+		return nil, errors.New("BreakEvenFeeParams: routes must not be empty")
+	}
+	if params.AmountIn == nil || params.AmountIn.Sign() <= 0 {
+		return nil, errors.New("BreakEvenFeeParams: amountIn must be greater than 0")
+	}
+	a, b := params.RouteA, params.RouteB
+	if a[0].TokenInID != b[0].TokenInID || a[len(a)-1].TokenOutID != b[len(b)-1].TokenOutID {
+		return nil, fmt.Errorf("routes do not connect the same tokens: %d -> %d and %d -> %d",
+			a[0].TokenInID, a[len(a)-1].TokenOutID, b[0].TokenInID, b[len(b)-1].TokenOutID)
+	}
+
+	outA, err := g.quoteRoute(a, params.AmountIn)
+	if err != nil {
+		return nil, fmt.Errorf("route A: %w", err)
+	}
+	outB, err := g.quoteRoute(b, params.AmountIn)
+	if err != nil {
+		return nil, fmt.Errorf("route B: %w", err)
+	}
+	result := &chains.BreakEvenFee{AmountOutA: outA, AmountOutB: outB, BetterIsA: outA.Cmp(outB) >= 0}
+
+	better, worseOut := a, outB
+	if !result.BetterIsA {
+		better, worseOut = b, outA
+	}
+
+	// The better route's output only falls as the fee grows, so search for the first fee
+	// at which it drops below the other route's output.
+	var quoteErr error
+	amountIn := new(big.Int)
+	firstWorse := sort.Search(feePipsDenominator+1, func(fee int) bool {
+		if quoteErr != nil {
+			return true
+		}
+		amountIn.Mul(params.AmountIn, big.NewInt(int64(feePipsDenominator-fee)))
+		amountIn.Quo(amountIn, big.NewInt(feePipsDenominator))
+		out, err := g.quoteRoute(better, amountIn)
+		if err != nil {
+			quoteErr = err
+			return true
+		}
+		return out.Cmp(worseOut) < 0
+	})
+	if quoteErr != nil {
+		return nil, quoteErr
+	}
+	result.FeePips = uint64(firstWorse - 1)
+	return result, nil
+}
+
+// quoteRoute quotes amountIn through every hop of path. A zero amount quotes to zero.
+func (g *Graph) quoteRoute(path []chains.TokenPoolPath, amountIn *big.Int) (*big.Int, error) {
+	amount := new(big.Int).Set(amountIn)
+	for i, hop := range path {
+		if amount.Sign() == 0 {
+			return amount, nil
+		}
+		if i > 0 && hop.TokenInID != path[i-1].TokenOutID {
+			return nil, fmt.Errorf("hop %d starts at token %d but hop %d ends at token %d", i, hop.TokenInID, i-1, path[i-1].TokenOutID)
+		}
+		poolIndex, exists := g.poolToIndex[hop.PoolID]
+		if !exists {
+			return nil, fmt.Errorf("pool %d not found in the graph", hop.PoolID)
+		}
+		getAmountOut := g.allGetAmountOutFuncs[poolIndex]
+		if getAmountOut == nil {
+			return nil, fmt.Errorf("pool %d cannot be quoted", hop.PoolID)
+		}
+		out, err := getAmountOut(amount, hop.TokenInID, hop.TokenOutID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to quote pool %d: %w", hop.PoolID, err)
+		}
+		amount.Set(out)
+	}
+	return amount, nil
+}
+
+// findCheapestRouteState encapsulates the state required for the depth-first search
+// of the cheapest acceptable route.
+type findCheapestRouteState struct {
+	end          int
+	maxHops      int
+	minOut       *big.Int
+	gasEstimates chains.GasEstimates
+	poolGas      map[int]uint64 // pool index -> per-hop gas, resolved lazily
+	path         []chains.TokenPoolPath
+	visited      bitset.BitSet // vertex index -> on current path
+	best         *chains.CheapestRoute
+}
+
+// FindCheapestAcceptableRoute inverts the usual objective of FindBestSwapPath: among all
+// routes of up to params.Runs hops that produce at least params.MinAcceptableOut, it
+// returns the one with the lowest estimated gas. Ties on gas are broken by the higher
+// output. Every pool of every edge is considered, since pools of different protocols
+// sharing an edge cost different amounts of gas.
+func (g *Graph) FindCheapestAcceptableRoute(params chains.CheapestRouteParams) (*chains.CheapestRoute, error) {
+	if params.AmountIn == nil || params.AmountIn.Sign() <= 0 {
+		return nil, errors.New("CheapestRouteParams: amountIn must be greater than 0")
+	}
+	if params.MinAcceptableOut == nil || params.MinAcceptableOut.Sign() < 0 {
+		return nil, errors.New("CheapestRouteParams: minAcceptableOut must not be negative")
+	}
+	if params.Runs <= 0 {
+		return nil, errors.New("CheapestRouteParams: runs must be greater than 0")
+	}
+
+	startIndex, exists := g.tokenToIndex[params.TokenInID]
+	if !exists {
+		return nil, fmt.Errorf("start token %d not found in the graph", params.TokenInID)
+	}
+	endIndex, exists := g.tokenToIndex[params.TokenOutID]
+	if !exists {
+		return nil, fmt.Errorf("end token %d not found in the graph", params.TokenOutID)
+	}
+
+	getAmountOutFuncs := g.applyOverrides(g.activeGetAmountOutFuncs, params.UniswapV2Overrides, params.UniswapV3Overrides)
+
+	state := &findCheapestRouteState{
+		end:          endIndex,
+		maxHops:      params.Runs,
+		minOut:       params.MinAcceptableOut,
+		gasEstimates: params.GasEstimates,
+		poolGas:      make(map[int]uint64),
+		path:         make([]chains.TokenPoolPath, 0, params.Runs),
+		visited:      bitset.NewBitSet(uint64(len(g.rawGraph.Tokens))),
 	}
+	state.visited.Set(uint64(startIndex))
+	g.searchCheapestRoute(state, startIndex, params.AmountIn, 0, getAmountOutFuncs)
 
-	// Iterate through all outgoing edges from the current token.
+	if state.best == nil {
+		return nil, fmt.Errorf("%w: token %d to token %d with at least %s out", chains.ErrNoRoute, params.TokenInID, params.TokenOutID, params.MinAcceptableOut)
+	}
+	return state.best, nil
+}
+
+// searchCheapestRoute walks all simple paths from currentIndex, keeping the cheapest
+// route to the end token that clears the minimum output.
+func (g *Graph) searchCheapestRoute(
+	state *findCheapestRouteState,
+	currentIndex int,
+	currentAmount *big.Int,
+	gasUsed uint64,
+	getAmountOutFuncs []GetAmountOutFunc,
+) {
+	if len(state.path) >= state.maxHops {
+		return
+	}
+
+	currentTokenID := g.rawGraph.Tokens[currentIndex]
 	for _, edgeIndex := range g.rawGraph.Adjacency[currentIndex] {
 		targetIndex := g.rawGraph.EdgeTargets[edgeIndex]
-		targetTokenID := g.rawGraph.Tokens[targetIndex]
-
-		// Crucial cycle prevention: do not traverse to a token that is already in the current path.
-		if currentKnown.IsSet(uint64(targetIndex)) {
+		if state.visited.IsSet(uint64(targetIndex)) {
 			continue
 		}
+		targetTokenID := g.rawGraph.Tokens[targetIndex]
 
-		bestAmountOut := state.temp
-		if !state.bestConnectionComputed.IsSet(uint64(edgeIndex)) {
-			// Iterate through all pools associated with this edge.
-			bestConnection := -1
-			bestAmountOut.SetUint64(0)
-			for _, poolIndex := range g.rawGraph.EdgePools[edgeIndex] {
-				getAmountOutFunc := g.allGetAmountOutFuncs[poolIndex]
-				// can be nil - @todo fix this
-				if getAmountOutFunc == nil {
-					continue
-				}
-				amountOut, err := getAmountOutFunc(currentCost, currentTokenID, targetTokenID)
-				if err != nil || amountOut == nil || amountOut.Sign() <= 0 {
-					continue
-				}
-
-				// we need the reserveOut
-				if amountOut.Cmp(bestAmountOut) == 1 {
-					bestAmountOut.Set(amountOut)
-					bestConnection = poolIndex
-				}
+		for _, poolIndex := range g.rawGraph.EdgePools[edgeIndex] {
+			getAmountOut := getAmountOutFuncs[poolIndex]
+			if getAmountOut == nil {
+				continue
 			}
-
-			if bestConnection != -1 {
-				// we have found a best connection for this edge (the pool with the highest reserve for targetID)
-				state.bestConnection[edgeIndex] = bestConnection
-				state.bestConnectionComputed.Set(uint64(edgeIndex))
+			hopGas, ok := g.poolGas(state, poolIndex)
+			if !ok {
+				continue
+			}
+			routeGas := gasUsed + hopGas
+			// Gas only grows along a path, so anything already costlier than the best is pruned.
+			if state.best != nil && routeGas > state.best.GasUsed {
+				continue
 			}
-		}
 
-		if state.bestConnection[edgeIndex] != -1 {
-			poolIndex := state.bestConnection[edgeIndex]
-			amountOut, err := g.allGetAmountOutFuncs[poolIndex](currentCost, currentTokenID, targetTokenID)
+			amountOut, err := getAmountOut(currentAmount, currentTokenID, targetTokenID)
 			if err != nil || amountOut == nil || amountOut.Sign() <= 0 {
 				continue
 			}
 
-			if state.costs[targetIndex].Sign() == 0 || amountOut.Cmp(state.costs[targetIndex]) == 1 {
-				state.costs[targetIndex].Set(amountOut)
-				poolID := g.rawGraph.Pools[poolIndex]
-				newPath := make([]chains.TokenPoolPath, len(currentPath)+1)
-				copy(newPath, currentPath)
-				newPath[len(currentPath)] = chains.TokenPoolPath{
-					TokenInID:  currentTokenID,
-					TokenOutID: targetTokenID,
-					PoolID:     poolID,
+			state.path = append(state.path, chains.TokenPoolPath{
+				TokenInID:  currentTokenID,
+				TokenOutID: targetTokenID,
+				PoolID:     g.rawGraph.Pools[poolIndex],
+			})
+
+			if targetIndex == state.end {
+				if amountOut.Cmp(state.minOut) >= 0 && (state.best == nil ||
+					routeGas < state.best.GasUsed ||
+					(routeGas == state.best.GasUsed && amountOut.Cmp(state.best.AmountOut) == 1)) {
+					state.best = &chains.CheapestRoute{
+						Path:      append([]chains.TokenPoolPath(nil), state.path...),
+						AmountOut: new(big.Int).Set(amountOut),
+						GasUsed:   routeGas,
+					}
 				}
-				state.paths[targetIndex] = newPath
-				state.known[targetIndex].SetFrom(currentKnown)
-				state.known[targetIndex].Set(uint64(currentIndex))
+			} else {
+				state.visited.Set(uint64(targetIndex))
+				g.searchCheapestRoute(state, targetIndex, amountOut, routeGas, getAmountOutFuncs)
+				state.visited.Unset(uint64(targetIndex))
 			}
+
+			state.path = state.path[:len(state.path)-1]
 		}
 	}
-	return nil
 }
 
-// findArbitrageCyclesState encapsulates the state required for the Bellman-Ford-like
-// arbitrage cycle finding algorithm.
-type findArbitrageCyclesState struct {
-	start         int
-	current       int
-	initialCost   *big.Int
-	paths         [][]chains.TokenPoolPath // vertex index -> path
-	costs         []*big.Int               // vertex index -> cost
-	known         []bitset.BitSet          // vertex index -> vertex index
-	bestCycleCost *big.Int
-	temp          *big.Int
+// poolGas returns the per-hop gas estimate for a pool, resolving its schema on first use.
+func (g *Graph) poolGas(state *findCheapestRouteState, poolIndex int) (uint64, bool) {
+	if gas, ok := state.poolGas[poolIndex]; ok {
+		return gas, true
+	}
+	schema, ok := g.protocolResolver.ResolveSchemaFromPoolID(g.rawGraph.Pools[poolIndex])
+	if !ok {
+		return 0, false
+	}
+	gas, ok := state.gasEstimates[schema]
+	if !ok {
+		return 0, false
+	}
+	state.poolGas[poolIndex] = gas
+	return gas, true
 }
 
-// FindArbitrageCycles searches the graph for a best effort at a profitable cycle
-// It begins by initializing all the required fields of the findArbitrageCyclesState and
-// updating our amountOut funcs with the pool overrides (if any)
-func (g *Graph) FindArbitrageCycles(params chains.CycleFindingParams) ([][]chains.TokenPoolPath, []*big.Int, error) {
-	runs := params.Runs
-	if runs <= 0 {
-		return nil, nil, errors.New("CycleFindingParams: runs must be greater than 09")
-	}
+// gasRateUnits is the amount of gas priced when converting gas costs into other tokens.
+// Pricing a large amount keeps the per-unit rate precise in tokens worth much less than gas.
+const gasRateUnits = 1_000_000
 
-	// --- Step 1: Create a temporary, patched slice of swap functions ---
-	getAmountOutFuncs := make([]GetAmountOutFunc, len(g.activeGetAmountOutFuncs))
-	copy(getAmountOutFuncs, g.activeGetAmountOutFuncs)
+// gasPricer prices route gas in the graph's tokens for a GasModel. It is built per search
+// and is not safe for concurrent use.
+type gasPricer struct {
+	model   chains.GasModel
+	rates   []*big.Int // vertex index -> cost of gasRateUnits gas; nil if it cannot be priced
+	poolGas map[int]cachedHopGas
+}
 
-	// Patch the local function slice with V2 overrides.
-	for poolID, overriddenPool := range params.UniswapV2Overrides {
+// cachedHopGas is a GasModel estimate for one pool.
+type cachedHopGas struct {
+	gas uint64
+	ok  bool
+}
 
-		poolIndex, exists := g.poolToIndex[poolID]
-		if !exists {
-			continue
-		}
-		if getAmountOutFuncs[poolIndex] == nil {
-			// pool is inactive skip!
-			continue
+// newGasPricer prices model's gas price in every token reachable from nativeTokenID.
+func (g *Graph) newGasPricer(model chains.GasModel, nativeTokenID uint64, runs int) (*gasPricer, error) {
+	price := model.GasPrice()
+	if price == nil || price.Sign() < 0 {
+		return nil, errors.New("GasModel: gas price must not be negative")
+	}
+	pricer := &gasPricer{
+		model:   model,
+		rates:   make([]*big.Int, len(g.rawGraph.Tokens)),
+		poolGas: make(map[int]cachedHopGas),
+	}
+	if price.Sign() == 0 {
+		free := new(big.Int)
+		for i := range pricer.rates {
+			pricer.rates[i] = free
 		}
-		getAmountOutFuncs[poolIndex] = func(amountIn *big.Int, tokenInID, tokenOutID uint64) (*big.Int, error) {
-			return uniswapv2calculator.GetAmountOut(amountIn, tokenInID, tokenOutID, overriddenPool)
+		return pricer, nil
+	}
+
+	rates, err := g.GetExchangeRates(new(big.Int).Mul(price, big.NewInt(gasRateUnits)), nativeTokenID, max(runs, 1), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to price gas: %w", err)
+	}
+	for tokenID, rate := range rates {
+		if index, exists := g.tokenToIndex[tokenID]; exists {
+			pricer.rates[index] = rate
 		}
 	}
+	return pricer, nil
+}
 
-	// Patch with UniswapV3 overrides.
-	for poolID, overriddenPool := range params.UniswapV3Overrides {
+// hopGas returns the model's estimate for a pool, resolving its schema on first use.
+func (p *gasPricer) hopGas(g *Graph, poolIndex int) (uint64, bool) {
+	if cached, ok := p.poolGas[poolIndex]; ok {
+		return cached.gas, cached.ok
+	}
+	var cached cachedHopGas
+	poolID := g.rawGraph.Pools[poolIndex]
+	if schema, ok := g.protocolResolver.ResolveSchemaFromPoolID(poolID); ok {
+		cached.gas, cached.ok = p.model.HopGas(schema, poolID)
+	}
+	p.poolGas[poolIndex] = cached
+	return cached.gas, cached.ok
+}
 
-		poolIndex, exists := g.poolToIndex[poolID]
+// pathGas returns the estimated gas of path, or false if a hop cannot be estimated.
+func (p *gasPricer) pathGas(g *Graph, path []chains.TokenPoolPath) (uint64, bool) {
+	var total uint64
+	for _, hop := range path {
+		poolIndex, exists := g.poolToIndex[hop.PoolID]
 		if !exists {
-			continue
-		}
-		if getAmountOutFuncs[poolIndex] == nil {
-			// pool is inactive skip!
-			continue
+			return 0, false
 		}
-		getAmountOutFuncs[poolIndex] = func(amountIn *big.Int, tokenInID, tokenOutID uint64) (*big.Int, error) {
-			return uniswapv3calculator.GetAmountOut(amountIn, nil, tokenInID, overriddenPool)
+		gas, ok := p.hopGas(g, poolIndex)
+		if !ok {
+			return 0, false
 		}
+		total += gas
 	}
+	return total, true
+}
 
-	baseIndex, exists := g.tokenToIndex[params.TokenID]
-	if !exists {
-		return nil, nil, fmt.Errorf("token %d not found in the graph", params.TokenID)
+// net returns amount minus the cost of gas in the token at vertex index. Gas is free in
+// tokens it cannot be priced in.
+func (p *gasPricer) net(index int, amount *big.Int, gas uint64) *big.Int {
+	net := new(big.Int).Set(amount)
+	if rate := p.rates[index]; rate != nil && gas > 0 {
+		cost := new(big.Int).Mul(rate, new(big.Int).SetUint64(gas))
+		net.Sub(net, cost.Quo(cost, big.NewInt(gasRateUnits)))
 	}
+	return net
+}
 
-	numTokens := len(g.rawGraph.Tokens)
-	state := &findArbitrageCyclesState{
-		start:         baseIndex,
-		initialCost:   params.AmountIn,
-		paths:         make([][]chains.TokenPoolPath, numTokens),
-		costs:         make([]*big.Int, numTokens),
-		known:         make([]bitset.BitSet, numTokens),
-		bestCycleCost: new(big.Int),
-		temp:          bigIntPool.Get().(*big.Int).SetUint64(0),
-	}
+// findSwapPathsState encapsulates the state required for the Bellman-Ford-like
+// swap path finding algorithm.
+type findSwapPathsState struct {
+	start      int
+	current    int
+	end        int
+	paths      [][]chains.TokenPoolPath // vertex index -> path
+	costs      []*big.Int               // vertex index -> cost
+	known      []bitset.BitSet          // vertex index -> vertex index
+	temp       *big.Int
+	selector   chains.PoolSelector    // nil selects the pool with the max amount out
+	candidates []chains.PoolCandidate // scratch buffer reused across edges when selector is set
 
-	// This defer block is CRITICAL. It ensures all rented objects are returned.
-	defer func() {
-		// Return the scratchpad int
-		bigIntPool.Put(state.temp.SetUint64(0))
-		// Return all integers used in the costs slice
-		for _, cost := range state.costs {
-			if cost != nil {
-				bigIntPool.Put(cost.SetUint64(0))
-			}
-		}
-	}()
+	// allowZeroOutput lets zero amounts propagate so zero-output routes can be returned.
+	// reached tracks which vertices have a path, since a zero cost no longer means "unreached".
+	allowZeroOutput bool
+	reached         bitset.BitSet
 
-	// Rent *big.Int objects from the pool instead of allocating new ones
-	for i := range numTokens {
-		state.known[i] = bitset.NewBitSet(uint64(numTokens))
-		state.costs[i] = bigIntPool.Get().(*big.Int).SetUint64(0)
-	}
+	// equivalences holds the fixed-ratio conversions out of each vertex index.
+	equivalences map[int][]equivalenceHop
 
-	state.costs[baseIndex].Set(params.AmountIn)
+	// edgeWeight is SwapFindingParams.EdgeWeight; when it is set, weights holds the product
+	// of the pool weights along the path to each vertex index.
+	edgeWeight func(poolID uint64) float64
+	weights    []float64
 
-	for range runs {
-		for j := range numTokens {
-			if state.costs[j].Sign() == 0 {
-				continue
-			}
-			state.current = j
-			if err := g.findArbitragePath(state, getAmountOutFuncs); err != nil {
-				return nil, nil, err
-			}
-		}
+	// gas prices hops when SwapFindingParams.GasModel is set; gasUsed then holds the gas
+	// of the path to each vertex index.
+	gas     *gasPricer
+	gasUsed []uint64
+}
+
+// poolWeight returns the routing weight of poolID and whether the pool may be used.
+func (s *findSwapPathsState) poolWeight(poolID uint64) (float64, bool) {
+	if s.edgeWeight == nil {
+		return 1, true
 	}
+	weight := s.edgeWeight(poolID)
+	return weight, weight > 0 && !math.IsInf(weight, 1)
+}
 
-	if len(state.paths[baseIndex]) == 0 {
-		return nil, nil, nil
+// weightedCmp compares a*weightA with b*weightB. The weights must be positive.
+func weightedCmp(a *big.Int, weightA float64, b *big.Int, weightB float64) int {
+	if weightA == weightB {
+		return a.Cmp(b)
 	}
+	x := new(big.Float).SetInt(a)
+	x.Mul(x, big.NewFloat(weightA))
+	y := new(big.Float).SetInt(b)
+	y.Mul(y, big.NewFloat(weightB))
+	return x.Cmp(y)
+}
 
-	// we set new big.Int because costs big.Ints are returned to pool
-	return [][]chains.TokenPoolPath{state.paths[baseIndex]}, []*big.Int{state.bestCycleCost}, nil
+// equivalenceHop converts into the token at target at a fixed ratio of raw amounts.
+type equivalenceHop struct {
+	target int
+	ratio  *big.Rat
 }
 
-// findArbitragePath is the core Bellman-Ford-like relaxation step for finding arbitrage.
-func (g *Graph) findArbitragePath(
-	state *findArbitrageCyclesState,
-	getAmountOutFuncs []GetAmountOutFunc,
-) error {
+// maxValidationFallbacks bounds how many times FindBestSwapPath falls back to the
+// next-best route after a validation failure.
+const maxValidationFallbacks = 3
 
-	currentIndex := state.current
-	currentCost := state.costs[currentIndex]
-	currentKnown := state.known[currentIndex]
-	currentPath := state.paths[currentIndex]
-	currentTokenID := g.rawGraph.Tokens[currentIndex]
+// FindBestSwapPath searches the graph for the most profitable swap path between two tokens.
+// It uses a "copy-and-patch" strategy to handle state overrides.
+func (g *Graph) FindBestSwapPath(params chains.SwapFindingParams) ([]chains.TokenPoolPath, *big.Int, error) {
+	ctx := context.Background()
+	if params.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, params.Timeout)
+		defer cancel()
+	}
 
-	if currentKnown.IsSet(uint64(currentIndex)) {
-		return nil
+	baseFuncs := g.activeGetAmountOutFuncs
+	if params.IncludeInactivePools {
+		baseFuncs = g.allGetAmountOutFuncs
+	}
+	// Create a temporary, patched slice of swap functions.
+	getAmountOutFuncs := g.applyOverrides(baseFuncs, params.UniswapV2Overrides, params.UniswapV3Overrides)
+	if params.MaxPoolStaleBlocks > 0 {
+		if params.SnapshotBlock == 0 {
+			return nil, nil, errors.New("SwapFindingParams: MaxPoolStaleBlocks requires SnapshotBlock")
+		}
+		g.excludeStalePools(getAmountOutFuncs, params)
 	}
 
-	maxAmountOut := state.temp
-	for _, edgeIndex := range g.rawGraph.Adjacency[currentIndex] {
-		targetIndex := g.rawGraph.EdgeTargets[edgeIndex]
-		targetTokenID := g.rawGraph.Tokens[targetIndex]
-		if currentKnown.IsSet(uint64(targetIndex)) && targetIndex != state.start {
-			continue
+	if len(params.Equivalences) > 0 && (params.FloatSearch || params.FallbackOnValidationFailure) {
+		return nil, nil, errors.New("SwapFindingParams: Equivalences are not supported with FloatSearch or FallbackOnValidationFailure")
+	}
+	if params.EdgeWeight != nil && params.FloatSearch {
+		return nil, nil, errors.New("SwapFindingParams: EdgeWeight is not supported with FloatSearch")
+	}
+	if params.GasModel != nil && (params.FloatSearch || params.EdgeWeight != nil) {
+		return nil, nil, errors.New("SwapFindingParams: GasModel is not supported with FloatSearch or EdgeWeight")
+	}
+
+	search := func(funcs []GetAmountOutFunc) ([]chains.TokenPoolPath, *big.Int, error) {
+		return g.findBestSwapPath(ctx, params, funcs)
+	}
+	if params.FloatSearch {
+		if params.PoolSelector != nil {
+			return nil, nil, errors.New("SwapFindingParams: FloatSearch does not support a PoolSelector")
 		}
+		cachedFuncs := g.applyCachedOverrides(params.UniswapV2Overrides, params.UniswapV3Overrides)
+		search = func(funcs []GetAmountOutFunc) ([]chains.TokenPoolPath, *big.Int, error) {
+			return g.findBestSwapPathFloat(ctx, params, funcs, cachedFuncs)
+		}
+	}
 
-		bestPoolIndex := -1
-		maxAmountOut.SetUint64(0)
+	if !params.FallbackOnValidationFailure {
+		return search(getAmountOutFuncs)
+	}
 
-		for _, poolIndex := range g.rawGraph.EdgePools[edgeIndex] {
-			// ignore pools currently in path
-			if poolInPath(currentPath, g.rawGraph.Pools[poolIndex]) {
-				continue
-			}
-			getAmountOut := getAmountOutFuncs[poolIndex]
-			// can be nil if pool is not part of active set
-			if getAmountOut == nil {
-				continue
-			}
-			amountOut, err := getAmountOut(currentCost, currentTokenID, targetTokenID)
-			if err == nil && amountOut.Cmp(maxAmountOut) == 1 {
-				maxAmountOut.Set(amountOut)
-				bestPoolIndex = poolIndex
-			}
+	for attempt := 0; ; attempt++ {
+		path, amountOut, searchErr := search(getAmountOutFuncs)
+		if path == nil {
+			return nil, nil, searchErr
 		}
 
-		if bestPoolIndex == -1 {
-			continue
+		// A partial route returned on timeout is validated too; searchErr is then ErrRouteTimeout.
+		failedPoolID, err := g.validateRoute(path, params.AmountIn, amountOut, getAmountOutFuncs, params.UniswapV2Overrides, params.UniswapV3Overrides)
+		if err == nil {
+			return path, amountOut, searchErr
+		}
+		if params.Logger != nil {
+			params.Logger.Warn("Route failed validation, falling back to the next-best route",
+				"tokenIn", params.TokenInID, "tokenOut", params.TokenOutID, "pool", failedPoolID, "attempt", attempt+1, "err", err)
+		}
+		if searchErr != nil {
+			return nil, nil, searchErr
 		}
+		if attempt == maxValidationFallbacks {
+			return nil, nil, err
+		}
+
+		// Exclude the failing pool; getAmountOutFuncs is already a private copy.
+		getAmountOutFuncs[g.poolToIndex[failedPoolID]] = nil
+	}
+}
+
+// FindBestSwapPathExactOut searches for the route that delivers amountOut of tokenOutID for
+// the least tokenInID, through up to maxHops active pools. It walks the graph backwards
+// from tokenOutID, pricing every hop with the calculators' exact-output quotes, and returns
+// the route in trade order with the input it requires. Pools without an exact-output
+// calculator (Solidly) are not used. It returns ErrNoRoute if no route can deliver amountOut.
+func (g *Graph) FindBestSwapPathExactOut(tokenInID, tokenOutID uint64, amountOut *big.Int, maxHops int) ([]chains.TokenPoolPath, *big.Int, error) {
+	if amountOut == nil || amountOut.Sign() <= 0 {
+		return nil, nil, errors.New("amountOut must be greater than 0")
+	}
+	if maxHops <= 0 {
+		return nil, nil, errors.New("maxHops must be greater than 0")
+	}
+	startIndex, exists := g.tokenToIndex[tokenInID]
+	if !exists {
+		return nil, nil, fmt.Errorf("start token %d not found in the graph", tokenInID)
+	}
+	endIndex, exists := g.tokenToIndex[tokenOutID]
+	if !exists {
+		return nil, nil, fmt.Errorf("end token %d not found in the graph", tokenOutID)
+	}
+	if startIndex == endIndex {
+		return nil, nil, fmt.Errorf("start and end token %d must differ", tokenInID)
+	}
+
+	// costs[i] is the least amount of token i known to buy amountOut, through paths[i].
+	// known[i] holds the vertices paths[i] passes through after i.
+	numTokens := len(g.rawGraph.Tokens)
+	costs := make([]*big.Int, numTokens)
+	paths := make([][]chains.TokenPoolPath, numTokens)
+	known := make([]bitset.BitSet, numTokens)
+	for i := range known {
+		known[i] = bitset.NewBitSet(uint64(numTokens))
+	}
+	costs[endIndex] = amountOut
+
+	for run := 0; run < maxHops; run++ {
+		for current := 0; current < numTokens; current++ {
+			// Routes end at the input token, so it is never extended backwards.
+			if costs[current] == nil || current == startIndex || len(paths[current]) >= maxHops {
+				continue
+			}
+			currentTokenID := g.rawGraph.Tokens[current]
+
+			for _, edgeIndex := range g.rawGraph.Adjacency[current] {
+				source := g.rawGraph.EdgeTargets[edgeIndex]
+				if source == current || known[current].IsSet(uint64(source)) {
+					continue
+				}
+				sourceTokenID := g.rawGraph.Tokens[source]
 
-		// handle target == start separately
-		if targetIndex == state.start {
-			// this allows us to still collect unprofitable cycles (to be optimized up stream)
-			if maxAmountOut.Cmp(state.bestCycleCost) == 1 {
-				poolID := g.rawGraph.Pools[bestPoolIndex]
-				newPath := make([]chains.TokenPoolPath, len(currentPath)+1)
-				copy(newPath, currentPath)
-				newPath[len(currentPath)] = chains.TokenPoolPath{
-					TokenInID:  currentTokenID,
-					TokenOutID: targetTokenID,
-					PoolID:     poolID,
+				bestPoolIndex := -1
+				var minAmountIn *big.Int
+				for _, poolIndex := range g.rawGraph.EdgePools[edgeIndex] {
+					amountIn, err := g.quoteExactOut(poolIndex, costs[current], sourceTokenID, currentTokenID)
+					if err != nil {
+						continue
+					}
+					if minAmountIn == nil || amountIn.Cmp(minAmountIn) < 0 {
+						bestPoolIndex, minAmountIn = poolIndex, amountIn
+					}
 				}
-				state.paths[targetIndex] = newPath
-				state.known[targetIndex].SetFrom(currentKnown)
-				state.known[targetIndex].Set(uint64(currentIndex))
-				// set best cycle cost
-				state.bestCycleCost.Set(maxAmountOut)
-			}
-		} else if maxAmountOut.Cmp(state.costs[targetIndex]) == 1 {
-			poolID := g.rawGraph.Pools[bestPoolIndex]
-			newPath := make([]chains.TokenPoolPath, len(currentPath)+1)
-			copy(newPath, currentPath)
-			newPath[len(currentPath)] = chains.TokenPoolPath{
-				TokenInID:  currentTokenID,
-				TokenOutID: targetTokenID,
-				PoolID:     poolID,
+
+				if bestPoolIndex == -1 || (costs[source] != nil && minAmountIn.Cmp(costs[source]) >= 0) {
+					continue
+				}
+				costs[source] = minAmountIn
+				newPath := make([]chains.TokenPoolPath, 0, len(paths[current])+1)
+				newPath = append(newPath, chains.TokenPoolPath{
+					TokenInID:  sourceTokenID,
+					TokenOutID: currentTokenID,
+					PoolID:     g.rawGraph.Pools[bestPoolIndex],
+				})
+				paths[source] = append(newPath, paths[current]...)
+				known[source].SetFrom(known[current])
+				known[source].Set(uint64(current))
 			}
-			state.paths[targetIndex] = newPath
-			state.known[targetIndex].SetFrom(currentKnown)
-			state.known[targetIndex].Set(uint64(currentIndex))
-			state.costs[targetIndex].Set(maxAmountOut)
 		}
 	}
 
-	return nil
-}
-
-// findSwapPathsState encapsulates the state required for the Bellman-Ford-like
-// swap path finding algorithm.
-type findSwapPathsState struct {
-	start   int
-	current int
-	end     int
-	paths   [][]chains.TokenPoolPath // vertex index -> path
-	costs   []*big.Int               // vertex index -> cost
-	known   []bitset.BitSet          // vertex index -> vertex index
-	temp    *big.Int
+	if paths[startIndex] == nil {
+		return nil, nil, fmt.Errorf("%w: %s of token %d from token %d", chains.ErrNoRoute, amountOut, tokenOutID, tokenInID)
+	}
+	return paths[startIndex], new(big.Int).Set(costs[startIndex]), nil
 }
 
-// FindBestSwapPath searches the graph for the most profitable swap path between two tokens.
-// It uses a "copy-and-patch" strategy to handle state overrides.
-func (g *Graph) FindBestSwapPath(params chains.SwapFindingParams) ([]chains.TokenPoolPath, *big.Int, error) {
+// quoteExactOut returns the input that buys amountOut from the active pool at poolIndex.
+// The quote is checked with the pool's exact-input function, so a pool that cannot fill
+// amountOut, e.g. a Uniswap V3 pool running out of initialized ticks, is rejected.
+func (g *Graph) quoteExactOut(poolIndex int, amountOut *big.Int, tokenInID, tokenOutID uint64) (*big.Int, error) {
+	getAmountOut := g.activeGetAmountOutFuncs[poolIndex]
+	if getAmountOut == nil {
+		return nil, errors.New("pool is not active")
+	}
+	poolID := g.rawGraph.Pools[poolIndex]
+	schema, ok := g.protocolResolver.ResolveSchemaFromPoolID(poolID)
+	if !ok {
+		return nil, fmt.Errorf("pool %d has an unknown schema", poolID)
+	}
 
-	// --- Step 1: Create a temporary, patched slice of swap functions ---
-	getAmountOutFuncs := make([]GetAmountOutFunc, len(g.activeGetAmountOutFuncs))
-	copy(getAmountOutFuncs, g.activeGetAmountOutFuncs)
+	var (
+		amountIn *big.Int
+		err      error
+	)
+	switch schema {
+	case uniswapv2.Schema:
+		pool, _ := g.indexedUniswapV2.GetByID(poolID)
+		amountIn, err = uniswapv2calculator.GetAmountIn(amountOut, tokenInID, tokenOutID, pool)
+	case uniswapv3.Schema, uniswapv4.Schema:
+		pool, _ := g.indexedUniswapV3.GetByID(poolID)
+		// The V3 calculator takes exact-output amounts as negative values.
+		amountIn, err = uniswapv3calculator.GetAmountIn(new(big.Int).Neg(amountOut), nil, tokenInID, pool)
+	default:
+		return nil, fmt.Errorf("pool %d has no exact-output quote for schema %s", poolID, schema)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if amountIn == nil || amountIn.Sign() <= 0 {
+		return nil, fmt.Errorf("pool %d quoted no input for %s of token %d", poolID, amountOut, tokenOutID)
+	}
 
-	// Patch with V2 overrides.
-	for poolID, overriddenPool := range params.UniswapV2Overrides {
+	filled, err := getAmountOut(amountIn, tokenInID, tokenOutID)
+	if err != nil {
+		return nil, err
+	}
+	if filled.Cmp(amountOut) < 0 {
+		return nil, fmt.Errorf("pool %d cannot fill %s of token %d", poolID, amountOut, tokenOutID)
+	}
+	return amountIn, nil
+}
 
-		poolIndex, exists := g.poolToIndex[poolID]
-		if !exists {
-			continue
-		}
-		if getAmountOutFuncs[poolIndex] == nil {
-			// pool is inactive skip!
+// excludeStalePools removes from funcs, in place, every pool last updated more than
+// params.MaxPoolStaleBlocks before params.SnapshotBlock. An override's last-update block
+// takes precedence over the indexed one.
+func (g *Graph) excludeStalePools(funcs []GetAmountOutFunc, params chains.SwapFindingParams) {
+	for i, poolID := range g.rawGraph.Pools {
+		if funcs[i] == nil {
 			continue
 		}
-		getAmountOutFuncs[poolIndex] = func(amountIn *big.Int, tokenInID, tokenOutID uint64) (*big.Int, error) {
-			return uniswapv2calculator.GetAmountOut(amountIn, tokenInID, tokenOutID, overriddenPool)
+		lastUpdated, ok := g.poolLastUpdated(poolID, params.UniswapV2Overrides, params.UniswapV3Overrides)
+		if ok && lastUpdated < params.SnapshotBlock && params.SnapshotBlock-lastUpdated > params.MaxPoolStaleBlocks {
+			funcs[i] = nil
 		}
 	}
+}
 
-	// Patch with UniswapV3 overrides.
-	for poolID, overriddenPool := range params.UniswapV3Overrides {
-
-		poolIndex, exists := g.poolToIndex[poolID]
-		if !exists {
-			continue
+// equivalenceHops indexes the configured equivalences by the vertex index they leave
+// from, adding the inverse conversion for every pair whose reverse is not configured.
+func (g *Graph) equivalenceHops(equivalences map[chains.TokenPair]*big.Rat) (map[int][]equivalenceHop, error) {
+	if len(equivalences) == 0 {
+		return nil, nil
+	}
+	hops := make(map[int][]equivalenceHop, 2*len(equivalences))
+	add := func(from, to uint64, ratio *big.Rat) {
+		hops[g.tokenToIndex[from]] = append(hops[g.tokenToIndex[from]], equivalenceHop{target: g.tokenToIndex[to], ratio: ratio})
+	}
+	for pair, ratio := range equivalences {
+		if ratio == nil || ratio.Sign() <= 0 {
+			return nil, fmt.Errorf("equivalence %d->%d: ratio must be positive", pair.From, pair.To)
 		}
-		if getAmountOutFuncs[poolIndex] == nil {
-			// pool is inactive skip!
-			continue
+		if pair.From == pair.To {
+			return nil, fmt.Errorf("equivalence %d->%d: tokens must differ", pair.From, pair.To)
 		}
-		getAmountOutFuncs[poolIndex] = func(amountIn *big.Int, tokenInID, tokenOutID uint64) (*big.Int, error) {
-			return uniswapv3calculator.GetAmountOut(amountIn, nil, tokenInID, overriddenPool)
+		for _, tokenID := range []uint64{pair.From, pair.To} {
+			if _, ok := g.tokenToIndex[tokenID]; !ok {
+				return nil, fmt.Errorf("equivalence %d->%d: token %d not found in the graph", pair.From, pair.To, tokenID)
+			}
+		}
+		add(pair.From, pair.To, ratio)
+		if _, ok := equivalences[chains.TokenPair{From: pair.To, To: pair.From}]; !ok {
+			add(pair.To, pair.From, new(big.Rat).Inv(ratio))
+		}
+	}
+	return hops, nil
+}
+
+// poolLastUpdated returns the last-update block of a pool, read from the overrides or the
+// indexed state. ok is false if the pool does not carry one.
+func (g *Graph) poolLastUpdated(
+	poolID uint64,
+	uniswapV2Overrides map[uint64]uniswapv2.Pool,
+	uniswapV3Overrides map[uint64]uniswapv3.Pool,
+) (uint64, bool) {
+	schema, _ := g.protocolResolver.ResolveSchemaFromPoolID(poolID)
+	switch schema {
+	case uniswapv2.Schema:
+		pool, ok := uniswapV2Overrides[poolID]
+		if !ok {
+			pool, _ = g.indexedUniswapV2.GetByID(poolID)
+		}
+		return pool.LastUpdated()
+	case uniswapv3.Schema, uniswapv4.Schema:
+		pool, ok := uniswapV3Overrides[poolID]
+		if !ok {
+			pool, _ = g.indexedUniswapV3.GetByID(poolID)
 		}
+		return pool.LastUpdated()
+	case solidly.Schema:
+		pool, _ := g.solidlyPool(poolID)
+		return pool.LastUpdated()
+	default:
+		return 0, false
 	}
+}
 
-	// --- Step 2: Initialize and run the pathfinding algorithm ---
+// findBestSwapPath runs the pathfinding algorithm with the given swap functions.
+// It stops early when ctx is done, returning the best route found so far with ErrRouteTimeout.
+func (g *Graph) findBestSwapPath(ctx context.Context, params chains.SwapFindingParams, getAmountOutFuncs []GetAmountOutFunc) ([]chains.TokenPoolPath, *big.Int, error) {
+	// --- Step 1: Initialize and run the pathfinding algorithm ---
 	startIndex, exists := g.tokenToIndex[params.TokenInID]
 	if !exists {
 		return nil, nil, fmt.Errorf("start token %d not found in the graph", params.TokenInID)
@@ -775,14 +3724,41 @@ func (g *Graph) FindBestSwapPath(params chains.SwapFindingParams) ([]chains.Toke
 		return nil, nil, fmt.Errorf("end token %d not found in the graph", params.TokenOutID)
 	}
 
+	equivalences, err := g.equivalenceHops(params.Equivalences)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	numTokens := len(g.rawGraph.Tokens)
 	state := &findSwapPathsState{
-		start: startIndex,
-		end:   endIndex,
-		paths: make([][]chains.TokenPoolPath, numTokens),
-		costs: make([]*big.Int, numTokens),
-		known: make([]bitset.BitSet, numTokens),
-		temp:  bigIntPool.Get().(*big.Int).SetUint64(0),
+		start:    startIndex,
+		end:      endIndex,
+		paths:    make([][]chains.TokenPoolPath, numTokens),
+		costs:    make([]*big.Int, numTokens),
+		known:    make([]bitset.BitSet, numTokens),
+		temp:     bigIntPool.Get().(*big.Int).SetUint64(0),
+		selector: params.PoolSelector,
+
+		allowZeroOutput: params.AllowZeroOutput,
+		reached:         bitset.NewBitSet(uint64(numTokens)),
+		equivalences:    equivalences,
+		edgeWeight:      params.EdgeWeight,
+	}
+	if state.edgeWeight != nil {
+		state.weights = make([]float64, numTokens)
+		for i := range state.weights {
+			state.weights[i] = 1
+		}
+	}
+	if params.GasModel != nil {
+		state.gas, err = g.newGasPricer(params.GasModel, params.NativeTokenID, params.Runs)
+		if err != nil {
+			return nil, nil, err
+		}
+		if state.gas.rates[endIndex] == nil {
+			return nil, nil, fmt.Errorf("cannot price gas in token %d: no conversion from native token %d", params.TokenOutID, params.NativeTokenID)
+		}
+		state.gasUsed = make([]uint64, numTokens)
 	}
 
 	// This defer block is CRITICAL. It ensures all rented objects are returned.
@@ -805,11 +3781,18 @@ func (g *Graph) FindBestSwapPath(params chains.SwapFindingParams) ([]chains.Toke
 	}
 
 	state.costs[startIndex].Set(params.AmountIn)
+	state.reached.Set(uint64(startIndex))
 	runs := params.Runs
 
+	var timedOut bool
+search:
 	for i := 0; i < runs; i++ {
 		for j := 0; j < numTokens; j++ {
-			if state.costs[j].Sign() == 0 {
+			if ctx.Err() != nil {
+				timedOut = true
+				break search
+			}
+			if state.costs[j].Sign() == 0 && !(state.allowZeroOutput && state.reached.IsSet(uint64(j))) {
 				continue
 			}
 			state.current = j
@@ -819,21 +3802,138 @@ func (g *Graph) FindBestSwapPath(params chains.SwapFindingParams) ([]chains.Toke
 		}
 	}
 
-	// --- Step 3: Reconstruct and return the best path found ---
+	// --- Step 2: Reconstruct and return the best path found ---
 	bestPath := state.paths[endIndex]
+	if timedOut {
+		err := fmt.Errorf("%w: token %d to token %d", chains.ErrRouteTimeout, params.TokenInID, params.TokenOutID)
+		if bestPath == nil || (state.costs[endIndex].Sign() == 0 && !state.allowZeroOutput) {
+			return nil, nil, err
+		}
+		return bestPath, new(big.Int).Set(state.costs[endIndex]), err
+	}
 	if bestPath == nil {
-		return nil, nil, nil // No path found between the two tokens.
+		return nil, nil, fmt.Errorf("%w: token %d to token %d", chains.ErrNoRoute, params.TokenInID, params.TokenOutID)
+	}
+	if state.costs[endIndex].Sign() == 0 && !state.allowZeroOutput {
+		return nil, nil, fmt.Errorf("%w: output from token %d to token %d rounds to zero", chains.ErrNoRoute, params.TokenInID, params.TokenOutID)
 	}
 
 	return bestPath, new(big.Int).Set(state.costs[endIndex]), nil
 }
 
+// findBestSwapPathFloat picks a route with the same relaxation as findBestSwapPath, but on
+// float64 quotes, then re-quotes it hop by hop with the exact getAmountOutFuncs. A pool is
+// only used if it has both a float and an exact function. If the exact re-quote fails, it
+// falls back to the exact search.
+func (g *Graph) findBestSwapPathFloat(
+	ctx context.Context,
+	params chains.SwapFindingParams,
+	getAmountOutFuncs []GetAmountOutFunc,
+	cachedFuncs []GetAmountOutFromCacheFunc,
+) ([]chains.TokenPoolPath, *big.Int, error) {
+	startIndex, exists := g.tokenToIndex[params.TokenInID]
+	if !exists {
+		return nil, nil, fmt.Errorf("start token %d not found in the graph", params.TokenInID)
+	}
+	endIndex, exists := g.tokenToIndex[params.TokenOutID]
+	if !exists {
+		return nil, nil, fmt.Errorf("end token %d not found in the graph", params.TokenOutID)
+	}
+
+	numTokens := len(g.rawGraph.Tokens)
+	costs := make([]float64, numTokens)
+	paths := make([][]chains.TokenPoolPath, numTokens)
+	known := make([]bitset.BitSet, numTokens)
+	for i := range known {
+		known[i] = bitset.NewBitSet(uint64(numTokens))
+	}
+	costs[startIndex] = bigToFloat64(params.AmountIn)
+
+	var timedOut bool
+search:
+	for run := 0; run < params.Runs; run++ {
+		for current := 0; current < numTokens; current++ {
+			if ctx.Err() != nil {
+				timedOut = true
+				break search
+			}
+			currentCost := costs[current]
+			if currentCost <= 0 {
+				continue
+			}
+			currentTokenID := g.rawGraph.Tokens[current]
+
+			for _, edgeIndex := range g.rawGraph.Adjacency[current] {
+				target := g.rawGraph.EdgeTargets[edgeIndex]
+				if known[current].IsSet(uint64(target)) || target == current {
+					continue
+				}
+				targetTokenID := g.rawGraph.Tokens[target]
+
+				bestPoolIndex, maxAmountOut := -1, 0.0
+				for _, poolIndex := range g.rawGraph.EdgePools[edgeIndex] {
+					if cachedFuncs[poolIndex] == nil || getAmountOutFuncs[poolIndex] == nil {
+						continue
+					}
+					amountOut, err := cachedFuncs[poolIndex](currentCost, currentTokenID, targetTokenID)
+					if err != nil {
+						continue
+					}
+					if amountOut > maxAmountOut {
+						bestPoolIndex, maxAmountOut = poolIndex, amountOut
+					}
+				}
+
+				if bestPoolIndex == -1 || maxAmountOut <= costs[target] {
+					continue
+				}
+				costs[target] = maxAmountOut
+				newPath := make([]chains.TokenPoolPath, len(paths[current])+1)
+				copy(newPath, paths[current])
+				newPath[len(paths[current])] = chains.TokenPoolPath{
+					TokenInID:  currentTokenID,
+					TokenOutID: targetTokenID,
+					PoolID:     g.rawGraph.Pools[bestPoolIndex],
+				}
+				paths[target] = newPath
+				known[target].SetFrom(known[current])
+				known[target].Set(uint64(current))
+			}
+		}
+	}
+
+	bestPath := paths[endIndex]
+	if bestPath == nil {
+		if timedOut {
+			return nil, nil, fmt.Errorf("%w: token %d to token %d", chains.ErrRouteTimeout, params.TokenInID, params.TokenOutID)
+		}
+		return nil, nil, fmt.Errorf("%w: token %d to token %d", chains.ErrNoRoute, params.TokenInID, params.TokenOutID)
+	}
+
+	// Re-quote the chosen route exactly.
+	amount := params.AmountIn
+	for _, hop := range bestPath {
+		out, err := getAmountOutFuncs[g.poolToIndex[hop.PoolID]](amount, hop.TokenInID, hop.TokenOutID)
+		if err != nil {
+			return g.findBestSwapPath(ctx, params, getAmountOutFuncs)
+		}
+		amount = out
+	}
+
+	if timedOut {
+		return bestPath, amount, fmt.Errorf("%w: token %d to token %d", chains.ErrRouteTimeout, params.TokenInID, params.TokenOutID)
+	}
+	if amount.Sign() == 0 && !params.AllowZeroOutput {
+		return nil, nil, fmt.Errorf("%w: output from token %d to token %d rounds to zero", chains.ErrNoRoute, params.TokenInID, params.TokenOutID)
+	}
+	return bestPath, amount, nil
+}
+
 // findSwapPath is the core Bellman-Ford-like relaxation step for finding the best swap paths.
 func (g *Graph) findSwapPath(state *findSwapPathsState, getAmountOutFuncs []GetAmountOutFunc) error {
 	currentIndex := state.current
 	currentCost := state.costs[currentIndex]
 	currentKnown := state.known[currentIndex]
-	currentPath := state.paths[currentIndex]
 	currentTokenID := g.rawGraph.Tokens[currentIndex]
 
 	if currentKnown.IsSet(uint64(currentIndex)) {
@@ -850,17 +3950,48 @@ func (g *Graph) findSwapPath(state *findSwapPathsState, getAmountOutFuncs []GetA
 
 		targetTokenID := g.rawGraph.Tokens[targetIndex]
 		bestPoolIndex := -1
+		bestWeight := 1.0
+		var bestGas uint64
 		maxAmountOut.SetUint64(0)
-		for _, poolIndex := range g.rawGraph.EdgePools[edgeIndex] {
-			getAmountOut := getAmountOutFuncs[poolIndex]
-			if getAmountOut == nil {
-				continue
+		if state.selector != nil {
+			bestPoolIndex = g.selectPool(state, getAmountOutFuncs, edgeIndex, currentTokenID, targetTokenID)
+			if bestPoolIndex != -1 {
+				maxAmountOut.Set(state.candidates[bestPoolIndex].AmountOut)
+				bestWeight, _ = state.poolWeight(state.candidates[bestPoolIndex].PoolID)
+				bestPoolIndex = g.poolToIndex[state.candidates[bestPoolIndex].PoolID]
+				if state.gas != nil {
+					var ok bool
+					if bestGas, ok = state.gas.hopGas(g, bestPoolIndex); !ok {
+						continue
+					}
+				}
 			}
+		} else {
+			for _, poolIndex := range g.rawGraph.EdgePools[edgeIndex] {
+				getAmountOut := getAmountOutFuncs[poolIndex]
+				if getAmountOut == nil {
+					continue
+				}
+				weight, ok := state.poolWeight(g.rawGraph.Pools[poolIndex])
+				if !ok {
+					continue
+				}
+				var hopGas uint64
+				if state.gas != nil {
+					if hopGas, ok = state.gas.hopGas(g, poolIndex); !ok {
+						continue
+					}
+				}
 
-			amountOut, err := getAmountOut(currentCost, currentTokenID, targetTokenID)
-			if err == nil && amountOut.Cmp(maxAmountOut) == 1 {
-				maxAmountOut.Set(amountOut)
-				bestPoolIndex = poolIndex
+				amountOut, err := getAmountOut(currentCost, currentTokenID, targetTokenID)
+				if err != nil {
+					continue
+				}
+				if (bestPoolIndex == -1 && (amountOut.Sign() == 1 || state.allowZeroOutput && amountOut.Sign() == 0)) ||
+					(bestPoolIndex != -1 && state.cmpHops(targetIndex, amountOut, weight, hopGas, maxAmountOut, bestWeight, bestGas) == 1) {
+					maxAmountOut.Set(amountOut)
+					bestPoolIndex, bestWeight, bestGas = poolIndex, weight, hopGas
+				}
 			}
 		}
 
@@ -868,24 +3999,239 @@ func (g *Graph) findSwapPath(state *findSwapPathsState, getAmountOutFuncs []GetA
 			continue
 
 		}
-		if maxAmountOut.Cmp(state.costs[targetIndex]) == 1 {
-			state.costs[targetIndex].Set(maxAmountOut)
-			poolID := g.rawGraph.Pools[bestPoolIndex]
-			newPath := make([]chains.TokenPoolPath, len(currentPath)+1)
-			copy(newPath, currentPath)
-			newPath[len(currentPath)] = chains.TokenPoolPath{
-				TokenInID:  currentTokenID,
-				TokenOutID: targetTokenID,
-				PoolID:     poolID,
-			}
-			state.paths[targetIndex] = newPath
-			state.known[targetIndex].SetFrom(currentKnown)
-			state.known[targetIndex].Set(uint64(currentIndex))
+		g.relaxSwapTarget(state, targetIndex, maxAmountOut, g.rawGraph.Pools[bestPoolIndex], bestWeight, bestGas)
+	}
+
+	// Fixed-ratio conversions to equivalent tokens are zero-slippage hops.
+	for _, hop := range state.equivalences[currentIndex] {
+		if currentKnown.IsSet(uint64(hop.target)) {
+			continue
 		}
+		amountOut := maxAmountOut.Mul(currentCost, hop.ratio.Num())
+		amountOut.Quo(amountOut, hop.ratio.Denom())
+		g.relaxSwapTarget(state, hop.target, amountOut, chains.EquivalencePoolID, 1, 0)
 	}
 	return nil
 }
 
+// cmpHops compares two hops into targetIndex by amount out, after weighting it or, with
+// a gas model, after subtracting the cost of each hop's gas in the target token.
+func (s *findSwapPathsState) cmpHops(targetIndex int, a *big.Int, weightA float64, gasA uint64, b *big.Int, weightB float64, gasB uint64) int {
+	if s.gas == nil {
+		return weightedCmp(a, weightA, b, weightB)
+	}
+	return s.gas.net(targetIndex, a, gasA).Cmp(s.gas.net(targetIndex, b, gasB))
+}
+
+// relaxSwapTarget records the hop from state.current to targetIndex through poolID if
+// amountOut improves on the best amount known for targetIndex. With edge weights, amounts
+// are compared after multiplying by the weights of their paths, poolWeight included; with
+// a gas model, after subtracting the cost of their paths' gas, hopGas included.
+func (g *Graph) relaxSwapTarget(state *findSwapPathsState, targetIndex int, amountOut *big.Int, poolID uint64, poolWeight float64, hopGas uint64) {
+	currentIndex := state.current
+	pathWeight, targetWeight := 1.0, 1.0
+	if state.weights != nil {
+		pathWeight, targetWeight = state.weights[currentIndex]*poolWeight, state.weights[targetIndex]
+	}
+	var pathGas, targetGas uint64
+	if state.gas != nil {
+		pathGas, targetGas = state.gasUsed[currentIndex]+hopGas, state.gasUsed[targetIndex]
+	}
+	unreached := !state.reached.IsSet(uint64(targetIndex))
+	if state.cmpHops(targetIndex, amountOut, pathWeight, pathGas, state.costs[targetIndex], targetWeight, targetGas) != 1 &&
+		!(unreached && (state.allowZeroOutput || state.gas != nil && amountOut.Sign() == 1)) {
+		return
+	}
+	currentPath := state.paths[currentIndex]
+	state.reached.Set(uint64(targetIndex))
+	state.costs[targetIndex].Set(amountOut)
+	if state.weights != nil {
+		state.weights[targetIndex] = pathWeight
+	}
+	if state.gas != nil {
+		state.gasUsed[targetIndex] = pathGas
+	}
+	newPath := make([]chains.TokenPoolPath, len(currentPath)+1)
+	copy(newPath, currentPath)
+	newPath[len(currentPath)] = chains.TokenPoolPath{
+		TokenInID:  g.rawGraph.Tokens[currentIndex],
+		TokenOutID: g.rawGraph.Tokens[targetIndex],
+		PoolID:     poolID,
+	}
+	state.paths[targetIndex] = newPath
+	state.known[targetIndex].SetFrom(state.known[currentIndex])
+	state.known[targetIndex].Set(uint64(currentIndex))
+}
+
+// ValidateRoute re-quotes a route hop by hop directly from the indexed pool state and
+// checks it against the functions used for routing. It catches broken paths, pools that
+// are no longer routable and calculator discrepancies before a route is executed.
+func (g *Graph) ValidateRoute(params chains.RouteValidationParams) error {
+	getAmountOutFuncs := g.applyOverrides(g.activeGetAmountOutFuncs, params.UniswapV2Overrides, params.UniswapV3Overrides)
+	_, err := g.validateRoute(params.Path, params.AmountIn, params.ExpectedOut, getAmountOutFuncs, params.UniswapV2Overrides, params.UniswapV3Overrides)
+	return err
+}
+
+// validateRoute implements ValidateRoute. On failure it also returns the ID of the pool
+// at fault, so callers can exclude it.
+func (g *Graph) validateRoute(
+	path []chains.TokenPoolPath,
+	amountIn *big.Int,
+	expectedOut *big.Int,
+	getAmountOutFuncs []GetAmountOutFunc,
+	uniswapV2Overrides map[uint64]uniswapv2.Pool,
+	uniswapV3Overrides map[uint64]uniswapv3.Pool,
+) (uint64, error) {
+	if len(path) == 0 {
+		return 0, fmt.Errorf("%w: route is empty", chains.ErrRouteValidation)
+	}
+	if amountIn == nil || amountIn.Sign() <= 0 {
+		return 0, fmt.Errorf("%w: amount in must be positive", chains.ErrRouteValidation)
+	}
+
+	amount := amountIn
+	for i, hop := range path {
+		if i > 0 && hop.TokenInID != path[i-1].TokenOutID {
+			return hop.PoolID, fmt.Errorf("%w: hop %d starts at token %d but hop %d ends at token %d",
+				chains.ErrRouteValidation, i, hop.TokenInID, i-1, path[i-1].TokenOutID)
+		}
+
+		poolIndex, ok := g.poolToIndex[hop.PoolID]
+		if !ok || getAmountOutFuncs[poolIndex] == nil {
+			return hop.PoolID, fmt.Errorf("%w: pool %d is not routable", chains.ErrRouteValidation, hop.PoolID)
+		}
+
+		quoted, err := g.quoteFromPoolState(hop, amount, uniswapV2Overrides, uniswapV3Overrides)
+		if err != nil {
+			return hop.PoolID, fmt.Errorf("%w: hop %d: %v", chains.ErrRouteValidation, i, err)
+		}
+		routed, err := getAmountOutFuncs[poolIndex](amount, hop.TokenInID, hop.TokenOutID)
+		if err != nil {
+			return hop.PoolID, fmt.Errorf("%w: hop %d: %v", chains.ErrRouteValidation, i, err)
+		}
+		if quoted.Cmp(routed) != 0 {
+			return hop.PoolID, fmt.Errorf("%w: pool %d quotes %s but the pool state gives %s",
+				chains.ErrRouteValidation, hop.PoolID, routed, quoted)
+		}
+		amount = quoted
+	}
+
+	if expectedOut != nil && amount.Cmp(expectedOut) != 0 {
+		last := path[len(path)-1]
+		return last.PoolID, fmt.Errorf("%w: route was quoted at %s but produces %s",
+			chains.ErrRouteValidation, expectedOut, amount)
+	}
+	return 0, nil
+}
+
+// quoteFromPoolState quotes a single hop with the protocol calculator, reading the pool
+// from the overrides or the indexed state rather than from the pre-built swap functions.
+func (g *Graph) quoteFromPoolState(
+	hop chains.TokenPoolPath,
+	amountIn *big.Int,
+	uniswapV2Overrides map[uint64]uniswapv2.Pool,
+	uniswapV3Overrides map[uint64]uniswapv3.Pool,
+) (*big.Int, error) {
+	schema, ok := g.protocolResolver.ResolveSchemaFromPoolID(hop.PoolID)
+	if !ok {
+		return nil, fmt.Errorf("pool %d has an unknown schema", hop.PoolID)
+	}
+
+	switch schema {
+	case uniswapv2.Schema:
+		pool, ok := uniswapV2Overrides[hop.PoolID]
+		if !ok {
+			pool, ok = g.indexedUniswapV2.GetByID(hop.PoolID)
+		}
+		if !ok {
+			return nil, fmt.Errorf("pool %d not found", hop.PoolID)
+		}
+		if !poolHoldsPair(pool.Token0, pool.Token1, hop) {
+			return nil, fmt.Errorf("pool %d does not trade token %d for token %d", hop.PoolID, hop.TokenInID, hop.TokenOutID)
+		}
+		return uniswapv2calculator.GetAmountOut(amountIn, hop.TokenInID, hop.TokenOutID, pool)
+	case uniswapv3.Schema, uniswapv4.Schema:
+		pool, ok := uniswapV3Overrides[hop.PoolID]
+		if !ok {
+			pool, ok = g.indexedUniswapV3.GetByID(hop.PoolID)
+		}
+		if !ok {
+			return nil, fmt.Errorf("pool %d not found", hop.PoolID)
+		}
+		if !poolHoldsPair(pool.Token0, pool.Token1, hop) {
+			return nil, fmt.Errorf("pool %d does not trade token %d for token %d", hop.PoolID, hop.TokenInID, hop.TokenOutID)
+		}
+		return uniswapv3calculator.GetAmountOut(amountIn, nil, hop.TokenInID, pool)
+	case solidly.Schema:
+		pool, ok := g.solidlyPool(hop.PoolID)
+		if !ok {
+			return nil, fmt.Errorf("pool %d not found", hop.PoolID)
+		}
+		if !poolHoldsPair(pool.Token0, pool.Token1, hop) {
+			return nil, fmt.Errorf("pool %d does not trade token %d for token %d", hop.PoolID, hop.TokenInID, hop.TokenOutID)
+		}
+		return solidlycalculator.GetAmountOut(amountIn, hop.TokenInID, hop.TokenOutID, pool)
+	default:
+		return nil, fmt.Errorf("pool %d has unsupported schema %s", hop.PoolID, schema)
+	}
+}
+
+// poolHoldsPair reports whether a pool with the given tokens trades the hop's pair.
+func poolHoldsPair(token0, token1 uint64, hop chains.TokenPoolPath) bool {
+	return (hop.TokenInID == token0 && hop.TokenOutID == token1) || (hop.TokenInID == token1 && hop.TokenOutID == token0)
+}
+
+// selectPool quotes every usable pool on an edge and lets the configured PoolSelector
+// choose between them. It returns the index into state.candidates of the chosen pool, or -1.
+func (g *Graph) selectPool(
+	state *findSwapPathsState,
+	getAmountOutFuncs []GetAmountOutFunc,
+	edgeIndex int,
+	tokenInID, tokenOutID uint64,
+) int {
+	currentCost := state.costs[state.current]
+	state.candidates = state.candidates[:0]
+	for _, poolIndex := range g.rawGraph.EdgePools[edgeIndex] {
+		getAmountOut := getAmountOutFuncs[poolIndex]
+		if getAmountOut == nil {
+			continue
+		}
+		if _, ok := state.poolWeight(g.rawGraph.Pools[poolIndex]); !ok {
+			continue
+		}
+
+		amountOut, err := getAmountOut(currentCost, tokenInID, tokenOutID)
+		if err != nil || amountOut == nil || amountOut.Sign() < 0 || (amountOut.Sign() == 0 && !state.allowZeroOutput) {
+			continue
+		}
+
+		poolID := g.rawGraph.Pools[poolIndex]
+		schema, _ := g.protocolResolver.ResolveSchemaFromPoolID(poolID)
+		candidate := chains.PoolCandidate{
+			PoolID:    poolID,
+			Schema:    schema,
+			AmountOut: amountOut,
+			FeePips:   g.poolFees[poolIndex],
+		}
+		if getReserves := g.getReservesFuncs[poolIndex]; getReserves != nil {
+			candidate.Reserves = func() (*big.Int, *big.Int, error) {
+				return getReserves(tokenInID, tokenOutID)
+			}
+		}
+		state.candidates = append(state.candidates, candidate)
+	}
+
+	if len(state.candidates) == 0 {
+		return -1
+	}
+
+	selected := state.selector.SelectPool(tokenInID, tokenOutID, state.candidates)
+	if selected < 0 || selected >= len(state.candidates) {
+		return -1
+	}
+	return selected
+}
+
 // equalTokenPoolPaths compares two paths to see if they are identical.
 func equalTokenPoolPaths(a, b []chains.TokenPoolPath) bool {
 	if len(a) != len(b) {
@@ -0,0 +1,46 @@
+package chains
+
+import (
+	"math/big"
+
+	"github.com/defistate/defistate-client-go/engine"
+)
+
+// BlockGasModel is a GasModel with a fixed gas estimate per protocol schema, priced at
+// a block's base fee plus a priority fee.
+type BlockGasModel struct {
+	// Estimates provides the per-hop gas for each schema. Pools whose schema has no
+	// estimate cannot be estimated.
+	Estimates GasEstimates
+	// BaseFee and PriorityFee are in wei per gas. Nil counts as zero.
+	BaseFee     *big.Int
+	PriorityFee *big.Int
+}
+
+// NewBlockGasModel returns a BlockGasModel priced at block's base fee, e.g. State.Block,
+// plus priorityFee.
+func NewBlockGasModel(block engine.BlockSummary, estimates GasEstimates, priorityFee *big.Int) *BlockGasModel {
+	return &BlockGasModel{
+		Estimates:   estimates,
+		BaseFee:     block.BaseFee,
+		PriorityFee: priorityFee,
+	}
+}
+
+// HopGas returns the estimate for schema, whatever the pool.
+func (m *BlockGasModel) HopGas(schema engine.ProtocolSchema, _ uint64) (uint64, bool) {
+	gas, ok := m.Estimates[schema]
+	return gas, ok
+}
+
+// GasPrice returns BaseFee plus PriorityFee.
+func (m *BlockGasModel) GasPrice() *big.Int {
+	price := new(big.Int)
+	if m.BaseFee != nil {
+		price.Add(price, m.BaseFee)
+	}
+	if m.PriorityFee != nil {
+		price.Add(price, m.PriorityFee)
+	}
+	return price
+}
@@ -0,0 +1,87 @@
+package chains
+
+import (
+	"slices"
+
+	"github.com/defistate/defistate-client-go/differ"
+	poolregistry "github.com/defistate/defistate-client-go/protocols/poolregistry"
+	solidly "github.com/defistate/defistate-client-go/protocols/solidly"
+	tokenpoolregistry "github.com/defistate/defistate-client-go/protocols/tokenpoolregistry"
+	tokenregistry "github.com/defistate/defistate-client-go/protocols/tokenregistry"
+	uniswapv2 "github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	uniswapv3 "github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	uniswapv4 "github.com/defistate/defistate-client-go/protocols/uniswapv4"
+)
+
+// ChangedPools returns the ids of the pools a decoded state diff adds or updates, sorted
+// and without duplicates, ready for GraphDiff.ChangedPools. With it, TokenPoolGraph.ApplyDiff
+// rewires only those pools for the patched state instead of rebuilding the whole graph.
+//
+// Deleted pools need no entry: ApplyDiff drops every pool missing from the patched
+// token-pool view. Token registry and token-pool diffs carry no pool changes of their own.
+// ok is false if a protocol diff reports an error or carries data of an unknown type; its
+// changes are then unknown and the graph should be rebuilt from the patched state.
+func ChangedPools(diff *differ.StateDiff) (poolIDs []uint64, ok bool) {
+	if diff == nil {
+		return nil, true
+	}
+
+	for _, protocolDiff := range diff.Protocols {
+		if protocolDiff.Error != "" {
+			return nil, false
+		}
+		switch data := protocolDiff.Data.(type) {
+		case nil:
+		case uniswapv2.UniswapV2SystemDiff:
+			for _, pool := range slices.Concat(data.Additions, data.Updates) {
+				poolIDs = append(poolIDs, pool.ID)
+			}
+		case uniswapv3.UniswapV3SystemDiff:
+			for _, pool := range slices.Concat(data.Additions, data.Updates) {
+				poolIDs = append(poolIDs, pool.ID)
+			}
+		case uniswapv4.UniswapV4SystemDiff:
+			for _, pool := range slices.Concat(data.Additions, data.Updates) {
+				poolIDs = append(poolIDs, pool.ID)
+			}
+		case solidly.SolidlySystemDiff:
+			for _, pool := range slices.Concat(data.Additions, data.Updates) {
+				poolIDs = append(poolIDs, pool.ID)
+			}
+		case poolregistry.PoolRegistryDiff:
+			for _, pool := range data.PoolAdditions {
+				poolIDs = append(poolIDs, pool.ID)
+			}
+		case tokenregistry.TokenSystemDiff, tokenpoolregistry.TokenPoolRegistryDiff:
+		default:
+			return nil, false
+		}
+	}
+
+	slices.Sort(poolIDs)
+	return slices.Compact(poolIDs), true
+}
+
+// ChangedTokens returns the ids of the tokens a decoded state diff adds, updates or
+// deletes, sorted and without duplicates, ready for GraphDiff.ChangedTokens. Their
+// metadata decides whether and how the pools trading them are routed.
+func ChangedTokens(diff *differ.StateDiff) []uint64 {
+	if diff == nil {
+		return nil
+	}
+
+	var tokenIDs []uint64
+	for _, protocolDiff := range diff.Protocols {
+		data, ok := protocolDiff.Data.(tokenregistry.TokenSystemDiff)
+		if !ok {
+			continue
+		}
+		for _, token := range slices.Concat(data.Additions, data.Updates) {
+			tokenIDs = append(tokenIDs, token.ID)
+		}
+		tokenIDs = append(tokenIDs, data.Deletions...)
+	}
+
+	slices.Sort(tokenIDs)
+	return slices.Compact(tokenIDs)
+}
@@ -0,0 +1,61 @@
+package chains
+
+import (
+	"testing"
+
+	"github.com/defistate/defistate-client-go/differ"
+	"github.com/defistate/defistate-client-go/engine"
+	poolregistry "github.com/defistate/defistate-client-go/protocols/poolregistry"
+	solidly "github.com/defistate/defistate-client-go/protocols/solidly"
+	tokenregistry "github.com/defistate/defistate-client-go/protocols/tokenregistry"
+	uniswapv2 "github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	uniswapv3 "github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangedPools(t *testing.T) {
+	t.Run("collects additions and updates across protocols", func(t *testing.T) {
+		diff := &differ.StateDiff{Protocols: map[engine.ProtocolID]differ.ProtocolDiff{
+			"uniswap-v2": {Data: uniswapv2.UniswapV2SystemDiff{
+				Additions: []uniswapv2.Pool{{ID: 7}},
+				Updates:   []uniswapv2.Pool{{ID: 3}},
+				Deletions: []uint64{9},
+			}},
+			"uniswap-v3":    {Data: uniswapv3.UniswapV3SystemDiff{Updates: []uniswapv3.Pool{{PoolViewMinimal: uniswapv3.PoolViewMinimal{ID: 5}}}}},
+			"solidly":       {Data: solidly.SolidlySystemDiff{Updates: []solidly.Pool{{ID: 3}}}},
+			"pool-registry": {Data: poolregistry.PoolRegistryDiff{PoolAdditions: []poolregistry.Pool{{ID: 7}, {ID: 8}}}},
+			"tokens":        {Data: tokenregistry.TokenSystemDiff{}},
+			"unchanged":     {},
+		}}
+
+		poolIDs, ok := ChangedPools(diff)
+		assert.True(t, ok)
+		assert.Equal(t, []uint64{3, 5, 7, 8}, poolIDs)
+	})
+
+	t.Run("unknown or failed protocol diffs need a rebuild", func(t *testing.T) {
+		_, ok := ChangedPools(&differ.StateDiff{Protocols: map[engine.ProtocolID]differ.ProtocolDiff{
+			"curve": {Data: struct{}{}},
+		}})
+		assert.False(t, ok)
+
+		_, ok = ChangedPools(&differ.StateDiff{Protocols: map[engine.ProtocolID]differ.ProtocolDiff{
+			"uniswap-v2": {Error: "out of sync"},
+		}})
+		assert.False(t, ok)
+	})
+}
+
+func TestChangedTokens(t *testing.T) {
+	diff := &differ.StateDiff{Protocols: map[engine.ProtocolID]differ.ProtocolDiff{
+		"tokens": {Data: tokenregistry.TokenSystemDiff{
+			Additions: []tokenregistry.Token{{ID: 9}},
+			Updates:   []tokenregistry.Token{{ID: 2}},
+			Deletions: []uint64{4, 9},
+		}},
+		"uniswap-v2": {Data: uniswapv2.UniswapV2SystemDiff{Updates: []uniswapv2.Pool{{ID: 3}}}},
+	}}
+
+	assert.Equal(t, []uint64{2, 4, 9}, ChangedTokens(diff))
+	assert.Empty(t, ChangedTokens(nil))
+}
@@ -0,0 +1,72 @@
+// Package graphers selects the grapher of a chain by its ID, for code that builds the
+// analytical graph of a state without a chain client, such as the quoter and the console.
+package graphers
+
+import (
+	"github.com/defistate/defistate-client-go/addr"
+	"github.com/defistate/defistate-client-go/chains"
+	arbitrumgrapher "github.com/defistate/defistate-client-go/chains/arbitrum/grapher"
+	basegrapher "github.com/defistate/defistate-client-go/chains/base/grapher"
+	bscgrapher "github.com/defistate/defistate-client-go/chains/bsc/grapher"
+	ethereumgrapher "github.com/defistate/defistate-client-go/chains/ethereum/grapher"
+	katanagrapher "github.com/defistate/defistate-client-go/chains/katana/grapher"
+	polygongrapher "github.com/defistate/defistate-client-go/chains/polygon/grapher"
+	chainids "github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains"
+)
+
+// Option configures the graphers ForChainID returns, whichever chain they are for.
+type Option interface {
+	apply(*options)
+}
+
+type options struct {
+	tokenAllowlist []addr.Address
+	tokenDenylist  []addr.Address
+}
+
+type funcOption func(*options)
+
+func (f funcOption) apply(o *options) {
+	f(o)
+}
+
+// WithTokenAllowlist restricts routing to pools whose tokens are all in the allowlist,
+// as the chain graphers' WithTokenAllowlist does. An empty allowlist allows every token.
+func WithTokenAllowlist(tokens []addr.Address) Option {
+	return funcOption(func(o *options) {
+		o.tokenAllowlist = tokens
+	})
+}
+
+// WithTokenDenylist leaves pools touching any denylisted token out of routing, as the
+// chain graphers' WithTokenDenylist does.
+func WithTokenDenylist(tokens []addr.Address) Option {
+	return funcOption(func(o *options) {
+		o.tokenDenylist = tokens
+	})
+}
+
+// ForChainID returns the grapher the client of chainID routes with. Chains without a
+// client of their own, and states without a chain ID, get the Ethereum grapher, which
+// handles the Uniswap and Solidly schemas every chain shares.
+func ForChainID(chainID uint64, opts ...Option) (chains.TokenPoolGrapher, error) {
+	var o options
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+
+	switch chainID {
+	case chainids.BSC:
+		return bscgrapher.NewGrapher(bscgrapher.WithTokenAllowlist(o.tokenAllowlist), bscgrapher.WithTokenDenylist(o.tokenDenylist))
+	case chainids.Arbitrum:
+		return arbitrumgrapher.NewGrapher(arbitrumgrapher.WithTokenAllowlist(o.tokenAllowlist), arbitrumgrapher.WithTokenDenylist(o.tokenDenylist))
+	case chainids.Base:
+		return basegrapher.NewGrapher(basegrapher.WithTokenAllowlist(o.tokenAllowlist), basegrapher.WithTokenDenylist(o.tokenDenylist))
+	case chainids.Katana:
+		return katanagrapher.NewGrapher(katanagrapher.WithTokenAllowlist(o.tokenAllowlist), katanagrapher.WithTokenDenylist(o.tokenDenylist))
+	case chainids.Polygon:
+		return polygongrapher.NewGrapher(polygongrapher.WithTokenAllowlist(o.tokenAllowlist), polygongrapher.WithTokenDenylist(o.tokenDenylist))
+	default:
+		return ethereumgrapher.NewGrapher(ethereumgrapher.WithTokenAllowlist(o.tokenAllowlist), ethereumgrapher.WithTokenDenylist(o.tokenDenylist))
+	}
+}
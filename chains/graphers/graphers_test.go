@@ -0,0 +1,24 @@
+package graphers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	bscgrapher "github.com/defistate/defistate-client-go/chains/bsc/grapher"
+	ethereumgrapher "github.com/defistate/defistate-client-go/chains/ethereum/grapher"
+	chainids "github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains"
+)
+
+func TestForChainID(t *testing.T) {
+	g, err := ForChainID(chainids.BSC)
+	require.NoError(t, err)
+	assert.IsType(t, &bscgrapher.Grapher{}, g)
+
+	for _, chainID := range []uint64{chainids.Mainnet, chainids.Optimism, 0} {
+		g, err := ForChainID(chainID)
+		require.NoError(t, err)
+		assert.IsType(t, &ethereumgrapher.Grapher{}, g, "chain %d", chainID)
+	}
+}
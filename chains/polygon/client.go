@@ -0,0 +1,519 @@
+package polygon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/defistate/defistate-client-go/chains"
+	"github.com/defistate/defistate-client-go/chains/polygon/grapher"
+	"github.com/defistate/defistate-client-go/engine"
+	jsonrpcclient "github.com/defistate/defistate-client-go/streams/jsonrpc/client"
+	polygonstateops "github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains/polygon"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/defistate/defistate-client-go/protocols/poolregistry"
+	poolregistryindexer "github.com/defistate/defistate-client-go/protocols/poolregistry/indexer"
+	"github.com/defistate/defistate-client-go/protocols/solidly"
+	solidlyindexer "github.com/defistate/defistate-client-go/protocols/solidly/indexer"
+	"github.com/defistate/defistate-client-go/protocols/tokenpoolregistry"
+	tokenregistry "github.com/defistate/defistate-client-go/protocols/tokenregistry"
+	tokenregistryindexer "github.com/defistate/defistate-client-go/protocols/tokenregistry/indexer"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	uniswapv2indexer "github.com/defistate/defistate-client-go/protocols/uniswapv2/indexer"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	uniswapv3indexer "github.com/defistate/defistate-client-go/protocols/uniswapv3/indexer"
+)
+
+// Client orchestrates the ingestion and processing of DeFi state.
+// Its lifecycle is bound to the context passed during Dial.
+type Client struct {
+	stream  chains.Client
+	logger  chains.Logger
+	stateCh chan *State
+	errCh   chan error
+
+	// Immutable Indexers (set via Options during Dial)
+	tokenPoolGrapher    chains.TokenPoolGrapher
+	tokenIndexer        chains.TokenIndexer
+	poolRegistryIndexer chains.PoolRegistryIndexer
+	uniswapV2Indexer    chains.UniswapV2Indexer
+	uniswapV3Indexer    chains.UniswapV3Indexer
+	solidlyIndexer      chains.SolidlyIndexer
+
+	// stateStore, if set, persists every state that is processed successfully.
+	stateStore chains.StateStore
+
+	// diffs, if set, holds the diffs the stream patched its states with, so a state
+	// patched from the last processed one updates its graph instead of rebuilding it.
+	diffs *chains.DiffTracker
+	// lastRaw is the last state processed successfully, and last its processed form.
+	lastRaw *engine.State
+	last    *State
+
+	// droppedStates counts processed states discarded because the consumer fell behind.
+	droppedStates        atomic.Uint64
+	droppedStatesCounter prometheus.Counter
+
+	ctx context.Context
+	wg  sync.WaitGroup
+}
+
+// Option configures the Client.
+// The interface method is unexported to prevent external modification after Dial.
+type Option interface {
+	apply(*Client)
+}
+
+type funcOption func(*Client)
+
+func (f funcOption) apply(p *Client) {
+	f(p)
+}
+
+func newOption(f func(*Client)) Option {
+	return funcOption(f)
+}
+
+// Dial establishes the connection and starts the processing loop.
+// The returned Client will remain active until the provided ctx is cancelled.
+func Dial(
+	ctx context.Context,
+	url string,
+	logger chains.Logger,
+	prometheusRegistry prometheus.Registerer,
+	opts ...Option,
+) (*Client, error) {
+
+	stateOps, err := polygonstateops.NewStateOps(
+		logger,
+		prometheusRegistry,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create state ops: %w", err)
+	}
+
+	diffs := chains.NewDiffTracker()
+	clientCfg := jsonrpcclient.Config{
+		URL:              url,
+		Logger:           logger,
+		BufferSize:       100,
+		StatePatcher:     diffs.Patcher(stateOps.Patch),
+		StateDecoder:     stateOps.DecodeStateJSON,
+		StateDiffDecoder: stateOps.DecodeStateDiffJSON,
+		Registry:         prometheusRegistry,
+	}
+
+	client, err := jsonrpcclient.NewClient(ctx, clientCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial defistate stream url: %w", err)
+	}
+
+	tokenPoolGrapher, err := grapher.NewGrapher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create grapher: %w", err)
+	}
+
+	p := &Client{
+		stream:              client,
+		logger:              logger,
+		stateCh:             make(chan *State, 100),
+		errCh:               make(chan error, 1),
+		tokenIndexer:        tokenregistryindexer.New(),
+		poolRegistryIndexer: poolregistryindexer.New(),
+		tokenPoolGrapher:    tokenPoolGrapher,
+		uniswapV2Indexer:    uniswapv2indexer.New(),
+		uniswapV3Indexer:    uniswapv3indexer.New(),
+		solidlyIndexer:      solidlyindexer.New(),
+		diffs:               diffs,
+	}
+	p.droppedStatesCounter = newDroppedStatesCounter(prometheusRegistry)
+
+	for _, opt := range opts {
+		opt.apply(p)
+	}
+
+	// Bind the Client's lifecycle to the user-provided context
+	p.ctx = ctx
+	p.wg.Add(1)
+	go p.loop()
+
+	p.logger.Info("Client started", "url", url)
+	return p, nil
+}
+
+func FromStream(
+	ctx context.Context,
+	stream chains.Client,
+	logger chains.Logger,
+	prometheusRegistry prometheus.Registerer,
+	opts ...Option,
+) (*Client, error) {
+	tokenPoolGrapher, err := grapher.NewGrapher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create grapher: %w", err)
+	}
+
+	p := &Client{
+		stream:              stream,
+		logger:              logger,
+		stateCh:             make(chan *State, 100),
+		errCh:               make(chan error, 1),
+		tokenIndexer:        tokenregistryindexer.New(),
+		poolRegistryIndexer: poolregistryindexer.New(),
+		tokenPoolGrapher:    tokenPoolGrapher,
+		uniswapV2Indexer:    uniswapv2indexer.New(),
+		uniswapV3Indexer:    uniswapv3indexer.New(),
+		solidlyIndexer:      solidlyindexer.New(),
+	}
+	p.droppedStatesCounter = newDroppedStatesCounter(prometheusRegistry)
+	for _, opt := range opts {
+		opt.apply(p)
+	}
+
+	// Bind the Client's lifecycle to the user-provided context
+	p.ctx = ctx
+	p.wg.Add(1)
+	go p.loop()
+
+	p.logger.Info("Client started")
+	return p, nil
+}
+
+// State channel is best-effort; if consumer is slow, updates may be dropped
+// (each drop is logged at warn level and counted; see DroppedStates)
+func (p *Client) State() <-chan *State {
+	return p.stateCh
+}
+
+func (p *Client) Err() <-chan error {
+	return p.errCh
+}
+
+func (p *Client) loop() {
+	defer p.wg.Done()
+	defer func() {
+		close(p.stateCh)
+		close(p.errCh)
+		p.logger.Info("Client stopped")
+	}()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+
+		case err := <-p.stream.Err():
+			p.logger.Error("Fatal client error", "err", err)
+			select {
+			case p.errCh <- err:
+			case <-p.ctx.Done():
+			}
+			return
+
+		case rawState, ok := <-p.stream.State():
+			if !ok {
+				p.logger.Error("Upstream state channel closed")
+				return
+			}
+
+			processed, err := p.processState(rawState)
+			if err != nil {
+				p.logger.Error("Failed to process state", "block", rawState.Block.Number, "err", err)
+				continue
+			}
+
+			p.storeState(rawState)
+
+			select {
+			case p.stateCh <- processed:
+			case <-p.ctx.Done():
+				return
+			default:
+				p.dropState(processed)
+			}
+		}
+	}
+}
+
+// storeState writes rawState through the configured StateStore, if any. A failed write
+// is logged and does not hold back the state.
+func (p *Client) storeState(rawState *engine.State) {
+	if p.stateStore == nil {
+		return
+	}
+	block := rawState.Block.Number.Uint64()
+	if err := p.stateStore.Put(block, rawState); err != nil {
+		p.logger.Error("Failed to store state", "block", block, "err", err)
+	}
+}
+
+// DroppedStates returns how many processed states were discarded because the
+// State channel was full.
+func (p *Client) DroppedStates() uint64 {
+	return p.droppedStates.Load()
+}
+
+// dropState records a processed state that was discarded because the consumer is not
+// keeping up. Frequent drops point at a slow consumer of State().
+func (p *Client) dropState(state *State) {
+	dropped := p.droppedStates.Add(1)
+	if p.droppedStatesCounter != nil {
+		p.droppedStatesCounter.Inc()
+	}
+	p.logger.Warn("State buffer full, discarding processed state", "block", state.Block.Number, "dropped_total", dropped)
+}
+
+// newDroppedStatesCounter creates and registers the dropped states counter. Clients
+// sharing a registry share the counter, so building a second client does not panic.
+func newDroppedStatesCounter(reg prometheus.Registerer) prometheus.Counter {
+	counter := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: jsonrpcclient.MetricsNamespace,
+		Name:      "dropped_states_total",
+		Help:      "Total number of processed states discarded because the state buffer was full.",
+	})
+	if err := reg.Register(counter); err != nil {
+		var registered prometheus.AlreadyRegisteredError
+		if errors.As(err, &registered) {
+			return registered.ExistingCollector.(prometheus.Counter)
+		}
+		panic(err)
+	}
+	return counter
+}
+
+type State struct {
+	Graph               chains.TokenPoolGraph
+	IndexedTokenSystem  tokenregistryindexer.IndexedTokenSystem
+	IndexedPoolRegistry poolregistryindexer.IndexedPoolRegistry
+	IndexedUniswapV2    uniswapv2indexer.IndexedUniswapV2
+	IndexedUniswapV3    uniswapv3indexer.IndexedUniswapV3
+	IndexedSolidly      solidlyindexer.IndexedSolidly
+	ProtocolResolver    *chains.ProtocolResolver
+	Block               engine.BlockSummary
+	ProcessedAtUnixNs   uint64
+}
+
+func (p *Client) processState(rawState *engine.State) (*State, error) {
+
+	indexingStart := time.Now()
+	p.logger.Info("New state received, starting processing", "block", rawState.Block.Number)
+
+	var wg sync.WaitGroup
+	wg.Add(5)
+
+	var (
+		rawGraph         *tokenpoolregistry.TokenPoolRegistryView
+		tokenData        []tokenregistry.Token
+		poolRegistryData *poolregistry.PoolRegistry
+
+		allUniswapV2Data []uniswapv2.Pool
+		allUniswapV3Data []uniswapv3.Pool
+		allSolidlyData   []solidly.Pool
+
+		indexedTokenSystem  tokenregistryindexer.IndexedTokenSystem
+		indexedPoolRegistry poolregistryindexer.IndexedPoolRegistry
+		indexedUniswapV2    uniswapv2indexer.IndexedUniswapV2
+		indexedUniswapV3    uniswapv3indexer.IndexedUniswapV3
+		indexedSolidly      solidlyindexer.IndexedSolidly
+	)
+
+	// first, get all data with switch on Protocol.Schema
+	for _, protocol := range rawState.Protocols {
+		switch protocol.Schema {
+		case tokenregistry.Schema:
+			if tokenData != nil {
+				return nil, fmt.Errorf("multiple token protocol data found")
+			}
+			tokenData = protocol.Data.([]tokenregistry.Token)
+		case poolregistry.Schema:
+			if poolRegistryData != nil {
+				return nil, fmt.Errorf("multiple pool registry protocol data found")
+			}
+			d := protocol.Data.(poolregistry.PoolRegistry)
+			poolRegistryData = &d
+
+		case tokenpoolregistry.Schema:
+			if rawGraph != nil {
+				return nil, fmt.Errorf("multiple graph data found")
+			}
+			rawGraph = protocol.Data.(*tokenpoolregistry.TokenPoolRegistryView)
+		case uniswapv2.Schema:
+			allUniswapV2Data = append(allUniswapV2Data, protocol.Data.([]uniswapv2.Pool)...)
+		case uniswapv3.Schema:
+			allUniswapV3Data = append(allUniswapV3Data, protocol.Data.([]uniswapv3.Pool)...)
+		case solidly.Schema:
+			allSolidlyData = append(allSolidlyData, protocol.Data.([]solidly.Pool)...)
+		}
+	}
+
+	if rawGraph == nil {
+		return nil, fmt.Errorf("No token pool graph data found in raw state. Block %d", rawState.Block.Number)
+	}
+	if tokenData == nil {
+		// Token metadata is optional: the graph can still route on token IDs alone,
+		// so we degrade instead of dropping the state.
+		p.logger.Warn("No token system data found in raw state, continuing without token metadata", "block", rawState.Block.Number)
+	}
+
+	if poolRegistryData == nil {
+		return nil, fmt.Errorf("No pool registry data found in raw state. Block %d", rawState.Block.Number)
+	}
+
+	go func() {
+		defer wg.Done()
+		if tokenData != nil {
+			indexedTokenSystem = p.tokenIndexer.Index(tokenData)
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		indexedPoolRegistry = p.poolRegistryIndexer.Index(*poolRegistryData)
+	}()
+
+	go func() {
+		defer wg.Done()
+		indexedUniswapV2 = p.uniswapV2Indexer.Index(allUniswapV2Data)
+	}()
+	go func() {
+		defer wg.Done()
+		indexedUniswapV3 = p.uniswapV3Indexer.Index(allUniswapV3Data)
+	}()
+	go func() {
+		defer wg.Done()
+		indexedSolidly = p.solidlyIndexer.Index(allSolidlyData)
+	}()
+
+	wg.Wait()
+
+	// log metrics
+	indexingDuration := time.Since(indexingStart)
+	p.logger.Info("All known protocols indexed in parallel", "block", rawState.Block.Number, "duration_ms", indexingDuration.Milliseconds())
+
+	graphingStart := time.Now()
+	protocolIDToProtocolSchema := map[engine.ProtocolID]engine.ProtocolSchema{}
+	for id, protocol := range rawState.Protocols {
+		protocolIDToProtocolSchema[id] = protocol.Schema
+	}
+
+	protocolResolver := chains.NewProtocolResolver(
+		protocolIDToProtocolSchema,
+		indexedPoolRegistry,
+	)
+
+	graph, err := p.graph(rawState, chains.GraphDiff{
+		TokenPool:            rawGraph,
+		IndexedTokenRegistry: indexedTokenSystem,
+		IndexedPoolRegistry:  indexedPoolRegistry,
+		IndexedUniswapV2:     indexedUniswapV2,
+		IndexedUniswapV3:     indexedUniswapV3,
+		IndexedSolidly:       indexedSolidly,
+		ProtocolResolver:     protocolResolver,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("Grapher error %v", err)
+	}
+
+	graphingDuration := time.Since(graphingStart)
+	p.logger.Info("Analytical graph built", "block", rawState.Block.Number, "duration_ms", graphingDuration.Milliseconds())
+
+	state := &State{
+		Graph:               graph,
+		IndexedTokenSystem:  indexedTokenSystem,
+		IndexedPoolRegistry: indexedPoolRegistry,
+		IndexedUniswapV2:    indexedUniswapV2,
+		IndexedUniswapV3:    indexedUniswapV3,
+		IndexedSolidly:      indexedSolidly,
+		ProtocolResolver:    protocolResolver,
+		Block:               rawState.Block,
+		ProcessedAtUnixNs:   uint64(time.Now().UnixNano()),
+	}
+	p.lastRaw, p.last = rawState, state
+
+	return state, nil
+
+}
+
+// graph builds the graph of rawState from its views. A state the stream patched from the
+// last processed state instead updates that state's graph with ApplyDiff, which rewires
+// only the pools and tokens the diff changed; the graph is built from scratch for full
+// states and for diffs whose changes are unknown.
+func (p *Client) graph(rawState *engine.State, views chains.GraphDiff) (chains.TokenPoolGraph, error) {
+	if p.diffs != nil {
+		if diff, ok := p.diffs.Take(p.lastRaw, rawState); ok {
+			if views.ChangedPools, ok = chains.ChangedPools(diff); ok {
+				views.ChangedTokens = chains.ChangedTokens(diff)
+				return p.last.Graph.ApplyDiff(views)
+			}
+		}
+	}
+	return p.tokenPoolGrapher.Graph(
+		views.TokenPool,
+		views.IndexedTokenRegistry,
+		views.IndexedPoolRegistry,
+		views.IndexedUniswapV2,
+		views.IndexedUniswapV3,
+		views.IndexedSolidly,
+		views.ProtocolResolver,
+	)
+}
+
+// Options Constructors for the Client
+
+func WithTokenIndexer(indexer chains.TokenIndexer) Option {
+	return newOption(func(p *Client) {
+		p.tokenIndexer = indexer
+	})
+}
+
+func WithPoolRegistryIndexer(indexer chains.PoolRegistryIndexer) Option {
+	return newOption(func(p *Client) {
+		p.poolRegistryIndexer = indexer
+	})
+}
+
+func WithUniswapV2Indexer(indexer chains.UniswapV2Indexer) Option {
+	return newOption(func(p *Client) {
+		p.uniswapV2Indexer = indexer
+	})
+}
+
+func WithUniswapV3Indexer(indexer chains.UniswapV3Indexer) Option {
+	return newOption(func(p *Client) {
+		p.uniswapV3Indexer = indexer
+	})
+}
+
+func WithSolidlyIndexer(indexer chains.SolidlyIndexer) Option {
+	return newOption(func(p *Client) {
+		p.solidlyIndexer = indexer
+	})
+}
+
+func WithTokenPoolGrapher(grapher chains.TokenPoolGrapher) Option {
+	return newOption(func(p *Client) {
+		p.tokenPoolGrapher = grapher
+	})
+}
+
+// WithDiffTracker updates the graph of each state the stream patched from the previous
+// one instead of rebuilding it, using the diffs tracker recorded. Dial sets it up itself;
+// FromStream callers wrap their stream's state patcher with tracker.Patcher.
+func WithDiffTracker(tracker *chains.DiffTracker) Option {
+	return newOption(func(p *Client) {
+		p.diffs = tracker
+	})
+}
+
+// WithStateStore persists every state the client processes successfully through store,
+// keyed by block number. See statestore.FileStore for a file-based store.
+func WithStateStore(store chains.StateStore) Option {
+	return newOption(func(p *Client) {
+		p.stateStore = store
+	})
+}
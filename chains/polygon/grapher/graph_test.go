@@ -0,0 +1,11910 @@
+package grapher
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/defistate/defistate-client-go/addr"
+	"github.com/defistate/defistate-client-go/chains"
+	"github.com/defistate/defistate-client-go/engine"
+	poolregistry "github.com/defistate/defistate-client-go/protocols/poolregistry"
+	tokenpoolregistry "github.com/defistate/defistate-client-go/protocols/tokenpoolregistry"
+	tokenregistry "github.com/defistate/defistate-client-go/protocols/tokenregistry"
+
+	"github.com/defistate/defistate-client-go/protocols/solidly"
+	solidlycalculator "github.com/defistate/defistate-client-go/protocols/solidly/calculator"
+	solidlyindexer "github.com/defistate/defistate-client-go/protocols/solidly/indexer"
+	uniswapv2 "github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	uniswapv2calculator "github.com/defistate/defistate-client-go/protocols/uniswapv2/calculator"
+	uniswapv3 "github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	uniswapv3calculator "github.com/defistate/defistate-client-go/protocols/uniswapv3/calculator"
+	uniswapv4 "github.com/defistate/defistate-client-go/protocols/uniswapv4"
+	uniswapv4calculator "github.com/defistate/defistate-client-go/protocols/uniswapv4/calculator"
+
+	poolregistryindexer "github.com/defistate/defistate-client-go/protocols/poolregistry/indexer"
+	tokenregistryindexer "github.com/defistate/defistate-client-go/protocols/tokenregistry/indexer"
+	uniswapv2indexer "github.com/defistate/defistate-client-go/protocols/uniswapv2/indexer"
+	uniswapv3indexer "github.com/defistate/defistate-client-go/protocols/uniswapv3/indexer"
+)
+
+// ##################################################################
+// #                  MOCK INTERFACE IMPLEMENTATIONS                #
+// ##################################################################
+
+const (
+	uniswapV2PoolRegistryID = uint16(1)
+	uniswapV3PoolRegistryID = uint16(2)
+	uniswapV2ProtocolID     = engine.ProtocolID("uniswap-v2")
+	uniswapV3ProtocolID     = engine.ProtocolID("uniswap-v3")
+)
+
+func bigIntFromString(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("failed to set big int string")
+	}
+
+	return n
+}
+
+func setupUniswapV3ETHUSDCPool(token0ID uint64, token1ID uint64, poolID uint64) uniswapv3.Pool {
+	// Raw tick data from a real pool snapshot.
+	rawTicks := []struct {
+		TickIdx      string
+		LiquidityNet string
+	}{}
+
+	rawTicksJson := `[
+      {
+        "liquidityGross": "44978760068372456",
+        "liquidityNet": "44978760068372456",
+        "tickIdx": "-887270"
+      },
+      {
+        "liquidityGross": "23703747677073",
+        "liquidityNet": "23703747677073",
+        "tickIdx": "-887260"
+      },
+      {
+        "liquidityGross": "398290794261",
+        "liquidityNet": "398290794261",
+        "tickIdx": "-92110"
+      },
+      {
+        "liquidityGross": "29651014881301328537",
+        "liquidityNet": "29651014881301328537",
+        "tickIdx": "-76280"
+      },
+      {
+        "liquidityGross": "29651014881301328537",
+        "liquidityNet": "-29651014881301328537",
+        "tickIdx": "-75980"
+      },
+      {
+        "liquidityGross": "13570724854034",
+        "liquidityNet": "13570724854034",
+        "tickIdx": "0"
+      },
+      {
+        "liquidityGross": "100",
+        "liquidityNet": "100",
+        "tickIdx": "100"
+      },
+      {
+        "liquidityGross": "100",
+        "liquidityNet": "-100",
+        "tickIdx": "110"
+      },
+      {
+        "liquidityGross": "13570724854034",
+        "liquidityNet": "-13570724854034",
+        "tickIdx": "100000"
+      },
+      {
+        "liquidityGross": "2266789968",
+        "liquidityNet": "2266789968",
+        "tickIdx": "108340"
+      },
+      {
+        "liquidityGross": "2273693713",
+        "liquidityNet": "2273693713",
+        "tickIdx": "108360"
+      },
+      {
+        "liquidityGross": "44739669244",
+        "liquidityNet": "44739669244",
+        "tickIdx": "108390"
+      },
+      {
+        "liquidityGross": "144810683332502",
+        "liquidityNet": "144810683332502",
+        "tickIdx": "115120"
+      },
+      {
+        "liquidityGross": "24308826080",
+        "liquidityNet": "24308826080",
+        "tickIdx": "115140"
+      },
+      {
+        "liquidityGross": "2344264358099",
+        "liquidityNet": "2344264358099",
+        "tickIdx": "138160"
+      },
+      {
+        "liquidityGross": "9403707956281",
+        "liquidityNet": "9403707956281",
+        "tickIdx": "149150"
+      },
+      {
+        "liquidityGross": "574355480",
+        "liquidityNet": "574355480",
+        "tickIdx": "161190"
+      },
+      {
+        "liquidityGross": "607207624019674",
+        "liquidityNet": "607207624019674",
+        "tickIdx": "166300"
+      },
+      {
+        "liquidityGross": "498531057962305",
+        "liquidityNet": "498531057962305",
+        "tickIdx": "175170"
+      },
+      {
+        "liquidityGross": "1142415145764782",
+        "liquidityNet": "1142415145764782",
+        "tickIdx": "177250"
+      },
+      {
+        "liquidityGross": "2588778177052193",
+        "liquidityNet": "2588778177052193",
+        "tickIdx": "177280"
+      },
+      {
+        "liquidityGross": "68579783371570",
+        "liquidityNet": "68579783371570",
+        "tickIdx": "178340"
+      },
+      {
+        "liquidityGross": "655281438629235",
+        "liquidityNet": "655281438629235",
+        "tickIdx": "180160"
+      },
+      {
+        "liquidityGross": "419968624358816",
+        "liquidityNet": "419968624358816",
+        "tickIdx": "180180"
+      },
+      {
+        "liquidityGross": "227351883552117",
+        "liquidityNet": "227351883552117",
+        "tickIdx": "180440"
+      },
+      {
+        "liquidityGross": "111626068365034",
+        "liquidityNet": "111626068365034",
+        "tickIdx": "180650"
+      },
+      {
+        "liquidityGross": "88680311208",
+        "liquidityNet": "88680311208",
+        "tickIdx": "181260"
+      },
+      {
+        "liquidityGross": "105220981852142",
+        "liquidityNet": "105220981852142",
+        "tickIdx": "181590"
+      },
+      {
+        "liquidityGross": "1131670065466301",
+        "liquidityNet": "1131670065466301",
+        "tickIdx": "182200"
+      },
+      {
+        "liquidityGross": "3451150785812214",
+        "liquidityNet": "3451150785812214",
+        "tickIdx": "182390"
+      },
+      {
+        "liquidityGross": "4740323423268196",
+        "liquidityNet": "4740323423268196",
+        "tickIdx": "182560"
+      },
+      {
+        "liquidityGross": "1084560033827482",
+        "liquidityNet": "1084560033827482",
+        "tickIdx": "182770"
+      },
+      {
+        "liquidityGross": "4740323423268196",
+        "liquidityNet": "-4740323423268196",
+        "tickIdx": "183080"
+      },
+      {
+        "liquidityGross": "1104057347094",
+        "liquidityNet": "1104057347094",
+        "tickIdx": "183160"
+      },
+      {
+        "liquidityGross": "14920025509875",
+        "liquidityNet": "14920025509875",
+        "tickIdx": "183260"
+      },
+      {
+        "liquidityGross": "151041316619269",
+        "liquidityNet": "151041316619269",
+        "tickIdx": "183920"
+      },
+      {
+        "liquidityGross": "17409290878",
+        "liquidityNet": "17409290878",
+        "tickIdx": "184200"
+      },
+      {
+        "liquidityGross": "1691342475368310",
+        "liquidityNet": "1691342475368310",
+        "tickIdx": "184210"
+      },
+      {
+        "liquidityGross": "5525404060502551",
+        "liquidityNet": "5523195945808363",
+        "tickIdx": "184220"
+      },
+      {
+        "liquidityGross": "3397635186717387",
+        "liquidityNet": "-3397635186717387",
+        "tickIdx": "184230"
+      },
+      {
+        "liquidityGross": "477197831652989",
+        "liquidityNet": "477197831652989",
+        "tickIdx": "185050"
+      },
+      {
+        "liquidityGross": "1049687190863",
+        "liquidityNet": "1049687190863",
+        "tickIdx": "185210"
+      },
+      {
+        "liquidityGross": "15469101894419",
+        "liquidityNet": "15469101894419",
+        "tickIdx": "185240"
+      },
+      {
+        "liquidityGross": "4745503553708786",
+        "liquidityNet": "4745503553708786",
+        "tickIdx": "185260"
+      },
+      {
+        "liquidityGross": "404583613408945",
+        "liquidityNet": "404583613408945",
+        "tickIdx": "185270"
+      },
+      {
+        "liquidityGross": "297728093",
+        "liquidityNet": "297728093",
+        "tickIdx": "185840"
+      },
+      {
+        "liquidityGross": "16737492612081",
+        "liquidityNet": "16737492612081",
+        "tickIdx": "186060"
+      },
+      {
+        "liquidityGross": "8039254639562",
+        "liquidityNet": "8039254639562",
+        "tickIdx": "186320"
+      },
+      {
+        "liquidityGross": "228699723058089",
+        "liquidityNet": "228699723058089",
+        "tickIdx": "186420"
+      },
+      {
+        "liquidityGross": "261844689822052",
+        "liquidityNet": "261844689822052",
+        "tickIdx": "186430"
+      },
+      {
+        "liquidityGross": "244135744116298",
+        "liquidityNet": "244135148660112",
+        "tickIdx": "186450"
+      },
+      {
+        "liquidityGross": "416983925278171",
+        "liquidityNet": "416983925278171",
+        "tickIdx": "186460"
+      },
+      {
+        "liquidityGross": "246350386196077",
+        "liquidityNet": "246350386196077",
+        "tickIdx": "187090"
+      },
+      {
+        "liquidityGross": "98726939861609",
+        "liquidityNet": "98726939861609",
+        "tickIdx": "187170"
+      },
+      {
+        "liquidityGross": "11661475258274",
+        "liquidityNet": "11661475258274",
+        "tickIdx": "187440"
+      },
+      {
+        "liquidityGross": "454493636691910",
+        "liquidityNet": "454493636691910",
+        "tickIdx": "187500"
+      },
+      {
+        "liquidityGross": "2670481682301302",
+        "liquidityNet": "-411616576700922",
+        "tickIdx": "187780"
+      },
+      {
+        "liquidityGross": "54743213803487",
+        "liquidityNet": "54743213803487",
+        "tickIdx": "187940"
+      },
+      {
+        "liquidityGross": "184855906959178362",
+        "liquidityNet": "184855906959178362",
+        "tickIdx": "188130"
+      },
+      {
+        "liquidityGross": "5020580219556",
+        "liquidityNet": "5020580219556",
+        "tickIdx": "188440"
+      },
+      {
+        "liquidityGross": "10013802424018",
+        "liquidityNet": "10013802424018",
+        "tickIdx": "188450"
+      },
+      {
+        "liquidityGross": "132473552239106",
+        "liquidityNet": "132473552239106",
+        "tickIdx": "188520"
+      },
+      {
+        "liquidityGross": "224653000969518",
+        "liquidityNet": "224653000969518",
+        "tickIdx": "188780"
+      },
+      {
+        "liquidityGross": "6391022318941913",
+        "liquidityNet": "6391022318941913",
+        "tickIdx": "188990"
+      },
+      {
+        "liquidityGross": "431254538102",
+        "liquidityNet": "431254538102",
+        "tickIdx": "189020"
+      },
+      {
+        "liquidityGross": "4459378691580",
+        "liquidityNet": "4459378691580",
+        "tickIdx": "189040"
+      },
+      {
+        "liquidityGross": "1491132445622",
+        "liquidityNet": "1491132445622",
+        "tickIdx": "189130"
+      },
+      {
+        "liquidityGross": "107388554969",
+        "liquidityNet": "107388554969",
+        "tickIdx": "189210"
+      },
+      {
+        "liquidityGross": "116526800732",
+        "liquidityNet": "116526800732",
+        "tickIdx": "189230"
+      },
+      {
+        "liquidityGross": "21054643810291374",
+        "liquidityNet": "21054643810291374",
+        "tickIdx": "189320"
+      },
+      {
+        "liquidityGross": "13452407513465956",
+        "liquidityNet": "13452407513465956",
+        "tickIdx": "189340"
+      },
+      {
+        "liquidityGross": "110366245499632",
+        "liquidityNet": "110366245499632",
+        "tickIdx": "189410"
+      },
+      {
+        "liquidityGross": "2659554920775",
+        "liquidityNet": "2659554920775",
+        "tickIdx": "189460"
+      },
+      {
+        "liquidityGross": "453600213217",
+        "liquidityNet": "453600213217",
+        "tickIdx": "189490"
+      },
+      {
+        "liquidityGross": "4355219688957",
+        "liquidityNet": "4355219688957",
+        "tickIdx": "189520"
+      },
+      {
+        "liquidityGross": "1034480184182597",
+        "liquidityNet": "1034480184182597",
+        "tickIdx": "189880"
+      },
+      {
+        "liquidityGross": "42201964560379",
+        "liquidityNet": "42201964560379",
+        "tickIdx": "189920"
+      },
+      {
+        "liquidityGross": "941735738993078",
+        "liquidityNet": "941735738993078",
+        "tickIdx": "189990"
+      },
+      {
+        "liquidityGross": "11591027949234734",
+        "liquidityNet": "11591027949234734",
+        "tickIdx": "190080"
+      },
+      {
+        "liquidityGross": "624068026011",
+        "liquidityNet": "624068026011",
+        "tickIdx": "190100"
+      },
+      {
+        "liquidityGross": "3077564238585",
+        "liquidityNet": "3077564238585",
+        "tickIdx": "190130"
+      },
+      {
+        "liquidityGross": "2082544348785232",
+        "liquidityNet": "2082544348785232",
+        "tickIdx": "190140"
+      },
+      {
+        "liquidityGross": "13957834346797159",
+        "liquidityNet": "13957834346797159",
+        "tickIdx": "190170"
+      },
+      {
+        "liquidityGross": "15637594630692703",
+        "liquidityNet": "15637594630692703",
+        "tickIdx": "190190"
+      },
+      {
+        "liquidityGross": "1375109716821800",
+        "liquidityNet": "1375109716821800",
+        "tickIdx": "190220"
+      },
+      {
+        "liquidityGross": "408198383987653",
+        "liquidityNet": "408198383987653",
+        "tickIdx": "190380"
+      },
+      {
+        "liquidityGross": "57016310416248",
+        "liquidityNet": "57016310416248",
+        "tickIdx": "190470"
+      },
+      {
+        "liquidityGross": "14327762966156",
+        "liquidityNet": "14327762966156",
+        "tickIdx": "190500"
+      },
+      {
+        "liquidityGross": "30520418856337",
+        "liquidityNet": "30520418856337",
+        "tickIdx": "190580"
+      },
+      {
+        "liquidityGross": "9992614566589614",
+        "liquidityNet": "9992614566589614",
+        "tickIdx": "190640"
+      },
+      {
+        "liquidityGross": "68758133535664",
+        "liquidityNet": "68758133535664",
+        "tickIdx": "190670"
+      },
+      {
+        "liquidityGross": "71510532495962",
+        "liquidityNet": "71510532495962",
+        "tickIdx": "190720"
+      },
+      {
+        "liquidityGross": "142660157354006",
+        "liquidityNet": "142660157354006",
+        "tickIdx": "190730"
+      },
+      {
+        "liquidityGross": "4499788938440932",
+        "liquidityNet": "4499788938440932",
+        "tickIdx": "190780"
+      },
+      {
+        "liquidityGross": "224082011199",
+        "liquidityNet": "224082011199",
+        "tickIdx": "190800"
+      },
+      {
+        "liquidityGross": "88934515412244",
+        "liquidityNet": "88934515412244",
+        "tickIdx": "190890"
+      },
+      {
+        "liquidityGross": "216130743921190",
+        "liquidityNet": "216130743921190",
+        "tickIdx": "190920"
+      },
+      {
+        "liquidityGross": "467104856038985",
+        "liquidityNet": "467104856038985",
+        "tickIdx": "190950"
+      },
+      {
+        "liquidityGross": "2454142663090112",
+        "liquidityNet": "2454142663090112",
+        "tickIdx": "191020"
+      },
+      {
+        "liquidityGross": "955418267016008",
+        "liquidityNet": "955418267016008",
+        "tickIdx": "191050"
+      },
+      {
+        "liquidityGross": "2537796806805945",
+        "liquidityNet": "2537796806805945",
+        "tickIdx": "191110"
+      },
+      {
+        "liquidityGross": "27563266076594",
+        "liquidityNet": "27563266076594",
+        "tickIdx": "191130"
+      },
+      {
+        "liquidityGross": "1147308847885218",
+        "liquidityNet": "1147258262744214",
+        "tickIdx": "191140"
+      },
+      {
+        "liquidityGross": "63706934514049943",
+        "liquidityNet": "63706933092836595",
+        "tickIdx": "191150"
+      },
+      {
+        "liquidityGross": "1620100610323942",
+        "liquidityNet": "1620100610323942",
+        "tickIdx": "191160"
+      },
+      {
+        "liquidityGross": "59062441893549909",
+        "liquidityNet": "59062441893549909",
+        "tickIdx": "191170"
+      },
+      {
+        "liquidityGross": "1050191227952063",
+        "liquidityNet": "1050191227952063",
+        "tickIdx": "191200"
+      },
+      {
+        "liquidityGross": "15210320549012533",
+        "liquidityNet": "15210320549012533",
+        "tickIdx": "191210"
+      },
+      {
+        "liquidityGross": "100397645365749",
+        "liquidityNet": "100397645365749",
+        "tickIdx": "191250"
+      },
+      {
+        "liquidityGross": "1208304338184526",
+        "liquidityNet": "1208304338184526",
+        "tickIdx": "191270"
+      },
+      {
+        "liquidityGross": "4401650642802915",
+        "liquidityNet": "4401650642802915",
+        "tickIdx": "191290"
+      },
+      {
+        "liquidityGross": "47944910966622481",
+        "liquidityNet": "47944910966622481",
+        "tickIdx": "191340"
+      },
+      {
+        "liquidityGross": "3150344442876953",
+        "liquidityNet": "3150344442876953",
+        "tickIdx": "191350"
+      },
+      {
+        "liquidityGross": "5188820077378081",
+        "liquidityNet": "5188820077378081",
+        "tickIdx": "191360"
+      },
+      {
+        "liquidityGross": "27338361178403975",
+        "liquidityNet": "27338361178403975",
+        "tickIdx": "191380"
+      },
+      {
+        "liquidityGross": "6783329557647",
+        "liquidityNet": "6783329557647",
+        "tickIdx": "191390"
+      },
+      {
+        "liquidityGross": "5039433626820",
+        "liquidityNet": "5039433626820",
+        "tickIdx": "191400"
+      },
+      {
+        "liquidityGross": "2094699975947",
+        "liquidityNet": "2094699975947",
+        "tickIdx": "191410"
+      },
+      {
+        "liquidityGross": "359772522343182",
+        "liquidityNet": "359772522343182",
+        "tickIdx": "191420"
+      },
+      {
+        "liquidityGross": "62625442344491",
+        "liquidityNet": "62625442344491",
+        "tickIdx": "191450"
+      },
+      {
+        "liquidityGross": "803705306501235",
+        "liquidityNet": "803705306501235",
+        "tickIdx": "191490"
+      },
+      {
+        "liquidityGross": "17473516102072026",
+        "liquidityNet": "17473516102072026",
+        "tickIdx": "191560"
+      },
+      {
+        "liquidityGross": "748462594481221",
+        "liquidityNet": "748462594481221",
+        "tickIdx": "191580"
+      },
+      {
+        "liquidityGross": "524896368645537",
+        "liquidityNet": "524896368645537",
+        "tickIdx": "191600"
+      },
+      {
+        "liquidityGross": "75338968183905158",
+        "liquidityNet": "75338968183905158",
+        "tickIdx": "191640"
+      },
+      {
+        "liquidityGross": "1566850962828684",
+        "liquidityNet": "1566850962828684",
+        "tickIdx": "191690"
+      },
+      {
+        "liquidityGross": "1622109959153899",
+        "liquidityNet": "1622109959153899",
+        "tickIdx": "191770"
+      },
+      {
+        "liquidityGross": "16754067954091",
+        "liquidityNet": "16754067954091",
+        "tickIdx": "191780"
+      },
+      {
+        "liquidityGross": "69660612896438",
+        "liquidityNet": "69660612896438",
+        "tickIdx": "191820"
+      },
+      {
+        "liquidityGross": "110172609084353",
+        "liquidityNet": "110172609084353",
+        "tickIdx": "191840"
+      },
+      {
+        "liquidityGross": "17110416892885",
+        "liquidityNet": "17110416892885",
+        "tickIdx": "191860"
+      },
+      {
+        "liquidityGross": "761140366858189",
+        "liquidityNet": "761140366858189",
+        "tickIdx": "191890"
+      },
+      {
+        "liquidityGross": "10761483450755904",
+        "liquidityNet": "10761483450755904",
+        "tickIdx": "191930"
+      },
+      {
+        "liquidityGross": "94566952731702183",
+        "liquidityNet": "94566952731702183",
+        "tickIdx": "191940"
+      },
+      {
+        "liquidityGross": "73984870976854",
+        "liquidityNet": "73984870976854",
+        "tickIdx": "191950"
+      },
+      {
+        "liquidityGross": "1212772494312444",
+        "liquidityNet": "1212772494312444",
+        "tickIdx": "191960"
+      },
+      {
+        "liquidityGross": "10959100062215967",
+        "liquidityNet": "10959100062215967",
+        "tickIdx": "191980"
+      },
+      {
+        "liquidityGross": "1666345879759411",
+        "liquidityNet": "-916999800151269",
+        "tickIdx": "192010"
+      },
+      {
+        "liquidityGross": "162007238007207",
+        "liquidityNet": "-132066617116831",
+        "tickIdx": "192040"
+      },
+      {
+        "liquidityGross": "110172609084353",
+        "liquidityNet": "-110172609084353",
+        "tickIdx": "192060"
+      },
+      {
+        "liquidityGross": "1142710830330571",
+        "liquidityNet": "1142710830330571",
+        "tickIdx": "192070"
+      },
+      {
+        "liquidityGross": "3635690949857831",
+        "liquidityNet": "3635690949857831",
+        "tickIdx": "192090"
+      },
+      {
+        "liquidityGross": "6795425165288",
+        "liquidityNet": "6795425165288",
+        "tickIdx": "192110"
+      },
+      {
+        "liquidityGross": "30070437780982754",
+        "liquidityNet": "30070437780982754",
+        "tickIdx": "192130"
+      },
+      {
+        "liquidityGross": "12671773620243808",
+        "liquidityNet": "12671773620243808",
+        "tickIdx": "192160"
+      },
+      {
+        "liquidityGross": "360377541178992221",
+        "liquidityNet": "360377541178992221",
+        "tickIdx": "192200"
+      },
+      {
+        "liquidityGross": "1094500921135",
+        "liquidityNet": "1094500921135",
+        "tickIdx": "192220"
+      },
+      {
+        "liquidityGross": "9981145729025",
+        "liquidityNet": "9981145729025",
+        "tickIdx": "192230"
+      },
+      {
+        "liquidityGross": "66336695746741",
+        "liquidityNet": "66336695746741",
+        "tickIdx": "192240"
+      },
+      {
+        "liquidityGross": "5851499863231400",
+        "liquidityNet": "5851499863231400",
+        "tickIdx": "192250"
+      },
+      {
+        "liquidityGross": "525812305488578",
+        "liquidityNet": "525812305488578",
+        "tickIdx": "192270"
+      },
+      {
+        "liquidityGross": "97041609388172",
+        "liquidityNet": "97041609388172",
+        "tickIdx": "192300"
+      },
+      {
+        "liquidityGross": "68558787967364",
+        "liquidityNet": "68558787967364",
+        "tickIdx": "192310"
+      },
+      {
+        "liquidityGross": "39372497843493362",
+        "liquidityNet": "39372497843493362",
+        "tickIdx": "192320"
+      },
+      {
+        "liquidityGross": "595664739440891",
+        "liquidityNet": "595664739440891",
+        "tickIdx": "192330"
+      },
+      {
+        "liquidityGross": "13506826513573",
+        "liquidityNet": "13506826513573",
+        "tickIdx": "192340"
+      },
+      {
+        "liquidityGross": "10859893043296587",
+        "liquidityNet": "-10135076653441127",
+        "tickIdx": "192430"
+      },
+      {
+        "liquidityGross": "5028056873592845",
+        "liquidityNet": "5028054492639427",
+        "tickIdx": "192540"
+      },
+      {
+        "liquidityGross": "313389198396341",
+        "liquidityNet": "313389198396341",
+        "tickIdx": "192550"
+      },
+      {
+        "liquidityGross": "852270591555056",
+        "liquidityNet": "852270591555056",
+        "tickIdx": "192560"
+      },
+      {
+        "liquidityGross": "1101283002618",
+        "liquidityNet": "1101283002618",
+        "tickIdx": "192570"
+      },
+      {
+        "liquidityGross": "5210098515299959",
+        "liquidityNet": "5210098515299959",
+        "tickIdx": "192580"
+      },
+      {
+        "liquidityGross": "785183510489161",
+        "liquidityNet": "785183510489161",
+        "tickIdx": "192590"
+      },
+      {
+        "liquidityGross": "3790906020488205",
+        "liquidityNet": "-3790906020488205",
+        "tickIdx": "192600"
+      },
+      {
+        "liquidityGross": "64130601516847",
+        "liquidityNet": "64130601516847",
+        "tickIdx": "192630"
+      },
+      {
+        "liquidityGross": "533656801435810",
+        "liquidityNet": "533656801435810",
+        "tickIdx": "192650"
+      },
+      {
+        "liquidityGross": "22315429910804164",
+        "liquidityNet": "22315429910804164",
+        "tickIdx": "192660"
+      },
+      {
+        "liquidityGross": "6724265650213026",
+        "liquidityNet": "6724265650213026",
+        "tickIdx": "192690"
+      },
+      {
+        "liquidityGross": "29001865383163",
+        "liquidityNet": "29001865383163",
+        "tickIdx": "192700"
+      },
+      {
+        "liquidityGross": "9333788502352",
+        "liquidityNet": "9333788502352",
+        "tickIdx": "192720"
+      },
+      {
+        "liquidityGross": "3362222189939151",
+        "liquidityNet": "3362222189939151",
+        "tickIdx": "192730"
+      },
+      {
+        "liquidityGross": "319612842172412",
+        "liquidityNet": "319612842172412",
+        "tickIdx": "192740"
+      },
+      {
+        "liquidityGross": "21037253742886",
+        "liquidityNet": "21037253742886",
+        "tickIdx": "192750"
+      },
+      {
+        "liquidityGross": "1104912081308990",
+        "liquidityNet": "1104912081308990",
+        "tickIdx": "192760"
+      },
+      {
+        "liquidityGross": "16790385442928",
+        "liquidityNet": "16790385442928",
+        "tickIdx": "192770"
+      },
+      {
+        "liquidityGross": "502989459558382",
+        "liquidityNet": "502989459558382",
+        "tickIdx": "192780"
+      },
+      {
+        "liquidityGross": "939053049297",
+        "liquidityNet": "939053049297",
+        "tickIdx": "192790"
+      },
+      {
+        "liquidityGross": "334384264738564",
+        "liquidityNet": "334384264738564",
+        "tickIdx": "192800"
+      },
+      {
+        "liquidityGross": "577460946635631",
+        "liquidityNet": "577460946635631",
+        "tickIdx": "192810"
+      },
+      {
+        "liquidityGross": "992012084007272",
+        "liquidityNet": "992012084007272",
+        "tickIdx": "192820"
+      },
+      {
+        "liquidityGross": "18009985194391259",
+        "liquidityNet": "18009985194391259",
+        "tickIdx": "192850"
+      },
+      {
+        "liquidityGross": "144702553708173",
+        "liquidityNet": "144702553708173",
+        "tickIdx": "192860"
+      },
+      {
+        "liquidityGross": "3316481380792550",
+        "liquidityNet": "3316481380792550",
+        "tickIdx": "192880"
+      },
+      {
+        "liquidityGross": "362828155770846340",
+        "liquidityNet": "362828155770846340",
+        "tickIdx": "192890"
+      },
+      {
+        "liquidityGross": "1190338611698300",
+        "liquidityNet": "1190338611698300",
+        "tickIdx": "192920"
+      },
+      {
+        "liquidityGross": "4147729363982767",
+        "liquidityNet": "4147729363982767",
+        "tickIdx": "192930"
+      },
+      {
+        "liquidityGross": "633385112841461",
+        "liquidityNet": "633385112841461",
+        "tickIdx": "192940"
+      },
+      {
+        "liquidityGross": "243555239068975",
+        "liquidityNet": "243555239068975",
+        "tickIdx": "192950"
+      },
+      {
+        "liquidityGross": "792418458417283",
+        "liquidityNet": "792418458417283",
+        "tickIdx": "192960"
+      },
+      {
+        "liquidityGross": "11747497668180204",
+        "liquidityNet": "11747497668180204",
+        "tickIdx": "192980"
+      },
+      {
+        "liquidityGross": "5582703905810",
+        "liquidityNet": "5582703905810",
+        "tickIdx": "193000"
+      },
+      {
+        "liquidityGross": "2899295615375",
+        "liquidityNet": "2618642453423",
+        "tickIdx": "193010"
+      },
+      {
+        "liquidityGross": "52855866853167758",
+        "liquidityNet": "52855866853167758",
+        "tickIdx": "193020"
+      },
+      {
+        "liquidityGross": "1592364244729474",
+        "liquidityNet": "1592364244729474",
+        "tickIdx": "193030"
+      },
+      {
+        "liquidityGross": "530903589704407",
+        "liquidityNet": "530903589704407",
+        "tickIdx": "193040"
+      },
+      {
+        "liquidityGross": "239551431053309",
+        "liquidityNet": "239551431053309",
+        "tickIdx": "193050"
+      },
+      {
+        "liquidityGross": "5834532636257724",
+        "liquidityNet": "5834532636257724",
+        "tickIdx": "193070"
+      },
+      {
+        "liquidityGross": "9415524806683644",
+        "liquidityNet": "9415524806683644",
+        "tickIdx": "193080"
+      },
+      {
+        "liquidityGross": "159182955381611",
+        "liquidityNet": "159182955381611",
+        "tickIdx": "193110"
+      },
+      {
+        "liquidityGross": "17582514774929",
+        "liquidityNet": "17582514774929",
+        "tickIdx": "193120"
+      },
+      {
+        "liquidityGross": "23802028003246999",
+        "liquidityNet": "23802028003246999",
+        "tickIdx": "193130"
+      },
+      {
+        "liquidityGross": "1268220779490710",
+        "liquidityNet": "1268220779490710",
+        "tickIdx": "193150"
+      },
+      {
+        "liquidityGross": "428593410094638",
+        "liquidityNet": "428396012173128",
+        "tickIdx": "193160"
+      },
+      {
+        "liquidityGross": "6164324568043073",
+        "liquidityNet": "6164324568043073",
+        "tickIdx": "193170"
+      },
+      {
+        "liquidityGross": "7270571658410040",
+        "liquidityNet": "-3578149220718032",
+        "tickIdx": "193180"
+      },
+      {
+        "liquidityGross": "252640582079213",
+        "liquidityNet": "252640582079213",
+        "tickIdx": "193190"
+      },
+      {
+        "liquidityGross": "8742335331739934",
+        "liquidityNet": "8742335331739934",
+        "tickIdx": "193200"
+      },
+      {
+        "liquidityGross": "21429840127304878",
+        "liquidityNet": "21429840127304878",
+        "tickIdx": "193210"
+      },
+      {
+        "liquidityGross": "24863651835896306",
+        "liquidityNet": "21713419551694964",
+        "tickIdx": "193220"
+      },
+      {
+        "liquidityGross": "505681290820892",
+        "liquidityNet": "505681290820892",
+        "tickIdx": "193240"
+      },
+      {
+        "liquidityGross": "2896529144969",
+        "liquidityNet": "2896529144969",
+        "tickIdx": "193250"
+      },
+      {
+        "liquidityGross": "71128033132486",
+        "liquidityNet": "71128033132486",
+        "tickIdx": "193260"
+      },
+      {
+        "liquidityGross": "417405379582506267",
+        "liquidityNet": "417405379582506267",
+        "tickIdx": "193270"
+      },
+      {
+        "liquidityGross": "16386661756838",
+        "liquidityNet": "16386661756838",
+        "tickIdx": "193280"
+      },
+      {
+        "liquidityGross": "147629331540997",
+        "liquidityNet": "147433212481233",
+        "tickIdx": "193290"
+      },
+      {
+        "liquidityGross": "10423298847805999",
+        "liquidityNet": "10423298847805999",
+        "tickIdx": "193300"
+      },
+      {
+        "liquidityGross": "92922799549920",
+        "liquidityNet": "92922799549920",
+        "tickIdx": "193310"
+      },
+      {
+        "liquidityGross": "11299792213255",
+        "liquidityNet": "11299792213255",
+        "tickIdx": "193320"
+      },
+      {
+        "liquidityGross": "481987597446560",
+        "liquidityNet": "481987597446560",
+        "tickIdx": "193330"
+      },
+      {
+        "liquidityGross": "23156212079566447",
+        "liquidityNet": "23145770992708597",
+        "tickIdx": "193340"
+      },
+      {
+        "liquidityGross": "52585024153162",
+        "liquidityNet": "52585024153162",
+        "tickIdx": "193350"
+      },
+      {
+        "liquidityGross": "10490869572965500",
+        "liquidityNet": "10490869572965500",
+        "tickIdx": "193360"
+      },
+      {
+        "liquidityGross": "10787423134689825",
+        "liquidityNet": "1296405839322119",
+        "tickIdx": "193370"
+      },
+      {
+        "liquidityGross": "70310058764139430",
+        "liquidityNet": "64914036868626052",
+        "tickIdx": "193380"
+      },
+      {
+        "liquidityGross": "13783786281720",
+        "liquidityNet": "13783786281720",
+        "tickIdx": "193390"
+      },
+      {
+        "liquidityGross": "1797101466719256",
+        "liquidityNet": "1797101466719256",
+        "tickIdx": "193400"
+      },
+      {
+        "liquidityGross": "32379831000142809",
+        "liquidityNet": "32379831000142809",
+        "tickIdx": "193410"
+      },
+      {
+        "liquidityGross": "951754252239215",
+        "liquidityNet": "-199628890502649",
+        "tickIdx": "193420"
+      },
+      {
+        "liquidityGross": "2185595125629027647",
+        "liquidityNet": "2067470241841927829",
+        "tickIdx": "193430"
+      },
+      {
+        "liquidityGross": "4359958076351360",
+        "liquidityNet": "4359958076351360",
+        "tickIdx": "193440"
+      },
+      {
+        "liquidityGross": "181485852009596",
+        "liquidityNet": "181485852009596",
+        "tickIdx": "193450"
+      },
+      {
+        "liquidityGross": "712799131895416801",
+        "liquidityNet": "-122011627269595733",
+        "tickIdx": "193470"
+      },
+      {
+        "liquidityGross": "535474783084873",
+        "liquidityNet": "535474783084873",
+        "tickIdx": "193480"
+      },
+      {
+        "liquidityGross": "843312834670442",
+        "liquidityNet": "843312834670442",
+        "tickIdx": "193500"
+      },
+      {
+        "liquidityGross": "42955992000798087",
+        "liquidityNet": "42955992000798087",
+        "tickIdx": "193510"
+      },
+      {
+        "liquidityGross": "157444189101167",
+        "liquidityNet": "-157444189101167",
+        "tickIdx": "193530"
+      },
+      {
+        "liquidityGross": "47606322110504996",
+        "liquidityNet": "47606128524366704",
+        "tickIdx": "193550"
+      },
+      {
+        "liquidityGross": "121196699469522",
+        "liquidityNet": "-51191301283478",
+        "tickIdx": "193560"
+      },
+      {
+        "liquidityGross": "417345483866658",
+        "liquidityNet": "417345483866658",
+        "tickIdx": "193570"
+      },
+      {
+        "liquidityGross": "407959931472533",
+        "liquidityNet": "49519250864937",
+        "tickIdx": "193580"
+      },
+      {
+        "liquidityGross": "242747533382",
+        "liquidityNet": "242747533382",
+        "tickIdx": "193590"
+      },
+      {
+        "liquidityGross": "420415578427063",
+        "liquidityNet": "-414275389306253",
+        "tickIdx": "193600"
+      },
+      {
+        "liquidityGross": "242747533382",
+        "liquidityNet": "-242747533382",
+        "tickIdx": "193610"
+      },
+      {
+        "liquidityGross": "4859155000976252",
+        "liquidityNet": "4859155000976252",
+        "tickIdx": "193620"
+      },
+      {
+        "liquidityGross": "317413508691314756",
+        "liquidityNet": "-1628492552956600",
+        "tickIdx": "193630"
+      },
+      {
+        "liquidityGross": "136596602628109",
+        "liquidityNet": "136596602628109",
+        "tickIdx": "193640"
+      },
+      {
+        "liquidityGross": "2394724731721493",
+        "liquidityNet": "2394724731721493",
+        "tickIdx": "193650"
+      },
+      {
+        "liquidityGross": "4868691739636577",
+        "liquidityNet": "-4850758465151173",
+        "tickIdx": "193660"
+      },
+      {
+        "liquidityGross": "6452158211314424",
+        "liquidityNet": "1662708747871438",
+        "tickIdx": "193670"
+      },
+      {
+        "liquidityGross": "22667950560196",
+        "liquidityNet": "22667950560196",
+        "tickIdx": "193690"
+      },
+      {
+        "liquidityGross": "344791059519915",
+        "liquidityNet": "344791059519915",
+        "tickIdx": "193700"
+      },
+      {
+        "liquidityGross": "125610818733604",
+        "liquidityNet": "125610818733604",
+        "tickIdx": "193720"
+      },
+      {
+        "liquidityGross": "632590114305662682",
+        "liquidityNet": "80687310874539236",
+        "tickIdx": "193730"
+      },
+      {
+        "liquidityGross": "226186835976284",
+        "liquidityNet": "226186835976284",
+        "tickIdx": "193740"
+      },
+      {
+        "liquidityGross": "9725248577948995",
+        "liquidityNet": "9676773964672429",
+        "tickIdx": "193760"
+      },
+      {
+        "liquidityGross": "3566136291796822",
+        "liquidityNet": "3536195670906446",
+        "tickIdx": "193770"
+      },
+      {
+        "liquidityGross": "31261767755462026",
+        "liquidityNet": "31261767755462026",
+        "tickIdx": "193790"
+      },
+      {
+        "liquidityGross": "49812768593933178",
+        "liquidityNet": "49806628404812368",
+        "tickIdx": "193800"
+      },
+      {
+        "liquidityGross": "75757380418552555",
+        "liquidityNet": "-75677653807476697",
+        "tickIdx": "193810"
+      },
+      {
+        "liquidityGross": "1042789587533",
+        "liquidityNet": "1042789587533",
+        "tickIdx": "193820"
+      },
+      {
+        "liquidityGross": "87376519580629",
+        "liquidityNet": "87376519580629",
+        "tickIdx": "193830"
+      },
+      {
+        "liquidityGross": "867536577382586",
+        "liquidityNet": "867447659653344",
+        "tickIdx": "193840"
+      },
+      {
+        "liquidityGross": "2950851383647149",
+        "liquidityNet": "-2950851383647149",
+        "tickIdx": "193850"
+      },
+      {
+        "liquidityGross": "40480786716227",
+        "liquidityNet": "40480786716227",
+        "tickIdx": "193860"
+      },
+      {
+        "liquidityGross": "9310357830628273",
+        "liquidityNet": "9310357830628273",
+        "tickIdx": "193870"
+      },
+      {
+        "liquidityGross": "17878397051958011",
+        "liquidityNet": "17446010840240743",
+        "tickIdx": "193890"
+      },
+      {
+        "liquidityGross": "2293631420519",
+        "liquidityNet": "2293631420519",
+        "tickIdx": "193900"
+      },
+      {
+        "liquidityGross": "9074419579393601",
+        "liquidityNet": "9074419579393601",
+        "tickIdx": "193910"
+      },
+      {
+        "liquidityGross": "26576634957459596",
+        "liquidityNet": "19106053362174780",
+        "tickIdx": "193920"
+      },
+      {
+        "liquidityGross": "361191804280492806",
+        "liquidityNet": "-352068727411541916",
+        "tickIdx": "193930"
+      },
+      {
+        "liquidityGross": "23209715064024249",
+        "liquidityNet": "-23209715064024249",
+        "tickIdx": "193940"
+      },
+      {
+        "liquidityGross": "841184624190776",
+        "liquidityNet": "-410332675601246",
+        "tickIdx": "193950"
+      },
+      {
+        "liquidityGross": "14565145856286956",
+        "liquidityNet": "12762712641222638",
+        "tickIdx": "193960"
+      },
+      {
+        "liquidityGross": "4453966892131775",
+        "liquidityNet": "-4117009959750143",
+        "tickIdx": "193970"
+      },
+      {
+        "liquidityGross": "7357439952526224",
+        "liquidityNet": "-6250767265634002",
+        "tickIdx": "193980"
+      },
+      {
+        "liquidityGross": "250040995586732",
+        "liquidityNet": "-250040995586732",
+        "tickIdx": "193990"
+      },
+      {
+        "liquidityGross": "677643596519793",
+        "liquidityNet": "-443409637318285",
+        "tickIdx": "194000"
+      },
+      {
+        "liquidityGross": "5549535135892810",
+        "liquidityNet": "5549535135892810",
+        "tickIdx": "194010"
+      },
+      {
+        "liquidityGross": "5401316418748966",
+        "liquidityNet": "-5171938342261516",
+        "tickIdx": "194020"
+      },
+      {
+        "liquidityGross": "2725854934748987",
+        "liquidityNet": "-2691114024433829",
+        "tickIdx": "194040"
+      },
+      {
+        "liquidityGross": "145218161656030047",
+        "liquidityNet": "145218161656030047",
+        "tickIdx": "194050"
+      },
+      {
+        "liquidityGross": "98448842312524",
+        "liquidityNet": "98448842312524",
+        "tickIdx": "194060"
+      },
+      {
+        "liquidityGross": "2214490701118183",
+        "liquidityNet": "2214490701118183",
+        "tickIdx": "194080"
+      },
+      {
+        "liquidityGross": "1706418892245099",
+        "liquidityNet": "-1091108088802047",
+        "tickIdx": "194090"
+      },
+      {
+        "liquidityGross": "2210946742086708",
+        "liquidityNet": "2210946742086708",
+        "tickIdx": "194100"
+      },
+      {
+        "liquidityGross": "305739459182454",
+        "liquidityNet": "-302660204535608",
+        "tickIdx": "194110"
+      },
+      {
+        "liquidityGross": "181093154008826",
+        "liquidityNet": "181004700396468",
+        "tickIdx": "194120"
+      },
+      {
+        "liquidityGross": "193670774978291",
+        "liquidityNet": "170314308516753",
+        "tickIdx": "194130"
+      },
+      {
+        "liquidityGross": "6746441018719522",
+        "liquidityNet": "6746441018719522",
+        "tickIdx": "194150"
+      },
+      {
+        "liquidityGross": "121710161972821833",
+        "liquidityNet": "-102339681901280997",
+        "tickIdx": "194160"
+      },
+      {
+        "liquidityGross": "21872094733693698",
+        "liquidityNet": "21872094733693698",
+        "tickIdx": "194170"
+      },
+      {
+        "liquidityGross": "127333777645379",
+        "liquidityNet": "127333777645379",
+        "tickIdx": "194180"
+      },
+      {
+        "liquidityGross": "1561086980580963",
+        "liquidityNet": "1372383597911125",
+        "tickIdx": "194190"
+      },
+      {
+        "liquidityGross": "249822638772157",
+        "liquidityNet": "-7058490999421",
+        "tickIdx": "194200"
+      },
+      {
+        "liquidityGross": "207033217830177",
+        "liquidityNet": "-35730929942559",
+        "tickIdx": "194210"
+      },
+      {
+        "liquidityGross": "49023756978848650",
+        "liquidityNet": "-48772735110768260",
+        "tickIdx": "194220"
+      },
+      {
+        "liquidityGross": "231812215555162",
+        "liquidityNet": "227114650310992",
+        "tickIdx": "194230"
+      },
+      {
+        "liquidityGross": "22743496170389",
+        "liquidityNet": "22385491048117",
+        "tickIdx": "194250"
+      },
+      {
+        "liquidityGross": "86424360320",
+        "liquidityNet": "86424360320",
+        "tickIdx": "194260"
+      },
+      {
+        "liquidityGross": "28035277881941",
+        "liquidityNet": "28035277881941",
+        "tickIdx": "194270"
+      },
+      {
+        "liquidityGross": "37434213071258",
+        "liquidityNet": "37434213071258",
+        "tickIdx": "194290"
+      },
+      {
+        "liquidityGross": "2127972363979292070",
+        "liquidityNet": "-2126758066958248254",
+        "tickIdx": "194300"
+      },
+      {
+        "liquidityGross": "8802293757967902",
+        "liquidityNet": "-8802293757967902",
+        "tickIdx": "194320"
+      },
+      {
+        "liquidityGross": "31897551758233076",
+        "liquidityNet": "7642153822967488",
+        "tickIdx": "194330"
+      },
+      {
+        "liquidityGross": "22699931112537341",
+        "liquidityNet": "22060705428192517",
+        "tickIdx": "194340"
+      },
+      {
+        "liquidityGross": "4503319968258673",
+        "liquidityNet": "-4496257908623191",
+        "tickIdx": "194350"
+      },
+      {
+        "liquidityGross": "10557881185873389",
+        "liquidityNet": "-9427347947305839",
+        "tickIdx": "194360"
+      },
+      {
+        "liquidityGross": "16450924918597403",
+        "liquidityNet": "-16253233826101765",
+        "tickIdx": "194370"
+      },
+      {
+        "liquidityGross": "2309533305757",
+        "liquidityNet": "-2112911638423",
+        "tickIdx": "194380"
+      },
+      {
+        "liquidityGross": "328470755555",
+        "liquidityNet": "-328470755555",
+        "tickIdx": "194410"
+      },
+      {
+        "liquidityGross": "1058737597323737",
+        "liquidityNet": "1058737597323737",
+        "tickIdx": "194420"
+      },
+      {
+        "liquidityGross": "295065388302142807",
+        "liquidityNet": "-283560318447319017",
+        "tickIdx": "194430"
+      },
+      {
+        "liquidityGross": "797943156680418",
+        "liquidityNet": "797943156680418",
+        "tickIdx": "194440"
+      },
+      {
+        "liquidityGross": "7725206018967450",
+        "liquidityNet": "7725206018967450",
+        "tickIdx": "194460"
+      },
+      {
+        "liquidityGross": "6881699369420418",
+        "liquidityNet": "-6881699369420418",
+        "tickIdx": "194470"
+      },
+      {
+        "liquidityGross": "109307854712499",
+        "liquidityNet": "-109307854712499",
+        "tickIdx": "194480"
+      },
+      {
+        "liquidityGross": "11467664755379124",
+        "liquidityNet": "-8540521363237642",
+        "tickIdx": "194510"
+      },
+      {
+        "liquidityGross": "721985335123383",
+        "liquidityNet": "721985335123383",
+        "tickIdx": "194520"
+      },
+      {
+        "liquidityGross": "29111337789713",
+        "liquidityNet": "-29111337789713",
+        "tickIdx": "194540"
+      },
+      {
+        "liquidityGross": "145192447673490260",
+        "liquidityNet": "-145190552984840610",
+        "tickIdx": "194550"
+      },
+      {
+        "liquidityGross": "8276668785909773",
+        "liquidityNet": "6570232914150011",
+        "tickIdx": "194580"
+      },
+      {
+        "liquidityGross": "410217889251662",
+        "liquidityNet": "410217889251662",
+        "tickIdx": "194590"
+      },
+      {
+        "liquidityGross": "1868877087942040",
+        "liquidityNet": "377986830376374",
+        "tickIdx": "194600"
+      },
+      {
+        "liquidityGross": "5967819685",
+        "liquidityNet": "5967819685",
+        "tickIdx": "194610"
+      },
+      {
+        "liquidityGross": "731786621768846",
+        "liquidityNet": "731786621768846",
+        "tickIdx": "194620"
+      },
+      {
+        "liquidityGross": "1121321520802207",
+        "liquidityNet": "-1121321520802207",
+        "tickIdx": "194630"
+      },
+      {
+        "liquidityGross": "1398093104964209",
+        "liquidityNet": "1079727194200987",
+        "tickIdx": "194640"
+      },
+      {
+        "liquidityGross": "148711110339063547",
+        "liquidityNet": "148711110339063547",
+        "tickIdx": "194650"
+      },
+      {
+        "liquidityGross": "976099557108",
+        "liquidityNet": "976099557108",
+        "tickIdx": "194660"
+      },
+      {
+        "liquidityGross": "106775541336400",
+        "liquidityNet": "106775541336400",
+        "tickIdx": "194670"
+      },
+      {
+        "liquidityGross": "152716660181441580",
+        "liquidityNet": "-144705560496685514",
+        "tickIdx": "194680"
+      },
+      {
+        "liquidityGross": "3379113631709",
+        "liquidityNet": "-3375735340417",
+        "tickIdx": "194690"
+      },
+      {
+        "liquidityGross": "1231989327617959",
+        "liquidityNet": "1224867245277571",
+        "tickIdx": "194700"
+      },
+      {
+        "liquidityGross": "145983268278519745",
+        "liquidityNet": "42806525257086003",
+        "tickIdx": "194710"
+      },
+      {
+        "liquidityGross": "9411837024782150",
+        "liquidityNet": "-9322664585430136",
+        "tickIdx": "194720"
+      },
+      {
+        "liquidityGross": "21262317876105093",
+        "liquidityNet": "10386192166502115",
+        "tickIdx": "194730"
+      },
+      {
+        "liquidityGross": "1436303551823626",
+        "liquidityNet": "1139674486633280",
+        "tickIdx": "194740"
+      },
+      {
+        "liquidityGross": "17233111237378877",
+        "liquidityNet": "-17233111237378877",
+        "tickIdx": "194750"
+      },
+      {
+        "liquidityGross": "3495033047444402",
+        "liquidityNet": "3482793727126212",
+        "tickIdx": "194760"
+      },
+      {
+        "liquidityGross": "42087797692117418",
+        "liquidityNet": "-42087797692117418",
+        "tickIdx": "194770"
+      },
+      {
+        "liquidityGross": "3734920884483280",
+        "liquidityNet": "-3538961023559730",
+        "tickIdx": "194780"
+      },
+      {
+        "liquidityGross": "17615571132218677",
+        "liquidityNet": "17615278098816611",
+        "tickIdx": "194790"
+      },
+      {
+        "liquidityGross": "7326149028989228",
+        "liquidityNet": "-7301626851550666",
+        "tickIdx": "194800"
+      },
+      {
+        "liquidityGross": "1198884537287",
+        "liquidityNet": "1198884537287",
+        "tickIdx": "194810"
+      },
+      {
+        "liquidityGross": "5471426786188891",
+        "liquidityNet": "-5468120091862623",
+        "tickIdx": "194820"
+      },
+      {
+        "liquidityGross": "533647820144747",
+        "liquidityNet": "195665307069921",
+        "tickIdx": "194830"
+      },
+      {
+        "liquidityGross": "668492964118805",
+        "liquidityNet": "-665829839802697",
+        "tickIdx": "194840"
+      },
+      {
+        "liquidityGross": "5102194722791571",
+        "liquidityNet": "-4942802848299371",
+        "tickIdx": "194850"
+      },
+      {
+        "liquidityGross": "32567901191946908",
+        "liquidityNet": "-3697734260593614",
+        "tickIdx": "194860"
+      },
+      {
+        "liquidityGross": "287898898889255",
+        "liquidityNet": "287898898889255",
+        "tickIdx": "194880"
+      },
+      {
+        "liquidityGross": "777118296410956",
+        "liquidityNet": "777118296410956",
+        "tickIdx": "194890"
+      },
+      {
+        "liquidityGross": "749676584791462",
+        "liquidityNet": "228341313864990",
+        "tickIdx": "194900"
+      },
+      {
+        "liquidityGross": "1102108361237794",
+        "liquidityNet": "-1029688301426864",
+        "tickIdx": "194910"
+      },
+      {
+        "liquidityGross": "21992200782322085",
+        "liquidityNet": "-21928990817041895",
+        "tickIdx": "194920"
+      },
+      {
+        "liquidityGross": "379597816286835",
+        "liquidityNet": "-309460113585833",
+        "tickIdx": "194930"
+      },
+      {
+        "liquidityGross": "3231998783247424",
+        "liquidityNet": "2077076889976162",
+        "tickIdx": "194940"
+      },
+      {
+        "liquidityGross": "1706669631022847",
+        "liquidityNet": "669113382061557",
+        "tickIdx": "194950"
+      },
+      {
+        "liquidityGross": "1328439979490704",
+        "liquidityNet": "-1328439979490704",
+        "tickIdx": "194960"
+      },
+      {
+        "liquidityGross": "6163535262852007",
+        "liquidityNet": "5860387846202263",
+        "tickIdx": "194970"
+      },
+      {
+        "liquidityGross": "7751822118392542",
+        "liquidityNet": "7751822118392542",
+        "tickIdx": "194980"
+      },
+      {
+        "liquidityGross": "6189175686659133",
+        "liquidityNet": "-6137869534903127",
+        "tickIdx": "194990"
+      },
+      {
+        "liquidityGross": "62383734670543453",
+        "liquidityNet": "-50389023348302991",
+        "tickIdx": "195000"
+      },
+      {
+        "liquidityGross": "130382076002772",
+        "liquidityNet": "79076664559610",
+        "tickIdx": "195010"
+      },
+      {
+        "liquidityGross": "65811208806635",
+        "liquidityNet": "55706007896799",
+        "tickIdx": "195020"
+      },
+      {
+        "liquidityGross": "801050594414955",
+        "liquidityNet": "63890163133653",
+        "tickIdx": "195030"
+      },
+      {
+        "liquidityGross": "353365647023931",
+        "liquidityNet": "-304458670649623",
+        "tickIdx": "195040"
+      },
+      {
+        "liquidityGross": "554871592058969",
+        "liquidityNet": "554871592058969",
+        "tickIdx": "195060"
+      },
+      {
+        "liquidityGross": "239561679378639",
+        "liquidityNet": "-189656584407909",
+        "tickIdx": "195070"
+      },
+      {
+        "liquidityGross": "2642135399259336",
+        "liquidityNet": "1533469494789578",
+        "tickIdx": "195080"
+      },
+      {
+        "liquidityGross": "157651679368360",
+        "liquidityNet": "107746584397630",
+        "tickIdx": "195090"
+      },
+      {
+        "liquidityGross": "497608501220997",
+        "liquidityNet": "339824763520819",
+        "tickIdx": "195100"
+      },
+      {
+        "liquidityGross": "218847746587640",
+        "liquidityNet": "-218847746587640",
+        "tickIdx": "195110"
+      },
+      {
+        "liquidityGross": "521465510876795",
+        "liquidityNet": "-112839649712551",
+        "tickIdx": "195120"
+      },
+      {
+        "liquidityGross": "3562032315123960",
+        "liquidityNet": "-3486121299590568",
+        "tickIdx": "195130"
+      },
+      {
+        "liquidityGross": "8620067529743194",
+        "liquidityNet": "-8300737189398700",
+        "tickIdx": "195140"
+      },
+      {
+        "liquidityGross": "1969726067396043",
+        "liquidityNet": "-1895451115356987",
+        "tickIdx": "195150"
+      },
+      {
+        "liquidityGross": "166415588589761",
+        "liquidityNet": "166415588589761",
+        "tickIdx": "195170"
+      },
+      {
+        "liquidityGross": "301354185193029",
+        "liquidityNet": "-294790772633901",
+        "tickIdx": "195180"
+      },
+      {
+        "liquidityGross": "835618767945628",
+        "liquidityNet": "551258275208008",
+        "tickIdx": "195190"
+      },
+      {
+        "liquidityGross": "281983275787761",
+        "liquidityNet": "164477651356407",
+        "tickIdx": "195200"
+      },
+      {
+        "liquidityGross": "2785453550",
+        "liquidityNet": "-219252698",
+        "tickIdx": "195210"
+      },
+      {
+        "liquidityGross": "16791340254466096",
+        "liquidityNet": "-16791310816433502",
+        "tickIdx": "195220"
+      },
+      {
+        "liquidityGross": "11739803578594",
+        "liquidityNet": "11739803578594",
+        "tickIdx": "195230"
+      },
+      {
+        "liquidityGross": "15037593904798",
+        "liquidityNet": "9244535614860",
+        "tickIdx": "195240"
+      },
+      {
+        "liquidityGross": "726147743046008",
+        "liquidityNet": "-25784032552266",
+        "tickIdx": "195250"
+      },
+      {
+        "liquidityGross": "15982241300548",
+        "liquidityNet": "15982241300548",
+        "tickIdx": "195260"
+      },
+      {
+        "liquidityGross": "345436228075577",
+        "liquidityNet": "-345436228075577",
+        "tickIdx": "195270"
+      },
+      {
+        "liquidityGross": "138804658880979",
+        "liquidityNet": "138804658880979",
+        "tickIdx": "195280"
+      },
+      {
+        "liquidityGross": "3280123399829202",
+        "liquidityNet": "-1937970290003954",
+        "tickIdx": "195300"
+      },
+      {
+        "liquidityGross": "21524965643282282",
+        "liquidityNet": "-21519444070932032",
+        "tickIdx": "195310"
+      },
+      {
+        "liquidityGross": "86097340223425",
+        "liquidityNet": "-71769617341295",
+        "tickIdx": "195320"
+      },
+      {
+        "liquidityGross": "50869624045273",
+        "liquidityNet": "50869624045273",
+        "tickIdx": "195330"
+      },
+      {
+        "liquidityGross": "8259866769861514",
+        "liquidityNet": "-7493107812492592",
+        "tickIdx": "195340"
+      },
+      {
+        "liquidityGross": "845852564343001",
+        "liquidityNet": "-731281869136289",
+        "tickIdx": "195350"
+      },
+      {
+        "liquidityGross": "95886477319952",
+        "liquidityNet": "-56919965067774",
+        "tickIdx": "195360"
+      },
+      {
+        "liquidityGross": "3737381698644229",
+        "liquidityNet": "-3412356995725307",
+        "tickIdx": "195370"
+      },
+      {
+        "liquidityGross": "104",
+        "liquidityNet": "104",
+        "tickIdx": "195380"
+      },
+      {
+        "liquidityGross": "1497090499851427",
+        "liquidityNet": "-1497090499851427",
+        "tickIdx": "195390"
+      },
+      {
+        "liquidityGross": "43243771706278",
+        "liquidityNet": "43028994596340",
+        "tickIdx": "195400"
+      },
+      {
+        "liquidityGross": "1469886243943053",
+        "liquidityNet": "-1469886243943053",
+        "tickIdx": "195410"
+      },
+      {
+        "liquidityGross": "34686419853248",
+        "liquidityNet": "34686419853248",
+        "tickIdx": "195420"
+      },
+      {
+        "liquidityGross": "28404473623411",
+        "liquidityNet": "-28404473623409",
+        "tickIdx": "195430"
+      },
+      {
+        "liquidityGross": "18177644570729552",
+        "liquidityNet": "-18013437905850010",
+        "tickIdx": "195440"
+      },
+      {
+        "liquidityGross": "98203969114105",
+        "liquidityNet": "98203969114105",
+        "tickIdx": "195450"
+      },
+      {
+        "liquidityGross": "1956783970846076",
+        "liquidityNet": "1045070554901222",
+        "tickIdx": "195460"
+      },
+      {
+        "liquidityGross": "4045975464481646",
+        "liquidityNet": "-4040590517588726",
+        "tickIdx": "195470"
+      },
+      {
+        "liquidityGross": "5551264933274766",
+        "liquidityNet": "5020870184962128",
+        "tickIdx": "195480"
+      },
+      {
+        "liquidityGross": "185074376099300622",
+        "liquidityNet": "-184637437819056102",
+        "tickIdx": "195490"
+      },
+      {
+        "liquidityGross": "95851646643317",
+        "liquidityNet": "-95851646643317",
+        "tickIdx": "195510"
+      },
+      {
+        "liquidityGross": "5526124578130548",
+        "liquidityNet": "-5264960535011316",
+        "tickIdx": "195520"
+      },
+      {
+        "liquidityGross": "24235385745561",
+        "liquidityNet": "-24235298696341",
+        "tickIdx": "195530"
+      },
+      {
+        "liquidityGross": "550442191879715",
+        "liquidityNet": "-550442191879715",
+        "tickIdx": "195540"
+      },
+      {
+        "liquidityGross": "2339352726316019",
+        "liquidityNet": "650765631333407",
+        "tickIdx": "195550"
+      },
+      {
+        "liquidityGross": "21774841572416",
+        "liquidityNet": "-17191670679762",
+        "tickIdx": "195560"
+      },
+      {
+        "liquidityGross": "16315284266143027",
+        "liquidityNet": "13293650409287509",
+        "tickIdx": "195570"
+      },
+      {
+        "liquidityGross": "9672322328918",
+        "liquidityNet": "9672322328918",
+        "tickIdx": "195580"
+      },
+      {
+        "liquidityGross": "16932070870753993",
+        "liquidityNet": "-16154833820723031",
+        "tickIdx": "195590"
+      },
+      {
+        "liquidityGross": "3471003246113374",
+        "liquidityNet": "-3379199545946558",
+        "tickIdx": "195600"
+      },
+      {
+        "liquidityGross": "140698396495948661",
+        "liquidityNet": "-132739323543379259",
+        "tickIdx": "195610"
+      },
+      {
+        "liquidityGross": "42679098236154",
+        "liquidityNet": "42679098236154",
+        "tickIdx": "195620"
+      },
+      {
+        "liquidityGross": "340629175831289",
+        "liquidityNet": "340629175831289",
+        "tickIdx": "195630"
+      },
+      {
+        "liquidityGross": "128407175107591",
+        "liquidityNet": "90919265604651",
+        "tickIdx": "195640"
+      },
+      {
+        "liquidityGross": "693045906459317",
+        "liquidityNet": "-693045906459317",
+        "tickIdx": "195650"
+      },
+      {
+        "liquidityGross": "577880200972866",
+        "liquidityNet": "567060156698400",
+        "tickIdx": "195660"
+      },
+      {
+        "liquidityGross": "3123686879969953",
+        "liquidityNet": "-2715982272976903",
+        "tickIdx": "195670"
+      },
+      {
+        "liquidityGross": "4116513586312119",
+        "liquidityNet": "-1192562086911467",
+        "tickIdx": "195680"
+      },
+      {
+        "liquidityGross": "726286635955495",
+        "liquidityNet": "-724660478811199",
+        "tickIdx": "195690"
+      },
+      {
+        "liquidityGross": "202830781947565",
+        "liquidityNet": "-201739572244771",
+        "tickIdx": "195700"
+      },
+      {
+        "liquidityGross": "44246660914013",
+        "liquidityNet": "-42837753572237",
+        "tickIdx": "195710"
+      },
+      {
+        "liquidityGross": "4838970710323049",
+        "liquidityNet": "3773059564357871",
+        "tickIdx": "195720"
+      },
+      {
+        "liquidityGross": "11267318795029853",
+        "liquidityNet": "11232163953430129",
+        "tickIdx": "195730"
+      },
+      {
+        "liquidityGross": "1691898914474789",
+        "liquidityNet": "1081912999085273",
+        "tickIdx": "195740"
+      },
+      {
+        "liquidityGross": "9043955485486811",
+        "liquidityNet": "248973146536755",
+        "tickIdx": "195750"
+      },
+      {
+        "liquidityGross": "2433374422260672",
+        "liquidityNet": "-1463744358332242",
+        "tickIdx": "195760"
+      },
+      {
+        "liquidityGross": "377261308026711",
+        "liquidityNet": "100556077660499",
+        "tickIdx": "195770"
+      },
+      {
+        "liquidityGross": "1688492946991114",
+        "liquidityNet": "1688492946991114",
+        "tickIdx": "195780"
+      },
+      {
+        "liquidityGross": "99956798298015",
+        "liquidityNet": "5269229259151",
+        "tickIdx": "195790"
+      },
+      {
+        "liquidityGross": "144830091984295",
+        "liquidityNet": "-127211881132285",
+        "tickIdx": "195800"
+      },
+      {
+        "liquidityGross": "5103041597563",
+        "liquidityNet": "-5103041597563",
+        "tickIdx": "195810"
+      },
+      {
+        "liquidityGross": "866522758531286",
+        "liquidityNet": "827459009295636",
+        "tickIdx": "195820"
+      },
+      {
+        "liquidityGross": "23741056796135",
+        "liquidityNet": "-22701751625559",
+        "tickIdx": "195830"
+      },
+      {
+        "liquidityGross": "850447098745378",
+        "liquidityNet": "-850447098745378",
+        "tickIdx": "195840"
+      },
+      {
+        "liquidityGross": "666237705107126",
+        "liquidityNet": "666237705107126",
+        "tickIdx": "195850"
+      },
+      {
+        "liquidityGross": "14282369320847781",
+        "liquidityNet": "-14263396463827633",
+        "tickIdx": "195860"
+      },
+      {
+        "liquidityGross": "559822598969316",
+        "liquidityNet": "-559822598969316",
+        "tickIdx": "195870"
+      },
+      {
+        "liquidityGross": "2596369185381570",
+        "liquidityNet": "-2067194179044762",
+        "tickIdx": "195880"
+      },
+      {
+        "liquidityGross": "14067997442399905",
+        "liquidityNet": "14067997442399905",
+        "tickIdx": "195890"
+      },
+      {
+        "liquidityGross": "246502283733188",
+        "liquidityNet": "-246502283733188",
+        "tickIdx": "195900"
+      },
+      {
+        "liquidityGross": "14125441610029806",
+        "liquidityNet": "-14051275875585176",
+        "tickIdx": "195910"
+      },
+      {
+        "liquidityGross": "3212332789961123",
+        "liquidityNet": "-2860221146779857",
+        "tickIdx": "195920"
+      },
+      {
+        "liquidityGross": "101564833960451310",
+        "liquidityNet": "-93610427734633410",
+        "tickIdx": "195930"
+      },
+      {
+        "liquidityGross": "192540858111049",
+        "liquidityNet": "-161643181407093",
+        "tickIdx": "195940"
+      },
+      {
+        "liquidityGross": "6922023355328583",
+        "liquidityNet": "-2709659288287603",
+        "tickIdx": "195960"
+      },
+      {
+        "liquidityGross": "2358021752359031",
+        "liquidityNet": "-2358021752359031",
+        "tickIdx": "195970"
+      },
+      {
+        "liquidityGross": "53411062339964950",
+        "liquidityNet": "53411062339964950",
+        "tickIdx": "195980"
+      },
+      {
+        "liquidityGross": "2112913161804976",
+        "liquidityNet": "-2112913161804976",
+        "tickIdx": "195990"
+      },
+      {
+        "liquidityGross": "532556565680575",
+        "liquidityNet": "393152588963657",
+        "tickIdx": "196000"
+      },
+      {
+        "liquidityGross": "475866251593538",
+        "liquidityNet": "-420244135655232",
+        "tickIdx": "196010"
+      },
+      {
+        "liquidityGross": "15038253251540",
+        "liquidityNet": "-15038253251540",
+        "tickIdx": "196020"
+      },
+      {
+        "liquidityGross": "11924599853319737",
+        "liquidityNet": "11849122343021879",
+        "tickIdx": "196030"
+      },
+      {
+        "liquidityGross": "3104434641388878",
+        "liquidityNet": "2920714493200402",
+        "tickIdx": "196040"
+      },
+      {
+        "liquidityGross": "95276543486891",
+        "liquidityNet": "-47835751939683",
+        "tickIdx": "196050"
+      },
+      {
+        "liquidityGross": "514824455657949",
+        "liquidityNet": "-514782249692785",
+        "tickIdx": "196060"
+      },
+      {
+        "liquidityGross": "3035697820647806",
+        "liquidityNet": "-2989451313941474",
+        "tickIdx": "196070"
+      },
+      {
+        "liquidityGross": "235123048042298",
+        "liquidityNet": "235123048042298",
+        "tickIdx": "196080"
+      },
+      {
+        "liquidityGross": "11714823457488200",
+        "liquidityNet": "-11659394408635524",
+        "tickIdx": "196090"
+      },
+      {
+        "liquidityGross": "233864248362155",
+        "liquidityNet": "-233864248362155",
+        "tickIdx": "196100"
+      },
+      {
+        "liquidityGross": "1565522914726323",
+        "liquidityNet": "-1565522914726323",
+        "tickIdx": "196110"
+      },
+      {
+        "liquidityGross": "326862961907812",
+        "liquidityNet": "-284938284188386",
+        "tickIdx": "196120"
+      },
+      {
+        "liquidityGross": "549844851488473",
+        "liquidityNet": "549844851488473",
+        "tickIdx": "196130"
+      },
+      {
+        "liquidityGross": "283035103384236",
+        "liquidityNet": "281314102169220",
+        "tickIdx": "196140"
+      },
+      {
+        "liquidityGross": "566032523879",
+        "liquidityNet": "566032523879",
+        "tickIdx": "196150"
+      },
+      {
+        "liquidityGross": "8940588416029748",
+        "liquidityNet": "8567910539159240",
+        "tickIdx": "196160"
+      },
+      {
+        "liquidityGross": "5984457381009316245",
+        "liquidityNet": "5984456248944268485",
+        "tickIdx": "196170"
+      },
+      {
+        "liquidityGross": "4373379595919090",
+        "liquidityNet": "-4373379595919090",
+        "tickIdx": "196180"
+      },
+      {
+        "liquidityGross": "1561230648704025",
+        "liquidityNet": "1443176916637037",
+        "tickIdx": "196190"
+      },
+      {
+        "liquidityGross": "39387742054624",
+        "liquidityNet": "-4888017823148",
+        "tickIdx": "196210"
+      },
+      {
+        "liquidityGross": "4387101703966528",
+        "liquidityNet": "-3986361749705394",
+        "tickIdx": "196220"
+      },
+      {
+        "liquidityGross": "89680474069800",
+        "liquidityNet": "-89680474069800",
+        "tickIdx": "196230"
+      },
+      {
+        "liquidityGross": "271986028734444",
+        "liquidityNet": "-270320681154154",
+        "tickIdx": "196240"
+      },
+      {
+        "liquidityGross": "6022981081053365645",
+        "liquidityNet": "-6015260464613004827",
+        "tickIdx": "196250"
+      },
+      {
+        "liquidityGross": "36804521623326101",
+        "liquidityNet": "-27179125210385857",
+        "tickIdx": "196260"
+      },
+      {
+        "liquidityGross": "257949016690013",
+        "liquidityNet": "257949016690013",
+        "tickIdx": "196270"
+      },
+      {
+        "liquidityGross": "166878451509232",
+        "liquidityNet": "101905185407398",
+        "tickIdx": "196280"
+      },
+      {
+        "liquidityGross": "8171423444647946",
+        "liquidityNet": "-8171423444647946",
+        "tickIdx": "196290"
+      },
+      {
+        "liquidityGross": "2673159211186683",
+        "liquidityNet": "689135043172139",
+        "tickIdx": "196300"
+      },
+      {
+        "liquidityGross": "87382429264762895",
+        "liquidityNet": "-87368272458705571",
+        "tickIdx": "196310"
+      },
+      {
+        "liquidityGross": "97076682180177",
+        "liquidityNet": "41675997802499",
+        "tickIdx": "196320"
+      },
+      {
+        "liquidityGross": "22733481403940",
+        "liquidityNet": "-16206919685890",
+        "tickIdx": "196330"
+      },
+      {
+        "liquidityGross": "4367656344637662",
+        "liquidityNet": "4148822298350800",
+        "tickIdx": "196340"
+      },
+      {
+        "liquidityGross": "7410015718579",
+        "liquidityNet": "-7410015718579",
+        "tickIdx": "196350"
+      },
+      {
+        "liquidityGross": "44279667104524",
+        "liquidityNet": "-44279667104524",
+        "tickIdx": "196360"
+      },
+      {
+        "liquidityGross": "68967570782517",
+        "liquidityNet": "68967570782517",
+        "tickIdx": "196380"
+      },
+      {
+        "liquidityGross": "291722824867927",
+        "liquidityNet": "291722824867927",
+        "tickIdx": "196390"
+      },
+      {
+        "liquidityGross": "260086033023964",
+        "liquidityNet": "260077680036104",
+        "tickIdx": "196400"
+      },
+      {
+        "liquidityGross": "1462609585977549",
+        "liquidityNet": "-1462609585977549",
+        "tickIdx": "196410"
+      },
+      {
+        "liquidityGross": "809017419016635",
+        "liquidityNet": "-475536618132393",
+        "tickIdx": "196420"
+      },
+      {
+        "liquidityGross": "319748840686051",
+        "liquidityNet": "319748840686051",
+        "tickIdx": "196430"
+      },
+      {
+        "liquidityGross": "174491192869730",
+        "liquidityNet": "-174491192869730",
+        "tickIdx": "196440"
+      },
+      {
+        "liquidityGross": "482660906287241",
+        "liquidityNet": "482660906287241",
+        "tickIdx": "196450"
+      },
+      {
+        "liquidityGross": "343465648843675",
+        "liquidityNet": "-296032032528427",
+        "tickIdx": "196460"
+      },
+      {
+        "liquidityGross": "2098100836320611",
+        "liquidityNet": "-2064473043840903",
+        "tickIdx": "196470"
+      },
+      {
+        "liquidityGross": "4796362650817581",
+        "liquidityNet": "-4796362650817581",
+        "tickIdx": "196480"
+      },
+      {
+        "liquidityGross": "6960089675509779",
+        "liquidityNet": "-6488441624916273",
+        "tickIdx": "196490"
+      },
+      {
+        "liquidityGross": "184913570239120",
+        "liquidityNet": "184913570239120",
+        "tickIdx": "196500"
+      },
+      {
+        "liquidityGross": "744191556419164",
+        "liquidityNet": "319528687331476",
+        "tickIdx": "196510"
+      },
+      {
+        "liquidityGross": "184913570239120",
+        "liquidityNet": "-184913570239120",
+        "tickIdx": "196520"
+      },
+      {
+        "liquidityGross": "524376830331701",
+        "liquidityNet": "-524376830331701",
+        "tickIdx": "196530"
+      },
+      {
+        "liquidityGross": "24897447029896",
+        "liquidityNet": "-4699291558494",
+        "tickIdx": "196540"
+      },
+      {
+        "liquidityGross": "66336695746741",
+        "liquidityNet": "-66336695746741",
+        "tickIdx": "196550"
+      },
+      {
+        "liquidityGross": "707259814897400",
+        "liquidityNet": "687061659425998",
+        "tickIdx": "196560"
+      },
+      {
+        "liquidityGross": "543089335630363",
+        "liquidityNet": "7334322586379",
+        "tickIdx": "196570"
+      },
+      {
+        "liquidityGross": "696421060987894",
+        "liquidityNet": "-696421060987894",
+        "tickIdx": "196580"
+      },
+      {
+        "liquidityGross": "329215603081793",
+        "liquidityNet": "-329215603081793",
+        "tickIdx": "196590"
+      },
+      {
+        "liquidityGross": "11238023438759517",
+        "liquidityNet": "-6166231016028537",
+        "tickIdx": "196600"
+      },
+      {
+        "liquidityGross": "102569392222601",
+        "liquidityNet": "-102569392222601",
+        "tickIdx": "196610"
+      },
+      {
+        "liquidityGross": "12671787221327446",
+        "liquidityNet": "-12671787221327446",
+        "tickIdx": "196620"
+      },
+      {
+        "liquidityGross": "9287209971783",
+        "liquidityNet": "7661052827487",
+        "tickIdx": "196630"
+      },
+      {
+        "liquidityGross": "3571023791649504",
+        "liquidityNet": "2074098602687062",
+        "tickIdx": "196640"
+      },
+      {
+        "liquidityGross": "87029620885755",
+        "liquidityNet": "-87029620885755",
+        "tickIdx": "196650"
+      },
+      {
+        "liquidityGross": "277416577183292",
+        "liquidityNet": "-277416577183292",
+        "tickIdx": "196660"
+      },
+      {
+        "liquidityGross": "232856053400657",
+        "liquidityNet": "-224623128936813",
+        "tickIdx": "196680"
+      },
+      {
+        "liquidityGross": "1990233101382540",
+        "liquidityNet": "-1990233101382540",
+        "tickIdx": "196690"
+      },
+      {
+        "liquidityGross": "477264930847132",
+        "liquidityNet": "477264930847132",
+        "tickIdx": "196710"
+      },
+      {
+        "liquidityGross": "459139595161597",
+        "liquidityNet": "243476404405577",
+        "tickIdx": "196720"
+      },
+      {
+        "liquidityGross": "68112018101153",
+        "liquidityNet": "68095834915471",
+        "tickIdx": "196730"
+      },
+      {
+        "liquidityGross": "4931510757581690",
+        "liquidityNet": "-4931510757581690",
+        "tickIdx": "196740"
+      },
+      {
+        "liquidityGross": "6041575136322",
+        "liquidityNet": "-6041575136322",
+        "tickIdx": "196750"
+      },
+      {
+        "liquidityGross": "272029515528899",
+        "liquidityNet": "-269925483913323",
+        "tickIdx": "196760"
+      },
+      {
+        "liquidityGross": "978379191344196",
+        "liquidityNet": "-528217314752672",
+        "tickIdx": "196770"
+      },
+      {
+        "liquidityGross": "888202507359966",
+        "liquidityNet": "882766914253146",
+        "tickIdx": "196780"
+      },
+      {
+        "liquidityGross": "1068877492597577",
+        "liquidityNet": "1063903322650331",
+        "tickIdx": "196790"
+      },
+      {
+        "liquidityGross": "1320579380532456",
+        "liquidityNet": "-1320579380532456",
+        "tickIdx": "196800"
+      },
+      {
+        "liquidityGross": "21951217748923232",
+        "liquidityNet": "20516980910172768",
+        "tickIdx": "196810"
+      },
+      {
+        "liquidityGross": "6745355184540147",
+        "liquidityNet": "6500333245063351",
+        "tickIdx": "196820"
+      },
+      {
+        "liquidityGross": "2876512332",
+        "liquidityNet": "-14334110",
+        "tickIdx": "196840"
+      },
+      {
+        "liquidityGross": "56882",
+        "liquidityNet": "56882",
+        "tickIdx": "196850"
+      },
+      {
+        "liquidityGross": "383169952298211",
+        "liquidityNet": "383169952298211",
+        "tickIdx": "196860"
+      },
+      {
+        "liquidityGross": "10135906124416",
+        "liquidityNet": "10135906124416",
+        "tickIdx": "196870"
+      },
+      {
+        "liquidityGross": "84985685677042",
+        "liquidityNet": "-62329623533738",
+        "tickIdx": "196880"
+      },
+      {
+        "liquidityGross": "2665836309138757",
+        "liquidityNet": "2660309908620727",
+        "tickIdx": "196900"
+      },
+      {
+        "liquidityGross": "821924047863187",
+        "liquidityNet": "-821190629432413",
+        "tickIdx": "196910"
+      },
+      {
+        "liquidityGross": "59208377072393",
+        "liquidityNet": "58484208444917",
+        "tickIdx": "196920"
+      },
+      {
+        "liquidityGross": "1460551175454691",
+        "liquidityNet": "-1460551175454691",
+        "tickIdx": "196930"
+      },
+      {
+        "liquidityGross": "601005627375049",
+        "liquidityNet": "531758138094093",
+        "tickIdx": "196940"
+      },
+      {
+        "liquidityGross": "13194736172637462",
+        "liquidityNet": "-12676721387145060",
+        "tickIdx": "196950"
+      },
+      {
+        "liquidityGross": "43873385375806",
+        "liquidityNet": "-43873385375806",
+        "tickIdx": "196960"
+      },
+      {
+        "liquidityGross": "2892145283978544",
+        "liquidityNet": "-789901818128012",
+        "tickIdx": "196970"
+      },
+      {
+        "liquidityGross": "3959478091923",
+        "liquidityNet": "3959478091923",
+        "tickIdx": "196980"
+      },
+      {
+        "liquidityGross": "1021411710393621",
+        "liquidityNet": "-1021236380313973",
+        "tickIdx": "196990"
+      },
+      {
+        "liquidityGross": "4787859316837343",
+        "liquidityNet": "4259862112063249",
+        "tickIdx": "197000"
+      },
+      {
+        "liquidityGross": "6987350609481000",
+        "liquidityNet": "-6986023507284608",
+        "tickIdx": "197010"
+      },
+      {
+        "liquidityGross": "11430936968622",
+        "liquidityNet": "-11430841448312",
+        "tickIdx": "197020"
+      },
+      {
+        "liquidityGross": "3988174290757047",
+        "liquidityNet": "1607497067360447",
+        "tickIdx": "197030"
+      },
+      {
+        "liquidityGross": "2050030463043466",
+        "liquidityNet": "2050030367523156",
+        "tickIdx": "197040"
+      },
+      {
+        "liquidityGross": "99794828693738",
+        "liquidityNet": "-85099879393624",
+        "tickIdx": "197050"
+      },
+      {
+        "liquidityGross": "30422510359689",
+        "liquidityNet": "30422423310469",
+        "tickIdx": "197060"
+      },
+      {
+        "liquidityGross": "133917883322390",
+        "liquidityNet": "-130633561795810",
+        "tickIdx": "197070"
+      },
+      {
+        "liquidityGross": "122140198278262",
+        "liquidityNet": "-108838902558678",
+        "tickIdx": "197080"
+      },
+      {
+        "liquidityGross": "11845915314244",
+        "liquidityNet": "8561593787664",
+        "tickIdx": "197090"
+      },
+      {
+        "liquidityGross": "5880440729039",
+        "liquidityNet": "-5880440729039",
+        "tickIdx": "197100"
+      },
+      {
+        "liquidityGross": "44645831046936",
+        "liquidityNet": "-44645831046936",
+        "tickIdx": "197110"
+      },
+      {
+        "liquidityGross": "36695194194845519",
+        "liquidityNet": "-32698061631415449",
+        "tickIdx": "197130"
+      },
+      {
+        "liquidityGross": "54260249526638919",
+        "liquidityNet": "-51451484179696597",
+        "tickIdx": "197140"
+      },
+      {
+        "liquidityGross": "130374838736715",
+        "liquidityNet": "129298387518447",
+        "tickIdx": "197150"
+      },
+      {
+        "liquidityGross": "28537188295320700",
+        "liquidityNet": "28056258430032026",
+        "tickIdx": "197160"
+      },
+      {
+        "liquidityGross": "1379369439272641",
+        "liquidityNet": "1379369439272641",
+        "tickIdx": "197180"
+      },
+      {
+        "liquidityGross": "74240964321383",
+        "liquidityNet": "-74240964321383",
+        "tickIdx": "197190"
+      },
+      {
+        "liquidityGross": "1994218449546505",
+        "liquidityNet": "-1994213403985635",
+        "tickIdx": "197200"
+      },
+      {
+        "liquidityGross": "71768594532802",
+        "liquidityNet": "32576198885432",
+        "tickIdx": "197220"
+      },
+      {
+        "liquidityGross": "107963108977593",
+        "liquidityNet": "107746496109199",
+        "tickIdx": "197230"
+      },
+      {
+        "liquidityGross": "331099282921941",
+        "liquidityNet": "212608305582293",
+        "tickIdx": "197240"
+      },
+      {
+        "liquidityGross": "552439640899999",
+        "liquidityNet": "-499184970077157",
+        "tickIdx": "197250"
+      },
+      {
+        "liquidityGross": "440729495904263",
+        "liquidityNet": "-102978092599971",
+        "tickIdx": "197260"
+      },
+      {
+        "liquidityGross": "344897400174848",
+        "liquidityNet": "299117231476348",
+        "tickIdx": "197270"
+      },
+      {
+        "liquidityGross": "322824870534948",
+        "liquidityNet": "-321189761116248",
+        "tickIdx": "197290"
+      },
+      {
+        "liquidityGross": "49847865154498154",
+        "liquidityNet": "-43089162131875858",
+        "tickIdx": "197310"
+      },
+      {
+        "liquidityGross": "390066994717598",
+        "liquidityNet": "390066994717598",
+        "tickIdx": "197330"
+      },
+      {
+        "liquidityGross": "276127971788189",
+        "liquidityNet": "81755946865973",
+        "tickIdx": "197340"
+      },
+      {
+        "liquidityGross": "306545319068908",
+        "liquidityNet": "-225380356684722",
+        "tickIdx": "197350"
+      },
+      {
+        "liquidityGross": "86730202507546",
+        "liquidityNet": "27032107398706",
+        "tickIdx": "197360"
+      },
+      {
+        "liquidityGross": "181622011108645",
+        "liquidityNet": "181622011108645",
+        "tickIdx": "197370"
+      },
+      {
+        "liquidityGross": "1767892989867346",
+        "liquidityNet": "-1007482363660684",
+        "tickIdx": "197380"
+      },
+      {
+        "liquidityGross": "32312579310165",
+        "liquidityNet": "-21010074419059",
+        "tickIdx": "197390"
+      },
+      {
+        "liquidityGross": "5248503423456263",
+        "liquidityNet": "5248503423456263",
+        "tickIdx": "197400"
+      },
+      {
+        "liquidityGross": "569251602324328",
+        "liquidityNet": "557949097433222",
+        "tickIdx": "197410"
+      },
+      {
+        "liquidityGross": "1640812164371685",
+        "liquidityNet": "-427386792905201",
+        "tickIdx": "197420"
+      },
+      {
+        "liquidityGross": "2957802016056091",
+        "liquidityNet": "2694300761945565",
+        "tickIdx": "197430"
+      },
+      {
+        "liquidityGross": "4761210274778154",
+        "liquidityNet": "-4726664508002426",
+        "tickIdx": "197440"
+      },
+      {
+        "liquidityGross": "24006119117640",
+        "liquidityNet": "-23868680164168",
+        "tickIdx": "197450"
+      },
+      {
+        "liquidityGross": "1406036504377436",
+        "liquidityNet": "-1398454345514152",
+        "tickIdx": "197460"
+      },
+      {
+        "liquidityGross": "4528639197025325",
+        "liquidityNet": "1634428937928623",
+        "tickIdx": "197470"
+      },
+      {
+        "liquidityGross": "3073114744199753",
+        "liquidityNet": "-3073114744199753",
+        "tickIdx": "197480"
+      },
+      {
+        "liquidityGross": "12210402708863",
+        "liquidityNet": "-12210402708863",
+        "tickIdx": "197490"
+      },
+      {
+        "liquidityGross": "20897196628272418",
+        "liquidityNet": "-20681689218951872",
+        "tickIdx": "197500"
+      },
+      {
+        "liquidityGross": "266471630607",
+        "liquidityNet": "-251054225367",
+        "tickIdx": "197510"
+      },
+      {
+        "liquidityGross": "361414005157098",
+        "liquidityNet": "361346765013654",
+        "tickIdx": "197520"
+      },
+      {
+        "liquidityGross": "21435120844932362",
+        "liquidityNet": "-21033077814163638",
+        "tickIdx": "197530"
+      },
+      {
+        "liquidityGross": "455024087853946",
+        "liquidityNet": "-268815166292558",
+        "tickIdx": "197540"
+      },
+      {
+        "liquidityGross": "195608658405383",
+        "liquidityNet": "-195608658405383",
+        "tickIdx": "197550"
+      },
+      {
+        "liquidityGross": "10976623986803697",
+        "liquidityNet": "10976623986803697",
+        "tickIdx": "197560"
+      },
+      {
+        "liquidityGross": "1409182658129687",
+        "liquidityNet": "1409179795951465",
+        "tickIdx": "197570"
+      },
+      {
+        "liquidityGross": "11239229093602154",
+        "liquidityNet": "-11037147452304866",
+        "tickIdx": "197580"
+      },
+      {
+        "liquidityGross": "895723459457961",
+        "liquidityNet": "-892169573277407",
+        "tickIdx": "197590"
+      },
+      {
+        "liquidityGross": "1954797849023913",
+        "liquidityNet": "1631481705377575",
+        "tickIdx": "197600"
+      },
+      {
+        "liquidityGross": "167603524454094",
+        "liquidityNet": "167583061487984",
+        "tickIdx": "197610"
+      },
+      {
+        "liquidityGross": "1866846556474383",
+        "liquidityNet": "-1847429021911883",
+        "tickIdx": "197620"
+      },
+      {
+        "liquidityGross": "193739327053775",
+        "liquidityNet": "193739327053775",
+        "tickIdx": "197630"
+      },
+      {
+        "liquidityGross": "207591694004054",
+        "liquidityNet": "207591694004054",
+        "tickIdx": "197640"
+      },
+      {
+        "liquidityGross": "4799140240443835",
+        "liquidityNet": "4126567458044491",
+        "tickIdx": "197650"
+      },
+      {
+        "liquidityGross": "197371060368821",
+        "liquidityNet": "-197248894708313",
+        "tickIdx": "197660"
+      },
+      {
+        "liquidityGross": "4558810721498131",
+        "liquidityNet": "-4376644206972401",
+        "tickIdx": "197670"
+      },
+      {
+        "liquidityGross": "2035441935076012",
+        "liquidityNet": "81598786816922",
+        "tickIdx": "197680"
+      },
+      {
+        "liquidityGross": "38127508600914737",
+        "liquidityNet": "-35158579729713989",
+        "tickIdx": "197690"
+      },
+      {
+        "liquidityGross": "2285650019145291",
+        "liquidityNet": "66596947231437",
+        "tickIdx": "197700"
+      },
+      {
+        "liquidityGross": "40188748975509",
+        "liquidityNet": "26307506116817",
+        "tickIdx": "197710"
+      },
+      {
+        "liquidityGross": "1396944453892922",
+        "liquidityNet": "-912133972808446",
+        "tickIdx": "197720"
+      },
+      {
+        "liquidityGross": "2229990130976303198",
+        "liquidityNet": "2229682829134415838",
+        "tickIdx": "197730"
+      },
+      {
+        "liquidityGross": "2232467168869842952",
+        "liquidityNet": "-2227345450299561462",
+        "tickIdx": "197740"
+      },
+      {
+        "liquidityGross": "4305006590010615",
+        "liquidityNet": "-2780159707162433",
+        "tickIdx": "197750"
+      },
+      {
+        "liquidityGross": "38002350481944",
+        "liquidityNet": "13329787193048",
+        "tickIdx": "197760"
+      },
+      {
+        "liquidityGross": "1141479963597901",
+        "liquidityNet": "-383366919250281",
+        "tickIdx": "197780"
+      },
+      {
+        "liquidityGross": "96611392466032",
+        "liquidityNet": "56015721937652",
+        "tickIdx": "197790"
+      },
+      {
+        "liquidityGross": "1316508517379869",
+        "liquidityNet": "1316508517379869",
+        "tickIdx": "197800"
+      },
+      {
+        "liquidityGross": "3807499523282080",
+        "liquidityNet": "-3807499523282080",
+        "tickIdx": "197810"
+      },
+      {
+        "liquidityGross": "99047449833039",
+        "liquidityNet": "-13588590519967",
+        "tickIdx": "197820"
+      },
+      {
+        "liquidityGross": "1338326363860077",
+        "liquidityNet": "-1293418572576637",
+        "tickIdx": "197830"
+      },
+      {
+        "liquidityGross": "10463644269478",
+        "liquidityNet": "-10463644269478",
+        "tickIdx": "197840"
+      },
+      {
+        "liquidityGross": "12141064759829",
+        "liquidityNet": "-12141064759829",
+        "tickIdx": "197850"
+      },
+      {
+        "liquidityGross": "6601407800121139",
+        "liquidityNet": "-6581266493858149",
+        "tickIdx": "197860"
+      },
+      {
+        "liquidityGross": "879629239876411",
+        "liquidityNet": "395586090970563",
+        "tickIdx": "197870"
+      },
+      {
+        "liquidityGross": "32033490441974236",
+        "liquidityNet": "-31919229822004590",
+        "tickIdx": "197880"
+      },
+      {
+        "liquidityGross": "1482776574796004",
+        "liquidityNet": "-1482739357178268",
+        "tickIdx": "197890"
+      },
+      {
+        "liquidityGross": "320436328606285",
+        "liquidityNet": "139641090876619",
+        "tickIdx": "197900"
+      },
+      {
+        "liquidityGross": "3598490209837783",
+        "liquidityNet": "3598490209837783",
+        "tickIdx": "197910"
+      },
+      {
+        "liquidityGross": "241455990057546",
+        "liquidityNet": "-152656005780136",
+        "tickIdx": "197920"
+      },
+      {
+        "liquidityGross": "34294593581126",
+        "liquidityNet": "-13554370420632",
+        "tickIdx": "197930"
+      },
+      {
+        "liquidityGross": "3377080657034663",
+        "liquidityNet": "-3373078624420383",
+        "tickIdx": "197940"
+      },
+      {
+        "liquidityGross": "1595246119253323",
+        "liquidityNet": "1113055660529935",
+        "tickIdx": "197950"
+      },
+      {
+        "liquidityGross": "2001016307140",
+        "liquidityNet": "-2001016307140",
+        "tickIdx": "197960"
+      },
+      {
+        "liquidityGross": "2990371534892578",
+        "liquidityNet": "-2153511778279878",
+        "tickIdx": "197970"
+      },
+      {
+        "liquidityGross": "1034981708568469",
+        "liquidityNet": "-913943380727185",
+        "tickIdx": "197980"
+      },
+      {
+        "liquidityGross": "286205815959",
+        "liquidityNet": "272092801187",
+        "tickIdx": "197990"
+      },
+      {
+        "liquidityGross": "261896816185174",
+        "liquidityNet": "208621214404126",
+        "tickIdx": "198000"
+      },
+      {
+        "liquidityGross": "963001173437481",
+        "liquidityNet": "-936079229751201",
+        "tickIdx": "198010"
+      },
+      {
+        "liquidityGross": "17055617782345",
+        "liquidityNet": "7252688040227",
+        "tickIdx": "198020"
+      },
+      {
+        "liquidityGross": "395005360439446",
+        "liquidityNet": "338079598778340",
+        "tickIdx": "198030"
+      },
+      {
+        "liquidityGross": "1173397557647392",
+        "liquidityNet": "554072537741302",
+        "tickIdx": "198040"
+      },
+      {
+        "liquidityGross": "1453610403335933",
+        "liquidityNet": "-1453610403335933",
+        "tickIdx": "198050"
+      },
+      {
+        "liquidityGross": "836882683461998",
+        "liquidityNet": "-836882683461998",
+        "tickIdx": "198060"
+      },
+      {
+        "liquidityGross": "44309445206565",
+        "liquidityNet": "-44309445206565",
+        "tickIdx": "198070"
+      },
+      {
+        "liquidityGross": "61088647182125764",
+        "liquidityNet": "-55625541478295428",
+        "tickIdx": "198080"
+      },
+      {
+        "liquidityGross": "39383549767089602",
+        "liquidityNet": "-39383549767089602",
+        "tickIdx": "198090"
+      },
+      {
+        "liquidityGross": "39163878708344765",
+        "liquidityNet": "8743237610319699",
+        "tickIdx": "198100"
+      },
+      {
+        "liquidityGross": "15730064624215538",
+        "liquidityNet": "-15497856512577588",
+        "tickIdx": "198120"
+      },
+      {
+        "liquidityGross": "24069662215151207",
+        "liquidityNet": "-24069662215151207",
+        "tickIdx": "198130"
+      },
+      {
+        "liquidityGross": "2821666989886645",
+        "liquidityNet": "-2818785958309855",
+        "tickIdx": "198140"
+      },
+      {
+        "liquidityGross": "551816231263643",
+        "liquidityNet": "479779145875449",
+        "tickIdx": "198160"
+      },
+      {
+        "liquidityGross": "16507237651455",
+        "liquidityNet": "-16507237651455",
+        "tickIdx": "198170"
+      },
+      {
+        "liquidityGross": "1014981683082535",
+        "liquidityNet": "-20815245879999",
+        "tickIdx": "198180"
+      },
+      {
+        "liquidityGross": "579093774183822",
+        "liquidityNet": "517606464452634",
+        "tickIdx": "198190"
+      },
+      {
+        "liquidityGross": "2492961192862707",
+        "liquidityNet": "628650267437247",
+        "tickIdx": "198200"
+      },
+      {
+        "liquidityGross": "1611989188706113",
+        "liquidityNet": "-1564618024823671",
+        "tickIdx": "198210"
+      },
+      {
+        "liquidityGross": "1009880360948969",
+        "liquidityNet": "-403734916652805",
+        "tickIdx": "198230"
+      },
+      {
+        "liquidityGross": "2519036035195",
+        "liquidityNet": "2519036035195",
+        "tickIdx": "198240"
+      },
+      {
+        "liquidityGross": "562444160992080",
+        "liquidityNet": "-562444160992080",
+        "tickIdx": "198250"
+      },
+      {
+        "liquidityGross": "106963752959853",
+        "liquidityNet": "-54422833079581",
+        "tickIdx": "198260"
+      },
+      {
+        "liquidityGross": "71950050520113",
+        "liquidityNet": "-71950050520113",
+        "tickIdx": "198270"
+      },
+      {
+        "liquidityGross": "123417252049990",
+        "liquidityNet": "-73392587069442",
+        "tickIdx": "198280"
+      },
+      {
+        "liquidityGross": "47739347251",
+        "liquidityNet": "47739347251",
+        "tickIdx": "198290"
+      },
+      {
+        "liquidityGross": "40947929943861",
+        "liquidityNet": "-31838653988823",
+        "tickIdx": "198300"
+      },
+      {
+        "liquidityGross": "18157443316294683",
+        "liquidityNet": "18157443316294683",
+        "tickIdx": "198310"
+      },
+      {
+        "liquidityGross": "1163714801039019",
+        "liquidityNet": "1131825612344787",
+        "tickIdx": "198320"
+      },
+      {
+        "liquidityGross": "17908956119986410",
+        "liquidityNet": "-17908294449575416",
+        "tickIdx": "198330"
+      },
+      {
+        "liquidityGross": "1147770206691903",
+        "liquidityNet": "-1147770206691903",
+        "tickIdx": "198340"
+      },
+      {
+        "liquidityGross": "245592076582059",
+        "liquidityNet": "245592076582059",
+        "tickIdx": "198360"
+      },
+      {
+        "liquidityGross": "826601339",
+        "liquidityNet": "-826601339",
+        "tickIdx": "198380"
+      },
+      {
+        "liquidityGross": "719385737917328",
+        "liquidityNet": "719385737917328",
+        "tickIdx": "198390"
+      },
+      {
+        "liquidityGross": "15580378389654",
+        "liquidityNet": "15580378389654",
+        "tickIdx": "198400"
+      },
+      {
+        "liquidityGross": "2124157394070",
+        "liquidityNet": "-2124157394070",
+        "tickIdx": "198410"
+      },
+      {
+        "liquidityGross": "468542124700012",
+        "liquidityNet": "451500832683482",
+        "tickIdx": "198420"
+      },
+      {
+        "liquidityGross": "323621183209916",
+        "liquidityNet": "283463112072528",
+        "tickIdx": "198430"
+      },
+      {
+        "liquidityGross": "909590861292435",
+        "liquidityNet": "583919962818375",
+        "tickIdx": "198440"
+      },
+      {
+        "liquidityGross": "290277545196536",
+        "liquidityNet": "-290277545196536",
+        "tickIdx": "198450"
+      },
+      {
+        "liquidityGross": "3317110763323210",
+        "liquidityNet": "1776166322897152",
+        "tickIdx": "198460"
+      },
+      {
+        "liquidityGross": "99862653496",
+        "liquidityNet": "99862653496",
+        "tickIdx": "198470"
+      },
+      {
+        "liquidityGross": "906786425592605",
+        "liquidityNet": "899580297516121",
+        "tickIdx": "198480"
+      },
+      {
+        "liquidityGross": "11132402580271691",
+        "liquidityNet": "-9438298501242043",
+        "tickIdx": "198490"
+      },
+      {
+        "liquidityGross": "7357329207108820",
+        "liquidityNet": "-7357329207108820",
+        "tickIdx": "198500"
+      },
+      {
+        "liquidityGross": "212678241574632",
+        "liquidityNet": "-11496411404286",
+        "tickIdx": "198510"
+      },
+      {
+        "liquidityGross": "497339885311537",
+        "liquidityNet": "-497339885311537",
+        "tickIdx": "198530"
+      },
+      {
+        "liquidityGross": "173732600262594",
+        "liquidityNet": "173732600262594",
+        "tickIdx": "198540"
+      },
+      {
+        "liquidityGross": "165264207447947",
+        "liquidityNet": "-165264207447947",
+        "tickIdx": "198560"
+      },
+      {
+        "liquidityGross": "3791691654104524",
+        "liquidityNet": "3776140879709132",
+        "tickIdx": "198570"
+      },
+      {
+        "liquidityGross": "5549435394448",
+        "liquidityNet": "-5549435394448",
+        "tickIdx": "198580"
+      },
+      {
+        "liquidityGross": "3968797884756267",
+        "liquidityNet": "-3968797884756267",
+        "tickIdx": "198590"
+      },
+      {
+        "liquidityGross": "25666701827351",
+        "liquidityNet": "-25666701827351",
+        "tickIdx": "198610"
+      },
+      {
+        "liquidityGross": "282817264728283",
+        "liquidityNet": "282817264728283",
+        "tickIdx": "198620"
+      },
+      {
+        "liquidityGross": "237158434977170",
+        "liquidityNet": "231257853830576",
+        "tickIdx": "198630"
+      },
+      {
+        "liquidityGross": "13283408140229",
+        "liquidityNet": "-13283408140229",
+        "tickIdx": "198640"
+      },
+      {
+        "liquidityGross": "230133082661011",
+        "liquidityNet": "-230133082661011",
+        "tickIdx": "198650"
+      },
+      {
+        "liquidityGross": "65006435935919",
+        "liquidityNet": "56773511472075",
+        "tickIdx": "198660"
+      },
+      {
+        "liquidityGross": "93068856583417",
+        "liquidityNet": "-93068856583417",
+        "tickIdx": "198670"
+      },
+      {
+        "liquidityGross": "10568367650",
+        "liquidityNet": "-10568367650",
+        "tickIdx": "198680"
+      },
+      {
+        "liquidityGross": "273081710105998",
+        "liquidityNet": "92651462201874",
+        "tickIdx": "198690"
+      },
+      {
+        "liquidityGross": "341801951139496",
+        "liquidityNet": "-341798153045906",
+        "tickIdx": "198700"
+      },
+      {
+        "liquidityGross": "1707563351284302",
+        "liquidityNet": "297152874873684",
+        "tickIdx": "198710"
+      },
+      {
+        "liquidityGross": "1329378141046676",
+        "liquidityNet": "-1329378141046676",
+        "tickIdx": "198720"
+      },
+      {
+        "liquidityGross": "4291025125981313",
+        "liquidityNet": "-4291025125981313",
+        "tickIdx": "198730"
+      },
+      {
+        "liquidityGross": "104200415654279",
+        "liquidityNet": "-32014372783357",
+        "tickIdx": "198740"
+      },
+      {
+        "liquidityGross": "6294244116656452",
+        "liquidityNet": "6293937469386956",
+        "tickIdx": "198750"
+      },
+      {
+        "liquidityGross": "6494577022514601",
+        "liquidityNet": "-6474856383277037",
+        "tickIdx": "198760"
+      },
+      {
+        "liquidityGross": "46166591212031",
+        "liquidityNet": "-45918026204723",
+        "tickIdx": "198770"
+      },
+      {
+        "liquidityGross": "198152867198909",
+        "liquidityNet": "37834206153297",
+        "tickIdx": "198780"
+      },
+      {
+        "liquidityGross": "76273173257762",
+        "liquidityNet": "-76273173257762",
+        "tickIdx": "198790"
+      },
+      {
+        "liquidityGross": "8372045892911",
+        "liquidityNet": "-8372045892911",
+        "tickIdx": "198810"
+      },
+      {
+        "liquidityGross": "360001813611171",
+        "liquidityNet": "-360001813611171",
+        "tickIdx": "198820"
+      },
+      {
+        "liquidityGross": "2797835679058747",
+        "liquidityNet": "-2797835679058747",
+        "tickIdx": "198830"
+      },
+      {
+        "liquidityGross": "4029643996556",
+        "liquidityNet": "-4029643996556",
+        "tickIdx": "198840"
+      },
+      {
+        "liquidityGross": "34485672668104",
+        "liquidityNet": "-34485672668104",
+        "tickIdx": "198850"
+      },
+      {
+        "liquidityGross": "598528129420",
+        "liquidityNet": "-598528128940",
+        "tickIdx": "198860"
+      },
+      {
+        "liquidityGross": "97104963908898",
+        "liquidityNet": "-97104963908898",
+        "tickIdx": "198870"
+      },
+      {
+        "liquidityGross": "499528760799264",
+        "liquidityNet": "318751421923936",
+        "tickIdx": "198880"
+      },
+      {
+        "liquidityGross": "464608994416258",
+        "liquidityNet": "464608994416258",
+        "tickIdx": "198890"
+      },
+      {
+        "liquidityGross": "959622567568204",
+        "liquidityNet": "959622567567724",
+        "tickIdx": "198900"
+      },
+      {
+        "liquidityGross": "8972329658921283",
+        "liquidityNet": "-6987721747465759",
+        "tickIdx": "198910"
+      },
+      {
+        "liquidityGross": "4009505580216188",
+        "liquidityNet": "-2644536673688760",
+        "tickIdx": "198920"
+      },
+      {
+        "liquidityGross": "9432688312744859",
+        "liquidityNet": "9432688312744859",
+        "tickIdx": "198930"
+      },
+      {
+        "liquidityGross": "61455478067672",
+        "liquidityNet": "-61455478067194",
+        "tickIdx": "198940"
+      },
+      {
+        "liquidityGross": "9523392769960157",
+        "liquidityNet": "-9523392769960157",
+        "tickIdx": "198950"
+      },
+      {
+        "liquidityGross": "671785593750083",
+        "liquidityNet": "-656407899567235",
+        "tickIdx": "198960"
+      },
+      {
+        "liquidityGross": "11600521422629",
+        "liquidityNet": "-11600521422629",
+        "tickIdx": "198970"
+      },
+      {
+        "liquidityGross": "6057272388709",
+        "liquidityNet": "-6057272388149",
+        "tickIdx": "198980"
+      },
+      {
+        "liquidityGross": "116724181483482",
+        "liquidityNet": "116038509072488",
+        "tickIdx": "198990"
+      },
+      {
+        "liquidityGross": "2941147222023",
+        "liquidityNet": "2941147221545",
+        "tickIdx": "199000"
+      },
+      {
+        "liquidityGross": "116381345277985",
+        "liquidityNet": "-116381345277985",
+        "tickIdx": "199010"
+      },
+      {
+        "liquidityGross": "97528009934540",
+        "liquidityNet": "96200907737588",
+        "tickIdx": "199020"
+      },
+      {
+        "liquidityGross": "99170907039791",
+        "liquidityNet": "62809852281145",
+        "tickIdx": "199030"
+      },
+      {
+        "liquidityGross": "1608314815216",
+        "liquidityNet": "-1608314815216",
+        "tickIdx": "199040"
+      },
+      {
+        "liquidityGross": "559759276453364",
+        "liquidityNet": "-559759276453364",
+        "tickIdx": "199050"
+      },
+      {
+        "liquidityGross": "82545145586010",
+        "liquidityNet": "-82545145586010",
+        "tickIdx": "199060"
+      },
+      {
+        "liquidityGross": "73788088044",
+        "liquidityNet": "73788088044",
+        "tickIdx": "199070"
+      },
+      {
+        "liquidityGross": "4386037171978715",
+        "liquidityNet": "4386037171978715",
+        "tickIdx": "199080"
+      },
+      {
+        "liquidityGross": "155528062251908",
+        "liquidityNet": "155380486075820",
+        "tickIdx": "199090"
+      },
+      {
+        "liquidityGross": "19048869057949",
+        "liquidityNet": "-19048869057949",
+        "tickIdx": "199100"
+      },
+      {
+        "liquidityGross": "27615798513471246",
+        "liquidityNet": "26941645942926228",
+        "tickIdx": "199110"
+      },
+      {
+        "liquidityGross": "2309155060695394",
+        "liquidityNet": "2214819435643762",
+        "tickIdx": "199130"
+      },
+      {
+        "liquidityGross": "7074371622550205",
+        "liquidityNet": "6430302596776479",
+        "tickIdx": "199140"
+      },
+      {
+        "liquidityGross": "47373281153672",
+        "liquidityNet": "47373281153672",
+        "tickIdx": "199150"
+      },
+      {
+        "liquidityGross": "7034769316039784",
+        "liquidityNet": "-6469904903001750",
+        "tickIdx": "199160"
+      },
+      {
+        "liquidityGross": "642248387401628",
+        "liquidityNet": "-575524103097152",
+        "tickIdx": "199170"
+      },
+      {
+        "liquidityGross": "75621400390424175",
+        "liquidityNet": "-75621400390424175",
+        "tickIdx": "199180"
+      },
+      {
+        "liquidityGross": "299319372937502",
+        "liquidityNet": "232595088633026",
+        "tickIdx": "199190"
+      },
+      {
+        "liquidityGross": "39201145479371879",
+        "liquidityNet": "30587313534450029",
+        "tickIdx": "199200"
+      },
+      {
+        "liquidityGross": "275753205373460",
+        "liquidityNet": "-256161256197068",
+        "tickIdx": "199210"
+      },
+      {
+        "liquidityGross": "49285087840171",
+        "liquidityNet": "49285086809113",
+        "tickIdx": "199220"
+      },
+      {
+        "liquidityGross": "154103332786117",
+        "liquidityNet": "141245037263931",
+        "tickIdx": "199230"
+      },
+      {
+        "liquidityGross": "14164582632099",
+        "liquidityNet": "14164582632099",
+        "tickIdx": "199240"
+      },
+      {
+        "liquidityGross": "1943675254506722",
+        "liquidityNet": "1927115828601846",
+        "tickIdx": "199250"
+      },
+      {
+        "liquidityGross": "26917912910260041",
+        "liquidityNet": "23965416244048219",
+        "tickIdx": "199260"
+      },
+      {
+        "liquidityGross": "1874510080240612",
+        "liquidityNet": "-1874510080240612",
+        "tickIdx": "199270"
+      },
+      {
+        "liquidityGross": "1992932662768916",
+        "liquidityNet": "-1992932662768916",
+        "tickIdx": "199280"
+      },
+      {
+        "liquidityGross": "148016922752040",
+        "liquidityNet": "148016922752040",
+        "tickIdx": "199290"
+      },
+      {
+        "liquidityGross": "393562713808220",
+        "liquidityNet": "-353647815938132",
+        "tickIdx": "199300"
+      },
+      {
+        "liquidityGross": "1352885195903299456",
+        "liquidityNet": "1352885195903299456",
+        "tickIdx": "199310"
+      },
+      {
+        "liquidityGross": "34032520525706223",
+        "liquidityNet": "-33519855362526023",
+        "tickIdx": "199320"
+      },
+      {
+        "liquidityGross": "70658562168873",
+        "liquidityNet": "14346432094203",
+        "tickIdx": "199330"
+      },
+      {
+        "liquidityGross": "817554709350",
+        "liquidityNet": "-817554709350",
+        "tickIdx": "199340"
+      },
+      {
+        "liquidityGross": "792317939497576",
+        "liquidityNet": "-792317939497576",
+        "tickIdx": "199350"
+      },
+      {
+        "liquidityGross": "7714405298723543",
+        "liquidityNet": "-7305128034384889",
+        "tickIdx": "199360"
+      },
+      {
+        "liquidityGross": "6285130699875",
+        "liquidityNet": "-4642147956327",
+        "tickIdx": "199370"
+      },
+      {
+        "liquidityGross": "7598705807111945502",
+        "liquidityNet": "7598705807111945502",
+        "tickIdx": "199380"
+      },
+      {
+        "liquidityGross": "6908484258181",
+        "liquidityNet": "-6908484258181",
+        "tickIdx": "199390"
+      },
+      {
+        "liquidityGross": "233",
+        "liquidityNet": "233",
+        "tickIdx": "199400"
+      },
+      {
+        "liquidityGross": "467",
+        "liquidityNet": "-1",
+        "tickIdx": "199420"
+      },
+      {
+        "liquidityGross": "16427088716168126",
+        "liquidityNet": "16427088716168126",
+        "tickIdx": "199430"
+      },
+      {
+        "liquidityGross": "1260911507144698",
+        "liquidityNet": "1165566570179008",
+        "tickIdx": "199440"
+      },
+      {
+        "liquidityGross": "600001754019951",
+        "liquidityNet": "-600001754019951",
+        "tickIdx": "199450"
+      },
+      {
+        "liquidityGross": "16404462357410162",
+        "liquidityNet": "-16404462357409696",
+        "tickIdx": "199460"
+      },
+      {
+        "liquidityGross": "1364262192671213",
+        "liquidityNet": "-1111237339896895",
+        "tickIdx": "199470"
+      },
+      {
+        "liquidityGross": "33324673217",
+        "liquidityNet": "-33324672753",
+        "tickIdx": "199480"
+      },
+      {
+        "liquidityGross": "109687086610797",
+        "liquidityNet": "109441723946229",
+        "tickIdx": "199490"
+      },
+      {
+        "liquidityGross": "2816471387298175",
+        "liquidityNet": "-2816471387297651",
+        "tickIdx": "199500"
+      },
+      {
+        "liquidityGross": "835",
+        "liquidityNet": "371",
+        "tickIdx": "199520"
+      },
+      {
+        "liquidityGross": "7049110291627469",
+        "liquidityNet": "-7049110270089969",
+        "tickIdx": "199540"
+      },
+      {
+        "liquidityGross": "74496991922150",
+        "liquidityNet": "74496991922150",
+        "tickIdx": "199550"
+      },
+      {
+        "liquidityGross": "1616020322757826",
+        "liquidityNet": "1616020322756620",
+        "tickIdx": "199560"
+      },
+      {
+        "liquidityGross": "6114792526958",
+        "liquidityNet": "6114792526958",
+        "tickIdx": "199570"
+      },
+      {
+        "liquidityGross": "1629468539025052",
+        "liquidityNet": "-1629468539024590",
+        "tickIdx": "199580"
+      },
+      {
+        "liquidityGross": "1299528205655287",
+        "liquidityNet": "-1177136826231823",
+        "tickIdx": "199590"
+      },
+      {
+        "liquidityGross": "10779755",
+        "liquidityNet": "-10779293",
+        "tickIdx": "199600"
+      },
+      {
+        "liquidityGross": "809820099028192",
+        "liquidityNet": "-809820099028192",
+        "tickIdx": "199610"
+      },
+      {
+        "liquidityGross": "304515142969478",
+        "liquidityNet": "-304515142969016",
+        "tickIdx": "199620"
+      },
+      {
+        "liquidityGross": "533087168979636",
+        "liquidityNet": "513032659291062",
+        "tickIdx": "199630"
+      },
+      {
+        "liquidityGross": "231",
+        "liquidityNet": "-231",
+        "tickIdx": "199640"
+      },
+      {
+        "liquidityGross": "71560570730",
+        "liquidityNet": "71560570730",
+        "tickIdx": "199650"
+      },
+      {
+        "liquidityGross": "231",
+        "liquidityNet": "-231",
+        "tickIdx": "199660"
+      },
+      {
+        "liquidityGross": "214559524421144",
+        "liquidityNet": "26101660793654",
+        "tickIdx": "199680"
+      },
+      {
+        "liquidityGross": "191701986657523",
+        "liquidityNet": "191701986657523",
+        "tickIdx": "199690"
+      },
+      {
+        "liquidityGross": "425879693738649",
+        "liquidityNet": "425879693738647",
+        "tickIdx": "199700"
+      },
+      {
+        "liquidityGross": "1442175329605960",
+        "liquidityNet": "-1394522275932262",
+        "tickIdx": "199710"
+      },
+      {
+        "liquidityGross": "783773814508555",
+        "liquidityNet": "783773814508095",
+        "tickIdx": "199720"
+      },
+      {
+        "liquidityGross": "14403264449810",
+        "liquidityNet": "14403264449810",
+        "tickIdx": "199730"
+      },
+      {
+        "liquidityGross": "675",
+        "liquidityNet": "215",
+        "tickIdx": "199740"
+      },
+      {
+        "liquidityGross": "338386054535499",
+        "liquidityNet": "338386054535499",
+        "tickIdx": "199750"
+      },
+      {
+        "liquidityGross": "14403",
+        "liquidityNet": "14403",
+        "tickIdx": "199760"
+      },
+      {
+        "liquidityGross": "486562890470664",
+        "liquidityNet": "-2203656830826",
+        "tickIdx": "199770"
+      },
+      {
+        "liquidityGross": "306411773320982",
+        "liquidityNet": "-306411751523488",
+        "tickIdx": "199780"
+      },
+      {
+        "liquidityGross": "142660157354006",
+        "liquidityNet": "-142660157354006",
+        "tickIdx": "199790"
+      },
+      {
+        "liquidityGross": "9774472230221",
+        "liquidityNet": "9774472201415",
+        "tickIdx": "199800"
+      },
+      {
+        "liquidityGross": "19841324105038",
+        "liquidityNet": "19841324105038",
+        "tickIdx": "199810"
+      },
+      {
+        "liquidityGross": "134088442708624035",
+        "liquidityNet": "132412111753933335",
+        "tickIdx": "199820"
+      },
+      {
+        "liquidityGross": "33999529151136",
+        "liquidityNet": "24648158712216",
+        "tickIdx": "199830"
+      },
+      {
+        "liquidityGross": "10909651",
+        "liquidityNet": "-10909651",
+        "tickIdx": "199840"
+      },
+      {
+        "liquidityGross": "8754807325732",
+        "liquidityNet": "-8754785440878",
+        "tickIdx": "199860"
+      },
+      {
+        "liquidityGross": "132496772429688635",
+        "liquidityNet": "-132496412790011197",
+        "tickIdx": "199870"
+      },
+      {
+        "liquidityGross": "1",
+        "liquidityNet": "1",
+        "tickIdx": "199880"
+      },
+      {
+        "liquidityGross": "5524425706845",
+        "liquidityNet": "-5524425706845",
+        "tickIdx": "199890"
+      },
+      {
+        "liquidityGross": "21906760",
+        "liquidityNet": "21906",
+        "tickIdx": "199900"
+      },
+      {
+        "liquidityGross": "123704697866143",
+        "liquidityNet": "123704697866143",
+        "tickIdx": "199910"
+      },
+      {
+        "liquidityGross": "624903395301679",
+        "liquidityNet": "251553959107603",
+        "tickIdx": "199920"
+      },
+      {
+        "liquidityGross": "711088224021437",
+        "liquidityNet": "-711088224021437",
+        "tickIdx": "199930"
+      },
+      {
+        "liquidityGross": "44749424061104",
+        "liquidityNet": "-44749402088538",
+        "tickIdx": "199940"
+      },
+      {
+        "liquidityGross": "2549242597872279",
+        "liquidityNet": "1788833624868295",
+        "tickIdx": "199950"
+      },
+      {
+        "liquidityGross": "646985406481961",
+        "liquidityNet": "646985384531357",
+        "tickIdx": "199960"
+      },
+      {
+        "liquidityGross": "1110724847423185",
+        "liquidityNet": "1110724847423183",
+        "tickIdx": "199970"
+      },
+      {
+        "liquidityGross": "21994559",
+        "liquidityNet": "21993",
+        "tickIdx": "199980"
+      },
+      {
+        "liquidityGross": "1110724847423184",
+        "liquidityNet": "-1110724847423184",
+        "tickIdx": "199990"
+      },
+      {
+        "liquidityGross": "37495929591945",
+        "liquidityNet": "-5672632077963",
+        "tickIdx": "200000"
+      },
+      {
+        "liquidityGross": "227547268231657",
+        "liquidityNet": "-227365776986897",
+        "tickIdx": "200020"
+      },
+      {
+        "liquidityGross": "90734592066",
+        "liquidityNet": "-90734592066",
+        "tickIdx": "200030"
+      },
+      {
+        "liquidityGross": "22060638",
+        "liquidityNet": "22060",
+        "tickIdx": "200040"
+      },
+      {
+        "liquidityGross": "104340034120545",
+        "liquidityNet": "-104340034120545",
+        "tickIdx": "200050"
+      },
+      {
+        "liquidityGross": "22082709",
+        "liquidityNet": "22081",
+        "tickIdx": "200060"
+      },
+      {
+        "liquidityGross": "74088806565007255",
+        "liquidityNet": "-74088806542880349",
+        "tickIdx": "200080"
+      },
+      {
+        "liquidityGross": "22126917",
+        "liquidityNet": "22127",
+        "tickIdx": "200100"
+      },
+      {
+        "liquidityGross": "7231564215441",
+        "liquidityNet": "-7231564215441",
+        "tickIdx": "200110"
+      },
+      {
+        "liquidityGross": "374045660143",
+        "liquidityNet": "374023533237",
+        "tickIdx": "200120"
+      },
+      {
+        "liquidityGross": "22171212",
+        "liquidityNet": "22170",
+        "tickIdx": "200140"
+      },
+      {
+        "liquidityGross": "2794350631607160463",
+        "liquidityNet": "2794350631607160463",
+        "tickIdx": "200150"
+      },
+      {
+        "liquidityGross": "68558810900586",
+        "liquidityNet": "-68558788127722",
+        "tickIdx": "200160"
+      },
+      {
+        "liquidityGross": "9719393930962",
+        "liquidityNet": "-9719393930962",
+        "tickIdx": "200170"
+      },
+      {
+        "liquidityGross": "22215598",
+        "liquidityNet": "22214",
+        "tickIdx": "200180"
+      },
+      {
+        "liquidityGross": "678211560703923",
+        "liquidityNet": "490373193234813",
+        "tickIdx": "200190"
+      },
+      {
+        "liquidityGross": "26815885329175",
+        "liquidityNet": "-19430643592743",
+        "tickIdx": "200200"
+      },
+      {
+        "liquidityGross": "2024401591704571",
+        "liquidityNet": "2023873390418693",
+        "tickIdx": "200210"
+      },
+      {
+        "liquidityGross": "993201586805",
+        "liquidityNet": "993179348993",
+        "tickIdx": "200220"
+      },
+      {
+        "liquidityGross": "48494646361",
+        "liquidityNet": "-48494646361",
+        "tickIdx": "200230"
+      },
+      {
+        "liquidityGross": "3199154781305376",
+        "liquidityNet": "-1138921463695256",
+        "tickIdx": "200240"
+      },
+      {
+        "liquidityGross": "1",
+        "liquidityNet": "-1",
+        "tickIdx": "200250"
+      },
+      {
+        "liquidityGross": "5987940193978175",
+        "liquidityNet": "1855609337378309",
+        "tickIdx": "200260"
+      },
+      {
+        "liquidityGross": "22326947",
+        "liquidityNet": "22325",
+        "tickIdx": "200280"
+      },
+      {
+        "liquidityGross": "1529687658581818",
+        "liquidityNet": "1529687658581818",
+        "tickIdx": "200290"
+      },
+      {
+        "liquidityGross": "1331011848970010",
+        "liquidityNet": "-1326183962814802",
+        "tickIdx": "200300"
+      },
+      {
+        "liquidityGross": "82911523828031212",
+        "liquidityNet": "-73312633701085292",
+        "tickIdx": "200310"
+      },
+      {
+        "liquidityGross": "134658639244763",
+        "liquidityNet": "98574242997795",
+        "tickIdx": "200320"
+      },
+      {
+        "liquidityGross": "57632422830797",
+        "liquidityNet": "-27372571432279",
+        "tickIdx": "200330"
+      },
+      {
+        "liquidityGross": "3447494752645078",
+        "liquidityNet": "2742414754506642",
+        "tickIdx": "200340"
+      },
+      {
+        "liquidityGross": "15129925699259",
+        "liquidityNet": "-15129925699259",
+        "tickIdx": "200350"
+      },
+      {
+        "liquidityGross": "135581911785292",
+        "liquidityNet": "-135581911784848",
+        "tickIdx": "200360"
+      },
+      {
+        "liquidityGross": "3930566173970",
+        "liquidityNet": "-3930566173970",
+        "tickIdx": "200370"
+      },
+      {
+        "liquidityGross": "93855006830354",
+        "liquidityNet": "93855006829908",
+        "tickIdx": "200380"
+      },
+      {
+        "liquidityGross": "1053450507491257",
+        "liquidityNet": "939688197585005",
+        "tickIdx": "200390"
+      },
+      {
+        "liquidityGross": "510838932108746",
+        "liquidityNet": "-510838932108302",
+        "tickIdx": "200400"
+      },
+      {
+        "liquidityGross": "2692886071143387",
+        "liquidityNet": "-166900033607989",
+        "tickIdx": "200410"
+      },
+      {
+        "liquidityGross": "122792561080834992",
+        "liquidityNet": "122792561080834992",
+        "tickIdx": "200420"
+      },
+      {
+        "liquidityGross": "6957598323464",
+        "liquidityNet": "6957598209700",
+        "tickIdx": "200430"
+      },
+      {
+        "liquidityGross": "122792561080834992",
+        "liquidityNet": "-122792561080834992",
+        "tickIdx": "200440"
+      },
+      {
+        "liquidityGross": "14566093142379",
+        "liquidityNet": "14566093142379",
+        "tickIdx": "200450"
+      },
+      {
+        "liquidityGross": "8483504068880673",
+        "liquidityNet": "-8483475989980353",
+        "tickIdx": "200460"
+      },
+      {
+        "liquidityGross": "351337821359505",
+        "liquidityNet": "351337821359505",
+        "tickIdx": "200470"
+      },
+      {
+        "liquidityGross": "16051775223170",
+        "liquidityNet": "-16051775222728",
+        "tickIdx": "200480"
+      },
+      {
+        "liquidityGross": "351337821359505",
+        "liquidityNet": "-351337821359505",
+        "tickIdx": "200490"
+      },
+      {
+        "liquidityGross": "442",
+        "liquidityNet": "0",
+        "tickIdx": "200500"
+      },
+      {
+        "liquidityGross": "36513897146351",
+        "liquidityNet": "36513897146351",
+        "tickIdx": "200510"
+      },
+      {
+        "liquidityGross": "45319483289349",
+        "liquidityNet": "45319483288907",
+        "tickIdx": "200520"
+      },
+      {
+        "liquidityGross": "21173915535375",
+        "liquidityNet": "21173915535375",
+        "tickIdx": "200530"
+      },
+      {
+        "liquidityGross": "441",
+        "liquidityNet": "-1",
+        "tickIdx": "200540"
+      },
+      {
+        "liquidityGross": "16569591813438",
+        "liquidityNet": "-8284070484708",
+        "tickIdx": "200550"
+      },
+      {
+        "liquidityGross": "527125566062894",
+        "liquidityNet": "-437617793065844",
+        "tickIdx": "200560"
+      },
+      {
+        "liquidityGross": "1982310279884812",
+        "liquidityNet": "-1982310279884812",
+        "tickIdx": "200570"
+      },
+      {
+        "liquidityGross": "30964057740244",
+        "liquidityNet": "30330952715726",
+        "tickIdx": "200580"
+      },
+      {
+        "liquidityGross": "71570634873",
+        "liquidityNet": "71570634871",
+        "tickIdx": "200590"
+      },
+      {
+        "liquidityGross": "68095114764569",
+        "liquidityNet": "68095114764129",
+        "tickIdx": "200600"
+      },
+      {
+        "liquidityGross": "1860176650658",
+        "liquidityNet": "-1836850799640",
+        "tickIdx": "200610"
+      },
+      {
+        "liquidityGross": "68095114764568",
+        "liquidityNet": "-68095114764130",
+        "tickIdx": "200620"
+      },
+      {
+        "liquidityGross": "420589345654229",
+        "liquidityNet": "-420589345654229",
+        "tickIdx": "200630"
+      },
+      {
+        "liquidityGross": "815702302406817",
+        "liquidityNet": "815702302406377",
+        "tickIdx": "200640"
+      },
+      {
+        "liquidityGross": "812983526947267",
+        "liquidityNet": "-812983526946829",
+        "tickIdx": "200660"
+      },
+      {
+        "liquidityGross": "2106182033520489",
+        "liquidityNet": "-2106182033520489",
+        "tickIdx": "200670"
+      },
+      {
+        "liquidityGross": "106429529365359",
+        "liquidityNet": "106429529364921",
+        "tickIdx": "200680"
+      },
+      {
+        "liquidityGross": "147027791046072",
+        "liquidityNet": "46604641066742",
+        "tickIdx": "200690"
+      },
+      {
+        "liquidityGross": "581260565700165",
+        "liquidityNet": "-336955780229879",
+        "tickIdx": "200700"
+      },
+      {
+        "liquidityGross": "97019630318513",
+        "liquidityNet": "-96612801794301",
+        "tickIdx": "200710"
+      },
+      {
+        "liquidityGross": "3224647536832895",
+        "liquidityNet": "328276238126211",
+        "tickIdx": "200720"
+      },
+      {
+        "liquidityGross": "366843669884575",
+        "liquidityNet": "-361792868277015",
+        "tickIdx": "200730"
+      },
+      {
+        "liquidityGross": "1003623340657575",
+        "liquidityNet": "756037507768727",
+        "tickIdx": "200740"
+      },
+      {
+        "liquidityGross": "437640355755909",
+        "liquidityNet": "-437640355755909",
+        "tickIdx": "200750"
+      },
+      {
+        "liquidityGross": "491775100291664",
+        "liquidityNet": "-392605036622820",
+        "tickIdx": "200760"
+      },
+      {
+        "liquidityGross": "2657161661807451",
+        "liquidityNet": "2634090598022917",
+        "tickIdx": "200770"
+      },
+      {
+        "liquidityGross": "61170764862676",
+        "liquidityNet": "-350592129264",
+        "tickIdx": "200780"
+      },
+      {
+        "liquidityGross": "2830257076538314",
+        "liquidityNet": "-1965897650637536",
+        "tickIdx": "200790"
+      },
+      {
+        "liquidityGross": "22266573740998",
+        "liquidityNet": "-4817758115102",
+        "tickIdx": "200800"
+      },
+      {
+        "liquidityGross": "1647919843839365",
+        "liquidityNet": "539763155419907",
+        "tickIdx": "200810"
+      },
+      {
+        "liquidityGross": "58812993697256645",
+        "liquidityNet": "-58632790214630591",
+        "tickIdx": "200820"
+      },
+      {
+        "liquidityGross": "1966145004214497",
+        "liquidityNet": "-1857694841713043",
+        "tickIdx": "200830"
+      },
+      {
+        "liquidityGross": "5935929477015998",
+        "liquidityNet": "5935929477015562",
+        "tickIdx": "200840"
+      },
+      {
+        "liquidityGross": "949032241194118",
+        "liquidityNet": "844988115259702",
+        "tickIdx": "200850"
+      },
+      {
+        "liquidityGross": "7607337254229732040",
+        "liquidityNet": "-7590074359994158930",
+        "tickIdx": "200860"
+      },
+      {
+        "liquidityGross": "1353587997990326790",
+        "liquidityNet": "-1353585769302967830",
+        "tickIdx": "200870"
+      },
+      {
+        "liquidityGross": "6079826813262725",
+        "liquidityNet": "-5807344029001399",
+        "tickIdx": "200880"
+      },
+      {
+        "liquidityGross": "160871579222557513",
+        "liquidityNet": "160869487974152025",
+        "tickIdx": "200890"
+      },
+      {
+        "liquidityGross": "806",
+        "liquidityNet": "372",
+        "tickIdx": "200900"
+      },
+      {
+        "liquidityGross": "160753138694254855",
+        "liquidityNet": "-160753138694254855",
+        "tickIdx": "200910"
+      },
+      {
+        "liquidityGross": "124795786263918",
+        "liquidityNet": "-122613609468868",
+        "tickIdx": "200920"
+      },
+      {
+        "liquidityGross": "5926908939545144",
+        "liquidityNet": "-5491879378840760",
+        "tickIdx": "200930"
+      },
+      {
+        "liquidityGross": "1034480195732719",
+        "liquidityNet": "-1034480172633653",
+        "tickIdx": "200940"
+      },
+      {
+        "liquidityGross": "156002102874199",
+        "liquidityNet": "-126458672292395",
+        "tickIdx": "200950"
+      },
+      {
+        "liquidityGross": "92921501471031",
+        "liquidityNet": "-92921478348857",
+        "tickIdx": "200960"
+      },
+      {
+        "liquidityGross": "1358724166319069",
+        "liquidityNet": "1356580988636911",
+        "tickIdx": "200970"
+      },
+      {
+        "liquidityGross": "2027366695954000",
+        "liquidityNet": "1652324107541166",
+        "tickIdx": "200980"
+      },
+      {
+        "liquidityGross": "1371488871080303",
+        "liquidityNet": "-1371488871080303",
+        "tickIdx": "200990"
+      },
+      {
+        "liquidityGross": "781772065160912",
+        "liquidityNet": "702617002410770",
+        "tickIdx": "201000"
+      },
+      {
+        "liquidityGross": "4317699066346711",
+        "liquidityNet": "4315586199005097",
+        "tickIdx": "201010"
+      },
+      {
+        "liquidityGross": "592323561734495",
+        "liquidityNet": "582822049176087",
+        "tickIdx": "201020"
+      },
+      {
+        "liquidityGross": "6388054037658248",
+        "liquidityNet": "-6048431382098680",
+        "tickIdx": "201030"
+      },
+      {
+        "liquidityGross": "11228721486804618",
+        "liquidityNet": "-3557979882117344",
+        "tickIdx": "201040"
+      },
+      {
+        "liquidityGross": "200270649597064",
+        "liquidityNet": "80880024376906",
+        "tickIdx": "201050"
+      },
+      {
+        "liquidityGross": "39050849077330",
+        "liquidityNet": "-38042042688804",
+        "tickIdx": "201060"
+      },
+      {
+        "liquidityGross": "615318939307057",
+        "liquidityNet": "-269000739269873",
+        "tickIdx": "201070"
+      },
+      {
+        "liquidityGross": "135046345652111",
+        "liquidityNet": "68956702828135",
+        "tickIdx": "201080"
+      },
+      {
+        "liquidityGross": "3336142887562818",
+        "liquidityNet": "2694658607802594",
+        "tickIdx": "201090"
+      },
+      {
+        "liquidityGross": "3141023900646091",
+        "liquidityNet": "-3055376945953063",
+        "tickIdx": "201100"
+      },
+      {
+        "liquidityGross": "51999305383353",
+        "liquidityNet": "-34814551861353",
+        "tickIdx": "201110"
+      },
+      {
+        "liquidityGross": "388182900103967",
+        "liquidityNet": "370998123320649",
+        "tickIdx": "201120"
+      },
+      {
+        "liquidityGross": "77307541856785",
+        "liquidityNet": "-77307541856785",
+        "tickIdx": "201130"
+      },
+      {
+        "liquidityGross": "355906665034707",
+        "liquidityNet": "-355906641703505",
+        "tickIdx": "201140"
+      },
+      {
+        "liquidityGross": "14394526573216294",
+        "liquidityNet": "3185240372147028",
+        "tickIdx": "201150"
+      },
+      {
+        "liquidityGross": "60677511541961",
+        "liquidityNet": "60677488234077",
+        "tickIdx": "201160"
+      },
+      {
+        "liquidityGross": "51820593366424",
+        "liquidityNet": "48817847062364",
+        "tickIdx": "201170"
+      },
+      {
+        "liquidityGross": "62052168023219",
+        "liquidityNet": "-59302831729477",
+        "tickIdx": "201180"
+      },
+      {
+        "liquidityGross": "1483277048793441",
+        "liquidityNet": "1483277048793441",
+        "tickIdx": "201190"
+      },
+      {
+        "liquidityGross": "54109862781336",
+        "liquidityNet": "51360526510960",
+        "tickIdx": "201200"
+      },
+      {
+        "liquidityGross": "1946170645085782",
+        "liquidityNet": "1797141477859690",
+        "tickIdx": "201210"
+      },
+      {
+        "liquidityGross": "6480535467861053",
+        "liquidityNet": "6480535444483143",
+        "tickIdx": "201220"
+      },
+      {
+        "liquidityGross": "1916872620130308",
+        "liquidityNet": "-1771675766918566",
+        "tickIdx": "201230"
+      },
+      {
+        "liquidityGross": "7404889069607928",
+        "liquidityNet": "-5661652208603854",
+        "tickIdx": "201240"
+      },
+      {
+        "liquidityGross": "8660433954155163",
+        "liquidityNet": "-8157871729957143",
+        "tickIdx": "201250"
+      },
+      {
+        "liquidityGross": "2539108155329676",
+        "liquidityNet": "1130215229414602",
+        "tickIdx": "201260"
+      },
+      {
+        "liquidityGross": "10095617936591774",
+        "liquidityNet": "9752955799890292",
+        "tickIdx": "201270"
+      },
+      {
+        "liquidityGross": "10569641727709273",
+        "liquidityNet": "-10249529180754647",
+        "tickIdx": "201280"
+      },
+      {
+        "liquidityGross": "1442669581710313",
+        "liquidityNet": "-1373590060285777",
+        "tickIdx": "201290"
+      },
+      {
+        "liquidityGross": "160525438828030",
+        "liquidityNet": "-160525415309438",
+        "tickIdx": "201300"
+      },
+      {
+        "liquidityGross": "1095681776003922",
+        "liquidityNet": "477712266859626",
+        "tickIdx": "201310"
+      },
+      {
+        "liquidityGross": "68960865886731",
+        "liquidityNet": "66974483738175",
+        "tickIdx": "201320"
+      },
+      {
+        "liquidityGross": "117320418846232",
+        "liquidityNet": "-117320418846232",
+        "tickIdx": "201330"
+      },
+      {
+        "liquidityGross": "67777611265632",
+        "liquidityNet": "-67777587699958",
+        "tickIdx": "201340"
+      },
+      {
+        "liquidityGross": "4011809697059212",
+        "liquidityNet": "4011809697059212",
+        "tickIdx": "201350"
+      },
+      {
+        "liquidityGross": "16148728818678420",
+        "liquidityNet": "-9321659891831588",
+        "tickIdx": "201360"
+      },
+      {
+        "liquidityGross": "1596025568956328",
+        "liquidityNet": "41423489074448",
+        "tickIdx": "201370"
+      },
+      {
+        "liquidityGross": "1549989008658237",
+        "liquidityNet": "-1539253825653133",
+        "tickIdx": "201380"
+      },
+      {
+        "liquidityGross": "67824229776976",
+        "liquidityNet": "-4990386824878",
+        "tickIdx": "201390"
+      },
+      {
+        "liquidityGross": "1905761853128514",
+        "liquidityNet": "1886785011478798",
+        "tickIdx": "201400"
+      },
+      {
+        "liquidityGross": "3091556223440611",
+        "liquidityNet": "3028722380488513",
+        "tickIdx": "201410"
+      },
+      {
+        "liquidityGross": "2573664387285085",
+        "liquidityNet": "-1234960806301203",
+        "tickIdx": "201420"
+      },
+      {
+        "liquidityGross": "3729169504894378",
+        "liquidityNet": "-3729169504894378",
+        "tickIdx": "201430"
+      },
+      {
+        "liquidityGross": "823159501087986",
+        "liquidityNet": "-733314569528550",
+        "tickIdx": "201440"
+      },
+      {
+        "liquidityGross": "14367994359976201",
+        "liquidityNet": "-13547674333618117",
+        "tickIdx": "201450"
+      },
+      {
+        "liquidityGross": "2166617532252280",
+        "liquidityNet": "-650907398108510",
+        "tickIdx": "201460"
+      },
+      {
+        "liquidityGross": "36119998222938",
+        "liquidityNet": "-16827750181546",
+        "tickIdx": "201470"
+      },
+      {
+        "liquidityGross": "1942744046868201",
+        "liquidityNet": "-1938831683495901",
+        "tickIdx": "201480"
+      },
+      {
+        "liquidityGross": "123908795710071",
+        "liquidityNet": "104616547668679",
+        "tickIdx": "201490"
+      },
+      {
+        "liquidityGross": "3991888933632115",
+        "liquidityNet": "-3855572938769733",
+        "tickIdx": "201500"
+      },
+      {
+        "liquidityGross": "727076815925031",
+        "liquidityNet": "-727076815925031",
+        "tickIdx": "201510"
+      },
+      {
+        "liquidityGross": "113477480720098",
+        "liquidityNet": "-113477480720098",
+        "tickIdx": "201520"
+      },
+      {
+        "liquidityGross": "6858909678292141",
+        "liquidityNet": "6824239110568007",
+        "tickIdx": "201530"
+      },
+      {
+        "liquidityGross": "32563202638530",
+        "liquidityNet": "32563202638530",
+        "tickIdx": "201550"
+      },
+      {
+        "liquidityGross": "1114705087551475",
+        "liquidityNet": "-1114705087551475",
+        "tickIdx": "201560"
+      },
+      {
+        "liquidityGross": "2164250889876547",
+        "liquidityNet": "-2164250889876547",
+        "tickIdx": "201570"
+      },
+      {
+        "liquidityGross": "950545747548079",
+        "liquidityNet": "885960592770969",
+        "tickIdx": "201580"
+      },
+      {
+        "liquidityGross": "2077209166935057",
+        "liquidityNet": "-1029158315195265",
+        "tickIdx": "201590"
+      },
+      {
+        "liquidityGross": "369024484077202",
+        "liquidityNet": "-369024484077202",
+        "tickIdx": "201600"
+      },
+      {
+        "liquidityGross": "109270333727788",
+        "liquidityNet": "-101525303618758",
+        "tickIdx": "201610"
+      },
+      {
+        "liquidityGross": "4792164193",
+        "liquidityNet": "4792164193",
+        "tickIdx": "201620"
+      },
+      {
+        "liquidityGross": "3872515054515",
+        "liquidityNet": "-3872515054515",
+        "tickIdx": "201630"
+      },
+      {
+        "liquidityGross": "27586179889260568",
+        "liquidityNet": "-27009485245212022",
+        "tickIdx": "201640"
+      },
+      {
+        "liquidityGross": "1534951463702752",
+        "liquidityNet": "765361740583450",
+        "tickIdx": "201650"
+      },
+      {
+        "liquidityGross": "34603690541862",
+        "liquidityNet": "-33390114239104",
+        "tickIdx": "201660"
+      },
+      {
+        "liquidityGross": "1527085319688934",
+        "liquidityNet": "-775342162142592",
+        "tickIdx": "201670"
+      },
+      {
+        "liquidityGross": "301087128591181",
+        "liquidityNet": "-198242218931975",
+        "tickIdx": "201680"
+      },
+      {
+        "liquidityGross": "160134104478378",
+        "liquidityNet": "-105012970700080",
+        "tickIdx": "201690"
+      },
+      {
+        "liquidityGross": "23144534925279",
+        "liquidityNet": "-23144534925279",
+        "tickIdx": "201700"
+      },
+      {
+        "liquidityGross": "100530336353123",
+        "liquidityNet": "98614049897619",
+        "tickIdx": "201710"
+      },
+      {
+        "liquidityGross": "87969225024706",
+        "liquidityNet": "-78809282445782",
+        "tickIdx": "201720"
+      },
+      {
+        "liquidityGross": "141302275465258",
+        "liquidityNet": "128694428325432",
+        "tickIdx": "201730"
+      },
+      {
+        "liquidityGross": "141071003624038",
+        "liquidityNet": "-140590409734278",
+        "tickIdx": "201740"
+      },
+      {
+        "liquidityGross": "31477761011204",
+        "liquidityNet": "-31477761011204",
+        "tickIdx": "201750"
+      },
+      {
+        "liquidityGross": "75848803157030",
+        "liquidityNet": "55594628576024",
+        "tickIdx": "201760"
+      },
+      {
+        "liquidityGross": "8544138",
+        "liquidityNet": "-8544138",
+        "tickIdx": "201770"
+      },
+      {
+        "liquidityGross": "44417949198275",
+        "liquidityNet": "-27283555976315",
+        "tickIdx": "201780"
+      },
+      {
+        "liquidityGross": "91599811640864",
+        "liquidityNet": "-91599811640864",
+        "tickIdx": "201790"
+      },
+      {
+        "liquidityGross": "9319971869154",
+        "liquidityNet": "-9319971869154",
+        "tickIdx": "201800"
+      },
+      {
+        "liquidityGross": "122846685345363",
+        "liquidityNet": "122846685345363",
+        "tickIdx": "201810"
+      },
+      {
+        "liquidityGross": "32789672924553",
+        "liquidityNet": "-32789672023349",
+        "tickIdx": "201820"
+      },
+      {
+        "liquidityGross": "122847048153463",
+        "liquidityNet": "-122846322537263",
+        "tickIdx": "201830"
+      },
+      {
+        "liquidityGross": "3293926376305",
+        "liquidityNet": "3293926376305",
+        "tickIdx": "201840"
+      },
+      {
+        "liquidityGross": "75303493831926",
+        "liquidityNet": "-75303493831926",
+        "tickIdx": "201850"
+      },
+      {
+        "liquidityGross": "98747458896728",
+        "liquidityNet": "98747458896728",
+        "tickIdx": "201860"
+      },
+      {
+        "liquidityGross": "27576606725510",
+        "liquidityNet": "27576606725510",
+        "tickIdx": "201880"
+      },
+      {
+        "liquidityGross": "304140118800551",
+        "liquidityNet": "304140118800551",
+        "tickIdx": "201890"
+      },
+      {
+        "liquidityGross": "443297704764811",
+        "liquidityNet": "-381517256167665",
+        "tickIdx": "201900"
+      },
+      {
+        "liquidityGross": "3008549830132364",
+        "liquidityNet": "1557511096631416",
+        "tickIdx": "201910"
+      },
+      {
+        "liquidityGross": "1490752855615372",
+        "liquidityNet": "1490752855615372",
+        "tickIdx": "201920"
+      },
+      {
+        "liquidityGross": "1656956536187030",
+        "liquidityNet": "-1328000075072396",
+        "tickIdx": "201930"
+      },
+      {
+        "liquidityGross": "2800763799648160687",
+        "liquidityNet": "-2796183445280093413",
+        "tickIdx": "201940"
+      },
+      {
+        "liquidityGross": "1105860665511954",
+        "liquidityNet": "8772087391684",
+        "tickIdx": "201950"
+      },
+      {
+        "liquidityGross": "2022334482141697",
+        "liquidityNet": "-2022334482141697",
+        "tickIdx": "201960"
+      },
+      {
+        "liquidityGross": "29467823437210",
+        "liquidityNet": "-29467823437210",
+        "tickIdx": "201980"
+      },
+      {
+        "liquidityGross": "918253170159524",
+        "liquidityNet": "-918253170159524",
+        "tickIdx": "201990"
+      },
+      {
+        "liquidityGross": "1443751488965052",
+        "liquidityNet": "1293604400121640",
+        "tickIdx": "202000"
+      },
+      {
+        "liquidityGross": "371214130284398",
+        "liquidityNet": "371214130284398",
+        "tickIdx": "202020"
+      },
+      {
+        "liquidityGross": "88359034719805",
+        "liquidityNet": "-88359034719805",
+        "tickIdx": "202030"
+      },
+      {
+        "liquidityGross": "1165747826076894",
+        "liquidityNet": "-1165747826076894",
+        "tickIdx": "202040"
+      },
+      {
+        "liquidityGross": "2112036435530125",
+        "liquidityNet": "1979596997391265",
+        "tickIdx": "202050"
+      },
+      {
+        "liquidityGross": "1746117654131131",
+        "liquidityNet": "385341402660873",
+        "tickIdx": "202060"
+      },
+      {
+        "liquidityGross": "1463745746507204",
+        "liquidityNet": "1256094250164338",
+        "tickIdx": "202070"
+      },
+      {
+        "liquidityGross": "1289174392026452",
+        "liquidityNet": "-1289117518890826",
+        "tickIdx": "202080"
+      },
+      {
+        "liquidityGross": "1044740064248498",
+        "liquidityNet": "899518794073598",
+        "tickIdx": "202090"
+      },
+      {
+        "liquidityGross": "776984662305219",
+        "liquidityNet": "34556401736423",
+        "tickIdx": "202100"
+      },
+      {
+        "liquidityGross": "1964611489752263",
+        "liquidityNet": "-207614746038809",
+        "tickIdx": "202110"
+      },
+      {
+        "liquidityGross": "456089752235215",
+        "liquidityNet": "-456089752235215",
+        "tickIdx": "202120"
+      },
+      {
+        "liquidityGross": "887158386419342",
+        "liquidityNet": "-873719823639174",
+        "tickIdx": "202130"
+      },
+      {
+        "liquidityGross": "1217222937037888",
+        "liquidityNet": "1203784374257720",
+        "tickIdx": "202150"
+      },
+      {
+        "liquidityGross": "1460098731480152",
+        "liquidityNet": "1460098731480152",
+        "tickIdx": "202160"
+      },
+      {
+        "liquidityGross": "1215487224091290",
+        "liquidityNet": "-1205520087204318",
+        "tickIdx": "202170"
+      },
+      {
+        "liquidityGross": "2272610849089484",
+        "liquidityNet": "-666672863230672",
+        "tickIdx": "202180"
+      },
+      {
+        "liquidityGross": "794877097843566",
+        "liquidityNet": "-721127241915418",
+        "tickIdx": "202190"
+      },
+      {
+        "liquidityGross": "2841271059224749",
+        "liquidityNet": "2841271059224749",
+        "tickIdx": "202200"
+      },
+      {
+        "liquidityGross": "20804432915779",
+        "liquidityNet": "20804432915779",
+        "tickIdx": "202210"
+      },
+      {
+        "liquidityGross": "2886116005280854",
+        "liquidityNet": "-2886116005280854",
+        "tickIdx": "202220"
+      },
+      {
+        "liquidityGross": "3898705337956426",
+        "liquidityNet": "3890419816627696",
+        "tickIdx": "202230"
+      },
+      {
+        "liquidityGross": "5341527419320384",
+        "liquidityNet": "4846086921315790",
+        "tickIdx": "202240"
+      },
+      {
+        "liquidityGross": "1861249977247649",
+        "liquidityNet": "-1845831689201499",
+        "tickIdx": "202250"
+      },
+      {
+        "liquidityGross": "98747458896728",
+        "liquidityNet": "-98747458896728",
+        "tickIdx": "202260"
+      },
+      {
+        "liquidityGross": "948515720825901",
+        "liquidityNet": "948515720825901",
+        "tickIdx": "202270"
+      },
+      {
+        "liquidityGross": "651926017759613",
+        "liquidityNet": "-606932490709277",
+        "tickIdx": "202280"
+      },
+      {
+        "liquidityGross": "1040026172519131",
+        "liquidityNet": "-1040026172519131",
+        "tickIdx": "202290"
+      },
+      {
+        "liquidityGross": "107281811783408",
+        "liquidityNet": "-84431771944744",
+        "tickIdx": "202300"
+      },
+      {
+        "liquidityGross": "1077699200074935",
+        "liquidityNet": "1016658362362261",
+        "tickIdx": "202310"
+      },
+      {
+        "liquidityGross": "46314553633318",
+        "liquidityNet": "1485425173462",
+        "tickIdx": "202320"
+      },
+      {
+        "liquidityGross": "646303867755704",
+        "liquidityNet": "-530380071325622",
+        "tickIdx": "202330"
+      },
+      {
+        "liquidityGross": "17882457470532002",
+        "liquidityNet": "17826169961373778",
+        "tickIdx": "202340"
+      },
+      {
+        "liquidityGross": "4544487085982590",
+        "liquidityNet": "-4054038137938264",
+        "tickIdx": "202350"
+      },
+      {
+        "liquidityGross": "1108466582107019",
+        "liquidityNet": "37688888135713",
+        "tickIdx": "202360"
+      },
+      {
+        "liquidityGross": "17661869039973",
+        "liquidityNet": "-5181401192827",
+        "tickIdx": "202370"
+      },
+      {
+        "liquidityGross": "297250035413589",
+        "liquidityNet": "275850618898897",
+        "tickIdx": "202380"
+      },
+      {
+        "liquidityGross": "202239912360275",
+        "liquidityNet": "-202239912360275",
+        "tickIdx": "202390"
+      },
+      {
+        "liquidityGross": "1045062518975539",
+        "liquidityNet": "11907509894361",
+        "tickIdx": "202400"
+      },
+      {
+        "liquidityGross": "291709804725254",
+        "liquidityNet": "82947004278596",
+        "tickIdx": "202410"
+      },
+      {
+        "liquidityGross": "581553348955679",
+        "liquidityNet": "-475416679914221",
+        "tickIdx": "202420"
+      },
+      {
+        "liquidityGross": "274050207472783",
+        "liquidityNet": "-231958528444287",
+        "tickIdx": "202430"
+      },
+      {
+        "liquidityGross": "49749484",
+        "liquidityNet": "49749484",
+        "tickIdx": "202440"
+      },
+      {
+        "liquidityGross": "208373734671811",
+        "liquidityNet": "-208373734671811",
+        "tickIdx": "202450"
+      },
+      {
+        "liquidityGross": "179833682186913",
+        "liquidityNet": "-6586339534015",
+        "tickIdx": "202470"
+      },
+      {
+        "liquidityGross": "830480927295548",
+        "liquidityNet": "-830480927295548",
+        "tickIdx": "202480"
+      },
+      {
+        "liquidityGross": "294207921674184",
+        "liquidityNet": "294207921674184",
+        "tickIdx": "202490"
+      },
+      {
+        "liquidityGross": "17957417719219246",
+        "liquidityNet": "-17957417719219246",
+        "tickIdx": "202510"
+      },
+      {
+        "liquidityGross": "4443296792250825",
+        "liquidityNet": "-4385939184862505",
+        "tickIdx": "202520"
+      },
+      {
+        "liquidityGross": "4903568332927367",
+        "liquidityNet": "576687292514407",
+        "tickIdx": "202540"
+      },
+      {
+        "liquidityGross": "162102198680786",
+        "liquidityNet": "-162102198680786",
+        "tickIdx": "202550"
+      },
+      {
+        "liquidityGross": "4400484934565610",
+        "liquidityNet": "4400484934565610",
+        "tickIdx": "202560"
+      },
+      {
+        "liquidityGross": "4628853452881895",
+        "liquidityNet": "-4172116416249325",
+        "tickIdx": "202570"
+      },
+      {
+        "liquidityGross": "13305292578630",
+        "liquidityNet": "13305292578630",
+        "tickIdx": "202580"
+      },
+      {
+        "liquidityGross": "382961297317448",
+        "liquidityNet": "-376755609839576",
+        "tickIdx": "202590"
+      },
+      {
+        "liquidityGross": "13305292578630",
+        "liquidityNet": "-13305292578630",
+        "tickIdx": "202600"
+      },
+      {
+        "liquidityGross": "991459414856411",
+        "liquidityNet": "-991459414856411",
+        "tickIdx": "202610"
+      },
+      {
+        "liquidityGross": "462916170411318",
+        "liquidityNet": "17868651158522",
+        "tickIdx": "202620"
+      },
+      {
+        "liquidityGross": "29245318865145",
+        "liquidityNet": "-29245318865145",
+        "tickIdx": "202630"
+      },
+      {
+        "liquidityGross": "240392410784920",
+        "liquidityNet": "-240392410784920",
+        "tickIdx": "202640"
+      },
+      {
+        "liquidityGross": "10218639059771",
+        "liquidityNet": "-2575858156917",
+        "tickIdx": "202650"
+      },
+      {
+        "liquidityGross": "7883035712938",
+        "liquidityNet": "586789738158",
+        "tickIdx": "202660"
+      },
+      {
+        "liquidityGross": "4987553801879075",
+        "liquidityNet": "-4220267505664283",
+        "tickIdx": "202670"
+      },
+      {
+        "liquidityGross": "4234912725548",
+        "liquidityNet": "-4234912725548",
+        "tickIdx": "202680"
+      },
+      {
+        "liquidityGross": "209975311487342",
+        "liquidityNet": "-209975311487342",
+        "tickIdx": "202690"
+      },
+      {
+        "liquidityGross": "4023070355157006",
+        "liquidityNet": "3912691503080106",
+        "tickIdx": "202700"
+      },
+      {
+        "liquidityGross": "7010548566953618",
+        "liquidityNet": "-3045642408061188",
+        "tickIdx": "202710"
+      },
+      {
+        "liquidityGross": "405576329100360",
+        "liquidityNet": "405576329100360",
+        "tickIdx": "202720"
+      },
+      {
+        "liquidityGross": "96120806807801145",
+        "liquidityNet": "92075174683119781",
+        "tickIdx": "202730"
+      },
+      {
+        "liquidityGross": "93413072204211757",
+        "liquidityNet": "-93412511999162297",
+        "tickIdx": "202750"
+      },
+      {
+        "liquidityGross": "405576329100360",
+        "liquidityNet": "-405576329100360",
+        "tickIdx": "202760"
+      },
+      {
+        "liquidityGross": "635875721240268",
+        "liquidityNet": "-635875721240268",
+        "tickIdx": "202770"
+      },
+      {
+        "liquidityGross": "70088886627848",
+        "liquidityNet": "39367994112418",
+        "tickIdx": "202780"
+      },
+      {
+        "liquidityGross": "87469033315472",
+        "liquidityNet": "86543163008874",
+        "tickIdx": "202790"
+      },
+      {
+        "liquidityGross": "936190454983278",
+        "liquidityNet": "534741969435138",
+        "tickIdx": "202800"
+      },
+      {
+        "liquidityGross": "223778444452698",
+        "liquidityNet": "49766248128352",
+        "tickIdx": "202810"
+      },
+      {
+        "liquidityGross": "891270577696723",
+        "liquidityNet": "-853206539302727",
+        "tickIdx": "202820"
+      },
+      {
+        "liquidityGross": "314569659716951",
+        "liquidityNet": "314569659716951",
+        "tickIdx": "202830"
+      },
+      {
+        "liquidityGross": "19032019196998",
+        "liquidityNet": "-19032019196998",
+        "tickIdx": "202840"
+      },
+      {
+        "liquidityGross": "330661109678975",
+        "liquidityNet": "-298478209754927",
+        "tickIdx": "202850"
+      },
+      {
+        "liquidityGross": "4583598845325014",
+        "liquidityNet": "-4400291569925088",
+        "tickIdx": "202860"
+      },
+      {
+        "liquidityGross": "1802570040104479",
+        "liquidityNet": "-1790722478715099",
+        "tickIdx": "202870"
+      },
+      {
+        "liquidityGross": "102144853689538",
+        "liquidityNet": "86481145519812",
+        "tickIdx": "202880"
+      },
+      {
+        "liquidityGross": "159707892442444",
+        "liquidityNet": "-159707892442444",
+        "tickIdx": "202890"
+      },
+      {
+        "liquidityGross": "124779696182196",
+        "liquidityNet": "-124779696182196",
+        "tickIdx": "202900"
+      },
+      {
+        "liquidityGross": "1559501623180",
+        "liquidityNet": "1559500721976",
+        "tickIdx": "202920"
+      },
+      {
+        "liquidityGross": "44770824606692",
+        "liquidityNet": "-44770824606692",
+        "tickIdx": "202960"
+      },
+      {
+        "liquidityGross": "89233030144128",
+        "liquidityNet": "-87133314853926",
+        "tickIdx": "202970"
+      },
+      {
+        "liquidityGross": "17566865042867",
+        "liquidityNet": "-17566865042867",
+        "tickIdx": "202980"
+      },
+      {
+        "liquidityGross": "53120179115163",
+        "liquidityNet": "-53120179115163",
+        "tickIdx": "202990"
+      },
+      {
+        "liquidityGross": "7181254743610",
+        "liquidityNet": "-7181254743610",
+        "tickIdx": "203000"
+      },
+      {
+        "liquidityGross": "8407139530500",
+        "liquidityNet": "-8407139530500",
+        "tickIdx": "203020"
+      },
+      {
+        "liquidityGross": "101785000042774",
+        "liquidityNet": "-101785000042774",
+        "tickIdx": "203040"
+      },
+      {
+        "liquidityGross": "598080051491788",
+        "liquidityNet": "598080051491788",
+        "tickIdx": "203050"
+      },
+      {
+        "liquidityGross": "6088612861499",
+        "liquidityNet": "-6088612861499",
+        "tickIdx": "203060"
+      },
+      {
+        "liquidityGross": "28277919904324",
+        "liquidityNet": "-28277919904324",
+        "tickIdx": "203100"
+      },
+      {
+        "liquidityGross": "5231689491892",
+        "liquidityNet": "-5231689491892",
+        "tickIdx": "203120"
+      },
+      {
+        "liquidityGross": "56979434141331",
+        "liquidityNet": "-32865497418105",
+        "tickIdx": "203130"
+      },
+      {
+        "liquidityGross": "98726939861609",
+        "liquidityNet": "-98726939861609",
+        "tickIdx": "203140"
+      },
+      {
+        "liquidityGross": "41481054197019",
+        "liquidityNet": "-41481054197019",
+        "tickIdx": "203150"
+      },
+      {
+        "liquidityGross": "15336904627316",
+        "liquidityNet": "-15336904627316",
+        "tickIdx": "203160"
+      },
+      {
+        "liquidityGross": "24388615498285",
+        "liquidityNet": "-24388615498285",
+        "tickIdx": "203170"
+      },
+      {
+        "liquidityGross": "2316828376572938",
+        "liquidityNet": "-2290011731112124",
+        "tickIdx": "203180"
+      },
+      {
+        "liquidityGross": "62891568007528779",
+        "liquidityNet": "-47844619771628543",
+        "tickIdx": "203190"
+      },
+      {
+        "liquidityGross": "584738576398555",
+        "liquidityNet": "-584738576398555",
+        "tickIdx": "203200"
+      },
+      {
+        "liquidityGross": "7619473815173",
+        "liquidityNet": "-7619473815173",
+        "tickIdx": "203220"
+      },
+      {
+        "liquidityGross": "636124044933302",
+        "liquidityNet": "-636124044933302",
+        "tickIdx": "203260"
+      },
+      {
+        "liquidityGross": "1880268460956411",
+        "liquidityNet": "-1880268460956411",
+        "tickIdx": "203270"
+      },
+      {
+        "liquidityGross": "173667836620054",
+        "liquidityNet": "-173667836620054",
+        "tickIdx": "203290"
+      },
+      {
+        "liquidityGross": "17732822804684",
+        "liquidityNet": "-17732822804684",
+        "tickIdx": "203320"
+      },
+      {
+        "liquidityGross": "42823465704219",
+        "liquidityNet": "-42823465704219",
+        "tickIdx": "203330"
+      },
+      {
+        "liquidityGross": "19957448935044",
+        "liquidityNet": "-19957448935044",
+        "tickIdx": "203340"
+      },
+      {
+        "liquidityGross": "1590441756031",
+        "liquidityNet": "-1590441756031",
+        "tickIdx": "203360"
+      },
+      {
+        "liquidityGross": "22560478618918",
+        "liquidityNet": "-22560478618918",
+        "tickIdx": "203390"
+      },
+      {
+        "liquidityGross": "507090483264063",
+        "liquidityNet": "-507090483264063",
+        "tickIdx": "203400"
+      },
+      {
+        "liquidityGross": "948347949819961",
+        "liquidityNet": "-948347949819961",
+        "tickIdx": "203450"
+      },
+      {
+        "liquidityGross": "4401764424180",
+        "liquidityNet": "-4401764424180",
+        "tickIdx": "203460"
+      },
+      {
+        "liquidityGross": "9287401875839",
+        "liquidityNet": "-9287401875839",
+        "tickIdx": "203470"
+      },
+      {
+        "liquidityGross": "66885192117",
+        "liquidityNet": "-66885192117",
+        "tickIdx": "203490"
+      },
+      {
+        "liquidityGross": "6560098309762",
+        "liquidityNet": "-6560098309762",
+        "tickIdx": "203520"
+      },
+      {
+        "liquidityGross": "10738102070491345",
+        "liquidityNet": "-10736192110597097",
+        "tickIdx": "203530"
+      },
+      {
+        "liquidityGross": "73502870437",
+        "liquidityNet": "-73502870437",
+        "tickIdx": "203540"
+      },
+      {
+        "liquidityGross": "188138191644572",
+        "liquidityNet": "-146194548038870",
+        "tickIdx": "203550"
+      },
+      {
+        "liquidityGross": "41148245238",
+        "liquidityNet": "41148245238",
+        "tickIdx": "203600"
+      },
+      {
+        "liquidityGross": "12644016052739932",
+        "liquidityNet": "-12644016052739932",
+        "tickIdx": "203610"
+      },
+      {
+        "liquidityGross": "18824353338452",
+        "liquidityNet": "-18824353338452",
+        "tickIdx": "203620"
+      },
+      {
+        "liquidityGross": "13321068754020790",
+        "liquidityNet": "-13321068754020790",
+        "tickIdx": "203630"
+      },
+      {
+        "liquidityGross": "598080051491778",
+        "liquidityNet": "-598080051491778",
+        "tickIdx": "203640"
+      },
+      {
+        "liquidityGross": "14319313250054",
+        "liquidityNet": "-14319313250054",
+        "tickIdx": "203680"
+      },
+      {
+        "liquidityGross": "29406113433896",
+        "liquidityNet": "-29406113433896",
+        "tickIdx": "203700"
+      },
+      {
+        "liquidityGross": "29187215029237",
+        "liquidityNet": "-29187215029237",
+        "tickIdx": "203720"
+      },
+      {
+        "liquidityGross": "25808506038260",
+        "liquidityNet": "-25808506038260",
+        "tickIdx": "203740"
+      },
+      {
+        "liquidityGross": "37593079364642",
+        "liquidityNet": "37593079364642",
+        "tickIdx": "203780"
+      },
+      {
+        "liquidityGross": "62958230984033",
+        "liquidityNet": "-62958230984033",
+        "tickIdx": "203790"
+      },
+      {
+        "liquidityGross": "410514541597419",
+        "liquidityNet": "-410514541597419",
+        "tickIdx": "203810"
+      },
+      {
+        "liquidityGross": "4459378691580",
+        "liquidityNet": "-4459378691580",
+        "tickIdx": "203820"
+      },
+      {
+        "liquidityGross": "190075317894",
+        "liquidityNet": "-190075317894",
+        "tickIdx": "203840"
+      },
+      {
+        "liquidityGross": "2563707068368625",
+        "liquidityNet": "-2563707068368625",
+        "tickIdx": "203860"
+      },
+      {
+        "liquidityGross": "4068284447161069",
+        "liquidityNet": "-4039297443936569",
+        "tickIdx": "203870"
+      },
+      {
+        "liquidityGross": "23015602873053776",
+        "liquidityNet": "-22760578394485304",
+        "tickIdx": "203880"
+      },
+      {
+        "liquidityGross": "21978177723817",
+        "liquidityNet": "6489986004223",
+        "tickIdx": "203890"
+      },
+      {
+        "liquidityGross": "7500972438780182",
+        "liquidityNet": "-7500972438780182",
+        "tickIdx": "203920"
+      },
+      {
+        "liquidityGross": "33906611355",
+        "liquidityNet": "33906611355",
+        "tickIdx": "203950"
+      },
+      {
+        "liquidityGross": "1184074883211",
+        "liquidityNet": "1184074883211",
+        "tickIdx": "203960"
+      },
+      {
+        "liquidityGross": "15213000014794",
+        "liquidityNet": "15213000014794",
+        "tickIdx": "203990"
+      },
+      {
+        "liquidityGross": "2721055168095",
+        "liquidityNet": "-2721055168095",
+        "tickIdx": "204020"
+      },
+      {
+        "liquidityGross": "65359217603",
+        "liquidityNet": "-65359217603",
+        "tickIdx": "204030"
+      },
+      {
+        "liquidityGross": "7168655126983",
+        "liquidityNet": "-7168655126983",
+        "tickIdx": "204040"
+      },
+      {
+        "liquidityGross": "224653000969518",
+        "liquidityNet": "-224653000969518",
+        "tickIdx": "204050"
+      },
+      {
+        "liquidityGross": "2610495386081331",
+        "liquidityNet": "-2610495386081331",
+        "tickIdx": "204060"
+      },
+      {
+        "liquidityGross": "23405696260743",
+        "liquidityNet": "-23405696260743",
+        "tickIdx": "204070"
+      },
+      {
+        "liquidityGross": "44749132440304",
+        "liquidityNet": "44749132440304",
+        "tickIdx": "204080"
+      },
+      {
+        "liquidityGross": "564499432987176",
+        "liquidityNet": "366010862813302",
+        "tickIdx": "204090"
+      },
+      {
+        "liquidityGross": "15580378389654",
+        "liquidityNet": "-15580378389654",
+        "tickIdx": "204100"
+      },
+      {
+        "liquidityGross": "3223954871333",
+        "liquidityNet": "-3223954871333",
+        "tickIdx": "204120"
+      },
+      {
+        "liquidityGross": "21173915535375",
+        "liquidityNet": "-21173915535375",
+        "tickIdx": "204130"
+      },
+      {
+        "liquidityGross": "527279115156",
+        "liquidityNet": "527279115156",
+        "tickIdx": "204160"
+      },
+      {
+        "liquidityGross": "81134504679928",
+        "liquidityNet": "81134504679928",
+        "tickIdx": "204170"
+      },
+      {
+        "liquidityGross": "1004655231506",
+        "liquidityNet": "1004655231506",
+        "tickIdx": "204180"
+      },
+      {
+        "liquidityGross": "697819740796349",
+        "liquidityNet": "696765182566037",
+        "tickIdx": "204200"
+      },
+      {
+        "liquidityGross": "2962633523350",
+        "liquidityNet": "-2962633523350",
+        "tickIdx": "204220"
+      },
+      {
+        "liquidityGross": "954979947124",
+        "liquidityNet": "-954979947124",
+        "tickIdx": "204240"
+      },
+      {
+        "liquidityGross": "2298787003010616",
+        "liquidityNet": "2298787003010616",
+        "tickIdx": "204260"
+      },
+      {
+        "liquidityGross": "1004655231506",
+        "liquidityNet": "-1004655231506",
+        "tickIdx": "204280"
+      },
+      {
+        "liquidityGross": "2268108510133",
+        "liquidityNet": "-2268108510133",
+        "tickIdx": "204330"
+      },
+      {
+        "liquidityGross": "1189417270755793",
+        "liquidityNet": "-205167652606593",
+        "tickIdx": "204340"
+      },
+      {
+        "liquidityGross": "46922130405323",
+        "liquidityNet": "46922130405323",
+        "tickIdx": "204360"
+      },
+      {
+        "liquidityGross": "519981051129559",
+        "liquidityNet": "-497297810940347",
+        "tickIdx": "204380"
+      },
+      {
+        "liquidityGross": "86249643007776",
+        "liquidityNet": "86249643007776",
+        "tickIdx": "204390"
+      },
+      {
+        "liquidityGross": "3990344019813",
+        "liquidityNet": "-3990344019813",
+        "tickIdx": "204420"
+      },
+      {
+        "liquidityGross": "94050936873876",
+        "liquidityNet": "-94050936873876",
+        "tickIdx": "204470"
+      },
+      {
+        "liquidityGross": "9024164118422",
+        "liquidityNet": "9024164118422",
+        "tickIdx": "204490"
+      },
+      {
+        "liquidityGross": "10",
+        "liquidityNet": "-10",
+        "tickIdx": "204500"
+      },
+      {
+        "liquidityGross": "21821905",
+        "liquidityNet": "21821905",
+        "tickIdx": "204530"
+      },
+      {
+        "liquidityGross": "19358288239660",
+        "liquidityNet": "-19358288239660",
+        "tickIdx": "204540"
+      },
+      {
+        "liquidityGross": "33615509923089",
+        "liquidityNet": "-33615509923089",
+        "tickIdx": "204550"
+      },
+      {
+        "liquidityGross": "17618420282610",
+        "liquidityNet": "17618420282610",
+        "tickIdx": "204570"
+      },
+      {
+        "liquidityGross": "23695521257064",
+        "liquidityNet": "-23695521257064",
+        "tickIdx": "204600"
+      },
+      {
+        "liquidityGross": "1341183578617033",
+        "liquidityNet": "1341183578617033",
+        "tickIdx": "204610"
+      },
+      {
+        "liquidityGross": "170673103538494",
+        "liquidityNet": "37620554049366",
+        "tickIdx": "204620"
+      },
+      {
+        "liquidityGross": "39197183406877",
+        "liquidityNet": "39197183406877",
+        "tickIdx": "204630"
+      },
+      {
+        "liquidityGross": "394387707481",
+        "liquidityNet": "394387707481",
+        "tickIdx": "204640"
+      },
+      {
+        "liquidityGross": "84644554722165",
+        "liquidityNet": "84644554722165",
+        "tickIdx": "204650"
+      },
+      {
+        "liquidityGross": "130330633417360",
+        "liquidityNet": "-130330633417360",
+        "tickIdx": "204660"
+      },
+      {
+        "liquidityGross": "76428208044276",
+        "liquidityNet": "-1966158769478",
+        "tickIdx": "204670"
+      },
+      {
+        "liquidityGross": "4495985696547137",
+        "liquidityNet": "-4495985696547137",
+        "tickIdx": "204700"
+      },
+      {
+        "liquidityGross": "744623171956822",
+        "liquidityNet": "-721318221347292",
+        "tickIdx": "204710"
+      },
+      {
+        "liquidityGross": "52991559159211",
+        "liquidityNet": "-52991559159211",
+        "tickIdx": "204720"
+      },
+      {
+        "liquidityGross": "1290203203245374",
+        "liquidityNet": "-1290203203245374",
+        "tickIdx": "204740"
+      },
+      {
+        "liquidityGross": "1087800237554443",
+        "liquidityNet": "1087800237554443",
+        "tickIdx": "204750"
+      },
+      {
+        "liquidityGross": "58556667865517",
+        "liquidityNet": "-58556667865517",
+        "tickIdx": "204790"
+      },
+      {
+        "liquidityGross": "8811768495241",
+        "liquidityNet": "8811768495241",
+        "tickIdx": "204800"
+      },
+      {
+        "liquidityGross": "8811768495241",
+        "liquidityNet": "-8811768495241",
+        "tickIdx": "204820"
+      },
+      {
+        "liquidityGross": "262121163150",
+        "liquidityNet": "-262068004012",
+        "tickIdx": "204860"
+      },
+      {
+        "liquidityGross": "17372156034119",
+        "liquidityNet": "-17372156034119",
+        "tickIdx": "204880"
+      },
+      {
+        "liquidityGross": "3777730624536",
+        "liquidityNet": "-3777730624536",
+        "tickIdx": "204890"
+      },
+      {
+        "liquidityGross": "26579569",
+        "liquidityNet": "-26579569",
+        "tickIdx": "204900"
+      },
+      {
+        "liquidityGross": "910271637044215",
+        "liquidityNet": "-910271637044215",
+        "tickIdx": "204910"
+      },
+      {
+        "liquidityGross": "6510470788869",
+        "liquidityNet": "-6510470788869",
+        "tickIdx": "204930"
+      },
+      {
+        "liquidityGross": "35112050975070",
+        "liquidityNet": "-35112050975070",
+        "tickIdx": "204950"
+      },
+      {
+        "liquidityGross": "5708588153368",
+        "liquidityNet": "5708588153368",
+        "tickIdx": "204960"
+      },
+      {
+        "liquidityGross": "37231024637399",
+        "liquidityNet": "-37231024637399",
+        "tickIdx": "204970"
+      },
+      {
+        "liquidityGross": "939053049297",
+        "liquidityNet": "-939053049297",
+        "tickIdx": "204990"
+      },
+      {
+        "liquidityGross": "15213000014794",
+        "liquidityNet": "-15213000014794",
+        "tickIdx": "205000"
+      },
+      {
+        "liquidityGross": "114005757282180",
+        "liquidityNet": "-109396489161074",
+        "tickIdx": "205010"
+      },
+      {
+        "liquidityGross": "3258972779416",
+        "liquidityNet": "3258972779416",
+        "tickIdx": "205070"
+      },
+      {
+        "liquidityGross": "3258972779416",
+        "liquidityNet": "-3258972779416",
+        "tickIdx": "205090"
+      },
+      {
+        "liquidityGross": "3815506156622",
+        "liquidityNet": "3815506156622",
+        "tickIdx": "205140"
+      },
+      {
+        "liquidityGross": "72247538655926",
+        "liquidityNet": "72247538655926",
+        "tickIdx": "205180"
+      },
+      {
+        "liquidityGross": "5708588153368",
+        "liquidityNet": "-5708588153368",
+        "tickIdx": "205190"
+      },
+      {
+        "liquidityGross": "2154791887441158",
+        "liquidityNet": "-2154791887441158",
+        "tickIdx": "205200"
+      },
+      {
+        "liquidityGross": "12887701135087",
+        "liquidityNet": "12887701135087",
+        "tickIdx": "205210"
+      },
+      {
+        "liquidityGross": "2413943077381",
+        "liquidityNet": "-2413943077381",
+        "tickIdx": "205220"
+      },
+      {
+        "liquidityGross": "12887701135087",
+        "liquidityNet": "-12887701135087",
+        "tickIdx": "205240"
+      },
+      {
+        "liquidityGross": "2118391249583235",
+        "liquidityNet": "2118391249583235",
+        "tickIdx": "205250"
+      },
+      {
+        "liquidityGross": "92409582075501",
+        "liquidityNet": "92409582075501",
+        "tickIdx": "205260"
+      },
+      {
+        "liquidityGross": "2177818484237818",
+        "liquidityNet": "-2177218599761408",
+        "tickIdx": "205270"
+      },
+      {
+        "liquidityGross": "108888109689097",
+        "liquidityNet": "-75931054461905",
+        "tickIdx": "205280"
+      },
+      {
+        "liquidityGross": "41769168482150",
+        "liquidityNet": "-41769168482150",
+        "tickIdx": "205290"
+      },
+      {
+        "liquidityGross": "163892614366023",
+        "liquidityNet": "-163892614366023",
+        "tickIdx": "205300"
+      },
+      {
+        "liquidityGross": "35601822000",
+        "liquidityNet": "35601822000",
+        "tickIdx": "205330"
+      },
+      {
+        "liquidityGross": "7721290634729",
+        "liquidityNet": "-7721290634729",
+        "tickIdx": "205340"
+      },
+      {
+        "liquidityGross": "35413176029881",
+        "liquidityNet": "35413176029881",
+        "tickIdx": "205350"
+      },
+      {
+        "liquidityGross": "35601822000",
+        "liquidityNet": "-35601822000",
+        "tickIdx": "205370"
+      },
+      {
+        "liquidityGross": "58520881195404",
+        "liquidityNet": "56032323483674",
+        "tickIdx": "205380"
+      },
+      {
+        "liquidityGross": "62750419706172",
+        "liquidityNet": "-62750419706172",
+        "tickIdx": "205390"
+      },
+      {
+        "liquidityGross": "2535046243868695",
+        "liquidityNet": "-2479164301850561",
+        "tickIdx": "205420"
+      },
+      {
+        "liquidityGross": "221591903313724",
+        "liquidityNet": "221591903313724",
+        "tickIdx": "205430"
+      },
+      {
+        "liquidityGross": "2369908845622768",
+        "liquidityNet": "-2337388845635494",
+        "tickIdx": "205460"
+      },
+      {
+        "liquidityGross": "167222976955551",
+        "liquidityNet": "167222976955551",
+        "tickIdx": "205490"
+      },
+      {
+        "liquidityGross": "5141291429292",
+        "liquidityNet": "-5141291429292",
+        "tickIdx": "205500"
+      },
+      {
+        "liquidityGross": "35547267802725",
+        "liquidityNet": "-35547267802725",
+        "tickIdx": "205510"
+      },
+      {
+        "liquidityGross": "167222976955551",
+        "liquidityNet": "-167222976955551",
+        "tickIdx": "205530"
+      },
+      {
+        "liquidityGross": "22741687028344",
+        "liquidityNet": "22741687028344",
+        "tickIdx": "205560"
+      },
+      {
+        "liquidityGross": "55695768449144",
+        "liquidityNet": "-10644388542016",
+        "tickIdx": "205590"
+      },
+      {
+        "liquidityGross": "22741687028344",
+        "liquidityNet": "-22741687028344",
+        "tickIdx": "205610"
+      },
+      {
+        "liquidityGross": "22525689953564",
+        "liquidityNet": "-22525689953564",
+        "tickIdx": "205630"
+      },
+      {
+        "liquidityGross": "46922130405323",
+        "liquidityNet": "-46922130405323",
+        "tickIdx": "205800"
+      },
+      {
+        "liquidityGross": "484834570048165",
+        "liquidityNet": "-484834570048165",
+        "tickIdx": "205850"
+      },
+      {
+        "liquidityGross": "1653347163134",
+        "liquidityNet": "-1653347163134",
+        "tickIdx": "205870"
+      },
+      {
+        "liquidityGross": "5921513161873",
+        "liquidityNet": "5921513161873",
+        "tickIdx": "205920"
+      },
+      {
+        "liquidityGross": "5921513161873",
+        "liquidityNet": "-5921513161873",
+        "tickIdx": "205930"
+      },
+      {
+        "liquidityGross": "14566093142378",
+        "liquidityNet": "-14566093142378",
+        "tickIdx": "205990"
+      },
+      {
+        "liquidityGross": "4132589508320855",
+        "liquidityNet": "4132589508320855",
+        "tickIdx": "206010"
+      },
+      {
+        "liquidityGross": "821491371774",
+        "liquidityNet": "-821491371774",
+        "tickIdx": "206030"
+      },
+      {
+        "liquidityGross": "36825342664461",
+        "liquidityNet": "-36825342664461",
+        "tickIdx": "206060"
+      },
+      {
+        "liquidityGross": "36210029905465",
+        "liquidityNet": "-36210029905465",
+        "tickIdx": "206110"
+      },
+      {
+        "liquidityGross": "382765298101521",
+        "liquidityNet": "382765298101521",
+        "tickIdx": "206130"
+      },
+      {
+        "liquidityGross": "8070561896919252",
+        "liquidityNet": "-8070561896919252",
+        "tickIdx": "206190"
+      },
+      {
+        "liquidityGross": "118797794071095",
+        "liquidityNet": "-118797794071095",
+        "tickIdx": "206220"
+      },
+      {
+        "liquidityGross": "30410086366488",
+        "liquidityNet": "-30410086366488",
+        "tickIdx": "206250"
+      },
+      {
+        "liquidityGross": "477197831652989",
+        "liquidityNet": "-477197831652989",
+        "tickIdx": "206260"
+      },
+      {
+        "liquidityGross": "5643802559471404",
+        "liquidityNet": "2993753263949656",
+        "tickIdx": "206290"
+      },
+      {
+        "liquidityGross": "744366934478",
+        "liquidityNet": "-744366934478",
+        "tickIdx": "206310"
+      },
+      {
+        "liquidityGross": "4132589508320855",
+        "liquidityNet": "-4132589508320855",
+        "tickIdx": "206320"
+      },
+      {
+        "liquidityGross": "122973486683145",
+        "liquidityNet": "122973486683145",
+        "tickIdx": "206370"
+      },
+      {
+        "liquidityGross": "128273656740017",
+        "liquidityNet": "-128273656740017",
+        "tickIdx": "206380"
+      },
+      {
+        "liquidityGross": "5517325292675",
+        "liquidityNet": "5517325292675",
+        "tickIdx": "206410"
+      },
+      {
+        "liquidityGross": "5517325292675",
+        "liquidityNet": "-5517325292675",
+        "tickIdx": "206450"
+      },
+      {
+        "liquidityGross": "216130743921190",
+        "liquidityNet": "-216130743921190",
+        "tickIdx": "206460"
+      },
+      {
+        "liquidityGross": "46445625148087",
+        "liquidityNet": "-46445625148087",
+        "tickIdx": "206530"
+      },
+      {
+        "liquidityGross": "10456351239484",
+        "liquidityNet": "-10456351239484",
+        "tickIdx": "206560"
+      },
+      {
+        "liquidityGross": "39249186082639",
+        "liquidityNet": "39249186082639",
+        "tickIdx": "206690"
+      },
+      {
+        "liquidityGross": "39249186082639",
+        "liquidityNet": "-39249186082639",
+        "tickIdx": "206710"
+      },
+      {
+        "liquidityGross": "71560570730",
+        "liquidityNet": "-71560570730",
+        "tickIdx": "206850"
+      },
+      {
+        "liquidityGross": "1060355204688",
+        "liquidityNet": "-1060355204688",
+        "tickIdx": "207040"
+      },
+      {
+        "liquidityGross": "21599131813462",
+        "liquidityNet": "-21599131813462",
+        "tickIdx": "207090"
+      },
+      {
+        "liquidityGross": "116526800732",
+        "liquidityNet": "-116526800732",
+        "tickIdx": "207190"
+      },
+      {
+        "liquidityGross": "23690981880736",
+        "liquidityNet": "23690981880736",
+        "tickIdx": "207200"
+      },
+      {
+        "liquidityGross": "29966490820790768",
+        "liquidityNet": "-29966490820790768",
+        "tickIdx": "207240"
+      },
+      {
+        "liquidityGross": "108381237000",
+        "liquidityNet": "-108381237000",
+        "tickIdx": "207250"
+      },
+      {
+        "liquidityGross": "37137476019528",
+        "liquidityNet": "-37137476019528",
+        "tickIdx": "207280"
+      },
+      {
+        "liquidityGross": "1457334165987",
+        "liquidityNet": "-1457334165987",
+        "tickIdx": "207330"
+      },
+      {
+        "liquidityGross": "18550684233560",
+        "liquidityNet": "-18550684233560",
+        "tickIdx": "207350"
+      },
+      {
+        "liquidityGross": "1049687190863",
+        "liquidityNet": "-1049687190863",
+        "tickIdx": "207380"
+      },
+      {
+        "liquidityGross": "6783329557647",
+        "liquidityNet": "-6783329557647",
+        "tickIdx": "207540"
+      },
+      {
+        "liquidityGross": "13425333082288737",
+        "liquidityNet": "-13425333082288737",
+        "tickIdx": "207590"
+      },
+      {
+        "liquidityGross": "17618420282610",
+        "liquidityNet": "-17618420282610",
+        "tickIdx": "207670"
+      },
+      {
+        "liquidityGross": "1024516309504589",
+        "liquidityNet": "-1024516309504589",
+        "tickIdx": "207760"
+      },
+      {
+        "liquidityGross": "33663120308853",
+        "liquidityNet": "-33663120308853",
+        "tickIdx": "207900"
+      },
+      {
+        "liquidityGross": "8",
+        "liquidityNet": "-8",
+        "tickIdx": "207910"
+      },
+      {
+        "liquidityGross": "227351883552117",
+        "liquidityNet": "-227351883552117",
+        "tickIdx": "208180"
+      },
+      {
+        "liquidityGross": "96891010503",
+        "liquidityNet": "-96891010503",
+        "tickIdx": "208250"
+      },
+      {
+        "liquidityGross": "3047476124369",
+        "liquidityNet": "-3047476124369",
+        "tickIdx": "208290"
+      },
+      {
+        "liquidityGross": "469142103728099",
+        "liquidityNet": "-469142103728099",
+        "tickIdx": "208300"
+      },
+      {
+        "liquidityGross": "15816971942115",
+        "liquidityNet": "-15816971942115",
+        "tickIdx": "208430"
+      },
+      {
+        "liquidityGross": "54743213803487",
+        "liquidityNet": "-54743213803487",
+        "tickIdx": "208520"
+      },
+      {
+        "liquidityGross": "1238910149582598",
+        "liquidityNet": "-1238910149582598",
+        "tickIdx": "208650"
+      },
+      {
+        "liquidityGross": "1759243635560",
+        "liquidityNet": "-1759243635560",
+        "tickIdx": "208760"
+      },
+      {
+        "liquidityGross": "116383157570658",
+        "liquidityNet": "-116383157570658",
+        "tickIdx": "208870"
+      },
+      {
+        "liquidityGross": "14039449939",
+        "liquidityNet": "-14039449939",
+        "tickIdx": "209120"
+      },
+      {
+        "liquidityGross": "34367459497789",
+        "liquidityNet": "-34367459497789",
+        "tickIdx": "209140"
+      },
+      {
+        "liquidityGross": "4225060974401",
+        "liquidityNet": "-4225060974401",
+        "tickIdx": "209310"
+      },
+      {
+        "liquidityGross": "9059350051728",
+        "liquidityNet": "-9059350051728",
+        "tickIdx": "209470"
+      },
+      {
+        "liquidityGross": "1084560033827482",
+        "liquidityNet": "-1084560033827482",
+        "tickIdx": "209730"
+      },
+      {
+        "liquidityGross": "191701986657523",
+        "liquidityNet": "-191701986657523",
+        "tickIdx": "209900"
+      },
+      {
+        "liquidityGross": "7222293479401010",
+        "liquidityNet": "-7222293479401010",
+        "tickIdx": "210120"
+      },
+      {
+        "liquidityGross": "228699723058089",
+        "liquidityNet": "-228699723058089",
+        "tickIdx": "210380"
+      },
+      {
+        "liquidityGross": "69273863244383",
+        "liquidityNet": "-69273863244383",
+        "tickIdx": "210810"
+      },
+      {
+        "liquidityGross": "13348687223838",
+        "liquidityNet": "-13348687223838",
+        "tickIdx": "210830"
+      },
+      {
+        "liquidityGross": "2649113876628352",
+        "liquidityNet": "-2649113876628352",
+        "tickIdx": "211310"
+      },
+      {
+        "liquidityGross": "2659554920775",
+        "liquidityNet": "-2659554920775",
+        "tickIdx": "211440"
+      },
+      {
+        "liquidityGross": "4473176297160",
+        "liquidityNet": "-4473176297160",
+        "tickIdx": "211470"
+      },
+      {
+        "liquidityGross": "1367464418001",
+        "liquidityNet": "-1366052033351",
+        "tickIdx": "211550"
+      },
+      {
+        "liquidityGross": "706192325",
+        "liquidityNet": "-706192325",
+        "tickIdx": "211560"
+      },
+      {
+        "liquidityGross": "4860235671811",
+        "liquidityNet": "-4860235671811",
+        "tickIdx": "212080"
+      },
+      {
+        "liquidityGross": "4554637977519",
+        "liquidityNet": "-4554637977519",
+        "tickIdx": "212160"
+      },
+      {
+        "liquidityGross": "1374517388397174",
+        "liquidityNet": "-1374517388397174",
+        "tickIdx": "212350"
+      },
+      {
+        "liquidityGross": "187004715148085",
+        "liquidityNet": "-187004715148085",
+        "tickIdx": "212510"
+      },
+      {
+        "liquidityGross": "13452407513465956",
+        "liquidityNet": "-13452407513465956",
+        "tickIdx": "212600"
+      },
+      {
+        "liquidityGross": "504391575228",
+        "liquidityNet": "-504391575228",
+        "tickIdx": "213060"
+      },
+      {
+        "liquidityGross": "2135569372348945",
+        "liquidityNet": "-2135569372348945",
+        "tickIdx": "214170"
+      },
+      {
+        "liquidityGross": "419968624358816",
+        "liquidityNet": "-419968624358816",
+        "tickIdx": "214210"
+      },
+      {
+        "liquidityGross": "102499926684047",
+        "liquidityNet": "-102499926684047",
+        "tickIdx": "216410"
+      },
+      {
+        "liquidityGross": "470800979737",
+        "liquidityNet": "-470800979737",
+        "tickIdx": "217600"
+      },
+      {
+        "liquidityGross": "123988887729631",
+        "liquidityNet": "-123988887729631",
+        "tickIdx": "218060"
+      },
+      {
+        "liquidityGross": "224082011199",
+        "liquidityNet": "-224082011199",
+        "tickIdx": "219600"
+      },
+      {
+        "liquidityGross": "498531057962448",
+        "liquidityNet": "-498531057962448",
+        "tickIdx": "221220"
+      },
+      {
+        "liquidityGross": "1131670065466301",
+        "liquidityNet": "-1131670065466301",
+        "tickIdx": "221990"
+      },
+      {
+        "liquidityGross": "63150",
+        "liquidityNet": "-63150",
+        "tickIdx": "222430"
+      },
+      {
+        "liquidityGross": "43059560597867",
+        "liquidityNet": "-43059560597867",
+        "tickIdx": "223340"
+      },
+      {
+        "liquidityGross": "1142415145764782",
+        "liquidityNet": "-1142415145764782",
+        "tickIdx": "223370"
+      },
+      {
+        "liquidityGross": "35531670596",
+        "liquidityNet": "-35531670596",
+        "tickIdx": "225630"
+      },
+      {
+        "liquidityGross": "34337229047",
+        "liquidityNet": "-34337229047",
+        "tickIdx": "225700"
+      },
+      {
+        "liquidityGross": "21549469604675",
+        "liquidityNet": "-21381469789743",
+        "tickIdx": "230270"
+      },
+      {
+        "liquidityGross": "1356881278244106196",
+        "liquidityNet": "1356881278244106196",
+        "tickIdx": "238030"
+      },
+      {
+        "liquidityGross": "1356881278244106196",
+        "liquidityNet": "-1356881278244106196",
+        "tickIdx": "238040"
+      },
+      {
+        "liquidityGross": "426144530607",
+        "liquidityNet": "-426144530607",
+        "tickIdx": "242650"
+      },
+      {
+        "liquidityGross": "280102524730",
+        "liquidityNet": "-280102524730",
+        "tickIdx": "249240"
+      },
+      {
+        "liquidityGross": "158140808402",
+        "liquidityNet": "158140808402",
+        "tickIdx": "260820"
+      },
+      {
+        "liquidityGross": "24250664520",
+        "liquidityNet": "-24250664520",
+        "tickIdx": "269390"
+      },
+      {
+        "liquidityGross": "2998064053514881",
+        "liquidityNet": "2998029234933125",
+        "tickIdx": "276300"
+      },
+      {
+        "liquidityGross": "3008582281165689",
+        "liquidityNet": "-2987584474068099",
+        "tickIdx": "276320"
+      },
+      {
+        "liquidityGross": "2266789968",
+        "liquidityNet": "-2266789968",
+        "tickIdx": "292530"
+      },
+      {
+        "liquidityGross": "2273693713",
+        "liquidityNet": "-2273693713",
+        "tickIdx": "292560"
+      },
+      {
+        "liquidityGross": "44739669244",
+        "liquidityNet": "-44739669244",
+        "tickIdx": "292600"
+      },
+      {
+        "liquidityGross": "28436567813",
+        "liquidityNet": "-28436567813",
+        "tickIdx": "310340"
+      },
+      {
+        "liquidityGross": "3216578076775223",
+        "liquidityNet": "3216578076775223",
+        "tickIdx": "344450"
+      },
+      {
+        "liquidityGross": "3216578076775223",
+        "liquidityNet": "-3216578076775223",
+        "tickIdx": "345410"
+      },
+      {
+        "liquidityGross": "1398391284452690",
+        "liquidityNet": "1398391284452690",
+        "tickIdx": "349460"
+      },
+      {
+        "liquidityGross": "2525400803780",
+        "liquidityNet": "-2525400803780",
+        "tickIdx": "349620"
+      },
+      {
+        "liquidityGross": "127004648638536",
+        "liquidityNet": "127004648638536",
+        "tickIdx": "351240"
+      },
+      {
+        "liquidityGross": "166765074643655",
+        "liquidityNet": "-122036674458985",
+        "tickIdx": "352340"
+      },
+      {
+        "liquidityGross": "1252272593613901",
+        "liquidityNet": "-1252272593613901",
+        "tickIdx": "352820"
+      },
+      {
+        "liquidityGross": "47846832113457898",
+        "liquidityNet": "47843396480882960",
+        "tickIdx": "354570"
+      },
+      {
+        "liquidityGross": "1021617313228699",
+        "liquidityNet": "1021617313228699",
+        "tickIdx": "354900"
+      },
+      {
+        "liquidityGross": "13379584971254501",
+        "liquidityNet": "13379584971254501",
+        "tickIdx": "356050"
+      },
+      {
+        "liquidityGross": "511481352845",
+        "liquidityNet": "511481352845",
+        "tickIdx": "356900"
+      },
+      {
+        "liquidityGross": "13379584971254501",
+        "liquidityNet": "-13379584971254501",
+        "tickIdx": "357040"
+      },
+      {
+        "liquidityGross": "3371930637656804",
+        "liquidityNet": "3371930637656804",
+        "tickIdx": "357500"
+      },
+      {
+        "liquidityGross": "1017111148",
+        "liquidityNet": "-1017111148",
+        "tickIdx": "357790"
+      },
+      {
+        "liquidityGross": "297698757815726047",
+        "liquidityNet": "297698757815726047",
+        "tickIdx": "358040"
+      },
+      {
+        "liquidityGross": "297698757815726047",
+        "liquidityNet": "-297698757815726047",
+        "tickIdx": "358080"
+      },
+      {
+        "liquidityGross": "3371930637656804",
+        "liquidityNet": "-3371930637656804",
+        "tickIdx": "359140"
+      },
+      {
+        "liquidityGross": "47845114297170429",
+        "liquidityNet": "-47845114297170429",
+        "tickIdx": "359260"
+      },
+      {
+        "liquidityGross": "1021617313228699",
+        "liquidityNet": "-1021617313228699",
+        "tickIdx": "359660"
+      },
+      {
+        "liquidityGross": "103724168037440",
+        "liquidityNet": "-103724168037440",
+        "tickIdx": "361500"
+      },
+      {
+        "liquidityGross": "11099171960",
+        "liquidityNet": "11099171960",
+        "tickIdx": "391460"
+      },
+      {
+        "liquidityGross": "1205661623580111",
+        "liquidityNet": "1205661623580111",
+        "tickIdx": "407550"
+      },
+      {
+        "liquidityGross": "1350483406084573",
+        "liquidityNet": "-1350483406084573",
+        "tickIdx": "414490"
+      },
+      {
+        "liquidityGross": "398290794261",
+        "liquidityNet": "-398290794261",
+        "tickIdx": "759890"
+      },
+      {
+        "liquidityGross": "45039377467845144",
+        "liquidityNet": "-45039377467845144",
+        "tickIdx": "887270"
+      }
+    ]`
+
+	pool := uniswapv3.Pool{
+		PoolViewMinimal: uniswapv3.PoolViewMinimal{
+			ID:           poolID,
+			Token0:       token0ID, // USDC (6 decimals)
+			Token1:       token1ID, // WETH (18 decimals)
+			Fee:          3000,
+			TickSpacing:  10, // Common for stable/volatile pairs
+			Tick:         193540,
+			Liquidity:    bigIntFromString("4411461329627947710"),                // Liquidity at the starting tick
+			SqrtPriceX96: bigIntFromString("1262831046415630070062062910819682"), // Price at tick 0
+		},
+		Ticks: make([]uniswapv3.TickInfo, 0, len(rawTicks)),
+	}
+
+	err := json.Unmarshal([]byte(rawTicksJson), &rawTicks)
+	if err != nil {
+		panic(err)
+	}
+	for _, rt := range rawTicks {
+		tickIdx, _ := new(big.Int).SetString(rt.TickIdx, 10)
+		t := uniswapv3.TickInfo{
+			Index:        tickIdx.Int64(),
+			LiquidityNet: bigIntFromString(rt.LiquidityNet),
+		}
+
+		if t.LiquidityNet.Sign() != 0 {
+			pool.Ticks = append(pool.Ticks, t)
+		}
+
+	}
+
+	return pool
+}
+
+type mockIndexedPoolRegistry struct {
+	poolsByID      map[uint64]poolregistry.Pool
+	poolsByAddress map[addr.Address]poolregistry.Pool
+}
+
+func newMockIndexedPoolRegistry() *mockIndexedPoolRegistry {
+	return &mockIndexedPoolRegistry{
+		poolsByID:      make(map[uint64]poolregistry.Pool),
+		poolsByAddress: make(map[addr.Address]poolregistry.Pool),
+	}
+}
+func (m *mockIndexedPoolRegistry) GetByID(id uint64) (poolregistry.Pool, bool) {
+	p, ok := m.poolsByID[id]
+	return p, ok
+}
+func (m *mockIndexedPoolRegistry) GetByAddress(address addr.Address) (poolregistry.Pool, bool) {
+	p, ok := m.poolsByAddress[address]
+	return p, ok
+}
+func (m *mockIndexedPoolRegistry) GetByPoolKey(key poolregistry.PoolKey) (poolregistry.Pool, bool) {
+	return poolregistry.Pool{}, false
+}
+func (m *mockIndexedPoolRegistry) All() []poolregistry.Pool {
+	all := make([]poolregistry.Pool, 0, len(m.poolsByID))
+	for _, p := range m.poolsByID {
+		all = append(all, p)
+	}
+	return all
+}
+
+func (m *mockIndexedPoolRegistry) GetProtocols() map[uint16]engine.ProtocolID {
+	return map[uint16]engine.ProtocolID{
+		uniswapV2PoolRegistryID: uniswapV2ProtocolID,
+		uniswapV3PoolRegistryID: uniswapV3ProtocolID,
+	}
+}
+
+type mockIndexedUniswapV2 struct {
+	poolsByID map[uint64]uniswapv2.Pool
+}
+
+func newMockIndexedUniswapV2() *mockIndexedUniswapV2 {
+	return &mockIndexedUniswapV2{poolsByID: make(map[uint64]uniswapv2.Pool)}
+}
+func (m *mockIndexedUniswapV2) GetByID(id uint64) (uniswapv2.Pool, bool) {
+	p, ok := m.poolsByID[id]
+	return p, ok
+}
+func (m *mockIndexedUniswapV2) All() []uniswapv2.Pool {
+	all := make([]uniswapv2.Pool, 0, len(m.poolsByID))
+	for _, p := range m.poolsByID {
+		all = append(all, p)
+	}
+	return all
+}
+
+type mockIndexedUniswapV3 struct {
+	poolsByID map[uint64]uniswapv3.Pool
+}
+
+func newMockIndexedUniswapV3() *mockIndexedUniswapV3 {
+	return &mockIndexedUniswapV3{poolsByID: make(map[uint64]uniswapv3.Pool)}
+}
+func (m *mockIndexedUniswapV3) GetByID(id uint64) (uniswapv3.Pool, bool) {
+	p, ok := m.poolsByID[id]
+	return p, ok
+}
+func (m *mockIndexedUniswapV3) All() []uniswapv3.Pool {
+	all := make([]uniswapv3.Pool, 0, len(m.poolsByID))
+	for _, p := range m.poolsByID {
+		all = append(all, p)
+	}
+	return all
+}
+
+// ##################################################################
+// #                   TEST HELPER FUNCTION                         #
+// ##################################################################
+
+func NewMockGraphRequirements(
+	t require.TestingT,
+	tokens map[uint64]common.Address,
+	pools map[uint64]common.Address,
+	uniswapV2Pools []uniswapv2.Pool,
+	uniswapV3Pools []uniswapv3.Pool,
+) (
+	*tokenpoolregistry.TokenPoolRegistryView,
+	poolregistryindexer.IndexedPoolRegistry,
+	uniswapv2indexer.IndexedUniswapV2,
+	uniswapv3indexer.IndexedUniswapV3,
+) {
+	tokenPoolSystem := tokenpoolregistry.NewTokenPoolSystem(100)
+	mockPoolRegistry := newMockIndexedPoolRegistry()
+	mockV2View := newMockIndexedUniswapV2()
+	mockV3View := newMockIndexedUniswapV3()
+
+	// Process Uniswap V2 pools.
+	for _, pool := range uniswapV2Pools {
+		poolAddress, ok := pools[pool.ID]
+		if !ok {
+			t.Errorf("Address for V2 pool ID %d not found in pools map", pool.ID)
+		}
+		genericPool := poolregistry.Pool{ID: pool.ID, Key: poolregistry.AddressToPoolKey(addr.FromCommon(poolAddress)), Protocol: uniswapV2PoolRegistryID}
+		mockPoolRegistry.poolsByID[pool.ID] = genericPool
+		mockPoolRegistry.poolsByAddress[addr.FromCommon(poolAddress)] = genericPool
+		mockV2View.poolsByID[pool.ID] = pool
+		tokenPoolSystem.AddPool([]uint64{pool.Token0, pool.Token1}, pool.ID)
+	}
+
+	// Process Uniswap V3 pools.
+	for _, pool := range uniswapV3Pools {
+		poolAddress, ok := pools[pool.ID]
+		if !ok {
+			t.Errorf("Address for V3 pool ID %d not found in pools map", pool.ID)
+		}
+		genericPool := poolregistry.Pool{ID: pool.ID, Key: poolregistry.AddressToPoolKey(addr.FromCommon(poolAddress)), Protocol: uniswapV3PoolRegistryID}
+		mockPoolRegistry.poolsByID[pool.ID] = genericPool
+		mockPoolRegistry.poolsByAddress[addr.FromCommon(poolAddress)] = genericPool
+		mockV3View.poolsByID[pool.ID] = pool
+		tokenPoolSystem.AddPool([]uint64{pool.PoolViewMinimal.Token0, pool.PoolViewMinimal.Token1}, pool.ID)
+	}
+
+	tokenPoolsView := tokenPoolSystem.View()
+
+	return tokenPoolsView, mockPoolRegistry, mockV2View, mockV3View
+}
+
+// setupSimpleTestGraph initializes a graph instance with standard test data.
+func setupSimpleTestGraph(t *testing.T, activePools map[uint64]struct{}) (*Graph, *tokenpoolregistry.TokenPoolRegistryView, poolregistryindexer.IndexedPoolRegistry, uniswapv2indexer.IndexedUniswapV2, uniswapv3indexer.IndexedUniswapV3) {
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"), // WETH
+		2: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), // USDC
+		3: common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F"), // DAI
+		4: common.HexToAddress("0x2260FAC5E5542a773Aa44fBCfeDf7C193bc2C599"), // WBTC
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc"), // V2 WETH/USDC
+		102: common.HexToAddress("0xC2e9F25Be6257c210d7Adf0D4Cd6E3E881ba25f8"), // V3 WETH/DAI
+		103: common.HexToAddress("0x3416cF6C70874d726580E6A94317C22e08432E73"), // PancakeV3 USDC/DAI
+		104: common.HexToAddress("0xBb2b8038a1640196FbE3e38816F3e67Cba72D940"), // V2 WETH/WBTC
+	}
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: big.NewInt(1000), Reserve1: big.NewInt(2000000), FeeBps: 30},
+		{ID: 104, Token0: 1, Token1: 4, Reserve0: big.NewInt(500), Reserve1: big.NewInt(10), FeeBps: 30},
+	}
+
+	uniswapV3Pools := []uniswapv3.Pool{
+		{PoolViewMinimal: uniswapv3.PoolViewMinimal{ID: 102, Token0: 1, Token1: 3, Fee: 3000}},
+	}
+
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, uniswapV3Pools)
+
+	protocolIDToProtocolSchema := map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}
+
+	protocolResolver := chains.NewProtocolResolver(
+		protocolIDToProtocolSchema,
+		poolRegistry,
+	)
+
+	graph, err := NewGraph(
+		rawGraph,
+		nil,
+		poolRegistry,
+		v2View,
+		v3View,
+		activePools,
+		protocolResolver,
+	)
+
+	require.NoError(t, err)
+	return graph, rawGraph, poolRegistry, v2View, v3View
+}
+
+func TestNewGraph(t *testing.T) {
+
+	t.Run("Success", func(t *testing.T) {
+		activePools := map[uint64]struct{}{101: {}, 102: {}, 103: {}} // Pool 104 is not active
+		graph, _, _, _, _ := setupSimpleTestGraph(t, activePools)
+		require.NotNil(t, graph)
+		// Check that lookup maps are correctly populated
+		assert.Len(t, graph.tokenToIndex, 4, "tokenToIndex should have 4 tokens")
+		assert.Len(t, graph.poolToIndex, 3, "poolToIndex should have 3 pools")
+		assert.Equal(t, 0, graph.tokenToIndex[1]) // WETH should be at index 0
+		assert.Equal(t, 1, graph.tokenToIndex[2]) // USDC should be at index 1
+
+		// Check that function slices are populated correctly
+		require.Len(t, graph.allGetAmountOutFuncs, 3)
+		require.Len(t, graph.getReservesFuncs, 3)
+		require.Len(t, graph.activeGetAmountOutFuncs, 3)
+
+		// Get internal indices for pools
+		pool101Index := graph.poolToIndex[101]
+		pool102Index := graph.poolToIndex[102]
+		pool103Index := graph.poolToIndex[103]
+		pool104Index := graph.poolToIndex[104]
+
+		// All pools should have a function in the 'all' slices
+		assert.NotNil(t, graph.allGetAmountOutFuncs[pool101Index])
+		assert.NotNil(t, graph.getReservesFuncs[pool101Index])
+		assert.NotNil(t, graph.allGetAmountOutFuncs[pool102Index])
+		assert.NotNil(t, graph.getReservesFuncs[pool102Index])
+		assert.NotNil(t, graph.allGetAmountOutFuncs[pool103Index])
+		assert.NotNil(t, graph.getReservesFuncs[pool103Index])
+		assert.NotNil(t, graph.allGetAmountOutFuncs[pool104Index])
+		assert.NotNil(t, graph.getReservesFuncs[pool104Index])
+
+		// Only active pools should have a function in the 'active' slice
+		assert.NotNil(t, graph.activeGetAmountOutFuncs[pool101Index])
+		assert.NotNil(t, graph.activeGetAmountOutFuncs[pool102Index])
+		assert.NotNil(t, graph.activeGetAmountOutFuncs[pool103Index])
+		assert.Nil(t, graph.activeGetAmountOutFuncs[pool104Index], "Inactive pool should not have an active amount out function")
+	})
+
+	t.Run("Empty Graph no error", func(t *testing.T) {
+		emptyTokens := make(map[uint64]common.Address)
+		emptyPools := make(map[uint64]common.Address)
+		emptyV2 := make([]uniswapv2.Pool, 0)
+		emptyV3 := make([]uniswapv3.Pool, 0)
+		emptyActive := make(map[uint64]struct{})
+		tokenPoolView, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, emptyTokens, emptyPools, emptyV2, emptyV3)
+
+		protocolIDToProtocolSchema := map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+			uniswapV3ProtocolID: uniswapv3.Schema,
+		}
+
+		protocolResolver := chains.NewProtocolResolver(
+			protocolIDToProtocolSchema,
+			poolRegistry,
+		)
+
+		graph, err := NewGraph(
+			tokenPoolView,
+			nil,
+			poolRegistry,
+			v2View,
+			v3View,
+			emptyActive,
+			protocolResolver,
+		)
+		require.NoError(t, err)
+		require.NotNil(t, graph)
+		assert.Empty(t, graph.tokenToIndex)
+		assert.Empty(t, graph.poolToIndex)
+		assert.Empty(t, graph.allGetAmountOutFuncs)
+		assert.Empty(t, graph.getReservesFuncs)
+		assert.Empty(t, graph.activeGetAmountOutFuncs)
+	})
+
+	/*
+		t.Run("Validation Failure", func(t *testing.T) {
+			// Setup the dependencies but we only need the views for this test.
+			_, _, poolRegistry, v2View, v3View, pancakeV3View := setupSimpleTestGraph(t, nil)
+
+			// Pass a nil tokenPool view to trigger the validation error.
+			_, err := NewGraph(nil, poolRegistry, v2View, v3View, pancakeV3View, nil)
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "graph data validation failed")
+			assert.Contains(t, err.Error(), "TokenPoolRegistryView cannot be nil")
+		})
+	*/
+}
+
+func TestGetPoolsForToken(t *testing.T) {
+	graph, _, _, _, _ := setupSimpleTestGraph(t, map[uint64]struct{}{})
+
+	t.Run("Token in multiple pools", func(t *testing.T) {
+		// WETH (ID 1) is in pools 101, 102, and 104
+		wethPools, err := graph.GetPoolsForToken(1)
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []uint64{101, 102, 104}, wethPools)
+	})
+
+	t.Run("Token in different multiple pools", func(t *testing.T) {
+		// DAI (ID 3) is in pools 102
+		daiPools, err := graph.GetPoolsForToken(3)
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []uint64{102}, daiPools)
+	})
+
+	t.Run("Non-existent token", func(t *testing.T) {
+		nonExistentPools, err := graph.GetPoolsForToken(999)
+		assert.NoError(t, err)
+		assert.Nil(t, nonExistentPools)
+	})
+}
+
+func TestGetTokensForPool(t *testing.T) {
+	graph, _, _, _, _ := setupSimpleTestGraph(t, map[uint64]struct{}{})
+
+	t.Run("V2 Pool", func(t *testing.T) {
+		v2Tokens, err := graph.GetTokensForPool(101)
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []uint64{1, 2}, v2Tokens)
+	})
+
+	t.Run("V3 Pool", func(t *testing.T) {
+		v3Tokens, err := graph.GetTokensForPool(102)
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []uint64{1, 3}, v3Tokens)
+	})
+
+	t.Run("Non-existent pool", func(t *testing.T) {
+		nonExistentTokens, err := graph.GetTokensForPool(999)
+		assert.Error(t, err)
+		assert.Nil(t, nonExistentTokens)
+	})
+}
+
+func TestGetPoolState(t *testing.T) {
+	graph, _, _, v2View, v3View := setupSimpleTestGraph(t, map[uint64]struct{}{})
+
+	t.Run("V2 pool", func(t *testing.T) {
+		schema, state, ok := graph.GetPoolState(101)
+		require.True(t, ok)
+		assert.Equal(t, uniswapv2.Schema, schema)
+		pool, isV2 := state.(uniswapv2.Pool)
+		require.True(t, isV2)
+		expected, _ := v2View.GetByID(101)
+		assert.Equal(t, expected, pool)
+	})
+
+	t.Run("V3 pool", func(t *testing.T) {
+		schema, state, ok := graph.GetPoolState(102)
+		require.True(t, ok)
+		assert.Equal(t, uniswapv3.Schema, schema)
+		pool, isV3 := state.(uniswapv3.Pool)
+		require.True(t, isV3)
+		expected, _ := v3View.GetByID(102)
+		assert.Equal(t, expected, pool)
+	})
+
+	t.Run("Unknown pool", func(t *testing.T) {
+		_, state, ok := graph.GetPoolState(999)
+		assert.False(t, ok)
+		assert.Nil(t, state)
+	})
+}
+
+func TestUniswapV4Pools(t *testing.T) {
+	liquidity := new(big.Int).Exp(big.NewInt(10), big.NewInt(21), nil)
+	lpFee := uint64(500)
+	v4Pools := []uniswapv4.Pool{
+		{
+			ID: 201, Token0: 1, Token1: 2, Fee: uniswapv4.DynamicFeeFlag, LPFee: &lpFee, TickSpacing: 60,
+			Liquidity: liquidity, SqrtPriceX96: new(big.Int).Set(uniswapv3calculator.Q96),
+			Ticks: []uniswapv4.TickInfo{
+				{Index: -600, LiquidityGross: liquidity, LiquidityNet: liquidity},
+				{Index: 600, LiquidityGross: liquidity, LiquidityNet: new(big.Int).Neg(liquidity)},
+			},
+		},
+		// A dynamic fee the stream does not report cannot be quoted.
+		{ID: 202, Token0: 1, Token1: 2, Fee: uniswapv4.DynamicFeeFlag, Liquidity: liquidity, SqrtPriceX96: new(big.Int).Set(uniswapv3calculator.Q96)},
+	}
+	v3Form, unknownFee := uniswapv4calculator.V3Pools(v4Pools)
+	require.Equal(t, []uint64{202}, unknownFee)
+
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"),
+		2: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"),
+	}
+	pools := map[uint64]common.Address{201: common.HexToAddress("0x01")}
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, nil, v3Form)
+	resolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV3ProtocolID: uniswapv4.Schema,
+	}, poolRegistry)
+
+	graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{201: {}}, resolver)
+	require.NoError(t, err)
+
+	schema, state, ok := graph.GetPoolState(201)
+	require.True(t, ok)
+	assert.Equal(t, uniswapv4.Schema, schema)
+	assert.IsType(t, uniswapv3.Pool{}, state)
+
+	amount := big.NewInt(1_000_000_000_000_000)
+	quote, err := graph.QuoteBothDirections(1, 2, amount)
+	require.NoError(t, err)
+	assert.Equal(t, []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 2, PoolID: 201}}, quote.Forward)
+	expected, err := uniswapv4calculator.GetAmountOut(amount, nil, 1, v4Pools[0])
+	require.NoError(t, err)
+	assert.Equal(t, expected, quote.ForwardOut)
+}
+
+func TestSolidlyPools(t *testing.T) {
+	e18 := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	reserve := new(big.Int).Mul(big.NewInt(1_000_000), e18)
+	solidlyPools := []solidly.Pool{
+		{ID: 301, Token0: 1, Token1: 2, Reserve0: reserve, Reserve1: reserve, Stable: true, FeeBps: 5, Decimals0: 18, Decimals1: 18},
+		{ID: 302, Token0: 1, Token1: 2, Reserve0: reserve, Reserve1: reserve, FeeBps: 30, Decimals0: 18, Decimals1: 18},
+	}
+
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F"),
+		2: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"),
+	}
+	pools := map[uint64]common.Address{301: common.HexToAddress("0x01"), 302: common.HexToAddress("0x02")}
+	// Register the pools under a protocol resolved to the Solidly schema; the V2 view is
+	// only used to populate the registries.
+	asV2 := []uniswapv2.Pool{{ID: 301, Token0: 1, Token1: 2}, {ID: 302, Token0: 1, Token1: 2}}
+	rawGraph, poolRegistry, _, v3View := NewMockGraphRequirements(t, tokens, pools, asV2, nil)
+	resolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: solidly.Schema,
+	}, poolRegistry)
+
+	graph, err := newGraph(
+		rawGraph, nil, poolRegistry, newMockIndexedUniswapV2(), v3View,
+		solidlyindexer.NewIndexableSolidlySystem(solidlyPools),
+		map[uint64]struct{}{301: {}, 302: {}}, resolver, nil, nil, SkipDuplicatePools, nil,
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 2, graph.BuildReport().RoutablePools)
+
+	schema, state, ok := graph.GetPoolState(301)
+	require.True(t, ok)
+	assert.Equal(t, solidly.Schema, schema)
+	assert.IsType(t, solidly.Pool{}, state)
+
+	// The stable curve is flatter around the peg, so it beats the volatile pool despite
+	// sharing its reserves.
+	amount := new(big.Int).Mul(big.NewInt(10_000), e18)
+	quote, err := graph.QuoteBothDirections(1, 2, amount)
+	require.NoError(t, err)
+	assert.Equal(t, []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 2, PoolID: 301}}, quote.Forward)
+	expected, err := solidlycalculator.GetAmountOut(amount, 1, 2, solidlyPools[0])
+	require.NoError(t, err)
+	assert.Equal(t, expected, quote.ForwardOut)
+}
+
+func TestQuoteBothDirections(t *testing.T) {
+	t.Run("Single pool", func(t *testing.T) {
+		// Only pool 101 (token 1/token 2) is active.
+		graph, _, _, _, _ := setupSimpleTestGraph(t, map[uint64]struct{}{101: {}})
+		amount := big.NewInt(1_000_000)
+
+		quote, err := graph.QuoteBothDirections(1, 2, amount)
+		require.NoError(t, err)
+		assert.Equal(t, []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 2, PoolID: 101}}, quote.Forward)
+		assert.Equal(t, []chains.TokenPoolPath{{TokenInID: 2, TokenOutID: 1, PoolID: 101}}, quote.Reverse)
+		assert.Positive(t, quote.ForwardOut.Sign())
+		assert.Positive(t, quote.ReverseOut.Sign())
+
+		// Fees and price impact are paid on both legs, so the round trip loses value.
+		assert.Positive(t, quote.Spread().Sign())
+		assert.Equal(t, amount, quote.AmountIn)
+	})
+
+	t.Run("Unconnected pair", func(t *testing.T) {
+		graph, _, _, _, _ := setupSimpleTestGraph(t, map[uint64]struct{}{101: {}})
+		_, err := graph.QuoteBothDirections(1, 999, big.NewInt(1_000))
+		assert.Error(t, err)
+	})
+
+	t.Run("Invalid amount", func(t *testing.T) {
+		graph, _, _, _, _ := setupSimpleTestGraph(t, nil)
+		_, err := graph.QuoteBothDirections(1, 2, big.NewInt(0))
+		assert.Error(t, err)
+	})
+}
+
+func TestWeightedPrice(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0x1"), // WETH
+		2: common.HexToAddress("0x2"), // USDC
+		3: common.HexToAddress("0x3"), // unconnected
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"),
+		102: common.HexToAddress("0x102"),
+	}
+	uniswapV2Pools := []uniswapv2.Pool{
+		// A deep, honest pool: 1,000 WETH against 3,000,000 USDC (3,000 USDC per WETH).
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(1_000), d18), Reserve1: big.NewInt(3_000_000e6), FeeBps: 30},
+		// A thin pool pushed to ten times the price: 0.1 WETH against 3,000 USDC.
+		{ID: 102, Token0: 1, Token1: 2, Reserve0: new(big.Int).Div(d18, big.NewInt(10)), Reserve1: big.NewInt(3_000e6), FeeBps: 30},
+	}
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+	protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}, poolRegistry)
+	tokenRegistry := tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+		{ID: 1, Symbol: "WETH", Decimals: 18},
+		{ID: 2, Symbol: "USDC", Decimals: 6},
+		{ID: 3, Symbol: "XYZ", Decimals: 18},
+	})
+	graph, err := NewGraph(rawGraph, tokenRegistry, poolRegistry, v2View, v3View, map[uint64]struct{}{}, protocolResolver)
+	require.NoError(t, err)
+
+	t.Run("Thin manipulated pool barely moves the price", func(t *testing.T) {
+		price, err := graph.WeightedPrice(1, 2)
+		require.NoError(t, err)
+		f, _ := price.Float64()
+		// An unweighted mean of the two spot prices would be 16,500.
+		assert.InDelta(t, 3000, f, 10)
+		assert.Greater(t, f, 3000.0, "the manipulated pool still contributes a little")
+	})
+
+	t.Run("Inverse direction", func(t *testing.T) {
+		price, err := graph.WeightedPrice(2, 1)
+		require.NoError(t, err)
+		f, _ := price.Float64()
+		assert.InDelta(t, 1.0/3000, f, 1e-6)
+	})
+
+	t.Run("Same token", func(t *testing.T) {
+		price, err := graph.WeightedPrice(1, 1)
+		require.NoError(t, err)
+		assert.Equal(t, 0, price.Cmp(big.NewFloat(1)))
+	})
+
+	t.Run("No shared pool", func(t *testing.T) {
+		_, err := graph.WeightedPrice(1, 3)
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+
+	t.Run("V3 pool is weighted with its virtual reserves", func(t *testing.T) {
+		// WETH/USDC is held by V2 pool 101 and V3 pool 102; without token metadata
+		// the price is in raw units.
+		graph, _, v2View, v3View := setupGetExchangeRatesGraph(t, map[uint64]struct{}{})
+		v2Pool, _ := v2View.GetByID(101)
+		v3Pool, _ := v3View.GetByID(102)
+		v2Price := new(big.Float).Quo(new(big.Float).SetInt(v2Pool.Reserve1), new(big.Float).SetInt(v2Pool.Reserve0))
+		wethReserve, usdcReserve, err := uniswapv3calculator.GetVirtualReserves(1, 2, v3Pool)
+		require.NoError(t, err)
+		v3Price := new(big.Float).Quo(new(big.Float).SetInt(usdcReserve), new(big.Float).SetInt(wethReserve))
+
+		price, err := graph.WeightedPrice(1, 2)
+		require.NoError(t, err)
+		low, high := v2Price, v3Price
+		if low.Cmp(high) > 0 {
+			low, high = high, low
+		}
+		assert.True(t, price.Cmp(low) >= 0 && price.Cmp(high) <= 0, "price %s should lie between %s and %s", price, low, high)
+	})
+}
+
+func TestMostMispricedPool(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0x1"), // WETH
+		2: common.HexToAddress("0x2"), // USDC
+		3: common.HexToAddress("0x3"), // unconnected
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"),
+		102: common.HexToAddress("0x102"),
+		103: common.HexToAddress("0x103"),
+	}
+	wethReserve := new(big.Int).Mul(big.NewInt(100), d18)
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: wethReserve, Reserve1: big.NewInt(300_000e6), FeeBps: 30}, // 3,000 USDC per WETH
+		{ID: 102, Token0: 1, Token1: 2, Reserve0: wethReserve, Reserve1: big.NewInt(306_000e6), FeeBps: 30}, // 3,060
+		{ID: 103, Token0: 2, Token1: 1, Reserve0: big.NewInt(240_000e6), Reserve1: wethReserve, FeeBps: 30}, // 2,400, tokens reversed
+	}
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+	protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}, poolRegistry)
+	tokenRegistry := tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+		{ID: 1, Symbol: "WETH", Decimals: 18},
+		{ID: 2, Symbol: "USDC", Decimals: 6},
+		{ID: 3, Symbol: "XYZ", Decimals: 18},
+	})
+	graph, err := NewGraph(rawGraph, tokenRegistry, poolRegistry, v2View, v3View, map[uint64]struct{}{}, protocolResolver)
+	require.NoError(t, err)
+
+	t.Run("Cheap pool is bought from", func(t *testing.T) {
+		mispricing, err := graph.MostMispricedPool(1, 2, big.NewFloat(3000))
+		require.NoError(t, err)
+		assert.Equal(t, uint64(103), mispricing.PoolID)
+		assert.Equal(t, chains.TokenPoolPath{TokenInID: 2, TokenOutID: 1, PoolID: 103}, mispricing.Swap)
+
+		spot, _ := mispricing.SpotPrice.Float64()
+		assert.InDelta(t, 2400, spot, 1e-6)
+		deviation, _ := mispricing.Deviation.Float64()
+		assert.InDelta(t, -0.2, deviation, 1e-9)
+	})
+
+	t.Run("Expensive pool is sold into", func(t *testing.T) {
+		// Against an external price of 2,400, pool 102 is the furthest above it.
+		mispricing, err := graph.MostMispricedPool(1, 2, big.NewFloat(2400))
+		require.NoError(t, err)
+		assert.Equal(t, uint64(102), mispricing.PoolID)
+		assert.Equal(t, chains.TokenPoolPath{TokenInID: 1, TokenOutID: 2, PoolID: 102}, mispricing.Swap)
+		deviation, _ := mispricing.Deviation.Float64()
+		assert.InDelta(t, 0.275, deviation, 1e-9)
+	})
+
+	t.Run("Pair given in reverse", func(t *testing.T) {
+		// 1/3,000 WETH per USDC: pool 103 makes USDC expensive, so USDC is sold there.
+		mispricing, err := graph.MostMispricedPool(2, 1, new(big.Float).Quo(big.NewFloat(1), big.NewFloat(3000)))
+		require.NoError(t, err)
+		assert.Equal(t, uint64(103), mispricing.PoolID)
+		assert.Equal(t, chains.TokenPoolPath{TokenInID: 2, TokenOutID: 1, PoolID: 103}, mispricing.Swap)
+		assert.Positive(t, mispricing.Deviation.Sign())
+	})
+
+	t.Run("Invalid input", func(t *testing.T) {
+		_, err := graph.MostMispricedPool(1, 2, big.NewFloat(0))
+		assert.Error(t, err)
+		_, err = graph.MostMispricedPool(1, 2, nil)
+		assert.Error(t, err)
+		_, err = graph.MostMispricedPool(1, 1, big.NewFloat(1))
+		assert.Error(t, err)
+	})
+
+	t.Run("No shared pool", func(t *testing.T) {
+		_, err := graph.MostMispricedPool(1, 3, big.NewFloat(1))
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+}
+
+func TestDepthCurves(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	weth := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), d18) }
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0x1"), // WETH
+		2: common.HexToAddress("0x2"), // USDC
+		3: common.HexToAddress("0x3"), // XYZ
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"),
+		102: common.HexToAddress("0x102"),
+		103: common.HexToAddress("0x103"),
+	}
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: weth(10), Reserve1: big.NewInt(30_000e6), FeeBps: 30},      // shallow
+		{ID: 102, Token0: 2, Token1: 1, Reserve0: big.NewInt(3_000_000e6), Reserve1: weth(1000), FeeBps: 30}, // deep, tokens reversed
+		{ID: 103, Token0: 1, Token1: 3, Reserve0: weth(1000), Reserve1: weth(1000), FeeBps: 30},              // another pair
+	}
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+	protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}, poolRegistry)
+	tokenRegistry := tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+		{ID: 1, Symbol: "WETH", Decimals: 18},
+		{ID: 2, Symbol: "USDC", Decimals: 6},
+		{ID: 3, Symbol: "XYZ", Decimals: 18},
+	})
+	graph, err := NewGraph(rawGraph, tokenRegistry, poolRegistry, v2View, v3View, map[uint64]struct{}{}, protocolResolver)
+	require.NoError(t, err)
+
+	params := chains.DepthCurveParams{
+		TokenInID:   1,
+		TokenOutID:  2,
+		MinAmountIn: new(big.Int).Div(d18, big.NewInt(1000)),
+		MaxAmountIn: weth(10),
+		Points:      5,
+	}
+
+	t.Run("Pools of the pair, deepest first", func(t *testing.T) {
+		curves, err := graph.DepthCurves(params)
+		require.NoError(t, err)
+		require.Len(t, curves, 2)
+		assert.Equal(t, uint64(102), curves[0].PoolID)
+		assert.Equal(t, uint64(101), curves[1].PoolID)
+
+		for _, curve := range curves {
+			assert.Equal(t, uniswapv2.Schema, curve.Schema)
+			require.Len(t, curve.Points, params.Points)
+			assert.Equal(t, params.MinAmountIn, curve.Points[0].AmountIn)
+			assert.Equal(t, params.MaxAmountIn, curve.Points[params.Points-1].AmountIn)
+
+			pool, _ := graph.indexedUniswapV2.GetByID(curve.PoolID)
+			for i, point := range curve.Points {
+				expected, err := uniswapv2calculator.GetAmountOut(point.AmountIn, 1, 2, pool)
+				require.NoError(t, err)
+				assert.Equal(t, expected, point.AmountOut)
+				if i > 0 {
+					// Sizes grow by a constant factor, 10 here, and prices fall.
+					ratio, _ := new(big.Float).Quo(new(big.Float).SetInt(point.AmountIn), new(big.Float).SetInt(curve.Points[i-1].AmountIn)).Float64()
+					assert.InDelta(t, 10, ratio, 1e-9)
+					assert.Equal(t, -1, point.MarginalPrice.Cmp(curve.Points[i-1].MarginalPrice))
+				}
+			}
+		}
+
+		// The smallest size barely moves the price from 3,000 USDC per WETH.
+		price, _ := curves[0].Points[0].MarginalPrice.Float64()
+		assert.InDelta(t, 3000, price, 0.01)
+		// Selling 10 WETH into the shallow pool doubles its WETH reserve and halves its USDC.
+		price, _ = curves[1].Points[params.Points-1].MarginalPrice.Float64()
+		assert.InDelta(t, 751.13, price, 0.01)
+	})
+
+	t.Run("Invalid params", func(t *testing.T) {
+		invalid := params
+		invalid.Points = 1
+		_, err := graph.DepthCurves(invalid)
+		assert.Error(t, err)
+
+		invalid = params
+		invalid.MinAmountIn, invalid.MaxAmountIn = params.MaxAmountIn, params.MinAmountIn
+		_, err = graph.DepthCurves(invalid)
+		assert.Error(t, err)
+	})
+
+	t.Run("No pool of the pair", func(t *testing.T) {
+		noPair := params
+		noPair.TokenInID, noPair.TokenOutID = 2, 3
+		_, err := graph.DepthCurves(noPair)
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+}
+
+func TestTriangleDeviation(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	units := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), d18) }
+	setup := func(t *testing.T, reserveCA1 *big.Int) *Graph {
+		tokens := map[uint64]common.Address{
+			1: common.HexToAddress("0xA"),
+			2: common.HexToAddress("0xB"),
+			3: common.HexToAddress("0xC"),
+			4: common.HexToAddress("0xD"), // connected to A only
+		}
+		pools := map[uint64]common.Address{
+			101: common.HexToAddress("0x101"),
+			102: common.HexToAddress("0x102"),
+			103: common.HexToAddress("0x103"),
+			104: common.HexToAddress("0x104"),
+		}
+		uniswapV2Pools := []uniswapv2.Pool{
+			{ID: 101, Token0: 1, Token1: 2, Reserve0: units(100), Reserve1: units(200), FeeBps: 30}, // 1 A = 2 B
+			{ID: 102, Token0: 2, Token1: 3, Reserve0: units(100), Reserve1: units(300), FeeBps: 30}, // 1 B = 3 C
+			{ID: 103, Token0: 3, Token1: 1, Reserve0: units(600), Reserve1: reserveCA1, FeeBps: 30}, // C/A
+			{ID: 104, Token0: 1, Token1: 4, Reserve0: units(100), Reserve1: units(100), FeeBps: 30},
+		}
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+		protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+			uniswapV3ProtocolID: uniswapv3.Schema,
+		}, poolRegistry)
+		graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{}, protocolResolver)
+		require.NoError(t, err)
+		return graph
+	}
+
+	t.Run("Consistent triangle", func(t *testing.T) {
+		// 600 C against 100 A: 1 C = 1/6 A, which closes the cycle exactly.
+		graph := setup(t, units(100))
+		for _, order := range [][3]uint64{{1, 2, 3}, {2, 3, 1}, {1, 3, 2}} {
+			deviation, err := graph.TriangleDeviation(order[0], order[1], order[2])
+			require.NoError(t, err)
+			f, _ := deviation.Float64()
+			assert.InDelta(t, 0, f, 1e-12, "order %v", order)
+		}
+	})
+
+	t.Run("Triangle with an arbitrage", func(t *testing.T) {
+		// 600 C against 120 A: 1 C = 0.2 A, so A -> B -> C -> A returns 1.2 A per A.
+		graph := setup(t, units(120))
+
+		deviation, err := graph.TriangleDeviation(1, 2, 3)
+		require.NoError(t, err)
+		f, _ := deviation.Float64()
+		assert.InDelta(t, 0.2, f, 1e-12)
+
+		// The reverse cycle loses at the same prices.
+		deviation, err = graph.TriangleDeviation(1, 3, 2)
+		require.NoError(t, err)
+		f, _ = deviation.Float64()
+		assert.InDelta(t, 1/1.2-1, f, 1e-12)
+	})
+
+	t.Run("Missing leg", func(t *testing.T) {
+		graph := setup(t, units(100))
+		_, err := graph.TriangleDeviation(1, 2, 4)
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+
+	t.Run("Repeated token", func(t *testing.T) {
+		graph := setup(t, units(100))
+		_, err := graph.TriangleDeviation(1, 2, 1)
+		assert.Error(t, err)
+	})
+}
+
+func TestShortestPath(t *testing.T) {
+	setup := func(t *testing.T) *Graph {
+		tokens := make(map[uint64]common.Address)
+		for id := uint64(1); id <= 7; id++ {
+			tokens[id] = common.HexToAddress(fmt.Sprintf("0x%x", id))
+		}
+		pools := make(map[uint64]common.Address)
+		for id := uint64(201); id <= 207; id++ {
+			pools[id] = common.HexToAddress(fmt.Sprintf("0x%x", id))
+		}
+		pool := func(id, token0, token1 uint64) uniswapv2.Pool {
+			return uniswapv2.Pool{ID: id, Token0: token0, Token1: token1, Reserve0: big.NewInt(1e18), Reserve1: big.NewInt(1e18), FeeBps: 30}
+		}
+		uniswapV2Pools := []uniswapv2.Pool{
+			// A long path 1 -> 2 -> 3 -> 4 ...
+			pool(201, 1, 2), pool(202, 2, 3), pool(203, 3, 4),
+			// ... and a shorter one 1 -> 5 -> 4, with two pools on the 1/5 edge.
+			pool(207, 1, 5), pool(204, 1, 5), pool(205, 5, 4),
+			// A separate component.
+			pool(206, 6, 7),
+		}
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+		protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+			uniswapV3ProtocolID: uniswapv3.Schema,
+		}, poolRegistry)
+		graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{}, protocolResolver)
+		require.NoError(t, err)
+		return graph
+	}
+	disable := func(graph *Graph, poolID uint64) {
+		graph.allGetAmountOutFuncs[graph.poolToIndex[poolID]] = nil
+	}
+
+	t.Run("Fewest hops wins over a longer path", func(t *testing.T) {
+		graph := setup(t)
+		expected := []chains.TokenPoolPath{
+			{TokenInID: 1, TokenOutID: 5, PoolID: 204}, // lowest pool ID on the edge
+			{TokenInID: 5, TokenOutID: 4, PoolID: 205},
+		}
+		assert.Equal(t, expected, graph.ShortestPath(1, 4))
+		assert.Len(t, graph.ShortestPath(4, 1), 2)
+		assert.Len(t, graph.ShortestPath(1, 2), 1)
+		assert.Len(t, graph.ShortestPath(2, 5), 2)
+	})
+
+	t.Run("Unroutable pools are skipped", func(t *testing.T) {
+		graph := setup(t)
+		disable(graph, 204)
+		assert.Equal(t, uint64(207), graph.ShortestPath(1, 5)[0].PoolID)
+
+		disable(graph, 205)
+		expected := []chains.TokenPoolPath{
+			{TokenInID: 1, TokenOutID: 2, PoolID: 201},
+			{TokenInID: 2, TokenOutID: 3, PoolID: 202},
+			{TokenInID: 3, TokenOutID: 4, PoolID: 203},
+		}
+		assert.Equal(t, expected, graph.ShortestPath(1, 4))
+	})
+
+	t.Run("Same token", func(t *testing.T) {
+		path := setup(t).ShortestPath(3, 3)
+		assert.NotNil(t, path)
+		assert.Empty(t, path)
+	})
+
+	t.Run("Unreachable or unknown token", func(t *testing.T) {
+		graph := setup(t)
+		assert.Nil(t, graph.ShortestPath(1, 6))
+		assert.Nil(t, graph.ShortestPath(1, 999))
+		assert.Nil(t, graph.ShortestPath(999, 1))
+	})
+}
+
+func TestAllSpotPrices(t *testing.T) {
+	t.Run("Prices V2 and V3 pools", func(t *testing.T) {
+		graph, _, v2View, v3View := setupGetExchangeRatesGraph(t, map[uint64]struct{}{})
+		prices, err := graph.AllSpotPrices(chains.SpotPriceParams{})
+		require.NoError(t, err)
+		require.Len(t, prices, 3)
+
+		// Without token metadata prices are raw: token1 units per token0 unit.
+		v2Pool, _ := v2View.GetByID(101)
+		expected := new(big.Float).Quo(new(big.Float).SetInt(v2Pool.Reserve1), new(big.Float).SetInt(v2Pool.Reserve0))
+		assert.Equal(t, 0, expected.Cmp(prices[101]))
+
+		v3Pool, _ := v3View.GetByID(102)
+		reserve0, reserve1 := uniswapv3calculator.VirtualReserves(v3Pool)
+		expected = new(big.Float).Quo(new(big.Float).SetInt(reserve1), new(big.Float).SetInt(reserve0))
+		assert.Equal(t, 0, expected.Cmp(prices[102]))
+	})
+
+	t.Run("Parallel results match the serial computation", func(t *testing.T) {
+		graph := setupUniswapV2BenchmarkGraph(t, 200, 600)
+		serial, err := graph.AllSpotPrices(chains.SpotPriceParams{Concurrency: 1})
+		require.NoError(t, err)
+		require.Len(t, serial, 600)
+
+		for _, concurrency := range []int{2, 4, 16} {
+			for _, batchSize := range []int{0, 1, 7, 1000} {
+				params := chains.SpotPriceParams{Concurrency: concurrency, BatchSize: batchSize}
+				for run := 0; run < 3; run++ {
+					parallel, err := graph.AllSpotPrices(params)
+					require.NoError(t, err)
+					require.Len(t, parallel, len(serial), "%+v", params)
+					for poolID, price := range serial {
+						require.Contains(t, parallel, poolID, "%+v", params)
+						require.Equal(t, 0, price.Cmp(parallel[poolID]), "pool %d, %+v", poolID, params)
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("Invalid parameters", func(t *testing.T) {
+		graph, _, _, _ := setupGetExchangeRatesGraph(t, map[uint64]struct{}{})
+		_, err := graph.AllSpotPrices(chains.SpotPriceParams{Concurrency: -1})
+		assert.Error(t, err)
+		_, err = graph.AllSpotPrices(chains.SpotPriceParams{BatchSize: -1})
+		assert.Error(t, err)
+	})
+}
+
+func BenchmarkAllSpotPrices(b *testing.B) {
+	graph := setupUniswapV2BenchmarkGraph(b, 2000, 6000)
+	for _, concurrency := range []int{1, 4, 8} {
+		b.Run(fmt.Sprintf("6000Pools_Concurrency%d", concurrency), func(b *testing.B) {
+			params := chains.SpotPriceParams{Concurrency: concurrency}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, _ = graph.AllSpotPrices(params)
+			}
+		})
+	}
+}
+
+func TestRateForSize(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0x1"), // WETH
+		2: common.HexToAddress("0x2"), // USDC
+	}
+	pools := map[uint64]common.Address{101: common.HexToAddress("0x101")}
+	// A shallow pool: 10 WETH against 30,000 USDC.
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(10), d18), Reserve1: big.NewInt(30_000e6), FeeBps: 30},
+	}
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+	protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}, poolRegistry)
+	tokenRegistry := tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+		{ID: 1, Symbol: "WETH", Decimals: 18},
+		{ID: 2, Symbol: "USDC", Decimals: 6},
+	})
+	graph, err := NewGraph(rawGraph, tokenRegistry, poolRegistry, v2View, v3View, map[uint64]struct{}{101: {}}, protocolResolver)
+	require.NoError(t, err)
+
+	rateFor := func(numerator, denominator int64) float64 {
+		amountIn := new(big.Int).Div(new(big.Int).Mul(d18, big.NewInt(numerator)), big.NewInt(denominator))
+		rate, err := graph.RateForSize(1, 2, amountIn)
+		require.NoError(t, err)
+		f, _ := rate.Float64()
+		return f
+	}
+
+	small := rateFor(1, 100) // 0.01 WETH
+	medium := rateFor(1, 1)  // 1 WETH
+	large := rateFor(5, 1)   // 5 WETH
+
+	assert.InDelta(t, 2990, small, 5, "a small trade gets close to the spot rate less fees")
+	assert.Greater(t, small, medium)
+	assert.Greater(t, medium, large)
+	assert.InDelta(t, 1994, large, 5, "half the pool's WETH depth costs about a third of the rate")
+
+	_, err = graph.RateForSize(1, 2, big.NewInt(0))
+	assert.Error(t, err)
+}
+
+func TestPortfolioValue(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0x1"), // WETH
+		2: common.HexToAddress("0x2"), // USDC
+		3: common.HexToAddress("0x3"), // WBTC
+		4: common.HexToAddress("0x4"), // DAI, only paired with LINK
+		5: common.HexToAddress("0x5"), // LINK
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"),
+		102: common.HexToAddress("0x102"),
+		103: common.HexToAddress("0x103"),
+	}
+	// Deep pools at 3,000 USDC per WETH and 60,000 USDC per WBTC.
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(10_000), d18), Reserve1: big.NewInt(30_000_000e6), FeeBps: 30},
+		{ID: 102, Token0: 3, Token1: 2, Reserve0: big.NewInt(1_000e8), Reserve1: big.NewInt(60_000_000e6), FeeBps: 30},
+		{ID: 103, Token0: 4, Token1: 5, Reserve0: new(big.Int).Mul(big.NewInt(1_000), d18), Reserve1: new(big.Int).Mul(big.NewInt(100), d18), FeeBps: 30},
+	}
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+	protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}, poolRegistry)
+	tokenRegistry := tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+		{ID: 1, Symbol: "WETH", Decimals: 18},
+		{ID: 2, Symbol: "USDC", Decimals: 6},
+		{ID: 3, Symbol: "WBTC", Decimals: 8},
+		{ID: 4, Symbol: "DAI", Decimals: 18},
+		{ID: 5, Symbol: "LINK", Decimals: 18},
+	})
+	graph, err := NewGraph(rawGraph, tokenRegistry, poolRegistry, v2View, v3View, map[uint64]struct{}{101: {}, 102: {}, 103: {}}, protocolResolver)
+	require.NoError(t, err)
+
+	t.Run("Values a three-token portfolio", func(t *testing.T) {
+		portfolio, err := graph.PortfolioValue(map[uint64]*big.Int{
+			1: d18,               // 1 WETH
+			2: big.NewInt(500e6), // 500 USDC
+			3: big.NewInt(0.1e8), // 0.1 WBTC
+			4: big.NewInt(0),     // ignored
+		}, 2)
+		require.NoError(t, err)
+		require.Len(t, portfolio.Values, 3)
+		assert.Empty(t, portfolio.Unpriced)
+
+		value := func(tokenID uint64) float64 {
+			f, _ := portfolio.Values[tokenID].Float64()
+			return f
+		}
+		// Each balance is worth its spot value less the 0.3% fee; the pools are deep enough
+		// for price impact to stay below a dollar.
+		assert.InDelta(t, 2991, value(1), 1)
+		assert.Equal(t, float64(500), value(2))
+		assert.InDelta(t, 5982, value(3), 1)
+
+		total, _ := portfolio.Total.Float64()
+		assert.InDelta(t, value(1)+value(2)+value(3), total, 1e-9)
+	})
+
+	t.Run("Reports tokens with no route", func(t *testing.T) {
+		portfolio, err := graph.PortfolioValue(map[uint64]*big.Int{
+			1:   d18,
+			5:   d18, // LINK only trades against DAI
+			999: d18, // not in the graph
+		}, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{5, 999}, portfolio.Unpriced)
+		require.Len(t, portfolio.Values, 1)
+		assert.Equal(t, 0, portfolio.Total.Cmp(portfolio.Values[1]))
+	})
+
+	t.Run("Invalid inputs", func(t *testing.T) {
+		_, err := graph.PortfolioValue(map[uint64]*big.Int{1: d18}, 999)
+		assert.Error(t, err, "unknown base token")
+
+		_, err = graph.PortfolioValue(map[uint64]*big.Int{1: big.NewInt(-1)}, 2)
+		assert.Error(t, err, "negative balance")
+	})
+}
+
+func TestQuoteRoute(t *testing.T) {
+	graph := setupSwapPathTestGraph(t, map[uint64]struct{}{101: {}, 102: {}, 103: {}, 104: {}, 105: {}})
+	amountIn := new(big.Int).SetUint64(1e18)
+
+	t.Run("Matches the best swap path hop by hop", func(t *testing.T) {
+		path, amountOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{TokenInID: 1, TokenOutID: 4, AmountIn: amountIn, Runs: 3})
+		require.NoError(t, err)
+
+		quote, err := graph.QuoteRoute(path, amountIn)
+		require.NoError(t, err)
+		assert.Equal(t, amountIn, quote.AmountIn)
+		assert.Equal(t, amountOut, quote.AmountOut)
+		require.Len(t, quote.Hops, len(path))
+
+		for i, hop := range quote.Hops {
+			assert.Equal(t, path[i], hop.TokenPoolPath)
+			if i > 0 {
+				assert.Equal(t, quote.Hops[i-1].AmountOut, hop.AmountIn, "hop %d should sell the previous hop's output", i)
+			}
+			// Selling into the pool lowers its price of the input token.
+			assert.Equal(t, -1, hop.SpotPriceAfter.Cmp(hop.SpotPriceBefore))
+			assert.Equal(t, 1, hop.PriceImpact.Sign())
+		}
+		last := quote.Hops[len(quote.Hops)-1]
+		assert.Equal(t, amountOut, last.AmountOut)
+		assert.Equal(t, last.CumulativePriceImpact, quote.PriceImpact)
+		assert.Equal(t, 1, quote.PriceImpact.Cmp(quote.Hops[0].PriceImpact), "impact accumulates along the route")
+	})
+
+	t.Run("V2 prices from reserves", func(t *testing.T) {
+		quote, err := graph.QuoteRoute([]chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 2, PoolID: 101}}, amountIn)
+		require.NoError(t, err)
+		hop := quote.Hops[0]
+
+		pool, _ := graph.indexedUniswapV2.GetByID(101)
+		spot, _ := new(big.Float).Quo(new(big.Float).SetInt(pool.Reserve1), new(big.Float).SetInt(pool.Reserve0)).Float64()
+		before, _ := hop.SpotPriceBefore.Float64()
+		assert.InDelta(t, spot, before, 1e-12)
+
+		reserve0 := new(big.Int).Add(pool.Reserve0, amountIn)
+		reserve1 := new(big.Int).Sub(pool.Reserve1, hop.AmountOut)
+		spotAfter, _ := new(big.Float).Quo(new(big.Float).SetInt(reserve1), new(big.Float).SetInt(reserve0)).Float64()
+		after, _ := hop.SpotPriceAfter.Float64()
+		assert.InDelta(t, spotAfter, after, 1e-12)
+
+		// A small trade in a deep pool pays little more than the 0.3% fee.
+		impact, _ := hop.PriceImpact.Float64()
+		assert.InDelta(t, 0.003, impact, 0.001)
+	})
+
+	t.Run("Invalid route", func(t *testing.T) {
+		_, err := graph.QuoteRoute(nil, amountIn)
+		assert.Error(t, err)
+		_, err = graph.QuoteRoute([]chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 2, PoolID: 101}}, big.NewInt(0))
+		assert.Error(t, err)
+		_, err = graph.QuoteRoute([]chains.TokenPoolPath{
+			{TokenInID: 1, TokenOutID: 2, PoolID: 101},
+			{TokenInID: 3, TokenOutID: 4, PoolID: 105},
+		}, amountIn)
+		assert.Error(t, err)
+	})
+}
+
+func TestFindSplitRoute(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0x1"), // WETH
+		2: common.HexToAddress("0x2"), // USDC
+		3: common.HexToAddress("0x3"), // DAI
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"),
+		102: common.HexToAddress("0x102"),
+		103: common.HexToAddress("0x103"),
+		104: common.HexToAddress("0x104"),
+	}
+	// Two WETH/USDC pools of different depth, and a WETH -> DAI -> USDC detour.
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(1_000), d18), Reserve1: big.NewInt(3_000_000e6), FeeBps: 30},
+		{ID: 102, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(500), d18), Reserve1: big.NewInt(1_500_000e6), FeeBps: 5},
+		{ID: 103, Token0: 1, Token1: 3, Reserve0: new(big.Int).Mul(big.NewInt(200), d18), Reserve1: new(big.Int).Mul(big.NewInt(600_000), d18), FeeBps: 30},
+		{ID: 104, Token0: 3, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(10_000_000), d18), Reserve1: big.NewInt(10_000_000e6), FeeBps: 1},
+	}
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+	protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}, poolRegistry)
+	graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{101: {}, 102: {}, 103: {}, 104: {}}, protocolResolver)
+	require.NoError(t, err)
+
+	amountIn := new(big.Int).Mul(big.NewInt(100), d18)
+
+	t.Run("Splits a large trade across disjoint routes", func(t *testing.T) {
+		result, err := graph.FindSplitRoute(chains.SplitRouteParams{AmountIn: amountIn, TokenInID: 1, TokenOutID: 2, Runs: 3})
+		require.NoError(t, err)
+		require.Len(t, result.Legs, 3)
+		assert.Equal(t, 1, result.AmountOut.Cmp(result.SinglePathOut), "splitting should beat the single best route")
+
+		_, singleOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{AmountIn: amountIn, TokenInID: 1, TokenOutID: 2, Runs: 3})
+		require.NoError(t, err)
+		assert.Equal(t, singleOut, result.SinglePathOut)
+
+		totalIn, totalOut := new(big.Int), new(big.Int)
+		usedPools := map[uint64]struct{}{}
+		for _, leg := range result.Legs {
+			out, err := graph.quoteRoute(leg.Path, leg.AmountIn)
+			require.NoError(t, err)
+			assert.Equal(t, out, leg.AmountOut)
+			totalIn.Add(totalIn, leg.AmountIn)
+			totalOut.Add(totalOut, leg.AmountOut)
+			for _, hop := range leg.Path {
+				_, used := usedPools[hop.PoolID]
+				assert.False(t, used, "pool %d is shared between legs", hop.PoolID)
+				usedPools[hop.PoolID] = struct{}{}
+			}
+		}
+		assert.Equal(t, amountIn, totalIn)
+		assert.Equal(t, result.AmountOut, totalOut)
+
+		// The legs plug straight into SplitSlippage.
+		slippage, err := graph.SplitSlippage(result.Legs)
+		require.NoError(t, err)
+		assert.Equal(t, result.AmountOut, slippage.AmountOut)
+	})
+
+	t.Run("Deeper routes take more of the input", func(t *testing.T) {
+		result, err := graph.FindSplitRoute(chains.SplitRouteParams{AmountIn: amountIn, TokenInID: 1, TokenOutID: 2, Runs: 3, MaxRoutes: 2})
+		require.NoError(t, err)
+		require.Len(t, result.Legs, 2)
+		allocation := map[uint64]*big.Int{}
+		for _, leg := range result.Legs {
+			allocation[leg.Path[0].PoolID] = leg.AmountIn
+		}
+		assert.Equal(t, 1, allocation[101].Cmp(allocation[102]))
+	})
+
+	t.Run("A single route holds the whole trade", func(t *testing.T) {
+		result, err := graph.FindSplitRoute(chains.SplitRouteParams{AmountIn: amountIn, TokenInID: 1, TokenOutID: 2, Runs: 3, MaxRoutes: 1})
+		require.NoError(t, err)
+		require.Len(t, result.Legs, 1)
+		assert.Equal(t, amountIn, result.Legs[0].AmountIn)
+		assert.Equal(t, result.SinglePathOut, result.AmountOut)
+	})
+
+	t.Run("Tiny trades are not split below one unit", func(t *testing.T) {
+		result, err := graph.FindSplitRoute(chains.SplitRouteParams{AmountIn: big.NewInt(3), TokenInID: 2, TokenOutID: 3, Runs: 3})
+		require.NoError(t, err)
+		totalIn := new(big.Int)
+		for _, leg := range result.Legs {
+			totalIn.Add(totalIn, leg.AmountIn)
+		}
+		assert.Equal(t, big.NewInt(3), totalIn)
+	})
+
+	t.Run("Unknown token", func(t *testing.T) {
+		_, err := graph.FindSplitRoute(chains.SplitRouteParams{AmountIn: amountIn, TokenInID: 1, TokenOutID: 4, Runs: 3})
+		assert.Error(t, err)
+	})
+
+	t.Run("Invalid params", func(t *testing.T) {
+		_, err := graph.FindSplitRoute(chains.SplitRouteParams{AmountIn: big.NewInt(0), TokenInID: 1, TokenOutID: 2, Runs: 3})
+		assert.Error(t, err)
+		_, err = graph.FindSplitRoute(chains.SplitRouteParams{AmountIn: amountIn, TokenInID: 1, TokenOutID: 2})
+		assert.Error(t, err)
+		_, err = graph.FindSplitRoute(chains.SplitRouteParams{AmountIn: amountIn, TokenInID: 1, TokenOutID: 2, Runs: 3, Parts: -1})
+		assert.Error(t, err)
+	})
+}
+
+func TestSplitSlippage(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0x1"), // WETH
+		2: common.HexToAddress("0x2"), // USDC
+		3: common.HexToAddress("0x3"), // DAI
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"),
+		102: common.HexToAddress("0x102"),
+		103: common.HexToAddress("0x103"),
+	}
+	// Two WETH/USDC pools at slightly different prices, and a DAI/USDC pool.
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(1_000), d18), Reserve1: big.NewInt(3_000_000e6), FeeBps: 30},
+		{ID: 102, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(500), d18), Reserve1: big.NewInt(1_510_000e6), FeeBps: 5},
+		{ID: 103, Token0: 3, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(1_000_000), d18), Reserve1: big.NewInt(1_000_000e6), FeeBps: 1},
+	}
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+	protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}, poolRegistry)
+	tokenRegistry := tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+		{ID: 1, Symbol: "WETH", Decimals: 18},
+		{ID: 2, Symbol: "USDC", Decimals: 6},
+		{ID: 3, Symbol: "DAI", Decimals: 18},
+	})
+	graph, err := NewGraph(rawGraph, tokenRegistry, poolRegistry, v2View, v3View, map[uint64]struct{}{101: {}, 102: {}, 103: {}}, protocolResolver)
+	require.NoError(t, err)
+
+	leg := func(poolID uint64, wholeIn int64) chains.SplitLeg {
+		path := []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 2, PoolID: poolID}}
+		amountIn := new(big.Int).Mul(big.NewInt(wholeIn), d18)
+		amountOut, err := graph.quoteRoute(path, amountIn)
+		require.NoError(t, err)
+		return chains.SplitLeg{Path: path, AmountIn: amountIn, AmountOut: amountOut}
+	}
+
+	t.Run("Two-leg split matches a manual computation", func(t *testing.T) {
+		legA, legB := leg(101, 60), leg(102, 40)
+		result, err := graph.SplitSlippage([]chains.SplitLeg{legA, legB})
+		require.NoError(t, err)
+
+		usdc := func(amount *big.Int) float64 { f, _ := new(big.Float).SetInt(amount).Float64(); return f / 1e6 }
+		totalOut := usdc(legA.AmountOut) + usdc(legB.AmountOut)
+		effective := totalOut / 100
+		spot := 3020.0 // pool 102 is the better pre-trade price
+		slippage := 1 - effective/spot
+
+		assert.Equal(t, new(big.Int).Mul(big.NewInt(100), d18), result.AmountIn)
+		assert.Equal(t, new(big.Int).Add(legA.AmountOut, legB.AmountOut), result.AmountOut)
+		gotSpot, _ := result.SpotPrice.Float64()
+		gotEffective, _ := result.EffectivePrice.Float64()
+		gotSlippage, _ := result.Slippage.Float64()
+		assert.InDelta(t, spot, gotSpot, 1e-9)
+		assert.InDelta(t, effective, gotEffective, 1e-9)
+		assert.InDelta(t, slippage, gotSlippage, 1e-12)
+
+		// Per-leg slippage against each leg's own pool does not add up to the net figure.
+		legSlippage := func(l chains.SplitLeg, legSpot float64) float64 {
+			f, _ := new(big.Float).SetInt(l.AmountIn).Float64()
+			return 1 - usdc(l.AmountOut)/(f/1e18)/legSpot
+		}
+		sum := legSlippage(legA, 3000) + legSlippage(legB, 3020)
+		assert.Greater(t, math.Abs(sum-gotSlippage), 1e-3)
+	})
+
+	t.Run("Multi-hop legs are priced through every hop", func(t *testing.T) {
+		path := []chains.TokenPoolPath{{TokenInID: 3, TokenOutID: 2, PoolID: 103}, {TokenInID: 2, TokenOutID: 1, PoolID: 101}}
+		amountIn := new(big.Int).Mul(big.NewInt(3_000), d18)
+		amountOut, err := graph.quoteRoute(path, amountIn)
+		require.NoError(t, err)
+
+		result, err := graph.SplitSlippage([]chains.SplitLeg{{Path: path, AmountIn: amountIn, AmountOut: amountOut}})
+		require.NoError(t, err)
+		spot, _ := result.SpotPrice.Float64()
+		assert.InDelta(t, 1.0/3000, spot, 1e-12)
+		assert.Equal(t, 1, result.Slippage.Sign())
+	})
+
+	t.Run("Invalid splits", func(t *testing.T) {
+		_, err := graph.SplitSlippage(nil)
+		assert.Error(t, err)
+
+		reverse := chains.SplitLeg{
+			Path:      []chains.TokenPoolPath{{TokenInID: 2, TokenOutID: 1, PoolID: 101}},
+			AmountIn:  big.NewInt(1e6),
+			AmountOut: big.NewInt(1),
+		}
+		_, err = graph.SplitSlippage([]chains.SplitLeg{leg(101, 1), reverse})
+		assert.Error(t, err, "legs must share their endpoints")
+
+		noAmount := leg(101, 1)
+		noAmount.AmountIn = nil
+		_, err = graph.SplitSlippage([]chains.SplitLeg{noAmount})
+		assert.Error(t, err)
+	})
+}
+
+func TestPoolTokenDecimals(t *testing.T) {
+	graph, _, _, _, _ := setupSimpleTestGraph(t, map[uint64]struct{}{})
+	// Token 4 (WBTC) is intentionally missing from the registry.
+	graph.indexedTokenRegistry = tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+		{ID: 1, Symbol: "WETH", Decimals: 18},
+		{ID: 2, Symbol: "USDC", Decimals: 6},
+		{ID: 3, Symbol: "DAI", Decimals: 18},
+	})
+
+	t.Run("V2 Pool with mismatched decimals", func(t *testing.T) {
+		decimals0, decimals1, ok := graph.PoolTokenDecimals(101)
+		require.True(t, ok)
+		assert.Equal(t, uint8(18), decimals0)
+		assert.Equal(t, uint8(6), decimals1)
+	})
+
+	t.Run("V3 Pool", func(t *testing.T) {
+		decimals0, decimals1, ok := graph.PoolTokenDecimals(102)
+		require.True(t, ok)
+		assert.Equal(t, uint8(18), decimals0)
+		assert.Equal(t, uint8(18), decimals1)
+	})
+
+	t.Run("Token missing from registry", func(t *testing.T) {
+		_, _, ok := graph.PoolTokenDecimals(104)
+		assert.False(t, ok)
+	})
+
+	t.Run("Non-existent pool", func(t *testing.T) {
+		_, _, ok := graph.PoolTokenDecimals(999)
+		assert.False(t, ok)
+	})
+
+	t.Run("No token registry", func(t *testing.T) {
+		graphWithoutRegistry, _, _, _, _ := setupSimpleTestGraph(t, map[uint64]struct{}{})
+		_, _, ok := graphWithoutRegistry.PoolTokenDecimals(101)
+		assert.False(t, ok)
+	})
+}
+
+func setupGetExchangeRatesGraph(t *testing.T, activePools map[uint64]struct{}) (*Graph, poolregistryindexer.IndexedPoolRegistry, uniswapv2indexer.IndexedUniswapV2, uniswapv3indexer.IndexedUniswapV3) {
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"), // WETH
+		2: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), // USDC
+		3: common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F"), // DAI
+		4: common.HexToAddress("0x2260FAC5E5542a773Aa44fBCfeDf7C193bc2C599"), // WBTC
+		5: common.HexToAddress("0x4Fabb145d64652a948d72533023f6E7A623C7C53"), // BUSD (Isolated)
+		6: common.HexToAddress("0x95aD61b0a150d79219dCF64E1E6Cc01f0B64C4cE"), // SHIB (Isolated)
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc"), // V2 WETH/USDC
+		102: common.HexToAddress("0x88e6a0c2ddd26feeb64f039a2c41296fcb3f5640"), // V3 WETH/USDC
+		104: common.HexToAddress("0xBb2b8038a1640196FbE3e38816F3e67Cba72D940"), // V2 WETH/WBTC
+	}
+
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: bigIntFromString("4000000000000000000000"), Reserve1: bigIntFromString("15600000000000"), FeeBps: 30},
+		{ID: 104, Token0: 1, Token1: 4, Reserve0: bigIntFromString("1000000000000000000000"), Reserve1: bigIntFromString("3154340000"), FeeBps: 30},
+	}
+	uniswapV3Pools := []uniswapv3.Pool{
+		setupUniswapV3ETHUSDCPool(2, 1, 102), // 2 == USDC, 1 == WETH, 102 == poolID
+	}
+
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, uniswapV3Pools)
+
+	protocolIDToProtocolSchema := map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}
+
+	protocolResolver := chains.NewProtocolResolver(
+		protocolIDToProtocolSchema,
+		poolRegistry,
+	)
+
+	graph, err := NewGraph(
+		rawGraph,
+		nil,
+		poolRegistry,
+		v2View,
+		v3View,
+		activePools,
+		protocolResolver,
+	)
+	require.NoError(t, err)
+	return graph, poolRegistry, v2View, v3View
+}
+
+func TestGetExchangeRates(t *testing.T) {
+	activePools := map[uint64]struct{}{101: {}, 102: {}, 104: {}}
+	graph, _, _, _ := setupGetExchangeRatesGraph(t, activePools)
+
+	t.Run("Success from WETH", func(t *testing.T) {
+		// Start with 1 WETH (10^18)
+		oneWETH := new(big.Int).SetUint64(1e18)
+		equivalents, err := graph.GetExchangeRates(oneWETH, 1, 3, nil) // No source token restriction
+
+		require.NoError(t, err)
+		require.NotNil(t, equivalents)
+		require.Len(t, equivalents, 3, "Should find equivalents for WETH, USDC, and WBTC")
+
+		// The base token's equivalent should be exactly the input amount.
+		assert.Equal(t, 0, oneWETH.Cmp(equivalents[1]), "Base token WETH equivalent should match input amount")
+
+		usdcAmount := equivalents[2] // USDC
+		wbtcAmount := equivalents[4] // WBTC
+		wethAmount := equivalents[1]
+
+		assert.NotNil(t, usdcAmount)
+		assert.True(t, usdcAmount.Cmp(big.NewInt(3924259038)) == 0, "USDC equivalent should be exactly 3924259038")
+		assert.NotNil(t, wbtcAmount)
+		assert.True(t, wbtcAmount.Cmp(big.NewInt(3141744)) == 0, "WBTC equivalent should be exactly 3141744")
+		assert.NotNil(t, wethAmount)
+		assert.True(t, wethAmount.Cmp(oneWETH) == 0, "WETH equivalent should be equal to oneWETH")
+	})
+
+	t.Run("Success with allowedSourceTokens", func(t *testing.T) {
+		oneWETH := new(big.Int).SetUint64(1e18)
+		// Only allow WETH to be a source of price propagation.
+		allowedSourceTokens := map[uint64]struct{}{1: {}}
+		equivalents, err := graph.GetExchangeRates(oneWETH, 1, 3, allowedSourceTokens)
+
+		require.NoError(t, err)
+		require.NotNil(t, equivalents)
+		// Should still find all connected tokens, as the search starts from WETH.
+		require.Len(t, equivalents, 3)
+		assert.Contains(t, equivalents, uint64(1))
+		assert.Contains(t, equivalents, uint64(2))
+		assert.Contains(t, equivalents, uint64(4))
+	})
+
+	t.Run("Non-existent base token", func(t *testing.T) {
+		oneWETH := new(big.Int).SetUint64(1e18)
+		_, err := graph.GetExchangeRates(oneWETH, 999, 3, nil) // Non-existent token ID
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "token 999 not found in the graph")
+	})
+
+	t.Run("No path to isolated token", func(t *testing.T) {
+		oneWETH := new(big.Int).SetUint64(1e18)
+		equivalents, err := graph.GetExchangeRates(oneWETH, 1, 3, nil)
+
+		require.NoError(t, err)
+		require.NotNil(t, equivalents)
+		_, exists := equivalents[5] // BUSD
+		assert.False(t, exists, "Isolated token BUSD should not have an equivalent")
+	})
+}
+
+// setupUniswapV2BenchmarkGraph creates a large, realistic graph using only V2 pools for performance testing.
+// It uses a "hub-and-spoke" model to simulate real-world liquidity patterns.
+func setupUniswapV2BenchmarkGraph(t require.TestingT, numTokens, numPools int) *Graph {
+	tokens := make(map[uint64]common.Address, numTokens)
+	for i := 0; i < numTokens; i++ {
+		tokens[uint64(i)] = common.HexToAddress(fmt.Sprintf("0x%040x", i))
+	}
+
+	pools := make(map[uint64]common.Address, numPools)
+	uniswapV2Pools := make([]uniswapv2.Pool, 0, numPools)
+
+	// Define our "hub" tokens (e.g., WETH, USDC)
+	hubTokens := []uint64{0, 1, 2}
+	if numTokens < len(hubTokens) {
+		hubTokens = hubTokens[:numTokens]
+	}
+
+	// Setup for generating large random numbers safely
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	baseReserve := new(big.Int).SetUint64(1e18)
+	maxRandReserve := new(big.Int)
+	maxRandReserve.Exp(big.NewInt(10), big.NewInt(20), nil) // 10^20
+	poolIDCounter := uint64(0)
+
+	// --- Step 1: Guarantee Connectivity ---
+	// Create a "backbone" by linking every non-hub token to a hub.
+	for i := len(hubTokens); i < numTokens; i++ {
+		if poolIDCounter >= uint64(numPools) {
+			break // Stop if we've already created the max number of pools
+		}
+		poolID := poolIDCounter
+		pools[poolID] = common.HexToAddress(fmt.Sprintf("0x1%039x", poolID))
+
+		tokenA := hubTokens[r.Intn(len(hubTokens))] // Pick a random hub
+		tokenB := uint64(i)
+
+		randReserve0 := new(big.Int).Rand(r, maxRandReserve)
+		randReserve1 := new(big.Int).Rand(r, maxRandReserve)
+		reserve0 := new(big.Int).Add(baseReserve, randReserve0)
+		reserve1 := new(big.Int).Add(baseReserve, randReserve1)
+
+		uniswapV2Pools = append(uniswapV2Pools, uniswapv2.Pool{
+			ID:       poolID,
+			Token0:   tokenA,
+			Token1:   tokenB,
+			Reserve0: reserve0,
+			Reserve1: reserve1,
+			Type:     0,
+		})
+		poolIDCounter++
+	}
+
+	// --- Step 2: Add remaining pools randomly for complexity ---
+	for i := poolIDCounter; i < uint64(numPools); i++ {
+		poolID := i
+		pools[poolID] = common.HexToAddress(fmt.Sprintf("0x1%039x", poolID))
+
+		var tokenA, tokenB uint64
+
+		// Create a mix of hub-spoke and spoke-spoke pools
+		if i%5 == 0 && numTokens > len(hubTokens) { // 20% of pools are cross-connects
+			// Spoke-to-spoke pool
+			tokenA = uint64(len(hubTokens) + r.Intn(numTokens-len(hubTokens)))
+			tokenB = uint64(len(hubTokens) + r.Intn(numTokens-len(hubTokens)))
+		} else {
+			// Hub-to-spoke pool
+			tokenA = hubTokens[r.Intn(len(hubTokens))]
+			tokenB = uint64(r.Intn(numTokens))
+		}
+
+		// Ensure we don't create a pool with the same token
+		if tokenA == tokenB {
+			tokenB = (tokenB + 1) % uint64(numTokens)
+		}
+
+		randReserve0 := new(big.Int).Rand(r, maxRandReserve)
+		randReserve1 := new(big.Int).Rand(r, maxRandReserve)
+		reserve0 := new(big.Int).Add(baseReserve, randReserve0)
+		reserve1 := new(big.Int).Add(baseReserve, randReserve1)
+
+		uniswapV2Pools = append(uniswapV2Pools, uniswapv2.Pool{
+			ID:       poolID,
+			Token0:   tokenA,
+			Token1:   tokenB,
+			Reserve0: reserve0,
+			Reserve1: reserve1,
+			FeeBps:   30,
+		})
+	}
+
+	activePools := make(map[uint64]struct{}, numPools)
+	for i := 0; i < numPools; i++ {
+		activePools[uint64(i)] = struct{}{}
+	}
+
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+
+	protocolIDToProtocolSchema := map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}
+
+	protocolResolver := chains.NewProtocolResolver(
+		protocolIDToProtocolSchema,
+		poolRegistry,
+	)
+
+	graph, err := NewGraph(
+		rawGraph,
+		nil,
+		poolRegistry,
+		v2View,
+		v3View,
+		activePools,
+		protocolResolver,
+	)
+	require.NoError(t, err)
+	return graph
+}
+
+func TestGetExchangeRatesLongPathIsExact(t *testing.T) {
+	// A linear 4-hop chain: 1 -> 2 -> 3 -> 4 -> 5, with very different decimals and prices.
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xA"),
+		2: common.HexToAddress("0xB"),
+		3: common.HexToAddress("0xC"),
+		4: common.HexToAddress("0xD"),
+		5: common.HexToAddress("0xE"),
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"),
+		102: common.HexToAddress("0x102"),
+		103: common.HexToAddress("0x103"),
+		104: common.HexToAddress("0x104"),
+	}
+	d18 := new(big.Int).SetUint64(1e18)
+	d6 := new(big.Int).SetUint64(1e6)
+	d8 := new(big.Int).SetUint64(1e8)
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(10000), d18), Reserve1: new(big.Int).Mul(big.NewInt(40000000), d6), FeeBps: 30},
+		{ID: 102, Token0: 2, Token1: 3, Reserve0: new(big.Int).Mul(big.NewInt(33333333), d6), Reserve1: new(big.Int).Mul(big.NewInt(777), d8), FeeBps: 30},
+		{ID: 103, Token0: 3, Token1: 4, Reserve0: new(big.Int).Mul(big.NewInt(999), d8), Reserve1: new(big.Int).Mul(big.NewInt(123456789), d18), FeeBps: 25},
+		{ID: 104, Token0: 4, Token1: 5, Reserve0: new(big.Int).Mul(big.NewInt(987654321), d18), Reserve1: new(big.Int).Mul(big.NewInt(31337), d6), FeeBps: 100},
+	}
+
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+	protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}, poolRegistry)
+	graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{}, protocolResolver)
+	require.NoError(t, err)
+
+	baseAmountIn := new(big.Int).Mul(big.NewInt(3), d18)
+	rates, err := graph.GetExchangeRates(baseAmountIn, 1, 5, nil)
+	require.NoError(t, err)
+
+	// The rate for every token must equal the exact composition of per-hop quotes.
+	expected := new(big.Int).Set(baseAmountIn)
+	for i, pool := range uniswapV2Pools {
+		tokenIn, tokenOut := uint64(i+1), uint64(i+2)
+		expected, err = uniswapv2calculator.GetAmountOut(expected, tokenIn, tokenOut, pool)
+		require.NoError(t, err)
+		require.Contains(t, rates, tokenOut)
+		assert.Equal(t, expected.String(), rates[tokenOut].String(), "rate for token %d after %d hops", tokenOut, i+1)
+	}
+}
+
+func BenchmarkGetExchangeRates(b *testing.B) {
+	// Define different scales to benchmark against
+	benchmarkCases := []struct {
+		name      string
+		numTokens int
+		numPools  int
+	}{
+		{"100Tokens_300Pools", 100, 300},
+		{"500Tokens_1500Pools", 500, 1500},
+		{"1000Tokens_3000Pools", 1000, 3000},
+		{"2000Tokens_6000Pools", 2000, 6000},
+	}
+
+	for _, bc := range benchmarkCases {
+		b.Run(bc.name, func(b *testing.B) {
+			// Setup is done once, outside the timed loop
+			graph := setupUniswapV2BenchmarkGraph(b, bc.numTokens, bc.numPools)
+			baseAmount := new(big.Int).SetUint64(1e18)
+			baseTokenID := uint64(0) // Start from one of our hub tokens
+
+			// Reset the timer to exclude setup time
+			b.ResetTimer()
+
+			// The loop runs b.N times
+			for i := 0; i < b.N; i++ {
+				// The function being benchmarked
+				_, _ = graph.GetExchangeRates(baseAmount, baseTokenID, 5, nil) // 5 runs for propagation
+			}
+		})
+	}
+}
+
+// setupArbitrageTestGraph creates a graph specifically for testing arbitrage cycles.
+// It features a 3-token loop (WETH-USDC-DAI) with initially balanced pools.
+func setupArbitrageTestGraph(t *testing.T, activePools map[uint64]struct{}) *Graph {
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"), // WETH
+		2: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), // USDC
+		3: common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F"), // DAI
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc"), // V2 WETH/USDC
+		102: common.HexToAddress("0xAE461cA67B15dc82787E5236E28020173Bf30ac2"), // V2 USDC/DAI
+		103: common.HexToAddress("0xa478c2975ab1ea89e8196811f51a7b7ade33eb11"), // V2 DAI/WETH
+	}
+
+	// Reserves are set to create a balanced 1:4000:4000 price ratio
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18)), Reserve1: new(big.Int).Mul(big.NewInt(4000000), big.NewInt(1e6)), FeeBps: 30},
+		{ID: 102, Token0: 2, Token1: 3, Reserve0: new(big.Int).Mul(big.NewInt(4000000), big.NewInt(1e6)), Reserve1: new(big.Int).Mul(big.NewInt(4000000), big.NewInt(1e18)), FeeBps: 30},
+		{ID: 103, Token0: 3, Token1: 1, Reserve0: new(big.Int).Mul(big.NewInt(4000000), big.NewInt(1e18)), Reserve1: new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18)), FeeBps: 30},
+	}
+
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+
+	protocolIDToProtocolSchema := map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}
+
+	protocolResolver := chains.NewProtocolResolver(
+		protocolIDToProtocolSchema,
+		poolRegistry,
+	)
+	graph, err := NewGraph(
+		rawGraph,
+		nil,
+		poolRegistry,
+		v2View,
+		v3View,
+		activePools,
+		protocolResolver,
+	)
+	require.NoError(t, err)
+	return graph
+}
+func TestFindArbitrageCycles(t *testing.T) {
+	startAmount := new(big.Int).SetUint64(1e18) // 1 WETH
+
+	// Define the default active set where all pools are available.
+	allPoolsActive := map[uint64]struct{}{
+		101: {}, 102: {}, 103: {},
+	}
+
+	// FindArbitrageCycles will always find cycles (so far there are more than one pool for a token)
+	// because the implementation now finds the best effort at a cycle
+	t.Run("Finds arbitrage with V2 override", func(t *testing.T) {
+		graph := setupArbitrageTestGraph(t, allPoolsActive)
+		originalPool, _ := graph.indexedUniswapV2.GetByID(101)
+		overriddenPool := originalPool
+		overriddenPool.Reserve1 = new(big.Int).Mul(big.NewInt(3800000), big.NewInt(1e6))
+		overriddenPool.Reserve0 = new(big.Int).Set(originalPool.Reserve0)
+
+		params := chains.CycleFindingParams{
+			TokenID:  1,
+			AmountIn: startAmount,
+			Runs:     4,
+			UniswapV2Overrides: map[uint64]uniswapv2.Pool{
+				101: overriddenPool,
+			},
+		}
+
+		cycles, costs, err := graph.FindArbitrageCycles(params)
+
+		require.NoError(t, err)
+		require.Len(t, cycles, 1, "Should find exactly one profitable cycle")
+		require.Len(t, costs, 1, "Should find exactly one profitable cost")
+
+		expectedPath := []chains.TokenPoolPath{
+			{TokenInID: 1, TokenOutID: 3, PoolID: 103},
+			{TokenInID: 3, TokenOutID: 2, PoolID: 102},
+			{TokenInID: 2, TokenOutID: 1, PoolID: 101},
+		}
+		assert.Equal(t, expectedPath, cycles[0], "The arbitrage path is incorrect")
+		profit := new(big.Int).Sub(costs[0], startAmount)
+		assert.True(t, profit.Sign() > 0, "The cycle should be profitable")
+	})
+
+	t.Run("Non-existent start token", func(t *testing.T) {
+		graph := setupArbitrageTestGraph(t, allPoolsActive)
+		params := chains.CycleFindingParams{
+			TokenID:  999,
+			AmountIn: startAmount,
+			Runs:     3,
+		}
+		_, _, err := graph.FindArbitrageCycles(params)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "token 999 not found in the graph")
+	})
+}
+
+func TestFindArbitrageCyclesMinProfit(t *testing.T) {
+	startAmount := new(big.Int).SetUint64(1e18) // 1 WETH
+	setup := func(t *testing.T) *Graph {
+		graph := setupProfitableCycleTestGraph(t)
+		graph.indexedTokenRegistry = tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+			{ID: 1, Symbol: "WETH", Decimals: 18},
+			{ID: 2, Symbol: "USDC", Decimals: 6},
+			{ID: 3, Symbol: "DAI", Decimals: 18},
+		})
+		return graph
+	}
+	params := func(threshold *chains.ProfitThreshold) chains.CycleFindingParams {
+		return chains.CycleFindingParams{TokenID: 1, AmountIn: startAmount, Runs: 4, MinProfit: threshold}
+	}
+	stables := []uint64{2, 3}
+
+	t.Run("Cycle profitable in raw terms is filtered below the USD threshold", func(t *testing.T) {
+		graph := setup(t)
+
+		rawThreshold := &chains.ProfitThreshold{Unit: chains.ProfitUnitRaw, Amount: big.NewFloat(1)}
+		cycles, amounts, err := graph.FindArbitrageCycles(params(rawThreshold))
+		require.NoError(t, err)
+		require.Len(t, cycles, 1, "the cycle clears a one-wei raw threshold")
+		require.Positive(t, new(big.Int).Sub(amounts[0], startAmount).Sign())
+
+		usdThreshold := &chains.ProfitThreshold{Unit: chains.ProfitUnitUSD, Amount: big.NewFloat(1000), StableTokenIDs: stables}
+		cycles, amounts, err = graph.FindArbitrageCycles(params(usdThreshold))
+		require.NoError(t, err)
+		assert.Empty(t, cycles)
+		assert.Empty(t, amounts)
+
+		all, _, err := graph.FindAllArbitrageCycles(params(usdThreshold), 0)
+		require.NoError(t, err)
+		assert.Empty(t, all)
+	})
+
+	t.Run("Cycle above the USD threshold is kept", func(t *testing.T) {
+		graph := setup(t)
+		threshold := &chains.ProfitThreshold{Unit: chains.ProfitUnitUSD, Amount: big.NewFloat(10), StableTokenIDs: stables}
+		cycles, _, err := graph.FindArbitrageCycles(params(threshold))
+		require.NoError(t, err)
+		assert.Len(t, cycles, 1)
+
+		all, _, err := graph.FindAllArbitrageCycles(params(threshold), 0)
+		require.NoError(t, err)
+		assert.NotEmpty(t, all)
+	})
+
+	t.Run("Threshold in bps of the input", func(t *testing.T) {
+		graph := setup(t)
+		cycles, _, err := graph.FindArbitrageCycles(params(&chains.ProfitThreshold{Unit: chains.ProfitUnitBps, Amount: big.NewFloat(10)}))
+		require.NoError(t, err)
+		assert.Len(t, cycles, 1)
+
+		cycles, _, err = graph.FindArbitrageCycles(params(&chains.ProfitThreshold{Unit: chains.ProfitUnitBps, Amount: big.NewFloat(5_000)}))
+		require.NoError(t, err)
+		assert.Empty(t, cycles)
+	})
+
+	t.Run("USD threshold requires anchor stables", func(t *testing.T) {
+		graph := setup(t)
+		_, _, err := graph.FindArbitrageCycles(params(&chains.ProfitThreshold{Unit: chains.ProfitUnitUSD, Amount: big.NewFloat(10)}))
+		assert.Error(t, err)
+	})
+}
+
+// setupArbitrageBenchmarkGraph creates a large, complex, and interconnected graph with diverse arbitrage opportunities.
+// setupProfitableCycleTestGraph mirrors setupArbitrageTestGraph but prices WETH lower in the
+// WETH/USDC pool, so the cycle WETH -> DAI -> USDC -> WETH is profitable before gas.
+func setupProfitableCycleTestGraph(t *testing.T) *Graph {
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"), // WETH
+		2: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), // USDC
+		3: common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F"), // DAI
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc"), // V2 WETH/USDC
+		102: common.HexToAddress("0xAE461cA67B15dc82787E5236E28020173Bf30ac2"), // V2 USDC/DAI
+		103: common.HexToAddress("0xa478c2975ab1ea89e8196811f51a7b7ade33eb11"), // V2 DAI/WETH
+	}
+
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18)), Reserve1: new(big.Int).Mul(big.NewInt(3800000), big.NewInt(1e6)), FeeBps: 30},
+		{ID: 102, Token0: 2, Token1: 3, Reserve0: new(big.Int).Mul(big.NewInt(4000000), big.NewInt(1e6)), Reserve1: new(big.Int).Mul(big.NewInt(4000000), big.NewInt(1e18)), FeeBps: 30},
+		{ID: 103, Token0: 3, Token1: 1, Reserve0: new(big.Int).Mul(big.NewInt(4000000), big.NewInt(1e18)), Reserve1: new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18)), FeeBps: 30},
+	}
+
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+
+	protocolResolver := chains.NewProtocolResolver(
+		map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+			uniswapV3ProtocolID: uniswapv3.Schema,
+		},
+		poolRegistry,
+	)
+	graph, err := NewGraph(
+		rawGraph,
+		nil,
+		poolRegistry,
+		v2View,
+		v3View,
+		map[uint64]struct{}{101: {}, 102: {}, 103: {}},
+		protocolResolver,
+	)
+	require.NoError(t, err)
+	return graph
+}
+
+// setupMultiCycleTestGraph extends setupProfitableCycleTestGraph with a second, cheaper
+// WETH/USDC pool so that several distinct cycles exist from WETH.
+func setupMultiCycleTestGraph(t *testing.T) *Graph {
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"), // WETH
+		2: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), // USDC
+		3: common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F"), // DAI
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc"), // V2 WETH/USDC
+		102: common.HexToAddress("0xAE461cA67B15dc82787E5236E28020173Bf30ac2"), // V2 USDC/DAI
+		103: common.HexToAddress("0xa478c2975ab1ea89e8196811f51a7b7ade33eb11"), // V2 DAI/WETH
+		104: common.HexToAddress("0x397FF1542f962076d0BFE58eA045FfA2d347ACa0"), // V2 WETH/USDC (Cheaper WETH)
+	}
+
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18)), Reserve1: new(big.Int).Mul(big.NewInt(3800000), big.NewInt(1e6)), FeeBps: 30},
+		{ID: 102, Token0: 2, Token1: 3, Reserve0: new(big.Int).Mul(big.NewInt(4000000), big.NewInt(1e6)), Reserve1: new(big.Int).Mul(big.NewInt(4000000), big.NewInt(1e18)), FeeBps: 30},
+		{ID: 103, Token0: 3, Token1: 1, Reserve0: new(big.Int).Mul(big.NewInt(4000000), big.NewInt(1e18)), Reserve1: new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18)), FeeBps: 30},
+		{ID: 104, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18)), Reserve1: new(big.Int).Mul(big.NewInt(4200000), big.NewInt(1e6)), FeeBps: 30},
+	}
+
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+
+	protocolResolver := chains.NewProtocolResolver(
+		map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+			uniswapV3ProtocolID: uniswapv3.Schema,
+		},
+		poolRegistry,
+	)
+	graph, err := NewGraph(
+		rawGraph,
+		nil,
+		poolRegistry,
+		v2View,
+		v3View,
+		map[uint64]struct{}{101: {}, 102: {}, 103: {}, 104: {}},
+		protocolResolver,
+	)
+	require.NoError(t, err)
+	return graph
+}
+
+func TestArbitrageSearchRestrictedPools(t *testing.T) {
+	graph := setupMultiCycleTestGraph(t)
+	params := chains.CycleFindingParams{
+		TokenID:  1,
+		AmountIn: new(big.Int).SetUint64(1e18), // 1 WETH
+		Runs:     3,
+	}
+
+	usesOnly := func(t *testing.T, cycles [][]chains.TokenPoolPath, allowed map[uint64]struct{}) {
+		t.Helper()
+		require.NotEmpty(t, cycles)
+		for _, cycle := range cycles {
+			for _, hop := range cycle {
+				assert.Contains(t, allowed, hop.PoolID, "cycle %v leaves the restricted set", cycle)
+			}
+		}
+	}
+
+	t.Run("ActivePools", func(t *testing.T) {
+		restricted := params
+		restricted.ActivePools = map[uint64]struct{}{101: {}, 102: {}, 103: {}}
+
+		cycles, _, err := graph.FindAllArbitrageCycles(restricted, 0)
+		require.NoError(t, err)
+		usesOnly(t, cycles, restricted.ActivePools)
+
+		best, _, err := graph.FindArbitrageCycles(restricted)
+		require.NoError(t, err)
+		usesOnly(t, best, restricted.ActivePools)
+	})
+
+	t.Run("MaxPools keeps the deepest pools", func(t *testing.T) {
+		// By sqrt(reserve0*reserve1) the pools rank 103, 102, 104, 101.
+		restricted := params
+		restricted.MaxPools = 3
+		deepest := map[uint64]struct{}{102: {}, 103: {}, 104: {}}
+
+		all, _, err := graph.FindAllArbitrageCycles(params, 0)
+		require.NoError(t, err)
+		cycles, _, err := graph.FindAllArbitrageCycles(restricted, 0)
+		require.NoError(t, err)
+		usesOnly(t, cycles, deepest)
+		assert.Less(t, len(cycles), len(all))
+
+		best, _, err := graph.FindArbitrageCycles(restricted)
+		require.NoError(t, err)
+		usesOnly(t, best, deepest)
+	})
+
+	t.Run("MaxPools applies within ActivePools", func(t *testing.T) {
+		restricted := params
+		restricted.ActivePools = map[uint64]struct{}{101: {}, 102: {}, 103: {}}
+		restricted.MaxPools = 2
+
+		// Only 102 and 103 remain, which do not connect WETH to USDC.
+		cycles, amounts, err := graph.FindAllArbitrageCycles(restricted, 0)
+		require.NoError(t, err)
+		assert.Empty(t, cycles)
+		assert.Empty(t, amounts)
+	})
+
+	t.Run("MaxPools measures overridden pools by their overrides", func(t *testing.T) {
+		// Pool 101, the shallowest, is ranked by its override, deeper than every other pool.
+		pool101, ok := graph.indexedUniswapV2.GetByID(101)
+		require.True(t, ok)
+		pool101.Reserve0 = new(big.Int).Mul(pool101.Reserve0, big.NewInt(1e6))
+		pool101.Reserve1 = new(big.Int).Mul(pool101.Reserve1, big.NewInt(1e6))
+		restricted := params
+		restricted.MaxPools = 3
+		restricted.UniswapV2Overrides = map[uint64]uniswapv2.Pool{101: pool101}
+		deepest := map[uint64]struct{}{101: {}, 102: {}, 103: {}}
+
+		cycles, _, err := graph.FindAllArbitrageCycles(restricted, 0)
+		require.NoError(t, err)
+		usesOnly(t, cycles, deepest)
+	})
+
+	t.Run("Negative MaxPools", func(t *testing.T) {
+		restricted := params
+		restricted.MaxPools = -1
+		_, _, err := graph.FindAllArbitrageCycles(restricted, 0)
+		assert.Error(t, err)
+		_, _, err = graph.FindArbitrageCycles(restricted)
+		assert.Error(t, err)
+	})
+}
+
+func TestFindAllArbitrageCycles(t *testing.T) {
+	params := chains.CycleFindingParams{
+		TokenID:  1,
+		AmountIn: new(big.Int).SetUint64(1e18), // 1 WETH
+		Runs:     3,
+	}
+
+	t.Run("Zero limit returns all cycles ordered by profit", func(t *testing.T) {
+		graph := setupMultiCycleTestGraph(t)
+		cycles, amounts, err := graph.FindAllArbitrageCycles(params, 0)
+		require.NoError(t, err)
+		require.Len(t, amounts, len(cycles))
+		require.Greater(t, len(cycles), 2, "the test graph should contain more cycles than the limits used below")
+
+		for i, cycle := range cycles {
+			assert.Equal(t, uint64(1), cycle[0].TokenInID, "cycle must start at the start token")
+			assert.Equal(t, uint64(1), cycle[len(cycle)-1].TokenOutID, "cycle must end at the start token")
+			if i > 0 {
+				assert.True(t, amounts[i-1].Cmp(amounts[i]) >= 0, "cycles must be ordered from most to least profitable")
+			}
+		}
+
+		// The best cycle buys cheap WETH in pool 104 and sells it in pool 101.
+		assert.Equal(t, []chains.TokenPoolPath{
+			{TokenInID: 1, TokenOutID: 2, PoolID: 104},
+			{TokenInID: 2, TokenOutID: 1, PoolID: 101},
+		}, cycles[0])
+	})
+
+	t.Run("Limit returns only the most profitable cycles", func(t *testing.T) {
+		graph := setupMultiCycleTestGraph(t)
+		allCycles, allAmounts, err := graph.FindAllArbitrageCycles(params, 0)
+		require.NoError(t, err)
+
+		for _, limit := range []int{1, 2} {
+			cycles, amounts, err := graph.FindAllArbitrageCycles(params, limit)
+			require.NoError(t, err)
+			require.Len(t, cycles, limit)
+			require.Len(t, amounts, limit)
+			assert.Equal(t, allCycles[:limit], cycles)
+			assert.Equal(t, allAmounts[:limit], amounts)
+		}
+	})
+
+	t.Run("Limit larger than the number of cycles", func(t *testing.T) {
+		graph := setupMultiCycleTestGraph(t)
+		allCycles, _, err := graph.FindAllArbitrageCycles(params, 0)
+		require.NoError(t, err)
+
+		cycles, _, err := graph.FindAllArbitrageCycles(params, len(allCycles)+10)
+		require.NoError(t, err)
+		assert.Equal(t, allCycles, cycles)
+	})
+
+	t.Run("Runs bounds the cycle length", func(t *testing.T) {
+		graph := setupMultiCycleTestGraph(t)
+		shortParams := params
+		shortParams.Runs = 2
+		cycles, _, err := graph.FindAllArbitrageCycles(shortParams, 0)
+		require.NoError(t, err)
+		require.NotEmpty(t, cycles)
+		for _, cycle := range cycles {
+			assert.LessOrEqual(t, len(cycle), 2)
+		}
+	})
+
+	t.Run("Negative limit", func(t *testing.T) {
+		graph := setupMultiCycleTestGraph(t)
+		_, _, err := graph.FindAllArbitrageCycles(params, -1)
+		require.Error(t, err)
+	})
+
+	t.Run("Non-existent start token", func(t *testing.T) {
+		graph := setupMultiCycleTestGraph(t)
+		badParams := params
+		badParams.TokenID = 999
+		_, _, err := graph.FindAllArbitrageCycles(badParams, 1)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "token 999 not found in the graph")
+	})
+}
+
+func TestFindAllArbitrageCyclesMaxDepth(t *testing.T) {
+	// A complete graph: every pair of tokens shares a pool, so the number of simple
+	// cycles grows factorially with their length.
+	const numTokens = 7
+	d18 := new(big.Int).SetUint64(1e18)
+	tokens := make(map[uint64]common.Address, numTokens)
+	for i := uint64(1); i <= numTokens; i++ {
+		tokens[i] = common.HexToAddress(fmt.Sprintf("0x%x", i))
+	}
+	pools := make(map[uint64]common.Address)
+	var uniswapV2Pools []uniswapv2.Pool
+	for a := uint64(1); a <= numTokens; a++ {
+		for b := a + 1; b <= numTokens; b++ {
+			poolID := 100*a + b
+			pools[poolID] = common.HexToAddress(fmt.Sprintf("0x%x", poolID))
+			uniswapV2Pools = append(uniswapV2Pools, uniswapv2.Pool{
+				ID: poolID, Token0: a, Token1: b, FeeBps: 30,
+				Reserve0: new(big.Int).Mul(big.NewInt(int64(1000+a*b)), d18),
+				Reserve1: new(big.Int).Mul(big.NewInt(int64(1000+a+b)), d18),
+			})
+		}
+	}
+	activePools := make(map[uint64]struct{}, len(pools))
+	for poolID := range pools {
+		activePools[poolID] = struct{}{}
+	}
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+	protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}, poolRegistry)
+	graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, activePools, protocolResolver)
+	require.NoError(t, err)
+
+	params := chains.CycleFindingParams{TokenID: 1, AmountIn: d18, Runs: numTokens}
+	assertValidCycles := func(t *testing.T, cycles [][]chains.TokenPoolPath, maxLen int) {
+		for _, cycle := range cycles {
+			require.GreaterOrEqual(t, len(cycle), 2)
+			require.LessOrEqual(t, len(cycle), maxLen)
+			assert.Equal(t, uint64(1), cycle[0].TokenInID)
+			assert.Equal(t, uint64(1), cycle[len(cycle)-1].TokenOutID)
+			seenTokens := map[uint64]bool{}
+			seenPools := map[uint64]bool{}
+			for i, hop := range cycle {
+				if i > 0 {
+					assert.Equal(t, cycle[i-1].TokenOutID, hop.TokenInID, "hops must chain")
+				}
+				assert.False(t, seenTokens[hop.TokenInID], "cycle must be simple")
+				assert.False(t, seenPools[hop.PoolID], "pool reused in cycle")
+				seenTokens[hop.TokenInID], seenPools[hop.PoolID] = true, true
+			}
+		}
+	}
+
+	t.Run("The depth limit cuts the enumeration short", func(t *testing.T) {
+		limited := params
+		limited.MaxDepth = 3
+		cycles, amounts, err := graph.FindAllArbitrageCycles(limited, 0)
+		require.ErrorIs(t, err, chains.ErrCycleDepthLimit)
+		require.NotEmpty(t, cycles)
+		require.Len(t, amounts, len(cycles))
+		assertValidCycles(t, cycles, 3)
+
+		// The cycles found are exactly those Runs = MaxDepth would enumerate.
+		bounded := params
+		bounded.Runs = 3
+		expected, _, err := graph.FindAllArbitrageCycles(bounded, 0)
+		require.NoError(t, err)
+		assert.Equal(t, expected, cycles)
+	})
+
+	t.Run("No error when Runs is within the limit", func(t *testing.T) {
+		cycles, _, err := graph.FindAllArbitrageCycles(params, 0)
+		require.NoError(t, err)
+		assertValidCycles(t, cycles, numTokens)
+		longest := 0
+		for _, cycle := range cycles {
+			longest = max(longest, len(cycle))
+		}
+		assert.Equal(t, numTokens, longest, "cycles through every token are enumerated")
+	})
+
+	t.Run("Zero uses the default depth", func(t *testing.T) {
+		deep := params
+		deep.Runs = chains.DefaultMaxCycleDepth + 1
+		_, _, err := graph.FindAllArbitrageCycles(deep, 1)
+		assert.NoError(t, err, "%d tokens cannot form a cycle of more than %d hops", numTokens, numTokens)
+
+		deep.MaxDepth = numTokens - 1
+		_, _, err = graph.FindAllArbitrageCycles(deep, 1)
+		assert.ErrorIs(t, err, chains.ErrCycleDepthLimit)
+	})
+
+	t.Run("Negative depth", func(t *testing.T) {
+		negative := params
+		negative.MaxDepth = -1
+		_, _, err := graph.FindAllArbitrageCycles(negative, 0)
+		assert.Error(t, err)
+	})
+}
+
+func TestCycleNetProfit(t *testing.T) {
+	startAmount := new(big.Int).SetUint64(1e18) // 1 WETH
+	gwei := big.NewInt(1e9)
+	gasEstimates := chains.GasEstimates{
+		uniswapv2.Schema: 100000,
+		uniswapv3.Schema: 150000,
+	}
+
+	wethCycle := []chains.TokenPoolPath{
+		{TokenInID: 1, TokenOutID: 3, PoolID: 103},
+		{TokenInID: 3, TokenOutID: 2, PoolID: 102},
+		{TokenInID: 2, TokenOutID: 1, PoolID: 101},
+	}
+
+	t.Run("Profitable at low gas", func(t *testing.T) {
+		graph := setupProfitableCycleTestGraph(t)
+		result, err := graph.CycleNetProfit(chains.CycleProfitParams{
+			Cycle:         wethCycle,
+			AmountIn:      startAmount,
+			GasPrice:      new(big.Int).Mul(big.NewInt(1), gwei),
+			GasEstimates:  gasEstimates,
+			NativeTokenID: 1,
+			Runs:          3,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, uint64(300000), result.GasUsed)
+		assert.Equal(t, new(big.Int).Mul(big.NewInt(300000), gwei), result.GasCost, "gas cost is not converted when the cycle token is native")
+		assert.True(t, result.GrossProfit.Sign() > 0, "cycle should be profitable before gas")
+		assert.True(t, result.NetProfit.Sign() > 0, "cycle should be profitable after gas")
+		assert.True(t, result.Profitable)
+		assert.Equal(t, new(big.Int).Sub(result.GrossProfit, result.GasCost), result.NetProfit)
+	})
+
+	t.Run("Gross profitable cycle is net negative at high gas", func(t *testing.T) {
+		graph := setupProfitableCycleTestGraph(t)
+		result, err := graph.CycleNetProfit(chains.CycleProfitParams{
+			Cycle:         wethCycle,
+			AmountIn:      startAmount,
+			GasPrice:      new(big.Int).Mul(big.NewInt(1000), gwei),
+			GasEstimates:  gasEstimates,
+			NativeTokenID: 1,
+			Runs:          3,
+		})
+		require.NoError(t, err)
+
+		assert.True(t, result.GrossProfit.Sign() > 0, "cycle should be profitable before gas")
+		assert.True(t, result.NetProfit.Sign() < 0, "cycle should be unprofitable after gas")
+		assert.False(t, result.Profitable)
+	})
+
+	t.Run("Gas is converted into a non-native cycle token", func(t *testing.T) {
+		graph := setupProfitableCycleTestGraph(t)
+		daiCycle := []chains.TokenPoolPath{
+			{TokenInID: 3, TokenOutID: 2, PoolID: 102},
+			{TokenInID: 2, TokenOutID: 1, PoolID: 101},
+			{TokenInID: 1, TokenOutID: 3, PoolID: 103},
+		}
+		result, err := graph.CycleNetProfit(chains.CycleProfitParams{
+			Cycle:         daiCycle,
+			AmountIn:      new(big.Int).Mul(big.NewInt(4000), big.NewInt(1e18)), // ~1 WETH worth of DAI
+			GasPrice:      new(big.Int).Mul(big.NewInt(1000), gwei),
+			GasEstimates:  gasEstimates,
+			NativeTokenID: 1,
+			Runs:          3,
+		})
+		require.NoError(t, err)
+
+		// 0.3 WETH of gas is roughly 1200 DAI.
+		assert.Equal(t, new(big.Int).Mul(big.NewInt(300000), new(big.Int).Mul(big.NewInt(1000), gwei)), result.GasCostNative)
+		lower := new(big.Int).Mul(big.NewInt(1100), big.NewInt(1e18))
+		upper := new(big.Int).Mul(big.NewInt(1300), big.NewInt(1e18))
+		assert.True(t, result.GasCost.Cmp(lower) > 0 && result.GasCost.Cmp(upper) < 0, "unexpected gas cost in DAI: %s", result.GasCost)
+		assert.True(t, result.GrossProfit.Sign() > 0)
+		assert.False(t, result.Profitable)
+	})
+
+	t.Run("Missing gas estimate for schema", func(t *testing.T) {
+		graph := setupProfitableCycleTestGraph(t)
+		_, err := graph.CycleNetProfit(chains.CycleProfitParams{
+			Cycle:         wethCycle,
+			AmountIn:      startAmount,
+			GasPrice:      gwei,
+			GasEstimates:  chains.GasEstimates{uniswapv3.Schema: 150000},
+			NativeTokenID: 1,
+			Runs:          3,
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no gas estimate for schema")
+	})
+
+	t.Run("Path is not a cycle", func(t *testing.T) {
+		graph := setupProfitableCycleTestGraph(t)
+		_, err := graph.CycleNetProfit(chains.CycleProfitParams{
+			Cycle:         wethCycle[:2],
+			AmountIn:      startAmount,
+			GasPrice:      gwei,
+			GasEstimates:  gasEstimates,
+			NativeTokenID: 1,
+			Runs:          3,
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "does not form a cycle")
+	})
+}
+
+func setupArbitrageBenchmarkGraph(t testing.TB, numTokens, numPools int) *Graph {
+	tokens := make(map[uint64]common.Address, numTokens)
+	for i := 0; i < numTokens; i++ {
+		tokens[uint64(i)] = common.HexToAddress(fmt.Sprintf("0x%040x", i))
+	}
+
+	pools := make(map[uint64]common.Address, numPools)
+	uniswapV2Pools := make([]uniswapv2.Pool, 0, numPools)
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	poolIDCounter := uint64(0)
+
+	// Define hub tokens
+	hubTokens := []uint64{0, 1, 2} // WETH, USDC, DAI equivalents
+	if numTokens < len(hubTokens) {
+		hubTokens = hubTokens[:numTokens]
+	}
+
+	// Big number setup
+	decimals := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+
+	// Helper to create a pool
+	createPool := func(tA, tB uint64, rA, rB *big.Int) {
+		if poolIDCounter >= uint64(numPools) {
+			return
+		}
+		poolID := poolIDCounter
+		pools[poolID] = common.HexToAddress(fmt.Sprintf("0x1%039x", poolID))
+		uniswapV2Pools = append(uniswapV2Pools, uniswapv2.Pool{ID: poolID, Token0: tA, Token1: tB, Reserve0: rA, Reserve1: rB, FeeBps: 30})
+		poolIDCounter++
+	}
+
+	// --- Step 1: Guaranteed Connectivity (Backbone) ---
+	// Connect every non-hub token to a random hub
+	for i := len(hubTokens); i < numTokens; i++ {
+		hub := hubTokens[r.Intn(len(hubTokens))]
+		reserveHub := new(big.Int).Mul(big.NewInt(1000), decimals)
+		reserveSpoke := new(big.Int).Mul(big.NewInt(int64(1000+r.Intn(5000))), decimals)
+		createPool(hub, uint64(i), reserveHub, reserveSpoke)
+	}
+
+	// --- Step 2: Hub Interconnectivity & Arbitrage Injection ---
+	// Create a richly connected core with guaranteed arbitrage loops
+	for i := 0; i < len(hubTokens); i++ {
+		for j := i + 1; j < len(hubTokens); j++ {
+			tA, tB := hubTokens[i], hubTokens[j]
+
+			// Create one "fair" pool
+			rA_fair := new(big.Int).Mul(big.NewInt(5000), decimals)
+			rB_fair := new(big.Int).Mul(big.NewInt(5000), decimals)
+			createPool(tA, tB, rA_fair, rB_fair)
+
+			// Create a second, imbalanced pool to guarantee an arbitrage opportunity
+			rA_imbalanced := new(big.Int).Mul(big.NewInt(5000), decimals)
+			imbalanceFactor := 1.0 + 0.02 + (r.Float64() * 0.05) // 2-7% imbalance
+			rB_imbalanced_float := new(big.Float).Mul(new(big.Float).SetInt(rB_fair), big.NewFloat(imbalanceFactor))
+			rB_imbalanced, _ := rB_imbalanced_float.Int(nil)
+			createPool(tA, tB, rA_imbalanced, rB_imbalanced)
+		}
+	}
+
+	// --- Step 3: Add Random Cross-Connections for Chaos ---
+	for poolIDCounter < uint64(numPools) {
+		tokenA := uint64(r.Intn(numTokens))
+		tokenB := uint64(r.Intn(numTokens))
+		if tokenA == tokenB {
+			continue
+		}
+		rA := new(big.Int).Mul(big.NewInt(int64(100+r.Intn(1000))), decimals)
+		rB := new(big.Int).Mul(big.NewInt(int64(100+r.Intn(1000))), decimals)
+		createPool(tokenA, tokenB, rA, rB)
+	}
+
+	activePools := make(map[uint64]struct{}, numPools)
+	for i := 0; i < int(poolIDCounter); i++ {
+		activePools[uint64(i)] = struct{}{}
+	}
+
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+
+	protocolIDToProtocolSchema := map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}
+
+	protocolResolver := chains.NewProtocolResolver(
+		protocolIDToProtocolSchema,
+		poolRegistry,
+	)
+
+	graph, err := NewGraph(
+		rawGraph,
+		nil,
+		poolRegistry,
+		v2View,
+		v3View,
+		activePools,
+		protocolResolver,
+	)
+	require.NoError(t, err)
+	return graph
+}
+func BenchmarkFindArbitrageCycles(b *testing.B) {
+	benchmarkCases := []struct {
+		name      string
+		numTokens int
+		numPools  int
+		runs      int // Path length to search
+	}{
+		{"100T_300P_3Hops", 100, 300, 3},
+		{"200T_600P_3Hops", 200, 600, 3},
+		{"500T_1500P_3Hops", 500, 1500, 3},
+		{"1000T_3000P_3Hops", 1000, 3000, 3},
+		{"100T_300P_4Hops", 100, 300, 4},
+		{"200T_600P_4Hops", 200, 600, 4},
+		{"500T_1500P_4Hops", 500, 1500, 4},
+		{"1000T_3000P_4Hops", 1000, 3000, 4},
+		{"5000T_10000P_3Hops", 5000, 10000, 3},
+		{"5000T_10000P_4Hops", 5000, 10000, 4},
+	}
+
+	for _, bc := range benchmarkCases {
+		b.Run(bc.name, func(b *testing.B) {
+			graph := setupArbitrageBenchmarkGraph(b, bc.numTokens, bc.numPools)
+			params := chains.CycleFindingParams{
+				TokenID:  0, // Start from our "WETH" hub token
+				AmountIn: new(big.Int).SetUint64(1e18),
+				Runs:     bc.runs,
+			}
+
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				_, _, _ = graph.FindArbitrageCycles(params)
+
+			}
+		})
+	}
+}
+
+// setupSwapPathTestGraph creates a graph specifically for testing swap paths.
+// It includes a clear optimal path (A->B->D) and several suboptimal paths.
+func setupSwapPathTestGraph(t *testing.T, activePools map[uint64]struct{}) *Graph {
+	// A, B, C, D are connected. E is isolated.
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xA"), // Token A (e.g., WETH)
+		2: common.HexToAddress("0xB"), // Token B (e.g., USDC)
+		3: common.HexToAddress("0xC"), // Token C (e.g., DAI)
+		4: common.HexToAddress("0xD"), // Token D (e.g., WBTC)
+		5: common.HexToAddress("0xE"), // Token E (Isolated)
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"), // A -> B (High Liquidity)
+		102: common.HexToAddress("0x102"), // B -> D (High Liquidity)
+		103: common.HexToAddress("0x103"), // A -> D (Direct, but Low Liquidity)
+		104: common.HexToAddress("0x104"), // A -> C (Low Liquidity)
+		105: common.HexToAddress("0x105"), // C -> D (Low Liquidity)
+	}
+
+	// Decimals for convenience
+	d18 := new(big.Int).SetUint64(1e18)
+	d6 := new(big.Int).SetUint64(1e6)
+	d8 := new(big.Int).SetUint64(1e8)
+
+	uniswapV2Pools := []uniswapv2.Pool{
+		// Optimal Path (High Liquidity)
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(10000), d18), Reserve1: new(big.Int).Mul(big.NewInt(40000000), d6), FeeBps: 30}, // A/B
+		{ID: 102, Token0: 2, Token1: 4, Reserve0: new(big.Int).Mul(big.NewInt(40000000), d6), Reserve1: new(big.Int).Mul(big.NewInt(1000), d8), FeeBps: 30},   // B/D
+
+		// Suboptimal Direct Path (Low Liquidity)
+		{ID: 103, Token0: 1, Token1: 4, Reserve0: new(big.Int).Mul(big.NewInt(10), d18), Reserve1: new(big.Int).Mul(big.NewInt(1), d8), FeeBps: 30}, // A/D
+
+		// Suboptimal Indirect Path (Low Liquidity)
+		{ID: 104, Token0: 1, Token1: 3, Reserve0: new(big.Int).Mul(big.NewInt(10), d18), Reserve1: new(big.Int).Mul(big.NewInt(40000), d18), FeeBps: 30}, // A/C
+		{ID: 105, Token0: 3, Token1: 4, Reserve0: new(big.Int).Mul(big.NewInt(40000), d18), Reserve1: new(big.Int).Mul(big.NewInt(1), d8), FeeBps: 30},   // C/D
+	}
+
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+
+	protocolIDToProtocolSchema := map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}
+
+	protocolResolver := chains.NewProtocolResolver(
+		protocolIDToProtocolSchema,
+		poolRegistry,
+	)
+
+	graph, err := NewGraph(
+		rawGraph,
+		nil,
+		poolRegistry,
+		v2View,
+		v3View,
+		activePools,
+		protocolResolver,
+	)
+	require.NoError(t, err)
+	return graph
+}
+
+func TestFindBestSwapPathEquivalences(t *testing.T) {
+	allActive := map[uint64]struct{}{101: {}, 102: {}, 103: {}, 104: {}, 105: {}}
+	d18 := new(big.Int).SetUint64(1e18)
+	// C is only reachable through thin pools; a conversion at 4,000 C per A beats them.
+	aToC := map[chains.TokenPair]*big.Rat{{From: 1, To: 3}: big.NewRat(4000, 1)}
+
+	t.Run("Converts directly at the configured ratio", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allActive)
+		path, amountOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+			TokenInID:    1,
+			TokenOutID:   3,
+			AmountIn:     d18,
+			Runs:         3,
+			Equivalences: aToC,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 3, PoolID: chains.EquivalencePoolID}}, path)
+		assert.Equal(t, new(big.Int).Mul(d18, big.NewInt(4000)), amountOut)
+	})
+
+	t.Run("Bridges a pool route into an equivalent token", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allActive)
+		amountIn := new(big.Int).SetUint64(1e7) // 0.1 D
+		poolPath, poolOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{TokenInID: 4, TokenOutID: 1, AmountIn: amountIn, Runs: 3})
+		require.NoError(t, err)
+
+		path, amountOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+			TokenInID:    4,
+			TokenOutID:   3,
+			AmountIn:     amountIn,
+			Runs:         3,
+			Equivalences: aToC,
+		})
+		require.NoError(t, err)
+		expectedPath := append(append([]chains.TokenPoolPath{}, poolPath...), chains.TokenPoolPath{TokenInID: 1, TokenOutID: 3, PoolID: chains.EquivalencePoolID})
+		assert.Equal(t, expectedPath, path)
+		assert.Equal(t, new(big.Int).Mul(poolOut, big.NewInt(4000)), amountOut)
+	})
+
+	t.Run("Reverse direction uses the inverse ratio", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allActive)
+		tenA := new(big.Int).Mul(d18, big.NewInt(10))
+		_, poolOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{TokenInID: 1, TokenOutID: 4, AmountIn: tenA, Runs: 3})
+		require.NoError(t, err)
+
+		// 40,000 C plus a remainder that rounds down to exactly 10 A.
+		amountIn := new(big.Int).Mul(tenA, big.NewInt(4000))
+		amountIn.Add(amountIn, big.NewInt(3999))
+		path, amountOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+			TokenInID:    3,
+			TokenOutID:   4,
+			AmountIn:     amountIn,
+			Runs:         3,
+			Equivalences: aToC,
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, path)
+		assert.Equal(t, chains.TokenPoolPath{TokenInID: 3, TokenOutID: 1, PoolID: chains.EquivalencePoolID}, path[0])
+		assert.Equal(t, poolOut, amountOut)
+	})
+
+	t.Run("A configured reverse ratio is used as is", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allActive)
+		path, amountOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+			TokenInID:  3,
+			TokenOutID: 1,
+			AmountIn:   new(big.Int).Mul(d18, big.NewInt(4000)),
+			Runs:       3,
+			Equivalences: map[chains.TokenPair]*big.Rat{
+				{From: 1, To: 3}: big.NewRat(4000, 1),
+				{From: 3, To: 1}: big.NewRat(1, 5000),
+			},
+		})
+		require.NoError(t, err)
+		// The inverse of A->C would pay exactly 1 A; the configured 0.8 A loses to pool 104.
+		assert.Equal(t, []chains.TokenPoolPath{{TokenInID: 3, TokenOutID: 1, PoolID: 104}}, path)
+		assert.Equal(t, -1, amountOut.Cmp(d18))
+	})
+
+	t.Run("Invalid equivalences", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allActive)
+		base := chains.SwapFindingParams{TokenInID: 1, TokenOutID: 4, AmountIn: d18, Runs: 3}
+		for name, equivalences := range map[string]map[chains.TokenPair]*big.Rat{
+			"nil ratio":      {{From: 1, To: 3}: nil},
+			"zero ratio":     {{From: 1, To: 3}: new(big.Rat)},
+			"negative ratio": {{From: 1, To: 3}: big.NewRat(-1, 1)},
+			"same token":     {{From: 1, To: 1}: big.NewRat(1, 1)},
+			"unknown token":  {{From: 1, To: 999}: big.NewRat(1, 1)},
+		} {
+			params := base
+			params.Equivalences = equivalences
+			_, _, err := graph.FindBestSwapPath(params)
+			assert.Error(t, err, name)
+		}
+
+		params := base
+		params.Equivalences = aToC
+		params.FloatSearch = true
+		_, _, err := graph.FindBestSwapPath(params)
+		assert.Error(t, err)
+	})
+}
+
+func TestFindBestSwapPathFloatSearch(t *testing.T) {
+	t.Run("Matches the exact search on a small graph", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, map[uint64]struct{}{
+			101: {}, 102: {}, 103: {}, 104: {}, 105: {},
+		})
+		for _, amount := range []uint64{1e15, 1e18, 1e19} {
+			params := chains.SwapFindingParams{
+				TokenInID:  1,
+				TokenOutID: 4,
+				AmountIn:   new(big.Int).SetUint64(amount),
+				Runs:       3,
+			}
+			exactPath, exactOut, err := graph.FindBestSwapPath(params)
+			require.NoError(t, err)
+
+			params.FloatSearch = true
+			floatPath, floatOut, err := graph.FindBestSwapPath(params)
+			require.NoError(t, err)
+
+			assert.Equal(t, exactPath, floatPath, "amount %d", amount)
+			assert.Equal(t, 0, exactOut.Cmp(floatOut), "amount %d: exact %s, float %s", amount, exactOut, floatOut)
+		}
+	})
+
+	t.Run("Matches the exact search on a larger V2 graph", func(t *testing.T) {
+		graph := setupUniswapV2BenchmarkGraph(t, 100, 400)
+		amountIn := new(big.Int).SetUint64(1e18)
+		for tokenOut := uint64(3); tokenOut < 100; tokenOut += 7 {
+			params := chains.SwapFindingParams{
+				TokenInID:  0,
+				TokenOutID: tokenOut,
+				AmountIn:   amountIn,
+				Runs:       3,
+			}
+			exactPath, exactOut, exactErr := graph.FindBestSwapPath(params)
+
+			params.FloatSearch = true
+			floatPath, floatOut, floatErr := graph.FindBestSwapPath(params)
+
+			if exactErr != nil {
+				assert.ErrorIs(t, floatErr, exactErr, "token %d", tokenOut)
+				continue
+			}
+			require.NoError(t, floatErr, "token %d", tokenOut)
+			assert.Equal(t, exactPath, floatPath, "token %d", tokenOut)
+			assert.Equal(t, 0, exactOut.Cmp(floatOut), "token %d: exact %s, float %s", tokenOut, exactOut, floatOut)
+		}
+	})
+
+	t.Run("Matches the exact search with a V3 pool for small swaps", func(t *testing.T) {
+		// Small enough that the V3 swap stays within the current tick, so the float quote
+		// from virtual reserves ranks the V2 and V3 pools the same way the exact math does.
+		graph, _, _, _ := setupGetExchangeRatesGraph(t, map[uint64]struct{}{101: {}, 102: {}, 104: {}})
+		cases := []struct {
+			tokenIn, tokenOut uint64
+			amountIn          *big.Int
+		}{
+			{1, 2, new(big.Int).SetUint64(1e18)},
+			{2, 1, big.NewInt(1000e6)},
+			{2, 4, big.NewInt(1000e6)},
+		}
+		for _, tc := range cases {
+			params := chains.SwapFindingParams{
+				TokenInID:  tc.tokenIn,
+				TokenOutID: tc.tokenOut,
+				AmountIn:   tc.amountIn,
+				Runs:       3,
+			}
+			exactPath, exactOut, err := graph.FindBestSwapPath(params)
+			require.NoError(t, err)
+
+			params.FloatSearch = true
+			floatPath, floatOut, err := graph.FindBestSwapPath(params)
+			require.NoError(t, err)
+
+			assert.Equal(t, exactPath, floatPath, "%d -> %d", tc.tokenIn, tc.tokenOut)
+			assert.Equal(t, 0, exactOut.Cmp(floatOut), "%d -> %d: exact %s, float %s", tc.tokenIn, tc.tokenOut, exactOut, floatOut)
+		}
+	})
+
+	t.Run("Rejects a PoolSelector", func(t *testing.T) {
+		graph, _, _, _, _ := setupSimpleTestGraph(t, map[uint64]struct{}{101: {}})
+		_, _, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+			TokenInID:    1,
+			TokenOutID:   2,
+			AmountIn:     big.NewInt(1e6),
+			Runs:         3,
+			FloatSearch:  true,
+			PoolSelector: chains.LowestFeeSelector{},
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestFindBestSwapPathMaxPoolStaleBlocks(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	units := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), d18) }
+	block := func(n uint64) *uint64 { return &n }
+	setup := func(t *testing.T, deepPoolUpdated *uint64) *Graph {
+		tokens := map[uint64]common.Address{1: common.HexToAddress("0xA"), 2: common.HexToAddress("0xB")}
+		pools := map[uint64]common.Address{101: common.HexToAddress("0x101"), 102: common.HexToAddress("0x102")}
+		uniswapV2Pools := []uniswapv2.Pool{
+			// The deeper pool gives the better quote, but may not have been updated recently.
+			{ID: 101, Token0: 1, Token1: 2, Reserve0: units(1_000), Reserve1: units(1_000), FeeBps: 30, LastUpdatedBlock: deepPoolUpdated},
+			{ID: 102, Token0: 1, Token1: 2, Reserve0: units(100), Reserve1: units(100), FeeBps: 30, LastUpdatedBlock: block(100)},
+		}
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+		protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+			uniswapV3ProtocolID: uniswapv3.Schema,
+		}, poolRegistry)
+		graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{101: {}, 102: {}}, protocolResolver)
+		require.NoError(t, err)
+		return graph
+	}
+	params := chains.SwapFindingParams{
+		TokenInID:     1,
+		TokenOutID:    2,
+		AmountIn:      units(10),
+		Runs:          3,
+		SnapshotBlock: 100,
+	}
+	poolUsed := func(t *testing.T, graph *Graph, params chains.SwapFindingParams) uint64 {
+		path, _, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		require.Len(t, path, 1)
+		return path[0].PoolID
+	}
+
+	t.Run("Stale pool is excluded", func(t *testing.T) {
+		graph := setup(t, block(90))
+		assert.Equal(t, uint64(101), poolUsed(t, graph, params), "without a limit the deeper pool wins")
+
+		params := params
+		params.MaxPoolStaleBlocks = 5
+		assert.Equal(t, uint64(102), poolUsed(t, graph, params))
+
+		params.MaxPoolStaleBlocks = 10 // exactly ten blocks behind is still fresh enough
+		assert.Equal(t, uint64(101), poolUsed(t, graph, params))
+	})
+
+	t.Run("Pool without a last-update block is kept", func(t *testing.T) {
+		graph := setup(t, nil)
+		params := params
+		params.MaxPoolStaleBlocks = 1
+		assert.Equal(t, uint64(101), poolUsed(t, graph, params))
+	})
+
+	t.Run("Override carries its own last-update block", func(t *testing.T) {
+		graph := setup(t, block(90))
+		refreshed, _ := graph.indexedUniswapV2.GetByID(101)
+		refreshed.LastUpdatedBlock = block(100)
+		params := params
+		params.MaxPoolStaleBlocks = 5
+		params.UniswapV2Overrides = map[uint64]uniswapv2.Pool{101: refreshed}
+		assert.Equal(t, uint64(101), poolUsed(t, graph, params))
+	})
+
+	t.Run("Every pool stale", func(t *testing.T) {
+		graph := setup(t, block(90))
+		params := params
+		params.SnapshotBlock = 200
+		params.MaxPoolStaleBlocks = 5
+		_, _, err := graph.FindBestSwapPath(params)
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+
+	t.Run("Snapshot block is required", func(t *testing.T) {
+		graph := setup(t, block(90))
+		params := params
+		params.SnapshotBlock = 0
+		params.MaxPoolStaleBlocks = 5
+		_, _, err := graph.FindBestSwapPath(params)
+		assert.Error(t, err)
+	})
+}
+
+func TestFindBestSwapPath(t *testing.T) {
+	startAmount := new(big.Int).SetUint64(1e18) // Represents 1 token A
+
+	// Define the set of all pools to be used as the default "active" set.
+	allPoolsActive := map[uint64]struct{}{
+		101: {}, 102: {}, 103: {}, 104: {}, 105: {},
+	}
+
+	t.Run("Finds best multi-hop path over direct path", func(t *testing.T) {
+		// This test ensures the algorithm correctly prioritizes the higher liquidity
+		// multi-hop path (A->B->D) over the direct but low-liquidity path (A->D).
+		graph := setupSwapPathTestGraph(t, allPoolsActive)
+		params := chains.SwapFindingParams{
+			TokenInID:  1,
+			TokenOutID: 4,
+			AmountIn:   startAmount,
+			Runs:       3,
+		}
+
+		path, amountOut, err := graph.FindBestSwapPath(params)
+
+		require.NoError(t, err)
+		require.NotNil(t, path)
+		require.NotNil(t, amountOut)
+		require.Len(t, path, 2)
+
+		expectedPath := []chains.TokenPoolPath{
+			{TokenInID: 1, TokenOutID: 2, PoolID: 101},
+			{TokenInID: 2, TokenOutID: 4, PoolID: 102},
+		}
+
+		assert.Equal(t, expectedPath, path)
+		assert.True(t, amountOut.Sign() > 0, "Amount out should be positive")
+	})
+
+	t.Run("Finds path with V2 override", func(t *testing.T) {
+		// We override the best path (A->B pool 101) to have terrible liquidity.
+		// The algorithm should now choose the direct A->D path (pool 103) as the best option.
+		graph := setupSwapPathTestGraph(t, allPoolsActive)
+		originalPool, _ := graph.indexedUniswapV2.GetByID(101)
+		overriddenPool := originalPool // copy
+		overriddenPool.Reserve0 = big.NewInt(1)
+		overriddenPool.Reserve1 = big.NewInt(1)
+
+		params := chains.SwapFindingParams{
+			TokenInID:  1,
+			TokenOutID: 4,
+			AmountIn:   startAmount,
+			Runs:       3,
+			UniswapV2Overrides: map[uint64]uniswapv2.Pool{
+				101: overriddenPool,
+			},
+		}
+
+		path, amountOut, err := graph.FindBestSwapPath(params)
+
+		require.NoError(t, err)
+		require.NotNil(t, path)
+		require.NotNil(t, amountOut)
+		require.Len(t, path, 1)
+
+		expectedPath := []chains.TokenPoolPath{
+			{TokenInID: 1, TokenOutID: 4, PoolID: 103},
+		}
+		assert.Equal(t, expectedPath, path)
+	})
+
+	t.Run("Finds best path using only active pools", func(t *testing.T) {
+		// Define an active set that EXCLUDES pool 101, which is part of the best multi-hop path.
+		activePools := map[uint64]struct{}{
+			102: {}, // B -> D (High Liquidity)
+			103: {}, // A -> D (Direct, Low Liquidity)
+			104: {}, // A -> C (Low Liquidity)
+			105: {}, // C -> D (Low Liquidity)
+		}
+		// Create a new graph with this specific active pool set.
+		graphWithInactivePool := setupSwapPathTestGraph(t, activePools)
+
+		params := chains.SwapFindingParams{
+			TokenInID:  1,
+			TokenOutID: 4,
+			AmountIn:   startAmount,
+			Runs:       3,
+		}
+
+		path, amountOut, err := graphWithInactivePool.FindBestSwapPath(params)
+
+		require.NoError(t, err)
+		require.NotNil(t, path)
+		require.NotNil(t, amountOut)
+
+		// The algorithm should now choose the direct, suboptimal path (A->D)
+		// because the better A->B->D path is broken by the inactive pool 101.
+		require.Len(t, path, 1)
+
+		expectedPath := []chains.TokenPoolPath{
+			{TokenInID: 1, TokenOutID: 4, PoolID: 103},
+		}
+		assert.Equal(t, expectedPath, path)
+	})
+
+	t.Run("Routes through inactive pools when requested", func(t *testing.T) {
+		// Pool 101 (A -> B) is outside the active set, as in the previous case.
+		graph := setupSwapPathTestGraph(t, map[uint64]struct{}{102: {}, 103: {}, 104: {}, 105: {}})
+		params := chains.SwapFindingParams{
+			TokenInID:  1,
+			TokenOutID: 4,
+			AmountIn:   startAmount,
+			Runs:       3,
+		}
+
+		activePath, activeOut, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		assert.Equal(t, []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 4, PoolID: 103}}, activePath)
+
+		params.IncludeInactivePools = true
+		allPath, allOut, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		expectedPath := []chains.TokenPoolPath{
+			{TokenInID: 1, TokenOutID: 2, PoolID: 101},
+			{TokenInID: 2, TokenOutID: 4, PoolID: 102},
+		}
+		assert.Equal(t, expectedPath, allPath)
+		assert.Positive(t, allOut.Cmp(activeOut), "the wider search should find the better route")
+
+		params.FloatSearch = true
+		floatPath, floatOut, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		assert.Equal(t, expectedPath, floatPath)
+		assert.Equal(t, 0, allOut.Cmp(floatOut))
+	})
+
+	t.Run("No path exists to isolated token", func(t *testing.T) {
+		// Token 5 exists in the graph but has no connecting pools.
+		graph := setupSwapPathTestGraph(t, allPoolsActive)
+		params := chains.SwapFindingParams{
+			TokenInID:  1,
+			TokenOutID: 5,
+			AmountIn:   startAmount,
+			Runs:       3,
+		}
+
+		path, amountOut, err := graph.FindBestSwapPath(params)
+
+		require.Error(t, err)
+		assert.Nil(t, path, "Path should be nil when no route exists")
+		assert.Nil(t, amountOut, "Amount out should be nil when no route exists")
+	})
+
+	t.Run("Start token does not exist", func(t *testing.T) {
+		// Token 999 is not in the graph's token registry.
+		graph := setupSwapPathTestGraph(t, allPoolsActive)
+		params := chains.SwapFindingParams{
+			TokenInID:  999,
+			TokenOutID: 4,
+			AmountIn:   startAmount,
+			Runs:       3,
+		}
+		_, _, err := graph.FindBestSwapPath(params)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "start token 999 not found in the graph")
+	})
+
+	t.Run("End token does not exist", func(t *testing.T) {
+		// Token 999 is not in the graph's token registry.
+		graph := setupSwapPathTestGraph(t, allPoolsActive)
+		params := chains.SwapFindingParams{
+			TokenInID:  1,
+			TokenOutID: 999,
+			AmountIn:   startAmount,
+			Runs:       3,
+		}
+		_, _, err := graph.FindBestSwapPath(params)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "end token 999 not found in the graph")
+	})
+}
+
+func TestFindBestSwapPathExactOut(t *testing.T) {
+	allPoolsActive := map[uint64]struct{}{
+		101: {}, 102: {}, 103: {}, 104: {}, 105: {},
+	}
+	amountOut := big.NewInt(10_000_000) // 0.1 token D
+
+	t.Run("Finds the cheapest multi-hop path", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allPoolsActive)
+
+		path, amountIn, err := graph.FindBestSwapPathExactOut(1, 4, amountOut, 3)
+		require.NoError(t, err)
+		assert.Equal(t, []chains.TokenPoolPath{
+			{TokenInID: 1, TokenOutID: 2, PoolID: 101},
+			{TokenInID: 2, TokenOutID: 4, PoolID: 102},
+		}, path)
+
+		// The input is the exact-output quote of every hop, from the last one back.
+		pool102, _ := graph.indexedUniswapV2.GetByID(102)
+		pool101, _ := graph.indexedUniswapV2.GetByID(101)
+		midAmount, err := uniswapv2calculator.GetAmountIn(amountOut, 2, 4, pool102)
+		require.NoError(t, err)
+		expected, err := uniswapv2calculator.GetAmountIn(midAmount, 1, 2, pool101)
+		require.NoError(t, err)
+		assert.Equal(t, expected, amountIn)
+
+		// Spending that input along the route delivers at least amountOut.
+		_, bought, err := graph.FindBestSwapPath(chains.SwapFindingParams{TokenInID: 1, TokenOutID: 4, AmountIn: amountIn, Runs: 3})
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, bought.Cmp(amountOut), 0)
+	})
+
+	t.Run("Respects maxHops", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allPoolsActive)
+
+		path, _, err := graph.FindBestSwapPathExactOut(1, 4, amountOut, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 4, PoolID: 103}}, path)
+	})
+
+	t.Run("Only uses active pools", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, map[uint64]struct{}{103: {}})
+
+		path, _, err := graph.FindBestSwapPathExactOut(1, 4, amountOut, 3)
+		require.NoError(t, err)
+		assert.Equal(t, []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 4, PoolID: 103}}, path)
+	})
+
+	t.Run("Output beyond the reserves", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, map[uint64]struct{}{103: {}})
+
+		_, _, err := graph.FindBestSwapPathExactOut(1, 4, big.NewInt(1e8), 3)
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+
+	t.Run("Uniswap V3 pool", func(t *testing.T) {
+		liquidity := new(big.Int).Exp(big.NewInt(10), big.NewInt(21), nil)
+		v3Pools := []uniswapv3.Pool{{
+			PoolViewMinimal: uniswapv3.PoolViewMinimal{
+				ID: 201, Token0: 1, Token1: 2, Fee: 500, TickSpacing: 60,
+				Liquidity: liquidity, SqrtPriceX96: new(big.Int).Set(uniswapv3calculator.Q96),
+			},
+			Ticks: []uniswapv3.TickInfo{
+				{Index: -600, LiquidityGross: liquidity, LiquidityNet: liquidity},
+				{Index: 600, LiquidityGross: liquidity, LiquidityNet: new(big.Int).Neg(liquidity)},
+			},
+		}}
+		tokens := map[uint64]common.Address{1: common.HexToAddress("0x1"), 2: common.HexToAddress("0x2")}
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, map[uint64]common.Address{201: common.HexToAddress("0x201")}, nil, v3Pools)
+		resolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{uniswapV3ProtocolID: uniswapv3.Schema}, poolRegistry)
+		graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{201: {}}, resolver)
+		require.NoError(t, err)
+
+		want := big.NewInt(1_000_000_000_000_000)
+		path, amountIn, err := graph.FindBestSwapPathExactOut(1, 2, want, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 2, PoolID: 201}}, path)
+		expected, err := uniswapv3calculator.GetAmountIn(new(big.Int).Neg(want), nil, 1, v3Pools[0])
+		require.NoError(t, err)
+		assert.Equal(t, expected, amountIn)
+
+		// More than the initialized ticks hold cannot be filled.
+		_, _, err = graph.FindBestSwapPathExactOut(1, 2, new(big.Int).Mul(liquidity, big.NewInt(1000)), 2)
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+
+	t.Run("Invalid input", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allPoolsActive)
+
+		_, _, err := graph.FindBestSwapPathExactOut(1, 4, big.NewInt(0), 3)
+		assert.Error(t, err)
+		_, _, err = graph.FindBestSwapPathExactOut(1, 4, amountOut, 0)
+		assert.Error(t, err)
+		_, _, err = graph.FindBestSwapPathExactOut(1, 999, amountOut, 3)
+		assert.Error(t, err)
+		_, _, err = graph.FindBestSwapPathExactOut(1, 1, amountOut, 3)
+		assert.Error(t, err)
+	})
+}
+
+func TestGraphWithoutTokenRegistry(t *testing.T) {
+	// Same topology as setupSwapPathTestGraph, but built through the Grapher with no token registry.
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xA"),
+		2: common.HexToAddress("0xB"),
+		4: common.HexToAddress("0xD"),
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"), // A -> B (High Liquidity)
+		102: common.HexToAddress("0x102"), // B -> D (High Liquidity)
+		103: common.HexToAddress("0x103"), // A -> D (Direct, but Low Liquidity)
+	}
+
+	d18 := new(big.Int).SetUint64(1e18)
+	d6 := new(big.Int).SetUint64(1e6)
+	d8 := new(big.Int).SetUint64(1e8)
+
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(10000), d18), Reserve1: new(big.Int).Mul(big.NewInt(40000000), d6), FeeBps: 30},
+		{ID: 102, Token0: 2, Token1: 4, Reserve0: new(big.Int).Mul(big.NewInt(40000000), d6), Reserve1: new(big.Int).Mul(big.NewInt(1000), d8), FeeBps: 30},
+		{ID: 103, Token0: 1, Token1: 4, Reserve0: new(big.Int).Mul(big.NewInt(10), d18), Reserve1: new(big.Int).Mul(big.NewInt(1), d8), FeeBps: 30},
+	}
+
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+	protocolResolver := chains.NewProtocolResolver(
+		map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+			uniswapV3ProtocolID: uniswapv3.Schema,
+		},
+		poolRegistry,
+	)
+
+	grapher, err := NewGrapher()
+	require.NoError(t, err)
+
+	graph, err := grapher.Graph(rawGraph, nil, poolRegistry, v2View, v3View, nil, protocolResolver)
+	require.NoError(t, err)
+
+	t.Run("Metadata is reported as unavailable", func(t *testing.T) {
+		assert.False(t, graph.HasTokenMetadata())
+		assert.Equal(t, "ID:1", graph.TokenLabel(1))
+		_, _, ok := graph.(*Graph).PoolTokenDecimals(101)
+		assert.False(t, ok)
+	})
+
+	t.Run("Routing by ID still works", func(t *testing.T) {
+		path, amountOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+			TokenInID:  1,
+			TokenOutID: 4,
+			AmountIn:   new(big.Int).SetUint64(1e18),
+			Runs:       3,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, amountOut)
+		assert.Equal(t, []chains.TokenPoolPath{
+			{TokenInID: 1, TokenOutID: 2, PoolID: 101},
+			{TokenInID: 2, TokenOutID: 4, PoolID: 102},
+		}, path)
+	})
+
+	t.Run("Labels use symbols when metadata is present", func(t *testing.T) {
+		withMetadata, err := grapher.Graph(
+			rawGraph,
+			tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{{ID: 1, Symbol: "WETH", Decimals: 18}}),
+			poolRegistry,
+			v2View,
+			v3View,
+			nil,
+			protocolResolver,
+		)
+		require.NoError(t, err)
+		assert.True(t, withMetadata.HasTokenMetadata())
+		assert.Equal(t, "WETH", withMetadata.TokenLabel(1))
+		assert.Equal(t, "ID:2", withMetadata.TokenLabel(2), "tokens missing from the registry fall back to their ID")
+	})
+}
+
+func TestFindBestSwapPathZeroOutput(t *testing.T) {
+	allPoolsActive := map[uint64]struct{}{
+		101: {}, 102: {}, 103: {}, 104: {}, 105: {},
+	}
+	// 1 wei of token A (18 decimals) is worth far less than 1 unit of token D (8 decimals),
+	// so every route from A to D rounds down to zero.
+	tinyAmount := big.NewInt(1)
+
+	t.Run("Zero-output route is no route by default", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allPoolsActive)
+		path, amountOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+			TokenInID:  1,
+			TokenOutID: 4,
+			AmountIn:   tinyAmount,
+			Runs:       3,
+		})
+		require.ErrorIs(t, err, chains.ErrNoRoute)
+		assert.Nil(t, path)
+		assert.Nil(t, amountOut)
+	})
+
+	t.Run("Zero-output route is returned when allowed", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allPoolsActive)
+		path, amountOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+			TokenInID:       1,
+			TokenOutID:      4,
+			AmountIn:        tinyAmount,
+			Runs:            3,
+			AllowZeroOutput: true,
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, path)
+		assert.Equal(t, uint64(1), path[0].TokenInID)
+		assert.Equal(t, uint64(4), path[len(path)-1].TokenOutID)
+		require.NotNil(t, amountOut)
+		assert.Equal(t, 0, amountOut.Sign())
+	})
+
+	t.Run("Zero-output route is returned when allowed with a pool selector", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allPoolsActive)
+		path, amountOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+			TokenInID:       1,
+			TokenOutID:      4,
+			AmountIn:        tinyAmount,
+			Runs:            3,
+			AllowZeroOutput: true,
+			PoolSelector:    chains.LowestFeeSelector{},
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, path)
+		assert.Equal(t, 0, amountOut.Sign())
+	})
+
+	t.Run("Positive routes are unaffected by the option", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allPoolsActive)
+		params := chains.SwapFindingParams{
+			TokenInID:  1,
+			TokenOutID: 4,
+			AmountIn:   new(big.Int).SetUint64(1e18),
+			Runs:       3,
+		}
+		expectedPath, expectedAmountOut, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+
+		params.AllowZeroOutput = true
+		path, amountOut, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		assert.Equal(t, expectedPath, path)
+		assert.Equal(t, expectedAmountOut, amountOut)
+	})
+}
+
+// setupPoolSelectorTestGraph creates a graph where a single edge (A <-> B) is served by
+// two pools with different trade-offs: a deep pool with a high fee and a shallow pool
+// with a low fee.
+func setupPoolSelectorTestGraph(t *testing.T) *Graph {
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xA"), // Token A (e.g., WETH)
+		2: common.HexToAddress("0xB"), // Token B (e.g., USDC)
+	}
+	pools := map[uint64]common.Address{
+		201: common.HexToAddress("0x201"), // A/B (Deep, 0.3% fee)
+		202: common.HexToAddress("0x202"), // A/B (Shallow, 0.05% fee)
+	}
+
+	d18 := new(big.Int).SetUint64(1e18)
+	d6 := new(big.Int).SetUint64(1e6)
+
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 201, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(1000), d18), Reserve1: new(big.Int).Mul(big.NewInt(2000000), d6), FeeBps: 30},
+		{ID: 202, Token0: 1, Token1: 2, Reserve0: new(big.Int).Mul(big.NewInt(10), d18), Reserve1: new(big.Int).Mul(big.NewInt(21000), d6), FeeBps: 5},
+	}
+
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+
+	protocolResolver := chains.NewProtocolResolver(
+		map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+			uniswapV3ProtocolID: uniswapv3.Schema,
+		},
+		poolRegistry,
+	)
+
+	graph, err := NewGraph(
+		rawGraph,
+		nil,
+		poolRegistry,
+		v2View,
+		v3View,
+		map[uint64]struct{}{201: {}, 202: {}},
+		protocolResolver,
+	)
+	require.NoError(t, err)
+	return graph
+}
+
+func TestFindBestSwapPathPoolSelector(t *testing.T) {
+	startAmount := new(big.Int).SetUint64(1e18) // Represents 1 token A
+
+	findPath := func(t *testing.T, selector chains.PoolSelector) ([]chains.TokenPoolPath, *big.Int) {
+		graph := setupPoolSelectorTestGraph(t)
+		path, amountOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+			TokenInID:    1,
+			TokenOutID:   2,
+			AmountIn:     startAmount,
+			Runs:         2,
+			PoolSelector: selector,
+		})
+		require.NoError(t, err)
+		require.Len(t, path, 1)
+		require.NotNil(t, amountOut)
+		return path, amountOut
+	}
+
+	t.Run("Default selector picks max amount out", func(t *testing.T) {
+		path, amountOut := findPath(t, nil)
+		assert.Equal(t, uint64(201), path[0].PoolID)
+
+		explicitPath, explicitAmountOut := findPath(t, chains.MaxAmountOutSelector{})
+		assert.Equal(t, path, explicitPath)
+		assert.Equal(t, amountOut, explicitAmountOut)
+	})
+
+	t.Run("Different selectors choose different pools on the same graph", func(t *testing.T) {
+		deepPath, deepAmountOut := findPath(t, chains.DeepestPoolSelector{})
+		cheapPath, cheapAmountOut := findPath(t, chains.LowestFeeSelector{})
+
+		assert.Equal(t, uint64(201), deepPath[0].PoolID, "deepest pool should be chosen")
+		assert.Equal(t, uint64(202), cheapPath[0].PoolID, "lowest fee pool should be chosen")
+
+		// The reported amount out reflects the pool that was actually chosen.
+		assert.Equal(t, 1, deepAmountOut.Cmp(cheapAmountOut))
+	})
+
+	t.Run("Custom selector can skip an edge", func(t *testing.T) {
+		graph := setupPoolSelectorTestGraph(t)
+		skipAll := chains.PoolSelectorFunc(func(_, _ uint64, _ []chains.PoolCandidate) int {
+			return -1
+		})
+		path, amountOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+			TokenInID:    1,
+			TokenOutID:   2,
+			AmountIn:     startAmount,
+			Runs:         2,
+			PoolSelector: skipAll,
+		})
+		require.ErrorIs(t, err, chains.ErrNoRoute)
+		assert.Nil(t, path)
+		assert.Nil(t, amountOut)
+	})
+}
+
+func TestFindBestSwapPathEdgeWeight(t *testing.T) {
+	allActive := map[uint64]struct{}{101: {}, 102: {}, 103: {}, 104: {}, 105: {}}
+	d18 := new(big.Int).SetUint64(1e18)
+	weights := func(w map[uint64]float64) func(uint64) float64 {
+		return func(poolID uint64) float64 {
+			if weight, ok := w[poolID]; ok {
+				return weight
+			}
+			return 1
+		}
+	}
+
+	t.Run("Unit weights match the unweighted search", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allActive)
+		params := chains.SwapFindingParams{TokenInID: 1, TokenOutID: 4, AmountIn: d18, Runs: 3}
+		path, amountOut, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+
+		params.EdgeWeight = weights(nil)
+		weightedPath, weightedAmountOut, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		assert.Equal(t, path, weightedPath)
+		assert.Equal(t, amountOut, weightedAmountOut)
+	})
+
+	t.Run("Weighting a pool diverts the route to it", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allActive)
+		params := chains.SwapFindingParams{TokenInID: 1, TokenOutID: 4, AmountIn: d18, Runs: 3}
+		path, bestOut, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		require.Equal(t, []uint64{101, 102}, []uint64{path[0].PoolID, path[1].PoolID})
+
+		params.EdgeWeight = weights(map[uint64]float64{103: 2})
+		path, amountOut, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		assert.Equal(t, []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 4, PoolID: 103}}, path)
+
+		// The returned amount is the real, unweighted quote of the preferred pool.
+		directOut, err := graph.quoteRoute(path, d18)
+		require.NoError(t, err)
+		assert.Equal(t, directOut, amountOut)
+		assert.Equal(t, -1, amountOut.Cmp(bestOut))
+	})
+
+	t.Run("Weighting a pool wins its edge", func(t *testing.T) {
+		graph := setupPoolSelectorTestGraph(t)
+		params := chains.SwapFindingParams{TokenInID: 1, TokenOutID: 2, AmountIn: d18, Runs: 2}
+		path, _, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		require.Equal(t, uint64(201), path[0].PoolID)
+
+		params.EdgeWeight = weights(map[uint64]float64{202: 1.5})
+		path, _, err = graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(202), path[0].PoolID)
+	})
+
+	t.Run("A non-positive weight excludes the pool", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allActive)
+		for _, weight := range []float64{0, -1, math.NaN(), math.Inf(1)} {
+			path, _, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+				TokenInID:  1,
+				TokenOutID: 4,
+				AmountIn:   d18,
+				Runs:       3,
+				EdgeWeight: weights(map[uint64]float64{101: weight}),
+			})
+			require.NoError(t, err)
+			for _, hop := range path {
+				assert.NotEqual(t, uint64(101), hop.PoolID, "weight %v", weight)
+			}
+		}
+	})
+
+	t.Run("Not supported with FloatSearch", func(t *testing.T) {
+		graph := setupSwapPathTestGraph(t, allActive)
+		_, _, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+			TokenInID:   1,
+			TokenOutID:  4,
+			AmountIn:    d18,
+			Runs:        3,
+			FloatSearch: true,
+			EdgeWeight:  weights(nil),
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestGrapherTokenFilters(t *testing.T) {
+	weth := common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+	usdc := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	dai := common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F")
+	tokens := map[uint64]common.Address{1: weth, 2: usdc, 3: dai}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"), // WETH/USDC
+		102: common.HexToAddress("0x102"), // USDC/DAI
+		103: common.HexToAddress("0x103"), // WETH/DAI
+	}
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: big.NewInt(1_000_000), Reserve1: big.NewInt(2_000_000), FeeBps: 30},
+		{ID: 102, Token0: 2, Token1: 3, Reserve0: big.NewInt(1_000_000), Reserve1: big.NewInt(1_000_000), FeeBps: 30},
+		{ID: 103, Token0: 1, Token1: 3, Reserve0: big.NewInt(1_000_000), Reserve1: big.NewInt(2_000_000), FeeBps: 30},
+	}
+	tokenRegistry := tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+		{ID: 1, Address: addr.FromCommon(weth), Symbol: "WETH", Decimals: 18},
+		{ID: 2, Address: addr.FromCommon(usdc), Symbol: "USDC", Decimals: 6},
+		{ID: 3, Address: addr.FromCommon(dai), Symbol: "DAI", Decimals: 18},
+	})
+	schemas := map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}
+
+	buildGraph := func(t *testing.T, registry tokenregistryindexer.IndexedTokenSystem, opts ...Option) (chains.TokenPoolGraph, error) {
+		t.Helper()
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+		grapher, err := NewGrapher(opts...)
+		require.NoError(t, err)
+		return grapher.Graph(rawGraph, registry, poolRegistry, v2View, v3View, nil, chains.NewProtocolResolver(schemas, poolRegistry))
+	}
+	poolsUsed := func(t *testing.T, graph chains.TokenPoolGraph, tokenIn, tokenOut uint64) []uint64 {
+		t.Helper()
+		path, _, err := graph.FindBestSwapPath(chains.SwapFindingParams{TokenInID: tokenIn, TokenOutID: tokenOut, AmountIn: big.NewInt(1_000), Runs: 3})
+		require.NoError(t, err)
+		var used []uint64
+		for _, hop := range path {
+			used = append(used, hop.PoolID)
+		}
+		return used
+	}
+
+	t.Run("Denylisted tokens are pruned", func(t *testing.T) {
+		graph, err := buildGraph(t, tokenRegistry, WithTokenDenylist([]addr.Address{addr.FromCommon(dai)}))
+		require.NoError(t, err)
+
+		report := graph.BuildReport()
+		assert.Equal(t, 1, report.RoutablePools)
+		assert.ElementsMatch(t, []chains.SkippedPool{
+			{PoolID: 102, Reason: chains.SkipReasonTokenFiltered},
+			{PoolID: 103, Reason: chains.SkipReasonTokenFiltered},
+		}, report.Skipped)
+
+		assert.Equal(t, []uint64{101}, poolsUsed(t, graph, 1, 2))
+		_, _, err = graph.FindBestSwapPath(chains.SwapFindingParams{TokenInID: 1, TokenOutID: 3, AmountIn: big.NewInt(1_000), Runs: 3})
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+
+	t.Run("Only allowlisted tokens are routed", func(t *testing.T) {
+		graph, err := buildGraph(t, tokenRegistry, WithTokenAllowlist([]addr.Address{addr.FromCommon(weth), addr.FromCommon(dai)}))
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, graph.BuildReport().RoutablePools)
+		assert.Equal(t, []uint64{103}, poolsUsed(t, graph, 1, 3))
+	})
+
+	t.Run("Denylist wins over allowlist", func(t *testing.T) {
+		graph, err := buildGraph(t, tokenRegistry,
+			WithTokenAllowlist([]addr.Address{addr.FromCommon(weth), addr.FromCommon(usdc)}),
+			WithTokenDenylist([]addr.Address{addr.FromCommon(usdc)}),
+		)
+		require.NoError(t, err)
+		assert.Equal(t, 0, graph.BuildReport().RoutablePools)
+	})
+
+	t.Run("Filters require token metadata", func(t *testing.T) {
+		_, err := buildGraph(t, nil, WithTokenDenylist([]addr.Address{addr.FromCommon(dai)}))
+		assert.Error(t, err)
+
+		graph, err := buildGraph(t, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 3, graph.BuildReport().RoutablePools)
+	})
+
+	t.Run("ApplyDiff keeps the filter", func(t *testing.T) {
+		graph, err := buildGraph(t, tokenRegistry, WithTokenDenylist([]addr.Address{addr.FromCommon(dai)}))
+		require.NoError(t, err)
+
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+		next, err := graph.ApplyDiff(chains.GraphDiff{
+			TokenPool:            rawGraph,
+			IndexedTokenRegistry: tokenRegistry,
+			IndexedPoolRegistry:  poolRegistry,
+			IndexedUniswapV2:     v2View,
+			IndexedUniswapV3:     v3View,
+			ProtocolResolver:     chains.NewProtocolResolver(schemas, poolRegistry),
+			ChangedPools:         []uint64{102, 103},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, next.BuildReport().RoutablePools)
+	})
+}
+
+func TestGrapherGraphOptions(t *testing.T) {
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"), // WETH
+		2: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), // USDC
+		3: common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F"), // DAI
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"), // WETH/USDC, ~600k USD
+		102: common.HexToAddress("0x102"), // USDC/DAI, ~20 USD
+		103: common.HexToAddress("0x103"), // WETH/DAI, ~6k USD
+		104: common.HexToAddress("0x104"), // USDC/WETH (V3)
+	}
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: bigIntFromString("100000000000000000000"), Reserve1: big.NewInt(300_000_000_000), FeeBps: 30},
+		{ID: 102, Token0: 2, Token1: 3, Reserve0: big.NewInt(10_000_000), Reserve1: bigIntFromString("10000000000000000000"), FeeBps: 30},
+		{ID: 103, Token0: 1, Token1: 3, Reserve0: bigIntFromString("1000000000000000000"), Reserve1: bigIntFromString("3000000000000000000000"), FeeBps: 30},
+	}
+	v3Pool := setupUniswapV3ETHUSDCPool(2, 1, 104)
+	tokenRegistry := tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+		{ID: 1, Address: addr.FromCommon(tokens[1]), Symbol: "WETH", Decimals: 18},
+		{ID: 2, Address: addr.FromCommon(tokens[2]), Symbol: "USDC", Decimals: 6},
+		{ID: 3, Address: addr.FromCommon(tokens[3]), Symbol: "DAI", Decimals: 18},
+	})
+	schemas := map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}
+
+	buildGraph := func(t *testing.T, opts GraphOptions) (chains.TokenPoolGraph, error) {
+		t.Helper()
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{v3Pool})
+		grapher, err := NewGrapher(WithGraphOptions(opts))
+		require.NoError(t, err)
+		return grapher.Graph(rawGraph, tokenRegistry, poolRegistry, v2View, v3View, nil, chains.NewProtocolResolver(schemas, poolRegistry))
+	}
+
+	t.Run("Pools below MinReserveUSD are pruned", func(t *testing.T) {
+		graph, err := buildGraph(t, GraphOptions{MinReserveUSD: 1_000, NumeraireTokenID: 2})
+		require.NoError(t, err)
+
+		report := graph.BuildReport()
+		assert.Equal(t, 3, report.RoutablePools)
+		assert.Equal(t, []chains.SkippedPool{{PoolID: 102, Reason: chains.SkipReasonBelowLiquidity}}, report.Skipped)
+
+		path, _, err := graph.FindBestSwapPath(chains.SwapFindingParams{TokenInID: 2, TokenOutID: 3, AmountIn: big.NewInt(1_000_000), Runs: 3})
+		require.NoError(t, err)
+		for _, hop := range path {
+			assert.NotEqual(t, uint64(102), hop.PoolID)
+		}
+	})
+
+	t.Run("Pools below MinLiquidity are pruned", func(t *testing.T) {
+		graph, err := buildGraph(t, GraphOptions{MinLiquidity: v3Pool.Liquidity})
+		require.NoError(t, err)
+		assert.Equal(t, 4, graph.BuildReport().RoutablePools)
+
+		graph, err = buildGraph(t, GraphOptions{MinLiquidity: new(big.Int).Add(v3Pool.Liquidity, big.NewInt(1))})
+		require.NoError(t, err)
+		assert.Equal(t, []chains.SkippedPool{{PoolID: 104, Reason: chains.SkipReasonBelowLiquidity}}, graph.BuildReport().Skipped)
+	})
+
+	t.Run("The numeraire must be a known token", func(t *testing.T) {
+		_, err := buildGraph(t, GraphOptions{MinReserveUSD: 1_000, NumeraireTokenID: 99})
+		assert.Error(t, err)
+	})
+
+	t.Run("ApplyDiff prunes again", func(t *testing.T) {
+		graph, err := buildGraph(t, GraphOptions{MinReserveUSD: 1_000, NumeraireTokenID: 2})
+		require.NoError(t, err)
+
+		// The USDC/DAI pool deepens past the threshold.
+		deeper := append([]uniswapv2.Pool(nil), uniswapV2Pools...)
+		deeper[1].Reserve0 = big.NewInt(10_000_000_000)
+		deeper[1].Reserve1 = bigIntFromString("10000000000000000000000")
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, deeper, []uniswapv3.Pool{v3Pool})
+		next, err := graph.ApplyDiff(chains.GraphDiff{
+			TokenPool:            rawGraph,
+			IndexedTokenRegistry: tokenRegistry,
+			IndexedPoolRegistry:  poolRegistry,
+			IndexedUniswapV2:     v2View,
+			IndexedUniswapV3:     v3View,
+			ProtocolResolver:     chains.NewProtocolResolver(schemas, poolRegistry),
+			ChangedPools:         []uint64{102},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 4, next.BuildReport().RoutablePools)
+		assert.Empty(t, next.BuildReport().Skipped)
+	})
+
+	t.Run("Illiquid pools do not price tokens", func(t *testing.T) {
+		scamTokens := maps.Clone(tokens)
+		scamTokens[4] = common.HexToAddress("0x4")
+		scamPools := maps.Clone(pools)
+		scamPools[105] = common.HexToAddress("0x105") // USDC/SCAM, ~20 USD, prices SCAM at 10 USD
+		scamPools[106] = common.HexToAddress("0x106") // DAI/SCAM, 1,000 SCAM against dust
+		scamV2Pools := append(append([]uniswapv2.Pool(nil), uniswapV2Pools...),
+			uniswapv2.Pool{ID: 105, Token0: 2, Token1: 4, Reserve0: big.NewInt(10_000_000), Reserve1: bigIntFromString("1000000000000000000"), FeeBps: 30},
+			uniswapv2.Pool{ID: 106, Token0: 3, Token1: 4, Reserve0: big.NewInt(1_000), Reserve1: bigIntFromString("1000000000000000000000"), FeeBps: 30},
+		)
+		registry := tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+			{ID: 1, Address: addr.FromCommon(tokens[1]), Symbol: "WETH", Decimals: 18},
+			{ID: 2, Address: addr.FromCommon(tokens[2]), Symbol: "USDC", Decimals: 6},
+			{ID: 3, Address: addr.FromCommon(tokens[3]), Symbol: "DAI", Decimals: 18},
+			{ID: 4, Address: addr.FromCommon(scamTokens[4]), Symbol: "SCAM", Decimals: 18},
+		})
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, scamTokens, scamPools, scamV2Pools, []uniswapv3.Pool{v3Pool})
+		grapher, err := NewGrapher(WithGraphOptions(GraphOptions{MinReserveUSD: 1_000, NumeraireTokenID: 2}))
+		require.NoError(t, err)
+		graph, err := grapher.Graph(rawGraph, registry, poolRegistry, v2View, v3View, nil, chains.NewProtocolResolver(schemas, poolRegistry))
+		require.NoError(t, err)
+
+		// Valued at the 10 USD the shallow pool quotes, pool 106 would hold 10,000 USD.
+		assert.NotContains(t, graph.(*Graph).liquidityPrices, uint64(4))
+		assert.Equal(t, []chains.SkippedPool{
+			{PoolID: 102, Reason: chains.SkipReasonBelowLiquidity},
+			{PoolID: 105, Reason: chains.SkipReasonBelowLiquidity},
+			{PoolID: 106, Reason: chains.SkipReasonBelowLiquidity},
+		}, graph.BuildReport().Skipped)
+	})
+
+	t.Run("Fixed prices value reserves", func(t *testing.T) {
+		// Without a WETH price, pool 101 is worth its 300,000 USDC and pool 103 its 3,000 DAI.
+		graph, err := buildGraph(t, GraphOptions{MinReserveUSD: 1_000, PricesUSD: map[uint64]float64{2: 1, 3: 1}})
+		require.NoError(t, err)
+		assert.Equal(t, []chains.SkippedPool{{PoolID: 102, Reason: chains.SkipReasonBelowLiquidity}}, graph.BuildReport().Skipped)
+
+		graph, err = buildGraph(t, GraphOptions{MinReserveUSD: 5_000, PricesUSD: map[uint64]float64{2: 1, 3: 1}})
+		require.NoError(t, err)
+		assert.Contains(t, graph.BuildReport().Skipped, chains.SkippedPool{PoolID: 103, Reason: chains.SkipReasonBelowLiquidity})
+	})
+
+	t.Run("ApplyDiff keeps the prices", func(t *testing.T) {
+		graph, err := buildGraph(t, GraphOptions{MinReserveUSD: 1_000, NumeraireTokenID: 2})
+		require.NoError(t, err)
+		prices := graph.(*Graph).liquidityPrices
+		require.Contains(t, prices, uint64(1))
+		require.Contains(t, prices, uint64(3))
+		assert.InDelta(t, 3_000, prices[1]/prices[3], 1, "DAI is priced through WETH at pool 103's spot price")
+
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{v3Pool})
+		next, err := graph.ApplyDiff(chains.GraphDiff{
+			TokenPool:            rawGraph,
+			IndexedTokenRegistry: tokenRegistry,
+			IndexedPoolRegistry:  poolRegistry,
+			IndexedUniswapV2:     v2View,
+			IndexedUniswapV3:     v3View,
+			ProtocolResolver:     chains.NewProtocolResolver(schemas, poolRegistry),
+			ChangedPools:         []uint64{101, 102, 103},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, prices, next.(*Graph).liquidityPrices)
+		assert.Equal(t, graph.BuildReport().Skipped, next.BuildReport().Skipped)
+	})
+}
+
+func TestGrapherUnknownTokens(t *testing.T) {
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0x1"),
+		2: common.HexToAddress("0x2"),
+		3: common.HexToAddress("0x3"), // not in the token registry
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"), // token 1/token 2
+		102: common.HexToAddress("0x102"), // token 1/token 3
+	}
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: big.NewInt(1_000_000), Reserve1: big.NewInt(2_000_000), FeeBps: 30},
+		{ID: 102, Token0: 1, Token1: 3, Reserve0: big.NewInt(1_000_000), Reserve1: big.NewInt(3_000_000), FeeBps: 30},
+	}
+	tokenRegistry := tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+		{ID: 1, Symbol: "WETH", Decimals: 18},
+		{ID: 2, Symbol: "USDC", Decimals: 6},
+	})
+	schemas := map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}
+	buildGraph := func(t *testing.T, opts ...Option) chains.TokenPoolGraph {
+		t.Helper()
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+		grapher, err := NewGrapher(opts...)
+		require.NoError(t, err)
+		graph, err := grapher.Graph(rawGraph, tokenRegistry, poolRegistry, v2View, v3View, nil, chains.NewProtocolResolver(schemas, poolRegistry))
+		require.NoError(t, err)
+		return graph
+	}
+	params := chains.SwapFindingParams{TokenInID: 1, TokenOutID: 3, AmountIn: big.NewInt(1_000), Runs: 2}
+
+	t.Run("Skipped by default", func(t *testing.T) {
+		graph := buildGraph(t)
+
+		report := graph.BuildReport()
+		assert.Equal(t, 1, report.RoutablePools)
+		assert.Equal(t, []chains.SkippedPool{{PoolID: 102, Reason: chains.SkipReasonUnknownToken}}, report.Skipped)
+		assert.Equal(t, []uint64{102}, report.UnknownTokenPools)
+
+		_, _, err := graph.FindBestSwapPath(params)
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+
+	t.Run("Routed by ID with default decimals", func(t *testing.T) {
+		graph := buildGraph(t, WithUnknownTokenPolicy(RouteUnknownTokensWithDefaultDecimals, 18))
+
+		report := graph.BuildReport()
+		assert.Equal(t, 2, report.RoutablePools)
+		assert.Empty(t, report.Skipped)
+		assert.Equal(t, []uint64{102}, report.UnknownTokenPools)
+
+		path, _, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		assert.Equal(t, []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 3, PoolID: 102}}, path)
+
+		// Decimals-aware features fall back to the default decimals.
+		grapherGraph := graph.(*Graph)
+		decimals0, decimals1, ok := grapherGraph.PoolTokenDecimals(102)
+		require.True(t, ok)
+		assert.Equal(t, uint8(18), decimals0)
+		assert.Equal(t, uint8(18), decimals1)
+
+		rate, err := graph.RateForSize(1, 3, big.NewInt(1_000))
+		require.NoError(t, err)
+		rateF, _ := rate.Float64()
+		assert.InDelta(t, 2.98, rateF, 0.01)
+	})
+}
+
+func TestGraphBuildReport(t *testing.T) {
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"), // WETH
+		2: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), // USDC
+		3: common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F"), // DAI
+		4: common.HexToAddress("0x2260FAC5E5542a773Aa44fBCfeDf7C193bc2C599"), // WBTC
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc"), // V2 WETH/USDC
+		102: common.HexToAddress("0xC2e9F25Be6257c210d7Adf0D4Cd6E3E881ba25f8"), // V3 WETH/DAI
+		104: common.HexToAddress("0xBb2b8038a1640196FbE3e38816F3e67Cba72D940"), // V2 WETH/WBTC
+	}
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: big.NewInt(1000), Reserve1: big.NewInt(2000000), FeeBps: 30},
+		{ID: 104, Token0: 1, Token1: 4, Reserve0: big.NewInt(500), Reserve1: big.NewInt(10), FeeBps: 30},
+	}
+	uniswapV3Pools := []uniswapv3.Pool{
+		{PoolViewMinimal: uniswapv3.PoolViewMinimal{ID: 102, Token0: 1, Token1: 3, Fee: 3000}},
+	}
+
+	buildGraph := func(
+		t *testing.T,
+		schemas map[engine.ProtocolID]engine.ProtocolSchema,
+		mutate func(poolRegistry *mockIndexedPoolRegistry, v2View *mockIndexedUniswapV2, v3View *mockIndexedUniswapV3),
+	) *Graph {
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, uniswapV3Pools)
+		if mutate != nil {
+			mutate(poolRegistry.(*mockIndexedPoolRegistry), v2View.(*mockIndexedUniswapV2), v3View.(*mockIndexedUniswapV3))
+		}
+		graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{}, chains.NewProtocolResolver(schemas, poolRegistry))
+		require.NoError(t, err)
+		return graph
+	}
+
+	defaultSchemas := map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}
+
+	t.Run("Healthy graph reports no skipped pools", func(t *testing.T) {
+		report := buildGraph(t, defaultSchemas, nil).BuildReport()
+		assert.Equal(t, 3, report.TotalPools)
+		assert.Equal(t, 3, report.RoutablePools)
+		assert.Empty(t, report.Skipped)
+	})
+
+	t.Run("Missing registry entries and pool data are reported", func(t *testing.T) {
+		graph := buildGraph(t, defaultSchemas, func(poolRegistry *mockIndexedPoolRegistry, v2View *mockIndexedUniswapV2, _ *mockIndexedUniswapV3) {
+			delete(poolRegistry.poolsByID, 102)
+			delete(v2View.poolsByID, 104)
+		})
+
+		report := graph.BuildReport()
+		assert.Equal(t, 3, report.TotalPools)
+		assert.Equal(t, 1, report.RoutablePools)
+		assert.ElementsMatch(t, []chains.SkippedPool{
+			{PoolID: 102, Reason: chains.SkipReasonNotInPoolRegistry},
+			{PoolID: 104, Reason: chains.SkipReasonMissingPoolData},
+		}, report.Skipped)
+
+		// Skipped pools must not be used for routing.
+		_, _, err := graph.FindBestSwapPath(chains.SwapFindingParams{TokenInID: 1, TokenOutID: 4, AmountIn: big.NewInt(100), Runs: 2})
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+
+	t.Run("Grapher skips and reports pools missing from the protocol indexer", func(t *testing.T) {
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, uniswapV3Pools)
+		// Pool 102 stays in the pool registry but is missing from the V3 indexer.
+		delete(v3View.(*mockIndexedUniswapV3).poolsByID, 102)
+
+		grapher, err := NewGrapher()
+		require.NoError(t, err)
+		graph, err := grapher.Graph(rawGraph, nil, poolRegistry, v2View, v3View, nil, chains.NewProtocolResolver(defaultSchemas, poolRegistry))
+		require.NoError(t, err)
+
+		report := graph.BuildReport()
+		assert.Equal(t, 2, report.RoutablePools)
+		assert.Equal(t, []chains.SkippedPool{{PoolID: 102, Reason: chains.SkipReasonMissingPoolData}}, report.Skipped)
+
+		// Routing over the remaining pools still works.
+		_, _, err = graph.FindBestSwapPath(chains.SwapFindingParams{TokenInID: 1, TokenOutID: 2, AmountIn: big.NewInt(100), Runs: 2})
+		require.NoError(t, err)
+		_, _, err = graph.FindBestSwapPath(chains.SwapFindingParams{TokenInID: 1, TokenOutID: 3, AmountIn: big.NewInt(100), Runs: 2})
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+
+	t.Run("Unresolvable and unsupported schemas are reported", func(t *testing.T) {
+		unresolved := buildGraph(t, map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+		}, nil)
+		assert.Equal(t, []chains.SkippedPool{{PoolID: 102, Reason: chains.SkipReasonUnknownSchema}}, unresolved.BuildReport().Skipped)
+
+		unsupported := buildGraph(t, map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+			uniswapV3ProtocolID: "defistate/unsupported@v1",
+		}, nil)
+		assert.Equal(t, []chains.SkippedPool{{PoolID: 102, Reason: chains.SkipReasonUnsupportedSchema}}, unsupported.BuildReport().Skipped)
+	})
+
+	t.Run("Report is a copy", func(t *testing.T) {
+		graph := buildGraph(t, defaultSchemas, func(_ *mockIndexedPoolRegistry, v2View *mockIndexedUniswapV2, _ *mockIndexedUniswapV3) {
+			delete(v2View.poolsByID, 104)
+		})
+		report := graph.BuildReport()
+		require.Len(t, report.Skipped, 1)
+		report.Skipped[0].PoolID = 999
+		assert.Equal(t, uint64(104), graph.BuildReport().Skipped[0].PoolID)
+	})
+}
+
+func TestGraphDuplicatePoolIDs(t *testing.T) {
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"), // WETH
+		2: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), // USDC
+		3: common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F"), // DAI
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc"), // V2 WETH/USDC
+		102: common.HexToAddress("0xC2e9F25Be6257c210d7Adf0D4Cd6E3E881ba25f8"), // V3 WETH/DAI
+	}
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: big.NewInt(1000), Reserve1: big.NewInt(2000000), FeeBps: 30},
+	}
+	uniswapV3Pools := []uniswapv3.Pool{
+		{PoolViewMinimal: uniswapv3.PoolViewMinimal{ID: 102, Token0: 1, Token1: 3, Fee: 3000}},
+	}
+	schemas := map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}
+	// requirements returns the graph views with pool 102 also claimed by the V2 protocol.
+	requirements := func(t *testing.T) (*tokenpoolregistry.TokenPoolRegistryView, poolregistryindexer.IndexedPoolRegistry, uniswapv2indexer.IndexedUniswapV2, uniswapv3indexer.IndexedUniswapV3) {
+		t.Helper()
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, uniswapV3Pools)
+		v2View.(*mockIndexedUniswapV2).poolsByID[102] = uniswapv2.Pool{
+			ID: 102, Token0: 1, Token1: 3, Reserve0: big.NewInt(1000), Reserve1: big.NewInt(3000), FeeBps: 30,
+		}
+		return rawGraph, poolRegistry, v2View, v3View
+	}
+
+	t.Run("NewGraph skips and reports the conflict", func(t *testing.T) {
+		rawGraph, poolRegistry, v2View, v3View := requirements(t)
+		graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{}, chains.NewProtocolResolver(schemas, poolRegistry))
+		require.NoError(t, err)
+
+		report := graph.BuildReport()
+		assert.Equal(t, []uint64{102}, report.DuplicatePoolIDs)
+		assert.Equal(t, 1, report.RoutablePools)
+		assert.Equal(t, []chains.SkippedPool{{PoolID: 102, Reason: chains.SkipReasonDuplicatePoolID}}, report.Skipped)
+
+		_, _, err = graph.FindBestSwapPath(chains.SwapFindingParams{TokenInID: 1, TokenOutID: 3, AmountIn: big.NewInt(100), Runs: 2})
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+
+	t.Run("Grapher rejects the conflict when configured", func(t *testing.T) {
+		rawGraph, poolRegistry, v2View, v3View := requirements(t)
+		resolver := chains.NewProtocolResolver(schemas, poolRegistry)
+
+		grapher, err := NewGrapher()
+		require.NoError(t, err)
+		graph, err := grapher.Graph(rawGraph, nil, poolRegistry, v2View, v3View, nil, resolver)
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{102}, graph.BuildReport().DuplicatePoolIDs)
+
+		grapher, err = NewGrapher(WithDuplicatePoolPolicy(RejectDuplicatePools))
+		require.NoError(t, err)
+		_, err = grapher.Graph(rawGraph, nil, poolRegistry, v2View, v3View, nil, resolver)
+		assert.ErrorIs(t, err, chains.ErrDuplicatePoolID)
+	})
+
+	t.Run("ApplyDiff detects a new conflict on an unchanged pool", func(t *testing.T) {
+		buildGraph := func(t *testing.T, opts ...Option) chains.TokenPoolGraph {
+			t.Helper()
+			rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, uniswapV3Pools)
+			grapher, err := NewGrapher(opts...)
+			require.NoError(t, err)
+			graph, err := grapher.Graph(rawGraph, nil, poolRegistry, v2View, v3View, nil, chains.NewProtocolResolver(schemas, poolRegistry))
+			require.NoError(t, err)
+			require.Empty(t, graph.BuildReport().DuplicatePoolIDs)
+			return graph
+		}
+		rawGraph, poolRegistry, v2View, v3View := requirements(t)
+		// Pool 102 is not listed as changed, so it would otherwise keep its V3 functions.
+		diff := chains.GraphDiff{
+			TokenPool:           rawGraph,
+			IndexedPoolRegistry: poolRegistry,
+			IndexedUniswapV2:    v2View,
+			IndexedUniswapV3:    v3View,
+			ProtocolResolver:    chains.NewProtocolResolver(schemas, poolRegistry),
+		}
+
+		next, err := buildGraph(t).ApplyDiff(diff)
+		require.NoError(t, err)
+		report := next.BuildReport()
+		assert.Equal(t, []uint64{102}, report.DuplicatePoolIDs)
+		assert.Equal(t, []chains.SkippedPool{{PoolID: 102, Reason: chains.SkipReasonDuplicatePoolID}}, report.Skipped)
+
+		_, err = buildGraph(t, WithDuplicatePoolPolicy(RejectDuplicatePools)).ApplyDiff(diff)
+		assert.ErrorIs(t, err, chains.ErrDuplicatePoolID)
+	})
+}
+
+// TestGraphV3FeeOverride builds a graph over a V3 pool that charges a fee other than its
+// fee tier, as on forks with dynamic fees, and expects the fee it charges to be quoted
+// and reported.
+func TestGraphV3FeeOverride(t *testing.T) {
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"), // WETH
+		2: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), // USDC
+	}
+	pools := map[uint64]common.Address{
+		301: common.HexToAddress("0x301"), // 0.05% tier, charging 1%
+		302: common.HexToAddress("0x302"), // 0.3% tier
+	}
+	liquidity := new(big.Int).Mul(big.NewInt(1_000_000), big.NewInt(1e18))
+	pool := func(id, fee uint64, feeOverride *uint64) uniswapv3.Pool {
+		return uniswapv3.Pool{
+			PoolViewMinimal: uniswapv3.PoolViewMinimal{ID: id, Token0: 1, Token1: 2, Fee: fee, TickSpacing: 60, Liquidity: liquidity, SqrtPriceX96: new(big.Int).Lsh(big.NewInt(1), 96)},
+			Ticks: []uniswapv3.TickInfo{
+				{Index: -887220, LiquidityGross: liquidity, LiquidityNet: liquidity},
+				{Index: 887220, LiquidityGross: liquidity, LiquidityNet: new(big.Int).Neg(liquidity)},
+			},
+			FeeOverride: feeOverride,
+		}
+	}
+	feeOverride := uint64(10_000)
+	uniswapV3Pools := []uniswapv3.Pool{pool(301, 500, &feeOverride), pool(302, 3000, nil)}
+
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, []uniswapv2.Pool{}, uniswapV3Pools)
+	resolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}, poolRegistry)
+	graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{301: {}, 302: {}}, resolver)
+	require.NoError(t, err)
+	params := chains.SwapFindingParams{TokenInID: 1, TokenOutID: 2, AmountIn: big.NewInt(1e18), Runs: 2}
+
+	t.Run("Candidates report the fee swaps pay", func(t *testing.T) {
+		fees := make(map[uint64]uint64)
+		params := params
+		params.PoolSelector = chains.PoolSelectorFunc(func(_, _ uint64, candidates []chains.PoolCandidate) int {
+			for _, candidate := range candidates {
+				fees[candidate.PoolID] = candidate.FeePips
+			}
+			return 0
+		})
+		_, _, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		assert.Equal(t, map[uint64]uint64{301: 10_000, 302: 3000}, fees)
+
+		params.PoolSelector = chains.LowestFeeSelector{}
+		path, _, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(302), path[0].PoolID, "the pool with the lower tier charges more")
+	})
+
+	t.Run("The float quote charges the override", func(t *testing.T) {
+		quote := graph.cachedGetAmountOutFuncs[graph.poolToIndex[301]]
+		require.NotNil(t, quote)
+		amountOut, err := quote(1e18, 1, 2)
+		require.NoError(t, err)
+
+		atOverride, err := uniswapV3FloatQuote(pool(301, feeOverride, nil))(1e18, 1, 2)
+		require.NoError(t, err)
+		atTier, err := uniswapV3FloatQuote(pool(301, 500, nil))(1e18, 1, 2)
+		require.NoError(t, err)
+		assert.Equal(t, atOverride, amountOut)
+		assert.Less(t, amountOut, atTier)
+	})
+}
+
+func TestBreakEvenFee(t *testing.T) {
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"), // WETH
+		2: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), // USDC
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"), // WETH/USDC 0.30%
+		102: common.HexToAddress("0x102"), // WETH/USDC 0.05%
+	}
+	reserveWETH := new(big.Int).Mul(big.NewInt(1_000), big.NewInt(1e18))
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: reserveWETH, Reserve1: big.NewInt(3_000_000e6), FeeBps: 30},
+		{ID: 102, Token0: 1, Token1: 2, Reserve0: reserveWETH, Reserve1: big.NewInt(3_000_000e6), FeeBps: 5},
+	}
+	schemas := map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+	graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{}, chains.NewProtocolResolver(schemas, poolRegistry))
+	require.NoError(t, err)
+
+	expensive := []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 2, PoolID: 101}}
+	cheap := []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 2, PoolID: 102}}
+	amountIn := big.NewInt(1e18)
+
+	// bruteForce raises the extra fee one pip at a time until the better pool falls behind.
+	bruteForce := func(t *testing.T, better uniswapv2.Pool, worseOut *big.Int) uint64 {
+		t.Helper()
+		for fee := int64(0); fee <= 1_000_000; fee++ {
+			in := new(big.Int).Mul(amountIn, big.NewInt(1_000_000-fee))
+			in.Quo(in, big.NewInt(1_000_000))
+			out, err := uniswapv2calculator.GetAmountOut(in, 1, 2, better)
+			require.NoError(t, err)
+			if out.Cmp(worseOut) < 0 {
+				return uint64(fee - 1)
+			}
+		}
+		return 1_000_000
+	}
+
+	t.Run("Cheaper fee tier can bear the fee difference", func(t *testing.T) {
+		result, err := graph.BreakEvenFee(chains.BreakEvenFeeParams{RouteA: expensive, RouteB: cheap, AmountIn: amountIn})
+		require.NoError(t, err)
+
+		assert.False(t, result.BetterIsA)
+		assert.Equal(t, 1, result.AmountOutB.Cmp(result.AmountOutA))
+		assert.Equal(t, bruteForce(t, uniswapV2Pools[1], result.AmountOutA), result.FeePips)
+		// Identical reserves: the break-even is about the 25 bip fee difference.
+		assert.InDelta(t, 2500, float64(result.FeePips), 10)
+	})
+
+	t.Run("Order of the routes does not matter", func(t *testing.T) {
+		result, err := graph.BreakEvenFee(chains.BreakEvenFeeParams{RouteA: cheap, RouteB: expensive, AmountIn: amountIn})
+		require.NoError(t, err)
+
+		assert.True(t, result.BetterIsA)
+		assert.Equal(t, bruteForce(t, uniswapV2Pools[1], result.AmountOutB), result.FeePips)
+	})
+
+	t.Run("Identical routes break even at zero", func(t *testing.T) {
+		result, err := graph.BreakEvenFee(chains.BreakEvenFeeParams{RouteA: cheap, RouteB: cheap, AmountIn: amountIn})
+		require.NoError(t, err)
+		assert.True(t, result.BetterIsA)
+		assert.Zero(t, result.FeePips)
+	})
+
+	t.Run("Invalid input", func(t *testing.T) {
+		_, err := graph.BreakEvenFee(chains.BreakEvenFeeParams{RouteA: cheap, RouteB: expensive})
+		assert.Error(t, err)
+
+		reversed := []chains.TokenPoolPath{{TokenInID: 2, TokenOutID: 1, PoolID: 101}}
+		_, err = graph.BreakEvenFee(chains.BreakEvenFeeParams{RouteA: cheap, RouteB: reversed, AmountIn: amountIn})
+		assert.Error(t, err)
+
+		unknown := []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 2, PoolID: 999}}
+		_, err = graph.BreakEvenFee(chains.BreakEvenFeeParams{RouteA: cheap, RouteB: unknown, AmountIn: amountIn})
+		assert.Error(t, err)
+	})
+}
+
+func TestFindCheapestAcceptableRoute(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	setup := func(t *testing.T) *Graph {
+		tokens := map[uint64]common.Address{
+			1: common.HexToAddress("0xA"),
+			2: common.HexToAddress("0xB"),
+			3: common.HexToAddress("0xC"),
+			4: common.HexToAddress("0xD"),
+		}
+		pools := map[uint64]common.Address{
+			101: common.HexToAddress("0x101"), // A -> D (Direct, Low Liquidity)
+			102: common.HexToAddress("0x102"), // A -> B (High Liquidity)
+			103: common.HexToAddress("0x103"), // B -> C (High Liquidity)
+			104: common.HexToAddress("0x104"), // C -> D (High Liquidity)
+		}
+		deep := new(big.Int).Mul(big.NewInt(10000), d18)
+		shallow := new(big.Int).Mul(big.NewInt(10), d18)
+		uniswapV2Pools := []uniswapv2.Pool{
+			{ID: 101, Token0: 1, Token1: 4, Reserve0: shallow, Reserve1: shallow, FeeBps: 30},
+			{ID: 102, Token0: 1, Token1: 2, Reserve0: deep, Reserve1: deep, FeeBps: 30},
+			{ID: 103, Token0: 2, Token1: 3, Reserve0: deep, Reserve1: deep, FeeBps: 30},
+			{ID: 104, Token0: 3, Token1: 4, Reserve0: deep, Reserve1: deep, FeeBps: 30},
+		}
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+		protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+			uniswapV3ProtocolID: uniswapv3.Schema,
+		}, poolRegistry)
+		graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{101: {}, 102: {}, 103: {}, 104: {}}, protocolResolver)
+		require.NoError(t, err)
+		return graph
+	}
+
+	gasEstimates := chains.GasEstimates{uniswapv2.Schema: 100_000}
+	amountIn := new(big.Int).Set(d18)
+
+	t.Run("Cheaper 1-hop route wins when both clear the minimum", func(t *testing.T) {
+		graph := setup(t)
+
+		bestPath, bestOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{TokenInID: 1, TokenOutID: 4, AmountIn: amountIn, Runs: 3})
+		require.NoError(t, err)
+		require.Len(t, bestPath, 3, "the output-maximizing route is the 3-hop route")
+
+		route, err := graph.FindCheapestAcceptableRoute(chains.CheapestRouteParams{
+			TokenInID:        1,
+			TokenOutID:       4,
+			AmountIn:         amountIn,
+			MinAcceptableOut: new(big.Int).Div(new(big.Int).Mul(d18, big.NewInt(8)), big.NewInt(10)), // 0.8
+			GasEstimates:     gasEstimates,
+			Runs:             3,
+		})
+		require.NoError(t, err)
+		require.Len(t, route.Path, 1)
+		assert.Equal(t, uint64(101), route.Path[0].PoolID)
+		assert.Equal(t, uint64(100_000), route.GasUsed)
+		assert.Equal(t, -1, route.AmountOut.Cmp(bestOut), "the cheap route accepts less output")
+	})
+
+	t.Run("Falls back to the costlier route when the cheap one misses the minimum", func(t *testing.T) {
+		graph := setup(t)
+		route, err := graph.FindCheapestAcceptableRoute(chains.CheapestRouteParams{
+			TokenInID:        1,
+			TokenOutID:       4,
+			AmountIn:         amountIn,
+			MinAcceptableOut: new(big.Int).Div(new(big.Int).Mul(d18, big.NewInt(95)), big.NewInt(100)), // 0.95
+			GasEstimates:     gasEstimates,
+			Runs:             3,
+		})
+		require.NoError(t, err)
+		require.Len(t, route.Path, 3)
+		assert.Equal(t, uint64(300_000), route.GasUsed)
+	})
+
+	t.Run("Returns ErrNoRoute when nothing clears the minimum", func(t *testing.T) {
+		graph := setup(t)
+		_, err := graph.FindCheapestAcceptableRoute(chains.CheapestRouteParams{
+			TokenInID:        1,
+			TokenOutID:       4,
+			AmountIn:         amountIn,
+			MinAcceptableOut: amountIn,
+			GasEstimates:     gasEstimates,
+			Runs:             3,
+		})
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+
+	t.Run("Pools without a gas estimate are not used", func(t *testing.T) {
+		graph := setup(t)
+		_, err := graph.FindCheapestAcceptableRoute(chains.CheapestRouteParams{
+			TokenInID:        1,
+			TokenOutID:       4,
+			AmountIn:         amountIn,
+			MinAcceptableOut: big.NewInt(1),
+			GasEstimates:     chains.GasEstimates{uniswapv3.Schema: 150_000},
+			Runs:             3,
+		})
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+
+	t.Run("Invalid params", func(t *testing.T) {
+		graph := setup(t)
+		_, err := graph.FindCheapestAcceptableRoute(chains.CheapestRouteParams{TokenInID: 1, TokenOutID: 4, MinAcceptableOut: big.NewInt(1), Runs: 3})
+		assert.Error(t, err)
+		_, err = graph.FindCheapestAcceptableRoute(chains.CheapestRouteParams{TokenInID: 1, TokenOutID: 4, AmountIn: amountIn, MinAcceptableOut: big.NewInt(1)})
+		assert.Error(t, err)
+		_, err = graph.FindCheapestAcceptableRoute(chains.CheapestRouteParams{TokenInID: 1, TokenOutID: 99, AmountIn: amountIn, MinAcceptableOut: big.NewInt(1), Runs: 3})
+		assert.Error(t, err)
+	})
+}
+
+func TestGasModelRouting(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	gasModel := func(gwei int64) *chains.BlockGasModel {
+		return chains.NewBlockGasModel(engine.BlockSummary{BaseFee: big.NewInt(gwei * 1e9)}, chains.GasEstimates{uniswapv2.Schema: 100_000}, nil)
+	}
+
+	t.Run("Swap routes are ranked net of gas", func(t *testing.T) {
+		tokens := map[uint64]common.Address{
+			1: common.HexToAddress("0xA"),
+			2: common.HexToAddress("0xB"),
+			3: common.HexToAddress("0xC"),
+			4: common.HexToAddress("0xD"),
+		}
+		pools := map[uint64]common.Address{
+			101: common.HexToAddress("0x101"), // A -> D (Direct, Low Liquidity)
+			102: common.HexToAddress("0x102"), // A -> B (High Liquidity)
+			103: common.HexToAddress("0x103"), // B -> C (High Liquidity)
+			104: common.HexToAddress("0x104"), // C -> D (High Liquidity)
+		}
+		deep := new(big.Int).Mul(big.NewInt(10000), d18)
+		shallow := new(big.Int).Mul(big.NewInt(10), d18)
+		uniswapV2Pools := []uniswapv2.Pool{
+			{ID: 101, Token0: 1, Token1: 4, Reserve0: shallow, Reserve1: shallow, FeeBps: 30},
+			{ID: 102, Token0: 1, Token1: 2, Reserve0: deep, Reserve1: deep, FeeBps: 30},
+			{ID: 103, Token0: 2, Token1: 3, Reserve0: deep, Reserve1: deep, FeeBps: 30},
+			{ID: 104, Token0: 3, Token1: 4, Reserve0: deep, Reserve1: deep, FeeBps: 30},
+		}
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+		protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+			uniswapV3ProtocolID: uniswapv3.Schema,
+		}, poolRegistry)
+		graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{101: {}, 102: {}, 103: {}, 104: {}}, protocolResolver)
+		require.NoError(t, err)
+
+		params := chains.SwapFindingParams{TokenInID: 1, TokenOutID: 4, AmountIn: d18, Runs: 3, NativeTokenID: 1}
+
+		// At 1 gwei the two extra hops cost 0.0002 A, less than the 3-hop route's extra output.
+		params.GasModel = gasModel(1)
+		path, _, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		assert.Len(t, path, 3)
+
+		// At 1,000 gwei they cost 0.2 A, so the thin direct pool wins.
+		params.GasModel = gasModel(1000)
+		path, amountOut, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		require.Len(t, path, 1)
+		assert.Equal(t, uint64(101), path[0].PoolID)
+		direct, err := graph.quoteRoute(path, d18)
+		require.NoError(t, err)
+		assert.Equal(t, direct, amountOut, "the returned amount is the gross output")
+
+		// Pools without a gas estimate are not used.
+		params.GasModel = chains.NewBlockGasModel(engine.BlockSummary{}, chains.GasEstimates{uniswapv3.Schema: 100_000}, nil)
+		_, _, err = graph.FindBestSwapPath(params)
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+
+		params.GasModel = gasModel(1)
+		params.FloatSearch = true
+		_, _, err = graph.FindBestSwapPath(params)
+		assert.Error(t, err, "GasModel is not supported with FloatSearch")
+	})
+
+	t.Run("Cycles are ranked and filtered net of gas", func(t *testing.T) {
+		graph := setupMultiCycleTestGraph(t)
+		params := chains.CycleFindingParams{
+			TokenID:       1,
+			AmountIn:      new(big.Int).SetUint64(1e18),
+			Runs:          3,
+			GasModel:      gasModel(1),
+			NativeTokenID: 1,
+		}
+		cycles, amounts, err := graph.FindAllArbitrageCycles(params, 0)
+		require.NoError(t, err)
+		require.Greater(t, len(cycles), 1)
+
+		// Gas is paid in the start token, so a hop costs exactly 100,000 gwei of it.
+		hopCost := big.NewInt(100_000 * 1e9)
+		net := func(i int) *big.Int {
+			cost := new(big.Int).Mul(hopCost, big.NewInt(int64(len(cycles[i]))))
+			return cost.Sub(amounts[i], cost)
+		}
+		for i := 1; i < len(cycles); i++ {
+			assert.True(t, net(i-1).Cmp(net(i)) >= 0, "cycles must be ordered by net profit")
+		}
+
+		// At 100,000 gwei a single hop costs 0.01 WETH, more than any cycle makes.
+		params.GasModel = gasModel(100_000)
+		params.MinProfit = &chains.ProfitThreshold{Unit: chains.ProfitUnitRaw, Amount: big.NewFloat(0)}
+		cycles, _, err = graph.FindAllArbitrageCycles(params, 0)
+		require.NoError(t, err)
+		assert.Empty(t, cycles)
+
+		cycles, _, err = graph.FindArbitrageCycles(params)
+		require.NoError(t, err)
+		assert.Empty(t, cycles)
+	})
+}
+
+// recordingLogger captures warnings so tests can assert on logged discrepancies.
+type recordingLogger struct {
+	warnings []string
+}
+
+func (l *recordingLogger) Debug(msg string, args ...any) {}
+func (l *recordingLogger) Info(msg string, args ...any)  {}
+func (l *recordingLogger) Warn(msg string, args ...any)  { l.warnings = append(l.warnings, msg) }
+func (l *recordingLogger) Error(msg string, args ...any) {}
+
+func TestFindBestSwapPathValidationFallback(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	setup := func(t *testing.T) *Graph {
+		tokens := map[uint64]common.Address{
+			1: common.HexToAddress("0xA"),
+			2: common.HexToAddress("0xB"),
+			3: common.HexToAddress("0xC"),
+			4: common.HexToAddress("0xD"),
+		}
+		pools := map[uint64]common.Address{
+			101: common.HexToAddress("0x101"), // A -> D (Direct, Low Liquidity)
+			102: common.HexToAddress("0x102"), // A -> B (High Liquidity)
+			103: common.HexToAddress("0x103"), // B -> C (High Liquidity)
+			104: common.HexToAddress("0x104"), // C -> D (High Liquidity)
+		}
+		deep := new(big.Int).Mul(big.NewInt(10000), d18)
+		shallow := new(big.Int).Mul(big.NewInt(10), d18)
+		uniswapV2Pools := []uniswapv2.Pool{
+			{ID: 101, Token0: 1, Token1: 4, Reserve0: shallow, Reserve1: shallow, FeeBps: 30},
+			{ID: 102, Token0: 1, Token1: 2, Reserve0: deep, Reserve1: deep, FeeBps: 30},
+			{ID: 103, Token0: 2, Token1: 3, Reserve0: deep, Reserve1: deep, FeeBps: 30},
+			{ID: 104, Token0: 3, Token1: 4, Reserve0: deep, Reserve1: deep, FeeBps: 30},
+		}
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+		protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+			uniswapV3ProtocolID: uniswapv3.Schema,
+		}, poolRegistry)
+		graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{101: {}, 102: {}, 103: {}, 104: {}}, protocolResolver)
+		require.NoError(t, err)
+
+		// Simulate a calculator discrepancy: pool 103's routing function overstates its output.
+		poolIndex := graph.poolToIndex[103]
+		honest := graph.activeGetAmountOutFuncs[poolIndex]
+		graph.activeGetAmountOutFuncs[poolIndex] = func(amountIn *big.Int, tokenInID, tokenOutID uint64) (*big.Int, error) {
+			out, err := honest(amountIn, tokenInID, tokenOutID)
+			if err != nil {
+				return nil, err
+			}
+			return out.Mul(out, big.NewInt(2)), nil
+		}
+		return graph
+	}
+	amountIn := new(big.Int).Set(d18)
+
+	t.Run("Without fallback the faulty route is returned and fails validation", func(t *testing.T) {
+		graph := setup(t)
+		path, amountOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{TokenInID: 1, TokenOutID: 4, AmountIn: amountIn, Runs: 3})
+		require.NoError(t, err)
+		require.Len(t, path, 3)
+		assert.Equal(t, uint64(103), path[1].PoolID)
+
+		err = graph.ValidateRoute(chains.RouteValidationParams{Path: path, AmountIn: amountIn, ExpectedOut: amountOut})
+		assert.ErrorIs(t, err, chains.ErrRouteValidation)
+	})
+
+	t.Run("Fallback returns the next-best valid route and logs the discrepancy", func(t *testing.T) {
+		graph := setup(t)
+		logger := &recordingLogger{}
+		path, amountOut, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+			TokenInID:                   1,
+			TokenOutID:                  4,
+			AmountIn:                    amountIn,
+			Runs:                        3,
+			FallbackOnValidationFailure: true,
+			Logger:                      logger,
+		})
+		require.NoError(t, err)
+		require.Len(t, path, 1, "the direct pool is the best route without pool 103")
+		assert.Equal(t, uint64(101), path[0].PoolID)
+		assert.Len(t, logger.warnings, 1)
+
+		require.NoError(t, graph.ValidateRoute(chains.RouteValidationParams{Path: path, AmountIn: amountIn, ExpectedOut: amountOut}))
+	})
+
+	t.Run("Validation rejects broken paths", func(t *testing.T) {
+		graph := setup(t)
+		brokenPath := []chains.TokenPoolPath{
+			{TokenInID: 1, TokenOutID: 2, PoolID: 102},
+			{TokenInID: 3, TokenOutID: 4, PoolID: 104},
+		}
+		err := graph.ValidateRoute(chains.RouteValidationParams{Path: brokenPath, AmountIn: amountIn})
+		assert.ErrorIs(t, err, chains.ErrRouteValidation)
+
+		wrongPool := []chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 4, PoolID: 102}}
+		err = graph.ValidateRoute(chains.RouteValidationParams{Path: wrongPool, AmountIn: amountIn})
+		assert.ErrorIs(t, err, chains.ErrRouteValidation)
+	})
+}
+
+func TestUSDPrice(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	weth := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), d18) }
+	usd6 := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e6)) }
+
+	setup := func(t *testing.T) *Graph {
+		tokens := map[uint64]common.Address{
+			1: common.HexToAddress("0x1"), // WETH
+			2: common.HexToAddress("0x2"), // USDC
+			3: common.HexToAddress("0x3"), // USDT
+			4: common.HexToAddress("0x4"), // DAI (depegged)
+			5: common.HexToAddress("0x5"), // FRAX (no pools)
+		}
+		pools := map[uint64]common.Address{
+			101: common.HexToAddress("0x101"),
+			102: common.HexToAddress("0x102"),
+			103: common.HexToAddress("0x103"),
+		}
+		uniswapV2Pools := []uniswapv2.Pool{
+			{ID: 101, Token0: 1, Token1: 2, Reserve0: weth(1000), Reserve1: usd6(3_000_000), FeeBps: 0},
+			{ID: 102, Token0: 1, Token1: 3, Reserve0: weth(1000), Reserve1: usd6(3_010_000), FeeBps: 0},
+			{ID: 103, Token0: 1, Token1: 4, Reserve0: weth(1000), Reserve1: weth(1_500_000), FeeBps: 0},
+		}
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+		protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+			uniswapV3ProtocolID: uniswapv3.Schema,
+		}, poolRegistry)
+		tokenRegistry := tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+			{ID: 1, Symbol: "WETH", Decimals: 18},
+			{ID: 2, Symbol: "USDC", Decimals: 6},
+			{ID: 3, Symbol: "USDT", Decimals: 6},
+			{ID: 4, Symbol: "DAI", Decimals: 18},
+			{ID: 5, Symbol: "FRAX", Decimals: 18},
+		})
+		graph, err := NewGraph(rawGraph, tokenRegistry, poolRegistry, v2View, v3View, map[uint64]struct{}{101: {}, 102: {}, 103: {}}, protocolResolver)
+		require.NoError(t, err)
+		return graph
+	}
+	// A small amount keeps price impact negligible.
+	amountIn := new(big.Int).Div(d18, big.NewInt(1000))
+
+	assertPrice := func(t *testing.T, expected float64, price *big.Float) {
+		t.Helper()
+		got, _ := price.Float64()
+		assert.InDelta(t, expected, got, expected*0.001)
+	}
+
+	t.Run("Median excludes the outlier stable", func(t *testing.T) {
+		graph := setup(t)
+		price, err := graph.USDPrice(chains.USDPriceParams{
+			TokenID:        1,
+			AmountIn:       amountIn,
+			StableTokenIDs: []uint64{4, 2, 3},
+			Mode:           chains.USDPriceMedian,
+			Runs:           2,
+		})
+		require.NoError(t, err)
+		assertPrice(t, 3000, price)
+	})
+
+	t.Run("Median of an even set averages the middle quotes", func(t *testing.T) {
+		graph := setup(t)
+		price, err := graph.USDPrice(chains.USDPriceParams{
+			TokenID:        1,
+			AmountIn:       amountIn,
+			StableTokenIDs: []uint64{2, 3, 5}, // FRAX is unreachable and ignored
+			Mode:           chains.USDPriceMedian,
+			Runs:           2,
+		})
+		require.NoError(t, err)
+		assertPrice(t, 3005, price)
+	})
+
+	t.Run("Single anchor uses the first reachable stable", func(t *testing.T) {
+		graph := setup(t)
+		price, err := graph.USDPrice(chains.USDPriceParams{
+			TokenID:        1,
+			AmountIn:       amountIn,
+			StableTokenIDs: []uint64{5, 4, 2},
+			Mode:           chains.USDPriceSingleAnchor,
+			Runs:           2,
+		})
+		require.NoError(t, err)
+		assertPrice(t, 1500, price) // the depegged stable skews a single-anchor price
+	})
+
+	t.Run("Returns ErrNoRoute when no stable is reachable", func(t *testing.T) {
+		graph := setup(t)
+		_, err := graph.USDPrice(chains.USDPriceParams{
+			TokenID:        1,
+			AmountIn:       amountIn,
+			StableTokenIDs: []uint64{5},
+			Mode:           chains.USDPriceMedian,
+			Runs:           2,
+		})
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+}
+
+func TestTopPoolsBySchema(t *testing.T) {
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0x1"),
+		2: common.HexToAddress("0x2"),
+		3: common.HexToAddress("0x3"),
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"),
+		102: common.HexToAddress("0x102"),
+		103: common.HexToAddress("0x103"),
+		104: common.HexToAddress("0x104"),
+	}
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: big.NewInt(100), Reserve1: big.NewInt(400), FeeBps: 30},    // depth 200
+		{ID: 102, Token0: 1, Token1: 3, Reserve0: big.NewInt(10_000), Reserve1: big.NewInt(100), FeeBps: 30}, // depth 1000
+		{ID: 103, Token0: 2, Token1: 3, Reserve0: big.NewInt(50), Reserve1: big.NewInt(50), FeeBps: 30},      // depth 50
+	}
+	uniswapV3Pools := []uniswapv3.Pool{setupUniswapV3ETHUSDCPool(1, 2, 104)}
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, uniswapV3Pools)
+	protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}, poolRegistry)
+	graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{}, protocolResolver)
+	require.NoError(t, err)
+
+	t.Run("Orders by depth and limits to N", func(t *testing.T) {
+		top, err := graph.TopPoolsBySchema(uniswapv2.Schema, 2)
+		require.NoError(t, err)
+		require.Len(t, top, 2)
+		assert.Equal(t, uint64(102), top[0].PoolID)
+		assert.Equal(t, big.NewInt(1000), top[0].Depth)
+		assert.Equal(t, uint64(101), top[1].PoolID)
+		assert.Equal(t, big.NewInt(200), top[1].Depth)
+	})
+
+	t.Run("N larger than the pool count returns every pool", func(t *testing.T) {
+		top, err := graph.TopPoolsBySchema(uniswapv2.Schema, 10)
+		require.NoError(t, err)
+		require.Len(t, top, 3)
+		assert.Equal(t, uint64(103), top[2].PoolID)
+	})
+
+	t.Run("Only pools of the requested schema are returned", func(t *testing.T) {
+		top, err := graph.TopPoolsBySchema(uniswapv3.Schema, 10)
+		require.NoError(t, err)
+		require.Len(t, top, 1)
+		assert.Equal(t, uint64(104), top[0].PoolID)
+		assert.Positive(t, top[0].Depth.Sign())
+	})
+
+	t.Run("N must be positive", func(t *testing.T) {
+		_, err := graph.TopPoolsBySchema(uniswapv2.Schema, 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("Reserves are scaled to 18 decimals", func(t *testing.T) {
+		// In raw units the 3 DAI pool looks a thousand times deeper than the 3,000 USDC
+		// pool, because USDC has 6 decimals.
+		uniswapV2Pools := []uniswapv2.Pool{
+			{ID: 101, Token0: 1, Token1: 2, Reserve0: big.NewInt(1e18), Reserve1: big.NewInt(3000e6), FeeBps: 30},
+			{ID: 102, Token0: 1, Token1: 3, Reserve0: big.NewInt(1e15), Reserve1: big.NewInt(3e18), FeeBps: 30},
+		}
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, nil)
+		tokenRegistry := tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+			{ID: 1, Symbol: "WETH", Decimals: 18},
+			{ID: 2, Symbol: "USDC", Decimals: 6},
+			{ID: 3, Symbol: "DAI", Decimals: 18},
+		})
+		graph, err := NewGraph(rawGraph, tokenRegistry, poolRegistry, v2View, v3View, map[uint64]struct{}{}, chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+			uniswapV2ProtocolID: uniswapv2.Schema,
+		}, poolRegistry))
+		require.NoError(t, err)
+
+		top, err := graph.TopPoolsBySchema(uniswapv2.Schema, 2)
+		require.NoError(t, err)
+		depth101, _ := new(big.Int).SetString("54772255750516611345", 10) // sqrt(1e18 * 3000e18)
+		assert.Equal(t, []chains.PoolDepth{
+			{PoolID: 101, Depth: depth101},
+			{PoolID: 102, Depth: big.NewInt(54772255750516611)}, // sqrt(1e15 * 3e18)
+		}, top)
+	})
+}
+
+func TestMinRouteDepth(t *testing.T) {
+	d18 := new(big.Int).SetUint64(1e18)
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0x1"), // A
+		2: common.HexToAddress("0x2"), // B, 3,000 per A
+		3: common.HexToAddress("0x3"), // C, 3,000 per A
+		4: common.HexToAddress("0x4"), // D, 1 per A
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"),
+		102: common.HexToAddress("0x102"),
+		103: common.HexToAddress("0x103"),
+		104: common.HexToAddress("0x104"),
+	}
+	whole := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), d18) }
+	uniswapV2Pools := []uniswapv2.Pool{
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: whole(1_000), Reserve1: whole(3_000_000), FeeBps: 30},     // deep
+		{ID: 102, Token0: 2, Token1: 3, Reserve0: whole(30_000), Reserve1: whole(30_000), FeeBps: 30},       // shallow: 10 A of B
+		{ID: 103, Token0: 3, Token1: 4, Reserve0: whole(3_000_000), Reserve1: whole(1_000), FeeBps: 30},     // deep
+		{ID: 104, Token0: 2, Token1: 3, Reserve0: whole(1_500_000), Reserve1: whole(1_500_000), FeeBps: 30}, // deep alternative to 102
+	}
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, []uniswapv3.Pool{})
+	protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}, poolRegistry)
+	graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{101: {}, 102: {}, 103: {}, 104: {}}, protocolResolver)
+	require.NoError(t, err)
+
+	route := func(middlePool uint64) []chains.TokenPoolPath {
+		return []chains.TokenPoolPath{
+			{TokenInID: 1, TokenOutID: 2, PoolID: 101},
+			{TokenInID: 2, TokenOutID: 3, PoolID: middlePool},
+			{TokenInID: 3, TokenOutID: 4, PoolID: 103},
+		}
+	}
+	assertDepth := func(t *testing.T, expectedWholeA float64, depth *big.Int) {
+		f, _ := new(big.Float).SetInt(depth).Float64()
+		assert.InEpsilon(t, expectedWholeA, f/1e18, 1e-9)
+	}
+
+	t.Run("Identifies the shallow hop", func(t *testing.T) {
+		depth, err := graph.MinRouteDepth(route(102))
+		require.NoError(t, err)
+		assert.Equal(t, 1, depth.HopIndex)
+		assert.Equal(t, uint64(102), depth.PoolID)
+		assertDepth(t, 10, depth.Depth)
+
+		// The other hops are 100 times deeper, in units of A.
+		require.Len(t, depth.HopDepths, 3)
+		assertDepth(t, 1_000, depth.HopDepths[0])
+		assertDepth(t, 10, depth.HopDepths[1])
+		assertDepth(t, 1_000, depth.HopDepths[2])
+	})
+
+	t.Run("A deeper middle pool lifts the minimum", func(t *testing.T) {
+		depth, err := graph.MinRouteDepth(route(104))
+		require.NoError(t, err)
+		assert.Equal(t, 1, depth.HopIndex)
+		assert.Equal(t, uint64(104), depth.PoolID)
+		assertDepth(t, 500, depth.Depth)
+	})
+
+	t.Run("Invalid routes", func(t *testing.T) {
+		_, err := graph.MinRouteDepth(nil)
+		assert.Error(t, err)
+
+		broken := route(102)
+		broken[1].TokenInID = 4
+		_, err = graph.MinRouteDepth(broken)
+		assert.Error(t, err)
+
+		_, err = graph.MinRouteDepth([]chains.TokenPoolPath{{TokenInID: 1, TokenOutID: 3, PoolID: 101}})
+		assert.Error(t, err, "pool does not hold the hop's tokens")
+	})
+}
+
+func TestConsistencyCheck(t *testing.T) {
+	decimals := func(d uint8) *uint8 { return &d }
+
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0x1"), // WETH
+		2: common.HexToAddress("0x2"), // USDC
+		3: common.HexToAddress("0x3"), // DAI
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"),
+		102: common.HexToAddress("0x102"),
+		103: common.HexToAddress("0x103"),
+	}
+	uniswapV2Pools := []uniswapv2.Pool{
+		// Consistent with the registry.
+		{ID: 101, Token0: 1, Token1: 2, Reserve0: big.NewInt(1), Reserve1: big.NewInt(1), FeeBps: 30, Decimals0: decimals(18), Decimals1: decimals(6)},
+		// No assumed decimals: not checked.
+		{ID: 103, Token0: 2, Token1: 3, Reserve0: big.NewInt(1), Reserve1: big.NewInt(1), FeeBps: 30},
+	}
+	mismatched := setupUniswapV3ETHUSDCPool(1, 2, 102)
+	// USDC was deliberately indexed as an 18-decimal token.
+	mismatched.Decimals0, mismatched.Decimals1 = decimals(18), decimals(18)
+	uniswapV3Pools := []uniswapv3.Pool{mismatched}
+
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, uniswapV2Pools, uniswapV3Pools)
+	protocolResolver := chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}, poolRegistry)
+	tokenRegistry := tokenregistryindexer.NewIndexableTokenSystem([]tokenregistry.Token{
+		{ID: 1, Symbol: "WETH", Decimals: 18},
+		{ID: 2, Symbol: "USDC", Decimals: 6},
+		{ID: 3, Symbol: "DAI", Decimals: 18},
+	})
+
+	t.Run("Flags the mismatched pool and token", func(t *testing.T) {
+		graph, err := NewGraph(rawGraph, tokenRegistry, poolRegistry, v2View, v3View, map[uint64]struct{}{}, protocolResolver)
+		require.NoError(t, err)
+		assert.Equal(t, []chains.DecimalsMismatch{
+			{PoolID: 102, TokenID: 2, PoolDecimals: 18, RegistryDecimals: 6},
+		}, graph.ConsistencyCheck())
+	})
+
+	t.Run("Nothing to check without token metadata", func(t *testing.T) {
+		graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{}, protocolResolver)
+		require.NoError(t, err)
+		assert.Empty(t, graph.ConsistencyCheck())
+	})
+}
+
+func TestFindBestSwapPathTimeout(t *testing.T) {
+	graph := setupUniswapV2BenchmarkGraph(t, 2000, 6000)
+	params := chains.SwapFindingParams{
+		TokenInID:  0,
+		TokenOutID: 1999,
+		AmountIn:   new(big.Int).SetUint64(1e18),
+		Runs:       50,
+	}
+
+	t.Run("Tiny timeout returns promptly", func(t *testing.T) {
+		params := params
+		params.Timeout = time.Millisecond
+
+		start := time.Now()
+		path, amountOut, err := graph.FindBestSwapPath(params)
+		elapsed := time.Since(start)
+
+		require.ErrorIs(t, err, chains.ErrRouteTimeout)
+		assert.Less(t, elapsed, 500*time.Millisecond, "the search should stop soon after the deadline")
+		if path != nil {
+			assert.Positive(t, amountOut.Sign(), "a partial route carries its output")
+		}
+	})
+
+	t.Run("Generous timeout completes the search", func(t *testing.T) {
+		params := params
+		params.Runs = 3
+		params.Timeout = time.Minute
+
+		path, _, err := graph.FindBestSwapPath(params)
+		require.NoError(t, err)
+		assert.NotEmpty(t, path)
+	})
+}
+
+func TestApplyDiff(t *testing.T) {
+	tokens := map[uint64]common.Address{
+		1: common.HexToAddress("0x1"),
+		2: common.HexToAddress("0x2"),
+		3: common.HexToAddress("0x3"),
+	}
+	pools := map[uint64]common.Address{
+		101: common.HexToAddress("0x101"),
+		102: common.HexToAddress("0x102"),
+	}
+	pool101 := uniswapv2.Pool{ID: 101, Token0: 1, Token1: 2, Reserve0: big.NewInt(1_000_000), Reserve1: big.NewInt(2_000_000), FeeBps: 30}
+	pool102 := uniswapv2.Pool{ID: 102, Token0: 2, Token1: 3, Reserve0: big.NewInt(1_000_000), Reserve1: big.NewInt(3_000_000), FeeBps: 30}
+	schemas := map[engine.ProtocolID]engine.ProtocolSchema{
+		uniswapV2ProtocolID: uniswapv2.Schema,
+		uniswapV3ProtocolID: uniswapv3.Schema,
+	}
+
+	// The old snapshot only knows pool 101, so token 3 is not in the graph.
+	rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, []uniswapv2.Pool{pool101}, []uniswapv3.Pool{})
+	graph, err := NewGraph(rawGraph, nil, poolRegistry, v2View, v3View, map[uint64]struct{}{101: {}}, chains.NewProtocolResolver(schemas, poolRegistry))
+	require.NoError(t, err)
+
+	params := chains.SwapFindingParams{AmountIn: big.NewInt(10_000), TokenInID: 1, TokenOutID: 3, Runs: 3}
+	_, _, err = graph.FindBestSwapPath(params)
+	require.Error(t, err)
+
+	// The patched snapshot adds pool 102, which connects token 2 to token 3.
+	newRawGraph, newPoolRegistry, newV2View, newV3View := NewMockGraphRequirements(t, tokens, pools, []uniswapv2.Pool{pool101, pool102}, []uniswapv3.Pool{})
+	next, err := graph.ApplyDiff(chains.GraphDiff{
+		TokenPool:           newRawGraph,
+		IndexedPoolRegistry: newPoolRegistry,
+		IndexedUniswapV2:    newV2View,
+		IndexedUniswapV3:    newV3View,
+		ProtocolResolver:    chains.NewProtocolResolver(schemas, newPoolRegistry),
+		ChangedPools:        []uint64{102},
+	})
+	require.NoError(t, err)
+
+	path, amountOut, err := next.FindBestSwapPath(params)
+	require.NoError(t, err)
+	require.Len(t, path, 2)
+	assert.Equal(t, uint64(101), path[0].PoolID)
+	assert.Equal(t, uint64(102), path[1].PoolID)
+	assert.Positive(t, amountOut.Sign())
+
+	report := next.BuildReport()
+	assert.Equal(t, 2, report.TotalPools)
+	assert.Equal(t, 2, report.RoutablePools)
+
+	// The new pool is wired for the active (cached) search as well.
+	rates, err := next.GetExchangeRates(big.NewInt(10_000), 1, 3, nil)
+	require.NoError(t, err)
+	assert.Contains(t, rates, uint64(3))
+
+	// The receiver still serves the old snapshot.
+	_, _, err = graph.FindBestSwapPath(params)
+	assert.Error(t, err)
+
+	t.Run("same tokens and pools rewire only the changed pools", func(t *testing.T) {
+		fullRawGraph, fullPoolRegistry, fullV2View, fullV3View := NewMockGraphRequirements(t, tokens, pools, []uniswapv2.Pool{pool101, pool102}, []uniswapv3.Pool{})
+		full, err := NewGraph(fullRawGraph, nil, fullPoolRegistry, fullV2View, fullV3View, nil, chains.NewProtocolResolver(schemas, fullPoolRegistry))
+		require.NoError(t, err)
+
+		// Both pools move in the patched snapshot, but only pool 102 is listed as changed.
+		moved101, moved102 := pool101, pool102
+		moved101.Reserve1 = big.NewInt(4_000_000)
+		moved102.Reserve1 = big.NewInt(6_000_000)
+		patchedRawGraph, patchedPoolRegistry, patchedV2View, patchedV3View := NewMockGraphRequirements(t, tokens, pools, []uniswapv2.Pool{moved101, moved102}, []uniswapv3.Pool{})
+		next, err := full.ApplyDiff(chains.GraphDiff{
+			TokenPool:           patchedRawGraph,
+			IndexedPoolRegistry: patchedPoolRegistry,
+			IndexedUniswapV2:    patchedV2View,
+			IndexedUniswapV3:    patchedV3View,
+			ProtocolResolver:    chains.NewProtocolResolver(schemas, patchedPoolRegistry),
+			ChangedPools:        []uint64{102},
+		})
+		require.NoError(t, err)
+
+		quote := func(graph chains.TokenPoolGraph, hop chains.TokenPoolPath) *big.Int {
+			t.Helper()
+			q, err := graph.QuoteRoute([]chains.TokenPoolPath{hop}, big.NewInt(10_000))
+			require.NoError(t, err)
+			return q.AmountOut
+		}
+		hop101 := chains.TokenPoolPath{TokenInID: 1, TokenOutID: 2, PoolID: 101}
+		hop102 := chains.TokenPoolPath{TokenInID: 2, TokenOutID: 3, PoolID: 102}
+		assert.Equal(t, quote(full, hop101), quote(next, hop101), "an unlisted pool keeps its functions")
+		assert.Equal(t, 1, quote(next, hop102).Cmp(quote(full, hop102)), "a listed pool is quoted on its new reserves")
+		assert.Equal(t, 2, next.BuildReport().RoutablePools)
+
+		top, err := next.TopPoolsBySchema(uniswapv2.Schema, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []chains.PoolDepth{
+			{PoolID: 102, Depth: big.NewInt(2_449_489)}, // sqrt(1e6 * 6e6), measured again
+			{PoolID: 101, Depth: big.NewInt(1_414_213)}, // sqrt(1e6 * 2e6), carried over
+		}, top)
+	})
+
+	t.Run("changed tokens rewire their pools", func(t *testing.T) {
+		rawGraph, poolRegistry, v2View, v3View := NewMockGraphRequirements(t, tokens, pools, []uniswapv2.Pool{pool101, pool102}, []uniswapv3.Pool{})
+		resolver := chains.NewProtocolResolver(schemas, poolRegistry)
+		grapher, err := NewGrapher()
+		require.NoError(t, err)
+		// Token 3 is missing from the registry, so pool 102 is skipped.
+		known := []tokenregistry.Token{{ID: 1, Symbol: "A", Decimals: 18}, {ID: 2, Symbol: "B", Decimals: 18}}
+		graph, err := grapher.Graph(rawGraph, tokenregistryindexer.NewIndexableTokenSystem(known), poolRegistry, v2View, v3View, nil, resolver)
+		require.NoError(t, err)
+		require.Equal(t, []chains.SkippedPool{{PoolID: 102, Reason: chains.SkipReasonUnknownToken}}, graph.BuildReport().Skipped)
+
+		diff := chains.GraphDiff{
+			TokenPool:            rawGraph,
+			IndexedTokenRegistry: tokenregistryindexer.NewIndexableTokenSystem(append(known, tokenregistry.Token{ID: 3, Symbol: "C", Decimals: 18})),
+			IndexedPoolRegistry:  poolRegistry,
+			IndexedUniswapV2:     v2View,
+			IndexedUniswapV3:     v3View,
+			ProtocolResolver:     resolver,
+		}
+		next, err := graph.ApplyDiff(diff)
+		require.NoError(t, err)
+		assert.Equal(t, 1, next.BuildReport().RoutablePools, "an unlisted token leaves its pools alone")
+
+		diff.ChangedTokens = []uint64{3}
+		next, err = graph.ApplyDiff(diff)
+		require.NoError(t, err)
+		report := next.BuildReport()
+		assert.Equal(t, 2, report.RoutablePools)
+		assert.Empty(t, report.Skipped)
+		assert.Empty(t, report.UnknownTokenPools)
+
+		// Deleting the token again skips its pool.
+		diff.IndexedTokenRegistry = tokenregistryindexer.NewIndexableTokenSystem(known)
+		again, err := next.ApplyDiff(diff)
+		require.NoError(t, err)
+		assert.Equal(t, []chains.SkippedPool{{PoolID: 102, Reason: chains.SkipReasonUnknownToken}}, again.BuildReport().Skipped)
+		assert.Equal(t, []uint64{102}, again.BuildReport().UnknownTokenPools)
+	})
+
+	t.Run("missing views", func(t *testing.T) {
+		_, err := graph.ApplyDiff(chains.GraphDiff{})
+		assert.Error(t, err)
+	})
+}
+
+func BenchmarkFindBestSwapPath(b *testing.B) {
+	benchmarkCases := []struct {
+		name      string
+		numTokens int
+		numPools  int
+		runs      int // Path length to search
+	}{
+		{"100T_300P_3Hops", 100, 300, 3},
+		{"500T_1500P_3Hops", 500, 1500, 3},
+		{"1000T_3000P_4Hops", 1000, 3000, 4},
+		{"2000T_6000P_4Hops", 2000, 6000, 4},
+		{"5000T_10000P_5Hops", 5000, 10000, 5},
+	}
+
+	for _, bc := range benchmarkCases {
+		b.Run(bc.name, func(b *testing.B) {
+			graph := setupUniswapV2BenchmarkGraph(b, bc.numTokens, bc.numPools)
+			params := chains.SwapFindingParams{
+				TokenInID:  0,                        // Start from a hub token
+				TokenOutID: uint64(bc.numTokens - 1), // End at a spoke token
+				AmountIn:   new(big.Int).SetUint64(1e18),
+				Runs:       bc.runs,
+			}
+
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				_, _, _ = graph.FindBestSwapPath(params)
+			}
+		})
+	}
+}
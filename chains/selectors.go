@@ -0,0 +1,96 @@
+package chains
+
+import (
+	"math/big"
+
+	"github.com/defistate/defistate-client-go/engine"
+)
+
+// PoolCandidate describes a single pool that can serve one hop (TokenInID -> TokenOutID)
+// of a route. Candidates are handed to a PoolSelector when an edge of the token graph
+// is served by more than one pool.
+type PoolCandidate struct {
+	PoolID uint64
+	Schema engine.ProtocolSchema
+
+	// AmountOut is the quoted output for the hop's input amount. It is always positive,
+	// unless zero-output routes are allowed (see SwapFindingParams.AllowZeroOutput).
+	AmountOut *big.Int
+
+	// FeePips is the pool's swap fee in hundredths of a basis point (1e-6),
+	// i.e. 3000 for 0.3%. Uniswap V2 fees are normalized to the same unit.
+	FeePips uint64
+
+	// Reserves returns the pool's reserves in the hop's direction. For concentrated
+	// liquidity pools this is expensive, so selectors should only call it when needed.
+	Reserves func() (reserveIn, reserveOut *big.Int, err error)
+}
+
+// PoolSelector decides which pool the router uses for an edge served by multiple pools.
+// Implementations must be safe for concurrent use and must not retain the candidates slice.
+type PoolSelector interface {
+	// SelectPool returns the index into candidates of the chosen pool,
+	// or -1 to skip the edge entirely.
+	SelectPool(tokenInID, tokenOutID uint64, candidates []PoolCandidate) int
+}
+
+// PoolSelectorFunc adapts an ordinary function to the PoolSelector interface.
+type PoolSelectorFunc func(tokenInID, tokenOutID uint64, candidates []PoolCandidate) int
+
+// SelectPool calls f(tokenInID, tokenOutID, candidates).
+func (f PoolSelectorFunc) SelectPool(tokenInID, tokenOutID uint64, candidates []PoolCandidate) int {
+	return f(tokenInID, tokenOutID, candidates)
+}
+
+// MaxAmountOutSelector picks the pool that returns the most output for the hop.
+// This is the default used by the router when no selector is configured.
+type MaxAmountOutSelector struct{}
+
+func (MaxAmountOutSelector) SelectPool(_, _ uint64, candidates []PoolCandidate) int {
+	best := -1
+	for i, c := range candidates {
+		if best == -1 || c.AmountOut.Cmp(candidates[best].AmountOut) == 1 {
+			best = i
+		}
+	}
+	return best
+}
+
+// DeepestPoolSelector picks the pool with the largest reserve of the output token.
+// Candidates whose reserves cannot be resolved are ignored.
+type DeepestPoolSelector struct{}
+
+func (DeepestPoolSelector) SelectPool(_, _ uint64, candidates []PoolCandidate) int {
+	best := -1
+	var bestReserve *big.Int
+	for i, c := range candidates {
+		if c.Reserves == nil {
+			continue
+		}
+		_, reserveOut, err := c.Reserves()
+		if err != nil || reserveOut == nil {
+			continue
+		}
+		if best == -1 || reserveOut.Cmp(bestReserve) == 1 {
+			best = i
+			bestReserve = reserveOut
+		}
+	}
+	return best
+}
+
+// LowestFeeSelector picks the pool with the lowest swap fee.
+// Ties are broken by the highest amount out.
+type LowestFeeSelector struct{}
+
+func (LowestFeeSelector) SelectPool(_, _ uint64, candidates []PoolCandidate) int {
+	best := -1
+	for i, c := range candidates {
+		if best == -1 ||
+			c.FeePips < candidates[best].FeePips ||
+			(c.FeePips == candidates[best].FeePips && c.AmountOut.Cmp(candidates[best].AmountOut) == 1) {
+			best = i
+		}
+	}
+	return best
+}
@@ -1,11 +1,16 @@
 package chains
 
 import (
+	"errors"
+	"math"
 	"math/big"
+	"time"
 
 	"github.com/defistate/defistate-client-go/engine"
 	poolregistry "github.com/defistate/defistate-client-go/protocols/poolregistry"
 	poolregistryindexer "github.com/defistate/defistate-client-go/protocols/poolregistry/indexer"
+	solidly "github.com/defistate/defistate-client-go/protocols/solidly"
+	solidlyindexer "github.com/defistate/defistate-client-go/protocols/solidly/indexer"
 
 	tokenpoolregistry "github.com/defistate/defistate-client-go/protocols/tokenpoolregistry"
 	tokenregistry "github.com/defistate/defistate-client-go/protocols/tokenregistry"
@@ -16,6 +21,28 @@ import (
 	uniswapv3indexer "github.com/defistate/defistate-client-go/protocols/uniswapv3/indexer"
 )
 
+// ErrNoRoute is returned by route finding when no usable route exists between two tokens.
+var ErrNoRoute = errors.New("no route found")
+
+// ErrRouteTimeout is returned when route finding exceeds its timeout. The best route found
+// before the deadline, if any, is returned alongside it.
+var ErrRouteTimeout = errors.New("route finding timed out")
+
+// ErrRouteValidation is returned when a route does not hold up when re-quoted against pool state.
+var ErrRouteValidation = errors.New("route failed validation")
+
+// ErrCycleDepthLimit is returned when cycle enumeration stops at its depth limit. The
+// cycles found within the limit are returned alongside it.
+var ErrCycleDepthLimit = errors.New("cycle enumeration reached its depth limit")
+
+// DefaultMaxCycleDepth is the enumeration depth FindAllArbitrageCycles stops at when
+// CycleFindingParams.MaxDepth is zero.
+const DefaultMaxCycleDepth = 8
+
+// ErrDuplicatePoolID is returned when building a graph that rejects pool ids claimed by
+// more than one protocol.
+var ErrDuplicatePoolID = errors.New("pool id claimed by more than one protocol")
+
 // Logger defines a standard interface for structured, leveled logging.
 type Logger interface {
 	Debug(msg string, args ...any)
@@ -30,6 +57,14 @@ type Client interface {
 	Err() <-chan error
 }
 
+// StateStore persists the states a client applies, e.g. for replay or audit.
+// Put is called from the client's processing loop, so slow stores delay every state.
+type StateStore interface {
+	Put(block uint64, state *engine.State) error
+	// Get returns the state stored for block, or false if there is none.
+	Get(block uint64) (*engine.State, bool)
+}
+
 // TokenIndexer defines the interface for any component that can index tokens.
 type TokenIndexer interface {
 	Index(tokens []tokenregistry.Token) tokenregistryindexer.IndexedTokenSystem
@@ -50,12 +85,27 @@ type UniswapV3Indexer interface {
 	Index(pools []uniswapv3.Pool) uniswapv3indexer.IndexedUniswapV3
 }
 
+// SolidlyIndexer defines the interface for any component that can index Solidly-style pools.
+type SolidlyIndexer interface {
+	Index(pools []solidly.Pool) solidlyindexer.IndexedSolidly
+}
+
 type TokenPoolPath struct {
 	TokenInID  uint64
 	TokenOutID uint64
 	PoolID     uint64
 }
 
+// EquivalencePoolID is the PoolID of a route hop that converts between two equivalent
+// tokens at a configured ratio (see SwapFindingParams.Equivalences) instead of through a pool.
+const EquivalencePoolID uint64 = math.MaxUint64
+
+// TokenPair is an ordered pair of tokens.
+type TokenPair struct {
+	From uint64
+	To   uint64
+}
+
 // CycleFindingParams encapsulates all inputs for an arbitrage search.
 type CycleFindingParams struct {
 	AmountIn *big.Int
@@ -67,6 +117,49 @@ type CycleFindingParams struct {
 	UniswapV2Overrides map[uint64]uniswapv2.Pool
 	UniswapV3Overrides map[uint64]uniswapv3.Pool
 	Runs               int // Number of runs to perform in the search.
+
+	// MinProfit, if set, drops cycles whose profit (amount out minus AmountIn) is below it.
+	MinProfit *ProfitThreshold
+
+	// ActivePools, if set, restricts the search to these pools. Pools outside the graph's
+	// own active set stay excluded either way.
+	ActivePools map[uint64]struct{}
+	// MaxPools, if positive, restricts the search to the MaxPools deepest pools (see
+	// TopPoolsBySchema for the depth measure), picked after ActivePools is applied.
+	// Zero means no limit.
+	MaxPools int
+	// MaxDepth caps the recursion depth of FindAllArbitrageCycles, and so the length of the
+	// cycles it enumerates, whatever Runs allows. Zero means DefaultMaxCycleDepth. If Runs
+	// is larger and a path reaches the cap, the enumeration is cut short and the cycles
+	// found are returned with ErrCycleDepthLimit.
+	MaxDepth int
+
+	// GasModel, if set, ranks cycles by their profit net of gas, priced in TokenID through
+	// NativeTokenID, and MinProfit is compared with that net profit. The returned amounts
+	// are still the gross amounts out.
+	GasModel GasModel
+	// NativeTokenID is the (wrapped) native token gas is paid in. Required with GasModel.
+	NativeTokenID uint64
+}
+
+// ProfitUnit is the unit a ProfitThreshold is expressed in.
+type ProfitUnit int
+
+const (
+	// ProfitUnitRaw compares profit in raw units of the cycle's token.
+	ProfitUnitRaw ProfitUnit = iota
+	// ProfitUnitBps compares profit in basis points of the input amount.
+	ProfitUnitBps
+	// ProfitUnitUSD compares profit in USD, priced with USDPrice in median mode.
+	ProfitUnitUSD
+)
+
+// ProfitThreshold is a minimum profit expressed in a unit that is comparable across tokens.
+type ProfitThreshold struct {
+	Unit   ProfitUnit
+	Amount *big.Float
+	// StableTokenIDs are the anchor stables used when Unit is ProfitUnitUSD.
+	StableTokenIDs []uint64
 }
 
 // CycleFindingParamsFromStartPool encapsulates all inputs for an arbitrage search from a specific poolregistry
@@ -94,6 +187,402 @@ type SwapFindingParams struct {
 	// Overrides allow for "what-if" analysis.
 	UniswapV2Overrides map[uint64]uniswapv2.Pool
 	UniswapV3Overrides map[uint64]uniswapv3.Pool
+
+	// PoolSelector chooses between pools sharing an edge. If nil, MaxAmountOutSelector is used.
+	PoolSelector PoolSelector
+
+	// IncludeInactivePools routes through every routable pool instead of only the graph's
+	// active set. The active set holds the hot pools, so the default search is faster but
+	// can miss a better route through a pool outside it; including every pool finds the
+	// best route the graph knows of at the cost of relaxing many more edges.
+	IncludeInactivePools bool
+
+	// MaxPoolStaleBlocks, if non-zero, skips pools whose last-update block is more than
+	// MaxPoolStaleBlocks behind SnapshotBlock, so routes only use recently observed pools.
+	// Pools whose stream does not carry a last-update block are not skipped.
+	MaxPoolStaleBlocks uint64
+	// SnapshotBlock is the block the graph was built for, e.g. State.Block.Number.
+	// It is required when MaxPoolStaleBlocks is set.
+	SnapshotBlock uint64
+
+	// AllowZeroOutput controls routes whose output rounds down to zero (e.g. a tiny input
+	// across tokens with a large decimal gap). By default (false) they are treated as
+	// no route and ErrNoRoute is returned; when true they are returned with a zero amount.
+	AllowZeroOutput bool
+
+	// FallbackOnValidationFailure validates the chosen route with ValidateRoute. If it fails
+	// (e.g. a calculator discrepancy), the failing pool is excluded and the next-best route
+	// is tried instead of returning an error. By default (false) routes are not validated.
+	FallbackOnValidationFailure bool
+	// Logger receives validation discrepancies when FallbackOnValidationFailure is set. Optional.
+	Logger Logger
+
+	// Timeout bounds the whole search, including validation fallbacks. When it expires the
+	// search stops and returns the best route found so far together with ErrRouteTimeout.
+	// Zero means no timeout.
+	Timeout time.Duration
+
+	// FloatSearch runs the search on float64 quotes, which is faster, and then re-quotes
+	// the chosen route with exact big.Int math; the returned amount is always exact.
+	// The float phase only picks the route, so its precision caveats affect route choice:
+	// float64 keeps about 15 significant digits, so routes whose outputs differ by less
+	// than that may be ranked wrongly, and Uniswap V3 pools are quoted from their virtual
+	// reserves, as if the swap never crossed a tick, which overstates large V3 swaps.
+	// If the exact re-quote fails, the exact search is run instead. Not supported with
+	// PoolSelector.
+	FloatSearch bool
+
+	// Equivalences lets routes convert between interchangeable tokens (e.g. stETH/wstETH or
+	// USDC/USDC.e) at a fixed ratio instead of through a pool. Each entry maps a pair to the
+	// raw amount of To received per raw unit of From; the reverse direction uses the inverse
+	// ratio unless it is configured too. A conversion is a zero-slippage hop whose PoolID is
+	// EquivalencePoolID. Not supported with FloatSearch or FallbackOnValidationFailure.
+	Equivalences map[TokenPair]*big.Rat
+
+	// EdgeWeight biases routing towards or away from pools, e.g. to prefer pools the caller
+	// already has allowances on. A route is ranked by its amount out multiplied by the weights
+	// of the pools it goes through; the returned amount is never weighted. Weights default to
+	// 1, and a pool whose weight is not positive and finite is excluded. Equivalence hops are
+	// not weighted. Not supported with FloatSearch.
+	EdgeWeight func(poolID uint64) float64
+
+	// GasModel, if set, ranks routes by their output net of gas: the gas of each route is
+	// priced in the output token through NativeTokenID and subtracted before routes are
+	// compared, so a shorter route can beat one with a slightly higher gross output. The
+	// returned amount is still the gross output. Equivalence hops cost no gas. Not supported
+	// with FloatSearch or EdgeWeight.
+	GasModel GasModel
+	// NativeTokenID is the (wrapped) native token gas is paid in. Required with GasModel.
+	NativeTokenID uint64
+}
+
+// USDPriceMode selects how USDPrice combines quotes from several anchor stables.
+type USDPriceMode int
+
+const (
+	// USDPriceSingleAnchor prices via the first stable in StableTokenIDs that can be reached.
+	USDPriceSingleAnchor USDPriceMode = iota
+	// USDPriceMedian prices via every reachable stable and returns the median, so a single
+	// depegged or illiquid stable cannot skew the result.
+	USDPriceMedian
+)
+
+// USDPriceParams encapsulates all inputs for pricing a token in USD.
+type USDPriceParams struct {
+	TokenID uint64
+	// AmountIn is the raw amount of TokenID routed to each stable. Larger amounts include
+	// more price impact; smaller amounts may round down on low-decimal stables.
+	AmountIn *big.Int
+	// StableTokenIDs are the anchor stables, each assumed to be worth exactly one USD.
+	StableTokenIDs []uint64
+	Mode           USDPriceMode
+	Runs           int // Number of runs to perform in the search.
+}
+
+// SpotPriceParams controls how AllSpotPrices spreads its work.
+type SpotPriceParams struct {
+	// Concurrency is the number of workers pricing pools in parallel. Zero or one prices
+	// every pool on the calling goroutine.
+	Concurrency int
+	// BatchSize is the number of consecutive pools a worker prices per task. Zero uses a
+	// default; larger batches mean less coordination but coarser load balancing.
+	BatchSize int
+}
+
+// RouteValidationParams encapsulates all inputs for validating a route.
+type RouteValidationParams struct {
+	Path     []TokenPoolPath
+	AmountIn *big.Int
+	// ExpectedOut is the output the route was quoted at. If nil, only the route itself is checked.
+	ExpectedOut *big.Int
+
+	// Overrides allow for "what-if" analysis.
+	UniswapV2Overrides map[uint64]uniswapv2.Pool
+	UniswapV3Overrides map[uint64]uniswapv3.Pool
+}
+
+// GasEstimates maps a protocol schema to the estimated gas consumed by a single
+// swap hop through a pool of that schema.
+type GasEstimates map[engine.ProtocolSchema]uint64
+
+// GasModel estimates what routes cost to execute. Route finding uses it to rank routes
+// and cycles by their output net of gas. Implementations must be safe for concurrent use.
+type GasModel interface {
+	// HopGas returns the estimated gas of a swap through poolID, a pool of the given
+	// schema, or false if the hop cannot be estimated; such pools are not used.
+	HopGas(schema engine.ProtocolSchema, poolID uint64) (uint64, bool)
+	// GasPrice returns the price per unit of gas in wei.
+	GasPrice() *big.Int
+}
+
+// CheapestRouteParams encapsulates all inputs for finding the cheapest acceptable route.
+type CheapestRouteParams struct {
+	AmountIn   *big.Int
+	TokenInID  uint64
+	TokenOutID uint64
+	// MinAcceptableOut is the smallest output a route must produce to be considered.
+	MinAcceptableOut *big.Int
+	// GasEstimates provides the per-hop gas cost for each schema. Pools whose schema
+	// has no estimate are not used.
+	GasEstimates GasEstimates
+	Runs         int // Maximum number of hops in a route.
+
+	// Overrides allow for "what-if" analysis.
+	UniswapV2Overrides map[uint64]uniswapv2.Pool
+	UniswapV3Overrides map[uint64]uniswapv3.Pool
+}
+
+// CheapestRoute is a route selected by gas cost rather than by output.
+type CheapestRoute struct {
+	Path      []TokenPoolPath
+	AmountOut *big.Int
+	GasUsed   uint64
+}
+
+// TwoWayQuote is the best route in each direction of a token pair. The reverse leg
+// sells the forward leg's output, so AmountIn - ReverseOut is the round-trip spread.
+type TwoWayQuote struct {
+	AmountIn   *big.Int
+	Forward    []TokenPoolPath
+	ForwardOut *big.Int
+	Reverse    []TokenPoolPath
+	ReverseOut *big.Int
+}
+
+// Spread returns the round-trip loss in raw units of the first token (AmountIn - ReverseOut).
+// It is negative only if the pair can be cycled at a profit.
+func (q *TwoWayQuote) Spread() *big.Int {
+	return new(big.Int).Sub(q.AmountIn, q.ReverseOut)
+}
+
+// PortfolioValue is the value of a set of token balances in a base token. Values are in
+// whole tokens with token metadata and in raw units without it.
+type PortfolioValue struct {
+	Total  *big.Float            // Sum of Values.
+	Values map[uint64]*big.Float // Value of each priced token's balance, by token ID.
+	// Unpriced lists, in ascending order, the tokens with a balance that have no route to
+	// the base token. They are left out of Total.
+	Unpriced []uint64
+}
+
+// HopQuote is one hop of a quoted route. Prices are of one hop input token in the hop
+// output token, fees excluded, in whole tokens with token metadata and in raw units
+// without it.
+type HopQuote struct {
+	TokenPoolPath
+	AmountIn  *big.Int
+	AmountOut *big.Int
+	// SpotPriceBefore and SpotPriceAfter are the pool's price before and after the hop.
+	SpotPriceBefore *big.Float
+	SpotPriceAfter  *big.Float
+	// PriceImpact is 1 - (AmountOut/AmountIn)/SpotPriceBefore, so fees count too.
+	PriceImpact *big.Float
+	// CumulativePriceImpact is the price impact of the route up to and including this
+	// hop, measured against the product of the hops' prices before the trade.
+	CumulativePriceImpact *big.Float
+}
+
+// RouteQuote is a route quoted hop by hop.
+type RouteQuote struct {
+	AmountIn    *big.Int
+	AmountOut   *big.Int
+	Hops        []HopQuote
+	PriceImpact *big.Float // The CumulativePriceImpact of the last hop.
+}
+
+// SplitLeg is one part of a trade split across several routes: AmountIn of the input
+// token is sent along Path for AmountOut of the output token.
+type SplitLeg struct {
+	Path      []TokenPoolPath
+	AmountIn  *big.Int
+	AmountOut *big.Int
+}
+
+// SplitRouteParams encapsulates all inputs for splitting a trade across parallel routes.
+type SplitRouteParams struct {
+	AmountIn   *big.Int
+	TokenInID  uint64
+	TokenOutID uint64
+	Runs       int // Maximum number of hops in a route.
+
+	// MaxRoutes bounds how many routes the trade may be split across. Zero means 4.
+	MaxRoutes int
+	// Parts is the number of equal chunks AmountIn is allocated in; a finer split finds a
+	// better allocation but quotes every route more often. Zero means 20.
+	Parts int
+}
+
+// SplitRoute is a trade split across routes that share no pool, so the legs can execute
+// side by side without moving each other's prices.
+type SplitRoute struct {
+	Legs      []SplitLeg // Only the routes that received part of the input.
+	AmountOut *big.Int   // Summed over the legs.
+	// SinglePathOut is the output of sending all of AmountIn along the best single route.
+	SinglePathOut *big.Int
+}
+
+// SplitSlippage is the blended execution of a split trade measured against the pre-trade
+// spot price. Prices are of one input token in the output token, in whole tokens with
+// token metadata and in raw units without it.
+type SplitSlippage struct {
+	AmountIn  *big.Int // Summed over the legs.
+	AmountOut *big.Int // Summed over the legs.
+	// EffectivePrice is AmountOut / AmountIn, the price the split trade executes at.
+	EffectivePrice *big.Float
+	// SpotPrice is the best spot price among the legs' routes before the trade, the
+	// product of each hop's pool price. Fees are not included.
+	SpotPrice *big.Float
+	// Slippage is 1 - EffectivePrice/SpotPrice, so fees and price impact both count.
+	Slippage *big.Float
+}
+
+// PoolMispricing is the pool whose spot price of a token pair deviates most from an
+// external reference price.
+type PoolMispricing struct {
+	PoolID uint64
+	// SpotPrice is the pool's price of one token A in token B.
+	SpotPrice *big.Float
+	// Deviation is (SpotPrice - externalPrice) / externalPrice: negative when token A is
+	// cheap in the pool, positive when it is expensive.
+	Deviation *big.Float
+	// Swap is the profitable trade in the pool: buy token A with token B when it is cheap,
+	// sell it for token B when it is expensive. Fees and price impact are not included.
+	Swap TokenPoolPath
+}
+
+// CycleProfitParams encapsulates all inputs for evaluating an arbitrage cycle after gas.
+type CycleProfitParams struct {
+	Cycle    []TokenPoolPath
+	AmountIn *big.Int // The (optimal) amount of the cycle's start token to trade.
+
+	// GasPrice is the price per unit of gas in the native token's smallest unit (wei).
+	GasPrice *big.Int
+	// GasEstimates provides the per-hop gas cost for each schema in the cycle.
+	GasEstimates GasEstimates
+	// NativeTokenID is the token ID of the (wrapped) native token gas is paid in.
+	NativeTokenID uint64
+	Runs          int // Number of runs used when pricing gas in the cycle's token.
+}
+
+// CycleProfit describes the outcome of an arbitrage cycle once gas is accounted for.
+// All amounts except GasCostNative are denominated in the cycle's start token.
+type CycleProfit struct {
+	AmountOut     *big.Int
+	GrossProfit   *big.Int // AmountOut - AmountIn; may be negative.
+	GasUsed       uint64
+	GasCostNative *big.Int
+	GasCost       *big.Int
+	NetProfit     *big.Int // GrossProfit - GasCost; may be negative.
+	Profitable    bool     // True only if NetProfit is strictly positive.
+}
+
+// BreakEvenFeeParams encapsulates all inputs for comparing the fees two routes can bear.
+type BreakEvenFeeParams struct {
+	RouteA   []TokenPoolPath
+	RouteB   []TokenPoolPath // Must start and end at the same tokens as RouteA.
+	AmountIn *big.Int
+}
+
+// BreakEvenFee compares two routes between the same tokens at one amount in.
+type BreakEvenFee struct {
+	AmountOutA *big.Int
+	AmountOutB *big.Int
+	// BetterIsA is true if RouteA produces at least as much as RouteB.
+	BetterIsA bool
+	// FeePips is the largest extra fee on the amount in, in hundredths of a basis point
+	// (1_000_000 is 100%), the better route can bear on top of its own fees and still
+	// produce at least as much as the other route.
+	FeePips uint64
+}
+
+// PoolSkipReason explains why a pool was left out of routing when a graph was built.
+type PoolSkipReason string
+
+const (
+	SkipReasonNotInPoolRegistry PoolSkipReason = "pool not found in pool registry"
+	SkipReasonUnknownSchema     PoolSkipReason = "protocol schema could not be resolved"
+	SkipReasonUnsupportedSchema PoolSkipReason = "protocol schema is not supported for routing"
+	SkipReasonMissingPoolData   PoolSkipReason = "pool missing from protocol state"
+	SkipReasonTokenFiltered     PoolSkipReason = "pool token excluded by the token allowlist or denylist"
+	SkipReasonUnknownToken      PoolSkipReason = "pool token missing from the token registry"
+	SkipReasonDuplicatePoolID   PoolSkipReason = "pool id claimed by more than one protocol"
+	SkipReasonBelowLiquidity    PoolSkipReason = "pool liquidity below the configured threshold"
+)
+
+// SkippedPool is a pool of the token-pool graph that cannot be quoted.
+type SkippedPool struct {
+	PoolID uint64
+	Reason PoolSkipReason
+}
+
+// GraphBuildReport summarizes which pools of the token-pool graph are available for routing.
+type GraphBuildReport struct {
+	TotalPools    int
+	RoutablePools int
+	Skipped       []SkippedPool // Ordered as the pools appear in the token-pool graph.
+	// UnknownTokenPools lists the pools referencing tokens missing from the token registry,
+	// whether they were skipped or routed with default decimals. Empty without token metadata.
+	UnknownTokenPools []uint64
+	// DuplicatePoolIDs lists the pool ids held by more than one protocol's state or listed
+	// more than once in the token-pool graph. Such ids cannot be resolved to a single pool.
+	DuplicatePoolIDs []uint64
+}
+
+// PoolDepth is a pool ranked by liquidity depth.
+type PoolDepth struct {
+	PoolID uint64
+	// Depth is the geometric mean of the pool's two reserves, sqrt(reserve0 * reserve1),
+	// with each reserve scaled to 18 decimals. For Uniswap V3 the reserves are the virtual
+	// reserves of the active liquidity at the current price.
+	Depth *big.Int
+}
+
+// DepthCurveParams selects the pair and the input sizes of TokenPoolGraph.DepthCurves.
+type DepthCurveParams struct {
+	TokenInID  uint64
+	TokenOutID uint64
+	// MinAmountIn and MaxAmountIn bound the input sizes, in raw units of TokenInID.
+	MinAmountIn *big.Int
+	MaxAmountIn *big.Int
+	// Points is the number of input sizes, spaced evenly in log scale. It must be at
+	// least 2.
+	Points int
+}
+
+// DepthPoint is the quote of one input size on a depth curve.
+type DepthPoint struct {
+	AmountIn  *big.Int
+	AmountOut *big.Int
+	// MarginalPrice is the price of TokenIn in TokenOut once AmountIn is swapped, fees
+	// excluded, in whole tokens with token metadata and in raw units without it.
+	MarginalPrice *big.Float
+}
+
+// DepthCurve is how a pool's output and price respond to the size of a swap.
+type DepthCurve struct {
+	PoolID uint64
+	Schema engine.ProtocolSchema
+	Points []DepthPoint
+}
+
+// RouteDepth locates the shallowest hop of a route. Depths are comparable across hops:
+// each is the hop pool's reserve of the token the hop sells into it, converted into raw
+// units of the route's input token at pre-trade spot prices. Uniswap V3 pools use their
+// virtual reserves, i.e. their active liquidity at the current price.
+type RouteDepth struct {
+	HopIndex  int
+	PoolID    uint64
+	Depth     *big.Int   // The smallest of HopDepths.
+	HopDepths []*big.Int // The depth of every hop, in route order.
+}
+
+// DecimalsMismatch is a pool whose assumed decimals for one of its tokens disagree with
+// the token registry, which usually points to an indexing bug.
+type DecimalsMismatch struct {
+	PoolID           uint64
+	TokenID          uint64
+	PoolDecimals     uint8
+	RegistryDecimals uint8
 }
 
 // TokenPoolGraph provides the complete interface for querying the analytical graph.
@@ -107,8 +596,97 @@ type TokenPoolGraph interface {
 		allowedSourceTokens map[uint64]struct{},
 	) (map[uint64]*big.Int, error)
 	FindArbitrageCycles(params CycleFindingParams) ([][]TokenPoolPath, []*big.Int, error)
+	FindAllArbitrageCycles(params CycleFindingParams, limit int) ([][]TokenPoolPath, []*big.Int, error)
+	CycleNetProfit(params CycleProfitParams) (*CycleProfit, error)
+	// BreakEvenFee returns the extra fee the better of two routes can bear before the
+	// other one produces more.
+	BreakEvenFee(params BreakEvenFeeParams) (*BreakEvenFee, error)
+	// FindBestSwapPath returns ErrNoRoute if the tokens are not connected.
 	FindBestSwapPath(params SwapFindingParams) ([]TokenPoolPath, *big.Int, error)
+	// FindBestSwapPathExactOut returns the route that buys amountOut of tokenOutID for the
+	// least tokenInID, and the input it requires, or ErrNoRoute if none can deliver it.
+	FindBestSwapPathExactOut(tokenInID, tokenOutID uint64, amountOut *big.Int, maxHops int) ([]TokenPoolPath, *big.Int, error)
+	// QuoteRoute quotes amountIn along path, e.g. a route from FindBestSwapPath, with
+	// the amounts, prices and price impact of every hop.
+	QuoteRoute(path []TokenPoolPath, amountIn *big.Int) (*RouteQuote, error)
+	// ValidateRoute returns an error wrapping ErrRouteValidation if the route is broken
+	// or its quote does not match the pool state.
+	ValidateRoute(params RouteValidationParams) error
+	// USDPrice returns the USD price of one whole token. It requires token metadata and
+	// returns ErrNoRoute if no anchor stable can be reached.
+	USDPrice(params USDPriceParams) (*big.Float, error)
+	// FindCheapestAcceptableRoute returns ErrNoRoute if no route clears MinAcceptableOut.
+	FindCheapestAcceptableRoute(params CheapestRouteParams) (*CheapestRoute, error)
 	Raw() *tokenpoolregistry.TokenPoolRegistryView
+	// HasTokenMetadata reports whether token metadata (symbols, decimals) is available.
+	HasTokenMetadata() bool
+	// TokenLabel returns a token's symbol, or "ID:<id>" when metadata is unavailable.
+	TokenLabel(tokenID uint64) string
+	// TopPoolsBySchema returns up to n pools of the schema, deepest first.
+	TopPoolsBySchema(schema engine.ProtocolSchema, n int) ([]PoolDepth, error)
+	// DepthCurves returns the depth curve of every pool of the pair, deepest first, so
+	// the pools can be compared size by size.
+	DepthCurves(params DepthCurveParams) ([]DepthCurve, error)
+	// MinRouteDepth returns the hop of path with the least liquidity, in terms of the
+	// route's input token.
+	MinRouteDepth(path []TokenPoolPath) (*RouteDepth, error)
+	// RateForSize returns the effective exchange rate (amount out per amount in) for routing
+	// amountIn, including price impact.
+	RateForSize(tokenInID, tokenOutID uint64, amountIn *big.Int) (*big.Float, error)
+	// QuoteBothDirections returns the best routes A→B and B→A, so the spread is visible.
+	QuoteBothDirections(tokenAID, tokenBID uint64, amount *big.Int) (*TwoWayQuote, error)
+	// PortfolioValue sums the value of balances, keyed by token ID, in baseTokenID, routing
+	// each balance at its full size. Tokens with no route are reported, not valued.
+	PortfolioValue(balances map[uint64]*big.Int, baseTokenID uint64) (*PortfolioValue, error)
+	// SplitSlippage returns the blended effective price and net slippage of a trade split
+	// across the routes of legs.
+	SplitSlippage(legs []SplitLeg) (*SplitSlippage, error)
+	// FindSplitRoute splits AmountIn across parallel routes to maximize the total output.
+	// It returns ErrNoRoute if the tokens are not connected.
+	FindSplitRoute(params SplitRouteParams) (*SplitRoute, error)
+	// WeightedPrice returns the liquidity-weighted average spot price of tokenID in baseTokenID
+	// across every pool that holds both tokens.
+	WeightedPrice(tokenID, baseTokenID uint64) (*big.Float, error)
+	// MostMispricedPool returns the pool of the pair whose spot price deviates most from
+	// externalPrice, the price of one tokenA in tokenB, and the direction to trade it.
+	MostMispricedPool(tokenA, tokenB uint64, externalPrice *big.Float) (*PoolMispricing, error)
+	// TriangleDeviation returns how far the product of the spot prices around a→b→c→a is
+	// from 1, a cheap signal of triangular arbitrage.
+	TriangleDeviation(a, b, c uint64) (*big.Float, error)
+	// ShortestPath returns a fewest-hop path between two tokens regardless of price, or nil
+	// if tokenOut cannot be reached.
+	ShortestPath(tokenIn, tokenOut uint64) []TokenPoolPath
+	// AllSpotPrices returns the spot price of token0 in token1 of every pool that can be
+	// priced, keyed by pool ID.
+	AllSpotPrices(params SpotPriceParams) (map[uint64]*big.Float, error)
+	// GetPoolState returns the concrete pool value behind a pool ID (uniswapv2.Pool,
+	// uniswapv3.Pool or solidly.Pool) and its schema.
+	GetPoolState(poolID uint64) (engine.ProtocolSchema, any, bool)
+	// ConsistencyCheck lists pools whose assumed token decimals disagree with the token registry.
+	ConsistencyCheck() []DecimalsMismatch
+	// BuildReport lists the pools that were left out of routing, and why.
+	BuildReport() GraphBuildReport
+	// ApplyDiff returns a new graph for the patched views in diff. Only the pools listed
+	// in diff.ChangedPools and the pools of diff.ChangedTokens are rebuilt; the receiver
+	// is left untouched.
+	ApplyDiff(diff GraphDiff) (TokenPoolGraph, error)
+}
+
+// GraphDiff carries the views of a patched state into TokenPoolGraph.ApplyDiff.
+// ChangedPools lists the pools the state diff added or updated, and ChangedTokens the
+// tokens it added, updated or deleted, whose pools are rewired as well. Pools that are
+// no longer in TokenPool are dropped, and every other pool is carried over as is.
+// ChangedPools and ChangedTokens derive the lists from a decoded differ.StateDiff.
+type GraphDiff struct {
+	TokenPool            *tokenpoolregistry.TokenPoolRegistryView
+	IndexedTokenRegistry tokenregistryindexer.IndexedTokenSystem
+	IndexedPoolRegistry  poolregistryindexer.IndexedPoolRegistry
+	IndexedUniswapV2     uniswapv2indexer.IndexedUniswapV2
+	IndexedUniswapV3     uniswapv3indexer.IndexedUniswapV3
+	IndexedSolidly       solidlyindexer.IndexedSolidly
+	ProtocolResolver     *ProtocolResolver
+	ChangedPools         []uint64
+	ChangedTokens        []uint64
 }
 
 type TokenPoolGrapher interface {
@@ -118,6 +696,7 @@ type TokenPoolGrapher interface {
 		indexedPoolRegistry poolregistryindexer.IndexedPoolRegistry,
 		indexedUniswapV2 uniswapv2indexer.IndexedUniswapV2,
 		indexedUniswapV3 uniswapv3indexer.IndexedUniswapV3,
+		indexedSolidly solidlyindexer.IndexedSolidly,
 		protocolResolver *ProtocolResolver,
 	) (TokenPoolGraph, error)
 }
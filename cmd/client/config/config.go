@@ -4,12 +4,61 @@ import (
 	"math/big"
 	"os"
 
+	"github.com/defistate/defistate-client-go/addr"
+	"github.com/defistate/defistate-client-go/chains/graphers"
+	"github.com/defistate/defistate-client-go/streams/jsonrpc/manager"
 	"gopkg.in/yaml.v3"
 )
 
 type ClientConfig struct {
 	ChainID        *big.Int `yaml:"chain_id"`
 	StateStreamURL string   `yaml:"state_stream_url"`
+
+	// SchemaVersion pins the state decoder to a single protocol schema version.
+	// Zero (the default) accepts every version the client can decode.
+	SchemaVersion uint64 `yaml:"schema_version"`
+
+	// Concurrency is the number of workers decoding and patching protocols in parallel.
+	// Zero (the default) handles them one by one.
+	Concurrency int `yaml:"concurrency"`
+
+	// TokenAllowlist and TokenDenylist filter the tokens the grapher routes through, by
+	// address (see grapher.WithTokenAllowlist and grapher.WithTokenDenylist), in every
+	// graph built from GrapherOptions. Both are optional; an invalid address fails
+	// LoadConfig.
+	TokenAllowlist []addr.Address `yaml:"token_allowlist"`
+	TokenDenylist  []addr.Address `yaml:"token_denylist"`
+
+	// Chains lists the chains to stream side by side. When it is empty, the single chain
+	// given by ChainID, StateStreamURL, SchemaVersion and Concurrency is streamed; see
+	// ChainConfigs.
+	Chains []manager.ChainConfig `yaml:"chains"`
+}
+
+// ChainConfigs returns the chains to stream: Chains, or else the single top-level chain.
+func (c *ClientConfig) ChainConfigs() []manager.ChainConfig {
+	if len(c.Chains) > 0 {
+		return c.Chains
+	}
+	var chainID uint64
+	if c.ChainID != nil {
+		chainID = c.ChainID.Uint64()
+	}
+	return []manager.ChainConfig{{
+		ChainID:        chainID,
+		StateStreamURL: c.StateStreamURL,
+		SchemaVersion:  c.SchemaVersion,
+		Concurrency:    c.Concurrency,
+	}}
+}
+
+// GrapherOptions returns the options that apply TokenAllowlist and TokenDenylist to the
+// graphers of graphers.ForChainID.
+func (c *ClientConfig) GrapherOptions() []graphers.Option {
+	return []graphers.Option{
+		graphers.WithTokenAllowlist(c.TokenAllowlist),
+		graphers.WithTokenDenylist(c.TokenDenylist),
+	}
 }
 
 // LoadConfig reads a configuration file from the given path and unmarshals it
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/defistate/defistate-client-go/addr"
+	"github.com/defistate/defistate-client-go/chains"
+	"github.com/defistate/defistate-client-go/chains/graphers"
+	"github.com/defistate/defistate-client-go/engine"
+	"github.com/defistate/defistate-client-go/engine/quoter"
+	poolregistry "github.com/defistate/defistate-client-go/protocols/poolregistry"
+	tokenpoolregistry "github.com/defistate/defistate-client-go/protocols/tokenpoolregistry"
+	"github.com/defistate/defistate-client-go/protocols/tokenregistry"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	"github.com/defistate/defistate-client-go/streams/jsonrpc/manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+	return path
+}
+
+func TestLoadConfig_TokenFilters(t *testing.T) {
+	t.Run("Denylist and allowlist are parsed", func(t *testing.T) {
+		cfg, err := LoadConfig(writeConfig(t, `
+chain_id: 1
+state_stream_url: "wss://example"
+token_allowlist:
+  - "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"
+token_denylist:
+  - "0xdAC17F958D2ee523a2206206994597C13D831ec7"
+  - "0x6B175474E89094C44Da98b954EedeAC495271d0F"
+`))
+		require.NoError(t, err)
+
+		weth, err := addr.Parse("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+		require.NoError(t, err)
+		usdt, err := addr.Parse("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+		require.NoError(t, err)
+		dai, err := addr.Parse("0x6B175474E89094C44Da98b954EedeAC495271d0F")
+		require.NoError(t, err)
+
+		assert.Equal(t, []addr.Address{weth}, cfg.TokenAllowlist)
+		assert.Equal(t, []addr.Address{usdt, dai}, cfg.TokenDenylist)
+	})
+
+	t.Run("Filters are optional", func(t *testing.T) {
+		cfg, err := LoadConfig(writeConfig(t, "chain_id: 1\nstate_stream_url: \"wss://example\"\n"))
+		require.NoError(t, err)
+		assert.Empty(t, cfg.TokenAllowlist)
+		assert.Empty(t, cfg.TokenDenylist)
+	})
+
+	t.Run("Invalid address is rejected", func(t *testing.T) {
+		_, err := LoadConfig(writeConfig(t, "chain_id: 1\ntoken_denylist:\n  - \"not-an-address\"\n"))
+		assert.Error(t, err)
+	})
+}
+
+func TestClientConfig_GrapherOptions(t *testing.T) {
+	cfg, err := LoadConfig(writeConfig(t, `
+chain_id: 1
+state_stream_url: "wss://example"
+token_denylist:
+  - "0x0000000000000000000000000000000000000002"
+`))
+	require.NoError(t, err)
+
+	// Pool 100 trades token 1 for the denied token 2, pool 101 token 1 for token 3.
+	state := &engine.State{
+		ChainID: 1,
+		Protocols: map[engine.ProtocolID]engine.ProtocolState{
+			"token-system": {Schema: tokenregistry.Schema, Data: []tokenregistry.Token{
+				{ID: 1, Address: addr.Address{19: 1}, Decimals: 18},
+				{ID: 2, Address: addr.Address{19: 2}, Decimals: 18},
+				{ID: 3, Address: addr.Address{19: 3}, Decimals: 18},
+			}},
+			"pool-system": {Schema: poolregistry.Schema, Data: poolregistry.PoolRegistry{
+				Pools:     []poolregistry.Pool{{ID: 100, Protocol: 1}, {ID: 101, Protocol: 1}},
+				Protocols: map[uint16]engine.ProtocolID{1: "uniswap-v2"},
+			}},
+			"token-pool-graph-system": {Schema: tokenpoolregistry.Schema, Data: &tokenpoolregistry.TokenPoolRegistryView{
+				Tokens:      []uint64{1, 2, 3},
+				Pools:       []uint64{100, 101},
+				Adjacency:   [][]int{{0, 2}, {1}, {3}},
+				EdgeTargets: []int{1, 0, 2, 0},
+				EdgePools:   [][]int{{0}, {0}, {1}, {1}},
+			}},
+			"uniswap-v2": {Schema: uniswapv2.Schema, Data: []uniswapv2.Pool{
+				{ID: 100, Token0: 1, Token1: 2, Reserve0: big.NewInt(1e18), Reserve1: big.NewInt(1e18), FeeBps: 30},
+				{ID: 101, Token0: 1, Token1: 3, Reserve0: big.NewInt(1e18), Reserve1: big.NewInt(1e18), FeeBps: 30},
+			}},
+		},
+	}
+
+	grapher, err := graphers.ForChainID(state.ChainID, cfg.GrapherOptions()...)
+	require.NoError(t, err)
+	q, err := quoter.New(state, quoter.WithGrapher(grapher))
+	require.NoError(t, err)
+
+	report := q.Graph().BuildReport()
+	assert.Equal(t, 1, report.RoutablePools)
+	assert.Equal(t, []chains.SkippedPool{{PoolID: 100, Reason: chains.SkipReasonTokenFiltered}}, report.Skipped)
+	_, err = q.QuoteExactIn(1, 2, big.NewInt(1e15))
+	assert.Error(t, err, "the denied token cannot be reached")
+	_, err = q.QuoteExactIn(1, 3, big.NewInt(1e15))
+	assert.NoError(t, err)
+}
+
+func TestClientConfig_ChainConfigs(t *testing.T) {
+	t.Run("Single chain", func(t *testing.T) {
+		cfg, err := LoadConfig(writeConfig(t, "chain_id: 1\nstate_stream_url: \"wss://example\"\nschema_version: 1\nconcurrency: 4\n"))
+		require.NoError(t, err)
+		assert.Equal(t, []manager.ChainConfig{{ChainID: 1, StateStreamURL: "wss://example", SchemaVersion: 1, Concurrency: 4}}, cfg.ChainConfigs())
+	})
+
+	t.Run("Chains list", func(t *testing.T) {
+		cfg, err := LoadConfig(writeConfig(t, `
+chains:
+  - chain_id: 1
+    state_stream_url: "wss://mainnet"
+  - chain_id: 8453
+    state_stream_url: "wss://base"
+    schema_version: 1
+    concurrency: 2
+`))
+		require.NoError(t, err)
+		assert.Equal(t, []manager.ChainConfig{
+			{ChainID: 1, StateStreamURL: "wss://mainnet"},
+			{ChainID: 8453, StateStreamURL: "wss://base", SchemaVersion: 1, Concurrency: 2},
+		}, cfg.ChainConfigs())
+	})
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/defistate/defistate-client-go/engine"
+)
+
+// Connection states reported by /healthz.
+const (
+	connectionConnecting = "connecting" // no state received yet
+	connectionStreaming  = "streaming"
+	connectionFailed     = "failed" // the chain's client gave up
+)
+
+// chainHealth tracks the stream of one chain.
+type chainHealth struct {
+	lastBlock  uint64
+	lastUpdate time.Time
+	connection string
+	err        string
+}
+
+// chainStatus is the health of one chain as reported by /healthz and expvar.
+type chainStatus struct {
+	ChainID    uint64  `json:"chainId"`
+	LastBlock  uint64  `json:"lastBlock"`
+	Staleness  float64 `json:"stalenessSeconds"`
+	Connection string  `json:"connection"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// healthTracker records the states and errors of the chain clients. It is safe for
+// concurrent use.
+type healthTracker struct {
+	mu           sync.Mutex
+	chains       map[uint64]*chainHealth
+	maxStaleness time.Duration
+	now          func() time.Time
+}
+
+// newHealthTracker tracks chainIDs. A chain whose last state is older than maxStaleness
+// is unhealthy; zero disables the check.
+func newHealthTracker(chainIDs []uint64, maxStaleness time.Duration) *healthTracker {
+	h := &healthTracker{
+		chains:       make(map[uint64]*chainHealth, len(chainIDs)),
+		maxStaleness: maxStaleness,
+		now:          time.Now,
+	}
+	for _, id := range chainIDs {
+		h.chains[id] = &chainHealth{connection: connectionConnecting}
+	}
+	return h
+}
+
+// observeState records a state emitted by the client of chainID.
+func (h *healthTracker) observeState(chainID uint64, state *engine.State) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	chain := h.chain(chainID)
+	if state.Block.Number != nil {
+		chain.lastBlock = state.Block.Number.Uint64()
+	}
+	chain.lastUpdate = h.now()
+	chain.connection = connectionStreaming
+	chain.err = ""
+}
+
+// observeError records the fatal error of the client of chainID.
+func (h *healthTracker) observeError(chainID uint64, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	chain := h.chain(chainID)
+	chain.connection = connectionFailed
+	chain.err = err.Error()
+}
+
+func (h *healthTracker) chain(chainID uint64) *chainHealth {
+	chain, ok := h.chains[chainID]
+	if !ok {
+		chain = &chainHealth{connection: connectionConnecting}
+		h.chains[chainID] = chain
+	}
+	return chain
+}
+
+// report returns the health of every chain, ordered by chain ID, and whether all of them
+// are streaming and fresh.
+func (h *healthTracker) report() ([]chainStatus, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.now()
+	healthy := true
+	report := make([]chainStatus, 0, len(h.chains))
+	for id, chain := range h.chains {
+		entry := chainStatus{
+			ChainID:    id,
+			LastBlock:  chain.lastBlock,
+			Connection: chain.connection,
+			Error:      chain.err,
+		}
+		if !chain.lastUpdate.IsZero() {
+			entry.Staleness = now.Sub(chain.lastUpdate).Seconds()
+		}
+		if chain.connection != connectionStreaming ||
+			(h.maxStaleness > 0 && now.Sub(chain.lastUpdate) > h.maxStaleness) {
+			healthy = false
+		}
+		report = append(report, entry)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].ChainID < report[j].ChainID })
+	return report, healthy
+}
+
+// ServeHTTP serves /healthz: the report as JSON, with status 503 if any chain is unhealthy.
+func (h *healthTracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report, healthy := h.report()
+	w.Header().Set("Content-Type", "application/json")
+	if !healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(struct {
+		Healthy bool          `json:"healthy"`
+		Chains  []chainStatus `json:"chains"`
+	}{healthy, report})
+}
+
+// The "chains" expvar is published once per process, as expvar.Publish panics on a
+// duplicate name, and reports the tracker of the most recent debug handler.
+var (
+	publishChains sync.Once
+	chainsHealth  atomic.Pointer[healthTracker]
+)
+
+// newDebugHandler serves pprof under /debug/pprof/, expvar under /debug/vars and health
+// under /healthz. The chain health is also published as the "chains" expvar.
+func newDebugHandler(health *healthTracker) http.Handler {
+	chainsHealth.Store(health)
+	publishChains.Do(func() {
+		expvar.Publish("chains", expvar.Func(func() any {
+			report, _ := chainsHealth.Load().report()
+			return report
+		}))
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.Handle("/healthz", health)
+	return mux
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/defistate/defistate-client-go/engine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugHandler(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	health := newHealthTracker([]uint64{1, 8453}, time.Minute)
+	health.now = func() time.Time { return now }
+	handler := newDebugHandler(health)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	type healthz struct {
+		Healthy bool          `json:"healthy"`
+		Chains  []chainStatus `json:"chains"`
+	}
+	decode := func(rec *httptest.ResponseRecorder) healthz {
+		var body healthz
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		return body
+	}
+
+	// Unhealthy until every chain streams.
+	health.observeState(1, &engine.State{Block: engine.BlockSummary{Number: big.NewInt(100)}})
+	rec := get("/healthz")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	body := decode(rec)
+	require.Len(t, body.Chains, 2)
+	assert.Equal(t, chainStatus{ChainID: 1, LastBlock: 100, Connection: connectionStreaming}, body.Chains[0])
+	assert.Equal(t, connectionConnecting, body.Chains[1].Connection)
+
+	health.observeState(8453, &engine.State{Block: engine.BlockSummary{Number: big.NewInt(200)}})
+	rec = get("/healthz")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, decode(rec).Healthy)
+
+	// Stale and failed chains are unhealthy.
+	now = now.Add(2 * time.Minute)
+	body = decode(get("/healthz"))
+	assert.False(t, body.Healthy)
+	assert.Equal(t, 120.0, body.Chains[0].Staleness)
+
+	health.observeError(8453, errors.New("stream lost"))
+	body = decode(get("/healthz"))
+	assert.Equal(t, connectionFailed, body.Chains[1].Connection)
+	assert.Equal(t, "stream lost", body.Chains[1].Error)
+
+	assert.Equal(t, http.StatusOK, get("/debug/pprof/").Code)
+	rec = get("/debug/vars")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"chains"`)
+}
+
+func TestDebugHandler_BuiltTwice(t *testing.T) {
+	newDebugHandler(newHealthTracker([]uint64{1}, 0))
+
+	// A second handler must not panic on the "chains" expvar and takes it over.
+	var handler http.Handler
+	require.NotPanics(t, func() { handler = newDebugHandler(newHealthTracker([]uint64{8453}, 0)) })
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	var vars struct {
+		Chains []chainStatus `json:"chains"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &vars))
+	require.Len(t, vars.Chains, 1)
+	assert.Equal(t, uint64(8453), vars.Chains[0].ChainID)
+}
@@ -2,21 +2,18 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"flag"
-	"fmt"
 	"log"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/defistate/defistate-client-go/cmd/client/config"
-	"github.com/defistate/defistate-client-go/differ"
-	"github.com/defistate/defistate-client-go/engine"
-	"github.com/defistate/defistate-client-go/streams/jsonrpc/client"
-	"github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains"
-	ethstateops "github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains/ethereum"
+	"github.com/defistate/defistate-client-go/streams/jsonrpc/manager"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -24,13 +21,6 @@ const (
 	DefaultClientStateBufferSize = 100
 )
 
-type ChainStateOps interface {
-	Diff(old *engine.State, new *engine.State) (*differ.StateDiff, error)
-	Patch(oldState *engine.State, diff *differ.StateDiff) (*engine.State, error)
-	DecodeStateJSON(schema engine.ProtocolSchema, data json.RawMessage) (any, error)
-	DecodeStateDiffJSON(schema engine.ProtocolSchema, data json.RawMessage) (any, error)
-}
-
 func main() {
 	// create the log handler
 	rootLogHandler := slog.NewJSONHandler(os.Stdout, nil)
@@ -40,7 +30,12 @@ func main() {
 
 	rootLogger := slog.New(rootLogHandler)
 	prometheusRegistry := prometheus.DefaultRegisterer
-	cfg, err := loadConfig()
+	configPath := flag.String("config", "config.yaml", "Path to the configuration file.")
+	debugAddr := flag.String("debug-addr", "", "Address to serve pprof, expvar and /healthz on, e.g. localhost:6060. Empty disables it.")
+	maxStaleness := flag.Duration("debug-max-staleness", time.Minute, "Age of a chain's last state after which /healthz reports it unhealthy. Zero disables the check.")
+	flag.Parse()
+
+	cfg, err := loadConfig(*configPath)
 	if err != nil {
 		rootLogger.Error("Failed to load configuration", "error", err)
 		close()
@@ -50,47 +45,50 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	var (
-		chainStateOps ChainStateOps
-	)
-
-	switch cfg.ChainID.Uint64() {
-	case chains.Mainnet:
-		chainStateOps, err = ethstateops.NewStateOps(rootLogger, prometheusRegistry)
-		if err != nil {
-			rootLogger.Error("Failed to initialize Chain State Ops", "chain_id", cfg.ChainID, "error", err)
-			close()
-		}
-	default:
-		// we don't know this chain, log error and close.
-		rootLogger.Error(fmt.Errorf("Chain State Ops not found for chain with ID %d", cfg.ChainID.Uint64()).Error())
+	chainManager, err := manager.NewClientManager(ctx, manager.Config{
+		Chains:     cfg.ChainConfigs(),
+		Logger:     rootLogger,
+		Registry:   prometheusRegistry,
+		BufferSize: DefaultClientStateBufferSize,
+	})
+	if err != nil {
+		rootLogger.Error("Failed to initialize Client Manager", "error", err)
 		close()
 	}
 
-	client, err := client.NewClient(
-		ctx,
-		client.Config{
-			URL:              cfg.StateStreamURL,
-			Logger:           rootLogger.With("component", "jsonrpc-client"),
-			BufferSize:       DefaultClientStateBufferSize,
-			StatePatcher:     chainStateOps.Patch,
-			StateDecoder:     chainStateOps.DecodeStateJSON,
-			StateDiffDecoder: chainStateOps.DecodeStateDiffJSON,
-		},
-	)
-
-	if err != nil {
-		rootLogger.Error("Failed to initialize Client", "chain_id", cfg.ChainID, "error", err)
-		close()
+	var chainIDs []uint64
+	for _, chain := range cfg.ChainConfigs() {
+		chainIDs = append(chainIDs, chain.ChainID)
+	}
+	health := newHealthTracker(chainIDs, *maxStaleness)
+	if *debugAddr != "" {
+		server := &http.Server{Addr: *debugAddr, Handler: newDebugHandler(health)}
+		go func() {
+			rootLogger.Info("Serving debug endpoints", "addr", *debugAddr)
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				rootLogger.Error("Debug server failed", "error", err)
+			}
+		}()
+		go func() {
+			<-ctx.Done()
+			server.Close()
+		}()
 	}
 
 	for {
 		select {
-		case <-client.State():
-		// consume state
-		case err := <-client.Err():
+		case chainState := <-chainManager.State():
+			// consume state, tagged with its chain ID
+			health.observeState(chainState.ChainID, chainState.State)
+		case err, ok := <-chainManager.Err():
+			if !ok {
+				return
+			}
 			rootLogger.Error("Fatal client error", "error", err)
-			return //
+			var chainErr *manager.ChainError
+			if errors.As(err, &chainErr) {
+				health.observeError(chainErr.ChainID, chainErr.Err)
+			}
 		case <-ctx.Done():
 			return
 		}
@@ -98,9 +96,7 @@ func main() {
 
 }
 
-func loadConfig() (*config.ClientConfig, error) {
-	configPath := flag.String("config", "config.yaml", "Path to the configuration file.")
-	flag.Parse()
-	log.Printf("Loading configuration from: %s", *configPath)
-	return config.LoadConfig(*configPath)
+func loadConfig(configPath string) (*config.ClientConfig, error) {
+	log.Printf("Loading configuration from: %s", configPath)
+	return config.LoadConfig(configPath)
 }
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/defistate/defistate-client-go/engine"
+	poolregistry "github.com/defistate/defistate-client-go/protocols/poolregistry"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	uniswapv3calculator "github.com/defistate/defistate-client-go/protocols/uniswapv3/calculator"
+)
+
+// crossDirection is the side of the target price a price alert waits for.
+type crossDirection string
+
+const (
+	crossAbove crossDirection = "above"
+	crossBelow crossDirection = "below"
+)
+
+// priceAlert detects a price crossing a target. It fires when the price moves from the
+// far side of the target to the watched side; a price already there when the watch
+// starts does not fire, and the alert re-arms once the price moves back.
+type priceAlert struct {
+	target    *big.Float
+	direction crossDirection
+	last      *big.Float
+}
+
+func newPriceAlert(target *big.Float, direction crossDirection) (*priceAlert, error) {
+	if target == nil || target.Sign() <= 0 {
+		return nil, fmt.Errorf("target price must be positive")
+	}
+	if direction != crossAbove && direction != crossBelow {
+		return nil, fmt.Errorf("direction must be %q or %q", crossAbove, crossBelow)
+	}
+	return &priceAlert{target: target, direction: direction}, nil
+}
+
+// observe records the next price and reports whether it crossed the target.
+func (a *priceAlert) observe(price *big.Float) bool {
+	last := a.last
+	a.last = price
+	return last != nil && !a.reached(last) && a.reached(price)
+}
+
+// reached reports whether price is on the watched side of the target, the target included.
+func (a *priceAlert) reached(price *big.Float) bool {
+	if a.direction == crossAbove {
+		return price.Cmp(a.target) >= 0
+	}
+	return price.Cmp(a.target) <= 0
+}
+
+type poolPriceView struct {
+	PoolID  uint64     `json:"poolId"`
+	Symbol0 string     `json:"symbol0"`
+	Symbol1 string     `json:"symbol1"`
+	Price   *big.Float `json:"price"` // whole token1 per whole token0
+}
+
+// poolSpotPrice returns the decimals-aware spot price of token0 in token1 for the pool
+// with the given registry key. Token decimals come from the token registry.
+func poolSpotPrice(state *engine.State, key [32]byte) (poolPriceView, error) {
+	var view poolPriceView
+	protocolState, ok := state.Protocols[engine.ProtocolID("pool-system")]
+	if !ok {
+		return view, fmt.Errorf("protocol 'pool-system' not found")
+	}
+	registry, ok := protocolState.Data.(poolregistry.PoolRegistry)
+	if !ok {
+		return view, fmt.Errorf("bad pool registry data type: %T", protocolState.Data)
+	}
+	var pool *poolregistry.Pool
+	for i := range registry.Pools {
+		if registry.Pools[i].Key == key {
+			pool = &registry.Pools[i]
+			break
+		}
+	}
+	if pool == nil {
+		return view, fmt.Errorf("pool key not found in registry")
+	}
+	view.PoolID = pool.ID
+	pState, ok := state.Protocols[registry.Protocols[pool.Protocol]]
+	if !ok {
+		return view, fmt.Errorf("protocol of pool %d is not loaded", pool.ID)
+	}
+
+	tokens, hasMetadata, err := tokenRegistry(state)
+	if err != nil {
+		return view, err
+	}
+	if !hasMetadata {
+		return view, fmt.Errorf("'token-system' missing: token decimals are unknown")
+	}
+	decimals := func(id uint64) (uint8, error) {
+		for _, t := range tokens {
+			if t.ID == id {
+				return t.Decimals, nil
+			}
+		}
+		return 0, fmt.Errorf("token %d missing from the token registry", id)
+	}
+	symbols := tokenSymbols(tokens)
+
+	var token0, token1 uint64
+	switch data := protocolPoolData(pState, pool.ID).(type) {
+	case *uniswapv2.Pool:
+		token0, token1 = data.Token0, data.Token1
+		view.Price, err = v2SpotPrice(data, decimals)
+	case *uniswapv3.Pool:
+		token0, token1 = data.Token0, data.Token1
+		view.Price, err = v3SpotPrice(data, decimals)
+	default:
+		return view, fmt.Errorf("no spot price for pool %d of schema %s", pool.ID, pState.Schema)
+	}
+	if err != nil {
+		return view, err
+	}
+	view.Symbol0, view.Symbol1 = symbolFor(symbols, token0), symbolFor(symbols, token1)
+	return view, nil
+}
+
+// v2SpotPrice is reserve1/reserve0 in whole tokens.
+func v2SpotPrice(pool *uniswapv2.Pool, decimals func(uint64) (uint8, error)) (*big.Float, error) {
+	decimals0, err := decimals(pool.Token0)
+	if err != nil {
+		return nil, err
+	}
+	decimals1, err := decimals(pool.Token1)
+	if err != nil {
+		return nil, err
+	}
+	if pool.Reserve0 == nil || pool.Reserve1 == nil || pool.Reserve0.Sign() == 0 {
+		return nil, fmt.Errorf("pool %d has no reserves", pool.ID)
+	}
+	reserve0 := new(big.Float).Quo(new(big.Float).SetInt(pool.Reserve0), pow10Float(decimals0))
+	reserve1 := new(big.Float).Quo(new(big.Float).SetInt(pool.Reserve1), pow10Float(decimals1))
+	return reserve1.Quo(reserve1, reserve0), nil
+}
+
+// v3SpotPrice converts the calculator's fixed-point spot price to whole tokens.
+func v3SpotPrice(pool *uniswapv3.Pool, decimals func(uint64) (uint8, error)) (*big.Float, error) {
+	decimals0, err := decimals(pool.Token0)
+	if err != nil {
+		return nil, err
+	}
+	decimals1, err := decimals(pool.Token1)
+	if err != nil {
+		return nil, err
+	}
+	if pool.SqrtPriceX96 == nil {
+		return nil, fmt.Errorf("pool %d has no price", pool.ID)
+	}
+	price, err := uniswapv3calculator.GetSpotPrice(pool.Token0, pool.Token1, decimals0, decimals1, *pool)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Float).Quo(new(big.Float).SetInt(price), pow10Float(decimals1)), nil
+}
+
+func pow10Float(decimals uint8) *big.Float {
+	return new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil))
+}
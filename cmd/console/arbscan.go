@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	chaintypes "github.com/defistate/defistate-client-go/chains"
+	"github.com/defistate/defistate-client-go/engine"
+	poolregistry "github.com/defistate/defistate-client-go/protocols/poolregistry"
+	"github.com/defistate/defistate-client-go/protocols/tokenregistry"
+)
+
+const (
+	// arbScanRuns is the maximum number of hops of a scanned cycle.
+	arbScanRuns = 3
+	// arbScanLimit is the number of most profitable cycles shown per block.
+	arbScanLimit = 5
+)
+
+type arbHopView struct {
+	TokenInID  uint64            `json:"tokenInId"`
+	TokenIn    string            `json:"tokenIn"`
+	TokenOutID uint64            `json:"tokenOutId"`
+	TokenOut   string            `json:"tokenOut"`
+	PoolID     uint64            `json:"poolId"`
+	ProtocolID engine.ProtocolID `json:"protocolId,omitempty"`
+}
+
+type arbCycleView struct {
+	Hops      []arbHopView `json:"hops"`
+	AmountOut *big.Int     `json:"amountOut"`
+	Profit    *big.Int     `json:"profit"`
+	ProfitFmt string       `json:"profitFormatted"`
+	ProfitBps float64      `json:"profitBps"`
+}
+
+type arbScanView struct {
+	Block    uint64         `json:"block"`
+	TokenID  uint64         `json:"tokenId"`
+	Symbol   string         `json:"symbol"`
+	AmountIn *big.Int       `json:"amountIn"`
+	Cycles   []arbCycleView `json:"cycles"`
+	// DepthLimited is true if the search was cut short and may have missed cycles.
+	DepthLimited bool `json:"depthLimited,omitempty"`
+}
+
+// scanArbitrage runs the grapher's cycle finder on state for cycles that start and end at
+// token, trading amountIn, and returns the most profitable ones that earn at least
+// minProfit raw units (and more than nothing).
+func scanArbitrage(state *engine.State, token *tokenregistry.Token, amountIn, minProfit *big.Int) (arbScanView, error) {
+	view := arbScanView{TokenID: token.ID, Symbol: token.Symbol, AmountIn: amountIn}
+	if state.Block.Number != nil {
+		view.Block = state.Block.Number.Uint64()
+	}
+
+	g, _, err := analyticalGraph(state)
+	if err != nil {
+		return view, err
+	}
+	cycles, amounts, err := g.FindAllArbitrageCycles(chaintypes.CycleFindingParams{
+		AmountIn:  amountIn,
+		TokenID:   token.ID,
+		Runs:      arbScanRuns,
+		MinProfit: &chaintypes.ProfitThreshold{Unit: chaintypes.ProfitUnitRaw, Amount: new(big.Float).SetInt(minProfit)},
+	}, arbScanLimit)
+	if errors.Is(err, chaintypes.ErrCycleDepthLimit) {
+		view.DepthLimited = true
+	} else if err != nil {
+		return view, err
+	}
+
+	var tokens []tokenregistry.Token
+	for _, p := range state.Protocols {
+		if p.Schema == tokenregistry.Schema {
+			tokens, _ = p.Data.([]tokenregistry.Token)
+		}
+	}
+	symbols := tokenSymbols(tokens)
+	decimals := decimalsByToken(tokens)
+	protocols := poolProtocols(state)
+
+	for i, cycle := range cycles {
+		profit := new(big.Int).Sub(amounts[i], amountIn)
+		if profit.Sign() <= 0 {
+			continue
+		}
+		bps, _ := new(big.Rat).SetFrac(new(big.Int).Mul(profit, big.NewInt(10_000)), amountIn).Float64()
+		cycleView := arbCycleView{
+			AmountOut: amounts[i],
+			Profit:    profit,
+			ProfitFmt: formatUnits(profit, decimals, token.ID),
+			ProfitBps: bps,
+		}
+		for _, hop := range cycle {
+			cycleView.Hops = append(cycleView.Hops, arbHopView{
+				TokenInID:  hop.TokenInID,
+				TokenIn:    symbolFor(symbols, hop.TokenInID),
+				TokenOutID: hop.TokenOutID,
+				TokenOut:   symbolFor(symbols, hop.TokenOutID),
+				PoolID:     hop.PoolID,
+				ProtocolID: protocols[hop.PoolID],
+			})
+		}
+		view.Cycles = append(view.Cycles, cycleView)
+	}
+	return view, nil
+}
+
+// poolProtocols maps the pools of the pool registry to their protocol.
+func poolProtocols(state *engine.State) map[uint64]engine.ProtocolID {
+	protocols := make(map[uint64]engine.ProtocolID)
+	for _, p := range state.Protocols {
+		registry, ok := p.Data.(poolregistry.PoolRegistry)
+		if !ok {
+			continue
+		}
+		for _, pool := range registry.Pools {
+			protocols[pool.ID] = registry.Protocols[pool.Protocol]
+		}
+	}
+	return protocols
+}
+
+// renderArbScan writes the profitable cycles found in one block.
+func renderArbScan(w io.Writer, view arbScanView, asJSON bool) error {
+	if asJSON {
+		return writeJSON(w, view)
+	}
+
+	if len(view.Cycles) == 0 {
+		_, err := fmt.Fprintf(w, " %s#%d%s  no profitable cycle\n", Gray, view.Block, Reset)
+		return err
+	}
+	for _, cycle := range view.Cycles {
+		route := make([]string, 0, len(cycle.Hops)+1)
+		pools := make([]string, 0, len(cycle.Hops))
+		for i, hop := range cycle.Hops {
+			if i == 0 {
+				route = append(route, hop.TokenIn)
+			}
+			route = append(route, hop.TokenOut)
+			pool := fmt.Sprintf("%d", hop.PoolID)
+			if hop.ProtocolID != "" {
+				pool += " (" + string(hop.ProtocolID) + ")"
+			}
+			pools = append(pools, pool)
+		}
+		fmt.Fprintf(w, " %s#%d%s  %s+%s %s%s (%.1f bps)  %s\n",
+			Gray, view.Block, Reset,
+			Green, cycle.ProfitFmt, view.Symbol, Reset, cycle.ProfitBps,
+			strings.Join(route, " -> "))
+		fmt.Fprintf(w, "          %svia pools %s%s\n", Gray, strings.Join(pools, ", "), Reset)
+	}
+	if view.DepthLimited {
+		fmt.Fprintln(w, Yellow+"          [WARN] search reached its depth limit; cycles may be missing."+Reset)
+	}
+	return nil
+}
+
+// rawUnits scales a decimal amount such as "1.5" to raw token units.
+func rawUnits(input string, decimals uint8) (*big.Int, bool) {
+	amount, ok := new(big.Float).SetString(strings.TrimSpace(input))
+	if !ok || amount.Sign() < 0 {
+		return nil, false
+	}
+	scale := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil))
+	raw, _ := new(big.Float).Mul(amount, scale).Int(nil)
+	return raw, true
+}
+
+// scanArbitrageLive scans every new block for profitable cycles of a base token until
+// the user presses Enter.
+func scanArbitrageLive(safeState *SafeState, reader *bufio.Reader) {
+	fmt.Print("\n" + Bold + "[Arb Scanner] Enter Base Token Address: " + Reset)
+	token, err := readAndValidateToken(safeState.Get(), reader)
+	if err != nil {
+		if err != errEmptyInput {
+			fmt.Println(Red + err.Error() + Reset)
+		}
+		return
+	}
+	fmt.Printf("%s   Selected Base: %s (%d decimals)%s\n", Green, token.Symbol, token.Decimals, Reset)
+
+	fmt.Print(Bold + "Amount In (e.g. 1.5): " + Reset)
+	amountInput, _ := reader.ReadString('\n')
+	amountIn, ok := rawUnits(amountInput, token.Decimals)
+	if !ok || amountIn.Sign() == 0 {
+		fmt.Println(Red + "Invalid amount format." + Reset)
+		return
+	}
+	fmt.Printf(Bold+"Min Profit in %s (default 0): "+Reset, token.Symbol)
+	minInput, _ := reader.ReadString('\n')
+	minProfit := new(big.Int)
+	if strings.TrimSpace(minInput) != "" {
+		if minProfit, ok = rawUnits(minInput, token.Decimals); !ok {
+			fmt.Println(Red + "Invalid amount format." + Reset)
+			return
+		}
+	}
+
+	fmt.Println(Green + "Scanning for cycles... (Press 'Enter' to stop)" + Reset)
+
+	stopCh := make(chan struct{})
+	go func() {
+		reader.ReadString('\n')
+		close(stopCh)
+	}()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	lastBlock := new(big.Int)
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			state := safeState.Get()
+			if state == nil || state.Block.Number == nil || state.Block.Number.Cmp(lastBlock) <= 0 {
+				continue
+			}
+			lastBlock.Set(state.Block.Number)
+
+			view, err := scanArbitrage(state, token, amountIn, minProfit)
+			if err != nil {
+				fmt.Println(Red + "[ERROR] " + err.Error() + Reset)
+				continue
+			}
+			if err := renderArbScan(os.Stdout, view, *jsonOutput); err != nil {
+				fmt.Println(Red + "[ERROR] " + err.Error() + Reset)
+			}
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+
+	"github.com/defistate/defistate-client-go/analytics"
+	"github.com/defistate/defistate-client-go/engine"
+	"github.com/defistate/defistate-client-go/pricing"
+	poolregistry "github.com/defistate/defistate-client-go/protocols/poolregistry"
+	"github.com/defistate/defistate-client-go/protocols/tokenregistry"
+)
+
+// browsePageSize is the number of pools per page of the pool browser.
+const browsePageSize = 20
+
+// usdAnchorSymbols are the stablecoins the pool browser prices TVL against.
+var usdAnchorSymbols = map[string]bool{"USDC": true, "USDT": true, "DAI": true}
+
+// browseSort is a column the pool browser sorts by.
+type browseSort string
+
+const (
+	sortByProtocol browseSort = "protocol"
+	sortByTVL      browseSort = "tvl"
+	sortByPair     browseSort = "pair"
+)
+
+type poolBrowseRow struct {
+	PoolID     uint64            `json:"poolId"`
+	ProtocolID engine.ProtocolID `json:"protocolId,omitempty"`
+	Address    string            `json:"address,omitempty"`
+	Token0     string            `json:"token0,omitempty"`
+	Token1     string            `json:"token1,omitempty"`
+	// TVL is in USD; it is only set when Priced.
+	TVL    float64 `json:"tvl"`
+	Priced bool    `json:"priced"`
+}
+
+// pair returns the token pair of the row, or "" if its protocol state is not loaded.
+func (r poolBrowseRow) pair() string {
+	if r.Token0 == "" {
+		return ""
+	}
+	return r.Token0 + "/" + r.Token1
+}
+
+type poolPageView struct {
+	Page   int             `json:"page"`
+	Pages  int             `json:"pages"`
+	Total  int             `json:"total"`
+	Sort   browseSort      `json:"sort"`
+	Search string          `json:"search,omitempty"`
+	Pools  []poolBrowseRow `json:"pools"`
+}
+
+// poolBrowseRows lists every pool of the pool registry with its token pair and its TVL
+// in USD. TVL is priced from the analytical graph against USD stablecoins; pools are
+// left unpriced if there is no graph, no stablecoin or a token cannot be priced.
+func poolBrowseRows(state *engine.State) ([]poolBrowseRow, error) {
+	var (
+		registry *poolregistry.PoolRegistry
+		tokens   []tokenregistry.Token
+	)
+	for id, p := range state.Protocols {
+		switch p.Schema {
+		case poolregistry.Schema:
+			r, ok := p.Data.(poolregistry.PoolRegistry)
+			if !ok {
+				return nil, fmt.Errorf("protocol %s: bad data type %T", id, p.Data)
+			}
+			registry = &r
+		case tokenregistry.Schema:
+			tokens, _ = p.Data.([]tokenregistry.Token)
+		}
+	}
+	if registry == nil {
+		return nil, fmt.Errorf("pool registry missing")
+	}
+
+	snapshot := analytics.Compute(state, poolPrices(state, tokens))
+	symbols := tokenSymbols(tokens)
+
+	rows := make([]poolBrowseRow, len(registry.Pools))
+	for i, pool := range registry.Pools {
+		row := poolBrowseRow{PoolID: pool.ID, ProtocolID: registry.Protocols[pool.Protocol]}
+		if address, err := pool.Key.ToAddress(); err == nil {
+			row.Address = address.Checksum()
+		}
+		if tvl, ok := snapshot.Pools[pool.ID]; ok {
+			row.Token0, row.Token1 = symbolFor(symbols, tvl.Token0), symbolFor(symbols, tvl.Token1)
+			row.TVL, row.Priced = tvl.Value, !tvl.Unpriced
+		}
+		rows[i] = row
+	}
+	return rows, nil
+}
+
+// poolPrices prices the tokens of state in USD, or returns no prices if it cannot.
+func poolPrices(state *engine.State, tokens []tokenregistry.Token) analytics.Prices {
+	var anchors []uint64
+	for _, t := range tokens {
+		if usdAnchorSymbols[strings.ToUpper(t.Symbol)] {
+			anchors = append(anchors, t.ID)
+		}
+	}
+	if len(anchors) == 0 {
+		return noPrices{}
+	}
+	g, _, err := analyticalGraph(state)
+	if err != nil {
+		return noPrices{}
+	}
+	oracle, err := pricing.NewOracle(pricing.Config{AnchorTokenIDs: anchors})
+	if err != nil {
+		return noPrices{}
+	}
+	prices, err := oracle.Prices(g, tokens)
+	if err != nil {
+		return noPrices{}
+	}
+	return prices
+}
+
+// noPrices prices nothing.
+type noPrices struct{}
+
+func (noPrices) Price(uint64) (pricing.Price, bool) { return pricing.Price{}, false }
+
+// fuzzyMatch reports whether the letters and digits of query appear in text in order,
+// ignoring case, so "wtusd" matches "WETH/USDC".
+func fuzzyMatch(query, text string) bool {
+	text = strings.ToLower(text)
+	for _, r := range strings.ToLower(query) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			continue
+		}
+		i := strings.IndexRune(text, r)
+		if i < 0 {
+			return false
+		}
+		text = text[i+len(string(r)):]
+	}
+	return true
+}
+
+// browsePage filters rows by a fuzzy search on their token pair, sorts them and returns
+// the given page, clamped to the available pages. TVL sorts descending, with unpriced
+// pools last; ties are broken by pool ID.
+func browsePage(rows []poolBrowseRow, by browseSort, search string, page int) poolPageView {
+	matched := make([]poolBrowseRow, 0, len(rows))
+	for _, row := range rows {
+		if search == "" || fuzzyMatch(search, row.pair()) {
+			matched = append(matched, row)
+		}
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		switch by {
+		case sortByProtocol:
+			if a.ProtocolID != b.ProtocolID {
+				return a.ProtocolID < b.ProtocolID
+			}
+		case sortByTVL:
+			if a.Priced != b.Priced {
+				return a.Priced
+			}
+			if a.TVL != b.TVL {
+				return a.TVL > b.TVL
+			}
+		case sortByPair:
+			if a.pair() != b.pair() {
+				// Pools without a known pair go last.
+				return b.pair() == "" || (a.pair() != "" && a.pair() < b.pair())
+			}
+		}
+		return a.PoolID < b.PoolID
+	})
+
+	pages := max((len(matched)+browsePageSize-1)/browsePageSize, 1)
+	page = min(max(page, 1), pages)
+	start := (page - 1) * browsePageSize
+	end := min(start+browsePageSize, len(matched))
+	return poolPageView{
+		Page:   page,
+		Pages:  pages,
+		Total:  len(matched),
+		Sort:   by,
+		Search: search,
+		Pools:  matched[start:end],
+	}
+}
+
+// renderPoolPage writes one page of the pool browser.
+func renderPoolPage(w io.Writer, view poolPageView, asJSON bool) error {
+	if asJSON {
+		return writeJSON(w, view)
+	}
+
+	title := fmt.Sprintf("POOLS (page %d/%d, %d pools, by %s)", view.Page, view.Pages, view.Total, view.Sort)
+	if view.Search != "" {
+		title += fmt.Sprintf(" matching %q", view.Search)
+	}
+	writeHeader(w, strings.ToUpper(title))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(tw, "ID\tPROTOCOL\tPAIR\tTVL (USD)\tPOOL ADDRESS\t")
+	fmt.Fprintln(tw, "--\t--------\t----\t---------\t------------\t")
+	for _, row := range view.Pools {
+		protoName := string(row.ProtocolID)
+		if protoName == "" {
+			protoName = "Unknown"
+		} else if len(protoName) > 22 {
+			protoName = protoName[:19] + "..."
+		}
+		pair := row.pair()
+		if pair == "" {
+			pair = Gray + "?" + Reset
+		}
+		tvl := Gray + "-" + Reset
+		if row.Priced {
+			tvl = fmt.Sprintf("%.2f", row.TVL)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t\n", row.PoolID, protoName, pair, tvl, row.Address)
+	}
+	return tw.Flush()
+}
+
+// browsePools pages through the pool registry until the user enters an empty line.
+func browsePools(state *engine.State, reader *bufio.Reader) {
+	rows, err := poolBrowseRows(state)
+	if err != nil {
+		fmt.Println(Red + "[ERROR] " + err.Error() + Reset)
+		return
+	}
+
+	by, search, page := sortByTVL, "", 1
+	for {
+		view := browsePage(rows, by, search, page)
+		page = view.Page
+		if err := renderPoolPage(os.Stdout, view, *jsonOutput); err != nil {
+			fmt.Println(Red + "[ERROR] " + err.Error() + Reset)
+			return
+		}
+
+		fmt.Println(Gray + "\n n/p: next/previous page | s protocol|tvl|pair: sort | / text: search symbols | Enter: back" + Reset)
+		fmt.Print(Bold + "> " + Reset)
+		input, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		input = strings.TrimSpace(input)
+		switch {
+		case input == "":
+			return
+		case input == "n":
+			page++
+		case input == "p":
+			page--
+		case strings.HasPrefix(input, "s "):
+			switch sortBy := browseSort(strings.TrimSpace(input[2:])); sortBy {
+			case sortByProtocol, sortByTVL, sortByPair:
+				by, page = sortBy, 1
+			default:
+				fmt.Println(Red + "Sort by protocol, tvl or pair." + Reset)
+			}
+		case strings.HasPrefix(input, "/"):
+			search, page = strings.TrimSpace(input[1:]), 1
+		default:
+			fmt.Println(Red + "Unknown command." + Reset)
+		}
+	}
+}
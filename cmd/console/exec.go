@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/defistate/defistate-client-go/engine"
+)
+
+// execScript runs commands without the interactive menu, for shell scripts and CI.
+var execScript = flag.String("exec", "", `Run commands non-interactively on the first state and exit, e.g. "route WETH USDC 1.5; summary". See "help" for the commands.`)
+
+// execTimeout bounds how long -exec waits for the first state.
+var execTimeout = flag.Duration("exec-timeout", time.Minute, "How long -exec waits for the first state before failing.")
+
+// execCommand is a command of the -exec grammar.
+type execCommand struct {
+	args  []string // argument names, for the usage line
+	about string
+	run   func(w io.Writer, state *engine.State, args []string, asJSON bool) error
+}
+
+// execCommands are the commands -exec accepts. Tokens are given by address or symbol,
+// pools by address or key and amounts in whole tokens.
+var execCommands = map[string]execCommand{
+	"block": {
+		about: "current block info",
+		run: func(w io.Writer, state *engine.State, _ []string, asJSON bool) error {
+			return renderBlockInfo(w, state, asJSON)
+		},
+	},
+	"summary": {
+		about: "protocol summary",
+		run: func(w io.Writer, state *engine.State, _ []string, asJSON bool) error {
+			return renderProtocolSummary(w, state, asJSON)
+		},
+	},
+	"health": {
+		about: "state self-test; fails if the state is unhealthy",
+		run: func(w io.Writer, state *engine.State, _ []string, asJSON bool) error {
+			view := checkHealth(state, time.Now())
+			if err := renderHealthCheck(w, view, asJSON); err != nil {
+				return err
+			}
+			if !view.Pass {
+				return fmt.Errorf("health check failed")
+			}
+			return nil
+		},
+	},
+	"find-pool": {
+		args:  []string{"pool"},
+		about: "look up a pool by address or key",
+		run: func(w io.Writer, state *engine.State, args []string, asJSON bool) error {
+			keys, err := parsePoolKeys(args[0])
+			if err != nil {
+				return err
+			}
+			return renderPoolMatch(w, state, keys[0], asJSON)
+		},
+	},
+	"route": {
+		args:  []string{"tokenIn", "tokenOut", "amount"},
+		about: "best route for an amount of tokenIn",
+		run: func(w io.Writer, state *engine.State, args []string, asJSON bool) error {
+			tokenIn, err := resolveToken(state, args[0])
+			if err != nil {
+				return fmt.Errorf("tokenIn: %w", err)
+			}
+			tokenOut, err := resolveToken(state, args[1])
+			if err != nil {
+				return fmt.Errorf("tokenOut: %w", err)
+			}
+			amountIn, ok := rawUnits(args[2], tokenIn.Decimals)
+			if !ok {
+				return fmt.Errorf("invalid amount %q", args[2])
+			}
+			return routeTokens(w, state, tokenIn, tokenOut, amountIn, asJSON)
+		},
+	},
+}
+
+// execStep is one parsed command of a script.
+type execStep struct {
+	name string
+	args []string
+}
+
+// parseExec parses a script: commands separated by ";", each a name followed by its
+// arguments separated by spaces.
+func parseExec(script string) ([]execStep, error) {
+	var steps []execStep
+	for _, line := range strings.Split(script, ";") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		name, args := fields[0], fields[1:]
+		if name == "help" {
+			return nil, fmt.Errorf("commands:\n%s", execUsage())
+		}
+		command, ok := execCommands[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown command %q; commands:\n%s", name, execUsage())
+		}
+		if len(args) != len(command.args) {
+			return nil, fmt.Errorf("%s takes %d arguments, got %d: usage: %s", name, len(command.args), len(args), commandUsage(name, command))
+		}
+		steps = append(steps, execStep{name: name, args: args})
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("no command to run")
+	}
+	return steps, nil
+}
+
+// runExec runs the steps on state, stopping at the first failing one.
+func runExec(w io.Writer, state *engine.State, steps []execStep, asJSON bool) error {
+	for _, step := range steps {
+		if err := execCommands[step.name].run(w, state, step.args, asJSON); err != nil {
+			return fmt.Errorf("%s: %w", step.name, err)
+		}
+	}
+	return nil
+}
+
+// execUsage lists the commands, one per line.
+func execUsage() string {
+	names := make([]string, 0, len(execCommands))
+	for name := range execCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var usage strings.Builder
+	for _, name := range names {
+		command := execCommands[name]
+		fmt.Fprintf(&usage, "  %-40s %s\n", commandUsage(name, command), command.about)
+	}
+	return usage.String()
+}
+
+func commandUsage(name string, command execCommand) string {
+	usage := name
+	for _, arg := range command.args {
+		usage += " <" + arg + ">"
+	}
+	return usage
+}
+
+// runExecOnFirstState runs the steps on the first state the client emits and returns
+// the process exit code. Results go to out and errors to errOut.
+func runExecOnFirstState(ctx context.Context, states <-chan *engine.State, errs <-chan error, steps []execStep, out, errOut io.Writer) int {
+	timeout := time.NewTimer(*execTimeout)
+	defer timeout.Stop()
+
+	select {
+	case state := <-states:
+		if err := runExec(out, state, steps, *jsonOutput); err != nil {
+			fmt.Fprintln(errOut, "[ERROR] "+err.Error())
+			return 1
+		}
+		return 0
+	case err := <-errs:
+		fmt.Fprintln(errOut, "[ERROR] client: "+err.Error())
+	case <-timeout.C:
+		fmt.Fprintf(errOut, "[ERROR] no state after %s; check the stream URL and client.log\n", *execTimeout)
+	case <-ctx.Done():
+	}
+	return 1
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/defistate/defistate-client-go/engine"
+	poolregistry "github.com/defistate/defistate-client-go/protocols/poolregistry"
+	"github.com/defistate/defistate-client-go/protocols/solidly"
+	"github.com/defistate/defistate-client-go/protocols/tokenregistry"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv4"
+)
+
+// exportFormat is the file format of an export.
+type exportFormat string
+
+const (
+	exportJSON exportFormat = "json"
+	exportCSV  exportFormat = "csv"
+)
+
+// poolCSVHeader is the header of the pool table shared by every pool protocol. Columns a
+// protocol does not have are left empty.
+var poolCSVHeader = []string{
+	"protocol", "schema", "pool_id", "token0", "token1",
+	"reserve0", "reserve1", "fee", "tick", "liquidity", "sqrt_price_x96", "ticks",
+}
+
+// exportState writes the full decoded state. As CSV, it is the pool table of every pool
+// protocol, ordered by protocol ID; registries and graphs have no CSV layout.
+func exportState(w io.Writer, state *engine.State, format exportFormat) error {
+	if format == exportJSON {
+		return writeJSON(w, state)
+	}
+
+	ids := make([]engine.ProtocolID, 0, len(state.Protocols))
+	for id := range state.Protocols {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	cw := csv.NewWriter(w)
+	cw.Write(poolCSVHeader)
+	for _, id := range ids {
+		for _, record := range poolCSVRecords(id, state.Protocols[id]) {
+			cw.Write(record)
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// exportProtocol writes the data of one protocol. As CSV, pool protocols are written as
+// the pool table and the token and pool registries as their own tables.
+func exportProtocol(w io.Writer, state *engine.State, id engine.ProtocolID, format exportFormat) error {
+	pState, ok := state.Protocols[id]
+	if !ok {
+		return fmt.Errorf("protocol '%s' not found", id)
+	}
+	if format == exportJSON {
+		return writeJSON(w, pState)
+	}
+
+	cw := csv.NewWriter(w)
+	switch data := pState.Data.(type) {
+	case []tokenregistry.Token:
+		cw.Write([]string{"id", "address", "symbol", "name", "decimals", "fee_on_transfer_percent", "gas_for_transfer"})
+		for _, t := range data {
+			cw.Write([]string{
+				strconv.FormatUint(t.ID, 10),
+				t.Address.Checksum(),
+				t.Symbol,
+				t.Name,
+				strconv.Itoa(int(t.Decimals)),
+				strconv.FormatFloat(t.FeeOnTransferPercent, 'f', -1, 64),
+				strconv.FormatUint(t.GasForTransfer, 10),
+			})
+		}
+	case poolregistry.PoolRegistry:
+		cw.Write([]string{"id", "key", "protocol"})
+		for _, pool := range data.Pools {
+			cw.Write([]string{
+				strconv.FormatUint(pool.ID, 10),
+				"0x" + hex.EncodeToString(pool.Key[:]),
+				string(data.Protocols[pool.Protocol]),
+			})
+		}
+	default:
+		records := poolCSVRecords(id, pState)
+		if records == nil {
+			return fmt.Errorf("no CSV layout for schema %s", pState.Schema)
+		}
+		cw.Write(poolCSVHeader)
+		for _, record := range records {
+			cw.Write(record)
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// exportPool writes one pool, looked up by key, with its ticks. As CSV, a pool with ticks
+// is written as its tick table and any other pool as a row of the pool table.
+func exportPool(w io.Writer, state *engine.State, key [32]byte, format exportFormat) error {
+	registry, ok := state.Protocols[engine.ProtocolID("pool-system")].Data.(poolregistry.PoolRegistry)
+	if !ok {
+		return fmt.Errorf("protocol 'pool-system' not found")
+	}
+	var pool *poolregistry.Pool
+	for i := range registry.Pools {
+		if registry.Pools[i].Key == key {
+			pool = &registry.Pools[i]
+			break
+		}
+	}
+	if pool == nil {
+		return fmt.Errorf("pool key not found in registry")
+	}
+	protocolID := registry.Protocols[pool.Protocol]
+	pState, ok := state.Protocols[protocolID]
+	if !ok {
+		return fmt.Errorf("protocol state for '%s' is not loaded", protocolID)
+	}
+	data := protocolPoolData(pState, pool.ID)
+	if data == nil {
+		data = solidlyPoolData(pState, pool.ID)
+	}
+	if data == nil {
+		return fmt.Errorf("no exporter for pool %d of schema %s", pool.ID, pState.Schema)
+	}
+
+	if format == exportJSON {
+		return writeJSON(w, poolMatchView{
+			Found:         true,
+			ID:            pool.ID,
+			Key:           "0x" + hex.EncodeToString(pool.Key[:]),
+			ProtocolIndex: pool.Protocol,
+			ProtocolID:    protocolID,
+			Schema:        pState.Schema,
+			Data:          data,
+		})
+	}
+
+	var ticks []uniswapv3.TickInfo
+	switch p := data.(type) {
+	case *uniswapv3.Pool:
+		ticks = p.Ticks
+	case *uniswapv4.Pool:
+		ticks = p.Ticks
+	}
+
+	cw := csv.NewWriter(w)
+	if ticks == nil {
+		cw.Write(poolCSVHeader)
+		record, _ := poolCSVRecord(protocolID, pState.Schema, data)
+		cw.Write(record)
+	} else {
+		cw.Write([]string{"pool_id", "tick", "liquidity_gross", "liquidity_net"})
+		for _, tick := range ticks {
+			cw.Write([]string{
+				strconv.FormatUint(pool.ID, 10),
+				strconv.FormatInt(tick.Index, 10),
+				bigString(tick.LiquidityGross),
+				bigString(tick.LiquidityNet),
+			})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// solidlyPoolData returns the state of a Solidly pool, or nil if pState holds no such pool.
+func solidlyPoolData(pState engine.ProtocolState, poolID uint64) any {
+	data, _ := pState.Data.([]solidly.Pool)
+	for i := range data {
+		if data[i].ID == poolID {
+			return &data[i]
+		}
+	}
+	return nil
+}
+
+// poolCSVRecords returns the pool table rows of a pool protocol, or nil if the protocol
+// does not hold pools.
+func poolCSVRecords(id engine.ProtocolID, pState engine.ProtocolState) [][]string {
+	var pools []any
+	switch data := pState.Data.(type) {
+	case []uniswapv2.Pool:
+		for i := range data {
+			pools = append(pools, &data[i])
+		}
+	case []uniswapv3.Pool:
+		for i := range data {
+			pools = append(pools, &data[i])
+		}
+	case []uniswapv4.Pool:
+		for i := range data {
+			pools = append(pools, &data[i])
+		}
+	case []solidly.Pool:
+		for i := range data {
+			pools = append(pools, &data[i])
+		}
+	default:
+		return nil
+	}
+
+	records := make([][]string, 0, len(pools))
+	for _, pool := range pools {
+		if record, ok := poolCSVRecord(id, pState.Schema, pool); ok {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// poolCSVRecord returns the pool table row of a pool.
+func poolCSVRecord(id engine.ProtocolID, schema engine.ProtocolSchema, pool any) ([]string, bool) {
+	u := func(v uint64) string { return strconv.FormatUint(v, 10) }
+	record := func(poolID, token0, token1 uint64, reserve0, reserve1 *big.Int, fee string, tick string, liquidity, sqrtPrice *big.Int, ticks string) []string {
+		return []string{
+			string(id), string(schema), u(poolID), u(token0), u(token1),
+			bigString(reserve0), bigString(reserve1), fee, tick, bigString(liquidity), bigString(sqrtPrice), ticks,
+		}
+	}
+
+	switch p := pool.(type) {
+	case *uniswapv2.Pool:
+		return record(p.ID, p.Token0, p.Token1, p.Reserve0, p.Reserve1, u(uint64(p.FeeBps)), "", nil, nil, ""), true
+	case *solidly.Pool:
+		return record(p.ID, p.Token0, p.Token1, p.Reserve0, p.Reserve1, u(uint64(p.FeeBps)), "", nil, nil, ""), true
+	case *uniswapv3.Pool:
+		return record(p.ID, p.Token0, p.Token1, nil, nil, u(p.Fee), strconv.FormatInt(p.Tick, 10),
+			p.Liquidity, p.SqrtPriceX96, strconv.Itoa(len(p.Ticks))), true
+	case *uniswapv4.Pool:
+		return record(p.ID, p.Token0, p.Token1, nil, nil, u(p.Fee), strconv.FormatInt(p.Tick, 10),
+			p.Liquidity, p.SqrtPriceX96, strconv.Itoa(len(p.Ticks))), true
+	}
+	return nil, false
+}
+
+// bigString formats n in base 10, or "" if it is nil.
+func bigString(n *big.Int) string {
+	if n == nil {
+		return ""
+	}
+	return n.String()
+}
+
+// exportData asks what to export and in which format, and writes it to a file.
+func exportData(state *engine.State, reader *bufio.Reader) {
+	header("EXPORT")
+	var block uint64
+	if state.Block.Number != nil {
+		block = state.Block.Number.Uint64()
+	}
+
+	fmt.Print(Bold + "1. Export what (state/protocol/pool): " + Reset)
+	scope, _ := reader.ReadString('\n')
+
+	var (
+		name  string
+		write func(io.Writer, exportFormat) error
+	)
+	switch strings.ToLower(strings.TrimSpace(scope)) {
+	case "state":
+		name = fmt.Sprintf("state-%d", block)
+		write = func(w io.Writer, f exportFormat) error { return exportState(w, state, f) }
+	case "protocol":
+		fmt.Print(Bold + "   Protocol ID: " + Reset)
+		input, _ := reader.ReadString('\n')
+		id := engine.ProtocolID(strings.TrimSpace(input))
+		if _, ok := state.Protocols[id]; !ok {
+			fmt.Printf(Red+"Protocol '%s' not found.%s\n", id, Reset)
+			return
+		}
+		name = fmt.Sprintf("%s-%d", id, block)
+		write = func(w io.Writer, f exportFormat) error { return exportProtocol(w, state, id, f) }
+	case "pool":
+		fmt.Print(Bold + "   Pool Address or Key (32-byte hex): " + Reset)
+		key := readAndParseKey(reader)
+		if key == nil {
+			return
+		}
+		name = fmt.Sprintf("pool-%x-%d", key[26:], block)
+		write = func(w io.Writer, f exportFormat) error { return exportPool(w, state, *key, f) }
+	default:
+		fmt.Println(Red + "Export state, protocol or pool." + Reset)
+		return
+	}
+
+	fmt.Print(Bold + "2. Format (json/csv, default json): " + Reset)
+	formatInput, _ := reader.ReadString('\n')
+	format := exportFormat(strings.ToLower(strings.TrimSpace(formatInput)))
+	switch format {
+	case "":
+		format = exportJSON
+	case exportJSON, exportCSV:
+	default:
+		fmt.Println(Red + "Format must be json or csv." + Reset)
+		return
+	}
+
+	path := name + "." + string(format)
+	fmt.Printf(Bold+"3. File (default %s): "+Reset, path)
+	if input, _ := reader.ReadString('\n'); strings.TrimSpace(input) != "" {
+		path = strings.TrimSpace(input)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		fmt.Println(Red + "[ERROR] " + err.Error() + Reset)
+		return
+	}
+	err = write(f, format)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		fmt.Println(Red + "[ERROR] " + err.Error() + Reset)
+		return
+	}
+	fmt.Printf(Green+"Exported block %d to %s%s\n", block, path, Reset)
+}
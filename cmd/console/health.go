@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	chaintypes "github.com/defistate/defistate-client-go/chains"
+	"github.com/defistate/defistate-client-go/chains/graphers"
+	"github.com/defistate/defistate-client-go/engine"
+	"github.com/defistate/defistate-client-go/protocols/pancakeswap"
+	poolregistry "github.com/defistate/defistate-client-go/protocols/poolregistry"
+	poolregistryindexer "github.com/defistate/defistate-client-go/protocols/poolregistry/indexer"
+	"github.com/defistate/defistate-client-go/protocols/solidly"
+	solidlyindexer "github.com/defistate/defistate-client-go/protocols/solidly/indexer"
+	tokenpoolregistry "github.com/defistate/defistate-client-go/protocols/tokenpoolregistry"
+	"github.com/defistate/defistate-client-go/protocols/tokenregistry"
+	tokenregistryindexer "github.com/defistate/defistate-client-go/protocols/tokenregistry/indexer"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	uniswapv2indexer "github.com/defistate/defistate-client-go/protocols/uniswapv2/indexer"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	uniswapv3indexer "github.com/defistate/defistate-client-go/protocols/uniswapv3/indexer"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv4"
+	uniswapv4calculator "github.com/defistate/defistate-client-go/protocols/uniswapv4/calculator"
+)
+
+// healthMaxStateAge is how old the latest state may be before the health check fails.
+const healthMaxStateAge = 2 * time.Minute
+
+type laggingProtocolView struct {
+	ID           engine.ProtocolID `json:"id"`
+	SyncedBlock  uint64            `json:"syncedBlock"`
+	BlocksBehind uint64            `json:"blocksBehind"`
+}
+
+type healthCheckView struct {
+	Pass               bool                          `json:"pass"`
+	Failures           []string                      `json:"failures,omitempty"`
+	Warnings           []string                      `json:"warnings,omitempty"`
+	Block              uint64                        `json:"block"`
+	StateAgeSeconds    float64                       `json:"stateAgeSeconds"`
+	Tokens             int                           `json:"tokens"`
+	Pools              int                           `json:"pools"`
+	RoutablePools      int                           `json:"routablePools"`
+	ErroredProtocols   []protocolSummaryView         `json:"erroredProtocols,omitempty"`
+	LaggingProtocols   []laggingProtocolView         `json:"laggingProtocols,omitempty"`
+	SkippedPools       map[string]int                `json:"skippedPools,omitempty"` // skip reason -> pool count
+	DecimalsMismatches []chaintypes.DecimalsMismatch `json:"decimalsMismatches,omitempty"`
+}
+
+// checkHealth aggregates the diagnostics of a state into a pass/fail summary: errored
+// and lagging protocols, the state's age at now, and the build report and consistency
+// check of the analytical graph. Pools skipped for routing are reported as warnings.
+func checkHealth(state *engine.State, now time.Time) healthCheckView {
+	var view healthCheckView
+	if state.Block.Number != nil {
+		view.Block = state.Block.Number.Uint64()
+	}
+
+	age := now.Sub(time.Unix(0, int64(state.Timestamp)))
+	view.StateAgeSeconds = age.Seconds()
+	if age > healthMaxStateAge {
+		view.Failures = append(view.Failures, fmt.Sprintf("state is %s old (max %s)", age.Round(time.Second), healthMaxStateAge))
+	}
+
+	for id, p := range state.Protocols {
+		if p.Error != "" {
+			view.ErroredProtocols = append(view.ErroredProtocols, protocolSummaryView{ID: id, Schema: p.Schema, Error: p.Error})
+		}
+		if p.SyncedBlockNumber != nil && *p.SyncedBlockNumber < view.Block {
+			view.LaggingProtocols = append(view.LaggingProtocols, laggingProtocolView{
+				ID:           id,
+				SyncedBlock:  *p.SyncedBlockNumber,
+				BlocksBehind: view.Block - *p.SyncedBlockNumber,
+			})
+		}
+	}
+	sort.Slice(view.ErroredProtocols, func(i, j int) bool { return view.ErroredProtocols[i].ID < view.ErroredProtocols[j].ID })
+	sort.Slice(view.LaggingProtocols, func(i, j int) bool { return view.LaggingProtocols[i].ID < view.LaggingProtocols[j].ID })
+	if n := len(view.ErroredProtocols); n > 0 {
+		view.Failures = append(view.Failures, fmt.Sprintf("%d protocols report errors", n))
+	}
+	if n := len(view.LaggingProtocols); n > 0 {
+		view.Failures = append(view.Failures, fmt.Sprintf("%d protocols are behind block %d", n, view.Block))
+	}
+
+	g, tokens, err := analyticalGraph(state)
+	if err != nil {
+		view.Failures = append(view.Failures, "graph: "+err.Error())
+	} else {
+		report := g.BuildReport()
+		view.Tokens = tokens
+		view.Pools = report.TotalPools
+		view.RoutablePools = report.RoutablePools
+		if len(report.Skipped) > 0 {
+			view.SkippedPools = make(map[string]int)
+			for _, skipped := range report.Skipped {
+				view.SkippedPools[string(skipped.Reason)]++
+			}
+			view.Warnings = append(view.Warnings, fmt.Sprintf("%d pools are skipped for routing", len(report.Skipped)))
+		}
+
+		view.DecimalsMismatches = g.ConsistencyCheck()
+		if n := len(view.DecimalsMismatches); n > 0 {
+			view.Failures = append(view.Failures, fmt.Sprintf("%d pools disagree with the token registry on decimals", n))
+		}
+	}
+
+	view.Pass = len(view.Failures) == 0
+	return view
+}
+
+// grapherOptions configures the graphers analyticalGraph builds with, e.g. the token
+// filters of the config; main sets it once the config is loaded.
+var grapherOptions []graphers.Option
+
+// analyticalGraph indexes the raw state and builds the analytical graph the client of
+// the state's chain routes on, returning it with the number of tokens in the token-pool
+// graph.
+func analyticalGraph(state *engine.State) (chaintypes.TokenPoolGraph, int, error) {
+	var (
+		rawGraph     *tokenpoolregistry.TokenPoolRegistryView
+		tokens       []tokenregistry.Token
+		poolRegistry *poolregistry.PoolRegistry
+		v2Pools      []uniswapv2.Pool
+		v3Pools      []uniswapv3.Pool
+		solidlyPools []solidly.Pool
+	)
+	schemas := make(map[engine.ProtocolID]engine.ProtocolSchema, len(state.Protocols))
+	for id, p := range state.Protocols {
+		schemas[id] = p.Schema
+		if p.Data == nil {
+			continue
+		}
+		var ok bool
+		switch p.Schema {
+		case tokenregistry.Schema:
+			tokens, ok = p.Data.([]tokenregistry.Token)
+		case poolregistry.Schema:
+			var registry poolregistry.PoolRegistry
+			registry, ok = p.Data.(poolregistry.PoolRegistry)
+			poolRegistry = &registry
+		case tokenpoolregistry.Schema:
+			rawGraph, ok = p.Data.(*tokenpoolregistry.TokenPoolRegistryView)
+		case uniswapv2.Schema, pancakeswap.V2Schema:
+			var pools []uniswapv2.Pool
+			pools, ok = p.Data.([]uniswapv2.Pool)
+			v2Pools = append(v2Pools, pools...)
+		case uniswapv3.Schema, pancakeswap.V3Schema:
+			var pools []uniswapv3.Pool
+			pools, ok = p.Data.([]uniswapv3.Pool)
+			v3Pools = append(v3Pools, pools...)
+		case uniswapv4.Schema:
+			var pools []uniswapv4.Pool
+			pools, ok = p.Data.([]uniswapv4.Pool)
+			v3Form, _ := uniswapv4calculator.V3Pools(pools)
+			v3Pools = append(v3Pools, v3Form...)
+		case solidly.Schema:
+			var pools []solidly.Pool
+			pools, ok = p.Data.([]solidly.Pool)
+			solidlyPools = append(solidlyPools, pools...)
+		default:
+			ok = true
+		}
+		if !ok {
+			return nil, 0, fmt.Errorf("protocol %s: bad data type %T", id, p.Data)
+		}
+	}
+	if rawGraph == nil {
+		return nil, 0, fmt.Errorf("token pool graph missing")
+	}
+	if poolRegistry == nil {
+		return nil, 0, fmt.Errorf("pool registry missing")
+	}
+
+	var indexedTokens tokenregistryindexer.IndexedTokenSystem
+	if tokens != nil {
+		indexedTokens = tokenregistryindexer.New().Index(tokens)
+	}
+	indexedPools := poolregistryindexer.New().Index(*poolRegistry)
+
+	tokenPoolGrapher, err := graphers.ForChainID(state.ChainID, grapherOptions...)
+	if err != nil {
+		return nil, 0, err
+	}
+	g, err := tokenPoolGrapher.Graph(
+		rawGraph,
+		indexedTokens,
+		indexedPools,
+		uniswapv2indexer.New().Index(v2Pools),
+		uniswapv3indexer.New().Index(v3Pools),
+		solidlyindexer.New().Index(solidlyPools),
+		chaintypes.NewProtocolResolver(schemas, indexedPools),
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	return g, len(rawGraph.Tokens), nil
+}
@@ -2,23 +2,25 @@ package main
 
 import (
 	"bufio"
-	"bytes"
 	"context"
 	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"log/slog"
 	"math/big"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
-	"text/tabwriter"
 	"time"
 
+	"github.com/defistate/defistate-client-go/addr"
+	chaintypes "github.com/defistate/defistate-client-go/chains"
 	"github.com/defistate/defistate-client-go/cmd/client/config"
 	"github.com/defistate/defistate-client-go/differ"
 	"github.com/defistate/defistate-client-go/engine"
@@ -27,14 +29,9 @@ import (
 
 	poolregistry "github.com/defistate/defistate-client-go/protocols/poolregistry"
 	"github.com/defistate/defistate-client-go/protocols/tokenregistry"
-	"github.com/defistate/defistate-client-go/protocols/uniswapv2"
-	"github.com/defistate/defistate-client-go/protocols/uniswapv3"
 	"github.com/defistate/defistate-client-go/streams/jsonrpc/client"
-	"github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains"
-	arbitrumstateops "github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains/arbitrum"
-	basestateops "github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains/base"
-	ethstateops "github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains/ethereum"
-	katanastateops "github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains/katana"
+	"github.com/defistate/defistate-client-go/streams/jsonrpc/stateops"
+	_ "github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains/all"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -55,13 +52,15 @@ const (
 
 // header prints a styled section header
 func header(title string) {
-	fmt.Println("\n" + Bold + Cyan + ":: " + title + " ::" + Reset)
+	writeHeader(os.Stdout, title)
 }
 
-// SafeState is a thread-safe container for the latest engine state.
+// SafeState is a thread-safe container for the latest engine state. It is also the
+// client's journal, so it holds the diff of the latest patched state.
 type SafeState struct {
 	mu    sync.RWMutex
 	state *engine.State
+	diff  *differ.StateDiff
 }
 
 func (s *SafeState) Update(newState *engine.State) {
@@ -76,11 +75,24 @@ func (s *SafeState) Get() *engine.State {
 	return s.state
 }
 
-type ChainStateOps interface {
-	Diff(old *engine.State, new *engine.State) (*differ.StateDiff, error)
-	Patch(oldState *engine.State, diff *differ.StateDiff) (*engine.State, error)
-	DecodeStateJSON(schema engine.ProtocolSchema, data json.RawMessage) (any, error)
-	DecodeStateDiffJSON(schema engine.ProtocolSchema, data json.RawMessage) (any, error)
+// Record keeps the diff a state was patched from; diff is nil for a full state. It
+// implements client.Journal.
+func (s *SafeState) Record(state *engine.State, diff *differ.StateDiff) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.diff = diff
+	return nil
+}
+
+// DiffFor returns the diff that produced the given block, or nil if that block came from
+// a full state or is no longer the latest recorded one.
+func (s *SafeState) DiffFor(block *big.Int) *differ.StateDiff {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.diff == nil || s.diff.ToBlock.Number == nil || block == nil || s.diff.ToBlock.Number.Cmp(block) != 0 {
+		return nil
+	}
+	return s.diff
 }
 
 func main() {
@@ -106,45 +118,29 @@ func main() {
 		rootLogger.Error("Failed to load configuration", "error", err)
 		closeApp()
 	}
+	grapherOptions = cfg.GrapherOptions()
+
+	var steps []execStep
+	if *execScript != "" {
+		if steps, err = parseExec(*execScript); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
 	// --- 3. INITIALIZE OPS ---
-	var chainStateOps ChainStateOps
-
-	switch cfg.ChainID.Uint64() {
-	case chains.Mainnet:
-		chainStateOps, err = ethstateops.NewStateOps(rootLogger, prometheusRegistry)
-		if err != nil {
-			rootLogger.Error("Failed to initialize Chain State Ops", "chain_id", cfg.ChainID, "error", err)
-			closeApp()
-		}
-	case chains.Arbitrum:
-		chainStateOps, err = arbitrumstateops.NewStateOps(rootLogger, prometheusRegistry)
-		if err != nil {
-			rootLogger.Error("Failed to initialize Chain State Ops", "chain_id", cfg.ChainID, "error", err)
-			closeApp()
-		}
-	case chains.Base:
-		chainStateOps, err = basestateops.NewStateOps(rootLogger, prometheusRegistry)
-		if err != nil {
-			rootLogger.Error("Failed to initialize Chain State Ops", "chain_id", cfg.ChainID, "error", err)
-			closeApp()
-		}
-	case chains.Katana:
-		chainStateOps, err = katanastateops.NewStateOps(rootLogger, prometheusRegistry)
-		if err != nil {
-			rootLogger.Error("Failed to initialize Chain State Ops", "chain_id", cfg.ChainID, "error", err)
-			closeApp()
-		}
-
-	default:
-		rootLogger.Error(fmt.Sprintf("Chain State Ops not found for chain with ID %d", cfg.ChainID.Uint64()))
+	chainStateOps, err := stateops.ForChainID(cfg.ChainID.Uint64(), rootLogger, prometheusRegistry, cfg.SchemaVersion)
+	if err != nil {
+		rootLogger.Error("Failed to initialize Chain State Ops", "chain_id", cfg.ChainID, "error", err)
 		closeApp()
 	}
+	chainStateOps.SetConcurrency(cfg.Concurrency)
 
 	// --- 4. INITIALIZE CLIENT ---
+	safeState := &SafeState{}
 	client, err := client.NewClient(
 		ctx,
 		client.Config{
@@ -154,6 +150,8 @@ func main() {
 			StatePatcher:     chainStateOps.Patch,
 			StateDecoder:     chainStateOps.DecodeStateJSON,
 			StateDiffDecoder: chainStateOps.DecodeStateDiffJSON,
+			Journal:          safeState,
+			Concurrency:      cfg.Concurrency,
 		},
 	)
 
@@ -163,7 +161,11 @@ func main() {
 	}
 
 	// --- 5. START CONSOLE & STATE LOOP ---
-	safeState := &SafeState{}
+	if steps != nil {
+		code := runExecOnFirstState(ctx, client.State(), client.Err(), steps, os.Stdout, os.Stderr)
+		stop()
+		os.Exit(code)
+	}
 
 	fmt.Println(Green + "Starting DeFi State Client..." + Reset)
 	fmt.Println("Logs are being written to 'client.log'")
@@ -188,6 +190,7 @@ func main() {
 // runConsole handles user input and display.
 func runConsole(ctx context.Context, safeState *SafeState) {
 	reader := bufio.NewReader(os.Stdin)
+	wait := newStateWait(time.Now, *stateWaitHint)
 	time.Sleep(500 * time.Millisecond)
 
 	for {
@@ -196,6 +199,10 @@ func runConsole(ctx context.Context, safeState *SafeState) {
 		}
 
 		printMenu()
+		if safeState.Get() == nil {
+			wait.writeStatus(os.Stdout)
+			fmt.Println("")
+		}
 
 		fmt.Print(Bold + "Enter selection: " + Reset)
 		input, err := reader.ReadString('\n')
@@ -205,7 +212,7 @@ func runConsole(ctx context.Context, safeState *SafeState) {
 		}
 		input = strings.TrimSpace(input)
 
-		handleCommand(input, safeState, reader)
+		handleCommand(input, safeState, reader, wait)
 
 		fmt.Println("\n" + Gray + "[Press Enter to continue]" + Reset)
 		reader.ReadString('\n')
@@ -220,20 +227,27 @@ func printMenu() {
 	fmt.Printf(" %s2.%s Protocol Summary\n", Cyan, Reset)
 	fmt.Printf(" %s3.%s Find Pool  %s(by Address/Key)%s\n", Cyan, Reset, Gray, Reset)
 	fmt.Printf(" %s4.%s Find Pools %s(by Token Address)%s\n", Cyan, Reset, Gray, Reset)
-	fmt.Printf(" %s5.%s Watch Pool %s(Live Monitor)%s\n", Cyan, Reset, Gray, Reset)
+	fmt.Printf(" %s5.%s Watch Pools %s(Live Monitor, comma-separated)%s\n", Cyan, Reset, Gray, Reset)
 	fmt.Printf(" %s6.%s Route      %s(Algo Router)%s\n", Cyan, Reset, Gray, Reset)
+	fmt.Printf(" %s7.%s Reachable  %s(Token Connectivity)%s\n", Cyan, Reset, Gray, Reset)
+	fmt.Printf(" %s8.%s Health     %s(State Self-Test)%s\n", Cyan, Reset, Gray, Reset)
+	fmt.Printf(" %s9.%s Price Alert %s(Live Crossing)%s\n", Cyan, Reset, Gray, Reset)
+	fmt.Printf(" %s10.%s Browse Pools %s(Sort & Search)%s\n", Cyan, Reset, Gray, Reset)
+	fmt.Printf(" %s11.%s Arb Scanner %s(Live Cycles)%s\n", Cyan, Reset, Gray, Reset)
+	fmt.Printf(" %s12.%s Export     %s(State/Protocol/Pool to JSON or CSV)%s\n", Cyan, Reset, Gray, Reset)
 	fmt.Println(Gray + "-----------------------------------" + Reset)
 	fmt.Printf(" %sh.%s Help / Architecture\n", Yellow, Reset)
 	fmt.Printf(" %sq.%s Quit\n", Red, Reset)
 	fmt.Println("")
 }
 
-func handleCommand(input string, safeState *SafeState, reader *bufio.Reader) {
+func handleCommand(input string, safeState *SafeState, reader *bufio.Reader, wait *stateWait) {
 	state := safeState.Get()
 
 	// Allow help and quit even if state isn't ready
 	if state == nil && input != "q" && input != "h" {
-		fmt.Println("\n" + Yellow + "[INFO] Waiting for first state update... (Check connection/logs)" + Reset)
+		fmt.Println("")
+		wait.writeStatus(os.Stdout)
 		return
 	}
 
@@ -247,9 +261,21 @@ func handleCommand(input string, safeState *SafeState, reader *bufio.Reader) {
 	case "4":
 		findPoolsByToken(state, reader)
 	case "5":
-		watchPool(safeState, reader)
+		watchPools(safeState, reader)
 	case "6":
 		findRoute(state, reader)
+	case "7":
+		showReachableTokens(state, reader)
+	case "8":
+		printHealthCheck(state)
+	case "9":
+		watchPriceAlert(safeState, reader)
+	case "10":
+		browsePools(state, reader)
+	case "11":
+		scanArbitrageLive(safeState, reader)
+	case "12":
+		exportData(state, reader)
 	case "h":
 		printHelp()
 	case "q":
@@ -308,42 +334,21 @@ func printHelp() {
 }
 
 func printBlockInfo(state *engine.State) {
-	ts := time.Unix(0, int64(state.Timestamp)).Format("15:04:05")
-
-	fmt.Printf("\n%sSTATUS  ::%s Block %s#%d%s | Chain %s%d%s | Time %s%s%s\n",
-		Green, Reset,
-		Bold, state.Block.Number, Reset,
-		Bold, state.ChainID, Reset,
-		Bold, ts, Reset,
-	)
+	if err := renderBlockInfo(os.Stdout, state, *jsonOutput); err != nil {
+		fmt.Println(Red + "[ERROR] " + err.Error() + Reset)
+	}
 }
 
 func printProtocolSummary(state *engine.State) {
-	header("PROTOCOL SUMMARY")
-
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
-	fmt.Fprintln(w, "PROTOCOL ID\tSCHEMA\tSTATUS\t")
-	fmt.Fprintln(w, "-----------\t------\t------\t")
-
-	errCount := 0
-	for id, p := range state.Protocols {
-		status := Green + "OK" + Reset
-		if p.Error != "" {
-			status = Red + "ERROR" + Reset
-			errCount++
-		}
-
-		// Truncate long IDs for display
-		pID := string(id)
-		if len(pID) > 25 {
-			pID = pID[:22] + "..."
-		}
-
-		fmt.Fprintf(w, "%s\t%s\t%s\t\n", pID, p.Schema, status)
+	if err := renderProtocolSummary(os.Stdout, state, *jsonOutput); err != nil {
+		fmt.Println(Red + "[ERROR] " + err.Error() + Reset)
 	}
-	w.Flush()
+}
 
-	fmt.Printf("\n%sProtocols with Errors: %d%s\n", Bold, errCount, Reset)
+func printHealthCheck(state *engine.State) {
+	if err := renderHealthCheck(os.Stdout, checkHealth(state, time.Now()), *jsonOutput); err != nil {
+		fmt.Println(Red + "[ERROR] " + err.Error() + Reset)
+	}
 }
 
 func findPool(state *engine.State, reader *bufio.Reader) {
@@ -357,64 +362,46 @@ func findPool(state *engine.State, reader *bufio.Reader) {
 }
 
 func findPoolsByToken(state *engine.State, reader *bufio.Reader) {
-	fmt.Print("\n" + Bold + "[Find Pools] Enter Token Address (Hex): " + Reset)
-	input, _ := reader.ReadString('\n')
-	input = strings.TrimPrefix(strings.TrimSpace(input), "0x")
-	if input == "" {
-		return
-	}
-
-	// 1. Parse Input
-	var searchAddrBytes []byte
-	var err error
-	searchAddrBytes, err = hex.DecodeString(input)
+	tokens, hasMetadata, err := tokenRegistry(state)
 	if err != nil {
-		fmt.Printf(Red+"[ERROR] Invalid hex format: %v%s\n", err, Reset)
+		fmt.Println(Red + "[ERROR] " + err.Error() + Reset)
 		return
 	}
 
-	// 2. Resolve Address -> TokenID (Token Registry)
-	tokenProto, ok := state.Protocols[engine.ProtocolID("token-system")]
-	if !ok {
-		fmt.Println(Red + "[ERROR] 'token-system' missing." + Reset)
-		return
+	if hasMetadata {
+		fmt.Print("\n" + Bold + "[Find Pools] Enter Token Address (Hex): " + Reset)
+	} else {
+		fmt.Println("\n" + Yellow + "[WARN] 'token-system' missing. Falling back to token IDs." + Reset)
+		fmt.Print(Bold + "[Find Pools] Enter Token ID: " + Reset)
 	}
-	// Cast to []tokenregistry.Token
-	tokens, ok := tokenProto.Data.([]tokenregistry.Token)
-	if !ok {
-		fmt.Printf(Red+"[ERROR] Bad Token Data Type: %T%s\n", tokenProto.Data, Reset)
+
+	// 1. Parse Input & Resolve -> TokenID (Token Registry)
+	searchToken, err := readAndValidateToken(state, reader)
+	if err != nil {
+		if err != errEmptyInput {
+			fmt.Println(Red + "[ERROR] " + err.Error() + Reset)
+		}
 		return
 	}
-
-	var searchTokenID uint64
-	var searchToken tokenregistry.Token
-	foundToken := false
+	searchTokenID := searchToken.ID
 
 	// Build a Symbol Map for fast lookup of Paired Tokens later
-	tokenSymbolMap := make(map[uint64]string)
-
-	for _, t := range tokens {
-		tokenSymbolMap[t.ID] = t.Symbol
-		if !foundToken && bytes.Equal(t.Address[:], searchAddrBytes) {
-			searchTokenID = t.ID
-			searchToken = t
-			foundToken = true
+	tokenSymbolMap := tokenSymbols(tokens)
+
+	// Print Detailed Token Info (JSON output carries the token with the pools)
+	if !*jsonOutput {
+		header("TOKEN DETAILS")
+		fmt.Printf(" %s%-10s%s %d\n", Gray, "ID:", Reset, searchToken.ID)
+		if hasMetadata {
+			fmt.Printf(" %s%-10s%s %s\n", Gray, "Symbol:", Reset, searchToken.Symbol)
+			fmt.Printf(" %s%-10s%s %s\n", Gray, "Name:", Reset, searchToken.Name)
+			fmt.Printf(" %s%-10s%s %d\n", Gray, "Decimals:", Reset, searchToken.Decimals)
+			fmt.Printf(" %s%-10s%s %s\n", Gray, "Address:", Reset, searchToken.Address.Checksum())
+		} else {
+			fmt.Printf(" %s%-10s%s %s\n", Gray, "Metadata:", Reset, "unavailable")
 		}
 	}
 
-	if !foundToken {
-		fmt.Println(Red + "[NOT FOUND] Token address not found in registry." + Reset)
-		return
-	}
-
-	// Print Detailed Token Info
-	header("TOKEN DETAILS")
-	fmt.Printf(" %s%-10s%s %d\n", Gray, "ID:", Reset, searchToken.ID)
-	fmt.Printf(" %s%-10s%s %s\n", Gray, "Symbol:", Reset, searchToken.Symbol)
-	fmt.Printf(" %s%-10s%s %s\n", Gray, "Name:", Reset, searchToken.Name)
-	fmt.Printf(" %s%-10s%s %d\n", Gray, "Decimals:", Reset, searchToken.Decimals)
-	fmt.Printf(" %s%-10s%s 0x%x\n", Gray, "Address:", Reset, searchToken.Address)
-
 	// 3. Query Graph: TokenID -> [PoolID: PairedTokenID]
 	graphProto, ok := state.Protocols[engine.ProtocolID("token-pool-graph-system")]
 	if !ok {
@@ -482,7 +469,9 @@ func findPoolsByToken(state *engine.State, reader *bufio.Reader) {
 		return
 	}
 
-	fmt.Printf("\nFound %d active pools. Resolving details...\n", len(poolPairs))
+	if !*jsonOutput {
+		fmt.Printf("\nFound %d active pools. Resolving details...\n", len(poolPairs))
+	}
 
 	// 4. Resolve PoolID -> Details (Pool Registry)
 	poolProto, ok := state.Protocols[engine.ProtocolID("pool-system")]
@@ -500,46 +489,40 @@ func findPoolsByToken(state *engine.State, reader *bufio.Reader) {
 		poolMap[p.ID] = p
 	}
 
-	// 5. Print Results
-	header(strings.ToUpper(fmt.Sprintf("POOLS FOR %s", searchToken.Symbol)))
-
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
-	fmt.Fprintln(w, "ID\tPROTOCOL\tPAIRED TOKEN\tPOOL ADDRESS\t")
-	fmt.Fprintln(w, "--\t--------\t------------\t------------\t")
-
+	rows := make([]tokenPoolView, 0, len(poolPairs))
 	for pID, pairedTokenID := range poolPairs {
+		row := tokenPoolView{
+			PoolID:        pID,
+			PairedTokenID: pairedTokenID,
+			// Resolve Paired Token Symbol (Using the ID we found in the Graph)
+			PairedSymbol: symbolFor(tokenSymbolMap, pairedTokenID),
+		}
 		if pool, exists := poolMap[pID]; exists {
-			// A. Resolve Protocol Name
-			protoName := "Unknown"
-			if name, ok := poolReg.Protocols[pool.Protocol]; ok {
-				protoName = string(name)
-				if len(protoName) > 22 {
-					protoName = protoName[:19] + "..."
-				}
-			}
-
-			// B. Resolve Paired Token Symbol (Using the ID we found in the Graph)
-			pairSymbol, ok := tokenSymbolMap[pairedTokenID]
-			if !ok {
-				pairSymbol = fmt.Sprintf("ID:%d", pairedTokenID)
-			}
-
-			// C. Address
+			row.ProtocolID = poolReg.Protocols[pool.Protocol]
 			poolAddr, _ := pool.Key.ToAddress()
-			addrStr := fmt.Sprintf("0x%x", poolAddr)
-
-			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t\n", pID, protoName, pairSymbol, addrStr)
+			row.Address = poolAddr.Checksum()
 		} else {
-			fmt.Fprintf(w, "%d\t%s???%s\t???\t<Missing>\t\n", pID, Red, Reset)
+			row.Missing = true
 		}
+		rows = append(rows, row)
+	}
+
+	// 5. Print Results
+	if err := renderTokenPools(os.Stdout, searchToken, rows, *jsonOutput); err != nil {
+		fmt.Println(Red + "[ERROR] " + err.Error() + Reset)
 	}
-	w.Flush()
 }
 
-func watchPool(safeState *SafeState, reader *bufio.Reader) {
-	fmt.Print("\n" + Bold + "[Watch Pool] Enter Pool Address or Key (32-byte hex): " + Reset)
-	key := readAndParseKey(reader)
-	if key == nil {
+// watchPools monitors one or more pools side by side, redrawing every block and
+// highlighting the fields that changed since the previous one.
+func watchPools(safeState *SafeState, reader *bufio.Reader) {
+	fmt.Print("\n" + Bold + "[Watch Pools] Enter Pool Addresses or Keys (comma-separated): " + Reset)
+	input, _ := reader.ReadString('\n')
+	keys, err := parsePoolKeys(input)
+	if err != nil {
+		if err != errEmptyInput {
+			fmt.Println(Red + "[ERROR] " + err.Error() + Reset)
+		}
 		return
 	}
 
@@ -556,6 +539,7 @@ func watchPool(safeState *SafeState, reader *bufio.Reader) {
 	defer ticker.Stop()
 
 	lastBlock := new(big.Int)
+	watcher := newPoolWatcher(keys)
 
 	for {
 		select {
@@ -569,12 +553,76 @@ func watchPool(safeState *SafeState, reader *bufio.Reader) {
 
 			if state.Block.Number.Cmp(lastBlock) > 0 {
 				lastBlock.Set(state.Block.Number)
+				view := watcher.observe(state, safeState.DiffFor(state.Block.Number))
+
+				if !*jsonOutput {
+					fmt.Print("\033[H\033[2J")
+					fmt.Println(Gray + "Press ENTER to return to menu." + Reset)
+				}
+				if err := renderWatchBlock(os.Stdout, view, *jsonOutput); err != nil {
+					fmt.Println(Red + "[ERROR] " + err.Error() + Reset)
+				}
+			}
+		}
+	}
+}
+
+// watchPriceAlert follows a pool's decimals-aware spot price block by block and alerts
+// when it crosses a target price in the chosen direction.
+func watchPriceAlert(safeState *SafeState, reader *bufio.Reader) {
+	fmt.Print("\n" + Bold + "[Price Alert] Enter Pool Address or Key (32-byte hex): " + Reset)
+	key := readAndParseKey(reader)
+	if key == nil {
+		return
+	}
+
+	fmt.Print(Bold + "Target Price (token1 per token0, e.g. 3000.5): " + Reset)
+	targetInput, _ := reader.ReadString('\n')
+	target, ok := new(big.Float).SetString(strings.TrimSpace(targetInput))
+	if !ok {
+		fmt.Println(Red + "Invalid price format." + Reset)
+		return
+	}
+	fmt.Print(Bold + "Alert when price crosses (above/below): " + Reset)
+	directionInput, _ := reader.ReadString('\n')
+	alert, err := newPriceAlert(target, crossDirection(strings.ToLower(strings.TrimSpace(directionInput))))
+	if err != nil {
+		fmt.Println(Red + "[ERROR] " + err.Error() + Reset)
+		return
+	}
+
+	fmt.Println(Green + "Watching price... (Press 'Enter' to stop)" + Reset)
+
+	stopCh := make(chan struct{})
+	go func() {
+		reader.ReadString('\n')
+		close(stopCh)
+	}()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	lastBlock := new(big.Int)
 
-				fmt.Print("\033[H\033[2J")
-				fmt.Printf(Bold+"\n--- LIVE MONITOR (Block: %s) ---\n"+Reset, state.Block.Number.String())
-				fmt.Println(Gray + "Press ENTER to return to menu." + Reset)
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			state := safeState.Get()
+			if state == nil || state.Block.Number == nil || state.Block.Number.Cmp(lastBlock) <= 0 {
+				continue
+			}
+			lastBlock.Set(state.Block.Number)
 
-				printPoolByKey(state, *key)
+			price, err := poolSpotPrice(state, *key)
+			if err != nil {
+				fmt.Println(Red + "[ERROR] " + err.Error() + Reset)
+				continue
+			}
+			crossed := alert.observe(price.Price)
+			if err := renderPriceTick(os.Stdout, state.Block.Number.Uint64(), price, alert, crossed, *jsonOutput); err != nil {
+				fmt.Println(Red + "[ERROR] " + err.Error() + Reset)
 			}
 		}
 	}
@@ -583,8 +631,19 @@ func watchPool(safeState *SafeState, reader *bufio.Reader) {
 func findRoute(state *engine.State, reader *bufio.Reader) {
 	header("ROUTE FINDER")
 
+	_, hasMetadata, err := tokenRegistry(state)
+	if err != nil {
+		fmt.Println(Red + "[ERROR] " + err.Error() + Reset)
+		return
+	}
+	tokenPrompt := "Address"
+	if !hasMetadata {
+		fmt.Println(Yellow + "[WARN] 'token-system' missing. Routing by token ID with raw amounts." + Reset)
+		tokenPrompt = "ID"
+	}
+
 	// 1. Input Token
-	fmt.Print(Bold + "1. Enter Input Token Address: " + Reset)
+	fmt.Print(Bold + "1. Enter Input Token " + tokenPrompt + ": " + Reset)
 	tokenIn, err := readAndValidateToken(state, reader)
 	if err != nil {
 		fmt.Println(Red + err.Error() + Reset)
@@ -593,7 +652,7 @@ func findRoute(state *engine.State, reader *bufio.Reader) {
 	fmt.Printf("%s   Selected Input: %s (%d decimals)%s\n", Green, tokenIn.Symbol, tokenIn.Decimals, Reset)
 
 	// 2. Output Token
-	fmt.Print(Bold + "2. Enter Output Token Address: " + Reset)
+	fmt.Print(Bold + "2. Enter Output Token " + tokenPrompt + ": " + Reset)
 	tokenOut, err := readAndValidateToken(state, reader)
 	if err != nil {
 		fmt.Println(Red + err.Error() + Reset)
@@ -617,158 +676,187 @@ func findRoute(state *engine.State, reader *bufio.Reader) {
 	rawAmount := new(big.Float).Mul(amountFloat, decimalsFloat)
 	rawInt, _ := rawAmount.Int(nil)
 
-	fmt.Printf("\nRouting %s %s (Raw: %s)... calculating best path...\n", amountInput, tokenIn.Symbol, rawInt.String())
+	if !*jsonOutput {
+		fmt.Printf("\nRouting %s %s (Raw: %s)... calculating best path...\n", amountInput, tokenIn.Symbol, rawInt.String())
+	}
 
-	// --- 4. GRAPH INITIALIZATION & ROUTING ---
+	if err := routeTokens(os.Stdout, state, tokenIn, tokenOut, rawInt, *jsonOutput); err != nil {
+		fmt.Println(Red + "[ERROR] " + err.Error() + Reset)
+	}
+}
+
+// routeTokens finds the best path for amountIn of tokenIn to tokenOut and writes it.
+// Warnings are written to w unless asJSON.
+func routeTokens(w io.Writer, state *engine.State, tokenIn, tokenOut *tokenregistry.Token, amountIn *big.Int, asJSON bool) error {
+	tokens, _, err := tokenRegistry(state)
+	if err != nil {
+		return err
+	}
+
+	// --- GRAPH INITIALIZATION & ROUTING ---
 
 	// A. Get Graph Data (for topology)
 	graphProto, ok := state.Protocols[engine.ProtocolID("token-pool-graph-system")]
 	if !ok {
-		fmt.Println(Red + "[ERROR] Graph protocol missing." + Reset)
-		return
+		return fmt.Errorf("graph protocol missing")
 	}
 	// Cast to correct type required by NewGraph (defined in graph package, likely poolregistry.TokenPoolRegistryView)
 	tokenPoolsView, ok := graphProto.Data.(*tokenpoolregistry.TokenPoolRegistryView)
 	if !ok {
-		fmt.Printf(Red+"[ERROR] Bad Graph Data Type: %T%s\n", graphProto.Data, Reset)
-		return
+		return fmt.Errorf("bad graph data type: %T", graphProto.Data)
 	}
 
 	// B. Get Pool Registry (for protocol lookups)
 	poolProto, ok := state.Protocols[engine.ProtocolID("pool-system")]
 	if !ok {
-		fmt.Println(Red + "[ERROR] Pool registry missing." + Reset)
-		return
+		return fmt.Errorf("pool registry missing")
 	}
 	poolRegView, ok := poolProto.Data.(poolregistry.PoolRegistry)
 	if !ok {
-		fmt.Println(Red + "[ERROR] Invalid pool registry type." + Reset)
-		return
-	}
-
-	tokenProto, ok := state.Protocols[engine.ProtocolID("token-system")]
-	if !ok {
-		fmt.Println("token-system missing")
-		return
-	}
-	tokens, ok := tokenProto.Data.([]tokenregistry.Token)
-	if !ok {
-		fmt.Println("bad token data")
-		return
+		return fmt.Errorf("invalid pool registry type")
 	}
 
 	// C. Create Graph Engine (Using imports provided)
 	g, err := graph.NewGraph(tokenPoolsView, tokens, poolRegView, state.Protocols)
 	if err != nil {
-		fmt.Printf(Red+"[ERROR] Failed to initialize graph: %v%s\n", err, Reset)
-		return
+		return fmt.Errorf("failed to initialize graph: %v", err)
+	}
+	if skipped := g.Skipped(); len(skipped) > 0 && !asJSON {
+		fmt.Fprintf(w, Yellow+"[WARN] %d pools skipped: missing from the registry or protocol state.%s\n", len(skipped), Reset)
 	}
 
 	// D. Run Algorithm (3 runs for Bellman-Ford variants is usually enough for 1-2 hops)
-	paths, amountOut, err := g.FindBestSwapPath(tokenIn.ID, tokenOut.ID, rawInt, 3)
+	paths, amountOut, err := g.FindBestSwapPath(tokenIn.ID, tokenOut.ID, amountIn, 3)
 	if err != nil {
-		fmt.Printf(Red+"[ERROR] Pathfinding failed: %v%s\n", err, Reset)
-		return
+		return fmt.Errorf("pathfinding failed: %v", err)
 	}
 
 	if len(paths) == 0 {
-		fmt.Println(Yellow + "No profitable path found." + Reset)
-		return
+		return fmt.Errorf("no profitable path found")
 	}
 
-	// 5. Output Result
-	printRouteResult(paths, amountOut, tokenIn, tokenOut, poolRegView, tokens)
+	// Output Result
+	quote, err := quoteRouteHops(state, paths, amountIn)
+	if err != nil && !asJSON {
+		fmt.Fprintf(w, Yellow+"[WARN] Per-hop quote unavailable: %v%s\n", err, Reset)
+	}
+	return renderRoute(w, paths, amountOut, quote, tokenIn, tokenOut, poolRegView, tokens, asJSON)
 }
 
-func printRouteResult(paths []graph.TokenPoolPath, amountOut *big.Int, tokenIn, tokenOut *tokenregistry.Token, poolReg poolregistry.PoolRegistry, allTokens []tokenregistry.Token) {
-	header("BEST ROUTE FOUND")
-
-	// Convert output amount to decimal format
-	decimals := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(tokenOut.Decimals)), nil)
-	outFloat := new(big.Float).SetInt(amountOut)
-	decFloat := new(big.Float).SetInt(decimals)
-	humanOut := new(big.Float).Quo(outFloat, decFloat)
+// quoteRouteHops quotes a route hop by hop on the analytical graph the chain clients use,
+// for the amounts, prices and price impact of every hop.
+func quoteRouteHops(state *engine.State, paths []graph.TokenPoolPath, amountIn *big.Int) (*chaintypes.RouteQuote, error) {
+	g, _, err := analyticalGraph(state)
+	if err != nil {
+		return nil, err
+	}
+	route := make([]chaintypes.TokenPoolPath, len(paths))
+	for i, p := range paths {
+		route[i] = chaintypes.TokenPoolPath{TokenInID: p.TokenInID, TokenOutID: p.TokenOutID, PoolID: p.PoolID}
+	}
+	return g.QuoteRoute(route, amountIn)
+}
 
-	fmt.Printf("%sEst. Output:%s %s %s (Raw: %s)\n\n", Bold, Reset, humanOut.Text('f', 4), tokenOut.Symbol, amountOut.String())
+func showReachableTokens(state *engine.State, reader *bufio.Reader) {
+	header("REACHABLE TOKENS")
 
-	// Build Token Symbol Map for intermediate lookups
-	// (Efficiency note: Ideally this map is passed in, but building it here is fine for a CLI command)
-	tokenMap := make(map[uint64]string)
-	for _, t := range allTokens {
-		tokenMap[t.ID] = t.Symbol
+	tokens, hasMetadata, err := tokenRegistry(state)
+	if err != nil {
+		fmt.Println(Red + "[ERROR] " + err.Error() + Reset)
+		return
+	}
+	tokenPrompt := "Address"
+	if !hasMetadata {
+		fmt.Println(Yellow + "[WARN] 'token-system' missing. Falling back to token IDs." + Reset)
+		tokenPrompt = "ID"
 	}
 
-	fmt.Println(Bold + "Route Path:" + Reset)
-	for i, p := range paths {
-		// Resolve Symbols
-		symIn := tokenMap[p.TokenInID]
-		symOut := tokenMap[p.TokenOutID]
-		if symIn == "" {
-			symIn = fmt.Sprintf("ID:%d", p.TokenInID)
-		}
-		if symOut == "" {
-			symOut = fmt.Sprintf("ID:%d", p.TokenOutID)
+	fmt.Print(Bold + "Enter Start Token " + tokenPrompt + ": " + Reset)
+	start, err := readAndValidateToken(state, reader)
+	if err != nil {
+		if err != errEmptyInput {
+			fmt.Println(Red + "[ERROR] " + err.Error() + Reset)
 		}
+		return
+	}
 
-		// Resolve Pool Info
-		poolDesc := "Unknown Pool"
-		poolAddr := "???"
-
-		// Linear lookup in pool registry (fast enough for CLI)
-		for _, pool := range poolReg.Pools {
-			if pool.ID == p.PoolID {
-				if name, ok := poolReg.Protocols[pool.Protocol]; ok {
-					poolDesc = string(name)
-					// Clean up protocol name for display
-					if len(poolDesc) > 20 {
-						poolDesc = poolDesc[:17] + "..."
-					}
-				}
-				addr, _ := pool.Key.ToAddress()
-				poolAddr = fmt.Sprintf("0x%x", addr)
-				break
-			}
-		}
+	graphProto, ok := state.Protocols[engine.ProtocolID("token-pool-graph-system")]
+	if !ok {
+		fmt.Println(Red + "[ERROR] 'token-pool-graph-system' missing." + Reset)
+		return
+	}
+	graphView, ok := graphProto.Data.(*tokenpoolregistry.TokenPoolRegistryView)
+	if !ok {
+		fmt.Printf(Red+"[ERROR] Bad Graph Data Type: %T%s\n", graphProto.Data, Reset)
+		return
+	}
 
-		// VISUAL DISPLAY
-		// Step N: [ Symbol In ]
-		//            |
-		//            +---[ Pool Info ]---> [ Symbol Out ]
-		fmt.Printf(" [ Step %d ]\n", i+1)
-		fmt.Printf("  %s%-6s%s\n", Cyan, symIn, Reset)
-		fmt.Printf("    %s|%s\n", Gray, Reset)
-		fmt.Printf("    %s+---[%s%s %s]--->%s  %s%-6s%s\n",
-			Gray,
-			Reset, poolDesc, poolAddr,
-			Reset,
-			Cyan, symOut, Reset)
-		fmt.Println("")
+	reachable, err := graph.ReachableTokens(graphView, start.ID)
+	if err != nil {
+		fmt.Println(Yellow + "[INFO] Token has no pools in the graph." + Reset)
+		return
+	}
+
+	if !*jsonOutput {
+		fmt.Printf("\n%d tokens reachable from %s.\n\n", len(reachable), start.Symbol)
+	}
+	if err := renderReachableTokens(os.Stdout, reachable, tokenSymbols(tokens), *jsonOutput); err != nil {
+		fmt.Println(Red + "[ERROR] " + err.Error() + Reset)
 	}
 }
 
+// errEmptyInput is returned by readAndValidateToken when the user enters nothing.
+var errEmptyInput = errors.New("empty input")
+
+// readAndValidateToken reads a token address or symbol and resolves it via the token
+// registry. If the registry is missing, it degrades to reading a numeric token ID and
+// returns a token with only the ID set and an "ID:<id>" symbol.
 func readAndValidateToken(state *engine.State, reader *bufio.Reader) (*tokenregistry.Token, error) {
 	input, _ := reader.ReadString('\n')
-	input = strings.TrimPrefix(strings.TrimSpace(input), "0x")
+	input = strings.TrimSpace(input)
 	if input == "" {
-		return nil, fmt.Errorf("empty input")
+		return nil, errEmptyInput
 	}
+	return resolveToken(state, input)
+}
 
-	addrBytes, err := hex.DecodeString(input)
+// resolveToken resolves a token address, or a symbol when it is unique, via the token
+// registry. If the registry is missing, it resolves a numeric token ID instead.
+func resolveToken(state *engine.State, input string) (*tokenregistry.Token, error) {
+	tokens, hasMetadata, err := tokenRegistry(state)
 	if err != nil {
-		return nil, fmt.Errorf("invalid hex: %v", err)
+		return nil, err
+	}
+	if !hasMetadata {
+		id, err := strconv.ParseUint(input, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("token-system missing: enter a numeric token ID")
+		}
+		return &tokenregistry.Token{ID: id, Symbol: fmt.Sprintf("ID:%d", id)}, nil
 	}
 
-	tokenProto, ok := state.Protocols[engine.ProtocolID("token-system")]
-	if !ok {
-		return nil, fmt.Errorf("token-system missing")
+	if !strings.HasPrefix(input, "0x") {
+		var match *tokenregistry.Token
+		for i := range tokens {
+			if strings.EqualFold(tokens[i].Symbol, input) {
+				if match != nil {
+					return nil, fmt.Errorf("symbol %s is ambiguous: enter the token address", input)
+				}
+				match = &tokens[i]
+			}
+		}
+		if match != nil {
+			return match, nil
+		}
 	}
-	tokens, ok := tokenProto.Data.([]tokenregistry.Token)
-	if !ok {
-		return nil, fmt.Errorf("bad token data")
+
+	address, err := addr.Parse(input)
+	if err != nil {
+		return nil, err
 	}
 
 	for _, t := range tokens {
-		if bytes.Equal(t.Address[:], addrBytes) {
+		if t.Address.Equal(address) {
 			return &t, nil
 		}
 	}
@@ -777,6 +865,37 @@ func readAndValidateToken(state *engine.State, reader *bufio.Reader) (*tokenregi
 
 // --- HELPERS ---
 
+// tokenRegistry returns the token registry from the state. The boolean is false when
+// the 'token-system' protocol is absent, in which case callers should degrade to IDs.
+func tokenRegistry(state *engine.State) ([]tokenregistry.Token, bool, error) {
+	tokenProto, ok := state.Protocols[engine.ProtocolID("token-system")]
+	if !ok {
+		return nil, false, nil
+	}
+	tokens, ok := tokenProto.Data.([]tokenregistry.Token)
+	if !ok {
+		return nil, false, fmt.Errorf("bad token data type: %T", tokenProto.Data)
+	}
+	return tokens, true, nil
+}
+
+// tokenSymbols maps token IDs to their symbols.
+func tokenSymbols(tokens []tokenregistry.Token) map[uint64]string {
+	symbols := make(map[uint64]string, len(tokens))
+	for _, t := range tokens {
+		symbols[t.ID] = t.Symbol
+	}
+	return symbols
+}
+
+// symbolFor resolves a token symbol, falling back to "ID:<id>" when it is unknown.
+func symbolFor(symbols map[uint64]string, id uint64) string {
+	if sym := symbols[id]; sym != "" {
+		return sym
+	}
+	return fmt.Sprintf("ID:%d", id)
+}
+
 func readAndParseKey(reader *bufio.Reader) *[32]byte {
 	input, _ := reader.ReadString('\n')
 	input = strings.TrimSpace(input)
@@ -809,93 +928,8 @@ func readAndParseKey(reader *bufio.Reader) *[32]byte {
 }
 
 func printPoolByKey(state *engine.State, searchKey [32]byte) {
-	protocolState, ok := state.Protocols[engine.ProtocolID("pool-system")]
-	if !ok {
-		fmt.Println(Red + "[ERROR] Protocol 'pool-system' not found." + Reset)
-		return
-	}
-
-	registry, ok := protocolState.Data.(poolregistry.PoolRegistry)
-	if !ok {
-		return
-	}
-
-	var foundPool *poolregistry.Pool
-	for i := range registry.Pools {
-		if registry.Pools[i].Key == searchKey {
-			foundPool = &registry.Pools[i]
-			break
-		}
-	}
-
-	if foundPool != nil {
-		header("POOL REGISTRY MATCH")
-		fmt.Printf("Registry ID:     %d\n", foundPool.ID)
-		fmt.Printf("Pool Key:        0x%x\n", hex.EncodeToString(foundPool.Key[:]))
-
-		if protocolID, exists := registry.Protocols[foundPool.Protocol]; exists {
-			fmt.Printf("Protocol:        %s%s%s (ID: %d)\n", Cyan, protocolID, Reset, foundPool.Protocol)
-			inspectProtocolData(state, protocolID, foundPool.ID)
-		} else {
-			fmt.Printf("Protocol:        %sUnknown%s (ID: %d)\n", Red, Reset, foundPool.Protocol)
-		}
-	} else {
-		fmt.Println(Red + "[NOT FOUND] Pool key not found in registry." + Reset)
-	}
-}
-
-func inspectProtocolData(state *engine.State, pID engine.ProtocolID, poolID uint64) {
-	pState, ok := state.Protocols[pID]
-	if !ok {
-		fmt.Printf(Yellow+"[WARN] Protocol state for '%s' is not loaded or empty.%s\n", pID, Reset)
-		return
-	}
-
-	printField := func(key string, value any) {
-		fmt.Printf("  %s%-15s%s %v\n", Gray, key+":", Reset, value)
-	}
-
-	switch pState.Schema {
-	case uniswapv2.Schema:
-		data := pState.Data.([]uniswapv2.Pool)
-		var pool *uniswapv2.Pool
-		for i := range data {
-			if data[i].ID == poolID {
-				pool = &data[i]
-				break
-			}
-		}
-
-		if pool != nil {
-			header(strings.ToUpper(string(pID) + " data"))
-			printField("Reserve0", pool.Reserve0)
-			printField("Reserve1", pool.Reserve1)
-		} else {
-			fmt.Printf(Yellow+"[WARN] Pool ID %d missing from V2 state.%s\n", poolID, Reset)
-		}
-
-	case uniswapv3.Schema:
-		data := pState.Data.([]uniswapv3.Pool)
-		var pool *uniswapv3.Pool
-		for i := range data {
-			if data[i].ID == poolID {
-				pool = &data[i]
-				break
-			}
-		}
-
-		if pool != nil {
-			header(strings.ToUpper(string(pID) + " data"))
-			printField("Liquidity", pool.Liquidity)
-			printField("SqrtPriceX96", pool.SqrtPriceX96)
-			printField("Current Tick", fmt.Sprintf("%s%d%s", Yellow, pool.Tick, Reset))
-			printField("Active Ticks", len(pool.Ticks))
-		} else {
-			fmt.Printf(Yellow+"[WARN] Pool ID %d missing from V3 state.%s\n", poolID, Reset)
-		}
-
-	default:
-		fmt.Printf(Gray+"[INFO] No inspector implemented for schema type: %s%s\n", pState.Schema, Reset)
+	if err := renderPoolMatch(os.Stdout, state, searchKey, *jsonOutput); err != nil {
+		fmt.Println(Red + "[ERROR] " + err.Error() + Reset)
 	}
 }
 
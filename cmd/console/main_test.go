@@ -0,0 +1,760 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/defistate/defistate-client-go/addr"
+	chaintypes "github.com/defistate/defistate-client-go/chains"
+	"github.com/defistate/defistate-client-go/differ"
+	"github.com/defistate/defistate-client-go/engine"
+	"github.com/defistate/defistate-client-go/examples/graph"
+	"github.com/defistate/defistate-client-go/protocols/pancakeswap"
+	poolregistry "github.com/defistate/defistate-client-go/protocols/poolregistry"
+	tokenpoolregistry "github.com/defistate/defistate-client-go/protocols/tokenpoolregistry"
+	"github.com/defistate/defistate-client-go/protocols/tokenregistry"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderReachableTokens(t *testing.T) {
+	// WETH(1) -[pool 100, 101]- USDC(2) -[pool 102]- DAI(3)
+	// WETH(1) -[pool 103]- AAVE(4)
+	// LINK(5) is isolated from WETH.
+	view := &tokenpoolregistry.TokenPoolRegistryView{
+		Tokens: []uint64{1, 2, 3, 4, 5},
+		Pools:  []uint64{100, 101, 102, 103},
+		Adjacency: [][]int{
+			{0, 2}, // WETH -> USDC, AAVE
+			{1, 3}, // USDC -> WETH, DAI
+			{4},    // DAI -> USDC
+			{5},    // AAVE -> WETH
+			{},     // LINK
+		},
+		EdgeTargets: []int{1, 0, 3, 2, 1, 0},
+		EdgePools:   [][]int{{0, 1}, {0, 1}, {3}, {2}, {2}, {3}},
+	}
+	symbols := map[uint64]string{1: "WETH", 2: "USDC", 3: "DAI", 4: "AAVE", 5: "LINK"}
+
+	reachable, err := graph.ReachableTokens(view, 1)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, renderReachableTokens(&out, reachable, symbols, false))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 5, "header, separator and three reachable tokens")
+	assert.Equal(t, []string{"AAVE", "4", "1", "1"}, strings.Fields(lines[2]))
+	assert.Equal(t, []string{"USDC", "2", "1", "3"}, strings.Fields(lines[3]))
+	assert.Equal(t, []string{"DAI", "3", "2", "1"}, strings.Fields(lines[4]))
+	assert.NotContains(t, out.String(), "LINK")
+
+	out.Reset()
+	require.NoError(t, renderReachableTokens(&out, reachable, symbols, true))
+	var decoded []reachableTokenView
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	require.Len(t, decoded, 3)
+	assert.Equal(t, reachableTokenView{TokenID: 4, Symbol: "AAVE", Hops: 1, PoolCount: 1}, decoded[0])
+
+	_, err = graph.ReachableTokens(view, 99)
+	assert.Error(t, err)
+}
+
+func TestRenderRoute(t *testing.T) {
+	tokens := []tokenregistry.Token{{ID: 1, Symbol: "WETH", Decimals: 18}, {ID: 2, Symbol: "USDC", Decimals: 6}}
+	paths := []graph.TokenPoolPath{{TokenInID: 1, TokenOutID: 2, PoolID: 100}}
+	amountOut := big.NewInt(2_991_000_000)
+	quote := &chaintypes.RouteQuote{
+		AmountIn:  big.NewInt(1e18),
+		AmountOut: amountOut,
+		Hops: []chaintypes.HopQuote{{
+			TokenPoolPath:         chaintypes.TokenPoolPath{TokenInID: 1, TokenOutID: 2, PoolID: 100},
+			AmountIn:              big.NewInt(1e18),
+			AmountOut:             amountOut,
+			SpotPriceBefore:       big.NewFloat(3000),
+			SpotPriceAfter:        big.NewFloat(2994),
+			PriceImpact:           big.NewFloat(0.003),
+			CumulativePriceImpact: big.NewFloat(0.003),
+		}},
+		PriceImpact: big.NewFloat(0.003),
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, renderRoute(&out, paths, amountOut, quote, &tokens[0], &tokens[1], poolregistry.PoolRegistry{}, tokens, false))
+	sections := strings.Split(out.String(), "Hop Quotes:"+Reset+"\n")
+	require.Len(t, sections, 2)
+	rows := strings.Split(sections[1], "\n")
+	assert.Equal(t, []string{"1", "1.0000", "WETH", "2991.0000", "USDC", "3000", "2994", "0.300%", "0.300%"}, strings.Fields(rows[1]))
+	assert.Contains(t, out.String(), "Total Price Impact:"+Reset+" 0.300%")
+
+	out.Reset()
+	require.NoError(t, renderRoute(&out, paths, amountOut, nil, &tokens[0], &tokens[1], poolregistry.PoolRegistry{}, tokens, false))
+	assert.NotContains(t, out.String(), "Hop Quotes:", "routes without a quote only show the path")
+
+	out.Reset()
+	require.NoError(t, renderRoute(&out, paths, amountOut, quote, &tokens[0], &tokens[1], poolregistry.PoolRegistry{}, tokens, true))
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, "0.003", decoded["priceImpact"])
+	hop := decoded["hops"].([]any)[0].(map[string]any)
+	assert.Equal(t, "3000", hop["spotPriceBefore"])
+	assert.Equal(t, float64(1e18), hop["amountIn"])
+}
+
+func TestRenderBlockInfo(t *testing.T) {
+	state := &engine.State{
+		ChainID:   1,
+		Timestamp: 1700000000000000000,
+		Block:     engine.BlockSummary{Number: big.NewInt(19000000)},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, renderBlockInfo(&out, state, true))
+	assert.JSONEq(t, `{"block": 19000000, "chainId": 1, "timestamp": 1700000000000000000}`, out.String())
+
+	out.Reset()
+	require.NoError(t, renderBlockInfo(&out, state, false))
+	assert.Contains(t, out.String(), "#19000000")
+}
+
+func TestRenderProtocolSummary(t *testing.T) {
+	state := &engine.State{
+		Protocols: map[engine.ProtocolID]engine.ProtocolState{
+			"uniswap-v3": {Schema: uniswapv3.Schema, Error: "rpc timeout"},
+			"uniswap-v2": {Schema: uniswapv2.Schema},
+		},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, renderProtocolSummary(&out, state, true))
+	assert.JSONEq(t, `[
+		{"id": "uniswap-v2", "schema": "`+string(uniswapv2.Schema)+`"},
+		{"id": "uniswap-v3", "schema": "`+string(uniswapv3.Schema)+`", "error": "rpc timeout"}
+	]`, out.String())
+
+	out.Reset()
+	require.NoError(t, renderProtocolSummary(&out, state, false))
+	assert.Contains(t, out.String(), "Protocols with Errors: 1")
+}
+
+func TestSymbolFor(t *testing.T) {
+	symbols := map[uint64]string{1: "WETH"}
+	assert.Equal(t, "WETH", symbolFor(symbols, 1))
+	assert.Equal(t, "ID:2", symbolFor(symbols, 2))
+}
+
+func healthTestState(now time.Time) *engine.State {
+	synced := uint64(19000000)
+	wethDecimals, usdcDecimals := uint8(18), uint8(6)
+	return &engine.State{
+		ChainID:   1,
+		Timestamp: uint64(now.UnixNano()),
+		Block:     engine.BlockSummary{Number: big.NewInt(19000000)},
+		Protocols: map[engine.ProtocolID]engine.ProtocolState{
+			"token-registry": {
+				Schema: tokenregistry.Schema,
+				Data: []tokenregistry.Token{
+					{ID: 1, Symbol: "WETH", Decimals: 18},
+					{ID: 2, Symbol: "USDC", Decimals: 6},
+				},
+			},
+			"pool-registry": {
+				Schema: poolregistry.Schema,
+				Data: poolregistry.PoolRegistry{
+					Pools:     []poolregistry.Pool{{ID: 100, Protocol: 1}},
+					Protocols: map[uint16]engine.ProtocolID{1: "uniswap-v2"},
+				},
+			},
+			"token-pool-registry": {
+				Schema: tokenpoolregistry.Schema,
+				Data: &tokenpoolregistry.TokenPoolRegistryView{
+					Tokens:      []uint64{1, 2},
+					Pools:       []uint64{100},
+					Adjacency:   [][]int{{0}, {1}},
+					EdgeTargets: []int{1, 0},
+					EdgePools:   [][]int{{0}, {0}},
+				},
+			},
+			"uniswap-v2": {
+				Schema:            uniswapv2.Schema,
+				SyncedBlockNumber: &synced,
+				Data: []uniswapv2.Pool{{
+					ID:        100,
+					Token0:    1,
+					Token1:    2,
+					Reserve0:  big.NewInt(1e18),
+					Reserve1:  big.NewInt(3000e6),
+					FeeBps:    30,
+					Decimals0: &wethDecimals,
+					Decimals1: &usdcDecimals,
+				}},
+			},
+		},
+	}
+}
+
+func TestCheckHealth(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	t.Run("healthy", func(t *testing.T) {
+		view := checkHealth(healthTestState(now), now.Add(10*time.Second))
+		assert.True(t, view.Pass, "failures: %v", view.Failures)
+		assert.Equal(t, uint64(19000000), view.Block)
+		assert.Equal(t, 2, view.Tokens)
+		assert.Equal(t, 1, view.Pools)
+		assert.Equal(t, 1, view.RoutablePools)
+		assert.Empty(t, view.ErroredProtocols)
+		assert.Empty(t, view.DecimalsMismatches)
+
+		var out bytes.Buffer
+		require.NoError(t, renderHealthCheck(&out, view, false))
+		assert.Contains(t, out.String(), "PASS")
+	})
+
+	t.Run("unhealthy", func(t *testing.T) {
+		state := healthTestState(now)
+		lagging := uint64(18999990)
+		state.Protocols["uniswap-v3"] = engine.ProtocolState{
+			Schema:            uniswapv3.Schema,
+			Error:             "rpc timeout",
+			SyncedBlockNumber: &lagging,
+		}
+		wrongDecimals := uint8(18)
+		state.Protocols["uniswap-v2"].Data.([]uniswapv2.Pool)[0].Decimals1 = &wrongDecimals
+
+		view := checkHealth(state, now.Add(10*time.Minute))
+		assert.False(t, view.Pass)
+		assert.Len(t, view.Failures, 4, "stale state, errored and lagging protocols, decimals mismatch")
+		require.Len(t, view.ErroredProtocols, 1)
+		assert.Equal(t, engine.ProtocolID("uniswap-v3"), view.ErroredProtocols[0].ID)
+		require.Len(t, view.LaggingProtocols, 1)
+		assert.Equal(t, uint64(10), view.LaggingProtocols[0].BlocksBehind)
+		require.Len(t, view.DecimalsMismatches, 1)
+		assert.Equal(t, uint64(2), view.DecimalsMismatches[0].TokenID)
+
+		var out bytes.Buffer
+		require.NoError(t, renderHealthCheck(&out, view, false))
+		assert.Contains(t, out.String(), "FAIL")
+		assert.Contains(t, out.String(), "rpc timeout")
+
+		out.Reset()
+		require.NoError(t, renderHealthCheck(&out, view, true))
+		var decoded healthCheckView
+		require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+		assert.False(t, decoded.Pass)
+	})
+
+	t.Run("bsc pancakeswap", func(t *testing.T) {
+		state := healthTestState(now)
+		state.ChainID = 56
+		protocol := state.Protocols["uniswap-v2"]
+		protocol.Schema = pancakeswap.V2Schema
+		state.Protocols["uniswap-v2"] = protocol
+
+		view := checkHealth(state, now.Add(10*time.Second))
+		assert.True(t, view.Pass, "failures: %v", view.Failures)
+		assert.Equal(t, 1, view.RoutablePools)
+		assert.Empty(t, view.SkippedPools)
+	})
+
+	t.Run("missing graph", func(t *testing.T) {
+		state := healthTestState(now)
+		delete(state.Protocols, "token-pool-registry")
+
+		view := checkHealth(state, now)
+		assert.False(t, view.Pass)
+		assert.Contains(t, view.Failures, "graph: token pool graph missing")
+	})
+}
+
+func TestPriceAlert(t *testing.T) {
+	series := func(prices ...float64) []*big.Float {
+		out := make([]*big.Float, len(prices))
+		for i, p := range prices {
+			out[i] = big.NewFloat(p)
+		}
+		return out
+	}
+	crossings := func(alert *priceAlert, prices []*big.Float) []int {
+		var at []int
+		for i, p := range prices {
+			if alert.observe(p) {
+				at = append(at, i)
+			}
+		}
+		return at
+	}
+
+	t.Run("above", func(t *testing.T) {
+		alert, err := newPriceAlert(big.NewFloat(3000), crossAbove)
+		require.NoError(t, err)
+		// Fires on reaching the target, stays quiet while above, re-arms after dropping back.
+		assert.Equal(t, []int{2, 6}, crossings(alert, series(2990, 2995, 3000, 3010, 3005, 2999, 3001)))
+	})
+
+	t.Run("below", func(t *testing.T) {
+		alert, err := newPriceAlert(big.NewFloat(3000), crossBelow)
+		require.NoError(t, err)
+		assert.Equal(t, []int{3}, crossings(alert, series(3010, 3005, 3001, 2950, 2900)))
+	})
+
+	t.Run("already past the target when the watch starts", func(t *testing.T) {
+		alert, err := newPriceAlert(big.NewFloat(3000), crossAbove)
+		require.NoError(t, err)
+		assert.Empty(t, crossings(alert, series(3100, 3200, 3300)))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := newPriceAlert(big.NewFloat(0), crossAbove)
+		assert.Error(t, err)
+		_, err = newPriceAlert(big.NewFloat(3000), "sideways")
+		assert.Error(t, err)
+	})
+}
+
+func TestPoolSpotPrice(t *testing.T) {
+	v2Key := poolregistry.AddressToPoolKey(addr.FromCommon(common.HexToAddress("0x100")))
+	v3Key := poolregistry.AddressToPoolKey(addr.FromCommon(common.HexToAddress("0x200")))
+	// sqrtPriceX96 of 3375.03 USDT per WETH for a WETH (18) / USDT (6) pool.
+	sqrtPriceX96, _ := new(big.Int).SetString("4602761997227095498465462", 10)
+	state := &engine.State{
+		Protocols: map[engine.ProtocolID]engine.ProtocolState{
+			"token-system": {
+				Schema: tokenregistry.Schema,
+				Data: []tokenregistry.Token{
+					{ID: 1, Symbol: "WETH", Decimals: 18},
+					{ID: 2, Symbol: "USDT", Decimals: 6},
+				},
+			},
+			"pool-system": {
+				Schema: poolregistry.Schema,
+				Data: poolregistry.PoolRegistry{
+					Pools: []poolregistry.Pool{
+						{ID: 100, Key: v2Key, Protocol: 1},
+						{ID: 200, Key: v3Key, Protocol: 2},
+					},
+					Protocols: map[uint16]engine.ProtocolID{1: "uniswap-v2", 2: "uniswap-v3"},
+				},
+			},
+			"uniswap-v2": {
+				Schema: uniswapv2.Schema,
+				Data: []uniswapv2.Pool{{
+					ID: 100, Token0: 1, Token1: 2,
+					Reserve0: new(big.Int).Mul(big.NewInt(10), big.NewInt(1e18)),
+					Reserve1: big.NewInt(30_000e6),
+				}},
+			},
+			"uniswap-v3": {
+				Schema: uniswapv3.Schema,
+				Data: []uniswapv3.Pool{{
+					PoolViewMinimal: uniswapv3.PoolViewMinimal{ID: 200, Token0: 1, Token1: 2, SqrtPriceX96: sqrtPriceX96},
+				}},
+			},
+		},
+	}
+
+	v2, err := poolSpotPrice(state, v2Key)
+	require.NoError(t, err)
+	f, _ := v2.Price.Float64()
+	assert.InDelta(t, 3000, f, 1e-9)
+	assert.Equal(t, "WETH", v2.Symbol0)
+	assert.Equal(t, "USDT", v2.Symbol1)
+
+	v3, err := poolSpotPrice(state, v3Key)
+	require.NoError(t, err)
+	f, _ = v3.Price.Float64()
+	assert.InDelta(t, 3375.031805, f, 1e-6)
+
+	_, err = poolSpotPrice(state, [32]byte{1})
+	assert.Error(t, err)
+
+	// A crossing is rendered as an alert.
+	alert, err := newPriceAlert(big.NewFloat(3100), crossAbove)
+	require.NoError(t, err)
+	alert.observe(v2.Price)
+	require.True(t, alert.observe(v3.Price))
+
+	var out bytes.Buffer
+	require.NoError(t, renderPriceTick(&out, 19000000, v3, alert, true, false))
+	assert.Contains(t, out.String(), "ALERT")
+	assert.Contains(t, out.String(), "above 3100")
+
+	out.Reset()
+	require.NoError(t, renderPriceTick(&out, 19000000, v3, alert, true, true))
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, true, decoded["crossed"])
+	assert.Equal(t, float64(200), decoded["poolId"])
+}
+
+func TestStateWait(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	wait := newStateWait(func() time.Time { return now }, 10*time.Second)
+
+	status := func() string {
+		var buf bytes.Buffer
+		wait.writeStatus(&buf)
+		return buf.String()
+	}
+
+	now = now.Add(3 * time.Second)
+	out := status()
+	assert.Contains(t, out, "Waiting for first state update... (3s elapsed)")
+	assert.NotContains(t, out, "[HINT]")
+	assert.Contains(t, out, "'h' and 'q'")
+	assert.False(t, wait.hintDue())
+
+	now = now.Add(7 * time.Second)
+	out = status()
+	assert.True(t, wait.hintDue())
+	assert.Contains(t, out, "(10s elapsed)")
+	assert.Contains(t, out, "[HINT] No state after 10s")
+
+	// The spinner advances with the clock.
+	now = now.Add(spinnerInterval)
+	assert.NotEqual(t, strings.Fields(out)[0], strings.Fields(status())[0])
+}
+
+func TestPoolBrowser(t *testing.T) {
+	rows, err := poolBrowseRows(healthTestState(time.Unix(1700000000, 0)))
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "WETH/USDC", rows[0].pair())
+	assert.Equal(t, engine.ProtocolID("uniswap-v2"), rows[0].ProtocolID)
+	require.True(t, rows[0].Priced, "USDC anchors the prices")
+	assert.InDelta(t, 6000, rows[0].TVL, 100, "1 WETH at ~3000 USDC plus 3000 USDC")
+
+	rows = nil
+	for id := uint64(1); id <= 45; id++ {
+		row := poolBrowseRow{PoolID: id, ProtocolID: "uniswap-v2", Token0: "WETH", Token1: "USDC", TVL: float64(id), Priced: true}
+		if id%3 == 0 {
+			row.ProtocolID, row.Token0 = "uniswap-v3", "WBTC"
+		}
+		rows = append(rows, row)
+	}
+	rows = append(rows, poolBrowseRow{PoolID: 46, ProtocolID: "aaa"})
+
+	page := browsePage(rows, sortByTVL, "", 1)
+	assert.Equal(t, 3, page.Pages)
+	assert.Equal(t, 46, page.Total)
+	require.Len(t, page.Pools, browsePageSize)
+	assert.Equal(t, uint64(45), page.Pools[0].PoolID, "highest TVL first")
+
+	page = browsePage(rows, sortByTVL, "", 9)
+	assert.Equal(t, 3, page.Page, "pages are clamped")
+	require.Len(t, page.Pools, 6)
+	assert.Equal(t, uint64(46), page.Pools[5].PoolID, "unpriced pools last")
+
+	page = browsePage(rows, sortByProtocol, "", 1)
+	assert.Equal(t, engine.ProtocolID("aaa"), page.Pools[0].ProtocolID)
+
+	page = browsePage(rows, sortByPair, "wbtusd", 1)
+	assert.Equal(t, 15, page.Total)
+	for _, row := range page.Pools {
+		assert.Equal(t, "WBTC/USDC", row.pair())
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, renderPoolPage(&out, page, false))
+	assert.Contains(t, out.String(), "WBTC/USDC")
+	out.Reset()
+	require.NoError(t, renderPoolPage(&out, page, true))
+	var decoded poolPageView
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Len(t, decoded.Pools, 15)
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	assert.True(t, fuzzyMatch("wtusd", "WETH/USDC"))
+	assert.True(t, fuzzyMatch("eth usdc", "WETH/USDC"))
+	assert.False(t, fuzzyMatch("usdweth", "WETH/USDC"))
+	assert.False(t, fuzzyMatch("dai", ""))
+}
+
+func TestPoolWatcher(t *testing.T) {
+	keyA, keyB := [32]byte{31: 0xa}, [32]byte{31: 0xb}
+	stateAt := func(block int64, reserveA, reserveB int64) *engine.State {
+		return &engine.State{
+			Block: engine.BlockSummary{Number: big.NewInt(block)},
+			Protocols: map[engine.ProtocolID]engine.ProtocolState{
+				"pool-system": {Schema: poolregistry.Schema, Data: poolregistry.PoolRegistry{
+					Pools:     []poolregistry.Pool{{ID: 1, Key: keyA}, {ID: 2, Key: keyB}},
+					Protocols: map[uint16]engine.ProtocolID{0: "uniswap-v2"},
+				}},
+				"uniswap-v2": {Schema: uniswapv2.Schema, Data: []uniswapv2.Pool{
+					{ID: 1, Reserve0: big.NewInt(reserveA), Reserve1: big.NewInt(1)},
+					{ID: 2, Reserve0: big.NewInt(reserveB), Reserve1: big.NewInt(1)},
+				}},
+			},
+		}
+	}
+
+	keys, err := parsePoolKeys(" 0x0a, 0x0b ,")
+	require.NoError(t, err)
+	assert.Equal(t, [][32]byte{keyA, keyB}, keys)
+	_, err = parsePoolKeys(" , ")
+	assert.ErrorIs(t, err, errEmptyInput)
+
+	watcher := newPoolWatcher(keys)
+	first := watcher.observe(stateAt(100, 10, 20), nil)
+	require.Len(t, first.Panes, 2)
+	assert.Equal(t, uint64(2), first.Panes[1].PoolID)
+	assert.Equal(t, []watchField{{Name: "Reserve0", Value: "10"}, {Name: "Reserve1", Value: "1"}}, first.Panes[0].Fields)
+
+	// The diff updates pool 1 only.
+	diff := &differ.StateDiff{
+		FromBlock: 100,
+		ToBlock:   engine.BlockSummary{Number: big.NewInt(101)},
+		Protocols: map[engine.ProtocolID]differ.ProtocolDiff{
+			"uniswap-v2": {Schema: uniswapv2.Schema, Data: uniswapv2.UniswapV2SystemDiff{
+				Updates: []uniswapv2.Pool{{ID: 1, Reserve0: big.NewInt(11), Reserve1: big.NewInt(1)}},
+			}},
+		},
+	}
+	next := watcher.observe(stateAt(101, 11, 20), diff)
+	assert.True(t, next.Panes[0].Updated)
+	assert.True(t, next.Panes[0].Fields[0].Changed)
+	assert.False(t, next.Panes[0].Fields[1].Changed)
+	assert.False(t, next.Panes[1].Updated)
+	assert.False(t, next.Panes[1].Fields[0].Changed)
+
+	var out bytes.Buffer
+	require.NoError(t, renderWatchBlock(&out, next, false))
+	assert.Contains(t, out.String(), "Pool 1 *")
+	assert.Contains(t, out.String(), Yellow+"11")
+
+	// Without a diff, as after a full state, every pool is compared.
+	full := watcher.observe(stateAt(102, 11, 21), nil)
+	assert.False(t, full.Panes[0].Fields[0].Changed)
+	assert.True(t, full.Panes[1].Fields[0].Changed)
+
+	out.Reset()
+	unknown, err := parsePoolKeys("0x0c")
+	require.NoError(t, err)
+	view := newPoolWatcher(unknown).observe(stateAt(103, 1, 1), nil)
+	require.NoError(t, renderWatchBlock(&out, view, true))
+	var decoded watchBlockView
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, "pool key not found in registry", decoded.Panes[0].Error)
+}
+
+func TestSafeStateDiffFor(t *testing.T) {
+	s := &SafeState{}
+	diff := &differ.StateDiff{ToBlock: engine.BlockSummary{Number: big.NewInt(101)}}
+	require.NoError(t, s.Record(nil, diff))
+	assert.Same(t, diff, s.DiffFor(big.NewInt(101)))
+	assert.Nil(t, s.DiffFor(big.NewInt(100)))
+	require.NoError(t, s.Record(nil, nil))
+	assert.Nil(t, s.DiffFor(big.NewInt(101)))
+}
+
+func TestScanArbitrage(t *testing.T) {
+	// Pool 100 sells WETH at 3000 USDC and pool 101 buys it at 3300 USDC.
+	state := healthTestState(time.Unix(1700000000, 0))
+	wethDecimals, usdcDecimals := uint8(18), uint8(6)
+	registry := state.Protocols["pool-registry"].Data.(poolregistry.PoolRegistry)
+	registry.Pools = append(registry.Pools, poolregistry.Pool{ID: 101, Protocol: 1})
+	state.Protocols["pool-registry"] = engine.ProtocolState{Schema: poolregistry.Schema, Data: registry}
+	state.Protocols["token-pool-registry"] = engine.ProtocolState{
+		Schema: tokenpoolregistry.Schema,
+		Data: &tokenpoolregistry.TokenPoolRegistryView{
+			Tokens:      []uint64{1, 2},
+			Pools:       []uint64{100, 101},
+			Adjacency:   [][]int{{0}, {1}},
+			EdgeTargets: []int{1, 0},
+			EdgePools:   [][]int{{0, 1}, {0, 1}},
+		},
+	}
+	pools := state.Protocols["uniswap-v2"].Data.([]uniswapv2.Pool)
+	state.Protocols["uniswap-v2"] = engine.ProtocolState{
+		Schema: uniswapv2.Schema,
+		Data: append(pools, uniswapv2.Pool{
+			ID:        101,
+			Token0:    1,
+			Token1:    2,
+			Reserve0:  big.NewInt(1e18),
+			Reserve1:  big.NewInt(3300e6),
+			FeeBps:    30,
+			Decimals0: &wethDecimals,
+			Decimals1: &usdcDecimals,
+		}),
+	}
+	usdc := &tokenregistry.Token{ID: 2, Symbol: "USDC", Decimals: 6}
+
+	t.Run("profitable cycle", func(t *testing.T) {
+		view, err := scanArbitrage(state, usdc, big.NewInt(10e6), big.NewInt(0))
+		require.NoError(t, err)
+		assert.Equal(t, uint64(19000000), view.Block)
+		require.NotEmpty(t, view.Cycles)
+
+		cycle := view.Cycles[0]
+		require.Len(t, cycle.Hops, 2)
+		assert.Equal(t, "USDC", cycle.Hops[0].TokenIn)
+		assert.Equal(t, "WETH", cycle.Hops[0].TokenOut)
+		assert.Equal(t, uint64(100), cycle.Hops[0].PoolID, "buys WETH where it is cheap")
+		assert.Equal(t, uint64(101), cycle.Hops[1].PoolID)
+		assert.Equal(t, engine.ProtocolID("uniswap-v2"), cycle.Hops[1].ProtocolID)
+		assert.Positive(t, cycle.Profit.Sign())
+		assert.Equal(t, 0, new(big.Int).Add(big.NewInt(10e6), cycle.Profit).Cmp(cycle.AmountOut))
+
+		var out bytes.Buffer
+		require.NoError(t, renderArbScan(&out, view, false))
+		assert.Contains(t, out.String(), "USDC -> WETH -> USDC")
+		assert.Contains(t, out.String(), "#19000000")
+	})
+
+	t.Run("min profit filter", func(t *testing.T) {
+		view, err := scanArbitrage(state, usdc, big.NewInt(10e6), big.NewInt(5e6))
+		require.NoError(t, err)
+		assert.Empty(t, view.Cycles)
+
+		var out bytes.Buffer
+		require.NoError(t, renderArbScan(&out, view, false))
+		assert.Contains(t, out.String(), "no profitable cycle")
+	})
+}
+
+func TestExport(t *testing.T) {
+	v2Key := poolregistry.AddressToPoolKey(addr.FromCommon(common.HexToAddress("0x100")))
+	v3Key := poolregistry.AddressToPoolKey(addr.FromCommon(common.HexToAddress("0x200")))
+	state := &engine.State{
+		ChainID: 1,
+		Block:   engine.BlockSummary{Number: big.NewInt(19000000)},
+		Protocols: map[engine.ProtocolID]engine.ProtocolState{
+			"token-system": {
+				Schema: tokenregistry.Schema,
+				Data:   []tokenregistry.Token{{ID: 1, Symbol: "WETH", Decimals: 18}},
+			},
+			"pool-system": {
+				Schema: poolregistry.Schema,
+				Data: poolregistry.PoolRegistry{
+					Pools: []poolregistry.Pool{
+						{ID: 100, Key: v2Key, Protocol: 1},
+						{ID: 200, Key: v3Key, Protocol: 2},
+					},
+					Protocols: map[uint16]engine.ProtocolID{1: "uniswap-v2", 2: "uniswap-v3"},
+				},
+			},
+			"uniswap-v2": {
+				Schema: uniswapv2.Schema,
+				Data: []uniswapv2.Pool{{
+					ID: 100, Token0: 1, Token1: 2, Reserve0: big.NewInt(10), Reserve1: big.NewInt(30), FeeBps: 30,
+				}},
+			},
+			"uniswap-v3": {
+				Schema: uniswapv3.Schema,
+				Data: []uniswapv3.Pool{{
+					PoolViewMinimal: uniswapv3.PoolViewMinimal{
+						ID: 200, Token0: 1, Token1: 2, Fee: 500, Tick: -5,
+						Liquidity: big.NewInt(7), SqrtPriceX96: big.NewInt(9),
+					},
+					Ticks: []uniswapv3.TickInfo{
+						{Index: -60, LiquidityGross: big.NewInt(7), LiquidityNet: big.NewInt(7)},
+						{Index: 60, LiquidityGross: big.NewInt(7), LiquidityNet: big.NewInt(-7)},
+					},
+				}},
+			},
+		},
+	}
+
+	t.Run("state", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, exportState(&out, state, exportJSON))
+		var decoded map[string]any
+		require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+		assert.Len(t, decoded["protocols"], 4)
+
+		out.Reset()
+		require.NoError(t, exportState(&out, state, exportCSV))
+		assert.Equal(t, strings.Join([]string{
+			"protocol,schema,pool_id,token0,token1,reserve0,reserve1,fee,tick,liquidity,sqrt_price_x96,ticks",
+			"uniswap-v2," + string(uniswapv2.Schema) + ",100,1,2,10,30,30,,,,",
+			"uniswap-v3," + string(uniswapv3.Schema) + ",200,1,2,,,500,-5,7,9,2",
+		}, "\n")+"\n", out.String())
+	})
+
+	t.Run("protocol", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, exportProtocol(&out, state, "pool-system", exportCSV))
+		assert.Contains(t, out.String(), "id,key,protocol\n100,0x")
+		assert.Contains(t, out.String(), ",uniswap-v3\n")
+
+		out.Reset()
+		require.NoError(t, exportProtocol(&out, state, "token-system", exportCSV))
+		assert.Contains(t, out.String(), "\n1,0x0000000000000000000000000000000000000000,WETH,,18,0,0\n")
+
+		assert.Error(t, exportProtocol(&out, state, "missing", exportJSON))
+	})
+
+	t.Run("pool", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, exportPool(&out, state, v3Key, exportCSV))
+		assert.Equal(t, "pool_id,tick,liquidity_gross,liquidity_net\n200,-60,7,7\n200,60,7,-7\n", out.String())
+
+		out.Reset()
+		require.NoError(t, exportPool(&out, state, v2Key, exportCSV))
+		assert.Contains(t, out.String(), "\nuniswap-v2,"+string(uniswapv2.Schema)+",100,")
+
+		out.Reset()
+		require.NoError(t, exportPool(&out, state, v3Key, exportJSON))
+		var decoded poolMatchView
+		require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+		assert.Equal(t, uint64(200), decoded.ID)
+		assert.Equal(t, engine.ProtocolID("uniswap-v3"), decoded.ProtocolID)
+		assert.Len(t, decoded.Data.(map[string]any)["ticks"], 2)
+
+		assert.Error(t, exportPool(&out, state, [32]byte{1}, exportCSV))
+	})
+}
+
+func TestExec(t *testing.T) {
+	t.Run("parse", func(t *testing.T) {
+		steps, err := parseExec("route WETH USDC 1.5; summary;")
+		require.NoError(t, err)
+		assert.Equal(t, []execStep{{name: "route", args: []string{"WETH", "USDC", "1.5"}}, {name: "summary", args: []string{}}}, steps)
+
+		_, err = parseExec("route WETH USDC")
+		assert.ErrorContains(t, err, "usage: route <tokenIn> <tokenOut> <amount>")
+		_, err = parseExec("swap WETH")
+		assert.ErrorContains(t, err, `unknown command "swap"`)
+		_, err = parseExec(" ; ")
+		assert.Error(t, err)
+	})
+
+	t.Run("run", func(t *testing.T) {
+		state := healthTestState(time.Unix(1700000000, 0))
+		for from, to := range map[engine.ProtocolID]engine.ProtocolID{
+			"token-registry":      "token-system",
+			"pool-registry":       "pool-system",
+			"token-pool-registry": "token-pool-graph-system",
+		} {
+			state.Protocols[to] = state.Protocols[from]
+			delete(state.Protocols, from)
+		}
+
+		steps, err := parseExec("route weth USDC 0.001")
+		require.NoError(t, err)
+		var out bytes.Buffer
+		require.NoError(t, runExec(&out, state, steps, true))
+		var route routeView
+		require.NoError(t, json.Unmarshal(out.Bytes(), &route))
+		assert.Equal(t, uint64(1), route.TokenInID)
+		assert.Equal(t, uint64(2), route.TokenOutID)
+		require.Len(t, route.Hops, 1)
+		assert.Equal(t, uint64(100), route.Hops[0].PoolID)
+
+		steps, err = parseExec("block; route DAI USDC 1")
+		require.NoError(t, err)
+		out.Reset()
+		err = runExec(&out, state, steps, false)
+		assert.ErrorContains(t, err, "route: tokenIn")
+		assert.Contains(t, out.String(), "19000000", "steps before the failing one ran")
+	})
+}
@@ -0,0 +1,592 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	chaintypes "github.com/defistate/defistate-client-go/chains"
+	"github.com/defistate/defistate-client-go/engine"
+	"github.com/defistate/defistate-client-go/examples/graph"
+	poolregistry "github.com/defistate/defistate-client-go/protocols/poolregistry"
+	"github.com/defistate/defistate-client-go/protocols/tokenregistry"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv4"
+)
+
+// --- RENDERERS ---
+//
+// Every command writes its result through a renderer that takes an io.Writer and
+// an asJSON switch, so results can be scripted with -json and tested directly.
+
+// jsonOutput switches every command's result to JSON. Prompts and errors stay as text.
+var jsonOutput = flag.Bool("json", false, "Print command results as JSON for scripting.")
+
+// writeHeader writes a styled section header.
+func writeHeader(w io.Writer, title string) {
+	fmt.Fprintln(w, "\n"+Bold+Cyan+":: "+title+" ::"+Reset)
+}
+
+// writeJSON writes v as indented JSON.
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+type blockInfoView struct {
+	Block     *big.Int `json:"block"`
+	ChainID   uint64   `json:"chainId"`
+	Timestamp uint64   `json:"timestamp"`
+}
+
+func renderBlockInfo(w io.Writer, state *engine.State, asJSON bool) error {
+	if asJSON {
+		return writeJSON(w, blockInfoView{
+			Block:     state.Block.Number,
+			ChainID:   state.ChainID,
+			Timestamp: state.Timestamp,
+		})
+	}
+
+	ts := time.Unix(0, int64(state.Timestamp)).Format("15:04:05")
+	_, err := fmt.Fprintf(w, "\n%sSTATUS  ::%s Block %s#%d%s | Chain %s%d%s | Time %s%s%s\n",
+		Green, Reset,
+		Bold, state.Block.Number, Reset,
+		Bold, state.ChainID, Reset,
+		Bold, ts, Reset,
+	)
+	return err
+}
+
+type protocolSummaryView struct {
+	ID     engine.ProtocolID     `json:"id"`
+	Schema engine.ProtocolSchema `json:"schema"`
+	Error  string                `json:"error,omitempty"`
+}
+
+// renderProtocolSummary lists the protocols of the state, ordered by protocol ID.
+func renderProtocolSummary(w io.Writer, state *engine.State, asJSON bool) error {
+	summary := make([]protocolSummaryView, 0, len(state.Protocols))
+	for id, p := range state.Protocols {
+		summary = append(summary, protocolSummaryView{ID: id, Schema: p.Schema, Error: p.Error})
+	}
+	sort.Slice(summary, func(i, j int) bool { return summary[i].ID < summary[j].ID })
+
+	if asJSON {
+		return writeJSON(w, summary)
+	}
+
+	writeHeader(w, "PROTOCOL SUMMARY")
+
+	tw := tabwriter.NewWriter(w, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(tw, "PROTOCOL ID\tSCHEMA\tSTATUS\t")
+	fmt.Fprintln(tw, "-----------\t------\t------\t")
+
+	errCount := 0
+	for _, p := range summary {
+		status := Green + "OK" + Reset
+		if p.Error != "" {
+			status = Red + "ERROR" + Reset
+			errCount++
+		}
+
+		// Truncate long IDs for display
+		pID := string(p.ID)
+		if len(pID) > 25 {
+			pID = pID[:22] + "..."
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t\n", pID, p.Schema, status)
+	}
+	tw.Flush()
+
+	_, err := fmt.Fprintf(w, "\n%sProtocols with Errors: %d%s\n", Bold, errCount, Reset)
+	return err
+}
+
+type poolMatchView struct {
+	Found         bool                  `json:"found"`
+	ID            uint64                `json:"id,omitempty"`
+	Key           string                `json:"key,omitempty"`
+	ProtocolIndex uint16                `json:"protocolIndex,omitempty"`
+	ProtocolID    engine.ProtocolID     `json:"protocolId,omitempty"`
+	Schema        engine.ProtocolSchema `json:"schema,omitempty"`
+	// Data is the protocol-specific pool state, when an inspector exists for the schema.
+	Data any `json:"data,omitempty"`
+}
+
+// renderPoolMatch looks up a pool key in the pool registry and renders the registry
+// entry together with the pool's protocol state.
+func renderPoolMatch(w io.Writer, state *engine.State, searchKey [32]byte, asJSON bool) error {
+	protocolState, ok := state.Protocols[engine.ProtocolID("pool-system")]
+	if !ok {
+		return fmt.Errorf("protocol 'pool-system' not found")
+	}
+
+	registry, ok := protocolState.Data.(poolregistry.PoolRegistry)
+	if !ok {
+		return fmt.Errorf("bad pool registry data type: %T", protocolState.Data)
+	}
+
+	var foundPool *poolregistry.Pool
+	for i := range registry.Pools {
+		if registry.Pools[i].Key == searchKey {
+			foundPool = &registry.Pools[i]
+			break
+		}
+	}
+
+	if foundPool == nil {
+		if asJSON {
+			return writeJSON(w, poolMatchView{Found: false})
+		}
+		_, err := fmt.Fprintln(w, Red+"[NOT FOUND] Pool key not found in registry."+Reset)
+		return err
+	}
+
+	view := poolMatchView{
+		Found:         true,
+		ID:            foundPool.ID,
+		Key:           "0x" + hex.EncodeToString(foundPool.Key[:]),
+		ProtocolIndex: foundPool.Protocol,
+	}
+	protocolID, known := registry.Protocols[foundPool.Protocol]
+	if known {
+		view.ProtocolID = protocolID
+		if pState, ok := state.Protocols[protocolID]; ok {
+			view.Schema = pState.Schema
+			view.Data = protocolPoolData(pState, foundPool.ID)
+		}
+	}
+
+	if asJSON {
+		return writeJSON(w, view)
+	}
+
+	writeHeader(w, "POOL REGISTRY MATCH")
+	fmt.Fprintf(w, "Registry ID:     %d\n", view.ID)
+	fmt.Fprintf(w, "Pool Key:        %s\n", view.Key)
+
+	if !known {
+		_, err := fmt.Fprintf(w, "Protocol:        %sUnknown%s (ID: %d)\n", Red, Reset, view.ProtocolIndex)
+		return err
+	}
+	fmt.Fprintf(w, "Protocol:        %s%s%s (ID: %d)\n", Cyan, protocolID, Reset, view.ProtocolIndex)
+	renderProtocolData(w, state, protocolID, view.ID, view.Data)
+	return nil
+}
+
+// protocolPoolData returns the protocol-specific state of a pool, or nil if the pool
+// is missing or the schema has no inspector.
+func protocolPoolData(pState engine.ProtocolState, poolID uint64) any {
+	switch pState.Schema {
+	case uniswapv2.Schema:
+		data, _ := pState.Data.([]uniswapv2.Pool)
+		for i := range data {
+			if data[i].ID == poolID {
+				return &data[i]
+			}
+		}
+	case uniswapv3.Schema:
+		data, _ := pState.Data.([]uniswapv3.Pool)
+		for i := range data {
+			if data[i].ID == poolID {
+				return &data[i]
+			}
+		}
+	case uniswapv4.Schema:
+		data, _ := pState.Data.([]uniswapv4.Pool)
+		for i := range data {
+			if data[i].ID == poolID {
+				return &data[i]
+			}
+		}
+	}
+	return nil
+}
+
+// renderProtocolData writes the human-readable view of a pool's protocol state.
+func renderProtocolData(w io.Writer, state *engine.State, pID engine.ProtocolID, poolID uint64, data any) {
+	pState, ok := state.Protocols[pID]
+	if !ok {
+		fmt.Fprintf(w, Yellow+"[WARN] Protocol state for '%s' is not loaded or empty.%s\n", pID, Reset)
+		return
+	}
+
+	printField := func(key string, value any) {
+		fmt.Fprintf(w, "  %s%-15s%s %v\n", Gray, key+":", Reset, value)
+	}
+
+	switch pool := data.(type) {
+	case *uniswapv2.Pool:
+		writeHeader(w, strings.ToUpper(string(pID)+" data"))
+		printField("Reserve0", pool.Reserve0)
+		printField("Reserve1", pool.Reserve1)
+	case *uniswapv3.Pool:
+		writeHeader(w, strings.ToUpper(string(pID)+" data"))
+		printField("Liquidity", pool.Liquidity)
+		printField("SqrtPriceX96", pool.SqrtPriceX96)
+		printField("Current Tick", fmt.Sprintf("%s%d%s", Yellow, pool.Tick, Reset))
+		printField("Active Ticks", len(pool.Ticks))
+	case *uniswapv4.Pool:
+		writeHeader(w, strings.ToUpper(string(pID)+" data"))
+		printField("Pool ID", pool.PoolID)
+		printField("Hooks", pool.Hooks)
+		if fee, ok := pool.SwapFee(); ok {
+			printField("Swap Fee", fee)
+		} else {
+			printField("Swap Fee", Yellow+"dynamic, unknown"+Reset)
+		}
+		printField("Liquidity", pool.Liquidity)
+		printField("SqrtPriceX96", pool.SqrtPriceX96)
+		printField("Current Tick", fmt.Sprintf("%s%d%s", Yellow, pool.Tick, Reset))
+		printField("Active Ticks", len(pool.Ticks))
+	default:
+		switch pState.Schema {
+		case uniswapv2.Schema:
+			fmt.Fprintf(w, Yellow+"[WARN] Pool ID %d missing from V2 state.%s\n", poolID, Reset)
+		case uniswapv3.Schema:
+			fmt.Fprintf(w, Yellow+"[WARN] Pool ID %d missing from V3 state.%s\n", poolID, Reset)
+		case uniswapv4.Schema:
+			fmt.Fprintf(w, Yellow+"[WARN] Pool ID %d missing from V4 state.%s\n", poolID, Reset)
+		default:
+			fmt.Fprintf(w, Gray+"[INFO] No inspector implemented for schema type: %s%s\n", pState.Schema, Reset)
+		}
+	}
+}
+
+type tokenPoolView struct {
+	PoolID        uint64            `json:"poolId"`
+	ProtocolID    engine.ProtocolID `json:"protocolId,omitempty"`
+	PairedTokenID uint64            `json:"pairedTokenId"`
+	PairedSymbol  string            `json:"pairedSymbol"`
+	Address       string            `json:"address,omitempty"`
+	Missing       bool              `json:"missing,omitempty"`
+}
+
+type tokenPoolsView struct {
+	Token *tokenregistry.Token `json:"token"`
+	Pools []tokenPoolView      `json:"pools"`
+}
+
+// renderTokenPools lists the pools holding a token, ordered by pool ID.
+func renderTokenPools(w io.Writer, token *tokenregistry.Token, pools []tokenPoolView, asJSON bool) error {
+	sorted := make([]tokenPoolView, len(pools))
+	copy(sorted, pools)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].PoolID < sorted[j].PoolID })
+
+	if asJSON {
+		return writeJSON(w, tokenPoolsView{Token: token, Pools: sorted})
+	}
+
+	writeHeader(w, strings.ToUpper(fmt.Sprintf("POOLS FOR %s", token.Symbol)))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(tw, "ID\tPROTOCOL\tPAIRED TOKEN\tPOOL ADDRESS\t")
+	fmt.Fprintln(tw, "--\t--------\t------------\t------------\t")
+
+	for _, p := range sorted {
+		if p.Missing {
+			fmt.Fprintf(tw, "%d\t%s???%s\t???\t<Missing>\t\n", p.PoolID, Red, Reset)
+			continue
+		}
+
+		protoName := "Unknown"
+		if p.ProtocolID != "" {
+			protoName = string(p.ProtocolID)
+			if len(protoName) > 22 {
+				protoName = protoName[:19] + "..."
+			}
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t\n", p.PoolID, protoName, p.PairedSymbol, p.Address)
+	}
+	return tw.Flush()
+}
+
+type routeHopView struct {
+	TokenInID  uint64            `json:"tokenInId"`
+	TokenIn    string            `json:"tokenIn"`
+	TokenOutID uint64            `json:"tokenOutId"`
+	TokenOut   string            `json:"tokenOut"`
+	PoolID     uint64            `json:"poolId"`
+	ProtocolID engine.ProtocolID `json:"protocolId,omitempty"`
+	Address    string            `json:"address,omitempty"`
+
+	// Per-hop quote, omitted when the route could not be quoted hop by hop.
+	AmountIn              *big.Int   `json:"amountIn,omitempty"`
+	AmountOut             *big.Int   `json:"amountOut,omitempty"`
+	SpotPriceBefore       *big.Float `json:"spotPriceBefore,omitempty"`
+	SpotPriceAfter        *big.Float `json:"spotPriceAfter,omitempty"`
+	PriceImpact           *big.Float `json:"priceImpact,omitempty"`
+	CumulativePriceImpact *big.Float `json:"cumulativePriceImpact,omitempty"`
+}
+
+type routeView struct {
+	TokenInID    uint64         `json:"tokenInId"`
+	TokenOutID   uint64         `json:"tokenOutId"`
+	AmountOut    *big.Int       `json:"amountOut"`
+	AmountOutFmt string         `json:"amountOutFormatted"`
+	PriceImpact  *big.Float     `json:"priceImpact,omitempty"`
+	Hops         []routeHopView `json:"hops"`
+}
+
+// renderRoute writes a route found by the router. quote, if not nil, adds the amounts,
+// prices and price impact of every hop.
+func renderRoute(
+	w io.Writer,
+	paths []graph.TokenPoolPath,
+	amountOut *big.Int,
+	quote *chaintypes.RouteQuote,
+	tokenIn, tokenOut *tokenregistry.Token,
+	poolReg poolregistry.PoolRegistry,
+	allTokens []tokenregistry.Token,
+	asJSON bool,
+) error {
+	// Convert output amount to decimal format
+	decimals := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(tokenOut.Decimals)), nil)
+	outFloat := new(big.Float).SetInt(amountOut)
+	decFloat := new(big.Float).SetInt(decimals)
+	humanOut := new(big.Float).Quo(outFloat, decFloat)
+
+	// Build Token Symbol Map for intermediate lookups
+	// (Efficiency note: Ideally this map is passed in, but building it here is fine for a CLI command)
+	tokenMap := tokenSymbols(allTokens)
+
+	view := routeView{
+		TokenInID:    tokenIn.ID,
+		TokenOutID:   tokenOut.ID,
+		AmountOut:    amountOut,
+		AmountOutFmt: humanOut.Text('f', 4),
+		Hops:         make([]routeHopView, len(paths)),
+	}
+	for i, p := range paths {
+		hop := routeHopView{
+			TokenInID:  p.TokenInID,
+			TokenIn:    symbolFor(tokenMap, p.TokenInID),
+			TokenOutID: p.TokenOutID,
+			TokenOut:   symbolFor(tokenMap, p.TokenOutID),
+			PoolID:     p.PoolID,
+		}
+
+		// Linear lookup in pool registry (fast enough for CLI)
+		for _, pool := range poolReg.Pools {
+			if pool.ID == p.PoolID {
+				hop.ProtocolID = poolReg.Protocols[pool.Protocol]
+				address, _ := pool.Key.ToAddress()
+				hop.Address = address.Checksum()
+				break
+			}
+		}
+		if quote != nil && i < len(quote.Hops) {
+			q := quote.Hops[i]
+			hop.AmountIn, hop.AmountOut = q.AmountIn, q.AmountOut
+			hop.SpotPriceBefore, hop.SpotPriceAfter = q.SpotPriceBefore, q.SpotPriceAfter
+			hop.PriceImpact, hop.CumulativePriceImpact = q.PriceImpact, q.CumulativePriceImpact
+		}
+		view.Hops[i] = hop
+	}
+	if quote != nil {
+		view.PriceImpact = quote.PriceImpact
+	}
+
+	if asJSON {
+		return writeJSON(w, view)
+	}
+
+	writeHeader(w, "BEST ROUTE FOUND")
+	fmt.Fprintf(w, "%sEst. Output:%s %s %s (Raw: %s)\n\n", Bold, Reset, view.AmountOutFmt, tokenOut.Symbol, amountOut.String())
+
+	fmt.Fprintln(w, Bold+"Route Path:"+Reset)
+	for i, hop := range view.Hops {
+		poolDesc := "Unknown Pool"
+		if hop.ProtocolID != "" {
+			poolDesc = string(hop.ProtocolID)
+			// Clean up protocol name for display
+			if len(poolDesc) > 20 {
+				poolDesc = poolDesc[:17] + "..."
+			}
+		}
+		poolAddr := hop.Address
+		if poolAddr == "" {
+			poolAddr = "???"
+		}
+
+		// VISUAL DISPLAY
+		// Step N: [ Symbol In ]
+		//            |
+		//            +---[ Pool Info ]---> [ Symbol Out ]
+		fmt.Fprintf(w, " [ Step %d ]\n", i+1)
+		fmt.Fprintf(w, "  %s%-6s%s\n", Cyan, hop.TokenIn, Reset)
+		fmt.Fprintf(w, "    %s|%s\n", Gray, Reset)
+		fmt.Fprintf(w, "    %s+---[%s%s %s]--->%s  %s%-6s%s\n",
+			Gray,
+			Reset, poolDesc, poolAddr,
+			Reset,
+			Cyan, hop.TokenOut, Reset)
+		fmt.Fprintln(w, "")
+	}
+
+	if quote == nil {
+		return nil
+	}
+	hopDecimals := decimalsByToken(allTokens)
+	fmt.Fprintln(w, Bold+"Hop Quotes:"+Reset)
+	tw := tabwriter.NewWriter(w, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tIN\tOUT\tSPOT BEFORE\tSPOT AFTER\tIMPACT\tCUM. IMPACT\t")
+	for i, hop := range view.Hops {
+		fmt.Fprintf(tw, "%d\t%s %s\t%s %s\t%s\t%s\t%s\t%s\t\n",
+			i+1,
+			formatUnits(hop.AmountIn, hopDecimals, hop.TokenInID), hop.TokenIn,
+			formatUnits(hop.AmountOut, hopDecimals, hop.TokenOutID), hop.TokenOut,
+			hop.SpotPriceBefore.Text('g', 8), hop.SpotPriceAfter.Text('g', 8),
+			formatPercent(hop.PriceImpact), formatPercent(hop.CumulativePriceImpact))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\n%sTotal Price Impact:%s %s\n", Bold, Reset, formatPercent(view.PriceImpact))
+	return nil
+}
+
+// decimalsByToken maps token IDs to their decimals.
+func decimalsByToken(tokens []tokenregistry.Token) map[uint64]uint8 {
+	decimals := make(map[uint64]uint8, len(tokens))
+	for _, t := range tokens {
+		decimals[t.ID] = t.Decimals
+	}
+	return decimals
+}
+
+// formatUnits formats a raw amount in whole tokens, or raw when the decimals are unknown.
+func formatUnits(amount *big.Int, decimals map[uint64]uint8, tokenID uint64) string {
+	d, ok := decimals[tokenID]
+	if !ok {
+		return amount.String()
+	}
+	scale := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d)), nil))
+	return new(big.Float).Quo(new(big.Float).SetInt(amount), scale).Text('f', 4)
+}
+
+// formatPercent formats a fraction as a percentage.
+func formatPercent(fraction *big.Float) string {
+	return new(big.Float).Mul(fraction, big.NewFloat(100)).Text('f', 3) + "%"
+}
+
+type reachableTokenView struct {
+	TokenID   uint64 `json:"tokenId"`
+	Symbol    string `json:"symbol"`
+	Hops      int    `json:"hops"`
+	PoolCount int    `json:"poolCount"`
+}
+
+// renderReachableTokens writes reachable tokens sorted by hop count, then symbol.
+func renderReachableTokens(out io.Writer, reachable []graph.ReachableToken, symbols map[uint64]string, asJSON bool) error {
+	sorted := make([]reachableTokenView, len(reachable))
+	for i, r := range reachable {
+		sorted[i] = reachableTokenView{TokenID: r.TokenID, Symbol: symbolFor(symbols, r.TokenID), Hops: r.Hops, PoolCount: r.PoolCount}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Hops != sorted[j].Hops {
+			return sorted[i].Hops < sorted[j].Hops
+		}
+		if sorted[i].Symbol != sorted[j].Symbol {
+			return sorted[i].Symbol < sorted[j].Symbol
+		}
+		return sorted[i].TokenID < sorted[j].TokenID
+	})
+
+	if asJSON {
+		return writeJSON(out, sorted)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(w, "TOKEN\tID\tHOPS\tPOOLS\t")
+	fmt.Fprintln(w, "-----\t--\t----\t-----\t")
+	for _, r := range sorted {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t\n", r.Symbol, r.TokenID, r.Hops, r.PoolCount)
+	}
+	return w.Flush()
+}
+
+// renderHealthCheck writes the health summary, failures first, ending with PASS or FAIL.
+func renderHealthCheck(w io.Writer, view healthCheckView, asJSON bool) error {
+	if asJSON {
+		return writeJSON(w, view)
+	}
+
+	writeHeader(w, "HEALTH CHECK")
+	fmt.Fprintf(w, " %s%-16s%s #%d (%.0fs old)\n", Gray, "Block:", Reset, view.Block, view.StateAgeSeconds)
+	fmt.Fprintf(w, " %s%-16s%s %d\n", Gray, "Tokens:", Reset, view.Tokens)
+	fmt.Fprintf(w, " %s%-16s%s %d (%d routable)\n", Gray, "Pools:", Reset, view.Pools, view.RoutablePools)
+
+	for _, p := range view.ErroredProtocols {
+		fmt.Fprintf(w, " %sERRORED%s  %s: %s\n", Red, Reset, p.ID, p.Error)
+	}
+	for _, p := range view.LaggingProtocols {
+		fmt.Fprintf(w, " %sLAGGING%s  %s: synced at #%d, %d blocks behind\n", Red, Reset, p.ID, p.SyncedBlock, p.BlocksBehind)
+	}
+	for _, m := range view.DecimalsMismatches {
+		fmt.Fprintf(w, " %sDECIMALS%s pool %d, token %d: pool assumes %d, registry has %d\n",
+			Red, Reset, m.PoolID, m.TokenID, m.PoolDecimals, m.RegistryDecimals)
+	}
+	reasons := make([]string, 0, len(view.SkippedPools))
+	for reason := range view.SkippedPools {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Fprintf(w, " %sSKIPPED%s  %d pools: %s\n", Yellow, Reset, view.SkippedPools[reason], reason)
+	}
+
+	fmt.Fprintln(w, "")
+	for _, failure := range view.Failures {
+		fmt.Fprintln(w, Red+" ✗ "+failure+Reset)
+	}
+	for _, warning := range view.Warnings {
+		fmt.Fprintln(w, Yellow+" ! "+warning+Reset)
+	}
+	if view.Pass {
+		_, err := fmt.Fprintln(w, "\n"+Bold+Green+"PASS"+Reset)
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%s%sFAIL%s (%d problems)\n", Bold, Red, Reset, len(view.Failures))
+	return err
+}
+
+type priceTickView struct {
+	Block uint64 `json:"block"`
+	poolPriceView
+	Target    *big.Float     `json:"target"`
+	Direction crossDirection `json:"direction"`
+	Crossed   bool           `json:"crossed"`
+}
+
+// renderPriceTick writes one block of a price alert watch, highlighting a crossing.
+func renderPriceTick(w io.Writer, block uint64, price poolPriceView, alert *priceAlert, crossed bool, asJSON bool) error {
+	if asJSON {
+		return writeJSON(w, priceTickView{
+			Block:         block,
+			poolPriceView: price,
+			Target:        alert.target,
+			Direction:     alert.direction,
+			Crossed:       crossed,
+		})
+	}
+
+	fmt.Fprintf(w, " %s#%d%s  1 %s = %s %s\n", Gray, block, Reset, price.Symbol0, price.Price.Text('g', 10), price.Symbol1)
+	if !crossed {
+		return nil
+	}
+	_, err := fmt.Fprintf(w, "\a%s%s ALERT%s price crossed %s %s %s\n",
+		Bold, Red, Reset, alert.direction, alert.target.Text('g', 10), price.Symbol1)
+	return err
+}
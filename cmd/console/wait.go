@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"time"
+)
+
+// stateWaitHint is how long the console waits for the first state before suggesting the
+// connection and logs be checked.
+var stateWaitHint = flag.Duration("state-wait-hint", 15*time.Second, "How long to wait for the first state before hinting to check the connection and logs.")
+
+var spinnerFrames = []string{"|", "/", "-", `\`}
+
+// spinnerInterval is how long each spinner frame is shown.
+const spinnerInterval = 250 * time.Millisecond
+
+// stateWait tracks the startup window before the first state arrives. The clock is
+// injected so the hint can be tested without waiting for it.
+type stateWait struct {
+	start     time.Time
+	hintAfter time.Duration
+	now       func() time.Time
+}
+
+func newStateWait(now func() time.Time, hintAfter time.Duration) *stateWait {
+	return &stateWait{start: now(), hintAfter: hintAfter, now: now}
+}
+
+// elapsed is the time spent waiting so far, to the second.
+func (w *stateWait) elapsed() time.Duration {
+	return w.now().Sub(w.start).Truncate(time.Second)
+}
+
+// hintDue reports whether the wait has lasted long enough to suggest troubleshooting.
+func (w *stateWait) hintDue() bool {
+	return w.now().Sub(w.start) >= w.hintAfter
+}
+
+// writeStatus prints the waiting line, a spinner frame with the elapsed time, followed by
+// the troubleshooting hint once it is due.
+func (w *stateWait) writeStatus(out io.Writer) {
+	waited := w.now().Sub(w.start)
+	frame := spinnerFrames[int(waited/spinnerInterval)%len(spinnerFrames)]
+	fmt.Fprintf(out, "%s%s Waiting for first state update... (%s elapsed)%s\n", Yellow, frame, w.elapsed(), Reset)
+	if w.hintDue() {
+		fmt.Fprintf(out, "%s[HINT] No state after %s. Check the stream URL and your connection, and see client.log for errors.%s\n", Yellow, w.hintAfter, Reset)
+	}
+	fmt.Fprintf(out, "%sOnly 'h' and 'q' are available until then.%s\n", Gray, Reset)
+}
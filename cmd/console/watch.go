@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/defistate/defistate-client-go/differ"
+	"github.com/defistate/defistate-client-go/engine"
+	poolregistry "github.com/defistate/defistate-client-go/protocols/poolregistry"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv4"
+)
+
+// parsePoolKeys parses a comma-separated list of pool addresses or keys, in the formats
+// readAndParseKey accepts.
+func parsePoolKeys(input string) ([][32]byte, error) {
+	var keys [][32]byte
+	for _, part := range strings.Split(input, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		raw := []byte(part)
+		if strings.HasPrefix(part, "0x") {
+			var err error
+			if raw, err = hex.DecodeString(part[2:]); err != nil {
+				return nil, fmt.Errorf("invalid hex key %q: %v", part, err)
+			}
+		}
+		if len(raw) > 32 {
+			return nil, fmt.Errorf("key %q too long (%d bytes), max 32 bytes", part, len(raw))
+		}
+		var key [32]byte
+		copy(key[32-len(raw):], raw)
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errEmptyInput
+	}
+	return keys, nil
+}
+
+// watchField is one labeled value of a watched pool.
+type watchField struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	Changed bool   `json:"changed,omitempty"`
+}
+
+type watchPaneView struct {
+	Key        string            `json:"key"`
+	PoolID     uint64            `json:"poolId,omitempty"`
+	ProtocolID engine.ProtocolID `json:"protocolId,omitempty"`
+	// Updated is true if the block's diff updated the pool.
+	Updated bool         `json:"updated,omitempty"`
+	Fields  []watchField `json:"fields,omitempty"`
+	Error   string       `json:"error,omitempty"`
+}
+
+type watchBlockView struct {
+	Block uint64          `json:"block"`
+	Panes []watchPaneView `json:"panes"`
+}
+
+// poolWatcher follows a set of pools block by block and flags the fields that changed
+// since the previous block.
+type poolWatcher struct {
+	keys [][32]byte
+	prev map[[32]byte]map[string]string // field values of the previous block, by key
+}
+
+func newPoolWatcher(keys [][32]byte) *poolWatcher {
+	return &poolWatcher{keys: keys, prev: make(map[[32]byte]map[string]string)}
+}
+
+// observe builds the panes of a block. diff, if not nil, is the diff the state was
+// patched from: a pool it does not update is unchanged, and the fields of a pool it does
+// update are compared with the previous block. Without a diff, as after a full state,
+// every pool is compared with the previous block.
+func (w *poolWatcher) observe(state *engine.State, diff *differ.StateDiff) watchBlockView {
+	view := watchBlockView{Panes: make([]watchPaneView, len(w.keys))}
+	if state.Block.Number != nil {
+		view.Block = state.Block.Number.Uint64()
+	}
+	updated := diffUpdatedPools(diff)
+
+	for i, key := range w.keys {
+		pane := watchPane(state, key)
+		if _, ok := updated[pane.PoolID]; ok {
+			pane.Updated = true
+		}
+		if prev, ok := w.prev[key]; ok && (diff == nil || pane.Updated) {
+			for j, field := range pane.Fields {
+				if old, ok := prev[field.Name]; ok && old != field.Value {
+					pane.Fields[j].Changed = true
+				}
+			}
+		}
+
+		values := make(map[string]string, len(pane.Fields))
+		for _, field := range pane.Fields {
+			values[field.Name] = field.Value
+		}
+		w.prev[key] = values
+		view.Panes[i] = pane
+	}
+	return view
+}
+
+// diffUpdatedPools returns the IDs of the pools a diff adds or updates.
+func diffUpdatedPools(diff *differ.StateDiff) map[uint64]struct{} {
+	ids := make(map[uint64]struct{})
+	if diff == nil {
+		return ids
+	}
+	for _, protocol := range diff.Protocols {
+		switch data := protocol.Data.(type) {
+		case uniswapv2.UniswapV2SystemDiff:
+			for _, pools := range [][]uniswapv2.Pool{data.Additions, data.Updates} {
+				for _, pool := range pools {
+					ids[pool.ID] = struct{}{}
+				}
+			}
+		case uniswapv3.UniswapV3SystemDiff:
+			for _, pools := range [][]uniswapv3.Pool{data.Additions, data.Updates} {
+				for _, pool := range pools {
+					ids[pool.ID] = struct{}{}
+				}
+			}
+		case uniswapv4.UniswapV4SystemDiff:
+			for _, pools := range [][]uniswapv4.Pool{data.Additions, data.Updates} {
+				for _, pool := range pools {
+					ids[pool.ID] = struct{}{}
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// watchPane looks up a pool key and lists the fields of the pool's protocol state.
+func watchPane(state *engine.State, key [32]byte) watchPaneView {
+	pane := watchPaneView{Key: "0x" + hex.EncodeToString(key[:])}
+	protocolState, ok := state.Protocols[engine.ProtocolID("pool-system")]
+	if !ok {
+		pane.Error = "protocol 'pool-system' not found"
+		return pane
+	}
+	registry, ok := protocolState.Data.(poolregistry.PoolRegistry)
+	if !ok {
+		pane.Error = fmt.Sprintf("bad pool registry data type: %T", protocolState.Data)
+		return pane
+	}
+	var pool *poolregistry.Pool
+	for i := range registry.Pools {
+		if registry.Pools[i].Key == key {
+			pool = &registry.Pools[i]
+			break
+		}
+	}
+	if pool == nil {
+		pane.Error = "pool key not found in registry"
+		return pane
+	}
+	pane.PoolID = pool.ID
+	pane.ProtocolID = registry.Protocols[pool.Protocol]
+	pState, ok := state.Protocols[pane.ProtocolID]
+	if !ok {
+		pane.Error = fmt.Sprintf("protocol state for '%s' is not loaded", pane.ProtocolID)
+		return pane
+	}
+
+	field := func(name string, value any) {
+		pane.Fields = append(pane.Fields, watchField{Name: name, Value: fmt.Sprint(value)})
+	}
+	switch data := protocolPoolData(pState, pool.ID).(type) {
+	case *uniswapv2.Pool:
+		field("Reserve0", data.Reserve0)
+		field("Reserve1", data.Reserve1)
+	case *uniswapv3.Pool:
+		field("Liquidity", data.Liquidity)
+		field("SqrtPriceX96", data.SqrtPriceX96)
+		field("Tick", data.Tick)
+		field("Active Ticks", len(data.Ticks))
+	case *uniswapv4.Pool:
+		if fee, ok := data.SwapFee(); ok {
+			field("Swap Fee", fee)
+		} else {
+			field("Swap Fee", "dynamic")
+		}
+		field("Liquidity", data.Liquidity)
+		field("SqrtPriceX96", data.SqrtPriceX96)
+		field("Tick", data.Tick)
+		field("Active Ticks", len(data.Ticks))
+	default:
+		pane.Error = fmt.Sprintf("no inspector for pool %d of schema %s", pool.ID, pState.Schema)
+	}
+	return pane
+}
+
+// renderWatchBlock writes the panes of a block side by side, one column per pool, with
+// the changed fields highlighted.
+func renderWatchBlock(w io.Writer, view watchBlockView, asJSON bool) error {
+	if asJSON {
+		return writeJSON(w, view)
+	}
+
+	// Rows are the union of the panes' field names, in order of first appearance.
+	var rows []string
+	seen := make(map[string]bool)
+	for _, pane := range view.Panes {
+		for _, field := range pane.Fields {
+			if !seen[field.Name] {
+				seen[field.Name] = true
+				rows = append(rows, field.Name)
+			}
+		}
+	}
+
+	// cells[r][c] is the text of row r in column c; column 0 holds the labels. Widths
+	// are measured before coloring, so the escape codes do not break the alignment.
+	header := []string{""}
+	protocols := []string{"Protocol"}
+	errs := []string{""}
+	hasErrors := false
+	for _, pane := range view.Panes {
+		title := fmt.Sprintf("Pool %d", pane.PoolID)
+		if pane.PoolID == 0 {
+			title = pane.Key[:10] + "..."
+		}
+		if pane.Updated {
+			title += " *"
+		}
+		header = append(header, title)
+		protocols = append(protocols, string(pane.ProtocolID))
+		errs = append(errs, pane.Error)
+		hasErrors = hasErrors || pane.Error != ""
+	}
+	cells := [][]string{header, protocols}
+	changed := [][]bool{make([]bool, len(header)), make([]bool, len(header))}
+	for _, name := range rows {
+		row := []string{name}
+		rowChanged := []bool{false}
+		for _, pane := range view.Panes {
+			value, isChanged := "", false
+			for _, field := range pane.Fields {
+				if field.Name == name {
+					value, isChanged = field.Value, field.Changed
+				}
+			}
+			row = append(row, value)
+			rowChanged = append(rowChanged, isChanged)
+		}
+		cells = append(cells, row)
+		changed = append(changed, rowChanged)
+	}
+	if hasErrors {
+		errs[0] = "Error"
+		cells = append(cells, errs)
+		changed = append(changed, make([]bool, len(errs)))
+	}
+
+	widths := make([]int, len(header))
+	for _, row := range cells {
+		for c, cell := range row {
+			widths[c] = max(widths[c], utf8.RuneCountInString(cell))
+		}
+	}
+
+	writeHeader(w, fmt.Sprintf("LIVE MONITOR (Block: %d)", view.Block))
+	for r, row := range cells {
+		var line strings.Builder
+		for c, cell := range row {
+			padded := cell + strings.Repeat(" ", widths[c]-utf8.RuneCountInString(cell)+3)
+			switch {
+			case r == 0 || c == 0:
+				line.WriteString(Bold + padded + Reset)
+			case changed[r][c]:
+				line.WriteString(Yellow + padded + Reset)
+			case hasErrors && r == len(cells)-1:
+				line.WriteString(Red + padded + Reset)
+			default:
+				line.WriteString(padded)
+			}
+		}
+		fmt.Fprintln(w, line.String())
+	}
+	_, err := fmt.Fprintln(w, Gray+"\n* updated by this block's diff; changed fields are highlighted."+Reset)
+	return err
+}
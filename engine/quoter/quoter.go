@@ -0,0 +1,212 @@
+// Package quoter quotes swaps against a decoded engine.State in one call. It indexes the
+// state and builds the analytical graph the chain clients use, so callers do not have
+// to wire the indexers, the protocol resolver and the grapher themselves.
+package quoter
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/defistate/defistate-client-go/chains"
+	"github.com/defistate/defistate-client-go/chains/graphers"
+	"github.com/defistate/defistate-client-go/engine"
+	"github.com/defistate/defistate-client-go/protocols/pancakeswap"
+	poolregistry "github.com/defistate/defistate-client-go/protocols/poolregistry"
+	poolregistryindexer "github.com/defistate/defistate-client-go/protocols/poolregistry/indexer"
+	"github.com/defistate/defistate-client-go/protocols/solidly"
+	solidlyindexer "github.com/defistate/defistate-client-go/protocols/solidly/indexer"
+	tokenpoolregistry "github.com/defistate/defistate-client-go/protocols/tokenpoolregistry"
+	"github.com/defistate/defistate-client-go/protocols/tokenregistry"
+	tokenregistryindexer "github.com/defistate/defistate-client-go/protocols/tokenregistry/indexer"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	uniswapv2indexer "github.com/defistate/defistate-client-go/protocols/uniswapv2/indexer"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	uniswapv3indexer "github.com/defistate/defistate-client-go/protocols/uniswapv3/indexer"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv4"
+	uniswapv4calculator "github.com/defistate/defistate-client-go/protocols/uniswapv4/calculator"
+)
+
+// DefaultSearchDepth is the search depth of a Quoter unless WithSearchDepth is set.
+const DefaultSearchDepth = 3
+
+// Quote is the best route found for a swap.
+type Quote struct {
+	Path      []chains.TokenPoolPath
+	AmountIn  *big.Int
+	AmountOut *big.Int
+}
+
+// Quoter quotes swaps between any two tokens of a state, across every protocol the
+// state holds. It is built for one state; build a new one for every new state.
+type Quoter struct {
+	graph chains.TokenPoolGraph
+	depth int
+}
+
+type config struct {
+	grapher chains.TokenPoolGrapher
+	depth   int
+}
+
+// Option configures the Quoter.
+type Option interface {
+	apply(*config)
+}
+
+type funcOption func(*config)
+
+func (f funcOption) apply(c *config) {
+	f(c)
+}
+
+// WithGrapher builds the graph with grapher instead of the grapher of the state's chain.
+func WithGrapher(grapher chains.TokenPoolGrapher) Option {
+	return funcOption(func(c *config) {
+		c.grapher = grapher
+	})
+}
+
+// WithSearchDepth sets how deep quotes search: routes of up to depth hops are always
+// considered. Exact-in searches run depth relaxation rounds of the graph, which can find
+// longer routes too; exact-out searches stop at depth hops. It must be greater than 0.
+func WithSearchDepth(depth int) Option {
+	return funcOption(func(c *config) {
+		c.depth = depth
+	})
+}
+
+// New indexes state and builds its graph with the grapher of state.ChainID. The state
+// must hold the token-pool graph and the pool registry; token metadata is optional. A
+// protocol of a schema the Quoter cannot route is an error. Uniswap V4 pools with an
+// unknown dynamic fee cannot be quoted and are left out.
+func New(state *engine.State, opts ...Option) (*Quoter, error) {
+	cfg := config{depth: DefaultSearchDepth}
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+	if cfg.depth <= 0 {
+		return nil, errors.New("search depth must be greater than 0")
+	}
+	if cfg.grapher == nil {
+		g, err := graphers.ForChainID(state.ChainID)
+		if err != nil {
+			return nil, err
+		}
+		cfg.grapher = g
+	}
+
+	var (
+		rawGraph     *tokenpoolregistry.TokenPoolRegistryView
+		tokens       []tokenregistry.Token
+		poolRegistry *poolregistry.PoolRegistry
+		v2Pools      []uniswapv2.Pool
+		v3Pools      []uniswapv3.Pool
+		solidlyPools []solidly.Pool
+	)
+	schemas := make(map[engine.ProtocolID]engine.ProtocolSchema, len(state.Protocols))
+	for id, p := range state.Protocols {
+		schemas[id] = p.Schema
+		if p.Data == nil {
+			continue
+		}
+		ok := true
+		switch p.Schema {
+		case tokenregistry.Schema:
+			tokens, ok = p.Data.([]tokenregistry.Token)
+		case poolregistry.Schema:
+			var registry poolregistry.PoolRegistry
+			registry, ok = p.Data.(poolregistry.PoolRegistry)
+			poolRegistry = &registry
+		case tokenpoolregistry.Schema:
+			rawGraph, ok = p.Data.(*tokenpoolregistry.TokenPoolRegistryView)
+		case uniswapv2.Schema, pancakeswap.V2Schema:
+			var pools []uniswapv2.Pool
+			pools, ok = p.Data.([]uniswapv2.Pool)
+			v2Pools = append(v2Pools, pools...)
+		case uniswapv3.Schema, pancakeswap.V3Schema:
+			var pools []uniswapv3.Pool
+			pools, ok = p.Data.([]uniswapv3.Pool)
+			v3Pools = append(v3Pools, pools...)
+		case uniswapv4.Schema:
+			// V4 pools share the V3 swap math, so they are indexed and routed in V3 form.
+			var pools []uniswapv4.Pool
+			pools, ok = p.Data.([]uniswapv4.Pool)
+			v3Form, _ := uniswapv4calculator.V3Pools(pools)
+			v3Pools = append(v3Pools, v3Form...)
+		case solidly.Schema:
+			var pools []solidly.Pool
+			pools, ok = p.Data.([]solidly.Pool)
+			solidlyPools = append(solidlyPools, pools...)
+		default:
+			return nil, fmt.Errorf("protocol %s: unsupported schema %s", id, p.Schema)
+		}
+		if !ok {
+			return nil, fmt.Errorf("protocol %s: bad data type %T", id, p.Data)
+		}
+	}
+	if rawGraph == nil {
+		return nil, errors.New("token pool graph missing from state")
+	}
+	if poolRegistry == nil {
+		return nil, errors.New("pool registry missing from state")
+	}
+
+	var indexedTokens tokenregistryindexer.IndexedTokenSystem
+	if tokens != nil {
+		indexedTokens = tokenregistryindexer.New().Index(tokens)
+	}
+	indexedPools := poolregistryindexer.New().Index(*poolRegistry)
+
+	graph, err := cfg.grapher.Graph(
+		rawGraph,
+		indexedTokens,
+		indexedPools,
+		uniswapv2indexer.New().Index(v2Pools),
+		uniswapv3indexer.New().Index(v3Pools),
+		solidlyindexer.New().Index(solidlyPools),
+		chains.NewProtocolResolver(schemas, indexedPools),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("building graph: %w", err)
+	}
+	return &Quoter{graph: graph, depth: cfg.depth}, nil
+}
+
+// Graph returns the analytical graph the quotes are computed on, for queries the Quoter
+// does not cover.
+func (q *Quoter) Graph() chains.TokenPoolGraph {
+	return q.graph
+}
+
+// QuoteExactIn returns the route that sells amountIn of tokenIn for the most tokenOut.
+// It returns an error wrapping chains.ErrNoRoute if the tokens are not connected.
+func (q *Quoter) QuoteExactIn(tokenIn, tokenOut uint64, amountIn *big.Int) (*Quote, error) {
+	if amountIn == nil || amountIn.Sign() <= 0 {
+		return nil, errors.New("amountIn must be greater than 0")
+	}
+	path, amountOut, err := q.graph.FindBestSwapPath(chains.SwapFindingParams{
+		AmountIn:   amountIn,
+		TokenInID:  tokenIn,
+		TokenOutID: tokenOut,
+		Runs:       q.depth,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(path) == 0 {
+		return nil, fmt.Errorf("%w: token %d to token %d", chains.ErrNoRoute, tokenIn, tokenOut)
+	}
+	return &Quote{Path: path, AmountIn: new(big.Int).Set(amountIn), AmountOut: amountOut}, nil
+}
+
+// QuoteExactOut returns the route that buys amountOut of tokenOut for the least tokenIn.
+// Solidly pools have no exact-output math and are not used. It returns an error wrapping
+// chains.ErrNoRoute if no route can deliver amountOut.
+func (q *Quoter) QuoteExactOut(tokenIn, tokenOut uint64, amountOut *big.Int) (*Quote, error) {
+	path, amountIn, err := q.graph.FindBestSwapPathExactOut(tokenIn, tokenOut, amountOut, q.depth)
+	if err != nil {
+		return nil, err
+	}
+	return &Quote{Path: path, AmountIn: amountIn, AmountOut: new(big.Int).Set(amountOut)}, nil
+}
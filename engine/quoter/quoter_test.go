@@ -0,0 +1,150 @@
+package quoter
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/defistate/defistate-client-go/chains"
+	"github.com/defistate/defistate-client-go/engine"
+	"github.com/defistate/defistate-client-go/protocols/pancakeswap"
+	poolregistry "github.com/defistate/defistate-client-go/protocols/poolregistry"
+	tokenpoolregistry "github.com/defistate/defistate-client-go/protocols/tokenpoolregistry"
+	"github.com/defistate/defistate-client-go/protocols/tokenregistry"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	uniswapv2calculator "github.com/defistate/defistate-client-go/protocols/uniswapv2/calculator"
+)
+
+// testState holds WETH(1) -[pool 100]- USDC(2) -[pool 101]- DAI(3), and LINK(4) without
+// pools.
+func testState() *engine.State {
+	pool := func(id, token0, token1 uint64, reserve0, reserve1 *big.Int) uniswapv2.Pool {
+		return uniswapv2.Pool{ID: id, Token0: token0, Token1: token1, Reserve0: reserve0, Reserve1: reserve1, FeeBps: 30}
+	}
+	e18 := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	return &engine.State{
+		Block: engine.BlockSummary{Number: big.NewInt(19000000)},
+		Protocols: map[engine.ProtocolID]engine.ProtocolState{
+			"token-system": {
+				Schema: tokenregistry.Schema,
+				Data: []tokenregistry.Token{
+					{ID: 1, Symbol: "WETH", Decimals: 18},
+					{ID: 2, Symbol: "USDC", Decimals: 6},
+					{ID: 3, Symbol: "DAI", Decimals: 18},
+					{ID: 4, Symbol: "LINK", Decimals: 18},
+				},
+			},
+			"pool-system": {
+				Schema: poolregistry.Schema,
+				Data: poolregistry.PoolRegistry{
+					Pools:     []poolregistry.Pool{{ID: 100, Protocol: 1}, {ID: 101, Protocol: 1}},
+					Protocols: map[uint16]engine.ProtocolID{1: "uniswap-v2"},
+				},
+			},
+			"token-pool-graph-system": {
+				Schema: tokenpoolregistry.Schema,
+				Data: &tokenpoolregistry.TokenPoolRegistryView{
+					Tokens:      []uint64{1, 2, 3, 4},
+					Pools:       []uint64{100, 101},
+					Adjacency:   [][]int{{0}, {1, 2}, {3}, {}},
+					EdgeTargets: []int{1, 0, 2, 1},
+					EdgePools:   [][]int{{0}, {0}, {1}, {1}},
+				},
+			},
+			"uniswap-v2": {
+				Schema: uniswapv2.Schema,
+				Data: []uniswapv2.Pool{
+					pool(100, 1, 2, new(big.Int).Mul(big.NewInt(100), e18), big.NewInt(300_000e6)),
+					pool(101, 2, 3, big.NewInt(1_000_000e6), new(big.Int).Mul(big.NewInt(1_000_000), e18)),
+				},
+			},
+		},
+	}
+}
+
+func TestQuoter(t *testing.T) {
+	q, err := New(testState())
+	require.NoError(t, err)
+	route := []chains.TokenPoolPath{
+		{TokenInID: 1, TokenOutID: 2, PoolID: 100},
+		{TokenInID: 2, TokenOutID: 3, PoolID: 101},
+	}
+	pools := testState().Protocols["uniswap-v2"].Data.([]uniswapv2.Pool)
+
+	t.Run("exact in", func(t *testing.T) {
+		amountIn := big.NewInt(1e18)
+		quote, err := q.QuoteExactIn(1, 3, amountIn)
+		require.NoError(t, err)
+		assert.Equal(t, route, quote.Path)
+		assert.Equal(t, amountIn, quote.AmountIn)
+
+		mid, err := uniswapv2calculator.GetAmountOut(amountIn, 1, 2, pools[0])
+		require.NoError(t, err)
+		expected, err := uniswapv2calculator.GetAmountOut(mid, 2, 3, pools[1])
+		require.NoError(t, err)
+		assert.Equal(t, expected, quote.AmountOut)
+	})
+
+	t.Run("exact out", func(t *testing.T) {
+		amountOut := new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18))
+		quote, err := q.QuoteExactOut(1, 3, amountOut)
+		require.NoError(t, err)
+		assert.Equal(t, route, quote.Path)
+		assert.Equal(t, amountOut, quote.AmountOut)
+
+		mid, err := uniswapv2calculator.GetAmountIn(amountOut, 2, 3, pools[1])
+		require.NoError(t, err)
+		expected, err := uniswapv2calculator.GetAmountIn(mid, 1, 2, pools[0])
+		require.NoError(t, err)
+		assert.Equal(t, expected, quote.AmountIn)
+	})
+
+	t.Run("no route", func(t *testing.T) {
+		_, err := q.QuoteExactIn(1, 4, big.NewInt(1e18))
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+		_, err = q.QuoteExactOut(1, 4, big.NewInt(1e18))
+		assert.ErrorIs(t, err, chains.ErrNoRoute)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := q.QuoteExactIn(1, 3, big.NewInt(0))
+		assert.Error(t, err)
+		_, err = New(testState(), WithSearchDepth(0))
+		assert.Error(t, err)
+
+		state := testState()
+		delete(state.Protocols, "token-pool-graph-system")
+		_, err = New(state)
+		assert.ErrorContains(t, err, "token pool graph missing")
+
+		state = testState()
+		state.Protocols["curve"] = engine.ProtocolState{Schema: "defistate/curve@v1", Data: []struct{}{}}
+		_, err = New(state)
+		assert.ErrorContains(t, err, "unsupported schema defistate/curve@v1")
+	})
+}
+
+func TestQuoter_ChainGrapher(t *testing.T) {
+	// The same pools streamed as PancakeSwap V2 are routed by the BSC grapher only.
+	pancakeState := func(chainID uint64) *engine.State {
+		state := testState()
+		state.ChainID = chainID
+		protocol := state.Protocols["uniswap-v2"]
+		protocol.Schema = pancakeswap.V2Schema
+		state.Protocols["uniswap-v2"] = protocol
+		return state
+	}
+
+	q, err := New(pancakeState(56))
+	require.NoError(t, err)
+	quote, err := q.QuoteExactIn(1, 3, big.NewInt(1e18))
+	require.NoError(t, err)
+	assert.Len(t, quote.Path, 2)
+
+	q, err = New(pancakeState(1))
+	require.NoError(t, err)
+	_, err = q.QuoteExactIn(1, 3, big.NewInt(1e18))
+	assert.ErrorIs(t, err, chains.ErrNoRoute, "the Ethereum grapher does not route PancakeSwap pools")
+}
@@ -2,6 +2,8 @@ package engine
 
 import (
 	"math/big"
+	"strconv"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
 )
@@ -12,6 +14,27 @@ type ProtocolID string
 // ProtocolSchem defines the decode contract for a protocol's data
 type ProtocolSchema string
 
+// Name returns the schema without its version suffix,
+// e.g. "defistate/uniswap-v3" for "defistate/uniswap-v3@v1".
+func (s ProtocolSchema) Name() string {
+	name, _, _ := strings.Cut(string(s), "@")
+	return name
+}
+
+// Version returns the numeric version of a schema of the form "<name>@v<N>".
+// ok is false if the schema carries no (valid) version suffix.
+func (s ProtocolSchema) Version() (version uint64, ok bool) {
+	_, suffix, found := strings.Cut(string(s), "@v")
+	if !found {
+		return 0, false
+	}
+	version, err := strconv.ParseUint(suffix, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return version, true
+}
+
 type ProtocolMeta struct {
 	Name ProtocolName `json:"name"`           // human label
 	Tags []string     `json:"tags,omitempty"` // "dex", "fork", etc.
@@ -43,6 +66,7 @@ type BlockSummary struct {
 	ReceivedAt  int64       `json:"receivedAt"` // The Unix nanosecond timestamp when the engine started processing the block.
 	GasUsed     uint64      `json:"gasUsed"`
 	GasLimit    uint64      `json:"gasLimit"`
+	BaseFee     *big.Int    `json:"baseFee,omitempty"` // The block's EIP-1559 base fee in wei; nil if the stream does not carry it.
 	StateRoot   common.Hash `json:"stateRoot"`
 	TxHash      common.Hash `json:"txHash"`
 	ReceiptHash common.Hash `json:"receiptHash"`
@@ -6,6 +6,7 @@ import (
 	"math/big"
 	"sync"
 
+	"github.com/defistate/defistate-client-go/addr"
 	"github.com/defistate/defistate-client-go/bitset"
 	"github.com/defistate/defistate-client-go/engine"
 	poolregistry "github.com/defistate/defistate-client-go/protocols/poolregistry"
@@ -20,7 +21,6 @@ import (
 	uniswapv3 "github.com/defistate/defistate-client-go/protocols/uniswapv3"
 	uniswapv3calculator "github.com/defistate/defistate-client-go/protocols/uniswapv3/calculator"
 	uniswapv3indexer "github.com/defistate/defistate-client-go/protocols/uniswapv3/indexer"
-	"github.com/ethereum/go-ethereum/common"
 )
 
 type TokenPoolPath struct {
@@ -32,7 +32,7 @@ type TokenPoolPath struct {
 // IndexedPoolRegistry defines the methods for accessing indexed pool registry data.
 type IndexedPoolRegistry interface {
 	GetByID(id uint64) (poolregistry.Pool, bool)
-	GetByAddress(address common.Address) (poolregistry.Pool, bool)
+	GetByAddress(address addr.Address) (poolregistry.Pool, bool)
 	All() []poolregistry.Pool
 }
 
@@ -68,6 +68,16 @@ type Graph struct {
 	poolToIndex  map[uint64]int
 
 	allGetAmountOutFuncs []GetAmountOutFunc
+
+	// skipped lists the pools that could not be quoted because their data was missing or
+	// their schema is not supported.
+	skipped []SkippedPool
+}
+
+// SkippedPool is a pool of the token-pool graph that was left out of routing.
+type SkippedPool struct {
+	PoolID uint64
+	Reason string
 }
 
 // NewGraph creates a new Graph instance. It pre-processes the raw view data
@@ -93,10 +103,12 @@ func NewGraph(
 	indexedTokenRegistry := tokenindexer.NewIndexableTokenSystem(tokenRegistryView)
 
 	protocolIDToIndexed := make(map[engine.ProtocolID]any)
+	var skipped []SkippedPool
 
 	for i, poolID := range tokenPool.Pools {
 		poolInfo, ok := indexedPoolRegistry.GetByID(poolID)
 		if !ok {
+			skipped = append(skipped, SkippedPool{PoolID: poolID, Reason: "pool not found in pool registry"})
 			continue
 		}
 
@@ -126,7 +138,9 @@ func NewGraph(
 			// get pool from protocol
 			pool, found := indexedProtocol.(*uniswapv2indexer.IndexableUniswapV2System).GetByID(poolID)
 			if !found {
-				continue // maybe panic?
+				// The registry and the protocol state disagree; skip the pool and report it.
+				skipped = append(skipped, SkippedPool{PoolID: poolID, Reason: "pool missing from protocol state"})
+				continue
 			}
 
 			// Check for Fee-On-Transfer tokens
@@ -157,7 +171,9 @@ func NewGraph(
 			// get pool from protocol
 			pool, found := indexedProtocol.(*uniswapv3indexer.IndexableUniswapV3System).GetByID(poolID)
 			if !found {
-				continue // maybe panic?
+				// The registry and the protocol state disagree; skip the pool and report it.
+				skipped = append(skipped, SkippedPool{PoolID: poolID, Reason: "pool missing from protocol state"})
+				continue
 			}
 
 			// Check for Fee-On-Transfer tokens
@@ -173,6 +189,8 @@ func NewGraph(
 			allGetAmountOutFuncs[i] = func(amountIn *big.Int, tokenInID, tokenOutID uint64) (*big.Int, error) {
 				return uniswapv3calculator.GetAmountOut(amountIn, nil, tokenInID, pool)
 			}
+		default:
+			skipped = append(skipped, SkippedPool{PoolID: poolID, Reason: fmt.Sprintf("unsupported schema %s", protocol.Schema)})
 		}
 	}
 
@@ -181,9 +199,16 @@ func NewGraph(
 		tokenToIndex:         tokenToIndex,
 		poolToIndex:          poolToIndex,
 		allGetAmountOutFuncs: allGetAmountOutFuncs,
+		skipped:              skipped,
 	}, nil
 }
 
+// Skipped returns the pools that were left out of routing because their data was missing
+// or their schema is not supported.
+func (g *Graph) Skipped() []SkippedPool {
+	return append([]SkippedPool(nil), g.skipped...)
+}
+
 // FindBestSwapPath searches the graph for the most profitable swap path between two tokens.
 // It uses a "copy-and-patch" strategy to handle state overrides.
 func (g *Graph) FindBestSwapPath(
@@ -317,3 +342,80 @@ func (g *Graph) findSwapPath(state *findSwapPathsState, getAmountOutFuncs []GetA
 	}
 	return nil
 }
+
+// ReachableToken describes a token reachable from a starting token.
+type ReachableToken struct {
+	TokenID uint64
+	// Hops is the minimum number of swaps needed to reach the token.
+	Hops int
+	// PoolCount is the number of pools holding the token.
+	PoolCount int
+}
+
+// ReachableTokens runs a breadth-first search over the token-pool graph and returns
+// every token reachable from tokenID, excluding tokenID itself. It only needs the
+// topology, so it works without protocol state. The result is in BFS order.
+func ReachableTokens(tokenPool *tokenpoolregistry.TokenPoolRegistryView, tokenID uint64) ([]ReachableToken, error) {
+	start := -1
+	for i, id := range tokenPool.Tokens {
+		if id == tokenID {
+			start = i
+			break
+		}
+	}
+	if start == -1 {
+		return nil, fmt.Errorf("token %d not found in the graph", tokenID)
+	}
+
+	hops := make([]int, len(tokenPool.Tokens))
+	for i := range hops {
+		hops[i] = -1
+	}
+	hops[start] = 0
+
+	var reachable []ReachableToken
+	queue := []int{start}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		if current >= len(tokenPool.Adjacency) {
+			continue
+		}
+		for _, edgeIndex := range tokenPool.Adjacency[current] {
+			if edgeIndex >= len(tokenPool.EdgeTargets) {
+				continue
+			}
+			target := tokenPool.EdgeTargets[edgeIndex]
+			if target >= len(tokenPool.Tokens) || hops[target] != -1 {
+				continue
+			}
+			hops[target] = hops[current] + 1
+			queue = append(queue, target)
+			reachable = append(reachable, ReachableToken{
+				TokenID:   tokenPool.Tokens[target],
+				Hops:      hops[target],
+				PoolCount: poolCount(tokenPool, target),
+			})
+		}
+	}
+
+	return reachable, nil
+}
+
+// poolCount returns the number of distinct pools on the edges of the token at tokenIndex.
+func poolCount(tokenPool *tokenpoolregistry.TokenPoolRegistryView, tokenIndex int) int {
+	if tokenIndex >= len(tokenPool.Adjacency) {
+		return 0
+	}
+	seen := make(map[int]struct{})
+	for _, edgeIndex := range tokenPool.Adjacency[tokenIndex] {
+		if edgeIndex >= len(tokenPool.EdgePools) {
+			continue
+		}
+		for _, poolIndex := range tokenPool.EdgePools[edgeIndex] {
+			seen[poolIndex] = struct{}{}
+		}
+	}
+	return len(seen)
+}
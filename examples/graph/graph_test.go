@@ -0,0 +1,49 @@
+package graph
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/defistate/defistate-client-go/engine"
+	poolregistry "github.com/defistate/defistate-client-go/protocols/poolregistry"
+	"github.com/defistate/defistate-client-go/protocols/solidly"
+	tokenpoolregistry "github.com/defistate/defistate-client-go/protocols/tokenpoolregistry"
+	uniswapv2 "github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewGraph_Skipped(t *testing.T) {
+	tokenPool := &tokenpoolregistry.TokenPoolRegistryView{
+		Tokens: []uint64{1, 2},
+		Pools:  []uint64{10, 11, 12, 13},
+	}
+	pools := poolregistry.PoolRegistry{
+		Pools: []poolregistry.Pool{
+			{ID: 10, Protocol: 0},
+			{ID: 11, Protocol: 1},
+			{ID: 13, Protocol: 0},
+		},
+		Protocols: map[uint16]engine.ProtocolID{0: "uniswap-v2", 1: "aerodrome"},
+	}
+	protocols := map[engine.ProtocolID]engine.ProtocolState{
+		"uniswap-v2": {
+			Schema: uniswapv2.Schema,
+			Data: []uniswapv2.Pool{
+				{ID: 10, Token0: 1, Token1: 2, Reserve0: big.NewInt(1e6), Reserve1: big.NewInt(1e6)},
+			},
+		},
+		"aerodrome": {Schema: solidly.Schema, Data: []solidly.Pool{{ID: 11}}},
+	}
+
+	graph, err := NewGraph(tokenPool, nil, pools, protocols)
+	require.NoError(t, err)
+
+	assert.Equal(t, []SkippedPool{
+		{PoolID: 11, Reason: "unsupported schema " + string(solidly.Schema)},
+		{PoolID: 12, Reason: "pool not found in pool registry"},
+		{PoolID: 13, Reason: "pool missing from protocol state"},
+	}, graph.Skipped())
+	assert.NotNil(t, graph.allGetAmountOutFuncs[0], "the supported pool is routed")
+	assert.Nil(t, graph.allGetAmountOutFuncs[1])
+}
@@ -3,6 +3,9 @@ package patcher
 import (
 	"errors"
 	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
 
 	differ "github.com/defistate/defistate-client-go/differ"
 	engine "github.com/defistate/defistate-client-go/engine"
@@ -23,6 +26,11 @@ type StatePatcherConfig struct {
 	// Map Schema -> Patcher Function
 	// Example: "defistate/uniswapv2/poolView@v1" -> UniswapV2Patcher
 	Patchers map[engine.ProtocolSchema]PatcherFunc
+
+	// Concurrency is the number of workers patching protocols in parallel. Zero or one
+	// patches every protocol on the calling goroutine. PatcherFuncs must be safe to call
+	// concurrently when it is above one.
+	Concurrency int
 }
 
 func (c *StatePatcherConfig) validate() error {
@@ -31,12 +39,16 @@ func (c *StatePatcherConfig) validate() error {
 			return errors.New("patcher cannot be nil")
 		}
 	}
+	if c.Concurrency < 0 {
+		return fmt.Errorf("concurrency must not be negative, got %d", c.Concurrency)
+	}
 	return nil
 }
 
 // StatePatcher is the generic engine for applying state updates.
 type StatePatcher struct {
-	patchers map[engine.ProtocolSchema]PatcherFunc
+	patchers    map[engine.ProtocolSchema]PatcherFunc
+	concurrency atomic.Int64
 }
 
 // NewStatePatcher constructs a new patcher from a configuration.
@@ -51,9 +63,18 @@ func NewStatePatcher(cfg *StatePatcherConfig) (*StatePatcher, error) {
 		patchers[k] = v
 	}
 
-	return &StatePatcher{
+	p := &StatePatcher{
 		patchers: patchers,
-	}, nil
+	}
+	p.concurrency.Store(int64(cfg.Concurrency))
+	return p, nil
+}
+
+// SetConcurrency sets the number of workers patching protocols in parallel, as
+// StatePatcherConfig.Concurrency does. It is safe to call while patching; a negative
+// value counts as zero.
+func (p *StatePatcher) SetConcurrency(n int) {
+	p.concurrency.Store(int64(max(n, 0)))
 }
 
 // --- Implementation ---
@@ -61,6 +82,14 @@ func NewStatePatcher(cfg *StatePatcherConfig) (*StatePatcher, error) {
 // Patch creates a new State by applying the Diff to the Old State.
 // It uses "Structural Sharing": parts of the state that didn't change are shared
 // by reference. Parts that changed are replaced by the PatcherFunc.
+//
+// Patch is atomic: all changes are staged on a copy of the protocols map and the
+// new State is only returned once every protocol diff has been applied. If any
+// protocol fails midway, Patch returns an error and oldState is left untouched,
+// so callers can keep serving it and safely retry on the next full state.
+//
+// Protocols are independent, so with a concurrency above one they are patched by a pool
+// of workers; the resulting State is the same as patching them one by one.
 func (p *StatePatcher) Patch(oldState *engine.State, diff *differ.StateDiff) (*engine.State, error) {
 	// 1. Integrity Check
 	if oldState.Block.Number.Uint64() != diff.FromBlock {
@@ -69,50 +98,64 @@ func (p *StatePatcher) Patch(oldState *engine.State, diff *differ.StateDiff) (*e
 
 	// 2. Initialize New Protocols Map
 	// We start with a shallow copy of the old map. This preserves all "Unchanged" data efficiently.
+	// All writes below go to this copy, never to oldState.Protocols, which is what makes
+	// an early return on error a clean rollback.
 	newProtocols := make(map[engine.ProtocolID]engine.ProtocolState, len(oldState.Protocols))
 	for k, v := range oldState.Protocols {
 		newProtocols[k] = v
 	}
 
 	// 3. Apply Diffs
-	// We iterate only over the protocols that have changes.
-	for protocolID, protocolDiff := range diff.Protocols {
+	// We patch only the protocols that have changes, in protocol ID order so that the
+	// error reported for a diff with several failures does not depend on scheduling.
+	protocolIDs := make([]engine.ProtocolID, 0, len(diff.Protocols))
+	for protocolID := range diff.Protocols {
+		protocolIDs = append(protocolIDs, protocolID)
+	}
+	slices.Sort(protocolIDs)
 
-		// A. Find the Patcher logic for this specific data type
-		patcherFunc, ok := p.patchers[protocolDiff.Schema]
-		if !ok {
-			return nil, fmt.Errorf("patcher: no patcher registered for schema %q (protocol=%s)", protocolDiff.Schema, protocolID)
-		}
+	results := make([]engine.ProtocolState, len(protocolIDs))
+	errs := make([]error, len(protocolIDs))
+	patchAt := func(i int) {
+		results[i], errs[i] = p.patchProtocol(oldState, protocolIDs[i], diff.Protocols[protocolIDs[i]])
+	}
 
-		// B. Retrieve Old Data (if it exists)
-		var oldData any
-		if oldResult, exists := oldState.Protocols[protocolID]; exists {
-			// Safety check: Schema migration is complex; for now, assume schemas must match.
-			if oldResult.Schema != protocolDiff.Schema {
-				return nil, fmt.Errorf("patcher: schema mismatch for protocol %s (old=%s, diff=%s)", protocolID, oldResult.Schema, protocolDiff.Schema)
+	concurrency := int(p.concurrency.Load())
+	if concurrency <= 1 || len(protocolIDs) <= 1 {
+		for i := range protocolIDs {
+			if patchAt(i); errs[i] != nil {
+				return nil, errs[i]
 			}
-			oldData = oldResult.Data
 		}
-
-		// C. Execute the Patch
-		// The PatcherFunc is responsible for deep-copying oldData + applying diffData
-		newData, err := patcherFunc(oldData, protocolDiff.Data)
-		if err != nil {
-			return nil, fmt.Errorf("patcher: failed to patch protocol %s: %w", protocolID, err)
+	} else {
+		// Each worker writes only its own protocols' slots of results and errs.
+		indices := make(chan int)
+		var wg sync.WaitGroup
+		for w := 0; w < min(concurrency, len(protocolIDs)); w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range indices {
+					patchAt(i)
+				}
+			}()
+		}
+		for i := range protocolIDs {
+			indices <- i
 		}
+		close(indices)
+		wg.Wait()
 
-		// D. Construct the New Protocol Result
-		// We use metadata from the Diff, as it represents the latest state truth.
-		newResult := engine.ProtocolState{
-			Meta:              protocolDiff.Meta,
-			SyncedBlockNumber: protocolDiff.SyncedBlockNumber,
-			Schema:            protocolDiff.Schema,
-			Data:              newData,
-			Error:             protocolDiff.Error,
+		for _, err := range errs {
+			if err != nil {
+				return nil, err
+			}
 		}
+	}
 
-		// E. Update the map
-		newProtocols[protocolID] = newResult
+	// E. Update the map
+	for i, protocolID := range protocolIDs {
+		newProtocols[protocolID] = results[i]
 	}
 
 	// 4. Return Final State
@@ -123,3 +166,39 @@ func (p *StatePatcher) Patch(oldState *engine.State, diff *differ.StateDiff) (*e
 		Protocols: newProtocols,
 	}, nil
 }
+
+// patchProtocol applies the diff of one protocol to its data in oldState.
+func (p *StatePatcher) patchProtocol(oldState *engine.State, protocolID engine.ProtocolID, protocolDiff differ.ProtocolDiff) (engine.ProtocolState, error) {
+	// A. Find the Patcher logic for this specific data type
+	patcherFunc, ok := p.patchers[protocolDiff.Schema]
+	if !ok {
+		return engine.ProtocolState{}, fmt.Errorf("patcher: no patcher registered for schema %q (protocol=%s)", protocolDiff.Schema, protocolID)
+	}
+
+	// B. Retrieve Old Data (if it exists)
+	var oldData any
+	if oldResult, exists := oldState.Protocols[protocolID]; exists {
+		// Safety check: Schema migration is complex; for now, assume schemas must match.
+		if oldResult.Schema != protocolDiff.Schema {
+			return engine.ProtocolState{}, fmt.Errorf("patcher: schema mismatch for protocol %s (old=%s, diff=%s)", protocolID, oldResult.Schema, protocolDiff.Schema)
+		}
+		oldData = oldResult.Data
+	}
+
+	// C. Execute the Patch
+	// The PatcherFunc is responsible for deep-copying oldData + applying diffData
+	newData, err := patcherFunc(oldData, protocolDiff.Data)
+	if err != nil {
+		return engine.ProtocolState{}, fmt.Errorf("patcher: failed to patch protocol %s: %w", protocolID, err)
+	}
+
+	// D. Construct the New Protocol Result
+	// We use metadata from the Diff, as it represents the latest state truth.
+	return engine.ProtocolState{
+		Meta:              protocolDiff.Meta,
+		SyncedBlockNumber: protocolDiff.SyncedBlockNumber,
+		Schema:            protocolDiff.Schema,
+		Data:              newData,
+		Error:             protocolDiff.Error,
+	}, nil
+}
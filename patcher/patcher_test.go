@@ -2,7 +2,9 @@ package patcher
 
 import (
 	"errors"
+	"fmt"
 	"math/big"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -176,3 +178,144 @@ func TestStatePatcher_SchemaMismatch(t *testing.T) {
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "schema mismatch")
 }
+
+func TestStatePatcher_FailureLeavesOldStateIntact(t *testing.T) {
+	schema := engine.ProtocolSchema("mock/int@v1")
+	patcher, err := NewStatePatcher(&StatePatcherConfig{
+		Patchers: map[engine.ProtocolSchema]PatcherFunc{
+			schema: mockIntPatcher,
+		},
+	})
+	require.NoError(t, err)
+
+	p1 := engine.ProtocolID("uniswap_v2_mainnet")
+	p2 := engine.ProtocolID("uniswap_v3_mainnet")
+	p3 := engine.ProtocolID("pancakeswap_v3_bsc")
+
+	oldState := makeState(100, map[engine.ProtocolID]engine.ProtocolState{
+		p1: {Schema: schema, Data: 10},
+		p2: {Schema: schema, Data: 50},
+	})
+	oldBlock := oldState.Block.Number.Uint64()
+	oldTimestamp := oldState.Timestamp
+
+	// p1 and p3 patch cleanly, p2 carries a diff the patcher rejects. Whatever the
+	// map iteration order, some protocols may be patched before the failure is hit.
+	diff := &differ.StateDiff{
+		FromBlock: 100,
+		ToBlock: engine.BlockSummary{
+			Number: big.NewInt(101),
+		},
+		Protocols: map[engine.ProtocolID]differ.ProtocolDiff{
+			p1: {Schema: schema, Data: 5},
+			p2: {Schema: schema, Data: "not-an-int"},
+			p3: {Schema: schema, Data: 100},
+		},
+	}
+
+	newState, err := patcher.Patch(oldState, diff)
+	require.Error(t, err)
+	assert.Nil(t, newState)
+	assert.Contains(t, err.Error(), "failed to patch protocol")
+
+	// The previous state must be exactly as it was.
+	assert.Equal(t, oldBlock, oldState.Block.Number.Uint64())
+	assert.Equal(t, oldTimestamp, oldState.Timestamp)
+	require.Len(t, oldState.Protocols, 2)
+	assert.Equal(t, 10, oldState.Protocols[p1].Data)
+	assert.Equal(t, 50, oldState.Protocols[p2].Data)
+	_, exists := oldState.Protocols[p3]
+	assert.False(t, exists, "new protocol must not leak into the old state")
+
+	// And it must remain usable for a subsequent, valid diff.
+	delete(diff.Protocols, p2)
+	newState, err = patcher.Patch(oldState, diff)
+	require.NoError(t, err)
+	assert.Equal(t, 15, newState.Protocols[p1].Data)
+	assert.Equal(t, 50, newState.Protocols[p2].Data)
+	assert.Equal(t, 100, newState.Protocols[p3].Data)
+}
+
+func TestStatePatcher_Concurrency(t *testing.T) {
+	schema := engine.ProtocolSchema("mock/int@v1")
+
+	// The patcher records how many calls overlap, so the test can tell the protocols
+	// were patched in parallel.
+	var inFlight, maxInFlight atomic.Int64
+	slowPatcher := func(old any, diff any) (any, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		return mockIntPatcher(old, diff)
+	}
+
+	protocols := make(map[engine.ProtocolID]engine.ProtocolState)
+	diffs := make(map[engine.ProtocolID]differ.ProtocolDiff)
+	for i := 0; i < 32; i++ {
+		id := engine.ProtocolID(fmt.Sprintf("protocol_%02d", i))
+		protocols[id] = engine.ProtocolState{Schema: schema, Data: i}
+		diffs[id] = differ.ProtocolDiff{Schema: schema, Data: 100}
+	}
+	oldState := makeState(100, protocols)
+	diff := &differ.StateDiff{
+		FromBlock: 100,
+		ToBlock:   engine.BlockSummary{Number: big.NewInt(101)},
+		Protocols: diffs,
+	}
+
+	sequential, err := NewStatePatcher(&StatePatcherConfig{
+		Patchers: map[engine.ProtocolSchema]PatcherFunc{schema: slowPatcher},
+	})
+	require.NoError(t, err)
+	want, err := sequential.Patch(oldState, diff)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), maxInFlight.Load())
+
+	parallel, err := NewStatePatcher(&StatePatcherConfig{
+		Patchers:    map[engine.ProtocolSchema]PatcherFunc{schema: slowPatcher},
+		Concurrency: 8,
+	})
+	require.NoError(t, err)
+	got, err := parallel.Patch(oldState, diff)
+	require.NoError(t, err)
+	assert.Equal(t, want.Protocols, got.Protocols)
+	assert.Greater(t, maxInFlight.Load(), int64(1), "protocols should be patched in parallel")
+	assert.LessOrEqual(t, maxInFlight.Load(), int64(8))
+
+	t.Run("reports the first failing protocol", func(t *testing.T) {
+		failing := &differ.StateDiff{FromBlock: 100, ToBlock: diff.ToBlock, Protocols: make(map[engine.ProtocolID]differ.ProtocolDiff)}
+		for id, protocolDiff := range diffs {
+			failing.Protocols[id] = protocolDiff
+		}
+		failing.Protocols["protocol_07"] = differ.ProtocolDiff{Schema: schema, Data: "not-an-int"}
+		failing.Protocols["protocol_21"] = differ.ProtocolDiff{Schema: schema, Data: "not-an-int"}
+
+		for i := 0; i < 10; i++ {
+			newState, err := parallel.Patch(oldState, failing)
+			require.Error(t, err)
+			assert.Nil(t, newState)
+			assert.Contains(t, err.Error(), "protocol_07")
+		}
+		assert.Equal(t, 7, oldState.Protocols["protocol_07"].Data, "old state must be left intact")
+	})
+
+	t.Run("SetConcurrency", func(t *testing.T) {
+		maxInFlight.Store(0)
+		parallel.SetConcurrency(0)
+		got, err := parallel.Patch(oldState, diff)
+		require.NoError(t, err)
+		assert.Equal(t, want.Protocols, got.Protocols)
+		assert.Equal(t, int64(1), maxInFlight.Load())
+	})
+
+	t.Run("negative concurrency", func(t *testing.T) {
+		_, err := NewStatePatcher(&StatePatcherConfig{Concurrency: -1})
+		assert.ErrorContains(t, err, "concurrency")
+	})
+}
@@ -0,0 +1,251 @@
+// Package pricing derives the price of every token of a state from its token-pool graph.
+// Prices are found by routing from anchor tokens, each worth exactly one unit of the
+// numeraire, through the deepest pools, and carry a confidence score based on the
+// liquidity along the token's route to an anchor. With USD stablecoins as anchors the
+// numeraire is USD; with WETH as the only anchor it is ETH.
+//
+// Build a Prices for each block's graph:
+//
+//	oracle, _ := pricing.NewOracle(pricing.Config{AnchorTokenIDs: []uint64{usdcID, usdtID}})
+//	prices, err := oracle.Prices(graph, tokens)
+//	price, ok := prices.Price(wethID)
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/defistate/defistate-client-go/chains"
+	"github.com/defistate/defistate-client-go/protocols/tokenregistry"
+)
+
+const (
+	// DefaultRuns is the maximum number of hops from an anchor when Config.Runs is zero.
+	DefaultRuns = 3
+	// DefaultReferenceDepth is Config.ReferenceDepth when it is zero.
+	DefaultReferenceDepth = 100_000
+)
+
+// Config configures an Oracle.
+type Config struct {
+	// AnchorTokenIDs are the tokens worth exactly one unit of the numeraire, e.g. USD
+	// stablecoins. A token reached from several anchors is priced at the median.
+	AnchorTokenIDs []uint64
+	// Runs is the maximum number of hops between a token and an anchor. Zero means
+	// DefaultRuns.
+	Runs int
+	// ReferenceDepth is the route depth, in numeraire units, that scores a confidence of
+	// 0.5. Zero means DefaultReferenceDepth.
+	ReferenceDepth float64
+	// Concurrency is the number of workers scoring tokens in parallel. Zero or one scores
+	// every token on the calling goroutine.
+	Concurrency int
+}
+
+// Oracle prices tokens from token-pool graphs. It is safe for concurrent use.
+type Oracle struct {
+	anchors        []uint64
+	runs           int
+	referenceDepth float64
+	concurrency    int
+}
+
+// NewOracle returns an Oracle configured by cfg.
+func NewOracle(cfg Config) (*Oracle, error) {
+	if len(cfg.AnchorTokenIDs) == 0 {
+		return nil, errors.New("pricing: at least one anchor token is required")
+	}
+	if cfg.Runs < 0 || cfg.ReferenceDepth < 0 || cfg.Concurrency < 0 {
+		return nil, errors.New("pricing: Runs, ReferenceDepth and Concurrency must not be negative")
+	}
+	o := &Oracle{
+		anchors:        cfg.AnchorTokenIDs,
+		runs:           cfg.Runs,
+		referenceDepth: cfg.ReferenceDepth,
+		concurrency:    cfg.Concurrency,
+	}
+	if o.runs == 0 {
+		o.runs = DefaultRuns
+	}
+	if o.referenceDepth == 0 {
+		o.referenceDepth = DefaultReferenceDepth
+	}
+	return o, nil
+}
+
+// Price is the price of one whole token.
+type Price struct {
+	// Value is the price in numeraire units.
+	Value *big.Float
+	// Depth is the liquidity of the shallowest pool on the token's route to its anchor, in
+	// numeraire units. Anchors have an infinite depth.
+	Depth float64
+	// Confidence scores Depth between 0 and 1: Depth / (Depth + Config.ReferenceDepth).
+	// Anchors score 1.
+	Confidence float64
+	// Anchors is the number of anchors the token was reached from.
+	Anchors int
+}
+
+// Prices holds the prices of the tokens of one graph.
+type Prices struct {
+	prices map[uint64]Price
+}
+
+// Price returns the price of tokenID, or false if it cannot be reached from any anchor.
+func (p *Prices) Price(tokenID uint64) (Price, bool) {
+	price, ok := p.prices[tokenID]
+	return price, ok
+}
+
+// All returns every price, keyed by token ID. The map is a copy.
+func (p *Prices) All() map[uint64]Price {
+	all := make(map[uint64]Price, len(p.prices))
+	for id, price := range p.prices {
+		all[id] = price
+	}
+	return all
+}
+
+// Prices prices tokens, typically the token registry of the state graph was built from.
+// Tokens that no anchor reaches are left out. Anchors missing from graph are skipped; it
+// is an error if none is in it.
+func (o *Oracle) Prices(graph chains.TokenPoolGraph, tokens []tokenregistry.Token) (*Prices, error) {
+	decimals := make(map[uint64]uint8, len(tokens))
+	for _, token := range tokens {
+		decimals[token.ID] = token.Decimals
+	}
+
+	// rates[i][t] is the raw amount of token t worth one whole unit of anchor i, found
+	// through the deepest pools from the anchor.
+	rates := make([]map[uint64]*big.Int, len(o.anchors))
+	reached := 0
+	for i, anchor := range o.anchors {
+		anchorDecimals, ok := decimals[anchor]
+		if !ok {
+			continue
+		}
+		rate, err := graph.GetExchangeRates(pow10(anchorDecimals), anchor, o.runs, nil)
+		if err != nil {
+			continue // the anchor is not in the graph
+		}
+		rates[i] = rate
+		reached++
+	}
+	if reached == 0 {
+		return nil, fmt.Errorf("pricing: none of the anchors %v is in the graph", o.anchors)
+	}
+
+	prices := &Prices{prices: make(map[uint64]Price, len(tokens))}
+	var mu sync.Mutex
+	price := func(token tokenregistry.Token) {
+		p, ok := o.price(graph, token, rates)
+		if !ok {
+			return
+		}
+		mu.Lock()
+		prices.prices[token.ID] = p
+		mu.Unlock()
+	}
+
+	if o.concurrency <= 1 {
+		for _, token := range tokens {
+			price(token)
+		}
+		return prices, nil
+	}
+	work := make(chan tokenregistry.Token)
+	var wg sync.WaitGroup
+	for range o.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for token := range work {
+				price(token)
+			}
+		}()
+	}
+	for _, token := range tokens {
+		work <- token
+	}
+	close(work)
+	wg.Wait()
+	return prices, nil
+}
+
+// price prices a single token from the anchors' exchange rates.
+func (o *Oracle) price(graph chains.TokenPoolGraph, token tokenregistry.Token, rates []map[uint64]*big.Int) (Price, bool) {
+	for _, anchor := range o.anchors {
+		if token.ID == anchor {
+			return Price{Value: big.NewFloat(1), Depth: math.Inf(1), Confidence: 1, Anchors: 1}, true
+		}
+	}
+
+	whole := new(big.Float).SetInt(pow10(token.Decimals))
+	var values []*big.Float
+	var scoreAnchor uint64
+	var scoreRate *big.Int
+	for i, anchorRates := range rates {
+		rate, ok := anchorRates[token.ID]
+		if !ok || rate.Sign() <= 0 {
+			continue
+		}
+		values = append(values, new(big.Float).Quo(whole, new(big.Float).SetInt(rate)))
+		if scoreRate == nil {
+			scoreAnchor, scoreRate = o.anchors[i], rate
+		}
+	}
+	if len(values) == 0 {
+		return Price{}, false
+	}
+
+	depth := o.routeDepth(graph, token.ID, scoreAnchor, scoreRate)
+	return Price{
+		Value:      median(values),
+		Depth:      depth,
+		Confidence: depth / (depth + o.referenceDepth),
+		Anchors:    len(values),
+	}, true
+}
+
+// routeDepth returns the depth, in numeraire units, of the shallowest pool on the best
+// route selling one numeraire unit's worth of tokenID, rate, into anchor. It is zero if
+// there is no such route.
+func (o *Oracle) routeDepth(graph chains.TokenPoolGraph, tokenID, anchor uint64, rate *big.Int) float64 {
+	path, _, err := graph.FindBestSwapPath(chains.SwapFindingParams{
+		TokenInID:  tokenID,
+		TokenOutID: anchor,
+		AmountIn:   rate,
+		Runs:       o.runs,
+	})
+	if err != nil || len(path) == 0 {
+		return 0
+	}
+	depth, err := graph.MinRouteDepth(path)
+	if err != nil || depth.Depth == nil {
+		return 0
+	}
+	// The depth is in raw units of tokenID, of which rate is worth one numeraire unit.
+	value, _ := new(big.Rat).SetFrac(depth.Depth, rate).Float64()
+	return value
+}
+
+func pow10(decimals uint8) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+}
+
+// median returns the median of values, averaging the middle two for an even count.
+// values is sorted in place.
+func median(values []*big.Float) *big.Float {
+	sort.Slice(values, func(i, j int) bool { return values[i].Cmp(values[j]) < 0 })
+	mid := len(values) / 2
+	if len(values)%2 == 1 {
+		return values[mid]
+	}
+	sum := new(big.Float).Add(values[mid-1], values[mid])
+	return sum.Quo(sum, big.NewFloat(2))
+}
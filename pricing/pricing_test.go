@@ -0,0 +1,125 @@
+package pricing
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/defistate/defistate-client-go/addr"
+	"github.com/defistate/defistate-client-go/chains"
+	"github.com/defistate/defistate-client-go/chains/ethereum/grapher"
+	"github.com/defistate/defistate-client-go/engine"
+	"github.com/defistate/defistate-client-go/protocols/poolregistry"
+	poolregistryindexer "github.com/defistate/defistate-client-go/protocols/poolregistry/indexer"
+	"github.com/defistate/defistate-client-go/protocols/tokenpoolregistry"
+	"github.com/defistate/defistate-client-go/protocols/tokenregistry"
+	tokenregistryindexer "github.com/defistate/defistate-client-go/protocols/tokenregistry/indexer"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	uniswapv2indexer "github.com/defistate/defistate-client-go/protocols/uniswapv2/indexer"
+	uniswapv3indexer "github.com/defistate/defistate-client-go/protocols/uniswapv3/indexer"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	usdc uint64 = iota + 1
+	usdt
+	weth
+	dust
+	orphan
+)
+
+func bigInt(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("invalid big int " + s)
+	}
+	return n
+}
+
+var testTokens = []tokenregistry.Token{
+	{ID: usdc, Symbol: "USDC", Decimals: 6},
+	{ID: usdt, Symbol: "USDT", Decimals: 6},
+	{ID: weth, Symbol: "WETH", Decimals: 18},
+	{ID: dust, Symbol: "DUST", Decimals: 18},
+	{ID: orphan, Symbol: "ORPHAN", Decimals: 18},
+}
+
+// testGraph prices WETH at 3000 against both stables, and DUST at 0.3 through a shallow
+// WETH pool. ORPHAN has no pools.
+func testGraph(t *testing.T) chains.TokenPoolGraph {
+	t.Helper()
+	pools := []uniswapv2.Pool{
+		{ID: 10, Token0: usdc, Token1: weth, Reserve0: bigInt("3000000000000"), Reserve1: bigInt("1000000000000000000000"), FeeBps: 30},
+		{ID: 11, Token0: usdt, Token1: weth, Reserve0: bigInt("3000000000000"), Reserve1: bigInt("1000000000000000000000"), FeeBps: 30},
+		{ID: 12, Token0: weth, Token1: dust, Reserve0: bigInt("10000000000000000"), Reserve1: bigInt("100000000000000000000"), FeeBps: 30},
+	}
+
+	tokens := make([]tokenregistry.Token, len(testTokens))
+	for i, token := range testTokens {
+		token.Address = addr.FromCommon(common.BigToAddress(new(big.Int).SetUint64(token.ID)))
+		tokens[i] = token
+	}
+	tokenPools := tokenpoolregistry.NewTokenPoolSystem(0)
+	registry := poolregistry.PoolRegistry{Protocols: map[uint16]engine.ProtocolID{0: "uniswap_v2"}}
+	for _, pool := range pools {
+		tokenPools.AddPool([]uint64{pool.Token0, pool.Token1}, pool.ID)
+		key := poolregistry.AddressToPoolKey(addr.FromCommon(common.BigToAddress(new(big.Int).SetUint64(1000 + pool.ID))))
+		registry.Pools = append(registry.Pools, poolregistry.Pool{ID: pool.ID, Key: key, Protocol: 0})
+	}
+	indexedPools := poolregistryindexer.NewIndexablePoolRegistry(registry)
+
+	graph, err := grapher.NewGraph(
+		tokenPools.View(),
+		tokenregistryindexer.NewIndexableTokenSystem(tokens),
+		indexedPools,
+		uniswapv2indexer.NewIndexableUniswapV2System(pools),
+		uniswapv3indexer.NewIndexableUniswapV3System(nil),
+		map[uint64]struct{}{10: {}, 11: {}, 12: {}},
+		chains.NewProtocolResolver(map[engine.ProtocolID]engine.ProtocolSchema{"uniswap_v2": uniswapv2.Schema}, indexedPools),
+	)
+	require.NoError(t, err)
+	return graph
+}
+
+func TestOracle_Prices(t *testing.T) {
+	for _, concurrency := range []int{0, 4} {
+		oracle, err := NewOracle(Config{AnchorTokenIDs: []uint64{usdc, usdt}, Concurrency: concurrency})
+		require.NoError(t, err)
+		prices, err := oracle.Prices(testGraph(t), testTokens)
+		require.NoError(t, err)
+
+		anchor, ok := prices.Price(usdc)
+		require.True(t, ok)
+		assert.Equal(t, 0, anchor.Value.Cmp(big.NewFloat(1)))
+		assert.Equal(t, 1.0, anchor.Confidence)
+
+		eth, ok := prices.Price(weth)
+		require.True(t, ok)
+		value, _ := eth.Value.Float64()
+		assert.InEpsilon(t, 3000, value, 0.01)
+		assert.Equal(t, 2, eth.Anchors)
+		assert.Greater(t, eth.Confidence, 0.9, "a million-dollar pool is deep")
+
+		shallow, ok := prices.Price(dust)
+		require.True(t, ok)
+		value, _ = shallow.Value.Float64()
+		assert.InEpsilon(t, 0.3, value, 0.05)
+		assert.InDelta(t, 30, shallow.Depth, 3, "the DUST pool holds about 30 USD of DUST")
+		assert.Less(t, shallow.Confidence, 0.01)
+
+		_, ok = prices.Price(orphan)
+		assert.False(t, ok, "tokens without a route are not priced")
+		assert.Len(t, prices.All(), 4)
+	}
+}
+
+func TestOracle_Errors(t *testing.T) {
+	_, err := NewOracle(Config{})
+	assert.Error(t, err)
+
+	oracle, err := NewOracle(Config{AnchorTokenIDs: []uint64{orphan + 1}})
+	require.NoError(t, err)
+	_, err = oracle.Prices(testGraph(t), testTokens)
+	assert.Error(t, err, "no anchor is in the graph")
+}
@@ -0,0 +1,76 @@
+package pancakeswap
+
+import (
+	"fmt"
+
+	"github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3"
+)
+
+// V2FeeBps is the swap fee of PancakeSwap V2 pools, 0.25%.
+const V2FeeBps = 25
+
+// V3ProtocolFeeDenominator is the denominator of PancakeSwap V3's protocol fee: each
+// token's 16 bits of slot0.feeProtocol are the protocol's cut of the swap fee in
+// hundredths of a percent.
+const V3ProtocolFeeDenominator = 10000
+
+// v3TickSpacings maps each PancakeSwap V3 fee tier, in hundredths of a bip, to its tick
+// spacing. They differ from Uniswap V3's, e.g. the 0.25% tier spaces ticks by 50.
+var v3TickSpacings = map[uint64]uint64{
+	100:   1,
+	500:   10,
+	2500:  50,
+	10000: 200,
+}
+
+// V3TickSpacing returns the tick spacing of a PancakeSwap V3 fee tier. ok is false for
+// a fee that is not a PancakeSwap V3 tier.
+func V3TickSpacing(fee uint64) (tickSpacing uint64, ok bool) {
+	tickSpacing, ok = v3TickSpacings[fee]
+	return tickSpacing, ok
+}
+
+// NormalizeV2Pools sets the fee of pools streamed without one to V2FeeBps, in place. A
+// streamed fee, zero included, is kept.
+func NormalizeV2Pools(pools []uniswapv2.Pool) {
+	for i := range pools {
+		if !pools[i].HasFeeBps() {
+			pools[i].SetFeeBps(V2FeeBps)
+		}
+	}
+}
+
+// NormalizeV3Pools sets the tick spacing of pools streamed without one from their fee
+// tier, and their protocol fee encoding to PancakeSwap's, in place. It fails on a pool
+// that has neither a tick spacing nor a known fee tier.
+func NormalizeV3Pools(pools []uniswapv3.Pool) error {
+	for i := range pools {
+		if pools[i].ProtocolFeeDenominator == 0 {
+			pools[i].ProtocolFeeDenominator = V3ProtocolFeeDenominator
+		}
+		if pools[i].TickSpacing != 0 {
+			continue
+		}
+		tickSpacing, ok := V3TickSpacing(pools[i].Fee)
+		if !ok {
+			return fmt.Errorf("pancakeswap: pool %d has unknown fee tier %d", pools[i].ID, pools[i].Fee)
+		}
+		pools[i].TickSpacing = tickSpacing
+	}
+	return nil
+}
+
+// NormalizeV2Diff normalizes the pools a diff adds or updates, in place.
+func NormalizeV2Diff(diff uniswapv2.UniswapV2SystemDiff) {
+	NormalizeV2Pools(diff.Additions)
+	NormalizeV2Pools(diff.Updates)
+}
+
+// NormalizeV3Diff normalizes the pools a diff adds or updates, in place.
+func NormalizeV3Diff(diff uniswapv3.UniswapV3SystemDiff) error {
+	if err := NormalizeV3Pools(diff.Additions); err != nil {
+		return err
+	}
+	return NormalizeV3Pools(diff.Updates)
+}
@@ -0,0 +1,40 @@
+package pancakeswap
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeV2Pools(t *testing.T) {
+	pools := []uniswapv2.Pool{
+		{ID: 1, Reserve0: big.NewInt(1), Reserve1: big.NewInt(1)},
+		{ID: 2, Reserve0: big.NewInt(1), Reserve1: big.NewInt(1), FeeBps: 17},
+		{ID: 3, Reserve0: big.NewInt(1), Reserve1: big.NewInt(1)},
+	}
+	pools[0].ClearFeeBps()
+	NormalizeV2Pools(pools)
+	assert.Equal(t, uint16(V2FeeBps), pools[0].SwapFeeBps(), "a pool streamed without a fee")
+	assert.Equal(t, uint16(17), pools[1].SwapFeeBps(), "a streamed fee is kept")
+	assert.Equal(t, uint16(0), pools[2].SwapFeeBps(), "a streamed zero fee is kept")
+}
+
+func TestNormalizeV3Pools(t *testing.T) {
+	pool := func(id, fee, tickSpacing uint64) uniswapv3.Pool {
+		return uniswapv3.Pool{PoolViewMinimal: uniswapv3.PoolViewMinimal{ID: id, Fee: fee, TickSpacing: tickSpacing}}
+	}
+
+	pools := []uniswapv3.Pool{pool(1, 2500, 0), pool(2, 100, 0), pool(3, 3000, 60)}
+	require.NoError(t, NormalizeV3Pools(pools))
+	assert.Equal(t, uint64(50), pools[0].TickSpacing)
+	assert.Equal(t, uint64(1), pools[1].TickSpacing)
+	assert.Equal(t, uint64(60), pools[2].TickSpacing, "a streamed tick spacing is kept")
+	assert.Equal(t, uint32(V3ProtocolFeeDenominator), pools[0].ProtocolFeeDenominator)
+
+	err := NormalizeV3Diff(uniswapv3.UniswapV3SystemDiff{Updates: []uniswapv3.Pool{pool(4, 3000, 0)}})
+	assert.ErrorContains(t, err, "unknown fee tier 3000")
+}
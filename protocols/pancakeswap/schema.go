@@ -0,0 +1,15 @@
+// Package pancakeswap describes the PancakeSwap V2 and V3 pools streamed for BNB Chain.
+//
+// PancakeSwap forks Uniswap: V2 pools share the Uniswap V2 layout and swap math with a
+// 0.25% fee, and V3 pools share the Uniswap V3 layout with their own fee tiers and tick
+// spacings. Pools therefore use the uniswapv2 and uniswapv3 types, differs, patchers and
+// calculators, but are streamed under their own schemas so they are not mistaken for
+// Uniswap pools. Normalize decoded pools with NormalizeV2Pools and NormalizeV3Pools.
+package pancakeswap
+
+import "github.com/defistate/defistate-client-go/engine"
+
+var (
+	V2Schema engine.ProtocolSchema = "defistate/pancakeswap-v2@v1"
+	V3Schema engine.ProtocolSchema = "defistate/pancakeswap-v3@v1"
+)
@@ -1,9 +1,9 @@
 package indexer
 
 import (
+	"github.com/defistate/defistate-client-go/addr"
 	"github.com/defistate/defistate-client-go/engine"
 	poolregistry "github.com/defistate/defistate-client-go/protocols/poolregistry"
-	"github.com/ethereum/go-ethereum/common"
 )
 
 type Indexer struct{}
@@ -59,7 +59,7 @@ func (ipr *IndexablePoolRegistry) GetByID(id uint64) (poolregistry.Pool, bool) {
 }
 
 // GetByAddress retrieves a pool by its contract address.
-func (ipr *IndexablePoolRegistry) GetByAddress(address common.Address) (poolregistry.Pool, bool) {
+func (ipr *IndexablePoolRegistry) GetByAddress(address addr.Address) (poolregistry.Pool, bool) {
 	p, ok := ipr.byKey[poolregistry.AddressToPoolKey(address)]
 	return p, ok
 }
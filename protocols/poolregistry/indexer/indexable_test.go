@@ -3,6 +3,7 @@ package indexer
 import (
 	"testing"
 
+	"github.com/defistate/defistate-client-go/addr"
 	"github.com/defistate/defistate-client-go/engine"
 	poolregistry "github.com/defistate/defistate-client-go/protocols/poolregistry"
 	"github.com/ethereum/go-ethereum/common"
@@ -12,9 +13,9 @@ import (
 
 func TestIndexablePoolRegistry(t *testing.T) {
 	// --- Test Data Setup ---
-	addr1 := common.HexToAddress("0x88e6A0c2dDD26FEEb64F039a2c41296FcB3f5640")
-	addr2 := common.HexToAddress("0x3416cF6C708Da44DB2624D63ea0AAef7113527C6")
-	nonExistentAddr := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	addr1 := addr.FromCommon(common.HexToAddress("0x88e6A0c2dDD26FEEb64F039a2c41296FcB3f5640"))
+	addr2 := addr.FromCommon(common.HexToAddress("0x3416cF6C708Da44DB2624D63ea0AAef7113527C6"))
+	nonExistentAddr := addr.FromCommon(common.HexToAddress("0x1111111111111111111111111111111111111111"))
 
 	key1 := poolregistry.AddressToPoolKey(addr1)
 	key2 := poolregistry.AddressToPoolKey(addr2)
@@ -3,6 +3,7 @@ package poolregistry
 import (
 	"testing"
 
+	"github.com/defistate/defistate-client-go/addr"
 	"github.com/defistate/defistate-client-go/engine"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/stretchr/testify/assert"
@@ -11,9 +12,9 @@ import (
 
 func TestPoolRegistryPatcher(t *testing.T) {
 	// --- Base Data ---
-	key1 := AddressToPoolKey(common.HexToAddress("0x1"))
-	key2 := AddressToPoolKey(common.HexToAddress("0x2"))
-	key3 := AddressToPoolKey(common.HexToAddress("0x3"))
+	key1 := AddressToPoolKey(addr.FromCommon(common.HexToAddress("0x1")))
+	key2 := AddressToPoolKey(addr.FromCommon(common.HexToAddress("0x2")))
+	key3 := AddressToPoolKey(addr.FromCommon(common.HexToAddress("0x3")))
 
 	protoUni := engine.ProtocolID("uniswap")
 	protoCurve := engine.ProtocolID("curve")
@@ -49,7 +50,7 @@ func TestPoolRegistryPatcher(t *testing.T) {
 	)
 
 	t.Run("Should handle Pool Additions only", func(t *testing.T) {
-		pool4 := Pool{ID: 4, Key: AddressToPoolKey(common.HexToAddress("0x4")), Protocol: 0}
+		pool4 := Pool{ID: 4, Key: AddressToPoolKey(addr.FromCommon(common.HexToAddress("0x4"))), Protocol: 0}
 
 		diff := PoolRegistryDiff{
 			PoolAdditions: []Pool{pool4},
@@ -65,7 +66,7 @@ func TestPoolRegistryPatcher(t *testing.T) {
 
 	t.Run("Should handle Pool and Protocol Additions", func(t *testing.T) {
 		// Add Pool 5 which uses a NEW protocol (ID 2: sushi)
-		pool5 := Pool{ID: 5, Key: AddressToPoolKey(common.HexToAddress("0x5")), Protocol: 2}
+		pool5 := Pool{ID: 5, Key: AddressToPoolKey(addr.FromCommon(common.HexToAddress("0x5"))), Protocol: 2}
 
 		diff := PoolRegistryDiff{
 			PoolAdditions:     []Pool{pool5},
@@ -119,7 +120,7 @@ func TestPoolRegistryPatcher(t *testing.T) {
 		// 3. Delete Protocol 1 (Curve - cleanup)
 		// 4. Add Protocol 2 (Sushi)
 
-		pool4 := Pool{ID: 4, Key: AddressToPoolKey(common.HexToAddress("0x4")), Protocol: 2}
+		pool4 := Pool{ID: 4, Key: AddressToPoolKey(addr.FromCommon(common.HexToAddress("0x4"))), Protocol: 2}
 
 		diff := PoolRegistryDiff{
 			PoolDeletions:     []uint64{2},
@@ -6,7 +6,7 @@ import (
 	"errors"
 	"strings"
 
-	"github.com/ethereum/go-ethereum/common"
+	"github.com/defistate/defistate-client-go/addr"
 )
 
 var empty12Bytes = make([]byte, 12)
@@ -91,9 +91,9 @@ func (p *PoolKey) UnmarshalJSON(data []byte) error {
 //
 //	[0..11]  = 0x00 padding
 //	[12..31] = address (20 bytes)
-func AddressToPoolKey(addr common.Address) PoolKey {
+func AddressToPoolKey(address addr.Address) PoolKey {
 	var key PoolKey
-	copy(key[12:], addr[:])
+	copy(key[12:], address[:])
 	return key
 }
 
@@ -114,14 +114,14 @@ func AddressToPoolKey(addr common.Address) PoolKey {
 //     for cryptographic hashes.
 //
 // Returns an error if the PoolKey does not conform to the ABI address shape.
-func (p PoolKey) ToAddress() (common.Address, error) {
+func (p PoolKey) ToAddress() (addr.Address, error) {
 	// confirm that first 12 bytes are zero
 	for _, b := range p[:12] {
 		if b != 0 {
-			return common.Address{}, errors.New("pool key is not an ABI-encoded Ethereum address")
+			return addr.Address{}, errors.New("pool key is not an ABI-encoded Ethereum address")
 		}
 	}
-	return common.Address(p[12:32]), nil
+	return addr.Address(p[12:32]), nil
 }
 
 func Bytes32ToPoolKey(b [32]byte) PoolKey {
@@ -4,6 +4,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/defistate/defistate-client-go/addr"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -12,7 +13,7 @@ import (
 func TestPoolKey(t *testing.T) {
 	// Standard Ethereum address (20 bytes)
 	addrHex := "0x0000000000000000000000000000000000000001"
-	addr := common.HexToAddress(addrHex)
+	address := addr.FromCommon(common.HexToAddress(addrHex))
 
 	// 32-byte hash
 	hashHex := "0x0000000000000000000000000000000000000000000000000000000000000002"
@@ -25,18 +26,18 @@ func TestPoolKey(t *testing.T) {
 	copy(hashArr[:], hashBytes)
 
 	t.Run("AddressToPoolKey_ABIAligned", func(t *testing.T) {
-		key := AddressToPoolKey(addr)
+		key := AddressToPoolKey(address)
 
 		// ABI layout for address in a 32-byte word:
 		// [0..11]  = 0x00 padding
 		// [12..31] = address (20 bytes)
 		assert.Equal(t, make([]byte, 12), key[:12], "first 12 bytes should be zero padding")
-		assert.Equal(t, addr.Bytes(), key[12:32], "last 20 bytes should match address")
+		assert.Equal(t, address.Bytes(), key[12:32], "last 20 bytes should match address")
 
 		// Round-trip extraction
 		gotAddr, err := key.ToAddress()
 		require.NoError(t, err)
-		assert.Equal(t, addr, gotAddr, "ToAddress should round-trip the original address")
+		assert.Equal(t, address, gotAddr, "ToAddress should round-trip the original address")
 
 		// Verify string representation
 		str := key.String()
@@ -63,7 +64,7 @@ func TestPoolKey(t *testing.T) {
 	})
 
 	t.Run("JSON_Marshaling_RoundTrip", func(t *testing.T) {
-		key := AddressToPoolKey(addr)
+		key := AddressToPoolKey(address)
 
 		// Marshal to JSON
 		jsonBytes, err := key.MarshalJSON()
@@ -0,0 +1,324 @@
+package solidly
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+
+	solidly "github.com/defistate/defistate-client-go/protocols/solidly"
+)
+
+var (
+	// ErrInvalidAmount is returned when an input amount is nil or negative.
+	ErrInvalidAmount = errors.New("amount must be non-nil and non-negative")
+	// ErrTokenMismatch is returned when the specified input/output tokens do not match the pool's tokens.
+	ErrTokenMismatch = errors.New("token mismatch")
+	// ErrNoConvergence is returned when the stable curve cannot be solved, which the pool
+	// contract reports by reverting.
+	ErrNoConvergence = errors.New("stable curve did not converge")
+
+	// basisPointDivisor is a constant representing 100% in basis points (10000).
+	basisPointDivisor = big.NewInt(10_000)
+	e18               = big.NewInt(1_000_000_000_000_000_000)
+)
+
+// maxNewtonIterations matches the loop bound of the pool contract's _get_y.
+const maxNewtonIterations = 255
+
+// GetReserves returns the reserves for the given token pair.
+func GetReserves(tokenInID, tokenOutID uint64, pool solidly.Pool) (reserveIn, reserveOut *big.Int, err error) {
+	if tokenInID == pool.Token0 && tokenOutID == pool.Token1 {
+		return pool.Reserve0, pool.Reserve1, nil
+	} else if tokenInID == pool.Token1 && tokenOutID == pool.Token0 {
+		return pool.Reserve1, pool.Reserve0, nil
+	}
+	return nil, nil, fmt.Errorf("%w: pool %d does not contain the pair %d -> %d", ErrTokenMismatch, pool.ID, tokenInID, tokenOutID)
+}
+
+// GetAmountOut calculates the output amount for a swap exactly as the pool contract's
+// getAmountOut does: the fee is taken from the input, then the input is swapped along
+// the constant-product curve for volatile pools or the x3y+y3x curve for stable pools.
+func GetAmountOut(amountIn *big.Int, tokenInID, tokenOutID uint64, pool solidly.Pool) (*big.Int, error) {
+	if amountIn == nil || amountIn.Sign() < 0 {
+		return nil, ErrInvalidAmount
+	}
+	reserveIn, reserveOut, err := GetReserves(tokenInID, tokenOutID, pool)
+	if err != nil {
+		return nil, err
+	}
+	if reserveIn == nil || reserveOut == nil || reserveIn.Sign() <= 0 || reserveOut.Sign() <= 0 {
+		return new(big.Int), nil
+	}
+
+	fee := new(big.Int).Mul(amountIn, big.NewInt(int64(pool.FeeBps)))
+	fee.Quo(fee, basisPointDivisor)
+	amountIn = new(big.Int).Sub(amountIn, fee)
+
+	if !pool.Stable {
+		// amountIn * reserveOut / (reserveIn + amountIn)
+		numerator := new(big.Int).Mul(amountIn, reserveOut)
+		return numerator.Quo(numerator, new(big.Int).Add(reserveIn, amountIn)), nil
+	}
+
+	scaleIn, scaleOut := scale(pool.Decimals0), scale(pool.Decimals1)
+	if tokenInID != pool.Token0 {
+		scaleIn, scaleOut = scaleOut, scaleIn
+	}
+	scale0, scale1 := scale(pool.Decimals0), scale(pool.Decimals1)
+	xy := stableK(pool.Reserve0, pool.Reserve1, scale0, scale1)
+
+	reserveA := normalize(reserveIn, scaleIn)
+	reserveB := normalize(reserveOut, scaleOut)
+	x0 := new(big.Int).Add(normalize(amountIn, scaleIn), reserveA)
+
+	y, err := getY(x0, xy, reserveB, scale0, scale1)
+	if err != nil {
+		return nil, fmt.Errorf("pool %d: %w", pool.ID, err)
+	}
+	out := new(big.Int).Sub(reserveB, y)
+	if out.Sign() < 0 {
+		return new(big.Int), nil
+	}
+	out.Mul(out, scaleOut)
+	return out.Quo(out, e18), nil
+}
+
+// scale returns 10^decimals, the pool contract's decimals0 and decimals1.
+func scale(decimals uint8) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+}
+
+// normalize converts a raw amount to 18 decimals.
+func normalize(amount, scale *big.Int) *big.Int {
+	n := new(big.Int).Mul(amount, e18)
+	return n.Quo(n, scale)
+}
+
+// stableK is the contract's _k for stable pools: x3y+y3x over reserves normalized to
+// 18 decimals.
+func stableK(x, y, scale0, scale1 *big.Int) *big.Int {
+	return f(normalize(x, scale0), normalize(y, scale1))
+}
+
+// f is the contract's _f: x0*y*(x0^2+y^2) in 18-decimal fixed point.
+func f(x0, y *big.Int) *big.Int {
+	a := new(big.Int).Mul(x0, y)
+	a.Quo(a, e18)
+	x2 := new(big.Int).Mul(x0, x0)
+	x2.Quo(x2, e18)
+	y2 := new(big.Int).Mul(y, y)
+	y2.Quo(y2, e18)
+	b := x2.Add(x2, y2)
+	a.Mul(a, b)
+	return a.Quo(a, e18)
+}
+
+// d is the contract's _d, the derivative of f in y.
+func d(x0, y *big.Int) *big.Int {
+	y2 := new(big.Int).Mul(y, y)
+	y2.Quo(y2, e18)
+	left := new(big.Int).Mul(big.NewInt(3), x0)
+	left.Mul(left, y2)
+	left.Quo(left, e18)
+	x3 := new(big.Int).Mul(x0, x0)
+	x3.Quo(x3, e18)
+	x3.Mul(x3, x0)
+	x3.Quo(x3, e18)
+	return left.Add(left, x3)
+}
+
+// getY is the contract's _get_y: it solves f(x0, y) = xy for y by Newton's method,
+// starting from y. Like the contract, its convergence check re-applies the decimal
+// normalization of _k to the already normalized x0 and y+1, so quotes match on-chain.
+func getY(x0, xy, y, scale0, scale1 *big.Int) (*big.Int, error) {
+	y = new(big.Int).Set(y)
+	one := big.NewInt(1)
+	for i := 0; i < maxNewtonIterations; i++ {
+		k := f(x0, y)
+		derivative := d(x0, y)
+		if derivative.Sign() == 0 {
+			return nil, ErrNoConvergence
+		}
+		if k.Cmp(xy) < 0 {
+			dy := new(big.Int).Sub(xy, k)
+			dy.Mul(dy, e18)
+			dy.Quo(dy, derivative)
+			if dy.Sign() == 0 {
+				if k.Cmp(xy) == 0 {
+					return y, nil
+				}
+				if stableK(x0, new(big.Int).Add(y, one), scale0, scale1).Cmp(xy) > 0 {
+					return y.Add(y, one), nil
+				}
+				dy.SetInt64(1)
+			}
+			y.Add(y, dy)
+		} else {
+			dy := new(big.Int).Sub(k, xy)
+			dy.Mul(dy, e18)
+			dy.Quo(dy, derivative)
+			if dy.Sign() == 0 {
+				if k.Cmp(xy) == 0 || f(x0, new(big.Int).Sub(y, one)).Cmp(xy) < 0 {
+					return y, nil
+				}
+				dy.SetInt64(1)
+			}
+			y.Sub(y, dy)
+		}
+	}
+	return nil, ErrNoConvergence
+}
+
+// FloatQuoter returns a float64 approximation of GetAmountOut for fast route search.
+// The reserves are converted once, when the function is built.
+func FloatQuoter(pool solidly.Pool) func(amountIn float64, tokenInID, tokenOutID uint64) (float64, error) {
+	reserve0, reserve1 := toFloat(pool.Reserve0), toFloat(pool.Reserve1)
+	scale0, scale1 := math.Pow10(int(pool.Decimals0)), math.Pow10(int(pool.Decimals1))
+	feeMultiplier := float64(10_000-int64(pool.FeeBps)) / 10_000
+
+	return func(amountIn float64, tokenInID, tokenOutID uint64) (float64, error) {
+		reserveIn, reserveOut, scaleIn, scaleOut := reserve0, reserve1, scale0, scale1
+		switch {
+		case tokenInID == pool.Token0 && tokenOutID == pool.Token1:
+		case tokenInID == pool.Token1 && tokenOutID == pool.Token0:
+			reserveIn, reserveOut, scaleIn, scaleOut = reserve1, reserve0, scale1, scale0
+		default:
+			return 0, fmt.Errorf("pool does not trade token %d for token %d", tokenInID, tokenOutID)
+		}
+		if reserveIn <= 0 || reserveOut <= 0 {
+			return 0, nil
+		}
+		amountIn *= feeMultiplier
+		if !pool.Stable {
+			return amountIn * reserveOut / (reserveIn + amountIn), nil
+		}
+
+		// Work in whole tokens, where the curve is x3y+y3x.
+		x, y := reserveIn/scaleIn, reserveOut/scaleOut
+		k := x * y * (x*x + y*y)
+		x0 := x + amountIn/scaleIn
+		newY := y
+		for i := 0; i < maxNewtonIterations; i++ {
+			fy := x0*newY*(x0*x0+newY*newY) - k
+			dy := 3*x0*newY*newY + x0*x0*x0
+			step := fy / dy
+			newY -= step
+			if math.Abs(step) <= 1e-12*newY {
+				break
+			}
+		}
+		if newY >= y {
+			return 0, nil
+		}
+		return (y - newY) * scaleOut, nil
+	}
+}
+
+// toFloat converts x to the nearest float64; nil converts to zero.
+func toFloat(x *big.Int) float64 {
+	if x == nil {
+		return 0
+	}
+	f, _ := new(big.Float).SetInt(x).Float64()
+	return f
+}
+
+// DepthPoint is a point of a pool's depth curve.
+type DepthPoint struct {
+	AmountIn  *big.Int
+	AmountOut *big.Int
+	// MarginalPrice is the raw price of tokenIn in tokenOut once the swap is done, fees
+	// excluded: the slope of the pool's curve at the new reserves.
+	MarginalPrice *big.Float
+}
+
+// DepthCurve quotes points input sizes, spaced evenly in log scale from minAmountIn to
+// maxAmountIn, and returns the output and the marginal price after each swap. The fee
+// leaves the pool, so only the rest of the input joins the reserves. For stable pools
+// the marginal price is the slope of x3y+y3x=k rather than the reserve ratio.
+func DepthCurve(minAmountIn, maxAmountIn *big.Int, points int, tokenInID, tokenOutID uint64, pool solidly.Pool) ([]DepthPoint, error) {
+	amountsIn, err := logSpacedAmounts(minAmountIn, maxAmountIn, points)
+	if err != nil {
+		return nil, err
+	}
+	reserveIn, reserveOut, err := GetReserves(tokenInID, tokenOutID, pool)
+	if err != nil {
+		return nil, err
+	}
+	if reserveIn == nil || reserveOut == nil || reserveIn.Sign() <= 0 || reserveOut.Sign() <= 0 {
+		return nil, fmt.Errorf("pool %d is empty", pool.ID)
+	}
+	scaleIn, scaleOut := scale(pool.Decimals0), scale(pool.Decimals1)
+	if tokenInID != pool.Token0 {
+		scaleIn, scaleOut = scaleOut, scaleIn
+	}
+
+	curve := make([]DepthPoint, len(amountsIn))
+	for i, amountIn := range amountsIn {
+		amountOut, err := GetAmountOut(amountIn, tokenInID, tokenOutID, pool)
+		if err != nil {
+			return nil, err
+		}
+		fee := new(big.Int).Mul(amountIn, big.NewInt(int64(pool.FeeBps)))
+		fee.Quo(fee, basisPointDivisor)
+		x := new(big.Int).Add(reserveIn, amountIn)
+		x.Sub(x, fee)
+		y := new(big.Int).Sub(reserveOut, amountOut)
+		curve[i] = DepthPoint{AmountIn: amountIn, AmountOut: amountOut, MarginalPrice: marginalPrice(x, y, scaleIn, scaleOut, pool.Stable)}
+	}
+	return curve, nil
+}
+
+// marginalPrice returns the raw price of the input token at reserves x (in) and y (out).
+// On the stable curve it is (3x²y+y³)/(x³+3xy²) over the 18-decimal normalized reserves,
+// scaled back to raw units.
+func marginalPrice(x, y, scaleIn, scaleOut *big.Int, stable bool) *big.Float {
+	if !stable {
+		return new(big.Float).Quo(new(big.Float).SetInt(y), new(big.Float).SetInt(x))
+	}
+	xn := new(big.Float).Quo(new(big.Float).SetInt(x), new(big.Float).SetInt(scaleIn))
+	yn := new(big.Float).Quo(new(big.Float).SetInt(y), new(big.Float).SetInt(scaleOut))
+	x2 := new(big.Float).Mul(xn, xn)
+	y2 := new(big.Float).Mul(yn, yn)
+
+	numerator := new(big.Float).Mul(big.NewFloat(3), x2)
+	numerator.Add(numerator, y2)
+	numerator.Mul(numerator, yn)
+	denominator := new(big.Float).Mul(big.NewFloat(3), y2)
+	denominator.Add(denominator, x2)
+	denominator.Mul(denominator, xn)
+
+	price := numerator.Quo(numerator, denominator)
+	price.Mul(price, new(big.Float).SetInt(scaleOut))
+	return price.Quo(price, new(big.Float).SetInt(scaleIn))
+}
+
+// logSpacedAmounts returns points amounts from minAmount to maxAmount, both included,
+// whose logarithms are evenly spaced.
+func logSpacedAmounts(minAmount, maxAmount *big.Int, points int) ([]*big.Int, error) {
+	if minAmount == nil || maxAmount == nil || minAmount.Sign() <= 0 || maxAmount.Cmp(minAmount) < 0 {
+		return nil, fmt.Errorf("%w: the range must satisfy 0 < min <= max", ErrInvalidAmount)
+	}
+	if points < 2 {
+		return nil, fmt.Errorf("a depth curve needs at least 2 points, got %d", points)
+	}
+	minFloat, _ := new(big.Float).SetInt(minAmount).Float64()
+	maxFloat, _ := new(big.Float).SetInt(maxAmount).Float64()
+	step := math.Log(maxFloat/minFloat) / float64(points-1)
+
+	amounts := make([]*big.Int, points)
+	amounts[0] = new(big.Int).Set(minAmount)
+	for i := 1; i < points-1; i++ {
+		amount, _ := big.NewFloat(minFloat * math.Exp(step*float64(i))).Int(nil)
+		// Rounding can step out of order on ranges narrower than the number of points.
+		if amount.Cmp(amounts[i-1]) < 0 {
+			amount.Set(amounts[i-1])
+		} else if amount.Cmp(maxAmount) > 0 {
+			amount.Set(maxAmount)
+		}
+		amounts[i] = amount
+	}
+	amounts[points-1] = new(big.Int).Set(maxAmount)
+	return amounts, nil
+}
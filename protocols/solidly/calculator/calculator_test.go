@@ -0,0 +1,150 @@
+package solidly
+
+import (
+	"math/big"
+	"testing"
+
+	solidly "github.com/defistate/defistate-client-go/protocols/solidly"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// units returns n whole tokens of the given decimals.
+func units(n int64, decimals uint8) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), scale(decimals))
+}
+
+func toF(x *big.Int) float64 {
+	f, _ := new(big.Float).SetInt(x).Float64()
+	return f
+}
+
+// newStablePool is a balanced USDC (6 decimals) / DAI (18 decimals) stable pool.
+func newStablePool() solidly.Pool {
+	return solidly.Pool{
+		ID: 1, Token0: 10, Token1: 11,
+		Reserve0: units(1_000_000, 6), Reserve1: units(1_000_000, 18),
+		Stable: true, FeeBps: 5, Decimals0: 6, Decimals1: 18,
+	}
+}
+
+func TestGetAmountOutVolatile(t *testing.T) {
+	pool := solidly.Pool{
+		ID: 2, Token0: 10, Token1: 11,
+		Reserve0: big.NewInt(1_000_000), Reserve1: big.NewInt(2_000_000),
+		FeeBps: 30, Decimals0: 18, Decimals1: 18,
+	}
+
+	out, err := GetAmountOut(big.NewInt(10_000), 10, 11, pool)
+	require.NoError(t, err)
+	// 10_000 - 30 fee = 9_970; 9_970 * 2_000_000 / 1_009_970 = 19_743
+	assert.Equal(t, big.NewInt(19_743), out)
+
+	out, err = GetAmountOut(big.NewInt(10_000), 11, 10, pool)
+	require.NoError(t, err)
+	// 9_970 * 1_000_000 / 2_009_970 = 4_960
+	assert.Equal(t, big.NewInt(4_960), out)
+}
+
+func TestGetAmountOutStable(t *testing.T) {
+	pool := newStablePool()
+
+	t.Run("trades near par with little slippage", func(t *testing.T) {
+		amountIn := units(10_000, 6) // 10k USDC
+		out, err := GetAmountOut(amountIn, 10, 11, pool)
+		require.NoError(t, err)
+
+		afterFee := 10_000 * (1 - 0.0005)
+		got := toF(out) / 1e18
+		assert.Less(t, got, afterFee)
+		assert.InDelta(t, afterFee, got, 0.01, "a 1%% trade on the stable curve barely moves the price")
+
+		// The same reserves on the constant-product curve give far less.
+		volatile := pool
+		volatile.Stable = false
+		volatileOut, err := GetAmountOut(amountIn, 10, 11, volatile)
+		require.NoError(t, err)
+		assert.Equal(t, 1, out.Cmp(volatileOut))
+	})
+
+	t.Run("is symmetric across decimals", func(t *testing.T) {
+		forward, err := GetAmountOut(units(1_000, 6), 10, 11, pool)
+		require.NoError(t, err)
+		backward, err := GetAmountOut(units(1_000, 18), 11, 10, pool)
+		require.NoError(t, err)
+		assert.InDelta(t, toF(forward)/1e18, toF(backward)/1e6, 1e-6)
+	})
+
+	t.Run("never pays out more than the reserve", func(t *testing.T) {
+		out, err := GetAmountOut(units(1_000_000_000, 6), 10, 11, pool)
+		require.NoError(t, err)
+		assert.Equal(t, -1, out.Cmp(pool.Reserve1))
+	})
+
+	t.Run("zero in gives zero out", func(t *testing.T) {
+		out, err := GetAmountOut(big.NewInt(0), 10, 11, pool)
+		require.NoError(t, err)
+		assert.Zero(t, out.Sign())
+	})
+}
+
+func TestGetAmountOutErrors(t *testing.T) {
+	pool := newStablePool()
+	_, err := GetAmountOut(nil, 10, 11, pool)
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+	_, err = GetAmountOut(big.NewInt(-1), 10, 11, pool)
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+	_, err = GetAmountOut(big.NewInt(1), 10, 12, pool)
+	assert.ErrorIs(t, err, ErrTokenMismatch)
+}
+
+func TestFloatQuoter(t *testing.T) {
+	for _, stable := range []bool{true, false} {
+		pool := newStablePool()
+		pool.Stable = stable
+		quote := FloatQuoter(pool)
+
+		for _, amountIn := range []*big.Int{units(100, 6), units(50_000, 6)} {
+			exact, err := GetAmountOut(amountIn, 10, 11, pool)
+			require.NoError(t, err)
+			approx, err := quote(toF(amountIn), 10, 11)
+			require.NoError(t, err)
+			assert.InEpsilon(t, toF(exact), approx, 1e-9, "stable=%v amountIn=%s", stable, amountIn)
+		}
+
+		_, err := quote(1, 10, 12)
+		assert.Error(t, err)
+	}
+}
+
+func TestDepthCurve(t *testing.T) {
+	pool := newStablePool()
+	curve, err := DepthCurve(units(1, 6), units(500_000, 6), 6, 10, 11, pool)
+	require.NoError(t, err)
+	require.Len(t, curve, 6)
+
+	// A balanced stable pool prices USDC at one DAI until it is pushed hard.
+	price, _ := curve[0].MarginalPrice.Float64()
+	assert.InEpsilon(t, 1e12, price, 1e-6)
+	for i, point := range curve {
+		out, err := GetAmountOut(point.AmountIn, 10, 11, pool)
+		require.NoError(t, err)
+		assert.Equal(t, out, point.AmountOut)
+		if i > 0 {
+			assert.Equal(t, -1, point.MarginalPrice.Cmp(curve[i-1].MarginalPrice))
+		}
+
+		// The marginal price is the slope of the quote, net of the fee.
+		delta := new(big.Int).Div(point.AmountIn, big.NewInt(10_000))
+		next, err := GetAmountOut(new(big.Int).Add(point.AmountIn, delta), 10, 11, pool)
+		require.NoError(t, err)
+		slope := toF(new(big.Int).Sub(next, out)) / (toF(delta) * 0.9995)
+		price, _ := point.MarginalPrice.Float64()
+		assert.InEpsilon(t, slope, price, 1e-3, "amount %s", point.AmountIn)
+	}
+
+	_, err = DepthCurve(units(2, 6), units(1, 6), 6, 10, 11, pool)
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+	_, err = DepthCurve(units(1, 6), units(2, 6), 1, 10, 11, pool)
+	assert.Error(t, err)
+}
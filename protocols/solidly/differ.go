@@ -0,0 +1,82 @@
+package solidly
+
+import (
+	"math/big"
+)
+
+type SolidlySystemDiff struct {
+	Additions []Pool   `json:"additions,omitempty"`
+	Updates   []Pool   `json:"updates,omitempty"`
+	Deletions []uint64 `json:"deletions,omitempty"`
+}
+
+// IsEmpty returns true if the diff contains no changes.
+func (d SolidlySystemDiff) IsEmpty() bool {
+	return len(d.Additions) == 0 && len(d.Updates) == 0 && len(d.Deletions) == 0
+}
+
+// poolChanged compares the fields that can change after a pool is created.
+func poolChanged(old, new Pool) bool {
+	if old.Reserve0.Cmp(new.Reserve0) != 0 || old.Reserve1.Cmp(new.Reserve1) != 0 {
+		return true
+	}
+	if old.FeeBps != new.FeeBps {
+		return true
+	}
+	return optionalUint64Changed(old.LastUpdatedBlock, new.LastUpdatedBlock)
+}
+
+// optionalUint64Changed compares fields that may be absent from the stream.
+func optionalUint64Changed(old, new *uint64) bool {
+	if old == nil || new == nil {
+		return old != new
+	}
+	return *old != *new
+}
+
+// Differ calculates the difference between two states of Solidly pools, matching pools
+// by ID.
+func Differ(old, new []Pool) SolidlySystemDiff {
+	oldPoolsMap := make(map[uint64]Pool, len(old))
+	for _, pool := range old {
+		oldPoolsMap[pool.ID] = pool
+	}
+
+	newPoolsMap := make(map[uint64]Pool, len(new))
+	for _, pool := range new {
+		newPoolsMap[pool.ID] = pool
+	}
+
+	var additions []Pool
+	var updates []Pool
+	var deletions []uint64
+
+	for newID, newPool := range newPoolsMap {
+		oldPool, exists := oldPoolsMap[newID]
+		if !exists {
+			additions = append(additions, newPool)
+		} else if poolChanged(oldPool, newPool) {
+			updates = append(updates, newPool)
+		}
+	}
+
+	for oldID := range oldPoolsMap {
+		if _, exists := newPoolsMap[oldID]; !exists {
+			deletions = append(deletions, oldID)
+		}
+	}
+
+	return SolidlySystemDiff{
+		Additions: additions,
+		Updates:   updates,
+		Deletions: deletions,
+	}
+}
+
+// copyOptionalBigInt copies a *big.Int that is allowed to be nil.
+func copyOptionalBigInt(b *big.Int) *big.Int {
+	if b == nil {
+		return nil
+	}
+	return new(big.Int).Set(b)
+}
@@ -0,0 +1,48 @@
+package solidly
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPool(id uint64, reserve0, reserve1 int64, stable bool) Pool {
+	return Pool{ID: id, Token0: 1, Token1: 2, Reserve0: big.NewInt(reserve0), Reserve1: big.NewInt(reserve1), Stable: stable, FeeBps: 5, Decimals0: 6, Decimals1: 18}
+}
+
+func TestDiffer(t *testing.T) {
+	pool1 := newTestPool(1, 1000, 2000, true)
+	pool2 := newTestPool(2, 3000, 4000, false)
+
+	t.Run("additions and deletions", func(t *testing.T) {
+		diff := Differ([]Pool{pool1}, []Pool{pool2})
+		require.Len(t, diff.Additions, 1)
+		assert.Equal(t, uint64(2), diff.Additions[0].ID)
+		assert.Equal(t, []uint64{1}, diff.Deletions)
+		assert.Empty(t, diff.Updates)
+	})
+
+	t.Run("no changes", func(t *testing.T) {
+		assert.True(t, Differ([]Pool{pool1, pool2}, []Pool{newTestPool(1, 1000, 2000, true), pool2}).IsEmpty())
+	})
+
+	for _, tc := range []struct {
+		name   string
+		change func(p *Pool)
+	}{
+		{name: "reserve0", change: func(p *Pool) { p.Reserve0 = big.NewInt(1001) }},
+		{name: "reserve1", change: func(p *Pool) { p.Reserve1 = big.NewInt(1999) }},
+		{name: "fee", change: func(p *Pool) { p.FeeBps = 4 }},
+	} {
+		t.Run("update on "+tc.name, func(t *testing.T) {
+			updated := pool1
+			tc.change(&updated)
+			diff := Differ([]Pool{pool1}, []Pool{updated})
+			require.Len(t, diff.Updates, 1)
+			assert.Empty(t, diff.Additions)
+			assert.Empty(t, diff.Deletions)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package indexer
+
+import (
+	solidly "github.com/defistate/defistate-client-go/protocols/solidly"
+)
+
+// Indexer is a concrete implementation of the chains.SolidlyIndexer interface.
+type Indexer struct{}
+
+// New creates a new Indexer.
+func New() *Indexer {
+	return &Indexer{}
+}
+
+// Index creates an indexed Solidly system from a raw slice of pools.
+func (i *Indexer) Index(pools []solidly.Pool) IndexedSolidly {
+	return NewIndexableSolidlySystem(pools)
+}
+
+// IndexableSolidlySystem provides fast, indexed access to Solidly pool data.
+type IndexableSolidlySystem struct {
+	byID map[uint64]solidly.Pool
+	all  []solidly.Pool
+}
+
+// NewIndexableSolidlySystem creates a new indexed Solidly system.
+func NewIndexableSolidlySystem(pools []solidly.Pool) *IndexableSolidlySystem {
+	byID := make(map[uint64]solidly.Pool, len(pools))
+	for _, p := range pools {
+		byID[p.ID] = p
+	}
+	return &IndexableSolidlySystem{
+		byID: byID,
+		all:  pools,
+	}
+}
+
+// GetByID retrieves a pool by its unique ID.
+func (is *IndexableSolidlySystem) GetByID(id uint64) (solidly.Pool, bool) {
+	p, ok := is.byID[id]
+	return p, ok
+}
+
+// All returns a defensive copy of the slice of all pools.
+func (is *IndexableSolidlySystem) All() []solidly.Pool {
+	allCopy := make([]solidly.Pool, len(is.all))
+	copy(allCopy, is.all)
+	return allCopy
+}
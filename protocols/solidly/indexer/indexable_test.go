@@ -0,0 +1,33 @@
+package indexer
+
+import (
+	"math/big"
+	"testing"
+
+	solidly "github.com/defistate/defistate-client-go/protocols/solidly"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexableSolidlySystem(t *testing.T) {
+	pools := []solidly.Pool{
+		{ID: 301, Token0: 0, Token1: 1, Reserve0: big.NewInt(100), Reserve1: big.NewInt(200), Stable: true},
+		{ID: 302, Token0: 1, Token1: 2, Reserve0: big.NewInt(300), Reserve1: big.NewInt(400)},
+	}
+	indexed := New().Index(pools)
+
+	pool, found := indexed.GetByID(301)
+	require.True(t, found)
+	assert.True(t, pool.Stable)
+
+	_, found = indexed.GetByID(999)
+	assert.False(t, found)
+
+	all := indexed.All()
+	require.Len(t, all, 2)
+	all[0].ID = 0
+	pool, _ = indexed.GetByID(301)
+	assert.Equal(t, uint64(301), pool.ID, "All returns a copy")
+
+	assert.NotNil(t, NewIndexableSolidlySystem(nil).All())
+}
@@ -0,0 +1,10 @@
+package indexer
+
+import solidly "github.com/defistate/defistate-client-go/protocols/solidly"
+
+// IndexedSolidly provides a read-only view of all indexed Solidly-style pools, stable
+// and volatile, merged across the forks a chain carries.
+type IndexedSolidly interface {
+	GetByID(id uint64) (solidly.Pool, bool)
+	All() []solidly.Pool
+}
@@ -0,0 +1,41 @@
+package solidly
+
+// deepCopyPool creates a new Pool with its own memory for all pointer types.
+func deepCopyPool(p Pool) Pool {
+	newPool := p
+	newPool.Reserve0 = copyOptionalBigInt(p.Reserve0)
+	newPool.Reserve1 = copyOptionalBigInt(p.Reserve1)
+	if p.LastUpdatedBlock != nil {
+		block := *p.LastUpdatedBlock
+		newPool.LastUpdatedBlock = &block
+	}
+	return newPool
+}
+
+// Patcher constructs a new state for Solidly pools by applying a diff to a previous
+// state. The previous state is left untouched.
+func Patcher(prevState []Pool, diff SolidlySystemDiff) ([]Pool, error) {
+	newStateMap := make(map[uint64]Pool, len(prevState))
+	for _, pool := range prevState {
+		newStateMap[pool.ID] = deepCopyPool(pool)
+	}
+
+	for _, poolIDToDelete := range diff.Deletions {
+		delete(newStateMap, poolIDToDelete)
+	}
+
+	for _, updatedPool := range diff.Updates {
+		newStateMap[updatedPool.ID] = deepCopyPool(updatedPool)
+	}
+
+	for _, addedPool := range diff.Additions {
+		newStateMap[addedPool.ID] = deepCopyPool(addedPool)
+	}
+
+	finalState := make([]Pool, 0, len(newStateMap))
+	for _, pool := range newStateMap {
+		finalState = append(finalState, pool)
+	}
+
+	return finalState, nil
+}
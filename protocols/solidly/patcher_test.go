@@ -0,0 +1,36 @@
+package solidly
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatcher(t *testing.T) {
+	prevState := []Pool{newTestPool(1, 1000, 2000, true), newTestPool(2, 3000, 4000, false)}
+	updated := newTestPool(1, 1100, 1900, true)
+	added := newTestPool(3, 5000, 6000, false)
+
+	newState, err := Patcher(prevState, SolidlySystemDiff{
+		Additions: []Pool{added},
+		Updates:   []Pool{updated},
+		Deletions: []uint64{2},
+	})
+	require.NoError(t, err)
+	require.Len(t, newState, 2)
+
+	byID := map[uint64]Pool{}
+	for _, p := range newState {
+		byID[p.ID] = p
+	}
+	assert.Equal(t, int64(1100), byID[1].Reserve0.Int64())
+	assert.True(t, byID[1].Stable)
+	assert.Equal(t, int64(5000), byID[3].Reserve0.Int64())
+	assert.NotContains(t, byID, uint64(2))
+
+	// Neither the previous state nor the diff shares memory with the result.
+	byID[1].Reserve0.SetInt64(-1)
+	assert.Equal(t, int64(1000), prevState[0].Reserve0.Int64())
+	assert.Equal(t, int64(1100), updated.Reserve0.Int64())
+}
@@ -0,0 +1,42 @@
+package solidly
+
+import (
+	"math/big"
+)
+
+// Pool is the view of a Solidly-style pool, as deployed by Velodrome, Aerodrome and
+// their forks. Stable pools trade on the x3y+y3x=k curve, volatile pools on x*y=k.
+type Pool struct {
+	ID       uint64   `json:"id"`
+	Token0   uint64   `json:"token0"`
+	Token1   uint64   `json:"token1"`
+	Reserve0 *big.Int `json:"reserve0"`
+	Reserve1 *big.Int `json:"reserve1"`
+	Stable   bool     `json:"stable"`
+	FeeBps   uint16   `json:"feeBps"` // i.e 5 for 0.05%
+
+	// Decimals0 and Decimals1 are the decimals of token0 and token1. Stable pools
+	// normalize both reserves to 18 decimals before applying the curve, so they are part
+	// of the pool's state rather than metadata.
+	Decimals0 uint8 `json:"decimals0"`
+	Decimals1 uint8 `json:"decimals1"`
+
+	// LastUpdatedBlock is the block in which the pool's state last changed. It is
+	// optional; use LastUpdated to access it.
+	LastUpdatedBlock *uint64 `json:"lastUpdatedBlock,omitempty"`
+}
+
+// AssumedDecimals returns the token decimals the pool was indexed with. They are always
+// present for Solidly pools; ok is kept for symmetry with the other protocols.
+func (p Pool) AssumedDecimals() (decimals0, decimals1 uint8, ok bool) {
+	return p.Decimals0, p.Decimals1, true
+}
+
+// LastUpdated returns the block in which the pool's state last changed.
+// ok is false if the stream did not provide it.
+func (p Pool) LastUpdated() (block uint64, ok bool) {
+	if p.LastUpdatedBlock == nil {
+		return 0, false
+	}
+	return *p.LastUpdatedBlock, true
+}
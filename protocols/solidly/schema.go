@@ -0,0 +1,7 @@
+package solidly
+
+import (
+	"github.com/defistate/defistate-client-go/engine"
+)
+
+var Schema engine.ProtocolSchema = "defistate/solidly@v1"
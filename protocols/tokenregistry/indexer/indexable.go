@@ -1,8 +1,8 @@
 package indexer
 
 import (
+	"github.com/defistate/defistate-client-go/addr"
 	tokenregistry "github.com/defistate/defistate-client-go/protocols/tokenregistry"
-	"github.com/ethereum/go-ethereum/common"
 )
 
 // Indexer is a concrete implementation of the defistate.TokenIndexer interface.
@@ -21,14 +21,14 @@ func (i *Indexer) Index(tokens []tokenregistry.Token) IndexedTokenSystem {
 // IndexableTokenSystem provides fast, indexed access to tokenregistry data.
 type IndexableTokenSystem struct {
 	byID      map[uint64]tokenregistry.Token
-	byAddress map[common.Address]tokenregistry.Token
+	byAddress map[addr.Address]tokenregistry.Token
 	all       []tokenregistry.Token
 }
 
 // NewIndexableTokenSystem creates a new indexed tokenregistry system from a raw slice.
 func NewIndexableTokenSystem(tokens []tokenregistry.Token) *IndexableTokenSystem {
 	byID := make(map[uint64]tokenregistry.Token, len(tokens))
-	byAddress := make(map[common.Address]tokenregistry.Token, len(tokens))
+	byAddress := make(map[addr.Address]tokenregistry.Token, len(tokens))
 
 	for _, t := range tokens {
 		byID[t.ID] = t
@@ -49,7 +49,7 @@ func (its *IndexableTokenSystem) GetByID(id uint64) (tokenregistry.Token, bool)
 }
 
 // GetByAddress retrieves a tokenregistry by its contract address.
-func (its *IndexableTokenSystem) GetByAddress(address common.Address) (tokenregistry.Token, bool) {
+func (its *IndexableTokenSystem) GetByAddress(address addr.Address) (tokenregistry.Token, bool) {
 	t, ok := its.byAddress[address]
 	return t, ok
 }
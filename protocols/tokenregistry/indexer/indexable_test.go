@@ -3,6 +3,7 @@ package indexer
 import (
 	"testing"
 
+	"github.com/defistate/defistate-client-go/addr"
 	tokenregistry "github.com/defistate/defistate-client-go/protocols/tokenregistry"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/stretchr/testify/assert"
@@ -11,9 +12,9 @@ import (
 
 func TestIndexableTokenSystem(t *testing.T) {
 	// --- Test Data Setup ---
-	wethAddress := common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
-	usdcAddress := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
-	nonExistentAddress := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	wethAddress := addr.FromCommon(common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"))
+	usdcAddress := addr.FromCommon(common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"))
+	nonExistentAddress := addr.FromCommon(common.HexToAddress("0x1111111111111111111111111111111111111111"))
 
 	testTokens := []tokenregistry.Token{
 		{ID: 1, Address: wethAddress, Name: "Wrapped Ether", Symbol: "WETH"},
@@ -3,6 +3,7 @@ package uniswapv2
 import (
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
 	"sync"
 
@@ -35,6 +36,8 @@ var (
 	ErrInvalidState = errors.New("invalid internal state")
 	// ErrInsufficientLiquidity is returned when an amountOut is requested that is greater than or equal to the available reserve.
 	ErrInsufficientLiquidity = errors.New("insufficient liquidity for swap")
+	// ErrPriceUnreachable is returned when a target price lies in the wrong direction for the swap.
+	ErrPriceUnreachable = errors.New("target price is unreachable")
 )
 
 func init() {
@@ -168,7 +171,13 @@ func (c *Calculator) getAmountOut(
 		return new(big.Int), nil
 	}
 
-	c.feeMultiplier.Sub(basisPointDivisor, big.NewInt(int64(pool.FeeBps)))
+	if useUint256.Load() {
+		if amountOut, ok := getAmountOutU256(amountIn, reserveIn, reserveOut, pool.SwapFeeBps()); ok {
+			return amountOut, nil
+		}
+	}
+
+	c.feeMultiplier.Sub(basisPointDivisor, big.NewInt(int64(pool.SwapFeeBps())))
 	c.amountInWithFee.Mul(amountIn, c.feeMultiplier)
 	c.numerator.Mul(reserveOut, c.amountInWithFee)
 	c.denominator.Mul(reserveIn, basisPointDivisor)
@@ -204,10 +213,16 @@ func (c *Calculator) getAmountIn(
 		return nil, fmt.Errorf("%w: requested amountOut (%s) is >= reserveOut (%s)", ErrInsufficientLiquidity, amountOut.String(), reserveOut.String())
 	}
 
+	if useUint256.Load() {
+		if amountIn, ok := getAmountInU256(amountOut, reserveIn, reserveOut, pool.SwapFeeBps()); ok {
+			return amountIn, nil
+		}
+	}
+
 	c.numeratorIn.Mul(reserveIn, amountOut)
 	c.numeratorIn.Mul(c.numeratorIn, basisPointDivisor)
 
-	c.feeMultiplier.Sub(basisPointDivisor, big.NewInt(int64(pool.FeeBps)))
+	c.feeMultiplier.Sub(basisPointDivisor, big.NewInt(int64(pool.SwapFeeBps())))
 	c.denominatorIn.Sub(reserveOut, amountOut)
 	c.denominatorIn.Mul(c.denominatorIn, c.feeMultiplier)
 
@@ -248,6 +263,64 @@ func (c *Calculator) simulateSwap(
 	return amountOut, newPoolState, nil
 }
 
+// AmountToReachPrice returns the input amount, fee included, that moves the pool's raw
+// price (reserve1/reserve0, token1 per token0) to targetPrice when swapping token0 for
+// token1 (zeroForOne) or token1 for token0. It solves the constant-product swap for the
+// input in closed form and rounds up, so swapping the result lands at or just past the
+// target. A target equal to the current price needs no input. It returns
+// ErrPriceUnreachable if the target lies in the opposite direction.
+func AmountToReachPrice(pool uniswapv2.Pool, targetPrice *big.Float, zeroForOne bool) (*big.Int, error) {
+	if targetPrice == nil || targetPrice.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: target price must be positive", ErrPriceUnreachable)
+	}
+	if pool.Reserve0 == nil || pool.Reserve1 == nil || pool.Reserve0.Sign() <= 0 || pool.Reserve1.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: pool %d has no reserves", ErrInvalidState, pool.ID)
+	}
+
+	// This function is not on a hot path, so it works in big.Float for clarity.
+	const prec = 256
+	newFloat := func() *big.Float { return new(big.Float).SetPrec(prec) }
+
+	// Work in the swap direction: the swap lowers reserveOut/reserveIn to target.
+	reserveIn, reserveOut := newFloat().SetInt(pool.Reserve0), newFloat().SetInt(pool.Reserve1)
+	target := newFloat().Set(targetPrice)
+	if !zeroForOne {
+		reserveIn, reserveOut = reserveOut, reserveIn
+		target.Quo(newFloat().SetInt64(1), target)
+	}
+	current := newFloat().Quo(reserveOut, reserveIn)
+	switch current.Cmp(target) {
+	case 0:
+		return new(big.Int), nil
+	case -1:
+		return nil, fmt.Errorf("%w: swapping in this direction moves the price away from %s", ErrPriceUnreachable, targetPrice.String())
+	}
+
+	// Swapping amountIn with fee factor g = (10000-feeBps)/10000 leaves
+	//   reserveOut' = reserveOut*reserveIn / (reserveIn + g*amountIn) and reserveIn' = reserveIn + amountIn,
+	// so reserveOut'/reserveIn' = target is the quadratic
+	//   g*x^2 + reserveIn*(1+g)*x + reserveIn^2 - reserveIn*reserveOut/target = 0,
+	// whose positive root is the required input.
+	g := newFloat().SetInt64(10000 - int64(pool.SwapFeeBps()))
+	g.Quo(g, newFloat().SetInt(basisPointDivisor))
+	b := newFloat().Add(newFloat().SetInt64(1), g)
+	b.Mul(b, reserveIn)
+	c := newFloat().Quo(newFloat().Mul(reserveIn, reserveOut), target)
+	c.Sub(newFloat().Mul(reserveIn, reserveIn), c)
+
+	discriminant := newFloat().Mul(b, b)
+	discriminant.Sub(discriminant, newFloat().Mul(newFloat().Mul(newFloat().SetInt64(4), g), c))
+	root := newFloat().Sqrt(discriminant)
+	root.Sub(root, b)
+	root.Quo(root, newFloat().Mul(newFloat().SetInt64(2), g))
+
+	amountIn, accuracy := root.Int(nil)
+	if accuracy == big.Below {
+		amountIn.Add(amountIn, big.NewInt(1))
+	}
+	return amountIn, nil
+}
+
 // GetReserves returns the reserves for the given token pair. For V2, this is a direct lookup.
 func GetReserves(tokenInID, tokenOutID uint64, pool uniswapv2.Pool) (reserveIn, reserveOut *big.Int, err error) {
 	if tokenInID == pool.Token0 && tokenOutID == pool.Token1 {
@@ -309,3 +382,70 @@ func GetExchangeRate(
 
 	return exchangeRate, nil
 }
+
+// DepthPoint is a point of a pool's depth curve.
+type DepthPoint struct {
+	AmountIn  *big.Int
+	AmountOut *big.Int
+	// MarginalPrice is the raw price of tokenIn in tokenOut once the swap is done,
+	// reserveOut/reserveIn, fees excluded.
+	MarginalPrice *big.Float
+}
+
+// DepthCurve quotes points input sizes, spaced evenly in log scale from minAmountIn to
+// maxAmountIn, and returns the output and the marginal price after each swap. The fee
+// stays in the pool, so it is part of the reserves the marginal price is taken from.
+func DepthCurve(minAmountIn, maxAmountIn *big.Int, points int, tokenInID, tokenOutID uint64, pool uniswapv2.Pool) ([]DepthPoint, error) {
+	amountsIn, err := logSpacedAmounts(minAmountIn, maxAmountIn, points)
+	if err != nil {
+		return nil, err
+	}
+	reserveIn, reserveOut, err := GetReserves(tokenInID, tokenOutID, pool)
+	if err != nil {
+		return nil, err
+	}
+	if reserveIn == nil || reserveOut == nil || reserveIn.Sign() <= 0 || reserveOut.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: pool %d is empty", ErrInsufficientLiquidity, pool.ID)
+	}
+
+	curve := make([]DepthPoint, len(amountsIn))
+	for i, amountIn := range amountsIn {
+		amountOut, err := GetAmountOut(amountIn, tokenInID, tokenOutID, pool)
+		if err != nil {
+			return nil, err
+		}
+		price := new(big.Float).SetInt(new(big.Int).Sub(reserveOut, amountOut))
+		price.Quo(price, new(big.Float).SetInt(new(big.Int).Add(reserveIn, amountIn)))
+		curve[i] = DepthPoint{AmountIn: amountIn, AmountOut: amountOut, MarginalPrice: price}
+	}
+	return curve, nil
+}
+
+// logSpacedAmounts returns points amounts from minAmount to maxAmount, both included,
+// whose logarithms are evenly spaced.
+func logSpacedAmounts(minAmount, maxAmount *big.Int, points int) ([]*big.Int, error) {
+	if minAmount == nil || maxAmount == nil || minAmount.Sign() <= 0 || maxAmount.Cmp(minAmount) < 0 {
+		return nil, fmt.Errorf("%w: the range must satisfy 0 < min <= max", ErrInvalidAmount)
+	}
+	if points < 2 {
+		return nil, fmt.Errorf("a depth curve needs at least 2 points, got %d", points)
+	}
+	minFloat, _ := new(big.Float).SetInt(minAmount).Float64()
+	maxFloat, _ := new(big.Float).SetInt(maxAmount).Float64()
+	step := math.Log(maxFloat/minFloat) / float64(points-1)
+
+	amounts := make([]*big.Int, points)
+	amounts[0] = new(big.Int).Set(minAmount)
+	for i := 1; i < points-1; i++ {
+		amount, _ := big.NewFloat(minFloat * math.Exp(step*float64(i))).Int(nil)
+		// Rounding can step out of order on ranges narrower than the number of points.
+		if amount.Cmp(amounts[i-1]) < 0 {
+			amount.Set(amounts[i-1])
+		} else if amount.Cmp(maxAmount) > 0 {
+			amount.Set(maxAmount)
+		}
+		amounts[i] = amount
+	}
+	amounts[points-1] = new(big.Int).Set(maxAmount)
+	return amounts, nil
+}
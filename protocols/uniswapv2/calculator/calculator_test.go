@@ -152,6 +152,39 @@ func TestGetAmountOut(t *testing.T) {
 	}
 }
 
+func TestGetAmountOut_FeeBps(t *testing.T) {
+	pool := func(feeBps uint16) uniswapv2.Pool {
+		return uniswapv2.Pool{
+			ID:       1,
+			Token0:   0,
+			Token1:   1,
+			Reserve0: big.NewInt(100_000_000),
+			Reserve1: newBigIntFromString("50000000000000000000"),
+			FeeBps:   feeBps,
+		}
+	}
+	amountIn := big.NewInt(1_000_000)
+	quote := func(feeBps uint16) *big.Int {
+		amountOut, err := GetAmountOut(amountIn, 0, 1, pool(feeBps))
+		require.NoError(t, err)
+		return amountOut
+	}
+
+	missing := pool(0)
+	missing.ClearFeeBps()
+	amountOut, err := GetAmountOut(amountIn, 0, 1, missing)
+	require.NoError(t, err)
+	assert.Equal(t, quote(30), amountOut, "a pool streamed without a fee pays the default")
+	assert.Equal(t, 1, quote(0).Cmp(quote(30)), "a fee-free pool pays no fee")
+	assert.Equal(t, 1, quote(25).Cmp(quote(30)), "a cheaper fork pays out more")
+	assert.Equal(t, 1, quote(20).Cmp(quote(25)))
+
+	amountOut = quote(25)
+	required, err := GetAmountIn(amountOut, 0, 1, pool(25))
+	require.NoError(t, err)
+	assert.LessOrEqual(t, required.Cmp(amountIn), 0, "the fee round-trips through GetAmountIn")
+}
+
 func TestGetAmountIn(t *testing.T) {
 	testCases := []struct {
 		name           string
@@ -395,6 +428,59 @@ func BenchmarkSimulateSwap(b *testing.B) {
 }
 
 // TestGetExchangeRate provides a suite of tests for the V2 GetexchangeRate function.
+func TestAmountToReachPrice(t *testing.T) {
+	pool := uniswapv2.Pool{
+		ID:       1,
+		Token0:   0,
+		Token1:   1,
+		Reserve0: big.NewInt(100_000_000),
+		Reserve1: newBigIntFromString("50000000000000000000"),
+		FeeBps:   30,
+	}
+	rawPrice := func(p uniswapv2.Pool) *big.Float {
+		return new(big.Float).Quo(new(big.Float).SetInt(p.Reserve1), new(big.Float).SetInt(p.Reserve0))
+	}
+	current := rawPrice(pool)
+
+	for _, tc := range []struct {
+		name       string
+		zeroForOne bool
+		tokenIn    uint64
+		tokenOut   uint64
+		move       float64
+	}{
+		{name: "Selling token0 lowers the price by 5%", zeroForOne: true, tokenIn: 0, tokenOut: 1, move: 0.95},
+		{name: "Selling token1 raises the price by 20%", zeroForOne: false, tokenIn: 1, tokenOut: 0, move: 1.2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			target := new(big.Float).Mul(current, big.NewFloat(tc.move))
+			amountIn, err := AmountToReachPrice(pool, target, tc.zeroForOne)
+			require.NoError(t, err)
+			require.Positive(t, amountIn.Sign())
+
+			_, after, err := SimulateSwap(amountIn, tc.tokenIn, tc.tokenOut, pool)
+			require.NoError(t, err)
+			relDiff, _ := new(big.Float).Quo(new(big.Float).Sub(rawPrice(after), target), target).Float64()
+			assert.InDelta(t, 0, relDiff, 1e-6)
+		})
+	}
+
+	t.Run("Current price needs no input", func(t *testing.T) {
+		amountIn, err := AmountToReachPrice(pool, current, true)
+		require.NoError(t, err)
+		assert.Zero(t, amountIn.Sign())
+	})
+
+	t.Run("Unreachable targets", func(t *testing.T) {
+		higher := new(big.Float).Mul(current, big.NewFloat(1.1))
+		_, err := AmountToReachPrice(pool, higher, true)
+		assert.ErrorIs(t, err, ErrPriceUnreachable)
+
+		_, err = AmountToReachPrice(pool, big.NewFloat(0), false)
+		assert.ErrorIs(t, err, ErrPriceUnreachable)
+	})
+}
+
 func TestGetExchangeRate(t *testing.T) {
 	// Mock Pool: Assume Token 0 is WETH (18 decimals) and Token 1 is USDC (6 decimals)
 	// Price: 3,000 USDC per WETH
@@ -499,3 +585,38 @@ func TestGetExchangeRate(t *testing.T) {
 		})
 	}
 }
+
+func TestDepthCurve(t *testing.T) {
+	pool := uniswapv2.Pool{
+		ID:       1,
+		Token0:   0,
+		Token1:   1,
+		Reserve0: big.NewInt(100_000_000),
+		Reserve1: newBigIntFromString("50000000000000000000"),
+		FeeBps:   30,
+	}
+	curve, err := DepthCurve(big.NewInt(1_000), big.NewInt(100_000_000), 6, 0, 1, pool)
+	require.NoError(t, err)
+	require.Len(t, curve, 6)
+	assert.Equal(t, big.NewInt(1_000), curve[0].AmountIn)
+	assert.Equal(t, big.NewInt(100_000_000), curve[5].AmountIn)
+
+	for i, point := range curve {
+		out, err := GetAmountOut(point.AmountIn, 0, 1, pool)
+		require.NoError(t, err)
+		assert.Equal(t, out, point.AmountOut)
+		if i > 0 {
+			assert.Equal(t, 0, new(big.Int).Mul(curve[i-1].AmountIn, big.NewInt(10)).Cmp(point.AmountIn), "sizes grow tenfold")
+		}
+	}
+
+	// Swapping the pool's whole USDC reserve doubles it and roughly halves the WETH,
+	// quartering the price of USDC from 5e11.
+	price, _ := curve[5].MarginalPrice.Float64()
+	assert.InEpsilon(t, 5e11/4, price, 0.01)
+
+	_, err = DepthCurve(big.NewInt(0), big.NewInt(1), 6, 0, 1, pool)
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+	_, err = DepthCurve(big.NewInt(1), big.NewInt(2), 6, 0, 1, uniswapv2.Pool{Token1: 1, Reserve0: big.NewInt(0), Reserve1: big.NewInt(0)})
+	assert.ErrorIs(t, err, ErrInsufficientLiquidity)
+}
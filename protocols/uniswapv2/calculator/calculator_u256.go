@@ -0,0 +1,79 @@
+package uniswapv2
+
+import (
+	"math/big"
+	"sync/atomic"
+
+	"github.com/holiman/uint256"
+)
+
+// useUint256 selects the uint256 math for GetAmountOut and GetAmountIn.
+var useUint256 atomic.Bool
+
+// SetUint256Math selects the math GetAmountOut, GetAmountIn and SimulateSwap quote with.
+// The uint256 math works on fixed-width integers on the stack instead of big.Ints and
+// returns the same amounts; a quote with a value beyond 256 bits is computed with big.Int.
+// It is off by default and safe to call at any time.
+func SetUint256Math(enabled bool) {
+	useUint256.Store(enabled)
+}
+
+// Uint256Math reports whether the uint256 math is selected.
+func Uint256Math() bool {
+	return useUint256.Load()
+}
+
+// getAmountOutU256 is the constant-product amount out in uint256. The reserves must be
+// positive. ok is false if a value does not fit in 256 bits or feeBps exceeds 100%.
+func getAmountOutU256(amountIn, reserveIn, reserveOut *big.Int, feeBps uint16) (amountOut *big.Int, ok bool) {
+	if feeBps > 10000 {
+		return nil, false
+	}
+
+	var in, rIn, rOut, amountInWithFee, denominator, out uint256.Int
+	if in.SetFromBig(amountIn) || rIn.SetFromBig(reserveIn) || rOut.SetFromBig(reserveOut) {
+		return nil, false
+	}
+	if _, overflow := amountInWithFee.MulOverflow(&in, uint256.NewInt(uint64(10000-feeBps))); overflow {
+		return nil, false
+	}
+	if _, overflow := denominator.MulOverflow(&rIn, uint256.NewInt(10000)); overflow {
+		return nil, false
+	}
+	if _, overflow := denominator.AddOverflow(&denominator, &amountInWithFee); overflow {
+		return nil, false
+	}
+
+	// The quotient never exceeds reserveOut, so only the 512-bit product can be large.
+	out.MulDivOverflow(&rOut, &amountInWithFee, &denominator)
+	return out.ToBig(), true
+}
+
+// getAmountInU256 is the constant-product amount in for amountOut in uint256. The
+// reserves must be positive and amountOut below reserveOut. ok is false if a value does
+// not fit in 256 bits or the fee leaves no input to swap.
+func getAmountInU256(amountOut, reserveIn, reserveOut *big.Int, feeBps uint16) (amountIn *big.Int, ok bool) {
+	if feeBps >= 10000 {
+		return nil, false
+	}
+
+	var out, rIn, rOut, numerator, denominator, in uint256.Int
+	if out.SetFromBig(amountOut) || rIn.SetFromBig(reserveIn) || rOut.SetFromBig(reserveOut) {
+		return nil, false
+	}
+	if _, overflow := numerator.MulOverflow(&rIn, uint256.NewInt(10000)); overflow {
+		return nil, false
+	}
+	denominator.Sub(&rOut, &out)
+	if _, overflow := denominator.MulOverflow(&denominator, uint256.NewInt(uint64(10000-feeBps))); overflow {
+		return nil, false
+	}
+
+	if _, overflow := in.MulDivOverflow(&numerator, &out, &denominator); overflow {
+		return nil, false
+	}
+	if _, overflow := in.AddOverflow(&in, uint256.NewInt(1)); overflow {
+		return nil, false
+	}
+	return in.ToBig(), true
+}
@@ -0,0 +1,95 @@
+package uniswapv2
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	uniswapv2 "github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// randBits returns a random value of a random bit length up to bits.
+func randBits(rng *rand.Rand, bits int) *big.Int {
+	return new(big.Int).Rand(rng, new(big.Int).Lsh(big.NewInt(1), uint(rng.Intn(bits)+1)))
+}
+
+// TestUint256Math_MatchesBigInt quotes random pools with both maths, from dust reserves
+// to reserves near 2^256, and expects the same amounts and errors.
+func TestUint256Math_MatchesBigInt(t *testing.T) {
+	t.Cleanup(func() { SetUint256Math(false) })
+	rng := rand.New(rand.NewSource(2792))
+
+	for i := 0; i < 20_000; i++ {
+		pool := uniswapv2.Pool{
+			ID:       1,
+			Token0:   0,
+			Token1:   1,
+			Reserve0: randBits(rng, 256),
+			Reserve1: randBits(rng, 256),
+			FeeBps:   uint16(rng.Intn(10_002)),
+		}
+		amount := randBits(rng, 256)
+		tokenIn, tokenOut := uint64(i%2), uint64(1-i%2)
+
+		SetUint256Math(false)
+		wantOut, wantOutErr := GetAmountOut(amount, tokenIn, tokenOut, pool)
+		wantIn, wantInErr := GetAmountIn(amount, tokenIn, tokenOut, pool)
+
+		SetUint256Math(true)
+		gotOut, gotOutErr := GetAmountOut(amount, tokenIn, tokenOut, pool)
+		gotIn, gotInErr := GetAmountIn(amount, tokenIn, tokenOut, pool)
+
+		require.Equal(t, wantOutErr, gotOutErr)
+		require.Equal(t, wantInErr, gotInErr)
+		assert.Equal(t, wantOut.String(), gotOut.String(), "amount out of %s", amount)
+		assert.Equal(t, wantIn.String(), gotIn.String(), "amount in for %s", amount)
+	}
+}
+
+func TestUint256Math_Fallback(t *testing.T) {
+	reserve0, reserve1 := big.NewInt(100_000_000), newBigIntFromString("50000000000000000000")
+	amount := big.NewInt(1_000_000)
+
+	out, ok := getAmountOutU256(amount, reserve0, reserve1, 30)
+	require.True(t, ok)
+	assert.Equal(t, "493579017198530649", out.String())
+
+	huge := new(big.Int).Lsh(big.NewInt(1), 256)
+	_, ok = getAmountOutU256(huge, reserve0, reserve1, 30)
+	assert.False(t, ok, "an amount beyond 256 bits")
+	_, ok = getAmountOutU256(amount, reserve0, reserve1, 10_001)
+	assert.False(t, ok, "a fee above 100%")
+	_, ok = getAmountInU256(amount, reserve0, reserve1, 10_000)
+	assert.False(t, ok, "a fee of 100%")
+	_, ok = getAmountInU256(amount, new(big.Int).Rsh(huge, 4), reserve1, 30)
+	assert.False(t, ok, "reserveIn * 10000 beyond 256 bits")
+}
+
+func BenchmarkGetAmountOut_Math(b *testing.B) {
+	pool := uniswapv2.Pool{
+		ID:       1,
+		Token0:   0,
+		Token1:   1,
+		Reserve0: newBigIntFromString("2000000000000"),          // 2,000,000 USDC
+		Reserve1: newBigIntFromString("1000000000000000000000"), // 1,000 WETH
+		FeeBps:   30,
+	}
+	amountIn := newBigIntFromString("1000000000000000000") // 1 WETH
+	defer SetUint256Math(false)
+
+	for _, enabled := range []bool{false, true} {
+		name := "big.Int"
+		if enabled {
+			name = "uint256"
+		}
+		b.Run(name, func(b *testing.B) {
+			SetUint256Math(enabled)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				result, _ = GetAmountOut(amountIn, 1, 0, pool)
+			}
+		})
+	}
+}
@@ -49,7 +49,9 @@ func Differ(old, new []Pool) UniswapV2SystemDiff {
 			// on the fields that are expected to change: the reserves.
 			// This is significantly faster than using reflect.DeepEqual.
 			// The Cmp method on big.Int returns 0 if the numbers are equal.
-			if oldPool.Reserve0.Cmp(newPool.Reserve0) != 0 || oldPool.Reserve1.Cmp(newPool.Reserve1) != 0 {
+			// The last-update block is compared too, so clients see when a pool was last observed.
+			if oldPool.Reserve0.Cmp(newPool.Reserve0) != 0 || oldPool.Reserve1.Cmp(newPool.Reserve1) != 0 ||
+				optionalUint64Changed(oldPool.LastUpdatedBlock, newPool.LastUpdatedBlock) {
 				updates = append(updates, newPool)
 			}
 		}
@@ -71,3 +73,11 @@ func Differ(old, new []Pool) UniswapV2SystemDiff {
 		Deletions: deletions,
 	}
 }
+
+// optionalUint64Changed compares fields that may be absent from the stream.
+func optionalUint64Changed(old, new *uint64) bool {
+	if old == nil || new == nil {
+		return old != new
+	}
+	return *old != *new
+}
@@ -55,6 +55,22 @@ func TestDiffer(t *testing.T) {
 		assert.Empty(t, diff.Deletions, "Should have no deletions")
 	})
 
+	t.Run("should identify updates when only the last-update block changes", func(t *testing.T) {
+		block100, block101 := uint64(100), uint64(101)
+		pool1Seen := Pool{ID: 1, Reserve0: big.NewInt(1000), Reserve1: big.NewInt(2000), LastUpdatedBlock: &block100}
+		pool1SeenAgain := Pool{ID: 1, Reserve0: big.NewInt(1000), Reserve1: big.NewInt(2000), LastUpdatedBlock: &block101}
+
+		diff := Differ([]Pool{pool1Seen}, []Pool{pool1SeenAgain})
+		require.Len(t, diff.Updates, 1)
+		block, ok := diff.Updates[0].LastUpdated()
+		require.True(t, ok)
+		assert.Equal(t, uint64(101), block)
+
+		// Starting to report the field is a change too.
+		diff = Differ([]Pool{pool1Old}, []Pool{pool1Seen})
+		assert.Len(t, diff.Updates, 1)
+	})
+
 	t.Run("should handle a mix of additions, updates, and deletions", func(t *testing.T) {
 		// pool1 is updated, pool2 is unchanged, pool3 is deleted
 		// pool4 is added
@@ -16,9 +16,30 @@ func deepCopyPool(p Pool) Pool {
 	if p.Reserve1 != nil {
 		newPool.Reserve1 = new(big.Int).Set(p.Reserve1)
 	}
+	newPool.Decimals0 = copyOptionalUint8(p.Decimals0)
+	newPool.Decimals1 = copyOptionalUint8(p.Decimals1)
+	newPool.LastUpdatedBlock = copyOptionalUint64(p.LastUpdatedBlock)
 	return newPool
 }
 
+// copyOptionalUint8 copies a *uint8 that is allowed to be nil.
+func copyOptionalUint8(v *uint8) *uint8 {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
+
+// copyOptionalUint64 copies a *uint64 that is allowed to be nil.
+func copyOptionalUint64(v *uint64) *uint64 {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
+
 // Patcher is a concrete implementation of the UniswapV2SystemDiff function type.
 // It efficiently constructs a new state for Uniswap V2 pools by applying a diff to a previous state.
 // The logic is optimized for performance and memory safety.
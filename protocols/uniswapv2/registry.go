@@ -1,6 +1,14 @@
 package uniswapv2
 
-import "math/big"
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/defistate/defistate-client-go/addr"
+)
+
+// DefaultFeeBps is the swap fee of pools streamed without one, Uniswap V2's 0.3%.
+const DefaultFeeBps = 30
 
 type Pool struct {
 	ID       uint64   `json:"id"`
@@ -9,5 +17,111 @@ type Pool struct {
 	Reserve0 *big.Int `json:"reserve0"`
 	Reserve1 *big.Int `json:"reserve1"`
 	Type     uint8    `json:"type"`
-	FeeBps   uint16   `json:"feeBps"` // i.e 30 for 0.3%
+	// FeeBps is the swap fee in basis points, i.e 30 for 0.3%. Forks charge other fees,
+	// such as 25 or 20, and zero is a fee-free pool. A pool decoded from a payload without
+	// the field pays DefaultFeeBps instead; use SwapFeeBps to read the fee a swap pays.
+	FeeBps uint16 `json:"feeBps"`
+	// feeBpsAbsent is set when the pool was decoded from a payload without feeBps.
+	feeBpsAbsent bool
+
+	// Decimals0 and Decimals1 are the token decimals the indexer assumed for this pool.
+	// They are optional; use AssumedDecimals to access them.
+	Decimals0 *uint8 `json:"decimals0,omitempty"`
+	Decimals1 *uint8 `json:"decimals1,omitempty"`
+
+	// LastUpdatedBlock is the block in which the pool's state last changed. It is
+	// optional; use LastUpdated to access it.
+	LastUpdatedBlock *uint64 `json:"lastUpdatedBlock,omitempty"`
+}
+
+// AssumedDecimals returns the token decimals the indexer assumed for token0 and token1.
+// ok is false if the stream did not provide them.
+func (p Pool) AssumedDecimals() (decimals0, decimals1 uint8, ok bool) {
+	if p.Decimals0 == nil || p.Decimals1 == nil {
+		return 0, 0, false
+	}
+	return *p.Decimals0, *p.Decimals1, true
+}
+
+// SwapFeeBps returns the fee a swap pays, in basis points: FeeBps if the stream
+// provided it, or DefaultFeeBps.
+func (p Pool) SwapFeeBps() uint16 {
+	if p.feeBpsAbsent {
+		return DefaultFeeBps
+	}
+	return p.FeeBps
+}
+
+// HasFeeBps reports whether FeeBps is the pool's fee: false only for a pool decoded from
+// a payload without feeBps.
+func (p Pool) HasFeeBps() bool {
+	return !p.feeBpsAbsent
+}
+
+// SetFeeBps sets the pool's fee, e.g. the default of a fork for a pool streamed without
+// one.
+func (p *Pool) SetFeeBps(feeBps uint16) {
+	p.FeeBps = feeBps
+	p.feeBpsAbsent = false
+}
+
+// ClearFeeBps marks the pool as having no fee of its own, as if decoded from a payload
+// without feeBps, so swaps pay DefaultFeeBps.
+func (p *Pool) ClearFeeBps() {
+	p.FeeBps = 0
+	p.feeBpsAbsent = true
+}
+
+// UnmarshalJSON decodes a pool, recording whether the payload carried feeBps.
+func (p *Pool) UnmarshalJSON(data []byte) error {
+	type plain Pool
+	aux := struct {
+		*plain
+		FeeBps *uint16 `json:"feeBps"`
+	}{plain: (*plain)(p)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if aux.FeeBps == nil {
+		p.ClearFeeBps()
+	} else {
+		p.SetFeeBps(*aux.FeeBps)
+	}
+	return nil
+}
+
+// MarshalJSON encodes a pool, leaving feeBps out for a pool decoded without it so the
+// fee stays absent through a round trip.
+func (p Pool) MarshalJSON() ([]byte, error) {
+	type plain Pool
+	aux := struct {
+		plain
+		FeeBps *uint16 `json:"feeBps,omitempty"`
+	}{plain: plain(p)}
+	if !p.feeBpsAbsent {
+		aux.FeeBps = &p.FeeBps
+	}
+	return json.Marshal(aux)
+}
+
+// LastUpdated returns the block in which the pool's state last changed.
+// ok is false if the stream did not provide it.
+func (p Pool) LastUpdated() (block uint64, ok bool) {
+	if p.LastUpdatedBlock == nil {
+		return 0, false
+	}
+	return *p.LastUpdatedBlock, true
+}
+
+// IsSorted reports whether token0's address is numerically less than token1's, the
+// Uniswap convention that fixes which way the pool's price is quoted. Pools reference
+// tokens by ID, so addresses are resolved with tokenAddress; ok is false if either
+// token cannot be resolved.
+func (p Pool) IsSorted(tokenAddress func(tokenID uint64) (addr.Address, bool)) (sorted, ok bool) {
+	address0, ok0 := tokenAddress(p.Token0)
+	address1, ok1 := tokenAddress(p.Token1)
+	if !ok0 || !ok1 {
+		return false, false
+	}
+	return address0.Less(address1), true
 }
@@ -0,0 +1,72 @@
+package uniswapv2
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/defistate/defistate-client-go/addr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolIsSorted(t *testing.T) {
+	// WETH sorts before USDT, USDC sorts before WETH.
+	addresses := map[uint64]addr.Address{
+		1: addr.Address{0xC0, 0x2a}, // WETH
+		2: addr.Address{0xda, 0xC1}, // USDT
+		3: addr.Address{0xA0, 0xb8}, // USDC
+	}
+	tokenAddress := func(id uint64) (addr.Address, bool) {
+		a, ok := addresses[id]
+		return a, ok
+	}
+
+	t.Run("token0 below token1", func(t *testing.T) {
+		sorted, ok := Pool{ID: 1, Token0: 1, Token1: 2}.IsSorted(tokenAddress)
+		assert.True(t, ok)
+		assert.True(t, sorted)
+	})
+
+	t.Run("token0 above token1", func(t *testing.T) {
+		sorted, ok := Pool{ID: 2, Token0: 1, Token1: 3}.IsSorted(tokenAddress)
+		assert.True(t, ok)
+		assert.False(t, sorted)
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		_, ok := Pool{ID: 3, Token0: 1, Token1: 99}.IsSorted(tokenAddress)
+		assert.False(t, ok)
+	})
+}
+
+func TestPoolSwapFeeBps(t *testing.T) {
+	assert.Equal(t, uint16(25), Pool{FeeBps: 25}.SwapFeeBps())
+	assert.Equal(t, uint16(0), Pool{}.SwapFeeBps(), "a fee-free pool")
+
+	var missing Pool
+	require.NoError(t, json.Unmarshal([]byte(`{"id":1}`), &missing))
+	assert.False(t, missing.HasFeeBps())
+	assert.Equal(t, uint16(DefaultFeeBps), missing.SwapFeeBps(), "a pool streamed without a fee pays the default")
+
+	var zero Pool
+	require.NoError(t, json.Unmarshal([]byte(`{"id":1,"feeBps":0}`), &zero))
+	assert.True(t, zero.HasFeeBps())
+	assert.Equal(t, uint16(0), zero.SwapFeeBps(), "an explicit zero fee is kept")
+
+	missing.SetFeeBps(25)
+	assert.Equal(t, uint16(25), missing.SwapFeeBps())
+}
+
+func TestPoolJSON_FeeBpsRoundTrip(t *testing.T) {
+	for _, payload := range []string{`{"id":1}`, `{"id":1,"feeBps":0}`, `{"id":1,"feeBps":25}`} {
+		var pool Pool
+		require.NoError(t, json.Unmarshal([]byte(payload), &pool))
+		data, err := json.Marshal(pool)
+		require.NoError(t, err)
+
+		var decoded Pool
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, pool, decoded, payload)
+		assert.Equal(t, pool.SwapFeeBps(), decoded.SwapFeeBps(), payload)
+	}
+}
@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"math"
 	"math/big"
+	"sort"
 	"sync"
 
 	uniswapv3 "github.com/defistate/defistate-client-go/protocols/uniswapv3"
 	"github.com/defistate/defistate-client-go/protocols/uniswapv3/calculator/liquiditymath"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3/calculator/sqrtpricemath"
 	"github.com/defistate/defistate-client-go/protocols/uniswapv3/calculator/swapmath"
 	"github.com/defistate/defistate-client-go/protocols/uniswapv3/calculator/tickbitmap"
 	"github.com/defistate/defistate-client-go/protocols/uniswapv3/calculator/tickmath"
@@ -18,10 +20,14 @@ var (
 	ErrInvalidAmountIn    = errors.New("amountIn must be greater than zero")
 	ErrTokenMismatch      = errors.New("token mismatch")
 	ErrLiquidityUnderflow = errors.New("liquidity underflow")
+	// ErrPriceUnreachable is returned when a target price lies in the wrong direction for
+	// the swap, or beyond the liquidity the pool has.
+	ErrPriceUnreachable = errors.New("target price is unreachable")
 
 	Q96, _        = new(big.Int).SetString("79228162514264337593543950336", 10)
 	Q64F          = new(big.Float).SetInt(Q96)
 	MaxUint256, _ = new(big.Int).SetString("ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", 16)
+	maxInt256     = new(big.Int).Rsh(MaxUint256, 1)
 )
 
 // swapState represents the state of a swap as it progresses.
@@ -65,12 +71,29 @@ var swapStatePool = sync.Pool{
 	},
 }
 
+// swapCheckpoint is the state of a swap at the last tick boundary it reached. A larger
+// swap from the same start takes the same steps up to that boundary, so it can resume
+// from the checkpoint and produce the same amounts.
+type swapCheckpoint struct {
+	saved                    bool
+	sqrtPriceX96             *big.Int
+	tick                     int64
+	liquidity                *big.Int
+	amountSpecifiedRemaining *big.Int
+	amountCalculated         *big.Int
+}
+
 // _swap is the internal, core simulation engine, fully optimized to be allocation-free.
+// If steps is not nil, every step of the swap is appended to it; this allocates, so the
+// quoting paths pass nil. If checkpoint is not nil, the state is saved to it at every
+// tick boundary the swap reaches.
 func _swap(
 	state *swapState,
 	pool uniswapv3.Pool,
 	sqrtPriceLimitX96 *big.Int,
 	zeroForOne bool,
+	steps *[]SwapStep,
+	checkpoint *swapCheckpoint,
 ) error {
 
 	if sqrtPriceLimitX96 == nil {
@@ -115,12 +138,24 @@ func _swap(
 			state.targetPrice,
 			state.liquidity,
 			state.amountSpecifiedRemaining,
-			state.tempAmount.SetUint64(pool.Fee),
+			state.tempAmount.SetUint64(pool.SwapFee()),
 		)
 		if err != nil {
 			break // Can happen if liquidity is zero
 		}
 
+		if steps != nil {
+			*steps = append(*steps, SwapStep{
+				TickNext:          tickNext,
+				Liquidity:         new(big.Int).Set(state.liquidity),
+				SqrtPriceStartX96: new(big.Int).Set(state.sqrtPriceStartX96),
+				SqrtPriceEndX96:   new(big.Int).Set(state.sqrtPriceX96),
+				AmountIn:          new(big.Int).Set(state.stepAmountIn),
+				AmountOut:         new(big.Int).Set(state.stepAmountOut),
+				FeeAmount:         new(big.Int).Set(state.stepFeeAmount),
+			})
+		}
+
 		if exactInput {
 			state.amountSpecifiedRemaining.Sub(state.amountSpecifiedRemaining, state.tempAmount.Add(state.stepAmountIn, state.stepFeeAmount))
 			state.amountCalculated.Add(state.amountCalculated, state.stepAmountOut)
@@ -150,6 +185,9 @@ func _swap(
 					}
 					return err
 				}
+				if steps != nil {
+					(*steps)[len(*steps)-1].Crossed = true
+				}
 			}
 
 			if zeroForOne {
@@ -157,6 +195,14 @@ func _swap(
 			} else {
 				state.tick = tickNext
 			}
+			if checkpoint != nil {
+				checkpoint.saved = true
+				checkpoint.sqrtPriceX96.Set(state.sqrtPriceX96)
+				checkpoint.tick = state.tick
+				checkpoint.liquidity.Set(state.liquidity)
+				checkpoint.amountSpecifiedRemaining.Set(state.amountSpecifiedRemaining)
+				checkpoint.amountCalculated.Set(state.amountCalculated)
+			}
 		} else if state.sqrtPriceX96.Cmp(state.sqrtPriceStartX96) != 0 {
 			state.tick, err = tickmath.GetTickAtSqrtRatio(state.sqrtPriceX96)
 			if err != nil {
@@ -193,7 +239,7 @@ func SimulateExactInSwap(
 	state.tick = pool.Tick
 	state.liquidity.Set(pool.Liquidity)
 
-	if err := _swap(state, pool, sqrtPriceLimitX96, zeroForOne); err != nil {
+	if err := _swap(state, pool, sqrtPriceLimitX96, zeroForOne, nil, nil); err != nil {
 		return nil, uniswapv3.Pool{}, err
 	}
 
@@ -233,7 +279,7 @@ func SimulateExactOutSwap(
 	state.tick = pool.Tick
 	state.liquidity.Set(pool.Liquidity)
 
-	if err := _swap(state, pool, sqrtPriceLimitX96, zeroForOne); err != nil {
+	if err := _swap(state, pool, sqrtPriceLimitX96, zeroForOne, nil, nil); err != nil {
 		return nil, uniswapv3.Pool{}, err
 	}
 
@@ -247,6 +293,147 @@ func SimulateExactOutSwap(
 	return amountIn, newPoolState, nil
 }
 
+// SwapStep is the part of a swap filled within one tick range, at constant liquidity.
+type SwapStep struct {
+	// TickNext is the tick the step swapped toward: the next initialized tick, or the
+	// price limit if it comes first.
+	TickNext int64
+	// Crossed is true if the step reached TickNext and crossed it, changing the liquidity.
+	Crossed bool
+	// Liquidity is the active liquidity the step swapped against.
+	Liquidity         *big.Int
+	SqrtPriceStartX96 *big.Int
+	SqrtPriceEndX96   *big.Int
+	// AmountIn excludes the fee, which is FeeAmount, in the input token.
+	AmountIn  *big.Int
+	AmountOut *big.Int
+	FeeAmount *big.Int
+}
+
+// SwapResult is the detailed outcome of a simulated swap.
+type SwapResult struct {
+	// AmountIn is the input the swap used, including fees, and AmountOut the output it
+	// delivered. They can fall short of the requested amount if the price limit is hit
+	// or the pool runs out of liquidity.
+	AmountIn  *big.Int
+	AmountOut *big.Int
+	// FeeAmount is the total fee paid, in the input token, of which ProtocolFeeAmount
+	// goes to the protocol when the pool's fee switch is on and the rest to LPs.
+	FeeAmount         *big.Int
+	ProtocolFeeAmount *big.Int
+	// FeeGrowthX128 is the LP fee accrued per unit of liquidity, in the input token, as
+	// the pool adds it to its global fee growth.
+	FeeGrowthX128 *big.Int
+	TicksCrossed  int
+	// Steps lists the swap tick range by tick range, in swap order.
+	Steps []SwapStep
+	// Pool is the pool state after the swap: its tick, price and liquidity are final, and
+	// the fees are accrued to its fee growth and protocol fees when the pool has them.
+	Pool uniswapv3.Pool
+}
+
+// SimulateExactInSwapDetailed simulates an exact-input swap like SimulateExactInSwap and
+// also reports the ticks crossed, the fees paid and the liquidity used per tick range.
+// It allocates for every step, so prefer GetAmountOut when only the amount is needed.
+func SimulateExactInSwapDetailed(
+	amountIn *big.Int,
+	sqrtPriceLimitX96 *big.Int,
+	tokenInID uint64,
+	pool uniswapv3.Pool,
+) (*SwapResult, error) {
+	if amountIn == nil || amountIn.Sign() <= 0 {
+		return nil, ErrInvalidAmountIn
+	}
+	return simulateSwapDetailed(amountIn, sqrtPriceLimitX96, tokenInID, pool)
+}
+
+// SimulateExactOutSwapDetailed simulates an exact-output swap like SimulateExactOutSwap,
+// with the same negative amountOut, and also reports the ticks crossed, the fees paid and
+// the liquidity used per tick range.
+func SimulateExactOutSwapDetailed(
+	amountOut *big.Int,
+	sqrtPriceLimitX96 *big.Int,
+	tokenInID uint64,
+	pool uniswapv3.Pool,
+) (*SwapResult, error) {
+	if amountOut == nil || amountOut.Sign() >= 0 {
+		return nil, errors.New("amountOut must be negative for an exact-output swap")
+	}
+	return simulateSwapDetailed(amountOut, sqrtPriceLimitX96, tokenInID, pool)
+}
+
+// simulateSwapDetailed runs a swap of amountSpecified, positive for exact input and
+// negative for exact output, and sums its steps into a SwapResult.
+func simulateSwapDetailed(
+	amountSpecified *big.Int,
+	sqrtPriceLimitX96 *big.Int,
+	tokenInID uint64,
+	pool uniswapv3.Pool,
+) (*SwapResult, error) {
+	zeroForOne := tokenInID == pool.Token0
+	if !zeroForOne && tokenInID != pool.Token1 {
+		return nil, fmt.Errorf("%w: token %d is not in pool %d", ErrTokenMismatch, tokenInID, pool.ID)
+	}
+
+	state := swapStatePool.Get().(*swapState)
+	defer swapStatePool.Put(state)
+
+	state.amountSpecifiedRemaining.Set(amountSpecified)
+	state.amountCalculated.SetInt64(0)
+	state.sqrtPriceX96.Set(pool.SqrtPriceX96)
+	state.tick = pool.Tick
+	state.liquidity.Set(pool.Liquidity)
+
+	var steps []SwapStep
+	if err := _swap(state, pool, sqrtPriceLimitX96, zeroForOne, &steps, nil); err != nil {
+		return nil, err
+	}
+
+	result := &SwapResult{
+		AmountIn:          new(big.Int),
+		AmountOut:         new(big.Int),
+		FeeAmount:         new(big.Int),
+		ProtocolFeeAmount: new(big.Int),
+		FeeGrowthX128:     new(big.Int),
+		Steps:             steps,
+		Pool:              pool,
+	}
+	for _, step := range steps {
+		result.AmountIn.Add(result.AmountIn, step.AmountIn)
+		result.AmountIn.Add(result.AmountIn, step.FeeAmount)
+		result.AmountOut.Add(result.AmountOut, step.AmountOut)
+		result.FeeAmount.Add(result.FeeAmount, step.FeeAmount)
+
+		// As in the pool, the protocol takes its cut of every step's fee and the rest
+		// grows the fees of the liquidity in range.
+		protocolFee := pool.ProtocolFeeAmount(step.FeeAmount, zeroForOne)
+		result.ProtocolFeeAmount.Add(result.ProtocolFeeAmount, protocolFee)
+		if step.Liquidity.Sign() > 0 {
+			growth := new(big.Int).Sub(step.FeeAmount, protocolFee)
+			growth.Lsh(growth, 128)
+			result.FeeGrowthX128.Add(result.FeeGrowthX128, growth.Div(growth, step.Liquidity))
+		}
+		if step.Crossed {
+			result.TicksCrossed++
+		}
+	}
+	result.Pool.SqrtPriceX96 = new(big.Int).Set(state.sqrtPriceX96)
+	result.Pool.Tick = state.tick
+	result.Pool.Liquidity = new(big.Int).Set(state.liquidity)
+
+	feeGrowth, protocolFees := &result.Pool.FeeGrowthGlobal1X128, &result.Pool.ProtocolFees1
+	if zeroForOne {
+		feeGrowth, protocolFees = &result.Pool.FeeGrowthGlobal0X128, &result.Pool.ProtocolFees0
+	}
+	if *feeGrowth != nil {
+		*feeGrowth = new(big.Int).Add(*feeGrowth, result.FeeGrowthX128)
+	}
+	if *protocolFees != nil {
+		*protocolFees = new(big.Int).Add(*protocolFees, result.ProtocolFeeAmount)
+	}
+	return result, nil
+}
+
 // GetAmountOut calculates the amount out for a given exact amount in.
 func GetAmountOut(
 	amountIn *big.Int,
@@ -263,6 +450,13 @@ func GetAmountOut(
 		return nil, fmt.Errorf("%w: token %d is not in pool %d", ErrTokenMismatch, tokenInID, pool.ID)
 	}
 
+	if useUint256.Load() {
+		amount, err := swapU256(amountIn, sqrtPriceLimitX96, zeroForOne, pool)
+		if !errors.Is(err, sqrtpricemath.ErrUint256Overflow) {
+			return amount, err
+		}
+	}
+
 	state := swapStatePool.Get().(*swapState)
 	defer swapStatePool.Put(state)
 
@@ -272,12 +466,95 @@ func GetAmountOut(
 	state.tick = pool.Tick
 	state.liquidity.Set(pool.Liquidity)
 
-	if err := _swap(state, pool, sqrtPriceLimitX96, zeroForOne); err != nil {
+	if err := _swap(state, pool, sqrtPriceLimitX96, zeroForOne, nil, nil); err != nil {
 		return nil, err
 	}
 	return new(big.Int).Set(state.amountCalculated), nil
 }
 
+// GetAmountsOut calculates the amount out for each of amountsIn, which must be sorted in
+// ascending order. Each amount is quoted as GetAmountOut would, but the ticks are walked
+// once for the whole list: a swap resumes from the last tick boundary the previous,
+// smaller swap reached. It suits building price-impact curves and splitting a route
+// across pools, where many sizes are quoted on one pool.
+func GetAmountsOut(
+	amountsIn []*big.Int,
+	sqrtPriceLimitX96 *big.Int,
+	tokenInID uint64,
+	pool uniswapv3.Pool,
+) ([]*big.Int, error) {
+	return getAmountsOut(amountsIn, sqrtPriceLimitX96, tokenInID, pool, nil)
+}
+
+// getAmountsOut is GetAmountsOut. If sqrtPricesAfter is not nil, it must be as long as
+// amountsIn and is filled with the pool's price after each swap.
+func getAmountsOut(
+	amountsIn []*big.Int,
+	sqrtPriceLimitX96 *big.Int,
+	tokenInID uint64,
+	pool uniswapv3.Pool,
+	sqrtPricesAfter []*big.Int,
+) ([]*big.Int, error) {
+	for i, amountIn := range amountsIn {
+		if amountIn == nil || amountIn.Sign() <= 0 {
+			return nil, fmt.Errorf("%w: amount %d", ErrInvalidAmountIn, i)
+		}
+		if i > 0 && amountIn.Cmp(amountsIn[i-1]) < 0 {
+			return nil, fmt.Errorf("amounts must be sorted in ascending order: amount %d is less than amount %d", i, i-1)
+		}
+	}
+
+	zeroForOne := tokenInID == pool.Token0
+	if !zeroForOne && tokenInID != pool.Token1 {
+		return nil, fmt.Errorf("%w: token %d is not in pool %d", ErrTokenMismatch, tokenInID, pool.ID)
+	}
+
+	state := swapStatePool.Get().(*swapState)
+	defer swapStatePool.Put(state)
+
+	// resume is where the next swap starts, and consumed the input swapped to get there.
+	resume := swapCheckpoint{
+		sqrtPriceX96:     new(big.Int).Set(pool.SqrtPriceX96),
+		tick:             pool.Tick,
+		liquidity:        new(big.Int).Set(pool.Liquidity),
+		amountCalculated: new(big.Int),
+	}
+	consumed := new(big.Int)
+	checkpoint := swapCheckpoint{
+		sqrtPriceX96:             new(big.Int),
+		liquidity:                new(big.Int),
+		amountSpecifiedRemaining: new(big.Int),
+		amountCalculated:         new(big.Int),
+	}
+
+	amountsOut := make([]*big.Int, len(amountsIn))
+	for i, amountIn := range amountsIn {
+		state.amountSpecifiedRemaining.Sub(amountIn, consumed)
+		state.amountCalculated.Set(resume.amountCalculated)
+		state.sqrtPriceX96.Set(resume.sqrtPriceX96)
+		state.tick = resume.tick
+		state.liquidity.Set(resume.liquidity)
+
+		checkpoint.saved = false
+		if err := _swap(state, pool, sqrtPriceLimitX96, zeroForOne, nil, &checkpoint); err != nil {
+			return nil, err
+		}
+		amountsOut[i] = new(big.Int).Set(state.amountCalculated)
+		if sqrtPricesAfter != nil {
+			sqrtPricesAfter[i] = new(big.Int).Set(state.sqrtPriceX96)
+		}
+
+		if checkpoint.saved {
+			resume.sqrtPriceX96.Set(checkpoint.sqrtPriceX96)
+			resume.tick = checkpoint.tick
+			resume.liquidity.Set(checkpoint.liquidity)
+			resume.amountCalculated.Set(checkpoint.amountCalculated)
+			consumed.Sub(amountIn, checkpoint.amountSpecifiedRemaining)
+		}
+	}
+	return amountsOut, nil
+}
+
 // GetAmountIn calculates the required amount in for a given exact amount out.
 // NOTE: It expects a negative amountOut to signal the exact-output swap type.
 func GetAmountIn(
@@ -295,6 +572,13 @@ func GetAmountIn(
 		return nil, fmt.Errorf("%w: token %d is not in pool %d", ErrTokenMismatch, tokenInID, pool.ID)
 	}
 
+	if useUint256.Load() {
+		amount, err := swapU256(amountOut, sqrtPriceLimitX96, zeroForOne, pool)
+		if !errors.Is(err, sqrtpricemath.ErrUint256Overflow) {
+			return amount, err
+		}
+	}
+
 	state := swapStatePool.Get().(*swapState)
 	defer swapStatePool.Put(state)
 
@@ -304,23 +588,36 @@ func GetAmountIn(
 	state.tick = pool.Tick
 	state.liquidity.Set(pool.Liquidity)
 
-	if err := _swap(state, pool, sqrtPriceLimitX96, zeroForOne); err != nil {
+	if err := _swap(state, pool, sqrtPriceLimitX96, zeroForOne, nil, nil); err != nil {
 		return nil, err
 	}
 	return new(big.Int).Set(state.amountCalculated), nil
 }
 
+// VirtualReserves returns the reserves a Uniswap V2 pool would need to quote the same
+// spot price and the same depth at the current price: reserve0 = L/sqrtP and
+// reserve1 = L*sqrtP. They let V3 pools be compared with V2 pools using reserve logic,
+// but only hold near the current price, since V3 liquidity changes across ticks.
+// A pool without liquidity or price has zero virtual reserves.
+func VirtualReserves(pool uniswapv3.Pool) (reserve0, reserve1 *big.Int) {
+	if pool.Liquidity == nil || pool.SqrtPriceX96 == nil || pool.SqrtPriceX96.Sign() == 0 {
+		return new(big.Int), new(big.Int)
+	}
+
+	// This function is not on a hot path, so a few allocations are acceptable for clarity.
+	reserve0 = new(big.Int).Div(new(big.Int).Lsh(pool.Liquidity, 96), pool.SqrtPriceX96)
+	reserve1 = new(big.Int).Div(new(big.Int).Mul(pool.Liquidity, pool.SqrtPriceX96), Q96)
+	return reserve0, reserve1
+}
+
 // GetVirtualReserves calculates the virtual reserves of a Uniswap V3 pool based on its
-// current liquidity and price.
+// current liquidity and price, ordered by swap direction. See VirtualReserves.
 func GetVirtualReserves(tokenInID, tokenOutID uint64, pool uniswapv3.Pool) (reserveIn, reserveOut *big.Int, err error) {
 	if !((tokenInID == pool.Token0 && tokenOutID == pool.Token1) || (tokenInID == pool.Token1 && tokenOutID == pool.Token0)) {
 		return nil, nil, fmt.Errorf("%w: provided tokens do not match pool tokens", ErrTokenMismatch)
 	}
 
-	// This function is not on a hot path, so a few allocations are acceptable for clarity.
-	reserve0 := new(big.Int).Div(new(big.Int).Lsh(pool.Liquidity, 96), pool.SqrtPriceX96)
-	reserve1 := new(big.Int).Div(new(big.Int).Mul(pool.Liquidity, pool.SqrtPriceX96), Q96)
-
+	reserve0, reserve1 := VirtualReserves(pool)
 	if tokenInID == pool.Token0 {
 		return reserve0, reserve1, nil
 	} else {
@@ -332,7 +629,8 @@ func GetVirtualReserves(tokenInID, tokenOutID uint64, pool uniswapv3.Pool) (rese
 // adjusted for token decimals. The returned big.Int represents the price
 // with precision matching the decimals of tokenOut.
 // For example, if tokenOut is USDT (6 decimals), a return value of 3045123456
-// represents a price of 3045.123456.
+// represents a price of 3045.123456. The price is truncated; see GetSpotPriceWithOptions
+// for more precision or other rounding.
 func GetSpotPrice(
 	tokenInID, tokenOutID uint64,
 	decimalsIn, decimalsOut uint8,
@@ -360,3 +658,405 @@ func GetSpotPrice(
 		return sp, nil
 	}
 }
+
+// Rounding selects how GetSpotPriceWithOptions rounds the last digit of a price.
+type Rounding int
+
+const (
+	// RoundDown truncates, as GetSpotPrice does. This is the default.
+	RoundDown Rounding = iota
+	// RoundHalfUp rounds to the nearest value, halves away from zero.
+	RoundHalfUp
+	// RoundUp rounds towards positive infinity.
+	RoundUp
+)
+
+// SpotPriceOptions controls the precision and rounding of GetSpotPriceWithOptions.
+// The zero value reproduces GetSpotPrice.
+type SpotPriceOptions struct {
+	// ExtraDecimals adds digits of precision beyond decimalsOut: the price is scaled by
+	// 10^(decimalsOut+ExtraDecimals) instead of 10^decimalsOut.
+	ExtraDecimals uint8
+	Rounding      Rounding
+}
+
+// GetSpotPriceWithOptions is GetSpotPrice with configurable precision and rounding. The
+// price is computed exactly from SqrtPriceX96 before it is rounded, so, unlike
+// GetSpotPrice, no precision is lost to floating point however many digits are requested.
+// For example, with ExtraDecimals 2 a WETH price in USDT of 3045.1234567 is returned as
+// 304512345 rounded down, or 304512346 with RoundHalfUp.
+func GetSpotPriceWithOptions(
+	tokenInID, tokenOutID uint64,
+	decimalsIn, decimalsOut uint8,
+	pool uniswapv3.Pool,
+	opts SpotPriceOptions,
+) (*big.Int, error) {
+	if !((tokenInID == pool.Token0 && tokenOutID == pool.Token1) || (tokenInID == pool.Token1 && tokenOutID == pool.Token0)) {
+		return nil, fmt.Errorf("%w: pool %d does not contain the pair %d -> %d", ErrTokenMismatch, pool.ID, tokenInID, tokenOutID)
+	}
+	if pool.SqrtPriceX96 == nil || pool.SqrtPriceX96.Sign() <= 0 {
+		return nil, fmt.Errorf("pool %d has no price", pool.ID)
+	}
+
+	// The raw price of token0 is sqrtPriceX96^2 / 2^192 token1. Scaled to whole tokenIn and
+	// to decimalsOut+ExtraDecimals digits of tokenOut, the price of tokenIn is
+	// rawPrice(tokenIn) * 10^(decimalsIn+ExtraDecimals), kept as a fraction until rounding;
+	// decimalsOut cancels out and only names the unit of the result.
+	ratio := new(big.Int).Mul(pool.SqrtPriceX96, pool.SqrtPriceX96)
+	numerator := pow10(decimalsIn)
+	numerator.Mul(numerator, pow10(opts.ExtraDecimals))
+	denominator := new(big.Int).Lsh(big.NewInt(1), 192)
+	if tokenInID == pool.Token0 {
+		numerator.Mul(numerator, ratio)
+	} else {
+		numerator.Mul(numerator, denominator)
+		denominator = ratio
+	}
+
+	price, remainder := new(big.Int).QuoRem(numerator, denominator, new(big.Int))
+	if remainder.Sign() == 0 {
+		return price, nil
+	}
+	switch opts.Rounding {
+	case RoundUp:
+		price.Add(price, big.NewInt(1))
+	case RoundHalfUp:
+		if remainder.Lsh(remainder, 1).Cmp(denominator) >= 0 {
+			price.Add(price, big.NewInt(1))
+		}
+	}
+	return price, nil
+}
+
+// TickToPrice returns the price of one whole token0 in token1 at tick, in the same fixed-point
+// form as GetSpotPrice: an integer with decimals1 decimals of precision (e.g. 3045123456 is
+// 3045.123456 for a 6-decimal token1). The price is 1.0001^tick in raw units, computed
+// exactly from the tick's sqrt ratio and rounded down. It returns nil if tick is outside
+// [MIN_TICK, MAX_TICK].
+func TickToPrice(tick int64, decimals0, decimals1 uint8) *big.Int {
+	sqrtPriceX96 := new(big.Int)
+	if err := tickmath.GetSqrtRatioAtTick(sqrtPriceX96, tick); err != nil {
+		return nil
+	}
+	// price in token1 units = sqrtPriceX96^2 / 2^192 * 10^decimals0; decimals1 only names
+	// the unit of the result.
+	price := new(big.Int).Mul(sqrtPriceX96, sqrtPriceX96)
+	price.Mul(price, pow10(decimals0))
+	return price.Rsh(price, 192)
+}
+
+// PriceToTick is the inverse of TickToPrice: it returns the tick whose price is closest to
+// price (in log space), where price is one whole token0 in token1 with decimals1 decimals of
+// precision. Prices beyond the tick range clamp to MIN_TICK or MAX_TICK, and a nil or
+// non-positive price returns MIN_TICK.
+func PriceToTick(price *big.Int, decimals0, decimals1 uint8) int64 {
+	if price == nil || price.Sign() <= 0 {
+		return tickmath.MIN_TICK
+	}
+	// ratio = sqrtPriceX96^2 = price * 2^192 / 10^decimals0, kept as a fraction.
+	numerator := new(big.Int).Lsh(price, 192)
+	denominator := pow10(decimals0)
+
+	sqrtPriceX96 := new(big.Int).Quo(numerator, denominator)
+	sqrtPriceX96.Sqrt(sqrtPriceX96)
+	if sqrtPriceX96.Cmp(tickmath.MIN_SQRT_RATIO) < 0 {
+		return tickmath.MIN_TICK
+	}
+	if sqrtPriceX96.Cmp(tickmath.MAX_SQRT_RATIO) >= 0 {
+		return tickmath.MAX_TICK
+	}
+	tick, err := tickmath.GetTickAtSqrtRatio(sqrtPriceX96)
+	if err != nil || tick >= tickmath.MAX_TICK {
+		return tick
+	}
+
+	// tick is the floor; round up if ratio is past the geometric midpoint of tick and
+	// tick+1, i.e. ratio >= sqrt(tick) * sqrt(tick+1).
+	lower, upper := new(big.Int), new(big.Int)
+	if tickmath.GetSqrtRatioAtTick(lower, tick) != nil || tickmath.GetSqrtRatioAtTick(upper, tick+1) != nil {
+		return tick
+	}
+	midpoint := lower.Mul(lower, upper)
+	if numerator.Cmp(midpoint.Mul(midpoint, denominator)) >= 0 {
+		return tick + 1
+	}
+	return tick
+}
+
+// PriceAtTick returns the price of one whole pool.Token0 in whole pool.Token1 if the pool
+// were at tick, for scenario analysis without simulating a swap. Unlike TickToPrice it is
+// returned in whole tokens, computed exactly from the tick's sqrt ratio and rounded only to
+// the float's 256-bit mantissa. decimals0 and decimals1 belong to pool.Token0 and
+// pool.Token1; if the pool carries the decimals its indexer assumed and they disagree,
+// an error is returned, which catches swapped arguments.
+func PriceAtTick(tick int64, pool uniswapv3.Pool, decimals0, decimals1 uint8) (*big.Float, error) {
+	if assumed0, assumed1, ok := pool.AssumedDecimals(); ok && (assumed0 != decimals0 || assumed1 != decimals1) {
+		return nil, fmt.Errorf("pool %d: decimals %d/%d disagree with the pool's %d/%d", pool.ID, decimals0, decimals1, assumed0, assumed1)
+	}
+	sqrtPriceX96 := new(big.Int)
+	if err := tickmath.GetSqrtRatioAtTick(sqrtPriceX96, tick); err != nil {
+		return nil, fmt.Errorf("pool %d: %w", pool.ID, err)
+	}
+	// price = sqrtPriceX96^2 / 2^192 raw token1 per raw token0, scaled by 10^(decimals0-decimals1).
+	numerator := new(big.Int).Mul(sqrtPriceX96, sqrtPriceX96)
+	numerator.Mul(numerator, pow10(decimals0))
+	denominator := new(big.Int).Lsh(pow10(decimals1), 192)
+	return new(big.Float).SetPrec(256).SetRat(new(big.Rat).SetFrac(numerator, denominator)), nil
+}
+
+// pow10 returns 10^n.
+func pow10(n uint8) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// MarginalPriceAfter returns the marginal (instantaneous) price of tokenIn in terms of
+// the other pool token after swapping amountIn, i.e. the price the next infinitesimal
+// unit would trade at. It is derived from the post-swap SqrtPriceX96 and is expressed
+// in raw token units (no decimal adjustment, fees excluded).
+//
+// Unlike amountOut/amountIn, which is the average execution price, the marginal price
+// is what limit-order-style logic should compare against.
+func MarginalPriceAfter(amountIn *big.Int, tokenInID uint64, pool uniswapv3.Pool) (*big.Float, error) {
+	_, newPoolState, err := SimulateExactInSwap(amountIn, nil, tokenInID, pool)
+	if err != nil {
+		return nil, err
+	}
+	return marginalPrice(tokenInID, newPoolState), nil
+}
+
+// DepthPoint is a point of a pool's depth curve.
+type DepthPoint struct {
+	AmountIn  *big.Int
+	AmountOut *big.Int
+	// MarginalPrice is the raw price of tokenIn after the swap, as MarginalPriceAfter
+	// returns it.
+	MarginalPrice *big.Float
+}
+
+// DepthCurve quotes points input sizes of tokenIn, spaced evenly in log scale from
+// minAmountIn to maxAmountIn, and returns the output and the marginal price after each
+// swap. The outputs are those of GetAmountOut; the ticks are walked once, as in
+// GetAmountsOut. Comparing the curves of pools of the same pair shows which can absorb
+// which size.
+func DepthCurve(minAmountIn, maxAmountIn *big.Int, points int, tokenInID uint64, pool uniswapv3.Pool) ([]DepthPoint, error) {
+	amountsIn, err := logSpacedAmounts(minAmountIn, maxAmountIn, points)
+	if err != nil {
+		return nil, err
+	}
+	sqrtPricesAfter := make([]*big.Int, len(amountsIn))
+	amountsOut, err := getAmountsOut(amountsIn, nil, tokenInID, pool, sqrtPricesAfter)
+	if err != nil {
+		return nil, err
+	}
+
+	curve := make([]DepthPoint, len(amountsIn))
+	for i := range amountsIn {
+		after := pool
+		after.SqrtPriceX96 = sqrtPricesAfter[i]
+		curve[i] = DepthPoint{
+			AmountIn:      amountsIn[i],
+			AmountOut:     amountsOut[i],
+			MarginalPrice: marginalPrice(tokenInID, after),
+		}
+	}
+	return curve, nil
+}
+
+// logSpacedAmounts returns points amounts from minAmount to maxAmount, both included,
+// whose logarithms are evenly spaced.
+func logSpacedAmounts(minAmount, maxAmount *big.Int, points int) ([]*big.Int, error) {
+	if minAmount == nil || maxAmount == nil || minAmount.Sign() <= 0 || maxAmount.Cmp(minAmount) < 0 {
+		return nil, fmt.Errorf("%w: the range must satisfy 0 < min <= max", ErrInvalidAmountIn)
+	}
+	if points < 2 {
+		return nil, fmt.Errorf("a depth curve needs at least 2 points, got %d", points)
+	}
+	minFloat, _ := new(big.Float).SetInt(minAmount).Float64()
+	maxFloat, _ := new(big.Float).SetInt(maxAmount).Float64()
+	step := math.Log(maxFloat/minFloat) / float64(points-1)
+
+	amounts := make([]*big.Int, points)
+	amounts[0] = new(big.Int).Set(minAmount)
+	for i := 1; i < points-1; i++ {
+		amount, _ := big.NewFloat(minFloat * math.Exp(step*float64(i))).Int(nil)
+		// Rounding can step out of order on ranges narrower than the number of points.
+		if amount.Cmp(amounts[i-1]) < 0 {
+			amount.Set(amounts[i-1])
+		} else if amount.Cmp(maxAmount) > 0 {
+			amount.Set(maxAmount)
+		}
+		amounts[i] = amount
+	}
+	amounts[points-1] = new(big.Int).Set(maxAmount)
+	return amounts, nil
+}
+
+// marginalPrice converts the pool's SqrtPriceX96 into the raw price of tokenIn.
+func marginalPrice(tokenInID uint64, pool uniswapv3.Pool) *big.Float {
+	// SqrtPriceX96 is sqrt(token1/token0) * 2^96, so squaring it yields token1 per token0.
+	sqrtPrice := new(big.Float).SetInt(pool.SqrtPriceX96)
+	sqrtPrice.Quo(sqrtPrice, Q64F)
+	price := new(big.Float).Mul(sqrtPrice, sqrtPrice)
+	if tokenInID == pool.Token0 {
+		return price
+	}
+	return price.Quo(big.NewFloat(1), price)
+}
+
+// AmountToReachPrice returns the input amount, fee included, that moves the pool's price to
+// targetSqrtPriceX96 when swapping token0 for token1 (zeroForOne) or token1 for token0. It
+// runs the swap with the target as the price limit, crossing ticks as a real swap would.
+// A target equal to the current price needs no input. It returns ErrPriceUnreachable if the
+// target lies in the opposite direction or past the pool's last initialized tick.
+func AmountToReachPrice(pool uniswapv3.Pool, targetSqrtPriceX96 *big.Int, zeroForOne bool) (*big.Int, error) {
+	if targetSqrtPriceX96 == nil || targetSqrtPriceX96.Cmp(tickmath.MIN_SQRT_RATIO) < 0 || targetSqrtPriceX96.Cmp(tickmath.MAX_SQRT_RATIO) > 0 {
+		return nil, fmt.Errorf("%w: sqrt price %v is out of range", ErrPriceUnreachable, targetSqrtPriceX96)
+	}
+	switch cmp := targetSqrtPriceX96.Cmp(pool.SqrtPriceX96); {
+	case cmp == 0:
+		return new(big.Int), nil
+	case zeroForOne && cmp > 0, !zeroForOne && cmp < 0:
+		return nil, fmt.Errorf("%w: swapping in this direction moves the price away from %s", ErrPriceUnreachable, targetSqrtPriceX96)
+	}
+
+	state := swapStatePool.Get().(*swapState)
+	defer swapStatePool.Put(state)
+
+	// Swap an amount no pool can absorb, so the price limit is what ends the swap.
+	state.amountSpecifiedRemaining.Set(maxInt256)
+	state.amountCalculated.SetInt64(0)
+	state.sqrtPriceX96.Set(pool.SqrtPriceX96)
+	state.tick = pool.Tick
+	state.liquidity.Set(pool.Liquidity)
+
+	if err := _swap(state, pool, targetSqrtPriceX96, zeroForOne, nil, nil); err != nil {
+		return nil, err
+	}
+	if state.sqrtPriceX96.Cmp(targetSqrtPriceX96) != 0 {
+		return nil, fmt.Errorf("%w: pool %d runs out of liquidity first", ErrPriceUnreachable, pool.ID)
+	}
+	return new(big.Int).Sub(maxInt256, state.amountSpecifiedRemaining), nil
+}
+
+// TickPoint is a single initialized tick of a pool, in a form suitable for charting
+// the pool's liquidity distribution (e.g. a depth chart).
+type TickPoint struct {
+	Index int64
+	// Price is the raw price of token0 in terms of token1 at this tick (1.0001^Index),
+	// not adjusted for token decimals.
+	Price float64
+	// SqrtPriceX96 is the exact Q64.96 square root price at this tick.
+	SqrtPriceX96 *big.Int
+	LiquidityNet *big.Int
+	// LiquidityActive is the cumulative liquidity that is in range between this tick
+	// and the next initialized tick, i.e. the sum of LiquidityNet of all ticks up to
+	// and including this one.
+	LiquidityActive *big.Int
+}
+
+// TickSeries returns every initialized tick of the pool in ascending price order, along
+// with the cumulative active liquidity for the range that starts at each tick.
+// For a consistent pool, LiquidityActive of the last point at or below pool.Tick equals pool.Liquidity.
+func TickSeries(pool uniswapv3.Pool) []TickPoint {
+	ticks := make([]uniswapv3.TickInfo, 0, len(pool.Ticks))
+	for _, tick := range pool.Ticks {
+		if tick.LiquidityNet == nil {
+			continue
+		}
+		ticks = append(ticks, tick)
+	}
+	sort.Slice(ticks, func(i, j int) bool { return ticks[i].Index < ticks[j].Index })
+
+	series := make([]TickPoint, 0, len(ticks))
+	active := new(big.Int)
+	for _, tick := range ticks {
+		active.Add(active, tick.LiquidityNet)
+
+		sqrtPriceX96 := new(big.Int)
+		if err := tickmath.GetSqrtRatioAtTick(sqrtPriceX96, tick.Index); err != nil {
+			continue // out of range ticks cannot be priced
+		}
+
+		series = append(series, TickPoint{
+			Index:           tick.Index,
+			Price:           math.Pow(1.0001, float64(tick.Index)),
+			SqrtPriceX96:    sqrtPriceX96,
+			LiquidityNet:    new(big.Int).Set(tick.LiquidityNet),
+			LiquidityActive: new(big.Int).Set(active),
+		})
+	}
+	return series
+}
+
+// ConcentrationScore returns the fraction of the pool's liquidity, measured in
+// liquidity x tick width, that lies within bandTicks of the current tick. The result is
+// in [0, 1]: a high score means liquidity is concentrated around the current price, a
+// low score means it is deeper overall but spread over a wide price range.
+//
+// Liquidity per range is reconstructed outwards from pool.Liquidity at the current tick,
+// so the score stays anchored to the active liquidity even if the tick snapshot is partial.
+// Liquidity outside the outermost initialized ticks is ignored, and ranges that would
+// have negative liquidity are treated as empty.
+func ConcentrationScore(pool uniswapv3.Pool, bandTicks int) float64 {
+	if pool.Liquidity == nil || bandTicks <= 0 {
+		return 0
+	}
+
+	ticks := make([]uniswapv3.TickInfo, 0, len(pool.Ticks))
+	for _, tick := range pool.Ticks {
+		if tick.LiquidityNet == nil || tick.Index <= tickmath.MIN_TICK || tick.Index >= tickmath.MAX_TICK {
+			continue
+		}
+		ticks = append(ticks, tick)
+	}
+	sort.Slice(ticks, func(i, j int) bool { return ticks[i].Index < ticks[j].Index })
+
+	// Range i spans [bounds[i], bounds[i+1]); the outermost ranges end at the tick limits.
+	bounds := make([]int64, 0, len(ticks)+2)
+	bounds = append(bounds, tickmath.MIN_TICK)
+	for _, tick := range ticks {
+		bounds = append(bounds, tick.Index)
+	}
+	bounds = append(bounds, tickmath.MAX_TICK)
+
+	current := sort.Search(len(bounds)-1, func(i int) bool { return bounds[i+1] > pool.Tick })
+	if current == len(bounds)-1 {
+		current--
+	}
+
+	liquidity := make([]*big.Int, len(bounds)-1)
+	liquidity[current] = new(big.Int).Set(pool.Liquidity)
+	for i := current + 1; i < len(liquidity); i++ {
+		// Crossing ticks[i-1] (the lower bound of range i) upwards adds its liquidityNet.
+		liquidity[i] = new(big.Int).Add(liquidity[i-1], ticks[i-1].LiquidityNet)
+	}
+	for i := current - 1; i >= 0; i-- {
+		// Crossing ticks[i] (the lower bound of range i+1) downwards removes its liquidityNet.
+		liquidity[i] = new(big.Int).Sub(liquidity[i+1], ticks[i].LiquidityNet)
+	}
+
+	bandLow := pool.Tick - int64(bandTicks)
+	bandHigh := pool.Tick + int64(bandTicks)
+
+	var total, inBand float64
+	for i, l := range liquidity {
+		// The outermost ranges are unbounded by initialized ticks and hold no liquidity
+		// in a consistent pool; counting them would let a partial snapshot dominate.
+		if i == 0 || i == len(liquidity)-1 || l.Sign() <= 0 {
+			continue
+		}
+		lf, _ := new(big.Float).SetInt(l).Float64()
+		low, high := bounds[i], bounds[i+1]
+		total += lf * float64(high-low)
+
+		overlapLow, overlapHigh := max(low, bandLow), min(high, bandHigh)
+		if overlapHigh > overlapLow {
+			inBand += lf * float64(overlapHigh-overlapLow)
+		}
+	}
+
+	if total == 0 {
+		return 0
+	}
+	return inBand / total
+}
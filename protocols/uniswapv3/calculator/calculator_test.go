@@ -2,11 +2,13 @@ package uniswapv3
 
 import (
 	"encoding/json"
+	"math"
 	"math/big"
 	"reflect"
 	"testing"
 
 	uniswapv3 "github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3/calculator/tickmath"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -7418,3 +7420,756 @@ func TestGetSpotPrice(t *testing.T) {
 		})
 	}
 }
+
+func TestGetSpotPriceWithOptions(t *testing.T) {
+	// The WETH/USDT fixture of TestGetSpotPrice: 3375.0318054727... USDT per WETH and
+	// 0.000296293504072605466... WETH per USDT.
+	sqrtPriceX96, _ := new(big.Int).SetString("4602761997227095498465462", 10)
+	pool := uniswapv3.Pool{
+		PoolViewMinimal: uniswapv3.PoolViewMinimal{
+			Token0:       0, // WETH (18 decimals)
+			Token1:       1, // USDT (6 decimals)
+			SqrtPriceX96: sqrtPriceX96,
+		},
+	}
+
+	t.Run("Zero options match GetSpotPrice", func(t *testing.T) {
+		for _, pair := range [][4]uint64{{0, 1, 18, 6}, {1, 0, 6, 18}} {
+			expected, err := GetSpotPrice(pair[0], pair[1], uint8(pair[2]), uint8(pair[3]), pool)
+			require.NoError(t, err)
+			price, err := GetSpotPriceWithOptions(pair[0], pair[1], uint8(pair[2]), uint8(pair[3]), pool, SpotPriceOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, expected.String(), price.String())
+		}
+	})
+
+	testCases := []struct {
+		name     string
+		tokenIn  uint64
+		opts     SpotPriceOptions
+		expected string
+	}{
+		{name: "Truncated extra digit", tokenIn: 0, opts: SpotPriceOptions{ExtraDecimals: 1}, expected: "33750318054"},
+		{name: "Rounded extra digit", tokenIn: 0, opts: SpotPriceOptions{ExtraDecimals: 1, Rounding: RoundHalfUp}, expected: "33750318055"},
+		{name: "Rounded up extra digits", tokenIn: 0, opts: SpotPriceOptions{ExtraDecimals: 4, Rounding: RoundUp}, expected: "33750318054728"},
+		{name: "Inverse rounds half up to the lower value", tokenIn: 1, opts: SpotPriceOptions{Rounding: RoundHalfUp}, expected: "296293504072605"},
+		{name: "Inverse rounds up", tokenIn: 1, opts: SpotPriceOptions{Rounding: RoundUp}, expected: "296293504072606"},
+		{name: "Inverse beyond float precision", tokenIn: 1, opts: SpotPriceOptions{ExtraDecimals: 6}, expected: "296293504072605466321"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			decimalsIn, decimalsOut := uint8(18), uint8(6)
+			if tc.tokenIn == 1 {
+				decimalsIn, decimalsOut = decimalsOut, decimalsIn
+			}
+			price, err := GetSpotPriceWithOptions(tc.tokenIn, 1-tc.tokenIn, decimalsIn, decimalsOut, pool, tc.opts)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, price.String())
+		})
+	}
+
+	t.Run("Token mismatch", func(t *testing.T) {
+		_, err := GetSpotPriceWithOptions(0, 2, 18, 6, pool, SpotPriceOptions{})
+		assert.ErrorIs(t, err, ErrTokenMismatch)
+	})
+}
+
+func TestVirtualReserves(t *testing.T) {
+	pool := createRealisticV3Pool(t)
+
+	t.Run("Reproduces the spot price", func(t *testing.T) {
+		reserve0, reserve1 := VirtualReserves(pool)
+		require.Positive(t, reserve0.Sign())
+		require.Positive(t, reserve1.Sign())
+
+		// reserve1/reserve0 = (L*sqrtP)/(L/sqrtP) = sqrtP^2, the raw price of token0 in token1.
+		impliedPrice := new(big.Float).Quo(new(big.Float).SetInt(reserve1), new(big.Float).SetInt(reserve0))
+		sqrtPrice := new(big.Float).Quo(new(big.Float).SetInt(pool.SqrtPriceX96), Q64F)
+		spotPrice := new(big.Float).Mul(sqrtPrice, sqrtPrice)
+
+		relErr, _ := new(big.Float).Quo(new(big.Float).Sub(impliedPrice, spotPrice), spotPrice).Float64()
+		assert.InDelta(t, 0, relErr, 1e-9)
+
+		// The product of the virtual reserves is L^2, as in a V2 pool with k = L^2.
+		k := new(big.Int).Mul(reserve0, reserve1)
+		l2 := new(big.Int).Mul(pool.Liquidity, pool.Liquidity)
+		kErr, _ := new(big.Float).Quo(new(big.Float).SetInt(new(big.Int).Sub(l2, k)), new(big.Float).SetInt(l2)).Float64()
+		assert.InDelta(t, 0, kErr, 1e-9)
+	})
+
+	t.Run("Matches the spot price with decimals", func(t *testing.T) {
+		// Token0 is USDC (6 decimals) and token1 is WETH (18 decimals).
+		reserve0, reserve1 := VirtualReserves(pool)
+		spot, err := GetSpotPrice(pool.Token1, pool.Token0, 18, 6, pool)
+		require.NoError(t, err)
+
+		implied := new(big.Float).Quo(new(big.Float).SetInt(reserve0), new(big.Float).SetInt(reserve1))
+		implied.Mul(implied, big.NewFloat(1e12)) // WETH→USDC in whole units
+		implied.Mul(implied, big.NewFloat(1e6))  // with USDC precision, as GetSpotPrice returns
+		impliedF, _ := implied.Float64()
+		spotF, _ := new(big.Float).SetInt(spot).Float64()
+		assert.InEpsilon(t, spotF, impliedF, 1e-6)
+	})
+
+	t.Run("Agrees with GetVirtualReserves", func(t *testing.T) {
+		reserve0, reserve1 := VirtualReserves(pool)
+		reserveIn, reserveOut, err := GetVirtualReserves(pool.Token1, pool.Token0, pool)
+		require.NoError(t, err)
+		assert.Equal(t, reserve1, reserveIn)
+		assert.Equal(t, reserve0, reserveOut)
+	})
+
+	t.Run("Empty pool", func(t *testing.T) {
+		reserve0, reserve1 := VirtualReserves(uniswapv3.Pool{})
+		assert.Zero(t, reserve0.Sign())
+		assert.Zero(t, reserve1.Sign())
+	})
+}
+
+func TestTickToPrice(t *testing.T) {
+	t.Run("Tick zero is parity", func(t *testing.T) {
+		assert.Equal(t, 0, TickToPrice(0, 18, 18).Cmp(fromString("1000000000000000000")))
+		assert.Equal(t, 0, TickToPrice(0, 6, 6).Cmp(big.NewInt(1_000_000)))
+	})
+
+	t.Run("Decimals-aware prices", func(t *testing.T) {
+		// A WETH (18) / USDC (6) pool around 3,000 USDC per WETH sits at a negative tick.
+		price := TickToPrice(-196_257, 18, 6)
+		require.NotNil(t, price)
+		f, _ := new(big.Float).SetInt(price).Float64()
+		assert.InDelta(t, 3000e6, f, 3000e6*1e-4)
+
+		// The same market with the tokens the other way round: WETH per USDC, 18 decimals.
+		price = TickToPrice(196_257, 6, 18)
+		require.NotNil(t, price)
+		f, _ = new(big.Float).SetInt(price).Float64()
+		assert.InDelta(t, 1e18/3000, f, 1e18/3000*1e-4)
+	})
+
+	t.Run("Agrees with GetSpotPrice at the pool tick", func(t *testing.T) {
+		pool := createRealisticV3Pool(t) // token0 USDC (6), token1 WETH (18)
+		spot, err := GetSpotPrice(pool.Token0, pool.Token1, 6, 18, pool)
+		require.NoError(t, err)
+		atTick := TickToPrice(pool.Tick, 6, 18)
+
+		// The pool price lies within one tick (0.01%) above the price at its current tick.
+		relDiff, _ := new(big.Float).Quo(new(big.Float).SetInt(new(big.Int).Sub(spot, atTick)), new(big.Float).SetInt(spot)).Float64()
+		assert.GreaterOrEqual(t, relDiff, -1e-12)
+		assert.Less(t, relDiff, 1.0001e-4)
+	})
+
+	t.Run("Out of range", func(t *testing.T) {
+		assert.Nil(t, TickToPrice(tickmath.MAX_TICK+1, 18, 18))
+		assert.Nil(t, TickToPrice(tickmath.MIN_TICK-1, 18, 18))
+	})
+}
+
+func TestPriceToTick(t *testing.T) {
+	t.Run("Round-trips ticks", func(t *testing.T) {
+		cases := []struct {
+			tick                 int64
+			decimals0, decimals1 uint8
+		}{
+			{0, 18, 18},
+			{1, 18, 18},
+			{-1, 18, 18},
+			{100, 18, 18},
+			{-100, 18, 18},
+			{50_000, 18, 18},
+			{-50_000, 18, 18},
+			{-196_257, 18, 6},
+			{196_257, 6, 18},
+			{-20_000, 6, 6},
+		}
+		for _, tc := range cases {
+			price := TickToPrice(tc.tick, tc.decimals0, tc.decimals1)
+			require.NotNil(t, price, "tick %d", tc.tick)
+			if price.Sign() == 0 {
+				continue // below the precision of decimals1
+			}
+			assert.Equal(t, tc.tick, PriceToTick(price, tc.decimals0, tc.decimals1), "tick %d (price %s)", tc.tick, price)
+		}
+	})
+
+	t.Run("Round-trips prices to the nearest tick", func(t *testing.T) {
+		prices := []struct {
+			price                *big.Int
+			decimals0, decimals1 uint8
+		}{
+			{big.NewInt(3_000_000_000), 18, 6},             // 3,000 USDC per WETH
+			{big.NewInt(1_000_100), 6, 6},                  // 1.0001, a stable pair
+			{fromString("333333333333333"), 6, 18},         // 0.000333 WETH per USDC
+			{fromString("25000000000000000000000"), 8, 18}, // 25,000 per WBTC
+		}
+		for _, tc := range prices {
+			tick := PriceToTick(tc.price, tc.decimals0, tc.decimals1)
+			back := TickToPrice(tick, tc.decimals0, tc.decimals1)
+			require.NotNil(t, back)
+
+			// The nearest tick is within half a tick (0.005%) of the price.
+			relDiff, _ := new(big.Float).Quo(new(big.Float).SetInt(new(big.Int).Sub(back, tc.price)), new(big.Float).SetInt(tc.price)).Float64()
+			assert.InDelta(t, 0, relDiff, 0.5001e-4, "price %s -> tick %d -> %s", tc.price, tick, back)
+		}
+	})
+
+	t.Run("Clamps to the tick range", func(t *testing.T) {
+		assert.Equal(t, tickmath.MIN_TICK, PriceToTick(big.NewInt(0), 18, 18))
+		assert.Equal(t, tickmath.MIN_TICK, PriceToTick(nil, 18, 18))
+		huge := new(big.Int).Lsh(big.NewInt(1), 256)
+		assert.Equal(t, tickmath.MAX_TICK, PriceToTick(huge, 0, 18))
+	})
+}
+
+func TestPriceAtTick(t *testing.T) {
+	pool := createRealisticV3Pool(t) // token0 USDC (6), token1 WETH (18)
+
+	t.Run("Matches the spot price at the current tick", func(t *testing.T) {
+		spot, err := GetSpotPriceWithOptions(pool.Token0, pool.Token1, 6, 18, pool, SpotPriceOptions{})
+		require.NoError(t, err)
+		spotWhole := new(big.Float).Quo(new(big.Float).SetInt(spot), big.NewFloat(1e18))
+
+		price, err := PriceAtTick(pool.Tick, pool, 6, 18)
+		require.NoError(t, err)
+
+		// The pool price lies within one tick (0.01%) above the price at its current tick.
+		relDiff, _ := new(big.Float).Quo(new(big.Float).Sub(spotWhole, price), spotWhole).Float64()
+		assert.GreaterOrEqual(t, relDiff, -1e-12)
+		assert.Less(t, relDiff, 1.0001e-4)
+
+		// And it is TickToPrice in whole tokens.
+		fixed := new(big.Float).Quo(new(big.Float).SetInt(TickToPrice(pool.Tick, 6, 18)), big.NewFloat(1e18))
+		diff, _ := new(big.Float).Sub(price, fixed).Float64()
+		assert.InDelta(t, 0, diff, 1e-18)
+	})
+
+	t.Run("Ticks far from the current tick", func(t *testing.T) {
+		current, err := PriceAtTick(pool.Tick, pool, 6, 18)
+		require.NoError(t, err)
+		for _, delta := range []int64{-100_000, -10_000, 10_000, 100_000} {
+			price, err := PriceAtTick(pool.Tick+delta, pool, 6, 18)
+			require.NoError(t, err)
+			ratio, _ := new(big.Float).Quo(price, current).Float64()
+			expected := math.Pow(1.0001, float64(delta))
+			assert.InEpsilon(t, expected, ratio, 1e-9, "delta %d", delta)
+		}
+
+		// About 3,000 USDC per WETH: 1 USDC is worth 1/3000 WETH.
+		price, err := PriceAtTick(196_257, pool, 6, 18)
+		require.NoError(t, err)
+		f, _ := price.Float64()
+		assert.InEpsilon(t, 1.0/3000, f, 1e-3)
+
+		// The extremes of the tick range are valid and remain positive.
+		for _, tick := range []int64{tickmath.MIN_TICK, tickmath.MAX_TICK} {
+			price, err := PriceAtTick(tick, pool, 6, 18)
+			require.NoError(t, err)
+			assert.Equal(t, 1, price.Sign())
+		}
+	})
+
+	t.Run("Invalid inputs", func(t *testing.T) {
+		_, err := PriceAtTick(tickmath.MAX_TICK+1, pool, 6, 18)
+		assert.Error(t, err)
+
+		withDecimals := pool
+		decimals0, decimals1 := uint8(6), uint8(18)
+		withDecimals.Decimals0, withDecimals.Decimals1 = &decimals0, &decimals1
+		_, err = PriceAtTick(pool.Tick, withDecimals, 6, 18)
+		assert.NoError(t, err)
+		_, err = PriceAtTick(pool.Tick, withDecimals, 18, 6)
+		assert.Error(t, err, "swapped decimals")
+	})
+}
+
+func TestMarginalPriceAfter(t *testing.T) {
+	pool := createRealisticV3Pool(t)
+
+	t.Run("Worsens monotonically with input size", func(t *testing.T) {
+		for _, tc := range []struct {
+			name      string
+			tokenInID uint64
+			amounts   []*big.Int
+		}{
+			{
+				name:      "USDC for WETH",
+				tokenInID: 0,
+				amounts: []*big.Int{
+					big.NewInt(1e6), big.NewInt(1_000e6), big.NewInt(100_000e6), big.NewInt(1_000_000e6),
+				},
+			},
+			{
+				name:      "WETH for USDC",
+				tokenInID: 1,
+				amounts: []*big.Int{
+					fromString("1000000000000000"), fromString("100000000000000000"), fromString("10000000000000000000"),
+				},
+			},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				previous := marginalPrice(tc.tokenInID, pool)
+				for _, amountIn := range tc.amounts {
+					price, err := MarginalPriceAfter(amountIn, tc.tokenInID, pool)
+					require.NoError(t, err)
+					assert.Equal(t, -1, price.Cmp(previous), "marginal price should worsen after swapping %s", amountIn)
+					previous = price
+				}
+			})
+		}
+	})
+
+	t.Run("Is worse than the average execution price", func(t *testing.T) {
+		amountIn := big.NewInt(1_000_000e6)
+		amountOut, err := GetAmountOut(amountIn, nil, 0, pool)
+		require.NoError(t, err)
+
+		average := new(big.Float).Quo(new(big.Float).SetInt(amountOut), new(big.Float).SetInt(amountIn))
+		marginal, err := MarginalPriceAfter(amountIn, 0, pool)
+		require.NoError(t, err)
+		assert.Equal(t, -1, marginal.Cmp(average))
+	})
+
+	t.Run("Does not mutate the input pool", func(t *testing.T) {
+		before := new(big.Int).Set(pool.SqrtPriceX96)
+		_, err := MarginalPriceAfter(big.NewInt(1_000_000e6), 0, pool)
+		require.NoError(t, err)
+		assert.Equal(t, before, pool.SqrtPriceX96)
+	})
+
+	t.Run("Invalid input", func(t *testing.T) {
+		_, err := MarginalPriceAfter(big.NewInt(0), 0, pool)
+		assert.ErrorIs(t, err, ErrInvalidAmountIn)
+
+		_, err = MarginalPriceAfter(big.NewInt(1e6), 99, pool)
+		assert.ErrorIs(t, err, ErrTokenMismatch)
+	})
+}
+
+func TestAmountToReachPrice(t *testing.T) {
+	pool := createRealisticV3Pool(t)
+	sqrtPriceAt := func(t *testing.T, tick int64) *big.Int {
+		t.Helper()
+		sqrtPriceX96 := new(big.Int)
+		require.NoError(t, tickmath.GetSqrtRatioAtTick(sqrtPriceX96, tick))
+		return sqrtPriceX96
+	}
+
+	for _, tc := range []struct {
+		name       string
+		zeroForOne bool
+		tokenInID  uint64
+		target     int64
+	}{
+		{name: "USDC for WETH lowers the price", zeroForOne: true, tokenInID: 0, target: pool.Tick - 500},
+		{name: "WETH for USDC raises the price", zeroForOne: false, tokenInID: 1, target: pool.Tick + 500},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			target := sqrtPriceAt(t, tc.target)
+			amountIn, err := AmountToReachPrice(pool, target, tc.zeroForOne)
+			require.NoError(t, err)
+			require.Positive(t, amountIn.Sign())
+
+			// Swapping the amount lands on the target; one unit less falls just short.
+			_, after, err := SimulateExactInSwap(amountIn, nil, tc.tokenInID, pool)
+			require.NoError(t, err)
+			relDiff, _ := new(big.Float).Quo(
+				new(big.Float).SetInt(new(big.Int).Sub(after.SqrtPriceX96, target)),
+				new(big.Float).SetInt(target),
+			).Float64()
+			assert.InDelta(t, 0, relDiff, 1e-15)
+
+			_, short, err := SimulateExactInSwap(new(big.Int).Sub(amountIn, big.NewInt(1)), nil, tc.tokenInID, pool)
+			require.NoError(t, err)
+			if tc.zeroForOne {
+				assert.Equal(t, 1, short.SqrtPriceX96.Cmp(target))
+			} else {
+				assert.Equal(t, -1, short.SqrtPriceX96.Cmp(target))
+			}
+		})
+	}
+
+	t.Run("Current price needs no input", func(t *testing.T) {
+		amountIn, err := AmountToReachPrice(pool, pool.SqrtPriceX96, true)
+		require.NoError(t, err)
+		assert.Zero(t, amountIn.Sign())
+	})
+
+	t.Run("Unreachable targets", func(t *testing.T) {
+		_, err := AmountToReachPrice(pool, sqrtPriceAt(t, pool.Tick+500), true)
+		assert.ErrorIs(t, err, ErrPriceUnreachable, "selling token0 cannot raise the price")
+
+		_, err = AmountToReachPrice(pool, tickmath.MIN_SQRT_RATIO, true)
+		assert.ErrorIs(t, err, ErrPriceUnreachable, "past the last initialized tick")
+
+		_, err = AmountToReachPrice(pool, nil, true)
+		assert.ErrorIs(t, err, ErrPriceUnreachable)
+	})
+}
+
+func TestTickSeries(t *testing.T) {
+	t.Run("Cumulative liquidity reconstructs pool liquidity at the current tick", func(t *testing.T) {
+		// Build a consistent pool from a set of overlapping positions: each position adds
+		// +L at its lower tick and -L at its upper tick, and is active if it spans the current tick.
+		positions := []struct {
+			lower, upper int64
+			liquidity    int64
+		}{
+			{-887220, 887220, 1_000_000},
+			{193000, 194000, 5_000_000},
+			{193500, 193600, 2_500_000},
+			{193600, 195000, 7_000_000}, // Out of range (above the current tick)
+			{180000, 193500, 9_000_000}, // Out of range (ends below the current tick)
+		}
+		currentTick := int64(193540)
+
+		liquidityNet := map[int64]*big.Int{}
+		activeLiquidity := new(big.Int)
+		for _, p := range positions {
+			for _, tick := range []int64{p.lower, p.upper} {
+				if liquidityNet[tick] == nil {
+					liquidityNet[tick] = new(big.Int)
+				}
+			}
+			liquidityNet[p.lower].Add(liquidityNet[p.lower], big.NewInt(p.liquidity))
+			liquidityNet[p.upper].Sub(liquidityNet[p.upper], big.NewInt(p.liquidity))
+			if p.lower <= currentTick && currentTick < p.upper {
+				activeLiquidity.Add(activeLiquidity, big.NewInt(p.liquidity))
+			}
+		}
+
+		pool := uniswapv3.Pool{
+			PoolViewMinimal: uniswapv3.PoolViewMinimal{
+				Tick:      currentTick,
+				Liquidity: activeLiquidity,
+			},
+		}
+		for index, net := range liquidityNet {
+			pool.Ticks = append(pool.Ticks, uniswapv3.TickInfo{Index: index, LiquidityNet: net})
+		}
+
+		series := TickSeries(pool)
+		require.Len(t, series, len(liquidityNet))
+
+		var current *TickPoint
+		for i := range series {
+			if i > 0 {
+				assert.Less(t, series[i-1].Index, series[i].Index, "series must be in ascending tick order")
+				assert.Less(t, series[i-1].Price, series[i].Price, "series must be in ascending price order")
+			}
+			if series[i].Index <= pool.Tick {
+				current = &series[i]
+			}
+		}
+
+		require.NotNil(t, current)
+		assert.Equal(t, int64(193500), current.Index)
+		assert.Equal(t, 0, current.LiquidityActive.Cmp(pool.Liquidity),
+			"expected active liquidity %s at tick %d, got %s", pool.Liquidity, pool.Tick, current.LiquidityActive)
+
+		// Crossing every initialized tick must return all liquidity.
+		assert.Equal(t, 0, series[len(series)-1].LiquidityActive.Sign())
+	})
+
+	t.Run("Realistic pool snapshot", func(t *testing.T) {
+		pool := createRealisticV3Pool(t)
+		series := TickSeries(pool)
+		require.Len(t, series, len(pool.Ticks))
+		for i := 1; i < len(series); i++ {
+			assert.Less(t, series[i-1].Index, series[i].Index)
+			assert.Equal(t, -1, series[i-1].SqrtPriceX96.Cmp(series[i].SqrtPriceX96))
+		}
+	})
+
+	t.Run("Unsorted ticks and prices", func(t *testing.T) {
+		pool := uniswapv3.Pool{
+			PoolViewMinimal: uniswapv3.PoolViewMinimal{
+				Tick:      5,
+				Liquidity: big.NewInt(300),
+			},
+			Ticks: []uniswapv3.TickInfo{
+				{Index: 60, LiquidityNet: big.NewInt(-200)},
+				{Index: -60, LiquidityNet: big.NewInt(100)},
+				{Index: 120, LiquidityNet: big.NewInt(-100)},
+				{Index: 0, LiquidityNet: big.NewInt(200)},
+			},
+		}
+
+		series := TickSeries(pool)
+		require.Len(t, series, 4)
+
+		expectedIndexes := []int64{-60, 0, 60, 120}
+		expectedActive := []int64{100, 300, 100, 0}
+		for i, point := range series {
+			assert.Equal(t, expectedIndexes[i], point.Index)
+			assert.Equal(t, expectedActive[i], point.LiquidityActive.Int64())
+		}
+
+		assert.Equal(t, float64(1), series[1].Price, "price at tick 0 is 1")
+		assert.Equal(t, 0, series[1].SqrtPriceX96.Cmp(Q96), "sqrt price at tick 0 is 2^96")
+		assert.Equal(t, 0, series[1].LiquidityActive.Cmp(pool.Liquidity))
+
+		// The input pool must not be mutated.
+		assert.Equal(t, int64(60), pool.Ticks[0].Index)
+		assert.Equal(t, int64(-200), pool.Ticks[0].LiquidityNet.Int64())
+	})
+
+	t.Run("Pool without ticks", func(t *testing.T) {
+		assert.Empty(t, TickSeries(uniswapv3.Pool{}))
+	})
+}
+
+func TestConcentrationScore(t *testing.T) {
+	t.Run("Realistic pool", func(t *testing.T) {
+		pool := createRealisticV3Pool(t)
+
+		previous := -1.0
+		for _, band := range []int{10, 100, 1_000, 10_000, 100_000, 1_000_000} {
+			score := ConcentrationScore(pool, band)
+			assert.GreaterOrEqual(t, score, 0.0, "band %d", band)
+			assert.LessOrEqual(t, score, 1.0, "band %d", band)
+			assert.Greater(t, score, previous, "wider band %d should capture more liquidity", band)
+			previous = score
+		}
+		assert.InDelta(t, 1.0, ConcentrationScore(pool, 2_000_000), 1e-9, "a band covering every tick captures everything")
+	})
+
+	t.Run("Single position", func(t *testing.T) {
+		// One position over [-100, 100) with the price in the middle.
+		pool := uniswapv3.Pool{
+			PoolViewMinimal: uniswapv3.PoolViewMinimal{Tick: 0, Liquidity: big.NewInt(1000)},
+			Ticks: []uniswapv3.TickInfo{
+				{Index: 100, LiquidityNet: big.NewInt(-1000)},
+				{Index: -100, LiquidityNet: big.NewInt(1000)},
+			},
+		}
+		assert.InDelta(t, 0.5, ConcentrationScore(pool, 50), 1e-9)
+		assert.InDelta(t, 1.0, ConcentrationScore(pool, 100), 1e-9)
+		assert.InDelta(t, 1.0, ConcentrationScore(pool, 500), 1e-9)
+	})
+
+	t.Run("Tighter positions score higher", func(t *testing.T) {
+		tight := uniswapv3.Pool{
+			PoolViewMinimal: uniswapv3.PoolViewMinimal{Tick: 0, Liquidity: big.NewInt(1000)},
+			Ticks: []uniswapv3.TickInfo{
+				{Index: -60, LiquidityNet: big.NewInt(1000)},
+				{Index: 60, LiquidityNet: big.NewInt(-1000)},
+			},
+		}
+		wide := uniswapv3.Pool{
+			PoolViewMinimal: uniswapv3.PoolViewMinimal{Tick: 0, Liquidity: big.NewInt(1000)},
+			Ticks: []uniswapv3.TickInfo{
+				{Index: -6000, LiquidityNet: big.NewInt(1000)},
+				{Index: 6000, LiquidityNet: big.NewInt(-1000)},
+			},
+		}
+		assert.Greater(t, ConcentrationScore(tight, 60), ConcentrationScore(wide, 60))
+	})
+
+	t.Run("Degenerate input", func(t *testing.T) {
+		assert.Equal(t, 0.0, ConcentrationScore(uniswapv3.Pool{}, 100))
+		assert.Equal(t, 0.0, ConcentrationScore(createRealisticV3Pool(t), 0))
+	})
+}
+
+func TestSimulateSwapDetailed(t *testing.T) {
+	pool := createRealisticV3Pool(t)
+
+	t.Run("exact in matches the plain simulation", func(t *testing.T) {
+		for _, amountIn := range []*big.Int{big.NewInt(1_000e6), big.NewInt(1_000_000e6)} {
+			amountOut, newPool, err := SimulateExactInSwap(amountIn, nil, 0, pool)
+			require.NoError(t, err)
+
+			result, err := SimulateExactInSwapDetailed(amountIn, nil, 0, pool)
+			require.NoError(t, err)
+			assert.Equal(t, amountOut.String(), result.AmountOut.String())
+			assert.Equal(t, amountIn.String(), result.AmountIn.String())
+			assert.Equal(t, newPool.Tick, result.Pool.Tick)
+			assert.Equal(t, newPool.SqrtPriceX96.String(), result.Pool.SqrtPriceX96.String())
+			assert.Equal(t, newPool.Liquidity.String(), result.Pool.Liquidity.String())
+
+			// The fee is the pool's fee rate of the input, give or take rounding per step.
+			expectedFee := new(big.Int).Div(new(big.Int).Mul(amountIn, big.NewInt(int64(pool.Fee))), big.NewInt(1e6))
+			assert.InDelta(t, expectedFee.Int64(), result.FeeAmount.Int64(), float64(len(result.Steps)))
+			assert.Positive(t, result.FeeGrowthX128.Sign())
+
+			// Steps chain price to price, and every crossing changes the liquidity.
+			require.NotEmpty(t, result.Steps)
+			assert.Equal(t, pool.SqrtPriceX96.String(), result.Steps[0].SqrtPriceStartX96.String())
+			for i := 1; i < len(result.Steps); i++ {
+				assert.Equal(t, result.Steps[i-1].SqrtPriceEndX96.String(), result.Steps[i].SqrtPriceStartX96.String())
+			}
+			crossed := 0
+			for i, step := range result.Steps {
+				if step.Crossed {
+					crossed++
+					if i+1 < len(result.Steps) {
+						assert.NotEqual(t, step.Liquidity.String(), result.Steps[i+1].Liquidity.String())
+					}
+				}
+			}
+			assert.Equal(t, crossed, result.TicksCrossed)
+		}
+
+		large, err := SimulateExactInSwapDetailed(big.NewInt(1_000_000e6), nil, 0, pool)
+		require.NoError(t, err)
+		small, err := SimulateExactInSwapDetailed(big.NewInt(1_000e6), nil, 0, pool)
+		require.NoError(t, err)
+		assert.Greater(t, large.TicksCrossed, small.TicksCrossed)
+	})
+
+	t.Run("exact out matches the plain simulation", func(t *testing.T) {
+		amountOut := fromString("-10000000000000000000") // 10 WETH
+		amountIn, newPool, err := SimulateExactOutSwap(amountOut, nil, 0, pool)
+		require.NoError(t, err)
+
+		result, err := SimulateExactOutSwapDetailed(amountOut, nil, 0, pool)
+		require.NoError(t, err)
+		assert.Equal(t, amountIn.String(), result.AmountIn.String())
+		assert.Equal(t, new(big.Int).Neg(amountOut).String(), result.AmountOut.String())
+		assert.Equal(t, newPool.Tick, result.Pool.Tick)
+	})
+
+	t.Run("invalid input", func(t *testing.T) {
+		_, err := SimulateExactInSwapDetailed(big.NewInt(0), nil, 0, pool)
+		assert.ErrorIs(t, err, ErrInvalidAmountIn)
+		_, err = SimulateExactOutSwapDetailed(big.NewInt(1), nil, 0, pool)
+		assert.Error(t, err)
+		_, err = SimulateExactInSwapDetailed(big.NewInt(1e6), nil, 7, pool)
+		assert.ErrorIs(t, err, ErrTokenMismatch)
+	})
+}
+
+func TestSimulateSwap_FeeOverrideAndProtocolFee(t *testing.T) {
+	pool := createRealisticV3Pool(t)
+	amountIn := big.NewInt(1_000_000e6)
+
+	t.Run("fee override", func(t *testing.T) {
+		base, err := SimulateExactInSwapDetailed(amountIn, nil, 0, pool)
+		require.NoError(t, err)
+
+		fee := pool.Fee * 2
+		overridden := pool
+		overridden.FeeOverride = &fee
+		result, err := SimulateExactInSwapDetailed(amountIn, nil, 0, overridden)
+		require.NoError(t, err)
+		assert.Less(t, result.AmountOut.Cmp(base.AmountOut), 0)
+		assert.InDelta(t, 2*base.FeeAmount.Int64(), result.FeeAmount.Int64(), float64(2*len(result.Steps)))
+
+		amountOut, _, err := SimulateExactInSwap(amountIn, nil, 0, overridden)
+		require.NoError(t, err)
+		assert.Equal(t, result.AmountOut.String(), amountOut.String())
+	})
+
+	t.Run("protocol fee", func(t *testing.T) {
+		base, err := SimulateExactInSwapDetailed(amountIn, nil, 0, pool)
+		require.NoError(t, err)
+		assert.Equal(t, "0", base.ProtocolFeeAmount.String())
+
+		feeProtocol := uint32(4) // 1/4 of token0 fees
+		switched := pool
+		switched.FeeProtocol = &feeProtocol
+		switched.FeeGrowthGlobal0X128 = big.NewInt(0)
+		switched.ProtocolFees0 = big.NewInt(0)
+		result, err := SimulateExactInSwapDetailed(amountIn, nil, 0, switched)
+		require.NoError(t, err)
+
+		// The switch splits the fee without changing the swap.
+		assert.Equal(t, base.AmountOut.String(), result.AmountOut.String())
+		assert.Equal(t, base.FeeAmount.String(), result.FeeAmount.String())
+		assert.InDelta(t, base.FeeAmount.Int64()/4, result.ProtocolFeeAmount.Int64(), float64(len(result.Steps)))
+		assert.Less(t, result.FeeGrowthX128.Cmp(base.FeeGrowthX128), 0)
+
+		// The fees accrue to the returned pool and not to the input.
+		assert.Equal(t, result.ProtocolFeeAmount.String(), result.Pool.ProtocolFees0.String())
+		assert.Equal(t, result.FeeGrowthX128.String(), result.Pool.FeeGrowthGlobal0X128.String())
+		assert.Equal(t, "0", switched.ProtocolFees0.String())
+		assert.Nil(t, result.Pool.ProtocolFees1)
+	})
+}
+
+func TestGetAmountsOut(t *testing.T) {
+	pool := createRealisticV3Pool(t)
+
+	// Sizes from dust to more than the pool holds, with a repeat.
+	amountsIn := []*big.Int{big.NewInt(1)}
+	for size := big.NewInt(1_000); size.Cmp(fromString("1000000000000000000000000")) < 0; size = new(big.Int).Mul(size, big.NewInt(7)) {
+		amountsIn = append(amountsIn, size)
+	}
+	amountsIn = append(amountsIn, amountsIn[len(amountsIn)-1])
+
+	for _, tokenIn := range []uint64{pool.Token0, pool.Token1} {
+		amountsOut, err := GetAmountsOut(amountsIn, nil, tokenIn, pool)
+		require.NoError(t, err)
+		require.Len(t, amountsOut, len(amountsIn))
+		for i, amountIn := range amountsIn {
+			expected, err := GetAmountOut(amountIn, nil, tokenIn, pool)
+			require.NoError(t, err)
+			assert.Equal(t, expected.String(), amountsOut[i].String(), "token %d, amount %s", tokenIn, amountIn)
+		}
+	}
+
+	t.Run("invalid input", func(t *testing.T) {
+		_, err := GetAmountsOut([]*big.Int{big.NewInt(1), big.NewInt(0)}, nil, 0, pool)
+		assert.ErrorIs(t, err, ErrInvalidAmountIn)
+		_, err = GetAmountsOut([]*big.Int{big.NewInt(2), big.NewInt(1)}, nil, 0, pool)
+		assert.ErrorContains(t, err, "ascending")
+		_, err = GetAmountsOut([]*big.Int{big.NewInt(1)}, nil, 7, pool)
+		assert.ErrorIs(t, err, ErrTokenMismatch)
+
+		amountsOut, err := GetAmountsOut(nil, nil, 0, pool)
+		require.NoError(t, err)
+		assert.Empty(t, amountsOut)
+	})
+}
+
+func BenchmarkGetAmountsOut(b *testing.B) {
+	pool := createRealisticV3Pool(nil)
+	amountsIn := make([]*big.Int, 32)
+	for i := range amountsIn {
+		amountsIn[i] = new(big.Int).Mul(big.NewInt(int64(i+1)), big.NewInt(50_000e6))
+	}
+
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := GetAmountsOut(amountsIn, nil, 0, pool); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("one by one", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, amountIn := range amountsIn {
+				if _, err := GetAmountOut(amountIn, nil, 0, pool); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+func TestDepthCurve(t *testing.T) {
+	pool := createRealisticV3Pool(t)
+	minAmountIn, maxAmountIn := big.NewInt(1_000e6), big.NewInt(10_000_000e6)
+	curve, err := DepthCurve(minAmountIn, maxAmountIn, 5, pool.Token0, pool)
+	require.NoError(t, err)
+	require.Len(t, curve, 5)
+	assert.Equal(t, minAmountIn, curve[0].AmountIn)
+	assert.Equal(t, maxAmountIn, curve[4].AmountIn)
+
+	for i, point := range curve {
+		amountOut, err := GetAmountOut(point.AmountIn, nil, pool.Token0, pool)
+		require.NoError(t, err)
+		assert.Equal(t, amountOut.String(), point.AmountOut.String())
+
+		price, err := MarginalPriceAfter(point.AmountIn, pool.Token0, pool)
+		require.NoError(t, err)
+		assert.Equal(t, 0, price.Cmp(point.MarginalPrice))
+		if i > 0 {
+			assert.Equal(t, -1, point.MarginalPrice.Cmp(curve[i-1].MarginalPrice))
+		}
+	}
+
+	_, err = DepthCurve(maxAmountIn, minAmountIn, 5, pool.Token0, pool)
+	assert.ErrorIs(t, err, ErrInvalidAmountIn)
+	_, err = DepthCurve(minAmountIn, maxAmountIn, 5, 7, pool)
+	assert.ErrorIs(t, err, ErrTokenMismatch)
+}
@@ -0,0 +1,183 @@
+package uniswapv3
+
+import (
+	"errors"
+	"math/big"
+	"sync/atomic"
+
+	"github.com/holiman/uint256"
+
+	uniswapv3 "github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3/calculator/liquiditymath"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3/calculator/sqrtpricemath"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3/calculator/swapmath"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3/calculator/tickbitmap"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3/calculator/tickmath"
+)
+
+// useUint256 selects the uint256 swap path for GetAmountOut and GetAmountIn.
+var useUint256 atomic.Bool
+
+var maxUint128U256 = new(uint256.Int).SubUint64(new(uint256.Int).Lsh(uint256.NewInt(1), 128), 1)
+
+// SetUint256Math selects the math GetAmountOut and GetAmountIn quote with. The uint256
+// path keeps every value in fixed-width integers on the stack instead of pooled big.Ints,
+// and returns the same amounts; a swap with a value beyond 256 bits is recomputed with
+// big.Int. It is off by default and safe to call at any time.
+func SetUint256Math(enabled bool) {
+	useUint256.Store(enabled)
+}
+
+// Uint256Math reports whether GetAmountOut and GetAmountIn use the uint256 path.
+func Uint256Math() bool {
+	return useUint256.Load()
+}
+
+// swapU256 is _swap in uint256 for the quoting paths: it returns the amount out of an
+// exact-input swap (positive amountSpecified) or the amount in of an exact-output swap
+// (negative amountSpecified). It returns sqrtpricemath.ErrUint256Overflow if any value
+// does not fit, in which case the caller quotes with _swap.
+func swapU256(
+	amountSpecified *big.Int,
+	sqrtPriceLimitX96 *big.Int,
+	zeroForOne bool,
+	pool uniswapv3.Pool,
+) (*big.Int, error) {
+	var (
+		remaining, calculated, sqrtPrice, liquidity, limit uint256.Int
+		sqrtPriceStart, sqrtPriceNext, target              uint256.Int
+		stepIn, stepOut, stepFee, stepAmount, liquidityNet uint256.Int
+	)
+
+	exactInput := amountSpecified.Sign() > 0
+	if _, overflow := absFromBig(&remaining, amountSpecified); overflow {
+		return nil, sqrtpricemath.ErrUint256Overflow
+	}
+	if !setU256(&sqrtPrice, pool.SqrtPriceX96) || !setU256(&liquidity, pool.Liquidity) {
+		return nil, sqrtpricemath.ErrUint256Overflow
+	}
+	if sqrtPriceLimitX96 == nil {
+		if zeroForOne {
+			sqrtPriceLimitX96 = tickmath.MIN_SQRT_RATIO
+		} else {
+			sqrtPriceLimitX96 = tickmath.MAX_SQRT_RATIO
+		}
+	}
+	if !setU256(&limit, sqrtPriceLimitX96) {
+		return nil, sqrtpricemath.ErrUint256Overflow
+	}
+
+	fee := pool.SwapFee()
+	tick := pool.Tick
+
+	for !remaining.IsZero() && !sqrtPrice.Eq(&limit) {
+		sqrtPriceStart.Set(&sqrtPrice)
+
+		tickNext, initialized := tickbitmap.NextInitializedTickWithinOneWord(pool.Ticks, tick, zeroForOne)
+		if !initialized {
+			break
+		}
+		if tickNext < tickmath.MIN_TICK {
+			tickNext = tickmath.MIN_TICK
+		} else if tickNext > tickmath.MAX_TICK {
+			tickNext = tickmath.MAX_TICK
+		}
+
+		if err := tickmath.GetSqrtRatioAtTickU256(&sqrtPriceNext, tickNext); err != nil {
+			return nil, err
+		}
+
+		if (zeroForOne && sqrtPriceNext.Lt(&limit)) || (!zeroForOne && sqrtPriceNext.Gt(&limit)) {
+			target.Set(&limit)
+		} else {
+			target.Set(&sqrtPriceNext)
+		}
+
+		err := swapmath.ComputeSwapStepU256(
+			&sqrtPrice, &stepIn, &stepOut, &stepFee,
+			&sqrtPriceStart,
+			&target,
+			&liquidity,
+			&remaining,
+			exactInput,
+			fee,
+		)
+		if err != nil {
+			if errors.Is(err, sqrtpricemath.ErrUint256Overflow) {
+				return nil, err
+			}
+			break // Can happen if liquidity is zero
+		}
+
+		if _, overflow := stepAmount.AddOverflow(&stepIn, &stepFee); overflow {
+			return nil, sqrtpricemath.ErrUint256Overflow
+		}
+		if exactInput {
+			if _, underflow := remaining.SubOverflow(&remaining, &stepAmount); underflow {
+				return nil, sqrtpricemath.ErrUint256Overflow
+			}
+			if _, overflow := calculated.AddOverflow(&calculated, &stepOut); overflow {
+				return nil, sqrtpricemath.ErrUint256Overflow
+			}
+		} else {
+			if _, underflow := remaining.SubOverflow(&remaining, &stepOut); underflow {
+				return nil, sqrtpricemath.ErrUint256Overflow
+			}
+			if _, overflow := calculated.AddOverflow(&calculated, &stepAmount); overflow {
+				return nil, sqrtpricemath.ErrUint256Overflow
+			}
+		}
+
+		if sqrtPrice.Eq(&sqrtPriceNext) {
+			for _, t := range pool.Ticks {
+				if t.Index != tickNext {
+					continue
+				}
+				negative, overflow := absFromBig(&liquidityNet, t.LiquidityNet)
+				if overflow {
+					return nil, sqrtpricemath.ErrUint256Overflow
+				}
+				// Crossing down subtracts the net liquidity that crossing up adds.
+				if negative != zeroForOne {
+					if liquidityNet.Gt(&liquidity) {
+						return calculated.ToBig(), nil
+					}
+					liquidity.Sub(&liquidity, &liquidityNet)
+				} else {
+					if _, overflow := liquidity.AddOverflow(&liquidity, &liquidityNet); overflow || liquidity.Gt(maxUint128U256) {
+						return nil, liquiditymath.ErrLiquidityOverflow
+					}
+				}
+				break
+			}
+
+			if zeroForOne {
+				tick = tickNext - 1
+			} else {
+				tick = tickNext
+			}
+		} else if !sqrtPrice.Eq(&sqrtPriceStart) {
+			if tick, err = tickmath.GetTickAtSqrtRatioU256(&sqrtPrice); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return calculated.ToBig(), nil
+}
+
+// absFromBig writes |x| into dest and reports whether x is negative and whether |x| does
+// not fit in 256 bits.
+func absFromBig(dest *uint256.Int, x *big.Int) (negative, overflow bool) {
+	overflow = dest.SetFromBig(x)
+	if x.Sign() < 0 {
+		dest.Neg(dest)
+		return true, overflow
+	}
+	return false, overflow
+}
+
+// setU256 writes x into dest and reports whether x is non-negative and fits in 256 bits,
+// the values the uint256 math agrees with big.Int on.
+func setU256(dest *uint256.Int, x *big.Int) bool {
+	return x.Sign() >= 0 && !dest.SetFromBig(x)
+}
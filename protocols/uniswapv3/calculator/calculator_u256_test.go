@@ -0,0 +1,136 @@
+package uniswapv3
+
+import (
+	"errors"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	uniswapv3 "github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3/calculator/sqrtpricemath"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3/calculator/tickmath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bigSwap quotes with the big.Int path whatever SetUint256Math selects.
+func bigSwap(amountSpecified, sqrtPriceLimitX96 *big.Int, zeroForOne bool, pool uniswapv3.Pool) (*big.Int, error) {
+	state := swapStatePool.Get().(*swapState)
+	defer swapStatePool.Put(state)
+
+	state.amountSpecifiedRemaining.Set(amountSpecified)
+	state.amountCalculated.SetInt64(0)
+	state.sqrtPriceX96.Set(pool.SqrtPriceX96)
+	state.tick = pool.Tick
+	state.liquidity.Set(pool.Liquidity)
+
+	if err := _swap(state, pool, sqrtPriceLimitX96, zeroForOne, nil, nil); err != nil {
+		return nil, err
+	}
+	return new(big.Int).Set(state.amountCalculated), nil
+}
+
+// TestSwapU256_MatchesBigInt quotes random swaps on the realistic pool with both paths:
+// amounts from dust to far beyond the pool's liquidity, in both directions and both swap
+// types, with and without a price limit, and at several fees.
+func TestSwapU256_MatchesBigInt(t *testing.T) {
+	base := createRealisticV3Pool(t)
+	rng := rand.New(rand.NewSource(2792))
+	var overflows, swaps int
+
+	for _, fee := range []uint64{0, 100, 500, 3000, 10_000, 999_999} {
+		pool := base
+		pool.FeeOverride = &fee
+
+		for i := 0; i < 150; i++ {
+			swaps++
+			zeroForOne := rng.Intn(2) == 0
+			amount := new(big.Int).Rand(rng, new(big.Int).Lsh(big.NewInt(1), uint(rng.Intn(256)+1)))
+			if amount.Sign() == 0 {
+				amount.SetInt64(1)
+			}
+			if rng.Intn(2) == 0 {
+				amount.Neg(amount)
+			}
+
+			var limit *big.Int
+			if rng.Intn(3) == 0 {
+				tick := pool.Tick + int64(rng.Intn(40_001)-20_000)
+				limit = new(big.Int)
+				require.NoError(t, tickmath.GetSqrtRatioAtTick(limit, tick))
+			}
+
+			want, wantErr := bigSwap(amount, limit, zeroForOne, pool)
+			got, gotErr := swapU256(amount, limit, zeroForOne, pool)
+			if errors.Is(gotErr, sqrtpricemath.ErrUint256Overflow) {
+				overflows++
+				continue
+			}
+			if wantErr != nil {
+				assert.Equal(t, wantErr, gotErr)
+				continue
+			}
+			require.NoError(t, gotErr)
+			assert.Equal(t, want.String(), got.String(), "fee %d, amount %s, zeroForOne %v, limit %v", fee, amount, zeroForOne, limit)
+		}
+	}
+
+	t.Logf("%d of %d swaps fell back to big.Int", overflows, swaps)
+	assert.Less(t, overflows, swaps/5, "too many swaps fell back to big.Int")
+}
+
+func TestSetUint256Math(t *testing.T) {
+	t.Cleanup(func() { SetUint256Math(false) })
+	pool := createRealisticV3Pool(t)
+
+	// A pool whose liquidity does not fit in 256 bits forces the big.Int fallback.
+	huge := pool
+	huge.Liquidity = new(big.Int).Lsh(big.NewInt(1), 300)
+
+	amounts := []*big.Int{big.NewInt(1), big.NewInt(1_000e6), fromString("1000000000000000000000"), new(big.Int).Lsh(big.NewInt(1), 255)}
+	for _, p := range []uniswapv3.Pool{pool, huge} {
+		for _, amount := range amounts {
+			for _, tokenIn := range []uint64{p.Token0, p.Token1} {
+				SetUint256Math(false)
+				require.False(t, Uint256Math())
+				wantOut, wantOutErr := GetAmountOut(amount, nil, tokenIn, p)
+				wantIn, wantInErr := GetAmountIn(new(big.Int).Neg(amount), nil, tokenIn, p)
+
+				SetUint256Math(true)
+				require.True(t, Uint256Math())
+				gotOut, gotOutErr := GetAmountOut(amount, nil, tokenIn, p)
+				gotIn, gotInErr := GetAmountIn(new(big.Int).Neg(amount), nil, tokenIn, p)
+
+				assert.Equal(t, wantOutErr, gotOutErr)
+				assert.Equal(t, wantInErr, gotInErr)
+				assert.Equal(t, wantOut.String(), gotOut.String(), "amount out of %s", amount)
+				assert.Equal(t, wantIn.String(), gotIn.String(), "amount in for %s", amount)
+			}
+		}
+	}
+
+	_, err := swapU256(big.NewInt(1), nil, true, huge)
+	assert.ErrorIs(t, err, sqrtpricemath.ErrUint256Overflow)
+}
+
+func BenchmarkGetAmountOut_Math(b *testing.B) {
+	pool := createRealisticV3Pool(nil)
+	amountIn := big.NewInt(1_000_000e6)
+	defer SetUint256Math(false)
+
+	for _, enabled := range []bool{false, true} {
+		name := "big.Int"
+		if enabled {
+			name = "uint256"
+		}
+		b.Run(name, func(b *testing.B) {
+			SetUint256Math(enabled)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := GetAmountOut(amountIn, nil, 0, pool); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
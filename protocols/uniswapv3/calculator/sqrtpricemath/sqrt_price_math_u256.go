@@ -0,0 +1,191 @@
+package sqrtpricemath
+
+import (
+	"errors"
+
+	"github.com/holiman/uint256"
+)
+
+// ErrUint256Overflow is returned by the uint256 functions when a value does not fit in
+// 256 bits. The big.Int functions have no such limit, so callers recompute with them.
+var ErrUint256Overflow = errors.New("uint256 overflow")
+
+var (
+	q96U256 = new(uint256.Int).Lsh(uint256.NewInt(1), Resolution)
+	oneU256 = uint256.NewInt(1)
+)
+
+// The uint256 functions below mirror the big.Int ones and return the same results
+// whenever no value exceeds 256 bits; otherwise they return ErrUint256Overflow. Products
+// are taken in 512 bits, as FullMath does, so only results can overflow.
+
+// MulDivU256 writes (a * b) / c into dest.
+func MulDivU256(dest, a, b, c *uint256.Int) error {
+	if c.IsZero() {
+		return ErrUint256Overflow
+	}
+	if _, overflow := dest.MulDivOverflow(a, b, c); overflow {
+		return ErrUint256Overflow
+	}
+	return nil
+}
+
+// MulDivRoundingUpU256 writes ceil((a * b) / c) into dest.
+func MulDivRoundingUpU256(dest, a, b, c *uint256.Int) error {
+	if c.IsZero() {
+		return ErrUint256Overflow
+	}
+	var rem uint256.Int
+	rem.MulMod(a, b, c)
+	if _, overflow := dest.MulDivOverflow(a, b, c); overflow {
+		return ErrUint256Overflow
+	}
+	if !rem.IsZero() {
+		if _, overflow := dest.AddOverflow(dest, oneU256); overflow {
+			return ErrUint256Overflow
+		}
+	}
+	return nil
+}
+
+// divRoundingUpU256 writes ceil(a / b) into dest.
+func divRoundingUpU256(dest, a, b *uint256.Int) {
+	var rem uint256.Int
+	rem.Mod(a, b)
+	dest.Div(a, b)
+	if !rem.IsZero() {
+		dest.Add(dest, oneU256)
+	}
+}
+
+// GetNextSqrtPriceFromInputU256 is GetNextSqrtPriceFromInput in uint256.
+func GetNextSqrtPriceFromInputU256(dest, sqrtPX96, liquidity, amountIn *uint256.Int, zeroForOne bool) error {
+	if sqrtPX96.IsZero() {
+		return ErrSqrtPriceZero
+	}
+	if liquidity.IsZero() {
+		return ErrLiquidityZero
+	}
+
+	if zeroForOne {
+		return getNextSqrtPriceFromAmount0RoundingUpU256(dest, sqrtPX96, liquidity, amountIn, true)
+	}
+	return getNextSqrtPriceFromAmount1RoundingDownU256(dest, sqrtPX96, liquidity, amountIn, true)
+}
+
+// GetNextSqrtPriceFromOutputU256 is GetNextSqrtPriceFromOutput in uint256.
+func GetNextSqrtPriceFromOutputU256(dest, sqrtPX96, liquidity, amountOut *uint256.Int, zeroForOne bool) error {
+	if sqrtPX96.IsZero() {
+		return ErrSqrtPriceZero
+	}
+	if liquidity.IsZero() {
+		return ErrLiquidityZero
+	}
+
+	if zeroForOne {
+		return getNextSqrtPriceFromAmount1RoundingDownU256(dest, sqrtPX96, liquidity, amountOut, false)
+	}
+	return getNextSqrtPriceFromAmount0RoundingUpU256(dest, sqrtPX96, liquidity, amountOut, false)
+}
+
+// GetAmount0DeltaU256 is GetAmount0Delta in uint256.
+func GetAmount0DeltaU256(dest, sqrtRatioAX96, sqrtRatioBX96, liquidity *uint256.Int, roundUp bool) error {
+	if sqrtRatioAX96.Gt(sqrtRatioBX96) {
+		sqrtRatioAX96, sqrtRatioBX96 = sqrtRatioBX96, sqrtRatioAX96
+	}
+	if sqrtRatioAX96.IsZero() {
+		return ErrSqrtPriceZero
+	}
+
+	var numerator1, numerator2, term uint256.Int
+	if err := lshResolution(&numerator1, liquidity); err != nil {
+		return err
+	}
+	numerator2.Sub(sqrtRatioBX96, sqrtRatioAX96)
+
+	if roundUp {
+		if err := MulDivRoundingUpU256(&term, &numerator1, &numerator2, sqrtRatioBX96); err != nil {
+			return err
+		}
+		divRoundingUpU256(dest, &term, sqrtRatioAX96)
+		return nil
+	}
+	if err := MulDivU256(&term, &numerator1, &numerator2, sqrtRatioBX96); err != nil {
+		return err
+	}
+	dest.Div(&term, sqrtRatioAX96)
+	return nil
+}
+
+// GetAmount1DeltaU256 is GetAmount1Delta in uint256.
+func GetAmount1DeltaU256(dest, sqrtRatioAX96, sqrtRatioBX96, liquidity *uint256.Int, roundUp bool) error {
+	if sqrtRatioAX96.Gt(sqrtRatioBX96) {
+		sqrtRatioAX96, sqrtRatioBX96 = sqrtRatioBX96, sqrtRatioAX96
+	}
+
+	var difference uint256.Int
+	difference.Sub(sqrtRatioBX96, sqrtRatioAX96)
+	if roundUp {
+		return MulDivRoundingUpU256(dest, liquidity, &difference, q96U256)
+	}
+	return MulDivU256(dest, liquidity, &difference, q96U256)
+}
+
+func getNextSqrtPriceFromAmount0RoundingUpU256(dest, sqrtPX96, liquidity, amount *uint256.Int, add bool) error {
+	if amount.IsZero() {
+		dest.Set(sqrtPX96)
+		return nil
+	}
+
+	var numerator1, product, denominator uint256.Int
+	if err := lshResolution(&numerator1, liquidity); err != nil {
+		return err
+	}
+	_, overflow := product.MulOverflow(amount, sqrtPX96)
+
+	if add {
+		if overflow {
+			return ErrUint256Overflow
+		}
+		if _, overflow := denominator.AddOverflow(&numerator1, &product); overflow {
+			return ErrUint256Overflow
+		}
+		return MulDivRoundingUpU256(dest, &numerator1, sqrtPX96, &denominator)
+	}
+	// A product beyond 256 bits exceeds numerator1 too, which the big.Int path rejects.
+	if overflow || !numerator1.Gt(&product) {
+		return errors.New("product overflow or denominator underflow")
+	}
+	denominator.Sub(&numerator1, &product)
+	return MulDivRoundingUpU256(dest, &numerator1, sqrtPX96, &denominator)
+}
+
+func getNextSqrtPriceFromAmount1RoundingDownU256(dest, sqrtPX96, liquidity, amount *uint256.Int, add bool) error {
+	var quotient uint256.Int
+	if add {
+		if err := MulDivU256(&quotient, amount, q96U256, liquidity); err != nil {
+			return err
+		}
+		if _, overflow := dest.AddOverflow(sqrtPX96, &quotient); overflow {
+			return ErrUint256Overflow
+		}
+		return nil
+	}
+	if err := MulDivRoundingUpU256(&quotient, amount, q96U256, liquidity); err != nil {
+		return err
+	}
+	if !sqrtPX96.Gt(&quotient) {
+		return errors.New("sqrtPX96 must be greater than quotient")
+	}
+	dest.Sub(sqrtPX96, &quotient)
+	return nil
+}
+
+// lshResolution writes x << 96 into dest.
+func lshResolution(dest, x *uint256.Int) error {
+	if x.BitLen() > 256-int(Resolution) {
+		return ErrUint256Overflow
+	}
+	dest.Lsh(x, Resolution)
+	return nil
+}
@@ -0,0 +1,102 @@
+package sqrtpricemath
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRandIntUpTo generates a random big.Int of a random bit length up to bits, so small
+// values are covered as well as values near the limit.
+func newRandIntUpTo(bits int) *big.Int {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(bits)))
+	if err != nil {
+		panic(err)
+	}
+	return newRandInt(int(n.Int64()) + 1)
+}
+
+// assertSameResult checks a uint256 result against the big.Int one: both fail, or both
+// succeed with the same value. A uint256 overflow is accepted and counted.
+func assertSameResult(t *testing.T, want *big.Int, wantErr error, got *uint256.Int, gotErr error, overflows *int) {
+	t.Helper()
+	if errors.Is(gotErr, ErrUint256Overflow) {
+		*overflows++
+		return
+	}
+	if wantErr != nil {
+		assert.Error(t, gotErr)
+		return
+	}
+	require.NoError(t, gotErr)
+	assert.Equal(t, want.String(), got.Dec())
+}
+
+func TestU256_MatchesBigInt(t *testing.T) {
+	const iterations = 20_000
+	var overflows int
+
+	for i := 0; i < iterations; i++ {
+		sqrtP := newRandIntUpTo(160)
+		sqrtQ := newRandIntUpTo(160)
+		liquidity := newRandIntUpTo(128)
+		amount := newRandIntUpTo(256)
+		if sqrtP.Sign() == 0 {
+			sqrtP.SetInt64(1)
+		}
+		if sqrtQ.Sign() == 0 {
+			sqrtQ.SetInt64(1)
+		}
+		if liquidity.Sign() == 0 {
+			liquidity.SetInt64(1)
+		}
+		uSqrtP, uSqrtQ := uint256.MustFromBig(sqrtP), uint256.MustFromBig(sqrtQ)
+		uLiquidity, uAmount := uint256.MustFromBig(liquidity), uint256.MustFromBig(amount)
+
+		for _, flag := range []bool{false, true} {
+			want, got := new(big.Int), new(uint256.Int)
+			wantErr := GetAmount0Delta(want, sqrtP, sqrtQ, liquidity, flag)
+			gotErr := GetAmount0DeltaU256(got, uSqrtP, uSqrtQ, uLiquidity, flag)
+			assertSameResult(t, want, wantErr, got, gotErr, &overflows)
+
+			want, got = new(big.Int), new(uint256.Int)
+			GetAmount1Delta(want, sqrtP, sqrtQ, liquidity, flag)
+			gotErr = GetAmount1DeltaU256(got, uSqrtP, uSqrtQ, uLiquidity, flag)
+			assertSameResult(t, want, nil, got, gotErr, &overflows)
+
+			want, got = new(big.Int), new(uint256.Int)
+			wantErr = GetNextSqrtPriceFromInput(want, sqrtP, liquidity, amount, flag)
+			gotErr = GetNextSqrtPriceFromInputU256(got, uSqrtP, uLiquidity, uAmount, flag)
+			assertSameResult(t, want, wantErr, got, gotErr, &overflows)
+
+			want, got = new(big.Int), new(uint256.Int)
+			wantErr = GetNextSqrtPriceFromOutput(want, sqrtP, liquidity, amount, flag)
+			gotErr = GetNextSqrtPriceFromOutputU256(got, uSqrtP, uLiquidity, uAmount, flag)
+			assertSameResult(t, want, wantErr, got, gotErr, &overflows)
+		}
+	}
+
+	t.Logf("%d of %d results fell back to big.Int", overflows, iterations*8)
+	// Overflows only come from amounts near 256 bits, a small share of the random ones.
+	assert.Less(t, overflows, iterations*8/5, "too many cases fell back to big.Int")
+}
+
+func TestMulDivU256(t *testing.T) {
+	max := new(uint256.Int).SetAllOne()
+
+	got := new(uint256.Int)
+	require.NoError(t, MulDivU256(got, max, max, max))
+	assert.True(t, got.Eq(max), "the product is taken in 512 bits")
+
+	require.NoError(t, MulDivRoundingUpU256(got, uint256.NewInt(7), uint256.NewInt(3), uint256.NewInt(2)))
+	assert.Equal(t, uint64(11), got.Uint64())
+
+	assert.ErrorIs(t, MulDivU256(got, max, uint256.NewInt(2), uint256.NewInt(1)), ErrUint256Overflow)
+	assert.ErrorIs(t, MulDivRoundingUpU256(got, max, max, uint256.NewInt(1)), ErrUint256Overflow)
+	assert.ErrorIs(t, MulDivU256(got, max, max, new(uint256.Int)), ErrUint256Overflow)
+}
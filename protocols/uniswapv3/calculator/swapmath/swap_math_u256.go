@@ -0,0 +1,131 @@
+package swapmath
+
+import (
+	"github.com/holiman/uint256"
+
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3/calculator/sqrtpricemath"
+)
+
+// feeDenominatorPips is feeDenominator as a plain integer.
+const feeDenominatorPips = 1_000_000
+
+// ComputeSwapStepU256 is ComputeSwapStep in uint256. The signed amountRemaining of
+// ComputeSwapStep is split into its absolute value and exactIn, which is true for a
+// non-negative amount.
+//
+// It returns the same results as ComputeSwapStep, or sqrtpricemath.ErrUint256Overflow if
+// a value does not fit in 256 bits, or feePips is not below 1e6. Callers recompute those
+// steps with ComputeSwapStep.
+func ComputeSwapStepU256(
+	// destination pointers
+	sqrtRatioNextX96 *uint256.Int,
+	amountIn *uint256.Int,
+	amountOut *uint256.Int,
+	feeAmount *uint256.Int,
+
+	sqrtRatioCurrentX96 *uint256.Int,
+	sqrtRatioTargetX96 *uint256.Int,
+	liquidity *uint256.Int,
+	amountRemaining *uint256.Int,
+	exactIn bool,
+	feePips uint64,
+) error {
+	if feePips >= feeDenominatorPips {
+		return sqrtpricemath.ErrUint256Overflow
+	}
+
+	zeroForOne := !sqrtRatioCurrentX96.Lt(sqrtRatioTargetX96)
+
+	var (
+		feeDenominator         = uint256.NewInt(feeDenominatorPips)
+		feeComplement          = uint256.NewInt(feeDenominatorPips - feePips)
+		fee                    = uint256.NewInt(feePips)
+		amountRemainingLessFee uint256.Int
+		next                   uint256.Int
+		in, out                uint256.Int
+	)
+
+	if exactIn {
+		if err := sqrtpricemath.MulDivU256(&amountRemainingLessFee, amountRemaining, feeComplement, feeDenominator); err != nil {
+			return err
+		}
+
+		var err error
+		if zeroForOne {
+			err = sqrtpricemath.GetAmount0DeltaU256(&in, sqrtRatioTargetX96, sqrtRatioCurrentX96, liquidity, true)
+		} else {
+			err = sqrtpricemath.GetAmount1DeltaU256(&in, sqrtRatioCurrentX96, sqrtRatioTargetX96, liquidity, true)
+		}
+		if err != nil {
+			return err
+		}
+
+		if !amountRemainingLessFee.Lt(&in) {
+			next.Set(sqrtRatioTargetX96)
+		} else if err := sqrtpricemath.GetNextSqrtPriceFromInputU256(&next, sqrtRatioCurrentX96, liquidity, &amountRemainingLessFee, zeroForOne); err != nil {
+			return err
+		}
+	} else {
+		var err error
+		if zeroForOne {
+			err = sqrtpricemath.GetAmount1DeltaU256(&out, sqrtRatioTargetX96, sqrtRatioCurrentX96, liquidity, false)
+		} else {
+			err = sqrtpricemath.GetAmount0DeltaU256(&out, sqrtRatioCurrentX96, sqrtRatioTargetX96, liquidity, false)
+		}
+		if err != nil {
+			return err
+		}
+
+		if !amountRemaining.Lt(&out) {
+			next.Set(sqrtRatioTargetX96)
+		} else if err := sqrtpricemath.GetNextSqrtPriceFromOutputU256(&next, sqrtRatioCurrentX96, liquidity, amountRemaining, zeroForOne); err != nil {
+			return err
+		}
+	}
+
+	max := sqrtRatioTargetX96.Eq(&next)
+
+	// --- Recalculate amounts based on the actual price movement ---
+	if zeroForOne {
+		if !(max && exactIn) {
+			if err := sqrtpricemath.GetAmount0DeltaU256(&in, &next, sqrtRatioCurrentX96, liquidity, true); err != nil {
+				return err
+			}
+		}
+		if !(max && !exactIn) {
+			if err := sqrtpricemath.GetAmount1DeltaU256(&out, &next, sqrtRatioCurrentX96, liquidity, false); err != nil {
+				return err
+			}
+		}
+	} else {
+		if !(max && exactIn) {
+			if err := sqrtpricemath.GetAmount1DeltaU256(&in, sqrtRatioCurrentX96, &next, liquidity, true); err != nil {
+				return err
+			}
+		}
+		if !(max && !exactIn) {
+			if err := sqrtpricemath.GetAmount0DeltaU256(&out, sqrtRatioCurrentX96, &next, liquidity, false); err != nil {
+				return err
+			}
+		}
+	}
+
+	// --- Final Adjustments ---
+	if !exactIn && out.Gt(amountRemaining) {
+		out.Set(amountRemaining)
+	}
+
+	if exactIn && !next.Eq(sqrtRatioTargetX96) {
+		// The big.Int path would return a negative fee; leave that to it.
+		if _, underflow := feeAmount.SubOverflow(amountRemaining, &in); underflow {
+			return sqrtpricemath.ErrUint256Overflow
+		}
+	} else if err := sqrtpricemath.MulDivRoundingUpU256(feeAmount, &in, fee, feeComplement); err != nil {
+		return err
+	}
+
+	sqrtRatioNextX96.Set(&next)
+	amountIn.Set(&in)
+	amountOut.Set(&out)
+	return nil
+}
@@ -0,0 +1,88 @@
+package swapmath
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3/calculator/sqrtpricemath"
+)
+
+// TestComputeSwapStepU256_MatchesBigInt runs both implementations on random steps and
+// checks they agree wherever the uint256 one does not fall back.
+func TestComputeSwapStepU256_MatchesBigInt(t *testing.T) {
+	const iterations = 20_000
+	var overflows int
+
+	for i := 0; i < iterations; i++ {
+		sqrtPrice := newRandIntUpTo(160)
+		sqrtPriceTarget := newRandIntUpTo(160)
+		liquidity := newRandIntUpTo(128)
+		amountRemaining := newRandIntUpTo(256)
+		feePips := newRandInt(20)
+		if sqrtPrice.Sign() == 0 {
+			sqrtPrice.SetInt64(1)
+		}
+		if sqrtPriceTarget.Sign() == 0 {
+			sqrtPriceTarget.SetInt64(1)
+		}
+		if amountRemaining.Sign() == 0 {
+			amountRemaining.SetInt64(1)
+		}
+		if feePips.Cmp(feeDenominator) >= 0 {
+			feePips.Sub(feeDenominator, one)
+		}
+		exactIn := i%2 == 0
+		signedRemaining := new(big.Int).Set(amountRemaining)
+		if !exactIn {
+			signedRemaining.Neg(signedRemaining)
+		}
+
+		sqrtQ, amountIn, amountOut, feeAmount := new(big.Int), new(big.Int), new(big.Int), new(big.Int)
+		wantErr := ComputeSwapStep(sqrtQ, amountIn, amountOut, feeAmount,
+			sqrtPrice, sqrtPriceTarget, liquidity, signedRemaining, feePips)
+
+		var uSqrtQ, uAmountIn, uAmountOut, uFeeAmount uint256.Int
+		gotErr := ComputeSwapStepU256(&uSqrtQ, &uAmountIn, &uAmountOut, &uFeeAmount,
+			uint256.MustFromBig(sqrtPrice), uint256.MustFromBig(sqrtPriceTarget), uint256.MustFromBig(liquidity),
+			uint256.MustFromBig(amountRemaining), exactIn, feePips.Uint64())
+
+		if errors.Is(gotErr, sqrtpricemath.ErrUint256Overflow) {
+			overflows++
+			continue
+		}
+		if wantErr != nil {
+			assert.Error(t, gotErr)
+			continue
+		}
+		require.NoError(t, gotErr)
+		assert.Equal(t, sqrtQ.String(), uSqrtQ.Dec())
+		assert.Equal(t, amountIn.String(), uAmountIn.Dec())
+		assert.Equal(t, amountOut.String(), uAmountOut.Dec())
+		assert.Equal(t, feeAmount.String(), uFeeAmount.Dec())
+	}
+
+	t.Logf("%d of %d steps fell back to big.Int", overflows, iterations)
+	assert.Less(t, overflows, iterations/5, "too many steps fell back to big.Int")
+}
+
+func TestComputeSwapStepU256_FeeOutOfRange(t *testing.T) {
+	var sqrtQ, amountIn, amountOut, feeAmount uint256.Int
+	err := ComputeSwapStepU256(&sqrtQ, &amountIn, &amountOut, &feeAmount,
+		uint256.NewInt(1<<40), uint256.NewInt(1<<41), uint256.NewInt(1<<50), uint256.NewInt(1000), true, 1_000_000)
+	assert.ErrorIs(t, err, sqrtpricemath.ErrUint256Overflow)
+}
+
+// newRandIntUpTo generates a random big.Int of a random bit length up to bits.
+func newRandIntUpTo(bits int) *big.Int {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(bits)))
+	if err != nil {
+		panic(err)
+	}
+	return newRandInt(int(n.Int64()) + 1)
+}
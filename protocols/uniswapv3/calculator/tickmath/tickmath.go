@@ -19,6 +19,9 @@ var (
 	// MAX_SQRT_RATIO is the maximum value that can be returned from getSqrtRatioAtTick.
 	MAX_SQRT_RATIO, _ = new(big.Int).SetString("1461446703485210103287273052203988822378723970342", 10)
 
+	minSqrtRatioU256 = uint256.MustFromBig(MIN_SQRT_RATIO)
+	maxSqrtRatioU256 = uint256.MustFromBig(MAX_SQRT_RATIO)
+
 	ErrTickOutOfBounds      = errors.New("tick out of bounds")
 	ErrSqrtPriceOutOfBounds = errors.New("sqrt price out of bounds")
 
@@ -82,6 +85,27 @@ func GetSqrtRatioAtTick(dest *big.Int, tick int64) error {
 	tm := pool.Get().(*tickMath)
 	defer pool.Put(tm)
 
+	tm.sqrtRatioAtTick(tick)
+	tm.ratio.IntoBig(&dest)
+	return nil
+}
+
+// GetSqrtRatioAtTickU256 is GetSqrtRatioAtTick for the uint256 swap path.
+func GetSqrtRatioAtTickU256(dest *uint256.Int, tick int64) error {
+	if tick < MIN_TICK || tick > MAX_TICK {
+		return ErrTickOutOfBounds
+	}
+
+	tm := pool.Get().(*tickMath)
+	defer pool.Put(tm)
+
+	tm.sqrtRatioAtTick(tick)
+	dest.Set(tm.ratio)
+	return nil
+}
+
+// sqrtRatioAtTick leaves sqrt(1.0001^tick) * 2^96 in tm.ratio. tick must be in range.
+func (tm *tickMath) sqrtRatioAtTick(tick int64) {
 	absTick := tick
 	if tick < 0 {
 		absTick = -tick
@@ -113,10 +137,6 @@ func GetSqrtRatioAtTick(dest *big.Int, tick int64) error {
 	if tm.rem.Sign() > 0 {
 		tm.ratio.Add(tm.ratio, one)
 	}
-
-	// set destination
-	tm.ratio.IntoBig(&dest)
-	return nil
 }
 
 // GetTickAtSqrtRatio calculates the greatest tick value such that getRatioAtTick(tick) <= ratio.
@@ -158,6 +178,32 @@ func GetTickAtSqrtRatio(sqrtPriceX96 *big.Int) (int64, error) {
 	return tick, nil
 }
 
+// GetTickAtSqrtRatioU256 is GetTickAtSqrtRatio for the uint256 swap path.
+func GetTickAtSqrtRatioU256(sqrtPriceX96 *uint256.Int) (int64, error) {
+	if sqrtPriceX96.Lt(minSqrtRatioU256) || !sqrtPriceX96.Lt(maxSqrtRatioU256) {
+		return 0, ErrSqrtPriceOutOfBounds
+	}
+
+	tm := pool.Get().(*tickMath)
+	defer pool.Put(tm)
+
+	low := MIN_TICK
+	high := MAX_TICK
+	var tick int64
+	for low <= high {
+		mid := (low + high) / 2
+		tm.sqrtRatioAtTick(mid)
+		if !tm.ratio.Gt(sqrtPriceX96) {
+			tick = mid
+			low = mid + 1
+		} else {
+			high = mid - 1
+		}
+	}
+
+	return tick, nil
+}
+
 // Helper to create a big.Int from a hex string.
 func fromHex(s string) *big.Int {
 	n, _ := new(big.Int).SetString(s[2:], 16)
@@ -5,6 +5,7 @@ import (
 	"math/big"
 	"testing"
 
+	"github.com/holiman/uint256"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -134,3 +135,31 @@ func TestInvariants_InverseFunctions(t *testing.T) {
 		assert.Equal(t, tick, tickCalculated, "tick %d -> sqrtP %s -> tick %d", tick, sqrtP.String(), tickCalculated)
 	}
 }
+
+// TestU256_MatchesBigInt checks the uint256 tick functions against the big.Int ones.
+func TestU256_MatchesBigInt(t *testing.T) {
+	tickRange := big.NewInt(int64(MAX_TICK - MIN_TICK + 1))
+	ticks := []int64{MIN_TICK, MIN_TICK + 1, -1, 0, 1, MAX_TICK - 1, MAX_TICK}
+	for i := 0; i < 1000; i++ {
+		randomOffset, _ := rand.Int(rand.Reader, tickRange)
+		ticks = append(ticks, MIN_TICK+randomOffset.Int64())
+	}
+
+	for _, tick := range ticks {
+		want := new(big.Int)
+		require.NoError(t, GetSqrtRatioAtTick(want, tick))
+		got := new(uint256.Int)
+		require.NoError(t, GetSqrtRatioAtTickU256(got, tick))
+		require.Equal(t, want.String(), got.Dec(), "tick %d", tick)
+
+		// Prices between ticks resolve to the lower one on both paths.
+		for _, sqrtP := range []*big.Int{want, new(big.Int).Add(want, big.NewInt(1)), new(big.Int).Sub(want, big.NewInt(1))} {
+			wantTick, wantErr := GetTickAtSqrtRatio(sqrtP)
+			gotTick, gotErr := GetTickAtSqrtRatioU256(uint256.MustFromBig(sqrtP))
+			assert.Equal(t, wantErr, gotErr)
+			assert.Equal(t, wantTick, gotTick, "sqrtP %s", sqrtP)
+		}
+	}
+
+	assert.ErrorIs(t, GetSqrtRatioAtTickU256(new(uint256.Int), MAX_TICK+1), ErrTickOutOfBounds)
+}
@@ -1,6 +1,7 @@
 package uniswapv3
 
 import (
+	"math/big"
 	"sort"
 )
 
@@ -31,7 +32,26 @@ func poolChanged(old, new Pool) bool {
 		return true
 	}
 
-	// 2. Compare ticks (order-insensitive)
+	// 2. Compare optional fee accounting fields
+	if optionalBigIntChanged(old.FeeGrowthGlobal0X128, new.FeeGrowthGlobal0X128) ||
+		optionalBigIntChanged(old.FeeGrowthGlobal1X128, new.FeeGrowthGlobal1X128) ||
+		optionalBigIntChanged(old.ProtocolFees0, new.ProtocolFees0) ||
+		optionalBigIntChanged(old.ProtocolFees1, new.ProtocolFees1) {
+		return true
+	}
+
+	// 3. Compare optional fee settings
+	if optionalUint64Changed(old.FeeOverride, new.FeeOverride) ||
+		optionalUint32Changed(old.FeeProtocol, new.FeeProtocol) ||
+		old.ProtocolFeeDenominator != new.ProtocolFeeDenominator {
+		return true
+	}
+
+	if optionalUint64Changed(old.LastUpdatedBlock, new.LastUpdatedBlock) {
+		return true
+	}
+
+	// 4. Compare ticks (order-insensitive)
 
 	if len(old.Ticks) != len(new.Ticks) {
 		return true
@@ -117,3 +137,27 @@ func Differ(old, new []Pool) UniswapV3SystemDiff {
 		Deletions: deletions,
 	}
 }
+
+// optionalBigIntChanged compares fields that may be absent from the stream.
+func optionalBigIntChanged(old, new *big.Int) bool {
+	if old == nil || new == nil {
+		return old != new
+	}
+	return old.Cmp(new) != 0
+}
+
+// optionalUint64Changed compares fields that may be absent from the stream.
+func optionalUint64Changed(old, new *uint64) bool {
+	if old == nil || new == nil {
+		return old != new
+	}
+	return *old != *new
+}
+
+// optionalUint32Changed compares fields that may be absent from the stream.
+func optionalUint32Changed(old, new *uint32) bool {
+	if old == nil || new == nil {
+		return old != new
+	}
+	return *old != *new
+}
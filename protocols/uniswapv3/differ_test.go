@@ -72,6 +72,35 @@ func TestDiffer(t *testing.T) {
 		assert.Empty(t, diff.Deletions, "Should have no deletions")
 	})
 
+	t.Run("should identify updates when only the last-update block changes", func(t *testing.T) {
+		block100, block101 := uint64(100), uint64(101)
+		pool1Seen := newTestPool(1, 1000, 5000, 100, []TickInfo{tick1})
+		pool1Seen.LastUpdatedBlock = &block100
+		pool1SeenAgain := newTestPool(1, 1000, 5000, 100, []TickInfo{tick1})
+		pool1SeenAgain.LastUpdatedBlock = &block101
+
+		diff := Differ([]Pool{pool1Seen}, []Pool{pool1SeenAgain})
+		require.Len(t, diff.Updates, 1)
+		block, ok := diff.Updates[0].LastUpdated()
+		require.True(t, ok)
+		assert.Equal(t, uint64(101), block)
+
+		diff = Differ([]Pool{pool1Seen}, []Pool{pool1Seen})
+		assert.Empty(t, diff.Updates)
+	})
+
+	t.Run("should identify updates when only the fee override or fee switch changes", func(t *testing.T) {
+		fee, feeProtocol := uint64(2500), uint32(4)
+		pool1Overridden := newTestPool(1, 1000, 5000, 100, []TickInfo{tick1})
+		pool1Overridden.FeeOverride = &fee
+		pool1Switched := newTestPool(1, 1000, 5000, 100, []TickInfo{tick1})
+		pool1Switched.FeeProtocol = &feeProtocol
+
+		assert.Len(t, Differ([]Pool{pool1Old}, []Pool{pool1Overridden}).Updates, 1)
+		assert.Len(t, Differ([]Pool{pool1Old}, []Pool{pool1Switched}).Updates, 1)
+		assert.Empty(t, Differ([]Pool{pool1Switched}, []Pool{pool1Switched}).Updates)
+	})
+
 	t.Run("should identify updates when a nested tick changes", func(t *testing.T) {
 		// The liquidity within the tick has changed, which should trigger the hash difference.
 		tick1Updated := TickInfo{Index: 10, LiquidityNet: big.NewInt(101)}
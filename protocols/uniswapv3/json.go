@@ -0,0 +1,148 @@
+package uniswapv3
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// PackedTicks is the JSON layout of a pool's ticks from schema v2 on: each tick is an
+// [index, liquidityGross, liquidityNet] array rather than an object, which makes
+// tick-heavy payloads about half the size.
+type PackedTicks []TickInfo
+
+func (t PackedTicks) MarshalJSON() ([]byte, error) {
+	if t == nil {
+		return []byte("null"), nil
+	}
+	packed := make([][3]any, len(t))
+	for i, tick := range t {
+		packed[i] = [3]any{tick.Index, tick.LiquidityGross, tick.LiquidityNet}
+	}
+	return json.Marshal(packed)
+}
+
+func (t *PackedTicks) UnmarshalJSON(data []byte) error {
+	var packed [][]json.RawMessage
+	if err := json.Unmarshal(data, &packed); err != nil {
+		return err
+	}
+	if packed == nil {
+		*t = nil
+		return nil
+	}
+	ticks := make(PackedTicks, len(packed))
+	for i, fields := range packed {
+		if len(fields) != 3 {
+			return fmt.Errorf("tick %d: expected [index, liquidityGross, liquidityNet], got %d fields", i, len(fields))
+		}
+		if err := json.Unmarshal(fields[0], &ticks[i].Index); err != nil {
+			return fmt.Errorf("tick %d index: %w", i, err)
+		}
+		if err := json.Unmarshal(fields[1], &ticks[i].LiquidityGross); err != nil {
+			return fmt.Errorf("tick %d liquidityGross: %w", i, err)
+		}
+		if err := json.Unmarshal(fields[2], &ticks[i].LiquidityNet); err != nil {
+			return fmt.Errorf("tick %d liquidityNet: %w", i, err)
+		}
+	}
+	*t = ticks
+	return nil
+}
+
+// poolV2 is a pool in the JSON layout of schema v2, with packed ticks.
+type poolV2 Pool
+
+func (p poolV2) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Pool
+		Ticks PackedTicks `json:"ticks"`
+	}{Pool(p), PackedTicks(p.Ticks)})
+}
+
+func (p *poolV2) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &struct {
+		*Pool
+		Ticks *PackedTicks `json:"ticks"`
+	}{(*Pool)(p), (*PackedTicks)(&p.Ticks)})
+}
+
+// diffV2 is a diff in the JSON layout of schema v2.
+type diffV2 struct {
+	Additions []poolV2 `json:"additions,omitempty"`
+	Updates   []poolV2 `json:"updates,omitempty"`
+	Deletions []uint64 `json:"deletions,omitempty"`
+}
+
+// MarshalPoolsJSON encodes pools in the JSON layout of the given schema version.
+func MarshalPoolsJSON(pools []Pool, version uint64) ([]byte, error) {
+	if version < 2 {
+		return json.Marshal(pools)
+	}
+	return json.Marshal(toV2(pools))
+}
+
+// UnmarshalPoolsJSON decodes pools in the JSON layout of the given schema version.
+func UnmarshalPoolsJSON(data []byte, version uint64) ([]Pool, error) {
+	if version < 2 {
+		var pools []Pool
+		err := json.Unmarshal(data, &pools)
+		return pools, err
+	}
+	var pools []poolV2
+	if err := json.Unmarshal(data, &pools); err != nil {
+		return nil, err
+	}
+	return fromV2(pools), nil
+}
+
+// MarshalDiffJSON encodes diff in the JSON layout of the given schema version.
+func MarshalDiffJSON(diff UniswapV3SystemDiff, version uint64) ([]byte, error) {
+	if version < 2 {
+		return json.Marshal(diff)
+	}
+	return json.Marshal(diffV2{
+		Additions: toV2(diff.Additions),
+		Updates:   toV2(diff.Updates),
+		Deletions: diff.Deletions,
+	})
+}
+
+// UnmarshalDiffJSON decodes a diff in the JSON layout of the given schema version.
+func UnmarshalDiffJSON(data []byte, version uint64) (UniswapV3SystemDiff, error) {
+	if version < 2 {
+		var diff UniswapV3SystemDiff
+		err := json.Unmarshal(data, &diff)
+		return diff, err
+	}
+	var diff diffV2
+	if err := json.Unmarshal(data, &diff); err != nil {
+		return UniswapV3SystemDiff{}, err
+	}
+	return UniswapV3SystemDiff{
+		Additions: fromV2(diff.Additions),
+		Updates:   fromV2(diff.Updates),
+		Deletions: diff.Deletions,
+	}, nil
+}
+
+func toV2(pools []Pool) []poolV2 {
+	if pools == nil {
+		return nil
+	}
+	out := make([]poolV2, len(pools))
+	for i, pool := range pools {
+		out[i] = poolV2(pool)
+	}
+	return out
+}
+
+func fromV2(pools []poolV2) []Pool {
+	if pools == nil {
+		return nil
+	}
+	out := make([]Pool, len(pools))
+	for i, pool := range pools {
+		out[i] = Pool(pool)
+	}
+	return out
+}
@@ -0,0 +1,50 @@
+package uniswapv3
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolsJSON_RoundTrip(t *testing.T) {
+	fee := uint64(100)
+	pools := []Pool{
+		{
+			PoolViewMinimal: PoolViewMinimal{ID: 1, Token0: 10, Token1: 11, Fee: 500, TickSpacing: 10, Tick: -7, Liquidity: big.NewInt(5000), SqrtPriceX96: big.NewInt(1 << 40)},
+			Ticks: []TickInfo{
+				{Index: -10, LiquidityGross: big.NewInt(5000), LiquidityNet: big.NewInt(5000)},
+				{Index: 10, LiquidityGross: big.NewInt(5000), LiquidityNet: big.NewInt(-5000)},
+			},
+			FeeOverride: &fee,
+		},
+		{PoolViewMinimal: PoolViewMinimal{ID: 2}, Ticks: []TickInfo{}},
+	}
+	diff := UniswapV3SystemDiff{Additions: pools[1:], Updates: pools[:1], Deletions: []uint64{3}}
+
+	for _, version := range []uint64{1, 2} {
+		data, err := MarshalPoolsJSON(pools, version)
+		require.NoError(t, err)
+		decoded, err := UnmarshalPoolsJSON(data, version)
+		require.NoError(t, err)
+		assert.Equal(t, pools, decoded, "v%d", version)
+
+		data, err = MarshalDiffJSON(diff, version)
+		require.NoError(t, err)
+		decodedDiff, err := UnmarshalDiffJSON(data, version)
+		require.NoError(t, err)
+		assert.Equal(t, diff, decodedDiff, "v%d", version)
+	}
+
+	data, err := MarshalPoolsJSON(pools[:1], 2)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"ticks":[[-10,5000,5000],[10,5000,-5000]]`)
+}
+
+func TestPackedTicks_Invalid(t *testing.T) {
+	_, err := UnmarshalPoolsJSON([]byte(`[{"id":1,"ticks":[[-10,5000]]}]`), 2)
+	assert.ErrorContains(t, err, "expected [index, liquidityGross, liquidityNet]")
+	_, err = UnmarshalPoolsJSON([]byte(`[{"id":1,"ticks":[{"index":-10}]}]`), 2)
+	assert.Error(t, err, "v1 ticks in a v2 payload")
+}
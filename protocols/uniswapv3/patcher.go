@@ -17,6 +17,41 @@ func copyTickInfo(t TickInfo) TickInfo {
 	return newTick
 }
 
+// copyOptionalBigInt copies a *big.Int that is allowed to be nil.
+func copyOptionalBigInt(b *big.Int) *big.Int {
+	if b == nil {
+		return nil
+	}
+	return new(big.Int).Set(b)
+}
+
+// copyOptionalUint8 copies a *uint8 that is allowed to be nil.
+func copyOptionalUint8(v *uint8) *uint8 {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
+
+// copyOptionalUint32 copies a *uint32 that is allowed to be nil.
+func copyOptionalUint32(v *uint32) *uint32 {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
+
+// copyOptionalUint64 copies a *uint64 that is allowed to be nil.
+func copyOptionalUint64(v *uint64) *uint64 {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
+
 // deepCopyPool creates a new Pool with its own memory for all pointer types,
 // including the nested Ticks slice. This is essential for memory safety.
 func deepCopyPool(p Pool) Pool {
@@ -25,6 +60,17 @@ func deepCopyPool(p Pool) Pool {
 	newPool.Liquidity = new(big.Int).Set(p.Liquidity)
 	newPool.SqrtPriceX96 = new(big.Int).Set(p.SqrtPriceX96)
 
+	// Fee accounting fields are optional and may be nil.
+	newPool.FeeGrowthGlobal0X128 = copyOptionalBigInt(p.FeeGrowthGlobal0X128)
+	newPool.FeeGrowthGlobal1X128 = copyOptionalBigInt(p.FeeGrowthGlobal1X128)
+	newPool.ProtocolFees0 = copyOptionalBigInt(p.ProtocolFees0)
+	newPool.ProtocolFees1 = copyOptionalBigInt(p.ProtocolFees1)
+	newPool.FeeOverride = copyOptionalUint64(p.FeeOverride)
+	newPool.FeeProtocol = copyOptionalUint32(p.FeeProtocol)
+	newPool.Decimals0 = copyOptionalUint8(p.Decimals0)
+	newPool.Decimals1 = copyOptionalUint8(p.Decimals1)
+	newPool.LastUpdatedBlock = copyOptionalUint64(p.LastUpdatedBlock)
+
 	// Deep copy the Ticks slice by creating a new slice and copying each element.
 	if p.Ticks != nil {
 		newTicks := make([]TickInfo, len(p.Ticks))
@@ -2,6 +2,8 @@ package uniswapv3
 
 import (
 	"math/big"
+
+	"github.com/defistate/defistate-client-go/addr"
 )
 
 // @note do not change the PoolViewMinimal struct name until it is confirmed from the uniswap v3 indexer
@@ -38,4 +40,118 @@ type TickInfo struct {
 type Pool struct {
 	PoolViewMinimal `json:",inline"`
 	Ticks           []TickInfo `json:"ticks"`
+
+	// Fee accounting fields. They are optional: streams that do not carry them leave
+	// them nil. Quoting never reads them; detailed swap simulation accrues fees to them
+	// when they are set. Use FeeGrowthGlobals and ProtocolFees to access them.
+	FeeGrowthGlobal0X128 *big.Int `json:"feeGrowthGlobal0X128,omitempty"`
+	FeeGrowthGlobal1X128 *big.Int `json:"feeGrowthGlobal1X128,omitempty"`
+	ProtocolFees0        *big.Int `json:"protocolFees0,omitempty"`
+	ProtocolFees1        *big.Int `json:"protocolFees1,omitempty"`
+
+	// FeeOverride is the fee the pool charges, in hundredths of a bip, when it differs
+	// from its fee tier, e.g. on forks that adjust fees dynamically. It is optional; use
+	// SwapFee to read the fee a swap pays.
+	FeeOverride *uint64 `json:"feeOverride,omitempty"`
+	// FeeProtocol is the pool's slot0.feeProtocol, the protocol's cut of the swap fee.
+	// It is optional; nil or zero means the fee switch is off. ProtocolFeeDenominator
+	// selects its encoding; use ProtocolFeeAmount to apply it.
+	FeeProtocol *uint32 `json:"feeProtocol,omitempty"`
+	// ProtocolFeeDenominator is zero for Uniswap V3's encoding of FeeProtocol: 4 bits per
+	// token, the protocol taking 1/N of the fee. Otherwise, as on PancakeSwap V3, it is
+	// 16 bits per token, the protocol taking N/ProtocolFeeDenominator of the fee.
+	ProtocolFeeDenominator uint32 `json:"protocolFeeDenominator,omitempty"`
+
+	// Decimals0 and Decimals1 are the token decimals the indexer assumed for this pool.
+	// They are optional; use AssumedDecimals to access them.
+	Decimals0 *uint8 `json:"decimals0,omitempty"`
+	Decimals1 *uint8 `json:"decimals1,omitempty"`
+
+	// LastUpdatedBlock is the block in which the pool's state last changed. It is
+	// optional; use LastUpdated to access it.
+	LastUpdatedBlock *uint64 `json:"lastUpdatedBlock,omitempty"`
+}
+
+// AssumedDecimals returns the token decimals the indexer assumed for token0 and token1.
+// ok is false if the stream did not provide them.
+func (p Pool) AssumedDecimals() (decimals0, decimals1 uint8, ok bool) {
+	if p.Decimals0 == nil || p.Decimals1 == nil {
+		return 0, 0, false
+	}
+	return *p.Decimals0, *p.Decimals1, true
+}
+
+// LastUpdated returns the block in which the pool's state last changed.
+// ok is false if the stream did not provide it.
+func (p Pool) LastUpdated() (block uint64, ok bool) {
+	if p.LastUpdatedBlock == nil {
+		return 0, false
+	}
+	return *p.LastUpdatedBlock, true
+}
+
+// FeeGrowthGlobals returns the pool's global fee growth per unit of liquidity for
+// token0 and token1, as Q128.128 values. ok is false if the stream did not provide them.
+func (p Pool) FeeGrowthGlobals() (feeGrowth0X128, feeGrowth1X128 *big.Int, ok bool) {
+	if p.FeeGrowthGlobal0X128 == nil || p.FeeGrowthGlobal1X128 == nil {
+		return nil, nil, false
+	}
+	return p.FeeGrowthGlobal0X128, p.FeeGrowthGlobal1X128, true
+}
+
+// ProtocolFees returns the uncollected protocol fees in token0 and token1.
+// ok is false if the stream did not provide them.
+func (p Pool) ProtocolFees() (token0, token1 *big.Int, ok bool) {
+	if p.ProtocolFees0 == nil || p.ProtocolFees1 == nil {
+		return nil, nil, false
+	}
+	return p.ProtocolFees0, p.ProtocolFees1, true
+}
+
+// SwapFee returns the fee a swap pays, in hundredths of a bip: FeeOverride if it is set,
+// or the pool's fee tier.
+func (p Pool) SwapFee() uint64 {
+	if p.FeeOverride != nil {
+		return *p.FeeOverride
+	}
+	return p.Fee
+}
+
+// ProtocolFeeAmount returns the protocol's cut of feeAmount, a swap fee paid in token0 if
+// zeroForOne is true and in token1 otherwise, rounded down as the pool does. It is zero
+// when the fee switch is off.
+func (p Pool) ProtocolFeeAmount(feeAmount *big.Int, zeroForOne bool) *big.Int {
+	if p.FeeProtocol == nil || *p.FeeProtocol == 0 {
+		return new(big.Int)
+	}
+	feeProtocol := *p.FeeProtocol
+	if p.ProtocolFeeDenominator == 0 {
+		share := feeProtocol % 16
+		if !zeroForOne {
+			share = (feeProtocol >> 4) % 16
+		}
+		if share == 0 {
+			return new(big.Int)
+		}
+		return new(big.Int).Div(feeAmount, big.NewInt(int64(share)))
+	}
+	share := feeProtocol % (1 << 16)
+	if !zeroForOne {
+		share = feeProtocol >> 16
+	}
+	amount := new(big.Int).Mul(feeAmount, big.NewInt(int64(share)))
+	return amount.Div(amount, big.NewInt(int64(p.ProtocolFeeDenominator)))
+}
+
+// IsSorted reports whether token0's address is numerically less than token1's, the
+// Uniswap convention that fixes which way the pool's price is quoted. Pools reference
+// tokens by ID, so addresses are resolved with tokenAddress; ok is false if either
+// token cannot be resolved.
+func (p Pool) IsSorted(tokenAddress func(tokenID uint64) (addr.Address, bool)) (sorted, ok bool) {
+	address0, ok0 := tokenAddress(p.Token0)
+	address1, ok1 := tokenAddress(p.Token1)
+	if !ok0 || !ok1 {
+		return false, false
+	}
+	return address0.Less(address1), true
 }
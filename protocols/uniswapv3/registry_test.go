@@ -0,0 +1,164 @@
+package uniswapv3
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/defistate/defistate-client-go/addr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolDecode_FeeAccountingFields(t *testing.T) {
+	t.Run("decodes fee growth and protocol fees when present", func(t *testing.T) {
+		payload := `{
+			"id": 7,
+			"token0": 1,
+			"token1": 2,
+			"fee": 500,
+			"tickSpacing": 10,
+			"tick": -201234,
+			"liquidity": 1517882343751509868544,
+			"sqrtPriceX96": 3182926985656425374149435,
+			"ticks": [{"index": -201240, "liquidityGross": 10, "liquidityNet": 10}],
+			"feeGrowthGlobal0X128": 3402823669209384634633746074317682114560,
+			"feeGrowthGlobal1X128": 1701411834604692317316873037158841057280,
+			"protocolFees0": 12345,
+			"protocolFees1": 67890
+		}`
+
+		var pool Pool
+		require.NoError(t, json.Unmarshal([]byte(payload), &pool))
+
+		assert.Equal(t, uint64(7), pool.ID)
+		require.Len(t, pool.Ticks, 1)
+
+		feeGrowth0, feeGrowth1, ok := pool.FeeGrowthGlobals()
+		require.True(t, ok)
+		assert.Equal(t, "3402823669209384634633746074317682114560", feeGrowth0.String())
+		assert.Equal(t, "1701411834604692317316873037158841057280", feeGrowth1.String())
+
+		fees0, fees1, ok := pool.ProtocolFees()
+		require.True(t, ok)
+		assert.Equal(t, big.NewInt(12345), fees0)
+		assert.Equal(t, big.NewInt(67890), fees1)
+
+		// Round-trip keeps the fields.
+		encoded, err := json.Marshal(pool)
+		require.NoError(t, err)
+		var decoded Pool
+		require.NoError(t, json.Unmarshal(encoded, &decoded))
+		assert.Equal(t, pool, decoded)
+	})
+
+	t.Run("fields are optional", func(t *testing.T) {
+		payload := `{"id": 7, "token0": 1, "token1": 2, "fee": 500, "tickSpacing": 10, "tick": 0,
+			"liquidity": 100, "sqrtPriceX96": 79228162514264337593543950336, "ticks": []}`
+
+		var pool Pool
+		require.NoError(t, json.Unmarshal([]byte(payload), &pool))
+
+		_, _, ok := pool.FeeGrowthGlobals()
+		assert.False(t, ok)
+		_, _, ok = pool.ProtocolFees()
+		assert.False(t, ok)
+
+		encoded, err := json.Marshal(pool)
+		require.NoError(t, err)
+		assert.NotContains(t, string(encoded), "feeGrowthGlobal0X128")
+		assert.NotContains(t, string(encoded), "protocolFees0")
+	})
+
+	t.Run("differ and patcher carry the fields", func(t *testing.T) {
+		old := newTestPool(1, 1000, 5000, 100, nil)
+		updated := newTestPool(1, 1000, 5000, 100, nil)
+		updated.FeeGrowthGlobal0X128 = big.NewInt(1)
+		updated.FeeGrowthGlobal1X128 = big.NewInt(2)
+
+		diff := Differ([]Pool{old}, []Pool{updated})
+		require.Len(t, diff.Updates, 1, "a fee growth change should produce an update")
+
+		patched, err := Patcher([]Pool{old}, diff)
+		require.NoError(t, err)
+		require.Len(t, patched, 1)
+
+		feeGrowth0, _, ok := patched[0].FeeGrowthGlobals()
+		require.True(t, ok)
+		assert.Equal(t, big.NewInt(1), feeGrowth0)
+
+		// The patched state must not alias the diff.
+		updated.FeeGrowthGlobal0X128.SetInt64(99)
+		assert.Equal(t, big.NewInt(1), patched[0].FeeGrowthGlobal0X128)
+	})
+}
+
+func TestPoolIsSorted(t *testing.T) {
+	// WETH sorts before USDT, USDC sorts before WETH.
+	addresses := map[uint64]addr.Address{
+		1: addr.Address{0xC0, 0x2a}, // WETH
+		2: addr.Address{0xda, 0xC1}, // USDT
+		3: addr.Address{0xA0, 0xb8}, // USDC
+	}
+	tokenAddress := func(id uint64) (addr.Address, bool) {
+		a, ok := addresses[id]
+		return a, ok
+	}
+	pool := func(token0, token1 uint64) Pool {
+		return Pool{PoolViewMinimal: PoolViewMinimal{ID: 7, Token0: token0, Token1: token1}}
+	}
+
+	t.Run("token0 below token1", func(t *testing.T) {
+		sorted, ok := pool(1, 2).IsSorted(tokenAddress)
+		assert.True(t, ok)
+		assert.True(t, sorted)
+	})
+
+	t.Run("token0 above token1", func(t *testing.T) {
+		sorted, ok := pool(1, 3).IsSorted(tokenAddress)
+		assert.True(t, ok)
+		assert.False(t, sorted)
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		_, ok := pool(99, 1).IsSorted(tokenAddress)
+		assert.False(t, ok)
+	})
+}
+
+func TestPoolFees(t *testing.T) {
+	u64 := func(v uint64) *uint64 { return &v }
+	u32 := func(v uint32) *uint32 { return &v }
+	pool := Pool{PoolViewMinimal: PoolViewMinimal{ID: 7, Fee: 3000}}
+
+	t.Run("swap fee", func(t *testing.T) {
+		assert.Equal(t, uint64(3000), pool.SwapFee())
+		overridden := pool
+		overridden.FeeOverride = u64(2500)
+		assert.Equal(t, uint64(2500), overridden.SwapFee())
+	})
+
+	t.Run("fee switch off", func(t *testing.T) {
+		assert.Equal(t, "0", pool.ProtocolFeeAmount(big.NewInt(1000), true).String())
+		off := pool
+		off.FeeProtocol = u32(0)
+		assert.Equal(t, "0", off.ProtocolFeeAmount(big.NewInt(1000), true).String())
+	})
+
+	t.Run("uniswap encoding", func(t *testing.T) {
+		// 1/4 of token0 fees, 1/6 of token1 fees.
+		uniswap := pool
+		uniswap.FeeProtocol = u32(6<<4 | 4)
+		assert.Equal(t, "250", uniswap.ProtocolFeeAmount(big.NewInt(1000), true).String())
+		assert.Equal(t, "166", uniswap.ProtocolFeeAmount(big.NewInt(1000), false).String())
+	})
+
+	t.Run("pancakeswap encoding", func(t *testing.T) {
+		// 32% of token0 fees, 0% of token1 fees.
+		pancake := pool
+		pancake.FeeProtocol = u32(3200)
+		pancake.ProtocolFeeDenominator = 10000
+		assert.Equal(t, "320", pancake.ProtocolFeeAmount(big.NewInt(1000), true).String())
+		assert.Equal(t, "0", pancake.ProtocolFeeAmount(big.NewInt(1000), false).String())
+	})
+}
@@ -0,0 +1,88 @@
+package uniswapv4
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	uniswapv3 "github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	uniswapv3calculator "github.com/defistate/defistate-client-go/protocols/uniswapv3/calculator"
+	uniswapv4 "github.com/defistate/defistate-client-go/protocols/uniswapv4"
+)
+
+// ErrUnknownFee is returned for pools whose swap fee cannot be known from the stream:
+// dynamic-fee pools without a reported LP fee or static hook override.
+var ErrUnknownFee = errors.New("swap fee is not statically known")
+
+// AsV3Pool returns pool in Uniswap V3 form, charging the fee a swap pays (see
+// uniswapv4.Pool.SwapFee). V4 swaps through the same concentrated liquidity math as V3,
+// so the V3 calculator quotes the result exactly, except for hooks that change swap
+// amounts or fees per swap, which cannot be known statically.
+func AsV3Pool(pool uniswapv4.Pool) (uniswapv3.Pool, error) {
+	fee, ok := pool.SwapFee()
+	if !ok {
+		return uniswapv3.Pool{}, fmt.Errorf("%w: pool %d", ErrUnknownFee, pool.ID)
+	}
+	return uniswapv3.Pool{
+		PoolViewMinimal: uniswapv3.PoolViewMinimal{
+			ID:           pool.ID,
+			Token0:       pool.Token0,
+			Token1:       pool.Token1,
+			Fee:          fee,
+			TickSpacing:  pool.TickSpacing,
+			Tick:         pool.Tick,
+			Liquidity:    pool.Liquidity,
+			SqrtPriceX96: pool.SqrtPriceX96,
+		},
+		Ticks:            pool.Ticks,
+		LastUpdatedBlock: pool.LastUpdatedBlock,
+	}, nil
+}
+
+// GetAmountOut calculates the amount out for a given exact amount in.
+func GetAmountOut(amountIn, sqrtPriceLimitX96 *big.Int, tokenInID uint64, pool uniswapv4.Pool) (*big.Int, error) {
+	v3Pool, err := AsV3Pool(pool)
+	if err != nil {
+		return nil, err
+	}
+	return uniswapv3calculator.GetAmountOut(amountIn, sqrtPriceLimitX96, tokenInID, v3Pool)
+}
+
+// GetAmountIn calculates the required amount in for a given exact amount out.
+// Like the V3 calculator, it expects a negative amountOut.
+func GetAmountIn(amountOut, sqrtPriceLimitX96 *big.Int, tokenInID uint64, pool uniswapv4.Pool) (*big.Int, error) {
+	v3Pool, err := AsV3Pool(pool)
+	if err != nil {
+		return nil, err
+	}
+	return uniswapv3calculator.GetAmountIn(amountOut, sqrtPriceLimitX96, tokenInID, v3Pool)
+}
+
+// GetVirtualReserves returns the pool's virtual reserves ordered by swap direction.
+// They do not depend on the fee, so pools with an unknown fee have them too.
+func GetVirtualReserves(tokenInID, tokenOutID uint64, pool uniswapv4.Pool) (reserveIn, reserveOut *big.Int, err error) {
+	return uniswapv3calculator.GetVirtualReserves(tokenInID, tokenOutID, uniswapv3.Pool{
+		PoolViewMinimal: uniswapv3.PoolViewMinimal{
+			ID:           pool.ID,
+			Token0:       pool.Token0,
+			Token1:       pool.Token1,
+			Liquidity:    pool.Liquidity,
+			SqrtPriceX96: pool.SqrtPriceX96,
+		},
+	})
+}
+
+// V3Pools returns the pools whose swap fee is known in Uniswap V3 form (see AsV3Pool),
+// ready to be merged into a V3 index, and the IDs of the pools left out.
+func V3Pools(pools []uniswapv4.Pool) (v3Pools []uniswapv3.Pool, unknownFee []uint64) {
+	v3Pools = make([]uniswapv3.Pool, 0, len(pools))
+	for _, pool := range pools {
+		v3Pool, err := AsV3Pool(pool)
+		if err != nil {
+			unknownFee = append(unknownFee, pool.ID)
+			continue
+		}
+		v3Pools = append(v3Pools, v3Pool)
+	}
+	return v3Pools, unknownFee
+}
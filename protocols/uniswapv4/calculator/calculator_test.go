@@ -0,0 +1,122 @@
+package uniswapv4
+
+import (
+	"math/big"
+	"testing"
+
+	uniswapv3 "github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	uniswapv3calculator "github.com/defistate/defistate-client-go/protocols/uniswapv3/calculator"
+	uniswapv4 "github.com/defistate/defistate-client-go/protocols/uniswapv4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestPool returns a pool at price 1 with one position over ticks [-600, 600].
+func newTestPool(fee uint64) uniswapv4.Pool {
+	liquidity := new(big.Int).Exp(big.NewInt(10), big.NewInt(21), nil)
+	return uniswapv4.Pool{
+		ID:           7,
+		Token0:       1,
+		Token1:       2,
+		Fee:          fee,
+		TickSpacing:  60,
+		Tick:         0,
+		Liquidity:    liquidity,
+		SqrtPriceX96: new(big.Int).Set(uniswapv3calculator.Q96),
+		Ticks: []uniswapv4.TickInfo{
+			{Index: -600, LiquidityGross: liquidity, LiquidityNet: liquidity},
+			{Index: 600, LiquidityGross: liquidity, LiquidityNet: new(big.Int).Neg(liquidity)},
+		},
+	}
+}
+
+func ptr(v uint64) *uint64 { return &v }
+
+func TestGetAmountOut(t *testing.T) {
+	amountIn := big.NewInt(1_000_000_000_000_000)
+
+	t.Run("static fee matches the V3 quote", func(t *testing.T) {
+		pool := newTestPool(3000)
+		v3Pool := uniswapv3.Pool{
+			PoolViewMinimal: uniswapv3.PoolViewMinimal{
+				ID: pool.ID, Token0: pool.Token0, Token1: pool.Token1, Fee: 3000, TickSpacing: 60,
+				Tick: pool.Tick, Liquidity: pool.Liquidity, SqrtPriceX96: pool.SqrtPriceX96,
+			},
+			Ticks: pool.Ticks,
+		}
+
+		got, err := GetAmountOut(amountIn, nil, 1, pool)
+		require.NoError(t, err)
+		want, err := uniswapv3calculator.GetAmountOut(amountIn, nil, 1, v3Pool)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("dynamic fee without a known fee is rejected", func(t *testing.T) {
+		_, err := GetAmountOut(amountIn, nil, 1, newTestPool(uniswapv4.DynamicFeeFlag))
+		assert.ErrorIs(t, err, ErrUnknownFee)
+	})
+
+	t.Run("dynamic fee uses the reported LP fee", func(t *testing.T) {
+		dynamic := newTestPool(uniswapv4.DynamicFeeFlag)
+		dynamic.LPFee = ptr(500)
+
+		got, err := GetAmountOut(amountIn, nil, 1, dynamic)
+		require.NoError(t, err)
+		want, err := GetAmountOut(amountIn, nil, 1, newTestPool(500))
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("hook override wins over the LP fee", func(t *testing.T) {
+		overridden := newTestPool(uniswapv4.DynamicFeeFlag)
+		overridden.LPFee = ptr(500)
+		overridden.FeeOverride = ptr(10000)
+
+		got, err := GetAmountOut(amountIn, nil, 1, overridden)
+		require.NoError(t, err)
+		want, err := GetAmountOut(amountIn, nil, 1, newTestPool(10000))
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("wrong token", func(t *testing.T) {
+		_, err := GetAmountOut(amountIn, nil, 3, newTestPool(3000))
+		assert.ErrorIs(t, err, uniswapv3calculator.ErrTokenMismatch)
+	})
+}
+
+func TestGetAmountIn(t *testing.T) {
+	pool := newTestPool(3000)
+	amountIn := big.NewInt(1_000_000_000_000_000)
+
+	amountOut, err := GetAmountOut(amountIn, nil, 2, pool)
+	require.NoError(t, err)
+
+	required, err := GetAmountIn(new(big.Int).Neg(amountOut), nil, 2, pool)
+	require.NoError(t, err)
+	// Rounding up on the way back may cost at most a unit.
+	diff := new(big.Int).Sub(required, amountIn)
+	assert.True(t, diff.Sign() >= 0 && diff.Cmp(big.NewInt(1)) <= 0, "required %s for %s in", required, amountIn)
+}
+
+func TestGetVirtualReserves(t *testing.T) {
+	// Reserves do not depend on the fee.
+	pool := newTestPool(uniswapv4.DynamicFeeFlag)
+	reserveIn, reserveOut, err := GetVirtualReserves(1, 2, pool)
+	require.NoError(t, err)
+	assert.Equal(t, pool.Liquidity, reserveIn)
+	assert.Equal(t, pool.Liquidity, reserveOut)
+}
+
+func TestV3Pools(t *testing.T) {
+	static := newTestPool(3000)
+	unknown := newTestPool(uniswapv4.DynamicFeeFlag)
+	unknown.ID = 8
+
+	v3Pools, unknownFee := V3Pools([]uniswapv4.Pool{static, unknown})
+	require.Len(t, v3Pools, 1)
+	assert.Equal(t, static.ID, v3Pools[0].ID)
+	assert.Equal(t, uint64(3000), v3Pools[0].Fee)
+	assert.Equal(t, []uint64{8}, unknownFee)
+}
@@ -0,0 +1,122 @@
+package uniswapv4
+
+import (
+	"math/big"
+	"sort"
+)
+
+type UniswapV4SystemDiff struct {
+	Additions []Pool   `json:"additions,omitempty"`
+	Updates   []Pool   `json:"updates,omitempty"`
+	Deletions []uint64 `json:"deletions,omitempty"`
+}
+
+// IsEmpty returns true if the diff contains no changes.
+func (d UniswapV4SystemDiff) IsEmpty() bool {
+	return len(d.Additions) == 0 && len(d.Updates) == 0 && len(d.Deletions) == 0
+}
+
+// poolChanged compares the fields that can change after a pool is initialized. The pool
+// key fields (tokens, fee, tick spacing, hooks) are fixed for a given ID.
+func poolChanged(old, new Pool) bool {
+	// 1. Compare core dynamic fields
+	if old.Tick != new.Tick {
+		return true
+	}
+	if old.SqrtPriceX96.Cmp(new.SqrtPriceX96) != 0 {
+		return true
+	}
+	if old.Liquidity.Cmp(new.Liquidity) != 0 {
+		return true
+	}
+
+	// 2. Compare optional fee and bookkeeping fields
+	if optionalUint64Changed(old.LPFee, new.LPFee) ||
+		optionalUint64Changed(old.FeeOverride, new.FeeOverride) ||
+		optionalUint64Changed(old.LastUpdatedBlock, new.LastUpdatedBlock) {
+		return true
+	}
+
+	// 3. Compare ticks (order-insensitive)
+	if len(old.Ticks) != len(new.Ticks) {
+		return true
+	}
+
+	oldTicks := sortedTicks(old.Ticks)
+	newTicks := sortedTicks(new.Ticks)
+	for i := range oldTicks {
+		if oldTicks[i].Index != newTicks[i].Index {
+			return true
+		}
+		if optionalBigIntChanged(oldTicks[i].LiquidityNet, newTicks[i].LiquidityNet) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sortedTicks returns a copy of ticks sorted by index.
+func sortedTicks(ticks []TickInfo) []TickInfo {
+	sorted := make([]TickInfo, len(ticks))
+	copy(sorted, ticks)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Index < sorted[j].Index
+	})
+	return sorted
+}
+
+// optionalBigIntChanged compares fields that may be absent from the stream.
+func optionalBigIntChanged(old, new *big.Int) bool {
+	if old == nil || new == nil {
+		return old != new
+	}
+	return old.Cmp(new) != 0
+}
+
+// optionalUint64Changed compares fields that may be absent from the stream.
+func optionalUint64Changed(old, new *uint64) bool {
+	if old == nil || new == nil {
+		return old != new
+	}
+	return *old != *new
+}
+
+// Differ calculates the difference between two states of Uniswap V4 pools, matching
+// pools by ID.
+func Differ(old, new []Pool) UniswapV4SystemDiff {
+	oldPoolsMap := make(map[uint64]Pool, len(old))
+	for _, pool := range old {
+		oldPoolsMap[pool.ID] = pool
+	}
+
+	newPoolsMap := make(map[uint64]Pool, len(new))
+	for _, pool := range new {
+		newPoolsMap[pool.ID] = pool
+	}
+
+	var additions []Pool
+	var updates []Pool
+	var deletions []uint64
+
+	for newID, newPool := range newPoolsMap {
+		oldPool, exists := oldPoolsMap[newID]
+		if !exists {
+			additions = append(additions, newPool)
+		} else if poolChanged(oldPool, newPool) {
+			updates = append(updates, newPool)
+		}
+	}
+
+	for oldID := range oldPoolsMap {
+		if _, exists := newPoolsMap[oldID]; !exists {
+			deletions = append(deletions, oldID)
+		}
+	}
+
+	return UniswapV4SystemDiff{
+		Additions: additions,
+		Updates:   updates,
+		Deletions: deletions,
+	}
+}
@@ -0,0 +1,65 @@
+package uniswapv4
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestPool returns a pool with the fields the differ and patcher look at.
+func newTestPool(id uint64, liquidity, sqrtPrice, tick int64, ticks []TickInfo) Pool {
+	return Pool{
+		ID:           id,
+		Liquidity:    big.NewInt(liquidity),
+		SqrtPriceX96: big.NewInt(sqrtPrice),
+		Tick:         tick,
+		Ticks:        ticks,
+	}
+}
+
+func TestDiffer(t *testing.T) {
+	tick1 := TickInfo{Index: 10, LiquidityNet: big.NewInt(100)}
+	tick2 := TickInfo{Index: 20, LiquidityNet: big.NewInt(200)}
+
+	pool1 := newTestPool(1, 1000, 5000, 100, []TickInfo{tick1, tick2})
+	pool2 := newTestPool(2, 2000, 6000, 200, nil)
+
+	t.Run("additions and deletions", func(t *testing.T) {
+		diff := Differ([]Pool{pool1}, []Pool{pool2})
+		require.Len(t, diff.Additions, 1)
+		assert.Equal(t, uint64(2), diff.Additions[0].ID)
+		assert.Equal(t, []uint64{1}, diff.Deletions)
+		assert.Empty(t, diff.Updates)
+	})
+
+	t.Run("no changes", func(t *testing.T) {
+		reordered := pool1
+		reordered.Ticks = []TickInfo{tick2, tick1}
+		assert.True(t, Differ([]Pool{pool1, pool2}, []Pool{reordered, pool2}).IsEmpty())
+	})
+
+	for _, tc := range []struct {
+		name   string
+		change func(p *Pool)
+	}{
+		{name: "price", change: func(p *Pool) { p.SqrtPriceX96 = big.NewInt(5001) }},
+		{name: "tick", change: func(p *Pool) { p.Tick = 101 }},
+		{name: "liquidity", change: func(p *Pool) { p.Liquidity = big.NewInt(999) }},
+		{name: "tick liquidity", change: func(p *Pool) {
+			p.Ticks = []TickInfo{tick1, {Index: 20, LiquidityNet: big.NewInt(201)}}
+		}},
+		{name: "dynamic LP fee", change: func(p *Pool) { p.LPFee = uint64Ptr(500) }},
+		{name: "hook fee override", change: func(p *Pool) { p.FeeOverride = uint64Ptr(100) }},
+	} {
+		t.Run("update on "+tc.name, func(t *testing.T) {
+			updated := pool1
+			tc.change(&updated)
+			diff := Differ([]Pool{pool1}, []Pool{updated})
+			require.Len(t, diff.Updates, 1)
+			assert.Empty(t, diff.Additions)
+			assert.Empty(t, diff.Deletions)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package uniswapv4
+
+import (
+	"encoding/json"
+
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3"
+)
+
+// poolV2 is a pool in the JSON layout of schema v2, with its ticks packed as in
+// uniswapv3.PackedTicks.
+type poolV2 Pool
+
+func (p poolV2) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Pool
+		Ticks uniswapv3.PackedTicks `json:"ticks"`
+	}{Pool(p), uniswapv3.PackedTicks(p.Ticks)})
+}
+
+func (p *poolV2) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &struct {
+		*Pool
+		Ticks *uniswapv3.PackedTicks `json:"ticks"`
+	}{(*Pool)(p), (*uniswapv3.PackedTicks)(&p.Ticks)})
+}
+
+// diffV2 is a diff in the JSON layout of schema v2.
+type diffV2 struct {
+	Additions []poolV2 `json:"additions,omitempty"`
+	Updates   []poolV2 `json:"updates,omitempty"`
+	Deletions []uint64 `json:"deletions,omitempty"`
+}
+
+// MarshalPoolsJSON encodes pools in the JSON layout of the given schema version.
+func MarshalPoolsJSON(pools []Pool, version uint64) ([]byte, error) {
+	if version < 2 {
+		return json.Marshal(pools)
+	}
+	return json.Marshal(toV2(pools))
+}
+
+// UnmarshalPoolsJSON decodes pools in the JSON layout of the given schema version.
+func UnmarshalPoolsJSON(data []byte, version uint64) ([]Pool, error) {
+	if version < 2 {
+		var pools []Pool
+		err := json.Unmarshal(data, &pools)
+		return pools, err
+	}
+	var pools []poolV2
+	if err := json.Unmarshal(data, &pools); err != nil {
+		return nil, err
+	}
+	return fromV2(pools), nil
+}
+
+// MarshalDiffJSON encodes diff in the JSON layout of the given schema version.
+func MarshalDiffJSON(diff UniswapV4SystemDiff, version uint64) ([]byte, error) {
+	if version < 2 {
+		return json.Marshal(diff)
+	}
+	return json.Marshal(diffV2{
+		Additions: toV2(diff.Additions),
+		Updates:   toV2(diff.Updates),
+		Deletions: diff.Deletions,
+	})
+}
+
+// UnmarshalDiffJSON decodes a diff in the JSON layout of the given schema version.
+func UnmarshalDiffJSON(data []byte, version uint64) (UniswapV4SystemDiff, error) {
+	if version < 2 {
+		var diff UniswapV4SystemDiff
+		err := json.Unmarshal(data, &diff)
+		return diff, err
+	}
+	var diff diffV2
+	if err := json.Unmarshal(data, &diff); err != nil {
+		return UniswapV4SystemDiff{}, err
+	}
+	return UniswapV4SystemDiff{
+		Additions: fromV2(diff.Additions),
+		Updates:   fromV2(diff.Updates),
+		Deletions: diff.Deletions,
+	}, nil
+}
+
+func toV2(pools []Pool) []poolV2 {
+	if pools == nil {
+		return nil
+	}
+	out := make([]poolV2, len(pools))
+	for i, pool := range pools {
+		out[i] = poolV2(pool)
+	}
+	return out
+}
+
+func fromV2(pools []poolV2) []Pool {
+	if pools == nil {
+		return nil
+	}
+	out := make([]Pool, len(pools))
+	for i, pool := range pools {
+		out[i] = Pool(pool)
+	}
+	return out
+}
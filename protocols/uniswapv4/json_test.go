@@ -0,0 +1,34 @@
+package uniswapv4
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolsJSON_RoundTrip(t *testing.T) {
+	lpFee := uint64(3000)
+	pools := []Pool{{
+		ID: 1, PoolID: [32]byte{1}, Token0: 0, Token1: 11, Fee: DynamicFeeFlag, TickSpacing: 60, Hooks: [20]byte{0x40},
+		Tick: -7, Liquidity: big.NewInt(5000), SqrtPriceX96: big.NewInt(1 << 40),
+		Ticks: []TickInfo{{Index: -60, LiquidityGross: big.NewInt(5000), LiquidityNet: big.NewInt(5000)}},
+		LPFee: &lpFee,
+	}}
+	diff := UniswapV4SystemDiff{Updates: pools, Deletions: []uint64{2}}
+
+	for _, version := range []uint64{1, 2} {
+		data, err := MarshalPoolsJSON(pools, version)
+		require.NoError(t, err)
+		decoded, err := UnmarshalPoolsJSON(data, version)
+		require.NoError(t, err)
+		assert.Equal(t, pools, decoded, "v%d", version)
+
+		data, err = MarshalDiffJSON(diff, version)
+		require.NoError(t, err)
+		decodedDiff, err := UnmarshalDiffJSON(data, version)
+		require.NoError(t, err)
+		assert.Equal(t, diff, decodedDiff, "v%d", version)
+	}
+}
@@ -0,0 +1,76 @@
+package uniswapv4
+
+import (
+	"math/big"
+)
+
+// --- Deep Copy Helper Functions ---
+
+// copyOptionalBigInt copies a *big.Int that is allowed to be nil.
+func copyOptionalBigInt(b *big.Int) *big.Int {
+	if b == nil {
+		return nil
+	}
+	return new(big.Int).Set(b)
+}
+
+// copyOptionalUint64 copies a *uint64 that is allowed to be nil.
+func copyOptionalUint64(v *uint64) *uint64 {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
+
+// deepCopyPool creates a new Pool with its own memory for all pointer types,
+// including the nested Ticks slice.
+func deepCopyPool(p Pool) Pool {
+	newPool := p
+	newPool.Liquidity = copyOptionalBigInt(p.Liquidity)
+	newPool.SqrtPriceX96 = copyOptionalBigInt(p.SqrtPriceX96)
+	newPool.LPFee = copyOptionalUint64(p.LPFee)
+	newPool.FeeOverride = copyOptionalUint64(p.FeeOverride)
+	newPool.LastUpdatedBlock = copyOptionalUint64(p.LastUpdatedBlock)
+
+	if p.Ticks != nil {
+		newTicks := make([]TickInfo, len(p.Ticks))
+		for i, tick := range p.Ticks {
+			newTicks[i] = tick
+			newTicks[i].LiquidityNet = copyOptionalBigInt(tick.LiquidityNet)
+			newTicks[i].LiquidityGross = copyOptionalBigInt(tick.LiquidityGross)
+		}
+		newPool.Ticks = newTicks
+	}
+	return newPool
+}
+
+// --- Patcher Implementation ---
+
+// Patcher constructs a new state for Uniswap V4 pools by applying a diff to a previous
+// state. The previous state is left untouched.
+func Patcher(prevState []Pool, diff UniswapV4SystemDiff) ([]Pool, error) {
+	newStateMap := make(map[uint64]Pool, len(prevState))
+	for _, pool := range prevState {
+		newStateMap[pool.ID] = deepCopyPool(pool)
+	}
+
+	for _, poolIDToDelete := range diff.Deletions {
+		delete(newStateMap, poolIDToDelete)
+	}
+
+	for _, updatedPool := range diff.Updates {
+		newStateMap[updatedPool.ID] = deepCopyPool(updatedPool)
+	}
+
+	for _, addedPool := range diff.Additions {
+		newStateMap[addedPool.ID] = deepCopyPool(addedPool)
+	}
+
+	finalState := make([]Pool, 0, len(newStateMap))
+	for _, pool := range newStateMap {
+		finalState = append(finalState, pool)
+	}
+
+	return finalState, nil
+}
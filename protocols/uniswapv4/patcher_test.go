@@ -0,0 +1,50 @@
+package uniswapv4
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatcher(t *testing.T) {
+	tick1 := TickInfo{Index: 10, LiquidityNet: big.NewInt(100), LiquidityGross: big.NewInt(100)}
+	pool1 := newTestPool(1, 1000, 5000, 100, []TickInfo{tick1})
+	pool2 := newTestPool(2, 2000, 6000, 200, nil)
+	pool2.LPFee = uint64Ptr(500)
+	prevState := []Pool{pool1, pool2}
+
+	updated := newTestPool(1, 1500, 5100, 110, []TickInfo{tick1})
+	added := newTestPool(3, 3000, 7000, 300, nil)
+
+	newState, err := Patcher(prevState, UniswapV4SystemDiff{
+		Additions: []Pool{added},
+		Updates:   []Pool{updated},
+		Deletions: []uint64{2},
+	})
+	require.NoError(t, err)
+	require.Len(t, newState, 2)
+
+	byID := map[uint64]Pool{}
+	for _, p := range newState {
+		byID[p.ID] = p
+	}
+	assert.Equal(t, int64(1500), byID[1].Liquidity.Int64())
+	assert.Equal(t, int64(3000), byID[3].Liquidity.Int64())
+	assert.NotContains(t, byID, uint64(2))
+
+	t.Run("previous state is untouched", func(t *testing.T) {
+		assert.Equal(t, int64(1000), prevState[0].Liquidity.Int64())
+
+		// The patched pools do not share memory with the diff.
+		byID[1].Ticks[0].LiquidityNet.SetInt64(-1)
+		assert.Equal(t, int64(100), tick1.LiquidityNet.Int64())
+	})
+
+	t.Run("round trip with the differ", func(t *testing.T) {
+		patched, err := Patcher(prevState, Differ(prevState, newState))
+		require.NoError(t, err)
+		assert.True(t, Differ(patched, newState).IsEmpty())
+	})
+}
@@ -0,0 +1,87 @@
+package uniswapv4
+
+import (
+	"math/big"
+
+	"github.com/defistate/defistate-client-go/addr"
+	"github.com/defistate/defistate-client-go/protocols/poolregistry"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3"
+)
+
+const (
+	// DynamicFeeFlag is the value of Fee that marks a pool whose LP fee is set by its hook
+	// rather than fixed in the pool key.
+	DynamicFeeFlag uint64 = 0x800000
+	// MaxLPFee is the largest LP fee, in hundredths of a bip, that is 100%.
+	MaxLPFee uint64 = 1_000_000
+)
+
+// TickInfo is the liquidity of an initialized tick. V4 keeps ticks exactly as V3 does.
+type TickInfo = uniswapv3.TickInfo
+
+// Pool is the view of a single Uniswap V4 pool. V4 pools live in a singleton contract
+// and are identified by their 32-byte PoolId, the hash of the pool key, rather than by
+// an address; ID is the pool registry's ID for that key.
+type Pool struct {
+	ID     uint64               `json:"id"`
+	PoolID poolregistry.PoolKey `json:"poolId"`
+	// Token0 and Token1 are the pool's currencies, sorted as in the pool key. The native
+	// currency is a token like any other.
+	Token0      uint64       `json:"token0"`
+	Token1      uint64       `json:"token1"`
+	Fee         uint64       `json:"fee"`
+	TickSpacing uint64       `json:"tickSpacing"`
+	Hooks       addr.Address `json:"hooks"`
+
+	Tick         int64      `json:"tick"`
+	Liquidity    *big.Int   `json:"liquidity"`
+	SqrtPriceX96 *big.Int   `json:"sqrtPriceX96"`
+	Ticks        []TickInfo `json:"ticks"`
+
+	// LPFee is the LP fee the hook last set on a dynamic-fee pool. It is optional;
+	// static-fee pools charge Fee.
+	LPFee *uint64 `json:"lpFee,omitempty"`
+	// FeeOverride is the fee the hook returns from beforeSwap for every swap, when the
+	// indexer knows it statically. It takes precedence over the pool's LP fee.
+	FeeOverride *uint64 `json:"feeOverride,omitempty"`
+
+	// LastUpdatedBlock is the block in which the pool's state last changed. It is
+	// optional; use LastUpdated to access it.
+	LastUpdatedBlock *uint64 `json:"lastUpdatedBlock,omitempty"`
+}
+
+// IsDynamicFee reports whether the pool's LP fee is set by its hook.
+func (p Pool) IsDynamicFee() bool {
+	return p.Fee == DynamicFeeFlag
+}
+
+// HasHooks reports whether the pool is attached to a hook contract.
+func (p Pool) HasHooks() bool {
+	return !p.Hooks.IsZero()
+}
+
+// SwapFee returns the fee a swap pays, in hundredths of a bip: the hook's static
+// override if known, otherwise the pool's LP fee. ok is false for a dynamic-fee pool
+// whose current fee the stream did not provide, and for fees above MaxLPFee.
+func (p Pool) SwapFee() (fee uint64, ok bool) {
+	switch {
+	case p.FeeOverride != nil:
+		fee = *p.FeeOverride
+	case !p.IsDynamicFee():
+		fee = p.Fee
+	case p.LPFee != nil:
+		fee = *p.LPFee
+	default:
+		return 0, false
+	}
+	return fee, fee <= MaxLPFee
+}
+
+// LastUpdated returns the block in which the pool's state last changed.
+// ok is false if the stream did not provide it.
+func (p Pool) LastUpdated() (block uint64, ok bool) {
+	if p.LastUpdatedBlock == nil {
+		return 0, false
+	}
+	return *p.LastUpdatedBlock, true
+}
@@ -0,0 +1,37 @@
+package uniswapv4
+
+import (
+	"testing"
+
+	"github.com/defistate/defistate-client-go/addr"
+	"github.com/stretchr/testify/assert"
+)
+
+func uint64Ptr(v uint64) *uint64 { return &v }
+
+func TestPoolSwapFee(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		pool    Pool
+		wantFee uint64
+		wantOK  bool
+	}{
+		{name: "static fee", pool: Pool{Fee: 3000}, wantFee: 3000, wantOK: true},
+		{name: "dynamic fee unknown", pool: Pool{Fee: DynamicFeeFlag}},
+		{name: "dynamic fee reported", pool: Pool{Fee: DynamicFeeFlag, LPFee: uint64Ptr(450)}, wantFee: 450, wantOK: true},
+		{name: "hook override on static pool", pool: Pool{Fee: 3000, FeeOverride: uint64Ptr(100)}, wantFee: 100, wantOK: true},
+		{name: "hook override on dynamic pool", pool: Pool{Fee: DynamicFeeFlag, LPFee: uint64Ptr(450), FeeOverride: uint64Ptr(0)}, wantFee: 0, wantOK: true},
+		{name: "fee above 100%", pool: Pool{Fee: DynamicFeeFlag, LPFee: uint64Ptr(MaxLPFee + 1)}, wantFee: MaxLPFee + 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fee, ok := tc.pool.SwapFee()
+			assert.Equal(t, tc.wantOK, ok)
+			assert.Equal(t, tc.wantFee, fee)
+		})
+	}
+}
+
+func TestPoolHasHooks(t *testing.T) {
+	assert.False(t, Pool{}.HasHooks())
+	assert.True(t, Pool{Hooks: addr.Address{19: 0x80}}.HasHooks())
+}
@@ -0,0 +1,7 @@
+package uniswapv4
+
+import (
+	"github.com/defistate/defistate-client-go/engine"
+)
+
+var Schema engine.ProtocolSchema = "defistate/uniswap-v4@v1"
@@ -0,0 +1,295 @@
+// Package statefilter trims states to the tokens, pools and protocols a consumer cares
+// about, e.g. to drop known scam tokens, dust pools or whole protocols before building a
+// graph. A Filter plugs into the client as its Filter hook:
+//
+//	filter := statefilter.New(statefilter.Config{DenyTokens: scams, MinLiquidity: big.NewInt(1e6)})
+//	cfg.Filter = filter.Apply
+package statefilter
+
+import (
+	"math/big"
+
+	"github.com/defistate/defistate-client-go/addr"
+	"github.com/defistate/defistate-client-go/engine"
+	"github.com/defistate/defistate-client-go/protocols/poolregistry"
+	"github.com/defistate/defistate-client-go/protocols/solidly"
+	"github.com/defistate/defistate-client-go/protocols/tokenpoolregistry"
+	"github.com/defistate/defistate-client-go/protocols/tokenregistry"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv4"
+)
+
+// Config configures a Filter. The zero value keeps everything.
+type Config struct {
+	// DenyTokens are dropped from the token registry, together with every pool that
+	// trades them.
+	DenyTokens []addr.Address
+	// AllowTokens, if not empty, keeps only these tokens and the pools between them.
+	AllowTokens []addr.Address
+
+	// DenyPools are dropped, by their pool registry key.
+	DenyPools []poolregistry.PoolKey
+
+	// DenyProtocols are dropped from the state. AllowProtocols, if not empty, keeps only
+	// these protocols and the registries (tokens, pools and token-pool graph), which
+	// every consumer needs.
+	DenyProtocols  []engine.ProtocolID
+	AllowProtocols []engine.ProtocolID
+
+	// MinReserve drops constant-product pools (Uniswap V2 style and Solidly) with a
+	// reserve below it. MinLiquidity drops concentrated-liquidity pools (Uniswap V3 and
+	// V4 style) whose active liquidity is below it. Both are in raw units, so they are
+	// best used to drop empty and near-empty pools; nil disables them.
+	MinReserve   *big.Int
+	MinLiquidity *big.Int
+}
+
+// Filter removes unwanted tokens, pools and protocols from states. It is safe for
+// concurrent use.
+type Filter struct {
+	denyTokens     map[addr.Address]struct{}
+	allowTokens    map[addr.Address]struct{}
+	denyPools      map[poolregistry.PoolKey]struct{}
+	denyProtocols  map[engine.ProtocolID]struct{}
+	allowProtocols map[engine.ProtocolID]struct{}
+	minReserve     *big.Int
+	minLiquidity   *big.Int
+}
+
+// New returns a Filter configured by cfg.
+func New(cfg Config) *Filter {
+	return &Filter{
+		denyTokens:     setOf(cfg.DenyTokens),
+		allowTokens:    setOf(cfg.AllowTokens),
+		denyPools:      setOf(cfg.DenyPools),
+		denyProtocols:  setOf(cfg.DenyProtocols),
+		allowProtocols: setOf(cfg.AllowProtocols),
+		minReserve:     cfg.MinReserve,
+		minLiquidity:   cfg.MinLiquidity,
+	}
+}
+
+func setOf[K comparable](keys []K) map[K]struct{} {
+	set := make(map[K]struct{}, len(keys))
+	for _, key := range keys {
+		set[key] = struct{}{}
+	}
+	return set
+}
+
+// Apply returns state without the filtered tokens, pools and protocols. state is not
+// modified; the result shares the data of protocols the filter leaves untouched. Protocols
+// of unknown schemas are kept or dropped as a whole.
+func (f *Filter) Apply(state *engine.State) *engine.State {
+	if state == nil {
+		return nil
+	}
+	run := f.newRun(state)
+
+	filtered := *state
+	filtered.Protocols = make(map[engine.ProtocolID]engine.ProtocolState, len(state.Protocols))
+	// Pool protocols first: the registries are trimmed to the pools removed from them.
+	for id, protocol := range state.Protocols {
+		if !f.keepProtocol(id, protocol) {
+			continue
+		}
+		protocol.Data = run.filterPools(protocol.Data)
+		filtered.Protocols[id] = protocol
+	}
+	for id, protocol := range filtered.Protocols {
+		protocol.Data = run.filterRegistry(protocol.Data)
+		filtered.Protocols[id] = protocol
+	}
+	return &filtered
+}
+
+// keepProtocol reports whether the protocol survives the protocol deny and allow lists.
+func (f *Filter) keepProtocol(id engine.ProtocolID, protocol engine.ProtocolState) bool {
+	if _, denied := f.denyProtocols[id]; denied {
+		return false
+	}
+	if len(f.allowProtocols) == 0 || isRegistry(protocol.Data) {
+		return true
+	}
+	_, allowed := f.allowProtocols[id]
+	return allowed
+}
+
+func isRegistry(data any) bool {
+	switch data.(type) {
+	case []tokenregistry.Token, poolregistry.PoolRegistry, *tokenpoolregistry.TokenPoolRegistryView:
+		return true
+	}
+	return false
+}
+
+// run holds what filtering one state decided: the tokens and pools it removes.
+type run struct {
+	filter        *Filter
+	removedTokens map[uint64]struct{}
+	removedPools  map[uint64]struct{}
+}
+
+// newRun resolves the filter's token addresses and pool keys to the IDs of state, and
+// collects the pools of dropped protocols.
+func (f *Filter) newRun(state *engine.State) *run {
+	r := &run{
+		filter:        f,
+		removedTokens: make(map[uint64]struct{}),
+		removedPools:  make(map[uint64]struct{}),
+	}
+	dropped := make(map[engine.ProtocolID]struct{})
+	for id, protocol := range state.Protocols {
+		if !f.keepProtocol(id, protocol) {
+			dropped[id] = struct{}{}
+		}
+	}
+	for _, protocol := range state.Protocols {
+		switch data := protocol.Data.(type) {
+		case []tokenregistry.Token:
+			for _, token := range data {
+				if !f.keepToken(token.Address) {
+					r.removedTokens[token.ID] = struct{}{}
+				}
+			}
+		case poolregistry.PoolRegistry:
+			for _, pool := range data.Pools {
+				_, denied := f.denyPools[pool.Key]
+				_, droppedProtocol := dropped[data.Protocols[pool.Protocol]]
+				if denied || droppedProtocol {
+					r.removedPools[pool.ID] = struct{}{}
+				}
+			}
+		}
+	}
+	return r
+}
+
+func (f *Filter) keepToken(address addr.Address) bool {
+	if _, denied := f.denyTokens[address]; denied {
+		return false
+	}
+	if len(f.allowTokens) == 0 {
+		return true
+	}
+	_, allowed := f.allowTokens[address]
+	return allowed
+}
+
+// filterPools returns the pools of data that survive the filter, recording the removed
+// ones. Data that is not a pool list is returned as is.
+func (r *run) filterPools(data any) any {
+	switch pools := data.(type) {
+	case []uniswapv2.Pool:
+		return filterSlice(pools, r, func(p uniswapv2.Pool) (uint64, bool) {
+			return p.ID, r.keepPair(p.Token0, p.Token1) && r.filter.aboveMinReserve(p.Reserve0, p.Reserve1)
+		})
+	case []solidly.Pool:
+		return filterSlice(pools, r, func(p solidly.Pool) (uint64, bool) {
+			return p.ID, r.keepPair(p.Token0, p.Token1) && r.filter.aboveMinReserve(p.Reserve0, p.Reserve1)
+		})
+	case []uniswapv3.Pool:
+		return filterSlice(pools, r, func(p uniswapv3.Pool) (uint64, bool) {
+			return p.ID, r.keepPair(p.Token0, p.Token1) && r.filter.aboveMinLiquidity(p.Liquidity)
+		})
+	case []uniswapv4.Pool:
+		return filterSlice(pools, r, func(p uniswapv4.Pool) (uint64, bool) {
+			return p.ID, r.keepPair(p.Token0, p.Token1) && r.filter.aboveMinLiquidity(p.Liquidity)
+		})
+	}
+	return data
+}
+
+// filterSlice returns the pools keep accepts that were not removed already, recording the
+// rest as removed. pools itself is returned if nothing is removed.
+func filterSlice[P any](pools []P, r *run, keep func(P) (uint64, bool)) []P {
+	var kept []P
+	for i, pool := range pools {
+		id, ok := keep(pool)
+		if _, removed := r.removedPools[id]; removed {
+			ok = false
+		}
+		if !ok {
+			r.removedPools[id] = struct{}{}
+			if kept == nil {
+				kept = append(make([]P, 0, len(pools)-1), pools[:i]...)
+			}
+			continue
+		}
+		if kept != nil {
+			kept = append(kept, pool)
+		}
+	}
+	if kept == nil {
+		return pools
+	}
+	return kept
+}
+
+func (r *run) keepPair(token0, token1 uint64) bool {
+	_, removed0 := r.removedTokens[token0]
+	_, removed1 := r.removedTokens[token1]
+	return !removed0 && !removed1
+}
+
+func (f *Filter) aboveMinReserve(reserve0, reserve1 *big.Int) bool {
+	if f.minReserve == nil {
+		return true
+	}
+	return reserve0 != nil && reserve1 != nil && reserve0.Cmp(f.minReserve) >= 0 && reserve1.Cmp(f.minReserve) >= 0
+}
+
+func (f *Filter) aboveMinLiquidity(liquidity *big.Int) bool {
+	if f.minLiquidity == nil {
+		return true
+	}
+	return liquidity != nil && liquidity.Cmp(f.minLiquidity) >= 0
+}
+
+// filterRegistry drops the removed tokens and pools from the registries. Other data is
+// returned as is.
+func (r *run) filterRegistry(data any) any {
+	switch registry := data.(type) {
+	case []tokenregistry.Token:
+		if len(r.removedTokens) == 0 {
+			return registry
+		}
+		kept := make([]tokenregistry.Token, 0, len(registry))
+		for _, token := range registry {
+			if _, removed := r.removedTokens[token.ID]; !removed {
+				kept = append(kept, token)
+			}
+		}
+		return kept
+	case poolregistry.PoolRegistry:
+		if len(r.removedPools) == 0 {
+			return registry
+		}
+		kept := make([]poolregistry.Pool, 0, len(registry.Pools))
+		for _, pool := range registry.Pools {
+			if _, removed := r.removedPools[pool.ID]; !removed {
+				kept = append(kept, pool)
+			}
+		}
+		return poolregistry.PoolRegistry{Pools: kept, Protocols: registry.Protocols}
+	case *tokenpoolregistry.TokenPoolRegistryView:
+		if registry == nil || len(r.removedTokens) == 0 && len(r.removedPools) == 0 {
+			return registry
+		}
+		// The system copies the view, so the unfiltered state keeps its own.
+		system := tokenpoolregistry.NewTokenPoolSystemFromView(registry, 0)
+		system.RemoveTokens(keys(r.removedTokens))
+		system.RemovePools(keys(r.removedPools))
+		return system.View()
+	}
+	return data
+}
+
+func keys(set map[uint64]struct{}) []uint64 {
+	ids := make([]uint64, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	return ids
+}
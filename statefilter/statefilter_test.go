@@ -0,0 +1,125 @@
+package statefilter
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/defistate/defistate-client-go/addr"
+	"github.com/defistate/defistate-client-go/engine"
+	"github.com/defistate/defistate-client-go/protocols/poolregistry"
+	"github.com/defistate/defistate-client-go/protocols/tokenpoolregistry"
+	"github.com/defistate/defistate-client-go/protocols/tokenregistry"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	weth = addr.FromCommon(common.HexToAddress("0x01"))
+	usdc = addr.FromCommon(common.HexToAddress("0x02"))
+	scam = addr.FromCommon(common.HexToAddress("0x03"))
+)
+
+func poolKey(id uint64) poolregistry.PoolKey {
+	return poolregistry.AddressToPoolKey(addr.FromCommon(common.BigToAddress(new(big.Int).SetUint64(100 + id))))
+}
+
+// testState has WETH/USDC and WETH/SCAM pools on Uniswap V2 (pools 1 and 2, the latter
+// nearly empty) and a WETH/USDC pool on Uniswap V3 (pool 3).
+func testState() *engine.State {
+	tokenPools := tokenpoolregistry.NewTokenPoolSystem(0)
+	tokenPools.AddPools([]uint64{1, 2, 3}, [][]uint64{{10, 20}, {10, 30}, {10, 20}})
+
+	return &engine.State{
+		Block: engine.BlockSummary{Number: big.NewInt(1)},
+		Protocols: map[engine.ProtocolID]engine.ProtocolState{
+			"tokens": {Schema: tokenregistry.Schema, Data: []tokenregistry.Token{
+				{ID: 10, Address: weth, Symbol: "WETH"},
+				{ID: 20, Address: usdc, Symbol: "USDC"},
+				{ID: 30, Address: scam, Symbol: "SCAM"},
+			}},
+			"pools": {Schema: poolregistry.Schema, Data: poolregistry.PoolRegistry{
+				Pools: []poolregistry.Pool{
+					{ID: 1, Key: poolKey(1), Protocol: 0},
+					{ID: 2, Key: poolKey(2), Protocol: 0},
+					{ID: 3, Key: poolKey(3), Protocol: 1},
+				},
+				Protocols: map[uint16]engine.ProtocolID{0: "uniswap_v2", 1: "uniswap_v3"},
+			}},
+			"token_pools": {Schema: tokenpoolregistry.Schema, Data: tokenPools.View()},
+			"uniswap_v2": {Schema: uniswapv2.Schema, Data: []uniswapv2.Pool{
+				{ID: 1, Token0: 10, Token1: 20, Reserve0: big.NewInt(1e18), Reserve1: big.NewInt(3e9)},
+				{ID: 2, Token0: 10, Token1: 30, Reserve0: big.NewInt(5), Reserve1: big.NewInt(1e18)},
+			}},
+			"uniswap_v3": {Schema: uniswapv3.Schema, Data: []uniswapv3.Pool{
+				{PoolViewMinimal: uniswapv3.PoolViewMinimal{ID: 3, Token0: 10, Token1: 20, Liquidity: big.NewInt(1e12)}},
+			}},
+		},
+	}
+}
+
+func poolIDs(t *testing.T, state *engine.State) []uint64 {
+	t.Helper()
+	var ids []uint64
+	for _, pool := range state.Protocols["pools"].Data.(poolregistry.PoolRegistry).Pools {
+		ids = append(ids, pool.ID)
+	}
+	return ids
+}
+
+func TestFilter_DenyTokens(t *testing.T) {
+	state := testState()
+	filtered := New(Config{DenyTokens: []addr.Address{scam}}).Apply(state)
+
+	tokens := filtered.Protocols["tokens"].Data.([]tokenregistry.Token)
+	assert.Len(t, tokens, 2)
+	v2 := filtered.Protocols["uniswap_v2"].Data.([]uniswapv2.Pool)
+	require.Len(t, v2, 1)
+	assert.Equal(t, uint64(1), v2[0].ID)
+	assert.Equal(t, []uint64{1, 3}, poolIDs(t, filtered))
+
+	view := tokenpoolregistry.NewTokenPoolSystemFromView(filtered.Protocols["token_pools"].Data.(*tokenpoolregistry.TokenPoolRegistryView), 0)
+	assert.Empty(t, view.PoolsForToken(30))
+	assert.ElementsMatch(t, []uint64{1, 3}, view.PoolsForToken(10))
+
+	// The input is untouched, so the client can keep patching it.
+	assert.Len(t, state.Protocols["tokens"].Data.([]tokenregistry.Token), 3)
+	assert.Len(t, state.Protocols["uniswap_v2"].Data.([]uniswapv2.Pool), 2)
+	assert.Equal(t, []uint64{1, 2, 3}, poolIDs(t, state))
+	original := tokenpoolregistry.NewTokenPoolSystemFromView(state.Protocols["token_pools"].Data.(*tokenpoolregistry.TokenPoolRegistryView), 0)
+	assert.Equal(t, []uint64{2}, original.PoolsForToken(30))
+}
+
+func TestFilter_AllowTokens(t *testing.T) {
+	filtered := New(Config{AllowTokens: []addr.Address{weth, usdc}}).Apply(testState())
+	assert.Equal(t, []uint64{1, 3}, poolIDs(t, filtered))
+}
+
+func TestFilter_Protocols(t *testing.T) {
+	filtered := New(Config{AllowProtocols: []engine.ProtocolID{"uniswap_v3"}}).Apply(testState())
+	assert.NotContains(t, filtered.Protocols, engine.ProtocolID("uniswap_v2"))
+	assert.Contains(t, filtered.Protocols, engine.ProtocolID("tokens"), "registries are always kept")
+	assert.Equal(t, []uint64{3}, poolIDs(t, filtered))
+
+	filtered = New(Config{DenyProtocols: []engine.ProtocolID{"uniswap_v3"}}).Apply(testState())
+	assert.NotContains(t, filtered.Protocols, engine.ProtocolID("uniswap_v3"))
+	assert.Equal(t, []uint64{1, 2}, poolIDs(t, filtered))
+}
+
+func TestFilter_PoolsAndThresholds(t *testing.T) {
+	filtered := New(Config{DenyPools: []poolregistry.PoolKey{poolKey(1)}}).Apply(testState())
+	assert.Equal(t, []uint64{2, 3}, poolIDs(t, filtered))
+	assert.Len(t, filtered.Protocols["uniswap_v2"].Data.([]uniswapv2.Pool), 1)
+
+	filtered = New(Config{MinReserve: big.NewInt(1000), MinLiquidity: big.NewInt(1e15)}).Apply(testState())
+	assert.Equal(t, []uint64{1}, poolIDs(t, filtered))
+	assert.Empty(t, filtered.Protocols["uniswap_v3"].Data.([]uniswapv3.Pool))
+}
+
+func TestFilter_ZeroConfigKeepsEverything(t *testing.T) {
+	state := testState()
+	filtered := New(Config{}).Apply(state)
+	assert.Equal(t, state.Protocols, filtered.Protocols)
+}
@@ -0,0 +1,294 @@
+package statestore
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	differ "github.com/defistate/defistate-client-go/differ"
+	engine "github.com/defistate/defistate-client-go/engine"
+)
+
+// DefaultSnapshotInterval is the number of blocks between Journal snapshots when
+// JournalConfig.SnapshotInterval is zero.
+const DefaultSnapshotInterval = 1000
+
+// ErrNoSnapshot is returned by Journal.Latest when no snapshot has been stored yet.
+var ErrNoSnapshot = errors.New("statestore: no snapshot stored")
+
+// PatcherFunc applies a diff to the state before it. The StateOps of each chain provide
+// one as Patch.
+type PatcherFunc func(prevState *engine.State, diff *differ.StateDiff) (*engine.State, error)
+
+// JournalConfig configures a Journal.
+type JournalConfig struct {
+	Dir string
+	// StateDecoder and DiffDecoder turn stored protocol data back into typed values. The
+	// StateOps of each chain provide them as DecodeStateJSON and DecodeStateDiffJSON.
+	StateDecoder DecoderFunc
+	DiffDecoder  DecoderFunc
+	// SnapshotInterval is the number of blocks between full snapshots. Zero means
+	// DefaultSnapshotInterval.
+	SnapshotInterval uint64
+	// MaxSnapshots is the number of snapshots kept, each with the log of diffs after it.
+	// Older ones are deleted when a snapshot is taken. Zero keeps every snapshot.
+	MaxSnapshots int
+}
+
+// Journal persists a state stream as periodic full snapshots plus a write-ahead log of
+// the diffs in between, so a restarted client can rebuild its latest state from disk
+// (see Latest) instead of fetching a full state over the network. It implements the
+// Journal hook of the JSON-RPC client.
+//
+// Snapshots are written under "<dir>/snapshots" like a FileStore; the diffs after the
+// snapshot of block N are appended to "<dir>/wal/N.wal", one JSON diff per line. The log
+// is not synced after every diff, so a crash can lose its last lines; Latest then
+// stops at the last complete diff.
+type Journal struct {
+	dir          string
+	snapshots    *FileStore
+	diffDecoder  DecoderFunc
+	interval     uint64
+	maxSnapshots int
+
+	mu sync.Mutex
+	// wal is the open log of diffs after the snapshot of snapshotBlock; lastBlock is the
+	// block of the last state recorded.
+	wal           *os.File
+	snapshotBlock uint64
+	lastBlock     uint64
+}
+
+// NewJournal creates the journal's directories if needed and returns a journal writing
+// into them.
+func NewJournal(cfg JournalConfig) (*Journal, error) {
+	if cfg.DiffDecoder == nil {
+		return nil, errors.New("statestore: diff decoder is required")
+	}
+	if cfg.MaxSnapshots < 0 {
+		return nil, errors.New("statestore: max snapshots must not be negative")
+	}
+	snapshots, err := NewFileStore(filepath.Join(cfg.Dir, "snapshots"), cfg.StateDecoder)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(cfg.Dir, "wal"), 0o755); err != nil {
+		return nil, fmt.Errorf("statestore: creating %s: %w", cfg.Dir, err)
+	}
+
+	interval := cfg.SnapshotInterval
+	if interval == 0 {
+		interval = DefaultSnapshotInterval
+	}
+	return &Journal{
+		dir:          cfg.Dir,
+		snapshots:    snapshots,
+		diffDecoder:  cfg.DiffDecoder,
+		interval:     interval,
+		maxSnapshots: cfg.MaxSnapshots,
+	}, nil
+}
+
+// Record persists state. A full state (nil diff), a diff that does not continue from the
+// last recorded state, or a state SnapshotInterval blocks past the last snapshot starts
+// a new snapshot; any other diff is appended to the log.
+func (j *Journal) Record(state *engine.State, diff *differ.StateDiff) error {
+	if state == nil || state.Block.Number == nil {
+		return errors.New("statestore: state has no block number")
+	}
+	block := state.Block.Number.Uint64()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if diff == nil || j.wal == nil || diff.FromBlock != j.lastBlock || block >= j.snapshotBlock+j.interval {
+		return j.snapshot(block, state)
+	}
+
+	line, err := json.Marshal(diff)
+	if err != nil {
+		return fmt.Errorf("statestore: encoding diff to block %d: %w", block, err)
+	}
+	if _, err := j.wal.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("statestore: logging diff to block %d: %w", block, err)
+	}
+	j.lastBlock = block
+	return nil
+}
+
+// snapshot stores state and starts a new log after it.
+func (j *Journal) snapshot(block uint64, state *engine.State) error {
+	if err := j.snapshots.Put(block, state); err != nil {
+		return err
+	}
+	if j.wal != nil {
+		j.wal.Close()
+		j.wal = nil
+	}
+	wal, err := os.OpenFile(j.walPath(block), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("statestore: starting log after block %d: %w", block, err)
+	}
+	j.wal, j.snapshotBlock, j.lastBlock = wal, block, block
+	return j.prune()
+}
+
+// prune deletes the snapshots, and their logs, beyond the newest MaxSnapshots.
+func (j *Journal) prune() error {
+	if j.maxSnapshots == 0 {
+		return nil
+	}
+	blocks, err := j.snapshotBlocks()
+	if err != nil {
+		return err
+	}
+	for _, block := range blocks[:max(len(blocks)-j.maxSnapshots, 0)] {
+		if err := os.Remove(j.snapshots.path(block)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("statestore: pruning block %d: %w", block, err)
+		}
+		if err := os.Remove(j.walPath(block)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("statestore: pruning block %d: %w", block, err)
+		}
+	}
+	return nil
+}
+
+// Latest rebuilds the newest recorded state: it loads the newest snapshot and applies
+// the logged diffs after it with patch. It returns ErrNoSnapshot if nothing is stored.
+// It only reads files, so it may run while another journal on the same directory records.
+func (j *Journal) Latest(patch PatcherFunc) (*engine.State, error) {
+	blocks, err := j.snapshotBlocks()
+	if err != nil {
+		return nil, err
+	}
+	if len(blocks) == 0 {
+		return nil, ErrNoSnapshot
+	}
+
+	var latest *engine.State
+	err = j.walk(blocks[len(blocks)-1], patch, func(state *engine.State) bool {
+		latest = state
+		return true
+	})
+	return latest, err
+}
+
+// walk loads the snapshot of snapshotBlock and applies its logged diffs with patch,
+// passing the snapshot and every patched state to visit until it returns false.
+func (j *Journal) walk(snapshotBlock uint64, patch PatcherFunc, visit func(*engine.State) bool) error {
+	state, ok := j.snapshots.Get(snapshotBlock)
+	if !ok {
+		return fmt.Errorf("statestore: snapshot of block %d cannot be read", snapshotBlock)
+	}
+	if !visit(state) {
+		return nil
+	}
+
+	f, err := os.Open(j.walPath(snapshotBlock))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("statestore: reading log after block %d: %w", snapshotBlock, err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			// A line without its newline is a torn write; the log ends before it.
+			return nil
+		}
+		diff, err := j.decodeDiff(line)
+		if err != nil || diff.FromBlock != state.Block.Number.Uint64() {
+			return nil
+		}
+		next, err := patch(state, diff)
+		if err != nil {
+			return fmt.Errorf("statestore: patching block %d: %w", diff.FromBlock, err)
+		}
+		next.Timestamp = diff.Timestamp
+		state = next
+		if !visit(state) {
+			return nil
+		}
+	}
+}
+
+// storedProtocolDiff mirrors differ.ProtocolDiff but keeps Data raw, so it can be
+// decoded according to its schema.
+type storedProtocolDiff struct {
+	differ.ProtocolDiff
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+type storedDiff struct {
+	differ.StateDiff
+	Protocols map[engine.ProtocolID]storedProtocolDiff `json:"protocols"`
+}
+
+func (j *Journal) decodeDiff(data []byte) (*differ.StateDiff, error) {
+	var stored storedDiff
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+
+	diff := stored.StateDiff
+	diff.Protocols = make(map[engine.ProtocolID]differ.ProtocolDiff, len(stored.Protocols))
+	for id, protocol := range stored.Protocols {
+		result := protocol.ProtocolDiff
+		if len(protocol.Data) > 0 {
+			decoded, err := j.diffDecoder(protocol.Schema, protocol.Data)
+			if err != nil {
+				return nil, fmt.Errorf("statestore: decoding protocol %s: %w", id, err)
+			}
+			result.Data = decoded
+		}
+		diff.Protocols[id] = result
+	}
+	return &diff, nil
+}
+
+// snapshotBlocks lists the blocks with a snapshot, oldest first.
+func (j *Journal) snapshotBlocks() ([]uint64, error) {
+	entries, err := os.ReadDir(j.snapshots.dir)
+	if err != nil {
+		return nil, fmt.Errorf("statestore: listing snapshots: %w", err)
+	}
+	var blocks []uint64
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		if block, err := strconv.ParseUint(name, 10, 64); err == nil {
+			blocks = append(blocks, block)
+		}
+	}
+	slices.Sort(blocks)
+	return blocks, nil
+}
+
+// Close closes the open log. The journal must not be used afterwards.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.wal == nil {
+		return nil
+	}
+	err := j.wal.Close()
+	j.wal = nil
+	return err
+}
+
+func (j *Journal) walPath(block uint64) string {
+	return filepath.Join(j.dir, "wal", strconv.FormatUint(block, 10)+".wal")
+}
@@ -0,0 +1,128 @@
+package statestore
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	differ "github.com/defistate/defistate-client-go/differ"
+	"github.com/defistate/defistate-client-go/engine"
+	uniswapv2 "github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	ethstateops "github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains/ethereum"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestJournal(t *testing.T, interval uint64, maxSnapshots int) (*Journal, *ethstateops.StateOps, string) {
+	stateOps, err := ethstateops.NewStateOps(slog.New(slog.NewJSONHandler(io.Discard, nil)), prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	journal, err := NewJournal(JournalConfig{
+		Dir:              dir,
+		StateDecoder:     stateOps.DecodeStateJSON,
+		DiffDecoder:      stateOps.DecodeStateDiffJSON,
+		SnapshotInterval: interval,
+		MaxSnapshots:     maxSnapshots,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { journal.Close() })
+	return journal, stateOps, dir
+}
+
+// makeDiff updates the reserves of pool 7 from block to block+1.
+func makeDiff(block int64) *differ.StateDiff {
+	return &differ.StateDiff{
+		Timestamp: uint64(block) * 10,
+		FromBlock: uint64(block),
+		ToBlock:   engine.BlockSummary{Number: big.NewInt(block + 1)},
+		Protocols: map[engine.ProtocolID]differ.ProtocolDiff{
+			"univ2": {
+				Schema: uniswapv2.Schema,
+				Data: uniswapv2.UniswapV2SystemDiff{Updates: []uniswapv2.Pool{
+					{ID: 7, Token0: 1, Token1: 2, Reserve0: big.NewInt(1_000 + block), Reserve1: big.NewInt(2_000), FeeBps: 30},
+				}},
+			},
+		},
+	}
+}
+
+// recordStream records a full state for from and the diffs up to to.
+func recordStream(t *testing.T, journal *Journal, stateOps *ethstateops.StateOps, from, to int64) *engine.State {
+	state := makeState(from)
+	delete(state.Protocols, "broken")
+	require.NoError(t, journal.Record(state, nil))
+	for block := from; block < to; block++ {
+		diff := makeDiff(block)
+		next, err := stateOps.Patch(state, diff)
+		require.NoError(t, err)
+		next.Timestamp = diff.Timestamp
+		require.NoError(t, journal.Record(next, diff))
+		state = next
+	}
+	return state
+}
+
+func TestJournal_Latest(t *testing.T) {
+	t.Run("Rebuilds the latest state from a snapshot and its log", func(t *testing.T) {
+		journal, stateOps, dir := newTestJournal(t, 100, 0)
+		expected := recordStream(t, journal, stateOps, 100, 103)
+
+		latest, err := journal.Latest(stateOps.Patch)
+		require.NoError(t, err)
+		assert.Equal(t, int64(103), latest.Block.Number.Int64())
+		assert.Equal(t, expected.Timestamp, latest.Timestamp)
+		assert.Equal(t, expected.Protocols, latest.Protocols)
+
+		snapshots, err := os.ReadDir(filepath.Join(dir, "snapshots"))
+		require.NoError(t, err)
+		assert.Len(t, snapshots, 1, "diffs within the interval are only logged")
+	})
+
+	t.Run("Takes a snapshot every interval and prunes old ones", func(t *testing.T) {
+		journal, stateOps, dir := newTestJournal(t, 2, 2)
+		recordStream(t, journal, stateOps, 100, 105)
+
+		blocks, err := journal.snapshotBlocks()
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{102, 104}, blocks)
+		_, err = os.Stat(filepath.Join(dir, "wal", "100.wal"))
+		assert.True(t, errors.Is(err, os.ErrNotExist), "the log of a pruned snapshot is deleted")
+
+		latest, err := journal.Latest(stateOps.Patch)
+		require.NoError(t, err)
+		assert.Equal(t, int64(105), latest.Block.Number.Int64())
+	})
+
+	t.Run("Stops at a torn log line", func(t *testing.T) {
+		journal, stateOps, dir := newTestJournal(t, 100, 0)
+		recordStream(t, journal, stateOps, 100, 102)
+		require.NoError(t, journal.Close())
+
+		wal, err := os.OpenFile(filepath.Join(dir, "wal", "100.wal"), os.O_APPEND|os.O_WRONLY, 0o644)
+		require.NoError(t, err)
+		_, err = wal.WriteString(`{"fromBlock":102,"toBl`)
+		require.NoError(t, err)
+		require.NoError(t, wal.Close())
+
+		latest, err := journal.Latest(stateOps.Patch)
+		require.NoError(t, err)
+		assert.Equal(t, int64(102), latest.Block.Number.Int64())
+	})
+
+	t.Run("Empty journal", func(t *testing.T) {
+		journal, stateOps, _ := newTestJournal(t, 100, 0)
+		_, err := journal.Latest(stateOps.Patch)
+		assert.ErrorIs(t, err, ErrNoSnapshot)
+	})
+}
+
+func TestNewJournal_RequiresDecoders(t *testing.T) {
+	_, err := NewJournal(JournalConfig{Dir: t.TempDir()})
+	assert.Error(t, err)
+}
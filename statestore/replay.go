@@ -0,0 +1,116 @@
+package statestore
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+
+	engine "github.com/defistate/defistate-client-go/engine"
+)
+
+// ErrBlockNotStored is returned when a journal holds no state for a requested block.
+var ErrBlockNotStored = errors.New("statestore: block not stored")
+
+// Replayer reconstructs past states from a Journal's snapshots and logs, e.g. to backtest
+// routing and arbitrage strategies offline. How far back it reaches depends on the
+// journal's MaxSnapshots.
+type Replayer struct {
+	journal *Journal
+	patch   PatcherFunc
+}
+
+// NewReplayer returns a replayer over journal that applies logged diffs with patch.
+func NewReplayer(journal *Journal, patch PatcherFunc) (*Replayer, error) {
+	if journal == nil {
+		return nil, errors.New("statestore: journal is required")
+	}
+	if patch == nil {
+		return nil, errors.New("statestore: patcher is required")
+	}
+	return &Replayer{journal: journal, patch: patch}, nil
+}
+
+// StateAt reconstructs the state at block by loading the nearest snapshot at or before it
+// and applying the logged diffs forward. It returns ErrBlockNotStored if the journal does
+// not cover block.
+func (r *Replayer) StateAt(block uint64) (*engine.State, error) {
+	for state, err := range r.ReplayRange(block, block) {
+		if err != nil {
+			return nil, err
+		}
+		if state.Block.Number.Uint64() == block {
+			return state, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: block %d", ErrBlockNotStored, block)
+}
+
+// ReplayRange returns an iterator over the stored states from fromBlock through toBlock,
+// oldest first. It starts from the nearest snapshot at or before fromBlock, applies the
+// logged diffs forward and carries on from the next snapshot wherever a log ends. Blocks
+// the journal never recorded, such as those missed while the client was down, are
+// skipped. If no snapshot covers fromBlock, the iterator yields ErrBlockNotStored; any
+// error ends the iteration.
+//
+// Each state is built by patching the one before it, so the iterator must not be used
+// while the states it yields are modified.
+func (r *Replayer) ReplayRange(fromBlock, toBlock uint64) iter.Seq2[*engine.State, error] {
+	return func(yield func(*engine.State, error) bool) {
+		if fromBlock > toBlock {
+			yield(nil, fmt.Errorf("statestore: invalid range %d to %d", fromBlock, toBlock))
+			return
+		}
+		snapshots, err := r.journal.snapshotBlocks()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+
+		// Start from the newest snapshot at or before fromBlock.
+		start := -1
+		for i, block := range snapshots {
+			if block > fromBlock {
+				break
+			}
+			start = i
+		}
+		if start == -1 {
+			yield(nil, fmt.Errorf("%w: no snapshot at or before block %d", ErrBlockNotStored, fromBlock))
+			return
+		}
+
+		var (
+			lastBlock uint64
+			emitted   bool
+			done      bool
+		)
+		for _, snapshot := range snapshots[start:] {
+			if snapshot > toBlock {
+				return
+			}
+			err := r.journal.walk(snapshot, r.patch, func(state *engine.State) bool {
+				block := state.Block.Number.Uint64()
+				if block > toBlock {
+					done = true
+					return false
+				}
+				if block < fromBlock || (emitted && block <= lastBlock) {
+					return true
+				}
+				lastBlock, emitted = block, true
+				if !yield(state, nil) {
+					done = true
+					return false
+				}
+				return true
+			})
+			if done {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package statestore
+
+import (
+	"testing"
+
+	"github.com/defistate/defistate-client-go/engine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayer(t *testing.T) {
+	journal, stateOps, _ := newTestJournal(t, 2, 0)
+
+	// Record blocks 100-105 and keep every state to compare against.
+	recorded := map[uint64]*engine.State{}
+	state := recordStream(t, journal, stateOps, 100, 100)
+	recorded[100] = state
+	for block := int64(100); block < 105; block++ {
+		diff := makeDiff(block)
+		next, err := stateOps.Patch(state, diff)
+		require.NoError(t, err)
+		next.Timestamp = diff.Timestamp
+		require.NoError(t, journal.Record(next, diff))
+		recorded[uint64(block+1)] = next
+		state = next
+	}
+
+	replayer, err := NewReplayer(journal, stateOps.Patch)
+	require.NoError(t, err)
+
+	t.Run("ReplayRange yields every block in order across snapshots", func(t *testing.T) {
+		var blocks []uint64
+		for state, err := range replayer.ReplayRange(101, 104) {
+			require.NoError(t, err)
+			block := state.Block.Number.Uint64()
+			blocks = append(blocks, block)
+			assert.Equal(t, recorded[block].Protocols, state.Protocols, "block %d", block)
+			assert.Equal(t, recorded[block].Timestamp, state.Timestamp, "block %d", block)
+		}
+		assert.Equal(t, []uint64{101, 102, 103, 104}, blocks)
+	})
+
+	t.Run("ReplayRange stops when the caller breaks", func(t *testing.T) {
+		count := 0
+		for _, err := range replayer.ReplayRange(100, 105) {
+			require.NoError(t, err)
+			count++
+			if count == 2 {
+				break
+			}
+		}
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("StateAt", func(t *testing.T) {
+		state, err := replayer.StateAt(103)
+		require.NoError(t, err)
+		assert.Equal(t, recorded[103].Protocols, state.Protocols)
+
+		_, err = replayer.StateAt(99)
+		assert.ErrorIs(t, err, ErrBlockNotStored)
+		_, err = replayer.StateAt(106)
+		assert.ErrorIs(t, err, ErrBlockNotStored)
+	})
+
+	t.Run("Invalid range", func(t *testing.T) {
+		for _, err := range replayer.ReplayRange(5, 4) {
+			assert.Error(t, err)
+		}
+	})
+}
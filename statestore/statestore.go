@@ -0,0 +1,115 @@
+package statestore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	engine "github.com/defistate/defistate-client-go/engine"
+)
+
+// DecoderFunc decodes the JSON data of a single protocol into its typed form.
+// The StateOps of each chain provide one as DecodeStateJSON.
+type DecoderFunc func(schema engine.ProtocolSchema, data json.RawMessage) (any, error)
+
+// FileStore persists each state as a JSON file named after its block number,
+// e.g. "<dir>/19000000.json". A state written for a block that is already stored
+// replaces it.
+type FileStore struct {
+	dir     string
+	decoder DecoderFunc
+}
+
+// NewFileStore creates dir if needed and returns a store writing into it. decoder is used
+// by Get to turn the stored protocol data back into the typed values the client expects.
+func NewFileStore(dir string, decoder DecoderFunc) (*FileStore, error) {
+	if decoder == nil {
+		return nil, errors.New("statestore: decoder is required")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("statestore: creating %s: %w", dir, err)
+	}
+	return &FileStore{dir: dir, decoder: decoder}, nil
+}
+
+// Put writes state for block. The file is written under a temporary name and renamed
+// into place, so a reader never sees a partially written state.
+func (s *FileStore) Put(block uint64, state *engine.State) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("statestore: encoding block %d: %w", block, err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, "state-*.tmp")
+	if err != nil {
+		return fmt.Errorf("statestore: writing block %d: %w", block, err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("statestore: writing block %d: %w", block, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("statestore: writing block %d: %w", block, err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(block)); err != nil {
+		return fmt.Errorf("statestore: writing block %d: %w", block, err)
+	}
+	return nil
+}
+
+// Get reads the state stored for block. It returns false if the block was never stored
+// or its file cannot be read or decoded.
+func (s *FileStore) Get(block uint64) (*engine.State, bool) {
+	data, err := os.ReadFile(s.path(block))
+	if err != nil {
+		return nil, false
+	}
+	state, err := s.decode(data)
+	if err != nil {
+		return nil, false
+	}
+	return state, true
+}
+
+// storedProtocolState mirrors engine.ProtocolState but keeps Data raw, so it can be
+// decoded according to its schema.
+type storedProtocolState struct {
+	engine.ProtocolState
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+type storedState struct {
+	engine.State
+	Protocols map[engine.ProtocolID]storedProtocolState `json:"protocols"`
+}
+
+func (s *FileStore) decode(data []byte) (*engine.State, error) {
+	var stored storedState
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+
+	state := stored.State
+	state.Protocols = make(map[engine.ProtocolID]engine.ProtocolState, len(stored.Protocols))
+	for id, protocol := range stored.Protocols {
+		result := protocol.ProtocolState
+		if len(protocol.Data) > 0 {
+			decoded, err := s.decoder(protocol.Schema, protocol.Data)
+			if err != nil {
+				return nil, fmt.Errorf("statestore: decoding protocol %s: %w", id, err)
+			}
+			result.Data = decoded
+		}
+		state.Protocols[id] = result
+	}
+	return &state, nil
+}
+
+func (s *FileStore) path(block uint64) string {
+	return filepath.Join(s.dir, strconv.FormatUint(block, 10)+".json")
+}
@@ -0,0 +1,132 @@
+package statestore
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/defistate/defistate-client-go/engine"
+	tokenregistry "github.com/defistate/defistate-client-go/protocols/tokenregistry"
+	uniswapv2 "github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	ethstateops "github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains/ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStore(t *testing.T) (*FileStore, string) {
+	stateOps, err := ethstateops.NewStateOps(slog.New(slog.NewJSONHandler(io.Discard, nil)), prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	dir := filepath.Join(t.TempDir(), "states")
+	store, err := NewFileStore(dir, stateOps.DecodeStateJSON)
+	require.NoError(t, err)
+	return store, dir
+}
+
+func makeState(block int64) *engine.State {
+	synced := uint64(block)
+	return &engine.State{
+		ChainID:   1,
+		Timestamp: 1_700_000_000,
+		Block: engine.BlockSummary{
+			Number: big.NewInt(block),
+			Hash:   common.HexToHash("0xabc"),
+		},
+		Protocols: map[engine.ProtocolID]engine.ProtocolState{
+			"tokens": {
+				Meta:              engine.ProtocolMeta{Name: "tokens"},
+				SyncedBlockNumber: &synced,
+				Schema:            tokenregistry.Schema,
+				Data:              []tokenregistry.Token{{ID: 1, Symbol: "WETH", Decimals: 18}},
+			},
+			"univ2": {
+				Meta:   engine.ProtocolMeta{Name: "uniswap-v2", Tags: []string{"dex"}},
+				Schema: uniswapv2.Schema,
+				Data: []uniswapv2.Pool{
+					{ID: 7, Token0: 1, Token1: 2, Reserve0: big.NewInt(1_000), Reserve1: big.NewInt(2_000), FeeBps: 30},
+				},
+			},
+			"broken": {
+				Meta:   engine.ProtocolMeta{Name: "broken"},
+				Schema: uniswapv2.Schema,
+				Error:  "out of sync",
+			},
+		},
+	}
+}
+
+func TestFileStore_RoundTrip(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	for block := int64(100); block < 103; block++ {
+		require.NoError(t, store.Put(uint64(block), makeState(block)))
+	}
+
+	for block := int64(100); block < 103; block++ {
+		stored, ok := store.Get(uint64(block))
+		require.True(t, ok, "block %d should be stored", block)
+
+		expected := makeState(block)
+		assert.Equal(t, expected.ChainID, stored.ChainID)
+		assert.Equal(t, 0, expected.Block.Number.Cmp(stored.Block.Number))
+		assert.Equal(t, expected.Block.Hash, stored.Block.Hash)
+		// Protocol data comes back in its typed form.
+		assert.Equal(t, expected.Protocols, stored.Protocols)
+	}
+}
+
+func TestFileStore_Get(t *testing.T) {
+	t.Run("Missing block", func(t *testing.T) {
+		store, _ := newTestStore(t)
+		_, ok := store.Get(1)
+		assert.False(t, ok)
+	})
+
+	t.Run("Overwrite keeps the latest state", func(t *testing.T) {
+		store, _ := newTestStore(t)
+		require.NoError(t, store.Put(5, makeState(5)))
+		replacement := makeState(5)
+		replacement.Timestamp = 42
+		require.NoError(t, store.Put(5, replacement))
+
+		stored, ok := store.Get(5)
+		require.True(t, ok)
+		assert.Equal(t, uint64(42), stored.Timestamp)
+	})
+
+	t.Run("Unknown schema", func(t *testing.T) {
+		store, dir := newTestStore(t)
+		data, err := json.Marshal(engine.State{
+			Block: engine.BlockSummary{Number: big.NewInt(9)},
+			Protocols: map[engine.ProtocolID]engine.ProtocolState{
+				"mystery": {Schema: "defistate/mystery@v1", Data: []int{1}},
+			},
+		})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "9.json"), data, 0o644))
+
+		_, ok := store.Get(9)
+		assert.False(t, ok)
+	})
+
+	t.Run("No temporary files are left behind", func(t *testing.T) {
+		store, dir := newTestStore(t)
+		require.NoError(t, store.Put(1, makeState(1)))
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "1.json", entries[0].Name())
+	})
+}
+
+func TestNewFileStore_RequiresDecoder(t *testing.T) {
+	_, err := NewFileStore(t.TempDir(), nil)
+	assert.Error(t, err)
+}
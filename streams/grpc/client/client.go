@@ -0,0 +1,224 @@
+// Package client consumes the aggregated state stream over gRPC. It offers the same
+// contract as the JSON-RPC client in streams/jsonrpc/client, State and Err channels fed
+// through the same patcher and decoder hooks, for deployments that standardize on gRPC.
+//
+// The service deliberately carries no protobuf messages: its messages are the JSON events
+// of the JSON-RPC stream, sent with the "defistate-json" content subtype (see CodecName).
+// A state is already encoded once, as JSON or with the binary codec of streams/wire, by
+// the encoder both transports share; wrapping it in a generated message would encode it
+// again for no gain, and would tie the payload schemas to a .proto that the versioned
+// protocol schemas do not need. Clients in other languages register a codec under
+// CodecName that passes the JSON through.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/defistate/defistate-client-go/engine"
+	jsonrpcclient "github.com/defistate/defistate-client-go/streams/jsonrpc/client"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Config holds the configuration for the client. The hooks are those of the JSON-RPC
+// client, so a chain's StateOps plugs into either transport.
+type Config struct {
+	// Target is the gRPC target of the server, e.g. "dns:///state.example.com:443".
+	Target           string
+	Logger           jsonrpcclient.Logger
+	BufferSize       uint
+	StatePatcher     jsonrpcclient.StatePatcherFunc
+	StateDecoder     jsonrpcclient.DecoderFunc
+	StateDiffDecoder jsonrpcclient.DecoderFunc
+
+	// Codec is the payload encoding requested from the server. Empty means JSON.
+	// CodecBinary requires StateBinaryDecoder and StateDiffBinaryDecoder.
+	Codec                  jsonrpcclient.Codec
+	StateBinaryDecoder     jsonrpcclient.BinaryDecoderFunc
+	StateDiffBinaryDecoder jsonrpcclient.BinaryDecoderFunc
+
+	// DialOptions are passed to grpc.NewClient. Nil means a plaintext connection.
+	DialOptions []grpc.DialOption
+
+	// Reconnect controls how a dropped stream is re-established; each new stream opens
+	// with a full state. The zero value retries forever with the default backoff.
+	Reconnect jsonrpcclient.ReconnectPolicy
+
+	// Filter, if set, is applied to every state before it is emitted on State(). Diffs
+	// are still applied to the unfiltered state.
+	Filter jsonrpcclient.StateFilterFunc
+
+	// Registry, if set, receives the stream health metrics (see jsonrpcclient.Metrics).
+	// Nil records none.
+	Registry prometheus.Registerer
+
+	// Concurrency is the number of workers decoding the protocols of each event in
+	// parallel, as jsonrpcclient.Config.Concurrency.
+	Concurrency int
+}
+
+// validate checks if the configuration is valid. Every setting but Target is checked as
+// the JSON-RPC client checks it.
+func (c *Config) validate() error {
+	if c.Target == "" {
+		return errors.New("config: Target is required")
+	}
+	return jsonrpcclient.StreamSettings{
+		Logger:                 c.Logger,
+		BufferSize:             c.BufferSize,
+		StatePatcher:           c.StatePatcher,
+		StateDecoder:           c.StateDecoder,
+		StateDiffDecoder:       c.StateDiffDecoder,
+		Codec:                  c.Codec,
+		StateBinaryDecoder:     c.StateBinaryDecoder,
+		StateDiffBinaryDecoder: c.StateDiffBinaryDecoder,
+		Reconnect:              c.Reconnect,
+		Concurrency:            c.Concurrency,
+	}.Validate()
+}
+
+// errGap ends a stream that skipped blocks, so the next one resyncs from a full state.
+var errGap = errors.New("stream skipped blocks")
+
+// Client streams states from a gRPC server.
+type Client struct {
+	processor *jsonrpcclient.StreamProcessor
+	errCh     chan error
+	logger    jsonrpcclient.Logger
+	conn      *grpc.ClientConn
+	codec     jsonrpcclient.Codec
+	reconnect jsonrpcclient.ReconnectPolicy
+}
+
+// NewClient creates a client and starts streaming until ctx is canceled.
+func NewClient(ctx context.Context, cfg Config) (*Client, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	processor := jsonrpcclient.NewStreamProcessor(
+		cfg.Logger,
+		cfg.BufferSize,
+		cfg.StatePatcher,
+		cfg.StateDecoder,
+		cfg.StateDiffDecoder,
+	)
+	processor.SetBinaryDecoders(cfg.StateBinaryDecoder, cfg.StateDiffBinaryDecoder)
+	processor.SetFilter(cfg.Filter)
+	processor.SetConcurrency(cfg.Concurrency)
+	if cfg.Registry != nil {
+		processor.SetMetrics(jsonrpcclient.NewMetrics(cfg.Registry))
+	}
+
+	opts := cfg.DialOptions
+	if opts == nil {
+		opts = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+	// grpc.NewClient connects lazily, so an unreachable server surfaces on the stream.
+	conn, err := grpc.NewClient(cfg.Target, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gRPC client: %w", err)
+	}
+
+	client := &Client{
+		processor: processor,
+		errCh:     make(chan error, 1),
+		logger:    cfg.Logger,
+		conn:      conn,
+		codec:     cfg.Codec,
+		reconnect: cfg.Reconnect,
+	}
+	go client.run(ctx)
+	return client, nil
+}
+
+// State delegates to the processor's state channel.
+func (c *Client) State() <-chan *engine.State {
+	return c.processor.State()
+}
+
+// Err returns a read-only channel for receiving fatal (unrecoverable) errors, such as
+// running out of reconnection attempts under Config.Reconnect.
+func (c *Client) Err() <-chan error {
+	return c.errCh
+}
+
+// run subscribes until ctx is canceled or the reconnection attempts run out.
+func (c *Client) run(ctx context.Context) {
+	defer close(c.errCh)
+	defer c.conn.Close()
+
+	// failures counts the consecutive attempts that did not receive an event.
+	failures := 0
+	for {
+		err := c.subscribeAndProcess(ctx, func() { failures = 0 })
+		if ctx.Err() != nil {
+			c.logger.Info("Client context canceled, shutting down.")
+			return
+		}
+		if errors.Is(err, errGap) {
+			c.logger.Warn("Stream skipped blocks; resubscribing for a full state.")
+			c.processor.Metrics().ObserveReconnect()
+			continue
+		}
+
+		failures++
+		if c.reconnect.MaxRetries > 0 && failures > c.reconnect.MaxRetries {
+			c.logger.Error("Subscription failed, giving up", "error", err, "attempts", failures)
+			c.errCh <- fmt.Errorf("stream lost after %d reconnection attempts: %w", c.reconnect.MaxRetries, err)
+			return
+		}
+		delay := c.reconnect.Delay(failures)
+		c.logger.Error("Subscription failed, will retry...", "error", err, "delay", delay)
+		select {
+		case <-time.After(delay):
+			c.processor.Metrics().ObserveReconnect()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// subscribeAndProcess feeds one stream to the processor until it fails. received is
+// called once the stream delivered its first event.
+func (c *Client) subscribeAndProcess(ctx context.Context, received func()) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.conn.NewStream(ctx, &subscribeStreamDesc, SubscribeMethod, grpc.CallContentSubtype(CodecName))
+	if err != nil {
+		return fmt.Errorf("failed to subscribe: %w", err)
+	}
+	if err := stream.SendMsg(&SubscribeRequest{Codec: c.codec}); err != nil {
+		return fmt.Errorf("failed to subscribe: %w", err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		return fmt.Errorf("failed to subscribe: %w", err)
+	}
+
+	for first := true; ; first = false {
+		var rawData json.RawMessage
+		if err := stream.RecvMsg(&rawData); err != nil {
+			if errors.Is(err, io.EOF) {
+				return errors.New("stream closed by server")
+			}
+			return err
+		}
+		if first {
+			received()
+		}
+		if err := c.processor.ProcessMessage(rawData); err != nil {
+			c.logger.Error("Error processing message", "error", err)
+			continue
+		}
+		if _, ok := c.processor.PendingGap(); ok {
+			return errGap
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	differ "github.com/defistate/defistate-client-go/differ"
+	"github.com/defistate/defistate-client-go/engine"
+	jsonrpcclient "github.com/defistate/defistate-client-go/streams/jsonrpc/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// mockServer sends the events of the n-th subscription from subscriptions, then holds
+// the stream open.
+type mockServer struct {
+	subscriptions [][]*jsonrpcclient.SubscriptionEvent
+	subscribed    atomic.Int32
+	codec         atomic.Value
+}
+
+func (s *mockServer) Subscribe(req *SubscribeRequest, stream StateStreamSubscribeServer) error {
+	s.codec.Store(req.Codec)
+	n := int(s.subscribed.Add(1)) - 1
+	if n < len(s.subscriptions) {
+		for _, event := range s.subscriptions[n] {
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		}
+	}
+	<-stream.Context().Done()
+	return nil
+}
+
+func startServer(t *testing.T, srv StateStreamServer) grpc.DialOption {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	RegisterStateStreamServer(server, srv)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	return grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	})
+}
+
+func fullEvent(t *testing.T, block int64) *jsonrpcclient.SubscriptionEvent {
+	payload, err := json.Marshal(engine.State{
+		ChainID:   1,
+		Block:     engine.BlockSummary{Number: big.NewInt(block)},
+		Protocols: map[engine.ProtocolID]engine.ProtocolState{},
+	})
+	require.NoError(t, err)
+	return &jsonrpcclient.SubscriptionEvent{Type: "full", Payload: payload}
+}
+
+func diffEvent(t *testing.T, from, to int64) *jsonrpcclient.SubscriptionEvent {
+	payload, err := json.Marshal(differ.StateDiff{
+		FromBlock: uint64(from),
+		ToBlock:   engine.BlockSummary{Number: big.NewInt(to)},
+		Protocols: map[engine.ProtocolID]differ.ProtocolDiff{},
+	})
+	require.NoError(t, err)
+	return &jsonrpcclient.SubscriptionEvent{Type: "diff", Payload: payload}
+}
+
+func testConfig(dialer grpc.DialOption) Config {
+	decoder := func(schema engine.ProtocolSchema, data json.RawMessage) (any, error) { return nil, nil }
+	return Config{
+		Target:     "passthrough:///bufnet",
+		Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+		BufferSize: 10,
+		StatePatcher: func(prev *engine.State, diff *differ.StateDiff) (*engine.State, error) {
+			return &engine.State{ChainID: prev.ChainID, Block: diff.ToBlock, Protocols: prev.Protocols}, nil
+		},
+		StateDecoder:     decoder,
+		StateDiffDecoder: decoder,
+		DialOptions:      []grpc.DialOption{dialer, grpc.WithTransportCredentials(insecure.NewCredentials())},
+		Reconnect:        jsonrpcclient.ReconnectPolicy{InitialBackoff: 10 * time.Millisecond},
+	}
+}
+
+func nextBlock(t *testing.T, client *Client) int64 {
+	t.Helper()
+	select {
+	case state := <-client.State():
+		return state.Block.Number.Int64()
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for state")
+		return 0
+	}
+}
+
+func TestClient_StreamsAndResyncsAfterGap(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srv := &mockServer{subscriptions: [][]*jsonrpcclient.SubscriptionEvent{
+		{fullEvent(t, 100), diffEvent(t, 100, 101), diffEvent(t, 103, 104)},
+		{fullEvent(t, 104), diffEvent(t, 104, 105)},
+	}}
+	client, err := NewClient(ctx, testConfig(startServer(t, srv)))
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(100), nextBlock(t, client))
+	assert.Equal(t, int64(101), nextBlock(t, client))
+	// The diff from 103 skips a block, so the client resubscribes for a full state.
+	assert.Equal(t, int64(104), nextBlock(t, client))
+	assert.Equal(t, int64(105), nextBlock(t, client))
+	assert.Equal(t, int32(2), srv.subscribed.Load())
+}
+
+func TestClient_GivesUpAfterMaxRetries(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	listener := bufconn.Listen(1 << 20)
+	listener.Close()
+	cfg := testConfig(grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	}))
+	cfg.Reconnect.MaxRetries = 2
+
+	client, err := NewClient(ctx, cfg)
+	require.NoError(t, err)
+
+	select {
+	case err := <-client.Err():
+		assert.ErrorContains(t, err, "after 2 reconnection attempts")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for the client to give up")
+	}
+}
+
+func TestClient_RequestsCodec(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	state := &engine.State{ChainID: 1, Block: engine.BlockSummary{Number: big.NewInt(7)}}
+	event, err := jsonrpcclient.EncodeBinaryStateEvent(state)
+	require.NoError(t, err)
+	srv := &mockServer{subscriptions: [][]*jsonrpcclient.SubscriptionEvent{{event}}}
+
+	cfg := testConfig(startServer(t, srv))
+	cfg.Codec = jsonrpcclient.CodecBinary
+	binaryDecoder := func(schema engine.ProtocolSchema, data []byte) (any, error) { return nil, nil }
+	cfg.StateBinaryDecoder, cfg.StateDiffBinaryDecoder = binaryDecoder, binaryDecoder
+
+	client, err := NewClient(ctx, cfg)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(7), nextBlock(t, client))
+	assert.Equal(t, jsonrpcclient.CodecBinary, srv.codec.Load())
+}
+
+func TestConfig_Validate(t *testing.T) {
+	cfg := testConfig(nil)
+	require.NoError(t, cfg.validate())
+
+	cfg.Target = ""
+	assert.Error(t, cfg.validate())
+
+	cfg = testConfig(nil)
+	cfg.Codec = jsonrpcclient.CodecBinary
+	assert.Error(t, cfg.validate())
+
+	// The settings shared with the JSON-RPC client are checked as it checks them.
+	cfg = testConfig(nil)
+	cfg.Reconnect.Jitter = 1.5
+	assert.Error(t, cfg.validate())
+}
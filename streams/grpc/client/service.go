@@ -0,0 +1,99 @@
+package client
+
+import (
+	"encoding/json"
+
+	jsonrpcclient "github.com/defistate/defistate-client-go/streams/jsonrpc/client"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+const (
+	// ServiceName is the gRPC service serving the state stream.
+	ServiceName = "defistate.StateStream"
+	// SubscribeMethod is the full name of the server-streaming RPC: the client sends one
+	// SubscribeRequest and receives a stream of subscription events.
+	SubscribeMethod = "/" + ServiceName + "/Subscribe"
+
+	// CodecName is the content subtype of the stream's messages. They are JSON, the same
+	// events the JSON-RPC stream sends, so servers can share one encoder for both
+	// transports and no protobuf code has to be generated (see the package doc). The
+	// codec is registered globally under this name, so it never replaces the default
+	// "proto" codec of other services on the same connection or server.
+	CodecName = "defistate-json"
+)
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// jsonCodec marshals messages as JSON, in place of the protobuf codec gRPC defaults to.
+// A *json.RawMessage is passed through as is.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return CodecName
+}
+
+// SubscribeRequest opens a state stream.
+type SubscribeRequest struct {
+	// Codec is the payload encoding requested, as in the JSON-RPC stream. Empty means JSON.
+	Codec jsonrpcclient.Codec `json:"codec,omitempty"`
+}
+
+// StateStreamServer is the server side of the state stream.
+type StateStreamServer interface {
+	// Subscribe streams events to stream until the client goes away. The first event
+	// must be a full state.
+	Subscribe(req *SubscribeRequest, stream StateStreamSubscribeServer) error
+}
+
+// StateStreamSubscribeServer sends the events of a subscription.
+type StateStreamSubscribeServer interface {
+	Send(event *jsonrpcclient.SubscriptionEvent) error
+	grpc.ServerStream
+}
+
+// RegisterStateStreamServer registers srv on s under ServiceName.
+func RegisterStateStreamServer(s grpc.ServiceRegistrar, srv StateStreamServer) {
+	s.RegisterService(&serviceDesc, srv)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*StateStreamServer)(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Subscribe",
+		Handler:       subscribeHandler,
+		ServerStreams: true,
+	}},
+}
+
+var subscribeStreamDesc = grpc.StreamDesc{
+	StreamName:    "Subscribe",
+	ServerStreams: true,
+}
+
+func subscribeHandler(srv any, stream grpc.ServerStream) error {
+	req := new(SubscribeRequest)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(StateStreamServer).Subscribe(req, &subscribeServer{stream})
+}
+
+type subscribeServer struct {
+	grpc.ServerStream
+}
+
+func (s *subscribeServer) Send(event *jsonrpcclient.SubscriptionEvent) error {
+	return s.ServerStream.SendMsg(event)
+}
@@ -5,11 +5,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	differ "github.com/defistate/defistate-client-go/differ"
 	"github.com/defistate/defistate-client-go/engine"
+	"github.com/defistate/defistate-client-go/streams/wire"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // Constants for reconnection logic
@@ -20,6 +26,9 @@ const (
 	// RpcNamespace is the namespace under which the streamer is registered.
 	RpcNamespace                  = "defi"
 	StateStreamSubscriptionMethod = "subscribeStateStream"
+	// StateStreamReplayMethod returns the events needed to rebuild every block after a
+	// given block, up to the server's head: a full state followed by diffs.
+	StateStreamReplayMethod = "replayStateStream"
 )
 
 // Logger defines a standard interface for structured, leveled logging.
@@ -36,6 +45,11 @@ type StatePatcherFunc func(prevState *engine.State, diff *differ.StateDiff) (new
 
 type DecoderFunc func(schema engine.ProtocolSchema, data json.RawMessage) (any, error)
 
+// StateFilterFunc returns the part of a state the consumer wants, e.g. without scam tokens
+// or dust pools (see statefilter). It must not modify state, which the client still
+// patches, and may share unchanged data with it.
+type StateFilterFunc func(state *engine.State) *engine.State
+
 // Config holds the configuration for the client.
 type Config struct {
 	URL              string
@@ -44,6 +58,109 @@ type Config struct {
 	StatePatcher     StatePatcherFunc
 	StateDecoder     DecoderFunc
 	StateDiffDecoder DecoderFunc
+
+	// Codec is the payload encoding requested from the server. Empty means CodecJSON.
+	// CodecBinary requires StateBinaryDecoder and StateDiffBinaryDecoder. Each event
+	// names its own encoding, so the client decodes JSON events either way.
+	Codec                  Codec
+	StateBinaryDecoder     BinaryDecoderFunc
+	StateDiffBinaryDecoder BinaryDecoderFunc
+
+	// ResumeFromBlock is the last block the consumer has already processed. When set,
+	// every (re)subscription first replays the blocks after it (or after the last
+	// emitted block, once one exists) and only then resumes the live stream, so no
+	// block is skipped and states are emitted in order.
+	ResumeFromBlock uint64
+
+	// Dial opens the RPC connections, for the subscription and for one-off calls (see
+	// Client.Call). Nil means rpc.DialContext.
+	Dial DialFunc
+	// MaxIdleConns is the number of connections kept open between one-off calls. Zero
+	// means DefaultMaxIdleConns; a negative value closes each connection after its call.
+	MaxIdleConns int
+
+	// Reconnect controls how a dropped stream is re-established. The zero value retries
+	// forever with the default backoff.
+	Reconnect ReconnectPolicy
+
+	// OnGap, if set, is called from the client's goroutine whenever the stream skips
+	// blocks, before the client resyncs from a fresh full state. Client.Gaps counts them.
+	OnGap func(Gap)
+
+	// InitialState, if set, is the state the client starts from, e.g. one restored from
+	// disk by statestore.Journal. It is emitted first on State(), diffs that continue from
+	// it are applied to it, and, unless ResumeFromBlock is set, the client resumes from its
+	// block. The server decides how to catch up; it may still open with a full state.
+	InitialState *engine.State
+	// Journal, if set, records every state before it is emitted, together with the diff
+	// it was patched from, so the stream can be persisted (see statestore.Journal).
+	Journal Journal
+
+	// Filter, if set, is applied to every state before it is emitted on State(). Diffs
+	// are still applied to, and the journal records, the unfiltered state.
+	Filter StateFilterFunc
+
+	// Registry, if set, receives the stream health metrics (see Metrics). Nil records
+	// none.
+	Registry prometheus.Registerer
+
+	// Concurrency is the number of workers decoding the protocols of each full state or
+	// diff in parallel. Zero or one decodes them on the client's goroutine. The decoders
+	// must then be safe for concurrent use, as the stateops ones are. Patching is
+	// parallelized by the StatePatcher itself (see stateops.ChainStateOps.SetConcurrency).
+	Concurrency int
+}
+
+// Journal records the states a client emits. Record is called from the client's goroutine;
+// diff is the diff state was patched from, or nil for a full state. A failed Record is
+// logged and does not hold back the state.
+type Journal interface {
+	Record(state *engine.State, diff *differ.StateDiff) error
+}
+
+// ReconnectPolicy controls how the client re-establishes a dropped or failed stream. Each
+// reconnection subscribes afresh, and a new subscription opens with a full state, so
+// patching resumes from a fresh snapshot without the caller rebuilding anything. With
+// ResumeFromBlock set, the blocks missed in between are replayed first.
+type ReconnectPolicy struct {
+	// MaxRetries is the number of consecutive failed attempts after which the client gives
+	// up and reports the last error on Err. Zero means retry forever.
+	MaxRetries int
+	// InitialBackoff is the delay before the first retry; it doubles after each failed
+	// attempt up to MaxBackoff. Zero values mean 1s and 30s.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Jitter spreads each delay randomly by up to this fraction of it, in [0, 1], so many
+	// clients dropped together do not reconnect in lockstep.
+	Jitter float64
+}
+
+// withDefaults fills in the zero durations.
+func (p ReconnectPolicy) withDefaults() ReconnectPolicy {
+	if p.InitialBackoff == 0 {
+		p.InitialBackoff = initialReconnectDelay
+	}
+	if p.MaxBackoff == 0 {
+		p.MaxBackoff = maxReconnectDelay
+	}
+	return p
+}
+
+// backoff returns the delay before retry number attempt (starting at 1), jittered by r,
+// a random number in [0, 1).
+func (p ReconnectPolicy) backoff(attempt int, r float64) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < attempt && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.MaxBackoff)
+	return delay + time.Duration(float64(delay)*p.Jitter*(2*r-1))
+}
+
+// Delay returns the jittered delay before retry number attempt (starting at 1), e.g. for
+// another transport that reconnects under the same policy.
+func (p ReconnectPolicy) Delay(attempt int) time.Duration {
+	return p.withDefaults().backoff(attempt, rand.Float64())
 }
 
 // validate checks if the configuration is valid.
@@ -51,29 +168,93 @@ func (c *Config) validate() error {
 	if c.URL == "" {
 		return errors.New("config: URL is required")
 	}
-	if c.BufferSize < 1 {
+	if err := c.streamSettings().Validate(); err != nil {
+		return err
+	}
+	if c.InitialState != nil && c.InitialState.Block.Number == nil {
+		return errors.New("config: InitialState must have a block number")
+	}
+	return nil
+}
+
+// streamSettings returns the part of the configuration every transport shares.
+func (c *Config) streamSettings() StreamSettings {
+	return StreamSettings{
+		Logger:                 c.Logger,
+		BufferSize:             c.BufferSize,
+		StatePatcher:           c.StatePatcher,
+		StateDecoder:           c.StateDecoder,
+		StateDiffDecoder:       c.StateDiffDecoder,
+		Codec:                  c.Codec,
+		StateBinaryDecoder:     c.StateBinaryDecoder,
+		StateDiffBinaryDecoder: c.StateDiffBinaryDecoder,
+		Reconnect:              c.Reconnect,
+		Concurrency:            c.Concurrency,
+	}
+}
+
+// StreamSettings are the settings of a stream that do not depend on its transport: how
+// events are decoded, patched and buffered, and how a dropped stream is re-established.
+// Other transports, such as streams/grpc/client, validate their configuration with it.
+type StreamSettings struct {
+	Logger                 Logger
+	BufferSize             uint
+	StatePatcher           StatePatcherFunc
+	StateDecoder           DecoderFunc
+	StateDiffDecoder       DecoderFunc
+	Codec                  Codec
+	StateBinaryDecoder     BinaryDecoderFunc
+	StateDiffBinaryDecoder BinaryDecoderFunc
+	Reconnect              ReconnectPolicy
+	Concurrency            int
+}
+
+// Validate checks the settings as Config does.
+func (s StreamSettings) Validate() error {
+	if s.BufferSize < 1 {
 		return errors.New("config: BufferSize must be greater than 0")
 	}
-	if c.Logger == nil {
+	if s.Logger == nil {
 		return errors.New("config: Logger is required")
 	}
-	if c.StatePatcher == nil {
+	if s.StatePatcher == nil {
 		return errors.New("config: StatePatcher is required")
 	}
-	if c.StateDecoder == nil {
+	if s.StateDecoder == nil {
 		return errors.New("config: StateDecoder is required")
 	}
-	if c.StateDiffDecoder == nil {
+	if s.StateDiffDecoder == nil {
 		return errors.New("config: StateDiffDecoder is required")
 	}
+	if s.Concurrency < 0 {
+		return errors.New("config: Concurrency must not be negative")
+	}
+	if s.Reconnect.MaxRetries < 0 || s.Reconnect.InitialBackoff < 0 || s.Reconnect.MaxBackoff < 0 {
+		return errors.New("config: Reconnect values must not be negative")
+	}
+	if s.Reconnect.Jitter < 0 || s.Reconnect.Jitter > 1 {
+		return errors.New("config: Reconnect.Jitter must be between 0 and 1")
+	}
+	switch s.Codec {
+	case "", CodecJSON:
+	case CodecBinary:
+		if s.StateBinaryDecoder == nil || s.StateDiffBinaryDecoder == nil {
+			return errors.New("config: CodecBinary requires StateBinaryDecoder and StateDiffBinaryDecoder")
+		}
+	default:
+		return fmt.Errorf("config: unknown Codec %q", s.Codec)
+	}
 	return nil
 }
 
 // SubscriptionEvent is the wrapper object received from the server.
 type SubscriptionEvent struct {
-	Type    string          `json:"type"`
-	Payload json.RawMessage `json:"payload"`
-	SentAt  int64           `json:"sentAt"`
+	Type string `json:"type"`
+	// Encoding is the codec of Payload; empty means CodecJSON. A CodecBinary payload is
+	// a JSON string holding the base64 of the binary encoding.
+	Encoding Codec           `json:"encoding,omitempty"`
+	Payload  json.RawMessage `json:"payload"`
+	SentAt   int64           `json:"sentAt"`
 }
 
 // -----------------------------------------------------------------------------
@@ -88,8 +269,26 @@ type StreamProcessor struct {
 	statePatcher     StatePatcherFunc
 	stateDecoder     DecoderFunc
 	stateDiffDecoder DecoderFunc
-	stateCh          chan *engine.State
-	logger           Logger
+	// binaryDecoder and binaryDiffDecoder decode CodecBinary events; without them such
+	// events are rejected.
+	binaryDecoder     BinaryDecoderFunc
+	binaryDiffDecoder BinaryDecoderFunc
+	stateCh           chan *engine.State
+	logger            Logger
+	gap               *Gap
+	journal           Journal
+	filter            StateFilterFunc
+	metrics           *Metrics
+	// concurrency is the number of workers decoding the protocols of an event.
+	concurrency int
+}
+
+// Gap describes blocks missed by the stream: a diff starting at FromBlock arrived while
+// the last known state was at LastBlock, so it cannot be applied.
+type Gap struct {
+	LastBlock uint64
+	FromBlock uint64
+	ToBlock   uint64
 }
 
 // NewStreamProcessor creates a pure logic processor without networking.
@@ -109,11 +308,51 @@ func NewStreamProcessor(
 	}
 }
 
+// SetBinaryDecoders sets the decoders of CodecBinary events. Without them such events
+// are rejected.
+func (sp *StreamProcessor) SetBinaryDecoders(stateDecoder, stateDiffDecoder BinaryDecoderFunc) {
+	sp.binaryDecoder = stateDecoder
+	sp.binaryDiffDecoder = stateDiffDecoder
+}
+
+// SetFilter sets the filter applied to states before they are emitted. Nil emits states
+// unfiltered.
+func (sp *StreamProcessor) SetFilter(filter StateFilterFunc) {
+	sp.filter = filter
+}
+
+// SetConcurrency sets the number of workers decoding the protocols of a full state or
+// diff in parallel. Zero or one decodes them on the processor's goroutine.
+func (sp *StreamProcessor) SetConcurrency(n int) {
+	sp.concurrency = n
+}
+
+// SetMetrics sets the metrics the processor records the stream's health in. Nil records
+// none.
+func (sp *StreamProcessor) SetMetrics(metrics *Metrics) {
+	sp.metrics = metrics
+}
+
+// Metrics returns the metrics set by SetMetrics, or nil.
+func (sp *StreamProcessor) Metrics() *Metrics {
+	return sp.metrics
+}
+
 // State returns a read-only channel for receiving new states.
 func (sp *StreamProcessor) State() <-chan *engine.State {
 	return sp.stateCh
 }
 
+// PendingGap returns the gap that stopped diffs from being applied, if any. Diffs are
+// discarded while a gap is pending; the next full state, or a diff that continues from
+// the last known state, clears it.
+func (sp *StreamProcessor) PendingGap() (Gap, bool) {
+	if sp.gap == nil {
+		return Gap{}, false
+	}
+	return *sp.gap, true
+}
+
 // ProcessMessage accepts a raw JSON message (from WS, File, or JS), processes it,
 // and updates the internal state.
 func (sp *StreamProcessor) ProcessMessage(rawData json.RawMessage) error {
@@ -124,6 +363,10 @@ func (sp *StreamProcessor) ProcessMessage(rawData json.RawMessage) error {
 		return fmt.Errorf("failed to unmarshal subscription event: %w", err)
 	}
 
+	switch event.Type {
+	case "full", "diff":
+		sp.metrics.observeReceived(event.Type)
+	}
 	switch event.Type {
 	case "full":
 		return sp.handleFullState(event, processingStart)
@@ -135,9 +378,28 @@ func (sp *StreamProcessor) ProcessMessage(rawData json.RawMessage) error {
 }
 
 func (sp *StreamProcessor) handleFullState(event SubscriptionEvent, start time.Time) error {
+	decode := sp.decodeJSONState
+	if event.Encoding == CodecBinary {
+		decode = sp.decodeBinaryState
+	}
+	state, err := decode(event.Payload)
+	if err != nil {
+		return err
+	}
+
+	processingDur := time.Since(start)
+	sp.logMetrics(state, processingDur, event.SentAt, "full")
+
+	sp.storeState(state)
+	sp.record(state, nil)
+	sp.emit(state)
+	return nil
+}
+
+func (sp *StreamProcessor) decodeJSONState(payload json.RawMessage) (*engine.State, error) {
 	var cState clientState
-	if err := json.Unmarshal(event.Payload, &cState); err != nil {
-		return fmt.Errorf("failed to unmarshal full state payload: %w", err)
+	if err := json.Unmarshal(payload, &cState); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal full state payload: %w", err)
 	}
 
 	// init state
@@ -148,37 +410,138 @@ func (sp *StreamProcessor) handleFullState(event SubscriptionEvent, start time.T
 		Protocols: map[engine.ProtocolID]engine.ProtocolState{},
 	}
 
-	for pID, protocolState := range cState.Protocols {
-		typedData, err := sp.stateDecoder(protocolState.Schema, protocolState.Data)
+	typedData, err := decodeProtocols(cState.Protocols, sp.concurrency, func(pID engine.ProtocolID, protocolState clientProtocolState) (any, error) {
+		decodeStart := time.Now()
+		data, err := sp.stateDecoder(protocolState.Schema, protocolState.Data)
+		sp.metrics.observeDecode(protocolState.Schema, decodeStart)
 		if err != nil {
-			return fmt.Errorf("failed to decode state for protocol %s: %w", pID, err)
+			return nil, fmt.Errorf("failed to decode state for protocol %s: %w", pID, err)
 		}
+		return data, nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
+	for pID, protocolState := range cState.Protocols {
 		state.Protocols[pID] = engine.ProtocolState{
 			Meta:              protocolState.Meta,
 			SyncedBlockNumber: protocolState.SyncedBlockNumber,
 			Schema:            protocolState.Schema,
-			Data:              typedData,
+			Data:              typedData[pID],
 			Error:             protocolState.Error,
 		}
 	}
+	return &state, nil
+}
 
-	processingDur := time.Since(start)
-	sp.logMetrics(&state, processingDur, event.SentAt, "full")
+func (sp *StreamProcessor) decodeBinaryState(payload json.RawMessage) (*engine.State, error) {
+	if sp.binaryDecoder == nil {
+		return nil, errors.New("received a binary full state without a binary decoder")
+	}
+	bState, err := decodeBinaryPayload[*wire.State](payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode binary full state payload: %w", err)
+	}
 
-	sp.storeState(&state)
-	sp.stateCh <- &state
-	return nil
+	state := engine.State{
+		ChainID:   bState.ChainID,
+		Timestamp: bState.Timestamp,
+		Block:     bState.Block,
+		Protocols: make(map[engine.ProtocolID]engine.ProtocolState, len(bState.Protocols)),
+	}
+	typedData, err := decodeProtocols(bState.Protocols, sp.concurrency, func(pID engine.ProtocolID, protocolState wire.ProtocolData) (any, error) {
+		if len(protocolState.Data) == 0 {
+			return nil, nil
+		}
+		decodeStart := time.Now()
+		data, err := sp.binaryDecoder(protocolState.Schema, protocolState.Data)
+		sp.metrics.observeDecode(protocolState.Schema, decodeStart)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode state for protocol %s: %w", pID, err)
+		}
+		return data, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for pID, protocolState := range bState.Protocols {
+		state.Protocols[pID] = engine.ProtocolState{
+			Meta:              protocolState.Meta,
+			SyncedBlockNumber: protocolState.SyncedBlockNumber,
+			Schema:            protocolState.Schema,
+			Data:              typedData[pID],
+			Error:             protocolState.Error,
+		}
+	}
+	return &state, nil
 }
 
 func (sp *StreamProcessor) handleDiff(event SubscriptionEvent, start time.Time) error {
-	var cDiff clientStateDiff
-	if err := json.Unmarshal(event.Payload, &cDiff); err != nil {
-		return fmt.Errorf("failed to unmarshal diff payload: %w", err)
+	decode := sp.decodeJSONDiff
+	if event.Encoding == CodecBinary {
+		decode = sp.decodeBinaryDiff
+	}
+	diff, err := decode(event.Payload)
+	if err != nil {
+		return err
 	}
 
 	if sp.lastState == nil {
-		return fmt.Errorf("received diff before full state; from_block: %d, to_block: %d", cDiff.FromBlock, cDiff.ToBlock.Number)
+		return fmt.Errorf("received diff before full state; from_block: %d, to_block: %d", diff.FromBlock, diff.ToBlock.Number)
+	}
+
+	lastBlockNum := sp.lastState.Block.Number.Uint64()
+	if diff.FromBlock > lastBlockNum {
+		if sp.gap == nil {
+			sp.gap = &Gap{LastBlock: lastBlockNum, FromBlock: diff.FromBlock}
+			sp.metrics.observeGap()
+			if diff.ToBlock.Number != nil {
+				sp.gap.ToBlock = diff.ToBlock.Number.Uint64()
+			}
+			sp.logger.Warn(
+				"Missed blocks in the stream; discarding diffs until resynced.",
+				"last_known_block", lastBlockNum,
+				"diff_from_block", diff.FromBlock,
+				"diff_to_block", diff.ToBlock.Number,
+			)
+		}
+		return nil // Non-fatal; the owner resyncs (see PendingGap)
+	}
+	if diff.FromBlock != lastBlockNum {
+		sp.logger.Warn(
+			"Received out-of-order diff; state may be out of sync. Discarding.",
+			"last_known_block", lastBlockNum,
+			"diff_from_block", diff.FromBlock,
+			"diff_to_block", diff.ToBlock.Number,
+		)
+		return nil // Non-fatal, just ignored
+	}
+
+	patchStart := time.Now()
+	newState, err := sp.statePatcher(sp.lastState, diff)
+	if err != nil {
+		// lastState is only replaced on success, so a failed patch leaves the
+		// previously emitted state intact for the next diff or full state.
+		return fmt.Errorf("failed to patch state: %w", err)
+	}
+	sp.metrics.observePatch(patchStart)
+
+	newState.Timestamp = diff.Timestamp
+
+	processingDur := time.Since(start)
+	sp.logMetrics(newState, processingDur, event.SentAt, "diff")
+
+	sp.storeState(newState)
+	sp.record(newState, diff)
+	sp.emit(newState)
+	return nil
+}
+
+func (sp *StreamProcessor) decodeJSONDiff(payload json.RawMessage) (*differ.StateDiff, error) {
+	var cDiff clientStateDiff
+	if err := json.Unmarshal(payload, &cDiff); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal diff payload: %w", err)
 	}
 
 	diff := differ.StateDiff{
@@ -188,49 +551,158 @@ func (sp *StreamProcessor) handleDiff(event SubscriptionEvent, start time.Time)
 		Protocols: make(map[engine.ProtocolID]differ.ProtocolDiff),
 	}
 
-	for pID, protocolDiff := range cDiff.Protocols {
-		typedData, err := sp.stateDiffDecoder(protocolDiff.Schema, protocolDiff.Data)
+	typedData, err := decodeProtocols(cDiff.Protocols, sp.concurrency, func(pID engine.ProtocolID, protocolDiff clientProtocolStateDiff) (any, error) {
+		decodeStart := time.Now()
+		data, err := sp.stateDiffDecoder(protocolDiff.Schema, protocolDiff.Data)
+		sp.metrics.observeDecode(protocolDiff.Schema, decodeStart)
 		if err != nil {
-			return fmt.Errorf("failed to decode diff data for protocol %s: %w", pID, err)
+			return nil, fmt.Errorf("failed to decode diff data for protocol %s: %w", pID, err)
 		}
+		return data, nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
+	for pID, protocolDiff := range cDiff.Protocols {
 		diff.Protocols[pID] = differ.ProtocolDiff{
 			Meta:              protocolDiff.Meta,
 			SyncedBlockNumber: protocolDiff.SyncedBlockNumber,
 			Schema:            protocolDiff.Schema,
-			Data:              typedData,
+			Data:              typedData[pID],
 			Error:             protocolDiff.Error,
 		}
 	}
+	return &diff, nil
+}
 
-	lastBlockNum := sp.lastState.Block.Number.Uint64()
-	if diff.FromBlock != lastBlockNum {
-		sp.logger.Warn(
-			"Received out-of-order diff; state may be out of sync. Discarding.",
-			"last_known_block", lastBlockNum,
-			"diff_from_block", diff.FromBlock,
-			"diff_to_block", diff.ToBlock.Number,
-		)
-		return nil // Non-fatal, just ignored
+func (sp *StreamProcessor) decodeBinaryDiff(payload json.RawMessage) (*differ.StateDiff, error) {
+	if sp.binaryDiffDecoder == nil {
+		return nil, errors.New("received a binary diff without a binary decoder")
+	}
+	bDiff, err := decodeBinaryPayload[*wire.StateDiff](payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode binary diff payload: %w", err)
 	}
 
-	newState, err := sp.statePatcher(sp.lastState, &diff)
+	diff := differ.StateDiff{
+		FromBlock: bDiff.FromBlock,
+		ToBlock:   bDiff.ToBlock,
+		Timestamp: bDiff.Timestamp,
+		Protocols: make(map[engine.ProtocolID]differ.ProtocolDiff, len(bDiff.Protocols)),
+	}
+	typedData, err := decodeProtocols(bDiff.Protocols, sp.concurrency, func(pID engine.ProtocolID, protocolDiff wire.ProtocolData) (any, error) {
+		if len(protocolDiff.Data) == 0 {
+			return nil, nil
+		}
+		decodeStart := time.Now()
+		data, err := sp.binaryDiffDecoder(protocolDiff.Schema, protocolDiff.Data)
+		sp.metrics.observeDecode(protocolDiff.Schema, decodeStart)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode diff data for protocol %s: %w", pID, err)
+		}
+		return data, nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to patch state: %w", err)
+		return nil, err
 	}
+	for pID, protocolDiff := range bDiff.Protocols {
+		diff.Protocols[pID] = differ.ProtocolDiff{
+			Meta:              protocolDiff.Meta,
+			SyncedBlockNumber: protocolDiff.SyncedBlockNumber,
+			Schema:            protocolDiff.Schema,
+			Data:              typedData[pID],
+			Error:             protocolDiff.Error,
+		}
+	}
+	return &diff, nil
+}
 
-	newState.Timestamp = diff.Timestamp
+// decodeProtocols decodes the data of every protocol with decode, on up to concurrency
+// workers; zero or one decodes them on the calling goroutine. Each protocol is decoded
+// once, so decode must only be safe for concurrent use across protocols. The error
+// returned is that of the first failing protocol in ID order.
+func decodeProtocols[P any](
+	protocols map[engine.ProtocolID]P,
+	concurrency int,
+	decode func(pID engine.ProtocolID, protocol P) (any, error),
+) (map[engine.ProtocolID]any, error) {
+	pIDs := make([]engine.ProtocolID, 0, len(protocols))
+	for pID := range protocols {
+		pIDs = append(pIDs, pID)
+	}
+	slices.Sort(pIDs)
 
-	processingDur := time.Since(start)
-	sp.logMetrics(newState, processingDur, event.SentAt, "diff")
+	results := make([]any, len(pIDs))
+	errs := make([]error, len(pIDs))
+	decodeAt := func(i int) {
+		results[i], errs[i] = decode(pIDs[i], protocols[pIDs[i]])
+	}
 
-	sp.storeState(newState)
-	sp.stateCh <- newState
-	return nil
+	if concurrency <= 1 || len(pIDs) <= 1 {
+		for i := range pIDs {
+			if decodeAt(i); errs[i] != nil {
+				return nil, errs[i]
+			}
+		}
+	} else {
+		workers := concurrency
+		if workers > len(pIDs) {
+			workers = len(pIDs)
+		}
+		indices := make(chan int)
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range indices {
+					decodeAt(i)
+				}
+			}()
+		}
+		for i := range pIDs {
+			indices <- i
+		}
+		close(indices)
+		wg.Wait()
+
+		for _, err := range errs {
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	decoded := make(map[engine.ProtocolID]any, len(pIDs))
+	for i, pID := range pIDs {
+		decoded[pID] = results[i]
+	}
+	return decoded, nil
 }
 
 func (sp *StreamProcessor) storeState(state *engine.State) {
 	sp.lastState = state
+	sp.gap = nil
+}
+
+// record passes an emitted state to the journal, if any.
+func (sp *StreamProcessor) record(state *engine.State, diff *differ.StateDiff) {
+	if sp.journal == nil {
+		return
+	}
+	if err := sp.journal.Record(state, diff); err != nil {
+		sp.logger.Warn("Failed to record state in the journal", "block", state.Block.Number, "error", err)
+	}
+}
+
+// emit sends state, filtered if a filter is set, to the state channel.
+func (sp *StreamProcessor) emit(state *engine.State) {
+	if sp.filter != nil {
+		state = sp.filter(state)
+	}
+	sp.stateCh <- state
+	sp.metrics.observeBuffer(len(sp.stateCh), cap(sp.stateCh))
 }
 
 func (sp *StreamProcessor) logMetrics(state *engine.State, processingDur time.Duration, sentAt int64, stateType string) {
@@ -272,9 +744,19 @@ func (sp *StreamProcessor) logMetrics(state *engine.State, processingDur time.Du
 
 // Client manages the connection and uses StreamProcessor for logic.
 type Client struct {
-	processor *StreamProcessor
-	errCh     chan error
-	logger    Logger
+	processor       *StreamProcessor
+	errCh           chan error
+	logger          Logger
+	resumeFromBlock uint64
+	dial            DialFunc
+	pool            *connPool
+	reconnect       ReconnectPolicy
+	onGap           func(Gap)
+	codec           Codec
+	gaps            atomic.Uint64
+	// lastGap is the most recently reported gap, so a gap that outlives a reconnect is
+	// reported once.
+	lastGap Gap
 }
 
 // NewClient creates a new client with networking enabled.
@@ -290,14 +772,49 @@ func NewClient(ctx context.Context, cfg Config) (*Client, error) {
 		cfg.StateDecoder,
 		cfg.StateDiffDecoder,
 	)
+	processor.journal = cfg.Journal
+	processor.SetBinaryDecoders(cfg.StateBinaryDecoder, cfg.StateDiffBinaryDecoder)
+	processor.SetFilter(cfg.Filter)
+	processor.SetConcurrency(cfg.Concurrency)
+	if cfg.Registry != nil {
+		processor.SetMetrics(NewMetrics(cfg.Registry))
+	}
+
+	resumeFromBlock := cfg.ResumeFromBlock
+	if cfg.InitialState != nil {
+		processor.storeState(cfg.InitialState)
+		processor.emit(cfg.InitialState) // BufferSize is at least 1
+		if resumeFromBlock == 0 {
+			resumeFromBlock = cfg.InitialState.Block.Number.Uint64()
+		}
+	}
+
+	dial := cfg.Dial
+	if dial == nil {
+		dial = rpc.DialContext
+	}
+	maxIdle := cfg.MaxIdleConns
+	if maxIdle == 0 {
+		maxIdle = DefaultMaxIdleConns
+	}
 
 	client := &Client{
-		processor: processor,
-		errCh:     make(chan error, 1),
-		logger:    cfg.Logger,
+		processor:       processor,
+		codec:           cfg.Codec,
+		errCh:           make(chan error, 1),
+		logger:          cfg.Logger,
+		resumeFromBlock: resumeFromBlock,
+		dial:            dial,
+		pool:            newConnPool(cfg.URL, dial, maxIdle),
+		reconnect:       cfg.Reconnect.withDefaults(),
+		onGap:           cfg.OnGap,
 	}
 
 	go client.run(ctx, cfg.URL)
+	go func() {
+		<-ctx.Done()
+		client.pool.close()
+	}()
 	return client, nil
 }
 
@@ -306,17 +823,41 @@ func (c *Client) State() <-chan *engine.State {
 	return c.processor.State()
 }
 
-// Err returns a read-only channel for receiving fatal (unrecoverable) errors.
+// Err returns a read-only channel for receiving fatal (unrecoverable) errors, such as
+// running out of reconnection attempts under Config.Reconnect.
 func (c *Client) Err() <-chan error {
 	return c.errCh
 }
 
+// Gaps returns the number of times the stream skipped blocks and had to be resynced.
+func (c *Client) Gaps() uint64 {
+	return c.gaps.Load()
+}
+
 // run handles the networking lifecycle and feeds data to the processor.
 func (c *Client) run(ctx context.Context, url string) {
 	// Note: We do NOT close c.processor.stateCh here because the processor owns it,
 	// but the client owns the lifecycle. Ideally we close it when we strictly stop run.
 	defer close(c.errCh)
-	reconnectDelay := initialReconnectDelay
+	// failures counts the consecutive attempts that did not reach a working subscription.
+	failures := 0
+	retry := func(msg string, err error) bool {
+		failures++
+		if c.reconnect.MaxRetries > 0 && failures > c.reconnect.MaxRetries {
+			c.logger.Error(msg+", giving up", "error", err, "attempts", failures)
+			c.errCh <- fmt.Errorf("stream lost after %d reconnection attempts: %w", c.reconnect.MaxRetries, err)
+			return false
+		}
+		delay := c.reconnect.backoff(failures, rand.Float64())
+		c.logger.Error(msg+", will retry...", "error", err, "delay", delay)
+		select {
+		case <-time.After(delay):
+			c.processor.metrics.ObserveReconnect()
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
 
 	for {
 		if ctx.Err() != nil {
@@ -325,48 +866,75 @@ func (c *Client) run(ctx context.Context, url string) {
 		}
 
 		c.logger.Info("Attempting to connect to RPC server", "url", url)
-		rpcClient, err := rpc.DialContext(ctx, url)
+		rpcClient, err := c.dial(ctx, url)
 		if err != nil {
-			c.logger.Error("Failed to connect to RPC server, will retry...", "error", err, "delay", reconnectDelay)
-			time.Sleep(reconnectDelay)
-			reconnectDelay = min(reconnectDelay*2, maxReconnectDelay)
+			if !retry("Failed to connect to RPC server", err) {
+				return
+			}
 			continue
 		}
-
 		c.logger.Info("Successfully connected to RPC server.")
-		reconnectDelay = initialReconnectDelay
 
-		err = c.subscribeAndProcess(ctx, rpcClient)
+		err = c.subscribeAndProcess(ctx, rpcClient, func() { failures = 0 })
 		if err != nil {
 			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 				c.logger.Info("Context canceled, shutting down.")
 				return
 			}
-			c.logger.Error("Subscription failed, will reconnect...", "error", err, "delay", reconnectDelay)
-			time.Sleep(reconnectDelay)
-			reconnectDelay = min(reconnectDelay*2, maxReconnectDelay)
+			if !retry("Subscription failed", err) {
+				return
+			}
 		}
 	}
 }
 
-func (c *Client) subscribeAndProcess(ctx context.Context, rpcClient *rpc.Client) error {
+// subscribeAndProcess streams from rpcClient until the subscription fails. subscribed is
+// called once the subscription, and the replay if any, succeeded.
+func (c *Client) subscribeAndProcess(ctx context.Context, rpcClient *rpc.Client, subscribed func()) error {
 	defer rpcClient.Close()
 
 	rawCh := make(chan json.RawMessage)
-	sub, err := rpcClient.Subscribe(ctx, RpcNamespace, rawCh, StateStreamSubscriptionMethod)
+	// The JSON codec is requested by omitting the argument, which any server accepts.
+	args := []any{StateStreamSubscriptionMethod}
+	if c.codec == CodecBinary {
+		args = append(args, string(c.codec))
+	}
+	sub, err := rpcClient.Subscribe(ctx, RpcNamespace, rawCh, args...)
 	if err != nil {
 		return fmt.Errorf("failed to subscribe: %w", err)
 	}
 	defer sub.Unsubscribe()
 
+	// Replay after subscribing, so live events produced meanwhile are queued, not lost.
+	replayedThrough, err := c.replay(ctx, rpcClient)
+	if err != nil {
+		return err
+	}
+	subscribed()
+
 	c.logger.Info("Successfully subscribed. Waiting for data...")
 	for {
 		select {
 		case rawData := <-rawCh:
+			if replayedThrough > 0 {
+				// Skip live events already covered by the replay.
+				if block, ok := eventBlockNumber(rawData); ok && block <= replayedThrough {
+					continue
+				}
+				replayedThrough = 0
+			}
 			// Delegate logic to the processor
 			if err := c.processor.ProcessMessage(rawData); err != nil {
 				c.logger.Error("Error processing message", "error", err)
 			}
+			if gap, ok := c.processor.PendingGap(); ok {
+				through, err := c.resync(ctx, rpcClient, gap)
+				if err != nil {
+					// Reconnecting resyncs too: a new subscription opens with a full state.
+					return err
+				}
+				replayedThrough = through
+			}
 		case err := <-sub.Err():
 			return err
 		case <-ctx.Done():
@@ -376,6 +944,104 @@ func (c *Client) subscribeAndProcess(ctx context.Context, rpcClient *rpc.Client)
 	}
 }
 
+// replay catches up the blocks missed since the resume point and returns the last
+// replayed block, or 0 if nothing was replayed. It is a no-op unless ResumeFromBlock is set.
+func (c *Client) replay(ctx context.Context, rpcClient *rpc.Client) (uint64, error) {
+	if c.resumeFromBlock == 0 {
+		return 0, nil
+	}
+
+	fromBlock := c.resumeFromBlock
+	if last := c.processor.lastState; last != nil && last.Block.Number != nil {
+		fromBlock = last.Block.Number.Uint64()
+	}
+	return c.replayFrom(ctx, rpcClient, fromBlock)
+}
+
+// resync reports gap and rebuilds the state from a fresh full state served by the replay
+// method, returning the last replayed block. It fails if the gap is still open afterwards.
+func (c *Client) resync(ctx context.Context, rpcClient *rpc.Client, gap Gap) (uint64, error) {
+	if gap != c.lastGap {
+		c.lastGap = gap
+		c.gaps.Add(1)
+		if c.onGap != nil {
+			c.onGap(gap)
+		}
+	}
+
+	through, err := c.replayFrom(ctx, rpcClient, gap.LastBlock)
+	if err != nil {
+		return 0, fmt.Errorf("failed to resync after gap at block %d: %w", gap.LastBlock, err)
+	}
+	if _, ok := c.processor.PendingGap(); ok {
+		return 0, fmt.Errorf("resync from block %d did not close the gap", gap.LastBlock)
+	}
+	c.logger.Info("Resynced after missed blocks", "from_block", gap.LastBlock, "through_block", through)
+	return through, nil
+}
+
+// replayFrom processes the events the server replays after fromBlock and returns the last
+// replayed block, or 0 if nothing was replayed.
+func (c *Client) replayFrom(ctx context.Context, rpcClient *rpc.Client, fromBlock uint64) (uint64, error) {
+	var events []json.RawMessage
+	if err := rpcClient.CallContext(ctx, &events, RpcNamespace+"_"+StateStreamReplayMethod, fromBlock); err != nil {
+		return 0, fmt.Errorf("failed to replay from block %d: %w", fromBlock, err)
+	}
+	c.logger.Info("Replaying missed blocks", "from_block", fromBlock, "events", len(events))
+
+	var replayedThrough uint64
+	for _, rawData := range events {
+		if err := c.processor.ProcessMessage(rawData); err != nil {
+			return 0, fmt.Errorf("failed to process replayed event: %w", err)
+		}
+		if block, ok := eventBlockNumber(rawData); ok {
+			replayedThrough = block
+		}
+	}
+	return replayedThrough, nil
+}
+
+// eventBlockNumber extracts the block an event produces: the block of a full state,
+// or the target block of a diff.
+func eventBlockNumber(rawData json.RawMessage) (uint64, bool) {
+	var event SubscriptionEvent
+	if err := json.Unmarshal(rawData, &event); err != nil {
+		return 0, false
+	}
+
+	var payload struct {
+		Block   engine.BlockSummary `json:"block"`
+		ToBlock engine.BlockSummary `json:"toBlock"`
+	}
+	switch {
+	case event.Encoding != CodecBinary:
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			return 0, false
+		}
+	case event.Type == "diff":
+		diff, err := decodeBinaryPayload[*wire.StateDiff](event.Payload)
+		if err != nil {
+			return 0, false
+		}
+		payload.ToBlock = diff.ToBlock
+	default:
+		state, err := decodeBinaryPayload[*wire.State](event.Payload)
+		if err != nil {
+			return 0, false
+		}
+		payload.Block = state.Block
+	}
+
+	block := payload.Block.Number
+	if event.Type == "diff" {
+		block = payload.ToBlock.Number
+	}
+	if block == nil {
+		return 0, false
+	}
+	return block.Uint64(), true
+}
+
 func min(a, b time.Duration) time.Duration {
 	if a < b {
 		return a
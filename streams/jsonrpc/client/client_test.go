@@ -1,6 +1,7 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -8,12 +9,17 @@ import (
 	"log/slog"
 	"math/big"
 	"net/http"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	differ "github.com/defistate/defistate-client-go/differ"
 	"github.com/defistate/defistate-client-go/engine"
+	"github.com/defistate/defistate-client-go/protocols/tokenregistry"
+	"github.com/defistate/defistate-client-go/streams/wire"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -23,19 +29,30 @@ import (
 type MockStateStreamer struct {
 	events chan *SubscriptionEvent
 	t      *testing.T
+
+	// replay is served by ReplayStateStream; replayFrom records the requested blocks.
+	replay     []*SubscriptionEvent
+	replayFrom chan uint64
 }
 
 func SetupMockStateStreamer(ctx context.Context, t *testing.T, port int, events []*SubscriptionEvent) (<-chan error, error) {
+	errChan, _, err := SetupMockReplayingStateStreamer(ctx, t, port, events, nil)
+	return errChan, err
+}
+
+// SetupMockReplayingStateStreamer is like SetupMockStateStreamer, but also serves the given
+// replay events and reports the block each replay was requested from.
+func SetupMockReplayingStateStreamer(ctx context.Context, t *testing.T, port int, events, replay []*SubscriptionEvent) (<-chan error, <-chan uint64, error) {
 	eventChan := make(chan *SubscriptionEvent, len(events))
 	for _, e := range events {
 		eventChan <- e
 	}
 	close(eventChan)
 
-	api := &MockStateStreamer{events: eventChan, t: t}
+	api := &MockStateStreamer{events: eventChan, t: t, replay: replay, replayFrom: make(chan uint64, 10)}
 	server := rpc.NewServer()
 	if err := server.RegisterName("defi", api); err != nil {
-		return nil, fmt.Errorf("failed to register API: %v", err)
+		return nil, nil, fmt.Errorf("failed to register API: %v", err)
 	}
 
 	wsHandler := server.WebsocketHandler([]string{"*"})
@@ -55,7 +72,17 @@ func SetupMockStateStreamer(ctx context.Context, t *testing.T, port int, events
 		_ = httpServer.Shutdown(shutdownCtx)
 	}()
 
-	return errChan, nil
+	return errChan, api.replayFrom, nil
+}
+
+func (api *MockStateStreamer) ReplayStateStream(fromBlock uint64) ([]*SubscriptionEvent, error) {
+	api.replayFrom <- fromBlock
+	return api.replay, nil
+}
+
+// Echo is a one-off method for exercising Client.Call.
+func (api *MockStateStreamer) Echo(v uint64) uint64 {
+	return v
 }
 
 func (api *MockStateStreamer) SubscribeStateStream(ctx context.Context) (*rpc.Subscription, error) {
@@ -348,6 +375,362 @@ func TestClient_Reconnection(t *testing.T) {
 	}
 }
 
+func TestClient_ReconnectGivesUp(t *testing.T) {
+	const testPort = 9995 // nothing listens here
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client, err := NewClient(ctx, Config{
+		URL:              fmt.Sprintf("ws://localhost:%d", testPort),
+		Logger:           slog.New(slog.NewTextHandler(io.Discard, nil)),
+		BufferSize:       10,
+		StatePatcher:     noopStatePatcher,
+		StateDecoder:     mockDecoder,
+		StateDiffDecoder: mockDecoder,
+		Reconnect: ReconnectPolicy{
+			MaxRetries:     2,
+			InitialBackoff: 10 * time.Millisecond,
+			MaxBackoff:     20 * time.Millisecond,
+		},
+	})
+	require.NoError(t, err)
+
+	select {
+	case err, ok := <-client.Err():
+		require.True(t, ok, "Err closed without reporting the failure")
+		assert.ErrorContains(t, err, "after 2 reconnection attempts")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the client to give up")
+	}
+}
+
+func TestReconnectPolicy_Backoff(t *testing.T) {
+	p := ReconnectPolicy{}.withDefaults()
+	assert.Equal(t, time.Second, p.backoff(1, 0.5))
+	assert.Equal(t, 4*time.Second, p.backoff(3, 0.5))
+	assert.Equal(t, 30*time.Second, p.backoff(10, 0.5), "capped at MaxBackoff")
+
+	p = ReconnectPolicy{InitialBackoff: time.Second, MaxBackoff: time.Minute, Jitter: 0.5}
+	assert.Equal(t, 500*time.Millisecond, p.backoff(1, 0))
+	assert.Equal(t, 1500*time.Millisecond, p.backoff(1, 1))
+
+	_, err := NewClient(context.Background(), Config{
+		URL:              "ws://localhost:1",
+		StatePatcher:     noopStatePatcher,
+		StateDecoder:     mockDecoder,
+		StateDiffDecoder: mockDecoder,
+		Reconnect:        ReconnectPolicy{Jitter: 2},
+	})
+	assert.Error(t, err)
+}
+
+func TestStreamSettings_Validate(t *testing.T) {
+	valid := func() StreamSettings {
+		return StreamSettings{
+			Logger:           slog.New(slog.NewTextHandler(io.Discard, nil)),
+			BufferSize:       1,
+			StatePatcher:     noopStatePatcher,
+			StateDecoder:     mockDecoder,
+			StateDiffDecoder: mockDecoder,
+		}
+	}
+	require.NoError(t, valid().Validate())
+
+	for name, modify := range map[string]func(*StreamSettings){
+		"no buffer":               func(s *StreamSettings) { s.BufferSize = 0 },
+		"no logger":               func(s *StreamSettings) { s.Logger = nil },
+		"no patcher":              func(s *StreamSettings) { s.StatePatcher = nil },
+		"negative concurrency":    func(s *StreamSettings) { s.Concurrency = -1 },
+		"jitter above 1":          func(s *StreamSettings) { s.Reconnect.Jitter = 1.5 },
+		"binary without decoders": func(s *StreamSettings) { s.Codec = CodecBinary },
+		"unknown codec":           func(s *StreamSettings) { s.Codec = "xml" },
+	} {
+		settings := valid()
+		modify(&settings)
+		assert.Error(t, settings.Validate(), name)
+	}
+
+	// Config checks the same settings.
+	cfg := Config{URL: "ws://localhost", Logger: valid().Logger, BufferSize: 1, StatePatcher: noopStatePatcher, StateDecoder: mockDecoder, StateDiffDecoder: mockDecoder}
+	require.NoError(t, cfg.validate())
+	cfg.Reconnect.Jitter = 1.5
+	assert.Error(t, cfg.validate())
+}
+
+func TestClient_ResumeFromBlock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mustMarshal := func(v any) json.RawMessage {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		return data
+	}
+	full := func(block int64) *SubscriptionEvent {
+		return &SubscriptionEvent{Type: "full", Payload: mustMarshal(engine.State{
+			Block:     engine.BlockSummary{Number: big.NewInt(block)},
+			Protocols: map[engine.ProtocolID]engine.ProtocolState{},
+		})}
+	}
+	diff := func(from, to int64) *SubscriptionEvent {
+		return &SubscriptionEvent{Type: "diff", Payload: mustMarshal(map[string]any{
+			"fromBlock": from,
+			"toBlock":   engine.BlockSummary{Number: big.NewInt(to)},
+			"protocols": map[string]any{},
+		})}
+	}
+
+	// The consumer stored block 100. Blocks 101-103 were missed; the live stream
+	// starts at 102, overlapping the replay, and continues to 105.
+	replay := []*SubscriptionEvent{full(101), diff(101, 102), diff(102, 103)}
+	live := []*SubscriptionEvent{full(102), diff(102, 103), diff(103, 104), diff(104, 105)}
+	_, replayFrom, err := SetupMockReplayingStateStreamer(ctx, t, 9991, live, replay)
+	require.NoError(t, err)
+
+	statePatcher := func(prev *engine.State, d *differ.StateDiff) (*engine.State, error) {
+		return &engine.State{Block: d.ToBlock, Protocols: prev.Protocols}, nil
+	}
+
+	client, err := NewClient(ctx, Config{
+		URL:              "ws://localhost:9991",
+		Logger:           slog.New(slog.NewTextHandler(io.Discard, nil)),
+		BufferSize:       10,
+		StatePatcher:     statePatcher,
+		StateDecoder:     mockDecoder,
+		StateDiffDecoder: mockDecoder,
+		ResumeFromBlock:  100,
+	})
+	require.NoError(t, err)
+
+	select {
+	case from := <-replayFrom:
+		assert.Equal(t, uint64(100), from)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the replay request")
+	}
+
+	for _, expected := range []int64{101, 102, 103, 104, 105} {
+		select {
+		case view := <-client.State():
+			assert.Equal(t, expected, view.Block.Number.Int64())
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out waiting for block %d", expected)
+		}
+	}
+
+	select {
+	case view := <-client.State():
+		t.Fatalf("unexpected extra state for block %d", view.Block.Number.Int64())
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestClient_ResyncsAfterGap(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mustMarshal := func(v any) json.RawMessage {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		return data
+	}
+	full := func(block int64) *SubscriptionEvent {
+		return &SubscriptionEvent{Type: "full", Payload: mustMarshal(engine.State{
+			Block:     engine.BlockSummary{Number: big.NewInt(block)},
+			Protocols: map[engine.ProtocolID]engine.ProtocolState{},
+		})}
+	}
+	diff := func(from, to int64) *SubscriptionEvent {
+		return &SubscriptionEvent{Type: "diff", Payload: mustMarshal(map[string]any{
+			"fromBlock": from,
+			"toBlock":   engine.BlockSummary{Number: big.NewInt(to)},
+			"protocols": map[string]any{},
+		})}
+	}
+
+	// The live stream drops the 101 -> 102 diff; the resync rebuilds 101-103.
+	live := []*SubscriptionEvent{full(100), diff(100, 101), diff(102, 103), diff(103, 104), diff(104, 105)}
+	replay := []*SubscriptionEvent{full(101), diff(101, 102), diff(102, 103)}
+	_, replayFrom, err := SetupMockReplayingStateStreamer(ctx, t, 9996, live, replay)
+	require.NoError(t, err)
+
+	statePatcher := func(prev *engine.State, d *differ.StateDiff) (*engine.State, error) {
+		return &engine.State{Block: d.ToBlock, Protocols: prev.Protocols}, nil
+	}
+
+	gaps := make(chan Gap, 10)
+	client, err := NewClient(ctx, Config{
+		URL:              "ws://localhost:9996",
+		Logger:           slog.New(slog.NewTextHandler(io.Discard, nil)),
+		BufferSize:       10,
+		StatePatcher:     statePatcher,
+		StateDecoder:     mockDecoder,
+		StateDiffDecoder: mockDecoder,
+		OnGap:            func(g Gap) { gaps <- g },
+	})
+	require.NoError(t, err)
+
+	for _, expected := range []int64{100, 101, 101, 102, 103, 104, 105} {
+		select {
+		case view := <-client.State():
+			assert.Equal(t, expected, view.Block.Number.Int64())
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out waiting for block %d", expected)
+		}
+	}
+
+	select {
+	case gap := <-gaps:
+		assert.Equal(t, Gap{LastBlock: 101, FromBlock: 102, ToBlock: 103}, gap)
+	default:
+		t.Fatal("OnGap was not called")
+	}
+	assert.Equal(t, uint64(101), <-replayFrom)
+	assert.Equal(t, uint64(1), client.Gaps())
+}
+
+// recordingJournal is a Journal that records the blocks it is given.
+type recordingJournal struct {
+	blocks chan uint64
+	diffs  chan bool
+}
+
+func (j *recordingJournal) Record(state *engine.State, diff *differ.StateDiff) error {
+	j.blocks <- state.Block.Number.Uint64()
+	j.diffs <- diff != nil
+	return nil
+}
+
+func TestClient_InitialStateAndJournal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mustMarshal := func(v any) json.RawMessage {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		return data
+	}
+	diff := func(from, to int64) *SubscriptionEvent {
+		return &SubscriptionEvent{Type: "diff", Payload: mustMarshal(map[string]any{
+			"fromBlock": from,
+			"toBlock":   engine.BlockSummary{Number: big.NewInt(to)},
+			"protocols": map[string]any{},
+		})}
+	}
+
+	// The client restarts from block 100 restored from disk; the server only sends diffs.
+	replay := []*SubscriptionEvent{diff(100, 101), diff(101, 102)}
+	live := []*SubscriptionEvent{diff(101, 102), diff(102, 103)}
+	_, replayFrom, err := SetupMockReplayingStateStreamer(ctx, t, 9997, live, replay)
+	require.NoError(t, err)
+
+	statePatcher := func(prev *engine.State, d *differ.StateDiff) (*engine.State, error) {
+		return &engine.State{Block: d.ToBlock, Protocols: prev.Protocols}, nil
+	}
+
+	journal := &recordingJournal{blocks: make(chan uint64, 10), diffs: make(chan bool, 10)}
+	client, err := NewClient(ctx, Config{
+		URL:              "ws://localhost:9997",
+		Logger:           slog.New(slog.NewTextHandler(io.Discard, nil)),
+		BufferSize:       10,
+		StatePatcher:     statePatcher,
+		StateDecoder:     mockDecoder,
+		StateDiffDecoder: mockDecoder,
+		InitialState: &engine.State{
+			Block:     engine.BlockSummary{Number: big.NewInt(100)},
+			Protocols: map[engine.ProtocolID]engine.ProtocolState{},
+		},
+		Journal: journal,
+	})
+	require.NoError(t, err)
+
+	for _, expected := range []int64{100, 101, 102, 103} {
+		select {
+		case view := <-client.State():
+			assert.Equal(t, expected, view.Block.Number.Int64())
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out waiting for block %d", expected)
+		}
+	}
+	assert.Equal(t, uint64(100), <-replayFrom)
+
+	// The restored state is not recorded again; every patched state is, with its diff.
+	for _, expected := range []uint64{101, 102, 103} {
+		assert.Equal(t, expected, <-journal.blocks)
+		assert.True(t, <-journal.diffs)
+	}
+}
+
+func TestClient_CallReusesConnections(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	testEvents := generateTestEvents(t)
+
+	// Each client gets its own server, since a mock server streams its events only once.
+	newCountingClient := func(t *testing.T, port, maxIdleConns int) (*Client, *atomic.Int32) {
+		_, err := SetupMockStateStreamer(ctx, t, port, testEvents[:1])
+		require.NoError(t, err)
+
+		dials := new(atomic.Int32)
+		client, err := NewClient(ctx, Config{
+			URL:              fmt.Sprintf("ws://localhost:%d", port),
+			Logger:           slog.New(slog.NewTextHandler(io.Discard, nil)),
+			BufferSize:       10,
+			StatePatcher:     noopStatePatcher,
+			StateDecoder:     mockDecoder,
+			StateDiffDecoder: mockDecoder,
+			MaxIdleConns:     maxIdleConns,
+			Dial: func(ctx context.Context, url string) (*rpc.Client, error) {
+				dials.Add(1)
+				return rpc.DialContext(ctx, url)
+			},
+		})
+		require.NoError(t, err)
+
+		// Wait for the subscription, then count only the dials made for calls. The
+		// subscription may have needed retries while the server was starting.
+		select {
+		case <-client.State():
+		case <-time.After(3 * time.Second):
+			t.Fatal("Test timed out waiting for state view")
+		}
+		dials.Store(0)
+		return client, dials
+	}
+
+	call := func(t *testing.T, client *Client, v uint64) {
+		var result uint64
+		require.NoError(t, client.Call(ctx, &result, "defi_echo", v))
+		assert.Equal(t, v, result)
+	}
+
+	t.Run("Repeated calls share one connection", func(t *testing.T) {
+		client, dials := newCountingClient(t, 9992, 0)
+		for i := uint64(0); i < 5; i++ {
+			call(t, client, i)
+		}
+		assert.Equal(t, int32(1), dials.Load())
+	})
+
+	t.Run("A server error keeps the connection", func(t *testing.T) {
+		client, dials := newCountingClient(t, 9993, 0)
+		call(t, client, 1)
+		var result uint64
+		assert.Error(t, client.Call(ctx, &result, "defi_missing"))
+		call(t, client, 2)
+		assert.Equal(t, int32(1), dials.Load())
+	})
+
+	t.Run("Negative MaxIdleConns disables pooling", func(t *testing.T) {
+		client, dials := newCountingClient(t, 9994, -1)
+		for i := uint64(0); i < 3; i++ {
+			call(t, client, i)
+		}
+		assert.Equal(t, int32(3), dials.Load())
+	})
+}
+
 // --- StreamProcessor Tests ---
 
 func TestStreamProcessor_FullAndDiffFlow(t *testing.T) {
@@ -396,6 +779,97 @@ func TestStreamProcessor_FullAndDiffFlow(t *testing.T) {
 	}
 }
 
+func TestStreamProcessor_Filter(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	var patched *engine.State
+	statePatcher := func(prev *engine.State, diff *differ.StateDiff) (*engine.State, error) {
+		patched = prev
+		return &engine.State{Block: diff.ToBlock, Protocols: prev.Protocols}, nil
+	}
+	sp := NewStreamProcessor(logger, 10, statePatcher, mockDecoder, mockDecoder)
+	sp.SetFilter(func(state *engine.State) *engine.State {
+		filtered := *state
+		filtered.Protocols = map[engine.ProtocolID]engine.ProtocolState{}
+		return &filtered
+	})
+
+	events := generateTestEvents(t)
+	for _, event := range events[:2] {
+		eventBytes, err := json.Marshal(event)
+		require.NoError(t, err)
+		require.NoError(t, sp.ProcessMessage(eventBytes))
+
+		select {
+		case state := <-sp.State():
+			assert.Empty(t, state.Protocols, "emitted states are filtered")
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for state")
+		}
+	}
+	require.NotNil(t, patched)
+	assert.Contains(t, patched.Protocols, engine.ProtocolID("uniswap_v2"), "diffs are applied to the unfiltered state")
+}
+
+func TestStreamProcessor_BinaryCodec(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	statePatcher := func(prev *engine.State, diff *differ.StateDiff) (*engine.State, error) {
+		return &engine.State{
+			Block:     diff.ToBlock,
+			Protocols: map[engine.ProtocolID]engine.ProtocolState{"p": {Data: diff.Protocols["p"].Data}},
+		}, nil
+	}
+	binaryDecoder := func(schema engine.ProtocolSchema, data []byte) (any, error) {
+		return wire.Unmarshal[[]tokenregistry.Token](data)
+	}
+	tokens := []tokenregistry.Token{{ID: 1, Symbol: "WETH", Decimals: 18}, {ID: 2}}
+	zero := uint64(0)
+
+	sp := NewStreamProcessor(logger, 10, statePatcher, mockDecoder, mockDecoder)
+	sp.SetBinaryDecoders(binaryDecoder, binaryDecoder)
+
+	fullEvent, err := EncodeBinaryStateEvent(&engine.State{
+		ChainID:   1,
+		Block:     engine.BlockSummary{Number: big.NewInt(100)},
+		Protocols: map[engine.ProtocolID]engine.ProtocolState{"p": {Schema: "s@v1", SyncedBlockNumber: &zero, Data: tokens}},
+	})
+	require.NoError(t, err)
+	diffEvent, err := EncodeBinaryDiffEvent(&differ.StateDiff{
+		FromBlock: 100,
+		ToBlock:   engine.BlockSummary{Number: big.NewInt(101)},
+		Protocols: map[engine.ProtocolID]differ.ProtocolDiff{"p": {Schema: "s@v1", Data: tokens[1:]}},
+	})
+	require.NoError(t, err)
+
+	for i, event := range []*SubscriptionEvent{fullEvent, diffEvent} {
+		raw, err := json.Marshal(event)
+		require.NoError(t, err)
+
+		block, ok := eventBlockNumber(raw)
+		require.True(t, ok)
+		assert.Equal(t, uint64(100+i), block)
+
+		require.NoError(t, sp.ProcessMessage(raw))
+	}
+
+	state := <-sp.State()
+	assert.Equal(t, uint64(1), state.ChainID)
+	assert.Equal(t, tokens, state.Protocols["p"].Data)
+	assert.Equal(t, engine.ProtocolSchema("s@v1"), state.Protocols["p"].Schema)
+	assert.Equal(t, &zero, state.Protocols["p"].SyncedBlockNumber, "a synced block of 0 is kept")
+
+	state = <-sp.State()
+	assert.Equal(t, int64(101), state.Block.Number.Int64())
+	assert.Equal(t, tokens[1:], state.Protocols["p"].Data)
+
+	t.Run("rejects binary events without a binary decoder", func(t *testing.T) {
+		sp := NewStreamProcessor(logger, 10, statePatcher, mockDecoder, mockDecoder)
+		raw, err := json.Marshal(fullEvent)
+		require.NoError(t, err)
+		assert.Error(t, sp.ProcessMessage(raw))
+	})
+}
+
 func TestStreamProcessor_ValidationErrors(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
 	sp := NewStreamProcessor(logger, 10, noopStatePatcher, mockDecoder, mockDecoder)
@@ -451,3 +925,231 @@ func TestStreamProcessor_OutOfOrderDiff(t *testing.T) {
 		// OK
 	}
 }
+
+func TestStreamProcessor_PendingGap(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sp := NewStreamProcessor(logger, 10, noopStatePatcher, mockDecoder, mockDecoder)
+
+	diffEvent := func(from, to int64) []byte {
+		payload, _ := json.Marshal(map[string]any{
+			"fromBlock": from,
+			"toBlock":   engine.BlockSummary{Number: big.NewInt(to)},
+			"protocols": map[string]any{},
+		})
+		data, _ := json.Marshal(&SubscriptionEvent{Type: "diff", Payload: payload})
+		return data
+	}
+
+	events := generateTestEvents(t)
+	fullEventBytes, _ := json.Marshal(events[0]) // Block 100
+	require.NoError(t, sp.ProcessMessage(fullEventBytes))
+	<-sp.State()
+
+	// A stale diff is not a gap.
+	require.NoError(t, sp.ProcessMessage(diffEvent(99, 100)))
+	_, ok := sp.PendingGap()
+	assert.False(t, ok)
+
+	// The first skipped diff opens the gap; later ones leave it as it was.
+	require.NoError(t, sp.ProcessMessage(diffEvent(101, 102)))
+	require.NoError(t, sp.ProcessMessage(diffEvent(102, 103)))
+	gap, ok := sp.PendingGap()
+	require.True(t, ok)
+	assert.Equal(t, Gap{LastBlock: 100, FromBlock: 101, ToBlock: 102}, gap)
+
+	// A full state closes it.
+	require.NoError(t, sp.ProcessMessage(fullEventBytes))
+	<-sp.State()
+	_, ok = sp.PendingGap()
+	assert.False(t, ok)
+}
+
+func TestStreamProcessor_Metrics(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sp := NewStreamProcessor(logger, 10, noopStatePatcher, mockDecoder, mockDecoder)
+	registry := prometheus.NewRegistry()
+	metrics := NewMetrics(registry)
+	sp.SetMetrics(metrics)
+
+	events := generateTestEvents(t)
+	for _, event := range events[:2] {
+		eventBytes, err := json.Marshal(event)
+		require.NoError(t, err)
+		require.NoError(t, sp.ProcessMessage(eventBytes))
+	}
+	gapPayload, _ := json.Marshal(map[string]any{
+		"fromBlock": 105,
+		"toBlock":   engine.BlockSummary{Number: big.NewInt(106)},
+		"protocols": map[string]any{},
+	})
+	gapEvent, _ := json.Marshal(&SubscriptionEvent{Type: "diff", Payload: gapPayload})
+	require.NoError(t, sp.ProcessMessage(gapEvent))
+	metrics.ObserveReconnect()
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.statesReceived.WithLabelValues("full")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.statesReceived.WithLabelValues("diff")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.diffsApplied))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.gaps))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.reconnects))
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.bufferOccupancy), "neither state was consumed")
+	assert.Equal(t, 10.0, testutil.ToFloat64(metrics.bufferCapacity))
+	// The full state and the first diff each decoded one uniswap-v2 protocol.
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.decodeDuration))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.patchDuration))
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		assert.Contains(t, family.GetName(), MetricsNamespace+"_")
+	}
+
+	// A processor without metrics records nothing and does not fail.
+	var none *Metrics
+	none.ObserveReconnect()
+}
+
+func TestStreamProcessor_FailedPatchPreservesState(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// Fails on the first call, succeeds afterwards.
+	calls := 0
+	statePatcher := func(prev *engine.State, diff *differ.StateDiff) (*engine.State, error) {
+		calls++
+		if calls == 1 {
+			return nil, fmt.Errorf("patch failed midway")
+		}
+		return &engine.State{
+			Block:     diff.ToBlock,
+			Protocols: prev.Protocols,
+		}, nil
+	}
+
+	sp := NewStreamProcessor(logger, 10, statePatcher, mockDecoder, mockDecoder)
+
+	events := generateTestEvents(t)
+	fullEventBytes, err := json.Marshal(events[0]) // Block 100
+	require.NoError(t, err)
+	require.NoError(t, sp.ProcessMessage(fullEventBytes))
+	prevState := <-sp.State()
+
+	// 1. The failing diff surfaces an error and emits nothing.
+	diffEventBytes, err := json.Marshal(events[1]) // 100 -> 101
+	require.NoError(t, err)
+
+	err = sp.ProcessMessage(diffEventBytes)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to patch state")
+
+	select {
+	case <-sp.State():
+		t.Fatal("Should not emit state when patching fails")
+	default:
+		// OK
+	}
+
+	// 2. The last known state is untouched.
+	assert.Same(t, prevState, sp.lastState)
+	assert.Equal(t, int64(100), sp.lastState.Block.Number.Int64())
+
+	// 3. Retrying the same diff against the preserved state succeeds.
+	require.NoError(t, sp.ProcessMessage(diffEventBytes))
+	select {
+	case state := <-sp.State():
+		assert.Equal(t, int64(101), state.Block.Number.Int64())
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for diff state")
+	}
+}
+
+func TestStreamProcessor_Concurrency(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	schema := engine.ProtocolSchema("uniswap-v2@v1")
+
+	// The decoder records how many calls overlap, and rejects data marked as bad.
+	var inFlight, maxInFlight atomic.Int64
+	decoder := func(schema engine.ProtocolSchema, data json.RawMessage) (any, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		if bytes.Contains(data, []byte("bad")) {
+			return nil, fmt.Errorf("bad data: %s", data)
+		}
+		return mockDecoder(schema, data)
+	}
+	var patched *differ.StateDiff
+	statePatcher := func(prev *engine.State, diff *differ.StateDiff) (*engine.State, error) {
+		patched = diff
+		return &engine.State{Block: diff.ToBlock, Protocols: prev.Protocols}, nil
+	}
+
+	protocols := make(map[engine.ProtocolID]engine.ProtocolState)
+	for i := 0; i < 16; i++ {
+		protocols[engine.ProtocolID(fmt.Sprintf("protocol_%02d", i))] = engine.ProtocolState{
+			Schema: schema,
+			Data:   map[string]any{"id": float64(i)},
+		}
+	}
+	fullPayload, err := json.Marshal(engine.State{Block: engine.BlockSummary{Number: big.NewInt(100)}, Protocols: protocols})
+	require.NoError(t, err)
+	full, err := json.Marshal(SubscriptionEvent{Type: "full", Payload: fullPayload})
+	require.NoError(t, err)
+
+	diffEvent := func(bad ...engine.ProtocolID) []byte {
+		diffs := make(map[engine.ProtocolID]differ.ProtocolDiff)
+		for pID := range protocols {
+			diffs[pID] = differ.ProtocolDiff{Schema: schema, Data: map[string]any{"reserve": 1}}
+		}
+		for _, pID := range bad {
+			diffs[pID] = differ.ProtocolDiff{Schema: schema, Data: map[string]any{"reserve": "bad"}}
+		}
+		payload, err := json.Marshal(clientStateDiffForTest{
+			FromBlock: 100,
+			ToBlock:   engine.BlockSummary{Number: big.NewInt(101)},
+			Protocols: diffs,
+		})
+		require.NoError(t, err)
+		event, err := json.Marshal(SubscriptionEvent{Type: "diff", Payload: payload})
+		require.NoError(t, err)
+		return event
+	}
+
+	sp := NewStreamProcessor(logger, 10, statePatcher, decoder, decoder)
+	sp.SetConcurrency(4)
+
+	require.NoError(t, sp.ProcessMessage(full))
+	state := <-sp.State()
+	require.Len(t, state.Protocols, 16)
+	for pID, protocolState := range protocols {
+		assert.Equal(t, protocolState.Data, state.Protocols[pID].Data, "protocol %s", pID)
+	}
+
+	require.NoError(t, sp.ProcessMessage(diffEvent()))
+	<-sp.State()
+	require.Len(t, patched.Protocols, 16)
+	for _, protocolDiff := range patched.Protocols {
+		assert.Equal(t, map[string]any{"reserve": float64(1)}, protocolDiff.Data)
+	}
+	assert.Greater(t, maxInFlight.Load(), int64(1), "protocols should be decoded in parallel")
+	assert.LessOrEqual(t, maxInFlight.Load(), int64(4))
+
+	// With several failures, the first protocol in ID order is reported.
+	for i := 0; i < 5; i++ {
+		err = sp.ProcessMessage(diffEvent("protocol_05", "protocol_11"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "protocol_05")
+	}
+}
+
+// clientStateDiffForTest marshals a diff with the JSON tags of clientStateDiff.
+type clientStateDiffForTest struct {
+	FromBlock uint64                                    `json:"fromBlock"`
+	ToBlock   engine.BlockSummary                       `json:"toBlock"`
+	Timestamp uint64                                    `json:"timestamp"`
+	Protocols map[engine.ProtocolID]differ.ProtocolDiff `json:"protocols"`
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+
+	differ "github.com/defistate/defistate-client-go/differ"
+	"github.com/defistate/defistate-client-go/engine"
+	"github.com/defistate/defistate-client-go/streams/wire"
+)
+
+// Codec selects the encoding of the state stream's payloads.
+type Codec string
+
+const (
+	// CodecJSON streams payloads as JSON. It is the default.
+	CodecJSON Codec = "json"
+	// CodecBinary streams payloads in the compact binary encoding of package wire, which
+	// is smaller and much cheaper to decode than JSON for large protocol data such as
+	// Uniswap V3 ticks. Protocol data is decoded per schema by Config.StateBinaryDecoder
+	// and Config.StateDiffBinaryDecoder. Zero values are preserved: a SyncedBlockNumber of
+	// 0 arrives as a pointer to 0. Events are framed in JSON-RPC notifications, so the
+	// payload travels as a base64 string.
+	CodecBinary Codec = "binary"
+)
+
+// BinaryDecoderFunc decodes the binary data of a single protocol into its typed form.
+// The StateOps of each chain provide DecodeStateBinary and DecodeStateDiffBinary.
+type BinaryDecoderFunc func(schema engine.ProtocolSchema, data []byte) (any, error)
+
+// EncodeBinaryStateEvent returns a "full" event carrying state in the binary codec, e.g.
+// for a server or a test feeding a client configured with CodecBinary. Protocol data
+// must hold the typed values of its schema.
+func EncodeBinaryStateEvent(state *engine.State) (*SubscriptionEvent, error) {
+	payload := &wire.State{
+		ChainID:   state.ChainID,
+		Timestamp: state.Timestamp,
+		Block:     state.Block,
+		Protocols: make(map[engine.ProtocolID]wire.ProtocolData, len(state.Protocols)),
+	}
+	for pID, protocol := range state.Protocols {
+		data, err := encodeProtocolData(protocol.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode state for protocol %s: %w", pID, err)
+		}
+		payload.Protocols[pID] = wire.ProtocolData{
+			Meta:              protocol.Meta,
+			SyncedBlockNumber: protocol.SyncedBlockNumber,
+			Schema:            protocol.Schema,
+			Error:             protocol.Error,
+			Data:              data,
+		}
+	}
+	return binaryEvent("full", payload)
+}
+
+// EncodeBinaryDiffEvent returns a "diff" event carrying diff in the binary codec.
+func EncodeBinaryDiffEvent(diff *differ.StateDiff) (*SubscriptionEvent, error) {
+	payload := &wire.StateDiff{
+		FromBlock: diff.FromBlock,
+		ToBlock:   diff.ToBlock,
+		Timestamp: diff.Timestamp,
+		Protocols: make(map[engine.ProtocolID]wire.ProtocolData, len(diff.Protocols)),
+	}
+	for pID, protocol := range diff.Protocols {
+		data, err := encodeProtocolData(protocol.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode diff for protocol %s: %w", pID, err)
+		}
+		payload.Protocols[pID] = wire.ProtocolData{
+			Meta:              protocol.Meta,
+			SyncedBlockNumber: protocol.SyncedBlockNumber,
+			Schema:            protocol.Schema,
+			Error:             protocol.Error,
+			Data:              data,
+		}
+	}
+	return binaryEvent("diff", payload)
+}
+
+// binaryEvent wraps a binary payload; json encodes its bytes as a base64 string.
+func binaryEvent(eventType string, payload any) (*SubscriptionEvent, error) {
+	data, err := wire.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &SubscriptionEvent{Type: eventType, Encoding: CodecBinary, Payload: raw}, nil
+}
+
+// encodeProtocolData encodes a protocol's typed data; a protocol without data, e.g. one
+// that errored, has none.
+func encodeProtocolData(v any) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return wire.Marshal(v)
+}
+
+// decodeBinaryPayload decodes the payload of a binary event into a *wire.State or a
+// *wire.StateDiff.
+func decodeBinaryPayload[T any](payload json.RawMessage) (T, error) {
+	var data []byte
+	if err := json.Unmarshal(payload, &data); err != nil {
+		var zero T
+		return zero, err
+	}
+	return wire.Unmarshal[T](data)
+}
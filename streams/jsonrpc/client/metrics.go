@@ -0,0 +1,123 @@
+package client
+
+import (
+	"time"
+
+	"github.com/defistate/defistate-client-go/engine"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricsNamespace prefixes the names of the stream health metrics.
+const MetricsNamespace = "defistate_client"
+
+// Metrics holds the Prometheus metrics of the health of a state stream. A nil *Metrics
+// records nothing.
+type Metrics struct {
+	statesReceived  *prometheus.CounterVec
+	diffsApplied    prometheus.Counter
+	patchDuration   prometheus.Histogram
+	decodeDuration  *prometheus.HistogramVec
+	bufferOccupancy prometheus.Gauge
+	bufferCapacity  prometheus.Gauge
+	reconnects      prometheus.Counter
+	gaps            prometheus.Counter
+}
+
+// NewMetrics creates and registers the stream health metrics.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		statesReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "states_received_total",
+			Help:      "Total number of stream events received, labeled by type (full or diff).",
+		}, []string{"type"}),
+		diffsApplied: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "diffs_applied_total",
+			Help:      "Total number of diffs successfully applied to the last state.",
+		}),
+		patchDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: MetricsNamespace,
+			Name:      "patch_duration_seconds",
+			Help:      "Time taken to apply a diff to the last state.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		decodeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: MetricsNamespace,
+			Name:      "decode_duration_seconds",
+			Help:      "Time taken to decode the state or diff data of a protocol, labeled by schema.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"schema"}),
+		bufferOccupancy: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: MetricsNamespace,
+			Name:      "buffer_occupancy",
+			Help:      "Number of states waiting in the state buffer, sampled whenever a state is emitted.",
+		}),
+		bufferCapacity: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: MetricsNamespace,
+			Name:      "buffer_capacity",
+			Help:      "Capacity of the state buffer.",
+		}),
+		reconnects: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "reconnects_total",
+			Help:      "Total number of attempts to re-establish a failed stream.",
+		}),
+		gaps: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "gaps_detected_total",
+			Help:      "Total number of times the stream skipped blocks.",
+		}),
+	}
+	reg.MustRegister(
+		m.statesReceived,
+		m.diffsApplied,
+		m.patchDuration,
+		m.decodeDuration,
+		m.bufferOccupancy,
+		m.bufferCapacity,
+		m.reconnects,
+		m.gaps,
+	)
+	return m
+}
+
+// ObserveReconnect counts an attempt to re-establish the stream, for transports that
+// drive a StreamProcessor themselves.
+func (m *Metrics) ObserveReconnect() {
+	if m != nil {
+		m.reconnects.Inc()
+	}
+}
+
+func (m *Metrics) observeReceived(eventType string) {
+	if m != nil {
+		m.statesReceived.WithLabelValues(eventType).Inc()
+	}
+}
+
+func (m *Metrics) observePatch(start time.Time) {
+	if m != nil {
+		m.patchDuration.Observe(time.Since(start).Seconds())
+		m.diffsApplied.Inc()
+	}
+}
+
+func (m *Metrics) observeDecode(schema engine.ProtocolSchema, start time.Time) {
+	if m != nil {
+		m.decodeDuration.WithLabelValues(string(schema)).Observe(time.Since(start).Seconds())
+	}
+}
+
+func (m *Metrics) observeBuffer(occupancy, capacity int) {
+	if m != nil {
+		m.bufferOccupancy.Set(float64(occupancy))
+		m.bufferCapacity.Set(float64(capacity))
+	}
+}
+
+func (m *Metrics) observeGap() {
+	if m != nil {
+		m.gaps.Inc()
+	}
+}
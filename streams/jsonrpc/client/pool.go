@@ -0,0 +1,87 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// DefaultMaxIdleConns is the number of idle connections kept for one-off calls when
+// Config.MaxIdleConns is zero.
+const DefaultMaxIdleConns = 2
+
+// DialFunc opens an RPC connection to url.
+type DialFunc func(ctx context.Context, url string) (*rpc.Client, error)
+
+// connPool keeps connections for request/response calls open between calls, so one-off
+// RPCs do not pay for a new connection each time. The subscription has its own connection.
+type connPool struct {
+	url     string
+	dial    DialFunc
+	maxIdle int
+
+	mu     sync.Mutex
+	idle   []*rpc.Client
+	closed bool
+}
+
+func newConnPool(url string, dial DialFunc, maxIdle int) *connPool {
+	return &connPool{url: url, dial: dial, maxIdle: maxIdle}
+}
+
+// get returns an idle connection, or dials a new one if none is available.
+func (p *connPool) get(ctx context.Context) (*rpc.Client, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, errors.New("client is closed")
+	}
+	if n := len(p.idle); n > 0 {
+		conn := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return conn, nil
+	}
+	p.mu.Unlock()
+	return p.dial(ctx, p.url)
+}
+
+// put returns conn to the pool. Broken connections, and any beyond maxIdle, are closed.
+func (p *connPool) put(conn *rpc.Client, broken bool) {
+	p.mu.Lock()
+	if broken || p.closed || len(p.idle) >= p.maxIdle {
+		p.mu.Unlock()
+		conn.Close()
+		return
+	}
+	p.idle = append(p.idle, conn)
+	p.mu.Unlock()
+}
+
+// close closes every idle connection; connections in use are closed when returned.
+func (p *connPool) close() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle, p.closed = nil, true
+	p.mu.Unlock()
+	for _, conn := range idle {
+		conn.Close()
+	}
+}
+
+// Call makes a one-off request/response RPC, such as a snapshot or a single pool lookup,
+// on a pooled connection. method is the full method name, e.g. "defi_replayStateStream".
+// A connection is reused after the call unless the call failed for a reason other than
+// an error returned by the server.
+func (c *Client) Call(ctx context.Context, result any, method string, args ...any) error {
+	conn, err := c.pool.get(ctx)
+	if err != nil {
+		return err
+	}
+	err = conn.CallContext(ctx, result, method, args...)
+	var serverErr rpc.Error
+	c.pool.put(conn, err != nil && !errors.As(err, &serverErr))
+	return err
+}
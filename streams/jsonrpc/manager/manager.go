@@ -0,0 +1,212 @@
+// Package manager runs the state stream clients of several chains side by side and
+// multiplexes their states into a single channel.
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+
+	"github.com/defistate/defistate-client-go/engine"
+	"github.com/defistate/defistate-client-go/streams/jsonrpc/client"
+	"github.com/defistate/defistate-client-go/streams/jsonrpc/stateops"
+	// Register the StateOps of every supported chain.
+	_ "github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains/all"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultBufferSize is the capacity of the multiplexed state channel, and of each chain
+// client's, when Config.BufferSize is zero.
+const DefaultBufferSize = 100
+
+// ChainConfig configures the client of one chain.
+type ChainConfig struct {
+	ChainID        uint64 `yaml:"chain_id"`
+	StateStreamURL string `yaml:"state_stream_url"`
+	// SchemaVersion pins the chain's decoders to a single protocol schema version. Zero
+	// accepts every version the client can decode.
+	SchemaVersion uint64 `yaml:"schema_version"`
+	// Concurrency is the number of workers decoding and patching the chain's protocols
+	// in parallel. Zero or one handles them one by one.
+	Concurrency int `yaml:"concurrency"`
+}
+
+// Config holds the configuration for a ClientManager.
+type Config struct {
+	Chains []ChainConfig
+	Logger client.Logger
+	// Registry receives the metrics of every chain, labeled with its chain_id. Nil means
+	// a private registry, i.e. the metrics are not exported.
+	Registry   prometheus.Registerer
+	BufferSize uint
+	// Reconnect is the reconnection policy of every chain client.
+	Reconnect client.ReconnectPolicy
+}
+
+// validate checks if the configuration is valid.
+func (c *Config) validate() error {
+	if len(c.Chains) == 0 {
+		return errors.New("config: at least one chain is required")
+	}
+	if c.Logger == nil {
+		return errors.New("config: Logger is required")
+	}
+	seen := make(map[uint64]bool, len(c.Chains))
+	for _, chain := range c.Chains {
+		if seen[chain.ChainID] {
+			return fmt.Errorf("config: chain %d is configured twice", chain.ChainID)
+		}
+		seen[chain.ChainID] = true
+		if chain.Concurrency < 0 {
+			return fmt.Errorf("config: chain %d: concurrency must not be negative", chain.ChainID)
+		}
+	}
+	return nil
+}
+
+// ChainState is a state emitted by the client of ChainID.
+type ChainState struct {
+	ChainID uint64
+	State   *engine.State
+}
+
+// ChainError is a fatal error of the client of ChainID, after which that chain streams
+// no more states. The other chains are unaffected.
+type ChainError struct {
+	ChainID uint64
+	Err     error
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("chain %d: %v", e.ChainID, e.Err)
+}
+
+func (e *ChainError) Unwrap() error {
+	return e.Err
+}
+
+// ClientManager runs one state stream client per configured chain and multiplexes their
+// states, tagged by chain ID, into a single channel.
+type ClientManager struct {
+	clients map[uint64]*client.Client
+	states  chan ChainState
+	errCh   chan error
+}
+
+// NewClientManager resolves the StateOps of every configured chain with
+// stateops.ForChainID, starts their clients
+// and streams until ctx is canceled. It fails if any chain is unknown or its client cannot
+// be created.
+func NewClientManager(ctx context.Context, cfg Config) (*ClientManager, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	bufferSize := cfg.BufferSize
+	if bufferSize == 0 {
+		bufferSize = DefaultBufferSize
+	}
+	registry := cfg.Registry
+	if registry == nil {
+		registry = prometheus.NewRegistry()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	m := &ClientManager{
+		clients: make(map[uint64]*client.Client, len(cfg.Chains)),
+		states:  make(chan ChainState, bufferSize),
+		errCh:   make(chan error, len(cfg.Chains)),
+	}
+	for _, chain := range cfg.Chains {
+		logger := loggerWith(cfg.Logger, "chain_id", chain.ChainID)
+		// Each chain registers the same metrics, so they are told apart by label.
+		chainRegistry := prometheus.WrapRegistererWith(prometheus.Labels{"chain_id": strconv.FormatUint(chain.ChainID, 10)}, registry)
+
+		stateOps, err := stateops.ForChainID(chain.ChainID, logger, chainRegistry, chain.SchemaVersion)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		stateOps.SetConcurrency(chain.Concurrency)
+		c, err := client.NewClient(ctx, client.Config{
+			URL:              chain.StateStreamURL,
+			Logger:           loggerWith(logger, "component", "jsonrpc-client"),
+			BufferSize:       bufferSize,
+			StatePatcher:     stateOps.Patch,
+			StateDecoder:     stateOps.DecodeStateJSON,
+			StateDiffDecoder: stateOps.DecodeStateDiffJSON,
+			Reconnect:        cfg.Reconnect,
+			Registry:         chainRegistry,
+			Concurrency:      chain.Concurrency,
+		})
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("chain %d: %w", chain.ChainID, err)
+		}
+		m.clients[chain.ChainID] = c
+	}
+
+	var wg sync.WaitGroup
+	for chainID, c := range m.clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.forward(ctx, chainID, c)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		cancel()
+		close(m.errCh)
+	}()
+	return m, nil
+}
+
+// forward tags the states of c and passes them on until ctx is canceled or c stops.
+func (m *ClientManager) forward(ctx context.Context, chainID uint64, c *client.Client) {
+	for {
+		select {
+		case state := <-c.State():
+			select {
+			case m.states <- ChainState{ChainID: chainID, State: state}:
+			case <-ctx.Done():
+				return
+			}
+		case err, ok := <-c.Err():
+			if ok {
+				m.errCh <- &ChainError{ChainID: chainID, Err: err}
+			}
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// State returns the states of every chain, in the order each chain emitted them. A slow
+// consumer holds back every chain.
+func (m *ClientManager) State() <-chan ChainState {
+	return m.states
+}
+
+// Err returns the fatal errors of the chain clients, each a *ChainError. It is closed once
+// every client has stopped, e.g. after the context is canceled.
+func (m *ClientManager) Err() <-chan error {
+	return m.errCh
+}
+
+// Client returns the client of chainID, e.g. for one-off calls.
+func (m *ClientManager) Client(chainID uint64) (*client.Client, bool) {
+	c, ok := m.clients[chainID]
+	return c, ok
+}
+
+// loggerWith adds args to logger if it is a *slog.Logger.
+func loggerWith(logger client.Logger, args ...any) client.Logger {
+	if l, ok := logger.(*slog.Logger); ok {
+		return l.With(args...)
+	}
+	return logger
+}
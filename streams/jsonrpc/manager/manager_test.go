@@ -0,0 +1,148 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/defistate/defistate-client-go/engine"
+	"github.com/defistate/defistate-client-go/streams/jsonrpc/client"
+	"github.com/defistate/defistate-client-go/streams/jsonrpc/stateops"
+	"github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fullStateStreamer streams a single full state at block to each subscriber.
+type fullStateStreamer struct {
+	chainID uint64
+	block   int64
+}
+
+func (s *fullStateStreamer) SubscribeStateStream(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	payload, err := json.Marshal(engine.State{
+		ChainID:   s.chainID,
+		Block:     engine.BlockSummary{Number: big.NewInt(s.block)},
+		Protocols: map[engine.ProtocolID]engine.ProtocolState{},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rpcSub := notifier.CreateSubscription()
+	go notifier.Notify(rpcSub.ID, &client.SubscriptionEvent{Type: "full", Payload: payload})
+	return rpcSub, nil
+}
+
+func startStreamer(t *testing.T, chainID uint64, block int64) string {
+	t.Helper()
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName(client.RpcNamespace, &fullStateStreamer{chainID: chainID, block: block}))
+	httpServer := httptest.NewServer(server.WebsocketHandler([]string{"*"}))
+	t.Cleanup(func() {
+		server.Stop()
+		httpServer.Close()
+	})
+	return "ws" + strings.TrimPrefix(httpServer.URL, "http")
+}
+
+func TestClientManager_MultiplexesChains(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registry := prometheus.NewRegistry()
+	manager, err := NewClientManager(ctx, Config{
+		Chains: []ChainConfig{
+			{ChainID: chains.Mainnet, StateStreamURL: startStreamer(t, chains.Mainnet, 100)},
+			{ChainID: chains.Base, StateStreamURL: startStreamer(t, chains.Base, 200), Concurrency: 4},
+		},
+		Logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Registry: registry,
+	})
+	require.NoError(t, err)
+
+	blocks := map[uint64]int64{}
+	for len(blocks) < 2 {
+		select {
+		case state := <-manager.State():
+			assert.Equal(t, state.ChainID, state.State.ChainID)
+			blocks[state.ChainID] = state.State.Block.Number.Int64()
+		case err := <-manager.Err():
+			t.Fatalf("unexpected error: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timeout waiting for states")
+		}
+	}
+	assert.Equal(t, map[uint64]int64{chains.Mainnet: 100, chains.Base: 200}, blocks)
+
+	_, ok := manager.Client(chains.Base)
+	assert.True(t, ok)
+	_, ok = manager.Client(chains.Arbitrum)
+	assert.False(t, ok)
+
+	cancel()
+	select {
+	case _, open := <-manager.Err():
+		assert.False(t, open, "Err is closed once every client stopped")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for the clients to stop")
+	}
+}
+
+func TestClientManager_ReportsChainErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	manager, err := NewClientManager(ctx, Config{
+		Chains:    []ChainConfig{{ChainID: chains.Arbitrum, StateStreamURL: "ws://127.0.0.1:1"}},
+		Logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Reconnect: client.ReconnectPolicy{MaxRetries: 1, InitialBackoff: time.Millisecond},
+	})
+	require.NoError(t, err)
+
+	select {
+	case err := <-manager.Err():
+		var chainErr *ChainError
+		require.ErrorAs(t, err, &chainErr)
+		assert.Equal(t, uint64(chains.Arbitrum), chainErr.ChainID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for the chain error")
+	}
+}
+
+func TestNewClientManager_RejectsInvalidConfig(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	_, err := NewClientManager(context.Background(), Config{Logger: logger})
+	assert.Error(t, err)
+
+	_, err = NewClientManager(context.Background(), Config{
+		Chains: []ChainConfig{{ChainID: chains.Mainnet, StateStreamURL: "ws://a"}, {ChainID: chains.Mainnet, StateStreamURL: "ws://b"}},
+		Logger: logger,
+	})
+	assert.ErrorContains(t, err, "configured twice")
+
+	_, err = NewClientManager(context.Background(), Config{
+		Chains: []ChainConfig{{ChainID: 999, StateStreamURL: "ws://a"}},
+		Logger: logger,
+	})
+	assert.ErrorIs(t, err, stateops.ErrUnknownChain)
+
+	_, err = NewClientManager(context.Background(), Config{
+		Chains: []ChainConfig{{ChainID: chains.Mainnet, StateStreamURL: "ws://a", Concurrency: -1}},
+		Logger: logger,
+	})
+	assert.ErrorContains(t, err, "concurrency")
+}
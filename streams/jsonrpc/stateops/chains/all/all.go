@@ -0,0 +1,13 @@
+// Package all registers the StateOps of every supported chain with the stateops
+// package. Import it for its side effects.
+package all
+
+import (
+	_ "github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains/arbitrum"
+	_ "github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains/base"
+	_ "github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains/bsc"
+	_ "github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains/ethereum"
+	_ "github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains/katana"
+	_ "github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains/optimism"
+	_ "github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains/polygon"
+)
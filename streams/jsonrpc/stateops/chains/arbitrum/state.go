@@ -3,6 +3,7 @@ package arbitrum
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 
 	"github.com/defistate/defistate-client-go/differ"
 	"github.com/defistate/defistate-client-go/engine"
@@ -10,9 +11,12 @@ import (
 	poolregistry "github.com/defistate/defistate-client-go/protocols/poolregistry"
 	tokenpoolregistry "github.com/defistate/defistate-client-go/protocols/tokenpoolregistry"
 
+	solidly "github.com/defistate/defistate-client-go/protocols/solidly"
 	tokenregistry "github.com/defistate/defistate-client-go/protocols/tokenregistry"
 	uniswapv2 "github.com/defistate/defistate-client-go/protocols/uniswapv2"
 	uniswapv3 "github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	"github.com/defistate/defistate-client-go/streams/jsonrpc/stateops"
+	"github.com/defistate/defistate-client-go/streams/wire"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -24,6 +28,12 @@ type Logger interface {
 	Error(msg string, args ...any)
 }
 
+var (
+	ErrUnknownSchema             = errors.New("unknown schema")
+	ErrIncompatibleSchemaVersion = stateops.ErrIncompatibleSchemaVersion
+	ErrUnsupportedSchemaVersion  = stateops.ErrUnsupportedSchemaVersion
+)
+
 // StateOps encapsulates the core business logic for processing Ethereum DSE State.
 //
 // It acts as a unified facade for two critical operations:
@@ -32,11 +42,29 @@ type Logger interface {
 type StateOps struct {
 	*differ.StateDiffer
 	*patcher.StatePatcher
+
+	// schemaVersion pins the decoders to a single schema version.
+	// Zero accepts every version in stateops.SchemaVersions.
+	schemaVersion uint64
+	versions      stateops.VersionGate
+}
+
+// Option configures the StateOps.
+type Option func(*StateOps)
+
+// WithSchemaVersion pins the decoders to the given schema version, one of
+// stateops.SchemaVersions. Payloads carrying any other version are rejected with
+// ErrIncompatibleSchemaVersion instead of being decoded with the wrong layout.
+func WithSchemaVersion(version uint64) Option {
+	return func(ops *StateOps) {
+		ops.schemaVersion = version
+	}
 }
 
 func NewStateOps(
 	logger Logger,
 	prometheusRegistry prometheus.Registerer,
+	opts ...Option,
 ) (*StateOps, error) {
 	protocolDiffers := map[engine.ProtocolSchema]differ.ProtocolDiffer{
 		tokenregistry.Schema: func(old, new any) (diff any, err error) {
@@ -54,6 +82,9 @@ func NewStateOps(
 		uniswapv3.Schema: func(old, new any) (diff any, err error) {
 			return uniswapv3.Differ(old.([]uniswapv3.Pool), new.([]uniswapv3.Pool)), nil
 		},
+		solidly.Schema: func(old, new any) (diff any, err error) {
+			return solidly.Differ(old.([]solidly.Pool), new.([]solidly.Pool)), nil
+		},
 	}
 
 	protocolPatchers := map[engine.ProtocolSchema]patcher.PatcherFunc{
@@ -72,6 +103,9 @@ func NewStateOps(
 		uniswapv3.Schema: func(prevState, diff any) (newState any, err error) {
 			return uniswapv3.Patcher(prevState.([]uniswapv3.Pool), diff.(uniswapv3.UniswapV3SystemDiff))
 		},
+		solidly.Schema: func(prevState, diff any) (newState any, err error) {
+			return solidly.Patcher(prevState.([]solidly.Pool), diff.(solidly.SolidlySystemDiff))
+		},
 	}
 
 	stateDiffer, err := differ.NewStateDiffer(&differ.StateDifferConfig{
@@ -90,10 +124,20 @@ func NewStateOps(
 		return nil, err
 	}
 
-	return &StateOps{
+	ops := &StateOps{
 		StateDiffer:  stateDiffer,
 		StatePatcher: statePatcher,
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(ops)
+	}
+
+	ops.versions, err = stateops.NewVersionGate(ops.schemaVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return ops, nil
 
 }
 
@@ -101,7 +145,12 @@ func (ops *StateOps) DecodeStateJSON(
 	schema engine.ProtocolSchema,
 	data json.RawMessage,
 ) (any, error) {
-	switch schema {
+	base, version, err := ops.versions.Resolve(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	switch base {
 	case tokenregistry.Schema:
 		var typedData []tokenregistry.Token
 		err := json.Unmarshal(data, &typedData)
@@ -132,14 +181,20 @@ func (ops *StateOps) DecodeStateJSON(
 		}
 		return typedData, nil
 	case uniswapv3.Schema:
-		var typedData []uniswapv3.Pool
+		typedData, err := uniswapv3.UnmarshalPoolsJSON(data, version)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case solidly.Schema:
+		var typedData []solidly.Pool
 		err := json.Unmarshal(data, &typedData)
 		if err != nil {
 			return nil, err
 		}
 		return typedData, nil
 	default:
-		return nil, errors.New("unknown schema")
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSchema, schema)
 	}
 }
 
@@ -147,7 +202,12 @@ func (ops *StateOps) DecodeStateDiffJSON(
 	schema engine.ProtocolSchema,
 	data json.RawMessage,
 ) (any, error) {
-	switch schema {
+	base, version, err := ops.versions.Resolve(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	switch base {
 	case tokenregistry.Schema:
 		var typedData tokenregistry.TokenSystemDiff
 		err := json.Unmarshal(data, &typedData)
@@ -178,13 +238,127 @@ func (ops *StateOps) DecodeStateDiffJSON(
 		}
 		return typedData, nil
 	case uniswapv3.Schema:
-		var typedData uniswapv3.UniswapV3SystemDiff
+		typedData, err := uniswapv3.UnmarshalDiffJSON(data, version)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case solidly.Schema:
+		var typedData solidly.SolidlySystemDiff
 		err := json.Unmarshal(data, &typedData)
 		if err != nil {
 			return nil, err
 		}
 		return typedData, nil
 	default:
-		return nil, errors.New("unknown schema")
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSchema, schema)
+	}
+}
+
+// DecodeStateBinary decodes protocol state data sent in the client's binary codec (see
+// client.CodecBinary) into the same typed values as DecodeStateJSON.
+func (ops *StateOps) DecodeStateBinary(
+	schema engine.ProtocolSchema,
+	data []byte,
+) (any, error) {
+	base, _, err := ops.versions.Resolve(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	switch base {
+	case tokenregistry.Schema:
+		typedData, err := wire.Unmarshal[[]tokenregistry.Token](data)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+
+	case poolregistry.Schema:
+		typedData, err := wire.Unmarshal[poolregistry.PoolRegistry](data)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case tokenpoolregistry.Schema:
+		typedData, err := wire.Unmarshal[*tokenpoolregistry.TokenPoolRegistryView](data)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case uniswapv2.Schema:
+		typedData, err := wire.Unmarshal[[]uniswapv2.Pool](data)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case uniswapv3.Schema:
+		typedData, err := wire.Unmarshal[[]uniswapv3.Pool](data)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case solidly.Schema:
+		typedData, err := wire.Unmarshal[[]solidly.Pool](data)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSchema, schema)
+	}
+}
+
+// DecodeStateDiffBinary decodes protocol diff data sent in the client's binary codec (see
+// client.CodecBinary) into the same typed values as DecodeStateDiffJSON.
+func (ops *StateOps) DecodeStateDiffBinary(
+	schema engine.ProtocolSchema,
+	data []byte,
+) (any, error) {
+	base, _, err := ops.versions.Resolve(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	switch base {
+	case tokenregistry.Schema:
+		typedData, err := wire.Unmarshal[tokenregistry.TokenSystemDiff](data)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+
+	case poolregistry.Schema:
+		typedData, err := wire.Unmarshal[poolregistry.PoolRegistryDiff](data)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case tokenpoolregistry.Schema:
+		typedData, err := wire.Unmarshal[tokenpoolregistry.TokenPoolRegistryDiff](data)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case uniswapv2.Schema:
+		typedData, err := wire.Unmarshal[uniswapv2.UniswapV2SystemDiff](data)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case uniswapv3.Schema:
+		typedData, err := wire.Unmarshal[uniswapv3.UniswapV3SystemDiff](data)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case solidly.Schema:
+		typedData, err := wire.Unmarshal[solidly.SolidlySystemDiff](data)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSchema, schema)
 	}
 }
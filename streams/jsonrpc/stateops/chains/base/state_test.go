@@ -0,0 +1,148 @@
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"testing"
+
+	"github.com/defistate/defistate-client-go/engine"
+	"github.com/defistate/defistate-client-go/protocols/poolregistry"
+	"github.com/defistate/defistate-client-go/protocols/solidly"
+	uniswapv2 "github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	uniswapv3 "github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	uniswapv4 "github.com/defistate/defistate-client-go/protocols/uniswapv4"
+	"github.com/defistate/defistate-client-go/streams/jsonrpc/stateops"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStateOps(t *testing.T, opts ...Option) *StateOps {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ops, err := NewStateOps(logger, prometheus.NewRegistry(), opts...)
+	require.NoError(t, err)
+	return ops
+}
+
+func TestDecode_SchemaVersions(t *testing.T) {
+	pools := []uniswapv3.Pool{{
+		PoolViewMinimal: uniswapv3.PoolViewMinimal{ID: 1, Token0: 10, Token1: 11, Fee: 500, TickSpacing: 10, Tick: -7, Liquidity: big.NewInt(5000), SqrtPriceX96: big.NewInt(1 << 40)},
+		Ticks:           []uniswapv3.TickInfo{{Index: -10, LiquidityGross: big.NewInt(5000), LiquidityNet: big.NewInt(-5000)}},
+	}}
+	diff := uniswapv3.UniswapV3SystemDiff{Updates: pools, Deletions: []uint64{2}}
+
+	for _, version := range stateops.SchemaVersions {
+		t.Run(fmt.Sprintf("v%d", version), func(t *testing.T) {
+			schema := engine.ProtocolSchema(fmt.Sprintf("%s@v%d", uniswapv3.Schema.Name(), version))
+			statePayload, err := uniswapv3.MarshalPoolsJSON(pools, version)
+			require.NoError(t, err)
+			diffPayload, err := uniswapv3.MarshalDiffJSON(diff, version)
+			require.NoError(t, err)
+
+			for _, ops := range []*StateOps{newTestStateOps(t), newTestStateOps(t, WithSchemaVersion(version))} {
+				state, err := ops.DecodeStateJSON(schema, statePayload)
+				require.NoError(t, err)
+				assert.Equal(t, pools, state)
+
+				decoded, err := ops.DecodeStateDiffJSON(schema, diffPayload)
+				require.NoError(t, err)
+				assert.Equal(t, diff, decoded)
+			}
+
+			_, err = newTestStateOps(t, WithSchemaVersion(3-version)).DecodeStateJSON(schema, statePayload)
+			assert.ErrorIs(t, err, ErrIncompatibleSchemaVersion)
+		})
+	}
+}
+
+func TestDecode_UniswapV4(t *testing.T) {
+	ops := newTestStateOps(t)
+
+	statePayload := json.RawMessage(`[{"id":5,"poolId":"0x21c67e77068de97969ba93d4aab21826d33ca12bb9f565d8496e8fda8a82ca27","token0":0,"token1":1,"fee":8388608,"tickSpacing":60,"hooks":"0x0000000000000000000000000000000000000080","tick":-5,"liquidity":1000,"sqrtPriceX96":79228162514264337593543950336,"ticks":[],"lpFee":3000}]`)
+	state, err := ops.DecodeStateJSON(uniswapv4.Schema, statePayload)
+	require.NoError(t, err)
+	pools, ok := state.([]uniswapv4.Pool)
+	require.True(t, ok)
+	require.Len(t, pools, 1)
+	assert.Equal(t, "0x21c67e77068de97969ba93d4aab21826d33ca12bb9f565d8496e8fda8a82ca27", pools[0].PoolID.String())
+	assert.True(t, pools[0].IsDynamicFee())
+	assert.True(t, pools[0].HasHooks())
+	fee, ok := pools[0].SwapFee()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(3000), fee)
+
+	diff, err := ops.DecodeStateDiffJSON(uniswapv4.Schema, json.RawMessage(`{"deletions":[5]}`))
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{5}, diff.(uniswapv4.UniswapV4SystemDiff).Deletions)
+}
+
+func TestDecode_Solidly(t *testing.T) {
+	ops := newTestStateOps(t)
+
+	statePayload := json.RawMessage(`[{"id":7,"token0":0,"token1":1,"reserve0":1000000,"reserve1":999000,"stable":true,"feeBps":5,"decimals0":6,"decimals1":18}]`)
+	state, err := ops.DecodeStateJSON(solidly.Schema, statePayload)
+	require.NoError(t, err)
+	pools, ok := state.([]solidly.Pool)
+	require.True(t, ok)
+	require.Len(t, pools, 1)
+	assert.True(t, pools[0].Stable)
+	assert.Equal(t, uint16(5), pools[0].FeeBps)
+	assert.Equal(t, big.NewInt(999000), pools[0].Reserve1)
+
+	diff, err := ops.DecodeStateDiffJSON(solidly.Schema, json.RawMessage(`{"deletions":[7]}`))
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{7}, diff.(solidly.SolidlySystemDiff).Deletions)
+}
+
+func TestDiffAndPatch_NewPool(t *testing.T) {
+	ops := newTestStateOps(t)
+
+	const (
+		registryID engine.ProtocolID = "pool_registry"
+		v2ID       engine.ProtocolID = "uniswap_v2"
+	)
+	existing := uniswapv2.Pool{ID: 1, Token0: 10, Token1: 11, Reserve0: big.NewInt(1000), Reserve1: big.NewInt(2000), FeeBps: 30}
+	added := uniswapv2.Pool{ID: 2, Token0: 11, Token1: 12, Reserve0: big.NewInt(500), Reserve1: big.NewInt(700), FeeBps: 30}
+
+	makeState := func(block int64, pools ...uniswapv2.Pool) *engine.State {
+		registry := poolregistry.PoolRegistry{Protocols: map[uint16]engine.ProtocolID{1: v2ID}}
+		for _, pool := range pools {
+			registry.Pools = append(registry.Pools, poolregistry.Pool{ID: pool.ID, Protocol: 1})
+		}
+		return &engine.State{
+			Block: engine.BlockSummary{Number: big.NewInt(block)},
+			Protocols: map[engine.ProtocolID]engine.ProtocolState{
+				registryID: {Schema: poolregistry.Schema, Data: registry},
+				v2ID:       {Schema: uniswapv2.Schema, Data: pools},
+			},
+		}
+	}
+	oldState := makeState(100, existing)
+	newState := makeState(101, existing, added)
+
+	diff, err := ops.Diff(oldState, newState)
+	require.NoError(t, err)
+
+	patched, err := ops.Patch(oldState, diff)
+	require.NoError(t, err)
+
+	registry := patched.Protocols[registryID].Data.(poolregistry.PoolRegistry)
+	require.Len(t, registry.Pools, 2)
+	assert.ElementsMatch(t, []uint64{1, 2}, []uint64{registry.Pools[0].ID, registry.Pools[1].ID})
+
+	pools := patched.Protocols[v2ID].Data.([]uniswapv2.Pool)
+	require.Len(t, pools, 2)
+	assert.ElementsMatch(t, []uint64{1, 2}, []uint64{pools[0].ID, pools[1].ID})
+	for _, pool := range pools {
+		if pool.ID == added.ID {
+			assert.Equal(t, added.Reserve1, pool.Reserve1)
+		}
+	}
+
+	// The old state is left untouched.
+	assert.Len(t, oldState.Protocols[v2ID].Data.([]uniswapv2.Pool), 1)
+}
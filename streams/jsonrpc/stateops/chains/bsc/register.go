@@ -0,0 +1,13 @@
+package bsc
+
+import (
+	"github.com/defistate/defistate-client-go/streams/jsonrpc/stateops"
+	"github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	stateops.Register(chains.BSC, func(logger stateops.Logger, registry prometheus.Registerer, schemaVersion uint64) (stateops.ChainStateOps, error) {
+		return NewStateOps(logger, registry, WithSchemaVersion(schemaVersion))
+	})
+}
@@ -4,5 +4,8 @@ const (
 	Mainnet  = 1
 	Arbitrum = 42161
 	Base     = 8453
+	Optimism = 10
 	Katana   = 747474
+	Polygon  = 137
+	BSC      = 56
 )
@@ -0,0 +1,106 @@
+package katana
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"testing"
+
+	"github.com/defistate/defistate-client-go/engine"
+	"github.com/defistate/defistate-client-go/protocols/poolregistry"
+	uniswapv2 "github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	uniswapv3 "github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	"github.com/defistate/defistate-client-go/streams/jsonrpc/stateops"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStateOps(t *testing.T, opts ...Option) *StateOps {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ops, err := NewStateOps(logger, prometheus.NewRegistry(), opts...)
+	require.NoError(t, err)
+	return ops
+}
+
+func TestDecode_SchemaVersions(t *testing.T) {
+	pools := []uniswapv3.Pool{{
+		PoolViewMinimal: uniswapv3.PoolViewMinimal{ID: 1, Token0: 10, Token1: 11, Fee: 500, TickSpacing: 10, Tick: -7, Liquidity: big.NewInt(5000), SqrtPriceX96: big.NewInt(1 << 40)},
+		Ticks:           []uniswapv3.TickInfo{{Index: -10, LiquidityGross: big.NewInt(5000), LiquidityNet: big.NewInt(-5000)}},
+	}}
+	diff := uniswapv3.UniswapV3SystemDiff{Updates: pools, Deletions: []uint64{2}}
+
+	for _, version := range stateops.SchemaVersions {
+		t.Run(fmt.Sprintf("v%d", version), func(t *testing.T) {
+			schema := engine.ProtocolSchema(fmt.Sprintf("%s@v%d", uniswapv3.Schema.Name(), version))
+			statePayload, err := uniswapv3.MarshalPoolsJSON(pools, version)
+			require.NoError(t, err)
+			diffPayload, err := uniswapv3.MarshalDiffJSON(diff, version)
+			require.NoError(t, err)
+
+			for _, ops := range []*StateOps{newTestStateOps(t), newTestStateOps(t, WithSchemaVersion(version))} {
+				state, err := ops.DecodeStateJSON(schema, statePayload)
+				require.NoError(t, err)
+				assert.Equal(t, pools, state)
+
+				decoded, err := ops.DecodeStateDiffJSON(schema, diffPayload)
+				require.NoError(t, err)
+				assert.Equal(t, diff, decoded)
+			}
+
+			_, err = newTestStateOps(t, WithSchemaVersion(3-version)).DecodeStateJSON(schema, statePayload)
+			assert.ErrorIs(t, err, ErrIncompatibleSchemaVersion)
+		})
+	}
+}
+
+func TestDiffAndPatch_NewPool(t *testing.T) {
+	ops := newTestStateOps(t)
+
+	const (
+		registryID engine.ProtocolID = "pool_registry"
+		v2ID       engine.ProtocolID = "uniswap_v2"
+	)
+	existing := uniswapv2.Pool{ID: 1, Token0: 10, Token1: 11, Reserve0: big.NewInt(1000), Reserve1: big.NewInt(2000), FeeBps: 30}
+	added := uniswapv2.Pool{ID: 2, Token0: 11, Token1: 12, Reserve0: big.NewInt(500), Reserve1: big.NewInt(700), FeeBps: 30}
+
+	makeState := func(block int64, pools ...uniswapv2.Pool) *engine.State {
+		registry := poolregistry.PoolRegistry{Protocols: map[uint16]engine.ProtocolID{1: v2ID}}
+		for _, pool := range pools {
+			registry.Pools = append(registry.Pools, poolregistry.Pool{ID: pool.ID, Protocol: 1})
+		}
+		return &engine.State{
+			Block: engine.BlockSummary{Number: big.NewInt(block)},
+			Protocols: map[engine.ProtocolID]engine.ProtocolState{
+				registryID: {Schema: poolregistry.Schema, Data: registry},
+				v2ID:       {Schema: uniswapv2.Schema, Data: pools},
+			},
+		}
+	}
+	oldState := makeState(100, existing)
+	newState := makeState(101, existing, added)
+
+	diff, err := ops.Diff(oldState, newState)
+	require.NoError(t, err)
+
+	patched, err := ops.Patch(oldState, diff)
+	require.NoError(t, err)
+
+	registry := patched.Protocols[registryID].Data.(poolregistry.PoolRegistry)
+	require.Len(t, registry.Pools, 2)
+	assert.ElementsMatch(t, []uint64{1, 2}, []uint64{registry.Pools[0].ID, registry.Pools[1].ID})
+
+	pools := patched.Protocols[v2ID].Data.([]uniswapv2.Pool)
+	require.Len(t, pools, 2)
+	assert.ElementsMatch(t, []uint64{1, 2}, []uint64{pools[0].ID, pools[1].ID})
+	for _, pool := range pools {
+		if pool.ID == added.ID {
+			assert.Equal(t, added.Reserve1, pool.Reserve1)
+		}
+	}
+
+	// The old state is left untouched.
+	assert.Len(t, oldState.Protocols[v2ID].Data.([]uniswapv2.Pool), 1)
+}
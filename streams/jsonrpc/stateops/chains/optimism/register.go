@@ -0,0 +1,22 @@
+package optimism
+
+import (
+	"github.com/defistate/defistate-client-go/streams/jsonrpc/stateops"
+	"github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	RegisterOPStackChain(chains.Optimism)
+}
+
+// RegisterOPStackChain registers this package's StateOps for another OP-stack chain, so
+// its stream can be consumed through stateops.ForChainID without a package of its own.
+// It fits chains whose streams carry the same protocols as Optimism mainnet (token, pool
+// and token-pool registries, Uniswap V2/V3/V4 and Solidly forks such as Velodrome and
+// Aerodrome). Like stateops.Register, it panics if chainID is already registered.
+func RegisterOPStackChain(chainID uint64) {
+	stateops.Register(chainID, func(logger stateops.Logger, registry prometheus.Registerer, schemaVersion uint64) (stateops.ChainStateOps, error) {
+		return NewStateOps(logger, registry, WithSchemaVersion(schemaVersion))
+	})
+}
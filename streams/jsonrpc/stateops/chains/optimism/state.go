@@ -0,0 +1,395 @@
+package optimism
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/defistate/defistate-client-go/differ"
+	"github.com/defistate/defistate-client-go/engine"
+	"github.com/defistate/defistate-client-go/patcher"
+	poolregistry "github.com/defistate/defistate-client-go/protocols/poolregistry"
+	tokenpoolregistry "github.com/defistate/defistate-client-go/protocols/tokenpoolregistry"
+
+	solidly "github.com/defistate/defistate-client-go/protocols/solidly"
+	tokenregistry "github.com/defistate/defistate-client-go/protocols/tokenregistry"
+	uniswapv2 "github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	uniswapv3 "github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	uniswapv4 "github.com/defistate/defistate-client-go/protocols/uniswapv4"
+	"github.com/defistate/defistate-client-go/streams/jsonrpc/stateops"
+	"github.com/defistate/defistate-client-go/streams/wire"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Logger defines a standard interface for structured, leveled logging.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+var (
+	ErrUnknownSchema             = errors.New("unknown schema")
+	ErrIncompatibleSchemaVersion = stateops.ErrIncompatibleSchemaVersion
+	ErrUnsupportedSchemaVersion  = stateops.ErrUnsupportedSchemaVersion
+)
+
+// StateOps encapsulates the core business logic for processing Optimism DSE State.
+//
+// It acts as a unified facade for two critical operations:
+// 1. Differ: Calculating the delta between two states (Used by the Server/Engine).
+// 2. Patcher: Applying a delta to a previous state to reconstruct the present (Used by a Client).
+type StateOps struct {
+	*differ.StateDiffer
+	*patcher.StatePatcher
+
+	// schemaVersion pins the decoders to a single schema version.
+	// Zero accepts every version in stateops.SchemaVersions.
+	schemaVersion uint64
+	versions      stateops.VersionGate
+}
+
+// Option configures the StateOps.
+type Option func(*StateOps)
+
+// WithSchemaVersion pins the decoders to the given schema version, one of
+// stateops.SchemaVersions. Payloads carrying any other version are rejected with
+// ErrIncompatibleSchemaVersion instead of being decoded with the wrong layout.
+func WithSchemaVersion(version uint64) Option {
+	return func(ops *StateOps) {
+		ops.schemaVersion = version
+	}
+}
+
+func NewStateOps(
+	logger Logger,
+	prometheusRegistry prometheus.Registerer,
+	opts ...Option,
+) (*StateOps, error) {
+	protocolDiffers := map[engine.ProtocolSchema]differ.ProtocolDiffer{
+		tokenregistry.Schema: func(old, new any) (diff any, err error) {
+			return tokenregistry.Differ(old.([]tokenregistry.Token), new.([]tokenregistry.Token)), nil
+		},
+		poolregistry.Schema: func(old, new any) (diff any, err error) {
+			return poolregistry.Differ(old.(poolregistry.PoolRegistry), new.(poolregistry.PoolRegistry)), nil
+		},
+		tokenpoolregistry.Schema: func(old, new any) (diff any, err error) {
+			return tokenpoolregistry.TokenPoolRegistryDiffer(old.(*tokenpoolregistry.TokenPoolRegistryView), new.(*tokenpoolregistry.TokenPoolRegistryView)), nil
+		},
+		uniswapv2.Schema: func(old, new any) (diff any, err error) {
+			return uniswapv2.Differ(old.([]uniswapv2.Pool), new.([]uniswapv2.Pool)), nil
+		},
+		uniswapv3.Schema: func(old, new any) (diff any, err error) {
+			return uniswapv3.Differ(old.([]uniswapv3.Pool), new.([]uniswapv3.Pool)), nil
+		},
+		uniswapv4.Schema: func(old, new any) (diff any, err error) {
+			return uniswapv4.Differ(old.([]uniswapv4.Pool), new.([]uniswapv4.Pool)), nil
+		},
+		solidly.Schema: func(old, new any) (diff any, err error) {
+			return solidly.Differ(old.([]solidly.Pool), new.([]solidly.Pool)), nil
+		},
+	}
+
+	protocolPatchers := map[engine.ProtocolSchema]patcher.PatcherFunc{
+		tokenregistry.Schema: func(prevState, diff any) (newState any, err error) {
+			return tokenregistry.Patcher(prevState.([]tokenregistry.Token), diff.(tokenregistry.TokenSystemDiff))
+		},
+		poolregistry.Schema: func(prevState, diff any) (newState any, err error) {
+			return poolregistry.Patcher(prevState.(poolregistry.PoolRegistry), diff.(poolregistry.PoolRegistryDiff))
+		},
+		tokenpoolregistry.Schema: func(prevState, diff any) (newState any, err error) {
+			return tokenpoolregistry.TokenPoolRegistryPatcher(prevState.(*tokenpoolregistry.TokenPoolRegistryView), diff.(tokenpoolregistry.TokenPoolRegistryDiff))
+		},
+		uniswapv2.Schema: func(prevState, diff any) (newState any, err error) {
+			return uniswapv2.Patcher(prevState.([]uniswapv2.Pool), diff.(uniswapv2.UniswapV2SystemDiff))
+		},
+		uniswapv3.Schema: func(prevState, diff any) (newState any, err error) {
+			return uniswapv3.Patcher(prevState.([]uniswapv3.Pool), diff.(uniswapv3.UniswapV3SystemDiff))
+		},
+		uniswapv4.Schema: func(prevState, diff any) (newState any, err error) {
+			return uniswapv4.Patcher(prevState.([]uniswapv4.Pool), diff.(uniswapv4.UniswapV4SystemDiff))
+		},
+		solidly.Schema: func(prevState, diff any) (newState any, err error) {
+			return solidly.Patcher(prevState.([]solidly.Pool), diff.(solidly.SolidlySystemDiff))
+		},
+	}
+
+	stateDiffer, err := differ.NewStateDiffer(&differ.StateDifferConfig{
+		ProtocolDiffers: protocolDiffers,
+		Logger:          logger,
+		Registry:        prometheusRegistry,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	statePatcher, err := patcher.NewStatePatcher(&patcher.StatePatcherConfig{
+		Patchers: protocolPatchers,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ops := &StateOps{
+		StateDiffer:  stateDiffer,
+		StatePatcher: statePatcher,
+	}
+	for _, opt := range opts {
+		opt(ops)
+	}
+
+	ops.versions, err = stateops.NewVersionGate(ops.schemaVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return ops, nil
+
+}
+
+func (ops *StateOps) DecodeStateJSON(
+	schema engine.ProtocolSchema,
+	data json.RawMessage,
+) (any, error) {
+	base, version, err := ops.versions.Resolve(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	switch base {
+	case tokenregistry.Schema:
+		var typedData []tokenregistry.Token
+		err := json.Unmarshal(data, &typedData)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+
+	case poolregistry.Schema:
+		var typedData poolregistry.PoolRegistry
+		err := json.Unmarshal(data, &typedData)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case tokenpoolregistry.Schema:
+		var typedData *tokenpoolregistry.TokenPoolRegistryView
+		err := json.Unmarshal(data, &typedData)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case uniswapv2.Schema:
+		var typedData []uniswapv2.Pool
+		err := json.Unmarshal(data, &typedData)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case uniswapv3.Schema:
+		typedData, err := uniswapv3.UnmarshalPoolsJSON(data, version)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case uniswapv4.Schema:
+		typedData, err := uniswapv4.UnmarshalPoolsJSON(data, version)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case solidly.Schema:
+		var typedData []solidly.Pool
+		err := json.Unmarshal(data, &typedData)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSchema, schema)
+	}
+}
+
+func (ops *StateOps) DecodeStateDiffJSON(
+	schema engine.ProtocolSchema,
+	data json.RawMessage,
+) (any, error) {
+	base, version, err := ops.versions.Resolve(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	switch base {
+	case tokenregistry.Schema:
+		var typedData tokenregistry.TokenSystemDiff
+		err := json.Unmarshal(data, &typedData)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+
+	case poolregistry.Schema:
+		var typedData poolregistry.PoolRegistryDiff
+		err := json.Unmarshal(data, &typedData)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case tokenpoolregistry.Schema:
+		var typedData tokenpoolregistry.TokenPoolRegistryDiff
+		err := json.Unmarshal(data, &typedData)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case uniswapv2.Schema:
+		var typedData uniswapv2.UniswapV2SystemDiff
+		err := json.Unmarshal(data, &typedData)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case uniswapv3.Schema:
+		typedData, err := uniswapv3.UnmarshalDiffJSON(data, version)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case uniswapv4.Schema:
+		typedData, err := uniswapv4.UnmarshalDiffJSON(data, version)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case solidly.Schema:
+		var typedData solidly.SolidlySystemDiff
+		err := json.Unmarshal(data, &typedData)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSchema, schema)
+	}
+}
+
+// DecodeStateBinary decodes protocol state data sent in the client's binary codec (see
+// client.CodecBinary) into the same typed values as DecodeStateJSON.
+func (ops *StateOps) DecodeStateBinary(
+	schema engine.ProtocolSchema,
+	data []byte,
+) (any, error) {
+	base, _, err := ops.versions.Resolve(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	switch base {
+	case tokenregistry.Schema:
+		typedData, err := wire.Unmarshal[[]tokenregistry.Token](data)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+
+	case poolregistry.Schema:
+		typedData, err := wire.Unmarshal[poolregistry.PoolRegistry](data)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case tokenpoolregistry.Schema:
+		typedData, err := wire.Unmarshal[*tokenpoolregistry.TokenPoolRegistryView](data)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case uniswapv2.Schema:
+		typedData, err := wire.Unmarshal[[]uniswapv2.Pool](data)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case uniswapv3.Schema:
+		typedData, err := wire.Unmarshal[[]uniswapv3.Pool](data)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case uniswapv4.Schema:
+		typedData, err := wire.Unmarshal[[]uniswapv4.Pool](data)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case solidly.Schema:
+		typedData, err := wire.Unmarshal[[]solidly.Pool](data)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSchema, schema)
+	}
+}
+
+// DecodeStateDiffBinary decodes protocol diff data sent in the client's binary codec (see
+// client.CodecBinary) into the same typed values as DecodeStateDiffJSON.
+func (ops *StateOps) DecodeStateDiffBinary(
+	schema engine.ProtocolSchema,
+	data []byte,
+) (any, error) {
+	base, _, err := ops.versions.Resolve(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	switch base {
+	case tokenregistry.Schema:
+		typedData, err := wire.Unmarshal[tokenregistry.TokenSystemDiff](data)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+
+	case poolregistry.Schema:
+		typedData, err := wire.Unmarshal[poolregistry.PoolRegistryDiff](data)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case tokenpoolregistry.Schema:
+		typedData, err := wire.Unmarshal[tokenpoolregistry.TokenPoolRegistryDiff](data)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case uniswapv2.Schema:
+		typedData, err := wire.Unmarshal[uniswapv2.UniswapV2SystemDiff](data)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case uniswapv3.Schema:
+		typedData, err := wire.Unmarshal[uniswapv3.UniswapV3SystemDiff](data)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case uniswapv4.Schema:
+		typedData, err := wire.Unmarshal[uniswapv4.UniswapV4SystemDiff](data)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	case solidly.Schema:
+		typedData, err := wire.Unmarshal[solidly.SolidlySystemDiff](data)
+		if err != nil {
+			return nil, err
+		}
+		return typedData, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSchema, schema)
+	}
+}
@@ -0,0 +1,92 @@
+// Package stateops resolves the StateOps of a chain by chain ID. The chain packages under
+// stateops/chains register themselves when imported; import stateops/chains/all to make
+// every supported chain available:
+//
+//	import _ "github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains/all"
+//
+//	ops, err := stateops.ForChainID(cfg.ChainID, logger, registry, cfg.SchemaVersion)
+package stateops
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/defistate/defistate-client-go/differ"
+	"github.com/defistate/defistate-client-go/engine"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrUnknownChain is returned by ForChainID for a chain without registered StateOps.
+var ErrUnknownChain = errors.New("stateops: unknown chain")
+
+// Logger defines a standard interface for structured, leveled logging.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// ChainStateOps is the state handling of one chain: diffing and patching states, and
+// decoding the protocol data of the stream in either codec.
+type ChainStateOps interface {
+	Diff(old *engine.State, new *engine.State) (*differ.StateDiff, error)
+	Patch(oldState *engine.State, diff *differ.StateDiff) (*engine.State, error)
+	// SetConcurrency sets the number of workers Patch applies protocol diffs with. Zero or
+	// one patches them on the calling goroutine.
+	SetConcurrency(n int)
+	DecodeStateJSON(schema engine.ProtocolSchema, data json.RawMessage) (any, error)
+	DecodeStateDiffJSON(schema engine.ProtocolSchema, data json.RawMessage) (any, error)
+	DecodeStateBinary(schema engine.ProtocolSchema, data []byte) (any, error)
+	DecodeStateDiffBinary(schema engine.ProtocolSchema, data []byte) (any, error)
+}
+
+// Factory creates the StateOps of a chain, pinned to schemaVersion unless it is zero.
+type Factory func(logger Logger, registry prometheus.Registerer, schemaVersion uint64) (ChainStateOps, error)
+
+var (
+	mu        sync.RWMutex
+	factories = make(map[uint64]Factory)
+)
+
+// Register makes factory the StateOps of chainID. It is meant to be called from the init
+// function of the chain's package, and panics if chainID is registered twice or factory
+// is nil.
+func Register(chainID uint64, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if factory == nil {
+		panic(fmt.Sprintf("stateops: Register factory for chain %d is nil", chainID))
+	}
+	if _, dup := factories[chainID]; dup {
+		panic(fmt.Sprintf("stateops: Register called twice for chain %d", chainID))
+	}
+	factories[chainID] = factory
+}
+
+// ForChainID creates the StateOps registered for chainID. It returns ErrUnknownChain if
+// the chain has none, e.g. because its package was not imported.
+func ForChainID(chainID uint64, logger Logger, registry prometheus.Registerer, schemaVersion uint64) (ChainStateOps, error) {
+	mu.RLock()
+	factory, ok := factories[chainID]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownChain, chainID)
+	}
+	return factory(logger, registry, schemaVersion)
+}
+
+// ChainIDs returns the registered chain IDs in ascending order.
+func ChainIDs() []uint64 {
+	mu.RLock()
+	defer mu.RUnlock()
+	ids := make([]uint64, 0, len(factories))
+	for id := range factories {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
@@ -0,0 +1,39 @@
+package stateops_test
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/defistate/defistate-client-go/streams/jsonrpc/stateops"
+	"github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains"
+	_ "github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains/all"
+	ethstateops "github.com/defistate/defistate-client-go/streams/jsonrpc/stateops/chains/ethereum"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForChainID(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	assert.Equal(t, []uint64{chains.Mainnet, chains.Optimism, chains.BSC, chains.Polygon, chains.Base, chains.Arbitrum, chains.Katana}, stateops.ChainIDs())
+
+	ops, err := stateops.ForChainID(chains.Mainnet, logger, prometheus.NewRegistry(), 1)
+	require.NoError(t, err)
+	assert.IsType(t, &ethstateops.StateOps{}, ops)
+
+	_, err = stateops.ForChainID(chains.Mainnet, logger, prometheus.NewRegistry(), 3)
+	assert.ErrorIs(t, err, ethstateops.ErrUnsupportedSchemaVersion)
+
+	_, err = stateops.ForChainID(999, logger, prometheus.NewRegistry(), 0)
+	assert.ErrorIs(t, err, stateops.ErrUnknownChain)
+}
+
+func TestRegister_PanicsOnDuplicate(t *testing.T) {
+	factory := func(stateops.Logger, prometheus.Registerer, uint64) (stateops.ChainStateOps, error) {
+		return nil, nil
+	}
+	assert.Panics(t, func() { stateops.Register(chains.Mainnet, factory) })
+	assert.Panics(t, func() { stateops.Register(12345, nil) })
+}
@@ -0,0 +1,54 @@
+package stateops
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/defistate/defistate-client-go/engine"
+)
+
+var (
+	// ErrIncompatibleSchemaVersion is returned for a payload whose schema version differs
+	// from the version the decoders are pinned to.
+	ErrIncompatibleSchemaVersion = errors.New("incompatible schema version")
+	// ErrUnsupportedSchemaVersion is returned for pinning the decoders to a version they
+	// cannot decode.
+	ErrUnsupportedSchemaVersion = errors.New("unsupported schema version")
+)
+
+// SchemaVersions lists the schema versions the decoders understand. Every schema exists
+// in each of them: v2 changes only the JSON layout of Uniswap V3 and V4 style pools,
+// whose ticks are packed as [index, liquidityGross, liquidityNet] arrays (see
+// uniswapv3.PackedTicks). The binary codec is the same for both.
+var SchemaVersions = []uint64{1, 2}
+
+// VersionGate resolves the versioned schemas of payloads for the decoders of a chain,
+// which are keyed by the v1 schemas, optionally pinning them to a single version.
+type VersionGate struct {
+	pinned uint64
+}
+
+// NewVersionGate returns a gate pinned to version, or one accepting every version in
+// SchemaVersions if version is zero.
+func NewVersionGate(version uint64) (VersionGate, error) {
+	if version != 0 && !slices.Contains(SchemaVersions, version) {
+		return VersionGate{}, fmt.Errorf("%w: v%d", ErrUnsupportedSchemaVersion, version)
+	}
+	return VersionGate{pinned: version}, nil
+}
+
+// Resolve returns the v1 schema the decoders know schema by, and the version whose
+// layout its data has. It rejects a schema of another version than the pinned one with
+// ErrIncompatibleSchemaVersion. A schema of an unknown version is returned unchanged, so
+// decoding it fails as an unknown schema.
+func (g VersionGate) Resolve(schema engine.ProtocolSchema) (base engine.ProtocolSchema, version uint64, err error) {
+	version, ok := schema.Version()
+	if g.pinned != 0 && (!ok || version != g.pinned) {
+		return "", 0, fmt.Errorf("%w: %q, decoder is pinned to v%d", ErrIncompatibleSchemaVersion, schema, g.pinned)
+	}
+	if !ok || !slices.Contains(SchemaVersions, version) {
+		return schema, version, nil
+	}
+	return engine.ProtocolSchema(schema.Name() + "@v1"), version, nil
+}
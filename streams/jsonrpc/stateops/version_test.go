@@ -0,0 +1,59 @@
+package stateops_test
+
+import (
+	"testing"
+
+	"github.com/defistate/defistate-client-go/engine"
+	"github.com/defistate/defistate-client-go/streams/jsonrpc/stateops"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionGate(t *testing.T) {
+	const (
+		v1 engine.ProtocolSchema = "defistate/uniswap-v3@v1"
+		v2 engine.ProtocolSchema = "defistate/uniswap-v3@v2"
+		v3 engine.ProtocolSchema = "defistate/uniswap-v3@v3"
+	)
+
+	t.Run("unpinned", func(t *testing.T) {
+		gate, err := stateops.NewVersionGate(0)
+		require.NoError(t, err)
+
+		base, version, err := gate.Resolve(v1)
+		require.NoError(t, err)
+		assert.Equal(t, v1, base)
+		assert.Equal(t, uint64(1), version)
+
+		base, version, err = gate.Resolve(v2)
+		require.NoError(t, err)
+		assert.Equal(t, v1, base, "v2 payloads are decoded by the v1 schema's decoder")
+		assert.Equal(t, uint64(2), version)
+
+		base, _, err = gate.Resolve(v3)
+		require.NoError(t, err)
+		assert.Equal(t, v3, base, "unknown versions are left for the decoder to reject")
+
+		base, _, err = gate.Resolve("defistate/unversioned")
+		require.NoError(t, err)
+		assert.Equal(t, engine.ProtocolSchema("defistate/unversioned"), base)
+	})
+
+	t.Run("pinned", func(t *testing.T) {
+		gate, err := stateops.NewVersionGate(2)
+		require.NoError(t, err)
+
+		base, version, err := gate.Resolve(v2)
+		require.NoError(t, err)
+		assert.Equal(t, v1, base)
+		assert.Equal(t, uint64(2), version)
+
+		for _, schema := range []engine.ProtocolSchema{v1, v3, "defistate/unversioned"} {
+			_, _, err = gate.Resolve(schema)
+			assert.ErrorIs(t, err, stateops.ErrIncompatibleSchemaVersion, schema)
+		}
+	})
+
+	_, err := stateops.NewVersionGate(3)
+	assert.ErrorIs(t, err, stateops.ErrUnsupportedSchemaVersion)
+}
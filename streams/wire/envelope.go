@@ -0,0 +1,137 @@
+package wire
+
+import (
+	"slices"
+
+	"github.com/defistate/defistate-client-go/engine"
+)
+
+// ProtocolData is a protocol's state or diff with its data still encoded, so the data
+// can be decoded according to Schema.
+type ProtocolData struct {
+	Meta              engine.ProtocolMeta
+	SyncedBlockNumber *uint64
+	Schema            engine.ProtocolSchema
+	Error             string
+	Data              []byte
+}
+
+// State is the envelope of a full state.
+type State struct {
+	ChainID   uint64
+	Timestamp uint64
+	Block     engine.BlockSummary
+	Protocols map[engine.ProtocolID]ProtocolData
+}
+
+// StateDiff is the envelope of a state diff.
+type StateDiff struct {
+	FromBlock uint64
+	ToBlock   engine.BlockSummary
+	Timestamp uint64
+	Protocols map[engine.ProtocolID]ProtocolData
+}
+
+func (w *writer) state(s *State) {
+	w.uint(s.ChainID)
+	w.uint(s.Timestamp)
+	w.block(s.Block)
+	w.protocols(s.Protocols)
+}
+
+func (r *reader) state() *State {
+	return &State{
+		ChainID:   r.uint(),
+		Timestamp: r.uint(),
+		Block:     r.block(),
+		Protocols: r.protocols(),
+	}
+}
+
+func (w *writer) stateDiff(d *StateDiff) {
+	w.uint(d.FromBlock)
+	w.block(d.ToBlock)
+	w.uint(d.Timestamp)
+	w.protocols(d.Protocols)
+}
+
+func (r *reader) stateDiff() *StateDiff {
+	return &StateDiff{
+		FromBlock: r.uint(),
+		ToBlock:   r.block(),
+		Timestamp: r.uint(),
+		Protocols: r.protocols(),
+	}
+}
+
+func (w *writer) block(b engine.BlockSummary) {
+	w.bigInt(b.Number)
+	w.fixed(b.Hash[:])
+	w.uint(b.Timestamp)
+	w.int(b.ReceivedAt)
+	w.uint(b.GasUsed)
+	w.uint(b.GasLimit)
+	w.bigInt(b.BaseFee)
+	w.fixed(b.StateRoot[:])
+	w.fixed(b.TxHash[:])
+	w.fixed(b.ReceiptHash[:])
+}
+
+func (r *reader) block() (b engine.BlockSummary) {
+	b.Number = r.bigInt()
+	r.fixed(b.Hash[:])
+	b.Timestamp = r.uint()
+	b.ReceivedAt = r.int()
+	b.GasUsed = r.uint()
+	b.GasLimit = r.uint()
+	b.BaseFee = r.bigInt()
+	r.fixed(b.StateRoot[:])
+	r.fixed(b.TxHash[:])
+	r.fixed(b.ReceiptHash[:])
+	return b
+}
+
+// protocols writes the protocols in ascending ID order, each as its ID followed by its
+// data.
+func (w *writer) protocols(m map[engine.ProtocolID]ProtocolData) {
+	if !w.length(m == nil, len(m)) {
+		return
+	}
+	ids := make([]engine.ProtocolID, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		p := m[id]
+		w.string(string(id))
+		w.string(string(p.Meta.Name))
+		writeSlice(w, p.Meta.Tags, (*writer).string)
+		w.optUint(p.SyncedBlockNumber)
+		w.string(string(p.Schema))
+		w.string(p.Error)
+		w.bytes(p.Data)
+	}
+}
+
+func (r *reader) protocols() map[engine.ProtocolID]ProtocolData {
+	n, ok := r.length(7)
+	if !ok {
+		return nil
+	}
+	m := make(map[engine.ProtocolID]ProtocolData, n)
+	for i := 0; i < n; i++ {
+		id := engine.ProtocolID(r.string())
+		m[id] = ProtocolData{
+			Meta: engine.ProtocolMeta{
+				Name: engine.ProtocolName(r.string()),
+				Tags: readSlice(r, 1, (*reader).string),
+			},
+			SyncedBlockNumber: r.optUint(),
+			Schema:            engine.ProtocolSchema(r.string()),
+			Error:             r.string(),
+			Data:              r.bytes(),
+		}
+	}
+	return m
+}
@@ -0,0 +1,443 @@
+package wire
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/defistate/defistate-client-go/engine"
+	"github.com/defistate/defistate-client-go/protocols/poolregistry"
+	"github.com/defistate/defistate-client-go/protocols/solidly"
+	"github.com/defistate/defistate-client-go/protocols/tokenpoolregistry"
+	"github.com/defistate/defistate-client-go/protocols/tokenregistry"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv4"
+)
+
+// Marshal returns the encoding of v, which must be one of the types Unmarshal decodes.
+func Marshal(v any) ([]byte, error) {
+	w := &writer{}
+	switch v := v.(type) {
+	case *State:
+		w.state(v)
+	case *StateDiff:
+		w.stateDiff(v)
+	case []tokenregistry.Token:
+		writeSlice(w, v, (*writer).token)
+	case tokenregistry.TokenSystemDiff:
+		writeSlice(w, v.Additions, (*writer).token)
+		writeSlice(w, v.Updates, (*writer).token)
+		writeSlice(w, v.Deletions, (*writer).uint)
+	case poolregistry.PoolRegistry:
+		writeSlice(w, v.Pools, (*writer).registryPool)
+		w.protocolIDs(v.Protocols)
+	case poolregistry.PoolRegistryDiff:
+		writeSlice(w, v.PoolAdditions, (*writer).registryPool)
+		writeSlice(w, v.PoolDeletions, (*writer).uint)
+		w.protocolIDs(v.ProtocolAdditions)
+		writeSlice(w, v.ProtocolDeletions, func(w *writer, id uint16) { w.uint(uint64(id)) })
+	case *tokenpoolregistry.TokenPoolRegistryView:
+		w.tokenPoolView(v)
+	case tokenpoolregistry.TokenPoolRegistryDiff:
+		w.tokenPoolView(v.Data)
+	case []uniswapv2.Pool:
+		writeSlice(w, v, (*writer).uniswapV2Pool)
+	case uniswapv2.UniswapV2SystemDiff:
+		writeSlice(w, v.Additions, (*writer).uniswapV2Pool)
+		writeSlice(w, v.Updates, (*writer).uniswapV2Pool)
+		writeSlice(w, v.Deletions, (*writer).uint)
+	case []uniswapv3.Pool:
+		writeSlice(w, v, (*writer).uniswapV3Pool)
+	case uniswapv3.UniswapV3SystemDiff:
+		writeSlice(w, v.Additions, (*writer).uniswapV3Pool)
+		writeSlice(w, v.Updates, (*writer).uniswapV3Pool)
+		writeSlice(w, v.Deletions, (*writer).uint)
+	case []uniswapv4.Pool:
+		writeSlice(w, v, (*writer).uniswapV4Pool)
+	case uniswapv4.UniswapV4SystemDiff:
+		writeSlice(w, v.Additions, (*writer).uniswapV4Pool)
+		writeSlice(w, v.Updates, (*writer).uniswapV4Pool)
+		writeSlice(w, v.Deletions, (*writer).uint)
+	case []solidly.Pool:
+		writeSlice(w, v, (*writer).solidlyPool)
+	case solidly.SolidlySystemDiff:
+		writeSlice(w, v.Additions, (*writer).solidlyPool)
+		writeSlice(w, v.Updates, (*writer).solidlyPool)
+		writeSlice(w, v.Deletions, (*writer).uint)
+	default:
+		return nil, fmt.Errorf("wire: unsupported type %T", v)
+	}
+	return w.buf, nil
+}
+
+// Unmarshal decodes data, as returned by Marshal, into a T: *State, *StateDiff, or the
+// typed state or diff data of a protocol, e.g. []uniswapv3.Pool or
+// uniswapv3.UniswapV3SystemDiff. The whole of data must be consumed.
+func Unmarshal[T any](data []byte) (T, error) {
+	var out T
+	r := &reader{buf: data}
+	switch v := any(&out).(type) {
+	case **State:
+		*v = r.state()
+	case **StateDiff:
+		*v = r.stateDiff()
+	case *[]tokenregistry.Token:
+		*v = readSlice(r, 32, (*reader).token)
+	case *tokenregistry.TokenSystemDiff:
+		v.Additions = readSlice(r, 32, (*reader).token)
+		v.Updates = readSlice(r, 32, (*reader).token)
+		v.Deletions = readSlice(r, 1, (*reader).uint)
+	case *poolregistry.PoolRegistry:
+		v.Pools = readSlice(r, 34, (*reader).registryPool)
+		v.Protocols = r.protocolIDs()
+	case *poolregistry.PoolRegistryDiff:
+		v.PoolAdditions = readSlice(r, 34, (*reader).registryPool)
+		v.PoolDeletions = readSlice(r, 1, (*reader).uint)
+		v.ProtocolAdditions = r.protocolIDs()
+		v.ProtocolDeletions = readSlice(r, 1, func(r *reader) uint16 { return uint16(r.uintN(16)) })
+	case **tokenpoolregistry.TokenPoolRegistryView:
+		*v = r.tokenPoolView()
+	case *tokenpoolregistry.TokenPoolRegistryDiff:
+		v.Data = r.tokenPoolView()
+	case *[]uniswapv2.Pool:
+		*v = readSlice(r, 10, (*reader).uniswapV2Pool)
+	case *uniswapv2.UniswapV2SystemDiff:
+		v.Additions = readSlice(r, 10, (*reader).uniswapV2Pool)
+		v.Updates = readSlice(r, 10, (*reader).uniswapV2Pool)
+		v.Deletions = readSlice(r, 1, (*reader).uint)
+	case *[]uniswapv3.Pool:
+		*v = readSlice(r, 20, (*reader).uniswapV3Pool)
+	case *uniswapv3.UniswapV3SystemDiff:
+		v.Additions = readSlice(r, 20, (*reader).uniswapV3Pool)
+		v.Updates = readSlice(r, 20, (*reader).uniswapV3Pool)
+		v.Deletions = readSlice(r, 1, (*reader).uint)
+	case *[]uniswapv4.Pool:
+		*v = readSlice(r, 64, (*reader).uniswapV4Pool)
+	case *uniswapv4.UniswapV4SystemDiff:
+		v.Additions = readSlice(r, 64, (*reader).uniswapV4Pool)
+		v.Updates = readSlice(r, 64, (*reader).uniswapV4Pool)
+		v.Deletions = readSlice(r, 1, (*reader).uint)
+	case *[]solidly.Pool:
+		*v = readSlice(r, 10, (*reader).solidlyPool)
+	case *solidly.SolidlySystemDiff:
+		v.Additions = readSlice(r, 10, (*reader).solidlyPool)
+		v.Updates = readSlice(r, 10, (*reader).solidlyPool)
+		v.Deletions = readSlice(r, 1, (*reader).uint)
+	default:
+		return out, fmt.Errorf("wire: unsupported type %T", out)
+	}
+	if r.err == nil && len(r.buf) != 0 {
+		r.err = fmt.Errorf("wire: %d trailing bytes", len(r.buf))
+	}
+	if r.err != nil {
+		var zero T
+		return zero, r.err
+	}
+	return out, nil
+}
+
+func writeSlice[E any](w *writer, s []E, write func(*writer, E)) {
+	if w.length(s == nil, len(s)) {
+		for _, e := range s {
+			write(w, e)
+		}
+	}
+}
+
+// readSlice reads a slice written by writeSlice whose elements take at least minSize
+// bytes each.
+func readSlice[E any](r *reader, minSize int, read func(*reader) E) []E {
+	n, ok := r.length(minSize)
+	if !ok {
+		return nil
+	}
+	s := make([]E, n)
+	for i := range s {
+		s[i] = read(r)
+	}
+	return s
+}
+
+func (w *writer) ints(s []int) {
+	writeSlice(w, s, func(w *writer, v int) { w.int(int64(v)) })
+}
+
+func (r *reader) ints() []int {
+	return readSlice(r, 1, func(r *reader) int { return int(r.int()) })
+}
+
+func (w *writer) optUint8(v *uint8) {
+	w.bool(v != nil)
+	if v != nil {
+		w.uint(uint64(*v))
+	}
+}
+
+func (r *reader) optUint8() *uint8 {
+	v := r.optUintN(8)
+	if v == nil {
+		return nil
+	}
+	u := uint8(*v)
+	return &u
+}
+
+func (w *writer) optUint32(v *uint32) {
+	w.bool(v != nil)
+	if v != nil {
+		w.uint(uint64(*v))
+	}
+}
+
+func (r *reader) optUint32() *uint32 {
+	v := r.optUintN(32)
+	if v == nil {
+		return nil
+	}
+	u := uint32(*v)
+	return &u
+}
+
+func (w *writer) token(t tokenregistry.Token) {
+	w.uint(t.ID)
+	w.fixed(t.Address[:])
+	w.string(t.Name)
+	w.string(t.Symbol)
+	w.uint(uint64(t.Decimals))
+	w.float(t.FeeOnTransferPercent)
+	w.uint(t.GasForTransfer)
+}
+
+func (r *reader) token() (t tokenregistry.Token) {
+	t.ID = r.uint()
+	r.fixed(t.Address[:])
+	t.Name = r.string()
+	t.Symbol = r.string()
+	t.Decimals = uint8(r.uintN(8))
+	t.FeeOnTransferPercent = r.float()
+	t.GasForTransfer = r.uint()
+	return t
+}
+
+func (w *writer) registryPool(p poolregistry.Pool) {
+	w.uint(p.ID)
+	w.fixed(p.Key[:])
+	w.uint(uint64(p.Protocol))
+}
+
+func (r *reader) registryPool() (p poolregistry.Pool) {
+	p.ID = r.uint()
+	r.fixed(p.Key[:])
+	p.Protocol = uint16(r.uintN(16))
+	return p
+}
+
+func (w *writer) protocolIDs(m map[uint16]engine.ProtocolID) {
+	if !w.length(m == nil, len(m)) {
+		return
+	}
+	keys := make([]uint16, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		w.uint(uint64(k))
+		w.string(string(m[k]))
+	}
+}
+
+func (r *reader) protocolIDs() map[uint16]engine.ProtocolID {
+	n, ok := r.length(2)
+	if !ok {
+		return nil
+	}
+	m := make(map[uint16]engine.ProtocolID, n)
+	for i := 0; i < n; i++ {
+		k := uint16(r.uintN(16))
+		m[k] = engine.ProtocolID(r.string())
+	}
+	return m
+}
+
+// tokenPoolView writes a presence byte, then the view's fields.
+func (w *writer) tokenPoolView(v *tokenpoolregistry.TokenPoolRegistryView) {
+	w.bool(v != nil)
+	if v == nil {
+		return
+	}
+	writeSlice(w, v.Tokens, (*writer).uint)
+	writeSlice(w, v.Pools, (*writer).uint)
+	writeSlice(w, v.Adjacency, (*writer).ints)
+	w.ints(v.EdgeTargets)
+	writeSlice(w, v.EdgePools, (*writer).ints)
+}
+
+func (r *reader) tokenPoolView() *tokenpoolregistry.TokenPoolRegistryView {
+	if !r.bool() {
+		return nil
+	}
+	return &tokenpoolregistry.TokenPoolRegistryView{
+		Tokens:      readSlice(r, 1, (*reader).uint),
+		Pools:       readSlice(r, 1, (*reader).uint),
+		Adjacency:   readSlice(r, 1, (*reader).ints),
+		EdgeTargets: r.ints(),
+		EdgePools:   readSlice(r, 1, (*reader).ints),
+	}
+}
+
+// uniswapV2Pool writes FeeBps as an optional value, absent for a pool without a fee of
+// its own (see uniswapv2.Pool.HasFeeBps).
+func (w *writer) uniswapV2Pool(p uniswapv2.Pool) {
+	w.uint(p.ID)
+	w.uint(p.Token0)
+	w.uint(p.Token1)
+	w.bigInt(p.Reserve0)
+	w.bigInt(p.Reserve1)
+	w.uint(uint64(p.Type))
+	w.bool(p.HasFeeBps())
+	if p.HasFeeBps() {
+		w.uint(uint64(p.FeeBps))
+	}
+	w.optUint8(p.Decimals0)
+	w.optUint8(p.Decimals1)
+	w.optUint(p.LastUpdatedBlock)
+}
+
+func (r *reader) uniswapV2Pool() (p uniswapv2.Pool) {
+	p.ID = r.uint()
+	p.Token0 = r.uint()
+	p.Token1 = r.uint()
+	p.Reserve0 = r.bigInt()
+	p.Reserve1 = r.bigInt()
+	p.Type = uint8(r.uintN(8))
+	if r.bool() {
+		p.FeeBps = uint16(r.uintN(16))
+	} else {
+		p.ClearFeeBps()
+	}
+	p.Decimals0 = r.optUint8()
+	p.Decimals1 = r.optUint8()
+	p.LastUpdatedBlock = r.optUint()
+	return p
+}
+
+func (w *writer) tick(t uniswapv3.TickInfo) {
+	w.int(t.Index)
+	w.bigInt(t.LiquidityGross)
+	w.bigInt(t.LiquidityNet)
+}
+
+func (r *reader) tick() (t uniswapv3.TickInfo) {
+	t.Index = r.int()
+	t.LiquidityGross = r.bigInt()
+	t.LiquidityNet = r.bigInt()
+	return t
+}
+
+func (w *writer) uniswapV3Pool(p uniswapv3.Pool) {
+	w.uint(p.ID)
+	w.uint(p.Token0)
+	w.uint(p.Token1)
+	w.uint(p.Fee)
+	w.uint(p.TickSpacing)
+	w.int(p.Tick)
+	w.bigInt(p.Liquidity)
+	w.bigInt(p.SqrtPriceX96)
+	writeSlice(w, p.Ticks, (*writer).tick)
+	w.bigInt(p.FeeGrowthGlobal0X128)
+	w.bigInt(p.FeeGrowthGlobal1X128)
+	w.bigInt(p.ProtocolFees0)
+	w.bigInt(p.ProtocolFees1)
+	w.optUint(p.FeeOverride)
+	w.optUint32(p.FeeProtocol)
+	w.uint(uint64(p.ProtocolFeeDenominator))
+	w.optUint8(p.Decimals0)
+	w.optUint8(p.Decimals1)
+	w.optUint(p.LastUpdatedBlock)
+}
+
+func (r *reader) uniswapV3Pool() (p uniswapv3.Pool) {
+	p.ID = r.uint()
+	p.Token0 = r.uint()
+	p.Token1 = r.uint()
+	p.Fee = r.uint()
+	p.TickSpacing = r.uint()
+	p.Tick = r.int()
+	p.Liquidity = r.bigInt()
+	p.SqrtPriceX96 = r.bigInt()
+	p.Ticks = readSlice(r, 3, (*reader).tick)
+	p.FeeGrowthGlobal0X128 = r.bigInt()
+	p.FeeGrowthGlobal1X128 = r.bigInt()
+	p.ProtocolFees0 = r.bigInt()
+	p.ProtocolFees1 = r.bigInt()
+	p.FeeOverride = r.optUint()
+	p.FeeProtocol = r.optUint32()
+	p.ProtocolFeeDenominator = uint32(r.uintN(32))
+	p.Decimals0 = r.optUint8()
+	p.Decimals1 = r.optUint8()
+	p.LastUpdatedBlock = r.optUint()
+	return p
+}
+
+func (w *writer) uniswapV4Pool(p uniswapv4.Pool) {
+	w.uint(p.ID)
+	w.fixed(p.PoolID[:])
+	w.uint(p.Token0)
+	w.uint(p.Token1)
+	w.uint(p.Fee)
+	w.uint(p.TickSpacing)
+	w.fixed(p.Hooks[:])
+	w.int(p.Tick)
+	w.bigInt(p.Liquidity)
+	w.bigInt(p.SqrtPriceX96)
+	writeSlice(w, p.Ticks, (*writer).tick)
+	w.optUint(p.LPFee)
+	w.optUint(p.FeeOverride)
+	w.optUint(p.LastUpdatedBlock)
+}
+
+func (r *reader) uniswapV4Pool() (p uniswapv4.Pool) {
+	p.ID = r.uint()
+	r.fixed(p.PoolID[:])
+	p.Token0 = r.uint()
+	p.Token1 = r.uint()
+	p.Fee = r.uint()
+	p.TickSpacing = r.uint()
+	r.fixed(p.Hooks[:])
+	p.Tick = r.int()
+	p.Liquidity = r.bigInt()
+	p.SqrtPriceX96 = r.bigInt()
+	p.Ticks = readSlice(r, 3, (*reader).tick)
+	p.LPFee = r.optUint()
+	p.FeeOverride = r.optUint()
+	p.LastUpdatedBlock = r.optUint()
+	return p
+}
+
+func (w *writer) solidlyPool(p solidly.Pool) {
+	w.uint(p.ID)
+	w.uint(p.Token0)
+	w.uint(p.Token1)
+	w.bigInt(p.Reserve0)
+	w.bigInt(p.Reserve1)
+	w.bool(p.Stable)
+	w.uint(uint64(p.FeeBps))
+	w.uint(uint64(p.Decimals0))
+	w.uint(uint64(p.Decimals1))
+	w.optUint(p.LastUpdatedBlock)
+}
+
+func (r *reader) solidlyPool() (p solidly.Pool) {
+	p.ID = r.uint()
+	p.Token0 = r.uint()
+	p.Token1 = r.uint()
+	p.Reserve0 = r.bigInt()
+	p.Reserve1 = r.bigInt()
+	p.Stable = r.bool()
+	p.FeeBps = uint16(r.uintN(16))
+	p.Decimals0 = uint8(r.uintN(8))
+	p.Decimals1 = uint8(r.uintN(8))
+	p.LastUpdatedBlock = r.optUint()
+	return p
+}
@@ -0,0 +1,261 @@
+// Package wire is the compact binary encoding of state stream payloads (see
+// client.CodecBinary). Every type has an explicit layout, written field by field in
+// declaration order, so the format is stable and can be implemented in any language:
+//
+//   - unsigned integers are uvarints and signed integers zigzag varints, as in
+//     encoding/binary and protobuf;
+//   - bools are one byte, 0 or 1, and float64s eight bytes of little-endian IEEE 754;
+//   - addresses, pool keys and hashes are their raw fixed-size bytes;
+//   - strings and byte slices are a uvarint length followed by the bytes;
+//   - a *big.Int is a uvarint tag: 0 for nil, otherwise 1 + (len << 1 | negative),
+//     followed by len bytes of big-endian magnitude, so zero and nil differ;
+//   - optional scalars (pointers) are a presence byte followed by the value if present;
+//   - slices and maps are a uvarint tag, 0 for nil and n+1 for n elements, followed by
+//     the elements; map entries are written in ascending key order.
+//
+// Zero values are always written, so unlike gob or omitempty JSON, a pointer to zero
+// stays a pointer to zero and an empty slice stays empty.
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// errTruncated is returned for data that ends in the middle of a value.
+var errTruncated = errors.New("wire: truncated data")
+
+type writer struct {
+	buf []byte
+}
+
+func (w *writer) uint(v uint64) {
+	w.buf = binary.AppendUvarint(w.buf, v)
+}
+
+func (w *writer) int(v int64) {
+	w.buf = binary.AppendVarint(w.buf, v)
+}
+
+func (w *writer) bool(v bool) {
+	if v {
+		w.buf = append(w.buf, 1)
+	} else {
+		w.buf = append(w.buf, 0)
+	}
+}
+
+func (w *writer) float(v float64) {
+	w.buf = binary.LittleEndian.AppendUint64(w.buf, math.Float64bits(v))
+}
+
+func (w *writer) fixed(b []byte) {
+	w.buf = append(w.buf, b...)
+}
+
+func (w *writer) string(s string) {
+	w.uint(uint64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+// bytes writes b, keeping nil apart from empty.
+func (w *writer) bytes(b []byte) {
+	if w.length(b == nil, len(b)) {
+		w.buf = append(w.buf, b...)
+	}
+}
+
+// length writes the tag of a slice or map and reports whether elements follow.
+func (w *writer) length(isNil bool, n int) bool {
+	if isNil {
+		w.uint(0)
+		return false
+	}
+	w.uint(uint64(n) + 1)
+	return true
+}
+
+func (w *writer) bigInt(x *big.Int) {
+	if x == nil {
+		w.uint(0)
+		return
+	}
+	n := (x.BitLen() + 7) / 8
+	tag := uint64(n) << 1
+	if x.Sign() < 0 {
+		tag |= 1
+	}
+	w.uint(tag + 1)
+	start := len(w.buf)
+	w.buf = append(w.buf, make([]byte, n)...)
+	x.FillBytes(w.buf[start:])
+}
+
+func (w *writer) optUint(v *uint64) {
+	w.bool(v != nil)
+	if v != nil {
+		w.uint(*v)
+	}
+}
+
+// reader decodes values from buf. The first error sticks: later reads return zero
+// values, so decoders check err once at the end.
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+	r.buf = nil
+}
+
+func (r *reader) uint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		r.fail(errTruncated)
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *reader) int() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.buf)
+	if n <= 0 {
+		r.fail(errTruncated)
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+// uintN reads an unsigned integer that must fit in bits.
+func (r *reader) uintN(bits int) uint64 {
+	v := r.uint()
+	if bits < 64 && v>>bits != 0 {
+		r.fail(fmt.Errorf("wire: %d overflows uint%d", v, bits))
+		return 0
+	}
+	return v
+}
+
+func (r *reader) bool() bool {
+	b := r.take(1)
+	if b == nil {
+		return false
+	}
+	switch b[0] {
+	case 0:
+		return false
+	case 1:
+		return true
+	default:
+		r.fail(fmt.Errorf("wire: invalid bool %d", b[0]))
+		return false
+	}
+}
+
+func (r *reader) float() float64 {
+	b := r.take(8)
+	if b == nil {
+		return 0
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b))
+}
+
+// take returns the next n bytes, or nil if there are fewer.
+func (r *reader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.buf) {
+		r.fail(errTruncated)
+		return nil
+	}
+	b := r.buf[:n:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *reader) fixed(dst []byte) {
+	if b := r.take(len(dst)); b != nil {
+		copy(dst, b)
+	}
+}
+
+func (r *reader) string() string {
+	n := r.uint()
+	if n > uint64(len(r.buf)) {
+		r.fail(errTruncated)
+		return ""
+	}
+	return string(r.take(int(n)))
+}
+
+func (r *reader) bytes() []byte {
+	n, ok := r.length(1)
+	if !ok {
+		return nil
+	}
+	return append(make([]byte, 0, n), r.take(n)...)
+}
+
+// length reads the tag of a slice or map whose elements take at least minSize bytes
+// each, and reports whether it is non-nil. The check against the remaining data keeps
+// corrupt lengths from allocating.
+func (r *reader) length(minSize int) (n int, ok bool) {
+	tag := r.uint()
+	if tag == 0 || r.err != nil {
+		return 0, false
+	}
+	if tag-1 > uint64(len(r.buf)/minSize) {
+		r.fail(errTruncated)
+		return 0, false
+	}
+	return int(tag - 1), true
+}
+
+func (r *reader) bigInt() *big.Int {
+	tag := r.uint()
+	if tag == 0 || r.err != nil {
+		return nil
+	}
+	tag--
+	b := r.take(int(min(tag>>1, uint64(len(r.buf))+1)))
+	if b == nil {
+		return nil
+	}
+	x := new(big.Int).SetBytes(b)
+	if tag&1 == 1 {
+		x.Neg(x)
+	}
+	return x
+}
+
+func (r *reader) optUint() *uint64 {
+	if !r.bool() {
+		return nil
+	}
+	v := r.uint()
+	return &v
+}
+
+func (r *reader) optUintN(bits int) *uint64 {
+	if !r.bool() {
+		return nil
+	}
+	v := r.uintN(bits)
+	return &v
+}
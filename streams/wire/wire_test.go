@@ -0,0 +1,218 @@
+package wire
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/defistate/defistate-client-go/engine"
+	"github.com/defistate/defistate-client-go/protocols/poolregistry"
+	"github.com/defistate/defistate-client-go/protocols/solidly"
+	"github.com/defistate/defistate-client-go/protocols/tokenpoolregistry"
+	"github.com/defistate/defistate-client-go/protocols/tokenregistry"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv2"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv3"
+	"github.com/defistate/defistate-client-go/protocols/uniswapv4"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func roundTrip[T any](t *testing.T, v T) {
+	t.Helper()
+	data, err := Marshal(v)
+	require.NoError(t, err)
+	got, err := Unmarshal[T](data)
+	require.NoError(t, err)
+	assert.Equal(t, v, got)
+}
+
+func TestRoundTrip(t *testing.T) {
+	v2Absent := uniswapv2.Pool{ID: 3, Reserve0: big.NewInt(1), Reserve1: big.NewInt(2)}
+	v2Absent.ClearFeeBps()
+	v3Pool := uniswapv3.Pool{
+		PoolViewMinimal: uniswapv3.PoolViewMinimal{ID: 7, Token0: 1, Token1: 2, Fee: 500, TickSpacing: 10, Tick: -887272, Liquidity: big.NewInt(1e18), SqrtPriceX96: new(big.Int).Lsh(big.NewInt(1), 96)},
+		Ticks: []uniswapv3.TickInfo{
+			{Index: -887270, LiquidityGross: big.NewInt(1e18), LiquidityNet: big.NewInt(1e18)},
+			{Index: 887270, LiquidityGross: big.NewInt(1e18), LiquidityNet: big.NewInt(-1e18)},
+		},
+		FeeGrowthGlobal0X128:   big.NewInt(0),
+		FeeOverride:            ptr(uint64(0)),
+		FeeProtocol:            ptr(uint32(0x44)),
+		ProtocolFeeDenominator: 10_000,
+		Decimals0:              ptr(uint8(0)),
+		Decimals1:              ptr(uint8(18)),
+		LastUpdatedBlock:       ptr(uint64(0)),
+	}
+
+	t.Run("tokens", func(t *testing.T) {
+		roundTrip(t, []tokenregistry.Token{
+			{ID: 1, Address: [20]byte{0xc0, 0x2a}, Name: "Wrapped Ether", Symbol: "WETH", Decimals: 18, FeeOnTransferPercent: 0.5, GasForTransfer: 30_000},
+			{},
+		})
+		roundTrip(t, tokenregistry.TokenSystemDiff{Updates: []tokenregistry.Token{{ID: 2}}, Deletions: []uint64{0}})
+	})
+
+	t.Run("pool registry", func(t *testing.T) {
+		roundTrip(t, poolregistry.PoolRegistry{
+			Pools:     []poolregistry.Pool{{ID: 1, Key: poolregistry.PoolKey{1}, Protocol: 0}},
+			Protocols: map[uint16]engine.ProtocolID{0: "uniswap-v2", 1: "uniswap-v3"},
+		})
+		roundTrip(t, poolregistry.PoolRegistryDiff{PoolDeletions: []uint64{4}, ProtocolAdditions: map[uint16]engine.ProtocolID{}, ProtocolDeletions: []uint16{0}})
+	})
+
+	t.Run("token pool graph", func(t *testing.T) {
+		view := &tokenpoolregistry.TokenPoolRegistryView{
+			Tokens:      []uint64{1, 2},
+			Pools:       []uint64{10},
+			Adjacency:   [][]int{{0}, {1}},
+			EdgeTargets: []int{1, 0},
+			EdgePools:   [][]int{{0}, {}},
+		}
+		roundTrip(t, view)
+		roundTrip(t, tokenpoolregistry.TokenPoolRegistryDiff{Data: view})
+		roundTrip(t, tokenpoolregistry.TokenPoolRegistryDiff{})
+	})
+
+	t.Run("uniswap v2", func(t *testing.T) {
+		pools := []uniswapv2.Pool{
+			{ID: 1, Token0: 1, Token1: 2, Reserve0: big.NewInt(0), Reserve1: nil, FeeBps: 0, Decimals0: ptr(uint8(0)), Decimals1: ptr(uint8(6)), LastUpdatedBlock: ptr(uint64(0))},
+			{ID: 2, Reserve0: big.NewInt(-5), Reserve1: new(big.Int).Lsh(big.NewInt(1), 255), FeeBps: 25, Type: 1},
+			v2Absent,
+		}
+		roundTrip(t, pools)
+		roundTrip(t, uniswapv2.UniswapV2SystemDiff{Additions: pools, Updates: []uniswapv2.Pool{}})
+
+		got, err := Unmarshal[[]uniswapv2.Pool](must(Marshal(pools)))
+		require.NoError(t, err)
+		assert.True(t, got[0].HasFeeBps(), "a zero fee is the pool's fee")
+		assert.Equal(t, uint16(uniswapv2.DefaultFeeBps), got[2].SwapFeeBps(), "an absent fee stays absent")
+	})
+
+	t.Run("uniswap v3", func(t *testing.T) {
+		roundTrip(t, []uniswapv3.Pool{v3Pool, {Ticks: []uniswapv3.TickInfo{}}})
+		roundTrip(t, uniswapv3.UniswapV3SystemDiff{Updates: []uniswapv3.Pool{v3Pool}, Deletions: []uint64{9}})
+	})
+
+	t.Run("uniswap v4", func(t *testing.T) {
+		pools := []uniswapv4.Pool{{
+			ID: 1, PoolID: poolregistry.PoolKey{0xff}, Token0: 0, Token1: 2, Fee: uniswapv4.DynamicFeeFlag, TickSpacing: 60,
+			Hooks: [20]byte{0x40}, Tick: 0, Liquidity: big.NewInt(0), SqrtPriceX96: big.NewInt(1),
+			Ticks: v3Pool.Ticks, LPFee: ptr(uint64(0)), LastUpdatedBlock: ptr(uint64(12)),
+		}}
+		roundTrip(t, pools)
+		roundTrip(t, uniswapv4.UniswapV4SystemDiff{Additions: pools})
+	})
+
+	t.Run("solidly", func(t *testing.T) {
+		pools := []solidly.Pool{{ID: 1, Token0: 1, Token1: 2, Reserve0: big.NewInt(10), Reserve1: big.NewInt(0), Stable: true, FeeBps: 0, Decimals0: 6, Decimals1: 18}}
+		roundTrip(t, pools)
+		roundTrip(t, solidly.SolidlySystemDiff{Deletions: []uint64{1}})
+	})
+
+	t.Run("envelopes", func(t *testing.T) {
+		block := engine.BlockSummary{Number: big.NewInt(0), Hash: [32]byte{1}, Timestamp: 1, ReceivedAt: -1, BaseFee: big.NewInt(0), TxHash: [32]byte{2}}
+		protocols := map[engine.ProtocolID]ProtocolData{
+			"uniswap-v3":  {Meta: engine.ProtocolMeta{Name: "Uniswap V3", Tags: []string{"dex"}}, SyncedBlockNumber: ptr(uint64(0)), Schema: uniswapv3.Schema, Data: must(Marshal([]uniswapv3.Pool{v3Pool}))},
+			"broken":      {Schema: uniswapv2.Schema, Error: "indexer down"},
+			"empty-state": {Schema: uniswapv2.Schema, Data: []byte{}},
+		}
+		roundTrip(t, &State{ChainID: 1, Timestamp: 2, Block: block, Protocols: protocols})
+		roundTrip(t, &StateDiff{FromBlock: 0, ToBlock: block, Protocols: protocols})
+		roundTrip(t, &State{})
+	})
+}
+
+func TestUnmarshal_Invalid(t *testing.T) {
+	data := must(Marshal([]uniswapv3.Pool{{PoolViewMinimal: uniswapv3.PoolViewMinimal{ID: 1, Liquidity: big.NewInt(1e18)}}}))
+
+	for i := range data {
+		_, err := Unmarshal[[]uniswapv3.Pool](data[:i])
+		assert.Error(t, err, "truncated to %d bytes", i)
+	}
+	_, err := Unmarshal[[]uniswapv3.Pool](append(data, 0))
+	assert.ErrorContains(t, err, "trailing")
+	_, err = Unmarshal[[]uniswapv2.Pool]([]byte{0xff, 0xff, 0xff, 0xff, 0x0f})
+	assert.Error(t, err, "a length beyond the data")
+	_, err = Unmarshal[[]tokenregistry.Token]([]byte{2, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x80, 0x02, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	assert.ErrorContains(t, err, "overflows uint8")
+
+	_, err = Marshal([]uint64{1})
+	assert.ErrorContains(t, err, "unsupported type")
+	_, err = Unmarshal[[]uint64](nil)
+	assert.ErrorContains(t, err, "unsupported type")
+}
+
+func must(data []byte, err error) []byte {
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// benchmarkPools returns n Uniswap V3 pools with ticks ticks each, the bulk of a
+// mainnet state.
+func benchmarkPools(n, ticks int) []uniswapv3.Pool {
+	liquidity, _ := new(big.Int).SetString("1234567890123456789012", 10)
+	sqrtPrice, _ := new(big.Int).SetString("1461446703485210103287273052203988822378723970341", 10)
+	pools := make([]uniswapv3.Pool, n)
+	for i := range pools {
+		pool := uniswapv3.Pool{
+			PoolViewMinimal:  uniswapv3.PoolViewMinimal{ID: uint64(i), Token0: uint64(2 * i), Token1: uint64(2*i + 1), Fee: 3000, TickSpacing: 60, Tick: int64(-200_000 + i), Liquidity: liquidity, SqrtPriceX96: sqrtPrice},
+			Ticks:            make([]uniswapv3.TickInfo, ticks),
+			LastUpdatedBlock: ptr(uint64(21_000_000 + i)),
+		}
+		for j := range pool.Ticks {
+			pool.Ticks[j] = uniswapv3.TickInfo{Index: int64(-887_220 + 60*j), LiquidityGross: liquidity, LiquidityNet: new(big.Int).Neg(liquidity)}
+		}
+		pools[i] = pool
+	}
+	return pools
+}
+
+// BenchmarkCodec compares the size and the encode and decode speed of the binary
+// encoding with JSON on Uniswap V3 pools.
+func BenchmarkCodec(b *testing.B) {
+	pools := benchmarkPools(1_000, 50)
+
+	b.Run("encode/binary", func(b *testing.B) {
+		b.ReportAllocs()
+		var data []byte
+		for i := 0; i < b.N; i++ {
+			data, _ = Marshal(pools)
+		}
+		b.ReportMetric(float64(len(data)), "bytes")
+	})
+	b.Run("encode/json", func(b *testing.B) {
+		b.ReportAllocs()
+		var data []byte
+		for i := 0; i < b.N; i++ {
+			data, _ = json.Marshal(pools)
+		}
+		b.ReportMetric(float64(len(data)), "bytes")
+	})
+
+	binary := must(Marshal(pools))
+	b.Run("decode/binary", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(binary)))
+		for i := 0; i < b.N; i++ {
+			if _, err := Unmarshal[[]uniswapv3.Pool](binary); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	jsonData := must(json.Marshal(pools))
+	b.Run("decode/json", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(jsonData)))
+		for i := 0; i < b.N; i++ {
+			var decoded []uniswapv3.Pool
+			if err := json.Unmarshal(jsonData, &decoded); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package tokenmeta
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	nameSelector     = selector("name()")
+	symbolSelector   = selector("symbol()")
+	decimalsSelector = selector("decimals()")
+)
+
+// selector returns the 4-byte function selector of signature.
+func selector(signature string) [4]byte {
+	var s [4]byte
+	copy(s[:], crypto.Keccak256([]byte(signature)))
+	return s
+}
+
+// decodeString decodes the return value of name() or symbol(): an ABI string, or the
+// bytes32 some older tokens (e.g. MKR) return instead.
+func decodeString(out []byte) (string, error) {
+	var raw []byte
+	switch {
+	case len(out) == 32:
+		raw = bytes.TrimRight(out, "\x00")
+	case len(out) >= 64:
+		offset := new(big.Int).SetBytes(out[:32])
+		if !offset.IsUint64() || offset.Uint64() > uint64(len(out)-32) {
+			return "", errors.New("string offset out of range")
+		}
+		start := offset.Uint64()
+		length := new(big.Int).SetBytes(out[start : start+32])
+		if !length.IsUint64() || length.Uint64() > uint64(len(out))-start-32 {
+			return "", errors.New("string length out of range")
+		}
+		raw = out[start+32 : start+32+length.Uint64()]
+	default:
+		return "", errors.New("unexpected string encoding")
+	}
+	if !utf8.Valid(raw) {
+		return "", errors.New("string is not valid UTF-8")
+	}
+	return strings.TrimSpace(string(raw)), nil
+}
+
+// decodeUint8 decodes the return value of decimals().
+func decodeUint8(out []byte) (uint8, error) {
+	if len(out) < 32 {
+		return 0, errors.New("unexpected uint encoding")
+	}
+	v := new(big.Int).SetBytes(out[:32])
+	if !v.IsUint64() || v.Uint64() > 255 {
+		return 0, errors.New("decimals out of range")
+	}
+	return uint8(v.Uint64()), nil
+}
@@ -0,0 +1,85 @@
+package tokenmeta
+
+// Solidity dispatches external calls by comparing the call's selector against each
+// function's selector, pushed with PUSH4. The selectors a contract pushes therefore list
+// the functions it exposes, which hints at how its balances behave.
+
+// feeOnTransferSelectors are functions typical of tokens that tax transfers, such as the
+// widespread "reflection" and auto-liquidity token templates.
+var feeOnTransferSelectors = selectorSet(
+	"excludeFromFee(address)",
+	"includeInFee(address)",
+	"isExcludedFromFee(address)",
+	"isExcludedFromFees(address)",
+	"excludeFromFees(address,bool)",
+	"setTaxFeePercent(uint256)",
+	"setLiquidityFeePercent(uint256)",
+	"_taxFee()",
+	"taxFee()",
+	"_liquidityFee()",
+	"buyTotalFees()",
+	"sellTotalFees()",
+	"setSwapAndLiquifyEnabled(bool)",
+	"swapAndLiquifyEnabled()",
+	"reflectionFromToken(uint256,bool)",
+	"tokenFromReflection(uint256)",
+)
+
+// rebasingSelectors are functions typical of tokens whose balances change without
+// transfers, e.g. elastic-supply tokens, share-based staking tokens and interest-bearing
+// deposit tokens.
+var rebasingSelectors = selectorSet(
+	"rebase(uint256,int256)",
+	"rebase()",
+	"rebaseOptIn()",
+	"rebasingCreditsPerToken()",
+	"sharesOf(address)",
+	"getSharesByPooledEth(uint256)",
+	"scaledBalanceOf(address)",
+	"gonsPerFragment()",
+)
+
+type selectors map[[4]byte]struct{}
+
+func selectorSet(signatures ...string) selectors {
+	set := make(selectors, len(signatures))
+	for _, signature := range signatures {
+		set[selector(signature)] = struct{}{}
+	}
+	return set
+}
+
+func (s selectors) containsAny(other selectors) bool {
+	for sel := range other {
+		if _, ok := s[sel]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	opPush1  = 0x60
+	opPush4  = 0x63
+	opPush32 = 0x7f
+)
+
+// pushedSelectors returns the operands of every PUSH4 in code, skipping the data of
+// other pushes so it is not mistaken for instructions.
+func pushedSelectors(code []byte) selectors {
+	set := make(selectors)
+	for pc := 0; pc < len(code); pc++ {
+		op := code[pc]
+		if op < opPush1 || op > opPush32 {
+			continue
+		}
+		size := int(op-opPush1) + 1
+		if op == opPush4 && pc+size < len(code) {
+			var sel [4]byte
+			copy(sel[:], code[pc+1:pc+1+size])
+			set[sel] = struct{}{}
+		}
+		pc += size
+	}
+	return set
+}
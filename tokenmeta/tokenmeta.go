@@ -0,0 +1,259 @@
+// Package tokenmeta backfills token metadata the stream does not carry. Given an RPC
+// endpoint, an Enricher reads a token's name, symbol and decimals from the token contract
+// and flags likely fee-on-transfer and rebasing tokens from its bytecode. Lookups are
+// cached in memory and, optionally, on disk, so results are stable across runs.
+package tokenmeta
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/defistate/defistate-client-go/addr"
+	"github.com/defistate/defistate-client-go/protocols/tokenregistry"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// Caller reads contracts at the latest block. *ethclient.Client implements it.
+type Caller interface {
+	CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+	CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error)
+}
+
+// Metadata is what an Enricher learned about a token.
+type Metadata struct {
+	Name     string `json:"name"`
+	Symbol   string `json:"symbol"`
+	Decimals uint8  `json:"decimals"`
+	// HasDecimals is false if the token does not implement decimals().
+	HasDecimals bool `json:"hasDecimals"`
+	// FeeOnTransfer and Rebasing flag tokens whose bytecode exposes functions typical of
+	// transfer taxes or of balances that change without transfers. They are heuristics:
+	// a proxy's implementation is not inspected, and a flagged token may not charge a fee.
+	FeeOnTransfer bool `json:"feeOnTransfer"`
+	Rebasing      bool `json:"rebasing"`
+}
+
+// cacheFile is the name of the on-disk cache inside Config.CacheDir.
+const cacheFile = "tokens.json"
+
+// Config configures an Enricher.
+type Config struct {
+	Caller Caller
+	// CacheDir, if set, persists lookups to "<dir>/tokens.json" and loads them on start.
+	CacheDir string
+}
+
+// Enricher looks up token metadata on chain. It is safe for concurrent use.
+type Enricher struct {
+	caller   Caller
+	cacheDir string
+
+	mu    sync.Mutex
+	cache map[addr.Address]Metadata
+	// dirty is set when cache holds lookups that were not written to disk yet.
+	dirty bool
+}
+
+// Dial connects to the RPC endpoint at url and returns an Enricher reading through it.
+func Dial(ctx context.Context, url string, cacheDir string) (*Enricher, error) {
+	client, err := ethclient.DialContext(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("tokenmeta: dialing %s: %w", url, err)
+	}
+	return NewEnricher(Config{Caller: client, CacheDir: cacheDir})
+}
+
+// NewEnricher returns an Enricher, loading the disk cache if there is one.
+func NewEnricher(cfg Config) (*Enricher, error) {
+	if cfg.Caller == nil {
+		return nil, errors.New("tokenmeta: caller is required")
+	}
+	e := &Enricher{
+		caller:   cfg.Caller,
+		cacheDir: cfg.CacheDir,
+		cache:    make(map[addr.Address]Metadata),
+	}
+	if cfg.CacheDir == "" {
+		return e, nil
+	}
+	if err := os.MkdirAll(cfg.CacheDir, 0o755); err != nil {
+		return nil, fmt.Errorf("tokenmeta: creating %s: %w", cfg.CacheDir, err)
+	}
+	data, err := os.ReadFile(filepath.Join(cfg.CacheDir, cacheFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return e, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tokenmeta: reading cache: %w", err)
+	}
+	if err := json.Unmarshal(data, &e.cache); err != nil {
+		return nil, fmt.Errorf("tokenmeta: decoding cache: %w", err)
+	}
+	return e, nil
+}
+
+// Lookup returns the metadata of the token at address, reading it from the chain on the
+// first lookup. A token that implements none of name, symbol and decimals, or has no
+// code, is an error and is not cached, so a later lookup retries it. A new result is
+// written to the disk cache before Lookup returns.
+func (e *Enricher) Lookup(ctx context.Context, address addr.Address) (Metadata, error) {
+	meta, err := e.lookup(ctx, address)
+	if err != nil {
+		return Metadata{}, err
+	}
+	return meta, e.Flush()
+}
+
+// lookup is Lookup without the disk write: a new result only marks the cache dirty.
+func (e *Enricher) lookup(ctx context.Context, address addr.Address) (Metadata, error) {
+	e.mu.Lock()
+	meta, ok := e.cache[address]
+	e.mu.Unlock()
+	if ok {
+		return meta, nil
+	}
+
+	meta, err := e.fetch(ctx, address)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.cache[address] = meta
+	e.dirty = true
+	return meta, nil
+}
+
+// Flush writes the cache to disk if it holds lookups that were not written yet. It does
+// nothing without a CacheDir.
+func (e *Enricher) Flush() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.dirty || e.cacheDir == "" {
+		return nil
+	}
+	if err := e.save(); err != nil {
+		return err
+	}
+	e.dirty = false
+	return nil
+}
+
+// Enrich returns a copy of tokens with missing names, symbols and decimals backfilled
+// from the chain. Tokens that have all three are not looked up; tokens whose lookup
+// fails are returned unchanged, and the first such error is returned alongside. New
+// lookups are written to the disk cache once, when every token has been looked up.
+func (e *Enricher) Enrich(ctx context.Context, tokens []tokenregistry.Token) ([]tokenregistry.Token, error) {
+	enriched := make([]tokenregistry.Token, len(tokens))
+	copy(enriched, tokens)
+
+	var firstErr error
+	for i := range enriched {
+		token := &enriched[i]
+		if token.Name != "" && token.Symbol != "" && token.Decimals != 0 {
+			continue
+		}
+		meta, err := e.lookup(ctx, token.Address)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("tokenmeta: token %d (%s): %w", token.ID, token.Address, err)
+			}
+			continue
+		}
+		if token.Name == "" {
+			token.Name = meta.Name
+		}
+		if token.Symbol == "" {
+			token.Symbol = meta.Symbol
+		}
+		if token.Decimals == 0 && meta.HasDecimals {
+			token.Decimals = meta.Decimals
+		}
+	}
+	if err := e.Flush(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return enriched, firstErr
+}
+
+// fetch reads the metadata of address from the chain.
+func (e *Enricher) fetch(ctx context.Context, address addr.Address) (Metadata, error) {
+	contract := address.Common()
+	code, err := e.caller.CodeAt(ctx, contract, nil)
+	if err != nil {
+		return Metadata{}, err
+	}
+	if len(code) == 0 {
+		return Metadata{}, errors.New("no contract code")
+	}
+
+	var meta Metadata
+	var found bool
+	if out, err := e.call(ctx, contract, nameSelector); err == nil {
+		if meta.Name, err = decodeString(out); err == nil {
+			found = true
+		}
+	}
+	if out, err := e.call(ctx, contract, symbolSelector); err == nil {
+		if meta.Symbol, err = decodeString(out); err == nil {
+			found = true
+		}
+	}
+	if out, err := e.call(ctx, contract, decimalsSelector); err == nil {
+		if meta.Decimals, err = decodeUint8(out); err == nil {
+			meta.HasDecimals, found = true, true
+		}
+	}
+	if !found {
+		// Calls that fail for every field usually mean the endpoint is down, not that the
+		// token is broken; report it instead of caching an empty result.
+		return Metadata{}, errors.New("token implements none of name, symbol and decimals")
+	}
+
+	selectors := pushedSelectors(code)
+	meta.FeeOnTransfer = selectors.containsAny(feeOnTransferSelectors)
+	meta.Rebasing = selectors.containsAny(rebasingSelectors)
+	return meta, nil
+}
+
+func (e *Enricher) call(ctx context.Context, contract common.Address, selector [4]byte) ([]byte, error) {
+	return e.caller.CallContract(ctx, ethereum.CallMsg{To: &contract, Data: selector[:]}, nil)
+}
+
+// save writes the cache to disk. It is written to a temporary file, synced and renamed
+// into place, so a crash never leaves a truncated cache. e.mu must be held.
+func (e *Enricher) save() error {
+	data, err := json.Marshal(e.cache)
+	if err != nil {
+		return fmt.Errorf("tokenmeta: encoding cache: %w", err)
+	}
+	tmp, err := os.CreateTemp(e.cacheDir, cacheFile+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("tokenmeta: writing cache: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("tokenmeta: writing cache: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("tokenmeta: writing cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("tokenmeta: writing cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(e.cacheDir, cacheFile)); err != nil {
+		return fmt.Errorf("tokenmeta: writing cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package tokenmeta
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/defistate/defistate-client-go/addr"
+	"github.com/defistate/defistate-client-go/protocols/tokenregistry"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeToken struct {
+	code    []byte
+	returns map[[4]byte][]byte
+}
+
+// fakeCaller serves contract calls from canned return values, counting the calls.
+type fakeCaller struct {
+	tokens map[common.Address]fakeToken
+	calls  int
+	onCall func()
+}
+
+func (f *fakeCaller) CallContract(_ context.Context, msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	f.calls++
+	if f.onCall != nil {
+		f.onCall()
+	}
+	var sel [4]byte
+	copy(sel[:], msg.Data)
+	out, ok := f.tokens[*msg.To].returns[sel]
+	if !ok {
+		return nil, errors.New("execution reverted")
+	}
+	return out, nil
+}
+
+func (f *fakeCaller) CodeAt(_ context.Context, account common.Address, _ *big.Int) ([]byte, error) {
+	return f.tokens[account].code, nil
+}
+
+func abiString(s string) []byte {
+	out := make([]byte, 64, 96)
+	out[31] = 0x20
+	new(big.Int).SetInt64(int64(len(s))).FillBytes(out[32:64])
+	padded := make([]byte, (len(s)+31)/32*32)
+	copy(padded, s)
+	return append(out, padded...)
+}
+
+func abiBytes32(s string) []byte {
+	out := make([]byte, 32)
+	copy(out, s)
+	return out
+}
+
+func abiUint(v int64) []byte {
+	return new(big.Int).SetInt64(v).FillBytes(make([]byte, 32))
+}
+
+// dispatcherCode returns bytecode that pushes the selectors of signatures, surrounded by
+// push data that merely looks like a PUSH4.
+func dispatcherCode(signatures ...string) []byte {
+	code := []byte{0x60, 0x80, 0x60, 0x40, 0x52}
+	for _, signature := range signatures {
+		sel := selector(signature)
+		code = append(code, 0x80, opPush4)
+		code = append(code, sel[:]...)
+		code = append(code, 0x14, 0x61, 0x00, 0x10, 0x57)
+	}
+	return code
+}
+
+var (
+	usdc = addr.FromCommon(common.HexToAddress("0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"))
+	mkr  = addr.FromCommon(common.HexToAddress("0x9f8f72aa9304c8b593d555f12ef6589cc3a579a2"))
+	safe = addr.FromCommon(common.HexToAddress("0x0000000000000000000000000000000000001234"))
+	eoa  = addr.FromCommon(common.HexToAddress("0x0000000000000000000000000000000000005678"))
+)
+
+func newFakeCaller() *fakeCaller {
+	return &fakeCaller{tokens: map[common.Address]fakeToken{
+		usdc.Common(): {
+			code: dispatcherCode("transfer(address,uint256)"),
+			returns: map[[4]byte][]byte{
+				nameSelector:     abiString("USD Coin"),
+				symbolSelector:   abiString("USDC"),
+				decimalsSelector: abiUint(6),
+			},
+		},
+		mkr.Common(): {
+			code: dispatcherCode("transfer(address,uint256)"),
+			returns: map[[4]byte][]byte{
+				nameSelector:     abiBytes32("Maker"),
+				symbolSelector:   abiBytes32("MKR"),
+				decimalsSelector: abiUint(18),
+			},
+		},
+		safe.Common(): {
+			code: dispatcherCode("transfer(address,uint256)", "excludeFromFee(address)", "sharesOf(address)"),
+			returns: map[[4]byte][]byte{
+				symbolSelector:   abiString("SAFE"),
+				decimalsSelector: abiUint(9),
+			},
+		},
+	}}
+}
+
+func TestEnricher_Lookup(t *testing.T) {
+	e, err := NewEnricher(Config{Caller: newFakeCaller()})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	meta, err := e.Lookup(ctx, usdc)
+	require.NoError(t, err)
+	assert.Equal(t, Metadata{Name: "USD Coin", Symbol: "USDC", Decimals: 6, HasDecimals: true}, meta)
+
+	meta, err = e.Lookup(ctx, mkr)
+	require.NoError(t, err)
+	assert.Equal(t, Metadata{Name: "Maker", Symbol: "MKR", Decimals: 18, HasDecimals: true}, meta, "bytes32 names are decoded")
+
+	meta, err = e.Lookup(ctx, safe)
+	require.NoError(t, err)
+	assert.Empty(t, meta.Name, "name() reverts")
+	assert.True(t, meta.FeeOnTransfer)
+	assert.True(t, meta.Rebasing)
+
+	_, err = e.Lookup(ctx, eoa)
+	assert.Error(t, err, "an account without code is not a token")
+}
+
+func TestEnricher_Enrich(t *testing.T) {
+	caller := newFakeCaller()
+	e, err := NewEnricher(Config{Caller: caller})
+	require.NoError(t, err)
+
+	tokens := []tokenregistry.Token{
+		{ID: 1, Address: usdc, Name: "USD Coin", Symbol: "USDC", Decimals: 6},
+		{ID: 2, Address: mkr, Symbol: "MKR"},
+		{ID: 3, Address: eoa, Symbol: "EOA"},
+	}
+	enriched, err := e.Enrich(context.Background(), tokens)
+	assert.ErrorContains(t, err, "token 3")
+
+	assert.Equal(t, tokens[0], enriched[0])
+	assert.Equal(t, "Maker", enriched[1].Name)
+	assert.Equal(t, uint8(18), enriched[1].Decimals)
+	assert.Equal(t, tokens[2], enriched[2])
+	assert.Empty(t, tokens[1].Name, "the input is not modified")
+	assert.Equal(t, 3, caller.calls, "complete tokens are not looked up")
+}
+
+func TestEnricher_DiskCache(t *testing.T) {
+	dir := t.TempDir()
+	caller := newFakeCaller()
+	e, err := NewEnricher(Config{Caller: caller, CacheDir: dir})
+	require.NoError(t, err)
+	want, err := e.Lookup(context.Background(), safe)
+	require.NoError(t, err)
+
+	// A second run is served from disk, even though the chain is now unreachable.
+	reloaded, err := NewEnricher(Config{Caller: &fakeCaller{}, CacheDir: dir})
+	require.NoError(t, err)
+	got, err := reloaded.Lookup(context.Background(), safe)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestEnricher_EnrichFlushesOnce(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, cacheFile)
+	caller := newFakeCaller()
+	// No lookup writes the cache while Enrich is still looking tokens up.
+	caller.onCall = func() {
+		_, err := os.Stat(path)
+		assert.True(t, errors.Is(err, os.ErrNotExist), "cache written during Enrich")
+	}
+	e, err := NewEnricher(Config{Caller: caller, CacheDir: dir})
+	require.NoError(t, err)
+
+	_, err = e.Enrich(context.Background(), []tokenregistry.Token{{ID: 1, Address: usdc}, {ID: 2, Address: mkr}, {ID: 3, Address: safe}})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var cached map[addr.Address]Metadata
+	require.NoError(t, json.Unmarshal(data, &cached))
+	assert.Len(t, cached, 3)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary file is left behind")
+
+	// Nothing new to write.
+	caller.onCall = nil
+	require.NoError(t, os.Remove(path))
+	require.NoError(t, e.Flush())
+	assert.NoFileExists(t, path)
+}
+
+func TestPushedSelectors_SkipsPushData(t *testing.T) {
+	sel := selector("rebase()")
+	// PUSH5 whose data contains a PUSH4 opcode followed by the selector.
+	code := append([]byte{0x64, opPush4}, sel[:]...)
+	assert.NotContains(t, pushedSelectors(code), sel)
+
+	code = append([]byte{opPush4}, sel[:]...)
+	code = append(code, 0x14)
+	assert.Contains(t, pushedSelectors(code), sel)
+}
+
+func TestDecodeUint8_RejectsOutOfRange(t *testing.T) {
+	_, err := decodeUint8(abiUint(256))
+	assert.Error(t, err)
+}